@@ -8,6 +8,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 )
@@ -18,13 +19,18 @@ const (
 	OpListDir       = "list_dir"
 	OpReadProcNet   = "read_proc_net"
 	OpCheckPort     = "check_port"
+	OpSocketOwners  = "socket_owners"
 )
 
+// maxSocketInodes bounds the sockets one request may resolve
+const maxSocketInodes = 4096
+
 // Request represents a privileged operation request
 type Request struct {
 	Operation string `json:"operation"`
 	Path      string `json:"path,omitempty"`
 	Port      int    `json:"port,omitempty"`
+	Inodes    []uint64 `json:"inodes,omitempty"`
 }
 
 // Response represents the operation result
@@ -76,6 +82,8 @@ func main() {
 		handleReadProcNet(req.Path)
 	case OpCheckPort:
 		handleCheckPort(req.Port)
+	case OpSocketOwners:
+		handleSocketOwners(req.Inodes)
 	default:
 		respondError(fmt.Sprintf("Unknown operation: %s", req.Operation))
 	}
@@ -183,6 +191,59 @@ func handleCheckPort(port int) {
 	})
 }
 
+// handleSocketOwners finds the processes holding sockets, by inode, for
+// discovery to tie listening ports to processes of other users. Only PIDs
+// are returned; their command lines are readable without privileges.
+func handleSocketOwners(inodes []uint64) {
+	if len(inodes) == 0 || len(inodes) > maxSocketInodes {
+		respondError(fmt.Sprintf("Between 1 and %d inodes required", maxSocketInodes))
+		return
+	}
+	wanted := make(map[uint64]bool, len(inodes))
+	for _, inode := range inodes {
+		wanted[inode] = true
+	}
+
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		respondError(fmt.Sprintf("Failed to list processes: %v", err))
+		return
+	}
+
+	// Workers inherit their parent's listening socket; the lowest PID,
+	// usually the parent, owns it
+	owners := make(map[string]int)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, err := ioutil.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") || !strings.HasSuffix(link, "]") {
+				continue
+			}
+			inode, err := strconv.ParseUint(link[len("socket:["):len(link)-1], 10, 64)
+			if err != nil || !wanted[inode] {
+				continue
+			}
+			key := strconv.FormatUint(inode, 10)
+			if owner, seen := owners[key]; !seen || pid < owner {
+				owners[key] = pid
+			}
+		}
+	}
+
+	respondSuccess(map[string]interface{}{
+		"owners": owners,
+	})
+}
+
 func isPathAllowed(path string) bool {
 	// Check exact match first
 	if allowedPaths[path] {
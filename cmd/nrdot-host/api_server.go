@@ -6,11 +6,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	configengine "github.com/newrelic/nrdot-host/nrdot-config-engine"
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v3"
 )
@@ -92,12 +92,14 @@ func (s *standaloneAPIServer) Stop(ctx context.Context) error {
 	return nil
 }
 
-// loadConfig loads configuration from file
+// loadConfig loads configuration from file, merged with its conf.d
+// overrides
 func (s *standaloneAPIServer) loadConfig() error {
-	data, err := os.ReadFile(s.configFile)
+	layered, err := configengine.LoadLayeredConfig(s.configFile)
 	if err != nil {
 		return err
 	}
+	data := layered.Data
 	
 	var config map[string]interface{}
 	if err := yaml.Unmarshal(data, &config); err != nil {
@@ -171,15 +173,15 @@ func (s *standaloneAPIServer) handleStatus(w http.ResponseWriter, r *http.Reques
 }
 
 func (s *standaloneAPIServer) handleGetConfig(w http.ResponseWriter, r *http.Request) {
-	// Read current config file
-	data, err := os.ReadFile(s.configFile)
+	// Read current config file, merged with its overrides
+	layered, err := configengine.LoadLayeredConfig(s.configFile)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to read config: %v", err), http.StatusInternalServerError)
 		return
 	}
 	
 	w.Header().Set("Content-Type", "application/yaml")
-	w.Write(data)
+	w.Write(layered.Data)
 }
 
 func (s *standaloneAPIServer) handleValidateConfig(w http.ResponseWriter, r *http.Request) {
@@ -5,6 +5,7 @@ go 1.21
 require (
 	github.com/gorilla/mux v1.8.1
 	github.com/newrelic/nrdot-host/nrdot-common v0.0.0-00010101000000-000000000000
+	github.com/newrelic/nrdot-host/nrdot-config-engine v0.0.0-00010101000000-000000000000
 	github.com/newrelic/nrdot-host/nrdot-supervisor v0.0.0-00010101000000-000000000000
 	go.uber.org/zap v1.27.0
 	gopkg.in/yaml.v3 v3.0.1
@@ -19,7 +20,6 @@ require (
 	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/grpc-ecosystem/grpc-gateway/v2 v2.19.0 // indirect
 	github.com/newrelic/nrdot-host/nrdot-api-server v0.0.0-00010101000000-000000000000 // indirect
-	github.com/newrelic/nrdot-host/nrdot-config-engine v0.0.0-00010101000000-000000000000 // indirect
 	github.com/newrelic/nrdot-host/nrdot-schema v0.0.0 // indirect
 	github.com/newrelic/nrdot-host/nrdot-telemetry-client v0.0.0-00010101000000-000000000000 // indirect
 	github.com/newrelic/nrdot-host/nrdot-template-lib v0.0.0-00010101000000-000000000000 // indirect
@@ -35,6 +35,7 @@ require (
 	go.opentelemetry.io/otel/trace v1.24.0 // indirect
 	go.opentelemetry.io/proto/otlp v1.1.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
+	golang.org/x/crypto v0.19.0 // indirect
 	golang.org/x/net v0.20.0 // indirect
 	golang.org/x/sys v0.17.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
@@ -13,6 +13,10 @@ import (
 	"time"
 
 	"github.com/newrelic/nrdot-host/nrdot-common/pkg/auth"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/tlsconfig"
+	configengine "github.com/newrelic/nrdot-host/nrdot-config-engine"
+	"github.com/newrelic/nrdot-host/nrdot-config-engine/pkg/hooks"
+	"github.com/newrelic/nrdot-host/nrdot-config-engine/pkg/secrets"
 	"github.com/newrelic/nrdot-host/nrdot-supervisor"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -40,10 +44,15 @@ func main() {
 	// Parse flags
 	var (
 		mode          = flag.String("mode", "all", "Run mode: all, agent, api, collector, version")
-		configFile    = flag.String("config", "/etc/nrdot/config.yaml", "Configuration file path")
+		configFile    = flag.String("config", "/etc/nrdot/config.yaml", "Configuration file path; the *.yaml files of conf.d next to it are merged over it")
 		collectorPath = flag.String("collector", "/usr/bin/otelcol-nrdot", "Path to collector binary")
 		workDir       = flag.String("workdir", "/var/lib/nrdot", "Working directory")
 		apiAddr       = flag.String("api-addr", "127.0.0.1:8080", "API server listen address")
+		apiTLSCert    = flag.String("api-tls-cert", "", "API server TLS certificate file (PEM)")
+		apiTLSKey     = flag.String("api-tls-key", "", "API server TLS private key file (PEM)")
+		apiTLSSelfSigned = flag.Bool("api-tls-self-signed", false, "Serve the API over TLS with a self-signed certificate kept under the working directory")
+		apiTLSClientCA   = flag.String("api-tls-client-ca", "", "CA file (PEM) verifying API client certificates")
+		apiTLSRequireClientCert = flag.Bool("api-tls-require-client-cert", false, "Reject API clients without a certificate signed by -api-tls-client-ca")
 		logLevel      = flag.String("log-level", "info", "Log level: debug, info, warn, error")
 		logFormat     = flag.String("log-format", "console", "Log format: console, json")
 		enableTelemetry = flag.Bool("telemetry", true, "Enable self-telemetry")
@@ -52,6 +61,25 @@ func main() {
 		authSecret    = flag.String("auth-secret", "", "Authentication secret key (auto-generated if empty)")
 		rateLimitRate = flag.Int("rate-limit", 100, "API rate limit (requests per minute)")
 		rateLimitBurst = flag.Int("rate-burst", 20, "API rate limit burst size")
+		rateLimitWrite = flag.Int("rate-limit-write", 0, "API rate limit for mutating requests (requests per minute, 0 counts them with reads)")
+		rateLimitProxies = flag.String("rate-limit-trusted-proxies", "", "Comma-separated IPs or CIDRs of proxies whose X-Forwarded-For names the API client being rate limited (default: none)")
+		updateManifest = flag.String("update-manifest", "", "Collector release manifest base URL (enables update checks)")
+		updateChannel  = flag.String("update-channel", "stable", "Collector update channel: stable, beta")
+		updateKey      = flag.String("update-key", "", "Base64 ed25519 public key release manifests are signed with")
+		updateMode     = flag.String("update-mode", "notify", "Collector update mode: notify, auto")
+		updateWindow   = flag.String("update-window", "", "Maintenance window for auto updates, e.g. \"sat,sun 02:00-04:00\"")
+		updateInterval = flag.Duration("update-interval", 6*time.Hour, "Collector update check interval")
+		memoryLimit    = flag.Uint64("memory-limit", 0, "Collector memory limit in bytes, 0 for none (Linux cgroup v2)")
+		cpuLimit       = flag.Float64("cpu-limit", 0, "Collector CPU limit in cores, 0 for none (Linux cgroup v2)")
+		cgroupParent   = flag.String("cgroup-parent", "", "cgroup v2 directory for the collector cgroups (default: own cgroup)")
+		watchConfig    = flag.Bool("watch-config", true, "Reload the config file when it changes (SIGHUP always reloads)")
+		healthProbes   = flag.String("health-probes", "", "Collector health probes with degraded:unhealthy thresholds, e.g. \"http:1:3,queue_saturation:0.8:0.95,data_flow:5m:15m\" (default: all three, \"none\" to disable)")
+		execAllowlist  = flag.String("exec-allowlist", "", "YAML file of diagnostic commands the authenticated API may run (default: none)")
+		secretProviders = flag.String("secret-providers", "", "YAML file of providers resolving the secrets configs reference (default: the environment when the collector starts)")
+		remoteConfigURL = flag.String("remote-config-url", "", "HTTPS endpoint signed configuration bundles are polled from (enables remote configuration)")
+		remoteConfigKey = flag.String("remote-config-key", "", "PEM ECDSA public key file remote configuration bundles are signed with")
+		remoteConfigInterval = flag.Duration("remote-config-interval", configengine.DefaultRemoteInterval, "Remote configuration poll interval")
+		reloadHooksFile = flag.String("reload-hooks", "", "YAML file of scripts run before and after collector reloads (default: none)")
 	)
 	
 	flag.Parse()
@@ -83,13 +111,76 @@ func main() {
 	// Build auth config
 	authConfig := buildAuthConfig(*enableAuth, *authType, *authSecret)
 	
+	// Build collector updater config
+	updaterConfig, err := buildUpdaterConfig(*updateManifest, *updateChannel, *updateKey, *updateMode, *updateWindow, *updateInterval)
+	if err != nil {
+		logger.Fatal("Invalid update configuration", zap.Error(err))
+	}
+	
+	// Collector health probes
+	probes, err := supervisor.ParseHealthProbes(*healthProbes)
+	if err != nil {
+		logger.Fatal("Invalid health probes", zap.Error(err))
+	}
+	
+	// API listener TLS
+	apiTLS := tlsconfig.Config{
+		CertFile:          *apiTLSCert,
+		KeyFile:           *apiTLSKey,
+		SelfSigned:        *apiTLSSelfSigned,
+		ClientCAFile:      *apiTLSClientCA,
+		RequireClientCert: *apiTLSRequireClientCert,
+	}
+	if err := apiTLS.Validate(); err != nil {
+		logger.Fatal("Invalid API TLS configuration", zap.Error(err))
+	}
+	
+	// Diagnostic commands the API may run
+	var execCommands []supervisor.ExecCommand
+	if *execAllowlist != "" {
+		execCommands, err = supervisor.LoadExecCommands(*execAllowlist)
+		if err != nil {
+			logger.Fatal("Invalid exec allowlist", zap.Error(err))
+		}
+	}
+	
+	// Providers resolving config secrets
+	var providers []secrets.ProviderConfig
+	if *secretProviders != "" {
+		providers, err = secrets.LoadProviderConfigs(*secretProviders)
+		if err != nil {
+			logger.Fatal("Invalid secret providers", zap.Error(err))
+		}
+	}
+	
+	// Scripts run around collector reloads
+	var reloadHooks hooks.ScriptConfig
+	if *reloadHooksFile != "" {
+		reloadHooks, err = hooks.LoadScriptConfig(*reloadHooksFile)
+		if err != nil {
+			logger.Fatal("Invalid reload hooks", zap.Error(err))
+		}
+	}
+	
+	// Remote configuration
+	remoteConfig, err := buildRemoteConfig(*remoteConfigURL, *remoteConfigKey, *remoteConfigInterval)
+	if err != nil {
+		logger.Fatal("Invalid remote configuration", zap.Error(err))
+	}
+	
+	// Collector resource limits
+	resources := supervisor.ResourceLimits{
+		MemoryMax:    *memoryLimit,
+		CPUMax:       *cpuLimit,
+		CgroupParent: *cgroupParent,
+	}
+	
 	// Run based on mode
-	var err error
 	switch runMode {
 	case ModeAll:
-		err = runAll(ctx, logger, *configFile, *collectorPath, *workDir, *apiAddr, *enableTelemetry, authConfig, *rateLimitRate, *rateLimitBurst)
+		err = runAll(ctx, logger, *configFile, *collectorPath, *workDir, *apiAddr, apiTLS, *enableTelemetry, authConfig, *rateLimitRate, *rateLimitBurst, *rateLimitWrite, splitList(*rateLimitProxies), updaterConfig, resources, probes, execCommands, providers, reloadHooks, remoteConfig, *watchConfig)
 	case ModeAgent:
-		err = runAgent(ctx, logger, *configFile, *collectorPath, *workDir, *enableTelemetry)
+		err = runAgent(ctx, logger, *configFile, *collectorPath, *workDir, *enableTelemetry, updaterConfig, resources, probes, providers, reloadHooks, remoteConfig, *watchConfig)
 	case ModeAPI:
 		err = runAPI(ctx, logger, *configFile, *apiAddr)
 	case ModeCollector:
@@ -106,7 +197,7 @@ func main() {
 }
 
 // runAll runs all components in a single process
-func runAll(ctx context.Context, logger *zap.Logger, configFile, collectorPath, workDir, apiAddr string, enableTelemetry bool, authConfig auth.Config, rateLimitRate, rateLimitBurst int) error {
+func runAll(ctx context.Context, logger *zap.Logger, configFile, collectorPath, workDir, apiAddr string, apiTLS tlsconfig.Config, enableTelemetry bool, authConfig auth.Config, rateLimitRate, rateLimitBurst, rateLimitWrite int, rateLimitProxies []string, updaterConfig supervisor.UpdaterConfig, resources supervisor.ResourceLimits, healthProbes []supervisor.HealthProbeConfig, execCommands []supervisor.ExecCommand, secretProviders []secrets.ProviderConfig, reloadHooks hooks.ScriptConfig, remoteConfig *configengine.RemoteConfig, watchConfig bool) error {
 	logger.Info("Running in ALL mode - unified process")
 	
 	// Create unified supervisor with everything embedded
@@ -114,17 +205,28 @@ func runAll(ctx context.Context, logger *zap.Logger, configFile, collectorPath,
 		CollectorPath:       collectorPath,
 		ConfigPath:          configFile,
 		WorkDir:             workDir,
+		WatchConfig:         watchConfig,
 		APIEnabled:          true,
 		APIListenAddr:       apiAddr,
+		APITLS:              apiTLS,
 		RestartDelay:        5 * time.Second,
 		MaxRestarts:         10,
 		HealthCheckInterval: 30 * time.Second,
+		HealthProbes:        healthProbes,
+		SecretProviders:     secretProviders,
+		ReloadHooks:         reloadHooks,
+		RemoteConfig:        remoteConfig,
 		EnableTelemetry:     enableTelemetry,
 		// Rate limiting
 		RateLimitEnabled:    rateLimitRate > 0,
 		RateLimitRate:       rateLimitRate,
 		RateLimitInterval:   time.Minute,
 		RateLimitBurst:      rateLimitBurst,
+		RateLimitWriteRate:  rateLimitWrite,
+		RateLimitTrustedProxies: rateLimitProxies,
+		Updater:             updaterConfig,
+		Resources:           resources,
+		ExecCommands:        execCommands,
 		Logger:              logger,
 	}
 	
@@ -149,31 +251,44 @@ func runAll(ctx context.Context, logger *zap.Logger, configFile, collectorPath,
 	if err := sup.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start supervisor: %w", err)
 	}
+	go reloadOnSIGHUP(ctx, logger, sup)
 	
 	// Wait for shutdown signal
 	<-ctx.Done()
 	logger.Info("Received shutdown signal")
 	
-	// Graceful shutdown
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30)
+	// Graceful shutdown, with time for the collector to flush when the host
+	// shuts down
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), stopTimeout)
 	defer cancel()
 	
 	return sup.Stop(shutdownCtx)
 }
 
+// stopTimeout bounds stopping the supervisor, beyond the collector's flush
+// on a host shutdown
+const stopTimeout = supervisor.DefaultHostShutdownFlushTimeout + 15*time.Second
+
 // runAgent runs just the collector and supervisor (no API)
-func runAgent(ctx context.Context, logger *zap.Logger, configFile, collectorPath, workDir string, enableTelemetry bool) error {
+func runAgent(ctx context.Context, logger *zap.Logger, configFile, collectorPath, workDir string, enableTelemetry bool, updaterConfig supervisor.UpdaterConfig, resources supervisor.ResourceLimits, healthProbes []supervisor.HealthProbeConfig, secretProviders []secrets.ProviderConfig, reloadHooks hooks.ScriptConfig, remoteConfig *configengine.RemoteConfig, watchConfig bool) error {
 	logger.Info("Running in AGENT mode - collector only")
 	
 	config := supervisor.SupervisorConfig{
 		CollectorPath:       collectorPath,
 		ConfigPath:          configFile,
 		WorkDir:             workDir,
+		WatchConfig:         watchConfig,
 		APIEnabled:          false, // No API in agent mode
 		RestartDelay:        5 * time.Second,
 		MaxRestarts:         10,
 		HealthCheckInterval: 30 * time.Second,
+		HealthProbes:        healthProbes,
+		SecretProviders:     secretProviders,
+		ReloadHooks:         reloadHooks,
+		RemoteConfig:        remoteConfig,
 		EnableTelemetry:     enableTelemetry,
+		Updater:             updaterConfig,
+		Resources:           resources,
 		Logger:              logger,
 	}
 	
@@ -185,15 +300,35 @@ func runAgent(ctx context.Context, logger *zap.Logger, configFile, collectorPath
 	if err := sup.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start supervisor: %w", err)
 	}
+	go reloadOnSIGHUP(ctx, logger, sup)
 	
 	<-ctx.Done()
 	
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), stopTimeout)
 	defer cancel()
 	
 	return sup.Stop(shutdownCtx)
 }
 
+// reloadOnSIGHUP reloads the config file on every SIGHUP until ctx is done
+func reloadOnSIGHUP(ctx context.Context, logger *zap.Logger, sup *supervisor.UnifiedSupervisor) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			logger.Info("Received SIGHUP, reloading config file")
+			if err := sup.ReloadConfigFile(ctx, supervisor.ConfigReloadTriggerSIGHUP); err != nil {
+				logger.Error("Config reload failed", zap.Error(err))
+			}
+		}
+	}
+}
+
 // runAPI runs just the API server (connects to existing supervisor)
 func runAPI(ctx context.Context, logger *zap.Logger, configFile, apiAddr string) error {
 	logger.Info("Running in API mode - API server only")
@@ -383,4 +518,68 @@ func buildAuthConfig(enabled bool, authType, secretKey string) auth.Config {
 	}
 	
 	return config
-}
\ No newline at end of file
+}
+
+// buildUpdaterConfig builds the collector updater configuration from flags.
+// Update checks are disabled unless a manifest URL is set.
+func buildUpdaterConfig(manifestURL, channel, publicKey, mode, window string, interval time.Duration) (supervisor.UpdaterConfig, error) {
+	if manifestURL == "" {
+		return supervisor.UpdaterConfig{}, nil
+	}
+	
+	maintenanceWindow, err := supervisor.ParseMaintenanceWindow(window)
+	if err != nil {
+		return supervisor.UpdaterConfig{}, err
+	}
+	
+	return supervisor.UpdaterConfig{
+		Enabled:           true,
+		Channel:           supervisor.UpdateChannel(channel),
+		ManifestURL:       manifestURL,
+		PublicKey:         publicKey,
+		CheckInterval:     interval,
+		Mode:              supervisor.UpdateMode(mode),
+		MaintenanceWindow: maintenanceWindow,
+	}, nil
+}
+
+// buildRemoteConfig creates the remote configuration settings, nil without
+// a URL. The license key in NEW_RELIC_LICENSE_KEY, if set, is sent as
+// X-License-Key.
+func buildRemoteConfig(url, keyFile string, interval time.Duration) (*configengine.RemoteConfig, error) {
+	if url == "" {
+		return nil, nil
+	}
+	if keyFile == "" {
+		return nil, fmt.Errorf("-remote-config-key is required with -remote-config-url")
+	}
+	
+	publicKey, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote config key: %w", err)
+	}
+	
+	config := &configengine.RemoteConfig{
+		URL:       url,
+		PublicKey: string(publicKey),
+		Interval:  interval,
+	}
+	if licenseKey := os.Getenv("NEW_RELIC_LICENSE_KEY"); licenseKey != "" {
+		config.Headers = map[string]string{"X-License-Key": licenseKey}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -4,14 +4,17 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	apiserver "github.com/newrelic/nrdot-host/nrdot-api-server"
 	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/handlers"
 	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/tlsconfig"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -20,11 +23,22 @@ const version = "v1.0.0"
 
 func main() {
 	var (
-		host       = flag.String("host", "127.0.0.1", "Host to bind to (localhost only)")
+		host       = flag.String("host", "127.0.0.1", "Host to bind to (localhost only without TLS and client certificates or authentication)")
 		port       = flag.Int("port", 8089, "Port to listen on")
 		readOnly   = flag.Bool("read-only", false, "Enable read-only mode")
 		enableCORS = flag.Bool("cors", true, "Enable CORS for localhost origins")
 		debug      = flag.Bool("debug", false, "Enable debug logging")
+		enableSLO  = flag.Bool("slo", true, "Track per-route latency SLOs and burn-rate alerts")
+		enableWebhooks = flag.Bool("webhooks", true, "Allow registering webhooks for events on /v1/webhooks")
+		adminTokenFile = flag.String("admin-token-file", "", "File holding the admin token; enables authentication (or set NRDOT_API_ADMIN_TOKEN)")
+		tokenTTL    = flag.Duration("token-ttl", 4*time.Hour, "Default lifetime of delegated tokens")
+		maxTokenTTL = flag.Duration("max-token-ttl", 24*time.Hour, "Longest lifetime a delegated token may be minted with")
+		tlsCert       = flag.String("tls-cert", "", "TLS certificate file (PEM)")
+		tlsKey        = flag.String("tls-key", "", "TLS private key file (PEM)")
+		tlsSelfSigned = flag.Bool("tls-self-signed", false, "Serve TLS with a generated self-signed certificate")
+		tlsDir        = flag.String("tls-dir", "", "Directory keeping the self-signed certificate across restarts (default: regenerated on each start)")
+		tlsClientCA   = flag.String("tls-client-ca", "", "CA file (PEM) verifying client certificates")
+		tlsRequireClientCert = flag.Bool("tls-require-client-cert", false, "Reject clients without a certificate signed by -tls-client-ca")
 		showVersion = flag.Bool("version", false, "Show version")
 	)
 	flag.Parse()
@@ -38,6 +52,16 @@ func main() {
 	logger := initLogger(*debug)
 	defer logger.Sync()
 
+	// Load the admin token
+	adminToken := os.Getenv("NRDOT_API_ADMIN_TOKEN")
+	if *adminTokenFile != "" {
+		data, err := os.ReadFile(*adminTokenFile)
+		if err != nil {
+			logger.Fatal("Failed to read admin token file", zap.Error(err))
+		}
+		adminToken = strings.TrimSpace(string(data))
+	}
+
 	// Create server config
 	config := apiserver.Config{
 		Host:        *host,
@@ -46,6 +70,22 @@ func main() {
 		Version:     version,
 		EnableCORS:  *enableCORS,
 		EnableDebug: *debug,
+		SLO:         apiserver.SLOConfig{Enabled: *enableSLO},
+		Webhooks:    apiserver.WebhookConfig{Enabled: *enableWebhooks},
+		Auth: apiserver.AuthConfig{
+			AdminToken:      adminToken,
+			DefaultTokenTTL: *tokenTTL,
+			MaxTokenTTL:     *maxTokenTTL,
+		},
+		TLS: tlsconfig.Config{
+			CertFile:          *tlsCert,
+			KeyFile:           *tlsKey,
+			SelfSigned:        *tlsSelfSigned,
+			SelfSignedDir:     *tlsDir,
+			Hosts:             selfSignedHosts(*host),
+			ClientCAFile:      *tlsClientCA,
+			RequireClientCert: *tlsRequireClientCert,
+		},
 	}
 
 	// Create server
@@ -60,6 +100,8 @@ func main() {
 
 	// Set providers
 	server.SetProviders(statusProvider, healthProvider, configProvider, metricsProvider)
+	server.SetProvenanceProvider(configProvider)
+	server.SetSummaryProvider(&mockSummaryProvider{})
 
 	// Setup signal handling
 	ctx, cancel := context.WithCancel(context.Background())
@@ -92,6 +134,25 @@ func main() {
 	logger.Info("Server stopped")
 }
 
+// selfSignedHosts returns the names a self-signed certificate is issued for:
+// localhost, plus the bind host and the machine's hostname when the API
+// listens beyond it
+func selfSignedHosts(host string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	host = strings.Trim(host, "[]")
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return hosts
+	}
+	if host != "" && (ip == nil || !ip.IsUnspecified()) {
+		hosts = append(hosts, host)
+	}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name)
+	}
+	return hosts
+}
+
 // initLogger initializes the logger
 func initLogger(debug bool) *zap.Logger {
 	config := zap.NewProductionConfig()
@@ -205,6 +266,37 @@ func (m *mockConfigProvider) ReloadConfig(force bool) error {
 	return nil
 }
 
+func (m *mockConfigProvider) GetConfigProvenance() (*models.ConfigProvenance, error) {
+	_, _, loadedAt := m.GetCurrentConfig()
+	return &models.ConfigProvenance{
+		ConfigHash: "mock-hash",
+		LoadedAt:   loadedAt,
+		Sources: []models.ConfigSource{
+			{Type: models.ConfigSourceDefault, Location: "nrdot-template-lib", UpdatedAt: loadedAt},
+		},
+		Components: []models.ComponentProvenance{
+			{Path: "service.name", Source: models.ConfigSourceDefault},
+			{Path: "metrics.interval", Source: models.ConfigSourceDefault},
+		},
+	}, nil
+}
+
+type mockSummaryProvider struct{}
+
+func (m *mockSummaryProvider) GetHostSummary() (*models.HostSummary, error) {
+	hostname, _ := os.Hostname()
+	lastRun := time.Now().Add(-5 * time.Minute)
+	return &models.HostSummary{
+		AgentID:       "mock-agent",
+		Hostname:      hostname,
+		Versions:      map[string]string{"collector": "0.96.0"},
+		ConfigVersion: 1,
+		Pipelines:     models.PipelineCounts{Total: 2, Running: 2},
+		Discovery:     &models.DiscoverySummary{LastRun: &lastRun, Services: 1},
+		Cardinality:   &models.CardinalitySummary{ActiveSeries: 1200, GlobalLimit: 100000},
+	}, nil
+}
+
 type mockMetricsProvider struct{}
 
 func (m *mockMetricsProvider) GetCustomMetrics() []handlers.Metric {
@@ -4,6 +4,7 @@ go 1.21
 
 require (
 	github.com/gorilla/mux v1.8.1
+	github.com/newrelic/nrdot-host/nrdot-common v0.0.0-00010101000000-000000000000
 	github.com/stretchr/testify v1.9.0
 	go.uber.org/zap v1.27.0
 )
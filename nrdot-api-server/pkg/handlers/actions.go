@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+)
+
+// ActionsHandler handles requests for the actions auto-configuration needs
+// from the user
+type ActionsHandler struct {
+	logger          *zap.Logger
+	actionsProvider ActionsProvider
+}
+
+// ActionsProvider provides the receivers auto-configuration left out until
+// their credentials are provided or fixed
+type ActionsProvider interface {
+	GetActionsNeeded() ([]models.ActionNeeded, error)
+}
+
+// NewActionsHandler creates a new actions handler. provider may be nil, in
+// which case actions are reported as not available.
+func NewActionsHandler(logger *zap.Logger, provider ActionsProvider) *ActionsHandler {
+	return &ActionsHandler{
+		logger:          logger,
+		actionsProvider: provider,
+	}
+}
+
+// ServeHTTP handles GET /v1/autoconfig/actions. The service query parameter
+// limits actions to one service type.
+func (h *ActionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.actionsProvider == nil {
+		problem.Error(w, r, "Auto-configuration actions are not available", http.StatusNotFound)
+		return
+	}
+
+	actions, err := h.actionsProvider.GetActionsNeeded()
+	if err != nil {
+		h.logger.Error("Failed to get actions needed", zap.Error(err))
+		problem.Error(w, r, "Failed to get actions needed", http.StatusInternalServerError)
+		return
+	}
+
+	service := r.URL.Query().Get("service")
+	response := &models.ActionsNeededResponse{
+		Actions:   make([]models.ActionNeeded, 0, len(actions)),
+		Timestamp: time.Now(),
+	}
+	for _, action := range actions {
+		if service == "" || action.Service == service {
+			response.Actions = append(response.Actions, action)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode actions response", zap.Error(err))
+		problem.Error(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
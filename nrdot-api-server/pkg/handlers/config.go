@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
 	"go.uber.org/zap"
 )
 
@@ -43,7 +44,7 @@ func (h *ConfigHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.handlePost(w, r)
 	default:
 		w.Header().Set("Allow", "GET, POST")
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -61,7 +62,7 @@ func (h *ConfigHandler) handleGet(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		h.logger.Error("Failed to encode config response", zap.Error(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		problem.Error(w, r, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 }
@@ -69,7 +70,7 @@ func (h *ConfigHandler) handleGet(w http.ResponseWriter, r *http.Request) {
 // handlePost handles POST /v1/config
 func (h *ConfigHandler) handlePost(w http.ResponseWriter, r *http.Request) {
 	if h.readOnly {
-		http.Error(w, "Configuration updates are disabled", http.StatusForbidden)
+		problem.Error(w, r, "Configuration updates are disabled", http.StatusForbidden)
 		return
 	}
 
@@ -77,7 +78,7 @@ func (h *ConfigHandler) handlePost(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // 1MB limit
 	if err != nil {
 		h.logger.Error("Failed to read request body", zap.Error(err))
-		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		problem.Error(w, r, "Failed to read request", http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
@@ -86,7 +87,7 @@ func (h *ConfigHandler) handlePost(w http.ResponseWriter, r *http.Request) {
 	var req models.ConfigUpdateRequest
 	if err := json.Unmarshal(body, &req); err != nil {
 		h.logger.Error("Failed to parse config update request", zap.Error(err))
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		problem.Error(w, r, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
@@ -162,12 +163,12 @@ func NewReloadHandler(logger *zap.Logger, provider ConfigProvider, readOnly bool
 func (h *ReloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.Header().Set("Allow", http.MethodPost)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	if h.readOnly {
-		http.Error(w, "Reload operations are disabled", http.StatusForbidden)
+		problem.Error(w, r, "Reload operations are disabled", http.StatusForbidden)
 		return
 	}
 
@@ -176,7 +177,7 @@ func (h *ReloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.ContentLength > 0 {
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			h.logger.Error("Failed to parse reload request", zap.Error(err))
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			problem.Error(w, r, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
 	}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+)
+
+// eventKeepAlive is how often an idle event stream sends a comment so
+// proxies do not close it
+const eventKeepAlive = 30 * time.Second
+
+// EventProvider provides system events, such as collector lifecycle and
+// config changes, for the event stream
+type EventProvider interface {
+	// SubscribeEvents returns a channel receiving every later event and a
+	// function ending the subscription
+	SubscribeEvents() (<-chan models.Event, func())
+}
+
+// EventStreamHandler streams events as Server-Sent Events. Besides the
+// provider's events it sends a status.changed event with the full status
+// whenever the status version changes.
+type EventStreamHandler struct {
+	logger        *zap.Logger
+	status        *StatusHandler
+	eventProvider EventProvider
+
+	// done is closed when the server shuts down and ends every stream
+	done <-chan struct{}
+}
+
+// NewEventStreamHandler creates a new event stream handler. Status changes
+// are taken from status so their versions match /v1/status. provider may be
+// nil, in which case only status changes are streamed.
+func NewEventStreamHandler(logger *zap.Logger, status *StatusHandler, provider EventProvider, done <-chan struct{}) *EventStreamHandler {
+	return &EventStreamHandler{
+		logger:        logger,
+		status:        status,
+		eventProvider: provider,
+		done:          done,
+	}
+}
+
+// ServeHTTP handles GET /v1/events/stream. The type query parameter takes
+// event types ("status.changed") or type prefixes ending in a dot
+// ("collector."), comma separated, and limits the stream to them.
+func (h *EventStreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	types := parseEventTypes(r)
+
+	// Streaming outlives the server's write timeout
+	controller := http.NewResponseController(w)
+	controller.SetWriteDeadline(time.Time{})
+
+	var events <-chan models.Event
+	if h.eventProvider != nil {
+		ch, cancel := h.eventProvider.SubscribeEvents()
+		defer cancel()
+		events = ch
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	var seq int
+	send := func(event models.Event) error {
+		if !MatchEventTypes(types, event.Type) {
+			return nil
+		}
+		seq++
+		if event.ID == "" {
+			event.ID = strconv.Itoa(seq)
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			h.logger.Warn("Failed to encode event", zap.String("type", event.Type), zap.Error(err))
+			return nil
+		}
+		_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+		return err
+	}
+
+	// Start with the current status so clients need no initial GET
+	status := h.status.observe()
+	if err := send(statusChangedEvent(status)); err != nil {
+		return
+	}
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	poll := time.NewTicker(statusPollInterval)
+	defer poll.Stop()
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.done:
+			return
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keepalive\n\n")
+		case <-poll.C:
+			current := h.status.observe()
+			if current.StatusVersion == status.StatusVersion {
+				continue
+			}
+			status = current
+			err = send(statusChangedEvent(status))
+		case event, ok := <-events:
+			if !ok {
+				// The provider ended the subscription; keep streaming status
+				events = nil
+				continue
+			}
+			err = send(event)
+		}
+		if err != nil {
+			return
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// statusChangedEvent wraps a status in a status.changed event whose severity
+// follows the overall status
+func statusChangedEvent(status *models.StatusResponse) models.Event {
+	severity := models.EventSeverityInfo
+	switch status.Status {
+	case models.StatusDegraded:
+		severity = models.EventSeverityWarning
+	case models.StatusUnhealthy:
+		severity = models.EventSeverityError
+	}
+	return models.Event{
+		Type:      models.EventTypeStatusChanged,
+		Severity:  severity,
+		Message:   "Status is " + status.Status,
+		Data:      status,
+		Timestamp: time.Now(),
+	}
+}
+
+// parseEventTypes reads the type query parameters
+func parseEventTypes(r *http.Request) []string {
+	var types []string
+	for _, v := range r.URL.Query()["type"] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types = append(types, t)
+			}
+		}
+	}
+	return types
+}
+
+// MatchEventTypes reports whether an event type is selected by types, event
+// types or prefixes ending in a dot, which match everything when empty
+func MatchEventTypes(types []string, eventType string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if strings.HasSuffix(t, ".") {
+			if strings.HasPrefix(eventType, t) {
+				return true
+			}
+		} else if eventType == t {
+			return true
+		}
+	}
+	return false
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
 	"go.uber.org/zap"
 )
 
@@ -32,7 +33,7 @@ func NewHealthHandler(logger *zap.Logger, provider HealthProvider) *HealthHandle
 func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.Header().Set("Allow", http.MethodGet)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
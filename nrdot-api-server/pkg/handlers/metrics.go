@@ -6,6 +6,7 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
 	"go.uber.org/zap"
 )
 
@@ -45,7 +46,7 @@ func NewMetricsHandler(logger *zap.Logger, version string, provider MetricsProvi
 func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.Header().Set("Allow", http.MethodGet)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
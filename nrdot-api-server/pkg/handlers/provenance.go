@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+)
+
+// ProvenanceHandler handles config provenance requests
+type ProvenanceHandler struct {
+	logger             *zap.Logger
+	provenanceProvider ProvenanceProvider
+}
+
+// ProvenanceProvider provides the provenance of the active configuration
+type ProvenanceProvider interface {
+	GetConfigProvenance() (*models.ConfigProvenance, error)
+}
+
+// NewProvenanceHandler creates a new provenance handler. provider may be
+// nil, in which case provenance is reported as not available.
+func NewProvenanceHandler(logger *zap.Logger, provider ProvenanceProvider) *ProvenanceHandler {
+	return &ProvenanceHandler{
+		logger:             logger,
+		provenanceProvider: provider,
+	}
+}
+
+// ServeHTTP handles GET /v1/config/provenance. The path query parameter
+// limits components to one config path and the paths below it
+// ("receivers.mysql"), and source to those set by one source type.
+func (h *ProvenanceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.provenanceProvider == nil {
+		problem.Error(w, r, "Config provenance is not available", http.StatusNotFound)
+		return
+	}
+
+	provenance, err := h.provenanceProvider.GetConfigProvenance()
+	if err != nil {
+		h.logger.Error("Failed to get config provenance", zap.Error(err))
+		problem.Error(w, r, "Failed to get config provenance", http.StatusInternalServerError)
+		return
+	}
+
+	response := &models.ConfigProvenanceResponse{
+		ConfigProvenance: filterProvenance(*provenance, r.URL.Query().Get("path"), r.URL.Query().Get("source")),
+		Timestamp:        time.Now(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode provenance response", zap.Error(err))
+		problem.Error(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// filterProvenance keeps the components under path set by source, either
+// empty for all, sorted by path. When filtering, sources are limited to the
+// ones that set or were overridden in the kept components.
+func filterProvenance(provenance models.ConfigProvenance, path, source string) models.ConfigProvenance {
+	components := make([]models.ComponentProvenance, 0, len(provenance.Components))
+	used := make(map[string]bool)
+	for _, component := range provenance.Components {
+		if path != "" && component.Path != path && !strings.HasPrefix(component.Path, path+".") {
+			continue
+		}
+		if source != "" && component.Source != source {
+			continue
+		}
+		components = append(components, component)
+		used[component.Source] = true
+		for _, overridden := range component.Overridden {
+			used[overridden] = true
+		}
+	}
+	sort.SliceStable(components, func(i, j int) bool {
+		return components[i].Path < components[j].Path
+	})
+	provenance.Components = components
+
+	sources := make([]models.ConfigSource, 0, len(provenance.Sources))
+	for _, s := range provenance.Sources {
+		if path == "" && source == "" || used[s.Type] {
+			sources = append(sources, s)
+		}
+	}
+	provenance.Sources = sources
+
+	return provenance
+}
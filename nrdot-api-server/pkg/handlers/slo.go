@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+)
+
+// SLOHandler handles API SLO requests
+type SLOHandler struct {
+	logger      *zap.Logger
+	sloProvider SLOProvider
+}
+
+// SLOProvider provides per-route SLO state
+type SLOProvider interface {
+	GetSLOStatus() []models.SLOStatus
+	GetSLOEvents() []models.SLOEvent
+}
+
+// NewSLOHandler creates a new SLO handler
+func NewSLOHandler(logger *zap.Logger, provider SLOProvider) *SLOHandler {
+	return &SLOHandler{
+		logger:      logger,
+		sloProvider: provider,
+	}
+}
+
+// ServeHTTP handles GET /v1/slo
+func (h *SLOHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := &models.SLOResponse{
+		Objectives: h.sloProvider.GetSLOStatus(),
+		Events:     h.sloProvider.GetSLOEvents(),
+		Timestamp:  time.Now(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode SLO response", zap.Error(err))
+		problem.Error(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
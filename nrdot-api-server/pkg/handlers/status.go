@@ -1,15 +1,33 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
 	"go.uber.org/zap"
 )
 
+const (
+	// MaxStatusWait caps how long a long-poll status request blocks
+	MaxStatusWait = 60 * time.Second
+
+	// statusPollInterval is how often a waiting request checks the provider
+	statusPollInterval = 250 * time.Millisecond
+
+	// statusWriteGrace is the time left to write a response after waiting
+	statusWriteGrace = 10 * time.Second
+)
+
 // StatusHandler handles status requests
 type StatusHandler struct {
 	logger    *zap.Logger
@@ -18,6 +36,11 @@ type StatusHandler struct {
 	
 	// Status provider interface (to be implemented by the actual system)
 	statusProvider StatusProvider
+
+	// Status version, bumped whenever the status content changes
+	mu          sync.Mutex
+	statusVer   uint64
+	fingerprint string
 }
 
 // StatusProvider provides system status information
@@ -38,26 +61,123 @@ func NewStatusHandler(logger *zap.Logger, version string, provider StatusProvide
 	}
 }
 
-// ServeHTTP handles GET /v1/status
+// ServeHTTP handles GET /v1/status. With ?wait=30s&if_version=N the request
+// blocks until the status version differs from N or the wait elapses, so
+// fleet controllers can long-poll instead of polling every few seconds.
 func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.Header().Set("Allow", http.MethodGet)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	wait, ifVersion, err := parseLongPoll(r)
+	if err != nil {
+		problem.Error(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Build status response
-	status := h.buildStatus()
+	status := h.observe()
+	if wait > 0 && ifVersion != nil && status.StatusVersion == *ifVersion {
+		// The server's write timeout would cut a long wait short
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Now().Add(wait + statusWriteGrace)); err != nil &&
+			!errors.Is(err, http.ErrNotSupported) {
+			h.logger.Debug("Failed to extend write deadline", zap.Error(err))
+		}
+		status = h.waitForChange(r.Context(), *ifVersion, wait, status)
+	}
 
 	// Encode response
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Status-Version", strconv.FormatUint(status.StatusVersion, 10))
 	if err := json.NewEncoder(w).Encode(status); err != nil {
 		h.logger.Error("Failed to encode status response", zap.Error(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		problem.Error(w, r, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 }
 
+// parseLongPoll reads the wait and if_version query parameters. A wait
+// longer than MaxStatusWait is capped.
+func parseLongPoll(r *http.Request) (time.Duration, *uint64, error) {
+	query := r.URL.Query()
+
+	var wait time.Duration
+	if value := query.Get("wait"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return 0, nil, fmt.Errorf("invalid wait %q: must be a duration such as 30s", value)
+		}
+		wait = min(d, MaxStatusWait)
+	}
+
+	var ifVersion *uint64
+	if value := query.Get("if_version"); value != "" {
+		v, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid if_version %q: must be a status version", value)
+		}
+		ifVersion = &v
+	}
+
+	return wait, ifVersion, nil
+}
+
+// waitForChange polls the provider until the status version differs from
+// version, the wait elapses or the client goes away, and returns the latest
+// status
+func (h *StatusHandler) waitForChange(ctx context.Context, version uint64, wait time.Duration, status *models.StatusResponse) *models.StatusResponse {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	ticker := time.NewTicker(statusPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return status
+		case <-timer.C:
+			return h.observe()
+		case <-ticker.C:
+			status = h.observe()
+			if status.StatusVersion != version {
+				return status
+			}
+		}
+	}
+}
+
+// observe builds the status and stamps it with the status version, bumping
+// the version if the content changed since it was last observed
+func (h *StatusHandler) observe() *models.StatusResponse {
+	status := h.buildStatus()
+	fingerprint := statusFingerprint(status)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if fingerprint != h.fingerprint {
+		h.fingerprint = fingerprint
+		h.statusVer++
+	}
+	status.StatusVersion = h.statusVer
+	return status
+}
+
+// statusFingerprint identifies the status content. Uptime changes every
+// second and is left out.
+func statusFingerprint(status *models.StatusResponse) string {
+	content := *status
+	content.Uptime = ""
+	data, err := json.Marshal(content)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 // buildStatus builds the status response
 func (h *StatusHandler) buildStatus() *models.StatusResponse {
 	uptime := time.Since(h.startTime)
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+)
+
+// SuggestionsHandler handles requests for the receivers auto-configuration
+// suggests without enabling them
+type SuggestionsHandler struct {
+	logger              *zap.Logger
+	suggestionsProvider SuggestionsProvider
+}
+
+// SuggestionsProvider provides the receivers auto-configuration left out
+// for the LOW discovery confidence of their services
+type SuggestionsProvider interface {
+	GetSuggestions() ([]models.Suggestion, error)
+}
+
+// NewSuggestionsHandler creates a new suggestions handler. provider may be
+// nil, in which case suggestions are reported as not available.
+func NewSuggestionsHandler(logger *zap.Logger, provider SuggestionsProvider) *SuggestionsHandler {
+	return &SuggestionsHandler{
+		logger:              logger,
+		suggestionsProvider: provider,
+	}
+}
+
+// ServeHTTP handles GET /v1/autoconfig/suggestions. The service query
+// parameter limits suggestions to one service type.
+func (h *SuggestionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.suggestionsProvider == nil {
+		problem.Error(w, r, "Auto-configuration suggestions are not available", http.StatusNotFound)
+		return
+	}
+
+	suggestions, err := h.suggestionsProvider.GetSuggestions()
+	if err != nil {
+		h.logger.Error("Failed to get suggestions", zap.Error(err))
+		problem.Error(w, r, "Failed to get suggestions", http.StatusInternalServerError)
+		return
+	}
+
+	service := r.URL.Query().Get("service")
+	response := &models.SuggestionsResponse{
+		Suggestions: make([]models.Suggestion, 0, len(suggestions)),
+		Timestamp:   time.Now(),
+	}
+	for _, suggestion := range suggestions {
+		if service == "" || suggestion.Service == service {
+			response.Suggestions = append(response.Suggestions, suggestion)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode suggestions response", zap.Error(err))
+		problem.Error(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+)
+
+// SummaryHandler handles host summary requests
+type SummaryHandler struct {
+	logger          *zap.Logger
+	version         string
+	status          *StatusHandler
+	health          *HealthHandler
+	summaryProvider SummaryProvider
+}
+
+// SummaryProvider provides the host details of the summary that the status
+// and health providers do not report: identity, component versions,
+// pipelines, discovery and cardinality
+type SummaryProvider interface {
+	GetHostSummary() (*models.HostSummary, error)
+}
+
+// NewSummaryHandler creates a new summary handler. It shares the status
+// handler so status versions match /v1/status. provider may be nil, in
+// which case the summary only has what status and health report.
+func NewSummaryHandler(logger *zap.Logger, version string, status *StatusHandler, health *HealthHandler, provider SummaryProvider) *SummaryHandler {
+	return &SummaryHandler{
+		logger:          logger,
+		version:         version,
+		status:          status,
+		health:          health,
+		summaryProvider: provider,
+	}
+}
+
+// ServeHTTP handles GET /v1/summary, a compact document combining status,
+// health, the active config version and the provider's host details, so a
+// dashboard makes one request per host
+func (h *SummaryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	summary, err := h.buildSummary()
+	if err != nil {
+		h.logger.Error("Failed to get host summary", zap.Error(err))
+		problem.Error(w, r, "Failed to get host summary", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Status-Version", strconv.FormatUint(summary.StatusVersion, 10))
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		h.logger.Error("Failed to encode summary response", zap.Error(err))
+		problem.Error(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// buildSummary builds the summary response
+func (h *SummaryHandler) buildSummary() (*models.SummaryResponse, error) {
+	status := h.status.observe()
+	health := h.health.buildHealth()
+
+	summary := &models.SummaryResponse{
+		Versions:      map[string]string{"api_server": h.version},
+		Status:        status.Status,
+		Health:        health.Status,
+		Uptime:        status.Uptime,
+		StatusVersion: status.StatusVersion,
+		Config: models.ConfigSummary{
+			Hash:       status.ConfigHash,
+			LastReload: status.LastReload,
+		},
+		Errors:    len(status.Errors),
+		Timestamp: time.Now(),
+	}
+
+	if h.summaryProvider == nil {
+		return summary, nil
+	}
+	host, err := h.summaryProvider.GetHostSummary()
+	if err != nil {
+		return nil, err
+	}
+	summary.AgentID = host.AgentID
+	summary.Hostname = host.Hostname
+	for component, version := range host.Versions {
+		summary.Versions[component] = version
+	}
+	summary.Config.Version = host.ConfigVersion
+	summary.Pipelines = host.Pipelines
+	summary.Discovery = host.Discovery
+	if host.Cardinality != nil {
+		cardinality := *host.Cardinality
+		if cardinality.GlobalLimit > 0 {
+			cardinality.Utilization = float64(cardinality.ActiveSeries) / float64(cardinality.GlobalLimit) * 100
+		}
+		summary.Cardinality = &cardinality
+	}
+
+	return summary, nil
+}
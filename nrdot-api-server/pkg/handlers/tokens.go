@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrTokenNotFound is returned when revoking an unknown token
+	ErrTokenNotFound = errors.New("token not found")
+	// ErrTooManyTokens is returned when minting while the active token limit is reached
+	ErrTooManyTokens = errors.New("too many active delegated tokens")
+)
+
+// TokenProvider mints, lists and revokes delegated tokens
+type TokenProvider interface {
+	Mint(req models.DelegatedTokenRequest) (*models.DelegatedTokenResponse, error)
+	Revoke(id string) error
+	ListTokens() []models.DelegatedToken
+	GetAuditLog() []models.AuditEntry
+}
+
+// TokenHandler handles delegated token requests
+type TokenHandler struct {
+	logger        *zap.Logger
+	tokenProvider TokenProvider
+}
+
+// NewTokenHandler creates a new token handler
+func NewTokenHandler(logger *zap.Logger, provider TokenProvider) *TokenHandler {
+	return &TokenHandler{
+		logger:        logger,
+		tokenProvider: provider,
+	}
+}
+
+// ServeHTTP handles /v1/tokens and /v1/tokens/{id} requests
+func (h *TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		h.handleList(w, r)
+	case id == "" && r.Method == http.MethodPost:
+		h.handleMint(w, r)
+	case id != "" && r.Method == http.MethodDelete:
+		h.handleRevoke(w, r, id)
+	default:
+		if id == "" {
+			w.Header().Set("Allow", "GET, POST")
+		} else {
+			w.Header().Set("Allow", http.MethodDelete)
+		}
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleList handles GET /v1/tokens
+func (h *TokenHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	response := &models.DelegatedTokenList{
+		Tokens:    h.tokenProvider.ListTokens(),
+		Timestamp: time.Now(),
+	}
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// handleMint handles POST /v1/tokens
+func (h *TokenHandler) handleMint(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+	if err != nil {
+		h.logger.Error("Failed to read request body", zap.Error(err))
+		problem.Error(w, r, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	var req models.DelegatedTokenRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		problem.Error(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.tokenProvider.Mint(req)
+	if errors.Is(err, ErrTooManyTokens) {
+		problem.Error(w, r, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, http.StatusCreated, token)
+}
+
+// handleRevoke handles DELETE /v1/tokens/{id}
+func (h *TokenHandler) handleRevoke(w http.ResponseWriter, r *http.Request, id string) {
+	if err := h.tokenProvider.Revoke(id); err != nil {
+		if errors.Is(err, ErrTokenNotFound) {
+			problem.Error(w, r, "Token not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Failed to revoke token", zap.String("id", id), zap.Error(err))
+		problem.Error(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON writes a JSON response
+func (h *TokenHandler) writeJSON(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode token response", zap.Error(err))
+	}
+}
+
+// AuditHandler handles audit log requests
+type AuditHandler struct {
+	logger        *zap.Logger
+	tokenProvider TokenProvider
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(logger *zap.Logger, provider TokenProvider) *AuditHandler {
+	return &AuditHandler{
+		logger:        logger,
+		tokenProvider: provider,
+	}
+}
+
+// ServeHTTP handles GET /v1/audit
+func (h *AuditHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := &models.AuditResponse{
+		Entries:   h.tokenProvider.GetAuditLog(),
+		Timestamp: time.Now(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode audit response", zap.Error(err))
+		problem.Error(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrWebhookNotFound is returned for an unknown webhook
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrTooManyWebhooks is returned when registering while the webhook limit is reached
+	ErrTooManyWebhooks = errors.New("too many webhooks")
+)
+
+// WebhookProvider registers, lists and removes event webhooks
+type WebhookProvider interface {
+	Register(req models.WebhookRequest) (*models.WebhookResponse, error)
+	Remove(id string) error
+	GetWebhook(id string) (models.Webhook, error)
+	ListWebhooks() []models.Webhook
+}
+
+// WebhookHandler handles webhook requests
+type WebhookHandler struct {
+	logger          *zap.Logger
+	webhookProvider WebhookProvider
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(logger *zap.Logger, provider WebhookProvider) *WebhookHandler {
+	return &WebhookHandler{
+		logger:          logger,
+		webhookProvider: provider,
+	}
+}
+
+// ServeHTTP handles /v1/webhooks and /v1/webhooks/{id} requests
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		h.handleList(w, r)
+	case id == "" && r.Method == http.MethodPost:
+		h.handleRegister(w, r)
+	case id != "" && r.Method == http.MethodGet:
+		h.handleGet(w, r, id)
+	case id != "" && r.Method == http.MethodDelete:
+		h.handleRemove(w, r, id)
+	default:
+		if id == "" {
+			w.Header().Set("Allow", "GET, POST")
+		} else {
+			w.Header().Set("Allow", "GET, DELETE")
+		}
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleList handles GET /v1/webhooks
+func (h *WebhookHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	response := &models.WebhookList{
+		Webhooks:  h.webhookProvider.ListWebhooks(),
+		Timestamp: time.Now(),
+	}
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// handleRegister handles POST /v1/webhooks
+func (h *WebhookHandler) handleRegister(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+	if err != nil {
+		h.logger.Error("Failed to read request body", zap.Error(err))
+		problem.Error(w, r, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	var req models.WebhookRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		problem.Error(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := h.webhookProvider.Register(req)
+	if errors.Is(err, ErrTooManyWebhooks) {
+		problem.Error(w, r, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, http.StatusCreated, webhook)
+}
+
+// handleGet handles GET /v1/webhooks/{id}
+func (h *WebhookHandler) handleGet(w http.ResponseWriter, r *http.Request, id string) {
+	webhook, err := h.webhookProvider.GetWebhook(id)
+	if err != nil {
+		if errors.Is(err, ErrWebhookNotFound) {
+			problem.Error(w, r, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Failed to get webhook", zap.String("id", id), zap.Error(err))
+		problem.Error(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, webhook)
+}
+
+// handleRemove handles DELETE /v1/webhooks/{id}
+func (h *WebhookHandler) handleRemove(w http.ResponseWriter, r *http.Request, id string) {
+	if err := h.webhookProvider.Remove(id); err != nil {
+		if errors.Is(err, ErrWebhookNotFound) {
+			problem.Error(w, r, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Failed to remove webhook", zap.String("id", id), zap.Error(err))
+		problem.Error(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON writes a JSON response
+func (h *WebhookHandler) writeJSON(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode webhook response", zap.Error(err))
+	}
+}
@@ -0,0 +1,438 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/handlers"
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+)
+
+const (
+	// delegatedTokenPrefix marks delegated tokens so they are recognizable in
+	// logs and secret scanners
+	delegatedTokenPrefix = "nrdot_dt_"
+	// maxDelegatedTokens bounds the number of active delegated tokens
+	maxDelegatedTokens = 100
+	// maxAuditEntries is the number of recent audit entries retained
+	maxAuditEntries = 1000
+	// tokenSweepInterval is how often expired tokens are revoked
+	tokenSweepInterval = time.Minute
+)
+
+// contextKey is the type of context keys set by this package
+type contextKey string
+
+// delegatedTokenKey holds the delegated token of an authenticated request
+const delegatedTokenKey contextKey = "delegated_token"
+
+// TokenAuthenticator authenticates API requests with the admin token or a
+// temporary delegated token minted by the admin. Delegated tokens are
+// scope-limited, expire on their own and every use is audited.
+type TokenAuthenticator struct {
+	adminTokenHash [sha256.Size]byte
+	defaultTTL     time.Duration
+	maxTTL         time.Duration
+	logger         *zap.Logger
+
+	mu     sync.Mutex
+	tokens map[[sha256.Size]byte]*models.DelegatedToken
+	audit  []models.AuditEntry
+
+	// now is the clock, replaceable in tests
+	now func() time.Time
+}
+
+// NewTokenAuthenticator creates an authenticator for the admin token.
+// Delegated tokens live for defaultTTL unless requested otherwise, and never
+// longer than maxTTL.
+func NewTokenAuthenticator(adminToken string, defaultTTL, maxTTL time.Duration, logger *zap.Logger) *TokenAuthenticator {
+	if maxTTL <= 0 {
+		maxTTL = 24 * time.Hour
+	}
+	if defaultTTL <= 0 || defaultTTL > maxTTL {
+		defaultTTL = minDuration(4*time.Hour, maxTTL)
+	}
+
+	return &TokenAuthenticator{
+		adminTokenHash: sha256.Sum256([]byte(adminToken)),
+		defaultTTL:     defaultTTL,
+		maxTTL:         maxTTL,
+		logger:         logger,
+		tokens:         make(map[[sha256.Size]byte]*models.DelegatedToken),
+		now:            time.Now,
+	}
+}
+
+// Middleware rejects requests without a valid token. Requests with a
+// delegated token are limited to its scope and audited.
+func (a *TokenAuthenticator) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret := bearerToken(r)
+			if secret == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="nrdot"`)
+				problem.Error(w, r, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			hash := sha256.Sum256([]byte(secret))
+			if subtle.ConstantTimeCompare(hash[:], a.adminTokenHash[:]) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, ok := a.authenticate(hash)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="nrdot", error="invalid_token"`)
+				problem.Error(w, r, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if !scopeAllows(token.Scope, r.Method) {
+				a.record(models.AuditEntry{
+					Action:   models.AuditDenied,
+					Actor:    "token:" + token.ID,
+					TokenID:  token.ID,
+					IssuedTo: token.IssuedTo,
+					Method:   r.Method,
+					Path:     r.URL.Path,
+					Status:   http.StatusForbidden,
+					Details:  "outside scope " + token.Scope,
+				})
+				problem.Error(w, r, "Forbidden - token scope is "+token.Scope, http.StatusForbidden)
+				return
+			}
+
+			wrapped := &responseWriter{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+			next.ServeHTTP(wrapped, r.WithContext(context.WithValue(r.Context(), delegatedTokenKey, token)))
+
+			action := models.AuditAccess
+			if wrapped.statusCode == http.StatusForbidden {
+				action = models.AuditDenied
+			}
+			a.record(models.AuditEntry{
+				Action:   action,
+				Actor:    "token:" + token.ID,
+				TokenID:  token.ID,
+				IssuedTo: token.IssuedTo,
+				Method:   r.Method,
+				Path:     r.URL.Path,
+				Status:   wrapped.statusCode,
+			})
+		})
+	}
+}
+
+// RequireAdmin rejects requests authenticated with a delegated token. It
+// must be installed inside Middleware.
+func (a *TokenAuthenticator) RequireAdmin() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Audited as denied by Middleware
+			if _, ok := DelegatedTokenFromContext(r.Context()); ok {
+				problem.Error(w, r, "Forbidden - admin only", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// DelegatedTokenFromContext returns the delegated token a request was
+// authenticated with; ok is false for admin requests
+func DelegatedTokenFromContext(ctx context.Context) (models.DelegatedToken, bool) {
+	token, ok := ctx.Value(delegatedTokenKey).(models.DelegatedToken)
+	return token, ok
+}
+
+// Identify returns the identity a request authenticates as, "admin" or
+// "token:<id>", without recording a use of the token. ok is false without
+// valid credentials.
+func (a *TokenAuthenticator) Identify(r *http.Request) (string, bool) {
+	secret := bearerToken(r)
+	if secret == "" {
+		return "", false
+	}
+
+	hash := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(hash[:], a.adminTokenHash[:]) == 1 {
+		return "admin", true
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	token, ok := a.tokens[hash]
+	if !ok || !a.now().Before(token.ExpiresAt) {
+		return "", false
+	}
+	return "token:" + token.ID, true
+}
+
+// authenticate looks up a delegated token, revoking it if it has expired,
+// and returns a snapshot of it
+func (a *TokenAuthenticator) authenticate(hash [sha256.Size]byte) (models.DelegatedToken, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	token, ok := a.tokens[hash]
+	if !ok {
+		return models.DelegatedToken{}, false
+	}
+
+	now := a.now()
+	if !now.Before(token.ExpiresAt) {
+		a.expireLocked(hash, token)
+		return models.DelegatedToken{}, false
+	}
+
+	token.Uses++
+	token.LastUsed = &now
+	return *token, true
+}
+
+// Mint creates a delegated token and returns it with its secret. The secret
+// is not retained and cannot be retrieved again.
+func (a *TokenAuthenticator) Mint(req models.DelegatedTokenRequest) (*models.DelegatedTokenResponse, error) {
+	if strings.TrimSpace(req.IssuedTo) == "" {
+		return nil, errors.New("issued_to is required")
+	}
+
+	scope := req.Scope
+	if scope == "" {
+		scope = models.TokenScopeReadOnly
+	}
+	if scope != models.TokenScopeReadOnly && scope != models.TokenScopeReadWrite {
+		return nil, fmt.Errorf("invalid scope %q: use %s or %s", scope, models.TokenScopeReadOnly, models.TokenScopeReadWrite)
+	}
+
+	ttl := a.defaultTTL
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ttl: %w", err)
+		}
+		if d <= 0 {
+			return nil, errors.New("ttl must be positive")
+		}
+		if d > a.maxTTL {
+			return nil, fmt.Errorf("ttl %s exceeds the maximum of %s", d, a.maxTTL)
+		}
+		ttl = d
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	secret = delegatedTokenPrefix + secret
+
+	a.mu.Lock()
+	now := a.now()
+	a.sweepLocked(now)
+	if len(a.tokens) >= maxDelegatedTokens {
+		a.mu.Unlock()
+		return nil, handlers.ErrTooManyTokens
+	}
+	token := &models.DelegatedToken{
+		ID:        id,
+		IssuedTo:  req.IssuedTo,
+		Reason:    req.Reason,
+		Scope:     scope,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	a.tokens[sha256.Sum256([]byte(secret))] = token
+	a.recordLocked(models.AuditEntry{
+		Action:   models.AuditTokenMinted,
+		Actor:    "admin",
+		TokenID:  id,
+		IssuedTo: req.IssuedTo,
+		Details:  fmt.Sprintf("scope %s, expires %s: %s", scope, token.ExpiresAt.Format(time.RFC3339), req.Reason),
+	})
+	response := &models.DelegatedTokenResponse{DelegatedToken: *token, Token: secret}
+	a.mu.Unlock()
+
+	return response, nil
+}
+
+// Revoke revokes the delegated token with the given ID
+func (a *TokenAuthenticator) Revoke(id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for hash, token := range a.tokens {
+		if token.ID != id {
+			continue
+		}
+		delete(a.tokens, hash)
+		a.recordLocked(models.AuditEntry{
+			Action:   models.AuditTokenRevoked,
+			Actor:    "admin",
+			TokenID:  id,
+			IssuedTo: token.IssuedTo,
+		})
+		return nil
+	}
+	return handlers.ErrTokenNotFound
+}
+
+// ListTokens returns the active delegated tokens, oldest first
+func (a *TokenAuthenticator) ListTokens() []models.DelegatedToken {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.sweepLocked(a.now())
+	tokens := make([]models.DelegatedToken, 0, len(a.tokens))
+	for _, token := range a.tokens {
+		tokens = append(tokens, *token)
+	}
+	sortTokens(tokens)
+	return tokens
+}
+
+// GetAuditLog returns recent audit entries, oldest first
+func (a *TokenAuthenticator) GetAuditLog() []models.AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.sweepLocked(a.now())
+	entries := make([]models.AuditEntry, len(a.audit))
+	copy(entries, a.audit)
+	return entries
+}
+
+// Sweep revokes every expired delegated token
+func (a *TokenAuthenticator) Sweep() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sweepLocked(a.now())
+}
+
+// Run revokes expired delegated tokens as they expire until ctx is done
+func (a *TokenAuthenticator) Run(ctx context.Context) {
+	ticker := time.NewTicker(tokenSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.Sweep()
+		}
+	}
+}
+
+// sweepLocked must be called with a.mu held
+func (a *TokenAuthenticator) sweepLocked(now time.Time) {
+	for hash, token := range a.tokens {
+		if !now.Before(token.ExpiresAt) {
+			a.expireLocked(hash, token)
+		}
+	}
+}
+
+// expireLocked must be called with a.mu held
+func (a *TokenAuthenticator) expireLocked(hash [sha256.Size]byte, token *models.DelegatedToken) {
+	delete(a.tokens, hash)
+	a.recordLocked(models.AuditEntry{
+		Action:   models.AuditTokenExpired,
+		Actor:    "token:" + token.ID,
+		TokenID:  token.ID,
+		IssuedTo: token.IssuedTo,
+		Details:  fmt.Sprintf("used %d times", token.Uses),
+	})
+}
+
+// record appends an audit entry
+func (a *TokenAuthenticator) record(entry models.AuditEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.recordLocked(entry)
+}
+
+// recordLocked must be called with a.mu held
+func (a *TokenAuthenticator) recordLocked(entry models.AuditEntry) {
+	entry.Time = a.now()
+	a.audit = append(a.audit, entry)
+	if len(a.audit) > maxAuditEntries {
+		a.audit = a.audit[len(a.audit)-maxAuditEntries:]
+	}
+
+	a.logger.Info("Audit",
+		zap.String("action", entry.Action),
+		zap.String("actor", entry.Actor),
+		zap.String("issued_to", entry.IssuedTo),
+		zap.String("method", entry.Method),
+		zap.String("path", entry.Path),
+		zap.Int("status", entry.Status),
+		zap.String("details", entry.Details),
+	)
+}
+
+// scopeAllows reports whether a token scope permits a request method
+func scopeAllows(scope, method string) bool {
+	switch scope {
+	case models.TokenScopeReadWrite:
+		return true
+	case models.TokenScopeReadOnly:
+		return method == http.MethodGet || method == http.MethodHead
+	default:
+		return false
+	}
+}
+
+// bearerToken extracts the token from the Authorization header
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(auth[7:])
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// sortTokens orders tokens by creation time, then ID
+func sortTokens(tokens []models.DelegatedToken) {
+	sort.Slice(tokens, func(i, j int) bool {
+		if !tokens[i].CreatedAt.Equal(tokens[j].CreatedAt) {
+			return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+		}
+		return tokens[i].ID < tokens[j].ID
+	})
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
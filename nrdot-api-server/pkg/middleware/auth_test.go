@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/handlers"
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testAdminToken = "admin-secret"
+
+func newTestTokenAuthenticator(now *time.Time) *TokenAuthenticator {
+	auth := NewTokenAuthenticator(testAdminToken, 0, 0, zap.NewNop())
+	auth.now = func() time.Time { return *now }
+	return auth
+}
+
+func doAuthRequest(handler http.Handler, method, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/v1/status", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func auditActions(entries []models.AuditEntry) []string {
+	actions := make([]string, len(entries))
+	for i, entry := range entries {
+		actions[i] = entry.Action
+	}
+	return actions
+}
+
+func TestTokenAuthenticatorMint(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	auth := newTestTokenAuthenticator(&now)
+
+	token, err := auth.Mint(models.DelegatedTokenRequest{IssuedTo: "support@example.com", Reason: "INC-42"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token.Token, delegatedTokenPrefix))
+	assert.Equal(t, models.TokenScopeReadOnly, token.Scope)
+	assert.Equal(t, now.Add(4*time.Hour), token.ExpiresAt)
+
+	now = now.Add(time.Minute)
+	token, err = auth.Mint(models.DelegatedTokenRequest{IssuedTo: "support@example.com", Scope: models.TokenScopeReadWrite, TTL: "30m"})
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(30*time.Minute), token.ExpiresAt)
+
+	invalid := []models.DelegatedTokenRequest{
+		{},
+		{IssuedTo: "support", Scope: "admin"},
+		{IssuedTo: "support", TTL: "soon"},
+		{IssuedTo: "support", TTL: "-1h"},
+		{IssuedTo: "support", TTL: "48h"},
+	}
+	for _, req := range invalid {
+		_, err := auth.Mint(req)
+		assert.Error(t, err, "%+v", req)
+	}
+
+	// Listing never exposes the secret
+	tokens := auth.ListTokens()
+	require.Len(t, tokens, 2)
+	assert.Equal(t, "INC-42", tokens[0].Reason)
+	assert.Equal(t, []string{models.AuditTokenMinted, models.AuditTokenMinted}, auditActions(auth.GetAuditLog()))
+}
+
+func TestTokenAuthenticatorMiddleware(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	auth := newTestTokenAuthenticator(&now)
+	handler := auth.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	readOnly, err := auth.Mint(models.DelegatedTokenRequest{IssuedTo: "support"})
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	readWrite, err := auth.Mint(models.DelegatedTokenRequest{IssuedTo: "oncall", Scope: models.TokenScopeReadWrite})
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusUnauthorized, doAuthRequest(handler, http.MethodGet, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, doAuthRequest(handler, http.MethodGet, "nrdot_dt_unknown").Code)
+	assert.Equal(t, http.StatusOK, doAuthRequest(handler, http.MethodPost, testAdminToken).Code)
+
+	assert.Equal(t, http.StatusOK, doAuthRequest(handler, http.MethodGet, readOnly.Token).Code)
+	assert.Equal(t, http.StatusForbidden, doAuthRequest(handler, http.MethodPost, readOnly.Token).Code)
+	assert.Equal(t, http.StatusOK, doAuthRequest(handler, http.MethodPost, readWrite.Token).Code)
+
+	// Denied requests count as uses too
+	tokens := auth.ListTokens()
+	assert.Equal(t, int64(2), tokens[0].Uses)
+	require.NotNil(t, tokens[0].LastUsed)
+
+	// Admin requests are not audited, delegated ones are
+	entries := auth.GetAuditLog()
+	assert.Equal(t, []string{
+		models.AuditTokenMinted,
+		models.AuditTokenMinted,
+		models.AuditAccess,
+		models.AuditDenied,
+		models.AuditAccess,
+	}, auditActions(entries))
+	assert.Equal(t, "token:"+readOnly.ID, entries[2].Actor)
+	assert.Equal(t, "/v1/status", entries[2].Path)
+	assert.Equal(t, http.StatusForbidden, entries[3].Status)
+}
+
+func TestTokenAuthenticatorExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	auth := newTestTokenAuthenticator(&now)
+	handler := auth.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	short, err := auth.Mint(models.DelegatedTokenRequest{IssuedTo: "support", TTL: "1h"})
+	require.NoError(t, err)
+	long, err := auth.Mint(models.DelegatedTokenRequest{IssuedTo: "support", TTL: "2h"})
+	require.NoError(t, err)
+
+	// Expired tokens stop working and are revoked by the sweep
+	now = now.Add(time.Hour)
+	assert.Equal(t, http.StatusUnauthorized, doAuthRequest(handler, http.MethodGet, short.Token).Code)
+	assert.Equal(t, http.StatusOK, doAuthRequest(handler, http.MethodGet, long.Token).Code)
+
+	now = now.Add(time.Hour)
+	auth.Sweep()
+	assert.Empty(t, auth.ListTokens())
+
+	entries := auth.GetAuditLog()
+	assert.Equal(t, []string{
+		models.AuditTokenMinted,
+		models.AuditTokenMinted,
+		models.AuditTokenExpired,
+		models.AuditAccess,
+		models.AuditTokenExpired,
+	}, auditActions(entries))
+	assert.Equal(t, short.ID, entries[2].TokenID)
+	assert.Equal(t, "used 1 times", entries[4].Details)
+}
+
+func TestTokenAuthenticatorRevoke(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	auth := newTestTokenAuthenticator(&now)
+	handler := auth.Middleware()(auth.RequireAdmin()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	token, err := auth.Mint(models.DelegatedTokenRequest{IssuedTo: "support", Scope: models.TokenScopeReadWrite})
+	require.NoError(t, err)
+
+	// Delegated tokens cannot reach admin routes, whatever their scope
+	assert.Equal(t, http.StatusForbidden, doAuthRequest(handler, http.MethodGet, token.Token).Code)
+	assert.Equal(t, http.StatusOK, doAuthRequest(handler, http.MethodGet, testAdminToken).Code)
+
+	require.NoError(t, auth.Revoke(token.ID))
+	assert.ErrorIs(t, auth.Revoke(token.ID), handlers.ErrTokenNotFound)
+	assert.Equal(t, http.StatusUnauthorized, doAuthRequest(handler, http.MethodGet, token.Token).Code)
+
+	entries := auth.GetAuditLog()
+	last := entries[len(entries)-1]
+	assert.Equal(t, models.AuditTokenRevoked, last.Action)
+	assert.Equal(t, "admin", last.Actor)
+}
+
+func TestTokenAuthenticatorLimit(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	auth := newTestTokenAuthenticator(&now)
+
+	for i := 0; i < maxDelegatedTokens; i++ {
+		_, err := auth.Mint(models.DelegatedTokenRequest{IssuedTo: "support", TTL: "1h"})
+		require.NoError(t, err)
+	}
+	_, err := auth.Mint(models.DelegatedTokenRequest{IssuedTo: "support"})
+	assert.ErrorIs(t, err, handlers.ErrTooManyTokens)
+
+	// Expired tokens free their slots
+	now = now.Add(time.Hour)
+	_, err = auth.Mint(models.DelegatedTokenRequest{IssuedTo: "support"})
+	assert.NoError(t, err)
+}
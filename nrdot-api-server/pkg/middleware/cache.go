@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxCacheEntries bounds the cache, which is keyed by URL including the
+// query string
+const maxCacheEntries = 1024
+
+// ResponseCache caches successful GET responses for a short TTL, so clients
+// polling an expensive endpoint share one computation. Concurrent misses for
+// the same request wait for the first one instead of computing it again.
+type ResponseCache struct {
+	ttl    time.Duration
+	now    func() time.Time
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	// generation is bumped by Invalidate, so responses computed before an
+	// invalidation are not stored after it
+	generation uint64
+}
+
+// cacheEntry is a cached response, or one being computed until ready is
+// closed
+type cacheEntry struct {
+	ready     chan struct{}
+	cacheable bool
+	status    int
+	header    http.Header
+	body      []byte
+	stored    time.Time
+	expires   time.Time
+}
+
+// NewResponseCache creates a response cache keeping responses for ttl
+func NewResponseCache(ttl time.Duration, logger *zap.Logger) *ResponseCache {
+	return &ResponseCache{
+		ttl:     ttl,
+		now:     time.Now,
+		logger:  logger,
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+// Middleware serves GET requests from the cache, marking responses with
+// X-Cache (HIT or MISS) and the Age of cached ones. Requests with
+// "Cache-Control: no-cache" bypass it.
+func (c *ResponseCache) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := r.URL.RequestURI() + "\x00" + r.Header.Get("Accept")
+			now := c.now()
+
+			c.mu.Lock()
+			entry, ok := c.entries[key]
+			if ok && (!isReady(entry) || now.Before(entry.expires)) {
+				c.mu.Unlock()
+				<-entry.ready
+				if !entry.cacheable {
+					next.ServeHTTP(w, r)
+					return
+				}
+				entry.write(w, "HIT", c.now().Sub(entry.stored))
+				return
+			}
+
+			if len(c.entries) >= maxCacheEntries {
+				c.evictExpiredLocked(now)
+			}
+			if len(c.entries) >= maxCacheEntries {
+				c.mu.Unlock()
+				next.ServeHTTP(w, r)
+				return
+			}
+			entry = &cacheEntry{ready: make(chan struct{})}
+			c.entries[key] = entry
+			generation := c.generation
+			c.mu.Unlock()
+
+			rec := &cacheRecorder{header: make(http.Header), status: http.StatusOK}
+			defer c.fill(key, entry, rec, generation)
+			next.ServeHTTP(rec, r)
+
+			entry.status = rec.status
+			entry.header = rec.header
+			entry.body = rec.body.Bytes()
+			entry.write(w, "MISS", 0)
+		})
+	}
+}
+
+// fill completes an entry computed for a request. Only 200 responses
+// computed since the last invalidation are kept; waiters on any other
+// compute the response themselves.
+func (c *ResponseCache) fill(key string, entry *cacheEntry, rec *cacheRecorder, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.cacheable = entry.header != nil && rec.status == http.StatusOK && generation == c.generation
+	if entry.cacheable {
+		entry.stored = c.now()
+		entry.expires = entry.stored.Add(c.ttl)
+	} else if c.entries[key] == entry {
+		delete(c.entries, key)
+	}
+	close(entry.ready)
+}
+
+// Invalidate drops every cached response, for when the state behind them
+// changed, e.g. after a config reload or collector restart
+func (c *ResponseCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if isReady(entry) {
+			delete(c.entries, key)
+		}
+	}
+	c.generation++
+	c.logger.Debug("Response cache invalidated")
+}
+
+// InvalidateMiddleware invalidates the cache after each successful request
+// other than GET, HEAD and OPTIONS, for endpoints that change what cached
+// endpoints report
+func (c *ResponseCache) InvalidateMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(rw, r)
+			if rw.statusCode < http.StatusBadRequest {
+				c.Invalidate()
+			}
+		})
+	}
+}
+
+// evictExpiredLocked must be called with c.mu held
+func (c *ResponseCache) evictExpiredLocked(now time.Time) {
+	for key, entry := range c.entries {
+		if isReady(entry) && !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// isReady reports whether an entry's response is complete
+func isReady(entry *cacheEntry) bool {
+	select {
+	case <-entry.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// write sends the entry's response to w
+func (e *cacheEntry) write(w http.ResponseWriter, cache string, age time.Duration) {
+	for name, values := range e.header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	w.Header().Set("X-Cache", cache)
+	if cache == "HIT" {
+		w.Header().Set("Age", strconv.Itoa(int(age.Seconds())))
+	}
+	w.WriteHeader(e.status)
+	w.Write(e.body)
+}
+
+// cacheRecorder captures a response so it can be cached before it is sent
+type cacheRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (r *cacheRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *cacheRecorder) WriteHeader(status int) {
+	if !r.wrote {
+		r.status = status
+		r.wrote = true
+	}
+}
+
+func (r *cacheRecorder) Write(p []byte) (int, error) {
+	r.wrote = true
+	return r.body.Write(p)
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestResponseCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewResponseCache(2*time.Second, zap.NewNop())
+	cache.now = func() time.Time { return now }
+
+	var calls atomic.Int32
+	status := http.StatusOK
+	handler := cache.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(status)
+		w.Write([]byte(strconv.Itoa(int(n))))
+	}))
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/v1/health")
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	assert.Equal(t, "1", w.Body.String())
+
+	now = now.Add(time.Second)
+	w = get("/v1/health")
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, "1", w.Header().Get("Age"))
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, "1", w.Body.String())
+
+	// Queries and no-cache requests are not served another response
+	assert.Equal(t, "2", get("/v1/health?verbose=true").Body.String())
+	assert.Equal(t, "3", get("/v1/health", "Cache-Control", "no-cache").Body.String())
+
+	// Responses expire after the TTL
+	now = now.Add(time.Second)
+	w = get("/v1/health")
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	assert.Equal(t, "4", w.Body.String())
+
+	// Invalidation drops cached responses
+	cache.Invalidate()
+	assert.Equal(t, "5", get("/v1/health").Body.String())
+
+	// Errors are not cached
+	status = http.StatusServiceUnavailable
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, "6", get("/v1/health").Body.String())
+	assert.Equal(t, "7", get("/v1/health").Body.String())
+}
+
+func TestResponseCacheSharesMisses(t *testing.T) {
+	cache := NewResponseCache(time.Minute, zap.NewNop())
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	handler := cache.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write([]byte("ok"))
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+			assert.Equal(t, "ok", w.Body.String())
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestResponseCacheInvalidateMiddleware(t *testing.T) {
+	cache := NewResponseCache(time.Minute, zap.NewNop())
+
+	var calls atomic.Int32
+	cached := cache.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strconv.Itoa(int(calls.Add(1)))))
+	}))
+	reloadStatus := http.StatusOK
+	reload := cache.InvalidateMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(reloadStatus)
+	}))
+	get := func() string {
+		w := httptest.NewRecorder()
+		cached.ServeHTTP(w, httptest.NewRequest("GET", "/v1/health", nil))
+		return w.Body.String()
+	}
+
+	require.Equal(t, "1", get())
+	require.Equal(t, "1", get())
+
+	// A failed reload changes nothing
+	reloadStatus = http.StatusInternalServerError
+	reload.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/v1/reload", nil))
+	assert.Equal(t, "1", get())
+
+	reloadStatus = http.StatusOK
+	reload.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/v1/reload", nil))
+	assert.Equal(t, "2", get())
+}
@@ -4,9 +4,10 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	"strings"
+	"net/url"
 	"time"
 
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
 	"go.uber.org/zap"
 )
 
@@ -49,7 +50,7 @@ func LocalhostOnlyMiddleware(logger *zap.Logger) func(http.Handler) http.Handler
 					zap.String("remote_addr", r.RemoteAddr),
 					zap.Error(err),
 				)
-				http.Error(w, "Forbidden", http.StatusForbidden)
+				problem.Error(w, r, "Forbidden", http.StatusForbidden)
 				return
 			}
 
@@ -58,7 +59,7 @@ func LocalhostOnlyMiddleware(logger *zap.Logger) func(http.Handler) http.Handler
 				logger.Warn("Rejected non-localhost connection",
 					zap.String("remote_addr", r.RemoteAddr),
 				)
-				http.Error(w, "Forbidden - localhost only", http.StatusForbidden)
+				problem.Error(w, r, "Forbidden - localhost only", http.StatusForbidden)
 				return
 			}
 
@@ -80,7 +81,7 @@ func RecoveryMiddleware(logger *zap.Logger) func(http.Handler) http.Handler {
 					)
 
 					// Return 500 error
-					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					problem.Error(w, r, "Internal Server Error", http.StatusInternalServerError)
 				}
 			}()
 
@@ -135,6 +136,11 @@ func (w *responseWriter) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // isLocalhost checks if an IP address is localhost
 func isLocalhost(ip string) bool {
 	parsedIP := net.ParseIP(ip)
@@ -163,25 +169,15 @@ func isLocalhostOrigin(origin string) bool {
 	}
 
 	// Parse origin URL
-	if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
-		return false
-	}
-
-	// Extract host
-	parts := strings.Split(origin, "://")
-	if len(parts) != 2 {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
 		return false
 	}
 
-	host := strings.Split(parts[1], "/")[0]
-	hostParts := strings.Split(host, ":")
-	hostname := hostParts[0]
-
-	// Check if hostname is localhost
-	return hostname == "localhost" || 
-		hostname == "127.0.0.1" || 
-		hostname == "[::1]" || 
-		hostname == "::1"
+	// Hostname strips the port and the brackets of IPv6 literals, such as
+	// http://[::1]:3000
+	hostname := u.Hostname()
+	return hostname == "localhost" || isLocalhost(hostname)
 }
 
 // RequestIDMiddleware adds a request ID to each request
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsLocalhostOrigin(t *testing.T) {
+	for origin, want := range map[string]bool{
+		"http://localhost:3000":     true,
+		"https://127.0.0.1":         true,
+		"http://[::1]:3000":         true,
+		"http://[::1]/app":          true,
+		"http://[fd00::1]:3000":     false,
+		"http://example.com":        false,
+		"http://localhost.evil.com": false,
+		"file://localhost":          false,
+		"":                          false,
+	} {
+		assert.Equal(t, want, isLocalhostOrigin(origin), origin)
+	}
+}
@@ -2,10 +2,15 @@ package middleware
 
 import (
 	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
 	"go.uber.org/zap"
 )
 
@@ -45,33 +50,64 @@ func NewRateLimiter(rate int, interval time.Duration, bucketSize int, logger *za
 	return rl
 }
 
+// RateLimitDecision is the outcome of counting a request against a bucket
+type RateLimitDecision struct {
+	Allowed   bool
+	Limit     int       // bucket size
+	Remaining int       // tokens left in the bucket
+	Reset     time.Time // when tokens are next added
+}
+
 // RateLimitMiddleware creates HTTP middleware for rate limiting
 func (rl *RateLimiter) RateLimitMiddleware(keyFunc func(*http.Request) string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			key := keyFunc(r)
-			if key == "" {
-				key = "default"
-			}
-			
-			if !rl.Allow(key) {
-				rl.logger.Warn("Rate limit exceeded",
-					zap.String("key", key),
-					zap.String("path", r.URL.Path),
-					zap.String("method", r.Method))
-				
-				w.Header().Set("Retry-After", fmt.Sprintf("%d", int(rl.interval.Seconds())))
-				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
-				return
+			if rl.limit(w, r, keyFunc(r)) {
+				next.ServeHTTP(w, r)
 			}
-			
-			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// limit counts a request against its key's bucket and sets the
+// X-RateLimit-* headers. Over the limit it responds 429 with Retry-After
+// and returns false.
+func (rl *RateLimiter) limit(w http.ResponseWriter, r *http.Request, key string) bool {
+	if key == "" {
+		key = "default"
+	}
+
+	decision := rl.Take(key)
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(decision.Reset.Unix(), 10))
+	if decision.Allowed {
+		return true
+	}
+
+	rl.logger.Warn("Rate limit exceeded",
+		zap.String("key", key),
+		zap.String("path", r.URL.Path),
+		zap.String("method", r.Method))
+
+	// Whole seconds until the next token, at least one
+	retryAfter := int(math.Ceil(time.Until(decision.Reset).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	problem.Error(w, r, "Rate limit exceeded", http.StatusTooManyRequests)
+	return false
+}
+
 // Allow checks if a request should be allowed
 func (rl *RateLimiter) Allow(key string) bool {
+	return rl.Take(key).Allowed
+}
+
+// Take counts a request against key's bucket and reports whether it is
+// allowed along with the bucket's state
+func (rl *RateLimiter) Take(key string) RateLimitDecision {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 	
@@ -88,13 +124,19 @@ func (rl *RateLimiter) Allow(key string) bool {
 	// Refill tokens based on elapsed time
 	rl.refillBucket(bucket)
 	
+	decision := RateLimitDecision{
+		Limit: rl.bucketSize,
+		Reset: bucket.lastRefill.Add(rl.interval),
+	}
+	
 	// Check if we have tokens available
 	if bucket.tokens > 0 {
 		bucket.tokens--
-		return true
+		decision.Allowed = true
 	}
+	decision.Remaining = bucket.tokens
 	
-	return false
+	return decision
 }
 
 // refillBucket adds tokens based on elapsed time
@@ -162,7 +204,11 @@ func IPKeyFunc(r *http.Request) string {
 		return ip
 	}
 	
-	// Fall back to RemoteAddr
+	// Fall back to RemoteAddr, without the port so every connection of a
+	// client shares its limit, e.g. ::1 for [::1]:52814
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
 	return r.RemoteAddr
 }
 
@@ -177,6 +223,96 @@ func APIKeyFunc(r *http.Request) string {
 	return r.URL.Query().Get("api_key")
 }
 
+// ParseTrustedProxies parses the addresses of the proxies whose forwarding
+// headers ClientIPKeyFunc trusts, each an IP or a CIDR
+func ParseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// ClientIPKeyFunc keys requests by client IP. Unlike IPKeyFunc it only
+// reads X-Real-IP and X-Forwarded-For on connections from trusted proxies,
+// as any client can set them: the client is then the last address of
+// X-Forwarded-For that is not a trusted proxy. Without trusted proxies
+// requests are keyed by the address of the connection.
+func ClientIPKeyFunc(trustedProxies []*net.IPNet) func(*http.Request) string {
+	trusted := func(addr string) bool {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return false
+		}
+		for _, network := range trustedProxies {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(r *http.Request) string {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if !trusted(host) {
+			return host
+		}
+
+		// Proxies append the address they received a request from
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(strings.Join(forwarded, ","), ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				hop := strings.TrimSpace(hops[i])
+				if hop == "" {
+					continue
+				}
+				host = hop
+				if !trusted(hop) {
+					break
+				}
+			}
+			return host
+		}
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+			return ip
+		}
+		return host
+	}
+}
+
+// IdentityKeyFunc keys requests by the identity identify authenticates them
+// as, and unauthenticated requests by client IP as ClientIPKeyFunc finds
+// it, so presenting unknown credentials or forwarding headers does not
+// escape the limit
+func IdentityKeyFunc(identify func(*http.Request) (string, bool), trustedProxies []*net.IPNet) func(*http.Request) string {
+	clientIP := ClientIPKeyFunc(trustedProxies)
+	return func(r *http.Request) string {
+		if identity, ok := identify(r); ok {
+			return "identity:" + identity
+		}
+		return "ip:" + clientIP(r)
+	}
+}
+
 // UserKeyFunc extracts authenticated user for rate limiting
 func UserKeyFunc(r *http.Request) string {
 	// This would typically extract from JWT claims or session
@@ -187,4 +323,60 @@ func UserKeyFunc(r *http.Request) string {
 // EndpointKeyFunc creates a global rate limit per endpoint
 func EndpointKeyFunc(r *http.Request) string {
 	return fmt.Sprintf("%s:%s", r.Method, r.URL.Path)
-}
\ No newline at end of file
+}
+
+// Rate limit tiers of MethodTierFunc
+const (
+	TierRead  = "read"
+	TierWrite = "write"
+)
+
+// RateTier is the rate limit of one tier of requests
+type RateTier struct {
+	Rate       int           // requests per interval
+	Interval   time.Duration // refill interval
+	BucketSize int           // max burst, defaults to Rate
+}
+
+// TieredRateLimiter limits each tier of requests, such as reads and writes,
+// with its own buckets, so a client exhausting one tier can still use the
+// others
+type TieredRateLimiter struct {
+	tiers    map[string]*RateLimiter
+	tierFunc func(*http.Request) string
+}
+
+// NewTieredRateLimiter creates a rate limiter with a bucket per key and
+// tier. tierFunc assigns requests to tiers; requests in a tier without a
+// limit are not limited.
+func NewTieredRateLimiter(tiers map[string]RateTier, tierFunc func(*http.Request) string, logger *zap.Logger) *TieredRateLimiter {
+	t := &TieredRateLimiter{
+		tiers:    make(map[string]*RateLimiter, len(tiers)),
+		tierFunc: tierFunc,
+	}
+	for name, tier := range tiers {
+		t.tiers[name] = NewRateLimiter(tier.Rate, tier.Interval, tier.BucketSize, logger.With(zap.String("tier", name)))
+	}
+	return t
+}
+
+// RateLimitMiddleware creates HTTP middleware limiting each key per tier
+func (t *TieredRateLimiter) RateLimitMiddleware(keyFunc func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rl, ok := t.tiers[t.tierFunc(r)]
+			if !ok || rl.limit(w, r, keyFunc(r)) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// MethodTierFunc assigns safe methods to TierRead and the rest to TierWrite
+func MethodTierFunc(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return TierRead
+	}
+	return TierWrite
+}
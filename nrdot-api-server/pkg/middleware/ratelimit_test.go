@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestRateLimitMiddlewareHeaders(t *testing.T) {
+	limiter := NewRateLimiter(2, time.Minute, 2, zap.NewNop())
+	handler := limiter.RateLimitMiddleware(IPKeyFunc)(okHandler())
+
+	do := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/status", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, remaining := range []string{"1", "0"} {
+		w := do()
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, remaining, w.Header().Get("X-RateLimit-Remaining"))
+		reset, err := strconv.ParseInt(w.Header().Get("X-RateLimit-Reset"), 10, 64)
+		require.NoError(t, err)
+		assert.InDelta(t, time.Now().Add(time.Minute).Unix(), reset, 2)
+	}
+
+	w := do()
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	assert.True(t, retryAfter > 0 && retryAfter <= 60, "Retry-After %d", retryAfter)
+}
+
+func TestIPKeyFunc(t *testing.T) {
+	// Connections of a client share its key, IPv4 or IPv6
+	for remoteAddr, want := range map[string]string{
+		"10.0.0.1:1234":      "10.0.0.1",
+		"[::1]:52814":        "::1",
+		"[fd00::1%eth0]:443": "fd00::1%eth0",
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		assert.Equal(t, want, IPKeyFunc(req), remoteAddr)
+	}
+}
+
+func TestIdentityKeyFunc(t *testing.T) {
+	keyFunc := IdentityKeyFunc(func(r *http.Request) (string, bool) {
+		if r.Header.Get("Authorization") == "Bearer valid" {
+			return "admin", true
+		}
+		return "", false
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	assert.Equal(t, "ip:10.0.0.1", keyFunc(req))
+
+	// Unknown credentials are limited with the client's other requests
+	req.Header.Set("Authorization", "Bearer guessed")
+	assert.Equal(t, "ip:10.0.0.1", keyFunc(req))
+
+	// So are forwarding headers, which any client can set
+	req.Header.Set("X-Real-IP", "192.0.2.1")
+	req.Header.Set("X-Forwarded-For", "192.0.2.2")
+	assert.Equal(t, "ip:10.0.0.1", keyFunc(req))
+
+	req.Header.Set("Authorization", "Bearer valid")
+	assert.Equal(t, "identity:admin", keyFunc(req))
+}
+
+func TestClientIPKeyFunc(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.1", "172.16.0.0/12"})
+	require.NoError(t, err)
+	keyFunc := ClientIPKeyFunc(proxies)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		realIP     string
+		forwarded  []string
+		want       string
+	}{
+		{name: "direct", remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		{name: "headers of an untrusted client", remoteAddr: "192.0.2.1:1234", realIP: "198.51.100.1", forwarded: []string{"198.51.100.2"}, want: "192.0.2.1"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.1:1234", forwarded: []string{"198.51.100.2"}, want: "198.51.100.2"},
+		{name: "trusted proxy chain", remoteAddr: "10.0.0.1:1234", forwarded: []string{"203.0.113.9, 198.51.100.2", "172.16.5.5"}, want: "198.51.100.2"},
+		{name: "trusted proxy real IP", remoteAddr: "10.0.0.1:1234", realIP: "198.51.100.1", want: "198.51.100.1"},
+		{name: "trusted proxy without headers", remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			assert.Equal(t, tt.want, keyFunc(req))
+		})
+	}
+
+	_, err = ParseTrustedProxies([]string{"proxy.local"})
+	assert.Error(t, err)
+}
+
+func TestTieredRateLimiter(t *testing.T) {
+	limiter := NewTieredRateLimiter(map[string]RateTier{
+		TierRead:  {Rate: 3, Interval: time.Minute},
+		TierWrite: {Rate: 1, Interval: time.Minute},
+	}, MethodTierFunc, zap.NewNop())
+	handler := limiter.RateLimitMiddleware(func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	})(okHandler())
+
+	do := func(method, user string) int {
+		req := httptest.NewRequest(method, "/v1/config", nil)
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Writes have their own, smaller budget
+	assert.Equal(t, http.StatusOK, do("POST", "alice"))
+	assert.Equal(t, http.StatusTooManyRequests, do("PUT", "alice"))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, do("GET", "alice"))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, do("HEAD", "alice"))
+
+	// Each identity has its own buckets
+	assert.Equal(t, http.StatusOK, do("POST", "bob"))
+	assert.Equal(t, http.StatusOK, do("GET", "bob"))
+}
+
+func TestTieredRateLimiterUnlimitedTier(t *testing.T) {
+	limiter := NewTieredRateLimiter(map[string]RateTier{
+		TierWrite: {Rate: 1, Interval: time.Minute},
+	}, MethodTierFunc, zap.NewNop())
+	handler := limiter.RateLimitMiddleware(EndpointKeyFunc)(okHandler())
+
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/status", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	}
+}
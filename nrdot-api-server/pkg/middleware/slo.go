@@ -0,0 +1,408 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/handlers"
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// sloBucketWidth is the resolution of the rolling SLO windows
+	sloBucketWidth = time.Minute
+	// maxSLOEvents is the number of recent burn-rate events retained
+	maxSLOEvents = 100
+)
+
+// SLOObjective is a latency and availability target for one API route.
+// A request is good when it completes below LatencyThreshold without a 5xx.
+type SLOObjective struct {
+	Route            string        // mux path template, e.g. "/v1/config"
+	Method           string        // empty matches any method
+	LatencyThreshold time.Duration // slower requests count against the budget
+	Target           float64       // fraction of requests that must be good, e.g. 0.99
+}
+
+// BurnRateAlert fires when the error budget burns faster than Threshold
+// over both the long and the short window
+type BurnRateAlert struct {
+	Name        string
+	LongWindow  time.Duration
+	ShortWindow time.Duration
+	Threshold   float64
+	Severity    string
+}
+
+// DefaultSLOObjectives returns objectives for the built-in API routes
+func DefaultSLOObjectives() []SLOObjective {
+	return []SLOObjective{
+		{Route: "/v1/status", Method: http.MethodGet, LatencyThreshold: time.Second, Target: 0.99},
+		{Route: "/v1/health", Method: http.MethodGet, LatencyThreshold: time.Second, Target: 0.99},
+		{Route: "/v1/metrics", Method: http.MethodGet, LatencyThreshold: 2 * time.Second, Target: 0.99},
+		{Route: "/v1/config", Method: http.MethodGet, LatencyThreshold: time.Second, Target: 0.99},
+		{Route: "/v1/config", Method: http.MethodPost, LatencyThreshold: 10 * time.Second, Target: 0.99},
+		{Route: "/v1/reload", Method: http.MethodPost, LatencyThreshold: 10 * time.Second, Target: 0.99},
+	}
+}
+
+// DefaultBurnRateAlerts returns the standard fast- and slow-burn alert pair
+func DefaultBurnRateAlerts() []BurnRateAlert {
+	return []BurnRateAlert{
+		{Name: "fast_burn", LongWindow: time.Hour, ShortWindow: 5 * time.Minute, Threshold: 14.4, Severity: "critical"},
+		{Name: "slow_burn", LongWindow: 6 * time.Hour, ShortWindow: 30 * time.Minute, Threshold: 6, Severity: "warning"},
+	}
+}
+
+// SLOTracker records per-route latency and errors against objectives and
+// emits burn-rate events when the API itself degrades
+type SLOTracker struct {
+	mu         sync.Mutex
+	objectives []*objectiveState
+	alerts     []BurnRateAlert
+	maxWindow  time.Duration
+	events     []models.SLOEvent
+	listeners  []func(models.SLOEvent)
+	logger     *zap.Logger
+
+	// now is the clock, replaceable in tests
+	now func() time.Time
+}
+
+// objectiveState is the rolling state of one objective
+type objectiveState struct {
+	objective SLOObjective
+	buckets   []sloBucket // ring indexed by minute
+	total     int64
+	bad       int64
+	firing    map[string]bool
+}
+
+// sloBucket counts requests within one bucket width
+type sloBucket struct {
+	minute int64
+	total  int64
+	bad    int64
+}
+
+// NewSLOTracker creates a tracker for the given objectives and alerts
+func NewSLOTracker(objectives []SLOObjective, alerts []BurnRateAlert, logger *zap.Logger) *SLOTracker {
+	maxWindow := time.Hour
+	for _, alert := range alerts {
+		if alert.LongWindow > maxWindow {
+			maxWindow = alert.LongWindow
+		}
+	}
+
+	t := &SLOTracker{
+		alerts:    alerts,
+		maxWindow: maxWindow,
+		logger:    logger,
+		now:       time.Now,
+	}
+	for _, objective := range objectives {
+		t.objectives = append(t.objectives, &objectiveState{
+			objective: objective,
+			buckets:   make([]sloBucket, int(maxWindow/sloBucketWidth)+1),
+			firing:    make(map[string]bool),
+		})
+	}
+	return t
+}
+
+// OnEvent registers a callback invoked for every burn-rate event
+func (t *SLOTracker) OnEvent(fn func(models.SLOEvent)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listeners = append(t.listeners, fn)
+}
+
+// Middleware records every routed request against its objective. It must be
+// installed on the mux router so the matched route template is available.
+func (t *SLOTracker) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Long-poll requests and event streams block by design; their
+			// latency says nothing about the API's responsiveness
+			if r.URL.Query().Get("wait") != "" || strings.HasSuffix(r.URL.Path, "/stream") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+
+			wrapped := &responseWriter{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+			next.ServeHTTP(wrapped, r)
+
+			route := r.URL.Path
+			if current := mux.CurrentRoute(r); current != nil {
+				if tmpl, err := current.GetPathTemplate(); err == nil {
+					route = tmpl
+				}
+			}
+			t.Record(route, r.Method, wrapped.statusCode, time.Since(start))
+		})
+	}
+}
+
+// Record counts one request against the matching objective, if any
+func (t *SLOTracker) Record(route, method string, status int, latency time.Duration) {
+	t.mu.Lock()
+
+	state := t.find(route, method)
+	if state == nil {
+		t.mu.Unlock()
+		return
+	}
+
+	bad := status >= http.StatusInternalServerError || latency > state.objective.LatencyThreshold
+	now := t.now()
+	bucket := t.bucket(state, now)
+	bucket.total++
+	state.total++
+	if bad {
+		bucket.bad++
+		state.bad++
+	}
+
+	events := t.evaluate(state, now)
+	listeners := t.listeners
+	t.mu.Unlock()
+
+	for _, event := range events {
+		for _, fn := range listeners {
+			fn(event)
+		}
+	}
+}
+
+// find returns the objective state for a route and method
+func (t *SLOTracker) find(route, method string) *objectiveState {
+	for _, state := range t.objectives {
+		if state.objective.Route != route {
+			continue
+		}
+		if state.objective.Method == "" || state.objective.Method == method {
+			return state
+		}
+	}
+	return nil
+}
+
+// bucket returns the bucket for now, recycling it if it holds an old minute
+func (t *SLOTracker) bucket(state *objectiveState, now time.Time) *sloBucket {
+	minute := now.Unix() / int64(sloBucketWidth/time.Second)
+	bucket := &state.buckets[minute%int64(len(state.buckets))]
+	if bucket.minute != minute {
+		*bucket = sloBucket{minute: minute}
+	}
+	return bucket
+}
+
+// burnRate returns how fast the error budget is burning over a window.
+// A burn rate of 1 consumes exactly the budget over the SLO period.
+func (t *SLOTracker) burnRate(state *objectiveState, window time.Duration, now time.Time) float64 {
+	width := int64(sloBucketWidth / time.Second)
+	current := now.Unix() / width
+	oldest := current - int64(window/sloBucketWidth) + 1
+
+	var total, bad int64
+	for _, bucket := range state.buckets {
+		if bucket.minute >= oldest && bucket.minute <= current {
+			total += bucket.total
+			bad += bucket.bad
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+
+	budget := 1 - state.objective.Target
+	if budget <= 0 {
+		return 0
+	}
+	return (float64(bad) / float64(total)) / budget
+}
+
+// evaluate updates alert state for an objective and returns transition events
+func (t *SLOTracker) evaluate(state *objectiveState, now time.Time) []models.SLOEvent {
+	var events []models.SLOEvent
+
+	for _, alert := range t.alerts {
+		long := t.burnRate(state, alert.LongWindow, now)
+		short := t.burnRate(state, alert.ShortWindow, now)
+		firing := long >= alert.Threshold && short >= alert.Threshold
+
+		if firing == state.firing[alert.Name] {
+			continue
+		}
+		state.firing[alert.Name] = firing
+
+		event := models.SLOEvent{
+			Type:          models.SLOEventBurnRateHigh,
+			Route:         state.objective.Route,
+			Method:        state.objective.Method,
+			Alert:         alert.Name,
+			Severity:      alert.Severity,
+			BurnRate:      long,
+			ShortBurnRate: short,
+			Threshold:     alert.Threshold,
+			Timestamp:     now,
+		}
+		if firing {
+			t.logger.Warn("API SLO burn rate alert firing",
+				zap.String("route", event.Route),
+				zap.String("method", event.Method),
+				zap.String("alert", event.Alert),
+				zap.Float64("burn_rate", long),
+				zap.Float64("short_burn_rate", short),
+				zap.Float64("threshold", alert.Threshold))
+		} else {
+			event.Type = models.SLOEventBurnRateRecovered
+			event.Severity = "info"
+			t.logger.Info("API SLO burn rate alert resolved",
+				zap.String("route", event.Route),
+				zap.String("method", event.Method),
+				zap.String("alert", event.Alert))
+		}
+
+		t.events = append(t.events, event)
+		if len(t.events) > maxSLOEvents {
+			t.events = t.events[len(t.events)-maxSLOEvents:]
+		}
+		events = append(events, event)
+	}
+
+	return events
+}
+
+// GetSLOStatus returns the current state of every objective
+func (t *SLOTracker) GetSLOStatus() []models.SLOStatus {
+	t.mu.Lock()
+
+	now := t.now()
+	statuses := make([]models.SLOStatus, 0, len(t.objectives))
+	var events []models.SLOEvent
+	for _, state := range t.objectives {
+		// Re-evaluate so alerts resolve even when traffic has stopped
+		events = append(events, t.evaluate(state, now)...)
+
+		status := models.SLOStatus{
+			Route:            state.objective.Route,
+			Method:           state.objective.Method,
+			LatencyThreshold: state.objective.LatencyThreshold.String(),
+			Target:           state.objective.Target,
+			Requests:         state.total,
+			BadRequests:      state.bad,
+			BurnRates:        make(map[string]float64),
+		}
+		for _, alert := range t.alerts {
+			status.BurnRates[alert.LongWindow.String()] = t.burnRate(state, alert.LongWindow, now)
+			status.BurnRates[alert.ShortWindow.String()] = t.burnRate(state, alert.ShortWindow, now)
+			if state.firing[alert.Name] {
+				status.FiringAlerts = append(status.FiringAlerts, alert.Name)
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	listeners := t.listeners
+	t.mu.Unlock()
+
+	for _, event := range events {
+		for _, fn := range listeners {
+			fn(event)
+		}
+	}
+
+	return statuses
+}
+
+// GetSLOEvents returns recent burn-rate events, oldest first
+func (t *SLOTracker) GetSLOEvents() []models.SLOEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	events := make([]models.SLOEvent, len(t.events))
+	copy(events, t.events)
+	return events
+}
+
+// GetCustomMetrics exposes SLO state as Prometheus metrics
+func (t *SLOTracker) GetCustomMetrics() []handlers.Metric {
+	statuses := t.GetSLOStatus()
+
+	// Series of one family must be contiguous, so collect per family
+	families := []struct {
+		name, help, metricType string
+		series                 []handlers.Metric
+	}{
+		{name: "nrdot_api_slo_requests_total", help: "Requests counted against the route SLO.", metricType: "counter"},
+		{name: "nrdot_api_slo_bad_requests_total", help: "Requests that were too slow or failed.", metricType: "counter"},
+		{name: "nrdot_api_slo_burn_rate", help: "Error budget burn rate over a rolling window.", metricType: "gauge"},
+		{name: "nrdot_api_slo_alert_firing", help: "Whether a burn-rate alert is firing.", metricType: "gauge"},
+	}
+	add := func(family int, value float64, labels map[string]string) {
+		families[family].series = append(families[family].series, handlers.Metric{
+			Name:   families[family].name,
+			Value:  value,
+			Labels: labels,
+		})
+	}
+
+	for _, status := range statuses {
+		labels := map[string]string{"route": status.Route, "method": status.Method}
+
+		add(0, float64(status.Requests), labels)
+		add(1, float64(status.BadRequests), labels)
+
+		windows := make([]string, 0, len(status.BurnRates))
+		for window := range status.BurnRates {
+			windows = append(windows, window)
+		}
+		sort.Strings(windows)
+		for _, window := range windows {
+			add(2, status.BurnRates[window], withLabel(labels, "window", window))
+		}
+
+		for _, alert := range t.alerts {
+			firing := 0.0
+			for _, name := range status.FiringAlerts {
+				if name == alert.Name {
+					firing = 1
+				}
+			}
+			add(3, firing, withLabel(labels, "alert", alert.Name))
+		}
+	}
+
+	var metrics []handlers.Metric
+	for _, family := range families {
+		if len(family.series) == 0 {
+			continue
+		}
+		// Only the first series of a family carries HELP and TYPE
+		family.series[0].Help = family.help
+		family.series[0].Type = family.metricType
+		metrics = append(metrics, family.series...)
+	}
+	return metrics
+}
+
+// withLabel returns a copy of labels with one extra label set
+func withLabel(labels map[string]string, key, value string) map[string]string {
+	out := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestSLOTracker(now *time.Time) *SLOTracker {
+	tracker := NewSLOTracker(DefaultSLOObjectives(), DefaultBurnRateAlerts(), zap.NewNop())
+	tracker.now = func() time.Time { return *now }
+	return tracker
+}
+
+func TestSLOTrackerRecord(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newTestSLOTracker(&now)
+
+	tracker.Record("/v1/config", http.MethodPost, http.StatusOK, 2*time.Second)
+	tracker.Record("/v1/config", http.MethodPost, http.StatusOK, 11*time.Second)
+	tracker.Record("/v1/config", http.MethodPost, http.StatusInternalServerError, time.Millisecond)
+	tracker.Record("/v1/config", http.MethodGet, http.StatusOK, 2*time.Second)
+	tracker.Record("/v1/unknown", http.MethodGet, http.StatusOK, time.Millisecond)
+
+	statuses := tracker.GetSLOStatus()
+	byKey := make(map[string]models.SLOStatus)
+	for _, status := range statuses {
+		byKey[status.Method+" "+status.Route] = status
+	}
+
+	post := byKey["POST /v1/config"]
+	assert.Equal(t, int64(3), post.Requests)
+	assert.Equal(t, int64(2), post.BadRequests)
+	assert.Equal(t, "10s", post.LatencyThreshold)
+
+	// GET has a tighter threshold than POST
+	get := byKey["GET /v1/config"]
+	assert.Equal(t, int64(1), get.Requests)
+	assert.Equal(t, int64(1), get.BadRequests)
+}
+
+func TestSLOTrackerBurnRateAlerts(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newTestSLOTracker(&now)
+
+	var received []models.SLOEvent
+	tracker.OnEvent(func(event models.SLOEvent) {
+		received = append(received, event)
+	})
+
+	// Every config apply is slow: burn rate 100 over all windows
+	for i := 0; i < 10; i++ {
+		tracker.Record("/v1/config", http.MethodPost, http.StatusOK, 15*time.Second)
+	}
+
+	require.Len(t, received, 2)
+	for _, event := range received {
+		assert.Equal(t, models.SLOEventBurnRateHigh, event.Type)
+		assert.Equal(t, "/v1/config", event.Route)
+		assert.InDelta(t, 100, event.BurnRate, 0.01)
+	}
+	assert.Equal(t, "fast_burn", received[0].Alert)
+	assert.Equal(t, "critical", received[0].Severity)
+	assert.Equal(t, "slow_burn", received[1].Alert)
+
+	// Repeated bad requests do not re-emit while firing
+	tracker.Record("/v1/config", http.MethodPost, http.StatusOK, 15*time.Second)
+	assert.Len(t, received, 2)
+
+	// Once the short windows pass with only good traffic, alerts resolve
+	now = now.Add(31 * time.Minute)
+	for i := 0; i < 10; i++ {
+		tracker.Record("/v1/config", http.MethodPost, http.StatusOK, time.Second)
+	}
+	require.Len(t, received, 4)
+	assert.Equal(t, models.SLOEventBurnRateRecovered, received[2].Type)
+	assert.Equal(t, models.SLOEventBurnRateRecovered, received[3].Type)
+
+	assert.Equal(t, received, tracker.GetSLOEvents())
+}
+
+func TestSLOTrackerWindowExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newTestSLOTracker(&now)
+
+	tracker.Record("/v1/status", http.MethodGet, http.StatusInternalServerError, time.Millisecond)
+
+	status := tracker.GetSLOStatus()[0]
+	assert.Greater(t, status.BurnRates["5m0s"], 0.0)
+	assert.Equal(t, []string{"fast_burn", "slow_burn"}, status.FiringAlerts)
+
+	// Bad requests age out of the windows, lifetime totals are kept
+	now = now.Add(7 * time.Hour)
+	status = tracker.GetSLOStatus()[0]
+	assert.Equal(t, 0.0, status.BurnRates["6h0m0s"])
+	assert.Empty(t, status.FiringAlerts)
+	assert.Equal(t, int64(1), status.BadRequests)
+}
+
+func TestSLOMiddleware(t *testing.T) {
+	now := time.Now()
+	tracker := newTestSLOTracker(&now)
+
+	router := mux.NewRouter()
+	router.Use(tracker.Middleware())
+	v1 := router.PathPrefix("/v1").Subrouter()
+	v1.HandleFunc("/reload", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}).Methods("POST")
+
+	req := httptest.NewRequest("POST", "/v1/reload", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	for _, status := range tracker.GetSLOStatus() {
+		if status.Route == "/v1/reload" {
+			assert.Equal(t, int64(1), status.Requests)
+			assert.Equal(t, int64(1), status.BadRequests)
+			return
+		}
+	}
+	t.Fatal("reload objective not found")
+}
+
+func TestSLOCustomMetrics(t *testing.T) {
+	now := time.Now()
+	tracker := NewSLOTracker([]SLOObjective{
+		{Route: "/v1/status", Method: http.MethodGet, LatencyThreshold: time.Second, Target: 0.99},
+		{Route: "/v1/health", Method: http.MethodGet, LatencyThreshold: time.Second, Target: 0.99},
+	}, DefaultBurnRateAlerts(), zap.NewNop())
+	tracker.now = func() time.Time { return now }
+
+	tracker.Record("/v1/status", http.MethodGet, http.StatusOK, time.Millisecond)
+
+	metrics := tracker.GetCustomMetrics()
+	require.NotEmpty(t, metrics)
+
+	// Each family is contiguous and only its first series has HELP/TYPE
+	seen := make(map[string]bool)
+	last := ""
+	for _, metric := range metrics {
+		if metric.Name != last {
+			assert.False(t, seen[metric.Name], "family %s is not contiguous", metric.Name)
+			assert.NotEmpty(t, metric.Help)
+			assert.NotEmpty(t, metric.Type)
+			seen[metric.Name] = true
+			last = metric.Name
+		} else {
+			assert.Empty(t, metric.Help)
+		}
+	}
+	assert.True(t, seen["nrdot_api_slo_requests_total"])
+	assert.True(t, seen["nrdot_api_slo_bad_requests_total"])
+	assert.True(t, seen["nrdot_api_slo_burn_rate"])
+	assert.True(t, seen["nrdot_api_slo_alert_firing"])
+}
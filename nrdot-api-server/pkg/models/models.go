@@ -14,6 +14,10 @@ type StatusResponse struct {
 	ConfigHash  string            `json:"config_hash"`
 	LastReload  *time.Time        `json:"last_reload,omitempty"`
 	Errors      []ErrorInfo       `json:"errors,omitempty"`
+
+	// StatusVersion changes whenever the content above, uptime aside,
+	// changes; pass it as if_version to long-poll for the next change
+	StatusVersion uint64 `json:"status_version"`
 }
 
 // CollectorStatus represents the status of a collector
@@ -61,6 +65,153 @@ type ValidationError struct {
 	Message string `json:"message"`
 }
 
+// Constants for config provenance source types
+const (
+	ConfigSourceUser       = "user"       // the user's config file or API update
+	ConfigSourceAutoconfig = "autoconfig" // generated from discovered services
+	ConfigSourceRemote     = "remote"     // received from the remote config service
+	ConfigSourceDefault    = "default"    // built-in defaults and templates
+)
+
+// ConfigProvenance represents which inputs the active configuration was
+// built from and which of them set each part of it
+type ConfigProvenance struct {
+	ConfigHash string                `json:"config_hash"`
+	LoadedAt   time.Time             `json:"loaded_at"`
+	Sources    []ConfigSource        `json:"sources"`
+	Components []ComponentProvenance `json:"components"`
+}
+
+// ConfigSource represents one input of the active configuration
+type ConfigSource struct {
+	Type      string    `json:"type"`               // one of the ConfigSource* constants
+	Location  string    `json:"location,omitempty"` // e.g. a file path or service URL
+	Hash      string    `json:"hash,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ComponentProvenance represents where one part of the active
+// configuration, such as "receivers.mysql", came from
+type ComponentProvenance struct {
+	Path       string   `json:"path"`
+	Source     string   `json:"source"`
+	Reason     string   `json:"reason,omitempty"`     // e.g. "mysql discovered on port 3306"
+	Overridden []string `json:"overridden,omitempty"` // sources whose setting this one replaced
+}
+
+// ConfigProvenanceResponse represents the provenance of the active
+// configuration
+type ConfigProvenanceResponse struct {
+	ConfigProvenance
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Reasons auto-configuration leaves a receiver out
+const (
+	ActionMissingCredentials = "missing_credentials"
+	ActionInvalidCredentials = "invalid_credentials"
+)
+
+// ActionNeeded represents a receiver auto-configuration left out of the
+// config until the user provides or fixes its credentials
+type ActionNeeded struct {
+	Service     string   `json:"service"`
+	Receiver    string   `json:"receiver"`
+	Endpoint    string   `json:"endpoint,omitempty"`
+	Reason      string   `json:"reason"`    // one of the Action* constants
+	Variables   []string `json:"variables"` // environment variables to set or fix
+	Message     string   `json:"message"`
+	Remediation string   `json:"remediation"`
+}
+
+// ActionsNeededResponse represents the actions auto-configuration needs
+// from the user
+type ActionsNeededResponse struct {
+	Actions   []ActionNeeded `json:"actions"`
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+// Suggestion represents a receiver auto-configuration left out of the
+// config because its service was found with LOW confidence, e.g. from a
+// listening port alone, until the service is pinned
+type Suggestion struct {
+	Service      string   `json:"service"`
+	Receiver     string   `json:"receiver"`
+	Endpoint     string   `json:"endpoint,omitempty"`
+	Confidence   string   `json:"confidence"`
+	DiscoveredBy []string `json:"discovered_by"`
+	Message      string   `json:"message"`
+	Remediation  string   `json:"remediation"`
+}
+
+// SuggestionsResponse represents the receivers auto-configuration suggests
+// without enabling them
+type SuggestionsResponse struct {
+	Suggestions []Suggestion `json:"suggestions"`
+	Timestamp   time.Time    `json:"timestamp"`
+}
+
+// SummaryResponse represents the state of a host in one compact document,
+// for fleet dashboards polling many hosts
+type SummaryResponse struct {
+	AgentID       string              `json:"agent_id,omitempty"`
+	Hostname      string              `json:"hostname,omitempty"`
+	Versions      map[string]string   `json:"versions"` // e.g. "api_server", "collector"
+	Status        string              `json:"status"`   // overall collector status, as on /v1/status
+	Health        string              `json:"health"`   // overall component health, as on /v1/health
+	Uptime        string              `json:"uptime"`
+	StatusVersion uint64              `json:"status_version"`
+	Config        ConfigSummary       `json:"config"`
+	Pipelines     PipelineCounts      `json:"pipelines"`
+	Errors        int                 `json:"errors"` // distinct errors, as listed on /v1/status
+	Discovery     *DiscoverySummary   `json:"discovery,omitempty"`
+	Cardinality   *CardinalitySummary `json:"cardinality,omitempty"`
+	Timestamp     time.Time           `json:"timestamp"`
+}
+
+// HostSummary represents the parts of the summary that the status and
+// health providers do not report
+type HostSummary struct {
+	AgentID       string
+	Hostname      string
+	Versions      map[string]string
+	ConfigVersion int
+	Pipelines     PipelineCounts
+	Discovery     *DiscoverySummary
+	Cardinality   *CardinalitySummary
+}
+
+// ConfigSummary represents the active configuration
+type ConfigSummary struct {
+	Version    int        `json:"version,omitempty"`
+	Hash       string     `json:"hash"`
+	LastReload *time.Time `json:"last_reload,omitempty"`
+}
+
+// PipelineCounts represents the collector pipelines by state
+type PipelineCounts struct {
+	Total   int `json:"total"`
+	Running int `json:"running"`
+	Failed  int `json:"failed"`
+}
+
+// DiscoverySummary represents the last service discovery run
+type DiscoverySummary struct {
+	LastRun  *time.Time `json:"last_run,omitempty"`
+	Services int        `json:"services"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// CardinalitySummary represents the headline numbers of the cardinality
+// limiter
+type CardinalitySummary struct {
+	ActiveSeries   int64   `json:"active_series"`
+	GlobalLimit    int64   `json:"global_limit"`
+	Utilization    float64 `json:"utilization"` // percent of the global limit in use
+	DroppedMetrics int64   `json:"dropped_metrics"`
+}
+
 // ReloadRequest represents a configuration reload request
 type ReloadRequest struct {
 	Force bool `json:"force,omitempty"`
@@ -94,6 +245,9 @@ type Health struct {
 type MetricsResponse string
 
 // ErrorResponse represents an error response
+//
+// Deprecated: errors are answered as problem details, see
+// nrdot-common/pkg/problem.
 type ErrorResponse struct {
 	Error   string    `json:"error"`
 	Code    string    `json:"code,omitempty"`
@@ -110,6 +264,170 @@ type ErrorInfo struct {
 	LastSeen  time.Time `json:"last_seen"`
 }
 
+// SLOResponse represents the API's own latency SLO state
+type SLOResponse struct {
+	Objectives []SLOStatus `json:"objectives"`
+	Events     []SLOEvent  `json:"events"`
+	Timestamp  time.Time   `json:"timestamp"`
+}
+
+// SLOStatus represents the state of one route objective
+type SLOStatus struct {
+	Route            string             `json:"route"`
+	Method           string             `json:"method,omitempty"`
+	LatencyThreshold string             `json:"latency_threshold"`
+	Target           float64            `json:"target"`
+	Requests         int64              `json:"requests"`
+	BadRequests      int64              `json:"bad_requests"`
+	BurnRates        map[string]float64 `json:"burn_rates"`
+	FiringAlerts     []string           `json:"firing_alerts,omitempty"`
+}
+
+// SLOEvent represents a burn-rate alert transition
+type SLOEvent struct {
+	Type          string    `json:"type"` // "burn_rate_high", "burn_rate_recovered"
+	Route         string    `json:"route"`
+	Method        string    `json:"method,omitempty"`
+	Alert         string    `json:"alert"`
+	Severity      string    `json:"severity"`
+	BurnRate      float64   `json:"burn_rate"`
+	ShortBurnRate float64   `json:"short_burn_rate"`
+	Threshold     float64   `json:"threshold"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// Constants for SLO event types
+const (
+	SLOEventBurnRateHigh      = "burn_rate_high"
+	SLOEventBurnRateRecovered = "burn_rate_recovered"
+)
+
+// Event represents a system event sent on the event stream
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"` // e.g. "status.changed", "collector.restarted"
+	Severity  string      `json:"severity"`
+	Message   string      `json:"message,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// Constants for event types raised by the API server itself
+const (
+	EventTypeStatusChanged = "status.changed"
+)
+
+// Constants for event severities
+const (
+	EventSeverityInfo    = "info"
+	EventSeverityWarning = "warning"
+	EventSeverityError   = "error"
+)
+
+// Constants for delegated token scopes
+const (
+	// TokenScopeReadOnly allows GET and HEAD requests
+	TokenScopeReadOnly = "read-only"
+	// TokenScopeReadWrite allows every request except token management
+	TokenScopeReadWrite = "read-write"
+)
+
+// DelegatedTokenRequest represents a request to mint a temporary token
+type DelegatedTokenRequest struct {
+	IssuedTo string `json:"issued_to"`        // who the token is handed to
+	Reason   string `json:"reason,omitempty"` // e.g. an incident or ticket
+	Scope    string `json:"scope,omitempty"`  // defaults to read-only
+	TTL      string `json:"ttl,omitempty"`    // e.g. "4h", defaults to the server default
+}
+
+// DelegatedToken represents a temporary access token; the secret itself is
+// only returned once, when it is minted
+type DelegatedToken struct {
+	ID        string     `json:"id"`
+	IssuedTo  string     `json:"issued_to"`
+	Reason    string     `json:"reason,omitempty"`
+	Scope     string     `json:"scope"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
+	Uses      int64      `json:"uses"`
+}
+
+// DelegatedTokenResponse represents a newly minted token
+type DelegatedTokenResponse struct {
+	DelegatedToken
+	Token string `json:"token"`
+}
+
+// DelegatedTokenList represents the active delegated tokens
+type DelegatedTokenList struct {
+	Tokens    []DelegatedToken `json:"tokens"`
+	Timestamp time.Time        `json:"timestamp"`
+}
+
+// AuditEntry represents one audited delegated access event
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Actor    string    `json:"actor"` // "admin" or "token:<id>"
+	TokenID  string    `json:"token_id,omitempty"`
+	IssuedTo string    `json:"issued_to,omitempty"`
+	Method   string    `json:"method,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Status   int       `json:"status,omitempty"`
+	Details  string    `json:"details,omitempty"`
+}
+
+// AuditResponse represents the audit log
+type AuditResponse struct {
+	Entries   []AuditEntry `json:"entries"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// Constants for audit actions
+const (
+	AuditTokenMinted  = "token_minted"
+	AuditTokenRevoked = "token_revoked"
+	AuditTokenExpired = "token_expired"
+	AuditAccess       = "access"
+	AuditDenied       = "denied"
+)
+
+// WebhookRequest represents a request to register a webhook
+type WebhookRequest struct {
+	URL         string   `json:"url"`
+	EventTypes  []string `json:"event_types,omitempty"` // types or prefixes ending in "."; every event when empty
+	Secret      string   `json:"secret,omitempty"`      // signs deliveries, generated when empty
+	Description string   `json:"description,omitempty"`
+}
+
+// Webhook represents a registered webhook; its secret is only returned
+// once, when it is registered
+type Webhook struct {
+	ID           string     `json:"id"`
+	URL          string     `json:"url"`
+	EventTypes   []string   `json:"event_types,omitempty"`
+	Description  string     `json:"description,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Delivered    int64      `json:"delivered"`
+	Failed       int64      `json:"failed"`  // deliveries given up after retries
+	Dropped      int64      `json:"dropped"` // events dropped because the queue was full
+	LastDelivery *time.Time `json:"last_delivery,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// WebhookResponse represents a newly registered webhook
+type WebhookResponse struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhookList represents the registered webhooks
+type WebhookList struct {
+	Webhooks  []Webhook `json:"webhooks"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
 // Constants for status values
 const (
 	StatusHealthy   = "healthy"
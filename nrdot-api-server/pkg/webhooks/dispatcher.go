@@ -0,0 +1,448 @@
+// Package webhooks delivers API server events to registered webhook URLs.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/handlers"
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/backoff"
+	"go.uber.org/zap"
+)
+
+// Headers of a delivery
+const (
+	// SignatureHeader holds "sha256=" and the hex HMAC-SHA256, keyed with
+	// the webhook secret, of the timestamp header, a dot and the body
+	SignatureHeader = "X-NRDOT-Signature"
+	// TimestampHeader holds the Unix time of the attempt
+	TimestampHeader = "X-NRDOT-Timestamp"
+	// EventHeader holds the event type
+	EventHeader = "X-NRDOT-Event"
+	// DeliveryHeader identifies a delivery; retries repeat it
+	DeliveryHeader = "X-NRDOT-Delivery"
+)
+
+const (
+	// maxWebhooks bounds the number of registered webhooks
+	maxWebhooks = 20
+	// queueSize is the number of events waiting per webhook before new
+	// ones are dropped
+	queueSize = 100
+	// minSecretLength is the shortest secret a client may choose
+	minSecretLength = 16
+)
+
+// Config represents delivery settings
+type Config struct {
+	MaxAttempts    int           // attempts per event, defaults to 5
+	InitialBackoff time.Duration // wait before the first retry, doubled after each, ±20%, defaults to 1s
+	MaxBackoff     time.Duration // longest wait between retries, defaults to 1m
+	Timeout        time.Duration // per attempt, defaults to 10s
+}
+
+// Dispatcher delivers events to registered webhooks. Each webhook has its
+// own queue and worker, so a slow or failing endpoint only delays its own
+// deliveries. Failed deliveries are retried with exponential backoff on
+// network errors, 429 and 5xx responses.
+type Dispatcher struct {
+	config Config
+	client *http.Client
+	logger *zap.Logger
+
+	mu     sync.Mutex
+	hooks  map[string]*hook
+	closed bool
+
+	workers sync.WaitGroup
+}
+
+// hook is a registered webhook
+type hook struct {
+	info   models.Webhook // guarded by Dispatcher.mu
+	secret []byte
+	queue  chan delivery
+
+	// ctx ends the worker and aborts its delivery when the webhook is removed
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// delivery is an event queued for a webhook
+type delivery struct {
+	id        string
+	eventType string
+	body      []byte
+}
+
+// NewDispatcher creates a dispatcher without webhooks
+func NewDispatcher(config Config, logger *zap.Logger) *Dispatcher {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = time.Minute
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	return &Dispatcher{
+		config: config,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			// A redirect would resend the signed body somewhere else
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		logger: logger,
+		hooks:  make(map[string]*hook),
+	}
+}
+
+// Register adds a webhook and returns it with its secret, which is not
+// returned again
+func (d *Dispatcher) Register(req models.WebhookRequest) (*models.WebhookResponse, error) {
+	if err := checkURL(req.URL); err != nil {
+		return nil, err
+	}
+	var eventTypes []string
+	for _, t := range req.EventTypes {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			return nil, errors.New("event_types must not contain empty types")
+		}
+		eventTypes = append(eventTypes, t)
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		generated, err := randomHex(32)
+		if err != nil {
+			return nil, err
+		}
+		secret = generated
+	} else if len(secret) < minSecretLength {
+		return nil, fmt.Errorf("secret must be at least %d characters", minSecretLength)
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, errors.New("webhook dispatcher is closed")
+	}
+	if len(d.hooks) >= maxWebhooks {
+		return nil, handlers.ErrTooManyWebhooks
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &hook{
+		info: models.Webhook{
+			ID:          id,
+			URL:         req.URL,
+			EventTypes:  eventTypes,
+			Description: req.Description,
+			CreatedAt:   time.Now(),
+		},
+		secret: []byte(secret),
+		queue:  make(chan delivery, queueSize),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	d.hooks[id] = h
+
+	d.workers.Add(1)
+	go d.run(h)
+
+	d.logger.Info("Webhook registered",
+		zap.String("id", id),
+		zap.String("url", redactURL(req.URL)),
+		zap.Strings("event_types", eventTypes),
+	)
+	return &models.WebhookResponse{Webhook: h.info, Secret: secret}, nil
+}
+
+// Remove removes a webhook, dropping its queued events
+func (d *Dispatcher) Remove(id string) error {
+	d.mu.Lock()
+	h, ok := d.hooks[id]
+	if ok {
+		delete(d.hooks, id)
+	}
+	d.mu.Unlock()
+
+	if !ok {
+		return handlers.ErrWebhookNotFound
+	}
+	h.cancel()
+	d.logger.Info("Webhook removed", zap.String("id", id))
+	return nil
+}
+
+// GetWebhook returns a webhook with its delivery state
+func (d *Dispatcher) GetWebhook(id string) (models.Webhook, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	h, ok := d.hooks[id]
+	if !ok {
+		return models.Webhook{}, handlers.ErrWebhookNotFound
+	}
+	return h.info, nil
+}
+
+// ListWebhooks returns the registered webhooks, oldest first
+func (d *Dispatcher) ListWebhooks() []models.Webhook {
+	d.mu.Lock()
+	webhooks := make([]models.Webhook, 0, len(d.hooks))
+	for _, h := range d.hooks {
+		webhooks = append(webhooks, h.info)
+	}
+	d.mu.Unlock()
+
+	sort.Slice(webhooks, func(i, j int) bool {
+		if !webhooks[i].CreatedAt.Equal(webhooks[j].CreatedAt) {
+			return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+		}
+		return webhooks[i].ID < webhooks[j].ID
+	})
+	return webhooks
+}
+
+// Dispatch queues an event for every webhook subscribed to its type. It
+// does not block: when a webhook's queue is full the event is dropped for it.
+func (d *Dispatcher) Dispatch(event models.Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.logger.Warn("Failed to encode event", zap.String("type", event.Type), zap.Error(err))
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, h := range d.hooks {
+		if !handlers.MatchEventTypes(h.info.EventTypes, event.Type) {
+			continue
+		}
+		id, err := randomHex(8)
+		if err != nil {
+			d.logger.Warn("Failed to queue webhook delivery", zap.Error(err))
+			return
+		}
+		select {
+		case h.queue <- delivery{id: id, eventType: event.Type, body: body}:
+		default:
+			h.info.Dropped++
+			d.logger.Warn("Webhook queue full, dropping event",
+				zap.String("id", h.info.ID),
+				zap.String("type", event.Type),
+			)
+		}
+	}
+}
+
+// Close removes every webhook and waits for deliveries in flight to end
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	d.closed = true
+	for id, h := range d.hooks {
+		h.cancel()
+		delete(d.hooks, id)
+	}
+	d.mu.Unlock()
+
+	d.workers.Wait()
+}
+
+// run delivers a webhook's queued events in order until it is removed
+func (d *Dispatcher) run(h *hook) {
+	defer d.workers.Done()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case dl := <-h.queue:
+			d.deliver(h, dl)
+		}
+	}
+}
+
+// deliveryJitter spreads out the retries of deliveries that failed together,
+// e.g. to a receiver that was down
+const deliveryJitter = 0.2
+
+// deliver sends an event, retrying with exponential backoff, and records
+// the outcome
+func (d *Dispatcher) deliver(h *hook, dl delivery) {
+	maxRetries := d.config.MaxAttempts - 1
+	if maxRetries == 0 {
+		maxRetries = -1 // a single attempt
+	}
+	retries := backoff.New(backoff.Policy{
+		Initial:    d.config.InitialBackoff,
+		Max:        d.config.MaxBackoff,
+		Jitter:     deliveryJitter,
+		MaxRetries: maxRetries,
+	}, nil)
+	for attempt := 1; ; attempt++ {
+		retry, err := d.send(h, dl)
+		if err == nil {
+			now := time.Now()
+			d.mu.Lock()
+			h.info.Delivered++
+			h.info.LastDelivery = &now
+			h.info.LastError = ""
+			d.mu.Unlock()
+			return
+		}
+		if h.ctx.Err() != nil {
+			return
+		}
+
+		delay, ok := retries.Next()
+		if !retry || !ok {
+			d.mu.Lock()
+			h.info.Failed++
+			h.info.LastError = err.Error()
+			d.mu.Unlock()
+			d.logger.Warn("Webhook delivery failed",
+				zap.String("id", h.info.ID),
+				zap.String("delivery", dl.id),
+				zap.String("type", dl.eventType),
+				zap.Int("attempts", attempt),
+				zap.Error(err),
+			)
+			return
+		}
+
+		d.logger.Debug("Retrying webhook delivery",
+			zap.String("id", h.info.ID),
+			zap.String("delivery", dl.id),
+			zap.Duration("backoff", delay),
+			zap.Error(err),
+		)
+		timer := time.NewTimer(delay)
+		select {
+		case <-h.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// send makes one delivery attempt. retry reports whether a failure is
+// worth retrying.
+func (d *Dispatcher) send(h *hook, dl delivery) (retry bool, err error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(h.ctx, http.MethodPost, h.info.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "nrdot-api-server")
+	req.Header.Set(EventHeader, dl.eventType)
+	req.Header.Set(DeliveryHeader, dl.id)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(h.secret, timestamp, dl.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook returned %s", resp.Status)
+}
+
+// Sign returns the SignatureHeader value of a delivery, for receivers to
+// compare with
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkURL requires an absolute http or https URL; plain http only to
+// loopback addresses, as payloads may describe the host
+func checkURL(raw string) error {
+	if raw == "" {
+		return errors.New("url is required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid url %q: must be absolute", raw)
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+			return nil
+		}
+		return fmt.Errorf("invalid url %q: http is only allowed to localhost, use https", raw)
+	default:
+		return fmt.Errorf("invalid url %q: scheme must be https", raw)
+	}
+}
+
+// redactURL drops the credentials and query of a URL for logging, as
+// webhook URLs often carry tokens
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	u.User = nil
+	u.RawQuery = ""
+	return u.String()
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,184 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/handlers"
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// receiver records the deliveries a webhook endpoint receives, answering
+// with the given status codes in turn and 200 after them
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rv.mu.Lock()
+	rv.requests = append(rv.requests, r)
+	rv.bodies = append(rv.bodies, body)
+	status := http.StatusOK
+	if len(rv.statuses) > 0 {
+		status, rv.statuses = rv.statuses[0], rv.statuses[1:]
+	}
+	rv.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+func (rv *receiver) count() int {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+	return len(rv.requests)
+}
+
+func newTestDispatcher(t *testing.T) *Dispatcher {
+	d := NewDispatcher(Config{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond}, zap.NewNop())
+	t.Cleanup(d.Close)
+	return d
+}
+
+func TestDispatcherDelivery(t *testing.T) {
+	rv := &receiver{}
+	ts := httptest.NewServer(rv)
+	defer ts.Close()
+
+	d := newTestDispatcher(t)
+	webhook, err := d.Register(models.WebhookRequest{
+		URL:        ts.URL,
+		EventTypes: []string{"collector.", "config.rejected"},
+	})
+	require.NoError(t, err)
+	assert.Len(t, webhook.Secret, 64)
+
+	d.Dispatch(models.Event{Type: "config.applied"})
+	d.Dispatch(models.Event{ID: "1", Type: "collector.restarted", Severity: models.EventSeverityWarning, Message: "Collector restarted"})
+	require.Eventually(t, func() bool { return rv.count() == 1 }, time.Second, 10*time.Millisecond)
+
+	rv.mu.Lock()
+	req, body := rv.requests[0], rv.bodies[0]
+	rv.mu.Unlock()
+	assert.Equal(t, "collector.restarted", req.Header.Get(EventHeader))
+	assert.NotEmpty(t, req.Header.Get(DeliveryHeader))
+	assert.Equal(t, Sign([]byte(webhook.Secret), req.Header.Get(TimestampHeader), body), req.Header.Get(SignatureHeader))
+
+	var event models.Event
+	require.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, "Collector restarted", event.Message)
+
+	require.Eventually(t, func() bool {
+		info, err := d.GetWebhook(webhook.ID)
+		return err == nil && info.Delivered == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDispatcherRetry(t *testing.T) {
+	rv := &receiver{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	ts := httptest.NewServer(rv)
+	defer ts.Close()
+
+	d := newTestDispatcher(t)
+	webhook, err := d.Register(models.WebhookRequest{URL: ts.URL, Secret: "0123456789abcdef"})
+	require.NoError(t, err)
+
+	// Retried until accepted, with the same delivery ID
+	d.Dispatch(models.Event{Type: "collector.crashed"})
+	require.Eventually(t, func() bool {
+		info, _ := d.GetWebhook(webhook.ID)
+		return info.Delivered == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, 3, rv.count())
+	rv.mu.Lock()
+	assert.Equal(t, rv.requests[0].Header.Get(DeliveryHeader), rv.requests[2].Header.Get(DeliveryHeader))
+	rv.mu.Unlock()
+
+	// Client errors are not retried
+	rv.mu.Lock()
+	rv.statuses = []int{http.StatusBadRequest}
+	rv.mu.Unlock()
+	d.Dispatch(models.Event{Type: "collector.crashed"})
+	require.Eventually(t, func() bool {
+		info, _ := d.GetWebhook(webhook.ID)
+		return info.Failed == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 4, rv.count())
+
+	info, err := d.GetWebhook(webhook.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "webhook returned 400 Bad Request", info.LastError)
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	var attempts atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	d := newTestDispatcher(t)
+	webhook, err := d.Register(models.WebhookRequest{URL: ts.URL})
+	require.NoError(t, err)
+
+	d.Dispatch(models.Event{Type: "cardinality.limit_exceeded"})
+	require.Eventually(t, func() bool {
+		info, _ := d.GetWebhook(webhook.ID)
+		return info.Failed == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestDispatcherRegister(t *testing.T) {
+	d := newTestDispatcher(t)
+
+	tests := []struct {
+		name   string
+		req    models.WebhookRequest
+		errMsg string
+	}{
+		{"no url", models.WebhookRequest{}, "url is required"},
+		{"relative url", models.WebhookRequest{URL: "/hook"}, "must be absolute"},
+		{"plain http", models.WebhookRequest{URL: "http://hooks.example.com/nrdot"}, "use https"},
+		{"other scheme", models.WebhookRequest{URL: "ftp://hooks.example.com/nrdot"}, "scheme must be https"},
+		{"empty type", models.WebhookRequest{URL: "https://hooks.example.com", EventTypes: []string{" "}}, "empty types"},
+		{"short secret", models.WebhookRequest{URL: "https://hooks.example.com", Secret: "short"}, "at least 16"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := d.Register(tt.req)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+
+	first, err := d.Register(models.WebhookRequest{URL: "https://hooks.example.com/a"})
+	require.NoError(t, err)
+	_, err = d.Register(models.WebhookRequest{URL: "http://localhost:9000/b"})
+	require.NoError(t, err)
+	assert.Len(t, d.ListWebhooks(), 2)
+
+	require.NoError(t, d.Remove(first.ID))
+	assert.ErrorIs(t, d.Remove(first.ID), handlers.ErrWebhookNotFound)
+	_, err = d.GetWebhook(first.ID)
+	assert.ErrorIs(t, err, handlers.ErrWebhookNotFound)
+
+	for i := len(d.ListWebhooks()); i < maxWebhooks; i++ {
+		_, err := d.Register(models.WebhookRequest{URL: "https://hooks.example.com"})
+		require.NoError(t, err)
+	}
+	_, err = d.Register(models.WebhookRequest{URL: "https://hooks.example.com"})
+	assert.ErrorIs(t, err, handlers.ErrTooManyWebhooks)
+}
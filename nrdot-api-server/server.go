@@ -5,11 +5,18 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/handlers"
 	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/middleware"
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/webhooks"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/tlsconfig"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/tracecontext"
 	"go.uber.org/zap"
 )
 
@@ -25,6 +32,34 @@ type Server struct {
 	healthProvider handlers.HealthProvider
 	configProvider handlers.ConfigProvider
 	metricsProvider handlers.MetricsProvider
+	eventProvider   handlers.EventProvider
+	provenanceProvider handlers.ProvenanceProvider
+	summaryProvider    handlers.SummaryProvider
+	actionsProvider    handlers.ActionsProvider
+	suggestionsProvider handlers.SuggestionsProvider
+
+	// SLO tracking of the API's own routes, nil when disabled
+	sloTracker *middleware.SLOTracker
+
+	// Token authentication, nil when no admin token is configured
+	tokenAuth *middleware.TokenAuthenticator
+	stopSweep context.CancelFunc
+
+	// Rate limiting middleware, nil when disabled. Created once so buckets
+	// survive route rebuilds.
+	rateLimit func(http.Handler) http.Handler
+
+	// Cache of the aggregate endpoints, nil when disabled. Created once so
+	// cached responses survive route rebuilds.
+	cache *middleware.ResponseCache
+
+	// Webhook deliveries of provider events, nil when disabled
+	webhooks *webhooks.Dispatcher
+
+	// Closed on shutdown to end event streams, which never finish on
+	// their own
+	streamsDone chan struct{}
+	stopStreams sync.Once
 }
 
 // Config represents server configuration
@@ -36,6 +71,14 @@ type Config struct {
 	EnableCORS  bool
 	EnableDebug bool
 	RateLimit   RateLimitConfig
+	SLO         SLOConfig
+	Auth        AuthConfig
+	Cache       CacheConfig
+	Webhooks    WebhookConfig
+
+	// TLS for the listener. With TLS and either client certificates or
+	// token authentication required, Host may be a non-loopback address.
+	TLS tlsconfig.Config
 }
 
 // RateLimitConfig represents rate limiting configuration
@@ -46,14 +89,99 @@ type RateLimitConfig struct {
 	BucketSize int           // max burst size
 	ByIP       bool          // rate limit by IP
 	ByAPIKey   bool          // rate limit by API key
+	ByIdentity bool          // rate limit by authenticated token, unauthenticated requests by IP
+
+	// TrustedProxies are the IPs or CIDRs of the proxies whose X-Real-IP
+	// and X-Forwarded-For headers name the client when limiting by IP.
+	// Without them clients are told apart by their connection address.
+	TrustedProxies []string
+
+	// Write limits requests other than GET, HEAD and OPTIONS with separate
+	// buckets when its Rate is set; Rate, Interval and BucketSize then only
+	// apply to reads. Its Interval defaults to the read interval.
+	Write middleware.RateTier
+}
+
+// CacheConfig represents response caching of the endpoints aggregating
+// collector state (/v1/health, /v1/summary and the metrics endpoints).
+// Cached responses are dropped on config changes, reloads and collector
+// lifecycle events.
+type CacheConfig struct {
+	Enabled bool
+	TTL     time.Duration // defaults to 2s
+}
+
+// WebhookConfig represents webhook subscriptions to the events of the event
+// provider, managed on /v1/webhooks
+type WebhookConfig struct {
+	Enabled bool
+	webhooks.Config
+}
+
+// SLOConfig represents per-route latency SLO tracking configuration
+type SLOConfig struct {
+	Enabled    bool
+	Objectives []middleware.SLOObjective // defaults to middleware.DefaultSLOObjectives
+	Alerts     []middleware.BurnRateAlert // defaults to middleware.DefaultBurnRateAlerts
+}
+
+// AuthConfig represents token authentication configuration. Without an
+// admin token the API is unauthenticated and delegated tokens are disabled.
+type AuthConfig struct {
+	AdminToken      string
+	DefaultTokenTTL time.Duration // lifetime of delegated tokens, defaults to 4h
+	MaxTokenTTL     time.Duration // longest lifetime that may be requested, defaults to 24h
 }
 
 // NewServer creates a new API server
 func NewServer(config Config, logger *zap.Logger) *Server {
 	s := &Server{
-		config: config,
-		logger: logger,
-		router: mux.NewRouter(),
+		config:      config,
+		logger:      logger,
+		router:      mux.NewRouter(),
+		streamsDone: make(chan struct{}),
+	}
+
+	// SLO tracking if enabled
+	if config.SLO.Enabled {
+		objectives := config.SLO.Objectives
+		if len(objectives) == 0 {
+			objectives = middleware.DefaultSLOObjectives()
+		}
+		alerts := config.SLO.Alerts
+		if len(alerts) == 0 {
+			alerts = middleware.DefaultBurnRateAlerts()
+		}
+		s.sloTracker = middleware.NewSLOTracker(objectives, alerts, logger.Named("slo"))
+	}
+
+	// Token authentication if an admin token is configured
+	if config.Auth.AdminToken != "" {
+		s.tokenAuth = middleware.NewTokenAuthenticator(
+			config.Auth.AdminToken,
+			config.Auth.DefaultTokenTTL,
+			config.Auth.MaxTokenTTL,
+			logger.Named("audit"),
+		)
+	}
+
+	// Rate limiting if enabled
+	if config.RateLimit.Enabled {
+		s.rateLimit = s.newRateLimit(config.RateLimit)
+	}
+
+	// Response caching if enabled
+	if config.Cache.Enabled {
+		ttl := config.Cache.TTL
+		if ttl <= 0 {
+			ttl = 2 * time.Second
+		}
+		s.cache = middleware.NewResponseCache(ttl, logger.Named("cache"))
+	}
+
+	// Webhooks if enabled
+	if config.Webhooks.Enabled {
+		s.webhooks = webhooks.NewDispatcher(config.Webhooks.Config, logger.Named("webhooks"))
 	}
 
 	// Setup routes
@@ -61,12 +189,15 @@ func NewServer(config Config, logger *zap.Logger) *Server {
 
 	// Create HTTP server
 	s.httpServer = &http.Server{
-		Addr:         fmt.Sprintf("%s:%d", config.Host, config.Port),
+		Addr:         listenAddr(config.Host, config.Port),
 		Handler:      s.buildHandler(),
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	s.httpServer.RegisterOnShutdown(func() {
+		s.stopStreams.Do(func() { close(s.streamsDone) })
+	})
 
 	return s
 }
@@ -82,32 +213,146 @@ func (s *Server) SetProviders(
 	s.healthProvider = health
 	s.configProvider = config
 	s.metricsProvider = metrics
+	s.rebuildRoutes()
+}
+
+// SetEventProvider sets the source of the events streamed on
+// /v1/events/stream in addition to status changes
+func (s *Server) SetEventProvider(events handlers.EventProvider) {
+	s.eventProvider = events
+	s.rebuildRoutes()
+}
+
+// SetProvenanceProvider sets the source of /v1/config/provenance, which
+// reports provenance as not available without one
+func (s *Server) SetProvenanceProvider(provenance handlers.ProvenanceProvider) {
+	s.provenanceProvider = provenance
+	s.rebuildRoutes()
+}
+
+// SetActionsProvider sets the source of /v1/autoconfig/actions, which
+// reports actions as not available without one
+func (s *Server) SetActionsProvider(actions handlers.ActionsProvider) {
+	s.actionsProvider = actions
+	s.rebuildRoutes()
+}
+
+// SetSuggestionsProvider sets the source of /v1/autoconfig/suggestions,
+// which reports suggestions as not available without one
+func (s *Server) SetSuggestionsProvider(suggestions handlers.SuggestionsProvider) {
+	s.suggestionsProvider = suggestions
+	s.rebuildRoutes()
+}
+
+// SetSummaryProvider sets the source of the host details on /v1/summary,
+// which only reports status and health without one
+func (s *Server) SetSummaryProvider(summary handlers.SummaryProvider) {
+	s.summaryProvider = summary
+	s.rebuildRoutes()
+}
+
+// rebuildRoutes rebuilds the routes after a provider changed, as handlers
+// capture their providers
+func (s *Server) rebuildRoutes() {
+	s.router = mux.NewRouter()
+	s.setupRoutes()
+	s.httpServer.Handler = s.buildHandler()
+}
+
+// SLOTracker returns the API SLO tracker, or nil if SLO tracking is disabled
+func (s *Server) SLOTracker() *middleware.SLOTracker {
+	return s.sloTracker
+}
+
+// TokenAuthenticator returns the token authenticator, or nil if
+// authentication is disabled
+func (s *Server) TokenAuthenticator() *middleware.TokenAuthenticator {
+	return s.tokenAuth
+}
+
+// Webhooks returns the webhook dispatcher, or nil if webhooks are disabled
+func (s *Server) Webhooks() *webhooks.Dispatcher {
+	return s.webhooks
 }
 
 // setupRoutes configures all API routes
 func (s *Server) setupRoutes() {
+	s.router.NotFoundHandler = problem.Handler(http.StatusNotFound, "No such API endpoint")
+	s.router.MethodNotAllowedHandler = problem.Handler(http.StatusMethodNotAllowed, "Method not allowed")
+
 	// API v1 routes
 	v1 := s.router.PathPrefix("/v1").Subrouter()
 
+	// Record route latency against SLOs; installed on the router so the
+	// matched route template is known
+	if s.sloTracker != nil {
+		s.router.Use(s.sloTracker.Middleware())
+		v1.Handle("/slo", handlers.NewSLOHandler(s.logger, s.sloTracker)).Methods("GET")
+	}
+
+	// Require the admin token or a delegated token on every v1 route; only
+	// the admin manages delegated tokens and reads the audit log
+	if s.tokenAuth != nil {
+		v1.Use(s.tokenAuth.Middleware())
+
+		adminOnly := s.tokenAuth.RequireAdmin()
+		tokenHandler := adminOnly(handlers.NewTokenHandler(s.logger, s.tokenAuth))
+		v1.Handle("/tokens", tokenHandler).Methods("GET", "POST")
+		v1.Handle("/tokens/{id}", tokenHandler).Methods("DELETE")
+		v1.Handle("/audit", adminOnly(handlers.NewAuditHandler(s.logger, s.tokenAuth))).Methods("GET")
+	}
+
+	// Webhooks receive events like the event stream; managing them is
+	// admin only, as they send events off the host
+	if s.webhooks != nil {
+		var webhookHandler http.Handler = handlers.NewWebhookHandler(s.logger, s.webhooks)
+		if s.tokenAuth != nil {
+			webhookHandler = s.tokenAuth.RequireAdmin()(webhookHandler)
+		}
+		v1.Handle("/webhooks", webhookHandler).Methods("GET", "POST")
+		v1.Handle("/webhooks/{id}", webhookHandler).Methods("GET", "DELETE")
+	}
+
 	// Status endpoint
 	statusHandler := handlers.NewStatusHandler(s.logger, s.config.Version, s.statusProvider)
 	v1.Handle("/status", statusHandler).Methods("GET")
 
+	// Event stream, sharing the status handler so status versions match
+	eventStreamHandler := handlers.NewEventStreamHandler(s.logger, statusHandler, s.eventProvider, s.streamsDone)
+	v1.Handle("/events/stream", eventStreamHandler).Methods("GET")
+
 	// Health endpoint
 	healthHandler := handlers.NewHealthHandler(s.logger, s.healthProvider)
-	v1.Handle("/health", healthHandler).Methods("GET")
+	v1.Handle("/health", s.cached(healthHandler)).Methods("GET")
+
+	// Host summary for fleet dashboards, sharing the status handler so
+	// status versions match
+	summaryHandler := handlers.NewSummaryHandler(s.logger, s.config.Version, statusHandler, healthHandler, s.summaryProvider)
+	v1.Handle("/summary", s.cached(summaryHandler)).Methods("GET")
 
 	// Config endpoints
 	configHandler := handlers.NewConfigHandler(s.logger, s.configProvider, s.config.ReadOnly)
-	v1.Handle("/config", configHandler).Methods("GET", "POST")
+	v1.Handle("/config", s.invalidatesCache(configHandler)).Methods("GET", "POST")
+
+	// Where each part of the active config came from
+	provenanceHandler := handlers.NewProvenanceHandler(s.logger, s.provenanceProvider)
+	v1.Handle("/config/provenance", provenanceHandler).Methods("GET")
+
+	// Receivers auto-configuration left out until credentials are fixed
+	actionsHandler := handlers.NewActionsHandler(s.logger, s.actionsProvider)
+	v1.Handle("/autoconfig/actions", actionsHandler).Methods("GET")
+
+	// Receivers of services found with too little confidence to enable
+	suggestionsHandler := handlers.NewSuggestionsHandler(s.logger, s.suggestionsProvider)
+	v1.Handle("/autoconfig/suggestions", suggestionsHandler).Methods("GET")
 
 	// Reload endpoint
 	reloadHandler := handlers.NewReloadHandler(s.logger, s.configProvider, s.config.ReadOnly)
-	v1.Handle("/reload", reloadHandler).Methods("POST")
+	v1.Handle("/reload", s.invalidatesCache(reloadHandler)).Methods("POST")
 
 	// Metrics endpoint
-	metricsHandler := handlers.NewMetricsHandler(s.logger, s.config.Version, s.metricsProvider)
-	v1.Handle("/metrics", metricsHandler).Methods("GET")
+	metricsHandler := handlers.NewMetricsHandler(s.logger, s.config.Version, s.customMetrics())
+	v1.Handle("/metrics", s.cached(metricsHandler)).Methods("GET")
 
 	// Root health check (for simple monitoring)
 	s.router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -116,8 +361,102 @@ func (s *Server) setupRoutes() {
 	}).Methods("GET")
 
 	// Prometheus metrics endpoint at root (for standard Prometheus scraping)
-	rootMetricsHandler := handlers.NewMetricsHandler(s.logger, s.config.Version, s.metricsProvider)
-	s.router.Handle("/metrics", rootMetricsHandler).Methods("GET")
+	rootMetricsHandler := handlers.NewMetricsHandler(s.logger, s.config.Version, s.customMetrics())
+	s.router.Handle("/metrics", s.cached(rootMetricsHandler)).Methods("GET")
+}
+
+// cached serves h through the response cache when caching is enabled
+func (s *Server) cached(h http.Handler) http.Handler {
+	if s.cache == nil {
+		return h
+	}
+	return s.cache.Middleware()(h)
+}
+
+// invalidatesCache drops cached responses after h changes the config
+func (s *Server) invalidatesCache(h http.Handler) http.Handler {
+	if s.cache == nil {
+		return h
+	}
+	return s.cache.InvalidateMiddleware()(h)
+}
+
+// cacheInvalidatingEvents are the event type prefixes after which cached
+// responses no longer reflect the collector: config changes, component
+// starts, stops, reloads and crashes, and health changes
+var cacheInvalidatingEvents = []string{"config.", "component.", "collector.", "health."}
+
+// invalidateCacheOnEvents drops cached responses whenever the event
+// provider reports a change to the collector, until ctx is done or the
+// server shuts down
+func (s *Server) invalidateCacheOnEvents(ctx context.Context) {
+	events, unsubscribe := s.eventProvider.SubscribeEvents()
+	defer unsubscribe()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			for _, prefix := range cacheInvalidatingEvents {
+				if strings.HasPrefix(event.Type, prefix) {
+					s.cache.Invalidate()
+					break
+				}
+			}
+		case <-ctx.Done():
+			return
+		case <-s.streamsDone:
+			return
+		}
+	}
+}
+
+// dispatchWebhooks hands every event of the event provider to the webhook
+// dispatcher until ctx is done or the server shuts down
+func (s *Server) dispatchWebhooks(ctx context.Context) {
+	events, unsubscribe := s.eventProvider.SubscribeEvents()
+	defer unsubscribe()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			s.webhooks.Dispatch(event)
+		case <-ctx.Done():
+			return
+		case <-s.streamsDone:
+			return
+		}
+	}
+}
+
+// customMetrics returns the provider for custom metrics, including SLO
+// metrics when tracking is enabled
+func (s *Server) customMetrics() handlers.MetricsProvider {
+	if s.sloTracker == nil {
+		return s.metricsProvider
+	}
+	providers := []handlers.MetricsProvider{s.sloTracker}
+	if s.metricsProvider != nil {
+		providers = append([]handlers.MetricsProvider{s.metricsProvider}, providers...)
+	}
+	return multiMetricsProvider(providers)
+}
+
+// multiMetricsProvider concatenates the metrics of several providers
+type multiMetricsProvider []handlers.MetricsProvider
+
+// GetCustomMetrics implements handlers.MetricsProvider
+func (m multiMetricsProvider) GetCustomMetrics() []handlers.Metric {
+	var metrics []handlers.Metric
+	for _, provider := range m {
+		metrics = append(metrics, provider.GetCustomMetrics()...)
+	}
+	return metrics
 }
 
 // buildHandler builds the HTTP handler with middleware
@@ -130,29 +469,15 @@ func (s *Server) buildHandler() http.Handler {
 	// Request ID
 	handler = middleware.RequestIDMiddleware()(handler)
 
+	// Trace context, continuing the caller's trace into the supervisor
+	handler = tracecontext.Middleware(handler)
+
 	// Recovery (catch panics)
 	handler = middleware.RecoveryMiddleware(s.logger)(handler)
 
 	// Rate limiting if enabled
-	if s.config.RateLimit.Enabled {
-		rateLimiter := middleware.NewRateLimiter(
-			s.config.RateLimit.Rate,
-			s.config.RateLimit.Interval,
-			s.config.RateLimit.BucketSize,
-			s.logger.Named("ratelimit"),
-		)
-		
-		// Choose key extraction function
-		var keyFunc func(*http.Request) string
-		if s.config.RateLimit.ByAPIKey {
-			keyFunc = middleware.APIKeyFunc
-		} else if s.config.RateLimit.ByIP {
-			keyFunc = middleware.IPKeyFunc
-		} else {
-			keyFunc = middleware.EndpointKeyFunc
-		}
-		
-		handler = rateLimiter.RateLimitMiddleware(keyFunc)(handler)
+	if s.rateLimit != nil {
+		handler = s.rateLimit(handler)
 	}
 
 	// CORS if enabled
@@ -160,8 +485,10 @@ func (s *Server) buildHandler() http.Handler {
 		handler = middleware.CORSMiddleware()(handler)
 	}
 
-	// Localhost only restriction
-	handler = middleware.LocalhostOnlyMiddleware(s.logger)(handler)
+	// Localhost only restriction, unless remote clients are authenticated
+	if !s.remoteAllowed() {
+		handler = middleware.LocalhostOnlyMiddleware(s.logger)(handler)
+	}
 
 	// Logging (outermost)
 	handler = middleware.LoggingMiddleware(s.logger)(handler)
@@ -169,6 +496,40 @@ func (s *Server) buildHandler() http.Handler {
 	return handler
 }
 
+// newRateLimit returns the rate limiting middleware for config
+func (s *Server) newRateLimit(config RateLimitConfig) func(http.Handler) http.Handler {
+	logger := s.logger.Named("ratelimit")
+
+	// Choose key extraction function. Invalid trusted proxies fail Start,
+	// until then no proxy is trusted.
+	trustedProxies, _ := middleware.ParseTrustedProxies(config.TrustedProxies)
+	var keyFunc func(*http.Request) string
+	if config.ByIdentity && s.tokenAuth != nil {
+		keyFunc = middleware.IdentityKeyFunc(s.tokenAuth.Identify, trustedProxies)
+	} else if config.ByAPIKey {
+		keyFunc = middleware.APIKeyFunc
+	} else if config.ByIP || config.ByIdentity {
+		keyFunc = middleware.ClientIPKeyFunc(trustedProxies)
+	} else {
+		keyFunc = middleware.EndpointKeyFunc
+	}
+
+	if config.Write.Rate <= 0 {
+		rateLimiter := middleware.NewRateLimiter(config.Rate, config.Interval, config.BucketSize, logger)
+		return rateLimiter.RateLimitMiddleware(keyFunc)
+	}
+
+	write := config.Write
+	if write.Interval <= 0 {
+		write.Interval = config.Interval
+	}
+	rateLimiter := middleware.NewTieredRateLimiter(map[string]middleware.RateTier{
+		middleware.TierRead:  {Rate: config.Rate, Interval: config.Interval, BucketSize: config.BucketSize},
+		middleware.TierWrite: write,
+	}, middleware.MethodTierFunc, logger)
+	return rateLimiter.RateLimitMiddleware(keyFunc)
+}
+
 // Start starts the API server
 func (s *Server) Start(ctx context.Context) error {
 	// Verify providers are set
@@ -177,22 +538,45 @@ func (s *Server) Start(ctx context.Context) error {
 		return fmt.Errorf("providers not set")
 	}
 
-	// Verify localhost only binding
-	ip := net.ParseIP(s.config.Host)
-	if ip == nil || !ip.IsLoopback() {
-		return fmt.Errorf("API server must bind to localhost only, got: %s", s.config.Host)
+	// Verify localhost only binding unless remote clients are authenticated
+	if !s.remoteAllowed() && !isLoopbackHost(s.config.Host) {
+		return fmt.Errorf("API server must bind to localhost only without TLS and client certificates or token authentication, got: %s", s.config.Host)
+	}
+
+	if s.config.RateLimit.Enabled {
+		if _, err := middleware.ParseTrustedProxies(s.config.RateLimit.TrustedProxies); err != nil {
+			return fmt.Errorf("invalid rate limit configuration: %w", err)
+		}
+	}
+
+	if s.config.TLS.Enabled() {
+		tlsConfig, err := s.config.TLS.ServerConfig()
+		if err != nil {
+			return fmt.Errorf("invalid TLS configuration: %w", err)
+		}
+		s.httpServer.TLSConfig = tlsConfig
 	}
 
 	s.logger.Info("Starting API server",
 		zap.String("address", s.httpServer.Addr),
 		zap.Bool("read_only", s.config.ReadOnly),
+		zap.Bool("auth", s.tokenAuth != nil),
+		zap.Bool("tls", s.config.TLS.Enabled()),
+		zap.Bool("client_certs", s.config.TLS.MutualTLS()),
+		zap.String("certificate_sha256", tlsconfig.Fingerprint(s.httpServer.TLSConfig)),
 		zap.String("version", s.config.Version),
 	)
 
 	// Start server in goroutine
 	errCh := make(chan error, 1)
 	go func() {
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.httpServer.TLSConfig != nil {
+			err = s.httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = s.httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			errCh <- err
 		}
 	}()
@@ -209,16 +593,58 @@ func (s *Server) Start(ctx context.Context) error {
 		case err := <-errCh:
 			return fmt.Errorf("server failed to start: %w", err)
 		default:
+			// Drop cached responses as the collector changes
+			if s.cache != nil && s.eventProvider != nil {
+				go s.invalidateCacheOnEvents(ctx)
+			}
+			// Deliver events to webhooks
+			if s.webhooks != nil && s.eventProvider != nil {
+				go s.dispatchWebhooks(ctx)
+			}
+			// Revoke delegated tokens as they expire
+			if s.tokenAuth != nil {
+				sweepCtx, cancel := context.WithCancel(ctx)
+				s.stopSweep = cancel
+				go s.tokenAuth.Run(sweepCtx)
+			}
 			s.logger.Info("API server started successfully")
 			return nil
 		}
 	}
 }
 
+// remoteAllowed reports whether clients beyond localhost may connect: only
+// over TLS, and only when they must present a client certificate or a token
+func (s *Server) remoteAllowed() bool {
+	return s.config.TLS.Enabled() && (s.config.TLS.MutualTLS() || s.config.Auth.AdminToken != "")
+}
+
+// listenAddr returns the address the server listens on. IPv6 literals may
+// be given with or without brackets, e.g. ::1 or [::1]; an empty host or ::
+// listens on every interface, IPv4 and IPv6.
+func listenAddr(host string, port int) string {
+	return net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(port))
+}
+
+// isLoopbackHost reports whether a listen host only accepts local
+// connections
+func isLoopbackHost(host string) bool {
+	host = strings.Trim(host, "[]")
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.logger.Info("Shutting down API server")
 
+	if s.stopSweep != nil {
+		s.stopSweep()
+	}
+
 	// Set a timeout if context doesn't have one
 	shutdownCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
@@ -227,6 +653,11 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		return fmt.Errorf("failed to shutdown server: %w", err)
 	}
 
+	// Deliveries in flight are abandoned
+	if s.webhooks != nil {
+		s.webhooks.Close()
+	}
+
 	s.logger.Info("API server shut down successfully")
 	return nil
 }
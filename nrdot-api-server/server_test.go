@@ -1,16 +1,28 @@
 package apiserver
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/handlers"
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/middleware"
 	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/webhooks"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/tlsconfig"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -82,6 +94,131 @@ func TestStatusEndpoint(t *testing.T) {
 	assert.Len(t, status.Collectors, 2)
 }
 
+func TestStatusLongPoll(t *testing.T) {
+	provider := &changingStatusProvider{hash: "hash-1"}
+	handler := handlers.NewStatusHandler(zap.NewNop(), "v1.0.0", provider)
+
+	get := func(target string) (*httptest.ResponseRecorder, models.StatusResponse) {
+		req := httptest.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		var status models.StatusResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&status))
+		}
+		return w, status
+	}
+
+	// The version is stable while the status is unchanged
+	w, first := get("/v1/status")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, uint64(1), first.StatusVersion)
+	assert.Equal(t, "1", w.Header().Get("X-Status-Version"))
+	_, again := get("/v1/status")
+	assert.Equal(t, first.StatusVersion, again.StatusVersion)
+
+	// A stale if_version returns immediately
+	start := time.Now()
+	_, status := get("/v1/status?wait=30s&if_version=0")
+	assert.Equal(t, uint64(1), status.StatusVersion)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// Without a change the request returns the same version once the wait elapses
+	start = time.Now()
+	_, status = get("/v1/status?wait=300ms&if_version=1")
+	assert.Equal(t, uint64(1), status.StatusVersion)
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+
+	// A change ends the wait early
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		provider.setHash("hash-2")
+	}()
+	start = time.Now()
+	_, status = get("/v1/status?wait=30s&if_version=1")
+	assert.Equal(t, uint64(2), status.StatusVersion)
+	assert.Equal(t, "hash-2", status.ConfigHash)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// A client going away ends the wait
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("GET", "/v1/status?wait=30s&if_version=2", nil).WithContext(ctx)
+	start = time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// Malformed parameters are rejected
+	for _, target := range []string{
+		"/v1/status?wait=soon",
+		"/v1/status?wait=-1s",
+		"/v1/status?wait=1s&if_version=latest",
+	} {
+		w, _ := get(target)
+		assert.Equal(t, http.StatusBadRequest, w.Code, target)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	server := NewServer(Config{Host: "127.0.0.1", Version: "test"}, zap.NewNop())
+	status := &changingStatusProvider{hash: "hash-1"}
+	events := &mockEventProvider{ch: make(chan models.Event, 10)}
+	server.SetProviders(status, &mockHealthProvider{healthy: true}, &mockConfigProvider{}, &mockMetricsProvider{})
+	server.SetEventProvider(events)
+
+	ts := httptest.NewServer(server.httpServer.Handler)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/events/stream?type=status.,collector.")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	reader := bufio.NewReader(resp.Body)
+
+	// The stream opens with the current status
+	event := readStreamEvent(t, reader)
+	assert.Equal(t, models.EventTypeStatusChanged, event.Type)
+	assert.Equal(t, models.EventSeverityInfo, event.Severity)
+	assert.Equal(t, "hash-1", event.Data.(map[string]interface{})["config_hash"])
+
+	// Provider events are forwarded if they match the filter
+	events.ch <- models.Event{Type: "config.applied", Severity: models.EventSeverityInfo}
+	events.ch <- models.Event{Type: "collector.restarted", Severity: models.EventSeverityWarning}
+	event = readStreamEvent(t, reader)
+	assert.Equal(t, "collector.restarted", event.Type)
+
+	// Status changes are streamed with their new version
+	status.setHash("hash-2")
+	event = readStreamEvent(t, reader)
+	assert.Equal(t, models.EventTypeStatusChanged, event.Type)
+	assert.Equal(t, float64(2), event.Data.(map[string]interface{})["status_version"])
+
+	// Shutting down ends the stream
+	require.NoError(t, server.httpServer.Shutdown(context.Background()))
+	_, err = reader.ReadString('\n')
+	assert.Error(t, err)
+	assert.True(t, events.cancelled())
+}
+
+// readStreamEvent reads the next Server-Sent Event, skipping comments
+func readStreamEvent(t *testing.T, reader *bufio.Reader) models.Event {
+	t.Helper()
+	var event models.Event
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSpace(line)
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			require.NoError(t, json.Unmarshal([]byte(data), &event))
+		}
+		if line == "" && event.Type != "" {
+			return event
+		}
+	}
+}
+
 func TestHealthEndpoint(t *testing.T) {
 	logger := zap.NewNop()
 	
@@ -136,6 +273,614 @@ func TestMetricsEndpoint(t *testing.T) {
 	assert.Contains(t, body, "nrdot_test_metric")
 }
 
+func TestSLOEndpoint(t *testing.T) {
+	logger := zap.NewNop()
+	config := Config{
+		Host:    "127.0.0.1",
+		Port:    0,
+		Version: "test",
+		SLO:     SLOConfig{Enabled: true},
+	}
+
+	server := NewServer(config, logger)
+	require.NotNil(t, server.SLOTracker())
+	server.SetProviders(
+		&mockStatusProvider{},
+		&mockHealthProvider{healthy: true},
+		&mockConfigProvider{},
+		&mockMetricsProvider{},
+	)
+
+	// Routed requests are recorded against their objective
+	req := httptest.NewRequest("GET", "/v1/status", nil)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Long-poll requests block by design and are not recorded
+	req = httptest.NewRequest("GET", "/v1/status?wait=10ms&if_version=0", nil)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("GET", "/v1/slo", nil)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response models.SLOResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	require.NotEmpty(t, response.Objectives)
+	for _, objective := range response.Objectives {
+		if objective.Route == "/v1/status" {
+			assert.Equal(t, int64(1), objective.Requests)
+			assert.Equal(t, int64(0), objective.BadRequests)
+		}
+	}
+
+	// SLO metrics are exported alongside the provider's metrics
+	req = httptest.NewRequest("GET", "/metrics", nil)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	body := w.Body.String()
+	assert.Contains(t, body, "nrdot_test_metric")
+	assert.Contains(t, body, "nrdot_api_slo_requests_total")
+}
+
+func TestDelegatedTokens(t *testing.T) {
+	logger := zap.NewNop()
+	config := Config{
+		Host:    "127.0.0.1",
+		Port:    0,
+		Version: "test",
+		Auth:    AuthConfig{AdminToken: "admin-secret"},
+	}
+
+	server := NewServer(config, logger)
+	require.NotNil(t, server.TokenAuthenticator())
+	server.SetProviders(
+		&mockStatusProvider{},
+		&mockHealthProvider{healthy: true},
+		&mockConfigProvider{},
+		&mockMetricsProvider{},
+	)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// v1 routes need a token, the root endpoints do not
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "/v1/status", "", "").Code)
+	assert.Equal(t, http.StatusOK, do("GET", "/metrics", "", "").Code)
+
+	// The admin mints a read-only token for support
+	w := do("POST", "/v1/tokens", "admin-secret", `{"issued_to":"support@example.com","reason":"INC-42","ttl":"2h"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var minted models.DelegatedTokenResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&minted))
+	assert.Equal(t, models.TokenScopeReadOnly, minted.Scope)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/v1/tokens", "admin-secret", `{"ttl":"2h"}`).Code)
+
+	// Support can read but not change the config or mint tokens
+	assert.Equal(t, http.StatusOK, do("GET", "/v1/config", minted.Token, "").Code)
+	assert.Equal(t, http.StatusForbidden, do("POST", "/v1/reload", minted.Token, "{}").Code)
+	assert.Equal(t, http.StatusForbidden, do("GET", "/v1/tokens", minted.Token, "").Code)
+
+	w = do("GET", "/v1/tokens", "admin-secret", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list models.DelegatedTokenList
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	require.Len(t, list.Tokens, 1)
+	assert.Equal(t, minted.ID, list.Tokens[0].ID)
+	assert.NotContains(t, w.Body.String(), minted.Token)
+
+	// Revocation takes effect immediately
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/v1/tokens/"+minted.ID, "admin-secret", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", "/v1/tokens/"+minted.ID, "admin-secret", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "/v1/status", minted.Token, "").Code)
+
+	w = do("GET", "/v1/audit", "admin-secret", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var audit models.AuditResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&audit))
+	var actions []string
+	for _, entry := range audit.Entries {
+		actions = append(actions, entry.Action)
+	}
+	assert.Equal(t, []string{
+		models.AuditTokenMinted,
+		models.AuditAccess,
+		models.AuditDenied,
+		models.AuditDenied,
+		models.AuditTokenRevoked,
+	}, actions)
+}
+
+func TestErrorResponses(t *testing.T) {
+	server := NewServer(Config{
+		Host:    "127.0.0.1",
+		Version: "test",
+		Auth:    AuthConfig{AdminToken: "admin-secret"},
+	}, zap.NewNop())
+	server.SetProviders(
+		&mockStatusProvider{},
+		&mockHealthProvider{healthy: true},
+		&mockConfigProvider{},
+		&mockMetricsProvider{},
+	)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "middleware", method: "GET", path: "/v1/status", status: http.StatusUnauthorized, code: "UNAUTHORIZED"},
+		{name: "handler", method: "POST", path: "/v1/tokens", token: "admin-secret", body: "{", status: http.StatusBadRequest, code: "BAD_REQUEST"},
+		{name: "not found", method: "DELETE", path: "/v1/tokens/missing", token: "admin-secret", status: http.StatusNotFound, code: "NOT_FOUND"},
+		{name: "unknown path", method: "GET", path: "/v2/status", status: http.StatusNotFound, code: "NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			details, ok := problem.Parse(w.Header(), w.Body.Bytes())
+			require.True(t, ok, "expected problem details, got %q", w.Body.String())
+			assert.Equal(t, tt.status, details.Status)
+			assert.Equal(t, tt.code, details.Code)
+			assert.Equal(t, http.StatusText(tt.status), details.Title)
+			assert.Equal(t, tt.path, details.Instance)
+			assert.NotEmpty(t, details.Detail)
+		})
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	server := NewServer(Config{
+		Host:     "127.0.0.1",
+		Version:  "test",
+		Auth:     AuthConfig{AdminToken: "admin-secret"},
+		Webhooks: WebhookConfig{Enabled: true},
+	}, zap.NewNop())
+	defer server.Webhooks().Close()
+	events := &mockEventProvider{ch: make(chan models.Event, 10)}
+	server.SetProviders(&mockStatusProvider{}, &mockHealthProvider{healthy: true}, &mockConfigProvider{}, &mockMetricsProvider{})
+	server.SetEventProvider(events)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.dispatchWebhooks(ctx)
+
+	received := make(chan *http.Request, 10)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer target.Close()
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/v1/webhooks", "admin-secret", `{"url":"`+target.URL+`","event_types":["config.rejected"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var registered models.WebhookResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&registered))
+	assert.NotEmpty(t, registered.Secret)
+
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/v1/webhooks", "admin-secret", `{"url":"http://example.com"}`).Code)
+
+	// Delegated tokens cannot manage webhooks
+	w = do("POST", "/v1/tokens", "admin-secret", `{"issued_to":"support@example.com","scope":"read-write"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var minted models.DelegatedTokenResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&minted))
+	assert.Equal(t, http.StatusForbidden, do("GET", "/v1/webhooks", minted.Token, "").Code)
+
+	// Matching provider events are delivered
+	events.ch <- models.Event{Type: "collector.restarted"}
+	events.ch <- models.Event{Type: "config.rejected", Severity: models.EventSeverityError}
+	select {
+	case r := <-received:
+		assert.Equal(t, "config.rejected", r.Header.Get(webhooks.EventHeader))
+	case <-time.After(time.Second):
+		t.Fatal("webhook not delivered")
+	}
+
+	w = do("GET", "/v1/webhooks", "admin-secret", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), registered.Secret)
+	var list models.WebhookList
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	require.Len(t, list.Webhooks, 1)
+	assert.Equal(t, registered.ID, list.Webhooks[0].ID)
+
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/v1/webhooks/"+registered.ID, "admin-secret", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/v1/webhooks/"+registered.ID, "admin-secret", "").Code)
+}
+
+func TestRateLimitByIdentity(t *testing.T) {
+	config := Config{
+		Host:    "127.0.0.1",
+		Port:    0,
+		Version: "test",
+		Auth:    AuthConfig{AdminToken: "admin-secret"},
+		RateLimit: RateLimitConfig{
+			Enabled:    true,
+			Rate:       2,
+			Interval:   time.Minute,
+			ByIdentity: true,
+			Write:      middleware.RateTier{Rate: 1},
+		},
+	}
+
+	server := NewServer(config, zap.NewNop())
+	server.SetProviders(
+		&mockStatusProvider{},
+		&mockHealthProvider{healthy: true},
+		&mockConfigProvider{},
+		&mockMetricsProvider{},
+	)
+	handler := server.httpServer.Handler
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = "127.0.0.1:12345"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// The admin's single write mints a token; a second write is throttled
+	w := do("POST", "/v1/tokens", "admin-secret", `{"issued_to":"support@example.com","ttl":"1h"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var minted models.DelegatedTokenResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&minted))
+	w = do("POST", "/v1/tokens", "admin-secret", `{"issued_to":"other@example.com"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// Reads have their own budget
+	assert.Equal(t, http.StatusOK, do("GET", "/v1/status", "admin-secret", "").Code)
+	w = do("GET", "/v1/status", "admin-secret", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, http.StatusTooManyRequests, do("GET", "/v1/status", "admin-secret", "").Code)
+
+	// The delegated token and unauthenticated clients are limited separately
+	assert.Equal(t, http.StatusOK, do("GET", "/v1/status", minted.Token, "").Code)
+	assert.Equal(t, http.StatusOK, do("GET", "/metrics", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "/v1/status", "guessed", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, do("GET", "/v1/status", "another-guess", "").Code)
+}
+
+func TestServerTLS(t *testing.T) {
+	// Reserve a port, the server reports its configured address only
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	config := Config{
+		Host:    "127.0.0.1",
+		Port:    port,
+		Version: "test",
+		TLS:     tlsconfig.Config{SelfSigned: true},
+	}
+	server := NewServer(config, zap.NewNop())
+	server.SetProviders(
+		&mockStatusProvider{},
+		&mockHealthProvider{healthy: true},
+		&mockConfigProvider{},
+		&mockMetricsProvider{},
+	)
+	require.NoError(t, server.Start(context.Background()))
+	defer server.Shutdown(context.Background())
+
+	// The certificate is served and trusted once pinned
+	roots := x509.NewCertPool()
+	leaf, err := x509.ParseCertificate(server.httpServer.TLSConfig.Certificates[0].Certificate[0])
+	require.NoError(t, err)
+	roots.AddCert(leaf)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	resp, err := client.Get("https://localhost:" + strconv.Itoa(port) + "/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Plain HTTP is refused
+	resp, err = http.Get("http://127.0.0.1:" + strconv.Itoa(port) + "/")
+	if err == nil {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestRemoteBinding(t *testing.T) {
+	providers := func(s *Server) *Server {
+		s.SetProviders(&mockStatusProvider{}, &mockHealthProvider{}, &mockConfigProvider{}, &mockMetricsProvider{})
+		return s
+	}
+
+	// Binding beyond localhost needs TLS and authenticated clients
+	for _, config := range []Config{
+		{Host: "0.0.0.0"},
+		{Host: "0.0.0.0", Auth: AuthConfig{AdminToken: "admin-secret"}},
+		{Host: "0.0.0.0", TLS: tlsconfig.Config{SelfSigned: true}},
+	} {
+		server := providers(NewServer(config, zap.NewNop()))
+		assert.False(t, server.remoteAllowed())
+		assert.Error(t, server.Start(context.Background()))
+	}
+
+	remote := func(server *Server) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.0.0.7:40000"
+		w := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusForbidden, remote(NewServer(Config{Host: "127.0.0.1"}, zap.NewNop())))
+
+	server := NewServer(Config{
+		Host: "0.0.0.0",
+		Auth: AuthConfig{AdminToken: "admin-secret"},
+		TLS:  tlsconfig.Config{SelfSigned: true},
+	}, zap.NewNop())
+	assert.True(t, server.remoteAllowed())
+	assert.Equal(t, http.StatusOK, remote(server))
+
+	// Client certificate verification needs a CA
+	server = providers(NewServer(Config{
+		Host: "127.0.0.1",
+		TLS:  tlsconfig.Config{SelfSigned: true, RequireClientCert: true},
+	}, zap.NewNop()))
+	assert.Error(t, server.Start(context.Background()))
+}
+
+func TestConfigProvenanceEndpoint(t *testing.T) {
+	server := NewServer(Config{Host: "127.0.0.1", Version: "test"}, zap.NewNop())
+	server.SetProviders(
+		&mockStatusProvider{},
+		&mockHealthProvider{healthy: true},
+		&mockConfigProvider{},
+		&mockMetricsProvider{},
+	)
+
+	get := func(path string) (*httptest.ResponseRecorder, models.ConfigProvenanceResponse) {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		var response models.ConfigProvenanceResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		}
+		return w, response
+	}
+
+	// Without a provider provenance is not available
+	w, _ := get("/v1/config/provenance")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	server.SetProvenanceProvider(&mockProvenanceProvider{})
+	w, response := get("/v1/config/provenance")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "abc123", response.ConfigHash)
+	assert.Len(t, response.Sources, 4)
+	paths := make([]string, 0, len(response.Components))
+	for _, component := range response.Components {
+		paths = append(paths, component.Path)
+	}
+	assert.Equal(t, []string{
+		"processors.batch",
+		"processors.batch.timeout",
+		"receivers.hostmetrics",
+		"receivers.mysql",
+		"receivers.mysqlx",
+	}, paths)
+
+	// Why is this receiver configured?
+	_, response = get("/v1/config/provenance?path=receivers.mysql")
+	require.Len(t, response.Components, 1)
+	assert.Equal(t, models.ConfigSourceAutoconfig, response.Components[0].Source)
+	assert.Equal(t, "mysql discovered on port 3306", response.Components[0].Reason)
+	require.Len(t, response.Sources, 1)
+	assert.Equal(t, "sha256:discovery", response.Sources[0].Hash)
+
+	// A path covers the settings below it, and sources include overridden ones
+	_, response = get("/v1/config/provenance?path=processors.batch")
+	assert.Len(t, response.Components, 2)
+	assert.Len(t, response.Sources, 3)
+
+	_, response = get("/v1/config/provenance?source=default")
+	require.Len(t, response.Components, 2)
+	assert.Equal(t, "processors.batch", response.Components[0].Path)
+
+	// Provider failures are reported
+	server.SetProvenanceProvider(&mockProvenanceProvider{err: assert.AnError})
+	w, _ = get("/v1/config/provenance")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestActionsEndpoint(t *testing.T) {
+	server := NewServer(Config{Host: "127.0.0.1", Version: "test"}, zap.NewNop())
+	server.SetProviders(
+		&mockStatusProvider{},
+		&mockHealthProvider{healthy: true},
+		&mockConfigProvider{},
+		&mockMetricsProvider{},
+	)
+
+	get := func(path string) (*httptest.ResponseRecorder, models.ActionsNeededResponse) {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		var response models.ActionsNeededResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		}
+		return w, response
+	}
+
+	// Without a provider actions are not available
+	w, _ := get("/v1/autoconfig/actions")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	server.SetActionsProvider(&mockActionsProvider{})
+	w, response := get("/v1/autoconfig/actions")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, response.Actions, 2)
+	assert.Equal(t, models.ActionMissingCredentials, response.Actions[0].Reason)
+	assert.Equal(t, []string{"MYSQL_MONITOR_USER", "MYSQL_MONITOR_PASS"}, response.Actions[0].Variables)
+
+	_, response = get("/v1/autoconfig/actions?service=redis")
+	require.Len(t, response.Actions, 1)
+	assert.Equal(t, "redis/cache", response.Actions[0].Receiver)
+
+	// Nothing to do is an empty list
+	_, response = get("/v1/autoconfig/actions?service=nginx")
+	assert.NotNil(t, response.Actions)
+	assert.Empty(t, response.Actions)
+
+	// Provider failures are reported
+	server.SetActionsProvider(&mockActionsProvider{err: assert.AnError})
+	w, _ = get("/v1/autoconfig/actions")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestSuggestionsEndpoint(t *testing.T) {
+	server := NewServer(Config{Host: "127.0.0.1", Version: "test"}, zap.NewNop())
+	server.SetProviders(
+		&mockStatusProvider{},
+		&mockHealthProvider{healthy: true},
+		&mockConfigProvider{},
+		&mockMetricsProvider{},
+	)
+
+	get := func(path string) (*httptest.ResponseRecorder, models.SuggestionsResponse) {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		var response models.SuggestionsResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		}
+		return w, response
+	}
+
+	// Without a provider suggestions are not available
+	w, _ := get("/v1/autoconfig/suggestions")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	server.SetSuggestionsProvider(&mockSuggestionsProvider{})
+	w, response := get("/v1/autoconfig/suggestions")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, response.Suggestions, 2)
+	assert.Equal(t, "LOW", response.Suggestions[0].Confidence)
+	assert.Equal(t, []string{"port"}, response.Suggestions[0].DiscoveredBy)
+
+	_, response = get("/v1/autoconfig/suggestions?service=redis")
+	require.Len(t, response.Suggestions, 1)
+	assert.Equal(t, "0.0.0.0:6379", response.Suggestions[0].Endpoint)
+
+	// Nothing suggested is an empty list
+	_, response = get("/v1/autoconfig/suggestions?service=nginx")
+	assert.NotNil(t, response.Suggestions)
+	assert.Empty(t, response.Suggestions)
+
+	// Provider failures are reported
+	server.SetSuggestionsProvider(&mockSuggestionsProvider{err: assert.AnError})
+	w, _ = get("/v1/autoconfig/suggestions")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestSummaryEndpoint(t *testing.T) {
+	server := NewServer(Config{Host: "127.0.0.1", Version: "test"}, zap.NewNop())
+	server.SetProviders(
+		&mockStatusProvider{},
+		&mockHealthProvider{healthy: true},
+		&mockConfigProvider{},
+		&mockMetricsProvider{},
+	)
+
+	get := func() (*httptest.ResponseRecorder, models.SummaryResponse) {
+		req := httptest.NewRequest("GET", "/v1/summary", nil)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		var response models.SummaryResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		}
+		return w, response
+	}
+
+	// Without a provider the summary has what status and health report
+	w, response := get()
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, models.StatusHealthy, response.Status)
+	assert.Equal(t, models.StatusHealthy, response.Health)
+	assert.Equal(t, map[string]string{"api_server": "test"}, response.Versions)
+	assert.Equal(t, "test-hash", response.Config.Hash)
+	assert.NotNil(t, response.Config.LastReload)
+	assert.Empty(t, response.AgentID)
+	assert.Nil(t, response.Cardinality)
+	assert.Equal(t, strconv.FormatUint(response.StatusVersion, 10), w.Header().Get("X-Status-Version"))
+
+	lastRun := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	server.SetSummaryProvider(&mockSummaryProvider{summary: &models.HostSummary{
+		AgentID:       "agent-1",
+		Hostname:      "web-01",
+		Versions:      map[string]string{"collector": "0.96.0", "supervisor": "1.2.0"},
+		ConfigVersion: 7,
+		Pipelines:     models.PipelineCounts{Total: 3, Running: 2, Failed: 1},
+		Discovery:     &models.DiscoverySummary{LastRun: &lastRun, Services: 4},
+		Cardinality:   &models.CardinalitySummary{ActiveSeries: 25000, GlobalLimit: 100000, DroppedMetrics: 12},
+	}})
+	w, response = get()
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "agent-1", response.AgentID)
+	assert.Equal(t, "web-01", response.Hostname)
+	assert.Equal(t, map[string]string{"api_server": "test", "collector": "0.96.0", "supervisor": "1.2.0"}, response.Versions)
+	assert.Equal(t, 7, response.Config.Version)
+	assert.Equal(t, "test-hash", response.Config.Hash)
+	assert.Equal(t, models.PipelineCounts{Total: 3, Running: 2, Failed: 1}, response.Pipelines)
+	require.NotNil(t, response.Discovery)
+	assert.Equal(t, 4, response.Discovery.Services)
+	assert.True(t, lastRun.Equal(*response.Discovery.LastRun))
+	require.NotNil(t, response.Cardinality)
+	assert.Equal(t, 25.0, response.Cardinality.Utilization)
+	assert.Equal(t, int64(12), response.Cardinality.DroppedMetrics)
+
+	// Provider failures are reported
+	server.SetSummaryProvider(&mockSummaryProvider{err: assert.AnError})
+	w, _ = get()
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
 func TestLocalHostOnlyRestriction(t *testing.T) {
 	logger := zap.NewNop()
 	config := Config{
@@ -160,6 +905,33 @@ func TestLocalHostOnlyRestriction(t *testing.T) {
 	// This is more of a structure test
 }
 
+func TestIPv6ListenAddr(t *testing.T) {
+	for host, want := range map[string]string{
+		"127.0.0.1": "127.0.0.1:8080",
+		"::1":       "[::1]:8080",
+		"[::1]":     "[::1]:8080",
+		"::":        "[::]:8080",
+		"":          ":8080",
+	} {
+		assert.Equal(t, want, listenAddr(host, 8080), host)
+	}
+
+	server := NewServer(Config{Host: "::1", Port: 8080, Version: "test"}, zap.NewNop())
+	assert.Equal(t, "[::1]:8080", server.httpServer.Addr)
+
+	for host, loopback := range map[string]bool{
+		"127.0.0.1": true,
+		"::1":       true,
+		"[::1]":     true,
+		"localhost": true,
+		"::":        false,
+		"0.0.0.0":   false,
+		"fd00::1":   false,
+	} {
+		assert.Equal(t, loopback, isLoopbackHost(host), host)
+	}
+}
+
 // Mock implementations
 
 type mockStatusProvider struct{}
@@ -184,6 +956,51 @@ func (m *mockStatusProvider) GetErrors() []models.ErrorInfo {
 	return nil
 }
 
+// changingStatusProvider serves a config hash that tests can change
+type changingStatusProvider struct {
+	mockStatusProvider
+	mu   sync.Mutex
+	hash string
+}
+
+func (m *changingStatusProvider) GetConfigHash() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hash
+}
+
+func (m *changingStatusProvider) GetLastReload() *time.Time {
+	return nil
+}
+
+func (m *changingStatusProvider) setHash(hash string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hash = hash
+}
+
+// mockEventProvider serves events sent on ch
+type mockEventProvider struct {
+	ch chan models.Event
+
+	mu       sync.Mutex
+	finished bool
+}
+
+func (m *mockEventProvider) SubscribeEvents() (<-chan models.Event, func()) {
+	return m.ch, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.finished = true
+	}
+}
+
+func (m *mockEventProvider) cancelled() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.finished
+}
+
 type mockHealthProvider struct {
 	healthy bool
 }
@@ -223,6 +1040,95 @@ func (m *mockConfigProvider) ReloadConfig(force bool) error {
 	return nil
 }
 
+type mockActionsProvider struct {
+	err error
+}
+
+func (m *mockActionsProvider) GetActionsNeeded() ([]models.ActionNeeded, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return []models.ActionNeeded{
+		{
+			Service:   "mysql",
+			Receiver:  "mysql",
+			Endpoint:  "localhost:3306",
+			Reason:    models.ActionMissingCredentials,
+			Variables: []string{"MYSQL_MONITOR_USER", "MYSQL_MONITOR_PASS"},
+		},
+		{
+			Service:   "redis",
+			Receiver:  "redis/cache",
+			Endpoint:  "172.17.0.3:6379",
+			Reason:    models.ActionInvalidCredentials,
+			Variables: []string{"REDIS_PASSWORD"},
+		},
+	}, nil
+}
+
+type mockSuggestionsProvider struct {
+	err error
+}
+
+func (m *mockSuggestionsProvider) GetSuggestions() ([]models.Suggestion, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return []models.Suggestion{
+		{
+			Service:      "memcached",
+			Receiver:     "memcached",
+			Endpoint:     "localhost:11211",
+			Confidence:   "LOW",
+			DiscoveredBy: []string{"port"},
+		},
+		{
+			Service:      "redis",
+			Receiver:     "redis",
+			Endpoint:     "0.0.0.0:6379",
+			Confidence:   "LOW",
+			DiscoveredBy: []string{"port"},
+		},
+	}, nil
+}
+
+type mockProvenanceProvider struct {
+	err error
+}
+
+func (m *mockProvenanceProvider) GetConfigProvenance() (*models.ConfigProvenance, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	updated := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return &models.ConfigProvenance{
+		ConfigHash: "abc123",
+		LoadedAt:   updated,
+		Sources: []models.ConfigSource{
+			{Type: models.ConfigSourceDefault, Location: "nrdot-template-lib", UpdatedAt: updated},
+			{Type: models.ConfigSourceAutoconfig, Hash: "sha256:discovery", UpdatedAt: updated},
+			{Type: models.ConfigSourceUser, Location: "/etc/nrdot/config.yaml", Hash: "sha256:user", UpdatedAt: updated},
+			{Type: models.ConfigSourceRemote, Version: "7", UpdatedAt: updated},
+		},
+		Components: []models.ComponentProvenance{
+			{Path: "receivers.mysqlx", Source: models.ConfigSourceUser},
+			{Path: "receivers.mysql", Source: models.ConfigSourceAutoconfig, Reason: "mysql discovered on port 3306"},
+			{Path: "receivers.hostmetrics", Source: models.ConfigSourceDefault},
+			{Path: "processors.batch.timeout", Source: models.ConfigSourceRemote, Overridden: []string{models.ConfigSourceUser}},
+			{Path: "processors.batch", Source: models.ConfigSourceDefault},
+		},
+	}, nil
+}
+
+type mockSummaryProvider struct {
+	summary *models.HostSummary
+	err     error
+}
+
+func (m *mockSummaryProvider) GetHostSummary() (*models.HostSummary, error) {
+	return m.summary, m.err
+}
+
 type mockMetricsProvider struct{}
 
 func (m *mockMetricsProvider) GetCustomMetrics() []handlers.Metric {
@@ -234,4 +1140,55 @@ func (m *mockMetricsProvider) GetCustomMetrics() []handlers.Metric {
 			Value: 42,
 		},
 	}
-}
\ No newline at end of file
+}
+func TestResponseCacheInvalidation(t *testing.T) {
+	server := NewServer(Config{Host: "127.0.0.1", Version: "test", Cache: CacheConfig{Enabled: true, TTL: time.Minute}}, zap.NewNop())
+	health := &countingHealthProvider{}
+	events := &mockEventProvider{ch: make(chan models.Event, 10)}
+	server.SetProviders(&mockStatusProvider{}, health, &mockConfigProvider{}, &mockMetricsProvider{})
+	server.SetEventProvider(events)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.invalidateCacheOnEvents(ctx)
+
+	ts := httptest.NewServer(server.httpServer.Handler)
+	defer ts.Close()
+
+	get := func() string {
+		resp, err := http.Get(ts.URL + "/v1/health")
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return resp.Header.Get("X-Cache")
+	}
+
+	assert.Equal(t, "MISS", get())
+	assert.Equal(t, "HIT", get())
+	assert.Equal(t, int32(1), health.calls.Load())
+
+	// A reload drops cached responses
+	resp, err := http.Post(ts.URL+"/v1/reload", "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "MISS", get())
+	assert.Equal(t, int32(2), health.calls.Load())
+
+	// So do collector lifecycle events, but not unrelated ones
+	events.ch <- models.Event{Type: "security.auth_failure"}
+	events.ch <- models.Event{Type: "collector.restarted"}
+	require.Eventually(t, func() bool {
+		return get() == "MISS"
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(3), health.calls.Load())
+}
+
+type countingHealthProvider struct {
+	calls atomic.Int32
+}
+
+func (m *countingHealthProvider) GetComponentHealth() map[string]models.Health {
+	m.calls.Add(1)
+	return map[string]models.Health{"test": {Status: models.StatusHealthy}}
+}
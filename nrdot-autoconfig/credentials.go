@@ -0,0 +1,339 @@
+package autoconfig
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/newrelic/nrdot-host/nrdot-discovery"
+	"go.uber.org/zap"
+)
+
+// credentialCheckTimeout bounds the connection testing the credentials of
+// one service
+const credentialCheckTimeout = 5 * time.Second
+
+// Reasons a receiver is left out of a generated config
+const (
+	ActionMissingCredentials = "missing_credentials"
+	ActionInvalidCredentials = "invalid_credentials"
+)
+
+// ActionNeeded is a receiver left out of a generated config until its
+// credentials are provided or fixed. Receivers are added back on the next
+// scan once their credentials work.
+type ActionNeeded struct {
+	Service     string   `json:"service"`
+	Receiver    string   `json:"receiver"`
+	Endpoint    string   `json:"endpoint,omitempty"`
+	Reason      string   `json:"reason"` // one of the Action* constants
+	Variables   []string `json:"variables"`
+	Message     string   `json:"message"`
+	Remediation string   `json:"remediation"`
+}
+
+// credentialSetup tells how to get the credentials of each service type
+var credentialSetup = map[string]string{
+	"mysql":         "Create the user with: CREATE USER 'nrdot'@'%' IDENTIFIED BY '<password>'; GRANT PROCESS, REPLICATION CLIENT, SELECT ON *.* TO 'nrdot'@'%';",
+	"postgresql":    "Create the user with: CREATE USER nrdot WITH PASSWORD '<password>'; GRANT pg_monitor TO nrdot;",
+	"redis":         "REDIS_PASSWORD is the requirepass of the server, or the password of an ACL user allowed to run INFO.",
+	"mongodb":       "Create the user with: db.getSiblingDB('admin').createUser({user: 'nrdot', pwd: '<password>', roles: [{role: 'clusterMonitor', db: 'admin'}]})",
+	"elasticsearch": "Create a user with the monitor cluster privilege.",
+	"rabbitmq":      "Create the user with: rabbitmqctl add_user nrdot <password> && rabbitmqctl set_user_tags nrdot monitoring",
+	"tomcat":        "Add a readonly user to the file of -Dcom.sun.management.jmxremote.access.file and its password to -Dcom.sun.management.jmxremote.password.file.",
+	"consul":        "Create the token with: consul acl token create -description nrdot -node-identity <node>:<datacenter>; it needs agent:read.",
+	"solr":          "Add a readonly user to the file of -Dcom.sun.management.jmxremote.access.file and its password to -Dcom.sun.management.jmxremote.password.file, or set ENABLE_REMOTE_JMX_OPTS=true in solr.in.sh for Solr's unauthenticated JMX.",
+}
+
+// errAuthRequired reports a service asking for credentials the receiver is
+// not configured with
+var errAuthRequired = errors.New("authentication required")
+
+// rejectedError reports a service refusing the credentials it was given,
+// as opposed to not being reachable to test them
+type rejectedError struct {
+	err error
+}
+
+func (e *rejectedError) Error() string { return e.err.Error() }
+func (e *rejectedError) Unwrap() error { return e.err }
+
+// credentialCheck connects to a service at address with the credentials
+// lookup returns. It returns errAuthRequired or a *rejectedError when the
+// credentials do not work, any other error when it could not tell.
+type credentialCheck func(ctx context.Context, address string, lookup func(string) string) error
+
+// credentialChecks test the credentials of the service types the collector
+// logs in to with a protocol of its own
+var credentialChecks = map[string]credentialCheck{
+	"mysql":      checkMySQL,
+	"postgresql": checkPostgreSQL,
+	"redis":      checkRedis,
+	"consul":     checkConsul,
+}
+
+// authRequiredKey is the ServiceInfo.Additional key marking a service found
+// to require credentials discovery could not tell it needs
+const authRequiredKey = "auth_required"
+
+// verifyCredentials is the pre-flight of a config: it keeps the services
+// whose credentials are set and, where they can be tested, accepted. The
+// others are returned as actions needed and get no receiver. Services that
+// cannot be reached to test their credentials are kept; the collector
+// retries them.
+func (cg *ConfigGenerator) verifyCredentials(ctx context.Context, services []discovery.ServiceInfo) ([]discovery.ServiceInfo, []ActionNeeded) {
+	lookup := func(name string) string {
+		value, _ := cg.lookupEnv(name)
+		return value
+	}
+
+	verified := make([]discovery.ServiceInfo, 0, len(services))
+	var actions []ActionNeeded
+	for _, svc := range services {
+		address := ""
+		if len(svc.Endpoints) > 0 {
+			address = endpointAddress(svc.Endpoints[0])
+		}
+
+		// Credentials that are not set need no testing
+		var err error
+		if check, ok := credentialChecks[svc.Type]; ok && address != "" && len(missingCredentials(svc, lookup)) == 0 {
+			checkCtx, cancel := context.WithTimeout(ctx, credentialCheckTimeout)
+			err = check(checkCtx, address, lookup)
+			cancel()
+		}
+		// Redis and Consul run without credentials unless they answer
+		// otherwise
+		if (svc.Type == "redis" || svc.Type == "consul") && errors.Is(err, errAuthRequired) {
+			svc = withAdditional(svc, authRequiredKey, true)
+			// Retry with the credentials the receiver will now send
+			checkCtx, cancel := context.WithTimeout(ctx, credentialCheckTimeout)
+			if password := lookup("REDIS_PASSWORD"); svc.Type == "redis" && password != "" {
+				err = pingRedis(checkCtx, address, password)
+			} else if token := lookup("CONSUL_HTTP_TOKEN"); svc.Type == "consul" && token != "" {
+				err = readConsulMetrics(checkCtx, address, token)
+			}
+			cancel()
+		}
+
+		variables := serviceCredentials(svc)
+		missing := missingCredentials(svc, lookup)
+
+		var rejected *rejectedError
+		switch {
+		case len(missing) > 0:
+			actions = append(actions, ActionNeeded{
+				Service:   svc.Type,
+				Receiver:  receiverName(svc),
+				Endpoint:  address,
+				Reason:    ActionMissingCredentials,
+				Variables: missing,
+				Message:   fmt.Sprintf("%s not set", strings.Join(missing, ", ")),
+				Remediation: fmt.Sprintf("Set %s in the environment or %s; the receiver is added on the next scan. %s",
+					strings.Join(missing, ", "), secretsEnvFileName, credentialSetup[svc.Type]),
+			})
+		case errors.As(err, &rejected) || errors.Is(err, errAuthRequired):
+			names := make([]string, 0, len(variables))
+			for _, v := range variables {
+				names = append(names, v[0])
+			}
+			actions = append(actions, ActionNeeded{
+				Service:   svc.Type,
+				Receiver:  receiverName(svc),
+				Endpoint:  address,
+				Reason:    ActionInvalidCredentials,
+				Variables: names,
+				Message:   fmt.Sprintf("%s on %s rejected the credentials: %v", svc.Type, address, err),
+				Remediation: fmt.Sprintf("Check %s in the environment or %s; the receiver is added on the next scan once they work. %s",
+					strings.Join(names, ", "), secretsEnvFileName, credentialSetup[svc.Type]),
+			})
+		default:
+			if err != nil {
+				cg.logger.Warn("Could not verify service credentials",
+					zap.String("service", svc.Type),
+					zap.String("endpoint", address),
+					zap.Error(err))
+			}
+			verified = append(verified, svc)
+			continue
+		}
+
+		action := actions[len(actions)-1]
+		cg.logger.Warn("Action needed: receiver omitted",
+			zap.String("service", action.Service),
+			zap.String("receiver", action.Receiver),
+			zap.String("endpoint", action.Endpoint),
+			zap.String("reason", action.Reason),
+			zap.Strings("variables", action.Variables),
+			zap.String("remediation", action.Remediation))
+	}
+	return verified, actions
+}
+
+// serviceCredentials returns the variables holding the credentials the
+// receiver of svc logs in with
+func serviceCredentials(svc discovery.ServiceInfo) [][2]string {
+	switch svc.Type {
+	case "redis", "consul":
+		if !requiresAuth(svc) {
+			return nil
+		}
+	case "tomcat", "solr":
+		if !jmxAuthenticate(svc) {
+			return nil
+		}
+	}
+	return credentialVariables[svc.Type]
+}
+
+// missingCredentials returns the credential variables of svc that are not
+// set
+func missingCredentials(svc discovery.ServiceInfo, lookup func(string) string) []string {
+	var missing []string
+	for _, v := range serviceCredentials(svc) {
+		if lookup(v[0]) == "" {
+			missing = append(missing, v[0])
+		}
+	}
+	return missing
+}
+
+// withAdditional returns svc with an Additional entry set, leaving the map
+// shared with discovery results untouched
+func withAdditional(svc discovery.ServiceInfo, key string, value interface{}) discovery.ServiceInfo {
+	additional := make(map[string]interface{}, len(svc.Additional)+1)
+	for k, v := range svc.Additional {
+		additional[k] = v
+	}
+	additional[key] = value
+	svc.Additional = additional
+	return svc
+}
+
+// checkMySQL logs in to MySQL as MYSQL_MONITOR_USER
+func checkMySQL(ctx context.Context, address string, lookup func(string) string) error {
+	config := mysql.NewConfig()
+	config.User = lookup("MYSQL_MONITOR_USER")
+	config.Passwd = lookup("MYSQL_MONITOR_PASS")
+	config.Net = "tcp"
+	config.Addr = address
+	config.Timeout = credentialCheckTimeout
+
+	db, err := sql.Open("mysql", config.FormatDSN())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.PingContext(ctx)
+	var mysqlErr *mysql.MySQLError
+	// ER_ACCESS_DENIED_ERROR
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1045 {
+		return &rejectedError{err: err}
+	}
+	return err
+}
+
+// checkPostgreSQL logs in to PostgreSQL as POSTGRES_MONITOR_USER, to the
+// database the receiver collects
+func checkPostgreSQL(ctx context.Context, address string, lookup func(string) string) error {
+	database := lookup("POSTGRES_MONITOR_DB")
+	if database == "" {
+		database = "postgres"
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(lookup("POSTGRES_MONITOR_USER"), lookup("POSTGRES_MONITOR_PASS")),
+		Host:     address,
+		Path:     "/" + database,
+		RawQuery: fmt.Sprintf("sslmode=disable&connect_timeout=%d", int(credentialCheckTimeout.Seconds())),
+	}
+
+	db, err := sql.Open("postgres", dsn.String())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.PingContext(ctx)
+	var pqErr *pq.Error
+	// invalid_password, or invalid_authorization_specification for unknown
+	// users and pg_hba.conf rejections
+	if errors.As(err, &pqErr) && (pqErr.Code == "28P01" || pqErr.Code == "28000") {
+		return &rejectedError{err: err}
+	}
+	return err
+}
+
+// checkRedis pings Redis without a password, as the receiver connects
+// unless Redis asks for one
+func checkRedis(ctx context.Context, address string, _ func(string) string) error {
+	return pingRedis(ctx, address, "")
+}
+
+// pingRedis pings Redis, authenticating with password if not empty
+func pingRedis(ctx context.Context, address, password string) error {
+	client := redis.NewClient(&redis.Options{
+		Addr:        address,
+		Password:    password,
+		DialTimeout: credentialCheckTimeout,
+		MaxRetries:  -1,
+	})
+	defer client.Close()
+
+	err := client.Ping(ctx).Err()
+	switch {
+	case err == nil:
+		return nil
+	case strings.HasPrefix(err.Error(), "NOAUTH"):
+		return errAuthRequired
+	case strings.HasPrefix(err.Error(), "WRONGPASS"), strings.Contains(err.Error(), "invalid password"),
+		strings.Contains(err.Error(), "without any password configured"):
+		return &rejectedError{err: err}
+	}
+	return err
+}
+
+// checkConsul reads the agent's metrics without a token, as the receiver
+// scrapes them unless Consul asks for one
+func checkConsul(ctx context.Context, address string, _ func(string) string) error {
+	return readConsulMetrics(ctx, address, "")
+}
+
+// readConsulMetrics reads the metrics of a Consul agent, with the ACL token
+// if not empty
+func readConsulMetrics(ctx context.Context, address, token string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+"/v1/agent/metrics", nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusForbidden && token == "":
+		return errAuthRequired
+	case resp.StatusCode == http.StatusForbidden:
+		return &rejectedError{err: fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))}
+	}
+	return fmt.Errorf("unexpected status %s", resp.Status)
+}
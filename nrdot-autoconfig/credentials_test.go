@@ -0,0 +1,178 @@
+package autoconfig
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-discovery"
+	"go.uber.org/zap"
+)
+
+// envLookup looks variables up in env instead of the environment
+func envLookup(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+// consulAgent serves agent metrics to requests with token, answering 403
+// to any other, as Consul does with ACLs enabled
+func consulAgent(t *testing.T, token string) int {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/agent/metrics" || r.Header.Get("X-Consul-Token") != token {
+			http.Error(w, "Permission denied", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"Gauges": []}`))
+	}))
+	t.Cleanup(server.Close)
+	return server.Listener.Addr().(*net.TCPAddr).Port
+}
+
+// closedPort returns a local port that was listened on and closed again,
+// so credential checks cannot connect to it
+func closedPort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	return port
+}
+
+func TestVerifyCredentials_Missing(t *testing.T) {
+	generator := NewConfigGenerator(zap.NewNop())
+	generator.lookupEnv = envLookup(map[string]string{"MYSQL_MONITOR_USER": "nrdot"})
+
+	verified, actions := generator.verifyCredentials(context.Background(), []discovery.ServiceInfo{
+		service("mysql", 3306),
+		service("nginx", 80),
+		service("memcached", 11211),
+	})
+	if want := []string{"nginx", "memcached"}; !reflect.DeepEqual(receiverNames(verified), want) {
+		t.Errorf("Expected %v verified, got %v", want, receiverNames(verified))
+	}
+	if len(actions) != 1 {
+		t.Fatalf("Expected 1 action needed, got %+v", actions)
+	}
+	action := actions[0]
+	if action.Reason != ActionMissingCredentials || action.Receiver != "mysql" || action.Endpoint != "127.0.0.1:3306" ||
+		!reflect.DeepEqual(action.Variables, []string{"MYSQL_MONITOR_PASS"}) {
+		t.Errorf("Expected MYSQL_MONITOR_PASS missing for mysql, got %+v", action)
+	}
+	if action.Message != "MYSQL_MONITOR_PASS not set" {
+		t.Errorf("Unexpected message %q", action.Message)
+	}
+}
+
+func TestVerifyCredentials_Unreachable(t *testing.T) {
+	generator := NewConfigGenerator(zap.NewNop())
+	generator.lookupEnv = envLookup(map[string]string{
+		"MYSQL_MONITOR_USER": "nrdot",
+		"MYSQL_MONITOR_PASS": "secret",
+	})
+
+	// Credentials that cannot be tested are kept for the collector to retry
+	verified, actions := generator.verifyCredentials(context.Background(), []discovery.ServiceInfo{service("mysql", closedPort(t))})
+	if len(verified) != 1 || len(actions) != 0 {
+		t.Errorf("Expected mysql kept while unreachable, got %v and %+v", receiverNames(verified), actions)
+	}
+}
+
+func TestVerifyCredentials_AuthRequired(t *testing.T) {
+	port := consulAgent(t, "agent-token")
+
+	tests := []struct {
+		name   string
+		env    map[string]string
+		reason string
+	}{
+		{"token not set", map[string]string{}, ActionMissingCredentials},
+		{"token rejected", map[string]string{"CONSUL_HTTP_TOKEN": "wrong"}, ActionInvalidCredentials},
+		{"token accepted", map[string]string{"CONSUL_HTTP_TOKEN": "agent-token"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator := NewConfigGenerator(zap.NewNop())
+			generator.lookupEnv = envLookup(tt.env)
+
+			verified, actions := generator.verifyCredentials(context.Background(), []discovery.ServiceInfo{service("consul", port)})
+			if tt.reason == "" {
+				if len(actions) != 0 || len(verified) != 1 || !requiresAuth(verified[0]) {
+					t.Fatalf("Expected consul verified with auth required, got %+v and %+v", verified, actions)
+				}
+				return
+			}
+			if len(verified) != 0 || len(actions) != 1 {
+				t.Fatalf("Expected consul omitted, got %+v and %+v", verified, actions)
+			}
+			if actions[0].Reason != tt.reason || actions[0].Receiver != "prometheus/consul" ||
+				!reflect.DeepEqual(actions[0].Variables, []string{"CONSUL_HTTP_TOKEN"}) {
+				t.Errorf("Expected %s for CONSUL_HTTP_TOKEN, got %+v", tt.reason, actions[0])
+			}
+		})
+	}
+}
+
+func TestLoadSecretsEnv(t *testing.T) {
+	// Variables set in the environment win over the file
+	t.Setenv("MYSQL_MONITOR_USER", "from-env")
+	t.Setenv("MYSQL_MONITOR_PASS", "")
+	os.Unsetenv("MYSQL_MONITOR_PASS")
+
+	aco := NewAutoConfigOrchestrator(zap.NewNop(), loadConfig(t, "service:\n  name: node-01\n"), nil)
+	envPath := filepath.Join(filepath.Dir(aco.configPath), secretsEnvFileName)
+	if err := os.WriteFile(envPath, []byte("MYSQL_MONITOR_USER=from-file\nMYSQL_MONITOR_PASS=secret\n"), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", secretsEnvFileName, err)
+	}
+	if err := aco.loadSecretsEnv(envPath); err != nil {
+		t.Fatalf("Failed to load %s: %v", secretsEnvFileName, err)
+	}
+	if user, pass := os.Getenv("MYSQL_MONITOR_USER"), os.Getenv("MYSQL_MONITOR_PASS"); user != "from-env" || pass != "secret" {
+		t.Errorf("Expected the user from the environment and the password from the file, got %q and %q", user, pass)
+	}
+
+	// The generator sees credentials from either source
+	generator := NewConfigGenerator(zap.NewNop())
+	if missing := missingCredentials(service("mysql", 3306), func(name string) string {
+		value, _ := generator.lookupEnv(name)
+		return value
+	}); len(missing) != 0 {
+		t.Errorf("Expected no missing credentials, got %v", missing)
+	}
+
+	// Variables removed from the file are unset again, the environment's kept
+	if err := os.WriteFile(envPath, []byte("# no secrets\n"), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", secretsEnvFileName, err)
+	}
+	if err := aco.loadSecretsEnv(envPath); err != nil {
+		t.Fatalf("Failed to load %s: %v", secretsEnvFileName, err)
+	}
+	if _, ok := os.LookupEnv("MYSQL_MONITOR_PASS"); ok || os.Getenv("MYSQL_MONITOR_USER") != "from-env" {
+		t.Errorf("Expected only the password from the file to be unset, got user %q", os.Getenv("MYSQL_MONITOR_USER"))
+	}
+
+	_, actions := generator.verifyCredentials(context.Background(), []discovery.ServiceInfo{service("mysql", 3306)})
+	if len(actions) != 1 || !reflect.DeepEqual(actions[0].Variables, []string{"MYSQL_MONITOR_PASS"}) {
+		t.Errorf("Expected MYSQL_MONITOR_PASS missing, got %+v", actions)
+	}
+
+	// A malformed file is an error
+	if err := os.WriteFile(envPath, []byte("MYSQL_MONITOR_PASS\n"), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", secretsEnvFileName, err)
+	}
+	if err := aco.loadSecretsEnv(envPath); err == nil {
+		t.Error("Expected an error for a malformed file")
+	}
+}
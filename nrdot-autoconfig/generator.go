@@ -12,9 +12,11 @@ import (
 	"encoding/pem"
 	"fmt"
 	"math/big"
+	"net"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/newrelic/nrdot-host/nrdot-discovery"
@@ -28,6 +30,10 @@ type ConfigGenerator struct {
 	templateEngine *TemplateEngine
 	validator      *ConfigValidator
 	signer         *ConfigSigner
+	kubelet        *discovery.KubeletConfig // nil unless SetKubernetes was called
+	overrides      *Overrides // operator changes merged into generated configs, nil for none
+	policies       *Policies  // guardrails generated configs are checked against, nil for none
+	lookupEnv      func(string) (string, bool) // credentials tested before adding receivers
 }
 
 // NewConfigGenerator creates a new configuration generator
@@ -37,13 +43,49 @@ func NewConfigGenerator(logger *zap.Logger) *ConfigGenerator {
 		templateEngine: NewTemplateEngine(logger),
 		validator:      NewConfigValidator(logger),
 		signer:         NewConfigSigner(logger),
+		lookupEnv:      os.LookupEnv,
 	}
 }
 
+// SetKubernetes adds the kubeletstats receiver, collecting the metrics of
+// this node and its pods from the kubelet, to the metrics pipeline
+func (cg *ConfigGenerator) SetKubernetes(kubelet discovery.KubeletConfig) {
+	cg.kubelet = &kubelet
+}
+
+// SetOverrides sets the operator's overrides of the configs generated next
+func (cg *ConfigGenerator) SetOverrides(overrides *Overrides) {
+	cg.overrides = overrides
+}
+
+// SetPolicies sets the guardrails the configs generated next are checked
+// against
+func (cg *ConfigGenerator) SetPolicies(policies *Policies) {
+	cg.policies = policies
+}
+
 // GenerateConfig creates a complete configuration from discovered services
 func (cg *ConfigGenerator) GenerateConfig(ctx context.Context, services []discovery.ServiceInfo) (*GeneratedConfig, error) {
 	cg.logger.Info("Generating configuration", zap.Int("services", len(services)))
 
+	// Discovered services stay in the result, but installed services that
+	// are not running get no receivers
+	discovered := services
+	services = cg.runningServices(services)
+	services, _ = cg.overrides.filter(services)
+
+	// Services found with LOW confidence, e.g. from a listening port alone,
+	// are often something else; suggest their receivers instead of letting
+	// them log scrape errors
+	services, unconfident := cg.confidentServices(services)
+	suggestions, stubs := cg.generateSuggestions(unconfident)
+
+	// Receivers whose credentials are missing or rejected would only fail
+	// in the collector; leave them out and report what to fix
+	services, actions := cg.verifyCredentials(ctx, services)
+
+	version := fmt.Sprintf("%s-%03d", time.Now().Format("2006-01-02"), 1)
+
 	// Build configuration sections
 	config := make(map[string]interface{})
 	
@@ -55,7 +97,7 @@ func (cg *ConfigGenerator) GenerateConfig(ctx context.Context, services []discov
 	config["receivers"] = receivers
 
 	// Generate processors
-	processors, err := cg.generateProcessors()
+	processors, err := cg.generateProcessors(services, version)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate processors: %w", err)
 	}
@@ -82,6 +124,9 @@ func (cg *ConfigGenerator) GenerateConfig(ctx context.Context, services []discov
 	}
 	config["extensions"] = extensions
 
+	// The operator's overrides win over the templates
+	cg.overrides.apply(config)
+
 	// Convert to YAML
 	var buf bytes.Buffer
 	encoder := yaml.NewEncoder(&buf)
@@ -92,15 +137,26 @@ func (cg *ConfigGenerator) GenerateConfig(ctx context.Context, services []discov
 
 	configYAML := buf.String()
 
-	// Add header comment
-	header := cg.generateHeader(services)
-	configYAML = header + "\n" + configYAML
+	// Add header comment, and the suggested receivers commented out
+	header := cg.generateHeader(services, actions, suggestions, version)
+	footer, err := renderStubs(stubs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode suggested receivers: %w", err)
+	}
+	configYAML = header + "\n" + configYAML + footer
 
 	// Validate configuration
 	if err := cg.validator.Validate(configYAML); err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
 	}
 
+	// Check the organization's guardrails against the config as written,
+	// overrides included
+	violations, err := cg.checkPolicies(configYAML)
+	if err != nil {
+		return nil, err
+	}
+
 	// Sign configuration
 	signature, err := cg.signer.Sign([]byte(configYAML))
 	if err != nil {
@@ -109,23 +165,80 @@ func (cg *ConfigGenerator) GenerateConfig(ctx context.Context, services []discov
 
 	// Identify required variables
 	variables := cg.identifyRequiredVariables(services)
+	names := make([]string, 0, len(variables))
+	for _, v := range variables {
+		names = append(names, v.Name)
+	}
 
 	return &GeneratedConfig{
-		Version:           fmt.Sprintf("%s-%03d", time.Now().Format("2006-01-02"), 1),
+		Version:           version,
 		Config:            configYAML,
 		Signature:         signature,
-		DiscoveredServices: services,
-		RequiredVariables: variables,
+		DiscoveredServices: discovered,
+		RequiredVariables: names,
+		Variables:         variables,
+		ActionsNeeded:     actions,
+		PolicyViolations:  violations,
+		Suggestions:       suggestions,
 		GeneratedAt:       time.Now(),
 	}, nil
 }
 
+// checkPolicies checks a config against the policies. Violations of
+// warning policies are logged and returned; those of blocking policies
+// fail the config with a *PolicyError.
+func (cg *ConfigGenerator) checkPolicies(configYAML string) ([]PolicyViolation, error) {
+	if cg.policies == nil || len(cg.policies.Policies) == 0 {
+		return nil, nil
+	}
+
+	var parsed map[string]interface{}
+	if err := yaml.Unmarshal([]byte(configYAML), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	violations := cg.policies.Evaluate(parsed)
+	blocked := false
+	for _, v := range violations {
+		if v.Action == PolicyBlock {
+			blocked = true
+			cg.logger.Error("Config violates policy",
+				zap.String("policy", v.Policy),
+				zap.String("message", v.Message))
+			continue
+		}
+		cg.logger.Warn("Config violates policy",
+			zap.String("policy", v.Policy),
+			zap.String("message", v.Message))
+	}
+	if blocked {
+		return violations, &PolicyError{Violations: violations}
+	}
+	return violations, nil
+}
+
+// runningServices drops the services discovery found stopped
+func (cg *ConfigGenerator) runningServices(services []discovery.ServiceInfo) []discovery.ServiceInfo {
+	running := make([]discovery.ServiceInfo, 0, len(services))
+	for _, svc := range services {
+		if svc.Stopped() {
+			cg.logger.Info("Skipping stopped service", zap.String("service", svc.Type))
+			continue
+		}
+		running = append(running, svc)
+	}
+	return running
+}
+
 // generateReceivers creates receiver configurations
 func (cg *ConfigGenerator) generateReceivers(services []discovery.ServiceInfo) (map[string]interface{}, error) {
 	receivers := make(map[string]interface{})
 
 	// Always include host metrics
 	receivers["hostmetrics"] = cg.templateEngine.RenderHostMetrics()
+	if cg.kubelet != nil {
+		receivers["kubeletstats"] = cg.templateEngine.RenderKubeletStats(*cg.kubelet)
+	}
 
 	// Add service-specific receivers
 	for _, svc := range services {
@@ -138,7 +251,12 @@ func (cg *ConfigGenerator) generateReceivers(services []discovery.ServiceInfo) (
 		}
 
 		// Add receiver config
-		receivers[svc.Type] = receiverConfig
+		receivers[receiverName(svc)] = receiverConfig
+
+		// Log files of containerized services are not on the host
+		if svc.Container() != nil {
+			continue
+		}
 
 		// Add log receivers if applicable
 		logConfigs := cg.templateEngine.RenderLogReceivers(svc)
@@ -153,8 +271,43 @@ func (cg *ConfigGenerator) generateReceivers(services []discovery.ServiceInfo) (
 	return receivers, nil
 }
 
+// receiverTypes are the receivers of the service types collected by a
+// generic receiver rather than one of their own, e.g. Tomcat through JMX
+var receiverTypes = map[string]string{
+	"tomcat":  "jmx",
+	"solr":    "jmx",
+	"consul":  "prometheus",
+	"etcd":    "prometheus",
+	"varnish": "prometheus",
+}
+
+// receiverName returns the name of a service's receiver: its type, or the
+// generic receiver named after it, e.g. jmx/tomcat, with the container name
+// for services running in a container, e.g. mysql/orders-db, or the
+// namespace and pod for services in a Kubernetes pod, e.g.
+// mysql/shop.orders-db-0, so each instance gets its own receiver next to one
+// on the host
+func receiverName(svc discovery.ServiceInfo) string {
+	name := svc.Type
+	if receiver, ok := receiverTypes[svc.Type]; ok {
+		name = receiver + "/" + svc.Type
+	}
+	if pod := svc.Pod(); pod != nil {
+		return name + "/" + pod.Namespace + "." + pod.Name
+	}
+	if container := svc.Container(); container != nil {
+		return name + "/" + container.Name
+	}
+	return name
+}
+
 // generateProcessors creates processor configurations
-func (cg *ConfigGenerator) generateProcessors() (map[string]interface{}, error) {
+func (cg *ConfigGenerator) generateProcessors(services []discovery.ServiceInfo, version string) (map[string]interface{}, error) {
+	serviceTypes := make([]string, 0, len(services))
+	for _, svc := range services {
+		serviceTypes = append(serviceTypes, svc.Type)
+	}
+
 	return map[string]interface{}{
 		"nrsecurity": map[string]interface{}{
 			"_comment": "Automatic secret redaction - no configuration needed",
@@ -168,12 +321,12 @@ func (cg *ConfigGenerator) generateProcessors() (map[string]interface{}, error)
 			"actions": []map[string]interface{}{
 				{
 					"key":    "discovered.services",
-					"value":  "${DISCOVERED_SERVICES}",
+					"value":  strings.Join(serviceTypes, ","),
 					"action": "insert",
 				},
 				{
 					"key":    "autoconfig.version",
-					"value":  "${CONFIG_VERSION}",
+					"value":  version,
 					"action": "insert",
 				},
 			},
@@ -221,20 +374,24 @@ func (cg *ConfigGenerator) generateExporters() (map[string]interface{}, error) {
 func (cg *ConfigGenerator) generateServicePipelines(services []discovery.ServiceInfo) (map[string]interface{}, error) {
 	// Build receiver lists
 	metricsReceivers := []string{"hostmetrics"}
+	if cg.kubelet != nil {
+		metricsReceivers = append(metricsReceivers, "kubeletstats")
+	}
 	logsReceivers := []string{"filelog/system"}
 
 	for _, svc := range services {
-		metricsReceivers = append(metricsReceivers, svc.Type)
+		metricsReceivers = append(metricsReceivers, receiverName(svc))
 		
 		// Add log receivers
-		if svc.Type == "mysql" {
-			logsReceivers = append(logsReceivers, "filelog/mysql_error", "filelog/mysql_slow")
-		} else if svc.Type == "postgresql" {
-			logsReceivers = append(logsReceivers, "filelog/postgresql")
-		} else if svc.Type == "nginx" {
-			logsReceivers = append(logsReceivers, "filelog/nginx_access", "filelog/nginx_error")
+		if svc.Container() != nil {
+			continue
+		}
+		names := make([]string, 0, 2)
+		for name := range cg.templateEngine.RenderLogReceivers(svc) {
+			names = append(names, name)
 		}
-		// Add more service-specific logs as needed
+		sort.Strings(names)
+		logsReceivers = append(logsReceivers, names...)
 	}
 
 	return map[string]interface{}{
@@ -292,7 +449,7 @@ func (cg *ConfigGenerator) generateExtensions() (map[string]interface{}, error)
 }
 
 // generateHeader creates configuration header comment
-func (cg *ConfigGenerator) generateHeader(services []discovery.ServiceInfo) string {
+func (cg *ConfigGenerator) generateHeader(services []discovery.ServiceInfo, actions []ActionNeeded, suggestions []Suggestion, version string) string {
 	serviceList := make([]string, 0, len(services))
 	for _, svc := range services {
 		info := fmt.Sprintf("%s", svc.Type)
@@ -302,57 +459,127 @@ func (cg *ConfigGenerator) generateHeader(services []discovery.ServiceInfo) stri
 		if len(svc.Endpoints) > 0 {
 			endpoints := make([]string, 0, len(svc.Endpoints))
 			for _, ep := range svc.Endpoints {
-				endpoints = append(endpoints, fmt.Sprintf("%s:%d", ep.Address, ep.Port))
+				endpoints = append(endpoints, net.JoinHostPort(ep.Address, strconv.Itoa(ep.Port)))
 			}
 			info += fmt.Sprintf(" on %s", strings.Join(endpoints, ","))
 		}
+		if pod := svc.Pod(); pod != nil {
+			info += fmt.Sprintf(" in pod %s/%s (%s)", pod.Namespace, pod.Name, svc.Container().Image)
+		} else if container := svc.Container(); container != nil {
+			info += fmt.Sprintf(" in %s container %s (%s)", container.Runtime, container.Name, container.Image)
+		}
 		serviceList = append(serviceList, "# - " + info)
 	}
+	for _, action := range actions {
+		serviceList = append(serviceList, fmt.Sprintf("# - %s on %s: receiver omitted, action needed: %s", action.Service, action.Endpoint, action.Message))
+	}
+	for _, suggestion := range suggestions {
+		serviceList = append(serviceList, fmt.Sprintf("# - %s on %s: receiver suggested below, %s", suggestion.Service, suggestion.Endpoint, suggestion.Message))
+	}
+
+	overrides := ""
+	if path := cg.overrides.Path(); path != "" {
+		overrides = fmt.Sprintf("# Overrides applied from: %s\n", path)
+	}
 
 	return fmt.Sprintf(`# Auto-Generated Configuration
 # This file was automatically generated by NRDOT-HOST auto-configuration
 # Generated at: %s
 # Config version: %s
-# Discovered services:
+%s# Discovered services:
 %s
 #
-# DO NOT EDIT - This file will be regenerated
+# DO NOT EDIT - This file will be regenerated; put changes in %s
 `, 
 		time.Now().Format(time.RFC3339),
-		fmt.Sprintf("%s-%03d", time.Now().Format("2006-01-02"), 1),
-		strings.Join(serviceList, "\n"))
+		version,
+		overrides,
+		strings.Join(serviceList, "\n"),
+		overridesFileName)
+}
+
+// credentialVariables are the variables holding the credentials of each
+// service type, with what they hold
+var credentialVariables = map[string][][2]string{
+	"mysql": {
+		{"MYSQL_MONITOR_USER", "MySQL user for the mysql receiver"},
+		{"MYSQL_MONITOR_PASS", "Password of MYSQL_MONITOR_USER"},
+	},
+	"postgresql": {
+		{"POSTGRES_MONITOR_USER", "PostgreSQL user for the postgresql receiver"},
+		{"POSTGRES_MONITOR_PASS", "Password of POSTGRES_MONITOR_USER"},
+	},
+	"mongodb": {
+		{"MONGODB_MONITOR_USER", "MongoDB user for the mongodb receiver"},
+		{"MONGODB_MONITOR_PASS", "Password of MONGODB_MONITOR_USER"},
+	},
+	"elasticsearch": {
+		{"ELASTICSEARCH_USER", "Elasticsearch user for the elasticsearch receiver"},
+		{"ELASTICSEARCH_PASS", "Password of ELASTICSEARCH_USER"},
+	},
+	"rabbitmq": {
+		{"RABBITMQ_USER", "RabbitMQ management user for the rabbitmq receiver"},
+		{"RABBITMQ_PASS", "Password of RABBITMQ_USER"},
+	},
+	"redis": {
+		{"REDIS_PASSWORD", "Password of the redis receiver"},
+	},
+	"tomcat": {
+		{"TOMCAT_JMX_USER", "JMX user for the jmx/tomcat receiver"},
+		{"TOMCAT_JMX_PASS", "Password of TOMCAT_JMX_USER"},
+	},
+	"consul": {
+		{"CONSUL_HTTP_TOKEN", "Consul ACL token with agent:read for the prometheus/consul receiver"},
+	},
+	"solr": {
+		{"SOLR_JMX_USER", "JMX user for the jmx/solr receiver"},
+		{"SOLR_JMX_PASS", "Password of SOLR_JMX_USER"},
+	},
 }
 
-// identifyRequiredVariables identifies environment variables needed
-func (cg *ConfigGenerator) identifyRequiredVariables(services []discovery.ServiceInfo) []string {
-	required := []string{"NEW_RELIC_LICENSE_KEY"}
+// identifyRequiredVariables identifies the environment variables needed by
+// the config, in order of first use. A variable shared by several instances
+// of a service lists all their endpoints.
+func (cg *ConfigGenerator) identifyRequiredVariables(services []discovery.ServiceInfo) []RequiredVariable {
+	required := []RequiredVariable{{
+		Name:        "NEW_RELIC_LICENSE_KEY",
+		Service:     "newrelic",
+		Endpoints:   []string{"otlp.nr-data.net:4317"},
+		Description: "New Relic license key the otlp/newrelic exporter sends data with",
+	}}
+	index := map[string]int{"NEW_RELIC_LICENSE_KEY": 0}
 
 	for _, svc := range services {
-		switch svc.Type {
-		case "mysql":
-			required = append(required, "MYSQL_MONITOR_USER", "MYSQL_MONITOR_PASS")
-		case "postgresql":
-			required = append(required, "POSTGRES_MONITOR_USER", "POSTGRES_MONITOR_PASS")
-		case "mongodb":
-			required = append(required, "MONGODB_MONITOR_USER", "MONGODB_MONITOR_PASS")
-		case "redis":
-			if requiresAuth(svc) {
-				required = append(required, "REDIS_PASSWORD")
+		credentials := serviceCredentials(svc)
+
+		endpoints := make([]string, 0, len(svc.Endpoints))
+		for _, ep := range svc.Endpoints {
+			endpoints = append(endpoints, net.JoinHostPort(ep.Address, strconv.Itoa(ep.Port)))
+		}
+
+		for _, credential := range credentials {
+			if i, ok := index[credential[0]]; ok {
+				required[i].Endpoints = append(required[i].Endpoints, endpoints...)
+				continue
 			}
-		case "elasticsearch":
-			required = append(required, "ELASTICSEARCH_USER", "ELASTICSEARCH_PASS")
-		case "rabbitmq":
-			required = append(required, "RABBITMQ_USER", "RABBITMQ_PASS")
+			index[credential[0]] = len(required)
+			required = append(required, RequiredVariable{
+				Name:        credential[0],
+				Service:     svc.Type,
+				Endpoints:   endpoints,
+				Description: credential[1],
+			})
 		}
 	}
 
 	return required
 }
 
+// requiresAuth reports whether a service that may run without credentials,
+// like Redis, asked for them during the credential pre-flight
 func requiresAuth(svc discovery.ServiceInfo) bool {
-	// Check if service requires authentication based on config or other signals
-	// This is a simplified check - in production would check actual config files
-	return false
+	required, _ := svc.Additional[authRequiredKey].(bool)
+	return required
 }
 
 // GeneratedConfig represents a generated configuration
@@ -362,6 +589,10 @@ type GeneratedConfig struct {
 	Signature          string                   `json:"signature"`
 	DiscoveredServices []discovery.ServiceInfo  `json:"discovered_services"`
 	RequiredVariables  []string                 `json:"required_variables"`
+	Variables          []RequiredVariable       `json:"variables"` // RequiredVariables with the services using them
+	ActionsNeeded      []ActionNeeded           `json:"actions_needed,omitempty"` // receivers left out for their credentials
+	PolicyViolations   []PolicyViolation        `json:"policy_violations,omitempty"` // of warning policies
+	Suggestions        []Suggestion             `json:"suggestions,omitempty"` // receivers left out for their LOW confidence
 	GeneratedAt        time.Time                `json:"generated_at"`
 }
 
@@ -0,0 +1,103 @@
+package autoconfig
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-discovery"
+	"go.uber.org/zap"
+)
+
+// receiverFor generates the receivers of one service and returns its own
+func receiverFor(t *testing.T, service discovery.ServiceInfo) map[string]interface{} {
+	t.Helper()
+	receivers, err := NewConfigGenerator(zap.NewNop()).generateReceivers([]discovery.ServiceInfo{service})
+	if err != nil {
+		t.Fatalf("Failed to generate receivers: %v", err)
+	}
+	receiver, ok := receivers[receiverName(service)].(map[string]interface{})
+	if !ok {
+		t.Fatalf("No receiver generated for %s", service.Type)
+	}
+	return receiver
+}
+
+// metricEnabled reports whether a receiver configuration enables a metric
+func metricEnabled(receiver map[string]interface{}, name string) bool {
+	metric, _ := receiver["metrics"].(map[string]interface{})[name].(map[string]bool)
+	return metric["enabled"]
+}
+
+func TestGenerateReceivers_MySQLVersions(t *testing.T) {
+	for version, replica := range map[string]bool{
+		"":        true,
+		"5.7.44":  false,
+		"8.0.35":  true,
+		"10.6.12": true, // MariaDB
+	} {
+		receiver := receiverFor(t, discovery.ServiceInfo{Type: "mysql", Version: version})
+		for _, name := range []string{"mysql.replica.lag", "mysql.replica.sql_delay"} {
+			if got := metricEnabled(receiver, name); got != replica {
+				t.Errorf("MySQL %q: expected %s enabled %v, got %v", version, name, replica, got)
+			}
+		}
+		if !metricEnabled(receiver, "mysql.questions") {
+			t.Errorf("MySQL %q: expected mysql.questions enabled", version)
+		}
+	}
+}
+
+func TestGenerateReceivers_PostgreSQLVersions(t *testing.T) {
+	for version, lag := range map[string]bool{
+		"":     true,
+		"9.6":  false,
+		"10.2": true,
+		"16.1": true,
+	} {
+		receiver := receiverFor(t, discovery.ServiceInfo{Type: "postgresql", Version: version})
+		for _, name := range []string{"postgresql.replication.lag", "postgresql.wal.delay"} {
+			if got := metricEnabled(receiver, name); got != lag {
+				t.Errorf("PostgreSQL %q: expected %s enabled %v, got %v", version, name, lag, got)
+			}
+		}
+	}
+}
+
+func TestGenerateReceivers_ElasticsearchVersions(t *testing.T) {
+	endpoints := []discovery.Endpoint{{Address: "0.0.0.0", Port: 9200, Protocol: "tcp"}}
+
+	for _, version := range []string{"", "7.17.15"} {
+		receiver := receiverFor(t, discovery.ServiceInfo{Type: "elasticsearch", Version: version, Endpoints: endpoints})
+		if want := []string{"http://localhost:9200"}; !reflect.DeepEqual(receiver["endpoints"], want) {
+			t.Errorf("Elasticsearch %q: expected %v, got %v", version, want, receiver["endpoints"])
+		}
+		if _, ok := receiver["tls"]; ok {
+			t.Errorf("Elasticsearch %q: expected no TLS settings", version)
+		}
+	}
+
+	receiver := receiverFor(t, discovery.ServiceInfo{Type: "elasticsearch", Version: "8.11.1", Endpoints: endpoints})
+	if want := []string{"https://localhost:9200"}; !reflect.DeepEqual(receiver["endpoints"], want) {
+		t.Errorf("Elasticsearch 8: expected %v, got %v", want, receiver["endpoints"])
+	}
+	if _, ok := receiver["tls"].(map[string]interface{})["ca_file"]; !ok {
+		t.Errorf("Elasticsearch 8: expected a CA file, got %v", receiver["tls"])
+	}
+}
+
+func TestGenerateReceivers_ElasticsearchProbedOverHTTP(t *testing.T) {
+	receiver := receiverFor(t, discovery.ServiceInfo{
+		Type:      "elasticsearch",
+		Version:   "8.11.1",
+		Endpoints: []discovery.Endpoint{{Address: "0.0.0.0", Port: 9200, Protocol: "tcp"}},
+		Additional: map[string]interface{}{
+			discovery.VersionProbeKey: &discovery.ProbeInfo{Address: "127.0.0.1:9200", Scheme: "http"},
+		},
+	})
+	if want := []string{"http://localhost:9200"}; !reflect.DeepEqual(receiver["endpoints"], want) {
+		t.Errorf("Expected %v for a node probed over http, got %v", want, receiver["endpoints"])
+	}
+	if _, ok := receiver["tls"]; ok {
+		t.Errorf("Expected no TLS settings for a node probed over http, got %v", receiver["tls"])
+	}
+}
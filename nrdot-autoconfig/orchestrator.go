@@ -2,6 +2,7 @@ package autoconfig
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -9,12 +10,17 @@ import (
 	"sync"
 	"time"
 
-	"github.com/newrelic/nrdot-host/nrdot-common/config"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/config"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
 	"github.com/newrelic/nrdot-host/nrdot-discovery"
 	"github.com/newrelic/nrdot-host/nrdot-supervisor"
 	"go.uber.org/zap"
 )
 
+// defaultScanInterval is the time between discovery scans when the
+// configuration leaves it unset
+const defaultScanInterval = 5 * time.Minute
+
 // AutoConfigOrchestrator manages the auto-configuration lifecycle
 type AutoConfigOrchestrator struct {
 	logger             *zap.Logger
@@ -26,8 +32,19 @@ type AutoConfigOrchestrator struct {
 	cache              *ConfigCache
 	supervisor         *supervisor.UnifiedSupervisor
 	configPath         string
+	overridesPath      string          // operator changes to generated configs
+	appliedOverrides   string          // hash of the overrides of the applied config
+	excludedServices   []string        // receivers the overrides left out of the last scan
+	policiesPath       string          // guardrails generated configs are checked against
+	appliedPolicies    string          // hash of the policies of the applied config
+	policyViolations   []PolicyViolation // of the last generated config
+	reconciler         *serviceReconciler // services of the applied config against those discovered
 	lastDiscovery      []discovery.ServiceInfo
+	lastScan           time.Time
 	lastConfigVersion  string
+	secretsFromFile    map[string]bool // variables exported from secrets.env
+	actionsNeeded      []ActionNeeded  // receivers left out of the last config for their credentials
+	suggestions        []Suggestion    // receivers left out of the last config for their LOW confidence
 	mu                 sync.RWMutex
 	stopCh             chan struct{}
 }
@@ -36,16 +53,65 @@ type AutoConfigOrchestrator struct {
 func NewAutoConfigOrchestrator(logger *zap.Logger, cfg *config.Config, supervisor *supervisor.UnifiedSupervisor) *AutoConfigOrchestrator {
 	hostID := getHostID()
 	
+	// Persist discovery results so service changes across reboots are reported
+	serviceDiscovery := discovery.NewServiceDiscovery(logger)
+	serviceDiscovery.SetWorkDir(cfg.DataDir)
+	serviceDiscovery.SetEventHandler(func(event models.Event) {
+		logger.Info(event.Summary,
+			zap.String("event_type", string(event.Type)),
+			zap.String("details", event.Details))
+	})
+
+	// On a Kubernetes node, discover the workloads of the node's pods and
+	// collect kubelet stats
+	generator := NewConfigGenerator(logger)
+	if cfg.Kubernetes.Enabled {
+		kubelet := discovery.KubeletConfig{
+			Endpoint:           cfg.Kubernetes.KubeletEndpoint,
+			InsecureSkipVerify: cfg.Kubernetes.InsecureSkipVerify,
+		}
+		serviceDiscovery.SetKubernetes(kubelet)
+		generator.SetKubernetes(kubelet)
+	}
+
+	// On edge deployments, also report the printers, NAS devices and other
+	// devices announced on the local network. Off by default, since it
+	// sends multicast queries to the whole segment.
+	if lan := cfg.AutoConfig.LANDiscovery; lan.Enabled {
+		serviceDiscovery.SetLAN(discovery.LANConfig{
+			Timeout:      lan.Timeout,
+			ServiceTypes: lan.ServiceTypes,
+			SSDP:         lan.SSDP,
+		})
+	}
+
+	// Ask discovered services for their versions. Off by default, since it
+	// connects to every discovered endpoint.
+	if probes := cfg.AutoConfig.VersionProbes; probes.Enabled {
+		serviceDiscovery.SetVersionProbes(discovery.VersionProbeConfig{
+			Timeout: probes.Timeout,
+		})
+	}
+
+	scanInterval := cfg.AutoConfig.ScanInterval
+	if scanInterval <= 0 {
+		scanInterval = defaultScanInterval
+	}
+	
 	return &AutoConfigOrchestrator{
 		logger:       logger,
 		enabled:      cfg.AutoConfig.Enabled,
-		scanInterval: cfg.AutoConfig.ScanInterval,
-		discovery:    discovery.NewServiceDiscovery(logger),
-		generator:    NewConfigGenerator(logger),
+		scanInterval: scanInterval,
+		discovery:    serviceDiscovery,
+		generator:    generator,
+		reconciler:   newServiceReconciler(cfg.AutoConfig.AddAfterScans, cfg.AutoConfig.RemoveAfterScans),
 		remoteClient: NewRemoteConfigClient(logger, cfg.LicenseKey, hostID),
 		cache:        NewConfigCache(logger, filepath.Join(cfg.DataDir, "config_cache.json")),
 		supervisor:   supervisor,
 		configPath:   cfg.ConfigPath,
+		overridesPath: filepath.Join(filepath.Dir(cfg.ConfigPath), overridesFileName),
+		policiesPath:  filepath.Join(filepath.Dir(cfg.ConfigPath), policiesFileName),
+		secretsFromFile: make(map[string]bool),
 		stopCh:       make(chan struct{}),
 	}
 }
@@ -58,7 +124,9 @@ func (aco *AutoConfigOrchestrator) Start(ctx context.Context) error {
 	}
 
 	aco.logger.Info("Starting auto-configuration orchestrator",
-		zap.Duration("scan_interval", aco.scanInterval))
+		zap.Duration("scan_interval", aco.scanInterval),
+		zap.Int("add_after_scans", aco.reconciler.addAfter),
+		zap.Int("remove_after_scans", aco.reconciler.removeAfter))
 
 	// Initial scan
 	if err := aco.runDiscoveryAndConfig(ctx); err != nil {
@@ -98,7 +166,9 @@ func (aco *AutoConfigOrchestrator) runPeriodic(ctx context.Context) {
 	}
 }
 
-// runDiscoveryAndConfig performs discovery and configuration update
+// runDiscoveryAndConfig runs a discovery scan, and regenerates the config
+// and reloads the collector when the services have drifted from those of
+// the applied config
 func (aco *AutoConfigOrchestrator) runDiscoveryAndConfig(ctx context.Context) error {
 	startTime := time.Now()
 
@@ -113,17 +183,62 @@ func (aco *AutoConfigOrchestrator) runDiscoveryAndConfig(ctx context.Context) er
 		zap.Int("services_found", len(services)),
 		zap.Duration("duration", duration))
 
-	// Check if services changed
-	if !aco.servicesChanged(services) {
-		aco.logger.Debug("No service changes detected")
-		return nil
+	// A broken overrides file keeps the applied config until it is fixed
+	overrides, err := LoadOverrides(aco.overridesPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", aco.overridesPath, err)
 	}
+	// Devices of the local network are reported, not collected
+	included, excluded := overrides.filter(hostServices(services))
+	aco.generator.SetOverrides(overrides)
 
-	// Update last discovery
+	// A broken policies file keeps it too, rather than applying unchecked configs
+	policies, err := LoadPolicies(aco.policiesPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", aco.policiesPath, err)
+	}
+	aco.generator.SetPolicies(policies)
+
+	// Compare the services with those of the applied config; while
+	// receivers wait for credentials, the config is regenerated to pick
+	// them up once fixed. Changed overrides and policies apply at once.
 	aco.mu.Lock()
 	aco.lastDiscovery = services
+	aco.lastScan = time.Now()
+	aco.excludedServices = excluded
+	aco.reconciler.pinned = overrides.Pinned
+	desired, drift := aco.reconciler.observe(included)
+	desired, _ = overrides.filter(desired)
+	pendingChanges := aco.reconciler.pending()
+	pendingActions := len(aco.actionsNeeded)
+	overridesChanged := overrides.Hash() != aco.appliedOverrides
+	policiesChanged := policies.Hash() != aco.appliedPolicies
 	aco.mu.Unlock()
 
+	for _, change := range pendingChanges {
+		aco.logger.Debug("Service change pending",
+			zap.String("service", change.Service),
+			zap.String("receiver", change.Receiver),
+			zap.String("change", change.Change),
+			zap.Int("scans", change.Scans),
+			zap.Int("required", change.Required))
+	}
+	if drift.Empty() && pendingActions == 0 && !overridesChanged && !policiesChanged {
+		aco.logger.Debug("No service drift detected")
+		return nil
+	}
+	aco.logDrift(drift)
+	if overridesChanged {
+		aco.logger.Info("Auto-configuration overrides changed",
+			zap.String("path", aco.overridesPath),
+			zap.Strings("excluded", excluded))
+	}
+	if policiesChanged {
+		aco.logger.Info("Auto-configuration policies changed",
+			zap.String("path", aco.policiesPath),
+			zap.Int("policies", len(policies.Policies)))
+	}
+
 	// Send baseline to New Relic
 	if err := aco.remoteClient.SendBaseline(ctx, services); err != nil {
 		aco.logger.Warn("Failed to send baseline report", zap.Error(err))
@@ -132,45 +247,48 @@ func (aco *AutoConfigOrchestrator) runDiscoveryAndConfig(ctx context.Context) er
 
 	// Fetch remote configuration
 	remoteConfig, err := aco.remoteClient.FetchConfig(ctx)
-	if err != nil {
+	switch {
+	case err != nil:
 		aco.logger.Warn("Failed to fetch remote configuration", zap.Error(err))
 		// Fall back to local generation
-		return aco.generateAndApplyLocal(ctx, services)
+		err = aco.generateAndApplyLocal(ctx, desired)
+	case remoteConfig == nil:
+		// The remote configuration has not changed, the services have
+		err = aco.generateAndApplyLocal(ctx, desired)
+	default:
+		err = aco.applyRemoteConfig(ctx, remoteConfig, desired)
 	}
-
-	if remoteConfig == nil {
-		// No new configuration
-		return nil
+	if err != nil {
+		// The drift is reported again on the next scan
+		return err
 	}
 
-	// Apply remote configuration
-	return aco.applyRemoteConfig(ctx, remoteConfig, services)
+	aco.mu.Lock()
+	aco.reconciler.commit(desired)
+	aco.appliedOverrides = overrides.Hash()
+	aco.appliedPolicies = policies.Hash()
+	aco.mu.Unlock()
+	return nil
 }
 
-// servicesChanged checks if discovered services have changed
-func (aco *AutoConfigOrchestrator) servicesChanged(services []discovery.ServiceInfo) bool {
-	aco.mu.RLock()
-	defer aco.mu.RUnlock()
-
-	if len(services) != len(aco.lastDiscovery) {
-		return true
-	}
-
-	// Create maps for comparison
-	oldMap := make(map[string]bool)
-	for _, svc := range aco.lastDiscovery {
-		key := fmt.Sprintf("%s:%v", svc.Type, svc.Endpoints)
-		oldMap[key] = true
-	}
-
-	for _, svc := range services {
-		key := fmt.Sprintf("%s:%v", svc.Type, svc.Endpoints)
-		if !oldMap[key] {
-			return true
+// logDrift logs the services a new config adds, moves and removes
+func (aco *AutoConfigOrchestrator) logDrift(drift ServiceDrift) {
+	log := func(change string, services []discovery.ServiceInfo) {
+		for _, svc := range services {
+			endpoint := ""
+			if len(svc.Endpoints) > 0 {
+				endpoint = endpointAddress(svc.Endpoints[0])
+			}
+			aco.logger.Info("Service drift detected",
+				zap.String("service", svc.Type),
+				zap.String("receiver", receiverName(svc)),
+				zap.String("endpoint", endpoint),
+				zap.String("change", change))
 		}
 	}
-
-	return false
+	log(ServiceAdded, drift.Added)
+	log(ServiceChanged, drift.Changed)
+	log(ServiceRemoved, drift.Removed)
 }
 
 // generateAndApplyLocal generates and applies configuration locally
@@ -178,19 +296,11 @@ func (aco *AutoConfigOrchestrator) generateAndApplyLocal(ctx context.Context, se
 	aco.logger.Info("Generating configuration locally")
 
 	// Generate configuration
-	generatedConfig, err := aco.generator.GenerateConfig(ctx, services)
+	generatedConfig, err := aco.generate(ctx, services)
 	if err != nil {
 		return fmt.Errorf("failed to generate configuration: %w", err)
 	}
 
-	// Check for required environment variables
-	missing := aco.checkRequiredVariables(generatedConfig.RequiredVariables)
-	if len(missing) > 0 {
-		aco.logger.Warn("Missing required environment variables",
-			zap.Strings("variables", missing))
-		// Could provide instructions or partial config
-	}
-
 	// Apply configuration
 	return aco.applyGeneratedConfig(ctx, generatedConfig)
 }
@@ -219,20 +329,70 @@ func (aco *AutoConfigOrchestrator) applyRemoteConfig(ctx context.Context, remote
 func (aco *AutoConfigOrchestrator) convertRemoteConfig(remote *RemoteConfig, services []discovery.ServiceInfo) (*GeneratedConfig, error) {
 	// Build configuration from remote integrations
 	// This is a simplified version - production would be more sophisticated
-	
-	config := &GeneratedConfig{
-		Version:            remote.Version,
-		DiscoveredServices: services,
-		GeneratedAt:        time.Now(),
-	}
 
 	// TODO: Convert remote.Integrations to YAML config
 	// For now, generate locally
-	return aco.generator.GenerateConfig(context.Background(), services)
+	return aco.generate(context.Background(), services)
+}
+
+// generate generates a configuration for services, testing their
+// credentials from the environment and the secrets env file next to the
+// config, and records the receivers left out for them
+func (aco *AutoConfigOrchestrator) generate(ctx context.Context, services []discovery.ServiceInfo) (*GeneratedConfig, error) {
+	envPath := filepath.Join(filepath.Dir(aco.configPath), secretsEnvFileName)
+	if err := aco.loadSecretsEnv(envPath); err != nil {
+		return nil, err
+	}
+
+	config, err := aco.generator.GenerateConfig(ctx, services)
+	var policyErr *PolicyError
+	if errors.As(err, &policyErr) {
+		// The running config is kept; report why
+		aco.mu.Lock()
+		aco.policyViolations = policyErr.Violations
+		aco.mu.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	aco.mu.Lock()
+	aco.actionsNeeded = config.ActionsNeeded
+	aco.suggestions = config.Suggestions
+	aco.policyViolations = config.PolicyViolations
+	aco.mu.Unlock()
+	return config, nil
+}
+
+// GetActionsNeeded returns the receivers left out of the last generated
+// config until their credentials are provided or fixed
+func (aco *AutoConfigOrchestrator) GetActionsNeeded() []ActionNeeded {
+	aco.mu.RLock()
+	defer aco.mu.RUnlock()
+	return append([]ActionNeeded(nil), aco.actionsNeeded...)
+}
+
+// GetSuggestions returns the receivers left out of the last generated
+// config for the LOW confidence of their services
+func (aco *AutoConfigOrchestrator) GetSuggestions() []Suggestion {
+	aco.mu.RLock()
+	defer aco.mu.RUnlock()
+	return append([]Suggestion(nil), aco.suggestions...)
 }
 
 // applyGeneratedConfig applies the generated configuration
 func (aco *AutoConfigOrchestrator) applyGeneratedConfig(ctx context.Context, config *GeneratedConfig) error {
+	// List the credentials the config needs, whether or not they are set
+	examplePath := filepath.Join(filepath.Dir(aco.configPath), secretsEnvExampleFileName)
+	if err := WriteSecretsEnvExample(examplePath, config.Variables); err != nil {
+		aco.logger.Warn("Failed to write secrets env example", zap.Error(err))
+	}
+
+	// A config with missing credentials would only fail in the collector
+	if err := aco.preflight(config, examplePath); err != nil {
+		return err
+	}
+
 	// Write to temporary file
 	tempFile := filepath.Join(filepath.Dir(aco.configPath), fmt.Sprintf(".config-%s.yaml", config.Version))
 	
@@ -246,15 +406,20 @@ func (aco *AutoConfigOrchestrator) applyGeneratedConfig(ctx context.Context, con
 		return fmt.Errorf("configuration validation failed: %w", err)
 	}
 
-	// Use supervisor's blue-green reload
-	if err := aco.supervisor.ReloadConfig(tempFile); err != nil {
+	// Move the config into place for the supervisor's blue-green reload,
+	// putting the previous one back if it is rejected
+	previous, readErr := ioutil.ReadFile(aco.configPath)
+	if err := os.Rename(tempFile, aco.configPath); err != nil {
 		os.Remove(tempFile)
-		return fmt.Errorf("failed to reload configuration: %w", err)
+		return fmt.Errorf("failed to write config file: %w", err)
 	}
-
-	// Success - move temp file to actual config
-	if err := os.Rename(tempFile, aco.configPath); err != nil {
-		aco.logger.Warn("Failed to move config file", zap.Error(err))
+	if err := aco.supervisor.ReloadConfigFile(ctx, supervisor.ConfigReloadTriggerAutoConfig); err != nil {
+		if readErr == nil {
+			if werr := ioutil.WriteFile(aco.configPath, previous, 0644); werr != nil {
+				aco.logger.Warn("Failed to restore previous config file", zap.Error(werr))
+			}
+		}
+		return fmt.Errorf("failed to reload configuration: %w", err)
 	}
 
 	// Update version
@@ -276,15 +441,57 @@ func (aco *AutoConfigOrchestrator) validateConfigFile(path string) error {
 	return nil
 }
 
-// checkRequiredVariables checks if required environment variables are set
-func (aco *AutoConfigOrchestrator) checkRequiredVariables(required []string) []string {
-	var missing []string
-	for _, v := range required {
-		if os.Getenv(v) == "" {
-			missing = append(missing, v)
+// loadSecretsEnv exports the variables of the secrets env file next to the
+// config that the environment leaves unset, so collectors started by the
+// supervisor inherit them. Variables exported from an earlier version of the
+// file follow its changes.
+func (aco *AutoConfigOrchestrator) loadSecretsEnv(envPath string) error {
+	secrets, err := LoadEnvFile(envPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", envPath, err)
+	}
+
+	aco.mu.Lock()
+	defer aco.mu.Unlock()
+	for name := range aco.secretsFromFile {
+		if _, ok := secrets[name]; !ok {
+			os.Unsetenv(name)
+			delete(aco.secretsFromFile, name)
+		}
+	}
+	for name, value := range secrets {
+		if os.Getenv(name) == "" || aco.secretsFromFile[name] {
+			os.Setenv(name, value)
+			aco.secretsFromFile[name] = true
+		}
+	}
+	return nil
+}
+
+// preflight checks that the variables referenced by the config resolve,
+// from the environment or the secrets env file next to the config
+func (aco *AutoConfigOrchestrator) preflight(config *GeneratedConfig, examplePath string) error {
+	envPath := filepath.Join(filepath.Dir(aco.configPath), secretsEnvFileName)
+	if err := aco.loadSecretsEnv(envPath); err != nil {
+		return err
+	}
+
+	perr, warnings := preflightIssues(LintPlaceholders(config.Config, config.Variables, os.LookupEnv), envPath, examplePath)
+	for _, w := range warnings {
+		aco.logger.Warn("Config placeholder does not resolve",
+			zap.String("variable", w.Variable),
+			zap.String("message", w.Message))
+	}
+	if perr != nil {
+		for _, issue := range perr.Issues {
+			aco.logger.Error("Missing required variable",
+				zap.String("variable", issue.Variable),
+				zap.String("service", issue.Service),
+				zap.String("endpoint", issue.Endpoint))
 		}
+		return perr
 	}
-	return missing
+	return nil
 }
 
 // GetStatus returns the current auto-configuration status
@@ -296,22 +503,33 @@ func (aco *AutoConfigOrchestrator) GetStatus() AutoConfigStatus {
 		Enabled:           aco.enabled,
 		LastScan:          aco.getLastScanTime(),
 		NextScan:          aco.getNextScanTime(),
+		PendingServices:   aco.reconciler.pending(),
+		ExcludedServices:  append([]string(nil), aco.excludedServices...),
+		LANDevices:        len(aco.lastDiscovery) - len(hostServices(aco.lastDiscovery)),
 		ActiveServices:    aco.getActiveServices(),
 		ConfigVersion:     aco.lastConfigVersion,
 		DiscoveredServices: len(aco.lastDiscovery),
+		ActionsNeeded:      append([]ActionNeeded(nil), aco.actionsNeeded...),
+		PolicyViolations:   append([]PolicyViolation(nil), aco.policyViolations...),
+		Suggestions:        append([]Suggestion(nil), aco.suggestions...),
 	}
 }
 
-// getLastScanTime returns the last scan time
+// getLastScanTime returns the last scan time, nil before the first scan
 func (aco *AutoConfigOrchestrator) getLastScanTime() *time.Time {
-	// This would be tracked properly in production
-	t := time.Now().Add(-time.Minute)
+	if aco.lastScan.IsZero() {
+		return nil
+	}
+	t := aco.lastScan
 	return &t
 }
 
-// getNextScanTime returns the next scan time
+// getNextScanTime returns the next scan time, nil before the first scan
 func (aco *AutoConfigOrchestrator) getNextScanTime() *time.Time {
-	t := time.Now().Add(aco.scanInterval)
+	if aco.lastScan.IsZero() {
+		return nil
+	}
+	t := aco.lastScan.Add(aco.scanInterval)
 	return &t
 }
 
@@ -320,7 +538,7 @@ func (aco *AutoConfigOrchestrator) getActiveServices() []string {
 	seen := make(map[string]bool)
 	var services []string
 	
-	for _, svc := range aco.lastDiscovery {
+	for _, svc := range hostServices(aco.lastDiscovery) {
 		if !seen[svc.Type] {
 			seen[svc.Type] = true
 			services = append(services, svc.Type)
@@ -338,6 +556,34 @@ type AutoConfigStatus struct {
 	ActiveServices     []string   `json:"active_services"`
 	ConfigVersion      string     `json:"config_version"`
 	DiscoveredServices int        `json:"discovered_services"`
+	ActionsNeeded      []ActionNeeded `json:"actions_needed,omitempty"`
+	// PendingServices are service changes waiting out the hysteresis
+	// before the config is regenerated
+	PendingServices    []PendingService `json:"pending_services,omitempty"`
+	// ExcludedServices are the receivers of discovered services the
+	// overrides leave out
+	ExcludedServices   []string `json:"excluded_services,omitempty"`
+	// LANDevices are the services found on other devices of the local
+	// network, see discovery.SetLAN
+	LANDevices         int `json:"lan_devices,omitempty"`
+	// PolicyViolations are the policies the last generated config
+	// violates; with a blocking one among them, it was not applied
+	PolicyViolations   []PolicyViolation `json:"policy_violations,omitempty"`
+	// Suggestions are the receivers of services found with LOW
+	// confidence, left out of the config until pinned
+	Suggestions        []Suggestion `json:"suggestions,omitempty"`
+}
+
+// hostServices returns the services of this host, leaving out those of
+// other devices of the local network
+func hostServices(services []discovery.ServiceInfo) []discovery.ServiceInfo {
+	local := make([]discovery.ServiceInfo, 0, len(services))
+	for _, svc := range services {
+		if svc.LAN() == nil {
+			local = append(local, svc)
+		}
+	}
+	return local
 }
 
 // getHostID generates or retrieves a persistent host ID
@@ -0,0 +1,81 @@
+package autoconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/config"
+	"github.com/newrelic/nrdot-host/nrdot-discovery"
+	"go.uber.org/zap"
+)
+
+// loadConfig writes content as the configuration file and loads the agent's
+// settings from it
+func loadConfig(t *testing.T, content string) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.DataDir = dir
+	return cfg
+}
+
+func TestNewAutoConfigOrchestrator_Settings(t *testing.T) {
+	cfg := loadConfig(t, `
+service:
+  name: node-01
+auto_config:
+  enabled: true
+  scan_interval: 2m
+  add_after_scans: 1
+  remove_after_scans: 5
+kubernetes:
+  enabled: true
+  kubelet_endpoint: https://node-01:10250
+  insecure_skip_verify: true
+`)
+
+	aco := NewAutoConfigOrchestrator(zap.NewNop(), cfg, nil)
+	if !aco.enabled {
+		t.Error("Expected auto-configuration to be enabled")
+	}
+	if aco.scanInterval != 2*time.Minute {
+		t.Errorf("Expected scan interval 2m, got %v", aco.scanInterval)
+	}
+	if aco.reconciler.addAfter != 1 || aco.reconciler.removeAfter != 5 {
+		t.Errorf("Expected add after 1 and remove after 5 scans, got %d and %d",
+			aco.reconciler.addAfter, aco.reconciler.removeAfter)
+	}
+	if aco.configPath != cfg.ConfigPath {
+		t.Errorf("Expected config path %s, got %s", cfg.ConfigPath, aco.configPath)
+	}
+
+	want := discovery.KubeletConfig{Endpoint: "https://node-01:10250", InsecureSkipVerify: true}
+	if aco.generator.kubelet == nil || *aco.generator.kubelet != want {
+		t.Errorf("Expected kubelet %+v, got %+v", want, aco.generator.kubelet)
+	}
+}
+
+func TestNewAutoConfigOrchestrator_Defaults(t *testing.T) {
+	aco := NewAutoConfigOrchestrator(zap.NewNop(), loadConfig(t, "service:\n  name: node-01\n"), nil)
+	if aco.enabled {
+		t.Error("Expected auto-configuration to be disabled")
+	}
+	if aco.scanInterval != defaultScanInterval {
+		t.Errorf("Expected scan interval %v, got %v", defaultScanInterval, aco.scanInterval)
+	}
+	if aco.reconciler.addAfter != defaultAddAfterScans || aco.reconciler.removeAfter != defaultRemoveAfterScans {
+		t.Errorf("Expected default scan counts, got %d and %d", aco.reconciler.addAfter, aco.reconciler.removeAfter)
+	}
+	if aco.generator.kubelet != nil {
+		t.Errorf("Expected no kubelet without kubernetes enabled, got %+v", aco.generator.kubelet)
+	}
+}
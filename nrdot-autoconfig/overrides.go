@@ -0,0 +1,173 @@
+package autoconfig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/newrelic/nrdot-host/nrdot-discovery"
+	"gopkg.in/yaml.v3"
+)
+
+// overridesFileName holds the operator's changes to generated configs next
+// to the config, e.g. /etc/nrdot/autoconfig-overrides.yaml
+const overridesFileName = "autoconfig-overrides.yaml"
+
+// Overrides are the operator's changes to generated configs, read from the
+// overrides file on every scan:
+//
+//	config:
+//	  receivers:
+//	    mysql:
+//	      collection_interval: 60s
+//	services:
+//	  pin: [mysql]
+//	  exclude: [redis, "mysql/*"]
+//
+// Config is deep-merged into every generated config: mappings are merged
+// key by key, other values replace the generated ones, and null removes
+// them. Services are matched by receiver name or service type, with
+// path.Match patterns.
+type Overrides struct {
+	Config   map[string]interface{} `yaml:"config,omitempty"`
+	Services ServiceOverrides       `yaml:"services,omitempty"`
+
+	// path and hash of the file read, empty without one
+	path string
+	hash string
+}
+
+// ServiceOverrides pins discovered services into the config or excludes
+// them from it
+type ServiceOverrides struct {
+	// Pin keeps the receivers of services once configured, however long
+	// discovery misses them, and adds them without waiting for the scans
+	// new services need
+	Pin []string `yaml:"pin,omitempty"`
+	// Exclude never configures services, whether discovered or pinned
+	Exclude []string `yaml:"exclude,omitempty"`
+}
+
+// LoadOverrides reads overrides from file. Without the file there are no
+// overrides.
+func LoadOverrides(file string) (*Overrides, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Overrides{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := &Overrides{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(overrides); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid overrides: %w", err)
+	}
+	for _, patterns := range [][]string{overrides.Services.Pin, overrides.Services.Exclude} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid service pattern %q: %w", pattern, err)
+			}
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	overrides.path = file
+	overrides.hash = hex.EncodeToString(sum[:])
+	return overrides, nil
+}
+
+// Path returns the overrides file read, empty when there was none
+func (o *Overrides) Path() string {
+	if o == nil {
+		return ""
+	}
+	return o.path
+}
+
+// Hash identifies the content of the overrides file, empty without one
+func (o *Overrides) Hash() string {
+	if o == nil {
+		return ""
+	}
+	return o.hash
+}
+
+// Pinned reports whether svc is pinned into the config
+func (o *Overrides) Pinned(svc discovery.ServiceInfo) bool {
+	return o != nil && matchService(o.Services.Pin, svc) && !o.Excluded(svc)
+}
+
+// Excluded reports whether svc is excluded from the config
+func (o *Overrides) Excluded(svc discovery.ServiceInfo) bool {
+	return o != nil && matchService(o.Services.Exclude, svc)
+}
+
+// filter returns the services not excluded, and the receivers of those
+// excluded
+func (o *Overrides) filter(services []discovery.ServiceInfo) ([]discovery.ServiceInfo, []string) {
+	if o == nil || len(o.Services.Exclude) == 0 {
+		return services, nil
+	}
+	kept := make([]discovery.ServiceInfo, 0, len(services))
+	var excluded []string
+	for _, svc := range services {
+		if o.Excluded(svc) {
+			excluded = append(excluded, receiverName(svc))
+			continue
+		}
+		kept = append(kept, svc)
+	}
+	return kept, excluded
+}
+
+// apply deep-merges the config overrides into a generated config
+func (o *Overrides) apply(config map[string]interface{}) {
+	if o != nil {
+		mergeOverrides(config, o.Config)
+	}
+}
+
+// matchService reports whether a pattern matches the receiver name or the
+// type of svc
+func matchService(patterns []string, svc discovery.ServiceInfo) bool {
+	name := receiverName(svc)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, svc.Type); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeOverrides merges src into dst: mappings in both are merged, a null
+// removes the key and other values replace dst's
+func mergeOverrides(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if value == nil {
+			delete(dst, key)
+			continue
+		}
+		override, ok := value.(map[string]interface{})
+		if !ok {
+			dst[key] = value
+			continue
+		}
+		existing, ok := dst[key].(map[string]interface{})
+		if !ok {
+			existing = make(map[string]interface{}, len(override))
+			dst[key] = existing
+		}
+		mergeOverrides(existing, override)
+	}
+}
@@ -0,0 +1,219 @@
+package autoconfig
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-discovery"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// writeOverrides writes content as the overrides file and loads it
+func writeOverrides(t *testing.T, content string) (*Overrides, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), overridesFileName)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write overrides: %v", err)
+	}
+	return LoadOverrides(path)
+}
+
+func TestMergeOverrides(t *testing.T) {
+	tests := []struct {
+		name      string
+		generated string
+		overrides string
+		want      string
+	}{
+		{
+			name:      "nested mappings are merged",
+			generated: "receivers: {mysql: {endpoint: 'localhost:3306', collection_interval: 30s, metrics: {mysql.locks: {enabled: false}}}}",
+			overrides: "receivers: {mysql: {collection_interval: 60s, metrics: {mysql.locks: {enabled: true}, mysql.joins: {enabled: true}}}}",
+			want:      "receivers: {mysql: {endpoint: 'localhost:3306', collection_interval: 60s, metrics: {mysql.locks: {enabled: true}, mysql.joins: {enabled: true}}}}",
+		},
+		{
+			name:      "lists are replaced",
+			generated: "service: {pipelines: {metrics: {receivers: [hostmetrics, mysql], exporters: [otlp]}}}",
+			overrides: "service: {pipelines: {metrics: {receivers: [hostmetrics]}}}",
+			want:      "service: {pipelines: {metrics: {receivers: [hostmetrics], exporters: [otlp]}}}",
+		},
+		{
+			name:      "null removes",
+			generated: "receivers: {mysql: {endpoint: 'localhost:3306', tls: {insecure: true}}, redis: {endpoint: 'localhost:6379'}}",
+			overrides: "receivers: {mysql: {tls: null}, redis: null, missing: null}",
+			want:      "receivers: {mysql: {endpoint: 'localhost:3306'}}",
+		},
+		{
+			name:      "scalars and mappings replace each other",
+			generated: "exporters: {otlp: {endpoint: 'otlp.nr-data.net:4317', headers: 'none'}}",
+			overrides: "exporters: {otlp: {endpoint: {host: eu}, headers: {api-key: '${NEW_RELIC_LICENSE_KEY}'}}}",
+			want:      "exporters: {otlp: {endpoint: {host: eu}, headers: {api-key: '${NEW_RELIC_LICENSE_KEY}'}}}",
+		},
+		{
+			name:      "new sections are added",
+			generated: "receivers: {hostmetrics: {}}",
+			overrides: "extensions: {health_check: {endpoint: '0.0.0.0:13133'}}",
+			want:      "receivers: {hostmetrics: {}}\nextensions: {health_check: {endpoint: '0.0.0.0:13133'}}",
+		},
+	}
+
+	parse := func(t *testing.T, content string) map[string]interface{} {
+		t.Helper()
+		parsed := make(map[string]interface{})
+		if err := yaml.Unmarshal([]byte(content), &parsed); err != nil {
+			t.Fatalf("Failed to parse %q: %v", content, err)
+		}
+		return parsed
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := parse(t, tt.generated)
+			mergeOverrides(config, parse(t, tt.overrides))
+			if want := parse(t, tt.want); !reflect.DeepEqual(config, want) {
+				t.Errorf("Expected\n%v\ngot\n%v", want, config)
+			}
+		})
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	overrides, err := LoadOverrides(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil || overrides.Path() != "" || overrides.Hash() != "" || overrides.Config != nil {
+		t.Errorf("Expected no overrides without the file, got %+v, %v", overrides, err)
+	}
+
+	overrides, err = writeOverrides(t, `
+config:
+  receivers:
+    mysql:
+      collection_interval: 60s
+services:
+  pin: [mysql]
+  exclude: [redis, "mysql/*"]
+`)
+	if err != nil {
+		t.Fatalf("Failed to load overrides: %v", err)
+	}
+	if !reflect.DeepEqual(overrides.Services, ServiceOverrides{Pin: []string{"mysql"}, Exclude: []string{"redis", "mysql/*"}}) {
+		t.Errorf("Unexpected services %+v", overrides.Services)
+	}
+	if len(overrides.Hash()) != 64 || !strings.HasSuffix(overrides.Path(), overridesFileName) {
+		t.Errorf("Expected the path and hash of the file, got %q and %q", overrides.Path(), overrides.Hash())
+	}
+
+	for name, content := range map[string]string{
+		"malformed YAML":  "config: [unterminated\n",
+		"unknown field":   "services:\n  include: [mysql]\n",
+		"invalid pattern": "services:\n  exclude: ['mysql[']\n",
+	} {
+		if _, err := writeOverrides(t, content); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestOverrides_Services(t *testing.T) {
+	overrides, err := writeOverrides(t, `
+services:
+  pin: [mysql, "redis/*", jmx/tomcat]
+  exclude: ["redis/cache-*", memcached]
+`)
+	if err != nil {
+		t.Fatalf("Failed to load overrides: %v", err)
+	}
+
+	inContainer := func(svc discovery.ServiceInfo, name string) discovery.ServiceInfo {
+		return withAdditional(svc, discovery.ContainerKey, &discovery.ContainerInfo{Name: name})
+	}
+	tests := []struct {
+		svc      discovery.ServiceInfo
+		pinned   bool
+		excluded bool
+	}{
+		{service("mysql", 3306), true, false},
+		{inContainer(service("mysql", 3306), "orders-db"), true, false},
+		{inContainer(service("redis", 6379), "sessions"), true, false},
+		// Exclusion wins over pinning
+		{inContainer(service("redis", 6379), "cache-1"), false, true},
+		{service("redis", 6379), false, false},
+		{service("tomcat", 8005), true, false},
+		{service("memcached", 11211), false, true},
+	}
+	for _, tt := range tests {
+		name := receiverName(tt.svc)
+		if got := overrides.Pinned(tt.svc); got != tt.pinned {
+			t.Errorf("%s: expected pinned %v, got %v", name, tt.pinned, got)
+		}
+		if got := overrides.Excluded(tt.svc); got != tt.excluded {
+			t.Errorf("%s: expected excluded %v, got %v", name, tt.excluded, got)
+		}
+	}
+
+	services := make([]discovery.ServiceInfo, 0, len(tests))
+	for _, tt := range tests {
+		services = append(services, tt.svc)
+	}
+	kept, excluded := overrides.filter(services)
+	if want := []string{"redis/cache-1", "memcached"}; !reflect.DeepEqual(excluded, want) {
+		t.Errorf("Expected %v excluded, got %v", want, excluded)
+	}
+	if len(kept) != len(services)-2 {
+		t.Errorf("Expected %d services kept, got %d", len(services)-2, len(kept))
+	}
+
+	var none *Overrides
+	if kept, excluded := none.filter(services); len(kept) != len(services) || excluded != nil || none.Pinned(services[0]) {
+		t.Error("Expected no overrides to keep every service")
+	}
+}
+
+func TestGenerateConfig_Overrides(t *testing.T) {
+	overrides, err := writeOverrides(t, `
+config:
+  receivers:
+    nginx:
+      collection_interval: 60s
+  exporters:
+    debug: null
+services:
+  exclude: [memcached]
+`)
+	if err != nil {
+		t.Fatalf("Failed to load overrides: %v", err)
+	}
+
+	generator := NewConfigGenerator(zap.NewNop())
+	generator.SetOverrides(overrides)
+	generator.lookupEnv = func(string) (string, bool) { return "", false }
+
+	// Excluded services get no receiver even though discovery found them
+	generated, err := generator.GenerateConfig(context.Background(), []discovery.ServiceInfo{
+		service("nginx", 80),
+		service("memcached", 11211),
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate config: %v", err)
+	}
+
+	var config map[string]interface{}
+	if err := yaml.Unmarshal([]byte(generated.Config), &config); err != nil {
+		t.Fatalf("Failed to parse generated config: %v", err)
+	}
+	receivers := config["receivers"].(map[string]interface{})
+	if _, ok := receivers["memcached"]; ok {
+		t.Error("Expected the excluded memcached to get no receiver")
+	}
+	nginx, _ := receivers["nginx"].(map[string]interface{})
+	if nginx["collection_interval"] != "60s" || nginx["endpoint"] == nil {
+		t.Errorf("Expected the nginx receiver merged with the override, got %v", nginx)
+	}
+	if _, ok := config["exporters"].(map[string]interface{})["debug"]; ok {
+		t.Error("Expected the debug exporter to be removed")
+	}
+}
@@ -0,0 +1,317 @@
+package autoconfig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"gopkg.in/yaml.v3"
+)
+
+// policiesFileName holds the organization's guardrails for generated
+// configs next to the config, e.g. /etc/nrdot/autoconfig-policies.yaml
+const policiesFileName = "autoconfig-policies.yaml"
+
+// What a violated policy does
+const (
+	PolicyBlock = "block" // the config is not applied, the default
+	PolicyWarn  = "warn"  // the config is applied and the violation reported
+)
+
+// Policies are the guardrails every generated config is checked against
+// before it is applied, read from the policies file on every scan:
+//
+//	policies:
+//	  - name: no-debug-exporter
+//	    forbid: [exporters.debug, exporters.logging]
+//	  - name: receiver-budget
+//	    max: {receivers: 20}
+//	  - name: process-scraper-filters
+//	    action: warn
+//	    expr: receivers.hostmetrics?.scrapers?.process == nil || receivers.hostmetrics.scrapers.process.include != nil
+//
+// Policies are checked against the config after the overrides, so the
+// overrides cannot get around them either.
+type Policies struct {
+	Policies []Policy `yaml:"policies"`
+
+	// path and hash of the file read, empty without one
+	path string
+	hash string
+}
+
+// Policy is a guardrail made of simple rules, an expression or both, all
+// of which a config must satisfy
+type Policy struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	// Action is PolicyBlock or PolicyWarn
+	Action string `yaml:"action,omitempty"`
+	// Forbid are dotted paths that must not be in the config, matched
+	// segment by segment with path.Match patterns. A segment without a
+	// slash also matches the type of component IDs, so exporters.debug
+	// forbids exporters.debug/verbose too.
+	Forbid []string `yaml:"forbid,omitempty"`
+	// Max bounds the number of entries of the mappings or lists at dotted
+	// paths, e.g. receivers: 20
+	Max map[string]int `yaml:"max,omitempty"`
+	// Expr is a boolean github.com/expr-lang/expr expression that must be
+	// true, with the sections of the config as variables, e.g.
+	// len(receivers) <= 20
+	Expr string `yaml:"expr,omitempty"`
+
+	program *vm.Program
+}
+
+// PolicyViolation is a policy a generated config violates
+type PolicyViolation struct {
+	Policy  string `json:"policy"`
+	Action  string `json:"action"`
+	Message string `json:"message"`
+}
+
+// PolicyError reports a generated config violating blocking policies. It
+// lists every violation, warnings included.
+type PolicyError struct {
+	Violations []PolicyViolation
+}
+
+func (e *PolicyError) Error() string {
+	var blocking []string
+	for _, v := range e.Violations {
+		if v.Action == PolicyBlock {
+			blocking = append(blocking, v.Policy+": "+v.Message)
+		}
+	}
+	return fmt.Sprintf("config violates policies: %s", strings.Join(blocking, "; "))
+}
+
+// LoadPolicies reads policies from file. Without the file there are no
+// policies.
+func LoadPolicies(file string) (*Policies, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Policies{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	policies := &Policies{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(policies); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid policies: %w", err)
+	}
+
+	names := make(map[string]bool, len(policies.Policies))
+	for i := range policies.Policies {
+		policy := &policies.Policies[i]
+		if err := policy.compile(); err != nil {
+			return nil, fmt.Errorf("invalid policy %q: %w", policy.Name, err)
+		}
+		if names[policy.Name] {
+			return nil, fmt.Errorf("duplicate policy %q", policy.Name)
+		}
+		names[policy.Name] = true
+	}
+
+	sum := sha256.Sum256(data)
+	policies.path = file
+	policies.hash = hex.EncodeToString(sum[:])
+	return policies, nil
+}
+
+// compile checks a policy and compiles its expression
+func (p *Policy) compile() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	switch p.Action {
+	case "":
+		p.Action = PolicyBlock
+	case PolicyBlock, PolicyWarn:
+	default:
+		return fmt.Errorf("action must be %s or %s, got %q", PolicyBlock, PolicyWarn, p.Action)
+	}
+	if len(p.Forbid) == 0 && len(p.Max) == 0 && p.Expr == "" {
+		return errors.New("one of forbid, max or expr is required")
+	}
+
+	for _, pattern := range p.Forbid {
+		if err := checkPathPattern(pattern); err != nil {
+			return err
+		}
+	}
+	for pattern := range p.Max {
+		if err := checkPathPattern(pattern); err != nil {
+			return err
+		}
+	}
+
+	if p.Expr != "" {
+		// Sections a config leaves out are nil
+		program, err := expr.Compile(p.Expr, expr.AllowUndefinedVariables(), expr.AsBool())
+		if err != nil {
+			return fmt.Errorf("failed to compile expression: %w", err)
+		}
+		p.program = program
+	}
+	return nil
+}
+
+// checkPathPattern checks the segments of a dotted path pattern
+func checkPathPattern(pattern string) error {
+	for _, segment := range strings.Split(pattern, ".") {
+		if segment == "" {
+			return fmt.Errorf("invalid path %q: empty segment", pattern)
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid path %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Path returns the policies file read, empty when there was none
+func (p *Policies) Path() string {
+	if p == nil {
+		return ""
+	}
+	return p.path
+}
+
+// Hash identifies the content of the policies file, empty without one
+func (p *Policies) Hash() string {
+	if p == nil {
+		return ""
+	}
+	return p.hash
+}
+
+// Evaluate checks a config against the policies, returning the violations
+// in policy order
+func (p *Policies) Evaluate(config map[string]interface{}) []PolicyViolation {
+	if p == nil {
+		return nil
+	}
+
+	var violations []PolicyViolation
+	for _, policy := range p.Policies {
+		for _, message := range policy.evaluate(config) {
+			violations = append(violations, PolicyViolation{
+				Policy:  policy.Name,
+				Action:  policy.Action,
+				Message: message,
+			})
+		}
+	}
+	return violations
+}
+
+// evaluate returns how a config violates a policy
+func (p *Policy) evaluate(config map[string]interface{}) []string {
+	var messages []string
+	for _, pattern := range p.Forbid {
+		for _, match := range matchPath(config, strings.Split(pattern, "."), "") {
+			messages = append(messages, fmt.Sprintf("%s is forbidden", match.path))
+		}
+	}
+
+	patterns := make([]string, 0, len(p.Max))
+	for pattern := range p.Max {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		limit := p.Max[pattern]
+		for _, match := range matchPath(config, strings.Split(pattern, "."), "") {
+			if n := entries(match.node); n > limit {
+				messages = append(messages, fmt.Sprintf("%s has %d entries, at most %d allowed", match.path, n, limit))
+			}
+		}
+	}
+
+	if p.program != nil {
+		result, err := expr.Run(p.program, config)
+		switch {
+		case err != nil:
+			// An expression that cannot tell fails closed
+			messages = append(messages, fmt.Sprintf("expression failed: %v", err))
+		case result != true:
+			messages = append(messages, fmt.Sprintf("expression is false: %s", p.Expr))
+		}
+	}
+	return messages
+}
+
+// pathMatch is a node of a config at the dotted path matching a pattern
+type pathMatch struct {
+	path string
+	node interface{}
+}
+
+// matchPath returns the nodes under node matching the segments of a
+// pattern, in key order
+func matchPath(node interface{}, segments []string, prefix string) []pathMatch {
+	if len(segments) == 0 {
+		return []pathMatch{{path: prefix, node: node}}
+	}
+	mapping, ok := node.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	keys := make([]string, 0, len(mapping))
+	for key := range mapping {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var matches []pathMatch
+	for _, key := range keys {
+		if !matchSegment(segments[0], key) {
+			continue
+		}
+		next := key
+		if prefix != "" {
+			next = prefix + "." + key
+		}
+		matches = append(matches, matchPath(mapping[key], segments[1:], next)...)
+	}
+	return matches
+}
+
+// matchSegment reports whether a path segment pattern matches a key, or
+// the type of a component ID like debug/verbose
+func matchSegment(pattern, key string) bool {
+	if ok, _ := path.Match(pattern, key); ok {
+		return true
+	}
+	if componentType, _, found := strings.Cut(key, "/"); found && !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, componentType)
+		return ok
+	}
+	return false
+}
+
+// entries returns the number of entries of a mapping or list, 0 for other
+// values
+func entries(node interface{}) int {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		return len(node)
+	case []interface{}:
+		return len(node)
+	}
+	return 0
+}
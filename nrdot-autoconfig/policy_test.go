@@ -0,0 +1,241 @@
+package autoconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// writePolicies writes content as the policies file and loads it
+func writePolicies(t *testing.T, content string) (*Policies, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), policiesFileName)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write policies: %v", err)
+	}
+	return LoadPolicies(path)
+}
+
+// policyConfig is a generated config as policies see it
+const policyConfig = `
+receivers:
+  hostmetrics:
+    scrapers:
+      cpu: {}
+      process:
+        mute_process_name_error: true
+  mysql:
+    endpoint: localhost:3306
+  redis:
+    endpoint: localhost:6379
+exporters:
+  otlp:
+    endpoint: otlp.nr-data.net:4317
+  debug/verbose:
+    verbosity: detailed
+service:
+  pipelines:
+    metrics:
+      receivers: [hostmetrics, mysql, redis]
+      exporters: [otlp, debug/verbose]
+`
+
+func TestLoadPolicies(t *testing.T) {
+	policies, err := LoadPolicies(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil || len(policies.Policies) != 0 || policies.Path() != "" || policies.Hash() != "" {
+		t.Errorf("Expected no policies without the file, got %+v, %v", policies, err)
+	}
+
+	policies, err = writePolicies(t, `
+policies:
+  - name: no-debug-exporter
+    forbid: [exporters.debug]
+  - name: receiver-budget
+    action: warn
+    max: {receivers: 20}
+  - name: otlp-only
+    expr: all(service.pipelines.metrics.exporters, {# startsWith "otlp"})
+`)
+	if err != nil {
+		t.Fatalf("Failed to load policies: %v", err)
+	}
+	var actions []string
+	for _, policy := range policies.Policies {
+		actions = append(actions, policy.Action)
+	}
+	if want := []string{PolicyBlock, PolicyWarn, PolicyBlock}; !reflect.DeepEqual(actions, want) {
+		t.Errorf("Expected actions %v, got %v", want, actions)
+	}
+	if !strings.HasSuffix(policies.Path(), policiesFileName) || len(policies.Hash()) != 64 {
+		t.Errorf("Expected the path and hash of the file, got %q and %q", policies.Path(), policies.Hash())
+	}
+
+	// An empty file has no policies
+	policies, err = writePolicies(t, "")
+	if err != nil || len(policies.Policies) != 0 {
+		t.Errorf("Expected no policies from an empty file, got %+v, %v", policies, err)
+	}
+}
+
+func TestLoadPolicies_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"malformed YAML", "policies:\n  - name: [unterminated\n", "invalid policies"},
+		{"not a list", "policies: {name: budget}\n", "invalid policies"},
+		{"unknown field", "policies:\n  - name: budget\n    maximum: {receivers: 20}\n", "field maximum not found"},
+		{"missing name", "policies:\n  - forbid: [exporters.debug]\n", "name is required"},
+		{"unknown action", "policies:\n  - name: budget\n    action: deny\n    max: {receivers: 20}\n", "action must be"},
+		{"no rules", "policies:\n  - name: budget\n    description: nothing to check\n", "one of forbid, max or expr"},
+		{"duplicate name", "policies:\n  - name: budget\n    max: {receivers: 20}\n  - name: budget\n    max: {exporters: 2}\n", "duplicate policy"},
+		{"empty path segment", "policies:\n  - name: debug\n    forbid: [exporters..debug]\n", "empty segment"},
+		{"invalid pattern", "policies:\n  - name: debug\n    forbid: ['exporters.[debug']\n", "invalid path"},
+		{"expression syntax", "policies:\n  - name: budget\n    expr: len(receivers) <=\n", "failed to compile expression"},
+		{"expression not boolean", "policies:\n  - name: budget\n    expr: len(receivers)\n", "failed to compile expression"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := writePolicies(t, tt.content)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPolicies_Evaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		messages []string
+	}{
+		{
+			name:     "forbidden component type",
+			policy:   "forbid: [exporters.debug]",
+			messages: []string{"exporters.debug/verbose is forbidden"},
+		},
+		{
+			name:     "forbidden pattern",
+			policy:   "forbid: ['receivers.*sql', exporters.logging]",
+			messages: []string{"receivers.mysql is forbidden"},
+		},
+		{
+			name:   "forbidding a full ID",
+			policy: "forbid: [exporters.debug/basic]",
+		},
+		{
+			name:     "entries over the limit",
+			policy:   "max: {receivers: 2, exporters: 2, receivers.hostmetrics.scrapers: 1}",
+			messages: []string{"receivers has 3 entries, at most 2 allowed", "receivers.hostmetrics.scrapers has 2 entries, at most 1 allowed"},
+		},
+		{
+			name:   "expression true",
+			policy: "expr: len(receivers) <= 3 && service.pipelines.metrics.receivers[0] == 'hostmetrics'",
+		},
+		{
+			name:     "expression false",
+			policy:   `expr: all(service.pipelines.metrics.exporters, {# startsWith "otlp"})`,
+			messages: []string{`expression is false: all(service.pipelines.metrics.exporters, {# startsWith "otlp"})`},
+		},
+		{
+			name:   "section left out",
+			policy: "expr: extensions == nil",
+		},
+		{
+			name:     "expression failing at runtime",
+			policy:   "expr: receivers.hostmetrics.scrapers.process.include.names[0] != ''",
+			messages: []string{"expression failed: "},
+		},
+	}
+
+	var config map[string]interface{}
+	if err := yaml.Unmarshal([]byte(policyConfig), &config); err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies, err := writePolicies(t, "policies:\n  - name: test\n    "+tt.policy+"\n")
+			if err != nil {
+				t.Fatalf("Failed to load policies: %v", err)
+			}
+			violations := policies.Evaluate(config)
+			if len(violations) != len(tt.messages) {
+				t.Fatalf("Expected %d violations, got %+v", len(tt.messages), violations)
+			}
+			for i, violation := range violations {
+				if violation.Policy != "test" || violation.Action != PolicyBlock ||
+					!strings.HasPrefix(violation.Message, tt.messages[i]) {
+					t.Errorf("Expected a violation %q, got %+v", tt.messages[i], violation)
+				}
+			}
+		})
+	}
+
+	var none *Policies
+	if violations := none.Evaluate(config); violations != nil {
+		t.Errorf("Expected no violations without policies, got %+v", violations)
+	}
+}
+
+func TestCheckPolicies_BlockAndWarn(t *testing.T) {
+	generator := NewConfigGenerator(zap.NewNop())
+
+	// Warnings are returned and the config goes through
+	policies, err := writePolicies(t, `
+policies:
+  - name: receiver-budget
+    action: warn
+    max: {receivers: 2}
+`)
+	if err != nil {
+		t.Fatalf("Failed to load policies: %v", err)
+	}
+	generator.SetPolicies(policies)
+	violations, err := generator.checkPolicies(policyConfig)
+	if err != nil {
+		t.Fatalf("Expected warnings not to block, got %v", err)
+	}
+	if len(violations) != 1 || violations[0].Action != PolicyWarn {
+		t.Errorf("Expected a warning, got %+v", violations)
+	}
+
+	// A blocking violation fails the config, listing every violation
+	policies, err = writePolicies(t, `
+policies:
+  - name: receiver-budget
+    action: warn
+    max: {receivers: 2}
+  - name: no-debug-exporter
+    forbid: [exporters.debug]
+`)
+	if err != nil {
+		t.Fatalf("Failed to load policies: %v", err)
+	}
+	generator.SetPolicies(policies)
+	violations, err = generator.checkPolicies(policyConfig)
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("Expected a *PolicyError, got %v", err)
+	}
+	if len(violations) != 2 || !reflect.DeepEqual(policyErr.Violations, violations) {
+		t.Errorf("Expected both violations, got %+v and %+v", violations, policyErr.Violations)
+	}
+	if want := "config violates policies: no-debug-exporter: exporters.debug/verbose is forbidden"; err.Error() != want {
+		t.Errorf("Expected %q, got %q", want, err.Error())
+	}
+
+	// A config that is not YAML cannot be checked
+	if _, err := generator.checkPolicies("receivers: [unterminated"); err == nil {
+		t.Error("Expected an error for invalid YAML")
+	}
+}
@@ -0,0 +1,238 @@
+package autoconfig
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/newrelic/nrdot-host/nrdot-discovery"
+)
+
+// Default hysteresis of the reconciliation loop: the consecutive scans a
+// service must be discovered before its receiver is added, and missed
+// before it is removed. Removing takes longer, so a service restarting does
+// not lose its receiver.
+const (
+	defaultAddAfterScans    = 2
+	defaultRemoveAfterScans = 3
+)
+
+// Changes of the service set of a config
+const (
+	ServiceAdded   = "added"
+	ServiceChanged = "changed"
+	ServiceRemoved = "removed"
+)
+
+// ServiceDrift is the difference between the services discovered and those
+// the applied config collects, once it has outlasted the hysteresis
+type ServiceDrift struct {
+	Added   []discovery.ServiceInfo
+	Changed []discovery.ServiceInfo // receivers whose endpoints moved
+	Removed []discovery.ServiceInfo
+}
+
+// Empty reports whether the applied config matches the services discovered
+func (d ServiceDrift) Empty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// PendingService is a change of the service set seen in recent scans but not
+// yet for long enough to regenerate the config
+type PendingService struct {
+	Service  string `json:"service"`
+	Receiver string `json:"receiver"`
+	Change   string `json:"change"` // one of the Service* constants
+	Scans    int    `json:"scans"`
+	Required int    `json:"required"`
+}
+
+// serviceReconciler tracks the services of the applied config against those
+// discovered scan after scan. Services are identified by the receiver they
+// get; stopped services count as missing, as they get no receiver.
+type serviceReconciler struct {
+	addAfter    int
+	removeAfter int
+
+	// applied is nil until a config is applied
+	applied map[string]discovery.ServiceInfo
+	// seen are the services discovered at endpoints the applied config
+	// does not collect, by serviceKey
+	seen map[string]sighting
+	// missing counts the consecutive scans an applied service was not
+	// discovered, by receiver name
+	missing map[string]int
+	// pinned services are added at once and never removed, nil for none
+	pinned func(discovery.ServiceInfo) bool
+}
+
+// sighting is a service discovered in consecutive scans
+type sighting struct {
+	service discovery.ServiceInfo
+	scans   int
+}
+
+func newServiceReconciler(addAfter, removeAfter int) *serviceReconciler {
+	if addAfter <= 0 {
+		addAfter = defaultAddAfterScans
+	}
+	if removeAfter <= 0 {
+		removeAfter = defaultRemoveAfterScans
+	}
+	return &serviceReconciler{
+		addAfter:    addAfter,
+		removeAfter: removeAfter,
+		seen:        make(map[string]sighting),
+		missing:     make(map[string]int),
+	}
+}
+
+// serviceKey identifies a service at its endpoints
+func serviceKey(svc discovery.ServiceInfo) string {
+	return fmt.Sprintf("%s:%v", receiverName(svc), svc.Endpoints)
+}
+
+// observe records a scan and returns the services the config should
+// collect, with the drift from the applied config. Until a config is
+// applied, every running service is added at once. Services of the applied
+// config keep their receivers while they are missing for fewer than
+// removeAfter scans; new and moved services wait addAfter scans. Pinned
+// services skip both waits, and are kept however long they are missing.
+// Drift is reported again on every scan until commit, so a failed apply is
+// retried.
+func (r *serviceReconciler) observe(services []discovery.ServiceInfo) ([]discovery.ServiceInfo, ServiceDrift) {
+	var drift ServiceDrift
+	running := make(map[string]discovery.ServiceInfo, len(services))
+	for _, svc := range services {
+		if !svc.Stopped() {
+			running[receiverName(svc)] = svc
+		}
+	}
+
+	if r.applied == nil {
+		desired := make([]discovery.ServiceInfo, 0, len(running))
+		for _, svc := range running {
+			desired = append(desired, svc)
+		}
+		sortServices(desired)
+		drift.Added = desired
+		return desired, drift
+	}
+
+	desired := make([]discovery.ServiceInfo, 0, len(running)+len(r.applied))
+	seen := make(map[string]sighting)
+	for name, svc := range running {
+		delete(r.missing, name)
+		applied, known := r.applied[name]
+		if known && fmt.Sprint(applied.Endpoints) == fmt.Sprint(svc.Endpoints) {
+			desired = append(desired, svc)
+			continue
+		}
+
+		key := serviceKey(svc)
+		seen[key] = sighting{service: svc, scans: r.seen[key].scans + 1}
+		waiting := seen[key].scans < r.addAfter && !r.isPinned(svc)
+		switch {
+		case waiting && known:
+			desired = append(desired, applied)
+		case waiting:
+		case known:
+			desired = append(desired, svc)
+			drift.Changed = append(drift.Changed, svc)
+		default:
+			desired = append(desired, svc)
+			drift.Added = append(drift.Added, svc)
+		}
+	}
+	// Discovery must find a service in consecutive scans
+	r.seen = seen
+
+	for name, svc := range r.applied {
+		if _, ok := running[name]; ok {
+			continue
+		}
+		if r.isPinned(svc) {
+			delete(r.missing, name)
+			desired = append(desired, svc)
+			continue
+		}
+		r.missing[name]++
+		if r.missing[name] < r.removeAfter {
+			desired = append(desired, svc)
+			continue
+		}
+		drift.Removed = append(drift.Removed, svc)
+	}
+
+	sortServices(desired)
+	sortServices(drift.Added)
+	sortServices(drift.Changed)
+	sortServices(drift.Removed)
+	return desired, drift
+}
+
+// isPinned reports whether svc is pinned into the config
+func (r *serviceReconciler) isPinned(svc discovery.ServiceInfo) bool {
+	return r.pinned != nil && r.pinned(svc)
+}
+
+// commit records services, as returned by observe, as those of the applied
+// config. Services kept while missing go on being counted.
+func (r *serviceReconciler) commit(services []discovery.ServiceInfo) {
+	r.applied = make(map[string]discovery.ServiceInfo, len(services))
+	for _, svc := range services {
+		r.applied[receiverName(svc)] = svc
+		delete(r.seen, serviceKey(svc))
+	}
+	for name := range r.missing {
+		if _, ok := r.applied[name]; !ok {
+			delete(r.missing, name)
+		}
+	}
+}
+
+// pending returns the changes of the service set waiting out the
+// hysteresis, ordered by receiver
+func (r *serviceReconciler) pending() []PendingService {
+	var pending []PendingService
+	for _, seen := range r.seen {
+		if seen.scans >= r.addAfter {
+			continue
+		}
+		name := receiverName(seen.service)
+		change := ServiceAdded
+		if _, known := r.applied[name]; known {
+			change = ServiceChanged
+		}
+		pending = append(pending, PendingService{
+			Service:  seen.service.Type,
+			Receiver: name,
+			Change:   change,
+			Scans:    seen.scans,
+			Required: r.addAfter,
+		})
+	}
+	for name, scans := range r.missing {
+		if scans >= r.removeAfter {
+			continue
+		}
+		pending = append(pending, PendingService{
+			Service:  r.applied[name].Type,
+			Receiver: name,
+			Change:   ServiceRemoved,
+			Scans:    scans,
+			Required: r.removeAfter,
+		})
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Receiver < pending[j].Receiver
+	})
+	return pending
+}
+
+// sortServices orders services by receiver name, so configs generated for
+// the same services are the same
+func sortServices(services []discovery.ServiceInfo) {
+	sort.Slice(services, func(i, j int) bool {
+		return receiverName(services[i]) < receiverName(services[j])
+	})
+}
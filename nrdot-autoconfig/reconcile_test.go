@@ -0,0 +1,165 @@
+package autoconfig
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-discovery"
+)
+
+// service is a service of type discovered on a local port
+func service(serviceType string, port int) discovery.ServiceInfo {
+	return discovery.ServiceInfo{
+		Type:      serviceType,
+		Endpoints: []discovery.Endpoint{{Address: "127.0.0.1", Port: port, Protocol: "tcp"}},
+	}
+}
+
+// receiverNames returns the receivers of services, in order
+func receiverNames(services []discovery.ServiceInfo) []string {
+	names := []string{}
+	for _, svc := range services {
+		names = append(names, receiverName(svc))
+	}
+	return names
+}
+
+// observeScan runs one scan and checks the receivers of the desired config
+// and of the drift's additions, moves and removals
+func observeScan(t *testing.T, r *serviceReconciler, services []discovery.ServiceInfo, desired, added, changed, removed []string) []discovery.ServiceInfo {
+	t.Helper()
+	gotDesired, drift := r.observe(services)
+	for _, check := range []struct {
+		what string
+		want []string
+		got  []discovery.ServiceInfo
+	}{
+		{"desired", desired, gotDesired},
+		{"added", added, drift.Added},
+		{"changed", changed, drift.Changed},
+		{"removed", removed, drift.Removed},
+	} {
+		if want := append([]string{}, check.want...); !reflect.DeepEqual(receiverNames(check.got), want) {
+			t.Errorf("Expected %s %v, got %v", check.what, want, receiverNames(check.got))
+		}
+	}
+	return gotDesired
+}
+
+func TestServiceReconciler_FirstConfig(t *testing.T) {
+	r := newServiceReconciler(0, 0)
+	if r.addAfter != defaultAddAfterScans || r.removeAfter != defaultRemoveAfterScans {
+		t.Errorf("Expected default scan counts, got %d and %d", r.addAfter, r.removeAfter)
+	}
+
+	stopped := service("postgresql", 5432)
+	stopped.Health = &discovery.ServiceHealth{State: discovery.HealthStopped}
+
+	// Until a config is applied, running services are added at once
+	observeScan(t, r, []discovery.ServiceInfo{service("redis", 6379), stopped, service("mysql", 3306)},
+		[]string{"mysql", "redis"}, []string{"mysql", "redis"}, nil, nil)
+	if pending := r.pending(); len(pending) != 0 {
+		t.Errorf("Expected nothing pending, got %+v", pending)
+	}
+}
+
+func TestServiceReconciler_AddAfter(t *testing.T) {
+	r := newServiceReconciler(2, 3)
+	mysql, redis := service("mysql", 3306), service("redis", 6379)
+	r.commit([]discovery.ServiceInfo{mysql})
+
+	// A new service waits for a second scan
+	observeScan(t, r, []discovery.ServiceInfo{mysql, redis}, []string{"mysql"}, nil, nil, nil)
+	want := []PendingService{{Service: "redis", Receiver: "redis", Change: ServiceAdded, Scans: 1, Required: 2}}
+	if pending := r.pending(); !reflect.DeepEqual(pending, want) {
+		t.Errorf("Expected pending %+v, got %+v", want, pending)
+	}
+
+	// Scans must be consecutive
+	observeScan(t, r, []discovery.ServiceInfo{mysql}, []string{"mysql"}, nil, nil, nil)
+	observeScan(t, r, []discovery.ServiceInfo{mysql, redis}, []string{"mysql"}, nil, nil, nil)
+	desired := observeScan(t, r, []discovery.ServiceInfo{mysql, redis},
+		[]string{"mysql", "redis"}, []string{"redis"}, nil, nil)
+
+	r.commit(desired)
+	observeScan(t, r, []discovery.ServiceInfo{mysql, redis}, []string{"mysql", "redis"}, nil, nil, nil)
+}
+
+func TestServiceReconciler_Moved(t *testing.T) {
+	r := newServiceReconciler(2, 3)
+	r.commit([]discovery.ServiceInfo{service("redis", 6379)})
+
+	// The receiver keeps its endpoint until the move is seen twice
+	moved := service("redis", 6380)
+	desired := observeScan(t, r, []discovery.ServiceInfo{moved}, []string{"redis"}, nil, nil, nil)
+	if desired[0].Endpoints[0].Port != 6379 {
+		t.Errorf("Expected the applied endpoint to be kept, got %+v", desired[0].Endpoints)
+	}
+	if pending := r.pending(); len(pending) != 1 || pending[0].Change != ServiceChanged {
+		t.Errorf("Expected a pending change, got %+v", pending)
+	}
+
+	desired = observeScan(t, r, []discovery.ServiceInfo{moved}, []string{"redis"}, nil, []string{"redis"}, nil)
+	if desired[0].Endpoints[0].Port != 6380 {
+		t.Errorf("Expected the new endpoint, got %+v", desired[0].Endpoints)
+	}
+}
+
+func TestServiceReconciler_RemoveAfter(t *testing.T) {
+	r := newServiceReconciler(2, 3)
+	mysql, redis := service("mysql", 3306), service("redis", 6379)
+	r.commit([]discovery.ServiceInfo{mysql, redis})
+
+	// A restarting service keeps its receiver
+	observeScan(t, r, []discovery.ServiceInfo{mysql}, []string{"mysql", "redis"}, nil, nil, nil)
+	observeScan(t, r, []discovery.ServiceInfo{mysql}, []string{"mysql", "redis"}, nil, nil, nil)
+	want := []PendingService{{Service: "redis", Receiver: "redis", Change: ServiceRemoved, Scans: 2, Required: 3}}
+	if pending := r.pending(); !reflect.DeepEqual(pending, want) {
+		t.Errorf("Expected pending %+v, got %+v", want, pending)
+	}
+	observeScan(t, r, []discovery.ServiceInfo{mysql, redis}, []string{"mysql", "redis"}, nil, nil, nil)
+
+	// Stopped services count as missing
+	stopped := redis
+	stopped.Health = &discovery.ServiceHealth{State: discovery.HealthStopped}
+	for scan := 1; scan < 3; scan++ {
+		observeScan(t, r, []discovery.ServiceInfo{mysql, stopped}, []string{"mysql", "redis"}, nil, nil, nil)
+	}
+	observeScan(t, r, []discovery.ServiceInfo{mysql, stopped}, []string{"mysql"}, nil, nil, []string{"redis"})
+}
+
+func TestServiceReconciler_Pinned(t *testing.T) {
+	r := newServiceReconciler(2, 3)
+	mysql, redis := service("mysql", 3306), service("redis", 6379)
+	r.pinned = func(svc discovery.ServiceInfo) bool { return svc.Type == "redis" }
+	r.commit([]discovery.ServiceInfo{mysql})
+
+	// Pinned services are added at once
+	desired := observeScan(t, r, []discovery.ServiceInfo{mysql, redis},
+		[]string{"mysql", "redis"}, []string{"redis"}, nil, nil)
+	r.commit(desired)
+
+	// and kept however long they are missing
+	for scan := 0; scan < 5; scan++ {
+		observeScan(t, r, []discovery.ServiceInfo{mysql}, []string{"mysql", "redis"}, nil, nil, nil)
+	}
+	if pending := r.pending(); len(pending) != 0 {
+		t.Errorf("Expected nothing pending for a pinned service, got %+v", pending)
+	}
+}
+
+func TestServiceReconciler_RetryUntilCommitted(t *testing.T) {
+	r := newServiceReconciler(1, 1)
+	mysql, redis := service("mysql", 3306), service("redis", 6379)
+	r.commit([]discovery.ServiceInfo{mysql})
+
+	// The config failed to reload, so the drift is reported again
+	observeScan(t, r, []discovery.ServiceInfo{redis}, []string{"redis"}, []string{"redis"}, nil, []string{"mysql"})
+	desired := observeScan(t, r, []discovery.ServiceInfo{redis}, []string{"redis"}, []string{"redis"}, nil, []string{"mysql"})
+
+	r.commit(desired)
+	observeScan(t, r, []discovery.ServiceInfo{redis}, []string{"redis"}, nil, nil, nil)
+	if len(r.missing) != 0 || len(r.seen) != 0 {
+		t.Errorf("Expected no counts left after commit, got missing %v and seen %v", r.missing, r.seen)
+	}
+}
@@ -0,0 +1,259 @@
+package autoconfig
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// secretsEnvFileName holds service credentials next to the config, in
+	// the KEY=value format of systemd's EnvironmentFile
+	secretsEnvFileName = "secrets.env"
+
+	// secretsEnvExampleFileName lists the variables secretsEnvFileName must
+	// set for the generated config, without values
+	secretsEnvExampleFileName = "secrets.env.example"
+)
+
+// RequiredVariable is an environment variable a generated config references,
+// with the service it is used for
+type RequiredVariable struct {
+	Name        string   `json:"name"`
+	Service     string   `json:"service"`
+	Endpoints   []string `json:"endpoints,omitempty"`
+	Description string   `json:"description"`
+}
+
+// placeholderPattern matches ${NAME}, ${env:NAME} and either with a default,
+// ${NAME:default} or ${env:NAME:-default}
+var placeholderPattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// variableNamePattern is a valid environment variable name
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// placeholder is a variable reference in a config
+type placeholder struct {
+	Name       string
+	Default    string
+	HasDefault bool
+}
+
+// parsePlaceholder parses the text between ${ and }
+func parsePlaceholder(ref string) (placeholder, bool) {
+	ref = strings.TrimPrefix(ref, "env:")
+	var p placeholder
+	if i := strings.Index(ref, ":"); i >= 0 {
+		p.Default = strings.TrimPrefix(ref[i+1:], "-")
+		p.HasDefault = true
+		ref = ref[:i]
+	}
+	p.Name = ref
+	return p, variableNamePattern.MatchString(p.Name)
+}
+
+// PlaceholderIssue is a problem with a variable reference found by
+// LintPlaceholders
+type PlaceholderIssue struct {
+	Variable string `json:"variable"`
+	Service  string `json:"service,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Severity is "error" for references that would leave the collector
+	// without a credential it needs, "warning" otherwise
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// LintPlaceholders checks the variable references of a config. References
+// must be well formed, and those without a default must resolve through
+// lookup: an unresolved required variable is an error, any other unresolved
+// reference a warning, as the collector expands it to an empty string.
+func LintPlaceholders(config string, required []RequiredVariable, lookup func(string) (string, bool)) []PlaceholderIssue {
+	declared := make(map[string]RequiredVariable, len(required))
+	for _, v := range required {
+		declared[v.Name] = v
+	}
+
+	var issues []PlaceholderIssue
+	seen := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(config, -1) {
+		if seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+
+		p, ok := parsePlaceholder(match[1])
+		if !ok {
+			issues = append(issues, PlaceholderIssue{
+				Variable: match[1],
+				Severity: "error",
+				Message:  fmt.Sprintf("malformed placeholder %s", match[0]),
+			})
+			continue
+		}
+		if p.HasDefault {
+			continue
+		}
+		if value, ok := lookup(p.Name); ok && value != "" {
+			continue
+		}
+
+		v, isRequired := declared[p.Name]
+		if !isRequired {
+			issues = append(issues, PlaceholderIssue{
+				Variable: p.Name,
+				Severity: "warning",
+				Message:  fmt.Sprintf("%s is not set and expands to an empty value", p.Name),
+			})
+			continue
+		}
+		issue := PlaceholderIssue{
+			Variable: p.Name,
+			Service:  v.Service,
+			Severity: "error",
+			Message:  fmt.Sprintf("%s is not set: %s", p.Name, v.Description),
+		}
+		if len(v.Endpoints) > 0 {
+			issue.Endpoint = strings.Join(v.Endpoints, ",")
+		}
+		issues = append(issues, issue)
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Severity != issues[j].Severity {
+			return issues[i].Severity == "error"
+		}
+		return issues[i].Variable < issues[j].Variable
+	})
+	return issues
+}
+
+// PreflightError rejects a config whose required variables do not resolve,
+// before it reaches the collector
+type PreflightError struct {
+	Issues      []PlaceholderIssue
+	EnvPath     string
+	ExamplePath string
+}
+
+func (e *PreflightError) Error() string {
+	var missing []string
+	for _, issue := range e.Issues {
+		if issue.Severity != "error" {
+			continue
+		}
+		entry := issue.Variable
+		switch {
+		case issue.Service != "" && issue.Endpoint != "":
+			entry += fmt.Sprintf(" (%s on %s)", issue.Service, issue.Endpoint)
+		case issue.Service != "":
+			entry += fmt.Sprintf(" (%s)", issue.Service)
+		case issue.Message != "":
+			entry += fmt.Sprintf(" (%s)", issue.Message)
+		}
+		missing = append(missing, entry)
+	}
+	return fmt.Sprintf("config references unset variables: %s; set them in the environment or %s (see %s)",
+		strings.Join(missing, ", "), e.EnvPath, e.ExamplePath)
+}
+
+// preflightIssues splits issues into a PreflightError for the errors, nil if
+// there are none, and the warnings
+func preflightIssues(issues []PlaceholderIssue, envPath, examplePath string) (*PreflightError, []PlaceholderIssue) {
+	var errs, warnings []PlaceholderIssue
+	for _, issue := range issues {
+		if issue.Severity == "error" {
+			errs = append(errs, issue)
+		} else {
+			warnings = append(warnings, issue)
+		}
+	}
+	if len(errs) == 0 {
+		return nil, warnings
+	}
+	return &PreflightError{Issues: errs, EnvPath: envPath, ExamplePath: examplePath}, warnings
+}
+
+// RenderSecretsEnvExample renders a commented env file listing each required
+// variable with the service and endpoints it is used for. Copied to
+// secrets.env and filled in, it provides the credentials of the config.
+func RenderSecretsEnvExample(variables []RequiredVariable) string {
+	var buf bytes.Buffer
+	buf.WriteString("# Credentials required by the auto-generated configuration\n")
+	buf.WriteString("#\n")
+	fmt.Fprintf(&buf, "# Copy this file to %s (mode 0600) and fill in the values; they are\n", secretsEnvFileName)
+	buf.WriteString("# read when a configuration is applied. Variables set in the environment of\n")
+	buf.WriteString("# nrdot take precedence and may be left out.\n")
+	buf.WriteString("#\n")
+	buf.WriteString("# This file is regenerated with the configuration; edit the copy.\n")
+
+	for _, v := range variables {
+		buf.WriteString("\n")
+		fmt.Fprintf(&buf, "# %s\n", v.Description)
+		if len(v.Endpoints) > 0 {
+			fmt.Fprintf(&buf, "# Service: %s on %s\n", v.Service, strings.Join(v.Endpoints, ", "))
+		} else {
+			fmt.Fprintf(&buf, "# Service: %s\n", v.Service)
+		}
+		fmt.Fprintf(&buf, "%s=\n", v.Name)
+	}
+	return buf.String()
+}
+
+// WriteSecretsEnvExample writes the example env file for variables. It holds
+// no values, so it is world-readable.
+func WriteSecretsEnvExample(path string, variables []RequiredVariable) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(RenderSecretsEnvExample(variables)), 0644); err != nil {
+		return fmt.Errorf("failed to write secrets env example: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write secrets env example: %w", err)
+	}
+	return nil
+}
+
+// LoadEnvFile reads variables from a file in the KEY=value format of
+// systemd's EnvironmentFile. Blank lines, comments and an "export " prefix
+// are allowed, and values may be quoted. A missing file holds no variables.
+func LoadEnvFile(path string) (map[string]string, error) {
+	vars := make(map[string]string)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return vars, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !variableNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%s:%d: expected KEY=value", filepath.Base(path), lineNo)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
@@ -0,0 +1,185 @@
+package autoconfig
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-discovery"
+	"go.uber.org/zap"
+)
+
+func TestGenerateConfig_SecretsReferenced(t *testing.T) {
+	secrets := map[string]string{
+		"NEW_RELIC_LICENSE_KEY": "license-0123456789abcdef",
+		"MYSQL_MONITOR_USER":    "nrdot-monitor",
+		"MYSQL_MONITOR_PASS":    "mysql-s3cret",
+		"CONSUL_HTTP_TOKEN":     "consul-t0ken",
+	}
+	for name, value := range secrets {
+		t.Setenv(name, value)
+	}
+
+	generator := NewConfigGenerator(zap.NewNop())
+	generated, err := generator.GenerateConfig(context.Background(), []discovery.ServiceInfo{
+		service("mysql", closedPort(t)),
+		service("consul", consulAgent(t, secrets["CONSUL_HTTP_TOKEN"])),
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate config: %v", err)
+	}
+	if len(generated.ActionsNeeded) != 0 {
+		t.Fatalf("Expected no actions needed, got %+v", generated.ActionsNeeded)
+	}
+
+	// The config references the variables and never holds their values
+	for name, value := range secrets {
+		if !strings.Contains(generated.Config, "${"+name+"}") {
+			t.Errorf("Expected the config to reference ${%s}", name)
+		}
+		if strings.Contains(generated.Config, value) {
+			t.Errorf("Expected the value of %s not to appear in the config", name)
+		}
+	}
+
+	want := []string{"NEW_RELIC_LICENSE_KEY", "MYSQL_MONITOR_USER", "MYSQL_MONITOR_PASS", "CONSUL_HTTP_TOKEN"}
+	if !reflect.DeepEqual(generated.RequiredVariables, want) {
+		t.Errorf("Expected required variables %v, got %v", want, generated.RequiredVariables)
+	}
+
+	// Nor does the example env file
+	example := RenderSecretsEnvExample(generated.Variables)
+	for name, value := range secrets {
+		if !strings.Contains(example, "\n"+name+"=\n") || strings.Contains(example, value) {
+			t.Errorf("Expected %s listed without its value in\n%s", name, example)
+		}
+	}
+}
+
+func TestLintPlaceholders(t *testing.T) {
+	config := `
+receivers:
+  mysql:
+    username: ${MYSQL_MONITOR_USER}
+    password: ${env:MYSQL_MONITOR_PASS}
+  redis:
+    password: ${REDIS_PASSWORD:-}
+exporters:
+  otlp:
+    headers:
+      api-key: ${NEW_RELIC_LICENSE_KEY}
+      x-region: ${NR_REGION}
+      x-bad: ${not a name}
+`
+	required := []RequiredVariable{
+		{Name: "MYSQL_MONITOR_USER", Service: "mysql", Endpoints: []string{"127.0.0.1:3306"}, Description: "MySQL monitoring user"},
+		{Name: "MYSQL_MONITOR_PASS", Service: "mysql", Endpoints: []string{"127.0.0.1:3306"}, Description: "MySQL monitoring password"},
+		{Name: "NEW_RELIC_LICENSE_KEY", Service: "newrelic", Description: "New Relic license key"},
+	}
+	issues := LintPlaceholders(config, required, envLookup(map[string]string{
+		"MYSQL_MONITOR_USER":    "nrdot",
+		"NEW_RELIC_LICENSE_KEY": "",
+	}))
+
+	want := []PlaceholderIssue{
+		{Variable: "MYSQL_MONITOR_PASS", Service: "mysql", Endpoint: "127.0.0.1:3306", Severity: "error", Message: "MYSQL_MONITOR_PASS is not set: MySQL monitoring password"},
+		{Variable: "NEW_RELIC_LICENSE_KEY", Service: "newrelic", Severity: "error", Message: "NEW_RELIC_LICENSE_KEY is not set: New Relic license key"},
+		{Variable: "not a name", Severity: "error", Message: "malformed placeholder ${not a name}"},
+		{Variable: "NR_REGION", Severity: "warning", Message: "NR_REGION is not set and expands to an empty value"},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("Expected\n%+v\ngot\n%+v", want, issues)
+	}
+
+	perr, warnings := preflightIssues(issues, "/etc/nrdot/secrets.env", "/etc/nrdot/secrets.env.example")
+	if perr == nil || len(perr.Issues) != 3 || len(warnings) != 1 {
+		t.Fatalf("Expected 3 errors and 1 warning, got %+v and %+v", perr, warnings)
+	}
+	if msg := perr.Error(); !strings.HasPrefix(msg, "config references unset variables: MYSQL_MONITOR_PASS (mysql on 127.0.0.1:3306), NEW_RELIC_LICENSE_KEY (newrelic), ") {
+		t.Errorf("Unexpected error %q", msg)
+	}
+	if perr, _ := preflightIssues(warnings, "", ""); perr != nil {
+		t.Errorf("Expected warnings alone not to fail, got %v", perr)
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, secretsEnvFileName)
+	content := `# Credentials
+; also a comment
+
+MYSQL_MONITOR_USER=nrdot
+export MYSQL_MONITOR_PASS = "pa ss=word"
+REDIS_PASSWORD='quoted'
+EMPTY=
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", secretsEnvFileName, err)
+	}
+
+	vars, err := LoadEnvFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load %s: %v", secretsEnvFileName, err)
+	}
+	want := map[string]string{
+		"MYSQL_MONITOR_USER": "nrdot",
+		"MYSQL_MONITOR_PASS": "pa ss=word",
+		"REDIS_PASSWORD":     "quoted",
+		"EMPTY":              "",
+	}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("Expected %v, got %v", want, vars)
+	}
+
+	if vars, err := LoadEnvFile(filepath.Join(dir, "missing.env")); err != nil || len(vars) != 0 {
+		t.Errorf("Expected no variables from a missing file, got %v, %v", vars, err)
+	}
+
+	for _, line := range []string{"NO_VALUE", "1BAD=value", "=value"} {
+		if err := os.WriteFile(path, []byte("OK=1\n"+line+"\n"), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", secretsEnvFileName, err)
+		}
+		_, err := LoadEnvFile(path)
+		if want := secretsEnvFileName + ":2: expected KEY=value"; err == nil || err.Error() != want {
+			t.Errorf("%q: expected %q, got %v", line, want, err)
+		}
+	}
+}
+
+func TestWriteSecretsEnvExample(t *testing.T) {
+	path := filepath.Join(t.TempDir(), secretsEnvExampleFileName)
+	variables := []RequiredVariable{
+		{Name: "REDIS_PASSWORD", Service: "redis", Endpoints: []string{"127.0.0.1:6379", "127.0.0.1:6380"}, Description: "Redis password"},
+		{Name: "CONSUL_HTTP_TOKEN", Service: "consul", Description: "Consul ACL token"},
+	}
+	if err := WriteSecretsEnvExample(path, variables); err != nil {
+		t.Fatalf("Failed to write example: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat example: %v", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("Expected mode 0644, got %v", info.Mode().Perm())
+	}
+	content, _ := os.ReadFile(path)
+	for _, want := range []string{
+		"# Redis password\n# Service: redis on 127.0.0.1:6379, 127.0.0.1:6380\nREDIS_PASSWORD=\n",
+		"# Consul ACL token\n# Service: consul\nCONSUL_HTTP_TOKEN=\n",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Expected the example to contain %q, got\n%s", want, content)
+		}
+	}
+
+	// The example loads as an env file of empty values
+	vars, err := LoadEnvFile(path)
+	if err != nil || len(vars) != 2 || vars["REDIS_PASSWORD"] != "" {
+		t.Errorf("Expected the example to load with empty values, got %v, %v", vars, err)
+	}
+}
@@ -0,0 +1,109 @@
+package autoconfig
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/newrelic/nrdot-host/nrdot-discovery"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfidenceLow is the discovery confidence of services found by a single
+// weak signal, such as a listening port alone
+const ConfidenceLow = "LOW"
+
+// Suggestion is a receiver left out of a generated config because its
+// service was found with LOW confidence: a service guessed from a port
+// alone is often something else, or needs credentials nobody set, and its
+// receiver would only log scrape errors. The receiver is written to the
+// config as a commented-out stub instead; pinning the service in the
+// overrides enables it.
+type Suggestion struct {
+	Service      string   `json:"service"`
+	Receiver     string   `json:"receiver"`
+	Endpoint     string   `json:"endpoint,omitempty"`
+	Confidence   string   `json:"confidence"`
+	DiscoveredBy []string `json:"discovered_by"`
+	Message      string   `json:"message"`
+	Remediation  string   `json:"remediation"`
+}
+
+// confidentServices splits services into those found with enough
+// confidence for a receiver and those only suggested. Pinned services get
+// receivers whatever their confidence.
+func (cg *ConfigGenerator) confidentServices(services []discovery.ServiceInfo) ([]discovery.ServiceInfo, []discovery.ServiceInfo) {
+	confident := make([]discovery.ServiceInfo, 0, len(services))
+	var suggested []discovery.ServiceInfo
+	for _, svc := range services {
+		if svc.Confidence == ConfidenceLow && !cg.overrides.Pinned(svc) {
+			suggested = append(suggested, svc)
+			continue
+		}
+		confident = append(confident, svc)
+	}
+	return confident, suggested
+}
+
+// generateSuggestions renders the receivers of services found with LOW
+// confidence, returning them as suggestions and the stubs for the config.
+// Services without a receiver template are left out.
+func (cg *ConfigGenerator) generateSuggestions(services []discovery.ServiceInfo) ([]Suggestion, map[string]interface{}) {
+	var suggestions []Suggestion
+	stubs := make(map[string]interface{})
+	for _, svc := range services {
+		receiverConfig, err := cg.templateEngine.RenderServiceReceiver(svc)
+		if err != nil {
+			continue
+		}
+		name := receiverName(svc)
+		stubs[name] = receiverConfig
+
+		endpoint := ""
+		if len(svc.Endpoints) > 0 {
+			endpoint = endpointAddress(svc.Endpoints[0])
+		}
+		suggestions = append(suggestions, Suggestion{
+			Service:      svc.Type,
+			Receiver:     name,
+			Endpoint:     endpoint,
+			Confidence:   svc.Confidence,
+			DiscoveredBy: svc.DiscoveredBy,
+			Message: fmt.Sprintf("%s found by %s only, %s confidence",
+				svc.Type, strings.Join(svc.DiscoveredBy, ", "), svc.Confidence),
+			Remediation: fmt.Sprintf("If this is %s, add %s under services.pin in %s to enable its receiver.",
+				svc.Type, name, overridesFileName),
+		})
+		cg.logger.Info("Receiver suggested, not enabled",
+			zap.String("service", svc.Type),
+			zap.String("receiver", name),
+			zap.String("endpoint", endpoint),
+			zap.String("confidence", svc.Confidence),
+			zap.Strings("discovered_by", svc.DiscoveredBy))
+	}
+	return suggestions, stubs
+}
+
+// renderStubs renders the receivers of suggestions as a commented-out
+// receivers section, to copy into the overrides or uncomment by hand
+func renderStubs(stubs map[string]interface{}) (string, error) {
+	if len(stubs) == 0 {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(map[string]interface{}{"receivers": stubs}); err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	out.WriteString("\n# Suggested receivers, left out for their LOW discovery confidence.\n")
+	fmt.Fprintf(&out, "# Pin a service in %s to enable its receiver:\n", overridesFileName)
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		out.WriteString("# " + line + "\n")
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,146 @@
+package autoconfig
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-discovery"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// withConfidence is svc found by methods with confidence
+func withConfidence(svc discovery.ServiceInfo, confidence string, methods ...string) discovery.ServiceInfo {
+	svc.Confidence = confidence
+	svc.DiscoveredBy = methods
+	return svc
+}
+
+func TestConfidentServices(t *testing.T) {
+	overrides, err := writeOverrides(t, "services:\n  pin: [redis]\n")
+	if err != nil {
+		t.Fatalf("Failed to load overrides: %v", err)
+	}
+	generator := NewConfigGenerator(zap.NewNop())
+	generator.SetOverrides(overrides)
+
+	confident, suggested := generator.confidentServices([]discovery.ServiceInfo{
+		withConfidence(service("mysql", 3306), "HIGH", "process", "port"),
+		withConfidence(service("memcached", 11211), ConfidenceLow, "port"),
+		withConfidence(service("nginx", 80), "MEDIUM", "process"),
+		// Pinned services get receivers whatever their confidence
+		withConfidence(service("redis", 6379), ConfidenceLow, "port"),
+		service("apache", 8080),
+	})
+	if want := []string{"mysql", "nginx", "redis", "apache"}; !reflect.DeepEqual(receiverNames(confident), want) {
+		t.Errorf("Expected %v confident, got %v", want, receiverNames(confident))
+	}
+	if want := []string{"memcached"}; !reflect.DeepEqual(receiverNames(suggested), want) {
+		t.Errorf("Expected %v suggested, got %v", want, receiverNames(suggested))
+	}
+}
+
+func TestGenerateSuggestions(t *testing.T) {
+	generator := NewConfigGenerator(zap.NewNop())
+
+	// Services without a receiver template are left out
+	suggestions, stubs := generator.generateSuggestions([]discovery.ServiceInfo{
+		withConfidence(service("memcached", 11211), ConfidenceLow, "port"),
+		withConfidence(service("gopher", 70), ConfidenceLow, "port"),
+	})
+
+	want := []Suggestion{{
+		Service:      "memcached",
+		Receiver:     "memcached",
+		Endpoint:     "127.0.0.1:11211",
+		Confidence:   ConfidenceLow,
+		DiscoveredBy: []string{"port"},
+		Message:      "memcached found by port only, LOW confidence",
+		Remediation:  "If this is memcached, add memcached under services.pin in " + overridesFileName + " to enable its receiver.",
+	}}
+	if !reflect.DeepEqual(suggestions, want) {
+		t.Errorf("Expected %+v, got %+v", want, suggestions)
+	}
+	if len(stubs) != 1 || stubs["memcached"] == nil {
+		t.Errorf("Expected the memcached receiver as the only stub, got %v", stubs)
+	}
+}
+
+func TestRenderStubs(t *testing.T) {
+	if footer, err := renderStubs(nil); err != nil || footer != "" {
+		t.Errorf("Expected nothing without stubs, got %q, %v", footer, err)
+	}
+
+	stubs := map[string]interface{}{
+		"memcached": map[string]interface{}{"endpoint": "localhost:11211", "collection_interval": "30s"},
+	}
+	footer, err := renderStubs(stubs)
+	if err != nil {
+		t.Fatalf("Failed to render stubs: %v", err)
+	}
+
+	// Every line is commented out, and uncommenting gives the receivers back
+	var uncommented strings.Builder
+	for _, line := range strings.Split(strings.Trim(footer, "\n"), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			t.Fatalf("Expected only comments, got line %q", line)
+		}
+		if strings.HasPrefix(line, "# ") && !strings.HasPrefix(line, "# Suggested") && !strings.HasPrefix(line, "# Pin") {
+			uncommented.WriteString(strings.TrimPrefix(line, "# ") + "\n")
+		}
+	}
+	var parsed map[string]interface{}
+	if err := yaml.Unmarshal([]byte(uncommented.String()), &parsed); err != nil {
+		t.Fatalf("Failed to parse uncommented stubs: %v", err)
+	}
+	if want := map[string]interface{}{"receivers": stubs}; !reflect.DeepEqual(parsed, want) {
+		t.Errorf("Expected %v, got %v", want, parsed)
+	}
+}
+
+func TestGenerateConfig_Suggestions(t *testing.T) {
+	services := []discovery.ServiceInfo{
+		withConfidence(service("nginx", 80), "HIGH", "process"),
+		withConfidence(service("memcached", 11211), ConfidenceLow, "port"),
+	}
+
+	generator := NewConfigGenerator(zap.NewNop())
+	generated, err := generator.GenerateConfig(context.Background(), services)
+	if err != nil {
+		t.Fatalf("Failed to generate config: %v", err)
+	}
+	if len(generated.Suggestions) != 1 || generated.Suggestions[0].Receiver != "memcached" {
+		t.Errorf("Expected memcached suggested, got %+v", generated.Suggestions)
+	}
+
+	var config map[string]interface{}
+	if err := yaml.Unmarshal([]byte(generated.Config), &config); err != nil {
+		t.Fatalf("Failed to parse generated config: %v", err)
+	}
+	receivers := config["receivers"].(map[string]interface{})
+	if _, ok := receivers["memcached"]; ok {
+		t.Error("Expected no memcached receiver for a LOW confidence service")
+	}
+	if _, ok := receivers["nginx"]; !ok {
+		t.Error("Expected an nginx receiver")
+	}
+	if !strings.Contains(generated.Config, "\n#   memcached:\n") {
+		t.Errorf("Expected the memcached receiver commented out, got\n%s", generated.Config)
+	}
+
+	// Pinning the service enables its receiver
+	overrides, err := writeOverrides(t, "services:\n  pin: [memcached]\n")
+	if err != nil {
+		t.Fatalf("Failed to load overrides: %v", err)
+	}
+	generator.SetOverrides(overrides)
+	generated, err = generator.GenerateConfig(context.Background(), services)
+	if err != nil {
+		t.Fatalf("Failed to generate config: %v", err)
+	}
+	if len(generated.Suggestions) != 0 || !strings.Contains(generated.Config, "\n  memcached:\n") {
+		t.Errorf("Expected the pinned memcached receiver enabled, got %+v and\n%s", generated.Suggestions, generated.Config)
+	}
+}
@@ -2,6 +2,9 @@ package autoconfig
 
 import (
 	"fmt"
+	"net"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/newrelic/nrdot-host/nrdot-discovery"
@@ -104,19 +107,112 @@ func (te *TemplateEngine) RenderServiceReceiver(service discovery.ServiceInfo) (
 		return te.renderMemcachedReceiver(service), nil
 	case "kafka":
 		return te.renderKafkaReceiver(service), nil
+	case "tomcat":
+		return te.renderTomcatReceiver(service), nil
+	case "haproxy":
+		return te.renderHAProxyReceiver(service), nil
+	case "consul":
+		return te.renderConsulReceiver(service), nil
+	case "etcd":
+		return te.renderEtcdReceiver(service), nil
+	case "varnish":
+		return te.renderVarnishReceiver(service), nil
+	case "solr":
+		return te.renderSolrReceiver(service), nil
 	default:
 		return nil, fmt.Errorf("unsupported service type: %s", service.Type)
 	}
 }
 
+// endpointAddress returns the host:port at which the collector reaches a
+// discovered endpoint. Services listening on every interface, 0.0.0.0 or
+// ::, are reached on localhost; IPv6 literals are bracketed, e.g. [::1]:6379.
+func endpointAddress(ep discovery.Endpoint) string {
+	host := ep.Address
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(ep.Port))
+}
+
+// serviceAddress returns the host:port at which the collector reaches a
+// port of a service other than those discovered, such as a stats or JMX
+// port, on the host of its first endpoint
+func serviceAddress(service discovery.ServiceInfo, port int) string {
+	for _, ep := range service.Endpoints {
+		if ep.Port == port {
+			return endpointAddress(ep)
+		}
+	}
+	ep := discovery.Endpoint{Port: port}
+	if len(service.Endpoints) > 0 {
+		ep.Address = service.Endpoints[0].Address
+	}
+	return endpointAddress(ep)
+}
+
+// jvmOption returns the value of a -D system property on a Java command
+// line
+func jvmOption(service discovery.ServiceInfo, name string) (string, bool) {
+	if service.ProcessInfo == nil {
+		return "", false
+	}
+	for _, arg := range strings.Fields(service.ProcessInfo.Cmdline) {
+		if value, ok := strings.CutPrefix(arg, "-D"+name+"="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// jmxPort returns the remote JMX port of a Java service: the one on its
+// command line, else the default of its type
+func jmxPort(service discovery.ServiceInfo, defaultPort int) int {
+	if value, ok := jvmOption(service, "com.sun.management.jmxremote.port"); ok {
+		if port, err := strconv.Atoi(value); err == nil {
+			return port
+		}
+	}
+	if port, ok := service.Additional["jmx_port"].(int); ok {
+		return port
+	}
+	return defaultPort
+}
+
+// jmxAuthenticate reports whether the remote JMX of a Java service asks for
+// credentials, as the JVM does unless turned off on its command line
+func jmxAuthenticate(service discovery.ServiceInfo) bool {
+	value, ok := jvmOption(service, "com.sun.management.jmxremote.authenticate")
+	return !ok || value != "false"
+}
+
+// majorVersion returns the major version of a service, probed from it or
+// guessed from its install path, e.g. 8 for 8.0.35
+func majorVersion(service discovery.ServiceInfo) (int, bool) {
+	major, _, _ := strings.Cut(service.Version, ".")
+	n, err := strconv.Atoi(major)
+	return n, err == nil
+}
+
+// disableMetrics turns off metrics of a receiver configuration
+func disableMetrics(config map[string]interface{}, names ...string) {
+	metrics := config["metrics"].(map[string]interface{})
+	for _, name := range names {
+		metrics[name] = map[string]bool{"enabled": false}
+	}
+}
+
+// jmxMetricsJar is the JMX metric gatherer the jmx receiver runs
+const jmxMetricsJar = "${JMX_METRICS_JAR:/opt/opentelemetry-java-contrib-jmx-metrics.jar}"
+
 // MySQL receiver configuration
 func (te *TemplateEngine) renderMySQLReceiver(service discovery.ServiceInfo) map[string]interface{} {
 	endpoint := "localhost:3306"
 	if len(service.Endpoints) > 0 {
-		endpoint = fmt.Sprintf("%s:%d", service.Endpoints[0].Address, service.Endpoints[0].Port)
+		endpoint = endpointAddress(service.Endpoints[0])
 	}
 
-	return map[string]interface{}{
+	config := map[string]interface{}{
 		"endpoint":             endpoint,
 		"collection_interval": "30s",
 		"username":            "${MYSQL_MONITOR_USER}",
@@ -142,16 +238,23 @@ func (te *TemplateEngine) renderMySQLReceiver(service discovery.ServiceInfo) map
 			"mysql.replica.sql_delay": map[string]bool{"enabled": true},
 		},
 	}
+
+	// The replica metrics read SHOW REPLICA STATUS, which MySQL 5.x
+	// servers do not know. MariaDB numbers its versions from 10.
+	if major, ok := majorVersion(service); ok && major < 8 {
+		disableMetrics(config, "mysql.replica.lag", "mysql.replica.sql_delay")
+	}
+	return config
 }
 
 // PostgreSQL receiver configuration
 func (te *TemplateEngine) renderPostgreSQLReceiver(service discovery.ServiceInfo) map[string]interface{} {
 	endpoint := "localhost:5432"
 	if len(service.Endpoints) > 0 {
-		endpoint = fmt.Sprintf("%s:%d", service.Endpoints[0].Address, service.Endpoints[0].Port)
+		endpoint = endpointAddress(service.Endpoints[0])
 	}
 
-	return map[string]interface{}{
+	config := map[string]interface{}{
 		"endpoint":             endpoint,
 		"collection_interval": "30s",
 		"username":            "${POSTGRES_MONITOR_USER}",
@@ -173,13 +276,20 @@ func (te *TemplateEngine) renderPostgreSQLReceiver(service discovery.ServiceInfo
 			"postgresql.wal.delay": map[string]bool{"enabled": true},
 		},
 	}
+
+	// Replication lag is read from the lag columns of pg_stat_replication,
+	// added in PostgreSQL 10
+	if major, ok := majorVersion(service); ok && major < 10 {
+		disableMetrics(config, "postgresql.replication.lag", "postgresql.wal.delay")
+	}
+	return config
 }
 
 // Redis receiver configuration
 func (te *TemplateEngine) renderRedisReceiver(service discovery.ServiceInfo) map[string]interface{} {
 	endpoint := "localhost:6379"
 	if len(service.Endpoints) > 0 {
-		endpoint = fmt.Sprintf("%s:%d", service.Endpoints[0].Address, service.Endpoints[0].Port)
+		endpoint = endpointAddress(service.Endpoints[0])
 	}
 
 	config := map[string]interface{}{
@@ -203,7 +313,7 @@ func (te *TemplateEngine) renderRedisReceiver(service discovery.ServiceInfo) map
 	}
 
 	// Add password if needed
-	if requiresAuth := false; requiresAuth { // Simplified check
+	if requiresAuth(service) {
 		config["password"] = "${REDIS_PASSWORD}"
 	}
 
@@ -247,7 +357,7 @@ func (te *TemplateEngine) renderApacheReceiver(service discovery.ServiceInfo) ma
 func (te *TemplateEngine) renderMongoDBReceiver(service discovery.ServiceInfo) map[string]interface{} {
 	endpoint := "localhost:27017"
 	if len(service.Endpoints) > 0 {
-		endpoint = fmt.Sprintf("%s:%d", service.Endpoints[0].Address, service.Endpoints[0].Port)
+		endpoint = endpointAddress(service.Endpoints[0])
 	}
 
 	return map[string]interface{}{
@@ -274,13 +384,23 @@ func (te *TemplateEngine) renderMongoDBReceiver(service discovery.ServiceInfo) m
 
 // Elasticsearch receiver configuration
 func (te *TemplateEngine) renderElasticsearchReceiver(service discovery.ServiceInfo) map[string]interface{} {
-	endpoint := "http://localhost:9200"
+	address := "localhost:9200"
 	if len(service.Endpoints) > 0 {
-		endpoint = fmt.Sprintf("http://%s:%d", service.Endpoints[0].Address, service.Endpoints[0].Port)
+		address = endpointAddress(service.Endpoints[0])
 	}
 
-	return map[string]interface{}{
-		"endpoints":            []string{endpoint},
+	// Elasticsearch 8 enables security by default, serving HTTPS with a
+	// certificate of its own CA. A node that answered the version probe
+	// serves whatever the probe spoke, plain HTTP when security is off.
+	scheme := "http"
+	if probe := service.VersionProbe(); probe != nil {
+		scheme = probe.Scheme
+	} else if major, ok := majorVersion(service); ok && major >= 8 {
+		scheme = "https"
+	}
+
+	config := map[string]interface{}{
+		"endpoints":            []string{scheme + "://" + address},
 		"collection_interval": "30s",
 		"username":            "${ELASTICSEARCH_USER}",
 		"password":            "${ELASTICSEARCH_PASS}",
@@ -296,6 +416,12 @@ func (te *TemplateEngine) renderElasticsearchReceiver(service discovery.ServiceI
 			"elasticsearch.node.jvm.memory.heap.used": map[string]bool{"enabled": true},
 		},
 	}
+	if scheme == "https" {
+		config["tls"] = map[string]interface{}{
+			"ca_file": "${ELASTICSEARCH_CA_FILE:/etc/elasticsearch/certs/http_ca.crt}",
+		}
+	}
+	return config
 }
 
 // RabbitMQ receiver configuration
@@ -320,7 +446,7 @@ func (te *TemplateEngine) renderRabbitMQReceiver(service discovery.ServiceInfo)
 func (te *TemplateEngine) renderMemcachedReceiver(service discovery.ServiceInfo) map[string]interface{} {
 	endpoint := "localhost:11211"
 	if len(service.Endpoints) > 0 {
-		endpoint = fmt.Sprintf("%s:%d", service.Endpoints[0].Address, service.Endpoints[0].Port)
+		endpoint = endpointAddress(service.Endpoints[0])
 	}
 
 	return map[string]interface{}{
@@ -353,6 +479,167 @@ func (te *TemplateEngine) renderKafkaReceiver(service discovery.ServiceInfo) map
 	}
 }
 
+// Tomcat receiver configuration (using JMX)
+func (te *TemplateEngine) renderTomcatReceiver(service discovery.ServiceInfo) map[string]interface{} {
+	config := map[string]interface{}{
+		"jar_path":            jmxMetricsJar,
+		"endpoint":            serviceAddress(service, jmxPort(service, 9010)),
+		"target_system":       "jvm,tomcat",
+		"collection_interval": "30s",
+	}
+	if jmxAuthenticate(service) {
+		config["username"] = "${TOMCAT_JMX_USER}"
+		config["password"] = "${TOMCAT_JMX_PASS}"
+	}
+	return config
+}
+
+// HAProxy receiver configuration, from the stats page
+func (te *TemplateEngine) renderHAProxyReceiver(service discovery.ServiceInfo) map[string]interface{} {
+	port := 8404
+	if p, ok := service.Additional["stats_port"].(int); ok {
+		port = p
+	}
+
+	return map[string]interface{}{
+		"endpoint":            "http://" + serviceAddress(service, port) + "/stats",
+		"collection_interval": "30s",
+		"metrics": map[string]interface{}{
+			"haproxy.connections.rate":      map[string]bool{"enabled": true},
+			"haproxy.connections.errors":    map[string]bool{"enabled": true},
+			"haproxy.sessions.count":        map[string]bool{"enabled": true},
+			"haproxy.sessions.rate":         map[string]bool{"enabled": true},
+			"haproxy.requests.total":        map[string]bool{"enabled": true},
+			"haproxy.requests.errors":       map[string]bool{"enabled": true},
+			"haproxy.requests.queued":       map[string]bool{"enabled": true},
+			"haproxy.responses.errors":      map[string]bool{"enabled": true},
+			"haproxy.bytes.input":           map[string]bool{"enabled": true},
+			"haproxy.bytes.output":          map[string]bool{"enabled": true},
+			"haproxy.server_selected.total": map[string]bool{"enabled": true},
+		},
+	}
+}
+
+// Consul receiver configuration, scraping the agent's Prometheus metrics
+func (te *TemplateEngine) renderConsulReceiver(service discovery.ServiceInfo) map[string]interface{} {
+	endpoint := "localhost:8500"
+	if len(service.Endpoints) > 0 {
+		endpoint = endpointAddress(service.Endpoints[0])
+	}
+
+	scrape := map[string]interface{}{
+		"job_name":        "consul",
+		"scrape_interval": "30s",
+		"metrics_path":    "/v1/agent/metrics",
+		"params":          map[string][]string{"format": {"prometheus"}},
+		"static_configs":  []map[string]interface{}{{"targets": []string{endpoint}}},
+	}
+
+	// Add the ACL token if needed
+	if requiresAuth(service) {
+		scrape["authorization"] = map[string]interface{}{
+			"credentials": "${CONSUL_HTTP_TOKEN}",
+		}
+	}
+
+	return map[string]interface{}{
+		"config": map[string]interface{}{
+			"scrape_configs": []map[string]interface{}{scrape},
+		},
+	}
+}
+
+// etcd receiver configuration, scraping its Prometheus metrics
+func (te *TemplateEngine) renderEtcdReceiver(service discovery.ServiceInfo) map[string]interface{} {
+	scheme, endpoint := "http", "localhost:2379"
+	if len(service.Endpoints) > 0 {
+		endpoint = endpointAddress(service.Endpoints[0])
+	}
+	// Clusters serving clients over mutual TLS, like kubeadm's, publish
+	// their metrics on separate plain HTTP URLs
+	if target, ok := etcdMetricsURL(service); ok {
+		port, err := strconv.Atoi(target.Port())
+		if err != nil {
+			port = 2379
+		}
+		scheme = target.Scheme
+		endpoint = endpointAddress(discovery.Endpoint{Address: target.Hostname(), Port: port})
+	}
+
+	return map[string]interface{}{
+		"config": map[string]interface{}{
+			"scrape_configs": []map[string]interface{}{
+				{
+					"job_name":        "etcd",
+					"scrape_interval": "30s",
+					"scheme":          scheme,
+					"static_configs":  []map[string]interface{}{{"targets": []string{endpoint}}},
+				},
+			},
+		},
+	}
+}
+
+// etcdMetricsURL returns the first plain HTTP URL of etcd's
+// --listen-metrics-urls flag
+func etcdMetricsURL(service discovery.ServiceInfo) (*url.URL, bool) {
+	if service.ProcessInfo == nil {
+		return nil, false
+	}
+	args := strings.Fields(service.ProcessInfo.Cmdline)
+	for i, arg := range args {
+		value, ok := strings.CutPrefix(arg, "--listen-metrics-urls=")
+		if !ok && arg == "--listen-metrics-urls" && i+1 < len(args) {
+			value, ok = args[i+1], true
+		}
+		if !ok {
+			continue
+		}
+		for _, raw := range strings.Split(value, ",") {
+			if target, err := url.Parse(raw); err == nil && target.Scheme == "http" {
+				return target, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Varnish receiver configuration, scraping prometheus_varnish_exporter, as
+// varnishstat counters are only read from the shared memory log
+func (te *TemplateEngine) renderVarnishReceiver(service discovery.ServiceInfo) map[string]interface{} {
+	port := 9131
+	if p, ok := service.Additional["exporter_port"].(int); ok {
+		port = p
+	}
+
+	return map[string]interface{}{
+		"config": map[string]interface{}{
+			"scrape_configs": []map[string]interface{}{
+				{
+					"job_name":        "varnish",
+					"scrape_interval": "30s",
+					"static_configs":  []map[string]interface{}{{"targets": []string{serviceAddress(service, port)}}},
+				},
+			},
+		},
+	}
+}
+
+// Solr receiver configuration (using JMX)
+func (te *TemplateEngine) renderSolrReceiver(service discovery.ServiceInfo) map[string]interface{} {
+	config := map[string]interface{}{
+		"jar_path":            jmxMetricsJar,
+		"endpoint":            serviceAddress(service, jmxPort(service, 18983)),
+		"target_system":       "jvm,solr",
+		"collection_interval": "30s",
+	}
+	if jmxAuthenticate(service) {
+		config["username"] = "${SOLR_JMX_USER}"
+		config["password"] = "${SOLR_JMX_PASS}"
+	}
+	return config
+}
+
 // RenderLogReceivers renders log receiver configurations for a service
 func (te *TemplateEngine) RenderLogReceivers(service discovery.ServiceInfo) map[string]interface{} {
 	configs := make(map[string]interface{})
@@ -373,6 +660,17 @@ func (te *TemplateEngine) RenderLogReceivers(service discovery.ServiceInfo) map[
 		configs["filelog/redis"] = te.renderRedisLog()
 	case "mongodb":
 		configs["filelog/mongodb"] = te.renderMongoDBLog()
+	case "tomcat":
+		configs["filelog/tomcat"] = te.renderTomcatLog()
+		configs["filelog/tomcat_access"] = te.renderTomcatAccessLog()
+	case "haproxy":
+		configs["filelog/haproxy"] = te.renderHAProxyLog()
+	case "consul":
+		configs["filelog/consul"] = te.renderConsulLog()
+	case "varnish":
+		configs["filelog/varnish_access"] = te.renderVarnishAccessLog()
+	case "solr":
+		configs["filelog/solr"] = te.renderSolrLog()
 	}
 
 	return configs
@@ -565,6 +863,134 @@ func (te *TemplateEngine) renderMongoDBLog() map[string]interface{} {
 	}
 }
 
+// Tomcat log configurations
+func (te *TemplateEngine) renderTomcatLog() map[string]interface{} {
+	return map[string]interface{}{
+		"include":  []string{"/var/log/tomcat*/catalina.out", "/opt/tomcat/logs/catalina.out"},
+		"start_at": "end",
+		"multiline": map[string]interface{}{
+			"line_start_pattern": `^\d{2}-\w{3}-\d{4} \d{2}:\d{2}:\d{2}`,
+		},
+		"operators": []map[string]interface{}{
+			{
+				"type":  "regex_parser",
+				"regex": `^(?P<time>\d{2}-\w{3}-\d{4} \d{2}:\d{2}:\d{2}\.\d{3}) (?P<level>\w+) \[(?P<thread>[^\]]+)\] (?P<logger>\S+) (?P<message>.*)`,
+			},
+			{
+				"type":       "severity_parser",
+				"parse_from": "attributes.level",
+				"mapping": map[string]interface{}{
+					"error": []string{"SEVERE"},
+					"warn":  []string{"WARNING"},
+					"info":  []string{"INFO", "CONFIG"},
+					"debug": []string{"FINE", "FINER", "FINEST"},
+				},
+			},
+		},
+		"resource": map[string]interface{}{
+			"service.name": "tomcat",
+			"log.type":     "catalina",
+		},
+	}
+}
+
+func (te *TemplateEngine) renderTomcatAccessLog() map[string]interface{} {
+	return map[string]interface{}{
+		"include":  []string{"/var/log/tomcat*/localhost_access_log.*.txt", "/opt/tomcat/logs/localhost_access_log.*.txt"},
+		"start_at": "end",
+		"operators": []map[string]interface{}{
+			{
+				"type":  "regex_parser",
+				"regex": `^(?P<remote_addr>\S+) - (?P<remote_user>\S+) \[(?P<time_local>[^\]]+)\] "(?P<request>[^"]+)" (?P<status>\d+) (?P<bytes_sent>\S+)`,
+			},
+		},
+		"resource": map[string]interface{}{
+			"service.name": "tomcat",
+			"log.type":     "access",
+		},
+	}
+}
+
+// HAProxy log configuration, as written by rsyslog
+func (te *TemplateEngine) renderHAProxyLog() map[string]interface{} {
+	return map[string]interface{}{
+		"include":  []string{"/var/log/haproxy.log"},
+		"start_at": "end",
+		"operators": []map[string]interface{}{
+			{
+				"type":     "syslog_parser",
+				"protocol": "rfc3164",
+			},
+		},
+		"resource": map[string]interface{}{
+			"service.name": "haproxy",
+		},
+	}
+}
+
+// Consul log configuration, for agents with a log_file
+func (te *TemplateEngine) renderConsulLog() map[string]interface{} {
+	return map[string]interface{}{
+		"include":  []string{"/var/log/consul/*.log"},
+		"start_at": "end",
+		"operators": []map[string]interface{}{
+			{
+				"type":  "regex_parser",
+				"regex": `^(?P<time>\S+) \[(?P<level>\w+)\]\s+(?P<message>.*)`,
+			},
+			{
+				"type":       "severity_parser",
+				"parse_from": "attributes.level",
+			},
+		},
+		"resource": map[string]interface{}{
+			"service.name": "consul",
+		},
+	}
+}
+
+// Varnish log configuration, as written by varnishncsa
+func (te *TemplateEngine) renderVarnishAccessLog() map[string]interface{} {
+	return map[string]interface{}{
+		"include":  []string{"/var/log/varnish/varnishncsa.log"},
+		"start_at": "end",
+		"operators": []map[string]interface{}{
+			{
+				"type":  "regex_parser",
+				"regex": `^(?P<remote_addr>\S+) - (?P<remote_user>\S+) \[(?P<time_local>[^\]]+)\] "(?P<request>[^"]+)" (?P<status>\d+) (?P<bytes_sent>\S+)`,
+			},
+		},
+		"resource": map[string]interface{}{
+			"service.name": "varnish",
+			"log.type":     "access",
+		},
+	}
+}
+
+// Solr log configuration
+func (te *TemplateEngine) renderSolrLog() map[string]interface{} {
+	return map[string]interface{}{
+		"include":  []string{"/var/solr/logs/solr.log"},
+		"start_at": "end",
+		"multiline": map[string]interface{}{
+			"line_start_pattern": `^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}`,
+		},
+		"operators": []map[string]interface{}{
+			{
+				"type":  "regex_parser",
+				"regex": `^(?P<time>\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d{3}) (?P<level>\w+)\s+\((?P<thread>[^)]+)\)`,
+			},
+			{
+				"type":       "severity_parser",
+				"parse_from": "attributes.level",
+			},
+		},
+		"resource": map[string]interface{}{
+			"service.name": "solr",
+		},
+	}
+}
+
 // RenderSystemLogs renders system log receiver configuration
 func (te *TemplateEngine) RenderSystemLogs() map[string]interface{} {
 	return map[string]interface{}{
@@ -583,6 +1009,29 @@ func (te *TemplateEngine) RenderSystemLogs() map[string]interface{} {
 	}
 }
 
+// RenderKubeletStats renders the kubeletstats receiver collecting node, pod
+// and container metrics from the kubelet of this node, authenticated with
+// the agent's service account
+func (te *TemplateEngine) RenderKubeletStats(kubelet discovery.KubeletConfig) map[string]interface{} {
+	// The receiver takes host:port, the scheme is https
+	endpoint := strings.TrimPrefix(kubelet.Endpoint, "https://")
+	if endpoint == "" {
+		endpoint = "${env:K8S_NODE_NAME}:10250"
+	}
+	config := map[string]interface{}{
+		"collection_interval": "60s",
+		"auth_type":           "serviceAccount",
+		"endpoint":            strings.TrimSuffix(endpoint, "/"),
+		"metric_groups":       []string{"node", "pod", "container"},
+	}
+	if kubelet.InsecureSkipVerify {
+		config["insecure_skip_verify"] = true
+	} else if kubelet.CAFile != "" {
+		config["ca_file"] = kubelet.CAFile
+	}
+	return config
+}
+
 // RenderResourceProcessor renders resource processor configuration
 func (te *TemplateEngine) RenderResourceProcessor() map[string]interface{} {
 	return map[string]interface{}{
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
 )
 
 // JWTClaims represents the claims in a JWT token
@@ -35,6 +36,9 @@ const (
 	PermissionReload = "reload"
 )
 
+// ErrTokenExpired is returned for tokens past their expiry
+var ErrTokenExpired = errors.New("token has expired")
+
 // JWTManager handles JWT token creation and validation
 type JWTManager struct {
 	secretKey     []byte
@@ -89,6 +93,9 @@ func (m *JWTManager) ValidateToken(tokenString string) (*JWTClaims, error) {
 		return m.secretKey, nil
 	})
 
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, ErrTokenExpired
+	}
 	if err != nil {
 		return nil, fmt.Errorf("invalid token: %w", err)
 	}
@@ -100,7 +107,7 @@ func (m *JWTManager) ValidateToken(tokenString string) (*JWTClaims, error) {
 
 	// Additional validation
 	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(time.Now()) {
-		return nil, errors.New("token has expired")
+		return nil, ErrTokenExpired
 	}
 
 	if claims.Issuer != m.issuer {
@@ -132,28 +139,28 @@ func (m *JWTManager) HTTPMiddleware(requiredPermissions ...string) func(http.Han
 			// Extract token from Authorization header
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, "Authorization header required", http.StatusUnauthorized)
+				problem.Error(w, r, "Authorization header required", http.StatusUnauthorized)
 				return
 			}
 
 			// Check for Bearer prefix
 			parts := strings.SplitN(authHeader, " ", 2)
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				http.Error(w, "Authorization header must be Bearer token", http.StatusUnauthorized)
+				problem.Error(w, r, "Authorization header must be Bearer token", http.StatusUnauthorized)
 				return
 			}
 
 			// Validate token
 			claims, err := m.ValidateToken(parts[1])
 			if err != nil {
-				http.Error(w, fmt.Sprintf("Invalid token: %v", err), http.StatusUnauthorized)
+				problem.Error(w, r, fmt.Sprintf("Invalid token: %v", err), http.StatusUnauthorized)
 				return
 			}
 
 			// Check permissions
 			if len(requiredPermissions) > 0 {
 				if !m.hasPermissions(claims.Permissions, requiredPermissions) {
-					http.Error(w, "Insufficient permissions", http.StatusForbidden)
+					problem.Error(w, r, "Insufficient permissions", http.StatusForbidden)
 					return
 				}
 			}
@@ -0,0 +1,437 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// apiKeyPrefix starts every generated API key so leaked keys are easy
+	// to recognize in logs and secret scanners
+	apiKeyPrefix = "nrdot_"
+
+	// lastUsedResolution is how far last-used times may lag in the key file;
+	// persisting every request would rewrite it on each API call
+	lastUsedResolution = time.Minute
+)
+
+var (
+	// ErrKeyNotFound is returned for unknown key IDs and secrets
+	ErrKeyNotFound = errors.New("API key not found")
+
+	// ErrKeyRevoked is returned for revoked keys
+	ErrKeyRevoked = errors.New("API key has been revoked")
+
+	// ErrKeyExpired is returned for expired keys
+	ErrKeyExpired = errors.New("API key has expired")
+)
+
+// APIKey is the metadata of an API key. The key itself is only returned when
+// it is created; the store keeps its SHA-256 hash.
+type APIKey struct {
+	ID          string     `json:"id"`
+	Prefix      string     `json:"prefix"`
+	UserID      string     `json:"user_id"`
+	Role        string     `json:"role"`
+	Description string     `json:"description,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	// RotatedFrom is the ID of the key this one replaced
+	RotatedFrom string `json:"rotated_from,omitempty"`
+	// RotatedTo is the ID of the key that replaced this one
+	RotatedTo string `json:"rotated_to,omitempty"`
+}
+
+// Active reports whether the key authenticates requests at now
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// storedKey is one entry of the key file
+type storedKey struct {
+	APIKey
+	Hash string `json:"hash"`
+}
+
+// KeyStore manages API keys, persisted as JSON in a file readable by the
+// owner only. Keys are stored as SHA-256 hashes: they are random, so a slow
+// password hash adds nothing, and a leaked file does not leak usable keys.
+type KeyStore struct {
+	mu     sync.Mutex
+	path   string
+	keys   map[string]*storedKey
+	byHash map[string]*storedKey
+	// lastSaved is the last-used time of each key as last persisted
+	lastSaved map[string]time.Time
+	now       func() time.Time
+}
+
+// NewKeyStore opens the key store at path, creating its directory if
+// needed. A missing file is an empty store; an empty path keeps the keys in
+// memory only.
+func NewKeyStore(path string) (*KeyStore, error) {
+	s := &KeyStore{
+		path:      path,
+		keys:      make(map[string]*storedKey),
+		byHash:    make(map[string]*storedKey),
+		lastSaved: make(map[string]time.Time),
+		now:       time.Now,
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("creating key store directory: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading API keys: %w", err)
+	}
+
+	var keys []*storedKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("decoding API keys %s: %w", path, err)
+	}
+	for _, key := range keys {
+		s.keys[key.ID] = key
+		s.byHash[key.Hash] = key
+		if key.LastUsedAt != nil {
+			s.lastSaved[key.ID] = *key.LastUsedAt
+		}
+	}
+	return s, nil
+}
+
+// Create generates a new API key. A ttl of zero never expires. The key is
+// returned once and cannot be recovered later.
+func (s *KeyStore) Create(userID, role, description string, ttl time.Duration) (*APIKey, string, error) {
+	if !ValidRole(role) {
+		return nil, "", fmt.Errorf("invalid role: %s", role)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, secret, err := s.newKey(userID, role, description, ttl)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.add(key); err != nil {
+		return nil, "", err
+	}
+	info := key.APIKey
+	return &info, secret, nil
+}
+
+// Import stores a key chosen elsewhere, such as the default admin key from
+// the configuration, unless it is already known. It returns the metadata of
+// the stored key, which may have been revoked since.
+func (s *KeyStore) Import(secret, userID, role, description string) (*APIKey, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("API key is empty")
+	}
+	if !ValidRole(role) {
+		return nil, fmt.Errorf("invalid role: %s", role)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.byHash[hashKey(secret)]; ok {
+		info := existing.APIKey
+		return &info, nil
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	key := &storedKey{
+		APIKey: APIKey{
+			ID:          id,
+			Prefix:      keyPrefix(secret),
+			UserID:      userID,
+			Role:        role,
+			Description: description,
+			CreatedAt:   s.now(),
+		},
+		Hash: hashKey(secret),
+	}
+	if err := s.add(key); err != nil {
+		return nil, err
+	}
+	info := key.APIKey
+	return &info, nil
+}
+
+// Validate returns the key matching secret if it is active, and records its
+// use. Revoked and expired keys return ErrKeyRevoked or ErrKeyExpired along
+// with their metadata, so callers can tell which key was used.
+func (s *KeyStore) Validate(secret string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.byHash[hashKey(secret)]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	now := s.now()
+	if key.RevokedAt != nil {
+		info := key.APIKey
+		return &info, ErrKeyRevoked
+	}
+	if !key.Active(now) {
+		info := key.APIKey
+		return &info, ErrKeyExpired
+	}
+
+	key.LastUsedAt = &now
+	if now.Sub(s.lastSaved[key.ID]) >= lastUsedResolution {
+		// Losing a last-used time is harmless, so a failed save does not
+		// fail the request
+		if s.save() == nil {
+			s.lastSaved[key.ID] = now
+		}
+	}
+
+	info := key.APIKey
+	return &info, nil
+}
+
+// Get returns the metadata of a key
+func (s *KeyStore) Get(id string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	info := key.APIKey
+	return &info, nil
+}
+
+// List returns the metadata of all keys, of one user if userID is set,
+// oldest first
+func (s *KeyStore) List(userID string) []APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		if userID == "" || key.UserID == userID {
+			keys = append(keys, key.APIKey)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
+
+// Revoke stops a key from authenticating. Revoking a revoked key is a no-op.
+func (s *KeyStore) Revoke(id string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	if key.RevokedAt == nil {
+		now := s.now()
+		key.RevokedAt = &now
+		if err := s.save(); err != nil {
+			key.RevokedAt = nil
+			return nil, err
+		}
+	}
+	info := key.APIKey
+	return &info, nil
+}
+
+// Rotate replaces a key with a new one for the same user, role and
+// description. The old key keeps working for grace, so clients can switch
+// over, and is revoked right away without one. A ttl of zero never expires.
+func (s *KeyStore) Rotate(id string, ttl, grace time.Duration) (*APIKey, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.keys[id]
+	if !ok {
+		return nil, "", ErrKeyNotFound
+	}
+	now := s.now()
+	if !old.Active(now) {
+		return nil, "", fmt.Errorf("cannot rotate an inactive key: %w", ErrKeyRevoked)
+	}
+
+	key, secret, err := s.newKey(old.UserID, old.Role, old.Description, ttl)
+	if err != nil {
+		return nil, "", err
+	}
+	key.RotatedFrom = old.ID
+
+	previous := old.APIKey
+	old.RotatedTo = key.ID
+	if grace > 0 {
+		end := now.Add(grace)
+		if old.ExpiresAt == nil || end.Before(*old.ExpiresAt) {
+			old.ExpiresAt = &end
+		}
+	} else {
+		old.RevokedAt = &now
+	}
+
+	if err := s.add(key); err != nil {
+		old.APIKey = previous
+		return nil, "", err
+	}
+	info := key.APIKey
+	return &info, secret, nil
+}
+
+// CleanupExpired removes keys that expired or were revoked more than
+// retention ago, and returns how many it removed
+func (s *KeyStore) CleanupExpired(retention time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := s.now().Add(-retention)
+	var removed []*storedKey
+	for id, key := range s.keys {
+		if (key.RevokedAt != nil && key.RevokedAt.Before(cutoff)) ||
+			(key.ExpiresAt != nil && key.ExpiresAt.Before(cutoff)) {
+			removed = append(removed, key)
+			delete(s.keys, id)
+			delete(s.byHash, key.Hash)
+		}
+	}
+	if len(removed) == 0 {
+		return 0, nil
+	}
+	if err := s.save(); err != nil {
+		for _, key := range removed {
+			s.keys[key.ID] = key
+			s.byHash[key.Hash] = key
+		}
+		return 0, err
+	}
+	return len(removed), nil
+}
+
+// newKey generates a key and its metadata without storing it
+func (s *KeyStore) newKey(userID, role, description string, ttl time.Duration) (*storedKey, string, error) {
+	id, err := randomID()
+	if err != nil {
+		return nil, "", err
+	}
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	now := s.now()
+	key := &storedKey{
+		APIKey: APIKey{
+			ID:          id,
+			Prefix:      keyPrefix(secret),
+			UserID:      userID,
+			Role:        role,
+			Description: description,
+			CreatedAt:   now,
+		},
+		Hash: hashKey(secret),
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		key.ExpiresAt = &expires
+	}
+	return key, secret, nil
+}
+
+// add stores a new key and persists the store, undoing the change if that
+// fails
+func (s *KeyStore) add(key *storedKey) error {
+	s.keys[key.ID] = key
+	s.byHash[key.Hash] = key
+	if err := s.save(); err != nil {
+		delete(s.keys, key.ID)
+		delete(s.byHash, key.Hash)
+		return err
+	}
+	return nil
+}
+
+// save replaces the key file atomically
+func (s *KeyStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	keys := make([]*storedKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding API keys: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing API keys: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing API keys: %w", err)
+	}
+	return nil
+}
+
+// hashKey returns the hex SHA-256 of an API key
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// keyPrefix returns the start of a key, enough to tell keys apart in
+// listings. Imported keys may be short, so only a few characters of them
+// are shown.
+func keyPrefix(secret string) string {
+	if strings.HasPrefix(secret, apiKeyPrefix) && len(secret) >= len(apiKeyPrefix)+32 {
+		return secret[:len(apiKeyPrefix)+6]
+	}
+	return secret[:min(4, len(secret))]
+}
+
+// randomID returns a random key ID
+func randomID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate key ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClock is a settable clock for key stores
+type testClock struct{ t time.Time }
+
+func (c *testClock) now() time.Time          { return c.t }
+func (c *testClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestKeyStore(t *testing.T, path string) (*KeyStore, *testClock) {
+	t.Helper()
+	store, err := NewKeyStore(path)
+	require.NoError(t, err)
+	clock := &testClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store.now = clock.now
+	return store, clock
+}
+
+func TestKeyStoreCreateAndValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "api_keys.json")
+	store, clock := newTestKeyStore(t, path)
+
+	key, secret, err := store.Create("ci", RoleOperator, "deploy pipeline", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, apiKeyPrefix))
+	assert.True(t, strings.HasPrefix(secret, key.Prefix))
+	require.NotNil(t, key.ExpiresAt)
+	assert.Nil(t, key.LastUsedAt)
+
+	info, err := store.Validate(secret)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, info.ID)
+	assert.Equal(t, RoleOperator, info.Role)
+	require.NotNil(t, info.LastUsedAt)
+
+	_, err = store.Validate(secret + "x")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	_, _, err = store.Create("ci", "root", "", 0)
+	assert.Error(t, err)
+
+	// Only the hash is persisted, readable by the owner only
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), secret)
+	assert.Contains(t, string(data), hashKey(secret))
+	stat, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+
+	clock.advance(time.Hour)
+	_, err = store.Validate(secret)
+	assert.ErrorIs(t, err, ErrKeyExpired)
+}
+
+func TestKeyStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_keys.json")
+	store, clock := newTestKeyStore(t, path)
+
+	kept, keptSecret, err := store.Create("alice", RoleViewer, "", 0)
+	require.NoError(t, err)
+	revoked, revokedSecret, err := store.Create("bob", RoleAdmin, "", 0)
+	require.NoError(t, err)
+	_, err = store.Revoke(revoked.ID)
+	require.NoError(t, err)
+
+	// Last-used times are persisted at most once per resolution
+	_, err = store.Validate(keptSecret)
+	require.NoError(t, err)
+	firstUse := clock.t
+	clock.advance(10 * time.Second)
+	_, err = store.Validate(keptSecret)
+	require.NoError(t, err)
+
+	reopened, _ := newTestKeyStore(t, path)
+	info, err := reopened.Get(kept.ID)
+	require.NoError(t, err)
+	require.NotNil(t, info.LastUsedAt)
+	assert.True(t, info.LastUsedAt.Equal(firstUse))
+
+	_, err = reopened.Validate(keptSecret)
+	assert.NoError(t, err)
+	info, err = reopened.Validate(revokedSecret)
+	assert.ErrorIs(t, err, ErrKeyRevoked)
+	require.NotNil(t, info)
+	assert.Equal(t, revoked.ID, info.ID)
+
+	assert.Len(t, reopened.List(""), 2)
+	assert.Len(t, reopened.List("alice"), 1)
+	assert.Empty(t, reopened.List("carol"))
+}
+
+func TestKeyStoreRotate(t *testing.T) {
+	store, clock := newTestKeyStore(t, "")
+
+	old, oldSecret, err := store.Create("ci", RoleOperator, "deploy pipeline", 0)
+	require.NoError(t, err)
+
+	// With a grace period both keys work until it ends
+	rotated, secret, err := store.Rotate(old.ID, 24*time.Hour, time.Hour)
+	require.NoError(t, err)
+	assert.NotEqual(t, oldSecret, secret)
+	assert.Equal(t, old.ID, rotated.RotatedFrom)
+	assert.Equal(t, "ci", rotated.UserID)
+	assert.Equal(t, RoleOperator, rotated.Role)
+	assert.Equal(t, "deploy pipeline", rotated.Description)
+
+	info, err := store.Get(old.ID)
+	require.NoError(t, err)
+	assert.Equal(t, rotated.ID, info.RotatedTo)
+
+	_, err = store.Validate(oldSecret)
+	assert.NoError(t, err)
+	clock.advance(time.Hour)
+	_, err = store.Validate(oldSecret)
+	assert.ErrorIs(t, err, ErrKeyExpired)
+	_, err = store.Validate(secret)
+	assert.NoError(t, err)
+
+	// Without one the old key is revoked at once
+	again, _, err := store.Rotate(rotated.ID, 0, 0)
+	require.NoError(t, err)
+	assert.Nil(t, again.ExpiresAt)
+	_, err = store.Validate(secret)
+	assert.ErrorIs(t, err, ErrKeyRevoked)
+
+	_, _, err = store.Rotate(rotated.ID, 0, 0)
+	assert.Error(t, err)
+	_, _, err = store.Rotate("missing", 0, 0)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestKeyStoreImport(t *testing.T) {
+	store, _ := newTestKeyStore(t, "")
+
+	imported, err := store.Import("configured-admin-key", "admin", RoleAdmin, "Default admin key")
+	require.NoError(t, err)
+	assert.Equal(t, "conf", imported.Prefix)
+
+	info, err := store.Validate("configured-admin-key")
+	require.NoError(t, err)
+	assert.Equal(t, RoleAdmin, info.Role)
+
+	// Importing again keeps the existing record, revoked or not
+	_, err = store.Revoke(imported.ID)
+	require.NoError(t, err)
+	again, err := store.Import("configured-admin-key", "admin", RoleAdmin, "Default admin key")
+	require.NoError(t, err)
+	assert.Equal(t, imported.ID, again.ID)
+	assert.NotNil(t, again.RevokedAt)
+
+	_, err = store.Import("", "admin", RoleAdmin, "")
+	assert.Error(t, err)
+}
+
+func TestKeyStoreCleanupExpired(t *testing.T) {
+	store, clock := newTestKeyStore(t, "")
+
+	expiring, _, err := store.Create("a", RoleViewer, "", time.Hour)
+	require.NoError(t, err)
+	revoked, _, err := store.Create("b", RoleViewer, "", 0)
+	require.NoError(t, err)
+	_, err = store.Revoke(revoked.ID)
+	require.NoError(t, err)
+	_, _, err = store.Create("c", RoleViewer, "", 0)
+	require.NoError(t, err)
+
+	removed, err := store.CleanupExpired(24 * time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	clock.advance(48 * time.Hour)
+	removed, err = store.CleanupExpired(24 * time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	_, err = store.Get(expiring.ID)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Len(t, store.List(""), 1)
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+)
+
+// roleLevels orders the roles: admin > operator > viewer
+var roleLevels = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// ValidRole reports whether role is a known role
+func ValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// HasRole reports whether userRole grants requiredRole. Higher roles grant
+// the lower ones; unknown roles grant nothing.
+func HasRole(userRole, requiredRole string) bool {
+	userLevel, ok1 := roleLevels[userRole]
+	requiredLevel, ok2 := roleLevels[requiredRole]
+	if !ok1 || !ok2 {
+		return false
+	}
+	return userLevel >= requiredLevel
+}
+
+// RoleFromContext returns the role of an authenticated request, taken from
+// its JWT claims or API token
+func RoleFromContext(ctx context.Context) (string, bool) {
+	if claims, ok := GetClaimsFromContext(ctx); ok {
+		return claims.Role, true
+	}
+	if info, ok := GetTokenInfoFromContext(ctx); ok {
+		return info.Role, true
+	}
+	return "", false
+}
+
+// RoleRule requires a role for the requests it matches
+type RoleRule struct {
+	// Methods limits the rule to these methods; empty matches every method
+	Methods []string
+	// Path matches itself and every path below it; empty matches every path
+	Path string
+	// Role is the role required
+	Role string
+	// Public requests need no authentication and no role
+	Public bool
+}
+
+// matches reports whether the rule applies to a request
+func (r RoleRule) matches(method, path string) bool {
+	if len(r.Methods) > 0 {
+		found := false
+		for _, m := range r.Methods {
+			if strings.EqualFold(m, method) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.Path == "" || path == r.Path {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(r.Path, "/")+"/")
+}
+
+// Policy maps requests to the role they require. The first matching rule
+// applies; requests no rule matches require DefaultRole.
+type Policy struct {
+	Rules       []RoleRule
+	DefaultRole string
+}
+
+// Validate checks that every rule names a known role
+func (p Policy) Validate() error {
+	for i, rule := range p.Rules {
+		if !rule.Public && !ValidRole(rule.Role) {
+			return fmt.Errorf("rule %d (%s): invalid role %q", i, rule.Path, rule.Role)
+		}
+	}
+	if !ValidRole(p.DefaultRole) {
+		return fmt.Errorf("invalid default role %q", p.DefaultRole)
+	}
+	return nil
+}
+
+// RequiredRole returns the role a request needs, with public true if it
+// needs no authentication at all
+func (p Policy) RequiredRole(method, path string) (role string, public bool) {
+	for _, rule := range p.Rules {
+		if rule.matches(method, path) {
+			return rule.Role, rule.Public
+		}
+	}
+	return p.DefaultRole, false
+}
+
+// IsPublic reports whether a request needs no authentication
+func (p Policy) IsPublic(r *http.Request) bool {
+	_, public := p.RequiredRole(r.Method, r.URL.Path)
+	return public
+}
+
+// HTTPMiddleware creates an HTTP middleware enforcing the policy. It must be
+// installed after an authentication middleware that stores JWT claims or
+// token info in the request context.
+func (p Policy) HTTPMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			required, public := p.RequiredRole(r.Method, r.URL.Path)
+			if public {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			role, ok := RoleFromContext(r.Context())
+			if !ok {
+				problem.Error(w, r, "Authentication required", http.StatusUnauthorized)
+				return
+			}
+			if !HasRole(role, required) {
+				problem.Error(w, r, fmt.Sprintf("Insufficient permissions: %s role required", required), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
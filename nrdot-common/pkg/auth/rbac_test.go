@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasRole(t *testing.T) {
+	assert.True(t, HasRole(RoleAdmin, RoleViewer))
+	assert.True(t, HasRole(RoleOperator, RoleOperator))
+	assert.False(t, HasRole(RoleViewer, RoleOperator))
+	assert.False(t, HasRole(RoleOperator, RoleAdmin))
+	assert.False(t, HasRole("", RoleViewer))
+	assert.False(t, HasRole("root", RoleViewer))
+	assert.False(t, HasRole(RoleAdmin, "root"))
+
+	assert.True(t, ValidRole(RoleViewer))
+	assert.False(t, ValidRole("Admin"))
+}
+
+func testPolicy() Policy {
+	return Policy{
+		Rules: []RoleRule{
+			{Path: "/health", Public: true},
+			{Path: "/v1/tokens", Role: RoleAdmin},
+			{Methods: []string{"GET"}, Role: RoleViewer},
+		},
+		DefaultRole: RoleOperator,
+	}
+}
+
+func TestPolicyRequiredRole(t *testing.T) {
+	policy := testPolicy()
+	require.NoError(t, policy.Validate())
+
+	tests := []struct {
+		method string
+		path   string
+		role   string
+		public bool
+	}{
+		{"GET", "/health", "", true},
+		{"GET", "/healthz", RoleViewer, false},
+		{"GET", "/v1/tokens", RoleAdmin, false},
+		{"DELETE", "/v1/tokens/abc", RoleAdmin, false},
+		{"GET", "/v1/status", RoleViewer, false},
+		{"get", "/v1/status", RoleViewer, false},
+		{"POST", "/v1/config", RoleOperator, false},
+		{"POST", "/v1/control/reload", RoleOperator, false},
+	}
+	for _, tt := range tests {
+		role, public := policy.RequiredRole(tt.method, tt.path)
+		assert.Equal(t, tt.role, role, "%s %s", tt.method, tt.path)
+		assert.Equal(t, tt.public, public, "%s %s", tt.method, tt.path)
+	}
+
+	assert.Error(t, Policy{DefaultRole: "root"}.Validate())
+	assert.Error(t, Policy{Rules: []RoleRule{{Path: "/x"}}, DefaultRole: RoleAdmin}.Validate())
+}
+
+func TestPolicyHTTPMiddleware(t *testing.T) {
+	handler := testPolicy().HTTPMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(method, path string, ctx context.Context) int {
+		req := httptest.NewRequest(method, path, nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	withClaims := func(role string) context.Context {
+		return context.WithValue(context.Background(), "claims", &JWTClaims{Role: role})
+	}
+	withTokenInfo := func(role string) context.Context {
+		return context.WithValue(context.Background(), "tokenInfo", &TokenInfo{Role: role})
+	}
+
+	// Public routes need no role, everything else an authenticated one
+	assert.Equal(t, http.StatusOK, do("GET", "/health", context.Background()))
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "/v1/status", context.Background()))
+
+	// Roles from JWT claims
+	assert.Equal(t, http.StatusOK, do("GET", "/v1/status", withClaims(RoleViewer)))
+	assert.Equal(t, http.StatusForbidden, do("POST", "/v1/config", withClaims(RoleViewer)))
+	assert.Equal(t, http.StatusOK, do("POST", "/v1/config", withClaims(RoleOperator)))
+	assert.Equal(t, http.StatusForbidden, do("GET", "/v1/tokens", withClaims(RoleOperator)))
+	assert.Equal(t, http.StatusOK, do("GET", "/v1/tokens", withClaims(RoleAdmin)))
+	assert.Equal(t, http.StatusForbidden, do("GET", "/v1/status", withClaims("")))
+
+	// Roles from API token records
+	assert.Equal(t, http.StatusOK, do("GET", "/v1/status", withTokenInfo(RoleViewer)))
+	assert.Equal(t, http.StatusForbidden, do("POST", "/v1/control/reload", withTokenInfo(RoleViewer)))
+	assert.Equal(t, http.StatusOK, do("DELETE", "/v1/tokens/abc", withTokenInfo(RoleAdmin)))
+}
@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
 )
 
 // TokenStore manages API tokens and their metadata
@@ -179,7 +181,7 @@ func (s *TokenStore) HTTPMiddleware(requiredRole string) func(http.Handler) http
 				// Also check X-API-Key header
 				authHeader = r.Header.Get("X-API-Key")
 				if authHeader == "" {
-					http.Error(w, "API key required", http.StatusUnauthorized)
+					problem.Error(w, r, "API key required", http.StatusUnauthorized)
 					return
 				}
 			}
@@ -193,13 +195,13 @@ func (s *TokenStore) HTTPMiddleware(requiredRole string) func(http.Handler) http
 			// Validate token
 			info, err := s.ValidateToken(token)
 			if err != nil {
-				http.Error(w, fmt.Sprintf("Invalid token: %v", err), http.StatusUnauthorized)
+				problem.Error(w, r, fmt.Sprintf("Invalid token: %v", err), http.StatusUnauthorized)
 				return
 			}
 
 			// Check role if required
-			if requiredRole != "" && !hasRequiredRole(info.Role, requiredRole) {
-				http.Error(w, "Insufficient permissions", http.StatusForbidden)
+			if requiredRole != "" && !HasRole(info.Role, requiredRole) {
+				problem.Error(w, r, "Insufficient permissions", http.StatusForbidden)
 				return
 			}
 
@@ -215,22 +217,3 @@ func GetTokenInfoFromContext(ctx context.Context) (*TokenInfo, bool) {
 	info, ok := ctx.Value("tokenInfo").(*TokenInfo)
 	return info, ok
 }
-
-// hasRequiredRole checks if user role meets the required role
-func hasRequiredRole(userRole, requiredRole string) bool {
-	// Role hierarchy: admin > operator > viewer
-	roleLevel := map[string]int{
-		RoleViewer:   1,
-		RoleOperator: 2,
-		RoleAdmin:    3,
-	}
-
-	userLevel, ok1 := roleLevel[userRole]
-	requiredLevel, ok2 := roleLevel[requiredRole]
-
-	if !ok1 || !ok2 {
-		return false
-	}
-
-	return userLevel >= requiredLevel
-}
\ No newline at end of file
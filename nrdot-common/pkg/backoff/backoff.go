@@ -0,0 +1,224 @@
+// Package backoff computes retry delays that grow exponentially, with
+// optional jitter, bounded by a number of retries and a time budget, and
+// retries operations with them. Every component retrying something, from
+// collector restarts to webhook deliveries, uses it so they back off alike.
+package backoff
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
+)
+
+// DefaultMultiplier is the growth of the delay per retry when the policy
+// does not set one
+const DefaultMultiplier = 2.0
+
+// Policy describes how delays grow and when to give up
+type Policy struct {
+	// Initial is the delay before the first retry
+	Initial time.Duration
+
+	// Max caps each delay, jitter included; zero is no cap
+	Max time.Duration
+
+	// Multiplier is the growth of the delay per retry, DefaultMultiplier
+	// when zero
+	Multiplier float64
+
+	// Jitter randomizes each delay within ±Jitter of it, e.g. 0.2 for
+	// ±20%, so clients failing together do not retry together; zero is
+	// no jitter
+	Jitter float64
+
+	// MaxRetries is the number of retries after the first attempt; zero
+	// is unlimited, negative is none
+	MaxRetries int
+
+	// MaxElapsed is the time budget from the first attempt; no retry is
+	// scheduled past it. Zero is unlimited.
+	MaxElapsed time.Duration
+}
+
+func (p Policy) multiplier() float64 {
+	if p.Multiplier < 1 {
+		return DefaultMultiplier
+	}
+	return p.Multiplier
+}
+
+// grow returns the delay after d, capped at Max
+func (p Policy) grow(d time.Duration) time.Duration {
+	next := float64(d) * p.multiplier()
+	if next >= math.MaxInt64 {
+		next = math.MaxInt64
+	}
+	if p.Max > 0 && time.Duration(next) > p.Max {
+		return p.Max
+	}
+	return time.Duration(next)
+}
+
+// random draws jitter, replaced in tests
+var random = rand.Float64
+
+// jitter randomizes d by the policy's jitter, capped at Max
+func (p Policy) jitter(d time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return d
+	}
+	factor := min(p.Jitter, 1)
+	d = time.Duration(float64(d) * (1 - factor + 2*factor*random()))
+	if p.Max > 0 && d > p.Max {
+		return p.Max
+	}
+	return d
+}
+
+// Backoff hands out the delays of a policy, one per retry. It is safe for
+// concurrent use.
+type Backoff struct {
+	policy Policy
+	clock  clock.Clock
+
+	mu      sync.Mutex
+	current time.Duration
+	retries int
+	start   time.Time
+}
+
+// New starts a sequence of retries of policy; the time budget runs from now
+// on c. A nil c is the wall clock.
+func New(policy Policy, c clock.Clock) *Backoff {
+	if c == nil {
+		c = clock.Real()
+	}
+	b := &Backoff{policy: policy, clock: c}
+	b.reset()
+	return b
+}
+
+// Next returns the delay before the next retry. ok is false once the
+// retries or the time budget are used up.
+func (b *Backoff) Next() (delay time.Duration, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.policy.MaxRetries < 0 || (b.policy.MaxRetries > 0 && b.retries >= b.policy.MaxRetries) {
+		return 0, false
+	}
+	delay = b.policy.jitter(b.current)
+	if b.policy.MaxElapsed > 0 && b.clock.Since(b.start)+delay > b.policy.MaxElapsed {
+		return 0, false
+	}
+
+	b.retries++
+	b.current = b.policy.grow(b.current)
+	return delay, true
+}
+
+// Retries returns the number of delays handed out since the start
+func (b *Backoff) Retries() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.retries
+}
+
+// Reset starts over from the initial delay, with a new time budget
+func (b *Backoff) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reset()
+}
+
+// reset must be called with b.mu held
+func (b *Backoff) reset() {
+	b.current = b.policy.Initial
+	b.retries = 0
+	b.start = b.clock.Now()
+}
+
+// permanentError stops Retry
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying: Retry returns it at once
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Retry calls op until it succeeds, fails with a Permanent error, the policy
+// gives up or ctx is done, waiting on the wall clock between attempts. It
+// returns nil, the last error of op, or ctx's error if ctx ended the wait.
+func Retry(ctx context.Context, policy Policy, op func(ctx context.Context) error) error {
+	return RetryOn(ctx, clock.Real(), policy, op)
+}
+
+// RetryOn is Retry, waiting on c
+func RetryOn(ctx context.Context, c clock.Clock, policy Policy, op func(ctx context.Context) error) error {
+	b := New(policy, c)
+	for {
+		err := op(ctx)
+		if err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+
+		delay, ok := b.Next()
+		if !ok {
+			return err
+		}
+		timer := c.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
+
+// RetryOnFailure renders the policy as the retry_on_failure settings of a
+// collector exporter. The collector has no retry count, so MaxRetries
+// becomes the time budget the retries take, unless MaxElapsed is set.
+func (p Policy) RetryOnFailure() map[string]interface{} {
+	if p.MaxRetries < 0 {
+		return map[string]interface{}{"enabled": false}
+	}
+	config := map[string]interface{}{
+		"enabled":              true,
+		"initial_interval":     p.Initial.String(),
+		"multiplier":           p.multiplier(),
+		"randomization_factor": min(max(p.Jitter, 0), 1),
+	}
+	if p.Max > 0 {
+		config["max_interval"] = p.Max.String()
+	}
+
+	budget := p.MaxElapsed
+	if budget == 0 && p.MaxRetries > 0 {
+		delay := p.Initial
+		for i := 0; i < p.MaxRetries; i++ {
+			budget += delay
+			delay = p.grow(delay)
+		}
+	}
+	// Zero retries forever
+	config["max_elapsed_time"] = budget.String()
+	return config
+}
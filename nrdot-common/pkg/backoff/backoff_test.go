@@ -0,0 +1,159 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// delays returns the delays b hands out until it gives up, at most n
+func delays(b *Backoff, n int) []time.Duration {
+	var out []time.Duration
+	for i := 0; i < n; i++ {
+		delay, ok := b.Next()
+		if !ok {
+			break
+		}
+		out = append(out, delay)
+	}
+	return out
+}
+
+func TestBackoffNext(t *testing.T) {
+	b := New(Policy{Initial: time.Second, Max: 10 * time.Second}, clock.NewFake(start))
+	assert.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second,
+	}, delays(b, 6))
+	assert.Equal(t, 6, b.Retries())
+
+	b.Reset()
+	assert.Equal(t, []time.Duration{time.Second}, delays(b, 1))
+
+	b = New(Policy{Initial: 100 * time.Millisecond, Multiplier: 1.5, MaxRetries: 3}, clock.NewFake(start))
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 150 * time.Millisecond, 225 * time.Millisecond}, delays(b, 10))
+
+	b = New(Policy{Initial: time.Second, MaxRetries: -1}, clock.NewFake(start))
+	assert.Empty(t, delays(b, 1))
+}
+
+func TestBackoffJitter(t *testing.T) {
+	defer func(r func() float64) { random = r }(random)
+
+	policy := Policy{Initial: 10 * time.Second, Max: 11 * time.Second, Jitter: 0.2}
+	for r, want := range map[float64]time.Duration{
+		0:   8 * time.Second,
+		0.5: 10 * time.Second,
+		1:   11 * time.Second, // 12s capped at Max
+	} {
+		random = func() float64 { return r }
+		delay, ok := New(policy, clock.NewFake(start)).Next()
+		require.True(t, ok)
+		assert.Equal(t, want, delay, r)
+	}
+}
+
+func TestBackoffMaxElapsed(t *testing.T) {
+	fake := clock.NewFake(start)
+	b := New(Policy{Initial: time.Second, MaxElapsed: 10 * time.Second}, fake)
+
+	// 1s, 2s and 4s fit in the budget; 8s more would end at 15s
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		delay, ok := b.Next()
+		require.True(t, ok)
+		assert.Equal(t, want, delay)
+		fake.Advance(delay)
+	}
+	_, ok := b.Next()
+	assert.False(t, ok)
+
+	// Reset restarts the budget
+	b.Reset()
+	_, ok = b.Next()
+	assert.True(t, ok)
+}
+
+func TestRetry(t *testing.T) {
+	fake := clock.NewFake(start)
+	policy := Policy{Initial: time.Second, MaxRetries: 3}
+
+	// Succeeds on the third attempt
+	attempts := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- RetryOn(context.Background(), fake, policy, func(ctx context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("unavailable")
+			}
+			return nil
+		})
+	}()
+	for i := 0; i < 2; i++ {
+		fake.BlockUntil(1)
+		fake.Advance(time.Hour)
+	}
+	require.NoError(t, <-done)
+	assert.Equal(t, 3, attempts)
+
+	// Gives up after the retries, returning the last error
+	attempts = 0
+	go func() {
+		done <- RetryOn(context.Background(), fake, policy, func(ctx context.Context) error {
+			attempts++
+			return errors.New("unavailable")
+		})
+	}()
+	for i := 0; i < 3; i++ {
+		fake.BlockUntil(1)
+		fake.Advance(time.Hour)
+	}
+	assert.EqualError(t, <-done, "unavailable")
+	assert.Equal(t, 4, attempts)
+
+	// A permanent error is not retried
+	attempts = 0
+	err := RetryOn(context.Background(), fake, policy, func(ctx context.Context) error {
+		attempts++
+		return Permanent(errors.New("unauthorized"))
+	})
+	assert.EqualError(t, err, "unauthorized")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryCanceled(t *testing.T) {
+	fake := clock.NewFake(start)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- RetryOn(ctx, fake, Policy{Initial: time.Second}, func(ctx context.Context) error {
+			return errors.New("unavailable")
+		})
+	}()
+	fake.BlockUntil(1)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestRetryOnFailure(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{
+		"enabled":              true,
+		"initial_interval":     "5s",
+		"max_interval":         "30s",
+		"multiplier":           2.0,
+		"randomization_factor": 0.5,
+		"max_elapsed_time":     "5m0s",
+	}, Policy{Initial: 5 * time.Second, Max: 30 * time.Second, Jitter: 0.5, MaxElapsed: 5 * time.Minute}.RetryOnFailure())
+
+	// Three retries take 5s + 10s + 20s
+	config := Policy{Initial: 5 * time.Second, MaxRetries: 3}.RetryOnFailure()
+	assert.Equal(t, "35s", config["max_elapsed_time"])
+	assert.NotContains(t, config, "max_interval")
+}
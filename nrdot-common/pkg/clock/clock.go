@@ -0,0 +1,80 @@
+// Package clock abstracts the passage of time, so components that depend on
+// it (reset intervals, backoff, health check intervals) can be driven by a
+// Fake clock in tests instead of sleeping.
+package clock
+
+import "time"
+
+// Clock tells the time and creates timers and tickers
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// Since returns the time elapsed since t
+	Since(t time.Time) time.Duration
+
+	// After waits for d to elapse and then sends the current time on the
+	// returned channel
+	After(d time.Duration) <-chan time.Time
+
+	// NewTimer creates a Timer that sends the current time on its channel
+	// after d
+	NewTimer(d time.Duration) Timer
+
+	// NewTicker creates a Ticker that sends the current time on its channel
+	// every d. It panics if d is not positive, like time.NewTicker.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a single event, like time.Timer
+type Timer interface {
+	// C returns the channel the time is delivered on
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing. It returns false if the timer
+	// already fired or was stopped.
+	Stop() bool
+
+	// Reset changes the timer to fire after d. It returns true if the timer
+	// had been active.
+	Reset(d time.Duration) bool
+}
+
+// Ticker delivers ticks at intervals, like time.Ticker. Ticks are dropped
+// for slow receivers.
+type Ticker interface {
+	// C returns the channel the ticks are delivered on
+	C() <-chan time.Time
+
+	// Stop turns off the ticker
+	Stop()
+
+	// Reset stops the ticker and resets its period to d
+	Reset(d time.Duration)
+}
+
+// Real returns the wall clock
+func Real() Clock {
+	return realClock{}
+}
+
+// realClock is the wall clock, backed by package time
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTimer(d time.Duration) Timer         { return realTimer{time.NewTimer(d)} }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time        { return t.t.C }
+func (t realTimer) Stop() bool                 { return t.t.Stop() }
+func (t realTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time   { return t.t.C }
+func (t realTicker) Stop()                 { t.t.Stop() }
+func (t realTicker) Reset(d time.Duration) { t.t.Reset(d) }
@@ -0,0 +1,139 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// received returns what ch holds without waiting
+func received(ch <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-ch:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFakeNow(t *testing.T) {
+	f := NewFake(start)
+	assert.Equal(t, start, f.Now())
+
+	f.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), f.Now())
+	assert.Equal(t, time.Minute, f.Since(start))
+
+	f.Set(start)
+	assert.Equal(t, start, f.Now())
+}
+
+func TestFakeTimer(t *testing.T) {
+	f := NewFake(start)
+	timer := f.NewTimer(10 * time.Second)
+	assert.Equal(t, 1, f.Waiters())
+
+	f.Advance(9 * time.Second)
+	_, fired := received(timer.C())
+	assert.False(t, fired)
+
+	// The timer sees the time it was due, not the end of the advance
+	f.Advance(5 * time.Second)
+	at, fired := received(timer.C())
+	require.True(t, fired)
+	assert.Equal(t, start.Add(10*time.Second), at)
+	assert.Equal(t, 0, f.Waiters())
+	assert.False(t, timer.Stop())
+
+	// Reset rearms it relative to now
+	assert.False(t, timer.Reset(time.Second))
+	assert.True(t, timer.Stop())
+	f.Advance(time.Hour)
+	_, fired = received(timer.C())
+	assert.False(t, fired, "stopped timer fired")
+
+	// After is a one-off timer
+	after := f.After(time.Second)
+	f.Set(f.Now().Add(time.Second))
+	_, fired = received(after)
+	assert.True(t, fired)
+}
+
+func TestFakeTicker(t *testing.T) {
+	f := NewFake(start)
+	ticker := f.NewTicker(time.Minute)
+
+	f.Advance(time.Minute)
+	at, fired := received(ticker.C())
+	require.True(t, fired)
+	assert.Equal(t, start.Add(time.Minute), at)
+
+	// Ticks are dropped for slow receivers, like time.Ticker
+	f.Advance(3 * time.Minute)
+	at, fired = received(ticker.C())
+	require.True(t, fired)
+	assert.Equal(t, start.Add(2*time.Minute), at)
+	_, fired = received(ticker.C())
+	assert.False(t, fired)
+
+	ticker.Reset(time.Hour)
+	f.Advance(59 * time.Minute)
+	_, fired = received(ticker.C())
+	assert.False(t, fired)
+	f.Advance(time.Minute)
+	_, fired = received(ticker.C())
+	assert.True(t, fired)
+
+	ticker.Stop()
+	assert.Equal(t, 0, f.Waiters())
+	assert.Panics(t, func() { f.NewTicker(0) })
+}
+
+func TestFakeOrder(t *testing.T) {
+	f := NewFake(start)
+	late := f.NewTimer(2 * time.Second)
+	ticker := f.NewTicker(time.Second)
+	early := f.NewTimer(time.Second)
+
+	// Everything due fires within one advance, each at its own deadline
+	f.Advance(2 * time.Second)
+	at, _ := received(early.C())
+	assert.Equal(t, start.Add(time.Second), at)
+	at, _ = received(late.C())
+	assert.Equal(t, start.Add(2*time.Second), at)
+	at, _ = received(ticker.C())
+	assert.Equal(t, start.Add(time.Second), at, "second tick dropped while the first was unread")
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	f := NewFake(start)
+	done := make(chan time.Time)
+	go func() {
+		done <- <-f.After(time.Minute)
+	}()
+
+	// Advancing before the goroutine waits on the clock would be lost
+	f.BlockUntil(1)
+	f.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), <-done)
+}
+
+func TestReal(t *testing.T) {
+	c := Real()
+	before := time.Now()
+	assert.False(t, c.Now().Before(before))
+
+	ticker := c.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	<-ticker.C()
+
+	timer := c.NewTimer(time.Millisecond)
+	<-timer.C()
+	assert.False(t, timer.Stop())
+	<-c.After(time.Millisecond)
+	assert.GreaterOrEqual(t, c.Since(before), 3*time.Millisecond)
+}
@@ -0,0 +1,214 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only moves when told to. Timers and tickers fire
+// from Advance and Set, in deadline order, each seeing the time it was due.
+// Use BlockUntil to wait for the code under test to start waiting on the
+// clock before advancing it.
+type Fake struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a pending timer or ticker; period is zero for timers
+type fakeWaiter struct {
+	deadline time.Time
+	period   time.Duration
+	ch       chan time.Time
+}
+
+// NewFake returns a fake clock set to now
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now returns the fake time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Since returns the fake time elapsed since t
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// After returns a channel that receives the fake time once the clock has
+// advanced by d
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+// NewTimer returns a timer that fires once the clock has advanced by d
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: f, w: &fakeWaiter{ch: make(chan time.Time, 1)}}
+	t.Reset(d)
+	return t
+}
+
+// NewTicker returns a ticker that fires every time the clock advances by d
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	t := &fakeTicker{clock: f, w: &fakeWaiter{ch: make(chan time.Time, 1)}}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing the timers and tickers that
+// come due on the way
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.advanceLocked(f.now.Add(d))
+}
+
+// Set moves the clock to t, firing the timers and tickers due by then.
+// Moving it backwards fires nothing.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if t.Before(f.now) {
+		f.now = t
+		return
+	}
+	f.advanceLocked(t)
+}
+
+// BlockUntil waits until n timers and tickers are pending on the clock
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+// Waiters returns the number of pending timers and tickers
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// advanceLocked must be called with f.mu held
+func (f *Fake) advanceLocked(target time.Time) {
+	for {
+		sort.SliceStable(f.waiters, func(i, j int) bool {
+			return f.waiters[i].deadline.Before(f.waiters[j].deadline)
+		})
+		if len(f.waiters) == 0 || f.waiters[0].deadline.After(target) {
+			break
+		}
+
+		w := f.waiters[0]
+		f.now = w.deadline
+		select {
+		case w.ch <- f.now:
+		default:
+			// The receiver has not taken the last tick yet
+		}
+		if w.period > 0 {
+			w.deadline = w.deadline.Add(w.period)
+		} else {
+			f.removeLocked(w)
+		}
+	}
+	f.now = target
+}
+
+// addLocked must be called with f.mu held
+func (f *Fake) addLocked(w *fakeWaiter) {
+	f.waiters = append(f.waiters, w)
+	f.cond.Broadcast()
+}
+
+// removeLocked must be called with f.mu held; reports whether w was pending
+func (f *Fake) removeLocked(w *fakeWaiter) bool {
+	for i, pending := range f.waiters {
+		if pending == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fakeTimer is a Timer of a Fake clock
+type fakeTimer struct {
+	clock *Fake
+	w     *fakeWaiter
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.w.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.removeLocked(t.w)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	active := t.clock.removeLocked(t.w)
+	t.w.deadline = t.clock.now.Add(d)
+	if d <= 0 {
+		select {
+		case t.w.ch <- t.clock.now:
+		default:
+		}
+		return active
+	}
+	t.clock.addLocked(t.w)
+	return active
+}
+
+// fakeTicker is a Ticker of a Fake clock
+type fakeTicker struct {
+	clock *Fake
+	w     *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.w.ch
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.clock.removeLocked(t.w)
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("clock: non-positive interval for Ticker.Reset")
+	}
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.clock.removeLocked(t.w)
+	t.w.period = d
+	t.w.deadline = t.clock.now.Add(d)
+	t.clock.addLocked(t.w)
+}
+
+// Ensure the clocks implement Clock
+var (
+	_ Clock = realClock{}
+	_ Clock = (*Fake)(nil)
+)
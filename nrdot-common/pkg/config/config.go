@@ -0,0 +1,115 @@
+// Package config reads the settings of the NRDOT-HOST agent itself from the
+// configuration file: where it keeps its state, and how it discovers and
+// configures the host's services. The collector settings of the same file
+// are validated and rendered by nrdot-schema and nrdot-config-engine.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultDataDir is where the agent keeps its state
+const DefaultDataDir = "/var/lib/nrdot"
+
+// Config represents the agent's settings
+type Config struct {
+	LicenseKey string             `yaml:"license_key,omitempty"`
+	AutoConfig AutoConfigSettings `yaml:"auto_config,omitempty"`
+	Kubernetes KubernetesSettings `yaml:"kubernetes,omitempty"`
+
+	// DataDir holds the discovery history and the cache of generated
+	// configurations
+	DataDir string `yaml:"-"`
+	// ConfigPath is the file the settings were read from, which generated
+	// configurations are written to
+	ConfigPath string `yaml:"-"`
+}
+
+// AutoConfigSettings defines the discovery of the host's services and the
+// generation of their receivers
+type AutoConfigSettings struct {
+	Enabled bool `yaml:"enabled"`
+	// ScanInterval is the time between discovery scans, 5m by default
+	ScanInterval time.Duration `yaml:"scan_interval,omitempty"`
+	// AddAfterScans is how many consecutive scans a new service must be
+	// seen in before it is configured, 2 by default
+	AddAfterScans int `yaml:"add_after_scans,omitempty"`
+	// RemoveAfterScans is how many consecutive scans a configured service
+	// must be missing from before it is removed, 3 by default
+	RemoveAfterScans int                  `yaml:"remove_after_scans,omitempty"`
+	LANDiscovery     LANDiscoverySettings `yaml:"lan_discovery,omitempty"`
+	VersionProbes    VersionProbeSettings `yaml:"version_probes,omitempty"`
+}
+
+// LANDiscoverySettings defines the discovery of devices announced on the
+// local network over mDNS and SSDP
+type LANDiscoverySettings struct {
+	Enabled      bool          `yaml:"enabled"`
+	Timeout      time.Duration `yaml:"timeout,omitempty"`
+	ServiceTypes []string      `yaml:"service_types,omitempty"`
+	SSDP         bool          `yaml:"ssdp,omitempty"`
+}
+
+// VersionProbeSettings defines the probes asking discovered services for
+// their versions
+type VersionProbeSettings struct {
+	Enabled bool          `yaml:"enabled"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// KubernetesSettings defines the discovery of the workloads of the node's
+// pods and the collection of kubelet stats
+type KubernetesSettings struct {
+	Enabled            bool   `yaml:"enabled"`
+	KubeletEndpoint    string `yaml:"kubelet_endpoint,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+}
+
+// Load reads the agent's settings from the configuration file at path,
+// ignoring the collector settings. DataDir is DefaultDataDir.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	config.DataDir = DefaultDataDir
+	config.ConfigPath = path
+	return config, nil
+}
+
+// Validate checks the settings. Zero values are defaults.
+func (c *Config) Validate() error {
+	autoConfig := c.AutoConfig
+	if autoConfig.ScanInterval < 0 {
+		return errors.New("auto_config.scan_interval must not be negative")
+	}
+	if autoConfig.AddAfterScans < 0 || autoConfig.RemoveAfterScans < 0 {
+		return errors.New("auto_config.add_after_scans and remove_after_scans must not be negative")
+	}
+	if autoConfig.LANDiscovery.Timeout < 0 {
+		return errors.New("auto_config.lan_discovery.timeout must not be negative")
+	}
+	if autoConfig.VersionProbes.Timeout < 0 {
+		return errors.New("auto_config.version_probes.timeout must not be negative")
+	}
+	if endpoint := c.Kubernetes.KubeletEndpoint; endpoint != "" {
+		if u, err := url.Parse(endpoint); err != nil || u.Host == "" {
+			return fmt.Errorf("kubernetes.kubelet_endpoint %q is not a URL", endpoint)
+		}
+	}
+	return nil
+}
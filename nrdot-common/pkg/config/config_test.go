@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+service:
+  name: node-01
+license_key: test-license-key
+auto_config:
+  enabled: true
+  scan_interval: 2m
+  add_after_scans: 1
+  remove_after_scans: 5
+  lan_discovery:
+    enabled: true
+    timeout: 500ms
+    service_types: [_ipp._tcp]
+    ssdp: true
+  version_probes:
+    enabled: true
+    timeout: 1s
+kubernetes:
+  enabled: true
+  kubelet_endpoint: https://node-01:10250
+  insecure_skip_verify: true
+`)
+
+	config, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, &Config{
+		LicenseKey: "test-license-key",
+		AutoConfig: AutoConfigSettings{
+			Enabled:          true,
+			ScanInterval:     2 * time.Minute,
+			AddAfterScans:    1,
+			RemoveAfterScans: 5,
+			LANDiscovery: LANDiscoverySettings{
+				Enabled:      true,
+				Timeout:      500 * time.Millisecond,
+				ServiceTypes: []string{"_ipp._tcp"},
+				SSDP:         true,
+			},
+			VersionProbes: VersionProbeSettings{Enabled: true, Timeout: time.Second},
+		},
+		Kubernetes: KubernetesSettings{
+			Enabled:            true,
+			KubeletEndpoint:    "https://node-01:10250",
+			InsecureSkipVerify: true,
+		},
+		DataDir:    DefaultDataDir,
+		ConfigPath: path,
+	}, config)
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	config, err := Load(writeConfig(t, "service:\n  name: node-01\n"))
+	require.NoError(t, err)
+	assert.False(t, config.AutoConfig.Enabled)
+	assert.False(t, config.Kubernetes.Enabled)
+	assert.Zero(t, config.AutoConfig.ScanInterval)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"unitless interval":  "auto_config: {scan_interval: 300}",
+		"negative interval":  "auto_config: {scan_interval: -5m}",
+		"negative scans":     "auto_config: {remove_after_scans: -1}",
+		"negative timeout":   "auto_config: {version_probes: {timeout: -1s}}",
+		"endpoint not a URL": "kubernetes: {kubelet_endpoint: node-01:10250}",
+		"enabled not a bool": "kubernetes: {enabled: sometimes}",
+	} {
+		_, err := Load(writeConfig(t, content))
+		assert.Error(t, err, name)
+	}
+
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -6,16 +6,25 @@ import (
 
 // Config represents the complete NRDOT configuration
 type Config struct {
-	Version      int                    `json:"version"`
-	Service      ServiceConfig          `json:"service"`
-	LicenseKey   string                 `json:"license_key,omitempty"`
-	Metrics      MetricsConfig          `json:"metrics"`
-	Traces       TracesConfig           `json:"traces"`
-	Logs         LogsConfig             `json:"logs"`
-	Security     SecurityConfig         `json:"security"`
-	Processing   ProcessingConfig       `json:"processing"`
-	Export       ExportConfig           `json:"export"`
-	Advanced     map[string]interface{} `json:"advanced,omitempty"`
+	// ConfigVersion is the version of the configuration format. Configs
+	// without it are version 1 and upgraded when they are loaded.
+	ConfigVersion int                    `json:"config_version" yaml:"config_version"`
+	Service       ServiceConfig          `json:"service"`
+	LicenseKey    string                 `json:"license_key,omitempty" yaml:"license_key,omitempty"`
+	Metrics       MetricsConfig          `json:"metrics"`
+	Traces        TracesConfig           `json:"traces"`
+	Logs          LogsConfig             `json:"logs"`
+	Security      SecurityConfig         `json:"security"`
+	Processing    ProcessingConfig       `json:"processing"`
+	Export        ExportConfig           `json:"export"`
+	Checks        []HostCheckConfig      `json:"checks,omitempty"`
+	Logging       LoggingConfig          `json:"logging,omitempty" yaml:"logging,omitempty"`
+	Advanced      map[string]interface{} `json:"advanced,omitempty"`
+}
+
+// LoggingConfig contains the collector's own logging settings
+type LoggingConfig struct {
+	Level string `json:"level,omitempty" yaml:"level,omitempty"` // debug, info, warn, error
 }
 
 // ServiceConfig contains service identification
@@ -97,6 +106,22 @@ type CardinalityConfig struct {
 	LimitAction  string                     `json:"limit_action"` // drop, sample, aggregate
 }
 
+// HostCheckConfig defines a local synthetic check run by the nrhostcheck
+// receiver
+type HostCheckConfig struct {
+	Name           string            `json:"name" yaml:"name"`
+	Type           string            `json:"type" yaml:"type"` // tcp, http, dns, disk, script
+	Target         string            `json:"target,omitempty" yaml:"target,omitempty"`
+	Interval       time.Duration     `json:"interval,omitempty" yaml:"interval,omitempty"`
+	Timeout        time.Duration     `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Method         string            `json:"method,omitempty" yaml:"method,omitempty"`
+	ExpectedStatus []int             `json:"expected_status,omitempty" yaml:"expected_status,omitempty"`
+	MaxUsedPercent float64           `json:"max_used_percent,omitempty" yaml:"max_used_percent,omitempty"`
+	Command        string            `json:"command,omitempty" yaml:"command,omitempty"`
+	Args           []string          `json:"args,omitempty" yaml:"args,omitempty"`
+	Attributes     map[string]string `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+}
+
 // ExportConfig contains export destination settings
 type ExportConfig struct {
 	Endpoint     string            `json:"endpoint"`
@@ -133,6 +158,7 @@ type ConfigResult struct {
 	AppliedAt       time.Time          `json:"applied_at,omitempty"`
 	Error           *ErrorInfo         `json:"error,omitempty"`
 	Warnings        []string           `json:"warnings,omitempty"`
+	RequestID       string             `json:"request_id,omitempty"`
 }
 
 // ValidationResult contains configuration validation details
@@ -145,9 +171,14 @@ type ValidationResult struct {
 
 // ValidationError represents a configuration validation error
 type ValidationError struct {
-	Path    string `json:"path"`
-	Message string `json:"message"`
-	Code    string `json:"code"`
+	Path       string `json:"path"`
+	Message    string `json:"message"`
+	Code       string `json:"code"`
+	// Line and Column locate the error in the user config, 0 if unknown
+	Line       int    `json:"line,omitempty"`
+	Column     int    `json:"column,omitempty"`
+	// Suggestion is the key probably meant by an unknown one
+	Suggestion string `json:"suggestion,omitempty"`
 }
 
 // ConfigVersion represents a configuration version entry
@@ -14,6 +14,8 @@ const (
 	EventTypeReloaded        EventType = "component.reloaded"
 	EventTypeUpdated         EventType = "component.updated"
 	EventTypeCrashed         EventType = "component.crashed"
+	EventTypeUpdateAvailable EventType = "component.update_available"
+	EventTypeStateChanged    EventType = "component.state_changed"
 	
 	// Configuration events
 	EventTypeConfigChanged   EventType = "config.changed"
@@ -36,10 +38,20 @@ const (
 	EventTypeBackpressure    EventType = "data.backpressure"
 	EventTypeCardinalityHigh EventType = "data.cardinality_high"
 	
+	// Discovery events
+	EventTypeServiceAppeared    EventType = "discovery.service_appeared"
+	EventTypeServiceDisappeared EventType = "discovery.service_disappeared"
+	
 	// Security events
 	EventTypeSecurityViolation EventType = "security.violation"
 	EventTypeAuthFailure      EventType = "security.auth_failure"
 	EventTypeCertExpiring     EventType = "security.cert_expiring"
+	EventTypeAPIKeyCreated    EventType = "security.api_key_created"
+	EventTypeAPIKeyRevoked    EventType = "security.api_key_revoked"
+	EventTypeAPIKeyRotated    EventType = "security.api_key_rotated"
+	
+	// Host events
+	EventTypeHostStopping EventType = "host.stopping"
 )
 
 // Event represents a system event
@@ -54,6 +66,10 @@ type Event struct {
 	Source      EventSource            `json:"source"`
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
 	Error       *ErrorInfo             `json:"error,omitempty"`
+	// TraceID and SpanID link the event to the trace of the operation
+	// that caused it, if any
+	TraceID     string                 `json:"trace_id,omitempty"`
+	SpanID      string                 `json:"span_id,omitempty"`
 }
 
 // EventSeverity represents the severity of an event
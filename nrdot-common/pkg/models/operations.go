@@ -25,6 +25,10 @@ type ReloadResult struct {
 	Duration      time.Duration  `json:"duration"`
 	Error         *ErrorInfo     `json:"error,omitempty"`
 	RollbackInfo  *RollbackInfo  `json:"rollback_info,omitempty"`
+	// TraceID and SpanID identify the reload in the trace of the request
+	// that triggered it
+	TraceID       string         `json:"trace_id,omitempty"`
+	SpanID        string         `json:"span_id,omitempty"`
 }
 
 // RollbackInfo contains information about a rollback operation
@@ -42,11 +46,24 @@ type CollectorUpdate struct {
 	Version      string            `json:"version"`
 	DownloadURL  string            `json:"download_url,omitempty"`
 	Checksum     string            `json:"checksum"`
+	Signature    string            `json:"signature,omitempty"` // base64 ed25519 signature of the binary
 	ReleaseNotes string            `json:"release_notes,omitempty"`
 	Mandatory    bool              `json:"mandatory"`
 	Metadata     map[string]string `json:"metadata,omitempty"`
 }
 
+// AvailableUpdate describes a newer collector release offered on the
+// host's update channel
+type AvailableUpdate struct {
+	Version      string     `json:"version"`
+	Channel      string     `json:"channel"`
+	ReleaseNotes string     `json:"release_notes,omitempty"`
+	ChangelogURL string     `json:"changelog_url,omitempty"`
+	Mandatory    bool       `json:"mandatory"`
+	DetectedAt   time.Time  `json:"detected_at"`
+	ScheduledFor *time.Time `json:"scheduled_for,omitempty"` // next maintenance window when auto-applying
+}
+
 // UpdateResult represents the outcome of an update operation
 type UpdateResult struct {
 	Success        bool          `json:"success"`
@@ -54,9 +71,19 @@ type UpdateResult struct {
 	NewVersion     string        `json:"new_version"`
 	UpdateDuration time.Duration `json:"update_duration"`
 	Downtime       time.Duration `json:"downtime"`
+	RolledBack     bool          `json:"rolled_back"`
+	Steps          []UpdateStep  `json:"steps,omitempty"`
 	Error          *ErrorInfo    `json:"error,omitempty"`
 }
 
+// UpdateStep records one stage of an update operation
+type UpdateStep struct {
+	Name     string        `json:"name"`
+	Success  bool          `json:"success"`
+	Duration time.Duration `json:"duration"`
+	Message  string        `json:"message,omitempty"`
+}
+
 // BackupInfo represents configuration backup information
 type BackupInfo struct {
 	ID           string            `json:"id"`
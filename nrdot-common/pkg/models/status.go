@@ -23,18 +23,34 @@ const (
 type CollectorState string
 
 const (
-	CollectorStateStarting CollectorState = "starting"
-	CollectorStateRunning  CollectorState = "running"
-	CollectorStateDegraded CollectorState = "degraded"
-	CollectorStateFailed   CollectorState = "failed"
-	CollectorStateStopped  CollectorState = "stopped"
+	CollectorStateStarting  CollectorState = "starting"
+	CollectorStateRunning   CollectorState = "running"
+	CollectorStateDegraded  CollectorState = "degraded"
+	CollectorStateFailed    CollectorState = "failed"
+	CollectorStateStopping  CollectorState = "stopping"
+	CollectorStateStopped   CollectorState = "stopped"
+	CollectorStateReloading CollectorState = "reloading"
+)
+
+// StartupPhase is how far the supervisor got bringing the collector up. It
+// is ready to serve once the collector's pipelines are active.
+type StartupPhase string
+
+const (
+	PhaseStarting         StartupPhase = "starting"          // loading or generating the initial config
+	PhaseConfigLoaded     StartupPhase = "config-loaded"     // config applied, the collector is not running
+	PhaseCollectorStarted StartupPhase = "collector-started" // collector running, pipelines not seen yet
+	PhasePipelinesActive  StartupPhase = "pipelines-active"  // the collector's telemetry reports its pipelines
 )
 
 // CollectorStatus represents the complete status of the OpenTelemetry Collector
 type CollectorStatus struct {
 	State           CollectorState    `json:"state"`
+	Phase           StartupPhase      `json:"phase,omitempty"`
+	StateSince      time.Time         `json:"state_since,omitempty"` // when the collector entered State
 	Version         string            `json:"version"`
 	ConfigVersion   int               `json:"config_version"`
+	ConfigHash      string            `json:"config_hash,omitempty"` // generation hash of the running collector config
 	StartTime       time.Time         `json:"start_time"`
 	Uptime          time.Duration     `json:"uptime"`
 	LastConfigLoad  time.Time         `json:"last_config_load"`
@@ -43,6 +59,7 @@ type CollectorStatus struct {
 	ResourceMetrics ResourceMetrics   `json:"resource_metrics"`
 	LastError       *ErrorInfo        `json:"last_error,omitempty"`
 	Features        map[string]bool   `json:"features"`
+	AvailableUpdate *AvailableUpdate  `json:"available_update,omitempty"`
 }
 
 // PipelineStatus represents the status of a single telemetry pipeline
@@ -53,14 +70,19 @@ type PipelineStatus struct {
 	ComponentsHealth map[string]string      `json:"components_health"`
 	Metrics          PipelineMetrics        `json:"metrics"`
 	LastError        *ErrorInfo             `json:"last_error,omitempty"`
+	UpdatedAt        time.Time              `json:"updated_at,omitempty"`
 }
 
 // PipelineMetrics contains runtime metrics for a pipeline
 type PipelineMetrics struct {
 	ItemsReceived   int64         `json:"items_received"`
+	ItemsRefused    int64         `json:"items_refused"`
 	ItemsProcessed  int64         `json:"items_processed"`
 	ItemsDropped    int64         `json:"items_dropped"`
 	ItemsExported   int64         `json:"items_exported"`
+	ExportFailed    int64         `json:"export_failed"`
+	QueueSize       int64         `json:"queue_size"`
+	QueueCapacity   int64         `json:"queue_capacity"`
 	ProcessingRate  float64       `json:"processing_rate"`  // items/sec
 	ErrorRate       float64       `json:"error_rate"`       // errors/sec
 	Latency         LatencyMetrics `json:"latency"`
@@ -0,0 +1,124 @@
+// Package problem writes API errors as RFC 7807 problem details, so the
+// supervisor API and the standalone API server answer every error in the
+// same application/problem+json format:
+//
+//	{
+//	  "type": "about:blank",
+//	  "title": "Not Found",
+//	  "status": 404,
+//	  "detail": "Webhook not found",
+//	  "instance": "/api/v1/webhooks/wh-1",
+//	  "code": "NOT_FOUND"
+//	}
+package problem
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ContentType is the media type of problem details
+	ContentType = "application/problem+json"
+
+	// DefaultType is the type of problems described by their status alone
+	DefaultType = "about:blank"
+)
+
+// Details is an RFC 7807 problem details object, with the machine-readable
+// code extension member
+type Details struct {
+	// Type identifies the kind of problem, DefaultType unless set
+	Type string `json:"type"`
+	// Title is the status text, the same for every occurrence of a type
+	Title string `json:"title"`
+	// Status is the HTTP status code
+	Status int `json:"status"`
+	// Detail explains this occurrence of the problem
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request that failed
+	Instance string `json:"instance,omitempty"`
+	// Code identifies the problem for clients, by default the status text
+	// in upper snake case, e.g. NOT_FOUND
+	Code string `json:"code"`
+}
+
+// New returns the problem of a status, explained by detail
+func New(status int, detail string) *Details {
+	return &Details{
+		Type:   DefaultType,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   Code(status),
+	}
+}
+
+// Code returns the default code of a status, e.g. TOO_MANY_REQUESTS for 429
+func Code(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "UNKNOWN_STATUS"
+	}
+	text = strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text)
+	return strings.ToUpper(text)
+}
+
+// WithCode sets a more specific code than the status's
+func (d *Details) WithCode(code string) *Details {
+	d.Code = code
+	return d
+}
+
+// Error returns the detail, or the title without one
+func (d *Details) Error() string {
+	if d.Detail != "" {
+		return d.Detail
+	}
+	return d.Title
+}
+
+// Write writes the problem as the response to r. Like http.Error, it
+// leaves other headers set, such as Retry-After, in place.
+func (d *Details) Write(w http.ResponseWriter, r *http.Request) {
+	if d.Instance == "" && r != nil && r.URL != nil {
+		d.Instance = r.URL.Path
+	}
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", ContentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(d.Status)
+	json.NewEncoder(w).Encode(d)
+}
+
+// Error replies to r with the problem of a status, explained by detail. It
+// replaces http.Error in API handlers and middleware.
+func Error(w http.ResponseWriter, r *http.Request, detail string, status int) {
+	New(status, detail).Write(w, r)
+}
+
+// Handler answers every request with the problem of a status, e.g. as a
+// router's handler for unknown paths
+func Handler(status int, detail string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Error(w, r, detail, status)
+	})
+}
+
+// Parse decodes the problem details of an error response, reporting false
+// when the body is not problem details, e.g. from an older agent answering
+// in plain text
+func Parse(header http.Header, body []byte) (*Details, bool) {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || mediaType != ContentType {
+		return nil, false
+	}
+	var details Details
+	if err := json.Unmarshal(body, &details); err != nil || details.Status == 0 {
+		return nil, false
+	}
+	return &details, true
+}
@@ -0,0 +1,68 @@
+package problem
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestError(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("Retry-After", "5")
+	w.Header().Set("Content-Length", "12")
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/webhooks/wh-1?verbose=true", nil)
+
+	Error(w, r, "Webhook not found", http.StatusNotFound)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "5", w.Header().Get("Retry-After"))
+	assert.Empty(t, w.Header().Get("Content-Length"))
+	assert.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Not Found",
+		"status": 404,
+		"detail": "Webhook not found",
+		"instance": "/api/v1/webhooks/wh-1",
+		"code": "NOT_FOUND"
+	}`, w.Body.String())
+}
+
+func TestCode(t *testing.T) {
+	assert.Equal(t, "BAD_REQUEST", Code(http.StatusBadRequest))
+	assert.Equal(t, "TOO_MANY_REQUESTS", Code(http.StatusTooManyRequests))
+	assert.Equal(t, "INTERNAL_SERVER_ERROR", Code(http.StatusInternalServerError))
+	assert.Equal(t, "REQUEST_URI_TOO_LONG", Code(http.StatusRequestURITooLong))
+	assert.Equal(t, "IM_A_TEAPOT", Code(http.StatusTeapot))
+	assert.Equal(t, "UNKNOWN_STATUS", Code(599))
+}
+
+func TestParse(t *testing.T) {
+	w := httptest.NewRecorder()
+	New(http.StatusConflict, "version 3 was replaced").WithCode("CONFIG_CONFLICT").
+		Write(w, httptest.NewRequest(http.MethodPost, "/v1/config", nil))
+
+	details, ok := Parse(w.Header(), w.Body.Bytes())
+	require.True(t, ok)
+	assert.Equal(t, http.StatusConflict, details.Status)
+	assert.Equal(t, "CONFIG_CONFLICT", details.Code)
+	assert.Equal(t, "/v1/config", details.Instance)
+	assert.Equal(t, "version 3 was replaced", details.Error())
+
+	// Plain text errors are left to the caller
+	header := http.Header{"Content-Type": []string{"text/plain; charset=utf-8"}}
+	_, ok = Parse(header, []byte("Forbidden\n"))
+	assert.False(t, ok)
+
+	header = http.Header{"Content-Type": []string{ContentType + "; charset=utf-8"}}
+	_, ok = Parse(header, []byte("{"))
+	assert.False(t, ok)
+
+	details, ok = Parse(header, []byte(`{"status": 503, "title": "Service Unavailable"}`))
+	require.True(t, ok)
+	assert.Equal(t, "Service Unavailable", details.Error())
+}
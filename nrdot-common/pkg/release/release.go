@@ -0,0 +1,162 @@
+// Package release reads the signed release manifests NRDOT-HOST releases are
+// published with. The supervisor's updater follows them to upgrade the
+// collector, and nrdot-ctl checks them for manual upgrades.
+package release
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxManifestSize bounds the release manifest download
+const maxManifestSize = 1 << 20
+
+// Manifest describes the latest releases on a channel: the collector, which
+// the updater can install, and nrdot-host itself
+type Manifest struct {
+	Channel      string    `json:"channel"`
+	Version      string    `json:"version"`
+	DownloadURL  string    `json:"download_url"`
+	Checksum     string    `json:"checksum"`            // hex sha256 of the binary
+	Signature    string    `json:"signature,omitempty"` // base64 ed25519 signature of the binary
+	ReleaseNotes string    `json:"release_notes,omitempty"`
+	ChangelogURL string    `json:"changelog_url,omitempty"`
+	Mandatory    bool      `json:"mandatory"`
+	PublishedAt  time.Time `json:"published_at"`
+
+	// Host is the latest nrdot-host release, upgraded through the package
+	// manager rather than the updater
+	Host *HostRelease `json:"host,omitempty"`
+}
+
+// HostRelease describes an nrdot-host release
+type HostRelease struct {
+	Version      string    `json:"version"`
+	ChangelogURL string    `json:"changelog_url,omitempty"`
+	PublishedAt  time.Time `json:"published_at,omitempty"`
+}
+
+// ParsePublicKey decodes the base64-encoded ed25519 key manifests are
+// signed with
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("update public key must be a base64-encoded ed25519 key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// FetchManifest downloads the manifest of a channel from
+// <baseURL>/<channel>.json and verifies its detached ed25519 signature,
+// <baseURL>/<channel>.json.sig
+func FetchManifest(ctx context.Context, client *http.Client, baseURL, channel string, publicKey ed25519.PublicKey) (*Manifest, error) {
+	url := strings.TrimSuffix(baseURL, "/") + "/" + channel + ".json"
+
+	data, err := get(ctx, client, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch release manifest: %w", err)
+	}
+	sig, err := get(ctx, client, url+".sig")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch release manifest signature: %w", err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid release manifest signature encoding: %w", err)
+	}
+	if !ed25519.Verify(publicKey, data, signature) {
+		return nil, fmt.Errorf("release manifest signature verification failed")
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse release manifest: %w", err)
+	}
+	if manifest.Channel != channel {
+		return nil, fmt.Errorf("release manifest is for channel %q, expected %q", manifest.Channel, channel)
+	}
+	if !ValidVersion(manifest.Version) {
+		return nil, fmt.Errorf("release manifest has invalid version %q", manifest.Version)
+	}
+	if manifest.Host != nil && !ValidVersion(manifest.Host.Version) {
+		return nil, fmt.Errorf("release manifest has invalid host version %q", manifest.Host.Version)
+	}
+
+	return &manifest, nil
+}
+
+// get fetches a small document
+func get(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+}
+
+// validVersion restricts versions to characters that are safe in paths
+var validVersion = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+)*(-[0-9A-Za-z.]+)?$`)
+
+// ValidVersion reports whether v is a dotted version, with an optional
+// leading "v" and pre-release suffix. Valid versions are safe in paths.
+func ValidVersion(v string) bool {
+	return validVersion.MatchString(v)
+}
+
+// CompareVersions compares dotted versions, ignoring a leading "v". A
+// pre-release suffix sorts before the release it precedes.
+func CompareVersions(a, b string) int {
+	a, aPre, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	b, bPre, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var x, y int
+		if i < len(aParts) {
+			x, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			y, _ = strconv.Atoi(bParts[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	case aPre < bPre:
+		return -1
+	default:
+		return 1
+	}
+}
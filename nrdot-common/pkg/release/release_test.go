@@ -0,0 +1,108 @@
+package release
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serveManifest serves manifest, signed with a new key, as the manifest of
+// channel
+func serveManifest(t *testing.T, channel string, manifest Manifest) (*httptest.Server, ed25519.PublicKey) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("Failed to marshal manifest: %v", err)
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/" + channel + ".json":
+			w.Write(data)
+		case "/" + channel + ".json.sig":
+			w.Write([]byte(signature))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, pub
+}
+
+func TestFetchManifest(t *testing.T) {
+	server, key := serveManifest(t, "stable", Manifest{
+		Channel:      "stable",
+		Version:      "0.97.0",
+		ChangelogURL: "https://example.com/collector/0.97.0",
+		Host:         &HostRelease{Version: "1.2.0", ChangelogURL: "https://example.com/host/1.2.0"},
+	})
+
+	manifest, err := FetchManifest(context.Background(), http.DefaultClient, server.URL+"/", "stable", key)
+	if err != nil {
+		t.Fatalf("FetchManifest failed: %v", err)
+	}
+	if manifest.Version != "0.97.0" || manifest.Host == nil || manifest.Host.Version != "1.2.0" {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+
+	other, _, _ := ed25519.GenerateKey(nil)
+	if _, err := FetchManifest(context.Background(), http.DefaultClient, server.URL, "stable", other); err == nil {
+		t.Error("Expected a manifest signed with another key to be rejected")
+	}
+	if _, err := FetchManifest(context.Background(), http.DefaultClient, server.URL, "beta", key); err == nil {
+		t.Error("Expected a missing channel to fail")
+	}
+}
+
+func TestFetchManifest_InvalidHostVersion(t *testing.T) {
+	server, key := serveManifest(t, "stable", Manifest{
+		Channel: "stable",
+		Version: "0.97.0",
+		Host:    &HostRelease{Version: "../1.2.0"},
+	})
+
+	if _, err := FetchManifest(context.Background(), http.DefaultClient, server.URL, "stable", key); err == nil {
+		t.Error("Expected an invalid host version to be rejected")
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(nil)
+	if _, err := ParsePublicKey(base64.StdEncoding.EncodeToString(pub)); err != nil {
+		t.Errorf("Expected key to parse: %v", err)
+	}
+	for _, invalid := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := ParsePublicKey(invalid); err == nil {
+			t.Errorf("Expected error parsing %q", invalid)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"0.97.0", "0.96.0", 1},
+		{"v0.96.0", "0.96.0", 0},
+		{"0.96.1", "0.96", 1},
+		{"0.100.0", "0.99.0", 1},
+		{"0.97.0-rc.1", "0.97.0", -1},
+		{"0.97.0-rc.2", "0.97.0-rc.1", 1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Package tlsconfig builds server TLS configurations for the NRDOT-HOST
+// management APIs, from certificate files or a generated self-signed
+// certificate, with optional client certificate verification (mTLS)
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// SelfSignedCertFile and SelfSignedKeyFile are the names of the generated
+	// certificate and key under Config.SelfSignedDir
+	SelfSignedCertFile = "api-cert.pem"
+	SelfSignedKeyFile  = "api-key.pem"
+
+	// selfSignedValidity is how long a generated certificate is valid
+	selfSignedValidity = 365 * 24 * time.Hour
+
+	// selfSignedRenewBefore is how long before expiry a stored self-signed
+	// certificate is replaced
+	selfSignedRenewBefore = 30 * 24 * time.Hour
+)
+
+// Config represents TLS configuration for a management API listener. TLS is
+// enabled by a certificate and key, or by SelfSigned.
+type Config struct {
+	CertFile string // PEM server certificate, may include intermediates
+	KeyFile  string // PEM private key of CertFile
+
+	// SelfSigned generates a certificate when no CertFile is set. It is
+	// kept in SelfSignedDir so clients can pin it across restarts, or only
+	// in memory when the directory is empty.
+	SelfSigned    bool
+	SelfSignedDir string
+	Hosts         []string // names and IPs of the generated certificate, defaults to localhost
+
+	// ClientCAFile enables client certificate verification against the PEM
+	// CAs it holds. Clients without a certificate are still accepted unless
+	// RequireClientCert is set.
+	ClientCAFile      string
+	RequireClientCert bool
+}
+
+// Enabled reports whether the listener serves TLS
+func (c Config) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || c.SelfSigned
+}
+
+// MutualTLS reports whether every client must present a certificate signed
+// by ClientCAFile
+func (c Config) MutualTLS() bool {
+	return c.ClientCAFile != "" && c.RequireClientCert
+}
+
+// Validate checks that the configuration is consistent
+func (c Config) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("TLS certificate and key files must be set together")
+	}
+	if c.CertFile != "" && c.SelfSigned {
+		return errors.New("TLS certificate files and a self-signed certificate are mutually exclusive")
+	}
+	if c.ClientCAFile != "" && !c.Enabled() {
+		return errors.New("client certificate verification requires TLS")
+	}
+	if c.RequireClientCert && c.ClientCAFile == "" {
+		return errors.New("requiring client certificates needs a client CA file")
+	}
+	return nil
+}
+
+// ServerConfig builds the TLS configuration of the listener, generating the
+// self-signed certificate if needed. TLS 1.2 is the minimum version.
+func (c Config) ServerConfig() (*tls.Config, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if !c.Enabled() {
+		return nil, errors.New("TLS is not enabled")
+	}
+
+	var cert tls.Certificate
+	var err error
+	if c.SelfSigned {
+		cert, err = c.selfSignedCertificate()
+	} else {
+		cert, err = tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.ClientCAFile != "" {
+		pool, err := loadCertPool(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if c.RequireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return config, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the server certificate in
+// config, for clients pinning a self-signed certificate
+func Fingerprint(config *tls.Config) string {
+	if config == nil || len(config.Certificates) == 0 || len(config.Certificates[0].Certificate) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(config.Certificates[0].Certificate[0])
+	return hex.EncodeToString(sum[:])
+}
+
+// loadCertPool reads the PEM certificates in path
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", path)
+	}
+	return pool, nil
+}
+
+// hosts returns the names and IPs of the self-signed certificate
+func (c Config) hosts() []string {
+	if len(c.Hosts) == 0 {
+		return []string{"localhost", "127.0.0.1", "::1"}
+	}
+	return c.Hosts
+}
+
+// selfSignedCertificate returns the stored self-signed certificate, or
+// generates and stores a new one when it is missing, expires soon or does
+// not cover the configured hosts
+func (c Config) selfSignedCertificate() (tls.Certificate, error) {
+	if c.SelfSignedDir == "" {
+		certPEM, keyPEM, err := generateSelfSigned(c.hosts(), time.Now())
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		return tls.X509KeyPair(certPEM, keyPEM)
+	}
+
+	certPath := filepath.Join(c.SelfSignedDir, SelfSignedCertFile)
+	keyPath := filepath.Join(c.SelfSignedDir, SelfSignedKeyFile)
+	if cert, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil && c.reusable(cert) {
+		return cert, nil
+	}
+
+	certPEM, keyPEM, err := generateSelfSigned(c.hosts(), time.Now())
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.MkdirAll(c.SelfSignedDir, 0700); err != nil {
+		return tls.Certificate{}, fmt.Errorf("creating certificate directory: %w", err)
+	}
+	if err := writeFileAtomic(keyPath, keyPEM); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := writeFileAtomic(certPath, certPEM); err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// reusable reports whether a stored self-signed certificate is still valid
+// for a while and covers every configured host
+func (c Config) reusable(cert tls.Certificate) bool {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil || time.Now().Add(selfSignedRenewBefore).After(leaf.NotAfter) {
+		return false
+	}
+	for _, host := range c.hosts() {
+		if leaf.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// generateSelfSigned creates a PEM encoded ECDSA P-256 certificate and key
+// valid for hosts
+func generateSelfSigned(hosts []string, now time.Time) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generating key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("generating serial number: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"NRDOT-HOST"}, CommonName: hosts[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding key: %w", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// writeFileAtomic replaces path with data, readable by the owner only
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{name: "disabled", config: Config{}},
+		{name: "files", config: Config{CertFile: "cert.pem", KeyFile: "key.pem"}},
+		{name: "self-signed with mTLS", config: Config{SelfSigned: true, ClientCAFile: "ca.pem", RequireClientCert: true}},
+		{name: "cert without key", config: Config{CertFile: "cert.pem"}, wantErr: true},
+		{name: "files and self-signed", config: Config{CertFile: "cert.pem", KeyFile: "key.pem", SelfSigned: true}, wantErr: true},
+		{name: "client CA without TLS", config: Config{ClientCAFile: "ca.pem"}, wantErr: true},
+		{name: "required client cert without CA", config: Config{SelfSigned: true, RequireClientCert: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSelfSigned(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tls")
+	config := Config{SelfSigned: true, SelfSignedDir: dir, Hosts: []string{"nrdot.example.com", "10.0.0.5"}}
+
+	first, err := config.ServerConfig()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), first.MinVersion)
+	leaf, err := x509.ParseCertificate(first.Certificates[0].Certificate[0])
+	require.NoError(t, err)
+	assert.NoError(t, leaf.VerifyHostname("nrdot.example.com"))
+	assert.NoError(t, leaf.VerifyHostname("10.0.0.5"))
+
+	stat, err := os.Stat(filepath.Join(dir, SelfSignedKeyFile))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+
+	// The stored certificate is reused so clients can pin it
+	second, err := config.ServerConfig()
+	require.NoError(t, err)
+	assert.Equal(t, Fingerprint(first), Fingerprint(second))
+
+	// Until the hosts change
+	config.Hosts = append(config.Hosts, "nrdot.internal")
+	third, err := config.ServerConfig()
+	require.NoError(t, err)
+	assert.NotEqual(t, Fingerprint(first), Fingerprint(third))
+
+	// Certificate files are used as they are
+	files := Config{
+		CertFile: filepath.Join(dir, SelfSignedCertFile),
+		KeyFile:  filepath.Join(dir, SelfSignedKeyFile),
+	}
+	loaded, err := files.ServerConfig()
+	require.NoError(t, err)
+	assert.Equal(t, Fingerprint(third), Fingerprint(loaded))
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw}), 0600))
+
+	config := Config{SelfSigned: true, ClientCAFile: caFile, RequireClientCert: true}
+	assert.True(t, config.MutualTLS())
+	serverConfig, err := config.ServerConfig()
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, serverConfig.ClientAuth)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	// handshake dials the listener, trusting its self-signed certificate
+	handshake := func(certs []tls.Certificate) error {
+		roots := x509.NewCertPool()
+		leaf, err := x509.ParseCertificate(serverConfig.Certificates[0].Certificate[0])
+		require.NoError(t, err)
+		roots.AddCert(leaf)
+		conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{
+			RootCAs:      roots,
+			ServerName:   "localhost",
+			Certificates: certs,
+		})
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		// TLS 1.3 reports a rejected client certificate on the first read
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return nil
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+
+	assert.Error(t, handshake(nil))
+	assert.NoError(t, handshake([]tls.Certificate{newTestClientCert(t, caCert, caKey)}))
+
+	otherCA, otherKey := newTestCA(t)
+	assert.Error(t, handshake([]tls.Certificate{newTestClientCert(t, otherCA, otherKey)}))
+
+	_, err = Config{SelfSigned: true, ClientCAFile: filepath.Join(dir, "missing.pem")}.ServerConfig()
+	assert.Error(t, err)
+}
+
+func newTestCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func newTestClientCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "fleet-tool"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
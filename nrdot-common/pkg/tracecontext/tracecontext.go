@@ -0,0 +1,171 @@
+// Package tracecontext carries W3C trace context from API requests through
+// the operations they trigger, so reload results, events, logs and the
+// collector processes started along the way share the trace of the request
+// that caused them.
+package tracecontext
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Header is the W3C trace context request header
+	Header = "traceparent"
+	// EnvVar passes the trace context to child processes
+	EnvVar = "TRACEPARENT"
+)
+
+// flagSampled is the sampled bit of the trace flags
+const flagSampled = 0x01
+
+// SpanContext identifies a span of a trace
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// IsValid reports whether neither ID is all zeros
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceIDString returns the trace ID as 32 hex digits
+func (sc SpanContext) TraceIDString() string {
+	return hex.EncodeToString(sc.TraceID[:])
+}
+
+// SpanIDString returns the span ID as 16 hex digits
+func (sc SpanContext) SpanIDString() string {
+	return hex.EncodeToString(sc.SpanID[:])
+}
+
+// String returns the span as a traceparent header value
+func (sc SpanContext) String() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceIDString(), sc.SpanIDString(), sc.Flags)
+}
+
+// Parse parses a traceparent header value. Versions after 00 are parsed
+// as 00, as the specification requires.
+func Parse(traceparent string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+
+	var sc SpanContext
+	var flags [1]byte
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) || !decodeHex(flags[:], parts[3]) {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q: zero trace or span ID", traceparent)
+	}
+	return sc, nil
+}
+
+// decodeHex decodes exactly len(dst) bytes of lowercase hex
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// NewRoot starts a new, sampled trace
+func NewRoot() SpanContext {
+	sc := SpanContext{Flags: flagSampled}
+	rand.Read(sc.TraceID[:])
+	rand.Read(sc.SpanID[:])
+	return sc
+}
+
+// NewChild returns a new span of the same trace
+func (sc SpanContext) NewChild() SpanContext {
+	child := SpanContext{TraceID: sc.TraceID, Flags: sc.Flags}
+	rand.Read(child.SpanID[:])
+	return child
+}
+
+type contextKey struct{}
+
+// ContextWith returns a copy of ctx carrying sc
+func ContextWith(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// FromContext returns the span ctx carries
+func FromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(contextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// StartSpan returns a child of the span ctx carries, or a new trace if it
+// carries none, and a copy of ctx carrying it
+func StartSpan(ctx context.Context) (context.Context, SpanContext) {
+	sc := NewRoot()
+	if parent, ok := FromContext(ctx); ok {
+		sc = parent.NewChild()
+	}
+	return ContextWith(ctx, sc), sc
+}
+
+// Fields returns the trace_id and span_id log fields of the span ctx
+// carries, none if it carries none
+func Fields(ctx context.Context) []zap.Field {
+	sc, ok := FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return []zap.Field{
+		zap.String("trace_id", sc.TraceIDString()),
+		zap.String("span_id", sc.SpanIDString()),
+	}
+}
+
+// Env returns the environment of a child process with the span ctx
+// carries as TRACEPARENT, replacing any inherited one. env is returned as
+// is when ctx carries no span; a nil env is the current environment, as
+// for exec.Cmd.
+func Env(ctx context.Context, env []string) []string {
+	sc, ok := FromContext(ctx)
+	if !ok {
+		return env
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+	out := make([]string, 0, len(env)+1)
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, EnvVar+"=") {
+			out = append(out, kv)
+		}
+	}
+	return append(out, EnvVar+"="+sc.String())
+}
+
+// Middleware starts a span for every request, continuing the trace of its
+// traceparent header if it has a valid one. The span is in the request
+// context and returned in the traceparent response header, so callers
+// without a trace of their own learn which trace their request started.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if parent, err := Parse(r.Header.Get(Header)); err == nil {
+			ctx = ContextWith(ctx, parent)
+		}
+		ctx, sc := StartSpan(ctx)
+		w.Header().Set(Header, sc.String())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,102 @@
+package tracecontext
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParse(t *testing.T) {
+	sc, err := Parse(testTraceparent)
+	require.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceIDString())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanIDString())
+	assert.Equal(t, byte(0x01), sc.Flags)
+	assert.Equal(t, testTraceparent, sc.String())
+
+	// Later versions may add fields
+	_, err = Parse("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	assert.NoError(t, err)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	} {
+		_, err := Parse(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestStartSpan(t *testing.T) {
+	ctx, root := StartSpan(context.Background())
+	require.True(t, root.IsValid())
+	assert.Equal(t, byte(flagSampled), root.Flags)
+
+	_, child := StartSpan(ctx)
+	assert.Equal(t, root.TraceID, child.TraceID)
+	assert.NotEqual(t, root.SpanID, child.SpanID)
+
+	_, other := StartSpan(context.Background())
+	assert.NotEqual(t, root.TraceID, other.TraceID)
+}
+
+func TestFieldsAndEnv(t *testing.T) {
+	assert.Empty(t, Fields(context.Background()))
+	env := []string{"A=1"}
+	assert.Equal(t, env, Env(context.Background(), env))
+
+	sc, err := Parse(testTraceparent)
+	require.NoError(t, err)
+	ctx := ContextWith(context.Background(), sc)
+
+	fields := Fields(ctx)
+	require.Len(t, fields, 2)
+	assert.Equal(t, "trace_id", fields[0].Key)
+	assert.Equal(t, sc.TraceIDString(), fields[0].String)
+
+	assert.Equal(t, []string{"A=1", "TRACEPARENT=" + testTraceparent},
+		Env(ctx, []string{"TRACEPARENT=00-old", "A=1"}))
+	assert.Contains(t, Env(ctx, nil), "TRACEPARENT="+testTraceparent)
+}
+
+func TestMiddleware(t *testing.T) {
+	var got SpanContext
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = FromContext(r.Context())
+	}))
+
+	// An incoming trace is continued
+	req := httptest.NewRequest(http.MethodPost, "/v1/control/reload", nil)
+	req.Header.Set(Header, testTraceparent)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", got.TraceIDString())
+	assert.NotEqual(t, "00f067aa0ba902b7", got.SpanIDString())
+	assert.Equal(t, got.String(), rec.Header().Get(Header))
+
+	// Otherwise a trace is started
+	req = httptest.NewRequest(http.MethodPost, "/v1/control/reload", nil)
+	req.Header.Set(Header, "garbage")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.True(t, got.IsValid())
+	assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", got.TraceIDString())
+	assert.Equal(t, got.String(), rec.Header().Get(Header))
+}
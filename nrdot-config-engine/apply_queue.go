@@ -0,0 +1,250 @@
+package configengine
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+)
+
+// ApplyState is the stage an ApplyConfig request has reached
+type ApplyState string
+
+const (
+	ApplyStateQueued     ApplyState = "queued"
+	ApplyStateValidating ApplyState = "validating"
+	ApplyStateGenerating ApplyState = "generating"
+	ApplyStateApplying   ApplyState = "applying"
+	ApplyStateDone       ApplyState = "done"
+	ApplyStateFailed     ApplyState = "failed"
+)
+
+// RequestIDMetadataKey lets callers choose the request ID of an apply via
+// ConfigUpdate.Metadata; otherwise one is generated
+const RequestIDMetadataKey = "request_id"
+
+// maxApplyHistory bounds how many finished requests stay queryable
+const maxApplyHistory = 100
+
+// ApplyStatus reports the progress of an ApplyConfig request
+type ApplyStatus struct {
+	RequestID  string     `json:"request_id"`
+	State      ApplyState `json:"state"`
+	Source     string     `json:"source,omitempty"`
+	Position   int        `json:"position,omitempty"` // requests ahead while queued
+	Version    int        `json:"version,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	QueuedAt   time.Time  `json:"queued_at"`
+	StartedAt  time.Time  `json:"started_at,omitempty"`
+	FinishedAt time.Time  `json:"finished_at,omitempty"`
+}
+
+// applyRequest is one entry of the apply queue
+type applyRequest struct {
+	status ApplyStatus
+	ready  chan struct{} // closed once the request is at the head of the queue
+}
+
+// applyQueue serializes ApplyConfig calls from the watcher, the API and
+// remote config in arrival order, and keeps their status for lookup by ID
+type applyQueue struct {
+	mu       sync.Mutex
+	pending  []*applyRequest // head is the running request
+	requests map[string]*applyRequest
+	finished []string // IDs of finished requests, oldest first
+}
+
+func newApplyQueue() *applyQueue {
+	return &applyQueue{
+		requests: make(map[string]*applyRequest),
+	}
+}
+
+// enqueue adds a request to the tail of the queue
+func (q *applyQueue) enqueue(requestID, source string) (*applyRequest, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	if _, exists := q.requests[requestID]; exists {
+		return nil, fmt.Errorf("apply request %s already exists", requestID)
+	}
+
+	req := &applyRequest{
+		status: ApplyStatus{
+			RequestID: requestID,
+			State:     ApplyStateQueued,
+			Source:    source,
+			QueuedAt:  time.Now(),
+		},
+		ready: make(chan struct{}),
+	}
+	q.pending = append(q.pending, req)
+	q.requests[requestID] = req
+	if len(q.pending) == 1 {
+		close(req.ready)
+	}
+	return req, nil
+}
+
+// wait blocks until req is at the head of the queue. If ctx ends first the
+// request is dropped from the queue and marked failed.
+func (q *applyQueue) wait(ctx context.Context, req *applyRequest) error {
+	select {
+	case <-req.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// Reached the head concurrently with the cancellation; run anyway so the
+	// caller's finish call advances the queue
+	select {
+	case <-req.ready:
+		return nil
+	default:
+	}
+
+	for i, pending := range q.pending {
+		if pending == req {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			break
+		}
+	}
+	q.complete(req, 0, ctx.Err())
+	return ctx.Err()
+}
+
+// setState records the stage of the running request
+func (q *applyQueue) setState(req *applyRequest, state ApplyState) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if req.status.StartedAt.IsZero() {
+		req.status.StartedAt = time.Now()
+	}
+	req.status.State = state
+}
+
+// finish completes the running request and hands the queue to the next one
+func (q *applyQueue) finish(req *applyRequest, version int, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) > 0 && q.pending[0] == req {
+		q.pending = q.pending[1:]
+		if len(q.pending) > 0 {
+			close(q.pending[0].ready)
+		}
+	}
+	q.complete(req, version, err)
+}
+
+// complete must be called with q.mu held
+func (q *applyQueue) complete(req *applyRequest, version int, err error) {
+	req.status.FinishedAt = time.Now()
+	if err != nil {
+		req.status.State = ApplyStateFailed
+		req.status.Error = err.Error()
+	} else {
+		req.status.State = ApplyStateDone
+		req.status.Version = version
+	}
+
+	q.finished = append(q.finished, req.status.RequestID)
+	if len(q.finished) > maxApplyHistory {
+		delete(q.requests, q.finished[0])
+		q.finished = q.finished[1:]
+	}
+}
+
+// status returns a snapshot of a request's status
+func (q *applyQueue) status(requestID string) (*ApplyStatus, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	req, ok := q.requests[requestID]
+	if !ok {
+		return nil, false
+	}
+
+	status := req.status
+	if status.State == ApplyStateQueued {
+		for i, pending := range q.pending {
+			if pending == req {
+				status.Position = i
+				break
+			}
+		}
+	}
+	return &status, true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "apply-" + hex.EncodeToString(b)
+}
+
+// SubmitConfig queues a configuration update and returns its request ID
+// without waiting for it to be applied. Progress is available from
+// GetApplyStatus.
+func (e *EngineV2) SubmitConfig(update *models.ConfigUpdate) (string, error) {
+	req, err := e.applyQueue.enqueue(update.Metadata[RequestIDMetadataKey], update.Source)
+	if err != nil {
+		return "", err
+	}
+
+	go e.runApply(context.Background(), update, req)
+	return req.status.RequestID, nil
+}
+
+// GetApplyStatus returns the status of a queued, running or recently
+// finished apply request
+func (e *EngineV2) GetApplyStatus(requestID string) (*ApplyStatus, error) {
+	status, ok := e.applyQueue.status(requestID)
+	if !ok {
+		return nil, models.NewError(
+			models.ErrCodeResourceNotFound,
+			fmt.Sprintf("Apply request %s not found", requestID),
+			models.ErrorCategoryConfig,
+			models.SeverityWarning,
+		)
+	}
+	return status, nil
+}
+
+// runApply waits for req's turn, applies the update and records the outcome
+func (e *EngineV2) runApply(ctx context.Context, update *models.ConfigUpdate, req *applyRequest) (*models.ConfigResult, error) {
+	if err := e.applyQueue.wait(ctx, req); err != nil {
+		return &models.ConfigResult{
+			Success:   false,
+			RequestID: req.status.RequestID,
+			Error: models.NewError(
+				models.ErrCodeResourceLocked,
+				"Configuration apply cancelled while queued",
+				models.ErrorCategoryConfig,
+				models.SeverityWarning,
+			).WithDetails(err.Error()),
+		}, err
+	}
+
+	result, err := e.applyConfig(ctx, update, req)
+
+	outcome := err
+	if outcome == nil && !result.Success && result.Error != nil {
+		outcome = result.Error
+	}
+	e.applyQueue.finish(req, result.Version, outcome)
+
+	result.RequestID = req.status.RequestID
+	return result, err
+}
@@ -0,0 +1,172 @@
+package configengine
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newTestEngineV2(t *testing.T) *EngineV2 {
+	engine, err := NewEngineV2(ConfigV2{Logger: zaptest.NewLogger(t)})
+	require.NoError(t, err)
+	return engine
+}
+
+func testUpdate(name string) *models.ConfigUpdate {
+	return &models.ConfigUpdate{
+		Config: []byte("service:\n  name: " + name + "\n"),
+		Format: "yaml",
+		Source: "api",
+	}
+}
+
+func TestApplyQueue_StatusByRequestID(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	result, err := engine.ApplyConfig(context.Background(), testUpdate("svc"))
+	require.NoError(t, err)
+	require.True(t, result.Success)
+	require.NotEmpty(t, result.RequestID)
+
+	status, err := engine.GetApplyStatus(result.RequestID)
+	require.NoError(t, err)
+	assert.Equal(t, ApplyStateDone, status.State)
+	assert.Equal(t, 1, status.Version)
+	assert.Equal(t, "api", status.Source)
+	assert.False(t, status.StartedAt.IsZero())
+	assert.False(t, status.FinishedAt.IsZero())
+
+	// Caller-chosen IDs are used and must be unique
+	update := testUpdate("svc")
+	update.Metadata = map[string]string{RequestIDMetadataKey: "watcher-1"}
+	result, err = engine.ApplyConfig(context.Background(), update)
+	require.NoError(t, err)
+	assert.Equal(t, "watcher-1", result.RequestID)
+
+	_, err = engine.ApplyConfig(context.Background(), update)
+	assert.Error(t, err)
+
+	_, err = engine.GetApplyStatus("missing")
+	assert.Error(t, err)
+}
+
+func TestApplyQueue_ValidationFailure(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	result, err := engine.ApplyConfig(context.Background(), &models.ConfigUpdate{
+		Config: []byte("service: {}\n"),
+		Format: "yaml",
+	})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+
+	status, err := engine.GetApplyStatus(result.RequestID)
+	require.NoError(t, err)
+	assert.Equal(t, ApplyStateFailed, status.State)
+	assert.NotEmpty(t, status.Error)
+}
+
+func TestApplyQueue_Serializes(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	// Hold the engine lock so the first request blocks while generating
+	engine.mu.Lock()
+
+	first, err := engine.SubmitConfig(testUpdate("first"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		status, _ := engine.GetApplyStatus(first)
+		return status.State == ApplyStateGenerating
+	}, 2*time.Second, 10*time.Millisecond)
+
+	second, err := engine.SubmitConfig(testUpdate("second"))
+	require.NoError(t, err)
+
+	status, err := engine.GetApplyStatus(second)
+	require.NoError(t, err)
+	assert.Equal(t, ApplyStateQueued, status.State)
+	assert.Equal(t, 1, status.Position)
+
+	engine.mu.Unlock()
+
+	for i, id := range []string{first, second} {
+		require.Eventually(t, func() bool {
+			status, _ := engine.GetApplyStatus(id)
+			return status.State == ApplyStateDone
+		}, 2*time.Second, 10*time.Millisecond)
+		status, _ := engine.GetApplyStatus(id)
+		assert.Equal(t, i+1, status.Version, "requests apply in arrival order")
+	}
+}
+
+func TestApplyQueue_ConcurrentApplies(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	var wg sync.WaitGroup
+	versions := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := engine.ApplyConfig(context.Background(), testUpdate("svc"))
+			assert.NoError(t, err)
+			versions <- result.Version
+		}()
+	}
+	wg.Wait()
+	close(versions)
+
+	seen := make(map[int]bool)
+	for v := range versions {
+		assert.False(t, seen[v], "version %d applied twice", v)
+		seen[v] = true
+	}
+	assert.Len(t, seen, 10)
+}
+
+func TestApplyQueue_CancelWhileQueued(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	engine.mu.Lock()
+	first, err := engine.SubmitConfig(testUpdate("first"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan *models.ConfigResult)
+	go func() {
+		result, _ := engine.ApplyConfig(ctx, testUpdate("cancelled"))
+		done <- result
+	}()
+
+	// Wait until the second request is queued behind the first
+	require.Eventually(t, func() bool {
+		engine.applyQueue.mu.Lock()
+		defer engine.applyQueue.mu.Unlock()
+		return len(engine.applyQueue.pending) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	result := <-done
+	assert.False(t, result.Success)
+
+	status, err := engine.GetApplyStatus(result.RequestID)
+	require.NoError(t, err)
+	assert.Equal(t, ApplyStateFailed, status.State)
+
+	// The queue keeps moving
+	engine.mu.Unlock()
+	require.Eventually(t, func() bool {
+		status, _ := engine.GetApplyStatus(first)
+		return status.State == ApplyStateDone
+	}, 2*time.Second, 10*time.Millisecond)
+
+	result, err = engine.ApplyConfig(context.Background(), testUpdate("after"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Version)
+}
@@ -29,6 +29,7 @@ func main() {
 		validateOnly  = flag.Bool("validate", false, "Only validate configurations and exit")
 		logLevel      = flag.String("log-level", "info", "Log level (debug, info, warn, error)")
 		versionFlag   = flag.Bool("version", false, "Print version information")
+		reference     = flag.String("reference", "", "Print the configuration reference as markdown or json and exit")
 	)
 
 	flag.Parse()
@@ -38,6 +39,14 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *reference != "" {
+		if err := configengine.WriteConfigReference(os.Stdout, *reference); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write reference: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Setup logger
 	logger, err := setupLogger(*logLevel)
 	if err != nil {
@@ -0,0 +1,161 @@
+package configengine
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap"
+)
+
+// CollectorValidationCode is the ValidationError code of errors the
+// collector reports for a generated config
+const CollectorValidationCode = "COLLECTOR_VALIDATION_FAILED"
+
+// CollectorValidator checks a generated config the way the collector loads
+// it, catching what the schema cannot, such as unknown component keys and
+// bad durations. Errors are returned when the collector rejects the config;
+// err is for failing to check it at all.
+type CollectorValidator interface {
+	ValidateCollectorConfig(ctx context.Context, otelConfig string) ([]models.ValidationError, error)
+}
+
+// SetCollectorValidator sets the validator every generated config must pass
+// before it is versioned, nil to skip collector validation
+func (e *EngineV2) SetCollectorValidator(validator CollectorValidator) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.collectorValidator = validator
+}
+
+// validateWithCollector checks a generated config with the collector
+// validator, adding its errors to result. It returns false if the collector
+// rejects the config. A validator that cannot run only adds a warning, as
+// the collector still checks the config when it is reloaded.
+func (e *EngineV2) validateWithCollector(ctx context.Context, otelConfig string, result *models.ValidationResult) bool {
+	e.mu.RLock()
+	validator := e.collectorValidator
+	e.mu.RUnlock()
+	if validator == nil {
+		return true
+	}
+
+	errs, err := validator.ValidateCollectorConfig(ctx, otelConfig)
+	if err != nil {
+		e.logger.Warn("Collector validation unavailable", zap.Error(err))
+		result.Warnings = append(result.Warnings, "collector validation skipped: "+err.Error())
+		return true
+	}
+	if len(errs) == 0 {
+		return true
+	}
+
+	result.Valid = false
+	result.Errors = append(result.Errors, errs...)
+	return false
+}
+
+// validationErrorDetails joins validation errors as "path: message" for
+// error details
+func validationErrorDetails(errs []models.ValidationError) string {
+	details := make([]string, 0, len(errs))
+	for _, err := range errs {
+		details = append(details, err.Path+": "+err.Message)
+	}
+	return strings.Join(details, "; ")
+}
+
+var (
+	// invalidConfigPattern matches the collector's semantic validation
+	// errors, e.g. "invalid configuration: processors::batch: ..."
+	invalidConfigPattern = regexp.MustCompile(`invalid configuration: ((?:[\w/.-]+::)+[\w/.-]+): (.+)`)
+	// componentPattern matches a component failing to decode, e.g.
+	// "error decoding 'processors': error reading configuration for "batch""
+	componentPattern = regexp.MustCompile(`'(\w+)':? error reading configuration for "([^"]+)"`)
+	// decodingPattern matches a section or field failing to decode
+	decodingPattern = regexp.MustCompile(`error decoding '([\w.]+)'`)
+	// logLinePattern matches the collector's log lines, which end an error
+	logLinePattern = regexp.MustCompile(`^\d{4}[/-]\d{2}[/-]\d{2}`)
+)
+
+// ParseCollectorErrors turns the output of `otelcol validate` into
+// validation errors with the path of the offending component, such as
+// processors.batch.timeout, where the output names one and "/" otherwise
+func ParseCollectorErrors(output string) []models.ValidationError {
+	var errs []models.ValidationError
+	for _, block := range collectorErrorBlocks(output) {
+		errs = append(errs, parseCollectorError(block))
+	}
+	if len(errs) == 0 && strings.TrimSpace(output) != "" {
+		errs = append(errs, models.ValidationError{
+			Path:    "/",
+			Message: strings.TrimSpace(output),
+			Code:    CollectorValidationCode,
+		})
+	}
+	return errs
+}
+
+// collectorErrorBlocks splits the output into its errors, each starting
+// with "Error: " and running until the next error or log line
+func collectorErrorBlocks(output string) [][]string {
+	var blocks [][]string
+	var current []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "Error: "):
+			if current != nil {
+				blocks = append(blocks, current)
+			}
+			current = []string{strings.TrimPrefix(line, "Error: ")}
+		case logLinePattern.MatchString(line):
+			if current != nil {
+				blocks = append(blocks, current)
+			}
+			current = nil
+		case current != nil && line != "":
+			current = append(current, strings.TrimPrefix(line, "* "))
+		}
+	}
+	if current != nil {
+		blocks = append(blocks, current)
+	}
+	return blocks
+}
+
+// parseCollectorError finds the component path and the innermost message of
+// one error
+func parseCollectorError(lines []string) models.ValidationError {
+	text := strings.Join(lines, " ")
+	if m := invalidConfigPattern.FindStringSubmatch(text); m != nil {
+		return models.ValidationError{
+			Path:    strings.ReplaceAll(m[1], "::", "."),
+			Message: m[2],
+			Code:    CollectorValidationCode,
+		}
+	}
+
+	path := "/"
+	if loc := componentPattern.FindStringSubmatchIndex(text); loc != nil {
+		path = text[loc[2]:loc[3]] + "." + text[loc[4]:loc[5]]
+		if m := decodingPattern.FindStringSubmatch(text[loc[1]:]); m != nil {
+			path += "." + m[1]
+		}
+	} else if m := decodingPattern.FindStringSubmatch(text); m != nil {
+		path = m[1]
+	}
+
+	// The last line holds the innermost error
+	message := decodingPattern.ReplaceAllString(lines[len(lines)-1], "")
+	message = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(message), ":"))
+	if message == "" {
+		message = text
+	}
+	return models.ValidationError{
+		Path:    path,
+		Message: message,
+		Code:    CollectorValidationCode,
+	}
+}
@@ -0,0 +1,148 @@
+package configengine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubCollectorValidator returns fixed results and records the configs it
+// checked
+type stubCollectorValidator struct {
+	errs    []models.ValidationError
+	err     error
+	checked []string
+}
+
+func (v *stubCollectorValidator) ValidateCollectorConfig(ctx context.Context, otelConfig string) ([]models.ValidationError, error) {
+	v.checked = append(v.checked, otelConfig)
+	return v.errs, v.err
+}
+
+func TestParseCollectorErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []models.ValidationError
+	}{
+		{
+			name: "unknown key",
+			output: `Error: failed to get config: cannot unmarshal the configuration: 1 error(s) decoding:
+
+* error decoding 'processors': error reading configuration for "batch": 1 error(s) decoding:
+
+* '' has invalid keys: send_batch_sise
+2024/05/01 12:00:00 collector server run finished with error: failed to get config
+`,
+			want: []models.ValidationError{
+				{Path: "processors.batch", Message: "'' has invalid keys: send_batch_sise"},
+			},
+		},
+		{
+			name: "bad duration",
+			output: `Error: failed to get config: cannot unmarshal the configuration: 1 error(s) decoding:
+
+* error decoding 'processors': error reading configuration for "batch": 1 error(s) decoding:
+
+* error decoding 'timeout': time: invalid duration "5 seconds"
+`,
+			want: []models.ValidationError{
+				{Path: "processors.batch.timeout", Message: `time: invalid duration "5 seconds"`},
+			},
+		},
+		{
+			name: "decoding failure in newer collectors",
+			output: `Error: failed to get config: cannot unmarshal the configuration: decoding failed due to the following error(s):
+
+'processors' error reading configuration for "memory_limiter": decoding failed due to the following error(s):
+
+'' has invalid keys: limit_mb
+`,
+			want: []models.ValidationError{
+				{Path: "processors.memory_limiter", Message: "'' has invalid keys: limit_mb"},
+			},
+		},
+		{
+			name:   "unknown component type",
+			output: `Error: failed to get config: cannot unmarshal the configuration: 1 error(s) decoding:` + "\n\n" + `* error decoding 'exporters': unknown type: "otlphttpx" for id: "otlphttpx"`,
+			want: []models.ValidationError{
+				{Path: "exporters", Message: `unknown type: "otlphttpx" for id: "otlphttpx"`},
+			},
+		},
+		{
+			name:   "semantic validation",
+			output: "Error: invalid configuration: service::pipelines::metrics: references processor \"nrtransform\" which is not configured\n",
+			want: []models.ValidationError{
+				{Path: "service.pipelines.metrics", Message: `references processor "nrtransform" which is not configured`},
+			},
+		},
+		{
+			name:   "unrecognized output",
+			output: "segmentation fault\n",
+			want: []models.ValidationError{
+				{Path: "/", Message: "segmentation fault"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.want {
+				tt.want[i].Code = CollectorValidationCode
+			}
+			assert.Equal(t, tt.want, ParseCollectorErrors(tt.output))
+		})
+	}
+}
+
+func TestEngineV2_CollectorValidation(t *testing.T) {
+	ctx := context.Background()
+	engine := newTestEngineV2(t)
+	validator := &stubCollectorValidator{
+		errs: []models.ValidationError{
+			{Path: "processors.batch.timeout", Message: `time: invalid duration "5x"`, Code: CollectorValidationCode},
+		},
+	}
+	engine.SetCollectorValidator(validator)
+
+	// A rejected config fails validation before it is versioned
+	result, err := engine.ApplyConfig(ctx, testUpdate("svc"))
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Zero(t, result.Version)
+	require.NotNil(t, result.ValidationResult)
+	assert.False(t, result.ValidationResult.Valid)
+	assert.Equal(t, validator.errs, result.ValidationResult.Errors)
+	assert.Equal(t, models.ErrCodeConfigInvalid, result.Error.Code)
+	assert.Contains(t, result.Error.Details, "processors.batch.timeout")
+	require.Len(t, validator.checked, 1)
+	assert.Contains(t, validator.checked[0], "receivers:")
+
+	_, err = engine.GetCurrentConfig(ctx)
+	assert.Error(t, err)
+
+	// Dry runs and ValidateConfig check with the collector too
+	update := testUpdate("svc")
+	update.DryRun = true
+	result, err = engine.ApplyConfig(ctx, update)
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+
+	validation, err := engine.ValidateConfig(ctx, update.Config)
+	require.NoError(t, err)
+	assert.False(t, validation.Valid)
+	assert.Equal(t, validator.errs, validation.Errors)
+	assert.Len(t, validator.checked, 3)
+
+	// A validator that cannot run does not block the config
+	validator.errs, validator.err = nil, errors.New("collector binary not found")
+	result, err = engine.ApplyConfig(ctx, testUpdate("svc"))
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 1, result.Version)
+	assert.Equal(t, []string{"collector validation skipped: collector binary not found"}, result.ValidationResult.Warnings)
+}
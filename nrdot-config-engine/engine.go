@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -120,6 +121,18 @@ func (e *Engine) ProcessConfig(ctx context.Context, configPath string) error {
 	
 	generatedFiles := []string{otelConfigPath}
 
+	// Proxy settings reach the collector through its environment, written
+	// in the KEY=value format of systemd's EnvironmentFile
+	envPath := filepath.Join(e.outputDir, "collector.env")
+	if env := generator.EnvironmentList(); len(env) > 0 {
+		if err := os.WriteFile(envPath, []byte(strings.Join(env, "\n")+"\n"), 0600); err != nil {
+			return fmt.Errorf("failed to write collector environment: %w", err)
+		}
+		generatedFiles = append(generatedFiles, envPath)
+	} else if err := os.Remove(envPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove collector environment: %w", err)
+	}
+
 	// Update current version
 	e.currentVersion = newVersion
 
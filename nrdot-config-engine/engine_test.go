@@ -102,6 +102,53 @@ logs:
 	assert.NoError(t, err)
 	assert.True(t, hookCalled)
 	assert.NotEmpty(t, engine.GetCurrentVersion())
+	assert.NoFileExists(t, filepath.Join(outputDir, "collector.env"))
+}
+
+func TestEngine_ProcessConfig_Proxy(t *testing.T) {
+	tempDir := t.TempDir()
+	outputDir := filepath.Join(tempDir, "output")
+
+	configPath := filepath.Join(tempDir, "test-config.yaml")
+	configContent := `---
+service:
+  name: test-service
+metrics:
+  enabled: true
+proxy:
+  https_proxy: http://proxy.corp:3128
+  no_proxy: localhost,.internal
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	engine, err := NewEngine(Config{
+		OutputDir: outputDir,
+		Logger:    zaptest.NewLogger(t),
+	})
+	require.NoError(t, err)
+
+	var generated []string
+	engine.RegisterHook(hooks.HookFunc(func(ctx context.Context, event hooks.ConfigChangeEvent) error {
+		generated = event.GeneratedConfigs
+		return nil
+	}))
+	require.NoError(t, engine.ProcessConfig(context.Background(), configPath))
+
+	envPath := filepath.Join(outputDir, "collector.env")
+	assert.Contains(t, generated, envPath)
+	env, err := os.ReadFile(envPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(env), "HTTPS_PROXY=http://proxy.corp:3128\n")
+	assert.Contains(t, string(env), "no_proxy=localhost,.internal\n")
+
+	otelConfig, err := os.ReadFile(filepath.Join(outputDir, "otel-config.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(otelConfig), "proxy_url: http://proxy.corp:3128")
+
+	// Dropping the proxy removes the environment file
+	require.NoError(t, os.WriteFile(configPath, []byte("service:\n  name: test-service\n"), 0644))
+	require.NoError(t, engine.ProcessConfig(context.Background(), configPath))
+	assert.NoFileExists(t, envPath)
 }
 
 func TestEngine_ProcessConfig_DryRun(t *testing.T) {
@@ -5,7 +5,10 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
@@ -13,15 +16,18 @@ import (
 	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
 	"github.com/newrelic/nrdot-host/nrdot-config-engine/internal/schema"
 	"github.com/newrelic/nrdot-host/nrdot-config-engine/internal/templates"
+	"github.com/newrelic/nrdot-host/nrdot-config-engine/pkg/facts"
 	"github.com/newrelic/nrdot-host/nrdot-config-engine/pkg/hooks"
+	"github.com/newrelic/nrdot-host/nrdot-config-engine/pkg/secrets"
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v3"
 )
 
 // versionRecord stores both the version metadata and the actual config
 type versionRecord struct {
-	Version    models.ConfigVersion
-	UserConfig string
+	Version    models.ConfigVersion `json:"version"`
+	UserConfig string               `json:"user_config"`
+	OTelConfig string               `json:"otel_config"`
 }
 
 // EngineV2 is the unified configuration engine that consolidates
@@ -38,6 +44,29 @@ type EngineV2 struct {
 	currentVersion int
 	currentConfig  *models.Config
 	currentOTel    string
+	currentGenerated *models.GeneratedConfig
+	
+	// Serializes ApplyConfig calls
+	applyQueue     *applyQueue
+	
+	// Source of the generation and apply timestamps
+	clock          func() time.Time
+	
+	// Resolves the variables generated configs reference, nil to leave
+	// them to the collector's environment
+	secrets        *secrets.Resolver
+	
+	// File the version history is kept in, "" to keep it in memory only
+	historyPath   string
+	
+	// Checks generated configs with the collector, nil to skip
+	collectorValidator CollectorValidator
+	
+	// Gathers the facts templated user configs are rendered against
+	hostFacts HostFactsFunc
+	
+	// Unknown keys are warnings rather than errors
+	allowUnknownFields bool
 	
 	// Options
 	maxVersions   int
@@ -49,6 +78,39 @@ type ConfigV2 struct {
 	Logger       *zap.Logger
 	MaxVersions  int  // Maximum versions to keep in history
 	EnableBackup bool // Enable automatic backups
+	
+	// Clock supplies the timestamps recorded with generated configs and
+	// versions, defaults to time.Now. Timestamps are never part of the
+	// generated collector config itself.
+	Clock        func() time.Time
+	
+	// Secrets, when set, must resolve every variable a generated config
+	// references, such as ${MYSQL_MONITOR_PASS}, or generation fails. Only
+	// the references are kept in the config; values are resolved again
+	// for the collector's environment when it starts.
+	Secrets      *secrets.Resolver
+	
+	// HistoryDir, when set, is where the version history is persisted, so
+	// it and rollback survive restarts. Only the last MaxVersions versions
+	// are kept.
+	HistoryDir   string
+	
+	// CollectorValidator, when set, checks every generated config the way
+	// the collector loads it before it is versioned, so configs the
+	// collector would refuse at startup fail validation with their
+	// component errors
+	CollectorValidator CollectorValidator
+	
+	// HostFacts supplies the facts templated user configs are rendered
+	// against, defaults to a facts.Gatherer, whose discovered services
+	// are empty
+	HostFacts    HostFactsFunc
+	
+	// AllowUnknownFields accepts user configs with keys that are not
+	// settings, reporting them as warnings, for configs shared with newer
+	// versions. By default validation is strict and they are errors, with
+	// the closest setting as suggestion.
+	AllowUnknownFields bool
 }
 
 // NewEngineV2 creates a new unified configuration engine
@@ -60,56 +122,103 @@ func NewEngineV2(cfg ConfigV2) (*EngineV2, error) {
 	if cfg.MaxVersions <= 0 {
 		cfg.MaxVersions = 10
 	}
+	
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	
+	if cfg.HostFacts == nil {
+		cfg.HostFacts = facts.NewGatherer().Gather
+	}
 
 	validator := schema.NewValidator()
+	validator.SetStrict(!cfg.AllowUnknownFields)
 	generator := templates.NewGenerator()
 
-	return &EngineV2{
+	engine := &EngineV2{
 		logger:       cfg.Logger,
 		validator:    validator,
 		generator:    generator,
 		hookManager:  hooks.NewManager(),
 		versions:     make([]models.ConfigVersion, 0),
 		versionMap:   make(map[int]*versionRecord),
+		applyQueue:   newApplyQueue(),
+		clock:        cfg.Clock,
+		secrets:      cfg.Secrets,
+		collectorValidator: cfg.CollectorValidator,
+		hostFacts:    cfg.HostFacts,
+		allowUnknownFields: cfg.AllowUnknownFields,
 		maxVersions:  cfg.MaxVersions,
 		enableBackup: cfg.EnableBackup,
-	}, nil
+	}
+	
+	if cfg.HistoryDir != "" {
+		engine.historyPath = filepath.Join(cfg.HistoryDir, historyFileName)
+		if err := engine.loadHistory(); err != nil {
+			// The next version saved replaces the history
+			engine.logger.Warn("Failed to load config version history, starting empty",
+				zap.String("path", engine.historyPath),
+				zap.Error(err))
+		}
+	}
+	
+	return engine, nil
 }
 
 // ProcessUserConfig implements the unified configuration processing
 func (e *EngineV2) ProcessUserConfig(ctx context.Context, userConfig []byte) (*models.GeneratedConfig, error) {
+	userConfig, err := e.renderUserConfig(ctx, userConfig)
+	if err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	validatedConfig, result, err := e.generate(ctx, userConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	e.mu.Lock()
-	defer e.mu.Unlock()
+	e.setCurrentLocked(validatedConfig, result)
+	e.mu.Unlock()
 
+	return result, nil
+}
+
+// generate validates a rendered user config and generates its OTel config
+// without making it current
+func (e *EngineV2) generate(ctx context.Context, userConfig []byte) (*models.Config, *models.GeneratedConfig, error) {
 	// Step 1: Validate user configuration
 	validatedConfig, err := e.validator.Validate(userConfig)
 	if err != nil {
-		return nil, fmt.Errorf("validation failed: %w", err)
+		return nil, nil, fmt.Errorf("validation failed: %w", err)
 	}
 
 	// Step 2: Generate OTel configuration from validated config
 	otelConfig, templatesUsed, err := e.generator.Generate(validatedConfig)
 	if err != nil {
-		return nil, fmt.Errorf("generation failed: %w", err)
+		return nil, nil, fmt.Errorf("generation failed: %w", err)
 	}
 
 	// Step 3: Marshal OTel config to YAML
-	var buf bytes.Buffer
-	encoder := yaml.NewEncoder(&buf)
-	encoder.SetIndent(2)
-	if err := encoder.Encode(otelConfig); err != nil {
-		return nil, fmt.Errorf("failed to encode OTel config: %w", err)
+	otelYAML, err := encodeOTelConfig(otelConfig)
+	if err != nil {
+		return nil, nil, err
 	}
-	otelYAML := buf.String()
 
-	// Step 4: Calculate hash
-	hash := e.calculateHash(otelYAML)
+	// Step 4: Check the referenced secrets resolve, without keeping them
+	if e.secrets != nil {
+		if _, err := e.secrets.Resolve(ctx, secrets.References(otelYAML)); err != nil {
+			return nil, nil, fmt.Errorf("secret resolution failed: %w", err)
+		}
+	}
+
+	// Step 5: Calculate hash
+	hash := HashOTelConfig(otelYAML)
 
-	// Step 5: Create result
+	// Step 6: Create result
 	result := &models.GeneratedConfig{
 		OTelConfig:   otelYAML,
 		Hash:         hash,
-		GeneratedAt:  time.Now(),
+		GeneratedAt:  e.clock(),
 		Templates:    templatesUsed,
 		Metadata: map[string]string{
 			"generator_version": "2.0",
@@ -117,31 +226,56 @@ func (e *EngineV2) ProcessUserConfig(ctx context.Context, userConfig []byte) (*m
 		},
 	}
 
-	// Store current config
-	e.currentConfig = validatedConfig
-	e.currentOTel = otelYAML
+	return validatedConfig, result, nil
+}
 
-	return result, nil
+// setCurrentLocked makes a generated config the current one. The caller
+// must hold e.mu.
+func (e *EngineV2) setCurrentLocked(validatedConfig *models.Config, generated *models.GeneratedConfig) {
+	e.currentConfig = validatedConfig
+	e.currentOTel = generated.OTelConfig
+	e.currentGenerated = generated
 }
 
-// ApplyConfig implements the ConfigProvider interface
+// ApplyConfig implements the ConfigProvider interface. Concurrent calls are
+// queued and applied one at a time in arrival order; the result carries the
+// request ID whose progress GetApplyStatus reports.
 func (e *EngineV2) ApplyConfig(ctx context.Context, update *models.ConfigUpdate) (*models.ConfigResult, error) {
+	req, err := e.applyQueue.enqueue(update.Metadata[RequestIDMetadataKey], update.Source)
+	if err != nil {
+		return &models.ConfigResult{
+			Success: false,
+			Error: models.NewError(
+				models.ErrCodeConfigConflict,
+				"Failed to queue configuration",
+				models.ErrorCategoryConfig,
+				models.SeverityError,
+			).WithDetails(err.Error()),
+		}, err
+	}
+	
+	return e.runApply(ctx, update, req)
+}
+
+// applyConfig validates, generates and records a configuration. It runs at
+// the head of the apply queue and reports its stage on req.
+func (e *EngineV2) applyConfig(ctx context.Context, update *models.ConfigUpdate, req *applyRequest) (*models.ConfigResult, error) {
 	e.logger.Info("Applying configuration",
+		zap.String("requestID", req.status.RequestID),
 		zap.String("source", update.Source),
 		zap.Bool("dryRun", update.DryRun))
 
-	// Validate the configuration
+	// Render templates, then validate the configuration. The history
+	// keeps the templated config, so a rollback renders it again.
+	e.applyQueue.setState(req, ApplyStateValidating)
 	validationResult := &models.ValidationResult{Valid: true}
-	_, err := e.validateUserConfig(update.Config, update.Format)
+	userConfig, err := e.renderUserConfig(ctx, update.Config)
+	if err == nil {
+		_, err = e.validateUserConfig(userConfig, update.Format)
+	}
 	if err != nil {
 		validationResult.Valid = false
-		validationResult.Errors = []models.ValidationError{
-			{
-				Path:    "/",
-				Message: err.Error(),
-				Code:    "VALIDATION_FAILED",
-			},
-		}
+		validationResult.Errors = validationErrors(err)
 		
 		return &models.ConfigResult{
 			Success:          false,
@@ -154,17 +288,11 @@ func (e *EngineV2) ApplyConfig(ctx context.Context, update *models.ConfigUpdate)
 			).WithDetails(err.Error()),
 		}, nil
 	}
-
-	// If dry run, return validation result only
-	if update.DryRun {
-		return &models.ConfigResult{
-			Success:          true,
-			ValidationResult: validationResult,
-		}, nil
-	}
+	validationResult.Warnings = e.configWarnings(userConfig)
 
 	// Generate new configuration
-	generated, err := e.ProcessUserConfig(ctx, update.Config)
+	e.applyQueue.setState(req, ApplyStateGenerating)
+	validatedConfig, generated, err := e.generate(ctx, userConfig)
 	if err != nil {
 		return &models.ConfigResult{
 			Success: false,
@@ -177,12 +305,36 @@ func (e *EngineV2) ApplyConfig(ctx context.Context, update *models.ConfigUpdate)
 		}, err
 	}
 
+	// The collector must accept the generated config too
+	if !e.validateWithCollector(ctx, generated.OTelConfig, validationResult) {
+		return &models.ConfigResult{
+			Success:          false,
+			ValidationResult: validationResult,
+			Error: models.NewError(
+				models.ErrCodeConfigInvalid,
+				"Collector rejected the configuration",
+				models.ErrorCategoryConfig,
+				models.SeverityError,
+			).WithDetails(validationErrorDetails(validationResult.Errors)),
+		}, nil
+	}
+
+	// If dry run, return validation result only
+	if update.DryRun {
+		return &models.ConfigResult{
+			Success:          true,
+			ValidationResult: validationResult,
+		}, nil
+	}
+
 	// Create new version
+	e.applyQueue.setState(req, ApplyStateApplying)
 	e.mu.Lock()
+	e.setCurrentLocked(validatedConfig, generated)
 	newVersion := e.currentVersion + 1
 	configVersion := models.ConfigVersion{
 		Version:     newVersion,
-		AppliedAt:   time.Now(),
+		AppliedAt:   e.clock(),
 		Source:      update.Source,
 		Author:      update.Author,
 		Description: update.Description,
@@ -195,6 +347,7 @@ func (e *EngineV2) ApplyConfig(ctx context.Context, update *models.ConfigUpdate)
 	record := &versionRecord{
 		Version:    configVersion,
 		UserConfig: string(update.Config),
+		OTelConfig: generated.OTelConfig,
 	}
 	
 	// Add to version history
@@ -208,6 +361,11 @@ func (e *EngineV2) ApplyConfig(ctx context.Context, update *models.ConfigUpdate)
 		delete(e.versionMap, oldVersion.Version)
 		e.versions = e.versions[1:]
 	}
+	if err := e.saveHistoryLocked(); err != nil {
+		e.logger.Warn("Failed to persist config version history",
+			zap.String("path", e.historyPath),
+			zap.Error(err))
+	}
 	e.mu.Unlock()
 
 	// Notify hooks
@@ -231,26 +389,48 @@ func (e *EngineV2) ApplyConfig(ctx context.Context, update *models.ConfigUpdate)
 	}, nil
 }
 
-// ValidateConfig implements the ConfigProvider interface
+// ValidateConfig implements the ConfigProvider interface. Templated configs
+// are rendered first. With a collector validator the generated config is
+// checked by the collector as well.
 func (e *EngineV2) ValidateConfig(ctx context.Context, config []byte) (*models.ValidationResult, error) {
-	_, err := e.validator.Validate(config)
+	config, err := e.renderUserConfig(ctx, config)
+	if err == nil {
+		_, err = e.validator.Validate(config)
+	}
 	if err != nil {
 		return &models.ValidationResult{
-			Valid: false,
-			Errors: []models.ValidationError{
+			Valid:  false,
+			Errors: validationErrors(err),
+		}, nil
+	}
+
+	result := &models.ValidationResult{
+		Valid:    true,
+		Warnings: e.configWarnings(config),
+	}
+	e.mu.RLock()
+	checkCollector := e.collectorValidator != nil
+	e.mu.RUnlock()
+	if checkCollector {
+		_, generated, err := e.generate(ctx, config)
+		if err != nil {
+			result.Valid = false
+			result.Errors = []models.ValidationError{
 				{
 					Path:    "/",
 					Message: err.Error(),
-					Code:    "VALIDATION_FAILED",
+					Code:    "GENERATION_FAILED",
 				},
-			},
-		}, nil
+			}
+			return result, nil
+		}
+		if !e.validateWithCollector(ctx, generated.OTelConfig, result) {
+			return result, nil
+		}
 	}
 
-	return &models.ValidationResult{
-		Valid: true,
-		Info:  []string{"Configuration is valid"},
-	}, nil
+	result.Info = []string{"Configuration is valid"}
+	return result, nil
 }
 
 // GetCurrentConfig implements the ConfigProvider interface
@@ -294,36 +474,68 @@ func (e *EngineV2) GetConfigHistory(ctx context.Context, limit int) ([]*models.C
 	return result, nil
 }
 
+// RollbackConfigMetadataKey marks a version created by a rollback; its
+// value is the version that was restored
+const RollbackConfigMetadataKey = "rollback_to"
+
 // RollbackConfig implements the ConfigProvider interface
 func (e *EngineV2) RollbackConfig(ctx context.Context, version int) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-	
-	// Find the version
-	var targetVersion *models.ConfigVersion
-	for _, v := range e.versions {
-		if v.Version == version {
-			targetVersion = &v
-			break
-		}
-	}
+	_, err := e.RollbackToVersion(ctx, version, "")
+	return err
+}
+
+// RollbackToVersion regenerates the collector config from the user config
+// stored with version and records it as a new version, so the history
+// keeps the versions rolled back from. It is queued like ApplyConfig.
+func (e *EngineV2) RollbackToVersion(ctx context.Context, version int, author string) (*models.ConfigResult, error) {
+	e.mu.RLock()
+	record, exists := e.versionMap[version]
+	current := e.currentVersion
+	e.mu.RUnlock()
 	
-	if targetVersion == nil {
-		return models.NewError(
+	if !exists {
+		return nil, models.NewError(
 			models.ErrCodeResourceNotFound,
 			fmt.Sprintf("Version %d not found", version),
 			models.ErrorCategoryConfig,
 			models.SeverityError,
 		)
 	}
+	if version == current {
+		return nil, models.NewError(
+			models.ErrCodeConfigConflict,
+			fmt.Sprintf("Version %d is already the current configuration", version),
+			models.ErrorCategoryConfig,
+			models.SeverityWarning,
+		)
+	}
 	
-	// In a real implementation, we would restore the config from backup
-	// For now, just log the action
 	e.logger.Info("Rolling back configuration",
+		zap.Int("fromVersion", current),
 		zap.Int("targetVersion", version),
-		zap.String("hash", targetVersion.Hash))
+		zap.String("hash", record.Version.Hash))
 	
-	return nil
+	result, err := e.ApplyConfig(ctx, &models.ConfigUpdate{
+		Config:      []byte(record.UserConfig),
+		Format:      "yaml",
+		Source:      "rollback",
+		Author:      author,
+		Description: fmt.Sprintf("Rollback to version %d", version),
+		Metadata: map[string]string{
+			RollbackConfigMetadataKey: strconv.Itoa(version),
+		},
+	})
+	if err != nil {
+		return result, err
+	}
+	if !result.Success {
+		// A stored config that no longer validates
+		if result.Error != nil {
+			return result, result.Error
+		}
+		return result, fmt.Errorf("rollback to version %d failed", version)
+	}
+	return result, nil
 }
 
 // RegisterHook registers a configuration change hook
@@ -352,11 +564,74 @@ func (e *EngineV2) validateUserConfig(data []byte, format string) (*models.Confi
 	}
 }
 
-// calculateHash calculates SHA256 hash of content
-func (e *EngineV2) calculateHash(content string) string {
-	h := sha256.New()
-	h.Write([]byte(content))
-	return hex.EncodeToString(h.Sum(nil))
+// ValidationErrors are the problems schema validation found in a user
+// config, each located by path, line and column
+type ValidationErrors = schema.ValidationErrors
+
+// validationErrors lists the problems of a failed validation, as one
+// error when they were not located
+func validationErrors(err error) []models.ValidationError {
+	var located schema.ValidationErrors
+	if errors.As(err, &located) {
+		return located
+	}
+	return []models.ValidationError{
+		{
+			Path:    "/",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		},
+	}
+}
+
+// configWarnings reports the deprecated settings of a valid user config,
+// which were upgraded in memory, and the keys that are not settings, which
+// only validate when unknown fields are allowed
+func (e *EngineV2) configWarnings(userConfig []byte) []string {
+	var warnings []string
+	if deprecated, err := e.validator.Deprecations(userConfig); err == nil {
+		for _, field := range deprecated {
+			warnings = append(warnings, schema.FormatError(field))
+		}
+	}
+	if !e.allowUnknownFields {
+		return warnings
+	}
+	unknown, err := e.validator.UnknownFields(userConfig)
+	if err != nil {
+		return warnings
+	}
+	for _, field := range unknown {
+		warnings = append(warnings, schema.FormatError(field)+" (ignored)")
+	}
+	return warnings
+}
+
+// encodeOTelConfig renders a generated OTel config as YAML. The output is
+// byte-stable for equal configs: mapping keys are written in sorted order
+// (which yaml.v3 does for every Go map, so map iteration order never leaks
+// into it), indentation is fixed and nothing time- or host-dependent is
+// added.
+func encodeOTelConfig(otelConfig map[string]interface{}) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(otelConfig); err != nil {
+		return "", fmt.Errorf("failed to encode OTel config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("failed to encode OTel config: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// HashOTelConfig returns the generation hash of a collector config: the hex
+// SHA-256 of its bytes. Since generation is deterministic, hosts given the
+// same NRDOT config report the same hash, and the hash of a config file on
+// disk can be compared with the one a supervisor reports.
+func HashOTelConfig(otelConfig string) string {
+	sum := sha256.Sum256([]byte(otelConfig))
+	return hex.EncodeToString(sum[:])
 }
 
 // GetCapabilities returns the provider capabilities
@@ -424,6 +699,25 @@ func (e *EngineV2) ExportConfig(ctx context.Context, format string) ([]byte, err
 	return []byte(ver.UserConfig), nil
 }
 
+// GetGeneratedConfig returns the collector config generated from the current
+// configuration
+func (e *EngineV2) GetGeneratedConfig(ctx context.Context) (*models.GeneratedConfig, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	
+	if e.currentGenerated == nil {
+		return nil, models.NewError(
+			models.ErrCodeConfigMissing,
+			"No configuration loaded",
+			models.ErrorCategoryConfig,
+			models.SeverityWarning,
+		)
+	}
+	
+	generated := *e.currentGenerated
+	return &generated, nil
+}
+
 // GenerateConfig creates an OTel config from user config
 func (e *EngineV2) GenerateConfig(ctx context.Context, userConfig []byte) (*models.GeneratedConfig, error) {
 	return e.ProcessUserConfig(ctx, userConfig)
@@ -0,0 +1,290 @@
+package configengine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-config-engine/pkg/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEngineV2_ProcessUserConfig_HostChecks(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	userConfig := []byte(`service:
+  name: web-01
+checks:
+  - name: postgres
+    type: tcp
+    target: localhost:5432
+  - name: root-disk
+    type: disk
+    target: /
+    max_used_percent: 85
+    interval: 5m
+`)
+
+	generated, err := engine.ProcessUserConfig(context.Background(), userConfig)
+	require.NoError(t, err)
+	assert.Contains(t, generated.Templates, "nrhostcheck_receiver")
+
+	var otel struct {
+		Receivers map[string]struct {
+			Checks []map[string]interface{} `yaml:"checks"`
+		} `yaml:"receivers"`
+		Service struct {
+			Pipelines map[string]struct {
+				Receivers []string `yaml:"receivers"`
+			} `yaml:"pipelines"`
+		} `yaml:"service"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(generated.OTelConfig), &otel))
+
+	checks := otel.Receivers["nrhostcheck"].Checks
+	require.Len(t, checks, 2)
+	assert.Equal(t, "localhost:5432", checks[0]["target"])
+	assert.Equal(t, 85, checks[1]["max_used_percent"])
+	assert.Equal(t, "5m0s", checks[1]["interval"])
+
+	// Results go out as metrics, state changes as log events
+	assert.Contains(t, otel.Service.Pipelines["metrics"].Receivers, "nrhostcheck")
+	assert.Contains(t, otel.Service.Pipelines["logs"].Receivers, "nrhostcheck")
+}
+
+func TestEngineV2_ProcessUserConfig_InvalidHostCheck(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	_, err := engine.ProcessUserConfig(context.Background(), []byte(`service:
+  name: web-01
+checks:
+  - name: ping
+    type: icmp
+`))
+	assert.Error(t, err)
+}
+
+func TestEngineV2_ProcessUserConfig_LogLevel(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	logLevel := func(userConfig string) string {
+		generated, err := engine.ProcessUserConfig(context.Background(), []byte(userConfig))
+		require.NoError(t, err)
+		var otel struct {
+			Service struct {
+				Telemetry struct {
+					Logs struct {
+						Level string `yaml:"level"`
+					} `yaml:"logs"`
+				} `yaml:"telemetry"`
+			} `yaml:"service"`
+		}
+		require.NoError(t, yaml.Unmarshal([]byte(generated.OTelConfig), &otel))
+		return otel.Service.Telemetry.Logs.Level
+	}
+
+	assert.Equal(t, "info", logLevel("service:\n  name: web-01\n"))
+	assert.Equal(t, "debug", logLevel("service:\n  name: web-01\nlogging:\n  level: debug\n"))
+
+	_, err := engine.ProcessUserConfig(context.Background(), []byte("service:\n  name: web-01\nlogging:\n  level: trace\n"))
+	assert.Error(t, err)
+}
+
+func TestEngineV2_ProcessUserConfig_Reproducible(t *testing.T) {
+	userConfig := []byte(`service:
+  name: web-01
+metrics:
+  enabled: true
+logs:
+  enabled: true
+  paths: [/var/log/app.log]
+checks:
+  - name: api
+    type: http
+    target: http://localhost:8080/health
+    attributes:
+      team: payments
+      tier: gold
+      region: us-east-1
+      env: prod
+`)
+
+	// Same input, fresh engines: identical bytes, hash and templates
+	first, err := newTestEngineV2(t).ProcessUserConfig(context.Background(), userConfig)
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		generated, err := newTestEngineV2(t).ProcessUserConfig(context.Background(), userConfig)
+		require.NoError(t, err)
+		require.Equal(t, first.OTelConfig, generated.OTelConfig)
+		require.Equal(t, first.Hash, generated.Hash)
+		require.Equal(t, first.Templates, generated.Templates)
+	}
+
+	assert.Equal(t, HashOTelConfig(first.OTelConfig), first.Hash)
+	assert.Len(t, first.Hash, 64)
+
+	// Reordering the input keys does not change the output
+	reordered := []byte(`checks:
+  - attributes:
+      env: prod
+      region: us-east-1
+      tier: gold
+      team: payments
+    target: http://localhost:8080/health
+    type: http
+    name: api
+logs:
+  paths: [/var/log/app.log]
+  enabled: true
+metrics:
+  enabled: true
+service:
+  name: web-01
+`)
+	generated, err := newTestEngineV2(t).ProcessUserConfig(context.Background(), reordered)
+	require.NoError(t, err)
+	assert.Equal(t, first.OTelConfig, generated.OTelConfig)
+	assert.Equal(t, first.Hash, generated.Hash)
+}
+
+func TestEngineV2_ProcessUserConfig_Clock(t *testing.T) {
+	fixed := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	engine, err := NewEngineV2(ConfigV2{Clock: func() time.Time { return fixed }})
+	require.NoError(t, err)
+
+	generated, err := engine.ProcessUserConfig(context.Background(), []byte("service:\n  name: web-01\n"))
+	require.NoError(t, err)
+	assert.Equal(t, fixed, generated.GeneratedAt)
+
+	// The timestamp is metadata only, never part of the hashed config
+	later, err := NewEngineV2(ConfigV2{})
+	require.NoError(t, err)
+	regenerated, err := later.ProcessUserConfig(context.Background(), []byte("service:\n  name: web-01\n"))
+	require.NoError(t, err)
+	assert.Equal(t, generated.Hash, regenerated.Hash)
+}
+
+func TestEncodeOTelConfig_SortsMapKeys(t *testing.T) {
+	// Build equal configs with maps filled in opposite orders
+	build := func(reverse bool) map[string]interface{} {
+		headers := make(map[string]interface{})
+		receivers := make(map[string]interface{})
+		for i := 0; i < 50; i++ {
+			n := i
+			if reverse {
+				n = 49 - i
+			}
+			headers[fmt.Sprintf("x-header-%02d", n)] = fmt.Sprintf("value-%d", n)
+			receivers[fmt.Sprintf("receiver/%02d", n)] = map[string]interface{}{"n": n}
+		}
+		return map[string]interface{}{
+			"receivers": receivers,
+			"exporters": map[string]interface{}{"otlp": map[string]interface{}{"headers": headers}},
+		}
+	}
+
+	forward, err := encodeOTelConfig(build(false))
+	require.NoError(t, err)
+	backward, err := encodeOTelConfig(build(true))
+	require.NoError(t, err)
+	assert.Equal(t, forward, backward)
+	assert.Regexp(t, "(?s)x-header-00.*x-header-01.*x-header-49", forward)
+}
+
+func TestEngineV2_RollbackToVersion(t *testing.T) {
+	engine := newTestEngineV2(t)
+	ctx := context.Background()
+
+	update := func(traces string) *models.ConfigUpdate {
+		return &models.ConfigUpdate{
+			Config: []byte("service:\n  name: web-01\ntraces:\n  enabled: " + traces + "\n"),
+			Format: "yaml",
+			Source: "api",
+		}
+	}
+
+	first, err := engine.ApplyConfig(ctx, update("false"))
+	require.NoError(t, err)
+	firstGenerated, err := engine.GetGeneratedConfig(ctx)
+	require.NoError(t, err)
+
+	_, err = engine.ApplyConfig(ctx, update("true"))
+	require.NoError(t, err)
+	current, err := engine.GetGeneratedConfig(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, firstGenerated.Hash, current.Hash)
+
+	result, err := engine.RollbackToVersion(ctx, first.Version, "ops")
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Version)
+
+	// The restored config is regenerated and recorded as a new version
+	restored, err := engine.GetGeneratedConfig(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, firstGenerated.Hash, restored.Hash)
+	assert.Equal(t, firstGenerated.OTelConfig, restored.OTelConfig)
+
+	history, err := engine.GetConfigHistory(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, "rollback", history[2].Source)
+	assert.Equal(t, "ops", history[2].Author)
+	assert.Equal(t, "1", history[2].Metadata[RollbackConfigMetadataKey])
+	assert.Equal(t, history[0].Hash, history[2].Hash)
+
+	exported, err := engine.ExportConfig(ctx, "yaml")
+	require.NoError(t, err)
+	assert.Equal(t, string(update("false").Config), string(exported))
+}
+
+func TestEngineV2_RollbackToVersion_Errors(t *testing.T) {
+	engine := newTestEngineV2(t)
+	ctx := context.Background()
+
+	assert.Error(t, engine.RollbackConfig(ctx, 1))
+
+	result, err := engine.ApplyConfig(ctx, testUpdate("web-01"))
+	require.NoError(t, err)
+
+	// Rolling back to the current version is a conflict
+	err = engine.RollbackConfig(ctx, result.Version)
+	require.Error(t, err)
+	var errInfo *models.ErrorInfo
+	require.ErrorAs(t, err, &errInfo)
+	assert.Equal(t, models.ErrCodeConfigConflict, errInfo.Code)
+
+	err = engine.RollbackConfig(ctx, 42)
+	require.ErrorAs(t, err, &errInfo)
+	assert.Equal(t, models.ErrCodeResourceNotFound, errInfo.Code)
+}
+
+func TestEngineV2_ProcessUserConfig_Secrets(t *testing.T) {
+	dir := t.TempDir()
+	engine, err := NewEngineV2(ConfigV2{
+		Secrets: secrets.NewResolver(&secrets.FileProvider{Dir: dir}),
+	})
+	require.NoError(t, err)
+
+	userConfig := []byte(`service:
+  name: web-01
+license_key: ${NEW_RELIC_LICENSE_KEY}
+`)
+
+	_, err = engine.ProcessUserConfig(context.Background(), userConfig)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "NEW_RELIC_LICENSE_KEY")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "NEW_RELIC_LICENSE_KEY"), []byte("0123456789abcdef"), 0600))
+	generated, err := engine.ProcessUserConfig(context.Background(), userConfig)
+	require.NoError(t, err)
+
+	// The config keeps the reference, never the value
+	assert.Contains(t, generated.OTelConfig, "${NEW_RELIC_LICENSE_KEY}")
+	assert.NotContains(t, generated.OTelConfig, "0123456789abcdef")
+}
@@ -0,0 +1,89 @@
+package configengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// historyFileName holds the version history under ConfigV2.HistoryDir
+const historyFileName = "config_history.json"
+
+// versionHistory is the content of the history file, oldest version first
+type versionHistory struct {
+	Versions []*versionRecord `json:"versions"`
+}
+
+// loadHistory restores the version history from the history file, keeping
+// the last maxVersions versions. A missing file is an empty history. The
+// newest version restored is the current version, so versions applied
+// after a restart continue its numbering; the current config itself is
+// only set once a config is processed again.
+func (e *EngineV2) loadHistory() error {
+	data, err := os.ReadFile(e.historyPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading version history: %w", err)
+	}
+
+	var history versionHistory
+	if err := json.Unmarshal(data, &history); err != nil {
+		return fmt.Errorf("decoding version history: %w", err)
+	}
+
+	records := make([]*versionRecord, 0, len(history.Versions))
+	for _, record := range history.Versions {
+		if record == nil || record.Version.Version <= 0 {
+			return fmt.Errorf("decoding version history: invalid version record")
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Version.Version < records[j].Version.Version
+	})
+	if len(records) > e.maxVersions {
+		records = records[len(records)-e.maxVersions:]
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, record := range records {
+		e.versions = append(e.versions, record.Version)
+		e.versionMap[record.Version.Version] = record
+		e.currentVersion = record.Version.Version
+	}
+	return nil
+}
+
+// saveHistoryLocked replaces the history file atomically with the versions
+// in memory. It does nothing without a HistoryDir. Callers must hold e.mu.
+func (e *EngineV2) saveHistoryLocked() error {
+	if e.historyPath == "" {
+		return nil
+	}
+
+	history := versionHistory{Versions: make([]*versionRecord, 0, len(e.versions))}
+	for _, version := range e.versions {
+		if record, ok := e.versionMap[version.Version]; ok {
+			history.Versions = append(history.Versions, record)
+		}
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding version history: %w", err)
+	}
+
+	// User configs may hold credentials
+	tmp := e.historyPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing version history: %w", err)
+	}
+	if err := os.Rename(tmp, e.historyPath); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing version history: %w", err)
+	}
+	return nil
+}
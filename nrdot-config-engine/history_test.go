@@ -0,0 +1,111 @@
+package configengine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newHistoryEngine(t *testing.T, dir string, maxVersions int) *EngineV2 {
+	engine, err := NewEngineV2(ConfigV2{
+		Logger:      zaptest.NewLogger(t),
+		MaxVersions: maxVersions,
+		HistoryDir:  dir,
+	})
+	require.NoError(t, err)
+	return engine
+}
+
+func TestEngineV2_History_SurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	engine := newHistoryEngine(t, dir, 10)
+	for _, name := range []string{"web-01", "web-02"} {
+		update := testUpdate(name)
+		update.Author = "ops"
+		_, err := engine.ApplyConfig(ctx, update)
+		require.NoError(t, err)
+	}
+	generated, err := engine.GetGeneratedConfig(ctx)
+	require.NoError(t, err)
+	before, err := engine.GetConfigHistory(ctx, 0)
+	require.NoError(t, err)
+
+	info, err := os.Stat(filepath.Join(dir, historyFileName))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	restarted := newHistoryEngine(t, dir, 10)
+	after, err := restarted.GetConfigHistory(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, after, 2)
+	for i := range before {
+		assert.Equal(t, before[i].Hash, after[i].Hash)
+		assert.Equal(t, "ops", after[i].Author)
+		assert.True(t, before[i].AppliedAt.Equal(after[i].AppliedAt))
+	}
+	assert.Equal(t, generated.OTelConfig, restarted.versionMap[2].OTelConfig)
+
+	exported, err := restarted.ExportConfig(ctx, "yaml")
+	require.NoError(t, err)
+	assert.Equal(t, string(testUpdate("web-02").Config), string(exported))
+
+	// Rollback works across the restart and numbering continues
+	result, err := restarted.RollbackToVersion(ctx, 1, "ops")
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Version)
+	restored, err := restarted.GetGeneratedConfig(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, before[0].Hash, restored.Hash)
+}
+
+func TestEngineV2_History_Pruned(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	engine := newHistoryEngine(t, dir, 5)
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		_, err := engine.ApplyConfig(ctx, testUpdate(name))
+		require.NoError(t, err)
+	}
+
+	// Pruned when saved, and again when loaded with a lower limit
+	restarted := newHistoryEngine(t, dir, 5)
+	history, err := restarted.GetConfigHistory(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 5)
+	assert.Equal(t, 2, history[0].Version)
+
+	restarted = newHistoryEngine(t, dir, 3)
+	history, err = restarted.GetConfigHistory(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, 4, history[0].Version)
+	assert.Equal(t, 6, history[2].Version)
+	assert.Error(t, restarted.RollbackConfig(ctx, 3))
+}
+
+func TestEngineV2_History_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, historyFileName)
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0600))
+
+	// An unreadable history starts empty and is replaced by the next save
+	engine := newHistoryEngine(t, dir, 10)
+	history, err := engine.GetConfigHistory(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	_, err = engine.ApplyConfig(context.Background(), testUpdate("web-01"))
+	require.NoError(t, err)
+	restarted := newHistoryEngine(t, dir, 10)
+	history, err = restarted.GetConfigHistory(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+}
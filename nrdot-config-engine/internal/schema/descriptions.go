@@ -0,0 +1,98 @@
+package schema
+
+// fieldDescriptions describes every setting Reference lists, by path. New
+// fields of models.Config need an entry here, which the reference test
+// checks.
+var fieldDescriptions = map[string]string{
+	"config_version": "Version of the configuration format, 2 for this release. Configs without it are version 1 and upgraded when loaded",
+
+	"service":             "Identification of the monitored service",
+	"service.name":        "Service name, attached to all telemetry as service.name",
+	"service.environment": "Deployment environment, such as production or staging",
+	"service.version":     "Version of the service, attached as service.version",
+	"service.tags":        "Tags attached to all telemetry as resource attributes",
+
+	"license_key": "New Relic license key used to export telemetry",
+
+	"metrics":                "Metrics collection",
+	"metrics.enabled":        "Collect and export metrics",
+	"metrics.interval":       "How often metrics are collected, such as 30s",
+	"metrics.hostmetrics":    "Collect host metrics: CPU, memory, disk, filesystem, network and load",
+	"metrics.processmetrics": "Collect per-process metrics",
+	"metrics.custommetrics":  "Reserved for custom metric settings, not yet used in the generated configuration",
+
+	"traces":             "Trace collection",
+	"traces.enabled":     "Receive and export traces",
+	"traces.samplerate":  "Fraction of traces kept, from 0 to 1",
+	"traces.propagators": "Trace context propagators, such as tracecontext and baggage. Not yet used in the generated configuration",
+
+	"logs":               "Log collection",
+	"logs.enabled":       "Collect and export logs",
+	"logs.paths":         "Files to tail, glob patterns allowed",
+	"logs.includestdout": "Collect the standard output of monitored processes",
+	"logs.includestderr": "Collect the standard error of monitored processes",
+
+	"security":                      "Data protection and TLS",
+	"security.redactsecrets":        "Redact passwords, API keys, tokens and other secrets from telemetry",
+	"security.redactpatterns":       "Additional regular expressions whose matches are redacted",
+	"security.alloweddomains":       "Domains telemetry may be exported to. Not yet enforced",
+	"security.tlsconfig":            "TLS settings for receivers. Not yet used in the generated configuration",
+	"security.tlsconfig.enabled":    "Serve receivers over TLS",
+	"security.tlsconfig.certfile":   "Path of the TLS certificate",
+	"security.tlsconfig.keyfile":    "Path of the TLS private key",
+	"security.tlsconfig.cafile":     "Path of the CA certificate used to verify clients",
+	"security.tlsconfig.skipverify": "Skip certificate verification, for testing only",
+
+	"processing":                                     "Processing applied to telemetry before export",
+	"processing.enrich":                              "Metadata added to telemetry",
+	"processing.enrich.addhostmetadata":              "Add host attributes such as host.name and os.type",
+	"processing.enrich.addcloudmetadata":             "Add cloud provider attributes such as cloud.region",
+	"processing.enrich.addk8smetadata":               "Add Kubernetes attributes such as k8s.pod.name",
+	"processing.enrich.customtags":                   "Attributes added to all telemetry",
+	"processing.transform":                           "Metric transformations",
+	"processing.transform.convertunits":              "Convert metrics to standard units, such as bytes to megabytes",
+	"processing.transform.aggregations":              "Metrics aggregated into new metrics",
+	"processing.transform.aggregations[].name":       "Name of the aggregated metric",
+	"processing.transform.aggregations[].metrics":    "Metrics to aggregate",
+	"processing.transform.aggregations[].method":     "Aggregation method, such as sum, avg, min or max",
+	"processing.transform.aggregations[].dimensions": "Attributes the aggregate is grouped by",
+	"processing.transform.aggregations[].window":     "Aggregation window, such as 1m",
+	"processing.transform.calculations":              "Metrics calculated from other metrics",
+	"processing.transform.calculations[].name":       "Name of the calculated metric",
+	"processing.transform.calculations[].expression": "Expression computing the metric from others",
+	"processing.transform.calculations[].unit":       "Unit of the calculated metric",
+	"processing.cardinality":                         "Limits on the number of metric time series",
+	"processing.cardinality.enabled":                 "Enforce cardinality limits",
+	"processing.cardinality.globallimit":             "Maximum number of time series across all metrics",
+	"processing.cardinality.permetric":               "Maximum number of time series by metric name",
+	"processing.cardinality.limitaction":             "What happens to series over the limit: drop, sample or aggregate",
+
+	"export":                             "Where telemetry is sent",
+	"export.endpoint":                    "OTLP endpoint telemetry is exported to",
+	"export.headers":                     "Headers sent with every export request",
+	"export.timeout":                     "Timeout of an export request",
+	"export.retryconfig":                 "Retries of failed exports",
+	"export.retryconfig.enabled":         "Retry failed exports",
+	"export.retryconfig.initialinterval": "Wait before the first retry",
+	"export.retryconfig.maxinterval":     "Longest wait between retries",
+	"export.retryconfig.maxelapsedtime":  "Time after which a failed export is dropped",
+	"export.compression":                 "Compression of export requests, such as gzip",
+
+	"checks":                    "Local synthetic checks run by the nrhostcheck receiver",
+	"checks[].name":             "Name of the check, reported as check.name",
+	"checks[].type":             "Kind of check",
+	"checks[].target":           "Address, URL, hostname or mount point checked",
+	"checks[].interval":         "How often the check runs",
+	"checks[].timeout":          "Timeout of one run of the check",
+	"checks[].method":           "HTTP method of http checks",
+	"checks[].expected_status":  "Status codes an http check accepts",
+	"checks[].max_used_percent": "Disk usage above which a disk check fails",
+	"checks[].command":          "Command a script check runs",
+	"checks[].args":             "Arguments of the script check command",
+	"checks[].attributes":       "Attributes added to the check results",
+
+	"logging":       "Logging of the collector itself",
+	"logging.level": "Level of the collector's own logs: debug, info, warn or error",
+
+	"advanced": "Reserved for settings without a typed field, not yet used in the generated configuration",
+}
@@ -0,0 +1,228 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentConfigVersion is the version of the configuration format this
+// release reads. Configs without config_version are version 1.
+const CurrentConfigVersion = 2
+
+// configVersionKey is the key of the configuration format version
+const configVersionKey = "config_version"
+
+// migration upgrades a config from version from to the next one, in
+// place, and returns the deprecated settings it rewrote
+type migration struct {
+	from    int
+	migrate func(doc *yaml.Node) []models.ValidationError
+}
+
+// migrations upgrade older configs, in order. A change that renames or
+// moves settings adds one, so existing configs keep loading and report
+// what to change instead of failing validation.
+var migrations = []migration{
+	{from: 1, migrate: migrateV1},
+}
+
+// Deprecations returns the deprecated settings of a config, which Validate
+// upgrades in memory, each with the line and column of its key
+func (v *Validator) Deprecations(yamlData []byte) ([]models.ValidationError, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(yamlData, &root); err != nil {
+		return nil, ValidationErrors{yamlError(err, CodeInvalidYAML)}
+	}
+	deprecated, _, err := migrate(&root)
+	return deprecated, err
+}
+
+// migrate upgrades a parsed config to CurrentConfigVersion in place and
+// reports whether it was older. Versions newer than CurrentConfigVersion
+// are rejected.
+func migrate(root *yaml.Node) (deprecated []models.ValidationError, upgraded bool, err error) {
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		// Not a config at all, which the schema reports
+		return nil, false, nil
+	}
+	doc := root.Content[0]
+
+	version, err := configVersion(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	if version == CurrentConfigVersion {
+		return nil, false, nil
+	}
+	for _, m := range migrations {
+		if m.from >= version {
+			deprecated = append(deprecated, m.migrate(doc)...)
+		}
+	}
+	setKey(doc, configVersionKey, &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   "!!int",
+		Value: fmt.Sprint(CurrentConfigVersion),
+	})
+	return deprecated, true, nil
+}
+
+// configVersion returns the config_version of a config, 1 when unset
+func configVersion(doc *yaml.Node) (int, error) {
+	key, value := lookupKey(doc, configVersionKey)
+	if value == nil {
+		return 1, nil
+	}
+
+	var version int
+	if value.Kind != yaml.ScalarNode || value.Tag != "!!int" || value.Decode(&version) != nil {
+		return 0, ValidationErrors{{
+			Path:    configVersionKey,
+			Message: fmt.Sprintf("must be an integer, got %q", value.Value),
+			Code:    CodeInvalidValue,
+			Line:    value.Line,
+			Column:  value.Column,
+		}}
+	}
+	switch {
+	case version < 1:
+		return 0, ValidationErrors{{
+			Path:    configVersionKey,
+			Message: fmt.Sprintf("must be at least 1, got %d", version),
+			Code:    CodeInvalidValue,
+			Line:    value.Line,
+			Column:  value.Column,
+		}}
+	case version > CurrentConfigVersion:
+		return 0, ValidationErrors{{
+			Path: configVersionKey,
+			Message: fmt.Sprintf("version %d is newer than version %d this release reads, upgrade nrdot-host",
+				version, CurrentConfigVersion),
+			Code:   CodeUnsupportedVersion,
+			Line:   key.Line,
+			Column: key.Column,
+		}}
+	}
+	return version, nil
+}
+
+// migrateV1 upgrades a version 1 config. Version 1 set the format version
+// with version, now config_version, and documented the snake_case JSON
+// names of settings, such as host_metrics, which were never read: they
+// become the keys the settings are read from, such as hostmetrics.
+func migrateV1(doc *yaml.Node) []models.ValidationError {
+	var deprecated []models.ValidationError
+	if key, _ := lookupKey(doc, "version"); key != nil {
+		removeKey(doc, "version")
+		deprecated = append(deprecated, deprecatedField(key, "version", configVersionKey))
+	}
+	renameJSONKeys(doc, reflect.TypeOf(models.Config{}), "", &deprecated)
+	return deprecated
+}
+
+// renameJSONKeys renames the keys of node, decoded into type t, that are
+// the JSON name of a setting read from another key, recursively. A key
+// whose setting is also set under its own key is left for strict
+// validation to report.
+func renameJSONKeys(node *yaml.Node, t reflect.Type, path string, deprecated *[]models.ValidationError) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && t != durationType && node.Kind == yaml.MappingNode:
+		fields := structFields(t)
+		renames := jsonRenames(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if renamed, ok := renames[key.Value]; ok {
+				if existing, _ := lookupKey(node, renamed); existing == nil {
+					*deprecated = append(*deprecated,
+						deprecatedField(key, joinPath(path, key.Value), joinPath(path, renamed)))
+					key.Value = renamed
+				}
+			}
+			if ft, ok := fields[key.Value]; ok {
+				renameJSONKeys(value, ft, joinPath(path, key.Value), deprecated)
+			}
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			renameJSONKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), deprecated)
+		}
+	}
+}
+
+// jsonRenames maps the JSON names of the fields of a struct that differ
+// from their YAML keys to the YAML keys
+func jsonRenames(t reflect.Type) map[string]string {
+	renames := make(map[string]string)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, ok := fieldKey(f)
+		if !ok {
+			continue
+		}
+		name := jsonName(f)
+		if name != "" && name != key {
+			renames[name] = key
+		}
+	}
+	return renames
+}
+
+// jsonName returns the JSON name of a struct field, "" when it has none
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// deprecatedField describes a deprecated key and the setting replacing it
+func deprecatedField(key *yaml.Node, path, replacement string) models.ValidationError {
+	return models.ValidationError{
+		Path:    path,
+		Message: "deprecated, use " + replacement,
+		Code:    CodeDeprecatedField,
+		Line:    key.Line,
+		Column:  key.Column,
+	}
+}
+
+// lookupKey returns the key and value nodes of a mapping entry
+func lookupKey(node *yaml.Node, name string) (key, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// setKey sets a mapping entry, adding it first when it is missing
+func setKey(node *yaml.Node, name string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}
+	node.Content = append([]*yaml.Node{key, value}, node.Content...)
+}
+
+// removeKey removes a mapping entry
+func removeKey(node *yaml.Node, name string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
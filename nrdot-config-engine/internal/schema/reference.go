@@ -0,0 +1,229 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+)
+
+// Field is one setting of the user configuration
+type Field struct {
+	Path        string        `json:"path"` // e.g. metrics.interval, checks[].name
+	Type        string        `json:"type"`
+	Default     interface{}   `json:"default,omitempty"`
+	Required    bool          `json:"required,omitempty"`
+	Enum        []interface{} `json:"enum,omitempty"`
+	Minimum     *float64      `json:"minimum,omitempty"`
+	Maximum     *float64      `json:"maximum,omitempty"`
+	Description string        `json:"description,omitempty"`
+}
+
+// durationType is the type of duration settings, written like "30s"
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Reference returns every setting the validator accepts, in config order.
+// Keys, types and defaults come from the models.Config struct the config is
+// decoded into and from applyDefaults, and constraints from the schema, so
+// the reference describes exactly what Validate accepts.
+func (v *Validator) Reference() []Field {
+	var doc schemaNode
+	if err := json.Unmarshal([]byte(v.schemaJSON), &doc); err != nil {
+		panic(fmt.Sprintf("invalid embedded schema: %v", err))
+	}
+
+	defaults := v.defaults()
+	var fields []Field
+	walkFields(reflect.TypeOf(models.Config{}), "", &doc, func(path string, t reflect.Type, node *schemaNode, required bool) {
+		field := Field{
+			Path:        path,
+			Type:        typeName(t),
+			Default:     defaults[path],
+			Required:    required,
+			Description: fieldDescriptions[path],
+		}
+		if node != nil {
+			field.Enum = node.Enum
+			field.Minimum = node.Minimum
+			field.Maximum = node.Maximum
+		}
+		fields = append(fields, field)
+	})
+	return fields
+}
+
+// schemaNode is the part of a JSON schema the reference reads
+type schemaNode struct {
+	Properties map[string]*schemaNode `json:"properties"`
+	Items      *schemaNode            `json:"items"`
+	Required   []string               `json:"required"`
+	Enum       []interface{}          `json:"enum"`
+	Minimum    *float64               `json:"minimum"`
+	Maximum    *float64               `json:"maximum"`
+}
+
+// property returns the schema of a property, nil if the schema has none
+func (n *schemaNode) property(name string) *schemaNode {
+	if n == nil {
+		return nil
+	}
+	return n.Properties[name]
+}
+
+// requires reports whether the schema requires a property
+func (n *schemaNode) requires(name string) bool {
+	if n == nil {
+		return false
+	}
+	for _, required := range n.Required {
+		if required == name {
+			return true
+		}
+	}
+	return false
+}
+
+// walkFields calls visit for every field of struct type t, depth first.
+// Lists of structs are walked with "[]" appended to their path.
+func walkFields(t reflect.Type, prefix string, node *schemaNode, visit func(path string, t reflect.Type, node *schemaNode, required bool)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, ok := fieldKey(f)
+		if !ok {
+			continue
+		}
+
+		// Schema properties named differently from the key never apply to
+		// a decoded field, so only constraints on the key itself are listed
+		child := node.property(key)
+		path := prefix + key
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		visit(path, ft, child, node.requires(key))
+		switch {
+		case ft.Kind() == reflect.Struct && ft != durationType:
+			walkFields(ft, path+".", child, visit)
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct:
+			var items *schemaNode
+			if child != nil {
+				items = child.Items
+			}
+			walkFields(ft.Elem(), path+"[].", items, visit)
+		}
+	}
+}
+
+// fieldKey returns the YAML key a struct field is decoded from, as
+// gopkg.in/yaml.v3 resolves it. ok is false for fields that are never
+// decoded.
+func fieldKey(f reflect.StructField) (key string, ok bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	tag, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	switch tag {
+	case "-":
+		return "", false
+	case "":
+		return strings.ToLower(f.Name), true
+	default:
+		return tag, true
+	}
+}
+
+// typeName describes a Go type in config terms
+func typeName(t reflect.Type) string {
+	if t == durationType {
+		return "duration"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Struct {
+			return "list of objects"
+		}
+		return "list of " + typeName(t.Elem())
+	case reflect.Map:
+		if t.Elem().Kind() == reflect.Interface {
+			return "map"
+		}
+		return "map of " + typeName(t.Elem())
+	case reflect.Struct:
+		return "object"
+	default:
+		return t.Kind().String()
+	}
+}
+
+// defaults returns the values applyDefaults fills in, by path. Besides an
+// empty config it probes one with every feature enabled, as some defaults
+// only apply to enabled features.
+func (v *Validator) defaults() map[string]interface{} {
+	defaults := make(map[string]interface{})
+
+	var enabled models.Config
+	enableAll(reflect.ValueOf(&enabled).Elem())
+
+	for _, probe := range []models.Config{{}, enabled} {
+		applied := probe
+		v.applyDefaults(&applied)
+		diffDefaults(reflect.ValueOf(probe), reflect.ValueOf(applied), "", defaults)
+	}
+	return defaults
+}
+
+// enableAll sets every boolean of a struct to true
+func enableAll(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.Bool:
+			field.SetBool(true)
+		case reflect.Struct:
+			enableAll(field)
+		}
+	}
+}
+
+// diffDefaults records the fields applyDefaults changed from before to
+// after, keeping defaults already found
+func diffDefaults(before, after reflect.Value, prefix string, defaults map[string]interface{}) {
+	t := before.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key, ok := fieldKey(t.Field(i))
+		if !ok {
+			continue
+		}
+		path := prefix + key
+		b, a := before.Field(i), after.Field(i)
+		if b.Kind() == reflect.Struct && b.Type() != durationType {
+			diffDefaults(b, a, path+".", defaults)
+			continue
+		}
+		if _, found := defaults[path]; found || reflect.DeepEqual(b.Interface(), a.Interface()) {
+			continue
+		}
+		if a.Type() == durationType {
+			defaults[path] = time.Duration(a.Int()).String()
+		} else {
+			defaults[path] = a.Interface()
+		}
+	}
+}
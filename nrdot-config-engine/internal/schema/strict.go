@@ -0,0 +1,288 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// Codes of the errors Validate reports, and of the deprecations
+// Deprecations reports
+const (
+	CodeInvalidYAML        = "INVALID_YAML"
+	CodeUnknownField       = "UNKNOWN_FIELD"
+	CodeSchemaViolation    = "SCHEMA_VIOLATION"
+	CodeInvalidValue       = "INVALID_VALUE"
+	CodeUnsupportedVersion = "UNSUPPORTED_VERSION"
+	CodeDeprecatedField    = "DEPRECATED_FIELD"
+)
+
+// ValidationErrors are the problems Validate found in a config, each with
+// the path and, when known, the line and column of the offending key or
+// value
+type ValidationErrors []models.ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = FormatError(err)
+	}
+	return "schema validation failed: " + strings.Join(msgs, "; ")
+}
+
+// FormatError renders a validation error on one line, such as
+//
+//	line 3, column 1: metrcs: unknown field, did you mean "metrics"?
+func FormatError(err models.ValidationError) string {
+	var b strings.Builder
+	if err.Line > 0 {
+		fmt.Fprintf(&b, "line %d, column %d: ", err.Line, err.Column)
+	}
+	if err.Path != "" && err.Path != "/" {
+		b.WriteString(err.Path + ": ")
+	}
+	b.WriteString(err.Message)
+	if err.Suggestion != "" {
+		fmt.Fprintf(&b, ", did you mean %q?", err.Suggestion)
+	}
+	return b.String()
+}
+
+// UnknownFields returns an error for every key of a config that is not a
+// setting, with the closest setting as suggestion. Validate rejects them in
+// strict mode and ignores them otherwise.
+func (v *Validator) UnknownFields(yamlData []byte) ([]models.ValidationError, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(yamlData, &root); err != nil {
+		return nil, ValidationErrors{yamlError(err, CodeInvalidYAML)}
+	}
+	if _, _, err := migrate(&root); err != nil {
+		return nil, err
+	}
+	return unknownFields(&root), nil
+}
+
+// unknownFields checks the keys of a parsed config against models.Config
+func unknownFields(root *yaml.Node) []models.ValidationError {
+	var errs []models.ValidationError
+	if len(root.Content) > 0 {
+		checkKeys(root.Content[0], reflect.TypeOf(models.Config{}), "", &errs)
+	}
+	return errs
+}
+
+// checkKeys checks the keys of node, decoded into type t, recursively.
+// Maps and other free-form values take any key.
+func checkKeys(node *yaml.Node, t reflect.Type, path string, errs *[]models.ValidationError) {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && t != durationType && node.Kind == yaml.MappingNode:
+		fields := structFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				checkKeys(value, t, path, errs)
+				continue
+			}
+			ft, ok := fields[key.Value]
+			if !ok {
+				*errs = append(*errs, models.ValidationError{
+					Path:       joinPath(path, key.Value),
+					Message:    "unknown field",
+					Code:       CodeUnknownField,
+					Line:       key.Line,
+					Column:     key.Column,
+					Suggestion: suggest(key.Value, fields),
+				})
+				continue
+			}
+			checkKeys(value, ft, joinPath(path, key.Value), errs)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			checkKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+// structFields maps the YAML keys of a struct to their field types
+func structFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if key, ok := fieldKey(t.Field(i)); ok {
+			fields[key] = t.Field(i).Type
+		}
+	}
+	return fields
+}
+
+// suggest returns the key closest to an unknown one, if it is close enough
+// to be a typo. Underscores are ignored, so host_metrics suggests
+// hostmetrics.
+func suggest(unknown string, fields map[string]reflect.Type) string {
+	normalized := strings.ReplaceAll(strings.ToLower(unknown), "_", "")
+	best, bestDistance := "", -1
+	for key := range fields {
+		d := editDistance(normalized, strings.ReplaceAll(key, "_", ""))
+		if bestDistance < 0 || d < bestDistance || (d == bestDistance && key < best) {
+			best, bestDistance = key, d
+		}
+	}
+	if bestDistance < 0 || bestDistance > max(2, len(normalized)/3) {
+		return ""
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// schemaErrors turns JSON schema violations into validation errors located
+// in the parsed config
+func schemaErrors(result *gojsonschema.Result, root *yaml.Node) []models.ValidationError {
+	var errs []models.ValidationError
+	for _, resultErr := range result.Errors() {
+		var segments []string
+		if field := resultErr.Field(); field != gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+			segments = strings.Split(field, ".")
+		}
+		node := nodeAt(root, segments)
+		if property, ok := resultErr.Details()["property"].(string); ok && resultErr.Type() == "required" {
+			segments = append(segments, property)
+		}
+
+		err := models.ValidationError{
+			Path:    displayPath(segments),
+			Message: resultErr.Description(),
+			Code:    CodeSchemaViolation,
+		}
+		if node != nil {
+			err.Line, err.Column = node.Line, node.Column
+		}
+		errs = append(errs, err)
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+	return errs
+}
+
+// nodeAt returns the node of a path in the parsed config: the key of a
+// mapping value, so errors point at the key, nil if it does not exist
+func nodeAt(root *yaml.Node, segments []string) *yaml.Node {
+	if len(root.Content) == 0 {
+		return nil
+	}
+	node, at := root.Content[0], root.Content[0]
+	for _, segment := range segments {
+		switch node.Kind {
+		case yaml.MappingNode:
+			var next *yaml.Node
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == segment {
+					at, next = node.Content[i], node.Content[i+1]
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			node = next
+		case yaml.SequenceNode:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[i]
+			at = node
+		default:
+			return nil
+		}
+	}
+	return at
+}
+
+// displayPath writes schema path segments like the reference, with list
+// indexes in brackets: checks[0].type
+func displayPath(segments []string) string {
+	path := ""
+	for _, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil {
+			path += "[" + segment + "]"
+		} else {
+			path = joinPath(path, segment)
+		}
+	}
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// yamlLinePattern finds the line yaml.v3 prefixes its errors with
+var yamlLinePattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// yamlError turns a YAML error into a validation error with its line
+func yamlError(err error, code string) models.ValidationError {
+	return lineError(strings.TrimPrefix(err.Error(), "yaml: "), code)
+}
+
+// decodeErrors turns the errors of decoding into models.Config into
+// validation errors, one for each value that does not fit its field
+func decodeErrors(err error) ValidationErrors {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return ValidationErrors{yamlError(err, CodeInvalidValue)}
+	}
+	var errs ValidationErrors
+	for _, msg := range typeErr.Errors {
+		errs = append(errs, lineError(msg, CodeInvalidValue))
+	}
+	return errs
+}
+
+// lineError parses a "line N: message" error
+func lineError(msg, code string) models.ValidationError {
+	err := models.ValidationError{Path: "/", Message: msg, Code: code}
+	if m := yamlLinePattern.FindStringSubmatch(msg); m != nil {
+		err.Line, _ = strconv.Atoi(m[1])
+		err.Message = m[2]
+	}
+	return err
+}
@@ -5,6 +5,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
 	"github.com/xeipuuv/gojsonschema"
@@ -14,7 +16,9 @@ import (
 // Validator validates user configurations against the NRDOT schema
 type Validator struct {
 	schema        *gojsonschema.Schema
+	schemaJSON    string
 	schemaVersion string
+	strict        bool
 }
 
 // NewValidator creates a new validator with embedded schema
@@ -26,6 +30,7 @@ func NewValidator() *Validator {
 		"type": "object",
 		"required": ["service"],
 		"properties": {
+			"config_version": {"type": "integer", "minimum": 1},
 			"service": {
 				"type": "object",
 				"required": ["name"],
@@ -88,6 +93,32 @@ func NewValidator() *Validator {
 						}
 					}
 				}
+			},
+			"checks": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"required": ["name", "type"],
+					"properties": {
+						"name": {"type": "string", "minLength": 1},
+						"type": {"type": "string", "enum": ["tcp", "http", "dns", "disk", "script"]},
+						"target": {"type": "string"},
+						"interval": {"type": "string"},
+						"timeout": {"type": "string"},
+						"method": {"type": "string"},
+						"expected_status": {"type": "array", "items": {"type": "integer"}},
+						"max_used_percent": {"type": "number", "minimum": 0, "maximum": 100},
+						"command": {"type": "string"},
+						"args": {"type": "array", "items": {"type": "string"}},
+						"attributes": {"type": "object"}
+					}
+				}
+			},
+			"logging": {
+				"type": "object",
+				"properties": {
+					"level": {"type": "string", "enum": ["debug", "info", "warn", "error"]}
+				}
 			}
 		}
 	}`
@@ -97,16 +128,38 @@ func NewValidator() *Validator {
 
 	return &Validator{
 		schema:        schema,
+		schemaJSON:    schemaJSON,
 		schemaVersion: "1.0.0",
+		strict:        true,
 	}
 }
 
-// Validate validates YAML configuration and returns parsed config
+// Validate validates YAML configuration and returns parsed config. Configs
+// older than CurrentConfigVersion are upgraded first, see Deprecations. The
+// problems found are returned as ValidationErrors. In strict mode, the
+// default, keys that are not settings are among them.
 func (v *Validator) Validate(yamlData []byte) (*models.Config, error) {
-	// Parse YAML to interface{}
+	// Parse YAML, keeping positions for the errors
+	var root yaml.Node
+	if err := yaml.Unmarshal(yamlData, &root); err != nil {
+		return nil, ValidationErrors{yamlError(err, CodeInvalidYAML)}
+	}
+
+	// Upgrade older config versions in memory. Positions still refer to
+	// the original config.
+	_, upgraded, err := migrate(&root)
+	if err != nil {
+		return nil, err
+	}
+	if upgraded {
+		if yamlData, err = yaml.Marshal(&root); err != nil {
+			return nil, fmt.Errorf("failed to upgrade config: %w", err)
+		}
+	}
+
 	var data interface{}
 	if err := yaml.Unmarshal(yamlData, &data); err != nil {
-		return nil, fmt.Errorf("invalid YAML: %w", err)
+		return nil, ValidationErrors{yamlError(err, CodeInvalidYAML)}
 	}
 
 	// Convert to JSON for schema validation
@@ -122,20 +175,31 @@ func (v *Validator) Validate(yamlData []byte) (*models.Config, error) {
 		return nil, fmt.Errorf("validation error: %w", err)
 	}
 
+	var errs ValidationErrors
+	if v.strict {
+		errs = append(errs, unknownFields(&root)...)
+	}
 	if !result.Valid() {
-		var errors []string
-		for _, err := range result.Errors() {
-			errors = append(errors, fmt.Sprintf("%s: %s", err.Field(), err.Description()))
-		}
-		return nil, fmt.Errorf("schema validation failed: %v", errors)
+		errs = append(errs, schemaErrors(result, &root)...)
+	}
+	if len(errs) > 0 {
+		return nil, errs
 	}
 
-	// Parse into Config struct
+	// Parse into Config struct. An upgraded config is decoded from its
+	// nodes, whose errors keep the original lines; unknown keys were
+	// already reported.
 	var config models.Config
-	decoder := yaml.NewDecoder(bytes.NewReader(yamlData))
-	decoder.KnownFields(true)
-	if err := decoder.Decode(&config); err != nil {
-		return nil, fmt.Errorf("failed to parse configuration: %w", err)
+	if upgraded {
+		if err := root.Decode(&config); err != nil {
+			return nil, decodeErrors(err)
+		}
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(yamlData))
+		decoder.KnownFields(v.strict)
+		if err := decoder.Decode(&config); err != nil && err != io.EOF {
+			return nil, decodeErrors(err)
+		}
 	}
 
 	// Apply defaults
@@ -144,6 +208,13 @@ func (v *Validator) Validate(yamlData []byte) (*models.Config, error) {
 	return &config, nil
 }
 
+// SetStrict sets whether Validate rejects keys that are not settings,
+// such as a misspelled metrcs. Strict mode is the default; otherwise such
+// keys are ignored and UnknownFields reports them.
+func (v *Validator) SetStrict(strict bool) {
+	v.strict = strict
+}
+
 // GetSchemaVersion returns the schema version
 func (v *Validator) GetSchemaVersion() string {
 	return v.schemaVersion
@@ -153,7 +224,7 @@ func (v *Validator) GetSchemaVersion() string {
 func (v *Validator) applyDefaults(config *models.Config) {
 	// Metrics defaults
 	if config.Metrics.Interval == 0 {
-		config.Metrics.Interval = 60 // 60 seconds
+		config.Metrics.Interval = 60 * time.Second
 	}
 	
 	// Security defaults
@@ -3,10 +3,20 @@ package templates
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/backoff"
 	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
 )
 
+// exportRetryPolicy is how the New Relic exporter retries failed exports
+var exportRetryPolicy = backoff.Policy{
+	Initial:    5 * time.Second,
+	Max:        30 * time.Second,
+	Jitter:     0.5,
+	MaxElapsed: 5 * time.Minute,
+}
+
 // Generator generates OpenTelemetry collector configurations from NRDOT configs
 type Generator struct {
 	templates map[string]string
@@ -46,10 +56,15 @@ func (g *Generator) Generate(config *models.Config) (map[string]interface{}, []s
 		receivers["otlp"] = g.buildOTLPReceiver()
 		templatesUsed = append(templatesUsed, "otlp_receiver")
 	}
+	
+	if len(config.Checks) > 0 {
+		receivers["nrhostcheck"] = g.buildHostCheckReceiver(config)
+		templatesUsed = append(templatesUsed, "nrhostcheck_receiver")
+	}
 
-	// Build processors
+	// Build processors, listed in pipeline order so the output is stable
 	processors := g.buildProcessors(config)
-	for name := range processors {
+	for _, name := range g.getProcessorOrder(processors) {
 		templatesUsed = append(templatesUsed, name+"_processor")
 	}
 
@@ -61,12 +76,16 @@ func (g *Generator) Generate(config *models.Config) (map[string]interface{}, []s
 	// Build pipelines
 	pipelines := g.buildPipelines(config, receivers, processors, exporters)
 
-	// Build service
+	// Build service; the collector logs at info unless configured
+	logLevel := config.Logging.Level
+	if logLevel == "" {
+		logLevel = "info"
+	}
 	service := map[string]interface{}{
 		"pipelines": pipelines,
 		"telemetry": map[string]interface{}{
 			"logs": map[string]interface{}{
-				"level": "info",
+				"level": logLevel,
 			},
 			"metrics": map[string]interface{}{
 				"address": ":8888",
@@ -150,20 +169,65 @@ func (g *Generator) buildFilelogReceiver(config *models.Config) map[string]inter
 	return cfg
 }
 
-// buildOTLPReceiver builds the OTLP receiver config
+// buildOTLPReceiver builds the OTLP receiver config. Endpoints without a
+// host listen on every interface, IPv4 and IPv6, unlike 0.0.0.0.
 func (g *Generator) buildOTLPReceiver() map[string]interface{} {
 	return map[string]interface{}{
 		"protocols": map[string]interface{}{
 			"grpc": map[string]interface{}{
-				"endpoint": "0.0.0.0:4317",
+				"endpoint": ":4317",
 			},
 			"http": map[string]interface{}{
-				"endpoint": "0.0.0.0:4318",
+				"endpoint": ":4318",
 			},
 		},
 	}
 }
 
+// buildHostCheckReceiver builds the host check receiver config
+func (g *Generator) buildHostCheckReceiver(config *models.Config) map[string]interface{} {
+	checks := make([]interface{}, 0, len(config.Checks))
+	for _, check := range config.Checks {
+		cfg := map[string]interface{}{
+			"name": check.Name,
+			"type": check.Type,
+		}
+		if check.Target != "" {
+			cfg["target"] = check.Target
+		}
+		if check.Interval > 0 {
+			cfg["interval"] = check.Interval.String()
+		}
+		if check.Timeout > 0 {
+			cfg["timeout"] = check.Timeout.String()
+		}
+		if check.Method != "" {
+			cfg["method"] = check.Method
+		}
+		if len(check.ExpectedStatus) > 0 {
+			cfg["expected_status"] = check.ExpectedStatus
+		}
+		if check.MaxUsedPercent > 0 {
+			cfg["max_used_percent"] = check.MaxUsedPercent
+		}
+		if check.Command != "" {
+			cfg["command"] = check.Command
+		}
+		if len(check.Args) > 0 {
+			cfg["args"] = check.Args
+		}
+		if len(check.Attributes) > 0 {
+			cfg["attributes"] = check.Attributes
+		}
+		checks = append(checks, cfg)
+	}
+	
+	return map[string]interface{}{
+		"collection_interval": "60s",
+		"checks":              checks,
+	}
+}
+
 // buildProcessors builds all configured processors
 func (g *Generator) buildProcessors(config *models.Config) map[string]interface{} {
 	processors := make(map[string]interface{})
@@ -233,12 +297,7 @@ func (g *Generator) buildNewRelicExporter(config *models.Config) map[string]inte
 			"api-key": config.LicenseKey,
 		},
 		"compression": "gzip",
-		"retry_on_failure": map[string]interface{}{
-			"enabled": true,
-			"initial_interval": "5s",
-			"max_interval": "30s",
-			"max_elapsed_time": "300s",
-		},
+		"retry_on_failure": exportRetryPolicy.RetryOnFailure(),
 	}
 
 	// Add custom headers
@@ -258,21 +317,28 @@ func (g *Generator) buildPipelines(config *models.Config, receivers, processors,
 	// Get processor names in order
 	processorNames := g.getProcessorOrder(processors)
 
-	if config.Metrics.Enabled && len(receivers) > 0 {
-		var metricReceivers []string
+	// Host checks report through both pipelines: results as metrics, state
+	// changes as log events
+	_, hostChecks := receivers["nrhostcheck"]
+
+	var metricReceivers []string
+	if config.Metrics.Enabled {
 		if _, ok := receivers["hostmetrics"]; ok {
 			metricReceivers = append(metricReceivers, "hostmetrics")
 		}
 		if _, ok := receivers["prometheus"]; ok {
 			metricReceivers = append(metricReceivers, "prometheus")
 		}
-		
-		if len(metricReceivers) > 0 {
-			pipelines["metrics"] = map[string]interface{}{
-				"receivers":  metricReceivers,
-				"processors": processorNames,
-				"exporters":  []string{"otlp/newrelic"},
-			}
+	}
+	if hostChecks {
+		metricReceivers = append(metricReceivers, "nrhostcheck")
+	}
+	
+	if len(metricReceivers) > 0 {
+		pipelines["metrics"] = map[string]interface{}{
+			"receivers":  metricReceivers,
+			"processors": processorNames,
+			"exporters":  []string{"otlp/newrelic"},
 		}
 	}
 
@@ -284,9 +350,17 @@ func (g *Generator) buildPipelines(config *models.Config, receivers, processors,
 		}
 	}
 
+	var logReceivers []string
 	if config.Logs.Enabled {
+		logReceivers = append(logReceivers, "filelog")
+	}
+	if hostChecks {
+		logReceivers = append(logReceivers, "nrhostcheck")
+	}
+	
+	if len(logReceivers) > 0 {
 		pipelines["logs"] = map[string]interface{}{
-			"receivers":  []string{"filelog"},
+			"receivers":  logReceivers,
 			"processors": processorNames,
 			"exporters":  []string{"otlp/newrelic"},
 		}
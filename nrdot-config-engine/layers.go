@@ -0,0 +1,237 @@
+package configengine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfDirName is the directory next to a config file holding its override
+// files, e.g. /etc/nrdot/conf.d for /etc/nrdot/config.yaml
+const ConfDirName = "conf.d"
+
+// Tags of override values changing how they merge
+const (
+	// TagAppend appends a list to the list it overrides, e.g.
+	// `exclude: !append [backup]`
+	TagAppend = "!append"
+
+	// TagReplace replaces a mapping instead of merging into it
+	TagReplace = "!replace"
+)
+
+// LayeredConfig is a config file merged with the override files of its
+// conf.d directory
+type LayeredConfig struct {
+	// Data is the merged config, or the config file as is without overrides
+	Data []byte
+
+	// Files are the files merged, the config file first
+	Files []string
+}
+
+// LoadLayeredConfig reads the config file at path and merges the *.yaml and
+// *.yml files of its conf.d directory over it, in lexical order, so
+// 10-base.yaml is overridden by 90-host.yaml:
+//
+//   - mappings merge key by key, recursively
+//   - scalars and lists replace what they override, lists tagged !append
+//     are appended to it instead
+//   - mappings tagged !replace replace what they override
+//
+// A missing conf.d directory is no overrides. Hidden files are skipped.
+func LoadLayeredConfig(path string) (*LayeredConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	layered := &LayeredConfig{Data: data, Files: []string{path}}
+
+	overrides, err := OverrideFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(overrides) == 0 {
+		return layered, nil
+	}
+
+	var merged yaml.Node
+	if err := yaml.Unmarshal(data, &merged); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, override := range overrides {
+		overrideData, err := os.ReadFile(override)
+		if err != nil {
+			return nil, err
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal(overrideData, &node); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", override, err)
+		}
+		if err := mergeDocuments(&merged, &node); err != nil {
+			return nil, fmt.Errorf("failed to merge %s: %w", override, err)
+		}
+		layered.Files = append(layered.Files, override)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&merged); err != nil {
+		return nil, fmt.Errorf("failed to encode merged config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode merged config: %w", err)
+	}
+	layered.Data = buf.Bytes()
+	return layered, nil
+}
+
+// OverrideFiles returns the override files of the config file at path, in
+// merge order
+func OverrideFiles(path string) ([]string, error) {
+	dir := filepath.Join(filepath.Dir(path), ConfDirName)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || entry.IsDir() {
+			continue
+		}
+		if ext := filepath.Ext(name); ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// mergeDocuments merges the document override into base. Empty documents
+// merge as nothing.
+func mergeDocuments(base, override *yaml.Node) error {
+	if len(override.Content) == 0 {
+		return nil
+	}
+	if len(base.Content) == 0 {
+		*base = *override
+		stripMergeTags(base)
+		return nil
+	}
+	return mergeNodes(base.Content[0], override.Content[0], "")
+}
+
+// mergeNodes merges override into base, at key path in errors
+func mergeNodes(base, override *yaml.Node, path string) error {
+	switch {
+	case override.Tag == TagAppend:
+		if override.Kind != yaml.SequenceNode {
+			return fmt.Errorf("%s: %s needs a list", displayPath(path), TagAppend)
+		}
+		stripMergeTags(override)
+		if base.Kind != yaml.SequenceNode {
+			return fmt.Errorf("%s: %s to a value that is not a list", displayPath(path), TagAppend)
+		}
+		base.Content = append(base.Content, override.Content...)
+		return nil
+
+	case override.Tag == TagReplace:
+		if override.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s: %s needs a mapping", displayPath(path), TagReplace)
+		}
+		stripMergeTags(override)
+		*base = *override
+		return nil
+
+	case base.Kind == yaml.MappingNode && override.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(override.Content); i += 2 {
+			key, value := override.Content[i], override.Content[i+1]
+			if existing := mappingValue(base, key.Value); existing != nil {
+				if err := mergeNodes(existing, value, path+"."+key.Value); err != nil {
+					return err
+				}
+				continue
+			}
+			if value.Tag == TagAppend && value.Kind == yaml.SequenceNode {
+				// Nothing to append to; the list is the value
+				value.Tag = ""
+			}
+			if err := checkMergeTags(value, path+"."+key.Value); err != nil {
+				return err
+			}
+			stripMergeTags(value)
+			base.Content = append(base.Content, key, value)
+		}
+		return nil
+
+	default:
+		if err := checkMergeTags(override, path); err != nil {
+			return err
+		}
+		stripMergeTags(override)
+		*base = *override
+		return nil
+	}
+}
+
+// mappingValue returns the value of key in mapping, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// checkMergeTags rejects merge tags on the wrong kind of value in a value
+// that is not merged into anything
+func checkMergeTags(node *yaml.Node, path string) error {
+	switch {
+	case node.Tag == TagAppend && node.Kind != yaml.SequenceNode:
+		return fmt.Errorf("%s: %s needs a list", displayPath(path), TagAppend)
+	case node.Tag == TagReplace && node.Kind != yaml.MappingNode:
+		return fmt.Errorf("%s: %s needs a mapping", displayPath(path), TagReplace)
+	}
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := checkMergeTags(node.Content[i+1], path+"."+node.Content[i].Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// stripMergeTags removes merge tags from node and its children, so the
+// merged config decodes like any other
+func stripMergeTags(node *yaml.Node) {
+	if node.Tag == TagAppend || node.Tag == TagReplace {
+		node.Tag = ""
+	}
+	for _, child := range node.Content {
+		stripMergeTags(child)
+	}
+}
+
+// displayPath returns a key path for errors, e.g. services.mysql
+func displayPath(path string) string {
+	if path == "" {
+		return "top level"
+	}
+	return strings.TrimPrefix(path, ".")
+}
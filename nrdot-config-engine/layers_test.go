@@ -0,0 +1,141 @@
+package configengine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// writeLayers writes config.yaml and the given conf.d files to a new
+// directory, returning the config file path
+func writeLayers(t *testing.T, base string, overrides map[string]string) string {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(base), 0644))
+	if overrides != nil {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, ConfDirName), 0755))
+	}
+	for name, content := range overrides {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ConfDirName, name), []byte(content), 0644))
+	}
+	return path
+}
+
+func TestLoadLayeredConfig_NoOverrides(t *testing.T) {
+	base := "service:\n    name: web-01 # kept as written\n"
+	path := writeLayers(t, base, nil)
+
+	layered, err := LoadLayeredConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, base, string(layered.Data))
+	assert.Equal(t, []string{path}, layered.Files)
+}
+
+func TestLoadLayeredConfig_Merge(t *testing.T) {
+	path := writeLayers(t, `service:
+  name: web-01
+  environment: production
+  tags:
+    team: platform
+metrics:
+  interval: 60s
+  exclude: [system.paging.*]
+processes:
+  include: [nginx]
+`, map[string]string{
+		"10-team.yaml": `service:
+  tags:
+    owner: db-team
+metrics:
+  exclude: !append [system.network.*]
+`,
+		"20-host.yml": `service:
+  environment: staging
+metrics:
+  interval: 30s
+processes:
+  include: [postgres]
+logs:
+  enabled: true
+`,
+		"30-tags.yaml": `service:
+  tags: !replace
+    cost_center: "1234"
+`,
+		"README.md":    "not a config",
+		".hidden.yaml": "service: {name: ignored}",
+	})
+
+	layered, err := LoadLayeredConfig(path)
+	require.NoError(t, err)
+	dir := filepath.Dir(path)
+	assert.Equal(t, []string{
+		path,
+		filepath.Join(dir, ConfDirName, "10-team.yaml"),
+		filepath.Join(dir, ConfDirName, "20-host.yml"),
+		filepath.Join(dir, ConfDirName, "30-tags.yaml"),
+	}, layered.Files)
+
+	var merged map[string]interface{}
+	require.NoError(t, yaml.Unmarshal(layered.Data, &merged))
+	assert.Equal(t, map[string]interface{}{
+		"service": map[string]interface{}{
+			"name":        "web-01",
+			"environment": "staging",
+			"tags":        map[string]interface{}{"cost_center": "1234"},
+		},
+		"metrics": map[string]interface{}{
+			"interval": "30s",
+			"exclude":  []interface{}{"system.paging.*", "system.network.*"},
+		},
+		"processes": map[string]interface{}{
+			"include": []interface{}{"postgres"},
+		},
+		"logs": map[string]interface{}{
+			"enabled": true,
+		},
+	}, merged)
+	assert.NotContains(t, string(layered.Data), "!append")
+	assert.NotContains(t, string(layered.Data), "!replace")
+}
+
+func TestLoadLayeredConfig_NewKeys(t *testing.T) {
+	// Appending to a list that does not exist yet sets it
+	path := writeLayers(t, "service:\n  name: web-01\n", map[string]string{
+		"10.yaml": "metrics:\n  exclude: !append [system.paging.*]\n",
+		"20.yaml": "",
+	})
+
+	layered, err := LoadLayeredConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "service:\n  name: web-01\nmetrics:\n  exclude: [system.paging.*]\n", string(layered.Data))
+}
+
+func TestLoadLayeredConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		override string
+		errMsg   string
+	}{
+		{"append to a mapping", "service: !append [x]\n", "service: !append to a value that is not a list"},
+		{"append a scalar", "metrics:\n  interval: !append 30s\n", "metrics.interval: !append needs a list"},
+		{"replace with a list", "metrics: !replace [x]\n", "metrics: !replace needs a mapping"},
+		{"invalid YAML", "service: [\n", "failed to parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeLayers(t, "service:\n  name: web-01\nmetrics:\n  interval: 60s\n", map[string]string{
+				"10.yaml": tt.override,
+			})
+			_, err := LoadLayeredConfig(path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+			assert.Contains(t, err.Error(), "10.yaml")
+		})
+	}
+}
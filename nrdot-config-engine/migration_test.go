@@ -0,0 +1,124 @@
+package configengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const v1Config = `version: 1
+service:
+  name: web-01
+metrics:
+  host_metrics: true
+processing:
+  cardinality:
+    enabled: true
+    global_limit: 5000
+`
+
+func TestEngineV2_ApplyConfig_UpgradesV1(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	result, err := engine.ApplyConfig(context.Background(), &models.ConfigUpdate{
+		Config: []byte(v1Config),
+		Format: "yaml",
+		Source: "api",
+	})
+	require.NoError(t, err)
+	require.True(t, result.Success, "%+v", result.ValidationResult)
+	assert.Equal(t, []string{
+		"line 1, column 1: version: deprecated, use config_version",
+		"line 5, column 3: metrics.host_metrics: deprecated, use metrics.hostmetrics",
+		"line 9, column 5: processing.cardinality.global_limit: deprecated, use processing.cardinality.globallimit",
+	}, result.ValidationResult.Warnings)
+
+	current, err := engine.GetCurrentConfig(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, current.ConfigVersion)
+	assert.True(t, current.Metrics.HostMetrics)
+	assert.Equal(t, 5000, current.Processing.Cardinality.GlobalLimit)
+
+	var otel struct {
+		Processors map[string]map[string]interface{} `yaml:"processors"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(engine.currentOTel), &otel))
+	assert.Equal(t, 5000, otel.Processors["nrcap"]["global_limit"])
+
+	// The upgrade happens in memory; the history keeps the config as written
+	assert.Equal(t, v1Config, engine.versionMap[result.Version].UserConfig)
+}
+
+func TestEngineV2_ValidateConfig_CurrentVersion(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	result, err := engine.ValidateConfig(context.Background(), []byte("config_version: 2\nservice:\n  name: web-01\nmetrics:\n  hostmetrics: true\n"))
+	require.NoError(t, err)
+	assert.True(t, result.Valid, "%+v", result.Errors)
+	assert.Empty(t, result.Warnings)
+
+	// Version 2 reads settings only by their keys
+	result, err = engine.ValidateConfig(context.Background(), []byte("config_version: 2\nservice:\n  name: web-01\nmetrics:\n  host_metrics: true\n"))
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "metrics.host_metrics", result.Errors[0].Path)
+	assert.Equal(t, "UNKNOWN_FIELD", result.Errors[0].Code)
+}
+
+func TestEngineV2_ValidateConfig_UnsupportedVersion(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	result, err := engine.ValidateConfig(context.Background(), []byte("service:\n  name: web-01\nconfig_version: 3\n"))
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Equal(t, []models.ValidationError{{
+		Path:    "config_version",
+		Message: "version 3 is newer than version 2 this release reads, upgrade nrdot-host",
+		Code:    "UNSUPPORTED_VERSION",
+		Line:    3,
+		Column:  1,
+	}}, result.Errors)
+
+	for _, version := range []string{"0", "two", "[2]"} {
+		result, err := engine.ValidateConfig(context.Background(), []byte("config_version: "+version+"\nservice:\n  name: web-01\n"))
+		require.NoError(t, err)
+		assert.False(t, result.Valid, version)
+		require.Len(t, result.Errors, 1, version)
+		assert.Equal(t, "INVALID_VALUE", result.Errors[0].Code, version)
+	}
+}
+
+func TestEngineV2_ValidateConfig_UpgradedErrorPositions(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	// Errors in an upgraded config point at the config as written
+	result, err := engine.ValidateConfig(context.Background(), []byte(`service:
+  name: web-01
+metrics:
+  process_metrics: true
+  interval: soon
+`))
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "INVALID_VALUE", result.Errors[0].Code)
+	assert.Equal(t, 5, result.Errors[0].Line)
+
+	// A deprecated key set next to its replacement is not renamed
+	result, err = engine.ValidateConfig(context.Background(), []byte(`service:
+  name: web-01
+metrics:
+  hostmetrics: true
+  host_metrics: false
+`))
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "metrics.host_metrics", result.Errors[0].Path)
+	assert.Equal(t, "hostmetrics", result.Errors[0].Suggestion)
+}
@@ -0,0 +1,209 @@
+package facts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Instance metadata services
+const (
+	defaultAWSEndpoint   = "http://169.254.169.254"
+	defaultAzureEndpoint = "http://169.254.169.254"
+	defaultGCPEndpoint   = "http://metadata.google.internal"
+)
+
+// metadataTimeout bounds each metadata request, off cloud they only time out
+const metadataTimeout = time.Second
+
+// Cloud are the facts about the cloud instance the host is
+type Cloud struct {
+	Provider     string // aws, azure or gcp
+	Region       string
+	Zone         string
+	InstanceID   string
+	InstanceType string
+	// AccountID is the AWS account, Azure subscription or GCP project
+	AccountID string
+}
+
+// detectCloud asks every provider's metadata service at once and keeps the
+// first answer, in provider order
+func (g *Gatherer) detectCloud(ctx context.Context) Cloud {
+	client := &http.Client{Timeout: metadataTimeout}
+	probes := []func(context.Context, *http.Client) (Cloud, error){g.awsCloud, g.azureCloud, g.gcpCloud}
+
+	results := make([]chan Cloud, len(probes))
+	for i, probe := range probes {
+		results[i] = make(chan Cloud, 1)
+		go func(probe func(context.Context, *http.Client) (Cloud, error), result chan<- Cloud) {
+			cloud, err := probe(ctx, client)
+			if err != nil {
+				cloud = Cloud{}
+			}
+			result <- cloud
+		}(probe, results[i])
+	}
+
+	var detected Cloud
+	for _, result := range results {
+		cloud := <-result
+		if detected.Provider == "" && cloud.Provider != "" {
+			detected = cloud
+		}
+	}
+	return detected
+}
+
+// awsCloud reads the EC2 instance identity document through IMDSv2
+func (g *Gatherer) awsCloud(ctx context.Context, client *http.Client) (Cloud, error) {
+	endpoint := endpointOr(g.awsEndpoint, defaultAWSEndpoint)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return Cloud{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	token, err := doMetadataRequest(client, req)
+	if err != nil {
+		return Cloud{}, err
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/latest/dynamic/instance-identity/document", nil)
+	if err != nil {
+		return Cloud{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", string(token))
+	data, err := doMetadataRequest(client, req)
+	if err != nil {
+		return Cloud{}, err
+	}
+
+	var doc struct {
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+		InstanceID       string `json:"instanceId"`
+		InstanceType     string `json:"instanceType"`
+		AccountID        string `json:"accountId"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return Cloud{}, err
+	}
+	return Cloud{
+		Provider:     "aws",
+		Region:       doc.Region,
+		Zone:         doc.AvailabilityZone,
+		InstanceID:   doc.InstanceID,
+		InstanceType: doc.InstanceType,
+		AccountID:    doc.AccountID,
+	}, nil
+}
+
+// azureCloud reads the compute metadata of the Azure instance metadata
+// service
+func (g *Gatherer) azureCloud(ctx context.Context, client *http.Client) (Cloud, error) {
+	endpoint := endpointOr(g.azureEndpoint, defaultAzureEndpoint)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/metadata/instance/compute?api-version=2021-02-01", nil)
+	if err != nil {
+		return Cloud{}, err
+	}
+	req.Header.Set("Metadata", "true")
+	data, err := doMetadataRequest(client, req)
+	if err != nil {
+		return Cloud{}, err
+	}
+
+	var compute struct {
+		Location       string `json:"location"`
+		Zone           string `json:"zone"`
+		VMID           string `json:"vmId"`
+		VMSize         string `json:"vmSize"`
+		SubscriptionID string `json:"subscriptionId"`
+	}
+	if err := json.Unmarshal(data, &compute); err != nil {
+		return Cloud{}, err
+	}
+	return Cloud{
+		Provider:     "azure",
+		Region:       compute.Location,
+		Zone:         compute.Zone,
+		InstanceID:   compute.VMID,
+		InstanceType: compute.VMSize,
+		AccountID:    compute.SubscriptionID,
+	}, nil
+}
+
+// gcpCloud reads the instance and project of the GCE metadata server
+func (g *Gatherer) gcpCloud(ctx context.Context, client *http.Client) (Cloud, error) {
+	endpoint := endpointOr(g.gcpEndpoint, defaultGCPEndpoint)
+
+	get := func(p string) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/computeMetadata/v1"+p, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		return doMetadataRequest(client, req)
+	}
+
+	data, err := get("/instance/?recursive=true")
+	if err != nil {
+		return Cloud{}, err
+	}
+	var instance struct {
+		ID          json.Number `json:"id"`
+		Zone        string      `json:"zone"`        // projects/<number>/zones/<zone>
+		MachineType string      `json:"machineType"` // projects/<number>/machineTypes/<type>
+	}
+	if err := json.Unmarshal(data, &instance); err != nil {
+		return Cloud{}, err
+	}
+	project, err := get("/project/project-id")
+	if err != nil {
+		return Cloud{}, err
+	}
+
+	zone := path.Base(instance.Zone)
+	region := zone
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		region = zone[:i]
+	}
+	return Cloud{
+		Provider:     "gcp",
+		Region:       region,
+		Zone:         zone,
+		InstanceID:   instance.ID.String(),
+		InstanceType: path.Base(instance.MachineType),
+		AccountID:    strings.TrimSpace(string(project)),
+	}, nil
+}
+
+// doMetadataRequest returns the body of a successful metadata request
+func doMetadataRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: status %d", req.Method, req.URL.Path, resp.StatusCode)
+	}
+	return body, nil
+}
+
+func endpointOr(endpoint, fallback string) string {
+	if endpoint == "" {
+		return fallback
+	}
+	return strings.TrimSuffix(endpoint, "/")
+}
@@ -0,0 +1,124 @@
+// Package facts gathers the facts about a host that user config templates
+// are rendered against: its name, platform, size, cloud instance and the
+// services discovered on it.
+package facts
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Host are the facts about the host a config is generated on
+type Host struct {
+	Hostname string
+	OS       string // runtime.GOOS, such as linux
+	Arch     string // runtime.GOARCH, such as amd64
+	NumCPU   int
+	// MemoryMB is the total memory, 0 when it is not known
+	MemoryMB int
+	// Cloud is empty when the host is not a cloud instance
+	Cloud Cloud
+	// Services are the types of the services discovered on the host, such
+	// as mysql or redis, sorted
+	Services []string
+}
+
+// HasService reports whether a service of the type was discovered on the
+// host
+func (h *Host) HasService(service string) bool {
+	for _, s := range h.Services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// Gatherer gathers host facts. Cloud metadata is looked up on the first
+// Gather and kept, since it does not change while the host runs. Services
+// are whatever was last passed to SetServices.
+type Gatherer struct {
+	// DisableCloud skips the cloud metadata lookup
+	DisableCloud bool
+
+	cloudOnce sync.Once
+	cloud     Cloud
+
+	mu       sync.RWMutex
+	services []string
+
+	// metadata endpoints and meminfo are replaceable in tests
+	awsEndpoint   string
+	azureEndpoint string
+	gcpEndpoint   string
+	meminfo       string
+}
+
+// NewGatherer creates a gatherer that looks up cloud metadata
+func NewGatherer() *Gatherer {
+	return &Gatherer{}
+}
+
+// SetServices sets the types of the services discovered on the host
+func (g *Gatherer) SetServices(services []string) {
+	sorted := append([]string(nil), services...)
+	sort.Strings(sorted)
+	g.mu.Lock()
+	g.services = sorted
+	g.mu.Unlock()
+}
+
+// Gather returns the current facts. Facts that cannot be read are left
+// empty rather than failing, so a template can test for them.
+func (g *Gatherer) Gather(ctx context.Context) (*Host, error) {
+	hostname, _ := os.Hostname()
+	host := &Host{
+		Hostname: hostname,
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		NumCPU:   runtime.NumCPU(),
+		MemoryMB: g.memoryMB(),
+	}
+	if !g.DisableCloud {
+		g.cloudOnce.Do(func() {
+			g.cloud = g.detectCloud(ctx)
+		})
+		host.Cloud = g.cloud
+	}
+	g.mu.RLock()
+	host.Services = append([]string(nil), g.services...)
+	g.mu.RUnlock()
+	return host, nil
+}
+
+// memoryMB reads the total memory from /proc/meminfo
+func (g *Gatherer) memoryMB() int {
+	path := g.meminfo
+	if path == "" {
+		path = "/proc/meminfo"
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return 0
+			}
+			return kb / 1024
+		}
+	}
+	return 0
+}
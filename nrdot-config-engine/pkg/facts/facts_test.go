@@ -0,0 +1,142 @@
+package facts
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// offCloud points every metadata endpoint at a server that answers 404
+func offCloud(t *testing.T, g *Gatherer) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+	g.awsEndpoint = server.URL
+	g.azureEndpoint = server.URL
+	g.gcpEndpoint = server.URL
+}
+
+func TestGatherer_Gather(t *testing.T) {
+	meminfo := filepath.Join(t.TempDir(), "meminfo")
+	require.NoError(t, os.WriteFile(meminfo, []byte("MemTotal:       16384000 kB\nMemFree:         1024000 kB\n"), 0644))
+
+	g := NewGatherer()
+	g.meminfo = meminfo
+	offCloud(t, g)
+	g.SetServices([]string{"redis", "mysql"})
+
+	host, err := g.Gather(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, runtime.GOOS, host.OS)
+	assert.Equal(t, runtime.NumCPU(), host.NumCPU)
+	assert.Equal(t, 16000, host.MemoryMB)
+	assert.Empty(t, host.Cloud.Provider)
+	assert.Equal(t, []string{"mysql", "redis"}, host.Services)
+	assert.True(t, host.HasService("mysql"))
+	assert.False(t, host.HasService("nginx"))
+}
+
+func TestGatherer_AWS(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			calls++
+			w.Write([]byte("token"))
+		case r.URL.Path == "/latest/dynamic/instance-identity/document" && r.Header.Get("X-aws-ec2-metadata-token") == "token":
+			w.Write([]byte(`{"region":"us-east-1","availabilityZone":"us-east-1a","instanceId":"i-0abc","instanceType":"m5.large","accountId":"123456789012"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	g := NewGatherer()
+	g.awsEndpoint = server.URL
+	g.azureEndpoint = server.URL
+	g.gcpEndpoint = server.URL
+
+	for i := 0; i < 2; i++ {
+		host, err := g.Gather(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, Cloud{
+			Provider:     "aws",
+			Region:       "us-east-1",
+			Zone:         "us-east-1a",
+			InstanceID:   "i-0abc",
+			InstanceType: "m5.large",
+			AccountID:    "123456789012",
+		}, host.Cloud)
+	}
+	assert.Equal(t, 1, calls, "cloud metadata is looked up once")
+}
+
+func TestGatherer_GCP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.URL.Path {
+		case "/computeMetadata/v1/instance/":
+			w.Write([]byte(`{"id":4520031799277581759,"zone":"projects/123/zones/europe-west1-b","machineType":"projects/123/machineTypes/e2-medium"}`))
+		case "/computeMetadata/v1/project/project-id":
+			w.Write([]byte("my-project"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	g := NewGatherer()
+	offCloud(t, g)
+	g.gcpEndpoint = server.URL
+
+	host, err := g.Gather(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Cloud{
+		Provider:     "gcp",
+		Region:       "europe-west1",
+		Zone:         "europe-west1-b",
+		InstanceID:   "4520031799277581759",
+		InstanceType: "e2-medium",
+		AccountID:    "my-project",
+	}, host.Cloud)
+}
+
+func TestGatherer_Azure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metadata/instance/compute" || r.Header.Get("Metadata") != "true" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"location":"westeurope","zone":"2","vmId":"0c9a-41","vmSize":"Standard_D2s_v3","subscriptionId":"sub-1"}`))
+	}))
+	defer server.Close()
+
+	g := NewGatherer()
+	offCloud(t, g)
+	g.azureEndpoint = server.URL
+
+	host, err := g.Gather(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "azure", host.Cloud.Provider)
+	assert.Equal(t, "westeurope", host.Cloud.Region)
+	assert.Equal(t, "Standard_D2s_v3", host.Cloud.InstanceType)
+}
+
+func TestGatherer_DisableCloud(t *testing.T) {
+	g := NewGatherer()
+	g.DisableCloud = true
+	g.awsEndpoint = "http://127.0.0.1:1"
+
+	host, err := g.Gather(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Cloud{}, host.Cloud)
+}
@@ -40,9 +40,11 @@ func (f HookFunc) Name() string {
 	return "HookFunc"
 }
 
-// Manager manages configuration change hooks
+// Manager manages configuration change hooks and the scripts run around
+// collector reloads
 type Manager struct {
-	hooks []Hook
+	hooks   []Hook
+	scripts ScriptConfig
 }
 
 // NewManager creates a new hook manager
@@ -0,0 +1,241 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/tracecontext"
+	"gopkg.in/yaml.v3"
+)
+
+// Reload hook stages
+const (
+	StagePreReload  = "pre_reload"
+	StagePostReload = "post_reload"
+)
+
+// Failure policies of a pre_reload script
+const (
+	// FailureAbort cancels the reload, the collector keeps its config
+	FailureAbort = "abort"
+	// FailureWarn reports the failure and reloads anyway
+	FailureWarn = "warn"
+)
+
+const (
+	// DefaultScriptTimeout bounds each run of a reload script
+	DefaultScriptTimeout = 30 * time.Second
+	// maxScriptOutput is how much script output is kept for errors
+	maxScriptOutput = 1024
+)
+
+// ScriptConfig configures executables run around collector reloads, such
+// as one draining the host from a load balancer before the reload and one
+// adding it back after. Scripts get the reload in their environment:
+//
+//	NRDOT_HOOK            pre_reload or post_reload
+//	NRDOT_OLD_VERSION     config version being replaced
+//	NRDOT_NEW_VERSION     config version being loaded
+//	NRDOT_CONFIG_PATH     user config file
+//	NRDOT_RELOAD_RESULT   success or failure (post_reload)
+//	NRDOT_RELOAD_ERROR    why the reload failed (post_reload)
+//	TRACEPARENT           trace context of the reload, when it is traced
+//
+// plus Env. A post_reload script runs after every reload that was
+// attempted, and its failure is only reported.
+type ScriptConfig struct {
+	PreReload  string            `yaml:"pre_reload,omitempty"`
+	PostReload string            `yaml:"post_reload,omitempty"`
+	Timeout    time.Duration     `yaml:"timeout,omitempty"`    // per run, default DefaultScriptTimeout
+	OnFailure  string            `yaml:"on_failure,omitempty"` // pre_reload failure policy, default abort
+	Env        map[string]string `yaml:"env,omitempty"`
+}
+
+// Validate checks the scripts are absolute paths and fills in defaults
+func (c *ScriptConfig) Validate() error {
+	for stage, path := range map[string]string{StagePreReload: c.PreReload, StagePostReload: c.PostReload} {
+		if path != "" && !filepath.IsAbs(path) {
+			return fmt.Errorf("%s: script must be an absolute path, got %q", stage, path)
+		}
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultScriptTimeout
+	}
+	switch c.OnFailure {
+	case "":
+		c.OnFailure = FailureAbort
+	case FailureAbort, FailureWarn:
+	default:
+		return fmt.Errorf("on_failure must be %s or %s, got %q", FailureAbort, FailureWarn, c.OnFailure)
+	}
+	for name := range c.Env {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+	}
+	return nil
+}
+
+// LoadScriptConfig reads reload scripts from a YAML file:
+//
+//	hooks:
+//	  pre_reload: /usr/local/bin/drain.sh
+//	  post_reload: /usr/local/bin/undrain.sh
+//	  timeout: 1m
+//	  on_failure: warn
+func LoadScriptConfig(path string) (ScriptConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ScriptConfig{}, fmt.Errorf("failed to read reload hooks: %w", err)
+	}
+
+	var file struct {
+		Hooks ScriptConfig `yaml:"hooks"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return ScriptConfig{}, fmt.Errorf("failed to parse reload hooks %s: %w", path, err)
+	}
+	if err := file.Hooks.Validate(); err != nil {
+		return ScriptConfig{}, fmt.Errorf("invalid reload hooks %s: %w", path, err)
+	}
+	return file.Hooks, nil
+}
+
+// ReloadEvent describes the reload a script runs around
+type ReloadEvent struct {
+	OldVersion int
+	NewVersion int
+	ConfigPath string
+	// Error is why the reload failed, for post_reload
+	Error error
+}
+
+// ScriptError is a reload script that failed or timed out
+type ScriptError struct {
+	Stage  string
+	Script string
+	Output string // combined stdout and stderr, truncated
+	Err    error
+	// Abort is set when the reload must not go ahead
+	Abort bool
+}
+
+func (e *ScriptError) Error() string {
+	msg := fmt.Sprintf("%s hook %s failed: %v", e.Stage, e.Script, e.Err)
+	if e.Output != "" {
+		msg += ": " + e.Output
+	}
+	return msg
+}
+
+func (e *ScriptError) Unwrap() error { return e.Err }
+
+// SetScripts sets the reload scripts run by RunPreReload and RunPostReload
+func (m *Manager) SetScripts(config ScriptConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	m.scripts = config
+	return nil
+}
+
+// RunPreReload runs the pre_reload script, if any. A failure is returned
+// as a *ScriptError whose Abort field tells if the reload must stop.
+func (m *Manager) RunPreReload(ctx context.Context, event ReloadEvent) error {
+	err := m.runScript(ctx, StagePreReload, m.scripts.PreReload, event)
+	if err != nil {
+		err.Abort = m.scripts.OnFailure != FailureWarn
+		return err
+	}
+	return nil
+}
+
+// RunPostReload runs the post_reload script, if any, returning a
+// *ScriptError if it fails
+func (m *Manager) RunPostReload(ctx context.Context, event ReloadEvent) error {
+	if err := m.runScript(ctx, StagePostReload, m.scripts.PostReload, event); err != nil {
+		return err
+	}
+	return nil
+}
+
+// runScript runs one script with the reload in its environment, killing it
+// after the timeout or once ctx is done
+func (m *Manager) runScript(ctx context.Context, stage, script string, event ReloadEvent) *ScriptError {
+	if script == "" {
+		return nil
+	}
+	timeout := m.scripts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultScriptTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	output := &cappedBuffer{max: maxScriptOutput}
+	cmd := exec.CommandContext(ctx, script)
+	cmd.Env = tracecontext.Env(ctx, scriptEnv(stage, event, m.scripts.Env))
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// Don't wait on children that keep the output pipes open
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	if err == nil {
+		return nil
+	}
+	out := strings.TrimSpace(output.buf.String())
+	return &ScriptError{Stage: stage, Script: script, Output: out, Err: err}
+}
+
+// cappedBuffer keeps the first max bytes written to it and discards the
+// rest without failing, so the script is not killed by a broken pipe
+type cappedBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); room < len(p) {
+		b.buf.Write(p[:max(room, 0)])
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// scriptEnv is the supervisor environment with the reload and the
+// configured variables added
+func scriptEnv(stage string, event ReloadEvent, extra map[string]string) []string {
+	env := append(os.Environ(),
+		"NRDOT_HOOK="+stage,
+		fmt.Sprintf("NRDOT_OLD_VERSION=%d", event.OldVersion),
+		fmt.Sprintf("NRDOT_NEW_VERSION=%d", event.NewVersion),
+		"NRDOT_CONFIG_PATH="+event.ConfigPath,
+	)
+	if stage == StagePostReload {
+		if event.Error != nil {
+			env = append(env, "NRDOT_RELOAD_RESULT=failure", "NRDOT_RELOAD_ERROR="+event.Error.Error())
+		} else {
+			env = append(env, "NRDOT_RELOAD_RESULT=success")
+		}
+	}
+	for name, value := range extra {
+		env = append(env, name+"="+value)
+	}
+	return env
+}
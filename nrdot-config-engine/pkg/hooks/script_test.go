@@ -0,0 +1,105 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeScript(t *testing.T, body string) string {
+	path := filepath.Join(t.TempDir(), "hook.sh")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755))
+	return path
+}
+
+func TestLoadScriptConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hooks.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`hooks:
+  pre_reload: /usr/local/bin/drain.sh
+  post_reload: /usr/local/bin/undrain.sh
+  timeout: 1m
+  env:
+    LB_POOL: web
+`), 0644))
+
+	config, err := LoadScriptConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "/usr/local/bin/drain.sh", config.PreReload)
+	assert.Equal(t, time.Minute, config.Timeout)
+	assert.Equal(t, FailureAbort, config.OnFailure)
+	assert.Equal(t, "web", config.Env["LB_POOL"])
+
+	require.NoError(t, os.WriteFile(path, []byte("hooks:\n  pre_reload: drain.sh\n"), 0644))
+	_, err = LoadScriptConfig(path)
+	assert.ErrorContains(t, err, "absolute path")
+
+	require.NoError(t, os.WriteFile(path, []byte("hooks:\n  on_failure: ignore\n"), 0644))
+	_, err = LoadScriptConfig(path)
+	assert.ErrorContains(t, err, "on_failure")
+
+	require.NoError(t, os.WriteFile(path, []byte("hooks:\n  pre_reolad: /bin/true\n"), 0644))
+	_, err = LoadScriptConfig(path)
+	assert.Error(t, err)
+}
+
+func TestManager_RunPreReload(t *testing.T) {
+	ctx := context.Background()
+	event := ReloadEvent{OldVersion: 3, NewVersion: 4, ConfigPath: "/etc/nrdot/config.yaml"}
+
+	m := NewManager()
+	assert.NoError(t, m.RunPreReload(ctx, event), "no script configured")
+
+	require.NoError(t, m.SetScripts(ScriptConfig{
+		PreReload: writeScript(t, `[ "$NRDOT_OLD_VERSION:$NRDOT_NEW_VERSION:$LB_POOL" = "3:4:web" ] || { echo "bad env"; exit 1; }`),
+		Env:       map[string]string{"LB_POOL": "web"},
+	}))
+	assert.NoError(t, m.RunPreReload(ctx, event))
+
+	// A failing script aborts the reload unless its policy is warn
+	failing := ScriptConfig{PreReload: writeScript(t, "echo draining failed >&2; exit 2")}
+	require.NoError(t, m.SetScripts(failing))
+	err := m.RunPreReload(ctx, event)
+	var scriptErr *ScriptError
+	require.True(t, errors.As(err, &scriptErr))
+	assert.True(t, scriptErr.Abort)
+	assert.Equal(t, StagePreReload, scriptErr.Stage)
+	assert.Equal(t, "draining failed", scriptErr.Output)
+
+	failing.OnFailure = FailureWarn
+	require.NoError(t, m.SetScripts(failing))
+	err = m.RunPreReload(ctx, event)
+	require.True(t, errors.As(err, &scriptErr))
+	assert.False(t, scriptErr.Abort)
+}
+
+func TestManager_ScriptTimeout(t *testing.T) {
+	m := NewManager()
+	require.NoError(t, m.SetScripts(ScriptConfig{
+		PostReload: writeScript(t, "exec sleep 10"),
+		Timeout:    100 * time.Millisecond,
+	}))
+
+	start := time.Now()
+	err := m.RunPostReload(context.Background(), ReloadEvent{})
+	assert.ErrorContains(t, err, "timed out after 100ms")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestManager_ScriptOutputCapped(t *testing.T) {
+	m := NewManager()
+	require.NoError(t, m.SetScripts(ScriptConfig{
+		PostReload: writeScript(t, "head -c 10000000 /dev/zero | tr '\\0' x; echo done >&2; exit 1"),
+	}))
+
+	err := m.RunPostReload(context.Background(), ReloadEvent{})
+	var scriptErr *ScriptError
+	require.True(t, errors.As(err, &scriptErr))
+	assert.Len(t, scriptErr.Output, maxScriptOutput)
+	assert.NotContains(t, scriptErr.Output, "done")
+}
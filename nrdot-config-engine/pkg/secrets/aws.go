@@ -0,0 +1,207 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultIMDSEndpoint is the EC2 instance metadata service
+const defaultIMDSEndpoint = "http://169.254.169.254"
+
+// AWSSecretsManagerProvider reads secrets from the keys of one AWS Secrets
+// Manager secret holding a JSON object, e.g. {"MYSQL_MONITOR_PASS": "..."}.
+//
+// Credentials are AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN from the environment, else those of the EC2 instance
+// role.
+type AWSSecretsManagerProvider struct {
+	Region   string
+	SecretID string
+
+	// Endpoint replaces https://secretsmanager.<Region>.amazonaws.com, e.g.
+	// for a VPC endpoint
+	Endpoint string
+
+	Client *http.Client
+
+	// imdsEndpoint and now are replaceable in tests
+	imdsEndpoint string
+	now          func() time.Time
+}
+
+// Name implements Provider
+func (p *AWSSecretsManagerProvider) Name() string { return "aws_secrets_manager" }
+
+// Lookup implements Provider
+func (p *AWSSecretsManagerProvider) Lookup(ctx context.Context, names []string) (map[string]string, error) {
+	creds, err := p.credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", p.Region)
+	}
+	body, err := json.Marshal(map[string]string{"SecretId": p.SecretID})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+
+	now := time.Now
+	if p.now != nil {
+		now = p.now
+	}
+	signV4(req, body, creds, p.Region, "secretsmanager", now().UTC())
+
+	respBody, err := doSecretRequest(p.Client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", p.SecretID, err)
+	}
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(response.SecretString), &data); err != nil {
+		return nil, fmt.Errorf("secret %s is not a JSON object", p.SecretID)
+	}
+	return pickStrings(data, names), nil
+}
+
+// awsCredentials sign requests
+type awsCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"Token"`
+}
+
+// credentials returns the credentials of the environment, else of the
+// instance role through IMDSv2
+func (p *AWSSecretsManagerProvider) credentials(ctx context.Context) (awsCredentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return awsCredentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	imds := p.imdsEndpoint
+	if imds == "" {
+		imds = defaultIMDSEndpoint
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, imds+"/latest/api/token", nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "300")
+	token, err := doSecretRequest(client, req)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("no credentials in the environment or from the instance role: %w", err)
+	}
+
+	get := func(path string) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, imds+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", string(token))
+		return doSecretRequest(client, req)
+	}
+	role, err := get("/latest/meta-data/iam/security-credentials/")
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("reading instance role: %w", err)
+	}
+	data, err := get("/latest/meta-data/iam/security-credentials/" + strings.TrimSpace(strings.SplitN(string(role), "\n", 2)[0]))
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("reading instance role credentials: %w", err)
+	}
+
+	var creds awsCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return awsCredentials{}, fmt.Errorf("decoding instance role credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// signV4 signs req with AWS Signature Version 4, setting its X-Amz-Date,
+// X-Amz-Security-Token and Authorization headers
+func signV4(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,137 @@
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Provider types of ProviderConfig
+const (
+	ProviderEnv               = "env"
+	ProviderFile              = "file"
+	ProviderExec              = "exec"
+	ProviderVault             = "vault"
+	ProviderAWSSecretsManager = "aws_secrets_manager"
+)
+
+// ProviderConfig configures one provider of the chain. The fields used
+// depend on Type.
+type ProviderConfig struct {
+	Type string `yaml:"type"`
+
+	// file
+	Dir string `yaml:"dir,omitempty"`
+
+	// exec
+	Command []string      `yaml:"command,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// vault: Address defaults to VAULT_ADDR, and the token is read from
+	// TokenFile or else VAULT_TOKEN, so it is never kept in this file
+	Address   string `yaml:"address,omitempty"`
+	Path      string `yaml:"path,omitempty"`
+	Namespace string `yaml:"namespace,omitempty"`
+	TokenFile string `yaml:"token_file,omitempty"`
+
+	// aws_secrets_manager
+	Region   string `yaml:"region,omitempty"`
+	SecretID string `yaml:"secret_id,omitempty"`
+	Endpoint string `yaml:"endpoint,omitempty"`
+}
+
+// NewProvider creates the provider described by config
+func NewProvider(config ProviderConfig) (Provider, error) {
+	switch config.Type {
+	case ProviderEnv:
+		return EnvProvider{}, nil
+
+	case ProviderFile:
+		if config.Dir == "" {
+			return nil, fmt.Errorf("file provider needs dir")
+		}
+		return &FileProvider{Dir: config.Dir}, nil
+
+	case ProviderExec:
+		if len(config.Command) == 0 {
+			return nil, fmt.Errorf("exec provider needs command")
+		}
+		return &ExecProvider{Command: config.Command, Timeout: config.Timeout}, nil
+
+	case ProviderVault:
+		provider := &VaultProvider{
+			Address:   config.Address,
+			Path:      config.Path,
+			Namespace: config.Namespace,
+			TokenFile: config.TokenFile,
+		}
+		if provider.Address == "" {
+			provider.Address = os.Getenv("VAULT_ADDR")
+		}
+		if provider.TokenFile == "" {
+			provider.Token = os.Getenv("VAULT_TOKEN")
+		}
+		if provider.Address == "" || provider.Path == "" {
+			return nil, fmt.Errorf("vault provider needs address (or VAULT_ADDR) and path")
+		}
+		return provider, nil
+
+	case ProviderAWSSecretsManager:
+		if config.Region == "" || config.SecretID == "" {
+			return nil, fmt.Errorf("aws_secrets_manager provider needs region and secret_id")
+		}
+		return &AWSSecretsManagerProvider{
+			Region:   config.Region,
+			SecretID: config.SecretID,
+			Endpoint: config.Endpoint,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown secret provider type %q", config.Type)
+	}
+}
+
+// NewResolverFromConfig creates a resolver for a chain of provider configs
+func NewResolverFromConfig(configs []ProviderConfig) (*Resolver, error) {
+	providers := make([]Provider, 0, len(configs))
+	for i, config := range configs {
+		provider, err := NewProvider(config)
+		if err != nil {
+			return nil, fmt.Errorf("provider %d: %w", i+1, err)
+		}
+		providers = append(providers, provider)
+	}
+	return NewResolver(providers...), nil
+}
+
+// LoadProviderConfigs reads a chain of providers from a YAML file:
+//
+//	providers:
+//	  - type: env
+//	  - type: file
+//	    dir: /etc/nrdot/secrets
+//	  - type: vault
+//	    address: https://vault.example.com:8200
+//	    path: secret/data/nrdot
+func LoadProviderConfigs(path string) ([]ProviderConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret providers: %w", err)
+	}
+
+	var file struct {
+		Providers []ProviderConfig `yaml:"providers"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse secret providers %s: %w", path, err)
+	}
+	if _, err := NewResolverFromConfig(file.Providers); err != nil {
+		return nil, fmt.Errorf("invalid secret providers %s: %w", path, err)
+	}
+	return file.Providers, nil
+}
@@ -0,0 +1,128 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EnvProvider looks secrets up in the environment of the process
+type EnvProvider struct{}
+
+// Name implements Provider
+func (EnvProvider) Name() string { return "env" }
+
+// Lookup implements Provider
+func (EnvProvider) Lookup(ctx context.Context, names []string) (map[string]string, error) {
+	found := make(map[string]string)
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			found[name] = value
+		}
+	}
+	return found, nil
+}
+
+// FileProvider reads each secret from a file named after it in Dir, such as
+// /etc/nrdot/secrets/MYSQL_MONITOR_PASS, with a trailing newline removed.
+// Files readable by other users are rejected.
+type FileProvider struct {
+	Dir string
+}
+
+// Name implements Provider
+func (p *FileProvider) Name() string { return "file" }
+
+// Lookup implements Provider
+func (p *FileProvider) Lookup(ctx context.Context, names []string) (map[string]string, error) {
+	found := make(map[string]string)
+	for _, name := range names {
+		path := filepath.Join(p.Dir, name)
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode().Perm()&0007 != 0 {
+			return nil, fmt.Errorf("%s is accessible by other users (%s); chmod 0600 it", path, info.Mode().Perm())
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		found[name] = strings.TrimRight(string(data), "\r\n")
+	}
+	return found, nil
+}
+
+// defaultExecTimeout bounds each run of an ExecProvider command
+const defaultExecTimeout = 10 * time.Second
+
+// ExecProvider runs Command with the name of a secret as its last argument.
+// The command prints the value on stdout, a trailing newline removed, or
+// nothing if it has no such secret; a non-zero exit is a failure.
+type ExecProvider struct {
+	Command []string
+	Timeout time.Duration
+}
+
+// Name implements Provider
+func (p *ExecProvider) Name() string { return "exec" }
+
+// Lookup implements Provider
+func (p *ExecProvider) Lookup(ctx context.Context, names []string) (map[string]string, error) {
+	if len(p.Command) == 0 {
+		return nil, fmt.Errorf("no command configured")
+	}
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = defaultExecTimeout
+	}
+
+	found := make(map[string]string)
+	for _, name := range names {
+		value, err := p.run(ctx, name, timeout)
+		if err != nil {
+			return nil, err
+		}
+		if value != "" {
+			found[name] = value
+		}
+	}
+	return found, nil
+}
+
+// run runs the command for one secret
+func (p *ExecProvider) run(ctx context.Context, name string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := append(append([]string(nil), p.Command[1:]...), name)
+	cmd := exec.CommandContext(ctx, p.Command[0], args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// stderr, never stdout: it may hold part of the secret
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		if msg != "" {
+			return "", fmt.Errorf("%s %s: %w: %s", p.Command[0], name, err, msg)
+		}
+		return "", fmt.Errorf("%s %s: %w", p.Command[0], name, err)
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
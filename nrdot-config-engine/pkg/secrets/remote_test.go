@@ -0,0 +1,115 @@
+package secrets
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/nrdot":
+			w.Write([]byte(`{"data":{"data":{"MYSQL_MONITOR_PASS":"s3cret","PORT":3306},"metadata":{"version":3}}}`))
+		case "/v1/kv/nrdot":
+			w.Write([]byte(`{"data":{"MYSQL_MONITOR_PASS":"v1-secret"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := &VaultProvider{Address: server.URL, Path: "secret/data/nrdot", Token: "s.token"}
+	found, err := provider.Lookup(context.Background(), []string{"MYSQL_MONITOR_PASS", "PORT", "MISSING"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"MYSQL_MONITOR_PASS": "s3cret", "PORT": "3306"}, found)
+
+	provider.Path = "kv/nrdot"
+	found, err = provider.Lookup(context.Background(), []string{"MYSQL_MONITOR_PASS"})
+	require.NoError(t, err)
+	assert.Equal(t, "v1-secret", found["MYSQL_MONITOR_PASS"])
+
+	// A token file is read on each lookup
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("s.expired\n"), 0600))
+	provider.TokenFile = tokenFile
+	_, err = provider.Lookup(context.Background(), []string{"MYSQL_MONITOR_PASS"})
+	assert.ErrorContains(t, err, "403")
+}
+
+func TestAWSSecretsManagerProvider(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.JSONEq(t, `{"SecretId":"nrdot/prod"}`, string(body))
+		assert.Equal(t, "session", r.Header.Get("X-Amz-Security-Token"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240102/us-east-1/secretsmanager/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, Signature="))
+		w.Write([]byte(`{"Name":"nrdot/prod","SecretString":"{\"MYSQL_MONITOR_PASS\":\"s3cret\"}"}`))
+	}))
+	defer server.Close()
+
+	provider := &AWSSecretsManagerProvider{
+		Region:   "us-east-1",
+		SecretID: "nrdot/prod",
+		Endpoint: server.URL,
+		now:      func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) },
+	}
+	found, err := provider.Lookup(context.Background(), []string{"MYSQL_MONITOR_PASS", "MISSING"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"MYSQL_MONITOR_PASS": "s3cret"}, found)
+}
+
+func TestAWSSecretsManagerProvider_InstanceRole(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			w.Write([]byte("imds-token"))
+		case r.Header.Get("X-aws-ec2-metadata-token") != "imds-token":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+			w.Write([]byte("nrdot-role"))
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/nrdot-role":
+			w.Write([]byte(`{"AccessKeyId":"ASIAROLE","SecretAccessKey":"secret","Token":"role-session"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer imds.Close()
+
+	provider := &AWSSecretsManagerProvider{Region: "eu-west-1", imdsEndpoint: imds.URL}
+	creds, err := provider.credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, awsCredentials{AccessKeyID: "ASIAROLE", SecretAccessKey: "secret", SessionToken: "role-session"}, creds)
+}
+
+func TestSignV4(t *testing.T) {
+	// The get-vanilla case of the AWS Signature Version 4 test suite
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	signV4(req, nil, awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}, "us-east-1", "service", now)
+	assert.Equal(t,
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+}
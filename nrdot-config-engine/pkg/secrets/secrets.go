@@ -0,0 +1,120 @@
+// Package secrets resolves the variables generated collector configs
+// reference, such as ${MYSQL_MONITOR_PASS}, from a chain of providers: the
+// environment, files, a command, Vault or AWS Secrets Manager.
+//
+// Resolved values are handed to the collector through its environment when
+// it starts. Configs keep the references, so values are never written to
+// disk in plaintext.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Provider looks up secrets by name
+type Provider interface {
+	// Name identifies the provider in errors and logs
+	Name() string
+
+	// Lookup returns the values of the names it has. Names it does not
+	// have are left out; an error means the provider could not be asked.
+	Lookup(ctx context.Context, names []string) (map[string]string, error)
+}
+
+// Resolver resolves names from a chain of providers, the first provider
+// having a name winning
+type Resolver struct {
+	providers []Provider
+}
+
+// NewResolver creates a resolver asking providers in order
+func NewResolver(providers ...Provider) *Resolver {
+	return &Resolver{providers: providers}
+}
+
+// Providers returns the providers of the chain, in order
+func (r *Resolver) Providers() []Provider {
+	return r.providers
+}
+
+// UnresolvedError lists names no provider has
+type UnresolvedError struct {
+	Names []string
+}
+
+func (e *UnresolvedError) Error() string {
+	return fmt.Sprintf("unresolved secrets: %s", strings.Join(e.Names, ", "))
+}
+
+// Resolve returns the values of names. It fails with an *UnresolvedError if
+// a name is not found, and with the provider's error if a provider fails
+// while names are still unresolved.
+func (r *Resolver) Resolve(ctx context.Context, names []string) (map[string]string, error) {
+	values := make(map[string]string, len(names))
+	pending := append([]string(nil), names...)
+
+	for _, provider := range r.providers {
+		if len(pending) == 0 {
+			break
+		}
+		found, err := provider.Lookup(ctx, pending)
+		if err != nil {
+			return nil, fmt.Errorf("secret provider %s: %w", provider.Name(), err)
+		}
+
+		remaining := pending[:0]
+		for _, name := range pending {
+			if value, ok := found[name]; ok {
+				values[name] = value
+			} else {
+				remaining = append(remaining, name)
+			}
+		}
+		pending = remaining
+	}
+
+	if len(pending) > 0 {
+		return nil, &UnresolvedError{Names: pending}
+	}
+	return values, nil
+}
+
+// Environ resolves the names config references as NAME=value pairs, sorted
+// by name, for the environment of the collector
+func (r *Resolver) Environ(ctx context.Context, config string) ([]string, error) {
+	values, err := r.Resolve(ctx, References(config))
+	if err != nil {
+		return nil, err
+	}
+
+	env := make([]string, 0, len(values))
+	for name, value := range values {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return env, nil
+}
+
+// referencePattern matches the environment references the collector
+// expands, ${NAME} and ${env:NAME}, optionally with a default after ":-"
+var referencePattern = regexp.MustCompile(`\$\{(?:env:)?([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+// References returns the sorted names config references without a default.
+// References with a default resolve without a secret.
+func References(config string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, match := range referencePattern.FindAllStringSubmatch(config, -1) {
+		if match[2] != "" || seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+		names = append(names, match[1])
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,161 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapProvider serves fixed values
+type mapProvider struct {
+	name   string
+	values map[string]string
+	err    error
+	asked  [][]string
+}
+
+func (p *mapProvider) Name() string { return p.name }
+
+func (p *mapProvider) Lookup(ctx context.Context, names []string) (map[string]string, error) {
+	p.asked = append(p.asked, append([]string(nil), names...))
+	if p.err != nil {
+		return nil, p.err
+	}
+	found := make(map[string]string)
+	for _, name := range names {
+		if value, ok := p.values[name]; ok {
+			found[name] = value
+		}
+	}
+	return found, nil
+}
+
+func TestReferences(t *testing.T) {
+	config := `exporters:
+  otlp:
+    headers:
+      api-key: ${NEW_RELIC_LICENSE_KEY}
+receivers:
+  mysql:
+    password: ${env:MYSQL_MONITOR_PASS}
+    username: ${MYSQL_USER:-monitor}
+  postgresql:
+    password: ${env:MYSQL_MONITOR_PASS}
+`
+	assert.Equal(t, []string{"MYSQL_MONITOR_PASS", "NEW_RELIC_LICENSE_KEY"}, References(config))
+	assert.Empty(t, References("receivers: {}"))
+}
+
+func TestResolver_Chain(t *testing.T) {
+	first := &mapProvider{name: "first", values: map[string]string{"A": "from-first"}}
+	second := &mapProvider{name: "second", values: map[string]string{"A": "shadowed", "B": "from-second"}}
+	resolver := NewResolver(first, second)
+
+	values, err := resolver.Resolve(context.Background(), []string{"A", "B"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"A": "from-first", "B": "from-second"}, values)
+	// Later providers are only asked for what is still missing
+	assert.Equal(t, [][]string{{"B"}}, second.asked)
+
+	_, err = resolver.Resolve(context.Background(), []string{"A", "C"})
+	var unresolved *UnresolvedError
+	require.True(t, errors.As(err, &unresolved))
+	assert.Equal(t, []string{"C"}, unresolved.Names)
+}
+
+func TestResolver_ProviderError(t *testing.T) {
+	failing := &mapProvider{name: "vault", err: errors.New("connection refused")}
+	resolver := NewResolver(&mapProvider{name: "env", values: map[string]string{"A": "a"}}, failing)
+
+	// Not asked once everything is resolved
+	_, err := resolver.Resolve(context.Background(), []string{"A"})
+	require.NoError(t, err)
+
+	_, err = resolver.Resolve(context.Background(), []string{"A", "B"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "secret provider vault")
+}
+
+func TestResolver_Environ(t *testing.T) {
+	resolver := NewResolver(&mapProvider{name: "test", values: map[string]string{"PASS": "s3cret", "KEY": "k"}})
+
+	env, err := resolver.Environ(context.Background(), "a: ${PASS}\nb: ${env:KEY}\nc: ${OPTIONAL:-x}\n")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"KEY=k", "PASS=s3cret"}, env)
+}
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "MYSQL_MONITOR_PASS"), []byte("s3cret\n"), 0600))
+
+	provider := &FileProvider{Dir: dir}
+	found, err := provider.Lookup(context.Background(), []string{"MYSQL_MONITOR_PASS", "MISSING"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"MYSQL_MONITOR_PASS": "s3cret"}, found)
+
+	require.NoError(t, os.Chmod(filepath.Join(dir, "MYSQL_MONITOR_PASS"), 0644))
+	_, err = provider.Lookup(context.Background(), []string{"MYSQL_MONITOR_PASS"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "accessible by other users")
+}
+
+func TestExecProvider(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "get-secret")
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+case "$2" in
+  API_TOKEN) echo "tok-$1" ;;
+  BROKEN) echo "vault sealed" >&2; exit 3 ;;
+esac
+`), 0700))
+
+	provider := &ExecProvider{Command: []string{script, "prod"}}
+	found, err := provider.Lookup(context.Background(), []string{"API_TOKEN", "UNKNOWN"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"API_TOKEN": "tok-prod"}, found)
+
+	_, err = provider.Lookup(context.Background(), []string{"BROKEN"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "vault sealed")
+}
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("NRDOT_TEST_SECRET", "value")
+
+	found, err := EnvProvider{}.Lookup(context.Background(), []string{"NRDOT_TEST_SECRET", "NRDOT_TEST_UNSET"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"NRDOT_TEST_SECRET": "value"}, found)
+}
+
+func TestLoadProviderConfigs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret-providers.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`providers:
+  - type: env
+  - type: file
+    dir: /etc/nrdot/secrets
+  - type: exec
+    command: ["/usr/local/bin/get-secret", "prod"]
+    timeout: 5s
+  - type: aws_secrets_manager
+    region: us-east-1
+    secret_id: nrdot/prod
+`), 0644))
+
+	configs, err := LoadProviderConfigs(path)
+	require.NoError(t, err)
+	require.Len(t, configs, 4)
+	assert.Equal(t, ProviderExec, configs[2].Type)
+	assert.Equal(t, []string{"/usr/local/bin/get-secret", "prod"}, configs[2].Command)
+
+	require.NoError(t, os.WriteFile(path, []byte("providers:\n  - type: file\n"), 0644))
+	_, err = LoadProviderConfigs(path)
+	assert.ErrorContains(t, err, "file provider needs dir")
+
+	require.NoError(t, os.WriteFile(path, []byte("providers:\n  - type: keychain\n"), 0644))
+	_, err = LoadProviderConfigs(path)
+	assert.ErrorContains(t, err, "unknown secret provider type")
+}
@@ -0,0 +1,120 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxSecretResponseSize bounds the responses of remote providers
+const maxSecretResponseSize = 1 << 20
+
+// VaultProvider reads secrets from the keys of one HashiCorp Vault secret,
+// KV version 1 or 2, e.g. Path "secret/data/nrdot" for the nrdot secret of
+// the KV v2 engine mounted at secret/
+type VaultProvider struct {
+	Address   string
+	Path      string
+	Namespace string
+
+	// Token authenticates to Vault. TokenFile, such as the sink of a Vault
+	// agent, is read on each lookup instead when set, so renewed tokens are
+	// picked up.
+	Token     string
+	TokenFile string
+
+	Client *http.Client
+}
+
+// Name implements Provider
+func (p *VaultProvider) Name() string { return "vault" }
+
+// Lookup implements Provider
+func (p *VaultProvider) Lookup(ctx context.Context, names []string) (map[string]string, error) {
+	token := p.Token
+	if p.TokenFile != "" {
+		data, err := os.ReadFile(p.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading token: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token == "" {
+		return nil, fmt.Errorf("no token configured")
+	}
+
+	url := strings.TrimSuffix(p.Address, "/") + "/v1/" + strings.TrimPrefix(p.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if p.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.Namespace)
+	}
+
+	body, err := doSecretRequest(p.Client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", p.Path, err)
+	}
+
+	// KV v2 nests the keys under data.data, next to data.metadata
+	data := response.Data
+	if nested, ok := data["data"]; ok {
+		if _, ok := data["metadata"]; ok {
+			data = nil
+			if err := json.Unmarshal(nested, &data); err != nil {
+				return nil, fmt.Errorf("decoding %s: %w", p.Path, err)
+			}
+		}
+	}
+	return pickStrings(data, names), nil
+}
+
+// pickStrings returns the string values of names in data
+func pickStrings(data map[string]json.RawMessage, names []string) map[string]string {
+	found := make(map[string]string)
+	for _, name := range names {
+		raw, ok := data[name]
+		if !ok {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			// Numbers and booleans are used as written
+			value = string(raw)
+		}
+		found[name] = value
+	}
+	return found
+}
+
+// doSecretRequest sends req, returning the body of a 200 response. Error
+// bodies are not included in errors, as they may echo request contents.
+func doSecretRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from %s: %s", req.URL.Host, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSecretResponseSize))
+}
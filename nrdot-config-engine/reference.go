@@ -0,0 +1,97 @@
+package configengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/newrelic/nrdot-host/nrdot-config-engine/internal/schema"
+)
+
+// ReferenceField is one setting of the user configuration, with its type,
+// default, constraints and description
+type ReferenceField = schema.Field
+
+// ConfigReference returns every setting the config engine accepts, in
+// config order. It is generated from the config structs and the validation
+// schema, so it always matches what the engine validates.
+func ConfigReference() []ReferenceField {
+	return schema.NewValidator().Reference()
+}
+
+// WriteConfigReference writes the config reference as "markdown" or "json"
+func WriteConfigReference(w io.Writer, format string) error {
+	validator := schema.NewValidator()
+	fields := validator.Reference()
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			SchemaVersion string           `json:"schema_version"`
+			Fields        []ReferenceField `json:"fields"`
+		}{validator.GetSchemaVersion(), fields})
+	case "markdown", "md":
+		return writeMarkdownReference(w, validator.GetSchemaVersion(), fields)
+	default:
+		return fmt.Errorf("unsupported reference format %q, use markdown or json", format)
+	}
+}
+
+// writeMarkdownReference writes the reference as a Markdown table
+func writeMarkdownReference(w io.Writer, schemaVersion string, fields []ReferenceField) error {
+	var b strings.Builder
+	b.WriteString("<!-- Generated by `config-engine -reference markdown`. Do not edit. -->\n\n")
+	b.WriteString("# NRDOT Configuration Reference\n\n")
+	fmt.Fprintf(&b, "Schema version %s. Keys are written as the config engine accepts them in YAML; ", schemaVersion)
+	b.WriteString("`[]` marks the fields of each list entry. Durations are written like `30s` or `1m`.\n\n")
+	b.WriteString("| Key | Type | Default | Description |\n")
+	b.WriteString("|-----|------|---------|-------------|\n")
+	for _, field := range fields {
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n",
+			field.Path, field.Type, markdownDefault(field.Default), markdownCell(referenceDescription(field)))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// referenceDescription adds the constraints of a field to its description
+func referenceDescription(field ReferenceField) string {
+	parts := []string{field.Description}
+	if field.Required {
+		parts = append(parts, "Required.")
+	}
+	if len(field.Enum) > 0 {
+		values := make([]string, len(field.Enum))
+		for i, value := range field.Enum {
+			values[i] = fmt.Sprintf("`%v`", value)
+		}
+		parts = append(parts, "One of "+strings.Join(values, ", ")+".")
+	}
+	if field.Minimum != nil {
+		parts = append(parts, fmt.Sprintf("Minimum %v.", *field.Minimum))
+	}
+	if field.Maximum != nil {
+		parts = append(parts, fmt.Sprintf("Maximum %v.", *field.Maximum))
+	}
+	description := strings.Join(parts, " ")
+	if field.Description != "" && !strings.HasSuffix(field.Description, ".") && len(parts) > 1 {
+		description = field.Description + ". " + strings.Join(parts[1:], " ")
+	}
+	return description
+}
+
+// markdownDefault formats a default value for a table cell
+func markdownDefault(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprintf("`%v`", value)
+}
+
+// markdownCell escapes text for a table cell
+func markdownCell(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
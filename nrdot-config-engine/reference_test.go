@@ -0,0 +1,60 @@
+package configengine
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateReference = flag.Bool("update", false, "regenerate docs/config-schema/reference.md")
+
+// referencePath is the published reference, checked against the generated one
+const referencePath = "../docs/config-schema/reference.md"
+
+func TestConfigReference(t *testing.T) {
+	fields := make(map[string]ReferenceField)
+	for _, field := range ConfigReference() {
+		assert.NotEmpty(t, field.Description, "%s has no description", field.Path)
+		fields[field.Path] = field
+	}
+
+	// Keys are the ones the decoder accepts, with defaults from the validator
+	assert.Equal(t, "1m0s", fields["metrics.interval"].Default)
+	assert.Equal(t, "duration", fields["metrics.interval"].Type)
+	assert.Equal(t, true, fields["security.redactsecrets"].Default)
+	assert.Equal(t, 100000, fields["processing.cardinality.globallimit"].Default)
+	assert.Equal(t, "list of objects", fields["checks"].Type)
+	assert.True(t, fields["service.name"].Required)
+	assert.Equal(t, []interface{}{"tcp", "http", "dns", "disk", "script"}, fields["checks[].type"].Enum)
+	require.NotNil(t, fields["checks[].max_used_percent"].Maximum)
+	assert.Equal(t, 100.0, *fields["checks[].max_used_percent"].Maximum)
+}
+
+func TestWriteConfigReference(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, WriteConfigReference(&out, "json"))
+	var doc struct {
+		SchemaVersion string           `json:"schema_version"`
+		Fields        []ReferenceField `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &doc))
+	assert.Equal(t, "1.0.0", doc.SchemaVersion)
+	assert.Len(t, doc.Fields, len(ConfigReference()))
+
+	assert.Error(t, WriteConfigReference(&out, "html"))
+
+	// The published reference must match the generated one
+	out.Reset()
+	require.NoError(t, WriteConfigReference(&out, "markdown"))
+	if *updateReference {
+		require.NoError(t, os.WriteFile(referencePath, out.Bytes(), 0644))
+	}
+	published, err := os.ReadFile(referencePath)
+	require.NoError(t, err)
+	assert.Equal(t, out.String(), string(published), "%s is out of date, run make reference", referencePath)
+}
@@ -0,0 +1,504 @@
+package configengine
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultRemoteInterval is how often remote configuration is polled
+	DefaultRemoteInterval = 5 * time.Minute
+
+	// maxRemoteBundleSize bounds a configuration bundle download
+	maxRemoteBundleSize = 4 << 20
+
+	// remoteRequestTimeout bounds each fetch and report
+	remoteRequestTimeout = 30 * time.Second
+)
+
+// RemoteVersionMetadataKey is the version metadata key recording the
+// bundle version a config came from
+const RemoteVersionMetadataKey = "remote_version"
+
+// Statuses of a remote configuration report
+const (
+	RemoteStatusApplied  = "applied"
+	RemoteStatusRejected = "rejected"
+)
+
+// ErrRemoteBundleInvalid is returned for a fetched bundle that is not
+// applied because it is malformed or its signature does not verify
+var ErrRemoteBundleInvalid = errors.New("invalid remote configuration bundle")
+
+// RemoteConfig configures polling a configuration bundle from an HTTPS
+// endpoint, such as New Relic's configuration service
+type RemoteConfig struct {
+	// URL the bundle is fetched from. It must be https, except on
+	// loopback addresses, e.g. a local proxy.
+	URL string
+
+	// ReportURL receives the result of applying each bundle as a POST,
+	// defaults to URL
+	ReportURL string
+
+	// PublicKey is the PEM ECDSA P-256 key bundles are signed with, as
+	// produced by the autoconfig ConfigSigner
+	PublicKey string
+
+	// Interval between polls, defaults to DefaultRemoteInterval
+	Interval time.Duration
+
+	// Headers sent with every request, e.g. a license key
+	Headers map[string]string
+
+	// StatePath is the file the serial of the last bundle applied is kept
+	// in, so bundles it replaced are refused after a restart too. Empty
+	// keeps it in memory only.
+	StatePath string
+}
+
+// Validate checks the remote configuration settings
+func (c RemoteConfig) Validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("remote config URL is required")
+	}
+	for _, raw := range []string{c.URL, c.ReportURL} {
+		if raw == "" {
+			continue
+		}
+		if err := checkRemoteURL(raw); err != nil {
+			return err
+		}
+	}
+	if c.PublicKey == "" {
+		return fmt.Errorf("remote config public key is required")
+	}
+	if _, err := ParseRemotePublicKey(c.PublicKey); err != nil {
+		return err
+	}
+	if c.Interval < 0 {
+		return fmt.Errorf("remote config interval must not be negative")
+	}
+	return nil
+}
+
+// checkRemoteURL rejects URLs that are not https, except on loopback
+func checkRemoteURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid remote config URL %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("remote config URL %q must use https", raw)
+}
+
+// RemoteBundle is a configuration bundle served by the remote endpoint
+type RemoteBundle struct {
+	// Version identifies the bundle and is reported back once applied
+	Version string `json:"version"`
+
+	// Serial orders bundles: each must be greater than the serial of the
+	// bundle last applied, so an old signed bundle cannot be replayed to
+	// roll the config back
+	Serial uint64 `json:"serial"`
+
+	// Config is the user configuration YAML
+	Config string `json:"config"`
+
+	// Signature is the base64 ECDSA signature of SignedBundleData, r and
+	// s 32 bytes each
+	Signature string `json:"signature"`
+}
+
+// SignedBundleData returns the bytes a bundle signature covers: the
+// serial, the version and the config, separated by newlines. Signing the
+// serial and version with the config keeps an old config from being served
+// as a newer bundle.
+func SignedBundleData(serial uint64, version, config string) []byte {
+	return []byte(strconv.FormatUint(serial, 10) + "\n" + version + "\n" + config)
+}
+
+// RemoteReport is sent to the report URL after each bundle
+type RemoteReport struct {
+	Version       string    `json:"version"`
+	Status        string    `json:"status"`
+	Error         string    `json:"error,omitempty"`
+	ConfigVersion int       `json:"config_version,omitempty"`
+	Host          string    `json:"host"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// RemoteStatus is the state of remote configuration polling
+type RemoteStatus struct {
+	// Version of the bundle last applied, "" before the first
+	Version string `json:"version,omitempty"`
+
+	// Serial of the bundle last applied, 0 before the first
+	Serial uint64 `json:"serial,omitempty"`
+
+	// ETag of the bundle last fetched
+	ETag string `json:"etag,omitempty"`
+
+	LastPoll  time.Time `json:"last_poll,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// RemoteApplyFunc validates and applies the user config of a bundle,
+// reloading the collector, and returns the config version it became
+type RemoteApplyFunc func(ctx context.Context, config []byte, version string) (int, error)
+
+// RemotePoller fetches configuration bundles and applies the ones that
+// changed. The ETag of the last bundle is sent as If-None-Match, so an
+// unchanged bundle costs a 304; a bundle that was rejected is not retried
+// until it changes, and one whose serial is not greater than the last one
+// applied is refused.
+type RemotePoller struct {
+	config    RemoteConfig
+	publicKey *ecdsa.PublicKey
+	apply     RemoteApplyFunc
+	client    *http.Client
+	logger    *zap.Logger
+	clock     func() time.Time
+
+	// Serializes polls
+	pollMu sync.Mutex
+
+	mu     sync.Mutex
+	status RemoteStatus
+
+	// Version of the last bundle the apply function rejected, so endpoints
+	// without ETags do not get it applied every poll
+	rejected string
+}
+
+// NewRemotePoller creates a poller applying bundles through apply
+func NewRemotePoller(config RemoteConfig, apply RemoteApplyFunc, logger *zap.Logger) (*RemotePoller, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if config.Interval == 0 {
+		config.Interval = DefaultRemoteInterval
+	}
+	if config.ReportURL == "" {
+		config.ReportURL = config.URL
+	}
+	publicKey, err := ParseRemotePublicKey(config.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &RemotePoller{
+		config:    config,
+		publicKey: publicKey,
+		apply:     apply,
+		client:    &http.Client{Timeout: remoteRequestTimeout},
+		logger:    logger,
+		clock:     time.Now,
+	}
+	if err := p.loadState(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// remoteState is what StatePath holds: the bundle last applied
+type remoteState struct {
+	Version string `json:"version"`
+	Serial  uint64 `json:"serial"`
+}
+
+// loadState restores the bundle last applied from StatePath, if any
+func (p *RemotePoller) loadState() error {
+	if p.config.StatePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(p.config.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read remote config state: %w", err)
+	}
+	var state remoteState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse remote config state %s: %w", p.config.StatePath, err)
+	}
+	p.status.Version = state.Version
+	p.status.Serial = state.Serial
+	return nil
+}
+
+// saveState replaces StatePath atomically with the bundle last applied
+func (p *RemotePoller) saveState(state remoteState) error {
+	if p.config.StatePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := p.config.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p.config.StatePath); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Interval returns how often to poll
+func (p *RemotePoller) Interval() time.Duration {
+	return p.config.Interval
+}
+
+// Status returns the state of polling
+func (p *RemotePoller) Status() RemoteStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+// Poll fetches the bundle once and applies it if it changed. Bundles that
+// fail verification are reported back and returned as
+// ErrRemoteBundleInvalid.
+func (p *RemotePoller) Poll(ctx context.Context) error {
+	p.pollMu.Lock()
+	defer p.pollMu.Unlock()
+
+	err := p.poll(ctx)
+
+	p.mu.Lock()
+	p.status.LastPoll = p.clock()
+	p.status.LastError = ""
+	if err != nil {
+		p.status.LastError = err.Error()
+	}
+	p.mu.Unlock()
+	return err
+}
+
+func (p *RemotePoller) poll(ctx context.Context) error {
+	status := p.Status()
+
+	bundle, etag, err := p.fetch(ctx, status.ETag)
+	if err != nil {
+		return err
+	}
+	if bundle == nil {
+		return nil
+	}
+
+	// Whatever happens to this bundle, it is not fetched again until it
+	// changes
+	p.mu.Lock()
+	p.status.ETag = etag
+	p.mu.Unlock()
+
+	if err := p.verify(bundle); err != nil {
+		err = fmt.Errorf("%w: %v", ErrRemoteBundleInvalid, err)
+		p.report(ctx, RemoteReport{Version: bundle.Version, Status: RemoteStatusRejected, Error: err.Error()})
+		return err
+	}
+	p.mu.Lock()
+	rejected := p.rejected
+	p.mu.Unlock()
+	if (bundle.Version == status.Version && bundle.Serial == status.Serial) || bundle.Version == rejected {
+		p.logger.Debug("Remote configuration unchanged", zap.String("version", bundle.Version))
+		return nil
+	}
+
+	// An older bundle, possibly replayed to roll the config back
+	if bundle.Serial <= status.Serial {
+		err := fmt.Errorf("%w: serial %d is not greater than %d of the applied version %s",
+			ErrRemoteBundleInvalid, bundle.Serial, status.Serial, status.Version)
+		p.report(ctx, RemoteReport{Version: bundle.Version, Status: RemoteStatusRejected, Error: err.Error()})
+		return err
+	}
+
+	p.logger.Info("Applying remote configuration", zap.String("version", bundle.Version))
+	configVersion, err := p.apply(ctx, []byte(bundle.Config), bundle.Version)
+	if err != nil {
+		p.mu.Lock()
+		p.rejected = bundle.Version
+		p.mu.Unlock()
+		p.report(ctx, RemoteReport{Version: bundle.Version, Status: RemoteStatusRejected, Error: err.Error()})
+		return fmt.Errorf("remote configuration %s not applied: %w", bundle.Version, err)
+	}
+
+	p.mu.Lock()
+	p.status.Version = bundle.Version
+	p.status.Serial = bundle.Serial
+	p.rejected = ""
+	p.mu.Unlock()
+	if err := p.saveState(remoteState{Version: bundle.Version, Serial: bundle.Serial}); err != nil {
+		p.logger.Warn("Failed to save remote configuration state", zap.Error(err))
+	}
+	p.report(ctx, RemoteReport{Version: bundle.Version, Status: RemoteStatusApplied, ConfigVersion: configVersion})
+	return nil
+}
+
+// fetch downloads the bundle, returning nil if it is unchanged since etag
+func (p *RemotePoller) fetch(ctx context.Context, etag string) (*RemoteBundle, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.URL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	p.setHeaders(req)
+	req.Header.Set("Accept", "application/json")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch remote configuration: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, etag, nil
+	case http.StatusOK:
+	default:
+		return nil, "", fmt.Errorf("remote configuration fetch failed with status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteBundleSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read remote configuration: %w", err)
+	}
+	if len(data) > maxRemoteBundleSize {
+		return nil, "", fmt.Errorf("%w: larger than %d bytes", ErrRemoteBundleInvalid, maxRemoteBundleSize)
+	}
+
+	var bundle RemoteBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrRemoteBundleInvalid, err)
+	}
+	return &bundle, resp.Header.Get("ETag"), nil
+}
+
+// verify checks the bundle is complete and signed with the public key
+func (p *RemotePoller) verify(bundle *RemoteBundle) error {
+	if bundle.Version == "" {
+		return fmt.Errorf("bundle has no version")
+	}
+	if bundle.Serial == 0 {
+		return fmt.Errorf("bundle has no serial")
+	}
+	if bundle.Config == "" {
+		return fmt.Errorf("bundle has no config")
+	}
+	return VerifyRemoteSignature(SignedBundleData(bundle.Serial, bundle.Version, bundle.Config), bundle.Signature, p.publicKey)
+}
+
+// report posts the result of a bundle, logging failures; the next changed
+// bundle is reported regardless
+func (p *RemotePoller) report(ctx context.Context, report RemoteReport) {
+	report.Host, _ = os.Hostname()
+	report.Timestamp = p.clock()
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		p.logger.Warn("Failed to encode remote configuration report", zap.Error(err))
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.ReportURL, bytes.NewReader(body))
+	if err != nil {
+		p.logger.Warn("Failed to create remote configuration report", zap.Error(err))
+		return
+	}
+	p.setHeaders(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.logger.Warn("Failed to report remote configuration status",
+			zap.String("version", report.Version),
+			zap.Error(err))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		p.logger.Warn("Remote configuration report refused",
+			zap.String("version", report.Version),
+			zap.String("status", resp.Status))
+	}
+}
+
+// setHeaders adds the configured headers to req
+func (p *RemotePoller) setHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", "NRDOT-HOST/2.0")
+	for name, value := range p.config.Headers {
+		req.Header.Set(name, value)
+	}
+}
+
+// ParseRemotePublicKey parses a PEM ECDSA public key
+func ParseRemotePublicKey(publicKeyPEM string) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse remote config public key: no PEM block")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse remote config public key: %w", err)
+	}
+	publicKey, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("remote config public key is not an ECDSA key")
+	}
+	return publicKey, nil
+}
+
+// VerifyRemoteSignature verifies the base64 ECDSA signature of data in the
+// ConfigSigner format: the SHA-256 digest signed, r and s 32 bytes each
+func VerifyRemoteSignature(data []byte, signature string, publicKey *ecdsa.PublicKey) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+	if len(sig) != 64 {
+		return fmt.Errorf("invalid signature length")
+	}
+
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	hash := sha256.Sum256(data)
+	if !ecdsa.Verify(publicKey, hash[:], r, s) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package configengine
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// remoteServer serves a signed bundle with an ETag and records reports
+type remoteServer struct {
+	*httptest.Server
+	key *ecdsa.PrivateKey
+
+	mu      sync.Mutex
+	bundle  RemoteBundle
+	etag    string
+	fetches int
+	reports []RemoteReport
+}
+
+func newRemoteServer(t *testing.T) *remoteServer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	s := &remoteServer{key: key}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.Method == http.MethodPost {
+			var report RemoteReport
+			if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			s.reports = append(s.reports, report)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		s.fetches++
+		if r.Header.Get("X-License-Key") != "license" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if s.etag != "" && r.Header.Get("If-None-Match") == s.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", s.etag)
+		json.NewEncoder(w).Encode(s.bundle)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// serve publishes a bundle signed with the server's key
+func (s *remoteServer) serve(t *testing.T, serial uint64, version, config, etag string) {
+	hash := sha256.Sum256(SignedBundleData(serial, version, config))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, hash[:])
+	require.NoError(t, err)
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bundle = RemoteBundle{Version: version, Serial: serial, Config: config, Signature: base64.StdEncoding.EncodeToString(signature)}
+	s.etag = etag
+}
+
+func (s *remoteServer) publicKeyPEM(t *testing.T) string {
+	der, err := x509.MarshalPKIXPublicKey(&s.key.PublicKey)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func (s *remoteServer) received() (int, []RemoteReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches, append([]RemoteReport(nil), s.reports...)
+}
+
+// recordingApply applies configs through an engine, recording the bundle
+// versions applied
+func recordingApply(engine *EngineV2, applied *[]string) RemoteApplyFunc {
+	return func(ctx context.Context, config []byte, version string) (int, error) {
+		result, err := engine.ApplyConfig(ctx, &models.ConfigUpdate{Config: config, Format: "yaml", Source: "remote"})
+		if err != nil {
+			return 0, err
+		}
+		if !result.Success {
+			return 0, result.Error
+		}
+		*applied = append(*applied, version)
+		return result.Version, nil
+	}
+}
+
+func TestRemotePoller_AppliesChangedBundles(t *testing.T) {
+	server := newRemoteServer(t)
+	server.serve(t, 1, "v1", "service:\n  name: web-01\n", `"a"`)
+
+	var applied []string
+	poller, err := NewRemotePoller(RemoteConfig{
+		URL:       server.URL,
+		PublicKey: server.publicKeyPEM(t),
+		Headers:   map[string]string{"X-License-Key": "license"},
+	}, recordingApply(newTestEngineV2(t), &applied), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRemoteInterval, poller.Interval())
+	ctx := context.Background()
+
+	require.NoError(t, poller.Poll(ctx))
+	assert.Equal(t, []string{"v1"}, applied)
+	assert.Equal(t, "v1", poller.Status().Version)
+	assert.Equal(t, `"a"`, poller.Status().ETag)
+
+	// Unchanged: a 304, nothing applied or reported
+	require.NoError(t, poller.Poll(ctx))
+	assert.Equal(t, []string{"v1"}, applied)
+
+	server.serve(t, 2, "v2", "service:\n  name: web-02\n", `"b"`)
+	require.NoError(t, poller.Poll(ctx))
+	assert.Equal(t, []string{"v1", "v2"}, applied)
+
+	fetches, reports := server.received()
+	assert.Equal(t, 3, fetches)
+	require.Len(t, reports, 2)
+	assert.Equal(t, "v1", reports[0].Version)
+	assert.Equal(t, RemoteStatusApplied, reports[0].Status)
+	assert.Equal(t, 1, reports[0].ConfigVersion)
+	assert.Equal(t, "v2", reports[1].Version)
+	assert.Equal(t, 2, reports[1].ConfigVersion)
+	assert.NotEmpty(t, reports[1].Host)
+}
+
+func TestRemotePoller_RejectsBundles(t *testing.T) {
+	server := newRemoteServer(t)
+	var applied []string
+	poller, err := NewRemotePoller(RemoteConfig{
+		URL:       server.URL,
+		PublicKey: server.publicKeyPEM(t),
+		Headers:   map[string]string{"X-License-Key": "license"},
+	}, recordingApply(newTestEngineV2(t), &applied), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// A bundle whose config was changed after signing
+	server.serve(t, 1, "v1", "service:\n  name: web-01\n", `"a"`)
+	server.mu.Lock()
+	server.bundle.Config = "service:\n  name: evil\n"
+	server.mu.Unlock()
+	err = poller.Poll(ctx)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrRemoteBundleInvalid))
+	assert.Empty(t, applied)
+	assert.Equal(t, err.Error(), poller.Status().LastError)
+
+	// Not refetched until it changes
+	require.NoError(t, poller.Poll(ctx))
+
+	// A signed config the engine rejects is reported and not retried,
+	// even without an ETag
+	server.serve(t, 2, "v2", "service: [\n", "")
+	require.Error(t, poller.Poll(ctx))
+	require.NoError(t, poller.Poll(ctx))
+	assert.Empty(t, applied)
+	assert.Empty(t, poller.Status().Version)
+
+	_, reports := server.received()
+	require.Len(t, reports, 2)
+	assert.Equal(t, RemoteStatusRejected, reports[0].Status)
+	assert.Contains(t, reports[0].Error, "signature verification failed")
+	assert.Equal(t, "v2", reports[1].Version)
+	assert.Equal(t, RemoteStatusRejected, reports[1].Status)
+}
+
+func TestRemotePoller_RefusesReplayedBundles(t *testing.T) {
+	server := newRemoteServer(t)
+	config := RemoteConfig{
+		URL:       server.URL,
+		PublicKey: server.publicKeyPEM(t),
+		Headers:   map[string]string{"X-License-Key": "license"},
+		StatePath: filepath.Join(t.TempDir(), "remote_config.json"),
+	}
+	engine := newTestEngineV2(t)
+	var applied []string
+	poller, err := NewRemotePoller(config, recordingApply(engine, &applied), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	server.serve(t, 1, "v1", "service:\n  name: web-01\n", `"a"`)
+	require.NoError(t, poller.Poll(ctx))
+	server.serve(t, 2, "v2", "service:\n  name: web-02\n", `"b"`)
+	require.NoError(t, poller.Poll(ctx))
+	assert.Equal(t, uint64(2), poller.Status().Serial)
+
+	// The first bundle, validly signed, served again
+	server.serve(t, 1, "v1", "service:\n  name: web-01\n", `"c"`)
+	err = poller.Poll(ctx)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrRemoteBundleInvalid))
+	assert.Equal(t, []string{"v1", "v2"}, applied)
+
+	// Refused after a restart too
+	restarted, err := NewRemotePoller(config, recordingApply(engine, &applied), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.Equal(t, "v2", restarted.Status().Version)
+	assert.Equal(t, uint64(2), restarted.Status().Serial)
+	err = restarted.Poll(ctx)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrRemoteBundleInvalid))
+	assert.Equal(t, []string{"v1", "v2"}, applied)
+
+	// The applied bundle is unchanged rather than replayed
+	server.serve(t, 2, "v2", "service:\n  name: web-02\n", "")
+	require.NoError(t, restarted.Poll(ctx))
+
+	_, reports := server.received()
+	require.Len(t, reports, 4)
+	assert.Equal(t, RemoteStatusRejected, reports[2].Status)
+	assert.Contains(t, reports[2].Error, "serial 1 is not greater than 2")
+}
+
+func TestRemoteConfig_Validate(t *testing.T) {
+	server := newRemoteServer(t)
+	key := server.publicKeyPEM(t)
+
+	tests := []struct {
+		name   string
+		config RemoteConfig
+		errMsg string
+	}{
+		{"valid", RemoteConfig{URL: "https://config.example.com/hosts/1", PublicKey: key}, ""},
+		{"loopback http", RemoteConfig{URL: "http://127.0.0.1:8081/config", PublicKey: key}, ""},
+		{"no URL", RemoteConfig{PublicKey: key}, "remote config URL is required"},
+		{"plain http", RemoteConfig{URL: "http://config.example.com", PublicKey: key}, "must use https"},
+		{"plain http report", RemoteConfig{URL: "https://config.example.com", ReportURL: "http://example.com", PublicKey: key}, "must use https"},
+		{"no key", RemoteConfig{URL: "https://config.example.com"}, "public key is required"},
+		{"bad key", RemoteConfig{URL: "https://config.example.com", PublicKey: "key"}, "no PEM block"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package configengine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/newrelic/nrdot-host/nrdot-config-engine/pkg/facts"
+)
+
+// HostFacts are the facts about the host user config templates are
+// rendered against
+type HostFacts = facts.Host
+
+// HostFactsFunc returns the host facts a templated user config is rendered
+// against. It is called for every templated config generated.
+type HostFactsFunc func(ctx context.Context) (*HostFacts, error)
+
+// templateFuncs are the functions user config templates can call besides
+// the text/template builtins
+var templateFuncs = template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+	"sub": func(a, b int) int { return a - b },
+	"mul": func(a, b int) int { return a * b },
+	"div": func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return a / b, nil
+	},
+	"min": func(a, b int) int { return min(a, b) },
+	"max": func(a, b int) int { return max(a, b) },
+	// default returns value, or fallback when value is empty:
+	// {{ .Cloud.Region | default "on-prem" }}
+	"default": func(fallback, value interface{}) interface{} {
+		if value == nil || value == "" || value == 0 {
+			return fallback
+		}
+		return value
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// RenderConfigTemplate renders the Go template expressions of a user
+// config against host, such as
+//
+//	globallimit: {{ mul .NumCPU 5000 }}
+//
+// Configs without "{{" are returned as is. Referencing a fact that does not
+// exist is an error.
+func RenderConfigTemplate(data []byte, host *HostFacts) ([]byte, error) {
+	if !bytes.Contains(data, []byte("{{")) {
+		return data, nil
+	}
+	tmpl, err := template.New("config").Option("missingkey=error").Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("config template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, host); err != nil {
+		return nil, fmt.Errorf("config template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// renderUserConfig renders a templated user config against the current
+// host facts. Facts are only gathered for configs that are templated.
+func (e *EngineV2) renderUserConfig(ctx context.Context, data []byte) ([]byte, error) {
+	if !bytes.Contains(data, []byte("{{")) {
+		return data, nil
+	}
+	host, err := e.hostFacts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather host facts: %w", err)
+	}
+	return RenderConfigTemplate(data, host)
+}
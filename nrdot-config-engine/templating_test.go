@@ -0,0 +1,146 @@
+package configengine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-config-engine/pkg/facts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"gopkg.in/yaml.v3"
+)
+
+func testHostFacts() *HostFacts {
+	return &HostFacts{
+		Hostname: "web-01",
+		OS:       "linux",
+		Arch:     "amd64",
+		NumCPU:   8,
+		MemoryMB: 16384,
+		Cloud:    facts.Cloud{Provider: "aws", Region: "us-east-1"},
+		Services: []string{"mysql", "redis"},
+	}
+}
+
+func TestRenderConfigTemplate(t *testing.T) {
+	rendered, err := RenderConfigTemplate([]byte(`service:
+  name: {{ .Hostname }}
+  environment: {{ .Cloud.Zone | default "on-prem" }}
+  tags:
+    region: {{ .Cloud.Region }}
+    mysql: "{{ .HasService "mysql" }}"
+processing:
+  cardinality:
+    globallimit: {{ mul .NumCPU 5000 }}
+    permetric: {{ min (div .MemoryMB 16) 500 }}
+`), testHostFacts())
+	require.NoError(t, err)
+
+	assert.Equal(t, `service:
+  name: web-01
+  environment: on-prem
+  tags:
+    region: us-east-1
+    mysql: "true"
+processing:
+  cardinality:
+    globallimit: 40000
+    permetric: 500
+`, string(rendered))
+}
+
+func TestRenderConfigTemplate_Untemplated(t *testing.T) {
+	config := []byte("service:\n  name: ${HOSTNAME}\n")
+	rendered, err := RenderConfigTemplate(config, nil)
+	require.NoError(t, err)
+	assert.Equal(t, config, rendered)
+}
+
+func TestRenderConfigTemplate_Errors(t *testing.T) {
+	tests := map[string]string{
+		"unknown fact":     "service:\n  name: {{ .Datacenter }}\n",
+		"syntax":           "service:\n  name: {{ .Hostname\n",
+		"division by zero": "service:\n  name: x{{ div .NumCPU 0 }}\n",
+	}
+	for name, config := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := RenderConfigTemplate([]byte(config), testHostFacts())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "config template")
+		})
+	}
+}
+
+func newTemplatingEngine(t *testing.T, hostFacts HostFactsFunc) *EngineV2 {
+	engine, err := NewEngineV2(ConfigV2{Logger: zaptest.NewLogger(t), HostFacts: hostFacts})
+	require.NoError(t, err)
+	return engine
+}
+
+func TestEngineV2_ApplyConfig_Templated(t *testing.T) {
+	host := testHostFacts()
+	engine := newTemplatingEngine(t, func(ctx context.Context) (*HostFacts, error) {
+		return host, nil
+	})
+
+	templated := []byte(`service:
+  name: {{ .Hostname }}
+processing:
+  cardinality:
+    enabled: true
+    globallimit: {{ mul .NumCPU 5000 }}
+`)
+	result, err := engine.ApplyConfig(context.Background(), &models.ConfigUpdate{
+		Config: templated,
+		Format: "yaml",
+		Source: "api",
+	})
+	require.NoError(t, err)
+	require.True(t, result.Success)
+
+	current, err := engine.GetCurrentConfig(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "web-01", current.Service.Name)
+	assert.Equal(t, 40000, current.Processing.Cardinality.GlobalLimit)
+
+	var otel struct {
+		Processors map[string]map[string]interface{} `yaml:"processors"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(engine.currentOTel), &otel))
+	assert.Equal(t, 40000, otel.Processors["nrcap"]["global_limit"])
+
+	// The history keeps the template, so a rollback renders it again
+	assert.Equal(t, string(templated), engine.versionMap[result.Version].UserConfig)
+}
+
+func TestEngineV2_ValidateConfig_TemplateError(t *testing.T) {
+	engine := newTemplatingEngine(t, func(ctx context.Context) (*HostFacts, error) {
+		return testHostFacts(), nil
+	})
+
+	result, err := engine.ValidateConfig(context.Background(), []byte("service:\n  name: {{ .Datacenter }}\n"))
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0].Message, "Datacenter")
+}
+
+func TestEngineV2_HostFactsOnlyForTemplates(t *testing.T) {
+	gathered := 0
+	engine := newTemplatingEngine(t, func(ctx context.Context) (*HostFacts, error) {
+		gathered++
+		return nil, errors.New("no facts")
+	})
+
+	_, err := engine.ProcessUserConfig(context.Background(), []byte("service:\n  name: web-01\n"))
+	require.NoError(t, err)
+	assert.Zero(t, gathered)
+
+	_, err = engine.ProcessUserConfig(context.Background(), []byte("service:\n  name: {{ .Hostname }}\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no facts")
+	assert.Equal(t, 1, gathered)
+}
@@ -0,0 +1,127 @@
+package configengine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestEngineV2_ValidateConfig_UnknownFields(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	result, err := engine.ValidateConfig(context.Background(), []byte(`config_version: 2
+service:
+  name: web-01
+metrcs:
+  enabled: true
+logs:
+  enabeld: true
+  include_stdout: true
+checks:
+  - name: db
+    type: tcp
+    tagret: localhost:5432
+`))
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Equal(t, []models.ValidationError{
+		{Path: "metrcs", Message: "unknown field", Code: "UNKNOWN_FIELD", Line: 4, Column: 1, Suggestion: "metrics"},
+		{Path: "logs.enabeld", Message: "unknown field", Code: "UNKNOWN_FIELD", Line: 7, Column: 3, Suggestion: "enabled"},
+		{Path: "logs.include_stdout", Message: "unknown field", Code: "UNKNOWN_FIELD", Line: 8, Column: 3, Suggestion: "includestdout"},
+		{Path: "checks[0].tagret", Message: "unknown field", Code: "UNKNOWN_FIELD", Line: 12, Column: 5, Suggestion: "target"},
+	}, result.Errors)
+}
+
+func TestEngineV2_ValidateConfig_NoSuggestion(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	result, err := engine.ValidateConfig(context.Background(), []byte("service:\n  name: web-01\nkubernetes: {}\n"))
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "kubernetes", result.Errors[0].Path)
+	assert.Empty(t, result.Errors[0].Suggestion)
+
+	// Free-form maps take any key
+	result, err = engine.ValidateConfig(context.Background(), []byte("service:\n  name: web-01\n  tags:\n    tema: payments\n"))
+	require.NoError(t, err)
+	assert.True(t, result.Valid, "%+v", result.Errors)
+}
+
+func TestEngineV2_ValidateConfig_SchemaErrorPositions(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	result, err := engine.ValidateConfig(context.Background(), []byte(`service:
+  environment: prod
+checks:
+  - name: ping
+    type: icmp
+`))
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 2)
+
+	assert.Equal(t, "service.name", result.Errors[0].Path)
+	assert.Equal(t, "SCHEMA_VIOLATION", result.Errors[0].Code)
+	assert.Equal(t, 1, result.Errors[0].Line)
+
+	assert.Equal(t, "checks[0].type", result.Errors[1].Path)
+	assert.Equal(t, 5, result.Errors[1].Line)
+	assert.Equal(t, 5, result.Errors[1].Column)
+}
+
+func TestEngineV2_ValidateConfig_InvalidValue(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	result, err := engine.ValidateConfig(context.Background(), []byte("service:\n  name: web-01\nmetrics:\n  interval: soon\n"))
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "INVALID_VALUE", result.Errors[0].Code)
+	assert.Equal(t, 4, result.Errors[0].Line)
+
+	result, err = engine.ValidateConfig(context.Background(), []byte("service:\n  name: [web\n"))
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "INVALID_YAML", result.Errors[0].Code)
+	assert.NotZero(t, result.Errors[0].Line)
+}
+
+func TestEngineV2_ApplyConfig_UnknownFieldErrors(t *testing.T) {
+	engine := newTestEngineV2(t)
+
+	result, err := engine.ApplyConfig(context.Background(), &models.ConfigUpdate{
+		Config: []byte("service:\n  name: web-01\nmetrcs: {}\n"),
+		Format: "yaml",
+		Source: "api",
+	})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	require.Len(t, result.ValidationResult.Errors, 1)
+	assert.Equal(t, "metrics", result.ValidationResult.Errors[0].Suggestion)
+	assert.Contains(t, result.Error.Details, `line 3, column 1: metrcs: unknown field, did you mean "metrics"?`)
+
+	// The errors stay located through ProcessUserConfig too
+	_, err = engine.ProcessUserConfig(context.Background(), []byte("service:\n  name: web-01\nmetrcs: {}\n"))
+	var located ValidationErrors
+	require.True(t, errors.As(err, &located))
+	assert.Equal(t, 3, located[0].Line)
+}
+
+func TestEngineV2_AllowUnknownFields(t *testing.T) {
+	engine, err := NewEngineV2(ConfigV2{Logger: zaptest.NewLogger(t), AllowUnknownFields: true})
+	require.NoError(t, err)
+
+	config := []byte("service:\n  name: web-01\nmetrcs: {}\n")
+	result, err := engine.ValidateConfig(context.Background(), config)
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Equal(t, []string{`line 3, column 1: metrcs: unknown field, did you mean "metrics"? (ignored)`}, result.Warnings)
+
+	applied, err := engine.ApplyConfig(context.Background(), &models.ConfigUpdate{Config: config, Format: "yaml", Source: "api"})
+	require.NoError(t, err)
+	assert.True(t, applied.Success)
+	assert.Len(t, applied.ValidationResult.Warnings, 1)
+}
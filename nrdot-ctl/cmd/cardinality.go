@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/output"
+	"github.com/newrelic/nrdot-host/processors/nrcap"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	simulateFile      string
+	simulateProcessor string
+	simulateAll       bool
+)
+
+// cardinalityCmd represents the cardinality command
+var cardinalityCmd = &cobra.Command{
+	Use:   "cardinality",
+	Short: "Work with nrcap cardinality limits",
+}
+
+// cardinalitySimulateCmd represents the cardinality simulate command
+var cardinalitySimulateCmd = &cobra.Command{
+	Use:   "simulate SAMPLE",
+	Short: "Show what candidate cardinality limits would do to recorded metrics",
+	Long: `Evaluate an nrcap configuration offline against a recorded sample, and
+report the series, data points and labels each metric would lose, so limits
+can be tuned before they reach production.
+
+The sample is either metrics recorded by the file exporter, as OTLP JSON or
+protobuf, or a cardinality report nrcap writes on SIGUSR2. OTLP samples are
+replayed through the processor as recorded, following their timestamps;
+reports only count series and label values, so their results are estimates.
+
+The configuration is a collector config, with the processor chosen by
+--processor when it has more than one nrcap processor, or the settings of a
+single nrcap processor. Nothing is sent to the agent.`,
+	Example: `  nrdot-ctl cardinality simulate -f candidate.yaml metrics.json
+  nrdot-ctl cardinality simulate -f config.yaml --processor nrcap/pods nrcap-report.json
+  nrdot-ctl cardinality simulate -f candidate.yaml metrics.pb --all -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCardinalitySimulate,
+}
+
+func init() {
+	rootCmd.AddCommand(cardinalityCmd)
+	cardinalityCmd.AddCommand(cardinalitySimulateCmd)
+
+	cardinalitySimulateCmd.Flags().StringVarP(&simulateFile, "file", "f", "", "Collector or nrcap processor config with the candidate limits (required)")
+	cardinalitySimulateCmd.Flags().StringVar(&simulateProcessor, "processor", "", "nrcap processor of the collector config, e.g. nrcap/pods")
+	cardinalitySimulateCmd.Flags().BoolVar(&simulateAll, "all", false, "List metrics the limits leave untouched")
+	cardinalitySimulateCmd.MarkFlagRequired("file")
+}
+
+func runCardinalitySimulate(cmd *cobra.Command, args []string) error {
+	// Flags and arguments are checked by now; failures are about the files
+	cmd.SilenceUsage = true
+
+	cfg, err := loadCandidateLimits(simulateFile, simulateProcessor)
+	if err != nil {
+		return err
+	}
+
+	report, err := nrcap.SimulateFile(cfg, args[0])
+	if err != nil {
+		return fmt.Errorf("simulation failed: %w", err)
+	}
+
+	formatter := output.NewFormatter(GetOutputFormat())
+	return formatter.FormatCardinalitySimulation(report, simulateAll)
+}
+
+// loadCandidateLimits reads the nrcap configuration to simulate from a
+// collector config, or from a file holding only the processor settings
+func loadCandidateLimits(path, processor string) (*nrcap.Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	settings := raw
+	if processors, ok := raw["processors"].(map[string]any); ok {
+		var names []string
+		for name := range processors {
+			if name == "nrcap" || strings.HasPrefix(name, "nrcap/") {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		switch {
+		case processor != "":
+			if _, ok := processors[processor]; !ok {
+				return nil, fmt.Errorf("processor %s not found in %s", processor, path)
+			}
+		case len(names) == 1:
+			processor = names[0]
+		case len(names) == 0:
+			return nil, fmt.Errorf("no nrcap processor in %s", path)
+		default:
+			return nil, fmt.Errorf("%s has several nrcap processors, choose one with --processor: %s", path, strings.Join(names, ", "))
+		}
+
+		// A processor without settings runs on the defaults
+		settings, _ = processors[processor].(map[string]any)
+	} else if processor != "" {
+		return nil, fmt.Errorf("%s is not a collector config, --processor does not apply", path)
+	}
+
+	cfg, err := nrcap.NewConfig(settings)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nrcap config: %w", err)
+	}
+	return cfg, nil
+}
@@ -2,8 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"io"
-	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
@@ -48,12 +46,12 @@ var restartCmd = &cobra.Command{
 	RunE:  runRestart,
 }
 
-// logsCmd represents the logs command
-var logsCmd = &cobra.Command{
+// collectorLogsCmd represents the collector logs command
+var collectorLogsCmd = &cobra.Command{
 	Use:   "logs",
 	Short: "View collector logs",
-	Long:  `Display logs from the NRDOT collector.`,
-	RunE:  runLogs,
+	Long:  `Display logs from the NRDOT collector; the same as logs --component collector.`,
+	RunE:  runCollectorLogs,
 }
 
 func init() {
@@ -61,11 +59,11 @@ func init() {
 	collectorCmd.AddCommand(startCmd)
 	collectorCmd.AddCommand(stopCmd)
 	collectorCmd.AddCommand(restartCmd)
-	collectorCmd.AddCommand(logsCmd)
+	collectorCmd.AddCommand(collectorLogsCmd)
 
 	// Flags for logs command
-	logsCmd.Flags().BoolVarP(&follow, "follow", "f", false, "Follow log output")
-	logsCmd.Flags().IntVarP(&lines, "lines", "n", 100, "Number of lines to show")
+	collectorLogsCmd.Flags().BoolVarP(&follow, "follow", "f", false, "Follow log output")
+	collectorLogsCmd.Flags().IntVarP(&lines, "lines", "n", 100, "Number of lines to show")
 }
 
 func runStart(cmd *cobra.Command, args []string) error {
@@ -137,29 +135,10 @@ func runRestart(cmd *cobra.Command, args []string) error {
 	return formatter.FormatOperationResult(result)
 }
 
-func runLogs(cmd *cobra.Command, args []string) error {
-	// Create API client
-	c := client.New(GetAPIEndpoint())
-
-	// Get logs
-	if follow {
-		// Stream logs
-		reader, err := c.StreamLogs()
-		if err != nil {
-			return fmt.Errorf("failed to stream logs: %w", err)
-		}
-		defer reader.Close()
-
-		_, err = io.Copy(os.Stdout, reader)
-		return err
-	} else {
-		// Get recent logs
-		logs, err := c.GetLogs(lines)
-		if err != nil {
-			return fmt.Errorf("failed to get logs: %w", err)
-		}
-
-		fmt.Print(logs)
-		return nil
-	}
-}
\ No newline at end of file
+func runCollectorLogs(cmd *cobra.Command, args []string) error {
+	return printLogs(client.LogQuery{
+		Component: "collector",
+		Tail:      lines,
+		Follow:    follow,
+	})
+}
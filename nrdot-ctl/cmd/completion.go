@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// completionTimeout bounds API lookups during shell completion, so an
+	// unreachable API never stalls the shell
+	completionTimeout = 2 * time.Second
+
+	// completionVersions is how many recent config versions are offered
+	completionVersions = 20
+)
+
+// completionClient returns an API client for completion lookups
+func completionClient() *client.Client {
+	c := client.New(GetAPIEndpoint())
+	c.SetAuth(GetAuthToken(), GetAPIKey())
+	c.SetTimeout(completionTimeout)
+	return c
+}
+
+// completeConfigVersions completes the config versions a rollback can
+// return to, newest first, described by their source and age. The current
+// version is left out.
+func completeConfigVersions(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	history, err := completionClient().GetConfigHistory(completionVersions)
+	if err != nil {
+		cobra.CompDebugln(fmt.Sprintf("config history lookup failed: %v", err), true)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	now := time.Now()
+	var versions []string
+	for i := len(history) - 2; i >= 0; i-- {
+		v := history[i]
+		version := strconv.Itoa(v.Version)
+		if !strings.HasPrefix(version, toComplete) {
+			continue
+		}
+		versions = append(versions, fmt.Sprintf("%s\t%s", version, describeConfigVersion(v, now)))
+	}
+	return versions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+// describeConfigVersion returns the completion description of a version
+func describeConfigVersion(v client.ConfigVersion, now time.Time) string {
+	parts := []string{v.Source}
+	if !v.AppliedAt.IsZero() {
+		if age := now.Sub(v.AppliedAt).Round(time.Minute); age < time.Minute {
+			parts = append(parts, "just now")
+		} else {
+			parts = append(parts, strings.TrimSuffix(age.String(), "0s")+" ago")
+		}
+	}
+	if v.Description != "" {
+		parts = append(parts, v.Description)
+	}
+	return strings.Join(parts, ", ")
+}
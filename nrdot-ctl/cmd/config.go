@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
 	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/output"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -42,16 +48,58 @@ configuration. This uses the nrdot-template-lib to transform the configuration.`
 var applyCmd = &cobra.Command{
 	Use:   "apply",
 	Short: "Apply new configuration",
-	Long: `Apply a new configuration to the running collector. This will validate
-the configuration and then reload the collector with the new settings.`,
+	Long: `Apply a new configuration to the running collector. The configuration is
+validated by the agent first and its changes from the running configuration
+are shown as a diff, to confirm before they are applied; an invalid
+configuration is not applied.
+
+With --dry-run the configuration is validated and compared but not applied.
+--force applies without asking, as scripts must: without a terminal, or with
+--non-interactive, changes that are not forced fail.`,
+	Example: `  nrdot-ctl config apply -f config.yaml --dry-run
+  nrdot-ctl config apply -f config.yaml
+  nrdot-ctl config apply -f config.yaml --force`,
 	RunE: runApply,
 }
 
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List configuration versions",
+	Long:  `List the most recent configuration versions kept by the supervisor.`,
+	RunE:  runHistory,
+}
+
+// rollbackCmd represents the rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback VERSION",
+	Short: "Roll back to a previous configuration version",
+	Long: `Restore the configuration of a previous version and reload the collector
+with it. The restored configuration is recorded as a new version. Versions
+are completed from the live API.
+
+The versions replaced and restored are shown, to confirm before rolling
+back; --force rolls back without asking.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeConfigVersions,
+	RunE:              runRollback,
+}
+
+var (
+	historyLimit int
+	applyDryRun  bool
+)
+
+// rollbackHistory is how many versions are looked up to describe a rollback
+const rollbackHistory = 100
+
 func init() {
 	rootCmd.AddCommand(configCmd)
 	configCmd.AddCommand(validateCmd)
 	configCmd.AddCommand(generateCmd)
 	configCmd.AddCommand(applyCmd)
+	configCmd.AddCommand(historyCmd)
+	configCmd.AddCommand(rollbackCmd)
 
 	// Flags for validate command
 	validateCmd.Flags().StringVarP(&configFile, "file", "f", "", "Configuration file to validate (required)")
@@ -64,7 +112,11 @@ func init() {
 
 	// Flags for apply command
 	applyCmd.Flags().StringVarP(&configFile, "file", "f", "", "Configuration file to apply (required)")
+	applyCmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "Validate the configuration and show its changes without applying it")
 	applyCmd.MarkFlagRequired("file")
+
+	// Flags for history command
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 10, "Number of versions to list")
 }
 
 func runValidate(cmd *cobra.Command, args []string) error {
@@ -124,17 +176,136 @@ func runApply(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
+	proposed, err := decodeConfigFile(configData)
+	if err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	update := &client.ConfigUpdate{
+		Config: configData,
+		Format: "yaml",
+		DryRun: applyDryRun,
+		Source: "cli",
+	}
+	if strings.EqualFold(filepath.Ext(configFile), ".json") {
+		update.Format = "json"
+	}
+
+	// Create API client
+	c := client.New(GetAPIEndpoint())
+	c.SetAuth(GetAuthToken(), GetAPIKey())
+	formatter := output.NewFormatter(GetOutputFormat())
+
+	// Validate on the server before showing or applying anything
+	checked, err := c.CheckConfig(update)
+	if err != nil {
+		return fmt.Errorf("failed to validate config: %w", err)
+	}
+	report := &output.ConfigApplyReport{Validation: checked.Validation, DryRun: applyDryRun}
+	if checked.Validation != nil && !checked.Validation.Valid {
+		if err := formatter.FormatConfigApply(report); err != nil {
+			return err
+		}
+		return fmt.Errorf("configuration is invalid")
+	}
+
+	running, err := c.GetRunningConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get running config: %w", err)
+	}
+	report.Changes = output.DiffConfig(running, proposed)
+
+	if !applyDryRun && len(report.Changes) > 0 {
+		diff, err := output.ConfigUnifiedDiff("running", configFile, running, proposed)
+		if err != nil {
+			return fmt.Errorf("failed to compare configs: %w", err)
+		}
+		preview := func(w io.Writer) {
+			output.FormatUnifiedDiff(w, diff)
+			fmt.Fprintln(w)
+		}
+		if err := newConfirmer().Confirm("apply this configuration", preview); err != nil {
+			return err
+		}
+	}
+
+	if !applyDryRun {
+		report.Result, err = c.ApplyConfig(update)
+		if err != nil {
+			return fmt.Errorf("failed to apply config: %w", err)
+		}
+	}
+
+	if err := formatter.FormatConfigApply(report); err != nil {
+		return err
+	}
+	if report.Result != nil && !report.Result.Success {
+		return fmt.Errorf("configuration was not applied")
+	}
+	return nil
+}
+
+// decodeConfigFile decodes a YAML or JSON config file the way the API
+// reports configs, as JSON, so the two can be compared
+func decodeConfigFile(data []byte) (map[string]interface{}, error) {
+	var config map[string]interface{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	config = nil
+	if err := json.Unmarshal(encoded, &config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	// Create API client
+	c := client.New(GetAPIEndpoint())
+	c.SetAuth(GetAuthToken(), GetAPIKey())
+
+	history, err := c.GetConfigHistory(historyLimit)
+	if err != nil {
+		return fmt.Errorf("failed to get config history: %w", err)
+	}
+
+	// Format output
+	formatter := output.NewFormatter(GetOutputFormat())
+	return formatter.FormatConfigHistory(history)
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	version, err := strconv.Atoi(args[0])
+	if err != nil || version <= 0 {
+		return fmt.Errorf("invalid version %q: a positive version number is required", args[0])
+	}
 
 	// Create API client
 	c := client.New(GetAPIEndpoint())
+	c.SetAuth(GetAuthToken(), GetAPIKey())
+
+	history, err := c.GetConfigHistory(rollbackHistory)
+	if err != nil {
+		return fmt.Errorf("failed to get config history: %w", err)
+	}
+	preview := func(w io.Writer) {
+		output.FormatRollbackPreview(w, version, history)
+		fmt.Fprintln(w)
+	}
+	if err := newConfirmer().Confirm(fmt.Sprintf("roll back to version %d", version), preview); err != nil {
+		return err
+	}
 
-	// Apply configuration
-	result, err := c.ApplyConfig(configData)
+	result, err := c.RollbackConfig(version)
 	if err != nil {
-		return fmt.Errorf("failed to apply config: %w", err)
+		return fmt.Errorf("failed to roll back config: %w", err)
 	}
 
 	// Format output
 	formatter := output.NewFormatter(GetOutputFormat())
-	return formatter.FormatApplyResult(result)
-}
\ No newline at end of file
+	return formatter.FormatRollbackResult(result)
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/config"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	contextServer string
+	contextToken  string
+	contextAPIKey string
+	contextUse    bool
+)
+
+// setContextCmd represents the config set-context command
+var setContextCmd = &cobra.Command{
+	Use:   "set-context NAME",
+	Short: "Add or update a context",
+	Long: `Add a context naming an agent's API endpoint and credentials, or update the
+settings given of an existing one. Contexts are stored in ~/.nrdot/config,
+readable by the current user only.
+
+Commands run against the current context, or the one given with --context;
+--api-endpoint, --token and --api-key override its settings.`,
+	Example: `  nrdot-ctl config set-context prod-host-1 --server https://prod-host-1:8080 --api-key $KEY
+  nrdot-ctl config set-context prod-host-1 --token $JWT --use`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSetContext,
+}
+
+// useContextCmd represents the config use-context command
+var useContextCmd = &cobra.Command{
+	Use:               "use-context NAME",
+	Short:             "Set the current context",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeContexts,
+	RunE:              runUseContext,
+}
+
+// getContextsCmd represents the config get-contexts command
+var getContextsCmd = &cobra.Command{
+	Use:   "get-contexts",
+	Short: "List contexts",
+	RunE:  runGetContexts,
+}
+
+// deleteContextCmd represents the config delete-context command
+var deleteContextCmd = &cobra.Command{
+	Use:               "delete-context NAME",
+	Short:             "Delete a context",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeContexts,
+	RunE:              runDeleteContext,
+}
+
+func init() {
+	configCmd.AddCommand(setContextCmd)
+	configCmd.AddCommand(useContextCmd)
+	configCmd.AddCommand(getContextsCmd)
+	configCmd.AddCommand(deleteContextCmd)
+
+	setContextCmd.Flags().StringVar(&contextServer, "server", "", "API endpoint of the agent's supervisor")
+	setContextCmd.Flags().StringVar(&contextToken, "token", "", "JWT for the agent's API")
+	setContextCmd.Flags().StringVar(&contextAPIKey, "api-key", "", "API key for the agent's API")
+	setContextCmd.Flags().BoolVar(&contextUse, "use", false, "Make it the current context")
+}
+
+func runSetContext(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	context, exists := contexts.Get(name)
+	if !exists {
+		if contextServer == "" {
+			return fmt.Errorf("--server is required for a new context")
+		}
+		context = &config.Context{Name: name}
+	}
+
+	if cmd.Flags().Changed("server") {
+		u, err := url.Parse(contextServer)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("--server must be an http or https URL")
+		}
+		context.Server = contextServer
+	}
+	if cmd.Flags().Changed("token") {
+		context.Token = contextToken
+	}
+	if cmd.Flags().Changed("api-key") {
+		context.APIKey = contextAPIKey
+	}
+	contexts.Set(*context)
+	if contextUse {
+		contexts.CurrentContext = name
+	}
+
+	if err := contexts.Save(config.GetContextsPath()); err != nil {
+		return fmt.Errorf("failed to save contexts: %w", err)
+	}
+
+	if exists {
+		fmt.Printf("Context %s updated\n", name)
+	} else {
+		fmt.Printf("Context %s created\n", name)
+	}
+	return nil
+}
+
+func runUseContext(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if _, ok := contexts.Get(name); !ok {
+		return fmt.Errorf("context %q not found", name)
+	}
+	contexts.CurrentContext = name
+
+	if err := contexts.Save(config.GetContextsPath()); err != nil {
+		return fmt.Errorf("failed to save contexts: %w", err)
+	}
+	fmt.Printf("Switched to context %s\n", name)
+	return nil
+}
+
+func runGetContexts(cmd *cobra.Command, args []string) error {
+	infos := make([]output.ContextInfo, 0, len(contexts.Contexts))
+	for _, context := range contexts.Contexts {
+		auth := "none"
+		switch {
+		case context.Token != "":
+			auth = "token"
+		case context.APIKey != "":
+			auth = "api-key"
+		}
+		infos = append(infos, output.ContextInfo{
+			Name:    context.Name,
+			Server:  context.Server,
+			Auth:    auth,
+			Current: context.Name == contexts.CurrentContext,
+		})
+	}
+
+	formatter := output.NewFormatter(GetOutputFormat())
+	return formatter.FormatContexts(infos)
+}
+
+func runDeleteContext(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if !contexts.Delete(name) {
+		return fmt.Errorf("context %q not found", name)
+	}
+
+	if err := contexts.Save(config.GetContextsPath()); err != nil {
+		return fmt.Errorf("failed to save contexts: %w", err)
+	}
+	fmt.Printf("Context %s deleted\n", name)
+	return nil
+}
+
+// completeContexts completes context names
+func completeContexts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 || contexts == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names := make([]string, 0, len(contexts.Contexts))
+	for _, context := range contexts.Contexts {
+		names = append(names, context.Name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	discoverWatch    bool
+	discoverInterval time.Duration
+)
+
+// discoverCmd represents the discover command
+var discoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Discover services running on the host",
+	Long: `Run service discovery on the agent's host and list the services found
+with their type, version, endpoints, confidence and the discovery methods
+(process, port, config_file, package) that detected them.
+
+With --watch, discovery re-runs every --interval until interrupted.`,
+	RunE: runDiscover,
+}
+
+func init() {
+	rootCmd.AddCommand(discoverCmd)
+
+	discoverCmd.Flags().BoolVarP(&discoverWatch, "watch", "w", false, "Re-run discovery on an interval until interrupted")
+	discoverCmd.Flags().DurationVar(&discoverInterval, "interval", 30*time.Second, "Time between discovery runs in watch mode")
+}
+
+func runDiscover(cmd *cobra.Command, args []string) error {
+	if discoverWatch && discoverInterval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+
+	// Create API client
+	c := client.New(GetAPIEndpoint())
+	c.SetAuth(GetAuthToken(), GetAPIKey())
+	formatter := output.NewFormatter(GetOutputFormat())
+
+	if !discoverWatch {
+		result, err := c.Discover()
+		if err != nil {
+			return fmt.Errorf("failed to discover services: %w", err)
+		}
+		return formatter.FormatDiscovery(result)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(discoverInterval)
+	defer ticker.Stop()
+
+	for {
+		// A failed run is reported and retried on the next tick
+		result, err := c.Discover()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to discover services: %v\n", err)
+		} else if err := formatter.FormatDiscovery(result); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if GetOutputFormat() == "table" {
+				fmt.Println()
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/doctor"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+var doctorOptions = doctor.DefaultOptions()
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the host environment",
+	Long: `Check that this host can run NRDOT and print a report with a hint for
+each problem. The checks cover:
+
+  - the collector binary and its version
+  - the configuration, validated by the agent API when it is reachable
+  - the agent API and the agent's health
+  - the format of the license key
+  - outbound connectivity to the New Relic OTLP endpoint
+  - the permissions of the privileged helper's socket
+  - the space available in the work directory
+
+The command runs on the host itself and exits non-zero if a check fails;
+warnings do not fail it.`,
+	Example: `  nrdot-ctl doctor
+  nrdot-ctl doctor --file ./config.yaml --collector otelcol
+  nrdot-ctl doctor -o json`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	flags := doctorCmd.Flags()
+	flags.StringVar(&doctorOptions.CollectorPath, "collector", doctorOptions.CollectorPath, "Collector binary, a path or a name in PATH")
+	flags.StringVarP(&doctorOptions.ConfigPath, "file", "f", doctorOptions.ConfigPath, "NRDOT configuration file")
+	flags.StringVar(&doctorOptions.WorkDir, "workdir", doctorOptions.WorkDir, "Agent work directory")
+	flags.StringVar(&doctorOptions.HelperSocket, "helper-socket", doctorOptions.HelperSocket, "Privileged helper socket")
+	flags.StringSliceVar(&doctorOptions.Endpoints, "endpoint", doctorOptions.Endpoints, "New Relic host:port to check connectivity to (repeatable)")
+	flags.DurationVar(&doctorOptions.Timeout, "timeout", doctorOptions.Timeout, "Timeout of each check that runs the collector or connects out")
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	// The report covers failures; usage is not the problem
+	cmd.SilenceUsage = true
+
+	c := client.New(GetAPIEndpoint())
+	c.SetAuth(GetAuthToken(), GetAPIKey())
+	c.SetTimeout(doctorOptions.Timeout)
+
+	opts := doctorOptions
+	opts.Client = c
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report := doctor.Run(ctx, opts)
+
+	formatter := output.NewFormatter(GetOutputFormat())
+	if err := formatter.FormatDoctorReport(report); err != nil {
+		return err
+	}
+
+	if failed := report.Count(doctor.StatusFail); failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(report.Checks))
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/config"
+)
+
+const (
+	// fleetConcurrency is how many agents --all-contexts queries at once
+	fleetConcurrency = 16
+
+	// fleetTimeout bounds each request of --all-contexts, so unreachable
+	// agents don't hold up the others
+	fleetTimeout = 10 * time.Second
+)
+
+// forAllContexts calls fn with a client for the agent of every context,
+// concurrently. i is the index of the context in contexts.Contexts.
+func forAllContexts(fn func(i int, context config.Context, c *client.Client)) error {
+	if len(contexts.Contexts) == 0 {
+		return fmt.Errorf("no contexts configured, add one with nrdot-ctl config set-context")
+	}
+
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, fleetConcurrency)
+	for i, context := range contexts.Contexts {
+		wg.Add(1)
+		go func(i int, context config.Context) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			c := client.New(context.Server)
+			c.SetAuth(context.Token, context.APIKey)
+			c.SetTimeout(fleetTimeout)
+			fn(i, context, c)
+		}(i, context)
+	}
+	wg.Wait()
+	return nil
+}
+
+// fleetError is returned when some agents of --all-contexts could not be
+// queried, after their results are shown
+func fleetError(unreachable, total int) error {
+	if unreachable == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d agents unreachable", unreachable, total)
+}
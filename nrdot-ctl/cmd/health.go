@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/config"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+var healthAllContexts bool
+
+// healthCmd represents the health command
+var healthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Show agent health",
+	Long: `Display the health of the agent and of its checks, as reported by the
+supervisor's /health endpoint.
+
+With --all-contexts the health of the agent of every context is listed.`,
+	Example: `  nrdot-ctl health
+  nrdot-ctl health --all-contexts
+  nrdot-ctl health --context prod-host-1 -o json`,
+	RunE: runHealth,
+}
+
+func init() {
+	rootCmd.AddCommand(healthCmd)
+
+	healthCmd.Flags().BoolVar(&healthAllContexts, "all-contexts", false, "Show the health of the agents of all contexts")
+}
+
+func runHealth(cmd *cobra.Command, args []string) error {
+	if healthAllContexts {
+		// Unreachable agents are not a usage error
+		cmd.SilenceUsage = true
+		return runFleetHealth()
+	}
+
+	c := client.New(GetAPIEndpoint())
+	c.SetAuth(GetAuthToken(), GetAPIKey())
+
+	health, err := c.GetHealth()
+	if err != nil {
+		return fmt.Errorf("failed to get health: %w", err)
+	}
+
+	formatter := output.NewFormatter(GetOutputFormat())
+	return formatter.FormatHealth(health)
+}
+
+func runFleetHealth() error {
+	fleet := make([]output.FleetHealth, len(contexts.Contexts))
+	err := forAllContexts(func(i int, context config.Context, c *client.Client) {
+		fleet[i] = output.FleetHealth{Context: context.Name, Server: context.Server}
+		health, err := c.GetHealth()
+		if err != nil {
+			fleet[i].Error = err.Error()
+			return
+		}
+		fleet[i].Health = health
+	})
+	if err != nil {
+		return err
+	}
+
+	formatter := output.NewFormatter(GetOutputFormat())
+	if err := formatter.FormatFleetHealth(fleet); err != nil {
+		return err
+	}
+
+	unreachable := 0
+	for _, agent := range fleet {
+		if agent.Health == nil {
+			unreachable++
+		}
+	}
+	return fleetError(unreachable, len(fleet))
+}
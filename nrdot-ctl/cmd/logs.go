@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logsComponent string
+	logsFollow    bool
+	logsTail      int
+	logsSince     string
+	logsGrep      string
+	logsLevel     string
+)
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Tail collector or supervisor logs",
+	Long: `Show the recent log lines of the collector or the supervisor, as kept by
+the supervisor, and with --follow keep streaming new lines until interrupted.
+
+--since, --grep and --level filter the lines on the agent; --tail counts the
+lines passing them. With -o json every line is printed as a JSON object with
+its timestamp, stream and severity.`,
+	Example: `  nrdot-ctl logs -f
+  nrdot-ctl logs --component supervisor --since 10m
+  nrdot-ctl logs --grep 'export|dropped' --level warn -f
+  nrdot-ctl logs --since 2024-01-01T12:00:00Z -o json`,
+	RunE: runLogs,
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+
+	logsCmd.Flags().StringVar(&logsComponent, "component", "collector", "Log to show: collector or supervisor")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep streaming new lines")
+	logsCmd.Flags().IntVarP(&logsTail, "tail", "n", 100, "Number of recent lines to show")
+	logsCmd.Flags().StringVar(&logsSince, "since", "", "Only lines from this long ago (e.g. 10m) or since an RFC 3339 time")
+	logsCmd.Flags().StringVar(&logsGrep, "grep", "", "Only lines matching this regular expression")
+	logsCmd.Flags().StringVar(&logsLevel, "level", "", "Only lines at or above this severity (debug, info, warn, error, fatal)")
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	switch logsComponent {
+	case "collector", "supervisor":
+	default:
+		return fmt.Errorf("--component must be collector or supervisor")
+	}
+	if logsTail < 0 {
+		return fmt.Errorf("--tail must not be negative")
+	}
+
+	return printLogs(client.LogQuery{
+		Component: logsComponent,
+		Tail:      logsTail,
+		Follow:    logsFollow,
+		Level:     logsLevel,
+		Since:     logsSince,
+		Grep:      logsGrep,
+		JSON:      GetOutputFormat() == "json",
+	})
+}
+
+// printLogs copies the lines of a supervisor log to stdout
+func printLogs(query client.LogQuery) error {
+	c := client.New(GetAPIEndpoint())
+	c.SetAuth(GetAuthToken(), GetAPIKey())
+	if query.Follow {
+		// Streams last until interrupted
+		c.SetTimeout(0)
+	}
+
+	body, err := c.Logs(query)
+	if err != nil {
+		return fmt.Errorf("failed to get %s logs: %w", query.Component, err)
+	}
+	defer body.Close()
+
+	_, err = io.Copy(os.Stdout, body)
+	return err
+}
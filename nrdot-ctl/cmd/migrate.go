@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/output"
+	migration "github.com/newrelic/nrdot-host/nrdot-migration"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	migrateDryRun   bool
+	migratePreserve bool
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate from other agents to NRDOT",
+	Long: `Migrate the configuration of another agent on this host to NRDOT.
+
+Migrations run locally, not through the agent API, and need root unless
+--dry-run is given.`,
+}
+
+// migrateInfraCmd represents the migrate infra command
+var migrateInfraCmd = &cobra.Command{
+	Use:   "infra",
+	Short: "Migrate from the New Relic Infrastructure Agent",
+	Long: `Convert the configuration of the New Relic Infrastructure Agent
+(/etc/newrelic-infra.yml) to /etc/nrdot/config.yaml, stop and disable the
+Infrastructure Agent, and list custom integrations that need to be migrated
+by hand.
+
+With --dry-run the configuration is converted and reported, but nothing is
+written and the Infrastructure Agent keeps running; -o json includes the
+converted configuration. With --preserve the Infrastructure Agent's
+configuration, integrations, data and logs are backed up under
+/var/lib/nrdot/migration-backup.`,
+	Example: `  nrdot-ctl migrate infra --dry-run
+  nrdot-ctl migrate infra --dry-run -o json
+  sudo nrdot-ctl migrate infra --preserve`,
+	Args: cobra.NoArgs,
+	RunE: runMigrateInfra,
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateInfraCmd)
+
+	migrateInfraCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Convert and report without writing the config or stopping the Infrastructure Agent")
+	migrateInfraCmd.Flags().BoolVar(&migratePreserve, "preserve", false, "Back up the Infrastructure Agent's configuration, integrations, data and logs")
+}
+
+func runMigrateInfra(cmd *cobra.Command, args []string) error {
+	if !migrateDryRun && os.Geteuid() != 0 {
+		return fmt.Errorf("migrate infra must run as root, or with --dry-run")
+	}
+
+	// The report covers failures; usage is not the problem
+	cmd.SilenceUsage = true
+
+	logger := zap.NewNop()
+	if IsVerbose() {
+		if l, err := zap.NewDevelopment(); err == nil {
+			logger = l
+		}
+	}
+	defer logger.Sync()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	migrator := migration.NewInfrastructureMigrator(logger, migrateDryRun, migratePreserve)
+	report, migrateErr := migrator.Migrate(ctx)
+	if report == nil {
+		return fmt.Errorf("migration failed: %w", migrateErr)
+	}
+
+	formatter := output.NewFormatter(GetOutputFormat())
+	if err := formatter.FormatMigrationReport(report, migrateDryRun); err != nil {
+		return err
+	}
+
+	if migrateErr != nil {
+		return fmt.Errorf("migration failed: %w", migrateErr)
+	}
+	if !report.Success {
+		return fmt.Errorf("migration failed")
+	}
+	return nil
+}
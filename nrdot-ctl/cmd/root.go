@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"github.com/fatih/color"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/config"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/prompt"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -16,6 +18,13 @@ var (
 	outputFormat string
 	noColor     bool
 	verbose     bool
+	authToken   string
+	apiKey      string
+	contextName string
+
+	// contexts are the agents managed with config set-context, loaded by
+	// initConfig
+	contexts *config.Contexts
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -41,12 +50,21 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "Output format (table|json|yaml)")
 	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored output")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
+	rootCmd.PersistentFlags().StringVar(&authToken, "token", "", "JWT for an authenticated API")
+	rootCmd.PersistentFlags().StringVar(&apiKey, "api-key", "", "API key for an authenticated API")
+	rootCmd.PersistentFlags().StringVar(&contextName, "context", "", "Context of the agent to manage (default is the current context)")
+	rootCmd.PersistentFlags().Bool("force", false, "Make changes without asking for confirmation")
+	rootCmd.PersistentFlags().Bool("non-interactive", false, "Never ask for confirmation; changes not forced fail")
 
 	// Bind flags to viper
 	viper.BindPFlag("api_endpoint", rootCmd.PersistentFlags().Lookup("api-endpoint"))
 	viper.BindPFlag("output_format", rootCmd.PersistentFlags().Lookup("output"))
 	viper.BindPFlag("no_color", rootCmd.PersistentFlags().Lookup("no-color"))
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindPFlag("token", rootCmd.PersistentFlags().Lookup("token"))
+	viper.BindPFlag("api_key", rootCmd.PersistentFlags().Lookup("api-key"))
+	viper.BindPFlag("force", rootCmd.PersistentFlags().Lookup("force"))
+	viper.BindPFlag("non_interactive", rootCmd.PersistentFlags().Lookup("non-interactive"))
 
 	// Disable color if requested
 	if noColor {
@@ -83,10 +101,44 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err == nil && verbose {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
+
+	// Load the contexts of managed agents
+	var err error
+	contexts, err = config.LoadContexts(config.GetContextsPath())
+	cobra.CheckErr(err)
+	if contextName != "" {
+		if _, ok := contexts.Get(contextName); !ok {
+			cobra.CheckErr(fmt.Errorf("context %q not found", contextName))
+		}
+	}
+}
+
+// activeContext returns the context chosen with --context, else the current
+// context; nil if neither is set
+func activeContext() *config.Context {
+	if contexts == nil {
+		return nil
+	}
+	if contextName != "" {
+		context, _ := contexts.Get(contextName)
+		return context
+	}
+	return contexts.Current()
+}
+
+// fromContext reports whether a setting comes from the active context:
+// there is one and flag was not given
+func fromContext(flag string) (*config.Context, bool) {
+	context := activeContext()
+	return context, context != nil && !rootCmd.PersistentFlags().Changed(flag)
 }
 
-// GetAPIEndpoint returns the configured API endpoint
+// GetAPIEndpoint returns the configured API endpoint, the server of the
+// active context unless --api-endpoint is given
 func GetAPIEndpoint() string {
+	if context, ok := fromContext("api-endpoint"); ok {
+		return context.Server
+	}
 	return viper.GetString("api_endpoint")
 }
 
@@ -95,11 +147,35 @@ func GetOutputFormat() string {
 	return viper.GetString("output_format")
 }
 
+// GetAuthToken returns the configured JWT, the active context's unless
+// --token is given
+func GetAuthToken() string {
+	if context, ok := fromContext("token"); ok {
+		return context.Token
+	}
+	return viper.GetString("token")
+}
+
+// GetAPIKey returns the configured API key, the active context's unless
+// --api-key is given
+func GetAPIKey() string {
+	if context, ok := fromContext("api-key"); ok {
+		return context.APIKey
+	}
+	return viper.GetString("api_key")
+}
+
 // IsVerbose returns whether verbose mode is enabled
 func IsVerbose() bool {
 	return viper.GetBool("verbose")
 }
 
+// newConfirmer returns the Confirmer commands changing the agent ask with,
+// honoring --force and --non-interactive
+func newConfirmer() *prompt.Confirmer {
+	return prompt.New(viper.GetBool("force"), viper.GetBool("non_interactive"))
+}
+
 // newCompletionCmd returns the completion command
 func newCompletionCmd() *cobra.Command {
 	completionCmd := &cobra.Command{
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/output"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	secretService        string
+	secretEndpoint       string
+	secretUsername       string
+	secretDatabase       string
+	secretSkipValidation bool
+)
+
+// Endpoints used when --endpoint is not given
+var defaultSecretEndpoints = map[string]string{
+	"mysql":      "localhost:3306",
+	"postgresql": "localhost:5432",
+	"redis":      "localhost:6379",
+}
+
+// secretCmd represents the secret command
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage service credentials",
+	Long: `Manage credentials the collector uses to monitor services. Secrets are
+stored encrypted by the supervisor and passed to the collector as environment
+variables, so configs reference them as ${env:NAME} instead of holding raw
+passwords.`,
+}
+
+// secretSetCmd represents the secret set command
+var secretSetCmd = &cobra.Command{
+	Use:   "set NAME",
+	Short: "Store a service credential",
+	Long: `Store a credential in the supervisor's encrypted secrets store.
+
+The value is read from the terminal without echo, or from stdin when it is not
+a terminal. Before storing it, the supervisor logs in to the target service
+with it (mysql, postgresql, redis or http basic auth); pass --skip-validation
+to store it unchecked. Requires an API started with --auth and an admin token.
+
+Examples:
+  nrdot-ctl secret set MYSQL_MONITOR_PASS --service mysql --username newrelic
+  echo -n "$PASS" | nrdot-ctl secret set REDIS_PASS --service redis --endpoint redis:6379`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSecretSet,
+}
+
+func init() {
+	rootCmd.AddCommand(secretCmd)
+	secretCmd.AddCommand(secretSetCmd)
+
+	secretSetCmd.Flags().StringVar(&secretService, "service", "", "Service to validate against (mysql|postgresql|redis|http)")
+	secretSetCmd.Flags().StringVar(&secretEndpoint, "endpoint", "", "Service address, host:port or URL for http (default: the service's local port)")
+	secretSetCmd.Flags().StringVar(&secretUsername, "username", "", "User the credential belongs to")
+	secretSetCmd.Flags().StringVar(&secretDatabase, "database", "", "Database to connect to (postgresql only)")
+	secretSetCmd.Flags().BoolVar(&secretSkipValidation, "skip-validation", false, "Store the secret without validating it")
+}
+
+func runSecretSet(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	req := &client.SecretSetRequest{}
+	if !secretSkipValidation {
+		if secretService == "" {
+			return fmt.Errorf("--service is required to validate the credential (or pass --skip-validation)")
+		}
+		endpoint := secretEndpoint
+		if endpoint == "" {
+			endpoint = defaultSecretEndpoints[secretService]
+		}
+		req.Validate = &client.SecretValidation{
+			Service:  secretService,
+			Endpoint: endpoint,
+			Username: secretUsername,
+			Database: secretDatabase,
+		}
+	}
+
+	value, err := readSecretValue(name)
+	if err != nil {
+		return err
+	}
+	req.Value = value
+
+	// Create API client
+	c := client.New(GetAPIEndpoint())
+	c.SetAuth(GetAuthToken(), GetAPIKey())
+
+	result, err := c.SetSecret(name, req)
+	if err != nil {
+		return fmt.Errorf("failed to set secret: %w", err)
+	}
+
+	// Format output
+	formatter := output.NewFormatter(GetOutputFormat())
+	return formatter.FormatSecretResult(result)
+}
+
+// readSecretValue prompts twice without echo on a terminal, otherwise reads
+// stdin, so values never appear in shell history or the process list
+func readSecretValue(name string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return readSecretFrom(os.Stdin)
+	}
+
+	fmt.Fprintf(os.Stderr, "Value for %s: ", name)
+	first, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	fmt.Fprint(os.Stderr, "Confirm value: ")
+	second, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+
+	if !bytes.Equal(first, second) {
+		return "", fmt.Errorf("values do not match")
+	}
+	if len(first) == 0 {
+		return "", fmt.Errorf("secret value is empty")
+	}
+	return string(first), nil
+}
+
+// readSecretFrom reads a secret from r, dropping the trailing newline
+func readSecretFrom(r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, 64*1024))
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	value := strings.TrimRight(string(data), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("no secret value on stdin")
+	}
+	return value, nil
+}
@@ -5,9 +5,12 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/config"
 	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/output"
 )
 
+var statusAllContexts bool
+
 // statusCmd represents the status command
 var statusCmd = &cobra.Command{
 	Use:   "status",
@@ -17,17 +20,28 @@ var statusCmd = &cobra.Command{
 - Uptime
 - Configuration version
 - Health status
-- Recent errors`,
+- Recent errors
+
+With --all-contexts the status of the agent of every context is listed.`,
 	RunE: runStatus,
 }
 
 func init() {
 	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().BoolVar(&statusAllContexts, "all-contexts", false, "Show the status of the agents of all contexts")
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
+	if statusAllContexts {
+		// Unreachable agents are not a usage error
+		cmd.SilenceUsage = true
+		return runFleetStatus()
+	}
+
 	// Create API client
 	c := client.New(GetAPIEndpoint())
+	c.SetAuth(GetAuthToken(), GetAPIKey())
 
 	// Get status
 	status, err := c.GetStatus()
@@ -38,4 +52,33 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	// Format output
 	formatter := output.NewFormatter(GetOutputFormat())
 	return formatter.FormatStatus(status)
-}
\ No newline at end of file
+}
+
+func runFleetStatus() error {
+	fleet := make([]output.FleetStatus, len(contexts.Contexts))
+	err := forAllContexts(func(i int, context config.Context, c *client.Client) {
+		fleet[i] = output.FleetStatus{Context: context.Name, Server: context.Server}
+		status, err := c.GetStatus()
+		if err != nil {
+			fleet[i].Error = err.Error()
+			return
+		}
+		fleet[i].Status = status
+	})
+	if err != nil {
+		return err
+	}
+
+	formatter := output.NewFormatter(GetOutputFormat())
+	if err := formatter.FormatFleetStatus(fleet); err != nil {
+		return err
+	}
+
+	unreachable := 0
+	for _, agent := range fleet {
+		if agent.Status == nil {
+			unreachable++
+		}
+	}
+	return fleetError(unreachable, len(fleet))
+}
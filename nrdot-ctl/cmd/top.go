@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/output"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	topEndpoint string
+	topInterval time.Duration
+	topCount    int
+	topOnce     bool
+)
+
+// topCmd represents the top command
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Live views of the running collector",
+}
+
+// topCardinalityCmd represents the top cardinality command
+var topCardinalityCmd = &cobra.Command{
+	Use:   "cardinality",
+	Short: "Show metrics and labels by cardinality, refreshing live",
+	Long: `Poll the stats endpoint of an nrcap processor (stats_server.endpoint in
+its configuration) and show the metrics and label keys with the highest
+cardinality, how much of their limits they use, and the rates of data points
+processed and dropped, refreshing every --interval until interrupted.
+
+The metrics are read from the processor directly, not from --api-endpoint.`,
+	Example: `  nrdot-ctl top cardinality
+  nrdot-ctl top cardinality --endpoint http://localhost:13135 --top 10
+  nrdot-ctl top cardinality --once -o json`,
+	RunE: runTopCardinality,
+}
+
+func init() {
+	rootCmd.AddCommand(topCmd)
+	topCmd.AddCommand(topCardinalityCmd)
+
+	topCardinalityCmd.Flags().StringVar(&topEndpoint, "endpoint", "http://localhost:13134", "nrcap stats endpoint")
+	topCardinalityCmd.Flags().DurationVar(&topInterval, "interval", 2*time.Second, "Time between refreshes")
+	topCardinalityCmd.Flags().IntVarP(&topCount, "top", "n", 15, "Number of metrics and labels to show")
+	topCardinalityCmd.Flags().BoolVar(&topOnce, "once", false, "Show one snapshot and exit")
+}
+
+func runTopCardinality(cmd *cobra.Command, args []string) error {
+	if topInterval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	if topCount <= 0 {
+		return fmt.Errorf("--top must be positive")
+	}
+
+	c := client.New(topEndpoint)
+	formatter := output.NewFormatter(GetOutputFormat())
+
+	if topOnce {
+		stats, err := c.GetCardinalityStats(topCount)
+		if err != nil {
+			return fmt.Errorf("failed to get cardinality stats: %w", err)
+		}
+		return formatter.FormatCardinalityTop(stats, nil)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(topInterval)
+	defer ticker.Stop()
+
+	// Redraw in place on a terminal; otherwise append frames, like top -b
+	redraw := GetOutputFormat() == "table" && term.IsTerminal(int(os.Stdout.Fd()))
+
+	var previous *client.CardinalityStats
+	for {
+		// A failed poll is reported and retried on the next tick
+		stats, err := c.GetCardinalityStats(topCount)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to get cardinality stats: %v\n", err)
+		} else {
+			if redraw {
+				fmt.Print("\033[H\033[2J")
+			}
+			if err := formatter.FormatCardinalityTop(stats, previous); err != nil {
+				return err
+			}
+			previous = stats
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if !redraw && GetOutputFormat() == "table" {
+				fmt.Println()
+			}
+		}
+	}
+}
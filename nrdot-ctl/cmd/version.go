@@ -1,10 +1,19 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"regexp"
 	"runtime"
+	"time"
 
-	"github.com/spf13/cobra"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/release"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/doctor"
 	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var (
@@ -14,16 +23,44 @@ var (
 	BuildTime = "unknown"
 )
 
+var (
+	versionCheckUpdate bool
+	versionHostPath    string
+	versionCollector   string
+)
+
 // versionCmd represents the version command
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Show version information",
-	Long:  `Display version information for nrdot-ctl and its components.`,
-	RunE:  runVersion,
+	Long: `Display version information for nrdot-ctl and its components.
+
+With --check-update the release manifest of the update channel, the one the
+supervisor's updater follows, is fetched and its signature verified, and the
+installed nrdot-host and collector binaries are compared with the latest
+releases. The manifest URL, channel and key can also be set as
+update_manifest, update_channel and update_key in the nrdot-ctl config file
+or as NRDOT_UPDATE_MANIFEST, NRDOT_UPDATE_CHANNEL and NRDOT_UPDATE_KEY.`,
+	Example: `  nrdot-ctl version
+  nrdot-ctl version --check-update --update-manifest https://releases.example.com/nrdot --update-key <key>`,
+	Args: cobra.NoArgs,
+	RunE: runVersion,
 }
 
 func init() {
 	rootCmd.AddCommand(versionCmd)
+
+	flags := versionCmd.Flags()
+	flags.BoolVar(&versionCheckUpdate, "check-update", false, "Check whether the installed binaries are the latest releases")
+	flags.String("update-manifest", "", "Release manifest base URL")
+	flags.String("update-channel", "stable", "Update channel: stable, beta")
+	flags.String("update-key", "", "Base64 ed25519 public key release manifests are signed with")
+	flags.StringVar(&versionHostPath, "host-binary", "nrdot-host", "nrdot-host binary, a path or a name in PATH")
+	flags.StringVar(&versionCollector, "collector", doctor.DefaultOptions().CollectorPath, "Collector binary, a path or a name in PATH")
+
+	viper.BindPFlag("update_manifest", flags.Lookup("update-manifest"))
+	viper.BindPFlag("update_channel", flags.Lookup("update-channel"))
+	viper.BindPFlag("update_key", flags.Lookup("update-key"))
 }
 
 func runVersion(cmd *cobra.Command, args []string) error {
@@ -35,7 +72,87 @@ func runVersion(cmd *cobra.Command, args []string) error {
 		Arch:      runtime.GOARCH,
 	}
 
+	if versionCheckUpdate {
+		manifestURL := viper.GetString("update_manifest")
+		if manifestURL == "" {
+			return fmt.Errorf("--check-update needs the release manifest URL, set with --update-manifest")
+		}
+		key, err := release.ParsePublicKey(viper.GetString("update_key"))
+		if err != nil {
+			return err
+		}
+		channel := viper.GetString("update_channel")
+
+		// The manifest is the problem from here on, not usage
+		cmd.SilenceUsage = true
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		manifest, err := release.FetchManifest(ctx, http.DefaultClient, manifestURL, channel, key)
+		if err != nil {
+			return err
+		}
+
+		versionInfo.Channel = channel
+		versionInfo.Updates = checkUpdates(ctx, manifest)
+	}
+
 	// Format output
 	formatter := output.NewFormatter(GetOutputFormat())
 	return formatter.FormatVersion(versionInfo)
-}
\ No newline at end of file
+}
+
+// checkUpdates compares the installed nrdot-host and collector binaries
+// with the latest releases of manifest
+func checkUpdates(ctx context.Context, manifest *release.Manifest) []output.UpdateCheck {
+	collector := output.UpdateCheck{
+		Component:    "collector",
+		Latest:       manifest.Version,
+		ChangelogURL: manifest.ChangelogURL,
+	}
+	collector.Installed, collector.Error = binaryVersion(ctx, versionCollector, "--version")
+
+	host := output.UpdateCheck{Component: "nrdot-host"}
+	if manifest.Host != nil {
+		host.Latest = manifest.Host.Version
+		host.ChangelogURL = manifest.Host.ChangelogURL
+	}
+	host.Installed, host.Error = binaryVersion(ctx, versionHostPath, "-mode", "version")
+
+	checks := []output.UpdateCheck{host, collector}
+	for i := range checks {
+		check := &checks[i]
+		if check.Installed != "" && check.Latest != "" {
+			check.Current = release.CompareVersions(check.Installed, check.Latest) >= 0
+		}
+	}
+	return checks
+}
+
+// binaryVersionPattern extracts the version from the version output of
+// nrdot-host ("NRDOT-HOST v1.2.0") and the collector ("otelcol-nrdot version
+// 0.97.0")
+var binaryVersionPattern = regexp.MustCompile(`(?i)(?:version|nrdot-host)\s+(v?[0-9]+(\.[0-9]+)*(-[0-9A-Za-z.]+)?)`)
+
+// binaryVersion runs a binary to ask for its version, returning why not if
+// that fails
+func binaryVersion(ctx context.Context, binary string, args ...string) (string, string) {
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return "", fmt.Sprintf("%s not installed", binary)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, args...).Output()
+	if err != nil {
+		return "", fmt.Sprintf("running %s: %v", path, err)
+	}
+
+	match := binaryVersionPattern.FindStringSubmatch(string(out))
+	if match == nil {
+		return "", fmt.Sprintf("no version in the output of %s", path)
+	}
+	return match[1], ""
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/wait"
+	"github.com/spf13/cobra"
+)
+
+var (
+	waitFor      string
+	waitTimeout  time.Duration
+	waitInterval time.Duration
+)
+
+// waitCmd represents the wait command
+var waitCmd = &cobra.Command{
+	Use:   "wait",
+	Short: "Wait until the agent reaches a state",
+	Long: `Poll the agent API until the agent reaches the state given with --for, then
+exit 0. Exit 1 if it is not reached within --timeout.
+
+Conditions:
+  healthy             the agent reports healthy
+  collector-running   the collector is running
+  config-version=N    configuration version N, or a later one, is applied
+
+Failed requests are retried, so wait can be started before the agent.`,
+	Example: `  nrdot-ctl wait --for=healthy --timeout=120s
+  nrdot-ctl config apply -f config.yaml && nrdot-ctl wait --for=config-version=4
+  nrdot-ctl wait --for=collector-running --context prod-host-1`,
+	RunE: runWait,
+}
+
+func init() {
+	rootCmd.AddCommand(waitCmd)
+
+	waitCmd.Flags().StringVar(&waitFor, "for", "", "Condition to wait for (healthy|collector-running|config-version=N)")
+	waitCmd.Flags().DurationVar(&waitTimeout, "timeout", 60*time.Second, "How long to wait")
+	waitCmd.Flags().DurationVar(&waitInterval, "interval", 2*time.Second, "Time between polls")
+	waitCmd.MarkFlagRequired("for")
+}
+
+func runWait(cmd *cobra.Command, args []string) error {
+	cond, err := wait.Parse(waitFor)
+	if err != nil {
+		return err
+	}
+	if waitTimeout <= 0 {
+		return fmt.Errorf("--timeout must be positive")
+	}
+	if waitInterval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	// Not being reached in time is not a usage error
+	cmd.SilenceUsage = true
+
+	c := client.New(GetAPIEndpoint())
+	c.SetAuth(GetAuthToken(), GetAPIKey())
+	// A hung request must not outlast the timeout
+	c.SetTimeout(min(waitInterval*5, waitTimeout))
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var progress func(string, error)
+	if IsVerbose() {
+		progress = func(state string, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "waiting for %s: %v\n", cond.Name, err)
+				return
+			}
+			fmt.Fprintf(os.Stderr, "waiting for %s: %s\n", cond.Name, state)
+		}
+	}
+
+	return wait.Until(ctx, c, cond, waitInterval, progress)
+}
@@ -6,13 +6,19 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
 )
 
 // Client is the API client for nrdot-api-server
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	token      string // JWT sent as a bearer token
+	apiKey     string // API key sent in the X-API-Key header
 }
 
 // New creates a new API client
@@ -25,6 +31,17 @@ func New(baseURL string) *Client {
 	}
 }
 
+// SetAuth sets the credentials sent with every request. Either may be empty.
+func (c *Client) SetAuth(token, apiKey string) {
+	c.token = token
+	c.apiKey = apiKey
+}
+
+// SetTimeout sets how long a request may take, including reading the body
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.httpClient.Timeout = timeout
+}
+
 // GetStatus gets the current system status
 func (c *Client) GetStatus() (*Status, error) {
 	var status Status
@@ -32,6 +49,26 @@ func (c *Client) GetStatus() (*Status, error) {
 	return &status, err
 }
 
+// GetHealth gets the health of the agent. An unhealthy agent answering with
+// 503 is reported like a healthy one, not as an error.
+func (c *Client) GetHealth() (*HealthReport, error) {
+	resp, err := c.getRaw("/health")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, apiError(resp)
+	}
+
+	var health HealthReport
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
 // ValidateConfig validates configuration
 func (c *Client) ValidateConfig(config []byte) (*ValidationResult, error) {
 	var result ValidationResult
@@ -50,11 +87,77 @@ func (c *Client) GenerateConfig(config []byte) ([]byte, error) {
 	return io.ReadAll(resp.Body)
 }
 
-// ApplyConfig applies new configuration
-func (c *Client) ApplyConfig(config []byte) (*ApplyResult, error) {
-	var result ApplyResult
-	err := c.post("/api/v1/config/apply", config, &result)
-	return &result, err
+// CheckConfig validates a configuration on the server without applying it
+func (c *Client) CheckConfig(update *ConfigUpdate) (*ConfigResult, error) {
+	dryRun := *update
+	dryRun.DryRun = true
+	return c.postConfigUpdate("/v1/config/validate", &dryRun)
+}
+
+// ApplyConfig validates a configuration and applies it, unless it is a dry
+// run
+func (c *Client) ApplyConfig(update *ConfigUpdate) (*ConfigResult, error) {
+	return c.postConfigUpdate("/v1/config", update)
+}
+
+// GetRunningConfig gets the configuration the agent is running, as decoded
+// JSON
+func (c *Client) GetRunningConfig() (map[string]interface{}, error) {
+	var config map[string]interface{}
+	err := c.get("/v1/config", &config)
+	return config, err
+}
+
+// GetConfigHistory gets the latest limit configuration versions, oldest
+// first
+func (c *Client) GetConfigHistory(limit int) ([]ConfigVersion, error) {
+	var history []ConfigVersion
+	err := c.get(fmt.Sprintf("/v1/config/history?limit=%d", limit), &history)
+	return history, err
+}
+
+// RollbackConfig restores the configuration of version and reloads the
+// collector with it
+func (c *Client) RollbackConfig(version int) (*RollbackResult, error) {
+	data, err := json.Marshal(map[string]int{"version": version})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.postRaw("/v1/config/rollback", data)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Failed rollbacks still report what happened; requests that are
+	// rejected answer with problem details
+	if details, ok := problem.Parse(resp.Header, body); ok {
+		return nil, problemError(details)
+	}
+	var result RollbackResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("API error: %s (status %d)", bytes.TrimSpace(body), resp.StatusCode)
+		}
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		message := http.StatusText(resp.StatusCode)
+		if result.Error != nil {
+			message = result.Error.Message
+			if result.Error.Details != "" {
+				message += ": " + result.Error.Details
+			}
+		}
+		return &result, fmt.Errorf("API error: %s (status %d)", message, resp.StatusCode)
+	}
+	return &result, nil
 }
 
 // StartCollector starts the collector
@@ -78,32 +181,55 @@ func (c *Client) RestartCollector() (*OperationResult, error) {
 	return &result, err
 }
 
-// GetLogs gets recent logs
-func (c *Client) GetLogs(lines int) (string, error) {
-	resp, err := c.getRaw(fmt.Sprintf("/api/v1/collector/logs?lines=%d", lines))
-	if err != nil {
-		return "", err
+// Logs opens a log served by the supervisor. The caller reads the lines
+// from the returned body and closes it; when following, it stays open until
+// closed or the supervisor stops, so the client's timeout must be disabled.
+func (c *Client) Logs(query LogQuery) (io.ReadCloser, error) {
+	params := url.Values{}
+	params.Set("tail", strconv.Itoa(query.Tail))
+	if query.Follow {
+		params.Set("follow", "true")
 	}
-	defer resp.Body.Close()
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	if query.Level != "" {
+		params.Set("level", query.Level)
+	}
+	if query.Since != "" {
+		params.Set("since", query.Since)
+	}
+	if query.Grep != "" {
+		params.Set("grep", query.Grep)
+	}
+	if query.JSON {
+		params.Set("format", "json")
 	}
 
-	return string(data), nil
-}
-
-// StreamLogs streams logs in real-time
-func (c *Client) StreamLogs() (io.ReadCloser, error) {
-	resp, err := c.getRaw("/api/v1/collector/logs?follow=true")
+	resp, err := c.getRaw("/v1/logs/" + url.PathEscape(query.Component) + "?" + params.Encode())
 	if err != nil {
 		return nil, err
 	}
-
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, apiError(resp)
+	}
 	return resp.Body, nil
 }
 
+// Discover runs service discovery on the agent's host
+func (c *Client) Discover() (*DiscoveryResult, error) {
+	var result DiscoveryResult
+	err := c.get("/v1/discovery", &result)
+	return &result, err
+}
+
+// GetCardinalityStats gets the statistics of an nrcap processor, listing
+// its top metrics and label keys, from a client created for the processor's
+// stats endpoint
+func (c *Client) GetCardinalityStats(top int) (*CardinalityStats, error) {
+	var stats CardinalityStats
+	err := c.get(fmt.Sprintf("/stats?top=%d", top), &stats)
+	return &stats, err
+}
+
 // GetMetrics gets current metrics
 func (c *Client) GetMetrics() (*Metrics, error) {
 	var metrics Metrics
@@ -111,8 +237,46 @@ func (c *Client) GetMetrics() (*Metrics, error) {
 	return &metrics, err
 }
 
+// SetSecret stores a service credential in the supervisor's secrets store,
+// optionally validating it against the service first
+func (c *Client) SetSecret(name string, req *SecretSetRequest) (*SecretSetResult, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doRaw("PUT", "/v1/secrets/"+url.PathEscape(name), data)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var result SecretSetResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
 // Helper methods
 
+func (c *Client) postConfigUpdate(path string, update *ConfigUpdate) (*ConfigResult, error) {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ConfigResult
+	if err := c.post(path, data, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
 func (c *Client) get(path string, result interface{}) error {
 	resp, err := c.getRaw(path)
 	if err != nil {
@@ -121,20 +285,14 @@ func (c *Client) get(path string, result interface{}) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: %s (status %d)", string(body), resp.StatusCode)
+		return apiError(resp)
 	}
 
 	return json.NewDecoder(resp.Body).Decode(result)
 }
 
 func (c *Client) getRaw(path string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", c.baseURL+path, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	return c.httpClient.Do(req)
+	return c.doRaw("GET", path, nil)
 }
 
 func (c *Client) post(path string, data []byte, result interface{}) error {
@@ -145,8 +303,7 @@ func (c *Client) post(path string, data []byte, result interface{}) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: %s (status %d)", string(body), resp.StatusCode)
+		return apiError(resp)
 	}
 
 	if result != nil {
@@ -156,13 +313,32 @@ func (c *Client) post(path string, data []byte, result interface{}) error {
 	return nil
 }
 
+// apiError returns the error of a failed request: the detail of the problem
+// the API answered with, or the body of agents answering in plain text
+func apiError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if details, ok := problem.Parse(resp.Header, body); ok {
+		return problemError(details)
+	}
+	return fmt.Errorf("API error: %s (status %d)", bytes.TrimSpace(body), resp.StatusCode)
+}
+
+// problemError formats the problem details of a failed request
+func problemError(details *problem.Details) error {
+	return fmt.Errorf("API error: %s (status %d, %s)", details.Error(), details.Status, details.Code)
+}
+
 func (c *Client) postRaw(path string, data []byte) (*http.Response, error) {
+	return c.doRaw("POST", path, data)
+}
+
+func (c *Client) doRaw(method, path string, data []byte) (*http.Response, error) {
 	var body io.Reader
 	if data != nil {
 		body = bytes.NewReader(data)
 	}
 
-	req, err := http.NewRequest("POST", c.baseURL+path, body)
+	req, err := http.NewRequest(method, c.baseURL+path, body)
 	if err != nil {
 		return nil, err
 	}
@@ -170,6 +346,12 @@ func (c *Client) postRaw(path string, data []byte) (*http.Response, error) {
 	if data != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
 
 	return c.httpClient.Do(req)
 }
\ No newline at end of file
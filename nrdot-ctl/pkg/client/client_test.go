@@ -0,0 +1,330 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+)
+
+func TestSetSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/v1/secrets/MYSQL_MONITOR_PASS" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer jwt-token" || r.Header.Get("X-API-Key") != "key" {
+			t.Errorf("Missing credentials: %v", r.Header)
+		}
+
+		var req SecretSetRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if req.Value != "s3cret" || req.Validate == nil || req.Validate.Service != "mysql" {
+			t.Errorf("Unexpected request body: %+v", req)
+		}
+
+		if req.Validate.Username != "newrelic" {
+			problem.Error(w, r, "credential validation failed: access denied", http.StatusUnprocessableEntity)
+			return
+		}
+		json.NewEncoder(w).Encode(SecretSetResult{Name: "MYSQL_MONITOR_PASS", Validated: true})
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	c.SetAuth("jwt-token", "key")
+
+	req := &SecretSetRequest{
+		Value:    "s3cret",
+		Validate: &SecretValidation{Service: "mysql", Endpoint: "localhost:3306", Username: "newrelic"},
+	}
+	result, err := c.SetSecret("MYSQL_MONITOR_PASS", req)
+	if err != nil {
+		t.Fatalf("SetSecret failed: %v", err)
+	}
+	if !result.Validated {
+		t.Errorf("Expected validated result, got %+v", result)
+	}
+
+	req.Validate.Username = "root"
+	_, err = c.SetSecret("MYSQL_MONITOR_PASS", req)
+	if err == nil || err.Error() != "API error: credential validation failed: access denied (status 422, UNPROCESSABLE_ENTITY)" {
+		t.Errorf("Expected the problem's detail, got %v", err)
+	}
+}
+
+func TestGetConfigHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/v1/config/history" || r.URL.Query().Get("limit") != "2" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+		json.NewEncoder(w).Encode([]ConfigVersion{
+			{Version: 4, Source: "api"},
+			{Version: 5, Source: "rollback", Description: "rollback to version 3"},
+		})
+	}))
+	defer server.Close()
+
+	history, err := New(server.URL).GetConfigHistory(2)
+	if err != nil {
+		t.Fatalf("GetConfigHistory failed: %v", err)
+	}
+	if len(history) != 2 || history[1].Version != 5 || history[1].Source != "rollback" {
+		t.Errorf("Unexpected history: %+v", history)
+	}
+}
+
+func TestRollbackConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/config/rollback" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+
+		var req map[string]int
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if req["version"] != 3 {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(RollbackResult{
+				ToVersion: req["version"],
+				Error:     &ErrorInfo{Code: "VERSION_NOT_FOUND", Message: "config version not found", Details: "version 9"},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(RollbackResult{Success: true, FromVersion: 5, ToVersion: 3, Version: 6})
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	result, err := c.RollbackConfig(3)
+	if err != nil {
+		t.Fatalf("RollbackConfig failed: %v", err)
+	}
+	if !result.Success || result.Version != 6 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	result, err = c.RollbackConfig(9)
+	if err == nil || !strings.Contains(err.Error(), "config version not found: version 9") || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected version not found error, got %v", err)
+	}
+	if result == nil || result.Error == nil || result.Error.Code != "VERSION_NOT_FOUND" {
+		t.Errorf("Expected the failed result to be returned, got %+v", result)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/v1/discovery" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+		w.Write([]byte(`{
+			"discovery_id": "550e8400-e29b-41d4-a716-446655440000",
+			"timestamp": "2024-01-15T10:30:00Z",
+			"discovered_services": [{
+				"type": "mysql",
+				"version": "8.0.32",
+				"endpoints": [{"address": "localhost", "port": 3306, "protocol": "tcp"}],
+				"confidence": "HIGH",
+				"discovered_by": ["process", "port", "config_file"]
+			}],
+			"scan_duration_ms": 450
+		}`))
+	}))
+	defer server.Close()
+
+	result, err := New(server.URL).Discover()
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if result.ScanDurationMs != 450 || len(result.DiscoveredServices) != 1 {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+	service := result.DiscoveredServices[0]
+	if service.Type != "mysql" || service.Endpoints[0].Port != 3306 || len(service.DiscoveredBy) != 3 {
+		t.Errorf("Unexpected service: %+v", service)
+	}
+}
+
+func TestConfigUpdates(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == "/v1/config" {
+			w.Write([]byte(`{"version": 3, "service": {"name": "web"}}`))
+			return
+		}
+		paths = append(paths, r.URL.Path)
+
+		var update ConfigUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if update.Format != "yaml" || update.Source != "cli" {
+			t.Errorf("Unexpected request body: %+v", update)
+		}
+		if r.URL.Path == "/v1/config/validate" && !update.DryRun {
+			t.Error("Expected validation to be a dry run")
+		}
+
+		if strings.Contains(string(update.Config), "invalid") {
+			json.NewEncoder(w).Encode(ConfigResult{
+				Validation: &ConfigValidation{Errors: []ConfigValidationError{{Path: "/", Message: "bad", Code: "VALIDATION_FAILED"}}},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(ConfigResult{Success: true, Version: 4, Validation: &ConfigValidation{Valid: true}})
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	update := &ConfigUpdate{Config: []byte("service:\n  name: api\n"), Format: "yaml", Source: "cli"}
+
+	result, err := c.CheckConfig(update)
+	if err != nil {
+		t.Fatalf("CheckConfig failed: %v", err)
+	}
+	if !result.Validation.Valid || update.DryRun {
+		t.Errorf("Unexpected validation %+v of update %+v", result.Validation, update)
+	}
+
+	result, err = c.ApplyConfig(update)
+	if err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if !result.Success || result.Version != 4 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	update.Config = []byte("invalid")
+	result, err = c.CheckConfig(update)
+	if err != nil {
+		t.Fatalf("CheckConfig failed: %v", err)
+	}
+	if result.Validation.Valid || len(result.Validation.Errors) != 1 {
+		t.Errorf("Expected validation errors, got %+v", result.Validation)
+	}
+
+	running, err := c.GetRunningConfig()
+	if err != nil {
+		t.Fatalf("GetRunningConfig failed: %v", err)
+	}
+	if running["service"].(map[string]interface{})["name"] != "web" {
+		t.Errorf("Unexpected running config: %v", running)
+	}
+
+	if strings.Join(paths, ",") != "/v1/config/validate,/v1/config,/v1/config/validate" {
+		t.Errorf("Unexpected requests: %v", paths)
+	}
+}
+
+func TestGetCardinalityStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stats" || r.URL.Query().Get("top") != "5" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"processor": "nrcap", "global_cardinality": 5230, "global_limit": 100000,
+			"dropped_metrics": 1200,
+			"metrics": [{"name": "http_requests_total", "cardinality": 4100, "limit": 10000, "strategy": "drop"}],
+			"labels": [{"key": "path", "distinct_values": 2050}]}`))
+	}))
+	defer server.Close()
+
+	stats, err := New(server.URL).GetCardinalityStats(5)
+	if err != nil {
+		t.Fatalf("GetCardinalityStats failed: %v", err)
+	}
+	if stats.GlobalCardinality != 5230 || stats.DroppedMetrics != 1200 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if len(stats.Metrics) != 1 || stats.Metrics[0].Limit != 10000 || len(stats.Labels) != 1 || stats.Labels[0].DistinctValues != 2050 {
+		t.Errorf("Unexpected metrics or labels: %+v", stats)
+	}
+}
+
+func TestLogs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/logs/agent" {
+			http.Error(w, "404 page not found", http.StatusNotFound)
+			return
+		}
+		if r.Method != "GET" || r.URL.Path != "/v1/logs/supervisor" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		query := r.URL.Query()
+		if query.Get("tail") != "50" || query.Get("follow") != "true" || query.Get("since") != "10m" ||
+			query.Get("grep") != "reload|restart" || query.Get("level") != "warn" || query.Get("format") != "json" {
+			t.Errorf("Unexpected query: %s", r.URL.RawQuery)
+		}
+		w.Write([]byte("{\"message\":\"Reloading collector\"}\n"))
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	body, err := c.Logs(LogQuery{
+		Component: "supervisor",
+		Tail:      50,
+		Follow:    true,
+		Level:     "warn",
+		Since:     "10m",
+		Grep:      "reload|restart",
+		JSON:      true,
+	})
+	if err != nil {
+		t.Fatalf("Logs failed: %v", err)
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil || string(data) != "{\"message\":\"Reloading collector\"}\n" {
+		t.Errorf("Unexpected body %q (%v)", data, err)
+	}
+
+	if _, err := c.Logs(LogQuery{Component: "agent"}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestGetHealth(t *testing.T) {
+	healthy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("X-API-Key") != "key" {
+			t.Errorf("Missing credentials: %v", r.Header)
+		}
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"unhealthy","checks":{"collector":"unhealthy","api":"healthy"}}`))
+			return
+		}
+		w.Write([]byte(`{"status":"healthy","checks":{"collector":"healthy","api":"healthy"}}`))
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	c.SetAuth("", "key")
+
+	health, err := c.GetHealth()
+	if err != nil {
+		t.Fatalf("GetHealth failed: %v", err)
+	}
+	if health.Status != "healthy" || health.Checks["collector"] != "healthy" {
+		t.Errorf("Unexpected health: %+v", health)
+	}
+
+	// An unhealthy agent is a result, not an error
+	healthy = false
+	health, err = c.GetHealth()
+	if err != nil {
+		t.Fatalf("GetHealth failed for an unhealthy agent: %v", err)
+	}
+	if health.Status != "unhealthy" || health.Checks["collector"] != "unhealthy" {
+		t.Errorf("Unexpected health: %+v", health)
+	}
+}
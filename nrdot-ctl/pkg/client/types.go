@@ -48,13 +48,39 @@ type OperationResult struct {
 	Error   string `json:"error,omitempty"`
 }
 
-// ApplyResult represents the result of applying configuration
-type ApplyResult struct {
-	Success       bool   `json:"success"`
-	Message       string `json:"message"`
-	PreviousVersion string `json:"previous_version"`
-	NewVersion    string `json:"new_version"`
-	Error         string `json:"error,omitempty"`
+// ConfigUpdate is a configuration submitted to the API for validation or
+// to be applied
+type ConfigUpdate struct {
+	Config []byte `json:"config"`
+	Format string `json:"format"` // yaml, json
+	DryRun bool   `json:"dry_run"`
+	Source string `json:"source"`
+}
+
+// ConfigResult represents the result of validating or applying a
+// configuration. An invalid configuration is reported with Success false
+// and the validation errors.
+type ConfigResult struct {
+	Success    bool              `json:"success"`
+	Version    int               `json:"version,omitempty"`
+	Validation *ConfigValidation `json:"validation,omitempty"`
+	Error      *ErrorInfo        `json:"error,omitempty"`
+	Warnings   []string          `json:"warnings,omitempty"`
+}
+
+// ConfigValidation represents the server-side validation of a configuration
+type ConfigValidation struct {
+	Valid    bool                    `json:"valid"`
+	Errors   []ConfigValidationError `json:"errors,omitempty"`
+	Warnings []string                `json:"warnings,omitempty"`
+	Info     []string                `json:"info,omitempty"`
+}
+
+// ConfigValidationError represents a server-side validation error
+type ConfigValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+	Code    string `json:"code"`
 }
 
 // Metrics represents system metrics
@@ -81,4 +107,146 @@ type PipelineMetric struct {
 	Sent     int64   `json:"sent"`
 	Dropped  int64   `json:"dropped"`
 	Errors   int64   `json:"errors"`
-}
\ No newline at end of file
+}
+
+// SecretSetRequest is the body of a secret update. The value never leaves
+// the supervisor again.
+type SecretSetRequest struct {
+	Value    string            `json:"value"`
+	Validate *SecretValidation `json:"validate,omitempty"`
+}
+
+// SecretValidation names the service a secret is checked against before it
+// is stored
+type SecretValidation struct {
+	Service  string `json:"service"`
+	Endpoint string `json:"endpoint"`
+	Username string `json:"username,omitempty"`
+	Database string `json:"database,omitempty"`
+}
+
+// SecretSetResult represents the result of storing a secret
+type SecretSetResult struct {
+	Name      string    `json:"name"`
+	Validated bool      `json:"validated"`
+	Message   string    `json:"message"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConfigVersion represents one applied configuration version
+type ConfigVersion struct {
+	Version     int       `json:"version"`
+	AppliedAt   time.Time `json:"applied_at"`
+	Source      string    `json:"source"`
+	Author      string    `json:"author,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Hash        string    `json:"hash"`
+}
+
+// RollbackResult represents the result of a configuration rollback. The
+// restored config is recorded as a new version.
+type RollbackResult struct {
+	Success     bool       `json:"success"`
+	FromVersion int        `json:"from_version"`
+	ToVersion   int        `json:"to_version"`
+	Version     int        `json:"version"`
+	Error       *ErrorInfo `json:"error,omitempty"`
+}
+
+// ErrorInfo represents an error reported by the API
+type ErrorInfo struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details string `json:"details,omitempty"`
+}
+
+// DiscoveryResult represents the result of a service discovery scan
+type DiscoveryResult struct {
+	DiscoveryID        string              `json:"discovery_id"`
+	Timestamp          time.Time           `json:"timestamp"`
+	DiscoveredServices []DiscoveredService `json:"discovered_services"`
+	ScanDurationMs     int64               `json:"scan_duration_ms"`
+}
+
+// DiscoveredService represents a service found on the host
+type DiscoveredService struct {
+	Type         string            `json:"type"`
+	Version      string            `json:"version,omitempty"`
+	Endpoints    []ServiceEndpoint `json:"endpoints"`
+	DiscoveredBy []string          `json:"discovered_by"`
+	Confidence   string            `json:"confidence"`
+}
+
+// ServiceEndpoint represents an address a discovered service listens on
+type ServiceEndpoint struct {
+	Address  string `json:"address"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// CardinalityStats is a snapshot of an nrcap processor's cardinality, served
+// on its stats endpoint. Counters are cumulative since the collector
+// started.
+type CardinalityStats struct {
+	Processor   string    `json:"processor"`
+	GeneratedAt time.Time `json:"generated_at"`
+	LastReset   time.Time `json:"last_reset"`
+
+	GlobalCardinality int `json:"global_cardinality"`
+	GlobalLimit       int `json:"global_limit"`
+
+	TotalMetrics        int64 `json:"total_metrics"`
+	DroppedMetrics      int64 `json:"dropped_metrics"`
+	AggregatedMetrics   int64 `json:"aggregated_metrics"`
+	SampledMetrics      int64 `json:"sampled_metrics"`
+	ChurnDroppedMetrics int64 `json:"churn_dropped_metrics"`
+	LabelLimitedMetrics int64 `json:"label_limited_metrics"`
+
+	Metrics []MetricCardinality `json:"metrics"`
+	Labels  []LabelCardinality  `json:"labels"`
+}
+
+// MetricCardinality is the cardinality of one metric against its limit
+type MetricCardinality struct {
+	Name        string `json:"name"`
+	Cardinality int    `json:"cardinality"`
+	Limit       int    `json:"limit"`
+	Strategy    string `json:"strategy"`
+}
+
+// LabelCardinality is the distinct values of one label key
+type LabelCardinality struct {
+	Key            string `json:"key"`
+	DistinctValues int    `json:"distinct_values"`
+	Limit          int    `json:"limit,omitempty"`
+}
+
+// LogQuery selects the lines of a log served by the supervisor
+type LogQuery struct {
+	// Component is the log to read: collector or supervisor
+	Component string
+
+	// Tail is how many recent lines passing the filters to return
+	Tail int
+
+	// Follow keeps streaming lines as they are logged
+	Follow bool
+
+	// Level is the minimum severity, e.g. warn
+	Level string
+
+	// Since is a duration back from now, e.g. 10m, or an RFC 3339 time
+	Since string
+
+	// Grep is a regular expression the lines must match
+	Grep string
+
+	// JSON returns one JSON object per line instead of the raw lines
+	JSON bool
+}
+
+// HealthReport represents the health of an agent
+type HealthReport struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Context is a named agent the CLI talks to: the API endpoint of its
+// supervisor and the credentials for it
+type Context struct {
+	Name   string `yaml:"name" json:"name"`
+	Server string `yaml:"server" json:"server"`
+	Token  string `yaml:"token,omitempty" json:"-"`
+	APIKey string `yaml:"api_key,omitempty" json:"-"`
+}
+
+// Contexts are the agents the CLI manages, kept in ~/.nrdot/config
+type Contexts struct {
+	CurrentContext string    `yaml:"current_context,omitempty"`
+	Contexts       []Context `yaml:"contexts"`
+}
+
+// LoadContexts loads contexts from file; a missing file has none
+func LoadContexts(path string) (*Contexts, error) {
+	contexts := &Contexts{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return contexts, nil
+		}
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, contexts); err != nil {
+		return nil, fmt.Errorf("invalid contexts file %s: %w", path, err)
+	}
+	return contexts, nil
+}
+
+// Save saves contexts to file, readable by the user only as it holds
+// credentials
+func (c *Contexts) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0600)
+}
+
+// Get returns the context called name
+func (c *Contexts) Get(name string) (*Context, bool) {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			return &c.Contexts[i], true
+		}
+	}
+	return nil, false
+}
+
+// Current returns the current context, nil if none is set
+func (c *Contexts) Current() *Context {
+	if c.CurrentContext == "" {
+		return nil
+	}
+	context, _ := c.Get(c.CurrentContext)
+	return context
+}
+
+// Set adds context, replacing the one with the same name, keeping contexts
+// sorted by name
+func (c *Contexts) Set(context Context) {
+	if existing, ok := c.Get(context.Name); ok {
+		*existing = context
+		return
+	}
+	c.Contexts = append(c.Contexts, context)
+	sort.Slice(c.Contexts, func(i, j int) bool { return c.Contexts[i].Name < c.Contexts[j].Name })
+}
+
+// Delete removes the context called name, unsetting it if current, and
+// reports whether it existed
+func (c *Contexts) Delete(name string) bool {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			c.Contexts = append(c.Contexts[:i], c.Contexts[i+1:]...)
+			if c.CurrentContext == name {
+				c.CurrentContext = ""
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// GetContextsPath returns the default contexts path
+func GetContextsPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".nrdot", "config")
+	}
+	return filepath.Join(home, ".nrdot", "config")
+}
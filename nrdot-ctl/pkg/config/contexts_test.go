@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestContexts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".nrdot", "config")
+
+	contexts, err := LoadContexts(path)
+	if err != nil {
+		t.Fatalf("LoadContexts failed for a missing file: %v", err)
+	}
+	if len(contexts.Contexts) != 0 || contexts.Current() != nil {
+		t.Errorf("Expected no contexts, got %+v", contexts)
+	}
+
+	contexts.Set(Context{Name: "prod-host-2", Server: "https://prod-host-2:8080", APIKey: "key"})
+	contexts.Set(Context{Name: "prod-host-1", Server: "https://prod-host-1:8080"})
+	contexts.Set(Context{Name: "prod-host-2", Server: "https://prod-host-2:9090", Token: "jwt"})
+	contexts.CurrentContext = "prod-host-2"
+
+	if err := contexts.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 for a file holding credentials, got %v", info.Mode().Perm())
+	}
+
+	loaded, err := LoadContexts(path)
+	if err != nil {
+		t.Fatalf("LoadContexts failed: %v", err)
+	}
+	if len(loaded.Contexts) != 2 || loaded.Contexts[0].Name != "prod-host-1" || loaded.Contexts[1].Name != "prod-host-2" {
+		t.Fatalf("Expected 2 contexts sorted by name, got %+v", loaded.Contexts)
+	}
+	current := loaded.Current()
+	if current == nil || current.Server != "https://prod-host-2:9090" || current.Token != "jwt" || current.APIKey != "" {
+		t.Errorf("Expected the replaced prod-host-2 as current, got %+v", current)
+	}
+
+	if !loaded.Delete("prod-host-2") {
+		t.Error("Expected prod-host-2 to be deleted")
+	}
+	if loaded.Delete("prod-host-2") {
+		t.Error("Expected a second delete to report a missing context")
+	}
+	if loaded.CurrentContext != "" || loaded.Current() != nil {
+		t.Errorf("Expected deleting the current context to unset it, got %q", loaded.CurrentContext)
+	}
+	if _, ok := loaded.Get("prod-host-1"); !ok {
+		t.Error("Expected prod-host-1 to remain")
+	}
+
+	if err := os.WriteFile(path, []byte("contexts: {"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadContexts(path); err == nil {
+		t.Error("Expected an error for an invalid file")
+	}
+}
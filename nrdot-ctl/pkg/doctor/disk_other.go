@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd
+
+package doctor
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// availableBytes is not implemented on this platform
+func availableBytes(dir string) (uint64, error) {
+	return 0, fmt.Errorf("disk checks are not supported on this platform")
+}
+
+// socketOwner is not implemented on this platform
+func socketOwner(info fs.FileInfo) string {
+	return ""
+}
@@ -0,0 +1,398 @@
+// Package doctor checks that a host can run NRDOT: the collector binary, the
+// configuration, the agent API, the license key, connectivity to New Relic,
+// the privileged helper and the work directory.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+	"gopkg.in/yaml.v3"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Check is the result of one check, with a hint on how to fix it unless it
+// passed
+type Check struct {
+	Name    string `json:"name" yaml:"name"`
+	Status  Status `json:"status" yaml:"status"`
+	Message string `json:"message" yaml:"message"`
+	Hint    string `json:"hint,omitempty" yaml:"hint,omitempty"`
+}
+
+// Report is the result of all checks
+type Report struct {
+	Checks []Check `json:"checks" yaml:"checks"`
+}
+
+// Count returns the number of checks with the given status
+func (r *Report) Count(status Status) int {
+	n := 0
+	for _, check := range r.Checks {
+		if check.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Options configures the checks
+type Options struct {
+	// CollectorPath is the collector binary, a path or a name looked up in
+	// PATH
+	CollectorPath string
+
+	// ConfigPath is the NRDOT configuration file
+	ConfigPath string
+
+	// WorkDir is the agent's work directory, whose filesystem needs
+	// MinFreeBytes available
+	WorkDir      string
+	MinFreeBytes uint64
+
+	// HelperSocket is the privileged helper's Unix socket
+	HelperSocket string
+
+	// Endpoints are the New Relic host:port addresses that must be
+	// reachable
+	Endpoints []string
+
+	// Client reaches the agent API
+	Client *client.Client
+
+	// Timeout bounds each check that runs a command or dials out
+	Timeout time.Duration
+}
+
+// DefaultOptions returns the options for a standard installation
+func DefaultOptions() Options {
+	return Options{
+		CollectorPath: "/usr/bin/otelcol-nrdot",
+		ConfigPath:    "/etc/nrdot/config.yaml",
+		WorkDir:       "/var/lib/nrdot",
+		MinFreeBytes:  512 << 20,
+		HelperSocket:  "/var/run/nrdot/privileged-helper.sock",
+		Endpoints:     []string{"otlp.nr-data.net:4317", "otlp.nr-data.net:443"},
+		Timeout:       5 * time.Second,
+	}
+}
+
+// Run runs all checks in order
+func Run(ctx context.Context, opts Options) *Report {
+	report := &Report{}
+	report.Checks = append(report.Checks, CheckCollector(ctx, opts.CollectorPath, opts.Timeout))
+
+	configData, configCheck := readConfig(opts.ConfigPath)
+	if configData != nil {
+		configCheck = CheckConfig(opts.Client, opts.ConfigPath, configData)
+	}
+	report.Checks = append(report.Checks, configCheck)
+
+	report.Checks = append(report.Checks,
+		CheckAPI(opts.Client),
+		CheckLicenseKey(configData, os.LookupEnv),
+	)
+	for _, endpoint := range opts.Endpoints {
+		report.Checks = append(report.Checks, CheckConnectivity(ctx, endpoint, opts.Timeout))
+	}
+	report.Checks = append(report.Checks,
+		CheckHelperSocket(opts.HelperSocket),
+		CheckDiskSpace(opts.WorkDir, opts.MinFreeBytes),
+	)
+	return report
+}
+
+// CheckCollector checks that the collector binary exists and reports its
+// version
+func CheckCollector(ctx context.Context, path string, timeout time.Duration) Check {
+	check := Check{Name: "collector binary"}
+
+	binary, err := exec.LookPath(path)
+	if err != nil {
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("%s not found", path)
+		check.Hint = "Install the nrdot-host package, or pass the collector's path with --collector"
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, binary, "--version").Output()
+	if err != nil {
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("%s --version failed: %v", binary, err)
+		check.Hint = "Reinstall the nrdot-host package; the binary may be corrupt or built for another platform"
+		return check
+	}
+
+	version := strings.TrimSpace(string(out))
+	if i := strings.IndexByte(version, '\n'); i >= 0 {
+		version = version[:i]
+	}
+	check.Status = StatusPass
+	check.Message = fmt.Sprintf("%s: %s", binary, version)
+	return check
+}
+
+// readConfig reads the configuration file, returning a failed check if it
+// cannot be read
+func readConfig(path string) ([]byte, Check) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		check := Check{Name: "configuration", Status: StatusFail}
+		if errors.Is(err, fs.ErrNotExist) {
+			check.Message = fmt.Sprintf("%s not found", path)
+			check.Hint = "Create the configuration, or pass its path with --file"
+		} else {
+			check.Message = fmt.Sprintf("cannot read %s: %v", path, err)
+			check.Hint = "Run as root or as a member of the nrdot group"
+		}
+		return nil, check
+	}
+	return data, Check{}
+}
+
+// CheckConfig validates the configuration with the agent API. Without an
+// agent to ask, only the YAML syntax is checked.
+func CheckConfig(c *client.Client, path string, data []byte) Check {
+	check := Check{Name: "configuration"}
+
+	var parsed map[string]interface{}
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("%s is not valid YAML: %v", path, err)
+		check.Hint = "Fix the syntax error, then run 'nrdot-ctl config validate -f " + path + "'"
+		return check
+	}
+
+	var result *client.ValidationResult
+	err := fmt.Errorf("no API endpoint configured")
+	if c != nil {
+		result, err = c.ValidateConfig(data)
+	}
+	if err != nil {
+		check.Status = StatusWarn
+		check.Message = fmt.Sprintf("%s is valid YAML; not validated by the agent: %v", path, err)
+		check.Hint = "Start the agent and run 'nrdot-ctl config validate -f " + path + "'"
+		return check
+	}
+
+	if !result.Valid {
+		var problems []string
+		for _, e := range result.Errors {
+			problems = append(problems, fmt.Sprintf("%s: %s", e.Field, e.Message))
+		}
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("%s is invalid: %s", path, strings.Join(problems, "; "))
+		check.Hint = "Run 'nrdot-ctl config validate -f " + path + "' for details"
+		return check
+	}
+	if len(result.Warnings) > 0 {
+		check.Status = StatusWarn
+		check.Message = fmt.Sprintf("%s is valid with warnings: %s", path, strings.Join(result.Warnings, "; "))
+		check.Hint = "Review the warnings with 'nrdot-ctl config validate -f " + path + "'"
+		return check
+	}
+	check.Status = StatusPass
+	check.Message = fmt.Sprintf("%s is valid", path)
+	return check
+}
+
+// CheckAPI checks that the agent API answers and the agent is healthy
+func CheckAPI(c *client.Client) Check {
+	check := Check{Name: "agent API"}
+	if c == nil {
+		check.Status = StatusWarn
+		check.Message = "no API endpoint configured"
+		check.Hint = "Pass the agent's endpoint with --api-endpoint or --context"
+		return check
+	}
+
+	health, err := c.GetHealth()
+	if err != nil {
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("unreachable: %v", err)
+		check.Hint = "Start the agent with 'sudo systemctl start nrdot-host' and check --api-endpoint and credentials"
+		return check
+	}
+
+	switch health.Status {
+	case "healthy":
+		check.Status = StatusPass
+		check.Message = "agent is healthy"
+	default:
+		check.Status = StatusWarn
+		check.Message = fmt.Sprintf("agent is %s", health.Status)
+		check.Hint = "Run 'nrdot-ctl health' for the failing checks"
+	}
+	return check
+}
+
+// licenseKeyPattern is the format of a New Relic ingest license key: 40
+// characters, the last four "NRAL" for current keys
+var licenseKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]{40}$`)
+
+// envReference matches a license key given as a variable reference
+var envReference = regexp.MustCompile(`^\$\{(?:env:)?([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// CheckLicenseKey checks the format of the license key of the configuration,
+// resolving a ${VAR} reference through lookup, or of NEW_RELIC_LICENSE_KEY if
+// the configuration sets none
+func CheckLicenseKey(configData []byte, lookup func(string) (string, bool)) Check {
+	check := Check{Name: "license key"}
+
+	var config struct {
+		LicenseKey string `yaml:"license_key"`
+	}
+	yaml.Unmarshal(configData, &config)
+
+	key, source := config.LicenseKey, "license_key"
+	if key == "" {
+		key = "${NEW_RELIC_LICENSE_KEY}"
+	}
+	if m := envReference.FindStringSubmatch(key); m != nil {
+		source = m[1]
+		key, _ = lookup(m[1])
+	}
+
+	switch {
+	case key == "":
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("%s is not set", source)
+		check.Hint = "Set NEW_RELIC_LICENSE_KEY in /etc/nrdot/nrdot.conf, or license_key in the configuration"
+	case !licenseKeyPattern.MatchString(key):
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("%s is not a license key: expected 40 letters and digits, got %d characters", source, len(key))
+		check.Hint = "Copy an ingest license key from the New Relic API keys page; user and browser keys are not accepted"
+	case !strings.HasSuffix(key, "NRAL"):
+		check.Status = StatusWarn
+		check.Message = fmt.Sprintf("%s has the format of a legacy license key", source)
+		check.Hint = "Legacy keys work, but consider an ingest license key ending in NRAL"
+	default:
+		check.Status = StatusPass
+		check.Message = fmt.Sprintf("%s is well formed", source)
+	}
+	return check
+}
+
+// CheckConnectivity checks that a TCP connection to address can be opened
+func CheckConnectivity(ctx context.Context, address string, timeout time.Duration) Check {
+	check := Check{Name: "connectivity " + address}
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("cannot connect: %v", err)
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) {
+			check.Hint = "Check the host's DNS resolver in /etc/resolv.conf"
+		} else {
+			check.Hint = "Allow outbound TCP to " + address + " in the firewall, or set HTTPS_PROXY for the agent"
+		}
+		return check
+	}
+	conn.Close()
+
+	check.Status = StatusPass
+	check.Message = "reachable"
+	return check
+}
+
+// CheckHelperSocket checks the privileged helper's socket: it must exist, be
+// a socket, and not be writable by other users, which would let any local
+// user run the helper's privileged operations
+func CheckHelperSocket(path string) Check {
+	check := Check{Name: "privileged helper"}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		check.Status = StatusWarn
+		check.Message = fmt.Sprintf("%s not found; process metrics of other users are not collected", path)
+		check.Hint = "Start the helper with 'sudo systemctl start nrdot-privileged-helper'"
+		return check
+	}
+	if err != nil {
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("cannot stat %s: %v", path, err)
+		check.Hint = "Run as root or as a member of the nrdot group"
+		return check
+	}
+
+	mode := info.Mode()
+	switch {
+	case mode&fs.ModeSocket == 0:
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("%s is not a socket (%s)", path, mode)
+		check.Hint = "Remove the file and restart nrdot-privileged-helper"
+	case mode.Perm()&0002 != 0:
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("%s is writable by all users (%s)", path, mode.Perm())
+		check.Hint = "Run 'sudo chmod 0660 " + path + "' and restart nrdot-privileged-helper"
+	default:
+		check.Status = StatusPass
+		check.Message = fmt.Sprintf("%s (%s%s)", path, mode.Perm(), socketOwner(info))
+	}
+	return check
+}
+
+// CheckDiskSpace checks that the filesystem of dir has minFree bytes
+// available
+func CheckDiskSpace(dir string, minFree uint64) Check {
+	check := Check{Name: "disk space"}
+
+	available, err := availableBytes(dir)
+	if err != nil {
+		check.Status = StatusFail
+		check.Message = err.Error()
+		check.Hint = "Create the work directory, or pass it with --workdir"
+		return check
+	}
+
+	message := fmt.Sprintf("%s available in %s", formatBytes(available), dir)
+	switch {
+	case available < minFree:
+		check.Status = StatusFail
+		check.Message = message
+		check.Hint = fmt.Sprintf("Free at least %s; the agent buffers data and keeps config history there", formatBytes(minFree))
+	case available < 2*minFree:
+		check.Status = StatusWarn
+		check.Message = message
+		check.Hint = "Free space, the agent may run out when buffering during an outage"
+	default:
+		check.Status = StatusPass
+		check.Message = message
+	}
+	return check
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,176 @@
+package doctor
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+)
+
+func TestCheckLicenseKey(t *testing.T) {
+	current := strings.Repeat("a", 36) + "NRAL"
+	legacy := strings.Repeat("0123456789", 4)
+	env := map[string]string{"NR_KEY": current, "SHORT_KEY": "abc"}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		name   string
+		config string
+		status Status
+	}{
+		{"literal key", "license_key: " + current, StatusPass},
+		{"legacy key", "license_key: " + legacy, StatusWarn},
+		{"malformed key", "license_key: not-a-key", StatusFail},
+		{"variable reference", "license_key: ${NR_KEY}", StatusPass},
+		{"env reference", "license_key: ${env:SHORT_KEY}", StatusFail},
+		{"unset variable", "license_key: ${MISSING}", StatusFail},
+		{"default variable unset", "service:\n  name: test", StatusFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := CheckLicenseKey([]byte(tt.config), lookup)
+			if check.Status != tt.status {
+				t.Errorf("Expected %s, got %s: %s", tt.status, check.Status, check.Message)
+			}
+			if check.Status != StatusPass && check.Hint == "" {
+				t.Error("Expected a hint")
+			}
+			if strings.Contains(check.Message, current) {
+				t.Error("License key leaked into the message")
+			}
+		})
+	}
+
+	env["NEW_RELIC_LICENSE_KEY"] = current
+	if check := CheckLicenseKey(nil, lookup); check.Status != StatusPass {
+		t.Errorf("Expected NEW_RELIC_LICENSE_KEY without a config to pass, got %s: %s", check.Status, check.Message)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	valid := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/config/validate" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if valid {
+			w.Write([]byte(`{"valid":true}`))
+			return
+		}
+		w.Write([]byte(`{"valid":false,"errors":[{"field":"service.name","message":"required"}]}`))
+	}))
+	defer server.Close()
+	c := client.New(server.URL)
+
+	if check := CheckConfig(c, "config.yaml", []byte("service:\n  name: test\n")); check.Status != StatusPass {
+		t.Errorf("Expected pass, got %s: %s", check.Status, check.Message)
+	}
+
+	valid = false
+	check := CheckConfig(c, "config.yaml", []byte("service: {}\n"))
+	if check.Status != StatusFail || !strings.Contains(check.Message, "service.name: required") {
+		t.Errorf("Expected failure naming the field, got %s: %s", check.Status, check.Message)
+	}
+
+	if check := CheckConfig(c, "config.yaml", []byte("service: [")); check.Status != StatusFail {
+		t.Errorf("Expected invalid YAML to fail, got %s: %s", check.Status, check.Message)
+	}
+
+	// Without the agent only the syntax is checked
+	server.Close()
+	if check := CheckConfig(c, "config.yaml", []byte("service: {}\n")); check.Status != StatusWarn {
+		t.Errorf("Expected warning without the agent, got %s: %s", check.Status, check.Message)
+	}
+}
+
+func TestCheckAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status":"unhealthy"}`))
+	}))
+
+	if check := CheckAPI(client.New(server.URL)); check.Status != StatusWarn {
+		t.Errorf("Expected warning for an unhealthy agent, got %s: %s", check.Status, check.Message)
+	}
+
+	server.Close()
+	if check := CheckAPI(client.New(server.URL)); check.Status != StatusFail {
+		t.Errorf("Expected failure for an unreachable agent, got %s: %s", check.Status, check.Message)
+	}
+}
+
+func TestCheckConnectivity(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+
+	if check := CheckConnectivity(context.Background(), address, DefaultOptions().Timeout); check.Status != StatusPass {
+		t.Errorf("Expected pass, got %s: %s", check.Status, check.Message)
+	}
+
+	listener.Close()
+	if check := CheckConnectivity(context.Background(), address, DefaultOptions().Timeout); check.Status != StatusFail {
+		t.Errorf("Expected failure, got %s: %s", check.Status, check.Message)
+	}
+}
+
+func TestCheckHelperSocket(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "helper.sock")
+
+	if check := CheckHelperSocket(path); check.Status != StatusWarn {
+		t.Errorf("Expected warning for a missing socket, got %s: %s", check.Status, check.Message)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("Unix sockets not supported: %v", err)
+	}
+	defer listener.Close()
+
+	if err := os.Chmod(path, 0660); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+	if check := CheckHelperSocket(path); check.Status != StatusPass {
+		t.Errorf("Expected pass, got %s: %s", check.Status, check.Message)
+	}
+
+	if err := os.Chmod(path, 0666); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+	if check := CheckHelperSocket(path); check.Status != StatusFail {
+		t.Errorf("Expected failure for a world-writable socket, got %s: %s", check.Status, check.Message)
+	}
+
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, nil, 0600)
+	if check := CheckHelperSocket(file); check.Status != StatusFail {
+		t.Errorf("Expected failure for a regular file, got %s: %s", check.Status, check.Message)
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+
+	if check := CheckDiskSpace(dir, 1); check.Status != StatusPass {
+		t.Errorf("Expected pass, got %s: %s", check.Status, check.Message)
+	}
+	if check := CheckDiskSpace(dir, 1<<62); check.Status != StatusFail {
+		t.Errorf("Expected failure, got %s: %s", check.Status, check.Message)
+	}
+	if check := CheckDiskSpace(filepath.Join(dir, "missing"), 1); check.Status != StatusFail {
+		t.Errorf("Expected failure for a missing directory, got %s: %s", check.Status, check.Message)
+	}
+}
@@ -0,0 +1,211 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+)
+
+// ConfigChange is a setting that differs between the running configuration
+// and a proposed one. Old is unset for added settings and New for removed
+// ones.
+type ConfigChange struct {
+	Path string      `json:"path"`
+	Kind string      `json:"kind"` // added, removed, changed
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// ConfigApplyReport is the outcome of config apply: the server-side
+// validation, the changes from the running configuration and, unless the
+// configuration was invalid or it was a dry run, the result of applying it
+type ConfigApplyReport struct {
+	Validation *client.ConfigValidation `json:"validation,omitempty"`
+	Changes    []ConfigChange           `json:"changes"`
+	DryRun     bool                     `json:"dry_run"`
+	Result     *client.ConfigResult     `json:"result,omitempty"`
+}
+
+// DiffConfig compares a proposed configuration with the running one, both
+// decoded from JSON. Nested settings are compared by dotted path and lists
+// as a whole. A setting one side leaves out and the other reports with its
+// empty value, like false or 0, is not a change, as the API reports
+// defaulted settings that config files usually omit.
+func DiffConfig(running, proposed map[string]interface{}) []ConfigChange {
+	old := make(map[string]interface{})
+	flattenConfig("", running, old)
+	updated := make(map[string]interface{})
+	flattenConfig("", proposed, updated)
+
+	var changes []ConfigChange
+	for path, value := range updated {
+		previous, ok := old[path]
+		switch {
+		case !ok && !isEmptyValue(value):
+			changes = append(changes, ConfigChange{Path: path, Kind: "added", New: value})
+		case ok && !reflect.DeepEqual(previous, value):
+			changes = append(changes, ConfigChange{Path: path, Kind: "changed", Old: previous, New: value})
+		}
+	}
+	for path, value := range old {
+		if _, ok := updated[path]; !ok && !isEmptyValue(value) {
+			changes = append(changes, ConfigChange{Path: path, Kind: "removed", Old: value})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// flattenConfig records the leaf settings of config under their dotted path
+func flattenConfig(prefix string, config map[string]interface{}, out map[string]interface{}) {
+	for key, value := range config {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenConfig(path, nested, out)
+			continue
+		}
+		out[path] = value
+	}
+}
+
+// isEmptyValue reports whether a decoded JSON value is empty
+func isEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// formatConfigValue renders a decoded JSON value on one line
+func formatConfigValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func formatConfigApplyMessage(report *ConfigApplyReport) error {
+	if v := report.Validation; v != nil {
+		if v.Valid {
+			fmt.Fprintln(outputWriter, successColor("Configuration is valid"))
+		} else {
+			fmt.Fprintln(outputWriter, errorColor("Configuration is invalid"))
+		}
+		for _, e := range v.Errors {
+			line := fmt.Sprintf("  ✗ %s: %s", e.Path, e.Message)
+			if e.Code != "" {
+				line += fmt.Sprintf(" (%s)", e.Code)
+			}
+			fmt.Fprintln(outputWriter, errorColor(line))
+		}
+		for _, w := range v.Warnings {
+			fmt.Fprintln(outputWriter, warningColor("  ! "+w))
+		}
+		if !v.Valid {
+			return nil
+		}
+		fmt.Fprintln(outputWriter)
+	}
+
+	if len(report.Changes) == 0 {
+		fmt.Fprintln(outputWriter, "No changes from the running configuration")
+	} else {
+		fmt.Fprintf(outputWriter, "Changes from the running configuration (%d):\n", len(report.Changes))
+		for _, change := range report.Changes {
+			switch change.Kind {
+			case "added":
+				fmt.Fprintln(outputWriter, successColor(fmt.Sprintf("  + %s: %s", change.Path, formatConfigValue(change.New))))
+			case "removed":
+				fmt.Fprintln(outputWriter, errorColor(fmt.Sprintf("  - %s: %s", change.Path, formatConfigValue(change.Old))))
+			default:
+				fmt.Fprintln(outputWriter, warningColor(fmt.Sprintf("  ~ %s: %s -> %s",
+					change.Path, formatConfigValue(change.Old), formatConfigValue(change.New))))
+			}
+		}
+	}
+
+	fmt.Fprintln(outputWriter)
+	switch result := report.Result; {
+	case report.DryRun:
+		fmt.Fprintln(outputWriter, infoColor("Dry run: configuration not applied"))
+	case result == nil:
+	case result.Success:
+		fmt.Fprintln(outputWriter, successColor(fmt.Sprintf("Configuration applied as version %d", result.Version)))
+	case result.Error != nil:
+		message := result.Error.Message
+		if result.Error.Details != "" {
+			message += ": " + result.Error.Details
+		}
+		fmt.Fprintln(outputWriter, errorColor("Error: "+message))
+	default:
+		fmt.Fprintln(outputWriter, errorColor("Error: configuration was not applied"))
+	}
+	if result := report.Result; result != nil {
+		for _, w := range result.Warnings {
+			fmt.Fprintln(outputWriter, warningColor("  ! "+w))
+		}
+	}
+	return nil
+}
+
+// FormatRollbackPreview writes what rolling back to version replaces, from
+// the configuration history, to w. The API does not serve the content of
+// past versions, so they are described by when and how they were applied.
+func FormatRollbackPreview(w io.Writer, version int, history []client.ConfigVersion) {
+	var current, target *client.ConfigVersion
+	for i := range history {
+		v := &history[i]
+		if current == nil || v.Version > current.Version {
+			current = v
+		}
+		if v.Version == version {
+			target = v
+		}
+	}
+
+	if current != nil {
+		fmt.Fprintln(w, warningColor(fmt.Sprintf("Roll back from version %d to version %d", current.Version, version)))
+		fmt.Fprintln(w, errorColor("  - "+describeVersion(current)))
+	} else {
+		fmt.Fprintln(w, warningColor(fmt.Sprintf("Roll back to version %d", version)))
+	}
+	if target != nil {
+		fmt.Fprintln(w, successColor("  + "+describeVersion(target)))
+	}
+	fmt.Fprintln(w, "The collector is reloaded with the restored configuration, recorded as a new version.")
+}
+
+// describeVersion describes a configuration version on one line
+func describeVersion(v *client.ConfigVersion) string {
+	line := fmt.Sprintf("version %d, applied %s from %s", v.Version, v.AppliedAt.Local().Format("2006-01-02 15:04:05"), v.Source)
+	if v.Author != "" {
+		line += " by " + v.Author
+	}
+	if v.Description != "" {
+		line += ": " + v.Description
+	}
+	return line
+}
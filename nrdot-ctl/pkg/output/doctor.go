@@ -0,0 +1,36 @@
+package output
+
+import (
+	"fmt"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/doctor"
+)
+
+func formatDoctorMessage(report *doctor.Report) error {
+	for _, check := range report.Checks {
+		line := fmt.Sprintf("%s: %s", check.Name, check.Message)
+		switch check.Status {
+		case doctor.StatusPass:
+			fmt.Fprintln(outputWriter, successColor("  ✓ "+line))
+		case doctor.StatusWarn:
+			fmt.Fprintln(outputWriter, warningColor("  ! "+line))
+		default:
+			fmt.Fprintln(outputWriter, errorColor("  ✗ "+line))
+		}
+		if check.Hint != "" {
+			fmt.Fprintf(outputWriter, "      %s\n", check.Hint)
+		}
+	}
+
+	passed, warned, failed := report.Count(doctor.StatusPass), report.Count(doctor.StatusWarn), report.Count(doctor.StatusFail)
+	summary := fmt.Sprintf("\n%d passed, %d warnings, %d failed", passed, warned, failed)
+	switch {
+	case failed > 0:
+		fmt.Fprintln(outputWriter, errorColor(summary))
+	case warned > 0:
+		fmt.Fprintln(outputWriter, warningColor(summary))
+	default:
+		fmt.Fprintln(outputWriter, successColor(summary))
+	}
+	return nil
+}
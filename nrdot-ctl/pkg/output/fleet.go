@@ -0,0 +1,175 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+	"github.com/olekukonko/tablewriter"
+)
+
+// ContextInfo describes a configured context without its credentials
+type ContextInfo struct {
+	Name    string `json:"name" yaml:"name"`
+	Server  string `json:"server" yaml:"server"`
+	Auth    string `json:"auth" yaml:"auth"` // token, api-key or none
+	Current bool   `json:"current" yaml:"current"`
+}
+
+// FleetStatus is the status of the agent of one context, or why it could
+// not be read
+type FleetStatus struct {
+	Context string         `json:"context" yaml:"context"`
+	Server  string         `json:"server" yaml:"server"`
+	Status  *client.Status `json:"status,omitempty" yaml:"status,omitempty"`
+	Error   string         `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// FleetHealth is the health of the agent of one context, or why it could
+// not be read
+type FleetHealth struct {
+	Context string               `json:"context" yaml:"context"`
+	Server  string               `json:"server" yaml:"server"`
+	Health  *client.HealthReport `json:"health,omitempty" yaml:"health,omitempty"`
+	Error   string               `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func formatContextsTable(contexts []ContextInfo) error {
+	if len(contexts) == 0 {
+		fmt.Fprintln(outputWriter, "No contexts configured")
+		return nil
+	}
+
+	table := tablewriter.NewWriter(outputWriter)
+	table.SetHeader([]string{"Current", "Name", "Server", "Auth"})
+	table.SetBorder(false)
+
+	for _, c := range contexts {
+		current := ""
+		if c.Current {
+			current = "*"
+		}
+		table.Append([]string{current, c.Name, c.Server, c.Auth})
+	}
+
+	table.Render()
+	return nil
+}
+
+func formatFleetStatusTable(fleet []FleetStatus) error {
+	table := tablewriter.NewWriter(outputWriter)
+	table.SetHeader([]string{"Context", "State", "Health", "Uptime", "Config Version", "Error"})
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+
+	unreachable := 0
+	for _, agent := range fleet {
+		if agent.Status == nil {
+			unreachable++
+			table.Append([]string{agent.Context, errorColor("unreachable"), "-", "-", "-", agent.Error})
+			continue
+		}
+		status := agent.Status
+		table.Append([]string{
+			agent.Context,
+			colorState(status.State),
+			colorHealth(status.Health.Status),
+			formatDuration(status.Uptime),
+			status.ConfigVersion,
+			status.LastError,
+		})
+	}
+
+	table.Render()
+	formatFleetSummary(len(fleet), unreachable)
+	return nil
+}
+
+func formatFleetHealthTable(fleet []FleetHealth) error {
+	table := tablewriter.NewWriter(outputWriter)
+	table.SetHeader([]string{"Context", "Health", "Checks", "Error"})
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+
+	unreachable := 0
+	for _, agent := range fleet {
+		if agent.Health == nil {
+			unreachable++
+			table.Append([]string{agent.Context, errorColor("unreachable"), "-", agent.Error})
+			continue
+		}
+		table.Append([]string{
+			agent.Context,
+			colorHealth(agent.Health.Status),
+			formatChecks(agent.Health.Checks),
+			"",
+		})
+	}
+
+	table.Render()
+	formatFleetSummary(len(fleet), unreachable)
+	return nil
+}
+
+func formatHealthMessage(health *client.HealthReport) error {
+	fmt.Fprintf(outputWriter, "Health: %s\n", colorHealth(health.Status))
+	names := make([]string, 0, len(health.Checks))
+	for name := range health.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(outputWriter, "  %s: %s\n", name, colorHealth(health.Checks[name]))
+	}
+	return nil
+}
+
+// formatFleetSummary prints how many agents answered
+func formatFleetSummary(total, unreachable int) {
+	fmt.Fprintln(outputWriter)
+	if unreachable > 0 {
+		fmt.Fprintln(outputWriter, warningColor(fmt.Sprintf("%d of %d agents unreachable", unreachable, total)))
+		return
+	}
+	fmt.Fprintf(outputWriter, "%d agents\n", total)
+}
+
+// formatChecks lists health checks as name=status, sorted by name
+func formatChecks(checks map[string]string) string {
+	if len(checks) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(checks))
+	for name, status := range checks {
+		parts = append(parts, name+"="+status)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// colorState colors a collector state: running green, stopped red, others
+// yellow
+func colorState(state string) string {
+	switch state {
+	case "running":
+		return successColor(state)
+	case "stopped":
+		return errorColor(state)
+	}
+	return warningColor(state)
+}
+
+// colorHealth colors a health status: healthy green, unhealthy red, degraded
+// yellow
+func colorHealth(status string) string {
+	switch status {
+	case "healthy":
+		return successColor(status)
+	case "unhealthy":
+		return errorColor(status)
+	case "degraded":
+		return warningColor(status)
+	}
+	return status
+}
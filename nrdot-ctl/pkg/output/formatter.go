@@ -5,6 +5,9 @@ import (
 	"fmt"
 
 	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/doctor"
+	migration "github.com/newrelic/nrdot-host/nrdot-migration"
+	"github.com/newrelic/nrdot-host/processors/nrcap"
 	"gopkg.in/yaml.v3"
 )
 
@@ -54,15 +57,15 @@ func (f *Formatter) FormatOperationResult(result *client.OperationResult) error
 	}
 }
 
-// FormatApplyResult formats apply result output
-func (f *Formatter) FormatApplyResult(result *client.ApplyResult) error {
+// FormatConfigApply formats config apply output
+func (f *Formatter) FormatConfigApply(report *ConfigApplyReport) error {
 	switch f.format {
 	case "json":
-		return f.formatJSON(result)
+		return f.formatJSON(report)
 	case "yaml":
-		return f.formatYAML(result)
+		return f.formatYAML(report)
 	default:
-		return formatApplyMessage(result)
+		return formatConfigApplyMessage(report)
 	}
 }
 
@@ -78,6 +81,153 @@ func (f *Formatter) FormatMetrics(metrics *client.Metrics) error {
 	}
 }
 
+// FormatSecretResult formats secret update output
+func (f *Formatter) FormatSecretResult(result *client.SecretSetResult) error {
+	switch f.format {
+	case "json":
+		return f.formatJSON(result)
+	case "yaml":
+		return f.formatYAML(result)
+	default:
+		return formatSecretMessage(result)
+	}
+}
+
+// FormatConfigHistory formats configuration history output
+func (f *Formatter) FormatConfigHistory(history []client.ConfigVersion) error {
+	switch f.format {
+	case "json":
+		return f.formatJSON(history)
+	case "yaml":
+		return f.formatYAML(history)
+	default:
+		return formatConfigHistoryTable(history)
+	}
+}
+
+// FormatRollbackResult formats configuration rollback output
+func (f *Formatter) FormatRollbackResult(result *client.RollbackResult) error {
+	switch f.format {
+	case "json":
+		return f.formatJSON(result)
+	case "yaml":
+		return f.formatYAML(result)
+	default:
+		return formatRollbackMessage(result)
+	}
+}
+
+// FormatDiscovery formats service discovery output
+func (f *Formatter) FormatDiscovery(result *client.DiscoveryResult) error {
+	switch f.format {
+	case "json":
+		return f.formatJSON(result)
+	case "yaml":
+		return f.formatYAML(result)
+	default:
+		return formatDiscoveryTable(result)
+	}
+}
+
+// FormatCardinalityTop formats a frame of the cardinality live view. Rates
+// are computed against previous, the frame before, and left out without it.
+func (f *Formatter) FormatCardinalityTop(stats, previous *client.CardinalityStats) error {
+	switch f.format {
+	case "json":
+		return f.formatJSON(stats)
+	case "yaml":
+		return f.formatYAML(stats)
+	default:
+		return formatCardinalityTop(stats, previous)
+	}
+}
+
+// FormatCardinalitySimulation formats what candidate cardinality limits would
+// do to a recorded sample. Metrics the limits leave untouched are only
+// listed in tables with all.
+func (f *Formatter) FormatCardinalitySimulation(report *nrcap.SimulationReport, all bool) error {
+	switch f.format {
+	case "json":
+		return f.formatJSON(report)
+	case "yaml":
+		return f.formatYAML(report)
+	default:
+		return formatCardinalitySimulation(report, all)
+	}
+}
+
+// FormatHealth formats agent health output
+func (f *Formatter) FormatHealth(health *client.HealthReport) error {
+	switch f.format {
+	case "json":
+		return f.formatJSON(health)
+	case "yaml":
+		return f.formatYAML(health)
+	default:
+		return formatHealthMessage(health)
+	}
+}
+
+// FormatFleetStatus formats the status of the agents of all contexts
+func (f *Formatter) FormatFleetStatus(fleet []FleetStatus) error {
+	switch f.format {
+	case "json":
+		return f.formatJSON(fleet)
+	case "yaml":
+		return f.formatYAML(fleet)
+	default:
+		return formatFleetStatusTable(fleet)
+	}
+}
+
+// FormatFleetHealth formats the health of the agents of all contexts
+func (f *Formatter) FormatFleetHealth(fleet []FleetHealth) error {
+	switch f.format {
+	case "json":
+		return f.formatJSON(fleet)
+	case "yaml":
+		return f.formatYAML(fleet)
+	default:
+		return formatFleetHealthTable(fleet)
+	}
+}
+
+// FormatContexts formats the configured contexts
+func (f *Formatter) FormatContexts(contexts []ContextInfo) error {
+	switch f.format {
+	case "json":
+		return f.formatJSON(contexts)
+	case "yaml":
+		return f.formatYAML(contexts)
+	default:
+		return formatContextsTable(contexts)
+	}
+}
+
+// FormatMigrationReport formats the report of an agent migration
+func (f *Formatter) FormatMigrationReport(report *migration.MigrationReport, dryRun bool) error {
+	switch f.format {
+	case "json":
+		return f.formatJSON(report)
+	case "yaml":
+		return f.formatYAML(report)
+	default:
+		return formatMigrationMessage(report, dryRun)
+	}
+}
+
+// FormatDoctorReport formats the results of the environment checks
+func (f *Formatter) FormatDoctorReport(report *doctor.Report) error {
+	switch f.format {
+	case "json":
+		return f.formatJSON(report)
+	case "yaml":
+		return f.formatYAML(report)
+	default:
+		return formatDoctorMessage(report)
+	}
+}
+
 // FormatVersion formats version output
 func (f *Formatter) FormatVersion(info *VersionInfo) error {
 	switch f.format {
@@ -111,6 +261,21 @@ type VersionInfo struct {
 	GoVersion string `json:"go_version" yaml:"go_version"`
 	OS        string `json:"os" yaml:"os"`
 	Arch      string `json:"arch" yaml:"arch"`
+
+	// Channel and Updates are set by version --check-update
+	Channel string        `json:"channel,omitempty" yaml:"channel,omitempty"`
+	Updates []UpdateCheck `json:"updates,omitempty" yaml:"updates,omitempty"`
+}
+
+// UpdateCheck compares the installed version of a component with the latest
+// release on the update channel
+type UpdateCheck struct {
+	Component    string `json:"component" yaml:"component"`
+	Installed    string `json:"installed,omitempty" yaml:"installed,omitempty"`
+	Latest       string `json:"latest,omitempty" yaml:"latest,omitempty"`
+	Current      bool   `json:"current" yaml:"current"`
+	ChangelogURL string `json:"changelog_url,omitempty" yaml:"changelog_url,omitempty"`
+	Error        string `json:"error,omitempty" yaml:"error,omitempty"`
 }
 
 func formatOperationMessage(result *client.OperationResult) error {
@@ -122,13 +287,23 @@ func formatOperationMessage(result *client.OperationResult) error {
 	return nil
 }
 
-func formatApplyMessage(result *client.ApplyResult) error {
-	if result.Success {
-		fmt.Fprintln(outputWriter, successColor(result.Message))
-		fmt.Fprintf(outputWriter, "Previous version: %s\n", result.PreviousVersion)
-		fmt.Fprintf(outputWriter, "New version: %s\n", result.NewVersion)
+func formatSecretMessage(result *client.SecretSetResult) error {
+	if result.Validated {
+		fmt.Fprintln(outputWriter, successColor(fmt.Sprintf("Secret %s validated and stored", result.Name)))
 	} else {
-		fmt.Fprintln(outputWriter, errorColor("Error: " + result.Error))
+		fmt.Fprintln(outputWriter, successColor(fmt.Sprintf("Secret %s stored (not validated)", result.Name)))
 	}
+	fmt.Fprintln(outputWriter, result.Message)
 	return nil
-}
\ No newline at end of file
+}
+
+func formatRollbackMessage(result *client.RollbackResult) error {
+	if result.Success {
+		fmt.Fprintln(outputWriter, successColor(fmt.Sprintf("Rolled back to version %d", result.ToVersion)))
+		fmt.Fprintf(outputWriter, "Previous version: %d\n", result.FromVersion)
+		fmt.Fprintf(outputWriter, "New version: %d\n", result.Version)
+	} else if result.Error != nil {
+		fmt.Fprintln(outputWriter, errorColor("Error: "+result.Error.Message))
+	}
+	return nil
+}
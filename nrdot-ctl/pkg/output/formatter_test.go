@@ -9,6 +9,9 @@ import (
 	"time"
 
 	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/doctor"
+	migration "github.com/newrelic/nrdot-host/nrdot-migration"
+	"github.com/newrelic/nrdot-host/processors/nrcap"
 	"gopkg.in/yaml.v3"
 )
 
@@ -176,4 +179,546 @@ func TestFormatMetrics(t *testing.T) {
 	if !strings.Contains(output, "hostmetrics") {
 		t.Error("Expected output to contain pipeline names")
 	}
-}
\ No newline at end of file
+}
+func TestFormatDiscovery(t *testing.T) {
+	result := &client.DiscoveryResult{
+		Timestamp: time.Now(),
+		DiscoveredServices: []client.DiscoveredService{
+			{
+				Type:         "mysql",
+				Version:      "8.0.32",
+				Endpoints:    []client.ServiceEndpoint{{Address: "localhost", Port: 3306, Protocol: "tcp"}},
+				Confidence:   "HIGH",
+				DiscoveredBy: []string{"process", "port"},
+			},
+			{
+				Type:         "redis",
+				Endpoints:    []client.ServiceEndpoint{{Address: "0.0.0.0", Port: 6379}},
+				Confidence:   "MEDIUM",
+				DiscoveredBy: []string{"port"},
+			},
+		},
+		ScanDurationMs: 450,
+	}
+
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+
+	if err := NewFormatter("table").FormatDiscovery(result); err != nil {
+		t.Fatalf("FormatDiscovery() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{"2 services discovered", "450ms", "8.0.32", "localhost:3306/tcp", "0.0.0.0:6379", "process, port"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, output)
+		}
+	}
+
+	buf.Reset()
+	if err := NewFormatter("json").FormatDiscovery(result); err != nil {
+		t.Fatalf("FormatDiscovery() error = %v", err)
+	}
+	var decoded client.DiscoveryResult
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+	if len(decoded.DiscoveredServices) != 2 || decoded.DiscoveredServices[1].Type != "redis" {
+		t.Errorf("Unexpected JSON output: %s", buf.String())
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	running := map[string]interface{}{
+		"version": float64(3),
+		"service": map[string]interface{}{"name": "web", "environment": "prod"},
+		"metrics": map[string]interface{}{"enabled": true, "interval": float64(60)},
+		"traces":  map[string]interface{}{"enabled": false, "sample_rate": float64(0)},
+		"logs":    map[string]interface{}{"paths": []interface{}{"/var/log/a.log"}},
+	}
+	proposed := map[string]interface{}{
+		"version":     float64(3),
+		"service":     map[string]interface{}{"name": "web", "environment": "staging"},
+		"metrics":     map[string]interface{}{"enabled": true},
+		"logs":        map[string]interface{}{"paths": []interface{}{"/var/log/a.log", "/var/log/b.log"}},
+		"license_key": "abc",
+	}
+
+	changes := DiffConfig(running, proposed)
+	want := []string{
+		"license_key added",
+		"logs.paths changed",
+		"metrics.interval removed",
+		"service.environment changed",
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), changes)
+	}
+	for i, change := range changes {
+		if got := change.Path + " " + change.Kind; got != want[i] {
+			t.Errorf("Change %d = %q, want %q", i, got, want[i])
+		}
+	}
+	if changes[3].Old != "prod" || changes[3].New != "staging" {
+		t.Errorf("Unexpected change: %+v", changes[3])
+	}
+}
+
+func TestFormatConfigApply(t *testing.T) {
+	report := &ConfigApplyReport{
+		Validation: &client.ConfigValidation{Valid: true, Warnings: []string{"license key is not set"}},
+		Changes: []ConfigChange{
+			{Path: "service.environment", Kind: "changed", Old: "prod", New: "staging"},
+			{Path: "metrics.interval", Kind: "removed", Old: float64(60)},
+		},
+		Result: &client.ConfigResult{Success: true, Version: 4},
+	}
+
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+
+	if err := NewFormatter("table").FormatConfigApply(report); err != nil {
+		t.Fatalf("FormatConfigApply() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{
+		"Configuration is valid",
+		"! license key is not set",
+		"Changes from the running configuration (2)",
+		`~ service.environment: "prod" -> "staging"`,
+		"- metrics.interval: 60",
+		"applied as version 4",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, output)
+		}
+	}
+
+	// Invalid configurations list their errors and nothing else
+	buf.Reset()
+	report = &ConfigApplyReport{
+		Validation: &client.ConfigValidation{Errors: []client.ConfigValidationError{{Path: "metrics.interval", Message: "must be positive", Code: "VALIDATION_FAILED"}}},
+		DryRun:     true,
+	}
+	if err := NewFormatter("table").FormatConfigApply(report); err != nil {
+		t.Fatalf("FormatConfigApply() error = %v", err)
+	}
+	output = buf.String()
+	if !strings.Contains(output, "✗ metrics.interval: must be positive (VALIDATION_FAILED)") || strings.Contains(output, "Dry run") {
+		t.Errorf("Unexpected output:\n%s", output)
+	}
+
+	buf.Reset()
+	report.Validation.Valid = true
+	report.Validation.Errors = nil
+	if err := NewFormatter("json").FormatConfigApply(report); err != nil {
+		t.Fatalf("FormatConfigApply() error = %v", err)
+	}
+	var decoded ConfigApplyReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+	if !decoded.DryRun || decoded.Result != nil {
+		t.Errorf("Unexpected JSON output: %s", buf.String())
+	}
+}
+
+func TestFormatCardinalityTop(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	previous := &client.CardinalityStats{
+		GeneratedAt:    now.Add(-2 * time.Second),
+		TotalMetrics:   1000,
+		DroppedMetrics: 10,
+	}
+	stats := &client.CardinalityStats{
+		Processor:         "nrcap/pods",
+		GeneratedAt:       now,
+		LastReset:         now.Add(-15 * time.Minute),
+		GlobalCardinality: 5230,
+		GlobalLimit:       100000,
+		TotalMetrics:      3000,
+		DroppedMetrics:    50,
+		Metrics: []client.MetricCardinality{
+			{Name: "http_requests_total", Cardinality: 9500, Limit: 10000, Strategy: "drop"},
+			{Name: "queue_depth", Cardinality: 10, Limit: 1000, Strategy: "aggregate"},
+		},
+		Labels: []client.LabelCardinality{
+			{Key: "path", DistinctValues: 2050},
+			{Key: "container_id", DistinctValues: 19000, Limit: 20000},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+
+	if err := NewFormatter("table").FormatCardinalityTop(stats, previous); err != nil {
+		t.Fatalf("FormatCardinalityTop() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{
+		"nrcap/pods", "last reset 15m ago",
+		"Series: 5230 / 100000 (5.2%)",
+		"Data points: 1000.0/s", "dropped 20.0/s",
+		"http_requests_total", "95.0%", "queue_depth", "1.0%",
+		"path", "2050",
+		"container_id", "19000", "20000",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, output)
+		}
+	}
+
+	// The first frame has no rates
+	buf.Reset()
+	if err := NewFormatter("table").FormatCardinalityTop(stats, nil); err != nil {
+		t.Fatalf("FormatCardinalityTop() error = %v", err)
+	}
+	if strings.Contains(buf.String(), "Data points") {
+		t.Errorf("Expected no rates without a previous frame:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := NewFormatter("json").FormatCardinalityTop(stats, previous); err != nil {
+		t.Fatalf("FormatCardinalityTop() error = %v", err)
+	}
+	var decoded client.CardinalityStats
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+	if decoded.GlobalCardinality != 5230 || len(decoded.Metrics) != 2 {
+		t.Errorf("Unexpected JSON output: %s", buf.String())
+	}
+}
+
+func TestFormatFleetStatus(t *testing.T) {
+	fleet := []FleetStatus{
+		{
+			Context: "prod-host-1",
+			Server:  "https://prod-host-1:8080",
+			Status: &client.Status{
+				State:         "running",
+				Uptime:        26 * time.Hour,
+				ConfigVersion: "v7",
+				Health:        client.Health{Status: "degraded"},
+			},
+		},
+		{
+			Context: "prod-host-2",
+			Server:  "https://prod-host-2:8080",
+			Error:   "connection refused",
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+
+	if err := NewFormatter("table").FormatFleetStatus(fleet); err != nil {
+		t.Fatalf("FormatFleetStatus() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{
+		"prod-host-1", "running", "degraded", "1d 2h", "v7",
+		"prod-host-2", "unreachable", "connection refused",
+		"1 of 2 agents unreachable",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, output)
+		}
+	}
+
+	buf.Reset()
+	if err := NewFormatter("json").FormatFleetStatus(fleet); err != nil {
+		t.Fatalf("FormatFleetStatus() error = %v", err)
+	}
+	var decoded []FleetStatus
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+	if len(decoded) != 2 || decoded[0].Status == nil || decoded[0].Status.State != "running" || decoded[1].Error != "connection refused" {
+		t.Errorf("Unexpected JSON output: %s", buf.String())
+	}
+}
+
+func TestFormatFleetHealth(t *testing.T) {
+	fleet := []FleetHealth{
+		{
+			Context: "prod-host-1",
+			Health: &client.HealthReport{
+				Status: "unhealthy",
+				Checks: map[string]string{"collector": "unhealthy", "api": "healthy"},
+			},
+		},
+		{Context: "prod-host-2", Health: &client.HealthReport{Status: "healthy"}},
+	}
+
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+
+	if err := NewFormatter("table").FormatFleetHealth(fleet); err != nil {
+		t.Fatalf("FormatFleetHealth() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{"prod-host-1", "unhealthy", "api=healthy, collector=unhealthy", "prod-host-2", "2 agents"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "unreachable") {
+		t.Errorf("Expected all agents reachable:\n%s", output)
+	}
+}
+
+func TestFormatMigrationReport(t *testing.T) {
+	report := &migration.MigrationReport{
+		Success:            true,
+		InfraAgentFound:    true,
+		ConfigMigrated:     true,
+		CustomIntegrations: []string{"custom-check.yml"},
+		Warnings:           []string{"Found 1 custom integrations that may need manual migration"},
+		MigratedConfig:     map[string]interface{}{"license_key": "${NEW_RELIC_LICENSE_KEY}"},
+	}
+
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+
+	if err := NewFormatter("table").FormatMigrationReport(report, true); err != nil {
+		t.Fatalf("FormatMigrationReport() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{"(dry run)", "Configuration converted, not written", "Infrastructure Agent left running", "custom-check.yml", "Dry run completed"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "Next steps") {
+		t.Errorf("Expected no next steps after a dry run:\n%s", output)
+	}
+
+	buf.Reset()
+	if err := NewFormatter("json").FormatMigrationReport(report, true); err != nil {
+		t.Fatalf("FormatMigrationReport() error = %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Expected JSON output: %v\n%s", err, buf.String())
+	}
+	if decoded["config_migrated"] != true || decoded["migrated_config"] == nil {
+		t.Errorf("Expected migrated config in JSON output:\n%s", buf.String())
+	}
+}
+
+func TestFormatCardinalitySimulation(t *testing.T) {
+	report := &nrcap.SimulationReport{
+		Source:       nrcap.SimulationSourceOTLP,
+		Batches:      12,
+		GlobalLimit:  10000,
+		InputSeries:  2030,
+		OutputSeries: 130,
+		InputPoints:  24360,
+		OutputPoints: 1560,
+		Metrics: []nrcap.MetricSimulation{
+			{
+				Name: "http_requests_total", Strategy: nrcap.StrategyDrop, Limit: 100,
+				InputSeries: 2000, OutputSeries: 100, InputPoints: 24000, OutputPoints: 1200, DroppedPoints: 22800,
+				Affected: true,
+			},
+			{
+				Name: "node_cpu", Strategy: nrcap.StrategyDrop, Limit: 1000,
+				InputSeries: 30, OutputSeries: 30, InputPoints: 360, OutputPoints: 360,
+			},
+		},
+		Labels: []nrcap.LabelSimulation{
+			{Key: "pod", Limit: 50, Action: nrcap.LabelActionHash, InputValues: 400, OutputValues: 150},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+
+	if err := NewFormatter("table").FormatCardinalitySimulation(report, false); err != nil {
+		t.Fatalf("FormatCardinalitySimulation() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{"12 recorded batches", "2030 -> 130", "http_requests_total", "2000 -> 100", "95.0%", "400 -> 150", "1 metrics affected, 1 untouched"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "node_cpu") {
+		t.Errorf("Expected untouched metrics to be left out without all:\n%s", output)
+	}
+
+	buf.Reset()
+	if err := NewFormatter("table").FormatCardinalitySimulation(report, true); err != nil {
+		t.Fatalf("FormatCardinalitySimulation() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "node_cpu") {
+		t.Errorf("Expected untouched metrics to be listed with all:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := NewFormatter("json").FormatCardinalitySimulation(report, false); err != nil {
+		t.Fatalf("FormatCardinalitySimulation() error = %v", err)
+	}
+	var decoded nrcap.SimulationReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Expected JSON output: %v\n%s", err, buf.String())
+	}
+	if len(decoded.Metrics) != 2 || decoded.OutputSeries != 130 {
+		t.Errorf("Expected all metrics in JSON output:\n%s", buf.String())
+	}
+}
+
+func TestFormatDoctorReport(t *testing.T) {
+	report := &doctor.Report{Checks: []doctor.Check{
+		{Name: "collector binary", Status: doctor.StatusPass, Message: "otelcol-nrdot version 1.0.0"},
+		{Name: "privileged helper", Status: doctor.StatusWarn, Message: "socket not found", Hint: "Start the helper"},
+		{Name: "license key", Status: doctor.StatusFail, Message: "NEW_RELIC_LICENSE_KEY is not set", Hint: "Set NEW_RELIC_LICENSE_KEY"},
+	}}
+
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+
+	if err := NewFormatter("table").FormatDoctorReport(report); err != nil {
+		t.Fatalf("FormatDoctorReport() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{"✓ collector binary", "! privileged helper", "✗ license key", "Set NEW_RELIC_LICENSE_KEY", "1 passed, 1 warnings, 1 failed"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, output)
+		}
+	}
+
+	buf.Reset()
+	if err := NewFormatter("json").FormatDoctorReport(report); err != nil {
+		t.Fatalf("FormatDoctorReport() error = %v", err)
+	}
+	var decoded doctor.Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Expected JSON output: %v\n%s", err, buf.String())
+	}
+	if len(decoded.Checks) != 3 || decoded.Checks[2].Status != doctor.StatusFail {
+		t.Errorf("Unexpected JSON output:\n%s", buf.String())
+	}
+}
+
+func TestFormatVersion_Updates(t *testing.T) {
+	info := &VersionInfo{
+		Version: "1.0.0",
+		OS:      "linux",
+		Arch:    "amd64",
+		Channel: "stable",
+		Updates: []UpdateCheck{
+			{Component: "nrdot-host", Installed: "1.1.0", Latest: "1.2.0", ChangelogURL: "https://example.com/host/1.2.0"},
+			{Component: "collector", Installed: "0.97.0", Latest: "0.97.0", Current: true, ChangelogURL: "https://example.com/collector/0.97.0"},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+
+	if err := NewFormatter("table").FormatVersion(info); err != nil {
+		t.Fatalf("FormatVersion() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{"Updates (stable channel)", "nrdot-host 1.1.0: 1.2.0 available", "https://example.com/host/1.2.0", "collector 0.97.0 is current"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "https://example.com/collector") {
+		t.Errorf("Expected no changelog for a current component:\n%s", output)
+	}
+
+	buf.Reset()
+	if err := NewFormatter("json").FormatVersion(info); err != nil {
+		t.Fatalf("FormatVersion() error = %v", err)
+	}
+	var decoded VersionInfo
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Expected JSON output: %v\n%s", err, buf.String())
+	}
+	if len(decoded.Updates) != 2 || decoded.Updates[0].Current || !decoded.Updates[1].Current {
+		t.Errorf("Unexpected JSON output:\n%s", buf.String())
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	if diff := UnifiedDiff("a", "b", "x\ny\n", "x\ny\n"); diff != "" {
+		t.Errorf("Expected no diff for equal texts, got %q", diff)
+	}
+
+	oldText := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	newText := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	want := `--- running
++++ config.yaml
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`
+	if diff := UnifiedDiff("running", "config.yaml", oldText, newText); diff != want {
+		t.Errorf("Unexpected diff:\n%s", diff)
+	}
+}
+
+func TestConfigUnifiedDiff(t *testing.T) {
+	running := map[string]interface{}{
+		"metrics": map[string]interface{}{"enabled": true, "interval": float64(60)},
+		"traces":  map[string]interface{}{"sample_rate": float64(0)},
+	}
+	proposed := map[string]interface{}{
+		"metrics": map[string]interface{}{"enabled": true, "interval": float64(30)},
+	}
+
+	diff, err := ConfigUnifiedDiff("running", "config.yaml", running, proposed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(diff, "-    interval: 60\n+    interval: 30\n") {
+		t.Errorf("Expected the interval change, got:\n%s", diff)
+	}
+	if strings.Contains(diff, "traces") {
+		t.Errorf("Expected empty settings to be left out, got:\n%s", diff)
+	}
+}
+
+func TestFormatRollbackPreview(t *testing.T) {
+	applied := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	history := []client.ConfigVersion{
+		{Version: 5, AppliedAt: applied, Source: "api", Author: "ops"},
+		{Version: 3, AppliedAt: applied, Source: "file", Description: "baseline"},
+	}
+
+	buf := new(bytes.Buffer)
+	FormatRollbackPreview(buf, 3, history)
+	out := buf.String()
+	for _, want := range []string{
+		"Roll back from version 5 to version 3",
+		"  - version 5, ",
+		" from api by ops",
+		"  + version 3, ",
+		" from file: baseline",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in preview:\n%s", want, out)
+		}
+	}
+}
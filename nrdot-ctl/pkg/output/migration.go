@@ -0,0 +1,74 @@
+package output
+
+import (
+	"fmt"
+	"time"
+
+	migration "github.com/newrelic/nrdot-host/nrdot-migration"
+)
+
+func formatMigrationMessage(report *migration.MigrationReport, dryRun bool) error {
+	title := "Infrastructure Agent migration"
+	if dryRun {
+		title += " (dry run)"
+	}
+	fmt.Fprintln(outputWriter, title)
+
+	step := func(done bool, doneMessage, notDoneMessage string) {
+		switch {
+		case done:
+			fmt.Fprintln(outputWriter, successColor("  ✓ "+doneMessage))
+		case notDoneMessage != "":
+			fmt.Fprintln(outputWriter, errorColor("  ✗ "+notDoneMessage))
+		}
+	}
+	step(report.InfraAgentFound, "Infrastructure Agent detected", "Infrastructure Agent not found")
+	if report.InfraAgentFound {
+		if dryRun {
+			step(report.ConfigMigrated, "Configuration converted, not written", "Configuration migration failed")
+			fmt.Fprintln(outputWriter, infoColor("  - Infrastructure Agent left running"))
+		} else {
+			step(report.ConfigMigrated, "Configuration migrated", "Configuration migration failed")
+			step(report.ServicesStopped, "Infrastructure Agent stopped", "")
+		}
+	}
+	step(report.DataPreserved, "Original data preserved", "")
+
+	if len(report.CustomIntegrations) > 0 {
+		fmt.Fprintf(outputWriter, "\nCustom integrations to migrate manually (%d):\n", len(report.CustomIntegrations))
+		for _, integration := range report.CustomIntegrations {
+			fmt.Fprintf(outputWriter, "  - %s\n", integration)
+		}
+	}
+	if len(report.Warnings) > 0 {
+		fmt.Fprintf(outputWriter, "\nWarnings (%d):\n", len(report.Warnings))
+		for _, w := range report.Warnings {
+			fmt.Fprintln(outputWriter, warningColor("  ! "+w))
+		}
+	}
+	if len(report.Errors) > 0 {
+		fmt.Fprintf(outputWriter, "\nErrors (%d):\n", len(report.Errors))
+		for _, e := range report.Errors {
+			fmt.Fprintln(outputWriter, errorColor("  ✗ "+e))
+		}
+	}
+
+	fmt.Fprintln(outputWriter)
+	switch {
+	case !report.Success:
+		fmt.Fprintln(outputWriter, errorColor("Migration failed"))
+	case dryRun:
+		fmt.Fprintln(outputWriter, successColor("Dry run completed; run without --dry-run to migrate"))
+	default:
+		fmt.Fprintln(outputWriter, successColor("Migration completed"))
+		fmt.Fprintln(outputWriter, "\nNext steps:")
+		fmt.Fprintln(outputWriter, "  1. Review the generated configuration at /etc/nrdot/config.yaml")
+		fmt.Fprintln(outputWriter, "  2. Set any required environment variables for service credentials")
+		fmt.Fprintln(outputWriter, "  3. Start NRDOT-HOST: sudo systemctl start nrdot-host")
+		fmt.Fprintln(outputWriter, "  4. Verify metrics in New Relic")
+	}
+	if !report.StartTime.IsZero() && !report.EndTime.IsZero() {
+		fmt.Fprintf(outputWriter, "Duration: %s\n", report.EndTime.Sub(report.StartTime).Round(time.Millisecond))
+	}
+	return nil
+}
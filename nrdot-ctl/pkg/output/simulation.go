@@ -0,0 +1,105 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/newrelic/nrdot-host/processors/nrcap"
+	"github.com/olekukonko/tablewriter"
+)
+
+func formatCardinalitySimulation(report *nrcap.SimulationReport, all bool) error {
+	switch report.Source {
+	case nrcap.SimulationSourceReport:
+		fmt.Fprintln(outputWriter, "Simulated against a cardinality report; series are estimates")
+	default:
+		fmt.Fprintf(outputWriter, "Simulated against %d recorded batches\n", report.Batches)
+	}
+	fmt.Fprintf(outputWriter, "Series: %d -> %d (global limit %d)\n",
+		report.InputSeries, report.OutputSeries, report.GlobalLimit)
+	if report.InputPoints > 0 {
+		fmt.Fprintf(outputWriter, "Data points: %d -> %d (%s dropped)\n",
+			report.InputPoints, report.OutputPoints,
+			formatLoss(report.InputPoints-report.OutputPoints, report.InputPoints))
+	}
+	fmt.Fprintln(outputWriter)
+
+	var affected, untouched int
+	table := tablewriter.NewWriter(outputWriter)
+	table.SetHeader([]string{"Metric", "Strategy", "Limit", "Series", "Data Points Dropped", "Labels Removed"})
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+	for _, metric := range report.Metrics {
+		if metric.Affected {
+			affected++
+		} else {
+			untouched++
+			if !all {
+				continue
+			}
+		}
+
+		dropped := "-"
+		if metric.InputPoints > 0 {
+			dropped = formatLoss(metric.DroppedPoints, metric.InputPoints)
+		}
+		removed := "-"
+		if len(metric.RemovedLabels) > 0 {
+			removed = strings.Join(metric.RemovedLabels, ", ")
+		}
+		series := fmt.Sprintf("%d -> %d", metric.InputSeries, metric.OutputSeries)
+		if metric.OutputSeries < metric.InputSeries {
+			series = warningColor(series)
+		}
+		table.Append([]string{
+			metric.Name,
+			string(metric.Strategy),
+			fmt.Sprintf("%d", metric.Limit),
+			series,
+			dropped,
+			removed,
+		})
+	}
+	if table.NumLines() > 0 {
+		table.Render()
+		fmt.Fprintln(outputWriter)
+	}
+
+	if len(report.Labels) > 0 {
+		table := tablewriter.NewWriter(outputWriter)
+		table.SetHeader([]string{"Label", "Limit", "Action", "Distinct Values"})
+		table.SetBorder(false)
+		for _, label := range report.Labels {
+			table.Append([]string{
+				label.Key,
+				fmt.Sprintf("%d", label.Limit),
+				string(label.Action),
+				fmt.Sprintf("%d -> %d", label.InputValues, label.OutputValues),
+			})
+		}
+		table.Render()
+		fmt.Fprintln(outputWriter)
+	}
+
+	switch {
+	case affected == 0:
+		fmt.Fprintln(outputWriter, successColor("No metric would lose series, data points or labels"))
+	case untouched > 0 && !all:
+		fmt.Fprintf(outputWriter, "%d metrics affected, %d untouched (--all lists them)\n", affected, untouched)
+	default:
+		fmt.Fprintf(outputWriter, "%d metrics affected, %d untouched\n", affected, untouched)
+	}
+	return nil
+}
+
+// formatLoss formats lost out of total as a percentage, red when any is lost
+func formatLoss(lost, total int64) string {
+	if total <= 0 {
+		return "-"
+	}
+	str := fmt.Sprintf("%.1f%%", float64(lost)/float64(total)*100)
+	if lost > 0 {
+		return errorColor(str)
+	}
+	return str
+}
@@ -3,7 +3,9 @@ package output
 import (
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -164,6 +166,162 @@ func formatMetricsTable(metrics *client.Metrics) error {
 	return nil
 }
 
+func formatConfigHistoryTable(history []client.ConfigVersion) error {
+	table := tablewriter.NewWriter(outputWriter)
+	table.SetHeader([]string{"Version", "Applied", "Source", "Author", "Description"})
+	table.SetBorder(false)
+
+	// Newest first
+	for i := len(history) - 1; i >= 0; i-- {
+		v := history[i]
+		table.Append([]string{
+			fmt.Sprintf("%d", v.Version),
+			v.AppliedAt.Local().Format("2006-01-02 15:04:05"),
+			v.Source,
+			v.Author,
+			v.Description,
+		})
+	}
+
+	table.Render()
+	return nil
+}
+
+func formatDiscoveryTable(result *client.DiscoveryResult) error {
+	fmt.Fprintf(outputWriter, "%d services discovered at %s (scan took %dms)\n\n",
+		len(result.DiscoveredServices),
+		result.Timestamp.Local().Format("2006-01-02 15:04:05"),
+		result.ScanDurationMs)
+	if len(result.DiscoveredServices) == 0 {
+		return nil
+	}
+
+	table := tablewriter.NewWriter(outputWriter)
+	table.SetHeader([]string{"Type", "Version", "Endpoints", "Confidence", "Discovered By"})
+	table.SetBorder(false)
+
+	for _, service := range result.DiscoveredServices {
+		endpoints := make([]string, 0, len(service.Endpoints))
+		for _, ep := range service.Endpoints {
+			endpoint := net.JoinHostPort(ep.Address, strconv.Itoa(ep.Port))
+			if ep.Protocol != "" {
+				endpoint += "/" + ep.Protocol
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+
+		// Format confidence with color
+		confidence := service.Confidence
+		switch strings.ToUpper(service.Confidence) {
+		case "HIGH":
+			confidence = successColor(service.Confidence)
+		case "MEDIUM":
+			confidence = warningColor(service.Confidence)
+		}
+
+		version := service.Version
+		if version == "" {
+			version = "-"
+		}
+
+		table.Append([]string{
+			service.Type,
+			version,
+			strings.Join(endpoints, ", "),
+			confidence,
+			strings.Join(service.DiscoveredBy, ", "),
+		})
+	}
+
+	table.Render()
+	return nil
+}
+
+func formatCardinalityTop(stats, previous *client.CardinalityStats) error {
+	fmt.Fprintf(outputWriter, "%s at %s, last reset %s ago\n",
+		stats.Processor,
+		stats.GeneratedAt.Local().Format("15:04:05"),
+		formatDuration(stats.GeneratedAt.Sub(stats.LastReset)))
+	fmt.Fprintf(outputWriter, "Series: %d / %d (%s)\n",
+		stats.GlobalCardinality, stats.GlobalLimit, formatUtilization(stats.GlobalCardinality, stats.GlobalLimit))
+
+	if previous != nil {
+		seconds := stats.GeneratedAt.Sub(previous.GeneratedAt).Seconds()
+		rate := func(current, before int64) float64 {
+			// Counters restart with the collector
+			if seconds <= 0 || current < before {
+				return 0
+			}
+			return float64(current-before) / seconds
+		}
+		total := rate(stats.TotalMetrics, previous.TotalMetrics)
+		dropped := rate(stats.DroppedMetrics, previous.DroppedMetrics)
+
+		droppedStr := fmt.Sprintf("%.1f/s", dropped)
+		if dropped > 0 {
+			droppedStr = errorColor(droppedStr)
+		}
+		fmt.Fprintf(outputWriter, "Data points: %.1f/s  dropped %s  aggregated %.1f/s  sampled %.1f/s  churn dropped %.1f/s  label limited %.1f/s\n",
+			total, droppedStr,
+			rate(stats.AggregatedMetrics, previous.AggregatedMetrics),
+			rate(stats.SampledMetrics, previous.SampledMetrics),
+			rate(stats.ChurnDroppedMetrics, previous.ChurnDroppedMetrics),
+			rate(stats.LabelLimitedMetrics, previous.LabelLimitedMetrics))
+	}
+	fmt.Fprintln(outputWriter)
+
+	if len(stats.Metrics) > 0 {
+		table := tablewriter.NewWriter(outputWriter)
+		table.SetHeader([]string{"Metric", "Series", "Limit", "Used", "Strategy"})
+		table.SetBorder(false)
+		table.SetAutoWrapText(false)
+		for _, metric := range stats.Metrics {
+			table.Append([]string{
+				metric.Name,
+				fmt.Sprintf("%d", metric.Cardinality),
+				fmt.Sprintf("%d", metric.Limit),
+				formatUtilization(metric.Cardinality, metric.Limit),
+				metric.Strategy,
+			})
+		}
+		table.Render()
+		fmt.Fprintln(outputWriter)
+	}
+
+	if len(stats.Labels) > 0 {
+		table := tablewriter.NewWriter(outputWriter)
+		table.SetHeader([]string{"Label", "Distinct Values", "Limit"})
+		table.SetBorder(false)
+		for _, label := range stats.Labels {
+			limit := "-"
+			if label.Limit > 0 {
+				limit = fmt.Sprintf("%d", label.Limit)
+			}
+			table.Append([]string{label.Key, fmt.Sprintf("%d", label.DistinctValues), limit})
+		}
+		table.Render()
+	}
+
+	return nil
+}
+
+// formatUtilization formats how much of a cardinality limit is used, yellow
+// from 75% and red from 90%
+func formatUtilization(cardinality, limit int) string {
+	if limit <= 0 {
+		return "-"
+	}
+	used := float64(cardinality) / float64(limit) * 100
+	str := fmt.Sprintf("%.1f%%", used)
+	switch {
+	case used >= 90:
+		return errorColor(str)
+	case used >= 75:
+		return warningColor(str)
+	}
+	return str
+}
+
 func formatVersionTable(info *VersionInfo) error {
 	table := tablewriter.NewWriter(outputWriter)
 	table.SetBorder(false)
@@ -177,9 +335,36 @@ func formatVersionTable(info *VersionInfo) error {
 	table.Append([]string{"OS/Arch:", fmt.Sprintf("%s/%s", info.OS, info.Arch)})
 
 	table.Render()
+
+	if len(info.Updates) > 0 {
+		fmt.Fprintf(outputWriter, "\nUpdates (%s channel):\n", info.Channel)
+		formatUpdateChecks(info.Updates)
+	}
 	return nil
 }
 
+func formatUpdateChecks(updates []UpdateCheck) {
+	for _, u := range updates {
+		installed := u.Installed
+		if installed == "" {
+			installed = "unknown"
+		}
+		switch {
+		case u.Error != "" && u.Installed == "":
+			fmt.Fprintln(outputWriter, warningColor(fmt.Sprintf("  ! %s: %s", u.Component, u.Error)))
+		case u.Latest == "":
+			fmt.Fprintln(outputWriter, warningColor(fmt.Sprintf("  ! %s %s: no release on the channel", u.Component, installed)))
+		case u.Current:
+			fmt.Fprintln(outputWriter, successColor(fmt.Sprintf("  ✓ %s %s is current", u.Component, installed)))
+		default:
+			fmt.Fprintln(outputWriter, warningColor(fmt.Sprintf("  ! %s %s: %s available", u.Component, installed, u.Latest)))
+		}
+		if u.ChangelogURL != "" && !u.Current {
+			fmt.Fprintf(outputWriter, "      Changelog: %s\n", u.ChangelogURL)
+		}
+	}
+}
+
 func formatDuration(d time.Duration) string {
 	days := int(d.Hours()) / 24
 	hours := int(d.Hours()) % 24
@@ -0,0 +1,194 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// diffContext is the number of unchanged lines shown around changes
+const diffContext = 3
+
+// diffLine is a line of a diff: ' ' unchanged, '-' removed or '+' added
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// UnifiedDiff returns the unified diff of two texts, empty when they are
+// equal
+func UnifiedDiff(oldName, newName, oldText, newText string) string {
+	lines := diffLines(splitLines(oldText), splitLines(newText))
+
+	// oldPos[i] and newPos[i] count the lines of each text before lines[i]
+	oldPos := make([]int, len(lines)+1)
+	newPos := make([]int, len(lines)+1)
+	for i, line := range lines {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if line.kind != '+' {
+			oldPos[i+1]++
+		}
+		if line.kind != '-' {
+			newPos[i+1]++
+		}
+	}
+
+	var b strings.Builder
+	for start := 0; ; {
+		first := start
+		for first < len(lines) && lines[first].kind == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+
+		// Changes closer than twice the context share a hunk
+		last := first
+		for i := first + 1; i < len(lines) && i-last <= 2*diffContext+1; i++ {
+			if lines[i].kind != ' ' {
+				last = i
+			}
+		}
+		from := max(first-diffContext, start)
+		to := min(last+diffContext+1, len(lines))
+
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n",
+			hunkRange(oldPos[from], oldPos[to]-oldPos[from]),
+			hunkRange(newPos[from], newPos[to]-newPos[from]))
+		for _, line := range lines[from:to] {
+			fmt.Fprintf(&b, "%c%s\n", line.kind, line.text)
+		}
+		start = to
+	}
+	return b.String()
+}
+
+// hunkRange formats the lines of a hunk in one text, which start after
+// line before. An empty range is numbered by the line it follows.
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// diffLines returns the lines of a and b as a shortest edit script, from
+// their longest common subsequence
+func diffLines(a, b []string) []diffLine {
+	// Common prefix and suffix lines need no table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	// lcs[i][j] is the length of the longest common subsequence of midA[i:]
+	// and midB[j:]
+	lcs := make([][]int, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]diffLine, 0, len(a)+len(b)-prefix-suffix)
+	for _, text := range a[:prefix] {
+		lines = append(lines, diffLine{' ', text})
+	}
+	i, j := 0, 0
+	for i < len(midA) || j < len(midB) {
+		switch {
+		case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+			lines = append(lines, diffLine{' ', midA[i]})
+			i++
+			j++
+		case j == len(midB) || (i < len(midA) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', midA[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', midB[j]})
+			j++
+		}
+	}
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{' ', text})
+	}
+	return lines
+}
+
+// splitLines splits text into lines without their line endings
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// FormatUnifiedDiff writes a unified diff to w, added lines in green,
+// removed ones in red and hunk headers in cyan
+func FormatUnifiedDiff(w io.Writer, diff string) {
+	for _, line := range splitLines(diff) {
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			fmt.Fprintln(w, line)
+		case strings.HasPrefix(line, "@@"):
+			fmt.Fprintln(w, infoColor(line))
+		case strings.HasPrefix(line, "+"):
+			fmt.Fprintln(w, successColor(line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Fprintln(w, errorColor(line))
+		default:
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+// ConfigUnifiedDiff returns the unified diff of two configurations decoded
+// from JSON, rendered as YAML. Settings with empty values are left out of
+// both, as DiffConfig does not count them as changes.
+func ConfigUnifiedDiff(oldName, newName string, running, proposed map[string]interface{}) (string, error) {
+	oldText, err := yaml.Marshal(pruneEmpty(running))
+	if err != nil {
+		return "", err
+	}
+	newText, err := yaml.Marshal(pruneEmpty(proposed))
+	if err != nil {
+		return "", err
+	}
+	return UnifiedDiff(oldName, newName, string(oldText), string(newText)), nil
+}
+
+// pruneEmpty returns config without its settings with empty values, nor
+// the sections left empty by removing them
+func pruneEmpty(config map[string]interface{}) map[string]interface{} {
+	pruned := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		if nested, ok := value.(map[string]interface{}); ok {
+			value = pruneEmpty(nested)
+		}
+		if !isEmptyValue(value) {
+			pruned[key] = value
+		}
+	}
+	return pruned
+}
@@ -0,0 +1,75 @@
+// Package prompt previews the changes commands are about to make to the agent
+// and asks for confirmation, so a configuration is not replaced by mistake.
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ErrAborted is returned when a change was not confirmed
+var ErrAborted = errors.New("aborted")
+
+// Confirmer asks whether to go on with a change after previewing it
+type Confirmer struct {
+	// In is where answers are read from, Out where previews and questions
+	// are written to
+	In  io.Reader
+	Out io.Writer
+
+	// Terminal is whether In is a terminal. Only terminals are asked, a
+	// script piping its input would answer blindly.
+	Terminal bool
+
+	// Force goes on without asking
+	Force bool
+
+	// NonInteractive never asks, refusing changes that are not forced
+	NonInteractive bool
+}
+
+// New returns a Confirmer asking on stdin and stderr, so the output of
+// commands can still be piped
+func New(force, nonInteractive bool) *Confirmer {
+	return &Confirmer{
+		In:             os.Stdin,
+		Out:            os.Stderr,
+		Terminal:       term.IsTerminal(int(os.Stdin.Fd())),
+		Force:          force,
+		NonInteractive: nonInteractive,
+	}
+}
+
+// Confirm writes the preview, if any, and asks whether to go on with
+// action, e.g. "apply this configuration". It returns nil to go on, and
+// ErrAborted unless the answer is yes. Without a terminal to ask, or with
+// NonInteractive, changes that are not forced fail.
+func (c *Confirmer) Confirm(action string, preview func(io.Writer)) error {
+	if preview != nil {
+		preview(c.Out)
+	}
+	if c.Force {
+		return nil
+	}
+	if c.NonInteractive || !c.Terminal {
+		return fmt.Errorf("refusing to %s without confirmation: rerun with --force", action)
+	}
+
+	fmt.Fprintf(c.Out, "%s%s? [y/N] ", strings.ToUpper(action[:1]), action[1:])
+	answer, err := bufio.NewReader(c.In).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(c.Out)
+		return ErrAborted
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return ErrAborted
+}
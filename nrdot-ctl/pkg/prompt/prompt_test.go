@@ -0,0 +1,65 @@
+package prompt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestConfirm(t *testing.T) {
+	preview := func(w io.Writer) { fmt.Fprintln(w, "+ metrics.interval: 30") }
+
+	tests := []struct {
+		name           string
+		input          string
+		terminal       bool
+		force          bool
+		nonInteractive bool
+		wantErr        error
+		wantRefused    bool
+		wantQuestion   bool
+	}{
+		{name: "yes", input: "y\n", terminal: true, wantQuestion: true},
+		{name: "yes spelled out", input: " YES \n", terminal: true, wantQuestion: true},
+		{name: "no", input: "n\n", terminal: true, wantErr: ErrAborted, wantQuestion: true},
+		{name: "default is no", input: "\n", terminal: true, wantErr: ErrAborted, wantQuestion: true},
+		{name: "end of input", input: "", terminal: true, wantErr: ErrAborted, wantQuestion: true},
+		{name: "force", terminal: true, force: true},
+		{name: "force without terminal", force: true, nonInteractive: true},
+		{name: "non-interactive", input: "y\n", terminal: true, nonInteractive: true, wantRefused: true},
+		{name: "no terminal", input: "y\n", wantRefused: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			c := &Confirmer{
+				In:             strings.NewReader(tt.input),
+				Out:            out,
+				Terminal:       tt.terminal,
+				Force:          tt.force,
+				NonInteractive: tt.nonInteractive,
+			}
+
+			err := c.Confirm("apply this configuration", preview)
+			switch {
+			case tt.wantRefused:
+				if err == nil || !strings.Contains(err.Error(), "--force") {
+					t.Errorf("Expected a refusal pointing to --force, got %v", err)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+
+			if !strings.HasPrefix(out.String(), "+ metrics.interval: 30\n") {
+				t.Errorf("Expected the preview first, got %q", out.String())
+			}
+			if asked := strings.Contains(out.String(), "Apply this configuration? [y/N] "); asked != tt.wantQuestion {
+				t.Errorf("Expected question %v, got %q", tt.wantQuestion, out.String())
+			}
+		})
+	}
+}
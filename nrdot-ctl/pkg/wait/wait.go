@@ -0,0 +1,106 @@
+// Package wait polls the agent API until the agent reaches a state, such as
+// healthy or running a configuration version, so scripts can block on it.
+package wait
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+)
+
+// Condition is a state of the agent to wait for
+type Condition struct {
+	// Name is the condition as given to Parse
+	Name string
+
+	// check reports whether the agent is in the state, and otherwise what
+	// it is in
+	check func(c *client.Client) (met bool, state string, err error)
+}
+
+// Parse parses a condition: healthy, collector-running or
+// config-version=N, which is met once version N or a later one is applied
+func Parse(s string) (Condition, error) {
+	switch {
+	case s == "healthy":
+		return Condition{Name: s, check: checkHealthy}, nil
+	case s == "collector-running":
+		return Condition{Name: s, check: checkCollectorRunning}, nil
+	case strings.HasPrefix(s, "config-version="):
+		version, err := strconv.Atoi(strings.TrimPrefix(s, "config-version="))
+		if err != nil || version < 1 {
+			return Condition{}, fmt.Errorf("invalid condition %q: the version must be a positive integer", s)
+		}
+		return Condition{Name: s, check: checkConfigVersion(version)}, nil
+	}
+	return Condition{}, fmt.Errorf("unknown condition %q, expected healthy, collector-running or config-version=N", s)
+}
+
+func checkHealthy(c *client.Client) (bool, string, error) {
+	health, err := c.GetHealth()
+	if err != nil {
+		return false, "", err
+	}
+	return health.Status == "healthy", "health " + health.Status, nil
+}
+
+func checkCollectorRunning(c *client.Client) (bool, string, error) {
+	status, err := c.GetStatus()
+	if err != nil {
+		return false, "", err
+	}
+	return status.State == "running", "collector " + status.State, nil
+}
+
+func checkConfigVersion(want int) func(c *client.Client) (bool, string, error) {
+	return func(c *client.Client) (bool, string, error) {
+		status, err := c.GetStatus()
+		if err != nil {
+			return false, "", err
+		}
+		// The version of an agent that has not applied a config yet is empty
+		version, err := strconv.Atoi(status.ConfigVersion)
+		if err != nil {
+			return false, fmt.Sprintf("config version %q", status.ConfigVersion), nil
+		}
+		return version >= want, "config version " + status.ConfigVersion, nil
+	}
+}
+
+// Until polls the agent every interval until it meets cond or ctx is done.
+// Failed requests are retried, since the agent may still be starting.
+// progress, if not nil, is called with the state of the agent after every
+// poll that did not meet cond, or with the error of a failed one.
+func Until(ctx context.Context, c *client.Client, cond Condition, interval time.Duration, progress func(state string, err error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last string
+	for {
+		met, state, err := cond.check(c)
+		if err == nil && met {
+			return nil
+		}
+		if err != nil {
+			last = err.Error()
+		} else {
+			last = state
+		}
+		if progress != nil {
+			progress(state, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("timed out waiting for %s (%s)", cond.Name, last)
+			}
+			return fmt.Errorf("stopped waiting for %s (%s)", cond.Name, last)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package wait
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-ctl/pkg/client"
+)
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{"healthy", "collector-running", "config-version=3"} {
+		cond, err := Parse(s)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", s, err)
+			continue
+		}
+		if cond.Name != s {
+			t.Errorf("Expected name %q, got %q", s, cond.Name)
+		}
+	}
+
+	for _, s := range []string{"", "ready", "config-version=", "config-version=0", "config-version=two"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Expected Parse(%q) to fail", s)
+		}
+	}
+}
+
+func TestUntil(t *testing.T) {
+	// The agent becomes healthy on the third poll, after failing the first
+	var polls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := polls.Add(1)
+		switch r.URL.Path {
+		case "/health":
+			if n == 1 {
+				http.Error(w, "starting", http.StatusBadGateway)
+				return
+			}
+			status := "unhealthy"
+			if n >= 3 {
+				status = "healthy"
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(client.HealthReport{Status: status})
+		case "/api/v1/status":
+			json.NewEncoder(w).Encode(client.Status{State: "running", ConfigVersion: "4"})
+		}
+	}))
+	defer server.Close()
+	c := client.New(server.URL)
+
+	healthy, _ := Parse("healthy")
+	var states []string
+	err := Until(context.Background(), c, healthy, time.Millisecond, func(state string, err error) {
+		if err != nil {
+			states = append(states, "error")
+			return
+		}
+		states = append(states, state)
+	})
+	if err != nil {
+		t.Fatalf("Until: %v", err)
+	}
+	if strings.Join(states, ",") != "error,health unhealthy" {
+		t.Errorf("Unexpected progress: %v", states)
+	}
+
+	for _, s := range []string{"collector-running", "config-version=3", "config-version=4"} {
+		cond, _ := Parse(s)
+		if err := Until(context.Background(), c, cond, time.Millisecond, nil); err != nil {
+			t.Errorf("Until(%s): %v", s, err)
+		}
+	}
+
+	// A version not applied yet times out, reporting the current one
+	cond, _ := Parse("config-version=5")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = Until(ctx, c, cond, time.Millisecond, nil)
+	if err == nil {
+		t.Fatal("Expected a timeout")
+	}
+	if !strings.Contains(err.Error(), "timed out waiting for config-version=5 (config version 4)") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
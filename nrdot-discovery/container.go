@@ -0,0 +1,296 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ContainerKey is the ServiceInfo.Additional key holding the
+// *ContainerInfo of a service discovered in a container
+const ContainerKey = "container"
+
+// ContainerInfo identifies the container a service runs in
+type ContainerInfo struct {
+	ID      string            `json:"id"`
+	Name    string            `json:"name"`
+	Image   string            `json:"image"`
+	Runtime string            `json:"runtime"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+// Container returns the container a service was discovered in, nil for
+// services running on the host
+func (svc *ServiceInfo) Container() *ContainerInfo {
+	container, _ := svc.Additional[ContainerKey].(*ContainerInfo)
+	return container
+}
+
+// containerSocket is a container engine API socket
+type containerSocket struct {
+	runtime string
+	path    string
+}
+
+// containerSockets are the engine sockets tried in order. Podman serves the
+// Docker Engine API on its socket; containerd only has a gRPC API, its
+// containers are found when it runs under Docker.
+var containerSockets = []containerSocket{
+	{runtime: "docker", path: "/var/run/docker.sock"},
+	{runtime: "podman", path: "/run/podman/podman.sock"},
+}
+
+// containerAPITimeout bounds one container engine API request
+const containerAPITimeout = 10 * time.Second
+
+// podNameLabel is the label the kubelet sets on the Docker containers of a
+// pod
+const podNameLabel = "io.kubernetes.pod.name"
+
+// Container image names, without registry, path and tag, that indicate a
+// service
+var containerImages = map[string]string{
+	"mysql":          "mysql",
+	"mariadb":        "mysql",
+	"percona":        "mysql",
+	"percona-server": "mysql",
+	"postgres":       "postgresql",
+	"postgresql":     "postgresql",
+	"redis":          "redis",
+	"nginx":          "nginx",
+	"httpd":          "apache",
+	"apache":         "apache",
+	"mongo":          "mongodb",
+	"mongodb":        "mongodb",
+	"elasticsearch":  "elasticsearch",
+	"rabbitmq":       "rabbitmq",
+	"memcached":      "memcached",
+	"kafka":          "kafka",
+	"cp-kafka":       "kafka",
+	"tomcat":         "tomcat",
+	"haproxy":        "haproxy",
+	"consul":         "consul",
+	"etcd":           "etcd",
+	"varnish":        "varnish",
+	"solr":           "solr",
+}
+
+// ContainerScanner finds services running in containers through the
+// container engine's socket API. Published ports are reached on the host,
+// unpublished ones on the container's address.
+type ContainerScanner struct {
+	logger   *zap.Logger
+	verbose  bool
+	sockets  []containerSocket
+	skipPods bool // containers of Kubernetes pods are found by the KubeletScanner
+}
+
+func NewContainerScanner(logger *zap.Logger) *ContainerScanner {
+	return &ContainerScanner{
+		logger:  logger,
+		sockets: containerSockets,
+	}
+}
+
+// dockerContainer is a container as listed by GET /containers/json
+type dockerContainer struct {
+	ID              string            `json:"Id"`
+	Names           []string          `json:"Names"`
+	Image           string            `json:"Image"`
+	Labels          map[string]string `json:"Labels"`
+	Ports           []dockerPort      `json:"Ports"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress         string `json:"IPAddress"`
+			GlobalIPv6Address string `json:"GlobalIPv6Address"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+type dockerPort struct {
+	IP          string `json:"IP"`
+	PrivatePort int    `json:"PrivatePort"`
+	PublicPort  int    `json:"PublicPort"`
+	Type        string `json:"Type"`
+}
+
+func (cs *ContainerScanner) Scan(ctx context.Context) ([]ServiceInfo, error) {
+	var services []ServiceInfo
+	for _, socket := range cs.sockets {
+		if _, err := os.Stat(socket.path); err != nil {
+			continue // Engine not installed
+		}
+		containers, err := cs.listContainers(ctx, socket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s containers: %w", socket.runtime, err)
+		}
+		for _, container := range containers {
+			if cs.skipPods && container.Labels[podNameLabel] != "" {
+				continue
+			}
+			if svc, ok := cs.containerService(container, socket); ok {
+				services = append(services, svc)
+			}
+		}
+	}
+	return services, nil
+}
+
+// listContainers lists the running containers of an engine
+func (cs *ContainerScanner) listContainers(ctx context.Context, socket containerSocket) ([]dockerContainer, error) {
+	client := &http.Client{
+		Timeout: containerAPITimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket.path)
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	// The host is ignored, requests go to the socket
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://engine/containers/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var containers []dockerContainer
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("failed to decode containers: %w", err)
+	}
+	return containers, nil
+}
+
+// containerService identifies the service in a container by its image, or
+// else by a well-known port it exposes
+func (cs *ContainerScanner) containerService(container dockerContainer, socket containerSocket) (ServiceInfo, bool) {
+	service, match := containerImages[imageName(container.Image)], container.Image
+	if service == "" {
+		for _, port := range container.Ports {
+			if s, ok := wellKnownPorts[port.PrivatePort]; ok && port.Type == "tcp" {
+				service, match = s, fmt.Sprintf("%d/%s", port.PrivatePort, port.Type)
+				break
+			}
+		}
+	}
+	if service == "" {
+		return ServiceInfo{}, false
+	}
+
+	info := &ContainerInfo{
+		ID:      container.ID,
+		Name:    containerName(container),
+		Image:   container.Image,
+		Runtime: socket.runtime,
+		Labels:  container.Labels,
+	}
+	svc := ServiceInfo{
+		Type:         service,
+		Endpoints:    containerEndpoints(container, service),
+		DiscoveredBy: []string{"container"},
+		Additional:   map[string]interface{}{ContainerKey: info},
+	}
+	if cs.verbose {
+		addEvidence(&svc, Evidence{
+			Method: "container",
+			Source: fmt.Sprintf("%s container %s", socket.runtime, info.Name),
+			Match:  match,
+		})
+	}
+	return svc, true
+}
+
+// containerEndpoints maps the TCP ports of a container to endpoints: the
+// host address of published ports, the container address of the others.
+// The service's well-known port comes first, as receivers use the first
+// endpoint, then the others by port.
+func containerEndpoints(container dockerContainer, service string) []Endpoint {
+	var containerIP string
+	for _, network := range container.NetworkSettings.Networks {
+		if network.IPAddress != "" {
+			containerIP = network.IPAddress
+			break
+		}
+		if containerIP == "" && network.GlobalIPv6Address != "" {
+			containerIP = network.GlobalIPv6Address
+		}
+	}
+
+	var endpoints []Endpoint
+	wellKnown := make(map[Endpoint]bool)
+	seen := make(map[int]bool)
+	for _, port := range container.Ports {
+		if port.Type != "tcp" {
+			continue
+		}
+		var endpoint Endpoint
+		switch {
+		case port.PublicPort > 0:
+			// Ports published on IPv4 and IPv6 are listed twice
+			if seen[port.PublicPort] {
+				continue
+			}
+			seen[port.PublicPort] = true
+			endpoint = Endpoint{Address: port.IP, Port: port.PublicPort, Protocol: "tcp"}
+		case containerIP != "":
+			endpoint = Endpoint{Address: containerIP, Port: port.PrivatePort, Protocol: "tcp"}
+		default:
+			continue
+		}
+		if endpoint.Address == "" {
+			endpoint.Address = "0.0.0.0"
+		}
+		wellKnown[endpoint] = wellKnownPorts[port.PrivatePort] == service
+		endpoints = append(endpoints, endpoint)
+	}
+
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		if wellKnown[endpoints[i]] != wellKnown[endpoints[j]] {
+			return wellKnown[endpoints[i]]
+		}
+		return endpoints[i].Port < endpoints[j].Port
+	})
+	return endpoints
+}
+
+// imageName returns the name of an image without registry, path, tag or
+// digest, e.g. mysql for docker.io/library/mysql:8.0
+func imageName(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, "/"); i >= 0 {
+		image = image[i+1:]
+	}
+	image, _, _ = strings.Cut(image, ":")
+	return strings.ToLower(image)
+}
+
+// containerName returns the name of a container without the leading slash,
+// its short ID if it has none
+func containerName(container dockerContainer) string {
+	for _, name := range container.Names {
+		if name = strings.TrimPrefix(name, "/"); name != "" {
+			return name
+		}
+	}
+	if len(container.ID) > 12 {
+		return container.ID[:12]
+	}
+	return container.ID
+}
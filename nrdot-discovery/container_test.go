@@ -0,0 +1,226 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// dockerContainers is a GET /containers/json listing: MySQL published on
+// IPv4 and IPv6, a cache found by its port, an unknown application and
+// the container of a Kubernetes pod
+const dockerContainers = `[
+  {
+    "Id": "8dfafdbc3a40a8f0a9e2b1c3d4e5f60718293a4b5c6d7e8f9a0b1c2d3e4f5a6b",
+    "Names": ["/db"],
+    "Image": "mysql:8.0",
+    "Labels": {"com.docker.compose.service": "db"},
+    "State": "running",
+    "Ports": [
+      {"PrivatePort": 33060, "Type": "tcp"},
+      {"IP": "0.0.0.0", "PrivatePort": 3306, "PublicPort": 3306, "Type": "tcp"},
+      {"IP": "::", "PrivatePort": 3306, "PublicPort": 3306, "Type": "tcp"}
+    ],
+    "NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.2", "GlobalIPv6Address": ""}}}
+  },
+  {
+    "Id": "2c4e6a8b0d1f3a5c7e9b2d4f6a8c0e1b3d5f7a9c2e4b6d8f0a1c3e5b7d9f2a4c",
+    "Names": ["/sessions"],
+    "Image": "registry.example.com/team/session-store@sha256:0f1e2d3c",
+    "Ports": [
+      {"PrivatePort": 9121, "Type": "tcp"},
+      {"PrivatePort": 6379, "Type": "tcp"},
+      {"PrivatePort": 6379, "Type": "udp"}
+    ],
+    "NetworkSettings": {"Networks": {"app": {"IPAddress": "", "GlobalIPv6Address": "fd00::5"}}}
+  },
+  {
+    "Id": "5e7a9c1b3d5f7e9a1c3b5d7f9e1a3c5b7d9f1e3a5c7b9d1f3e5a7c9b1d3f5e7a",
+    "Names": ["/worker"],
+    "Image": "example/worker:2",
+    "Ports": [{"PrivatePort": 8080, "Type": "tcp"}],
+    "NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.4"}}}
+  },
+  {
+    "Id": "9b1d3f5e7a9c1b3d5f7e9a1c3b5d7f9e1a3c5b7d9f1e3a5c7b9d1f3e5a7c9b1d",
+    "Names": ["/k8s_redis_redis-0_cache_0c5f6a2e-1111_0"],
+    "Image": "redis:7.2",
+    "Labels": {"io.kubernetes.pod.name": "redis-0"},
+    "Ports": [],
+    "NetworkSettings": {"Networks": {}}
+  }
+]`
+
+// dockerEngine serves containers on /containers/json on a Unix socket,
+// returning a scanner using it
+func dockerEngine(t *testing.T, status int, containers string) *ContainerScanner {
+	t.Helper()
+	// Socket paths are limited to about 100 bytes, shorter than some
+	// t.TempDir paths
+	dir, err := os.MkdirTemp("", "engine")
+	if err != nil {
+		t.Fatalf("Failed to create socket directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "docker.sock")
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(containers))
+	}))
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+
+	cs := NewContainerScanner(zap.NewNop())
+	cs.sockets = []containerSocket{
+		{runtime: "podman", path: filepath.Join(dir, "missing.sock")},
+		{runtime: "docker", path: path},
+	}
+	return cs
+}
+
+func TestContainerScanner_Scan(t *testing.T) {
+	cs := dockerEngine(t, http.StatusOK, dockerContainers)
+
+	services, err := cs.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to scan: %v", err)
+	}
+	want := []ServiceInfo{
+		{
+			// Published ports once, then unpublished ones on the
+			// container's address
+			Type: "mysql",
+			Endpoints: []Endpoint{
+				{Address: "0.0.0.0", Port: 3306, Protocol: "tcp"},
+				{Address: "172.17.0.2", Port: 33060, Protocol: "tcp"},
+			},
+			DiscoveredBy: []string{"container"},
+			Additional: map[string]interface{}{
+				ContainerKey: &ContainerInfo{
+					ID:      "8dfafdbc3a40a8f0a9e2b1c3d4e5f60718293a4b5c6d7e8f9a0b1c2d3e4f5a6b",
+					Name:    "db",
+					Image:   "mysql:8.0",
+					Runtime: "docker",
+					Labels:  map[string]string{"com.docker.compose.service": "db"},
+				},
+			},
+		},
+		{
+			// Found by port, on the container's IPv6 address
+			Type: "redis",
+			Endpoints: []Endpoint{
+				{Address: "fd00::5", Port: 6379, Protocol: "tcp"},
+				{Address: "fd00::5", Port: 9121, Protocol: "tcp"},
+			},
+			DiscoveredBy: []string{"container"},
+			Additional: map[string]interface{}{
+				ContainerKey: &ContainerInfo{
+					ID:      "2c4e6a8b0d1f3a5c7e9b2d4f6a8c0e1b3d5f7a9c2e4b6d8f0a1c3e5b7d9f2a4c",
+					Name:    "sessions",
+					Image:   "registry.example.com/team/session-store@sha256:0f1e2d3c",
+					Runtime: "docker",
+				},
+			},
+		},
+		{
+			Type:         "redis",
+			DiscoveredBy: []string{"container"},
+			Additional: map[string]interface{}{
+				ContainerKey: &ContainerInfo{
+					ID:      "9b1d3f5e7a9c1b3d5f7e9a1c3b5d7f9e1a3c5b7d9f1e3a5c7b9d1f3e5a7c9b1d",
+					Name:    "k8s_redis_redis-0_cache_0c5f6a2e-1111_0",
+					Image:   "redis:7.2",
+					Runtime: "docker",
+					Labels:  map[string]string{"io.kubernetes.pod.name": "redis-0"},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(services, want) {
+		t.Errorf("Expected\n%+v\ngot\n%+v", want, services)
+	}
+
+	// Pods are left to the kubelet scanner when it is enabled
+	cs.skipPods = true
+	services, err = cs.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to scan: %v", err)
+	}
+	if len(services) != 2 {
+		t.Errorf("Expected the pod's container to be skipped, got %d services", len(services))
+	}
+}
+
+func TestContainerScanner_ScanErrors(t *testing.T) {
+	for name, engine := range map[string]*ContainerScanner{
+		"engine error":      dockerEngine(t, http.StatusInternalServerError, `{"message": "page not found"}`),
+		"truncated listing": dockerEngine(t, http.StatusOK, `[{"Id": "8dfa`),
+		"not a list":        dockerEngine(t, http.StatusOK, `{"Id": "8dfa"}`),
+	} {
+		if _, err := engine.Scan(context.Background()); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestImageName(t *testing.T) {
+	for image, want := range map[string]string{
+		"mysql":                            "mysql",
+		"mysql:8.0":                        "mysql",
+		"docker.io/library/postgres:16":    "postgres",
+		"bitnami/redis:7.2.4-debian-12-r9": "redis",
+		"localhost:5000/team/nginx:1.25":   "nginx",
+		"localhost:5000/mariadb":           "mariadb",
+		"quay.io/prometheus/node-exporter": "node-exporter",
+		"Elasticsearch:8.11.1":             "elasticsearch",
+		"httpd@sha256:0f1e2d3c4b5a":        "httpd",
+	} {
+		if got := imageName(image); got != want {
+			t.Errorf("imageName(%q): expected %q, got %q", image, want, got)
+		}
+	}
+}
+
+func TestContainerImages(t *testing.T) {
+	for image, want := range map[string]string{
+		"mariadb:11":                    "mysql",
+		"percona/percona-server:8.0":    "mysql",
+		"docker.io/library/postgres:16": "postgresql",
+		"httpd:2.4":                     "apache",
+		"mongo:7":                       "mongodb",
+		"confluentinc/cp-kafka:7.5.0":   "kafka",
+		"docker.elastic.co/elasticsearch/elasticsearch:8.11.1": "elasticsearch",
+		"example/worker:2": "",
+	} {
+		if got := containerImages[imageName(image)]; got != want {
+			t.Errorf("%s: expected %q, got %q", image, want, got)
+		}
+	}
+}
+
+func TestContainerName(t *testing.T) {
+	id := "8dfafdbc3a40a8f0a9e2b1c3d4e5f60718293a4b5c6d7e8f9a0b1c2d3e4f5a6b"
+	if got := containerName(dockerContainer{ID: id, Names: []string{"/db", "/app/db"}}); got != "db" {
+		t.Errorf("Expected the first name, got %q", got)
+	}
+	if got := containerName(dockerContainer{ID: id}); got != "8dfafdbc3a40" {
+		t.Errorf("Expected the short ID, got %q", got)
+	}
+}
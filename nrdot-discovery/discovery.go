@@ -5,16 +5,15 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
-	"io/ioutil"
 	"net"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
 	"github.com/newrelic/nrdot-host/nrdot-telemetry/process"
 	"go.uber.org/zap"
 )
@@ -30,6 +29,7 @@ type ServiceInfo struct {
 	ConfigPaths  []string                 `json:"config_paths,omitempty"`
 	PackageInfo  *PackageInfo             `json:"package_info,omitempty"`
 	Additional   map[string]interface{}   `json:"additional_info,omitempty"`
+	Health       *ServiceHealth           `json:"health,omitempty"`
 }
 
 // Endpoint represents a service endpoint
@@ -37,6 +37,19 @@ type Endpoint struct {
 	Address  string `json:"address"`
 	Port     int    `json:"port"`
 	Protocol string `json:"protocol"`
+	PID      int32  `json:"pid,omitempty"` // process listening on it, when known
+}
+
+// EvidenceKey is the ServiceInfo.Additional key holding the []Evidence
+// behind a detection when verbose discovery is enabled, see SetVerbose
+const EvidenceKey = "evidence"
+
+// Evidence is the raw input a discovery method matched, kept so users can
+// understand and dispute a classification
+type Evidence struct {
+	Method string `json:"method"`
+	Source string `json:"source"`
+	Match  string `json:"match"`
 }
 
 // PackageInfo represents package manager information
@@ -53,19 +66,56 @@ type ServiceDiscovery struct {
 	portScanner      *PortScanner
 	configLocator    *ConfigLocator
 	packageDetector  *PackageDetector
+	containerScanner *ContainerScanner
+	kubeletScanner   *KubeletScanner // nil unless SetKubernetes was called
+	lanScanner       *LANScanner     // nil unless SetLAN was called
+	versionProber    *VersionProber  // nil unless SetVersionProbes was called
 	privilegedHelper string // Path to privileged helper binary
+
+	// Result persistence across restarts, see SetWorkDir
+	mu             sync.Mutex
+	workDir        string
+	eventHandler   func(models.Event)
+	startupChecked bool
+	startupDiff    *ServiceDiff
+}
+
+// SetVerbose enables recording the raw evidence behind each detection (the
+// matched process cmdline, /proc/net entry, config path, package line or
+// container image)
+// under ServiceInfo.Additional[EvidenceKey]. Call it before Discover.
+func (sd *ServiceDiscovery) SetVerbose(verbose bool) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.processScanner.verbose = verbose
+	sd.portScanner.verbose = verbose
+	sd.configLocator.verbose = verbose
+	sd.packageDetector.verbose = verbose
+	sd.containerScanner.verbose = verbose
+	if sd.kubeletScanner != nil {
+		sd.kubeletScanner.verbose = verbose
+	}
+	if sd.lanScanner != nil {
+		sd.lanScanner.verbose = verbose
+	}
+	if sd.versionProber != nil {
+		sd.versionProber.verbose = verbose
+	}
 }
 
 // NewServiceDiscovery creates a new service discovery instance
 func NewServiceDiscovery(logger *zap.Logger) *ServiceDiscovery {
-	return &ServiceDiscovery{
+	sd := &ServiceDiscovery{
 		logger:           logger,
 		processScanner:   NewProcessScanner(logger),
 		portScanner:      NewPortScanner(logger),
 		configLocator:    NewConfigLocator(logger),
 		packageDetector:  NewPackageDetector(logger),
+		containerScanner: NewContainerScanner(logger),
 		privilegedHelper: "/usr/local/bin/nrdot-helper",
 	}
+	sd.portScanner.helper = sd.privilegedHelper
+	return sd
 }
 
 // Discover performs comprehensive service discovery
@@ -75,10 +125,12 @@ func (sd *ServiceDiscovery) Discover(ctx context.Context) ([]ServiceInfo, error)
 
 	// Run all discovery methods in parallel
 	var wg sync.WaitGroup
-	results := make(chan []ServiceInfo, 4)
-	errors := make(chan error, 4)
+	results := make(chan []ServiceInfo, 7)
+	errors := make(chan error, 7)
 
-	// Process scanning
+	// Process scanning; without it services cannot be told apart from
+	// stopped ones
+	var processScanned bool
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -87,6 +139,7 @@ func (sd *ServiceDiscovery) Discover(ctx context.Context) ([]ServiceInfo, error)
 			errors <- fmt.Errorf("process scan failed: %w", err)
 			return
 		}
+		processScanned = true
 		results <- services
 	}()
 
@@ -126,6 +179,54 @@ func (sd *ServiceDiscovery) Discover(ctx context.Context) ([]ServiceInfo, error)
 		results <- services
 	}()
 
+	// Container detection
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		services, err := sd.containerScanner.Scan(ctx)
+		if err != nil {
+			errors <- fmt.Errorf("container scan failed: %w", err)
+			return
+		}
+		results <- services
+	}()
+
+	// Kubernetes pod detection, when enabled
+	sd.mu.Lock()
+	kubeletScanner := sd.kubeletScanner
+	sd.mu.Unlock()
+	if kubeletScanner != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			services, err := kubeletScanner.Scan(ctx)
+			if err != nil {
+				sd.logger.Warn("Kubernetes pod scan failed", zap.Error(err))
+				errors <- fmt.Errorf("kubernetes scan failed: %w", err)
+				return
+			}
+			results <- services
+		}()
+	}
+
+	// Local network devices, when enabled
+	sd.mu.Lock()
+	lanScanner := sd.lanScanner
+	sd.mu.Unlock()
+	if lanScanner != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			services, err := lanScanner.Scan(ctx)
+			if err != nil {
+				sd.logger.Warn("LAN scan failed", zap.Error(err))
+				errors <- fmt.Errorf("lan scan failed: %w", err)
+				return
+			}
+			results <- services
+		}()
+	}
+
 	// Wait for all scans to complete
 	go func() {
 		wg.Wait()
@@ -155,6 +256,17 @@ func (sd *ServiceDiscovery) Discover(ctx context.Context) ([]ServiceInfo, error)
 				if len(svc.ConfigPaths) > 0 {
 					existing.ConfigPaths = mergeStrings(existing.ConfigPaths, svc.ConfigPaths)
 				}
+				for _, key := range []string{ContainerKey, KubernetesKey, LocationKey, LANKey} {
+					if value, ok := svc.Additional[key]; ok && existing.Additional[key] == nil {
+						if existing.Additional == nil {
+							existing.Additional = make(map[string]interface{})
+						}
+						existing.Additional[key] = value
+					}
+				}
+				if evidence, ok := svc.Additional[EvidenceKey].([]Evidence); ok {
+					addEvidence(existing, evidence...)
+				}
 			} else {
 				svc.Confidence = sd.calculateConfidence(svc.DiscoveredBy)
 				allServices[key] = &svc
@@ -168,20 +280,63 @@ func (sd *ServiceDiscovery) Discover(ctx context.Context) ([]ServiceInfo, error)
 		finalServices = append(finalServices, *svc)
 	}
 
+	// Ports tied to a process join what the process scan found of it
+	finalServices = sd.mergeByProcess(finalServices)
+
+	// Installed but stopped services are reported with their health so
+	// no receivers are generated for them. The results channel is closed
+	// after every scan finished, so processScanned is final.
+	checkHealth(ctx, finalServices, processScanned)
+
+	// Versions from the services themselves, when enabled, once health
+	// tells which ports are open
+	sd.mu.Lock()
+	versionProber := sd.versionProber
+	sd.mu.Unlock()
+	if versionProber != nil {
+		versionProber.Probe(ctx, finalServices)
+	}
+
 	duration := time.Since(startTime)
 	sd.logger.Info("Service discovery completed",
 		zap.Int("services_found", len(finalServices)),
 		zap.Duration("duration", duration))
 
+	sd.recordResult(finalServices, startTime)
+
 	return finalServices, nil
 }
 
-// calculateConfidence determines confidence level based on discovery methods
+// confidenceScores weigh discovery methods by what they tell about a
+// service: a matching process, container image or pod is evidence of the
+// service itself, while a listening port, config file or package alone
+// may belong to anything. Other methods score 1.
+var confidenceScores = map[string]int{
+	"process":     2,
+	"container":   2,
+	"kubernetes":  2,
+	"port":        1,
+	"config_file": 1,
+	"package":     1,
+}
+
+// calculateConfidence determines confidence level based on the scores of
+// the discovery methods: HIGH from 4, e.g. process, port and config file,
+// MEDIUM from 2, e.g. a process alone or a port and a config file, and LOW
+// for a single weak signal such as a listening port alone
 func (sd *ServiceDiscovery) calculateConfidence(methods []string) string {
-	count := len(methods)
-	if count >= 3 {
+	score := 0
+	for _, method := range methods {
+		if s, ok := confidenceScores[method]; ok {
+			score += s
+		} else {
+			score++
+		}
+	}
+	switch {
+	case score >= 4:
 		return "HIGH"
-	} else if count == 2 {
+	case score >= 2:
 		return "MEDIUM"
 	}
 	return "LOW"
@@ -191,6 +346,7 @@ func (sd *ServiceDiscovery) calculateConfidence(methods []string) string {
 type ProcessScanner struct {
 	logger   *zap.Logger
 	detector *process.ServiceDetector
+	verbose  bool
 }
 
 func NewProcessScanner(logger *zap.Logger) *ProcessScanner {
@@ -248,6 +404,13 @@ func (ps *ProcessScanner) Scan(ctx context.Context) ([]ServiceInfo, error) {
 			}
 
 			svc.Additional = metadata
+			if ps.verbose {
+				addEvidence(&svc, Evidence{
+					Method: "process",
+					Source: fmt.Sprintf("/proc/%d/cmdline", proc.PID),
+					Match:  fmt.Sprintf("%s (%s confidence)", proc.Cmdline, confidence),
+				})
+			}
 			services = append(services, svc)
 		}
 	}
@@ -255,13 +418,22 @@ func (ps *ProcessScanner) Scan(ctx context.Context) ([]ServiceInfo, error) {
 	return services, nil
 }
 
-// PortScanner scans network ports to identify services
+// PortScanner scans network ports to identify services, and the processes
+// listening on them
 type PortScanner struct {
-	logger *zap.Logger
+	logger   *zap.Logger
+	detector *process.ServiceDetector
+	procRoot string
+	helper   string // privileged helper resolving other users' sockets
+	verbose  bool
 }
 
 func NewPortScanner(logger *zap.Logger) *PortScanner {
-	return &PortScanner{logger: logger}
+	return &PortScanner{
+		logger:   logger,
+		detector: process.NewServiceDetector(),
+		procRoot: "/proc",
+	}
 }
 
 // Well-known ports for services
@@ -278,6 +450,12 @@ var wellKnownPorts = map[int]string{
 	9092:  "kafka",
 	2181:  "zookeeper",
 	9042:  "cassandra",
+	8005:  "tomcat",  // shutdown port
+	8404:  "haproxy", // stats
+	8500:  "consul",
+	2379:  "etcd",
+	6081:  "varnish",
+	8983:  "solr",
 }
 
 func (ps *PortScanner) Scan(ctx context.Context) ([]ServiceInfo, error) {
@@ -295,22 +473,76 @@ func (ps *PortScanner) Scan(ctx context.Context) ([]ServiceInfo, error) {
 	// Combine all ports
 	allPorts := append(tcpPorts, tcp6Ports...)
 
+	// The processes listening, as far as they can be told
+	owners := ps.resolveOwners(ctx, allPorts)
+
 	var services []ServiceInfo
 	detectedServices := make(map[string]bool)
+	ownedServices := make(map[string]int) // service/pid -> index in services
 
 	for _, port := range allPorts {
-		if service, exists := wellKnownPorts[port.Port]; exists && !detectedServices[service] {
+		owner := owners[port.Inode]
+
+		// A known process names the service on any port; otherwise the
+		// port itself has to be a well-known one
+		service, byProcess := "", false
+		if owner != nil {
+			service, _ = ps.detector.DetectService(owner.info)
+			byProcess = service != ""
+		}
+		if service == "" {
+			service = wellKnownPorts[port.Port]
+		}
+		if service == "" {
+			continue
+		}
+
+		endpoint := Endpoint{
+			Address:  port.Address,
+			Port:     port.Port,
+			Protocol: "tcp",
+		}
+		if owner == nil {
+			if detectedServices[service] {
+				continue
+			}
 			detectedServices[service] = true
-			
-			services = append(services, ServiceInfo{
-				Type: service,
-				Endpoints: []Endpoint{{
-					Address:  port.Address,
-					Port:     port.Port,
-					Protocol: "tcp",
-				}},
-				DiscoveredBy: []string{"port"},
-			})
+		} else {
+			endpoint.PID = owner.info.PID
+
+			// Every port of a process makes one service
+			key := fmt.Sprintf("%s/%d", service, owner.info.PID)
+			if i, ok := ownedServices[key]; ok {
+				services[i].Endpoints = addEndpoint(services[i].Endpoints, endpoint)
+				if ps.verbose {
+					addEvidence(&services[i], portEvidence(port, owner)...)
+				}
+				continue
+			}
+			ownedServices[key] = len(services)
+		}
+
+		svc := ServiceInfo{
+			Type:         service,
+			Endpoints:    []Endpoint{endpoint},
+			DiscoveredBy: []string{"port"},
+		}
+		if byProcess {
+			svc.ProcessInfo = owner.info
+			svc.Version = owner.version
+			svc.ConfigPaths = owner.configPaths
+			svc.DiscoveredBy = append(svc.DiscoveredBy, "process")
+		}
+		if ps.verbose {
+			addEvidence(&svc, portEvidence(port, owner)...)
+		}
+		services = append(services, svc)
+	}
+
+	// The service's own ports first, so receivers use them
+	for i := range services {
+		if services[i].ProcessInfo != nil {
+			sortEndpoints(services[i].Endpoints, ps.detector.GetServiceMetadata(services[i].Type))
 		}
 	}
 
@@ -321,6 +553,9 @@ type ListeningPort struct {
 	Address string
 	Port    int
 	State   string
+	Inode   uint64 // socket inode, 0 if unknown
+	Source  string // /proc/net file the entry was read from
+	Raw     string // Unparsed /proc/net entry
 }
 
 func (ps *PortScanner) parseProcNet(path string) ([]ListeningPort, error) {
@@ -337,7 +572,8 @@ func (ps *PortScanner) parseProcNet(path string) ([]ListeningPort, error) {
 	scanner.Scan()
 	
 	for scanner.Scan() {
-		fields := strings.Fields(scanner.Text())
+		line := scanner.Text()
+		fields := strings.Fields(line)
 		if len(fields) < 4 {
 			continue
 		}
@@ -360,6 +596,12 @@ func (ps *PortScanner) parseProcNet(path string) ([]ListeningPort, error) {
 		ipHex := parts[0]
 		ip := ps.hexToIP(ipHex)
 
+		// Parse socket inode, which ties the port to a process
+		var inode uint64
+		if len(fields) > 9 {
+			inode, _ = strconv.ParseUint(fields[9], 10, 64)
+		}
+
 		// Parse state (0A = LISTEN)
 		state := fields[3]
 		if state == "0A" {
@@ -367,6 +609,9 @@ func (ps *PortScanner) parseProcNet(path string) ([]ListeningPort, error) {
 				Address: ip,
 				Port:    int(port),
 				State:   "LISTEN",
+				Inode:   inode,
+				Source:  path,
+				Raw:     strings.TrimSpace(line),
 			})
 		}
 	}
@@ -374,21 +619,30 @@ func (ps *PortScanner) parseProcNet(path string) ([]ListeningPort, error) {
 	return ports, scanner.Err()
 }
 
+// hexToIP decodes an address of /proc/net/tcp or /proc/net/tcp6: 4 or 16
+// bytes in hex, stored as 32-bit words in host byte order, little-endian on
+// the architectures NRDOT runs on. IPv4-mapped IPv6 addresses, which
+// dual-stack sockets report, are returned as IPv4. Malformed addresses are
+// returned as-is.
 func (ps *PortScanner) hexToIP(hexStr string) string {
-	if len(hexStr) == 8 {
-		// IPv4
-		bytes, _ := hex.DecodeString(hexStr)
-		if len(bytes) == 4 {
-			return fmt.Sprintf("%d.%d.%d.%d", bytes[3], bytes[2], bytes[1], bytes[0])
+	bytes, err := hex.DecodeString(hexStr)
+	if err != nil || (len(bytes) != net.IPv4len && len(bytes) != net.IPv6len) {
+		return hexStr
+	}
+
+	ip := make(net.IP, len(bytes))
+	for word := 0; word < len(bytes); word += 4 {
+		for i := 0; i < 4; i++ {
+			ip[word+i] = bytes[word+3-i]
 		}
 	}
-	// Return as-is for IPv6 or errors
-	return hexStr
+	return ip.String()
 }
 
 // ConfigLocator finds services by configuration files
 type ConfigLocator struct {
-	logger *zap.Logger
+	logger  *zap.Logger
+	verbose bool
 }
 
 func NewConfigLocator(logger *zap.Logger) *ConfigLocator {
@@ -409,6 +663,14 @@ var configIndicators = map[string]string{
 	"/etc/rabbitmq":      "rabbitmq",
 	"/etc/kafka":         "kafka",
 	"/etc/cassandra":     "cassandra",
+	"/etc/tomcat":        "tomcat",
+	"/etc/tomcat9":       "tomcat",
+	"/etc/tomcat10":      "tomcat",
+	"/etc/haproxy":       "haproxy",
+	"/etc/consul.d":      "consul",
+	"/etc/etcd":          "etcd",
+	"/etc/varnish":       "varnish",
+	"/var/solr":          "solr",
 }
 
 func (cl *ConfigLocator) Scan(ctx context.Context) ([]ServiceInfo, error) {
@@ -419,47 +681,92 @@ func (cl *ConfigLocator) Scan(ctx context.Context) ([]ServiceInfo, error) {
 		if _, err := os.Stat(path); err == nil && !detectedServices[service] {
 			detectedServices[service] = true
 			
-			services = append(services, ServiceInfo{
+			svc := ServiceInfo{
 				Type:         service,
 				DiscoveredBy: []string{"config_file"},
 				ConfigPaths:  []string{path},
-			})
+			}
+			if cl.verbose {
+				addEvidence(&svc, Evidence{
+					Method: "config_file",
+					Source: "filesystem",
+					Match:  path,
+				})
+			}
+			services = append(services, svc)
 		}
 	}
 
 	return services, nil
 }
 
-// PackageDetector finds services via package managers
+const (
+	// defaultPackageScanInterval is the minimum time between package manager
+	// queries; scans in between reuse the last package list
+	defaultPackageScanInterval = 15 * time.Minute
+
+	// defaultPackageScanTimeout bounds one package manager query
+	defaultPackageScanTimeout = 30 * time.Second
+
+	// packageScanNiceness is the CPU niceness package managers run with
+	packageScanNiceness = 10
+)
+
+// PackageDetector finds services via package managers. Listing every
+// installed package is expensive on hosts with large package sets, so
+// queries run niced, with a timeout, and at most once per minimum interval.
 type PackageDetector struct {
-	logger *zap.Logger
+	logger  *zap.Logger
+	verbose bool
+
+	mu          sync.Mutex
+	minInterval time.Duration
+	timeout     time.Duration
+	lastQuery   time.Time // zero before the first query
+	packages    *packageList
+}
+
+// packageList is the output of a package manager query
+type packageList struct {
+	manager string
+	command string
+	output  []byte
 }
 
 func NewPackageDetector(logger *zap.Logger) *PackageDetector {
-	return &PackageDetector{logger: logger}
+	return &PackageDetector{
+		logger:      logger,
+		minInterval: defaultPackageScanInterval,
+		timeout:     defaultPackageScanTimeout,
+	}
+}
+
+// SetPackageScanLimits sets the minimum time between package manager queries
+// and the timeout of one query; zero keeps the default
+func (sd *ServiceDiscovery) SetPackageScanLimits(minInterval, timeout time.Duration) {
+	pd := sd.packageDetector
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+	if minInterval > 0 {
+		pd.minInterval = minInterval
+	}
+	if timeout > 0 {
+		pd.timeout = timeout
+	}
 }
 
 func (pd *PackageDetector) Scan(ctx context.Context) ([]ServiceInfo, error) {
 	var services []ServiceInfo
 
-	// Detect package manager
-	var manager string
-	var cmd *exec.Cmd
-
-	if _, err := exec.LookPath("dpkg"); err == nil {
-		manager = "apt"
-		cmd = exec.CommandContext(ctx, "dpkg", "-l")
-	} else if _, err := exec.LookPath("rpm"); err == nil {
-		manager = "yum"
-		cmd = exec.CommandContext(ctx, "rpm", "-qa")
-	} else {
-		return services, nil // No supported package manager
-	}
-
-	output, err := cmd.Output()
+	packages, err := pd.listPackages(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query packages: %w", err)
+		return nil, err
+	}
+	if packages == nil {
+		return services, nil // No supported package manager
 	}
+	manager := packages.manager
+	output := packages.output
 
 	// Parse package list
 	scanner := bufio.NewScanner(strings.NewReader(string(output)))
@@ -484,6 +791,11 @@ func (pd *PackageDetector) Scan(ctx context.Context) ([]ServiceInfo, error) {
 			{"elasticsearch", "elasticsearch"},
 			{"rabbitmq-server", "rabbitmq"},
 			{"kafka", "kafka"},
+			{"tomcat", "tomcat"},
+			{"haproxy", "haproxy"},
+			{"consul", "consul"},
+			{"etcd", "etcd"},
+			{"varnish", "varnish"},
 		} {
 			if strings.Contains(line, pattern.name) && !detectedServices[pattern.service] {
 				detectedServices[pattern.service] = true
@@ -491,7 +803,7 @@ func (pd *PackageDetector) Scan(ctx context.Context) ([]ServiceInfo, error) {
 				// Extract version if possible
 				version := pd.extractVersion(line, manager)
 				
-				services = append(services, ServiceInfo{
+				svc := ServiceInfo{
 					Type:         pattern.service,
 					DiscoveredBy: []string{"package"},
 					PackageInfo: &PackageInfo{
@@ -499,7 +811,15 @@ func (pd *PackageDetector) Scan(ctx context.Context) ([]ServiceInfo, error) {
 						Version: version,
 						Manager: manager,
 					},
-				})
+				}
+				if pd.verbose {
+					addEvidence(&svc, Evidence{
+						Method: "package",
+						Source: packages.command,
+						Match:  strings.TrimSpace(line),
+					})
+				}
+				services = append(services, svc)
 			}
 		}
 	}
@@ -507,6 +827,59 @@ func (pd *PackageDetector) Scan(ctx context.Context) ([]ServiceInfo, error) {
 	return services, nil
 }
 
+// listPackages returns the installed packages, querying the package manager
+// only if the last query is older than the minimum interval. A failed query
+// also waits out the interval, keeping the previous list. nil means no
+// supported package manager.
+func (pd *PackageDetector) listPackages(ctx context.Context) (*packageList, error) {
+	// Held during the query so concurrent scans share it
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+
+	if !pd.lastQuery.IsZero() && time.Since(pd.lastQuery) < pd.minInterval {
+		return pd.packages, nil
+	}
+	pd.lastQuery = time.Now()
+
+	var manager string
+	var args []string
+	if _, err := exec.LookPath("dpkg"); err == nil {
+		manager = "apt"
+		args = []string{"dpkg", "-l"}
+	} else if _, err := exec.LookPath("rpm"); err == nil {
+		manager = "yum"
+		args = []string{"rpm", "-qa"}
+	} else {
+		pd.packages = nil
+		return nil, nil
+	}
+	command := strings.Join(args, " ")
+
+	// Lower the query's CPU priority where nice is available
+	if nice, err := exec.LookPath("nice"); err == nil {
+		args = append([]string{nice, "-n", strconv.Itoa(packageScanNiceness)}, args...)
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, pd.timeout)
+	defer cancel()
+
+	started := time.Now()
+	output, err := exec.CommandContext(queryCtx, args[0], args[1:]...).Output()
+	if err != nil {
+		if queryCtx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", pd.timeout)
+		}
+		return nil, fmt.Errorf("failed to query packages with %s: %w", command, err)
+	}
+	pd.logger.Debug("Queried installed packages",
+		zap.String("command", command),
+		zap.Int("bytes", len(output)),
+		zap.Duration("duration", time.Since(started)))
+
+	pd.packages = &packageList{manager: manager, command: command, output: output}
+	return pd.packages, nil
+}
+
 func (pd *PackageDetector) extractVersion(line, manager string) string {
 	if manager == "apt" {
 		// dpkg format: ii  package-name  version  description
@@ -525,6 +898,14 @@ func (pd *PackageDetector) extractVersion(line, manager string) string {
 }
 
 // Helper functions
+func addEvidence(svc *ServiceInfo, evidence ...Evidence) {
+	if svc.Additional == nil {
+		svc.Additional = make(map[string]interface{})
+	}
+	existing, _ := svc.Additional[EvidenceKey].([]Evidence)
+	svc.Additional[EvidenceKey] = append(existing, evidence...)
+}
+
 func mergeStrings(a, b []string) []string {
 	seen := make(map[string]bool)
 	result := []string{}
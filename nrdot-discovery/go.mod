@@ -0,0 +1,31 @@
+module github.com/newrelic/nrdot-host/nrdot-discovery
+
+go 1.21
+
+require (
+	github.com/newrelic/nrdot-host/nrdot-common v0.0.0-00010101000000-000000000000
+	github.com/newrelic/nrdot-host/nrdot-telemetry v0.0.0-00010101000000-000000000000
+	go.uber.org/zap v1.27.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/gogo/protobuf v1.3.2 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	go.opentelemetry.io/collector/pdata v1.3.0 // indirect
+	go.uber.org/multierr v1.11.0 // indirect
+	golang.org/x/net v0.20.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240123012728-ef4313101c80 // indirect
+	google.golang.org/grpc v1.62.0 // indirect
+	google.golang.org/protobuf v1.32.0 // indirect
+)
+
+replace (
+	github.com/newrelic/nrdot-host/nrdot-common => ../nrdot-common
+	github.com/newrelic/nrdot-host/nrdot-telemetry => ../nrdot-telemetry
+)
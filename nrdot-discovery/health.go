@@ -0,0 +1,228 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HealthState summarizes the health signals of a discovered service
+type HealthState string
+
+const (
+	// HealthRunning: the process runs and its ports, if known, accept
+	// connections
+	HealthRunning HealthState = "running"
+	// HealthDegraded: the service runs but a port refuses connections or a
+	// config file does not parse
+	HealthDegraded HealthState = "degraded"
+	// HealthStopped: the service is installed but neither its process nor
+	// its ports are up
+	HealthStopped HealthState = "stopped"
+	// HealthUnknown: no signal could be checked
+	HealthUnknown HealthState = "unknown"
+)
+
+const (
+	// healthDialTimeout bounds each port connection attempt
+	healthDialTimeout = time.Second
+	// maxConfigParseSize is the largest config file parsed, bigger files
+	// are only checked for readability
+	maxConfigParseSize = 1 << 20
+)
+
+// ServiceHealth is the basic health of a discovered service at discovery
+// time. Signals that could not be checked are nil.
+type ServiceHealth struct {
+	State          HealthState `json:"state"`
+	ProcessRunning *bool       `json:"process_running,omitempty"`
+	// PortsOpen is whether every endpoint accepts TCP connections
+	PortsOpen *bool `json:"ports_open,omitempty"`
+	// ConfigValid is whether every config file is readable and, for YAML
+	// and JSON files, parses
+	ConfigValid *bool     `json:"config_valid,omitempty"`
+	ConfigError string    `json:"config_error,omitempty"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+// Stopped reports whether the service is known to be installed but not
+// running, so no receiver should be generated for it
+func (svc *ServiceInfo) Stopped() bool {
+	return svc.Health != nil && svc.Health.State == HealthStopped
+}
+
+// checkHealth fills in the health of every service. processScanned tells
+// whether the process scan succeeded, so a service it did not find is
+// known not to run.
+func checkHealth(ctx context.Context, services []ServiceInfo, processScanned bool) {
+	var wg sync.WaitGroup
+	for i := range services {
+		wg.Add(1)
+		go func(svc *ServiceInfo) {
+			defer wg.Done()
+			svc.Health = serviceHealth(ctx, svc, processScanned)
+		}(&services[i])
+	}
+	wg.Wait()
+}
+
+// serviceHealth checks the process, ports and config files of one service
+func serviceHealth(ctx context.Context, svc *ServiceInfo, processScanned bool) *ServiceHealth {
+	health := &ServiceHealth{CheckedAt: time.Now()}
+
+	// Only running containers and pods are listed, and only running
+	// devices answer LAN queries
+	if svc.ProcessInfo != nil || containsString(svc.DiscoveredBy, "process") ||
+		containsString(svc.DiscoveredBy, "container") || containsString(svc.DiscoveredBy, "kubernetes") ||
+		containsString(svc.DiscoveredBy, "lan") {
+		health.ProcessRunning = boolPtr(true)
+	} else if processScanned {
+		health.ProcessRunning = boolPtr(false)
+	}
+
+	if len(svc.Endpoints) > 0 {
+		open := true
+		for _, endpoint := range svc.Endpoints {
+			if !portOpen(ctx, endpoint) {
+				open = false
+				break
+			}
+		}
+		health.PortsOpen = boolPtr(open)
+	}
+
+	if len(svc.ConfigPaths) > 0 {
+		health.ConfigValid = boolPtr(true)
+		for _, path := range svc.ConfigPaths {
+			if err := checkConfigPath(path); err != nil {
+				health.ConfigValid = boolPtr(false)
+				health.ConfigError = err.Error()
+				break
+			}
+		}
+	}
+
+	health.State = healthState(health)
+	return health
+}
+
+// healthState combines the signals into a state
+func healthState(h *ServiceHealth) HealthState {
+	running := h.ProcessRunning != nil && *h.ProcessRunning
+	listening := h.PortsOpen != nil && *h.PortsOpen
+	switch {
+	case running || listening:
+		if (h.PortsOpen != nil && !*h.PortsOpen) || (h.ConfigValid != nil && !*h.ConfigValid) {
+			return HealthDegraded
+		}
+		return HealthRunning
+	case h.ProcessRunning != nil || h.PortsOpen != nil:
+		return HealthStopped
+	default:
+		return HealthUnknown
+	}
+}
+
+// portOpen reports whether an endpoint accepts TCP connections
+func portOpen(ctx context.Context, endpoint Endpoint) bool {
+	if endpoint.Protocol != "" && endpoint.Protocol != "tcp" {
+		return true // only TCP can be checked without speaking the protocol
+	}
+	dialer := net.Dialer{Timeout: healthDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", dialAddress(endpoint))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// dialAddress returns the host:port an endpoint is dialed at. Wildcard and
+// unparsed listen addresses are dialed on localhost.
+func dialAddress(endpoint Endpoint) string {
+	host := endpoint.Address
+	if ip := net.ParseIP(host); host == "" || host == "localhost" || ip == nil || ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(endpoint.Port))
+}
+
+// checkConfigPath checks a config file, or the files directly in a config
+// directory, can be read and parsed
+func checkConfigPath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return checkConfigFile(path, info)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if err := checkConfigFile(filepath.Join(path, entry.Name()), info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkConfigFile reads a config file and parses it if it is YAML or JSON.
+// Other formats are service specific and only need to be readable.
+func checkConfigFile(path string, info os.FileInfo) error {
+	if info.Size() > maxConfigParseSize {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var parsed interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(data, &parsed)
+	case ".json":
+		err = json.Unmarshal(data, &parsed)
+	}
+	if err != nil {
+		return fmt.Errorf("%s does not parse: %w", path, err)
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
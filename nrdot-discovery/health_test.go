@@ -0,0 +1,198 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openPort listens on a local port for the duration of the test
+func openPort(t *testing.T) Endpoint {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return Endpoint{Address: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port, Protocol: "tcp"}
+}
+
+// closedPort returns a local port that was listened on and closed again,
+// so connections to it are refused
+func closedPort(t *testing.T) Endpoint {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	return Endpoint{Address: "0.0.0.0", Port: port, Protocol: "tcp"}
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestServiceHealth(t *testing.T) {
+	dir := t.TempDir()
+	validYAML := writeFile(t, dir, "redis.yaml", "bind: 127.0.0.1\nport: 6379\n")
+	invalidJSON := writeFile(t, dir, "config.json", `{"port": `)
+	nativeConf := writeFile(t, dir, "my.cnf", "[mysqld]\nport = 3306\n")
+	confDir := filepath.Join(dir, "conf.d")
+	if err := os.Mkdir(confDir, 0755); err != nil {
+		t.Fatalf("Failed to create conf.d: %v", err)
+	}
+	writeFile(t, confDir, "site.yml", "key: [unterminated\n")
+
+	open, closed := openPort(t), closedPort(t)
+
+	tests := []struct {
+		name           string
+		svc            ServiceInfo
+		processScanned bool
+		state          HealthState
+		processRunning *bool
+		portsOpen      *bool
+		configValid    *bool
+		configError    string
+	}{
+		{
+			name:           "running",
+			svc:            ServiceInfo{Type: "redis", DiscoveredBy: []string{"process"}, Endpoints: []Endpoint{open}, ConfigPaths: []string{validYAML, nativeConf}},
+			processScanned: true,
+			state:          HealthRunning,
+			processRunning: boolPtr(true),
+			portsOpen:      boolPtr(true),
+			configValid:    boolPtr(true),
+		},
+		{
+			name:           "listening without a process",
+			svc:            ServiceInfo{Type: "redis", DiscoveredBy: []string{"port"}, Endpoints: []Endpoint{open}},
+			processScanned: true,
+			state:          HealthRunning,
+			processRunning: boolPtr(false),
+			portsOpen:      boolPtr(true),
+		},
+		{
+			name:           "process with a closed port",
+			svc:            ServiceInfo{Type: "mysql", DiscoveredBy: []string{"process"}, Endpoints: []Endpoint{open, closed}},
+			processScanned: true,
+			state:          HealthDegraded,
+			processRunning: boolPtr(true),
+			portsOpen:      boolPtr(false),
+		},
+		{
+			name:           "container with a config that does not parse",
+			svc:            ServiceInfo{Type: "app", DiscoveredBy: []string{"container"}, ConfigPaths: []string{invalidJSON}},
+			state:          HealthDegraded,
+			processRunning: boolPtr(true),
+			configValid:    boolPtr(false),
+			configError:    "config.json does not parse",
+		},
+		{
+			name:           "config directory",
+			svc:            ServiceInfo{Type: "app", DiscoveredBy: []string{"kubernetes"}, ConfigPaths: []string{confDir}},
+			state:          HealthDegraded,
+			processRunning: boolPtr(true),
+			configValid:    boolPtr(false),
+			configError:    "site.yml does not parse",
+		},
+		{
+			name:           "installed but stopped",
+			svc:            ServiceInfo{Type: "postgresql", DiscoveredBy: []string{"package"}, Endpoints: []Endpoint{closed}},
+			processScanned: true,
+			state:          HealthStopped,
+			processRunning: boolPtr(false),
+			portsOpen:      boolPtr(false),
+		},
+		{
+			name:        "missing config",
+			svc:         ServiceInfo{Type: "nginx", DiscoveredBy: []string{"config"}, ConfigPaths: []string{filepath.Join(dir, "missing.conf")}},
+			state:       HealthUnknown,
+			configValid: boolPtr(false),
+			configError: "missing.conf",
+		},
+		{
+			name:      "UDP endpoints are not dialed",
+			svc:       ServiceInfo{Type: "statsd", DiscoveredBy: []string{"port"}, Endpoints: []Endpoint{{Address: "127.0.0.1", Port: closed.Port, Protocol: "udp"}}},
+			state:     HealthRunning,
+			portsOpen: boolPtr(true),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := serviceHealth(context.Background(), &tt.svc, tt.processScanned)
+			if health.State != tt.state {
+				t.Errorf("Expected state %s, got %s", tt.state, health.State)
+			}
+			checkBool(t, "ProcessRunning", tt.processRunning, health.ProcessRunning)
+			checkBool(t, "PortsOpen", tt.portsOpen, health.PortsOpen)
+			checkBool(t, "ConfigValid", tt.configValid, health.ConfigValid)
+			if !strings.Contains(health.ConfigError, tt.configError) || (tt.configError == "") != (health.ConfigError == "") {
+				t.Errorf("Expected config error containing %q, got %q", tt.configError, health.ConfigError)
+			}
+			if health.CheckedAt.IsZero() {
+				t.Error("Expected CheckedAt to be set")
+			}
+		})
+	}
+}
+
+func checkBool(t *testing.T, name string, want, got *bool) {
+	t.Helper()
+	switch {
+	case want == nil && got != nil:
+		t.Errorf("Expected %s unset, got %v", name, *got)
+	case want != nil && got == nil:
+		t.Errorf("Expected %s %v, got unset", name, *want)
+	case want != nil && *want != *got:
+		t.Errorf("Expected %s %v, got %v", name, *want, *got)
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	services := []ServiceInfo{
+		{Type: "redis", DiscoveredBy: []string{"process"}, Endpoints: []Endpoint{openPort(t)}},
+		{Type: "mysql", DiscoveredBy: []string{"package"}, Endpoints: []Endpoint{closedPort(t)}},
+	}
+	checkHealth(context.Background(), services, true)
+
+	if services[0].Health == nil || services[0].Stopped() {
+		t.Errorf("Expected redis to be running, got %+v", services[0].Health)
+	}
+	if !services[1].Stopped() {
+		t.Errorf("Expected mysql to be stopped, got %+v", services[1].Health)
+	}
+	if (&ServiceInfo{}).Stopped() {
+		t.Error("Expected a service without health not to be stopped")
+	}
+}
+
+func TestDialAddress(t *testing.T) {
+	for _, tt := range []struct {
+		endpoint Endpoint
+		want     string
+	}{
+		{Endpoint{Address: "0.0.0.0", Port: 3306}, "127.0.0.1:3306"},
+		{Endpoint{Address: "::", Port: 3306}, "127.0.0.1:3306"},
+		{Endpoint{Address: "", Port: 6379}, "127.0.0.1:6379"},
+		{Endpoint{Address: "localhost", Port: 80}, "127.0.0.1:80"},
+		{Endpoint{Address: "*", Port: 80}, "127.0.0.1:80"},
+		{Endpoint{Address: "10.0.0.5", Port: 9200}, "10.0.0.5:9200"},
+		{Endpoint{Address: "fd00::5", Port: 6379}, "[fd00::5]:6379"},
+	} {
+		if got := dialAddress(tt.endpoint); got != tt.want {
+			t.Errorf("dialAddress(%+v): expected %s, got %s", tt.endpoint, tt.want, got)
+		}
+	}
+}
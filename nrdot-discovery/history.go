@@ -0,0 +1,248 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap"
+)
+
+// resultsFileName is the file in WorkDir holding the last discovery result
+const resultsFileName = "discovery_results.json"
+
+// DiscoveryResult is a persisted discovery run
+type DiscoveryResult struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Hostname  string        `json:"hostname"`
+	Services  []ServiceInfo `json:"services"`
+}
+
+// ServiceDiff lists services that appeared or disappeared between two
+// discovery runs, keyed by service type
+type ServiceDiff struct {
+	PreviousScan time.Time     `json:"previous_scan"`
+	CurrentScan  time.Time     `json:"current_scan"`
+	Added        []ServiceInfo `json:"added"`
+	Removed      []ServiceInfo `json:"removed"`
+}
+
+// Empty reports whether nothing changed
+func (d *ServiceDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// Events converts the diff into one event per appeared or disappeared service
+func (d *ServiceDiff) Events() []models.Event {
+	hostname, _ := os.Hostname()
+	since := fmt.Sprintf("since last scan at %s", d.PreviousScan.Format(time.RFC3339))
+
+	var events []models.Event
+	newEvent := func(eventType models.EventType, severity models.EventSeverity, summary string, svc ServiceInfo) models.Event {
+		return models.Event{
+			Type:      eventType,
+			Timestamp: d.CurrentScan,
+			Component: "discovery",
+			Severity:  severity,
+			Summary:   summary,
+			Details:   since,
+			Source: models.EventSource{
+				Component: "nrdot-discovery",
+				Host:      hostname,
+			},
+			Metadata: map[string]interface{}{
+				"service_type":  svc.Type,
+				"endpoints":     svc.Endpoints,
+				"discovered_by": svc.DiscoveredBy,
+				"previous_scan": d.PreviousScan,
+			},
+		}
+	}
+
+	for _, svc := range d.Added {
+		events = append(events, newEvent(models.EventTypeServiceAppeared, models.EventSeverityInfo,
+			fmt.Sprintf("Service %s appeared", svc.Type), svc))
+	}
+	for _, svc := range d.Removed {
+		events = append(events, newEvent(models.EventTypeServiceDisappeared, models.EventSeverityWarning,
+			fmt.Sprintf("Service %s disappeared", svc.Type), svc))
+	}
+
+	return events
+}
+
+// DiffServices compares two discovery results by service type. Services found
+// several times (e.g. on different endpoints) count once.
+func DiffServices(previous, current []ServiceInfo) *ServiceDiff {
+	prevByType := servicesByType(previous)
+	currByType := servicesByType(current)
+
+	diff := &ServiceDiff{}
+	for serviceType, svc := range currByType {
+		if _, ok := prevByType[serviceType]; !ok {
+			diff.Added = append(diff.Added, svc)
+		}
+	}
+	for serviceType, svc := range prevByType {
+		if _, ok := currByType[serviceType]; !ok {
+			diff.Removed = append(diff.Removed, svc)
+		}
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].Type < diff.Added[j].Type })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].Type < diff.Removed[j].Type })
+	return diff
+}
+
+func servicesByType(services []ServiceInfo) map[string]ServiceInfo {
+	byType := make(map[string]ServiceInfo, len(services))
+	for _, svc := range services {
+		if existing, ok := byType[svc.Type]; ok {
+			existing.Endpoints = append(existing.Endpoints, svc.Endpoints...)
+			existing.DiscoveredBy = mergeStrings(existing.DiscoveredBy, svc.DiscoveredBy)
+			byType[svc.Type] = existing
+			continue
+		}
+		byType[svc.Type] = svc
+	}
+	return byType
+}
+
+// SetWorkDir enables persisting discovery results to dir. The first Discover
+// call afterwards diffs its result against the one persisted by the previous
+// run (typically before a reboot) and reports the changes as events.
+func (sd *ServiceDiscovery) SetWorkDir(dir string) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.workDir = dir
+}
+
+// SetEventHandler sets the callback receiving discovery events
+func (sd *ServiceDiscovery) SetEventHandler(handler func(models.Event)) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.eventHandler = handler
+}
+
+// StartupDiff returns the diff against the previous run computed by the first
+// Discover call, or nil if none was computed (no WorkDir or no earlier result).
+func (sd *ServiceDiscovery) StartupDiff() *ServiceDiff {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	return sd.startupDiff
+}
+
+// LoadLastResult reads the persisted result from WorkDir. It returns nil
+// without error if nothing has been persisted yet.
+func (sd *ServiceDiscovery) LoadLastResult() (*DiscoveryResult, error) {
+	sd.mu.Lock()
+	dir := sd.workDir
+	sd.mu.Unlock()
+	if dir == "" {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, resultsFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read discovery results: %w", err)
+	}
+
+	var result DiscoveryResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal discovery results: %w", err)
+	}
+	return &result, nil
+}
+
+// saveResult persists services to WorkDir, replacing the file atomically so a
+// crash mid-write never leaves a truncated result behind
+func (sd *ServiceDiscovery) saveResult(dir string, result *DiscoveryResult) error {
+	// Process details change on every run and are not needed for diffing
+	services := make([]ServiceInfo, len(result.Services))
+	for i, svc := range result.Services {
+		svc.ProcessInfo = nil
+		svc.Additional = nil
+		services[i] = svc
+	}
+	persisted := *result
+	persisted.Services = services
+
+	data, err := json.MarshalIndent(persisted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal discovery results: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create work dir: %w", err)
+	}
+
+	path := filepath.Join(dir, resultsFileName)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write discovery results: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write discovery results: %w", err)
+	}
+	return nil
+}
+
+// recordResult persists a discovery result and, on the first run since
+// startup, reports how it differs from the previously persisted one
+func (sd *ServiceDiscovery) recordResult(services []ServiceInfo, timestamp time.Time) {
+	sd.mu.Lock()
+	dir := sd.workDir
+	firstRun := !sd.startupChecked
+	sd.startupChecked = true
+	handler := sd.eventHandler
+	sd.mu.Unlock()
+
+	if dir == "" {
+		return
+	}
+
+	if firstRun {
+		previous, err := sd.LoadLastResult()
+		if err != nil {
+			sd.logger.Warn("Failed to load previous discovery results", zap.Error(err))
+		} else if previous != nil {
+			diff := DiffServices(previous.Services, services)
+			diff.PreviousScan = previous.Timestamp
+			diff.CurrentScan = timestamp
+
+			sd.mu.Lock()
+			sd.startupDiff = diff
+			sd.mu.Unlock()
+
+			sd.logger.Info("Compared discovery with previous run",
+				zap.Time("previous_scan", previous.Timestamp),
+				zap.Int("services_added", len(diff.Added)),
+				zap.Int("services_removed", len(diff.Removed)))
+
+			if handler != nil {
+				for _, event := range diff.Events() {
+					handler(event)
+				}
+			}
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	result := &DiscoveryResult{
+		Timestamp: timestamp,
+		Hostname:  hostname,
+		Services:  services,
+	}
+	if err := sd.saveResult(dir, result); err != nil {
+		sd.logger.Warn("Failed to persist discovery results", zap.Error(err))
+	}
+}
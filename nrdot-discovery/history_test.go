@@ -0,0 +1,162 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-telemetry/process"
+	"go.uber.org/zap"
+)
+
+func TestDiffServices(t *testing.T) {
+	mysql := ServiceInfo{Type: "mysql", Endpoints: []Endpoint{{Address: "0.0.0.0", Port: 3306, Protocol: "tcp"}}, DiscoveredBy: []string{"process"}}
+	redis := ServiceInfo{Type: "redis", Endpoints: []Endpoint{{Address: "127.0.0.1", Port: 6379, Protocol: "tcp"}}, DiscoveredBy: []string{"port"}}
+	nginx := ServiceInfo{Type: "nginx", Endpoints: []Endpoint{{Address: "0.0.0.0", Port: 80, Protocol: "tcp"}}, DiscoveredBy: []string{"process"}}
+	elasticsearch := ServiceInfo{Type: "elasticsearch", DiscoveredBy: []string{"package"}}
+
+	// A second redis on another port is the same service type
+	redis2 := ServiceInfo{Type: "redis", Endpoints: []Endpoint{{Address: "127.0.0.1", Port: 6380, Protocol: "tcp"}}, DiscoveredBy: []string{"process"}}
+
+	diff := DiffServices([]ServiceInfo{mysql, redis, nginx}, []ServiceInfo{redis2, nginx, elasticsearch, mysql})
+	if len(diff.Added) != 1 || diff.Added[0].Type != "elasticsearch" {
+		t.Errorf("Expected elasticsearch to be added, got %+v", diff.Added)
+	}
+	if len(diff.Removed) != 0 {
+		t.Errorf("Expected nothing removed, got %+v", diff.Removed)
+	}
+
+	diff = DiffServices([]ServiceInfo{mysql, redis, redis2, nginx}, []ServiceInfo{elasticsearch})
+	var removed []string
+	for _, svc := range diff.Removed {
+		removed = append(removed, svc.Type)
+	}
+	if want := []string{"mysql", "nginx", "redis"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("Expected %v removed in order, got %v", want, removed)
+	}
+	// Services found several times are merged
+	if redisRemoved := diff.Removed[2]; len(redisRemoved.Endpoints) != 2 ||
+		!reflect.DeepEqual(redisRemoved.DiscoveredBy, []string{"port", "process"}) {
+		t.Errorf("Expected both redis endpoints and methods, got %+v", redisRemoved)
+	}
+
+	if diff := DiffServices([]ServiceInfo{mysql}, []ServiceInfo{mysql}); !diff.Empty() {
+		t.Errorf("Expected an empty diff, got %+v", diff)
+	}
+}
+
+func TestServiceDiff_Events(t *testing.T) {
+	previous := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	current := previous.Add(12 * time.Hour)
+	diff := &ServiceDiff{
+		PreviousScan: previous,
+		CurrentScan:  current,
+		Added:        []ServiceInfo{{Type: "redis", DiscoveredBy: []string{"process"}}},
+		Removed:      []ServiceInfo{{Type: "mysql", DiscoveredBy: []string{"package"}}},
+	}
+
+	events := diff.Events()
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	appeared, disappeared := events[0], events[1]
+	if appeared.Type != models.EventTypeServiceAppeared || appeared.Severity != models.EventSeverityInfo ||
+		appeared.Summary != "Service redis appeared" {
+		t.Errorf("Unexpected event %+v", appeared)
+	}
+	if disappeared.Type != models.EventTypeServiceDisappeared || disappeared.Severity != models.EventSeverityWarning ||
+		disappeared.Metadata["service_type"] != "mysql" {
+		t.Errorf("Unexpected event %+v", disappeared)
+	}
+	if !appeared.Timestamp.Equal(current) || appeared.Details != "since last scan at 2024-03-01T10:00:00Z" {
+		t.Errorf("Expected the event at %v since %v, got %v %q", current, previous, appeared.Timestamp, appeared.Details)
+	}
+}
+
+func TestRecordResult(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "work")
+	first := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	sd := NewServiceDiscovery(zap.NewNop())
+	sd.SetWorkDir(dir)
+	if result, err := sd.LoadLastResult(); err != nil || result != nil {
+		t.Fatalf("Expected no result before the first run, got %+v, %v", result, err)
+	}
+
+	services := []ServiceInfo{{
+		Type:         "mysql",
+		Version:      "8.0.35",
+		Endpoints:    []Endpoint{{Address: "0.0.0.0", Port: 3306, Protocol: "tcp"}},
+		DiscoveredBy: []string{"process"},
+		Confidence:   "HIGH",
+		ProcessInfo:  &process.ProcessInfo{PID: 1234, Name: "mysqld"},
+		Additional:   map[string]interface{}{"evidence": []Evidence{{Method: "process"}}},
+	}}
+	sd.recordResult(services, first)
+	if sd.StartupDiff() != nil {
+		t.Error("Expected no startup diff without an earlier result")
+	}
+
+	// The result round-trips without process details
+	result, err := sd.LoadLastResult()
+	if err != nil {
+		t.Fatalf("Failed to load result: %v", err)
+	}
+	persisted := services[0]
+	persisted.ProcessInfo = nil
+	persisted.Additional = nil
+	if !result.Timestamp.Equal(first) || !reflect.DeepEqual(result.Services, []ServiceInfo{persisted}) {
+		t.Errorf("Expected %+v at %v, got %+v at %v", persisted, first, result.Services, result.Timestamp)
+	}
+	if services[0].ProcessInfo == nil {
+		t.Error("Expected the recorded services to keep their process details")
+	}
+	if _, err := os.Stat(filepath.Join(dir, resultsFileName+".tmp")); !os.IsNotExist(err) {
+		t.Errorf("Expected no temporary file left behind, got %v", err)
+	}
+
+	// After a restart, the first run is diffed against the persisted one
+	var events []models.Event
+	restarted := NewServiceDiscovery(zap.NewNop())
+	restarted.SetWorkDir(dir)
+	restarted.SetEventHandler(func(event models.Event) { events = append(events, event) })
+
+	second := first.Add(time.Hour)
+	restarted.recordResult([]ServiceInfo{{Type: "redis", DiscoveredBy: []string{"process"}}}, second)
+	diff := restarted.StartupDiff()
+	if diff == nil || !diff.PreviousScan.Equal(first) || !diff.CurrentScan.Equal(second) ||
+		len(diff.Added) != 1 || len(diff.Removed) != 1 {
+		t.Fatalf("Expected redis added and mysql removed since %v, got %+v", first, diff)
+	}
+	if len(events) != 2 {
+		t.Errorf("Expected 2 events, got %d", len(events))
+	}
+
+	// Later runs are not diffed
+	restarted.recordResult(nil, second.Add(time.Hour))
+	if len(events) != 2 || restarted.StartupDiff() != diff {
+		t.Errorf("Expected only the first run to be diffed, got %d events", len(events))
+	}
+}
+
+func TestLoadLastResult_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, resultsFileName), []byte(`{"timestamp": `), 0600); err != nil {
+		t.Fatalf("Failed to write results: %v", err)
+	}
+
+	sd := NewServiceDiscovery(zap.NewNop())
+	sd.SetWorkDir(dir)
+	if _, err := sd.LoadLastResult(); err == nil {
+		t.Error("Expected an error for a corrupt result")
+	}
+
+	// Without a work dir nothing is persisted
+	sd = NewServiceDiscovery(zap.NewNop())
+	if result, err := sd.LoadLastResult(); err != nil || result != nil {
+		t.Errorf("Expected no result without a work dir, got %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,282 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// KubernetesKey is the ServiceInfo.Additional key holding the *PodInfo of a
+// service discovered in a Kubernetes pod
+const KubernetesKey = "kubernetes"
+
+// PodInfo identifies the Kubernetes pod a service runs in
+type PodInfo struct {
+	Namespace string            `json:"namespace"`
+	Name      string            `json:"name"`
+	UID       string            `json:"uid"`
+	Node      string            `json:"node"`
+	Container string            `json:"container"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// Pod returns the Kubernetes pod a service was discovered in, nil for
+// services outside Kubernetes
+func (svc *ServiceInfo) Pod() *PodInfo {
+	pod, _ := svc.Additional[KubernetesKey].(*PodInfo)
+	return pod
+}
+
+const (
+	// kubeletPort is the kubelet's authenticated API port
+	kubeletPort = 10250
+
+	// kubeletAPITimeout bounds one kubelet API request
+	kubeletAPITimeout = 10 * time.Second
+
+	// defaultServiceAccountDir holds the token and CA of the agent's
+	// service account
+	defaultServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// KubeletConfig configures access to the kubelet of the node the agent
+// runs on. Zero values are defaulted, see SetKubernetes.
+type KubeletConfig struct {
+	// Endpoint is the kubelet API base URL, https://$K8S_NODE_NAME:10250
+	// by default
+	Endpoint string
+	// TokenPath is the service account token sent as a bearer token
+	TokenPath string
+	// CAFile verifies the kubelet's serving certificate, the service
+	// account CA by default
+	CAFile string
+	// InsecureSkipVerify skips verifying the kubelet's certificate, which
+	// is often self-signed
+	InsecureSkipVerify bool
+}
+
+// KubeletScanner finds services in the pods of the node the agent runs on
+// through the kubelet API. Each container is reached on its pod's address.
+type KubeletScanner struct {
+	logger  *zap.Logger
+	verbose bool
+	config  KubeletConfig
+}
+
+func NewKubeletScanner(logger *zap.Logger, config KubeletConfig) *KubeletScanner {
+	if config.Endpoint == "" {
+		config.Endpoint = DefaultKubeletEndpoint()
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	if config.TokenPath == "" {
+		config.TokenPath = defaultServiceAccountDir + "/token"
+	}
+	if config.CAFile == "" && !config.InsecureSkipVerify {
+		config.CAFile = defaultServiceAccountDir + "/ca.crt"
+	}
+	return &KubeletScanner{logger: logger, config: config}
+}
+
+// DefaultKubeletEndpoint returns the kubelet API URL of the node named by
+// K8S_NODE_NAME, set from spec.nodeName in the agent's DaemonSet
+func DefaultKubeletEndpoint() string {
+	node := os.Getenv("K8S_NODE_NAME")
+	if node == "" {
+		node = "localhost"
+	}
+	return fmt.Sprintf("https://%s:%d", node, kubeletPort)
+}
+
+// SetKubernetes enables discovering the services of the pods on this node
+// through the kubelet. Call it before Discover.
+func (sd *ServiceDiscovery) SetKubernetes(config KubeletConfig) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.kubeletScanner = NewKubeletScanner(sd.logger, config)
+	sd.kubeletScanner.verbose = sd.processScanner.verbose
+	// Docker lists the containers of pods too
+	sd.containerScanner.skipPods = true
+}
+
+// kubeletPod is a pod as listed by the kubelet's /pods
+type kubeletPod struct {
+	Metadata struct {
+		Name      string            `json:"name"`
+		Namespace string            `json:"namespace"`
+		UID       string            `json:"uid"`
+		Labels    map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		NodeName   string `json:"nodeName"`
+		Containers []struct {
+			Name  string `json:"name"`
+			Image string `json:"image"`
+			Ports []struct {
+				ContainerPort int    `json:"containerPort"`
+				Protocol      string `json:"protocol"`
+			} `json:"ports"`
+		} `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		Phase             string `json:"phase"`
+		PodIP             string `json:"podIP"`
+		ContainerStatuses []struct {
+			Name        string `json:"name"`
+			ContainerID string `json:"containerID"`
+		} `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+func (ks *KubeletScanner) Scan(ctx context.Context) ([]ServiceInfo, error) {
+	pods, err := ks.listPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []ServiceInfo
+	for _, pod := range pods {
+		// Pending pods have no address yet, finished ones no process
+		if pod.Status.Phase != "Running" || pod.Status.PodIP == "" {
+			continue
+		}
+		services = append(services, ks.podServices(pod)...)
+	}
+	return services, nil
+}
+
+// listPods lists the pods of the node from the kubelet
+func (ks *KubeletScanner) listPods(ctx context.Context) ([]kubeletPod, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: ks.config.InsecureSkipVerify}
+	if ks.config.CAFile != "" {
+		pem, err := os.ReadFile(ks.config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubelet CA: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", ks.config.CAFile)
+		}
+	}
+	client := &http.Client{
+		Timeout:   kubeletAPITimeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	defer client.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.config.Endpoint+"/pods", nil)
+	if err != nil {
+		return nil, err
+	}
+	// The read-only port needs no token
+	if token, err := os.ReadFile(ks.config.TokenPath); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query kubelet: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kubelet returned %s", resp.Status)
+	}
+
+	var podList struct {
+		Items []kubeletPod `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&podList); err != nil {
+		return nil, fmt.Errorf("failed to decode pods: %w", err)
+	}
+	return podList.Items, nil
+}
+
+// defaultPort returns the well-known port of a service, 0 if it has none
+func defaultPort(service string) int {
+	for port, s := range wellKnownPorts {
+		if s == service {
+			return port
+		}
+	}
+	return 0
+}
+
+// podServices identifies the service in each container of a pod by its
+// image, or else by a well-known port it declares
+func (ks *KubeletScanner) podServices(pod kubeletPod) []ServiceInfo {
+	containerIDs := make(map[string]string)
+	for _, status := range pod.Status.ContainerStatuses {
+		containerIDs[status.Name] = status.ContainerID
+	}
+
+	var services []ServiceInfo
+	for _, container := range pod.Spec.Containers {
+		var endpoints []Endpoint
+		service, match := containerImages[imageName(container.Image)], container.Image
+		for _, port := range container.Ports {
+			// The protocol defaults to TCP
+			if port.Protocol != "" && port.Protocol != "TCP" {
+				continue
+			}
+			endpoint := Endpoint{Address: pod.Status.PodIP, Port: port.ContainerPort, Protocol: "tcp"}
+			if s, ok := wellKnownPorts[port.ContainerPort]; ok && (service == "" || s == service) {
+				if service == "" {
+					service, match = s, fmt.Sprintf("%d/TCP", port.ContainerPort)
+				}
+				// Receivers use the first endpoint
+				endpoints = append([]Endpoint{endpoint}, endpoints...)
+				continue
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+		if service == "" {
+			continue
+		}
+		// Declaring ports is optional, without any the service listens on
+		// its default one
+		if port := defaultPort(service); len(endpoints) == 0 && port > 0 {
+			endpoints = []Endpoint{{Address: pod.Status.PodIP, Port: port, Protocol: "tcp"}}
+		}
+
+		// The container ID is prefixed with the runtime,
+		// e.g. containerd://4f3a...
+		runtime, id, _ := strings.Cut(containerIDs[container.Name], "://")
+		svc := ServiceInfo{
+			Type:         service,
+			Endpoints:    endpoints,
+			DiscoveredBy: []string{"kubernetes"},
+			Additional: map[string]interface{}{
+				ContainerKey: &ContainerInfo{
+					ID:      id,
+					Name:    container.Name,
+					Image:   container.Image,
+					Runtime: runtime,
+				},
+				KubernetesKey: &PodInfo{
+					Namespace: pod.Metadata.Namespace,
+					Name:      pod.Metadata.Name,
+					UID:       pod.Metadata.UID,
+					Node:      pod.Spec.NodeName,
+					Container: container.Name,
+					Labels:    pod.Metadata.Labels,
+				},
+			},
+		}
+		if ks.verbose {
+			addEvidence(&svc, Evidence{
+				Method: "kubernetes",
+				Source: fmt.Sprintf("pod %s/%s container %s", pod.Metadata.Namespace, pod.Metadata.Name, container.Name),
+				Match:  match,
+			})
+		}
+		services = append(services, svc)
+	}
+	return services
+}
@@ -0,0 +1,279 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// kubeletPods is a /pods listing of a node: Running pods with a service
+// found by image, one found by port, and pods without a process or address
+const kubeletPods = `{
+  "kind": "PodList",
+  "apiVersion": "v1",
+  "items": [
+    {
+      "metadata": {"name": "redis-0", "namespace": "cache", "uid": "0c5f6a2e-1111", "labels": {"app": "redis"}},
+      "spec": {
+        "nodeName": "node-01",
+        "containers": [
+          {"name": "redis", "image": "docker.io/library/redis:7.2-alpine"},
+          {"name": "metrics", "image": "oliver006/redis_exporter:v1.55.0", "ports": [{"containerPort": 9121, "protocol": "TCP"}]}
+        ]
+      },
+      "status": {
+        "phase": "Running",
+        "podIP": "10.244.1.5",
+        "containerStatuses": [
+          {"name": "redis", "containerID": "containerd://4f3a9c"},
+          {"name": "metrics", "containerID": "containerd://77b1e0"}
+        ]
+      }
+    },
+    {
+      "metadata": {"name": "orders-db-0", "namespace": "shop", "uid": "9d2b7c41-2222"},
+      "spec": {
+        "nodeName": "node-01",
+        "containers": [
+          {"name": "db", "image": "registry.example.com/team/orders-db:3", "ports": [
+            {"containerPort": 8080},
+            {"containerPort": 5432, "protocol": "TCP"}
+          ]}
+        ]
+      },
+      "status": {
+        "phase": "Running",
+        "podIP": "10.244.1.6",
+        "containerStatuses": [{"name": "db", "containerID": "cri-o://a1b2c3"}]
+      }
+    },
+    {
+      "metadata": {"name": "mysql-0", "namespace": "shop", "uid": "3e8f0d55-3333"},
+      "spec": {
+        "nodeName": "node-01",
+        "containers": [
+          {"name": "mysql", "image": "mysql:8.0", "ports": [
+            {"containerPort": 9104},
+            {"containerPort": 53, "protocol": "UDP"},
+            {"containerPort": 3306}
+          ]}
+        ]
+      },
+      "status": {
+        "phase": "Running",
+        "podIP": "10.244.1.7",
+        "containerStatuses": [{"name": "mysql", "containerID": "containerd://d4e5f6"}]
+      }
+    },
+    {
+      "metadata": {"name": "report-28431", "namespace": "batch", "uid": "5a6b7c8d-4444"},
+      "spec": {"nodeName": "node-01", "containers": [{"name": "redis", "image": "redis:7"}]},
+      "status": {"phase": "Succeeded", "podIP": "10.244.1.8"}
+    },
+    {
+      "metadata": {"name": "web-5d9c", "namespace": "default", "uid": "6b7c8d9e-5555"},
+      "spec": {"nodeName": "node-01", "containers": [{"name": "nginx", "image": "nginx:1.25"}]},
+      "status": {"phase": "Pending"}
+    }
+  ]
+}`
+
+// kubeletServer serves pods on /pods over TLS to clients presenting token,
+// returning a scanner trusting its certificate
+func kubeletServer(t *testing.T, token, pods string) *KubeletScanner {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pods" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(pods))
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatalf("Failed to write CA: %v", err)
+	}
+	tokenPath := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenPath, []byte("kubelet-token\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token: %v", err)
+	}
+
+	return NewKubeletScanner(zap.NewNop(), KubeletConfig{
+		Endpoint:  server.URL + "/",
+		TokenPath: tokenPath,
+		CAFile:    caFile,
+	})
+}
+
+func TestKubeletScanner_Scan(t *testing.T) {
+	ks := kubeletServer(t, "kubelet-token", kubeletPods)
+
+	services, err := ks.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to scan: %v", err)
+	}
+
+	want := []ServiceInfo{
+		{
+			// Found by image, on its default port as it declares none
+			Type:         "redis",
+			Endpoints:    []Endpoint{{Address: "10.244.1.5", Port: 6379, Protocol: "tcp"}},
+			DiscoveredBy: []string{"kubernetes"},
+			Additional: map[string]interface{}{
+				ContainerKey: &ContainerInfo{ID: "4f3a9c", Name: "redis", Image: "docker.io/library/redis:7.2-alpine", Runtime: "containerd"},
+				KubernetesKey: &PodInfo{
+					Namespace: "cache",
+					Name:      "redis-0",
+					UID:       "0c5f6a2e-1111",
+					Node:      "node-01",
+					Container: "redis",
+					Labels:    map[string]string{"app": "redis"},
+				},
+			},
+		},
+		{
+			// Found by port, which is moved first
+			Type: "postgresql",
+			Endpoints: []Endpoint{
+				{Address: "10.244.1.6", Port: 5432, Protocol: "tcp"},
+				{Address: "10.244.1.6", Port: 8080, Protocol: "tcp"},
+			},
+			DiscoveredBy: []string{"kubernetes"},
+			Additional: map[string]interface{}{
+				ContainerKey: &ContainerInfo{ID: "a1b2c3", Name: "db", Image: "registry.example.com/team/orders-db:3", Runtime: "cri-o"},
+				KubernetesKey: &PodInfo{
+					Namespace: "shop",
+					Name:      "orders-db-0",
+					UID:       "9d2b7c41-2222",
+					Node:      "node-01",
+					Container: "db",
+				},
+			},
+		},
+		{
+			// Found by image, its well-known port moved first and UDP
+			// ports left out
+			Type: "mysql",
+			Endpoints: []Endpoint{
+				{Address: "10.244.1.7", Port: 3306, Protocol: "tcp"},
+				{Address: "10.244.1.7", Port: 9104, Protocol: "tcp"},
+			},
+			DiscoveredBy: []string{"kubernetes"},
+			Additional: map[string]interface{}{
+				ContainerKey: &ContainerInfo{ID: "d4e5f6", Name: "mysql", Image: "mysql:8.0", Runtime: "containerd"},
+				KubernetesKey: &PodInfo{
+					Namespace: "shop",
+					Name:      "mysql-0",
+					UID:       "3e8f0d55-3333",
+					Node:      "node-01",
+					Container: "mysql",
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(services, want) {
+		t.Errorf("Expected\n%+v\ngot\n%+v", want, services)
+	}
+	if pod := services[0].Pod(); pod == nil || pod.Name != "redis-0" {
+		t.Errorf("Expected Pod() to return redis-0, got %+v", pod)
+	}
+}
+
+func TestKubeletScanner_PodServices(t *testing.T) {
+	ks := NewKubeletScanner(zap.NewNop(), KubeletConfig{InsecureSkipVerify: true})
+	ks.verbose = true
+
+	tests := []struct {
+		name      string
+		image     string
+		ports     []int
+		service   string
+		endpoints []int
+		match     string
+	}{
+		{name: "image", image: "bitnami/postgresql:16", ports: []int{5432}, service: "postgresql", endpoints: []int{5432}, match: "bitnami/postgresql:16"},
+		{name: "image over port", image: "redis:7", ports: []int{3306, 6379}, service: "redis", endpoints: []int{6379, 3306}, match: "redis:7"},
+		{name: "port", image: "example/app:1", ports: []int{8080, 9200}, service: "elasticsearch", endpoints: []int{9200, 8080}, match: "9200/TCP"},
+		{name: "first well-known port", image: "example/app:1", ports: []int{27017, 6379}, service: "mongodb", endpoints: []int{27017, 6379}, match: "27017/TCP"},
+		{name: "default port", image: "memcached:1.6", service: "memcached", endpoints: []int{11211}, match: "memcached:1.6"},
+		{name: "no default port", image: "nginx:1.25", service: "nginx", match: "nginx:1.25"},
+		{name: "unknown", image: "example/app:1", ports: []int{8080}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			declared := make([]map[string]int, 0, len(tt.ports))
+			for _, port := range tt.ports {
+				declared = append(declared, map[string]int{"containerPort": port})
+			}
+			spec, _ := json.Marshal(map[string]interface{}{
+				"metadata": map[string]string{"name": "pod", "namespace": "default"},
+				"spec": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{"name": "app", "image": tt.image, "ports": declared}},
+				},
+				"status": map[string]string{"phase": "Running", "podIP": "10.0.0.1"},
+			})
+			var pod kubeletPod
+			if err := json.Unmarshal(spec, &pod); err != nil {
+				t.Fatalf("Failed to decode pod: %v", err)
+			}
+
+			services := ks.podServices(pod)
+			if tt.service == "" {
+				if len(services) != 0 {
+					t.Fatalf("Expected no service, got %+v", services)
+				}
+				return
+			}
+			if len(services) != 1 || services[0].Type != tt.service {
+				t.Fatalf("Expected %s, got %+v", tt.service, services)
+			}
+			var ports []int
+			for _, endpoint := range services[0].Endpoints {
+				ports = append(ports, endpoint.Port)
+			}
+			if !reflect.DeepEqual(ports, tt.endpoints) {
+				t.Errorf("Expected ports %v, got %v", tt.endpoints, ports)
+			}
+			if evidence, _ := services[0].Additional[EvidenceKey].([]Evidence); len(evidence) != 1 || evidence[0].Match != tt.match {
+				t.Errorf("Expected evidence matching %q, got %+v", tt.match, evidence)
+			}
+		})
+	}
+}
+
+func TestKubeletScanner_Errors(t *testing.T) {
+	// A token the kubelet does not accept
+	ks := kubeletServer(t, "other-token", kubeletPods)
+	if _, err := ks.Scan(context.Background()); err == nil {
+		t.Error("Expected an error for a rejected token")
+	}
+
+	ks = kubeletServer(t, "kubelet-token", `{"items": [`)
+	if _, err := ks.Scan(context.Background()); err == nil {
+		t.Error("Expected an error for a truncated pod list")
+	}
+
+	ks = kubeletServer(t, "kubelet-token", kubeletPods)
+	ks.config.CAFile = filepath.Join(t.TempDir(), "missing.crt")
+	if _, err := ks.Scan(context.Background()); err == nil {
+		t.Error("Expected an error for a missing CA file")
+	}
+}
@@ -0,0 +1,654 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// LocationKey is the ServiceInfo.Additional key holding where a
+	// service runs: LocationLAN for devices found on the local network
+	LocationKey = "location"
+	// LocationLAN is the location of services on other devices of the
+	// local network segment
+	LocationLAN = "lan"
+
+	// LANKey is the ServiceInfo.Additional key holding the *LANDevice a
+	// service was announced by
+	LANKey = "lan"
+)
+
+// LANDevice is a device of the local network announcing a service over mDNS
+// or SSDP
+type LANDevice struct {
+	// Protocol is mdns or ssdp
+	Protocol string `json:"protocol"`
+	// Name is the mDNS service instance, e.g. "Office Printer", or the
+	// SSDP unique service name
+	Name string `json:"name"`
+	// Host is the mDNS host name, e.g. officejet.local
+	Host string `json:"host,omitempty"`
+	// ServiceType is the mDNS service type, e.g. _ipp._tcp, or the SSDP
+	// search target, e.g. urn:schemas-upnp-org:device:MediaServer:1
+	ServiceType string `json:"service_type"`
+	// Model is the model the device announces, from the mDNS ty, md or
+	// product keys or the SSDP SERVER header
+	Model string `json:"model,omitempty"`
+	// Location is the URL of the SSDP device description
+	Location string `json:"location,omitempty"`
+	// Attributes are the mDNS TXT record
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// LAN returns the network device a service was discovered on, nil for
+// services of this host
+func (svc *ServiceInfo) LAN() *LANDevice {
+	device, _ := svc.Additional[LANKey].(*LANDevice)
+	return device
+}
+
+const (
+	// mdnsAddress and ssdpAddress are the IPv4 multicast groups queried
+	mdnsAddress = "224.0.0.251:5353"
+	ssdpAddress = "239.255.255.250:1900"
+
+	// defaultLANTimeout is how long answers are collected after a query
+	defaultLANTimeout = 3 * time.Second
+
+	// maxLANPacket is the largest mDNS or SSDP packet read
+	maxLANPacket = 9000
+)
+
+// lanServiceTypes are the mDNS service types queried by default, with the
+// service each indicates
+var lanServiceTypes = map[string]string{
+	"_ipp._tcp":            "printer",
+	"_ipps._tcp":           "printer",
+	"_printer._tcp":        "printer",
+	"_pdl-datastream._tcp": "printer",
+	"_smb._tcp":            "nas",
+	"_afpovertcp._tcp":     "nas",
+	"_nfs._tcp":            "nas",
+	"_hap._tcp":            "homekit",
+	"_mqtt._tcp":           "mqtt",
+	"_googlecast._tcp":     "chromecast",
+	"_airplay._tcp":        "airplay",
+	"_http._tcp":           "http",
+}
+
+// ssdpDeviceTypes are UPnP device types, from the urn:...:device:<type>:<v>
+// search targets devices answer with, and the service each indicates.
+// Other devices are reported as upnp.
+var ssdpDeviceTypes = map[string]string{
+	"InternetGatewayDevice": "router",
+	"WANDevice":             "router",
+	"MediaServer":           "media_server",
+	"MediaRenderer":         "media_renderer",
+	"Printer":               "printer",
+	"DigitalSecurityCamera": "camera",
+}
+
+// LANConfig configures local network discovery. Zero values are
+// defaulted, see SetLAN.
+type LANConfig struct {
+	// Timeout is how long answers are collected, 3s by default
+	Timeout time.Duration
+	// ServiceTypes are the mDNS service types queried, e.g. _ipp._tcp,
+	// those of lanServiceTypes by default. Types without a known service
+	// are reported as their name, e.g. _ssh._tcp as ssh.
+	ServiceTypes []string
+	// SSDP also searches for UPnP devices
+	SSDP bool
+}
+
+// LANScanner finds printers, NAS devices and other services announced on
+// the local network segment with mDNS and SSDP queries. It only sends
+// queries, answered by devices to its own port, so it needs no privileges
+// and does not listen on 5353 or 1900.
+type LANScanner struct {
+	logger  *zap.Logger
+	verbose bool
+	config  LANConfig
+}
+
+func NewLANScanner(logger *zap.Logger, config LANConfig) *LANScanner {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultLANTimeout
+	}
+	if len(config.ServiceTypes) == 0 {
+		for serviceType := range lanServiceTypes {
+			config.ServiceTypes = append(config.ServiceTypes, serviceType)
+		}
+		sort.Strings(config.ServiceTypes)
+	}
+	return &LANScanner{logger: logger, config: config}
+}
+
+// SetLAN enables discovering the services other devices announce on the
+// local network segment. Discovery stays on this host unless it is called,
+// before Discover.
+func (sd *ServiceDiscovery) SetLAN(config LANConfig) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.lanScanner = NewLANScanner(sd.logger, config)
+	sd.lanScanner.verbose = sd.processScanner.verbose
+}
+
+// Scan queries mDNS, and SSDP when enabled, in parallel. A protocol whose
+// query cannot be sent is skipped unless both fail.
+func (ls *LANScanner) Scan(ctx context.Context) ([]ServiceInfo, error) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		services []ServiceInfo
+		errs     []error
+		queries  int
+	)
+	scan := func(protocol string, query func(context.Context) ([]ServiceInfo, error)) {
+		defer wg.Done()
+		found, err := query(ctx)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			ls.logger.Debug("LAN query failed", zap.String("protocol", protocol), zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s: %w", protocol, err))
+			return
+		}
+		services = append(services, found...)
+	}
+
+	queries++
+	wg.Add(1)
+	go scan("mdns", ls.queryMDNS)
+	if ls.config.SSDP {
+		queries++
+		wg.Add(1)
+		go scan("ssdp", ls.querySSDP)
+	}
+	wg.Wait()
+
+	if len(errs) == queries {
+		return nil, errors.Join(errs...)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return lanServiceKey(services[i]) < lanServiceKey(services[j])
+	})
+	return services, nil
+}
+
+// lanServiceKey identifies a service of a device
+func lanServiceKey(svc ServiceInfo) string {
+	return fmt.Sprintf("%s:%v", svc.Type, svc.Endpoints)
+}
+
+// exchange sends query to a multicast group and returns the answers that
+// arrive within the timeout, with their senders
+func (ls *LANScanner) exchange(ctx context.Context, group string, query []byte) ([]lanAnswer, error) {
+	addr, err := net.ResolveUDPAddr("udp4", group)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(ls.config.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if _, err := conn.WriteToUDP(query, addr); err != nil {
+		return nil, fmt.Errorf("failed to send query: %w", err)
+	}
+
+	var answers []lanAnswer
+	buf := make([]byte, maxLANPacket)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return answers, nil
+			}
+			return answers, err
+		}
+		answers = append(answers, lanAnswer{from: from.IP, data: append([]byte(nil), buf[:n]...)})
+	}
+}
+
+// lanAnswer is a packet answering a query
+type lanAnswer struct {
+	from net.IP
+	data []byte
+}
+
+// queryMDNS asks for the instances of every service type. Questions ask for
+// unicast answers, so responders answer the scanner's port directly.
+func (ls *LANScanner) queryMDNS(ctx context.Context) ([]ServiceInfo, error) {
+	answers, err := ls.exchange(ctx, mdnsAddress, mdnsQuery(ls.config.ServiceTypes))
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make(map[string]*mdnsInstance)
+	for _, answer := range answers {
+		records, err := parseDNSRecords(answer.data)
+		if err != nil {
+			ls.logger.Debug("Ignoring malformed mDNS answer", zap.Stringer("from", answer.from), zap.Error(err))
+			continue
+		}
+		collectInstances(instances, records, answer.from)
+	}
+
+	seen := make(map[string]bool)
+	var services []ServiceInfo
+	for _, instance := range instances {
+		if instance.port == 0 || instance.address == nil {
+			continue
+		}
+		svc := ls.mdnsService(instance)
+		if key := lanServiceKey(svc); !seen[key] {
+			seen[key] = true
+			services = append(services, svc)
+		}
+	}
+	return services, nil
+}
+
+// mdnsService maps an announced instance to a service
+func (ls *LANScanner) mdnsService(instance *mdnsInstance) ServiceInfo {
+	service, ok := lanServiceTypes[instance.serviceType]
+	if !ok {
+		service = strings.TrimPrefix(strings.SplitN(instance.serviceType, ".", 2)[0], "_")
+	}
+	protocol := "tcp"
+	if strings.HasSuffix(instance.serviceType, "._udp") {
+		protocol = "udp"
+	}
+
+	device := &LANDevice{
+		Protocol:    "mdns",
+		Name:        instance.name,
+		Host:        strings.TrimSuffix(instance.host, "."),
+		ServiceType: instance.serviceType,
+		Attributes:  instance.txt,
+	}
+	for _, key := range []string{"ty", "md", "product", "model"} {
+		if model := instance.txt[key]; model != "" {
+			device.Model = strings.Trim(model, "()")
+			break
+		}
+	}
+
+	svc := ServiceInfo{
+		Type: service,
+		Endpoints: []Endpoint{{
+			Address:  instance.address.String(),
+			Port:     instance.port,
+			Protocol: protocol,
+		}},
+		DiscoveredBy: []string{"lan"},
+		Additional: map[string]interface{}{
+			LocationKey: LocationLAN,
+			LANKey:      device,
+		},
+	}
+	if ls.verbose {
+		addEvidence(&svc, Evidence{
+			Method: "lan",
+			Source: "mdns " + instance.address.String(),
+			Match:  instance.name + "." + instance.serviceType,
+		})
+	}
+	return svc
+}
+
+// querySSDP searches for all UPnP devices. Devices answer once for every
+// device and service they implement; each device is reported once, as the
+// most specific device type it answered with.
+func (ls *LANScanner) querySSDP(ctx context.Context) ([]ServiceInfo, error) {
+	mx := int(ls.config.Timeout / time.Second)
+	if mx < 1 {
+		mx = 1
+	}
+	query := fmt.Sprintf("M-SEARCH * HTTP/1.1\r\nHOST: %s\r\nMAN: \"ssdp:discover\"\r\nMX: %d\r\nST: ssdp:all\r\n\r\n", ssdpAddress, mx)
+	answers, err := ls.exchange(ctx, ssdpAddress, []byte(query))
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make(map[string]*ServiceInfo)
+	var order []string
+	for _, answer := range answers {
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(answer.data)), nil)
+		if err != nil {
+			ls.logger.Debug("Ignoring malformed SSDP answer", zap.Stringer("from", answer.from), zap.Error(err))
+			continue
+		}
+		resp.Body.Close()
+
+		svc, ok := ls.ssdpService(answer.from, resp.Header)
+		if !ok {
+			continue
+		}
+		// The unique service name starts with the device's UUID
+		uuid, _, _ := strings.Cut(resp.Header.Get("USN"), "::")
+		key := answer.from.String() + "/" + uuid
+		existing, ok := devices[key]
+		if !ok {
+			devices[key] = &svc
+			order = append(order, key)
+			continue
+		}
+		if existing.Type == "upnp" && svc.Type != "upnp" {
+			*existing = svc
+		}
+	}
+
+	services := make([]ServiceInfo, 0, len(order))
+	for _, key := range order {
+		services = append(services, *devices[key])
+	}
+	return services, nil
+}
+
+// ssdpService maps an SSDP answer to a service of the answering device
+func (ls *LANScanner) ssdpService(from net.IP, header http.Header) (ServiceInfo, bool) {
+	location, err := url.Parse(header.Get("LOCATION"))
+	if err != nil || location.Host == "" {
+		return ServiceInfo{}, false
+	}
+	port := 80
+	if p, err := strconv.Atoi(location.Port()); err == nil {
+		port = p
+	} else if location.Scheme == "https" {
+		port = 443
+	}
+
+	target := header.Get("ST")
+	service := "upnp"
+	if parts := strings.Split(target, ":"); len(parts) >= 4 && parts[2] == "device" {
+		if s, ok := ssdpDeviceTypes[parts[3]]; ok {
+			service = s
+		}
+	}
+
+	device := &LANDevice{
+		Protocol:    "ssdp",
+		Name:        header.Get("USN"),
+		ServiceType: target,
+		Model:       header.Get("SERVER"),
+		Location:    location.String(),
+	}
+	svc := ServiceInfo{
+		Type: service,
+		Endpoints: []Endpoint{{
+			Address:  from.String(),
+			Port:     port,
+			Protocol: "tcp",
+		}},
+		DiscoveredBy: []string{"lan"},
+		Additional: map[string]interface{}{
+			LocationKey: LocationLAN,
+			LANKey:      device,
+		},
+	}
+	if ls.verbose {
+		addEvidence(&svc, Evidence{
+			Method: "lan",
+			Source: "ssdp " + from.String(),
+			Match:  target,
+		})
+	}
+	return svc, true
+}
+
+// DNS record types of mDNS answers
+const (
+	dnsTypeA    = 1
+	dnsTypePTR  = 12
+	dnsTypeTXT  = 16
+	dnsTypeAAAA = 28
+	dnsTypeSRV  = 33
+
+	dnsClassIN = 1
+	// dnsUnicastResponse is the QU bit of a question's class, asking for a
+	// unicast answer
+	dnsUnicastResponse = 0x8000
+)
+
+// mdnsQuery encodes a query for the PTR records of the service types
+func mdnsQuery(serviceTypes []string) []byte {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[4:], uint16(len(serviceTypes)))
+	for _, serviceType := range serviceTypes {
+		for _, label := range strings.Split(serviceType+".local", ".") {
+			if label == "" {
+				continue
+			}
+			msg = append(msg, byte(len(label)))
+			msg = append(msg, label...)
+		}
+		msg = append(msg, 0)
+		msg = binary.BigEndian.AppendUint16(msg, dnsTypePTR)
+		msg = binary.BigEndian.AppendUint16(msg, dnsClassIN|dnsUnicastResponse)
+	}
+	return msg
+}
+
+// dnsRecord is a resource record of a DNS message, with the data of the
+// record types mDNS answers use
+type dnsRecord struct {
+	name  string
+	rtype uint16
+
+	ptr     string
+	port    int
+	target  string
+	address net.IP
+	txt     []string
+}
+
+// parseDNSRecords returns the answer, authority and additional records of
+// a DNS message
+func parseDNSRecords(msg []byte) ([]dnsRecord, error) {
+	if len(msg) < 12 {
+		return nil, fmt.Errorf("message too short")
+	}
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	count := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+
+	offset := 12
+	for i := 0; i < questions; i++ {
+		_, next, err := readDNSName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		offset = next + 4
+	}
+
+	records := make([]dnsRecord, 0, count)
+	for i := 0; i < count; i++ {
+		name, next, err := readDNSName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		if next+10 > len(msg) {
+			return nil, fmt.Errorf("truncated record")
+		}
+		record := dnsRecord{name: name, rtype: binary.BigEndian.Uint16(msg[next:])}
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		start := next + 10
+		end := start + length
+		if end > len(msg) {
+			return nil, fmt.Errorf("truncated record data")
+		}
+		data := msg[start:end]
+
+		switch record.rtype {
+		case dnsTypePTR:
+			record.ptr, _, err = readDNSName(msg, start)
+		case dnsTypeSRV:
+			if length < 7 {
+				return nil, fmt.Errorf("short SRV record")
+			}
+			record.port = int(binary.BigEndian.Uint16(data[4:]))
+			record.target, _, err = readDNSName(msg, start+6)
+		case dnsTypeA, dnsTypeAAAA:
+			if length == net.IPv4len || length == net.IPv6len {
+				record.address = net.IP(append([]byte(nil), data...))
+			}
+		case dnsTypeTXT:
+			for len(data) > 0 {
+				n := int(data[0])
+				if 1+n > len(data) {
+					break
+				}
+				record.txt = append(record.txt, string(data[1:1+n]))
+				data = data[1+n:]
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+		offset = end
+	}
+	return records, nil
+}
+
+// readDNSName reads the possibly compressed name at offset, returning it
+// with a trailing dot and the offset after it
+func readDNSName(msg []byte, offset int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if offset >= len(msg) {
+			return "", 0, fmt.Errorf("truncated name")
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case length&0xC0 == 0xC0:
+			if offset+1 >= len(msg) {
+				return "", 0, fmt.Errorf("truncated name pointer")
+			}
+			if jumps++; jumps > 16 {
+				return "", 0, fmt.Errorf("name pointer loop")
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3FFF)
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, fmt.Errorf("truncated label")
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+// mdnsInstance is a service instance assembled from the records of mDNS
+// answers
+type mdnsInstance struct {
+	name        string // instance label, e.g. Office Printer
+	serviceType string // e.g. _ipp._tcp
+	host        string
+	port        int
+	address     net.IP
+	txt         map[string]string
+}
+
+// collectInstances adds the instances answered in records. Instances are
+// reached at the address of their host, or else of the answering device.
+func collectInstances(instances map[string]*mdnsInstance, records []dnsRecord, from net.IP) {
+	addresses := make(map[string]net.IP)
+	for _, record := range records {
+		// Prefer IPv4 addresses, which the scanner reaches devices on
+		if record.address != nil && (addresses[record.name] == nil || record.address.To4() != nil) {
+			addresses[record.name] = record.address
+		}
+	}
+
+	instance := func(name string) *mdnsInstance {
+		if existing, ok := instances[name]; ok {
+			return existing
+		}
+		label, serviceType, ok := strings.Cut(strings.TrimSuffix(name, ".local."), ".")
+		if !ok {
+			return nil
+		}
+		instances[name] = &mdnsInstance{name: label, serviceType: serviceType}
+		return instances[name]
+	}
+
+	for _, record := range records {
+		switch record.rtype {
+		case dnsTypePTR:
+			instance(record.ptr)
+		case dnsTypeSRV:
+			if i := instance(record.name); i != nil {
+				i.host, i.port = record.target, record.port
+			}
+		case dnsTypeTXT:
+			if i := instance(record.name); i != nil {
+				i.txt = parseTXT(record.txt)
+			}
+		}
+	}
+
+	for _, i := range instances {
+		if i.address != nil || i.port == 0 {
+			continue
+		}
+		if address := addresses[i.host]; address != nil {
+			i.address = address
+		} else {
+			i.address = from
+		}
+	}
+}
+
+// parseTXT parses the key=value strings of a TXT record; keys without a
+// value are flags, set to ""
+func parseTXT(strs []string) map[string]string {
+	if len(strs) == 0 {
+		return nil
+	}
+	txt := make(map[string]string, len(strs))
+	for _, s := range strs {
+		key, value, _ := strings.Cut(s, "=")
+		if key != "" {
+			txt[strings.ToLower(key)] = value
+		}
+	}
+	if len(txt) == 0 {
+		return nil
+	}
+	return txt
+}
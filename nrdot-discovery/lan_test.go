@@ -0,0 +1,299 @@
+package discovery
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// printerAnswer is an IPP printer's answer to a PTR query for _ipp._tcp:
+// the PTR record, and the SRV, TXT, A and AAAA records of the instance and
+// its host as additional records. All names but the first are compressed.
+var printerAnswer = mustDecodeHex(
+	"000084000000000100000004045f697070045f746370056c6f63616c00000c00" +
+		"010000119400110e4f6666696365205072696e746572c00cc027002180010000" +
+		"00780012000000000277096f66666963656a6574c016c0270010800100001194" +
+		"003809747874766572733d311874793d4850204f66666963654a65742050726f" +
+		"20393031300c72703d6970702f7072696e7407436f6c6f723d54c04a00018001" +
+		"000000780004c0a80132c04a001c8001000000780010fe800000000000000000" +
+		"000000000001")
+
+// nasAnswer is a NAS answer for _smb._tcp echoing the question, its PTR
+// record pointing into the question's name
+var nasAnswer = mustDecodeHex(
+	"123484000001000100000000045f736d62045f746370056c6f63616c00000c8001" +
+		"c00c000c0001000011940006036e6173c00c")
+
+func mustDecodeHex(s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func TestParseDNSRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     []byte
+		records []dnsRecord
+		wantErr string
+	}{
+		{
+			name: "printer answer",
+			msg:  printerAnswer,
+			records: []dnsRecord{
+				{name: "_ipp._tcp.local.", rtype: dnsTypePTR, ptr: "Office Printer._ipp._tcp.local."},
+				{name: "Office Printer._ipp._tcp.local.", rtype: dnsTypeSRV, port: 631, target: "officejet.local."},
+				{name: "Office Printer._ipp._tcp.local.", rtype: dnsTypeTXT, txt: []string{
+					"txtvers=1", "ty=HP OfficeJet Pro 9010", "rp=ipp/print", "Color=T",
+				}},
+				{name: "officejet.local.", rtype: dnsTypeA, address: net.IPv4(192, 168, 1, 50).To4()},
+				{name: "officejet.local.", rtype: dnsTypeAAAA, address: net.ParseIP("fe80::1")},
+			},
+		},
+		{
+			name: "answer echoing the question",
+			msg:  nasAnswer,
+			records: []dnsRecord{
+				{name: "_smb._tcp.local.", rtype: dnsTypePTR, ptr: "nas._smb._tcp.local."},
+			},
+		},
+		{
+			name:    "short header",
+			msg:     printerAnswer[:11],
+			wantErr: "too short",
+		},
+		{
+			name:    "name pointer loop",
+			msg:     mustDecodeHex("000084000000000100000000c00c"),
+			wantErr: "loop",
+		},
+		{
+			name:    "pointer past the end",
+			msg:     mustDecodeHex("000084000000000100000000c0ff"),
+			wantErr: "truncated",
+		},
+		{
+			name:    "short SRV record",
+			msg:     mustDecodeHex("00008400000000010000000000002180010000007800020000"),
+			wantErr: "short SRV",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := parseDNSRecords(tt.msg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to parse records: %v", err)
+			}
+			if !reflect.DeepEqual(records, tt.records) {
+				t.Errorf("Expected records\n%+v\ngot\n%+v", tt.records, records)
+			}
+		})
+	}
+}
+
+func TestParseDNSRecords_Truncated(t *testing.T) {
+	for _, msg := range [][]byte{printerAnswer, nasAnswer} {
+		for n := 0; n < len(msg); n++ {
+			if _, err := parseDNSRecords(msg[:n]); err == nil {
+				t.Errorf("Expected an error for the answer truncated to %d of %d bytes", n, len(msg))
+			}
+		}
+	}
+}
+
+func TestMDNSService(t *testing.T) {
+	ls := NewLANScanner(zap.NewNop(), LANConfig{})
+	from := net.IPv4(192, 168, 1, 99).To4()
+
+	records, err := parseDNSRecords(printerAnswer)
+	if err != nil {
+		t.Fatalf("Failed to parse records: %v", err)
+	}
+	instances := make(map[string]*mdnsInstance)
+	collectInstances(instances, records, from)
+	instance := instances["Office Printer._ipp._tcp.local."]
+	if len(instances) != 1 || instance == nil {
+		t.Fatalf("Expected the printer instance, got %v", instances)
+	}
+
+	svc := ls.mdnsService(instance)
+	want := ServiceInfo{
+		Type:         "printer",
+		Endpoints:    []Endpoint{{Address: "192.168.1.50", Port: 631, Protocol: "tcp"}},
+		DiscoveredBy: []string{"lan"},
+		Additional: map[string]interface{}{
+			LocationKey: LocationLAN,
+			LANKey: &LANDevice{
+				Protocol:    "mdns",
+				Name:        "Office Printer",
+				Host:        "officejet.local",
+				ServiceType: "_ipp._tcp",
+				Model:       "HP OfficeJet Pro 9010",
+				Attributes: map[string]string{
+					"txtvers": "1",
+					"ty":      "HP OfficeJet Pro 9010",
+					"rp":      "ipp/print",
+					"color":   "T",
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(svc, want) {
+		t.Errorf("Expected\n%+v\ngot\n%+v", want, svc)
+	}
+	if device := svc.LAN(); device == nil || device.Host != "officejet.local" {
+		t.Errorf("Expected LAN() to return the printer, got %+v", device)
+	}
+}
+
+func TestCollectInstances(t *testing.T) {
+	from := net.IPv4(192, 168, 1, 20).To4()
+
+	// A PTR record alone gives no port, so the instance is not reported
+	records, err := parseDNSRecords(nasAnswer)
+	if err != nil {
+		t.Fatalf("Failed to parse records: %v", err)
+	}
+	instances := make(map[string]*mdnsInstance)
+	collectInstances(instances, records, from)
+	if nas := instances["nas._smb._tcp.local."]; nas == nil || nas.port != 0 || nas.address != nil {
+		t.Errorf("Expected a NAS instance without port or address, got %+v", nas)
+	}
+
+	// Without an address record of the host, the answering device is used
+	instances = make(map[string]*mdnsInstance)
+	collectInstances(instances, []dnsRecord{
+		{name: "broker._mqtt._tcp.local.", rtype: dnsTypeSRV, port: 1883, target: "broker.local."},
+		{name: "broker._mqtt._tcp.local.", rtype: dnsTypeTXT, txt: []string{"=ignored", "secure"}},
+	}, from)
+	broker := instances["broker._mqtt._tcp.local."]
+	if broker == nil || !broker.address.Equal(from) || broker.port != 1883 {
+		t.Fatalf("Expected the broker at %v:1883, got %+v", from, broker)
+	}
+	if want := map[string]string{"secure": ""}; !reflect.DeepEqual(broker.txt, want) {
+		t.Errorf("Expected TXT %v, got %v", want, broker.txt)
+	}
+}
+
+func TestMDNSService_UnknownTypes(t *testing.T) {
+	ls := NewLANScanner(zap.NewNop(), LANConfig{})
+	for serviceType, want := range map[string]struct {
+		service  string
+		protocol string
+	}{
+		"_ipps._tcp":        {"printer", "tcp"},
+		"_ssh._tcp":         {"ssh", "tcp"},
+		"_sleep-proxy._udp": {"sleep-proxy", "udp"},
+	} {
+		svc := ls.mdnsService(&mdnsInstance{
+			name:        "device",
+			serviceType: serviceType,
+			port:        1,
+			address:     net.IPv4(10, 0, 0, 1),
+		})
+		if svc.Type != want.service || svc.Endpoints[0].Protocol != want.protocol {
+			t.Errorf("%s: expected %s over %s, got %s over %s",
+				serviceType, want.service, want.protocol, svc.Type, svc.Endpoints[0].Protocol)
+		}
+	}
+}
+
+func TestSSDPService(t *testing.T) {
+	ls := NewLANScanner(zap.NewNop(), LANConfig{SSDP: true})
+	from := net.IPv4(192, 168, 1, 1).To4()
+
+	tests := []struct {
+		name     string
+		response string
+		service  string
+		port     int
+		ok       bool
+	}{
+		{
+			name: "gateway",
+			response: "HTTP/1.1 200 OK\r\n" +
+				"CACHE-CONTROL: max-age=120\r\n" +
+				"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n" +
+				"USN: uuid:6a3b2f1c-0000-1000-8000-00223f1a2b3c::urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n" +
+				"EXT:\r\n" +
+				"SERVER: Linux/5.4 UPnP/1.1 MiniUPnPd/2.2.1\r\n" +
+				"LOCATION: http://192.168.1.1:5000/rootDesc.xml\r\n\r\n",
+			service: "router",
+			port:    5000,
+			ok:      true,
+		},
+		{
+			name: "media server on the default port",
+			response: "HTTP/1.1 200 OK\r\n" +
+				"ST: urn:schemas-upnp-org:device:MediaServer:1\r\n" +
+				"USN: uuid:4d696e69-444c-164e-9d41-b827eb000001::urn:schemas-upnp-org:device:MediaServer:1\r\n" +
+				"LOCATION: http://192.168.1.1/description.xml\r\n\r\n",
+			service: "media_server",
+			port:    80,
+			ok:      true,
+		},
+		{
+			name: "service search target over https",
+			response: "HTTP/1.1 200 OK\r\n" +
+				"ST: urn:schemas-upnp-org:service:ContentDirectory:1\r\n" +
+				"USN: uuid:4d696e69-444c-164e-9d41-b827eb000001::urn:schemas-upnp-org:service:ContentDirectory:1\r\n" +
+				"LOCATION: https://192.168.1.1/description.xml\r\n\r\n",
+			service: "upnp",
+			port:    443,
+			ok:      true,
+		},
+		{
+			name: "missing location",
+			response: "HTTP/1.1 200 OK\r\n" +
+				"ST: upnp:rootdevice\r\n" +
+				"USN: uuid:6a3b2f1c-0000-1000-8000-00223f1a2b3c::upnp:rootdevice\r\n\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(tt.response)), nil)
+			if err != nil {
+				t.Fatalf("Failed to read response: %v", err)
+			}
+			resp.Body.Close()
+
+			svc, ok := ls.ssdpService(from, resp.Header)
+			if ok != tt.ok {
+				t.Fatalf("Expected ok %v, got %v", tt.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if svc.Type != tt.service {
+				t.Errorf("Expected service %q, got %q", tt.service, svc.Type)
+			}
+			if want := []Endpoint{{Address: "192.168.1.1", Port: tt.port, Protocol: "tcp"}}; !reflect.DeepEqual(svc.Endpoints, want) {
+				t.Errorf("Expected endpoints %v, got %v", want, svc.Endpoints)
+			}
+			device := svc.LAN()
+			if device == nil || device.Protocol != "ssdp" || device.Name != resp.Header.Get("USN") ||
+				device.ServiceType != resp.Header.Get("ST") || device.Location != resp.Header.Get("LOCATION") {
+				t.Errorf("Unexpected device %+v", device)
+			}
+			if svc.Additional[LocationKey] != LocationLAN {
+				t.Errorf("Expected location %q, got %v", LocationLAN, svc.Additional[LocationKey])
+			}
+		})
+	}
+}
@@ -0,0 +1,413 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-telemetry/process"
+	"go.uber.org/zap"
+)
+
+// helperTimeout bounds a socket owner lookup by the privileged helper
+const helperTimeout = 10 * time.Second
+
+// portOwner is the process listening on a port, with what its executable
+// and command line tell about the service
+type portOwner struct {
+	info        *process.ProcessInfo
+	version     string
+	configPaths []string
+}
+
+// resolveOwners ties listening ports to their processes by socket inode.
+// The fds of other users' processes cannot be read without privileges;
+// the sockets left are resolved by the privileged helper, when installed.
+func (ps *PortScanner) resolveOwners(ctx context.Context, ports []ListeningPort) map[uint64]*portOwner {
+	inodes := make(map[uint64]bool)
+	for _, port := range ports {
+		if port.Inode != 0 {
+			inodes[port.Inode] = true
+		}
+	}
+	if len(inodes) == 0 {
+		return nil
+	}
+
+	pids := scanSocketOwners(ps.procRoot, inodes)
+	if len(pids) < len(inodes) && ps.helper != "" {
+		var missing []uint64
+		for inode := range inodes {
+			if _, ok := pids[inode]; !ok {
+				missing = append(missing, inode)
+			}
+		}
+		helperPIDs, err := helperSocketOwners(ctx, ps.helper, missing)
+		if err != nil {
+			ps.logger.Debug("Privileged helper did not resolve socket owners",
+				zap.String("helper", ps.helper),
+				zap.Int("sockets", len(missing)),
+				zap.Error(err))
+		}
+		for inode, pid := range helperPIDs {
+			pids[inode] = pid
+		}
+	}
+
+	owners := make(map[uint64]*portOwner, len(pids))
+	byPID := make(map[int32]*portOwner)
+	for inode, pid := range pids {
+		owner, read := byPID[pid]
+		if !read {
+			var err error
+			owner, err = readOwner(ps.procRoot, pid)
+			if err != nil {
+				// Exited since, or hidden from us
+				ps.logger.Debug("Failed to read socket owner", zap.Int32("pid", pid), zap.Error(err))
+			}
+			byPID[pid] = owner
+		}
+		if owner != nil {
+			owners[inode] = owner
+		}
+	}
+	return owners
+}
+
+// scanSocketOwners finds the processes holding sockets from the
+// socket:[inode] links in /proc/<pid>/fd. Workers inherit their parent's
+// listening socket, so the lowest PID, usually the parent, owns it.
+func scanSocketOwners(procRoot string, inodes map[uint64]bool) map[uint64]int32 {
+	owners := make(map[uint64]int32)
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return owners
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.ParseInt(entry.Name(), 10, 32)
+		if err != nil {
+			continue // Not a PID directory
+		}
+		fdDir := filepath.Join(procRoot, entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue // Another user's process
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil {
+				continue
+			}
+			inode, ok := socketInode(link)
+			if !ok || !inodes[inode] {
+				continue
+			}
+			if owner, seen := owners[inode]; !seen || int32(pid) < owner {
+				owners[inode] = int32(pid)
+			}
+		}
+	}
+	return owners
+}
+
+// socketInode parses the inode of an fd link like socket:[12345]
+func socketInode(link string) (uint64, bool) {
+	rest, ok := strings.CutPrefix(link, "socket:[")
+	if !ok || !strings.HasSuffix(rest, "]") {
+		return 0, false
+	}
+	inode, err := strconv.ParseUint(strings.TrimSuffix(rest, "]"), 10, 64)
+	return inode, err == nil
+}
+
+// helperSocketOwners asks the privileged helper for the processes holding
+// sockets. A missing helper resolves nothing.
+func helperSocketOwners(ctx context.Context, helper string, inodes []uint64) (map[uint64]int32, error) {
+	if _, err := os.Stat(helper); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	request, err := json.Marshal(map[string]interface{}{
+		"operation": "socket_owners",
+		"inodes":    inodes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, helperTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, helper)
+	cmd.Stdin = bytes.NewReader(request)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Success bool `json:"success"`
+		Data    struct {
+			Owners map[string]int32 `json:"owners"`
+		} `json:"data"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(output, &response); err != nil {
+		return nil, fmt.Errorf("invalid helper response: %w", err)
+	}
+	if !response.Success {
+		return nil, errors.New(response.Error)
+	}
+
+	owners := make(map[uint64]int32, len(response.Data.Owners))
+	for key, pid := range response.Data.Owners {
+		if inode, err := strconv.ParseUint(key, 10, 64); err == nil {
+			owners[inode] = pid
+		}
+	}
+	return owners, nil
+}
+
+// readOwner reads the name, parent and command line of a socket owner,
+// which are readable for every process
+func readOwner(procRoot string, pid int32) (*portOwner, error) {
+	dir := filepath.Join(procRoot, strconv.Itoa(int(pid)))
+	comm, err := os.ReadFile(filepath.Join(dir, "comm"))
+	if err != nil {
+		return nil, err
+	}
+	info := &process.ProcessInfo{PID: pid, Name: strings.TrimSpace(string(comm))}
+
+	// The fields after the parenthesized command are state and ppid
+	if stat, err := os.ReadFile(filepath.Join(dir, "stat")); err == nil {
+		if i := bytes.LastIndexByte(stat, ')'); i >= 0 {
+			if fields := strings.Fields(string(stat[i+1:])); len(fields) > 1 {
+				ppid, _ := strconv.ParseInt(fields[1], 10, 32)
+				info.PPID = int32(ppid)
+			}
+		}
+	}
+
+	var args []string
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil && len(cmdline) > 0 {
+		args = strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+		info.Cmdline = strings.Join(args, " ")
+	}
+
+	// Only readable with the fds; the command line has to do otherwise
+	exe, _ := os.Readlink(filepath.Join(dir, "exe"))
+
+	return &portOwner{
+		info:        info,
+		version:     processVersion(exe, args),
+		configPaths: configPathsFromArgs(args),
+	}, nil
+}
+
+// Versions in install paths, e.g. /usr/lib/postgresql/14/bin/postgres or
+// /opt/kafka_2.13-3.5.1/libs. Paths on Java command lines only count with
+// a dotted version, they hold data directories and the like too.
+var (
+	pathVersion   = regexp.MustCompile(`[/_-](\d+(?:\.\d+)*)(?:/|$)`)
+	dottedVersion = regexp.MustCompile(`[/_-](\d+\.\d+(?:\.\d+)*)(?:/|$)`)
+)
+
+// processVersion reads the version of a service from its executable path,
+// or for Java services from the install paths on its command line
+func processVersion(exe string, args []string) string {
+	program := exe
+	if program == "" && len(args) > 0 {
+		program = args[0]
+	}
+
+	if filepath.Base(program) != "java" {
+		for _, path := range []string{exe, firstArg(args)} {
+			if match := pathVersion.FindStringSubmatch(path); match != nil {
+				return match[1]
+			}
+		}
+		return ""
+	}
+
+	// The JVM's own version is not the service's
+	for i, arg := range args {
+		if i == 0 {
+			continue
+		}
+		for _, part := range strings.FieldsFunc(arg, func(r rune) bool { return r == ':' || r == '=' }) {
+			if !filepath.IsAbs(part) || strings.Contains(part, "/jvm/") || strings.Contains(part, "jdk") || strings.Contains(part, "jre") {
+				continue
+			}
+			if match := dottedVersion.FindStringSubmatch(part); match != nil {
+				return match[1]
+			}
+		}
+	}
+	return ""
+}
+
+// firstArg returns the program a command line ran, empty without one
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+// configExtensions are the extensions of config files passed on command
+// lines, e.g. redis-server /etc/redis/redis.conf or
+// postgres -c config_file=/etc/postgresql/14/main/postgresql.conf
+var configExtensions = map[string]bool{
+	".conf":       true,
+	".cnf":        true,
+	".cfg":        true,
+	".ini":        true,
+	".yml":        true,
+	".yaml":       true,
+	".xml":        true,
+	".properties": true,
+	".toml":       true,
+	".hcl":        true,
+}
+
+// configPathsFromArgs returns the config files on a command line, as
+// arguments or option values
+func configPathsFromArgs(args []string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for i, arg := range args {
+		if i == 0 {
+			continue
+		}
+		if j := strings.LastIndexByte(arg, '='); j >= 0 {
+			arg = arg[j+1:]
+		}
+		if filepath.IsAbs(arg) && configExtensions[filepath.Ext(arg)] && !seen[arg] {
+			seen[arg] = true
+			paths = append(paths, arg)
+		}
+	}
+	return paths
+}
+
+// addEndpoint adds an endpoint unless its port is there already, as on
+// both /proc/net/tcp and /proc/net/tcp6 for dual-stack listeners
+func addEndpoint(endpoints []Endpoint, endpoint Endpoint) []Endpoint {
+	for _, ep := range endpoints {
+		if ep.Port == endpoint.Port {
+			return endpoints
+		}
+	}
+	return append(endpoints, endpoint)
+}
+
+// sortEndpoints orders the ports of a process with the default ports of its
+// service first, then by port
+func sortEndpoints(endpoints []Endpoint, metadata map[string]interface{}) {
+	defaults := make(map[int]bool)
+	if port, ok := metadata["default_port"].(int); ok {
+		defaults[port] = true
+	}
+	if ports, ok := metadata["default_ports"].([]int); ok {
+		for _, port := range ports {
+			defaults[port] = true
+		}
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		if defaults[endpoints[i].Port] != defaults[endpoints[j].Port] {
+			return defaults[endpoints[i].Port]
+		}
+		return endpoints[i].Port < endpoints[j].Port
+	})
+}
+
+// portEvidence is the /proc/net entry of a port and the fd tying it to its
+// process
+func portEvidence(port ListeningPort, owner *portOwner) []Evidence {
+	evidence := []Evidence{{
+		Method: "port",
+		Source: port.Source,
+		Match:  port.Raw,
+	}}
+	if owner != nil {
+		command := owner.info.Cmdline
+		if command == "" {
+			command = owner.info.Name
+		}
+		evidence = append(evidence, Evidence{
+			Method: "port",
+			Source: fmt.Sprintf("/proc/%d/fd", owner.info.PID),
+			Match:  fmt.Sprintf("socket:[%d] held by %s", port.Inode, command),
+		})
+	}
+	return evidence
+}
+
+// mergeByProcess folds services found on the ports of a process into the
+// same service found by the process scan, which only knows its default
+// endpoints. The processes match when one is the other or its parent, as
+// workers share their parent's listening sockets.
+func (sd *ServiceDiscovery) mergeByProcess(services []ServiceInfo) []ServiceInfo {
+	merged := make([]bool, len(services))
+	for i := range services {
+		observed := &services[i]
+		if observed.ProcessInfo == nil || !hasListeningPID(observed.Endpoints) {
+			continue
+		}
+		for j := range services {
+			target := &services[j]
+			if i == j || merged[j] || target.Type != observed.Type || target.ProcessInfo == nil ||
+				hasListeningPID(target.Endpoints) || !relatedProcesses(target.ProcessInfo, observed.ProcessInfo) {
+				continue
+			}
+
+			// The ports the process listens on replace the defaults
+			target.Endpoints = observed.Endpoints
+			target.DiscoveredBy = mergeStrings(target.DiscoveredBy, observed.DiscoveredBy)
+			target.Confidence = sd.calculateConfidence(target.DiscoveredBy)
+			if target.Version == "" {
+				target.Version = observed.Version
+			}
+			target.ConfigPaths = mergeStrings(observed.ConfigPaths, target.ConfigPaths)
+			if evidence, ok := observed.Additional[EvidenceKey].([]Evidence); ok {
+				addEvidence(target, evidence...)
+			}
+			merged[i] = true
+			break
+		}
+	}
+
+	result := services[:0]
+	for i, svc := range services {
+		if !merged[i] {
+			result = append(result, svc)
+		}
+	}
+	return result
+}
+
+// hasListeningPID reports whether endpoints were tied to a process
+func hasListeningPID(endpoints []Endpoint) bool {
+	for _, ep := range endpoints {
+		if ep.PID != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// relatedProcesses reports whether two processes are the same or parent
+// and child
+func relatedProcesses(a, b *process.ProcessInfo) bool {
+	return a.PID == b.PID || a.PPID == b.PID || b.PPID == a.PID
+}
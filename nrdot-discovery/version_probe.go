@@ -0,0 +1,409 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultVersionProbeTimeout bounds each probe unless configured
+	defaultVersionProbeTimeout = 2 * time.Second
+	// maxProbeResponse is the most read of any probe response
+	maxProbeResponse = 64 << 10
+	// probeRecheckInterval is how long a failed probe, or one of a service
+	// whose process start is unknown, is remembered before probing again
+	probeRecheckInterval = time.Hour
+)
+
+// VersionProbeKey is the ServiceInfo.Additional key holding the *ProbeInfo
+// of a service whose version was read from one of its endpoints
+const VersionProbeKey = "version_probe"
+
+// ProbeInfo records how the version of a service was read
+type ProbeInfo struct {
+	Address string `json:"address"`
+	// Scheme is the protocol the service answered over: mysql, redis or
+	// http. Services answering over HTTP were asked in plain text.
+	Scheme string `json:"scheme"`
+	// Authenticated is whether credentials were sent, never for now
+	Authenticated bool `json:"authenticated"`
+}
+
+// VersionProbe returns how the version of a service was read, nil for
+// versions guessed from its install path or not known
+func (svc *ServiceInfo) VersionProbe() *ProbeInfo {
+	probe, _ := svc.Additional[VersionProbeKey].(*ProbeInfo)
+	return probe
+}
+
+// VersionProbeConfig configures version probes. Zero values are defaulted,
+// see SetVersionProbes.
+type VersionProbeConfig struct {
+	// Timeout bounds each probe, from connecting to the last byte read,
+	// 2s by default
+	Timeout time.Duration
+}
+
+// dialFunc connects to the endpoint at address, the connection's deadline
+// bounding the whole exchange
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// versionProbe asks a service for its version at address over a connection
+// from dial, returning the version and the raw answer it was read from
+type versionProbe func(ctx context.Context, address string, dial dialFunc) (version, answer string, err error)
+
+// versionProbes are the handshakes sent per service type, and the scheme
+// each speaks. Each only reads what the service tells unauthenticated
+// clients: the MySQL greeting, the server section of Redis INFO, the HTTP
+// Server header and the root of the Elasticsearch API.
+var versionProbes = map[string]struct {
+	scheme string
+	probe  versionProbe
+}{
+	"mysql":         {"mysql", probeMySQL},
+	"redis":         {"redis", probeRedis},
+	"nginx":         {"http", probeServerHeader("nginx")},
+	"apache":        {"http", probeServerHeader("Apache")},
+	"elasticsearch": {"http", probeElasticsearch},
+}
+
+// leadingVersion is the dotted version an answer starts with, e.g. 8.0.35
+// of 8.0.35-0ubuntu0.22.04.1
+var leadingVersion = regexp.MustCompile(`^\d+(?:\.\d+)+`)
+
+// VersionProber reads the versions of discovered services from their
+// endpoints. Unlike the other discovery methods it talks to the services,
+// so it is off unless SetVersionProbes is called.
+//
+// Answers are remembered per endpoint and process start, so a service is
+// asked once rather than every scan: MySQL counts each greeting left
+// without a login toward max_connect_errors, and blocks the host at the
+// limit.
+type VersionProber struct {
+	logger  *zap.Logger
+	verbose bool
+	config  VersionProbeConfig
+	dial    dialFunc
+	clock   clock.Clock
+
+	mu      sync.Mutex
+	answers map[string]probeAnswer
+}
+
+// probeAnswer is a remembered probe of an endpoint
+type probeAnswer struct {
+	// instance identifies the process answering, see serviceInstance
+	instance string
+	version  string
+	answer   string
+	err      error
+	at       time.Time
+}
+
+func NewVersionProber(logger *zap.Logger, config VersionProbeConfig) *VersionProber {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultVersionProbeTimeout
+	}
+	timeout := config.Timeout
+	return &VersionProber{
+		logger: logger,
+		config: config,
+		dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialProbe(ctx, network, address, timeout)
+		},
+		clock:   clock.Real(),
+		answers: make(map[string]probeAnswer),
+	}
+}
+
+// SetVersionProbes enables reading the versions of discovered services by
+// sending them protocol handshakes, for templates to pick receiver settings
+// by version. Call it before Discover.
+func (sd *ServiceDiscovery) SetVersionProbes(config VersionProbeConfig) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.versionProber = NewVersionProber(sd.logger, config)
+	sd.versionProber.verbose = sd.processScanner.verbose
+}
+
+// Probe sets the version of every service with a probe from the first of
+// its endpoints to answer. A version read from the service replaces one
+// guessed from its install path. Devices of the local network and services
+// whose ports are closed are left alone.
+func (vp *VersionProber) Probe(ctx context.Context, services []ServiceInfo) {
+	var wg sync.WaitGroup
+	for i := range services {
+		svc := &services[i]
+		probe, ok := versionProbes[svc.Type]
+		if !ok || svc.LAN() != nil || svc.Stopped() ||
+			(svc.Health != nil && svc.Health.PortsOpen != nil && !*svc.Health.PortsOpen) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vp.probe(ctx, svc, probe.scheme, probe.probe)
+		}()
+	}
+	wg.Wait()
+}
+
+// probe tries the TCP endpoints of a service in order, asking each unless
+// its answer for the same process is remembered
+func (vp *VersionProber) probe(ctx context.Context, svc *ServiceInfo, scheme string, probe versionProbe) {
+	instance := serviceInstance(svc)
+	for _, endpoint := range svc.Endpoints {
+		if endpoint.Protocol != "" && endpoint.Protocol != "tcp" {
+			continue
+		}
+		address := dialAddress(endpoint)
+		key := svc.Type + "|" + address
+
+		answer, ok := vp.remembered(key, instance)
+		if !ok {
+			answer = probeAnswer{instance: instance, at: vp.clock.Now()}
+			answer.version, answer.answer, answer.err = probe(ctx, address, vp.dial)
+			if ctx.Err() != nil {
+				// Cancelled rather than answered
+				return
+			}
+			vp.mu.Lock()
+			vp.answers[key] = answer
+			vp.mu.Unlock()
+
+			if answer.err != nil {
+				vp.logger.Debug("Version probe failed",
+					zap.String("service", svc.Type),
+					zap.String("address", address),
+					zap.Error(answer.err))
+			} else {
+				vp.logger.Debug("Version probed",
+					zap.String("service", svc.Type),
+					zap.String("address", address),
+					zap.String("version", answer.version))
+			}
+		}
+		if answer.err != nil {
+			continue
+		}
+
+		svc.Version = answer.version
+		if svc.Additional == nil {
+			svc.Additional = make(map[string]interface{})
+		}
+		svc.Additional[VersionProbeKey] = &ProbeInfo{Address: address, Scheme: scheme}
+		if vp.verbose {
+			addEvidence(svc, Evidence{Method: "version_probe", Source: address, Match: answer.answer})
+		}
+		return
+	}
+}
+
+// remembered returns the answer of an endpoint probed for the same process.
+// Failures, and answers of processes whose start is unknown, are forgotten
+// after probeRecheckInterval.
+func (vp *VersionProber) remembered(key, instance string) (probeAnswer, bool) {
+	vp.mu.Lock()
+	defer vp.mu.Unlock()
+	answer, ok := vp.answers[key]
+	if !ok || answer.instance != instance {
+		return probeAnswer{}, false
+	}
+	if (answer.err != nil || instance == "") && vp.clock.Since(answer.at) >= probeRecheckInterval {
+		return probeAnswer{}, false
+	}
+	return answer, true
+}
+
+// serviceInstance identifies the process serving a service across scans:
+// its PID and start time, or its container or pod, which are replaced
+// rather than restarted on upgrade. Empty if unknown.
+func serviceInstance(svc *ServiceInfo) string {
+	if info := svc.ProcessInfo; info != nil && info.CreateTime != 0 {
+		return fmt.Sprintf("process:%d@%d", info.PID, info.CreateTime)
+	}
+	if container := svc.Container(); container != nil {
+		return "container:" + container.ID
+	}
+	if pod := svc.Pod(); pod != nil {
+		return "pod:" + pod.UID + "/" + pod.Container
+	}
+	return ""
+}
+
+// probeMySQL reads the server version from the greeting MySQL and MariaDB
+// send on connect, before any authentication
+func probeMySQL(ctx context.Context, address string, dial dialFunc) (string, string, error) {
+	conn, err := dial(ctx, "tcp", address)
+	if err != nil {
+		return "", "", err
+	}
+	defer conn.Close()
+
+	// Packets are a 3 byte little-endian length and a sequence number
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return "", "", err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	if length < 2 || length > maxProbeResponse {
+		return "", "", fmt.Errorf("unexpected greeting length %d", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return "", "", err
+	}
+
+	// An error packet instead, e.g. for hosts not allowed to connect
+	if payload[0] == 0xff {
+		return "", "", errors.New("server refused the connection")
+	}
+	if payload[0] != 10 {
+		return "", "", fmt.Errorf("unsupported protocol version %d", payload[0])
+	}
+	end := strings.IndexByte(string(payload[1:]), 0)
+	if end < 0 {
+		return "", "", errors.New("malformed greeting")
+	}
+	answer := string(payload[1 : 1+end])
+
+	// MariaDB prefixes its version for old replication clients, e.g.
+	// 5.5.5-10.6.12-MariaDB
+	version := leadingVersion.FindString(strings.TrimPrefix(answer, "5.5.5-"))
+	if version == "" {
+		return "", "", fmt.Errorf("no version in greeting %q", answer)
+	}
+	return version, answer, nil
+}
+
+// probeRedis reads redis_version from INFO server. Servers requiring a
+// password answer with an error instead.
+func probeRedis(ctx context.Context, address string, dial dialFunc) (string, string, error) {
+	conn, err := dial(ctx, "tcp", address)
+	if err != nil {
+		return "", "", err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("INFO server\r\n")); err != nil {
+		return "", "", err
+	}
+	reader := bufio.NewReader(io.LimitReader(conn, maxProbeResponse))
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "-") {
+		return "", "", fmt.Errorf("server answered %q", strings.TrimPrefix(line, "-"))
+	}
+	size, err := strconv.Atoi(strings.TrimPrefix(line, "$"))
+	if !strings.HasPrefix(line, "$") || err != nil || size < 0 || size > maxProbeResponse {
+		return "", "", fmt.Errorf("unexpected reply %q", line)
+	}
+
+	info := make([]byte, size)
+	if _, err := io.ReadFull(reader, info); err != nil {
+		return "", "", err
+	}
+	for _, field := range strings.Split(string(info), "\r\n") {
+		if version, ok := strings.CutPrefix(field, "redis_version:"); ok {
+			return version, field, nil
+		}
+	}
+	return "", "", errors.New("no redis_version in INFO")
+}
+
+// probeServerHeader reads the version from the Server header of the answer
+// to HEAD /, e.g. nginx/1.24.0, if the header names product. Servers hiding
+// their version, as with nginx's server_tokens off, give none.
+func probeServerHeader(product string) versionProbe {
+	return func(ctx context.Context, address string, dial dialFunc) (string, string, error) {
+		resp, err := probeHTTP(ctx, http.MethodHead, address, dial)
+		if err != nil {
+			return "", "", err
+		}
+		resp.Body.Close()
+
+		answer := resp.Header.Get("Server")
+		name, rest, found := strings.Cut(answer, "/")
+		if !found || !strings.EqualFold(name, product) {
+			return "", "", fmt.Errorf("no %s version in Server header %q", product, answer)
+		}
+		version := leadingVersion.FindString(rest)
+		if version == "" {
+			return "", "", fmt.Errorf("no %s version in Server header %q", product, answer)
+		}
+		return version, answer, nil
+	}
+}
+
+// probeElasticsearch reads version.number from the root of the API, which
+// clusters with security enabled only answer to authenticated clients
+func probeElasticsearch(ctx context.Context, address string, dial dialFunc) (string, string, error) {
+	resp, err := probeHTTP(ctx, http.MethodGet, address, dial)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("server answered %s", resp.Status)
+	}
+
+	var root struct {
+		Version struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxProbeResponse)).Decode(&root); err != nil {
+		return "", "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	version := leadingVersion.FindString(root.Version.Number)
+	if version == "" {
+		return "", "", errors.New("no version.number in response")
+	}
+	return version, root.Version.Number, nil
+}
+
+// dialProbe connects to address, the timeout bounding the whole exchange
+func dialProbe(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// probeHTTP requests / over plain HTTP without following redirects
+func probeHTTP(ctx context.Context, method, address string, dial dialFunc) (*http.Response, error) {
+	client := &http.Client{
+		Transport: &http.Transport{DialContext: dial, DisableKeepAlives: true},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "http://"+address+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
@@ -0,0 +1,245 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
+	"github.com/newrelic/nrdot-host/nrdot-telemetry/process"
+	"go.uber.org/zap"
+)
+
+// pipeDial answers every dial with one end of a net.Pipe, serve running
+// the service on the other. dials counts the connections made.
+func pipeDial(serve func(conn net.Conn), dials *int32) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		atomic.AddInt32(dials, 1)
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			serve(server)
+		}()
+		if err := client.SetDeadline(time.Now().Add(time.Second)); err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+}
+
+// mysqlGreeting writes the greeting packet of a server with version
+func mysqlGreeting(version string) func(net.Conn) {
+	return func(conn net.Conn) {
+		payload := append([]byte{10}, version...)
+		payload = append(payload, 0, 1, 0, 0, 0)
+		header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), 0}
+		conn.Write(append(header, payload...))
+	}
+}
+
+// httpServer answers one request with a response of status, headers and body
+func httpServer(status string, headers map[string]string, body string) func(net.Conn) {
+	return func(conn net.Conn) {
+		if _, err := http.ReadRequest(bufio.NewReader(conn)); err != nil {
+			return
+		}
+		var resp strings.Builder
+		resp.WriteString("HTTP/1.1 " + status + "\r\n")
+		for name, value := range headers {
+			resp.WriteString(name + ": " + value + "\r\n")
+		}
+		resp.WriteString("Content-Length: " + strconv.Itoa(len(body)) + "\r\nConnection: close\r\n\r\n" + body)
+		conn.Write([]byte(resp.String()))
+	}
+}
+
+func TestVersionProbes(t *testing.T) {
+	tests := []struct {
+		name    string
+		probe   versionProbe
+		serve   func(net.Conn)
+		version string
+		wantErr string
+	}{
+		{
+			name:    "mysql greeting",
+			probe:   probeMySQL,
+			serve:   mysqlGreeting("8.0.35-0ubuntu0.22.04.1"),
+			version: "8.0.35",
+		},
+		{
+			name:    "mariadb replication prefix",
+			probe:   probeMySQL,
+			serve:   mysqlGreeting("5.5.5-10.6.12-MariaDB-0ubuntu0.22.04.1"),
+			version: "10.6.12",
+		},
+		{
+			name:  "mysql host not allowed",
+			probe: probeMySQL,
+			serve: func(conn net.Conn) {
+				conn.Write([]byte{5, 0, 0, 0, 0xff, 0x6a, 0x04, '#', 'H'})
+			},
+			wantErr: "refused",
+		},
+		{
+			name:  "redis info",
+			probe: probeRedis,
+			serve: func(conn net.Conn) {
+				bufio.NewReader(conn).ReadString('\n')
+				info := "# Server\r\nredis_version:7.2.4\r\nredis_mode:standalone\r\n"
+				conn.Write([]byte("$" + strconv.Itoa(len(info)) + "\r\n" + info + "\r\n"))
+			},
+			version: "7.2.4",
+		},
+		{
+			name:  "redis requiring a password",
+			probe: probeRedis,
+			serve: func(conn net.Conn) {
+				bufio.NewReader(conn).ReadString('\n')
+				conn.Write([]byte("-NOAUTH Authentication required.\r\n"))
+			},
+			wantErr: "NOAUTH",
+		},
+		{
+			name:    "nginx server header",
+			probe:   probeServerHeader("nginx"),
+			serve:   httpServer("200 OK", map[string]string{"Server": "nginx/1.24.0 (Ubuntu)"}, ""),
+			version: "1.24.0",
+		},
+		{
+			name:    "server header hiding the version",
+			probe:   probeServerHeader("nginx"),
+			serve:   httpServer("200 OK", map[string]string{"Server": "nginx"}, ""),
+			wantErr: "no nginx version",
+		},
+		{
+			name:    "missing server header",
+			probe:   probeServerHeader("Apache"),
+			serve:   httpServer("200 OK", nil, ""),
+			wantErr: "no Apache version",
+		},
+		{
+			name:    "elasticsearch root",
+			probe:   probeElasticsearch,
+			serve:   httpServer("200 OK", nil, `{"name":"node-1","version":{"number":"8.11.1"}}`),
+			version: "8.11.1",
+		},
+		{
+			name:    "elasticsearch requiring credentials",
+			probe:   probeElasticsearch,
+			serve:   httpServer("401 Unauthorized", nil, `{}`),
+			wantErr: "401",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dials int32
+			version, _, err := tt.probe(context.Background(), "127.0.0.1:1", pipeDial(tt.serve, &dials))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to probe: %v", err)
+			}
+			if version != tt.version {
+				t.Errorf("Expected version %q, got %q", tt.version, version)
+			}
+		})
+	}
+}
+
+func TestVersionProber_Probe(t *testing.T) {
+	var dials int32
+	vp := NewVersionProber(zap.NewNop(), VersionProbeConfig{})
+	vp.dial = pipeDial(httpServer("200 OK", nil, `{"version":{"number":"8.11.1"}}`), &dials)
+
+	services := []ServiceInfo{{
+		Type:      "elasticsearch",
+		Version:   "7",
+		Endpoints: []Endpoint{{Address: "0.0.0.0", Port: 9200, Protocol: "tcp"}},
+	}}
+	vp.Probe(context.Background(), services)
+
+	if services[0].Version != "8.11.1" {
+		t.Errorf("Expected the probed version to replace the guessed one, got %q", services[0].Version)
+	}
+	probe := services[0].VersionProbe()
+	if probe == nil || probe.Scheme != "http" || probe.Authenticated {
+		t.Errorf("Expected an unauthenticated probe over http, got %+v", probe)
+	}
+}
+
+func TestVersionProber_RemembersAnswers(t *testing.T) {
+	var dials int32
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	vp := NewVersionProber(zap.NewNop(), VersionProbeConfig{})
+	vp.clock = fake
+	vp.dial = pipeDial(mysqlGreeting("8.0.35"), &dials)
+
+	mysql := func(createTime int64) []ServiceInfo {
+		return []ServiceInfo{{
+			Type:        "mysql",
+			Endpoints:   []Endpoint{{Address: "127.0.0.1", Port: 3306, Protocol: "tcp"}},
+			ProcessInfo: &process.ProcessInfo{PID: 1234, CreateTime: createTime},
+		}}
+	}
+
+	for scan := 0; scan < 3; scan++ {
+		services := mysql(1700000000)
+		vp.Probe(context.Background(), services)
+		if services[0].Version != "8.0.35" {
+			t.Fatalf("Scan %d: expected version 8.0.35, got %q", scan, services[0].Version)
+		}
+		fake.Advance(2 * probeRecheckInterval)
+	}
+	if dials != 1 {
+		t.Errorf("Expected one greeting for the same process, got %d", dials)
+	}
+
+	// A restarted server is asked again
+	vp.Probe(context.Background(), mysql(1700000500))
+	if dials != 2 {
+		t.Errorf("Expected the restarted server to be probed, got %d dials", dials)
+	}
+}
+
+func TestVersionProber_RechecksFailures(t *testing.T) {
+	var dials int32
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	vp := NewVersionProber(zap.NewNop(), VersionProbeConfig{})
+	vp.clock = fake
+	vp.dial = pipeDial(func(conn net.Conn) {
+		bufio.NewReader(conn).ReadString('\n')
+		conn.Write([]byte("-NOAUTH Authentication required.\r\n"))
+	}, &dials)
+
+	services := []ServiceInfo{{
+		Type:        "redis",
+		Endpoints:   []Endpoint{{Address: "127.0.0.1", Port: 6379, Protocol: "tcp"}},
+		ProcessInfo: &process.ProcessInfo{PID: 42, CreateTime: 1700000000},
+	}}
+	vp.Probe(context.Background(), services)
+	vp.Probe(context.Background(), services)
+	if dials != 1 {
+		t.Errorf("Expected a failed probe to be remembered, got %d dials", dials)
+	}
+	if services[0].Version != "" || services[0].VersionProbe() != nil {
+		t.Errorf("Expected no version from a refused probe, got %q", services[0].Version)
+	}
+
+	fake.Advance(probeRecheckInterval)
+	vp.Probe(context.Background(), services)
+	if dials != 2 {
+		t.Errorf("Expected a failed probe to be retried after %v, got %d dials", probeRecheckInterval, dials)
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/newrelic/nrdot-host/nrdot-migration
+
+go 1.21
+
+require (
+	go.uber.org/zap v1.27.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require go.uber.org/multierr v1.10.0 // indirect
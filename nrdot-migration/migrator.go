@@ -1,14 +1,12 @@
 package migration
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
 
@@ -38,17 +36,17 @@ func NewInfrastructureMigrator(logger *zap.Logger, dryRun, preserveOriginal bool
 
 // MigrationReport contains the migration results
 type MigrationReport struct {
-	Success            bool                     `json:"success"`
-	InfraAgentFound    bool                     `json:"infra_agent_found"`
-	ConfigMigrated     bool                     `json:"config_migrated"`
-	ServicesStopped    bool                     `json:"services_stopped"`
-	DataPreserved      bool                     `json:"data_preserved"`
-	Errors             []string                 `json:"errors,omitempty"`
-	Warnings           []string                 `json:"warnings,omitempty"`
-	MigratedConfig     map[string]interface{}   `json:"migrated_config,omitempty"`
-	CustomIntegrations []string                 `json:"custom_integrations,omitempty"`
-	StartTime          time.Time                `json:"start_time"`
-	EndTime            time.Time                `json:"end_time"`
+	Success            bool                     `json:"success" yaml:"success"`
+	InfraAgentFound    bool                     `json:"infra_agent_found" yaml:"infra_agent_found"`
+	ConfigMigrated     bool                     `json:"config_migrated" yaml:"config_migrated"`
+	ServicesStopped    bool                     `json:"services_stopped" yaml:"services_stopped"`
+	DataPreserved      bool                     `json:"data_preserved" yaml:"data_preserved"`
+	Errors             []string                 `json:"errors,omitempty" yaml:"errors,omitempty"`
+	Warnings           []string                 `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+	MigratedConfig     map[string]interface{}   `json:"migrated_config,omitempty" yaml:"migrated_config,omitempty"`
+	CustomIntegrations []string                 `json:"custom_integrations,omitempty" yaml:"custom_integrations,omitempty"`
+	StartTime          time.Time                `json:"start_time" yaml:"start_time"`
+	EndTime            time.Time                `json:"end_time" yaml:"end_time"`
 }
 
 // Migrate performs the migration from Infrastructure Agent to NRDOT-HOST
@@ -108,7 +106,7 @@ func (im *InfrastructureMigrator) Migrate(ctx context.Context) (*MigrationReport
 	}
 
 	// Step 6: Preserve data if requested
-	if im.preserveOriginal {
+	if im.preserveOriginal && !im.dryRun {
 		if err := im.preserveInfraData(); err != nil {
 			report.Warnings = append(report.Warnings, fmt.Sprintf("Failed to preserve data: %v", err))
 		} else {
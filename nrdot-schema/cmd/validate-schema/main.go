@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,12 +11,30 @@ import (
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s <config-file>\n", os.Args[0])
+	format := flag.String("format", "text", "Output format: text validates a config file, jsonschema prints the config JSON Schema")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [--format text] <config-file>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s --format jsonschema > nrdot-config.schema.json\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	switch *format {
+	case "jsonschema":
+		os.Stdout.Write(schema.JSONSchema())
+		return
+	case "text":
+	default:
+		fmt.Fprintf(os.Stderr, "Unsupported format: %s\n", *format)
+		os.Exit(1)
+	}
+
+	if flag.NArg() < 1 {
+		flag.Usage()
 		os.Exit(1)
 	}
 
-	configFile := os.Args[1]
+	configFile := flag.Arg(0)
 	
 	// Read the file
 	data, err := os.ReadFile(configFile)
@@ -56,4 +75,4 @@ func main() {
 	// Pretty print the configuration
 	jsonData, _ := json.MarshalIndent(config, "", "  ")
 	fmt.Printf("Parsed configuration:\n%s\n", jsonData)
-}
\ No newline at end of file
+}
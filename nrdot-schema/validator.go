@@ -5,7 +5,11 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/xeipuuv/gojsonschema"
 	"gopkg.in/yaml.v3"
@@ -25,6 +29,12 @@ type Config struct {
 	Security   SecurityConfig   `yaml:"security,omitempty" json:"security,omitempty"`
 	Processing ProcessingConfig `yaml:"processing,omitempty" json:"processing,omitempty"`
 	Export     ExportConfig     `yaml:"export,omitempty" json:"export,omitempty"`
+	Proxy      ProxyConfig      `yaml:"proxy,omitempty" json:"proxy,omitempty"`
+	Deployment DeploymentConfig `yaml:"deployment,omitempty" json:"deployment,omitempty"`
+	Telemetry  TelemetryConfig  `yaml:"telemetry,omitempty" json:"telemetry,omitempty"`
+	Checks     []CheckConfig    `yaml:"checks,omitempty" json:"checks,omitempty"`
+	AutoConfig AutoConfigConfig `yaml:"auto_config,omitempty" json:"auto_config,omitempty"`
+	Kubernetes KubernetesConfig `yaml:"kubernetes,omitempty" json:"kubernetes,omitempty"`
 	Logging    LoggingConfig    `yaml:"logging,omitempty" json:"logging,omitempty"`
 }
 
@@ -75,6 +85,19 @@ type SecurityConfig struct {
 type ProcessingConfig struct {
 	CardinalityLimit int              `yaml:"cardinality_limit,omitempty" json:"cardinality_limit,omitempty"`
 	Enrichment       EnrichmentConfig `yaml:"enrichment,omitempty" json:"enrichment,omitempty"`
+	Tuning           TuningConfig     `yaml:"tuning,omitempty" json:"tuning,omitempty"`
+}
+
+// TuningConfig overrides the memory limiter, batch and queue sizes, which
+// are otherwise computed from the host's resources. Zero values are
+// computed.
+type TuningConfig struct {
+	MemoryLimitMiB int `yaml:"memory_limit_mib,omitempty" json:"memory_limit_mib,omitempty"`
+	SpikeLimitMiB  int `yaml:"spike_limit_mib,omitempty" json:"spike_limit_mib,omitempty"`
+	BatchSize      int `yaml:"batch_size,omitempty" json:"batch_size,omitempty"`
+	BatchMaxSize   int `yaml:"batch_max_size,omitempty" json:"batch_max_size,omitempty"`
+	QueueSize      int `yaml:"queue_size,omitempty" json:"queue_size,omitempty"`
+	NumConsumers   int `yaml:"num_consumers,omitempty" json:"num_consumers,omitempty"`
 }
 
 // EnrichmentConfig defines enrichment settings
@@ -86,11 +109,27 @@ type EnrichmentConfig struct {
 
 // ExportConfig defines export settings
 type ExportConfig struct {
-	Endpoint    string       `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
-	Region      string       `yaml:"region,omitempty" json:"region,omitempty"`
-	Compression string       `yaml:"compression,omitempty" json:"compression,omitempty"`
-	Timeout     string       `yaml:"timeout,omitempty" json:"timeout,omitempty"`
-	Retry       RetryConfig  `yaml:"retry,omitempty" json:"retry,omitempty"`
+	Endpoint    string               `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	Region      string               `yaml:"region,omitempty" json:"region,omitempty"`
+	Compression string               `yaml:"compression,omitempty" json:"compression,omitempty"`
+	Timeout     string               `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Retry       RetryConfig          `yaml:"retry,omitempty" json:"retry,omitempty"`
+	Additional  []AdditionalExporter `yaml:"additional,omitempty" json:"additional,omitempty"`
+}
+
+// AdditionalExporter defines an exporter telemetry is sent to in addition
+// to New Relic, such as a second OTLP backend or a file for debugging.
+// Which other fields apply depends on the type.
+type AdditionalExporter struct {
+	Name     string            `yaml:"name" json:"name"`
+	Type     string            `yaml:"type" json:"type"` // otlp, otlphttp, prometheusremotewrite, file, kafka
+	Signals  []string          `yaml:"signals,omitempty" json:"signals,omitempty"`
+	Endpoint string            `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Insecure bool              `yaml:"insecure,omitempty" json:"insecure,omitempty"`
+	Path     string            `yaml:"path,omitempty" json:"path,omitempty"`
+	Brokers  []string          `yaml:"brokers,omitempty" json:"brokers,omitempty"`
+	Topic    string            `yaml:"topic,omitempty" json:"topic,omitempty"`
 }
 
 // RetryConfig defines retry settings
@@ -100,6 +139,110 @@ type RetryConfig struct {
 	Backoff     string `yaml:"backoff,omitempty" json:"backoff,omitempty"`
 }
 
+// ProxyConfig defines the proxy for outbound connections
+type ProxyConfig struct {
+	HTTPProxy  string `yaml:"http_proxy,omitempty" json:"http_proxy,omitempty"`
+	HTTPSProxy string `yaml:"https_proxy,omitempty" json:"https_proxy,omitempty"`
+	NoProxy    string `yaml:"no_proxy,omitempty" json:"no_proxy,omitempty"`
+}
+
+// DeploymentConfig places the collector in a two-tier topology: agents
+// collect from their hosts and forward to gateways, which receive from
+// agents over OTLP and export to New Relic
+type DeploymentConfig struct {
+	Mode          string                `yaml:"mode,omitempty" json:"mode,omitempty"` // agent, gateway
+	Receiver      GatewayReceiverConfig `yaml:"receiver,omitempty" json:"receiver,omitempty"`
+	LoadBalancing LoadBalancingConfig   `yaml:"load_balancing,omitempty" json:"load_balancing,omitempty"`
+}
+
+// GatewayReceiverConfig configures the OTLP receiver of a gateway
+type GatewayReceiverConfig struct {
+	GRPCEndpoint string     `yaml:"grpc_endpoint,omitempty" json:"grpc_endpoint,omitempty"`
+	HTTPEndpoint string     `yaml:"http_endpoint,omitempty" json:"http_endpoint,omitempty"`
+	TLS          *TLSConfig `yaml:"tls,omitempty" json:"tls,omitempty"`
+	AuthToken    string     `yaml:"auth_token,omitempty" json:"auth_token,omitempty"`
+}
+
+// TLSConfig configures the TLS of a server
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file" json:"cert_file"`
+	KeyFile      string `yaml:"key_file" json:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file,omitempty" json:"client_ca_file,omitempty"`
+}
+
+// LoadBalancingConfig forwards telemetry to a pool of collectors instead of
+// New Relic, keeping each trace or service on one of them
+type LoadBalancingConfig struct {
+	Hostnames  []string `yaml:"hostnames,omitempty" json:"hostnames,omitempty"`
+	RoutingKey string   `yaml:"routing_key,omitempty" json:"routing_key,omitempty"` // traceID, service
+	Insecure   bool     `yaml:"insecure,omitempty" json:"insecure,omitempty"`
+	CAFile     string   `yaml:"ca_file,omitempty" json:"ca_file,omitempty"`
+	AuthToken  string   `yaml:"auth_token,omitempty" json:"auth_token,omitempty"`
+}
+
+// TelemetryConfig defines the collector's own telemetry
+type TelemetryConfig struct {
+	InternalMetrics InternalMetricsConfig `yaml:"internal_metrics,omitempty" json:"internal_metrics,omitempty"`
+}
+
+// InternalMetricsConfig defines the metrics the collector exposes about
+// itself on a Prometheus endpoint, which the metrics pipeline scrapes
+type InternalMetricsConfig struct {
+	Level     string `yaml:"level,omitempty" json:"level,omitempty"` // none, basic, normal, detailed
+	Address   string `yaml:"address,omitempty" json:"address,omitempty"`
+	Exemplars bool   `yaml:"exemplars,omitempty" json:"exemplars,omitempty"`
+}
+
+// CheckConfig defines a local synthetic check
+type CheckConfig struct {
+	Name           string            `yaml:"name" json:"name"`
+	Type           string            `yaml:"type" json:"type"`
+	Target         string            `yaml:"target,omitempty" json:"target,omitempty"`
+	Interval       string            `yaml:"interval,omitempty" json:"interval,omitempty"`
+	Timeout        string            `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Method         string            `yaml:"method,omitempty" json:"method,omitempty"`
+	ExpectedStatus []int             `yaml:"expected_status,omitempty" json:"expected_status,omitempty"`
+	MaxUsedPercent float64           `yaml:"max_used_percent,omitempty" json:"max_used_percent,omitempty"`
+	Command        string            `yaml:"command,omitempty" json:"command,omitempty"`
+	Args           []string          `yaml:"args,omitempty" json:"args,omitempty"`
+	Attributes     map[string]string `yaml:"attributes,omitempty" json:"attributes,omitempty"`
+}
+
+// AutoConfigConfig defines the discovery of the host's services and the
+// generation of their receivers
+type AutoConfigConfig struct {
+	Enabled          bool                `yaml:"enabled" json:"enabled"`
+	ScanInterval     string              `yaml:"scan_interval,omitempty" json:"scan_interval,omitempty"`
+	AddAfterScans    int                 `yaml:"add_after_scans,omitempty" json:"add_after_scans,omitempty"`
+	RemoveAfterScans int                 `yaml:"remove_after_scans,omitempty" json:"remove_after_scans,omitempty"`
+	LANDiscovery     LANDiscoveryConfig  `yaml:"lan_discovery,omitempty" json:"lan_discovery,omitempty"`
+	VersionProbes    VersionProbesConfig `yaml:"version_probes,omitempty" json:"version_probes,omitempty"`
+}
+
+// LANDiscoveryConfig defines the discovery of devices announced on the
+// local network over mDNS and SSDP
+type LANDiscoveryConfig struct {
+	Enabled      bool     `yaml:"enabled" json:"enabled"`
+	Timeout      string   `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	ServiceTypes []string `yaml:"service_types,omitempty" json:"service_types,omitempty"`
+	SSDP         bool     `yaml:"ssdp,omitempty" json:"ssdp,omitempty"`
+}
+
+// VersionProbesConfig defines the probes asking discovered services for
+// their versions
+type VersionProbesConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// KubernetesConfig defines the discovery of the workloads of the node's
+// pods through the kubelet API
+type KubernetesConfig struct {
+	Enabled            bool   `yaml:"enabled" json:"enabled"`
+	KubeletEndpoint    string `yaml:"kubelet_endpoint,omitempty" json:"kubelet_endpoint,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty" json:"insecure_skip_verify,omitempty"`
+}
+
 // LoggingConfig defines logging settings
 type LoggingConfig struct {
 	Level  string `yaml:"level,omitempty" json:"level,omitempty"`
@@ -156,6 +299,21 @@ func (v *Validator) ValidateYAML(yamlData []byte) (*Config, error) {
 	if err := yaml.Unmarshal(yamlData, config); err != nil {
 		return nil, fmt.Errorf("failed to parse configuration: %w", err)
 	}
+	if err := validateTuning(config.Processing.Tuning); err != nil {
+		return nil, err
+	}
+	if err := validateAdditionalExporters(config.Export.Additional); err != nil {
+		return nil, err
+	}
+	if err := validateDeployment(config.Deployment); err != nil {
+		return nil, err
+	}
+	if err := validateTelemetry(config.Telemetry); err != nil {
+		return nil, err
+	}
+	if err := validateAutoConfig(config.AutoConfig, config.Kubernetes); err != nil {
+		return nil, err
+	}
 
 	// Apply defaults
 	v.applyDefaults(config)
@@ -179,6 +337,21 @@ func (v *Validator) ValidateJSON(jsonData []byte) (*Config, error) {
 	if err := json.Unmarshal(jsonData, config); err != nil {
 		return nil, fmt.Errorf("failed to parse configuration: %w", err)
 	}
+	if err := validateTuning(config.Processing.Tuning); err != nil {
+		return nil, err
+	}
+	if err := validateAdditionalExporters(config.Export.Additional); err != nil {
+		return nil, err
+	}
+	if err := validateDeployment(config.Deployment); err != nil {
+		return nil, err
+	}
+	if err := validateTelemetry(config.Telemetry); err != nil {
+		return nil, err
+	}
+	if err := validateAutoConfig(config.AutoConfig, config.Kubernetes); err != nil {
+		return nil, err
+	}
 
 	v.applyDefaults(config)
 
@@ -198,6 +371,83 @@ func (v *Validator) formatValidationErrors(errors []gojsonschema.ResultError) er
 	return fmt.Errorf("configuration validation failed:\n%s", strings.Join(messages, "\n"))
 }
 
+// validateTuning checks the tuning overrides against each other, which the
+// schema cannot express
+func validateTuning(tuning TuningConfig) error {
+	if tuning.MemoryLimitMiB > 0 && tuning.SpikeLimitMiB >= tuning.MemoryLimitMiB {
+		return fmt.Errorf("configuration validation failed:\n- processing.tuning.spike_limit_mib: must be less than memory_limit_mib")
+	}
+	if tuning.BatchSize > 0 && tuning.BatchMaxSize > 0 && tuning.BatchMaxSize < tuning.BatchSize {
+		return fmt.Errorf("configuration validation failed:\n- processing.tuning.batch_max_size: must be at least batch_size")
+	}
+	return nil
+}
+
+// validateAdditionalExporters checks that additional exporter names are
+// unique by type, which the schema cannot express
+func validateAdditionalExporters(exporters []AdditionalExporter) error {
+	seen := make(map[string]bool)
+	for i, exporter := range exporters {
+		id := exporter.Type + "/" + exporter.Name
+		if seen[id] {
+			return fmt.Errorf("configuration validation failed:\n- export.additional.%d.name: %s exporter %q is defined twice", i, exporter.Type, exporter.Name)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// validateDeployment checks that the receiver is only configured in gateway
+// mode, which the schema cannot express
+func validateDeployment(deployment DeploymentConfig) error {
+	receiver := deployment.Receiver
+	if deployment.Mode != "gateway" && (receiver.GRPCEndpoint != "" || receiver.HTTPEndpoint != "" ||
+		receiver.TLS != nil || receiver.AuthToken != "") {
+		return fmt.Errorf("configuration validation failed:\n- deployment.receiver: only used in gateway mode")
+	}
+	return nil
+}
+
+// validateTelemetry checks the internal metrics address is a host:port and
+// exemplars are only requested with metrics to scrape, which the schema
+// cannot express
+func validateTelemetry(telemetry TelemetryConfig) error {
+	metrics := telemetry.InternalMetrics
+	if metrics.Address != "" {
+		_, port, err := net.SplitHostPort(metrics.Address)
+		if n, perr := strconv.Atoi(port); err != nil || perr != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("configuration validation failed:\n- telemetry.internal_metrics.address: %q is not a host:port", metrics.Address)
+		}
+	}
+	if metrics.Level == "none" && metrics.Exemplars {
+		return fmt.Errorf("configuration validation failed:\n- telemetry.internal_metrics.exemplars: no internal metrics to scrape with level none")
+	}
+	return nil
+}
+
+// validateAutoConfig checks the durations are positive and the kubelet
+// endpoint is a URL with a host, which the schema cannot express
+func validateAutoConfig(autoConfig AutoConfigConfig, kubernetes KubernetesConfig) error {
+	for field, value := range map[string]string{
+		"auto_config.scan_interval":          autoConfig.ScanInterval,
+		"auto_config.lan_discovery.timeout":  autoConfig.LANDiscovery.Timeout,
+		"auto_config.version_probes.timeout": autoConfig.VersionProbes.Timeout,
+	} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("configuration validation failed:\n- %s: %q is not a positive duration", field, value)
+		}
+	}
+	if endpoint := kubernetes.KubeletEndpoint; endpoint != "" {
+		if u, err := url.Parse(endpoint); err != nil || u.Host == "" {
+			return fmt.Errorf("configuration validation failed:\n- kubernetes.kubelet_endpoint: %q is not a URL", endpoint)
+		}
+	}
+	return nil
+}
+
 // applyDefaults applies default values to the configuration
 func (v *Validator) applyDefaults(config *Config) {
 	// Service defaults
@@ -247,6 +497,22 @@ func (v *Validator) applyDefaults(config *Config) {
 		config.Export.Retry.Backoff = "5s"
 	}
 
+	// Deployment defaults
+	if config.Deployment.Mode == "" {
+		config.Deployment.Mode = "agent"
+	}
+
+	// Auto-configuration defaults
+	if config.AutoConfig.ScanInterval == "" {
+		config.AutoConfig.ScanInterval = "5m"
+	}
+	if config.AutoConfig.AddAfterScans == 0 {
+		config.AutoConfig.AddAfterScans = 2
+	}
+	if config.AutoConfig.RemoveAfterScans == 0 {
+		config.AutoConfig.RemoveAfterScans = 3
+	}
+
 	// Logging defaults
 	if config.Logging.Level == "" {
 		config.Logging.Level = "info"
@@ -259,4 +525,11 @@ func (v *Validator) applyDefaults(config *Config) {
 // GetSchema returns the raw JSON schema
 func GetSchema() string {
 	return nrdotConfigSchema
-}
\ No newline at end of file
+}
+
+// JSONSchema returns the canonical config schema as a JSON Schema
+// (draft-07) document. Editors and CI pipelines can use it to autocomplete
+// and validate /etc/nrdot/config.yaml.
+func JSONSchema() []byte {
+	return []byte(strings.TrimSpace(nrdotConfigSchema) + "\n")
+}
@@ -1,10 +1,12 @@
 package schema
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/xeipuuv/gojsonschema"
 )
 
 func TestValidator(t *testing.T) {
@@ -230,4 +232,342 @@ service:
 	assert.Equal(t, "5s", config.Export.Retry.Backoff)
 	assert.Equal(t, "info", config.Logging.Level)
 	assert.Equal(t, "text", config.Logging.Format)
-}
\ No newline at end of file
+}
+func TestValidateChecks(t *testing.T) {
+	validator, err := NewValidator()
+	require.NoError(t, err)
+
+	config, err := validator.ValidateYAML([]byte(`
+service:
+  name: web-01
+checks:
+  - name: api-health
+    type: http
+    target: http://localhost:8080/health
+    expected_status: [200, 204]
+  - name: root-disk
+    type: disk
+    target: /
+    max_used_percent: 85
+`))
+	require.NoError(t, err)
+	require.Len(t, config.Checks, 2)
+	assert.Equal(t, []int{200, 204}, config.Checks[0].ExpectedStatus)
+	assert.Equal(t, 85.0, config.Checks[1].MaxUsedPercent)
+
+	_, err = validator.ValidateYAML([]byte(`
+service:
+  name: web-01
+checks:
+  - name: ping
+    type: icmp
+`))
+	assert.Error(t, err)
+
+	_, err = validator.ValidateYAML([]byte(`
+service:
+  name: web-01
+checks:
+  - type: tcp
+    target: localhost:22
+`))
+	assert.Error(t, err)
+}
+
+func TestValidateProxy(t *testing.T) {
+	validator, err := NewValidator()
+	require.NoError(t, err)
+
+	config, err := validator.ValidateYAML([]byte(`
+service:
+  name: web-01
+proxy:
+  https_proxy: http://proxy.corp:3128
+  no_proxy: localhost,.internal,10.0.0.0/8
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "http://proxy.corp:3128", config.Proxy.HTTPSProxy)
+	assert.Equal(t, "localhost,.internal,10.0.0.0/8", config.Proxy.NoProxy)
+
+	_, err = validator.ValidateYAML([]byte(`
+service:
+  name: web-01
+proxy:
+  https_proxy: proxy.corp:3128
+`))
+	assert.Error(t, err)
+}
+
+func TestValidateTuning(t *testing.T) {
+	validator, err := NewValidator()
+	require.NoError(t, err)
+
+	config, err := validator.ValidateYAML([]byte(`
+service:
+  name: web-01
+processing:
+  tuning:
+    memory_limit_mib: 400
+    batch_size: 2000
+    queue_size: 500
+`))
+	require.NoError(t, err)
+	assert.Equal(t, TuningConfig{MemoryLimitMiB: 400, BatchSize: 2000, QueueSize: 500}, config.Processing.Tuning)
+
+	for name, tuning := range map[string]string{
+		"limit below minimum":  "{memory_limit_mib: 16}",
+		"spike above limit":    "{memory_limit_mib: 400, spike_limit_mib: 400}",
+		"max below batch size": "{batch_size: 2000, batch_max_size: 1000}",
+		"unknown setting":      "{queue_bytes: 1000}",
+	} {
+		_, err := validator.ValidateYAML([]byte(`
+service:
+  name: web-01
+processing:
+  tuning: ` + tuning + `
+`))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestValidateAdditionalExporters(t *testing.T) {
+	validator, err := NewValidator()
+	require.NoError(t, err)
+
+	config, err := validator.ValidateYAML([]byte(`
+service:
+  name: web-01
+export:
+  additional:
+    - name: backup
+      type: otlp
+      endpoint: collector.internal:4317
+      insecure: true
+    - name: thanos
+      type: prometheusremotewrite
+      endpoint: https://thanos.internal/api/v1/receive
+      signals: [metrics]
+    - name: debug
+      type: file
+      path: /var/log/nrdot/telemetry.json
+    - name: events
+      type: kafka
+      brokers: [kafka-1:9092]
+      topic: nrdot
+      signals: [logs]
+`))
+	require.NoError(t, err)
+	require.Len(t, config.Export.Additional, 4)
+	assert.Equal(t, AdditionalExporter{
+		Name:     "backup",
+		Type:     "otlp",
+		Endpoint: "collector.internal:4317",
+		Insecure: true,
+	}, config.Export.Additional[0])
+	assert.Equal(t, []string{"kafka-1:9092"}, config.Export.Additional[3].Brokers)
+
+	for name, exporter := range map[string]string{
+		"unknown type":        "{name: x, type: zipkin, endpoint: http://zipkin:9411}",
+		"missing endpoint":    "{name: x, type: otlphttp}",
+		"missing path":        "{name: x, type: file}",
+		"missing brokers":     "{name: x, type: kafka}",
+		"remote write traces": "{name: x, type: prometheusremotewrite, endpoint: https://prw, signals: [traces]}",
+		"unknown signal":      "{name: x, type: file, path: /tmp/out.json, signals: [profiles]}",
+		"invalid name":        "{name: My Backup, type: file, path: /tmp/out.json}",
+		"unknown setting":     "{name: x, type: file, path: /tmp/out.json, rotate: true}",
+		"duplicate name":      "{name: x, type: file, path: /tmp/a.json}\n    - {name: x, type: file, path: /tmp/b.json}",
+	} {
+		_, err := validator.ValidateYAML([]byte(`
+service:
+  name: web-01
+export:
+  additional:
+    - ` + exporter + `
+`))
+		assert.Error(t, err, name)
+	}
+
+	// Names only need to be unique by type
+	_, err = validator.ValidateYAML([]byte(`
+service:
+  name: web-01
+export:
+  additional:
+    - {name: backup, type: otlp, endpoint: collector.internal:4317}
+    - {name: backup, type: file, path: /tmp/backup.json}
+`))
+	assert.NoError(t, err)
+}
+
+func TestValidateDeployment(t *testing.T) {
+	validator, err := NewValidator()
+	require.NoError(t, err)
+
+	config, err := validator.ValidateYAML([]byte("service:\n  name: web-01\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "agent", config.Deployment.Mode)
+
+	config, err = validator.ValidateYAML([]byte(`
+service:
+  name: gateway-01
+deployment:
+  mode: gateway
+  receiver:
+    grpc_endpoint: 0.0.0.0:14317
+    tls:
+      cert_file: /etc/nrdot/tls/gateway.crt
+      key_file: /etc/nrdot/tls/gateway.key
+      client_ca_file: /etc/nrdot/tls/agents-ca.crt
+    auth_token: s3cret
+`))
+	require.NoError(t, err)
+	assert.Equal(t, GatewayReceiverConfig{
+		GRPCEndpoint: "0.0.0.0:14317",
+		TLS: &TLSConfig{
+			CertFile:     "/etc/nrdot/tls/gateway.crt",
+			KeyFile:      "/etc/nrdot/tls/gateway.key",
+			ClientCAFile: "/etc/nrdot/tls/agents-ca.crt",
+		},
+		AuthToken: "s3cret",
+	}, config.Deployment.Receiver)
+
+	config, err = validator.ValidateYAML([]byte(`
+service:
+  name: web-01
+deployment:
+  load_balancing:
+    hostnames: [gateway-1:4317, gateway-2:4317]
+    routing_key: service
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gateway-1:4317", "gateway-2:4317"}, config.Deployment.LoadBalancing.Hostnames)
+
+	for name, deployment := range map[string]string{
+		"unknown mode":            "mode: relay",
+		"receiver in agent mode":  "receiver: {auth_token: s3cret}",
+		"certificate without key": "mode: gateway\n  receiver: {tls: {cert_file: /etc/nrdot/tls/gateway.crt}}",
+		"no hostnames":            "load_balancing: {routing_key: traceID}",
+		"unknown routing key":     "load_balancing: {hostnames: [gateway-1:4317], routing_key: metric}",
+	} {
+		_, err := validator.ValidateYAML([]byte("service:\n  name: web-01\ndeployment:\n  " + deployment + "\n"))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestValidateTelemetry(t *testing.T) {
+	validator, err := NewValidator()
+	require.NoError(t, err)
+
+	config, err := validator.ValidateYAML([]byte(`
+service:
+  name: web-01
+telemetry:
+  internal_metrics:
+    level: normal
+    address: "[::1]:9888"
+    exemplars: true
+`))
+	require.NoError(t, err)
+	assert.Equal(t, InternalMetricsConfig{Level: "normal", Address: "[::1]:9888", Exemplars: true}, config.Telemetry.InternalMetrics)
+
+	for name, metrics := range map[string]string{
+		"unknown level":             "level: verbose",
+		"address without port":      "address: localhost",
+		"port out of range":         "address: \":70000\"",
+		"exemplars without metrics": "{level: none, exemplars: true}",
+	} {
+		_, err := validator.ValidateYAML([]byte("service:\n  name: web-01\ntelemetry:\n  internal_metrics:\n    " + metrics + "\n"))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestValidateAutoConfig(t *testing.T) {
+	validator, err := NewValidator()
+	require.NoError(t, err)
+
+	config, err := validator.ValidateYAML([]byte("service:\n  name: web-01\n"))
+	require.NoError(t, err)
+	assert.Equal(t, AutoConfigConfig{ScanInterval: "5m", AddAfterScans: 2, RemoveAfterScans: 3}, config.AutoConfig)
+	assert.False(t, config.Kubernetes.Enabled)
+
+	config, err = validator.ValidateYAML([]byte(`
+service:
+  name: node-01
+auto_config:
+  enabled: true
+  scan_interval: 2m
+  add_after_scans: 1
+  remove_after_scans: 5
+  lan_discovery:
+    enabled: true
+    timeout: 500ms
+    service_types: [_ipp._tcp, _smb._tcp]
+    ssdp: true
+  version_probes:
+    enabled: true
+    timeout: 1s
+kubernetes:
+  enabled: true
+  kubelet_endpoint: https://node-01:10250
+  insecure_skip_verify: true
+`))
+	require.NoError(t, err)
+	assert.Equal(t, AutoConfigConfig{
+		Enabled:          true,
+		ScanInterval:     "2m",
+		AddAfterScans:    1,
+		RemoveAfterScans: 5,
+		LANDiscovery: LANDiscoveryConfig{
+			Enabled:      true,
+			Timeout:      "500ms",
+			ServiceTypes: []string{"_ipp._tcp", "_smb._tcp"},
+			SSDP:         true,
+		},
+		VersionProbes: VersionProbesConfig{Enabled: true, Timeout: "1s"},
+	}, config.AutoConfig)
+	assert.Equal(t, KubernetesConfig{
+		Enabled:            true,
+		KubeletEndpoint:    "https://node-01:10250",
+		InsecureSkipVerify: true,
+	}, config.Kubernetes)
+
+	for name, section := range map[string]string{
+		"unknown setting":       "auto_config: {enabled: true, interval: 5m}",
+		"unitless interval":     "auto_config: {scan_interval: \"300\"}",
+		"zero interval":         "auto_config: {scan_interval: 0s}",
+		"zero add after scans":  "auto_config: {add_after_scans: 0}",
+		"unknown service type":  "auto_config: {lan_discovery: {service_types: [ipp]}}",
+		"zero probe timeout":    "auto_config: {version_probes: {timeout: 0ms}}",
+		"unknown kubernetes":    "kubernetes: {enabled: true, namespace: default}",
+		"endpoint without host": "kubernetes: {kubelet_endpoint: \"https://\"}",
+		"endpoint not a URL":    "kubernetes: {kubelet_endpoint: node-01:10250}",
+	} {
+		_, err := validator.ValidateYAML([]byte("service:\n  name: web-01\n" + section + "\n"))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	doc := JSONSchema()
+
+	var parsed map[string]interface{}
+	require.NoError(t, json.Unmarshal(doc, &parsed))
+	assert.Equal(t, "http://json-schema.org/draft-07/schema#", parsed["$schema"])
+	assert.Contains(t, parsed["properties"], "service")
+	assert.Equal(t, byte('\n'), doc[len(doc)-1])
+
+	// The exported document validates configs like the validator does
+	exported, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(doc))
+	require.NoError(t, err)
+	result, err := exported.Validate(gojsonschema.NewGoLoader(map[string]interface{}{
+		"service": map[string]interface{}{"name": "web-01"},
+	}))
+	require.NoError(t, err)
+	assert.True(t, result.Valid())
+
+	result, err = exported.Validate(gojsonschema.NewGoLoader(map[string]interface{}{
+		"service": map[string]interface{}{"name": "web-01", "environment": "prod"},
+	}))
+	require.NoError(t, err)
+	assert.False(t, result.Valid())
+}
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"runtime"
+	"strconv"
 
 	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
 	"go.uber.org/zap"
 )
 
@@ -21,7 +24,7 @@ func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
 	health, err := h.Supervisor.GetHealth(ctx)
 	if err != nil {
 		h.Logger.Error("Failed to get health", zap.Error(err))
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		problem.Error(w, r, "Internal error", http.StatusInternalServerError)
 		return
 	}
 
@@ -46,11 +49,25 @@ func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// Ready handles GET /ready
+// Ready handles GET /ready. The supervisor is ready once the collector's
+// pipelines are active and the collector is not Unhealthy; until then it
+// answers 503 with the startup phase reached.
 func (h *Handlers) Ready(w http.ResponseWriter, r *http.Request) {
-	// Simple ready check - if we can respond, we're ready
+	health, err := h.Supervisor.GetHealth(r.Context())
+	if err != nil {
+		h.Logger.Error("Failed to get health", zap.Error(err))
+		problem.Error(w, r, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"ready": true})
+	if !health.ReadinessProbe {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ready": health.ReadinessProbe,
+		"phase": h.Supervisor.Phase(),
+	})
 }
 
 // Status handles GET /v1/status
@@ -59,7 +76,7 @@ func (h *Handlers) Status(w http.ResponseWriter, r *http.Request) {
 	status, err := h.Supervisor.GetStatus(ctx)
 	if err != nil {
 		h.Logger.Error("Failed to get status", zap.Error(err))
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		problem.Error(w, r, "Internal error", http.StatusInternalServerError)
 		return
 	}
 
@@ -73,7 +90,7 @@ func (h *Handlers) GetConfig(w http.ResponseWriter, r *http.Request) {
 	config, err := h.Supervisor.GetCurrentConfig(ctx)
 	if err != nil {
 		h.Logger.Error("Failed to get config", zap.Error(err))
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		problem.Error(w, r, "Internal error", http.StatusInternalServerError)
 		return
 	}
 
@@ -86,7 +103,7 @@ func (h *Handlers) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 	// Decode the update request
 	var update models.ConfigUpdate
 	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Error(w, r, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
@@ -94,7 +111,7 @@ func (h *Handlers) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 	result, err := h.Supervisor.ApplyConfig(r.Context(), &update)
 	if err != nil {
 		h.Logger.Error("Failed to apply config", zap.Error(err))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -107,7 +124,7 @@ func (h *Handlers) ValidateConfig(w http.ResponseWriter, r *http.Request) {
 	// Use ConfigUpdate for validation
 	var update models.ConfigUpdate
 	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Error(w, r, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
@@ -118,7 +135,7 @@ func (h *Handlers) ValidateConfig(w http.ResponseWriter, r *http.Request) {
 	result, err := h.Supervisor.ApplyConfig(r.Context(), &update)
 	if err != nil {
 		h.Logger.Error("Config validation failed", zap.Error(err))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -132,7 +149,7 @@ func (h *Handlers) GetMetrics(w http.ResponseWriter, r *http.Request) {
 	status, err := h.Supervisor.GetStatus(ctx)
 	if err != nil {
 		h.Logger.Error("Failed to get metrics", zap.Error(err))
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		problem.Error(w, r, "Internal error", http.StatusInternalServerError)
 		return
 	}
 
@@ -156,12 +173,13 @@ func (h *Handlers) GetMetrics(w http.ResponseWriter, r *http.Request) {
 	writeMetric("nrdot_cpu_percent", "CPU usage percentage", "gauge", status.ResourceMetrics.CPUPercent)
 	writeMetric("nrdot_memory_bytes", "Memory usage in bytes", "gauge", status.ResourceMetrics.MemoryBytes)
 	writeMetric("nrdot_goroutine_count", "Number of goroutines", "gauge", status.ResourceMetrics.GoroutineCount)
+	writeMetric("nrdot_supervisor_goroutines", "Number of goroutines in the supervisor", "gauge", runtime.NumGoroutine())
 }
 
 // RestartCollector handles POST /v1/collector/restart
 func (h *Handlers) RestartCollector(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -169,7 +187,7 @@ func (h *Handlers) RestartCollector(w http.ResponseWriter, r *http.Request) {
 	err := h.Supervisor.RestartCollector(ctx, "API request")
 	if err != nil {
 		h.Logger.Error("Failed to restart collector", zap.Error(err))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err.Error(), controlErrorStatus(err))
 		return
 	}
 
@@ -183,7 +201,7 @@ func (h *Handlers) RestartCollector(w http.ResponseWriter, r *http.Request) {
 // ReloadCollector handles POST /v1/collector/reload
 func (h *Handlers) ReloadCollector(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		problem.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -191,7 +209,7 @@ func (h *Handlers) ReloadCollector(w http.ResponseWriter, r *http.Request) {
 	result, err := h.Supervisor.ReloadCollector(ctx, models.ReloadStrategyBlueGreen)
 	if err != nil {
 		h.Logger.Error("Failed to reload collector", zap.Error(err))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err.Error(), controlErrorStatus(err))
 		return
 	}
 
@@ -203,13 +221,19 @@ func (h *Handlers) ReloadCollector(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) GetVersionHistory(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	limit := 10 // Default limit
-	
-	// TODO: Parse limit from query params
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			problem.Error(w, r, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
 	
 	history, err := h.Supervisor.GetConfigHistory(ctx, limit)
 	if err != nil {
 		h.Logger.Error("Failed to get version history", zap.Error(err))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
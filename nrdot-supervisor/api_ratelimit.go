@@ -0,0 +1,60 @@
+package supervisor
+
+import (
+	"net/http"
+
+	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/middleware"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/auth"
+)
+
+// newRateLimit returns the API rate limiting middleware. Requests are
+// counted against the user or API key identify authenticates them as, and
+// against the client IP otherwise. With a write rate, mutating requests
+// are counted in separate buckets.
+func (s *UnifiedSupervisor) newRateLimit(identify func(*http.Request) (string, bool)) func(http.Handler) http.Handler {
+	logger := s.componentLogger("ratelimit", "ratelimit")
+	keyFunc := middleware.IdentityKeyFunc(identify, s.trustedProxies)
+
+	if s.config.RateLimitWriteRate <= 0 {
+		rateLimiter := middleware.NewRateLimiter(
+			s.config.RateLimitRate,
+			s.config.RateLimitInterval,
+			s.config.RateLimitBurst,
+			logger,
+		)
+		return rateLimiter.RateLimitMiddleware(keyFunc)
+	}
+
+	rateLimiter := middleware.NewTieredRateLimiter(map[string]middleware.RateTier{
+		middleware.TierRead: {
+			Rate:       s.config.RateLimitRate,
+			Interval:   s.config.RateLimitInterval,
+			BucketSize: s.config.RateLimitBurst,
+		},
+		middleware.TierWrite: {
+			Rate:       s.config.RateLimitWriteRate,
+			Interval:   s.config.RateLimitInterval,
+			BucketSize: s.config.RateLimitWriteBurst,
+		},
+	}, middleware.MethodTierFunc, logger)
+	return rateLimiter.RateLimitMiddleware(keyFunc)
+}
+
+// credentialIdentity returns a function telling the API key or user the
+// credentials of a request authenticate as. The rate limit runs before
+// authentication, so that failed attempts count against the client.
+func credentialIdentity(config auth.Config, jwtManager *auth.JWTManager, keyStore *auth.KeyStore) func(*http.Request) (string, bool) {
+	return func(r *http.Request) (string, bool) {
+		claims, key, err := authenticateRequest(r, config, jwtManager, keyStore)
+		if err != nil {
+			return "", false
+		}
+		if key != nil {
+			return "key:" + key.ID, true
+		}
+		if claims.Subject != "" {
+			return "user:" + claims.Subject, true
+		}
+		return "", false
+	}
+}
@@ -0,0 +1,123 @@
+package supervisor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/auth"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestAPIRateLimit_PerIdentity(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		APIEnabled:         true,
+		RateLimitEnabled:   true,
+		RateLimitRate:      3,
+		RateLimitInterval:  time.Minute,
+		RateLimitWriteRate: 1,
+		Logger:             zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.Enabled = true
+	authConfig.Type = auth.AuthTypeJWT
+	authConfig.JWT.SecretKey = "test-secret"
+	if err := s.SetupAuthenticatedAPIServer(authConfig); err != nil {
+		t.Fatalf("Failed to set up authentication: %v", err)
+	}
+	handler := s.apiServer.Handler
+
+	do := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.RemoteAddr = "10.0.0.1:1234"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	login := func(username, password string) string {
+		rec := do("POST", "/v1/auth/login", "", LoginRequest{Username: username, Password: password})
+		var resp LoginResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to log in as %s: %v", username, err)
+		}
+		return resp.Token
+	}
+
+	// Both logins are writes from the same unauthenticated IP
+	operator := login("operator", "operator123")
+	if rec := do("POST", "/v1/auth/login", "", LoginRequest{Username: "viewer", Password: "viewer123"}); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected the second login from the IP to be limited, got %d", rec.Code)
+	}
+
+	// The operator has its own read and write budgets
+	for i := 0; i < 3; i++ {
+		if rec := do("GET", "/v1/status", operator, nil); rec.Code != http.StatusOK {
+			t.Fatalf("Expected read %d to pass, got %d", i+1, rec.Code)
+		}
+	}
+	rec := do("GET", "/v1/status", operator, nil)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected a limited read with Retry-After, got %d", rec.Code)
+	}
+	rec = do("POST", "/v1/config/validate", operator, map[string]string{})
+	if rec.Code == http.StatusTooManyRequests {
+		t.Errorf("Expected the write budget to be separate from reads")
+	}
+	if got := rec.Header().Get("X-RateLimit-Limit"); got != "1" {
+		t.Errorf("Expected the write limit in X-RateLimit-Limit, got %q", got)
+	}
+}
+
+func TestAPIRateLimit_FailedAuthentication(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		APIEnabled:        true,
+		RateLimitEnabled:  true,
+		RateLimitRate:     2,
+		RateLimitInterval: time.Minute,
+		Logger:            zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.Enabled = true
+	authConfig.Type = auth.AuthTypeJWT
+	authConfig.JWT.SecretKey = "test-secret"
+	if err := s.SetupAuthenticatedAPIServer(authConfig); err != nil {
+		t.Fatalf("Failed to set up authentication: %v", err)
+	}
+	handler := s.apiServer.Handler
+
+	// Guessed tokens from one address, each claiming another client
+	codes := make([]int, 0, 3)
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/v1/status", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Authorization", "Bearer guessed")
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("192.0.2.%d", i+1))
+		req.Header.Set("X-Real-IP", fmt.Sprintf("198.51.100.%d", i+1))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+	}
+
+	want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, codes)
+		}
+	}
+}
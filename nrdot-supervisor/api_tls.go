@@ -0,0 +1,121 @@
+package supervisor
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/tlsconfig"
+	"go.uber.org/zap"
+)
+
+// apiTLSDirName keeps the self-signed API certificate under WorkDir
+const apiTLSDirName = "tls"
+
+// apiTLSConfig returns the API TLS configuration with defaults applied: a
+// self-signed certificate is kept under WorkDir and issued for localhost
+// and the listen host
+func (s *UnifiedSupervisor) apiTLSConfig() tlsconfig.Config {
+	config := s.config.APITLS
+	if config.SelfSigned && config.SelfSignedDir == "" && s.config.WorkDir != "" {
+		config.SelfSignedDir = filepath.Join(s.config.WorkDir, apiTLSDirName)
+	}
+	if config.SelfSigned && len(config.Hosts) == 0 {
+		config.Hosts = apiCertificateHosts(s.config.APIListenAddr)
+	}
+	return config
+}
+
+// configureAPITLS sets up TLS on the API server when configured. Like the
+// standalone API server, it refuses to listen beyond localhost unless
+// remote clients are authenticated over TLS.
+func (s *UnifiedSupervisor) configureAPITLS() error {
+	config := s.apiTLSConfig()
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if !s.apiRemoteAllowed() && !isLoopbackAddr(s.config.APIListenAddr) {
+		return fmt.Errorf("API must listen on localhost only without TLS and client certificates or authentication, got %s", s.config.APIListenAddr)
+	}
+	if !config.Enabled() {
+		return nil
+	}
+
+	tlsConfig, err := config.ServerConfig()
+	if err != nil {
+		return err
+	}
+	s.apiServer.TLSConfig = tlsConfig
+
+	s.logger.Info("API server TLS enabled",
+		zap.Bool("self_signed", config.SelfSigned),
+		zap.Bool("client_certs", config.ClientCAFile != ""),
+		zap.Bool("client_certs_required", config.MutualTLS()),
+		zap.String("certificate_sha256", tlsconfig.Fingerprint(tlsConfig)))
+	return nil
+}
+
+// apiRemoteAllowed reports whether clients beyond localhost may connect:
+// only over TLS, and only when they must present a client certificate or
+// authenticate
+func (s *UnifiedSupervisor) apiRemoteAllowed() bool {
+	config := s.apiTLSConfig()
+	return config.Enabled() && (config.MutualTLS() || s.apiAuthEnabled)
+}
+
+// apiCertificateHosts returns the names a self-signed API certificate is
+// issued for: localhost, plus the listen host and the machine's hostname
+// when the API listens beyond it
+func apiCertificateHosts(addr string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if isLoopbackAddr(addr) {
+		return hosts
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if ip := net.ParseIP(host); host != "" && (ip == nil || !ip.IsUnspecified()) {
+			hosts = append(hosts, host)
+		}
+	}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name)
+	}
+	return hosts
+}
+
+// validateListenAddr checks a host:port listen address. IPv6 literals need
+// brackets, e.g. [::1]:8080; an empty host, as in :8080, listens on every
+// interface, IPv4 and IPv6.
+func validateListenAddr(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		if strings.Count(addr, ":") > 1 && !strings.HasPrefix(addr, "[") {
+			return fmt.Errorf("%q: IPv6 addresses need brackets, e.g. [::1]:8080", addr)
+		}
+		return fmt.Errorf("%q: %w", addr, err)
+	}
+	if _, err := netip.ParseAddr(host); strings.Contains(host, ":") && err != nil {
+		return fmt.Errorf("%q: invalid IPv6 address %q", addr, host)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("%q: invalid port %q", addr, port)
+	}
+	return nil
+}
+
+// isLoopbackAddr reports whether a listen address only accepts local
+// connections
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,212 @@
+package supervisor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/auth"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/tlsconfig"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestUnifiedSupervisor_APITLS(t *testing.T) {
+	// Reserve a port for the API listener
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve a port: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	workDir := t.TempDir()
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		WorkDir:         workDir,
+		APIEnabled:      true,
+		APIListenAddr:   addr,
+		APITLS:          tlsconfig.Config{SelfSigned: true},
+		ConfigEngine:    &stubConfigEngine{otelConfig: "receivers: {}\n"},
+		CollectorRunner: &fakeRunner{},
+		Logger:          zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	defer s.Stop(context.Background())
+
+	// The self-signed certificate is kept under the work dir
+	if _, err := os.Stat(filepath.Join(workDir, apiTLSDirName, tlsconfig.SelfSignedCertFile)); err != nil {
+		t.Fatalf("Expected the certificate under the work dir: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	leaf, err := x509.ParseCertificate(s.apiServer.TLSConfig.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	roots.AddCert(leaf)
+	client := &http.Client{
+		Timeout:   time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost"}},
+	}
+
+	// The listener starts in the background
+	var resp *http.Response
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if resp, err = client.Get("https://" + addr + "/health"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 over HTTPS, got %d", resp.StatusCode)
+	}
+}
+
+func TestUnifiedSupervisor_APITLSInvalid(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		WorkDir:         t.TempDir(),
+		APIEnabled:      true,
+		APIListenAddr:   "127.0.0.1:0",
+		APITLS:          tlsconfig.Config{SelfSigned: true, RequireClientCert: true},
+		ConfigEngine:    &stubConfigEngine{otelConfig: "receivers: {}\n"},
+		CollectorRunner: &fakeRunner{},
+		Logger:          zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	if err := s.Start(context.Background()); err == nil {
+		s.Stop(context.Background())
+		t.Fatal("Expected requiring client certificates without a CA to fail")
+	}
+}
+
+func TestUnifiedSupervisor_APIRemoteListen(t *testing.T) {
+	tests := []struct {
+		name    string
+		tls     tlsconfig.Config
+		auth    bool
+		allowed bool
+	}{
+		{name: "plain HTTP", auth: true},
+		{name: "TLS without authentication", tls: tlsconfig.Config{SelfSigned: true}},
+		{name: "TLS with authentication", tls: tlsconfig.Config{SelfSigned: true}, auth: true, allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewUnifiedSupervisor(SupervisorConfig{
+				WorkDir:       t.TempDir(),
+				APIEnabled:    true,
+				APIListenAddr: "0.0.0.0:8080",
+				APITLS:        tt.tls,
+				Logger:        zaptest.NewLogger(t),
+			})
+			if err != nil {
+				t.Fatalf("Failed to create supervisor: %v", err)
+			}
+			if tt.auth {
+				authConfig := auth.DefaultAuthConfig()
+				authConfig.Enabled = true
+				authConfig.Type = auth.AuthTypeJWT
+				authConfig.JWT.SecretKey = "test-secret"
+				if err := s.SetupAuthenticatedAPIServer(authConfig); err != nil {
+					t.Fatalf("Failed to set up authentication: %v", err)
+				}
+			}
+
+			err = s.configureAPITLS()
+			if tt.allowed && err != nil {
+				t.Errorf("Expected listening beyond localhost to be allowed: %v", err)
+			}
+			if !tt.allowed && (err == nil || !strings.Contains(err.Error(), "localhost only")) {
+				t.Errorf("Expected listening beyond localhost to be refused, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUnifiedSupervisor_APIRemoteLogin(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		APIEnabled:    true,
+		APIListenAddr: "0.0.0.0:8080",
+		APITLS:        tlsconfig.Config{SelfSigned: true},
+		Logger:        zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.Enabled = true
+	authConfig.Type = auth.AuthTypeJWT
+	authConfig.JWT.SecretKey = "test-secret"
+	if err := s.SetupAuthenticatedAPIServer(authConfig); err != nil {
+		t.Fatalf("Failed to set up authentication: %v", err)
+	}
+
+	// The built-in users' passwords are well known
+	body := strings.NewReader(`{"username": "admin", "password": "admin123"}`)
+	rec := httptest.NewRecorder()
+	s.apiServer.Handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/auth/login", body))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected built-in users to be refused beyond localhost, got %d", rec.Code)
+	}
+}
+
+func TestValidateListenAddr(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:8080", "localhost:8080", ":8080", "[::1]:8080", "[::]:8080", "[fe80::1%eth0]:8080"} {
+		if err := validateListenAddr(addr); err != nil {
+			t.Errorf("validateListenAddr(%q): %v", addr, err)
+		}
+	}
+	for _, addr := range []string{"::1:8080", "127.0.0.1", "[::1]", "[::1]:http-alt", "127.0.0.1:70000", "[nothex::1]:8080"} {
+		if err := validateListenAddr(addr); err == nil {
+			t.Errorf("Expected validateListenAddr(%q) to fail", addr)
+		}
+	}
+	if err := validateListenAddr("::1:8080"); err == nil || !strings.Contains(err.Error(), "brackets") {
+		t.Errorf("Expected a hint about brackets, got %v", err)
+	}
+}
+
+func TestAPICertificateHosts(t *testing.T) {
+	local := []string{"localhost", "127.0.0.1", "::1"}
+	hostname, _ := os.Hostname()
+
+	tests := []struct {
+		addr string
+		want []string
+	}{
+		{addr: "127.0.0.1:8080", want: local},
+		{addr: "localhost:8080", want: local},
+		{addr: "0.0.0.0:8080", want: append(local[:3:3], hostname)},
+		{addr: "10.0.0.5:8080", want: append(local[:3:3], "10.0.0.5", hostname)},
+		{addr: "[::1]:8080", want: local},
+		{addr: "[::]:8080", want: append(local[:3:3], hostname)},
+		{addr: ":8080", want: append(local[:3:3], hostname)},
+		{addr: "[fd00::5]:8080", want: append(local[:3:3], "fd00::5", hostname)},
+	}
+	for _, tt := range tests {
+		if got := apiCertificateHosts(tt.addr); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("apiCertificateHosts(%q) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
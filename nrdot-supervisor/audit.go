@@ -0,0 +1,397 @@
+package supervisor
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/auth"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+)
+
+const (
+	// auditLogFileName holds the audit log under WorkDir
+	auditLogFileName = "audit.log"
+
+	// defaultAuditPageSize and maxAuditPageSize bound GET /v1/audit pages
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 1000
+)
+
+// auditActions names the mutating API calls by method and route. Other
+// mutating calls are recorded as "<method> <route>".
+var auditActions = map[string]string{
+	"POST /v1/config":                     "config.update",
+	"PUT /v1/config":                      "config.update",
+	"POST /v1/config/rollback":            "config.rollback",
+	"POST /v1/control/reload":             "collector.reload",
+	"POST /v1/control/restart":            "collector.restart",
+	"POST /v1/control/update":             "collector.update",
+	"POST /v1/control/breaker/reset":      "breaker.reset",
+	"POST /v1/control/cardinality-report": "collector.cardinality_report",
+	"POST /v1/control/exec/{name}":        "host.exec",
+	"PUT /v1/secrets/{name}":              "secret.set",
+	"POST /v1/auth/keys":                  "api_key.create",
+	"POST /v1/auth/tokens":                "api_key.create",
+	"POST /v1/auth/keys/{id}/rotate":      "api_key.rotate",
+	"DELETE /v1/auth/keys/{id}":           "api_key.revoke",
+	"DELETE /v1/auth/tokens/{id}":         "api_key.revoke",
+}
+
+// auditExempt lists mutating methods and routes that change nothing
+var auditExempt = map[string]bool{
+	"POST /v1/config/validate": true,
+	"POST /v1/auth/login":      true,
+	"POST /v1/auth/refresh":    true,
+}
+
+// AuditEntry is one mutating API call. Each entry carries the hash of the
+// one before, so removing or editing an entry breaks the chain after it.
+type AuditEntry struct {
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	Target     string    `json:"target,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	PrevHash   string    `json:"prev_hash"`
+	Hash       string    `json:"hash,omitempty"`
+}
+
+// AuditQuery filters the audit log; zero fields match everything
+type AuditQuery struct {
+	Actor  string
+	Action string
+	Since  time.Time
+	Until  time.Time
+	Offset int
+	Limit  int
+}
+
+// AuditPage is a page of the audit log, newest first
+type AuditPage struct {
+	Entries    []AuditEntry `json:"entries"`
+	Total      int          `json:"total"`
+	Offset     int          `json:"offset"`
+	Limit      int          `json:"limit"`
+	NextOffset *int         `json:"next_offset,omitempty"`
+
+	// ChainValid is false when the persisted log failed verification at
+	// startup; ChainError says where
+	ChainValid bool   `json:"chain_valid"`
+	ChainError string `json:"chain_error,omitempty"`
+}
+
+// auditLog is an append-only, hash-chained log of mutating API calls,
+// persisted as JSON lines under WorkDir or kept in memory without one
+type auditLog struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	entries  []AuditEntry
+	chainErr error
+}
+
+// newAuditLog opens the audit log in dir, or an in-memory one if dir is
+// empty. The persisted chain is verified; a broken chain is reported by
+// chainError and new entries continue from the last one.
+func newAuditLog(dir string) (*auditLog, error) {
+	l := &auditLog{}
+	if dir == "" {
+		return l, nil
+	}
+	l.path = filepath.Join(dir, auditLogFileName)
+
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	l.file = file
+	return l, nil
+}
+
+// load reads and verifies the persisted entries
+func (l *auditLog) load() error {
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading audit log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	prevHash := ""
+	for line := 1; scanner.Scan(); line++ {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			if l.chainErr == nil {
+				l.chainErr = fmt.Errorf("line %d: %v", line, err)
+			}
+			continue
+		}
+		if l.chainErr == nil {
+			if entry.PrevHash != prevHash {
+				l.chainErr = fmt.Errorf("entry %d: does not follow the entry before it", entry.Seq)
+			} else if hashAuditEntry(entry) != entry.Hash {
+				l.chainErr = fmt.Errorf("entry %d: content does not match its hash", entry.Seq)
+			}
+		}
+		prevHash = entry.Hash
+		l.entries = append(l.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading audit log: %w", err)
+	}
+	return nil
+}
+
+// append chains entry to the log and persists it
+func (l *auditLog) append(entry AuditEntry) (AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.Time = entry.Time.UTC()
+	entry.Seq = 1
+	entry.PrevHash = ""
+	if n := len(l.entries); n > 0 {
+		entry.Seq = l.entries[n-1].Seq + 1
+		entry.PrevHash = l.entries[n-1].Hash
+	}
+	entry.Hash = hashAuditEntry(entry)
+
+	if l.file != nil {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return entry, err
+		}
+		if _, err := l.file.Write(append(data, '\n')); err != nil {
+			return entry, fmt.Errorf("writing audit log: %w", err)
+		}
+		if err := l.file.Sync(); err != nil {
+			return entry, fmt.Errorf("syncing audit log: %w", err)
+		}
+	}
+	l.entries = append(l.entries, entry)
+	return entry, nil
+}
+
+// query returns the entries matching q, newest first
+func (l *auditLog) query(q AuditQuery) AuditPage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var matched []AuditEntry
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		entry := l.entries[i]
+		if q.Actor != "" && entry.Actor != q.Actor {
+			continue
+		}
+		if q.Action != "" && entry.Action != q.Action && !strings.HasPrefix(entry.Action, q.Action+".") {
+			continue
+		}
+		if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && !entry.Time.Before(q.Until) {
+			continue
+		}
+		matched = append(matched, entry)
+	}
+
+	page := AuditPage{
+		Entries:    []AuditEntry{},
+		Total:      len(matched),
+		Offset:     q.Offset,
+		Limit:      q.Limit,
+		ChainValid: l.chainErr == nil,
+	}
+	if l.chainErr != nil {
+		page.ChainError = l.chainErr.Error()
+	}
+	if q.Offset < len(matched) {
+		end := len(matched)
+		if q.Offset+q.Limit < end {
+			end = q.Offset + q.Limit
+			next := end
+			page.NextOffset = &next
+		}
+		page.Entries = matched[q.Offset:end]
+	}
+	return page
+}
+
+// chainError returns why the persisted chain failed verification, nil if
+// it is intact
+func (l *auditLog) chainError() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.chainErr
+}
+
+// close closes the log file
+func (l *auditLog) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+// hashAuditEntry returns the hash of an entry's content, which includes
+// the hash of the entry before it
+func hashAuditEntry(entry AuditEntry) string {
+	entry.Hash = ""
+	data, _ := json.Marshal(entry)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// auditMiddleware records every mutating API call once it completes. It
+// must be installed after authentication so the caller is known.
+func (s *UnifiedSupervisor) auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		key := r.Method + " " + route
+		if auditExempt[key] || !strings.HasPrefix(route, "/v1/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		action, ok := auditActions[key]
+		if !ok {
+			action = strings.ToLower(r.Method) + " " + route
+		}
+		vars := mux.Vars(r)
+		target := vars["name"]
+		if target == "" {
+			target = vars["id"]
+		}
+
+		entry, err := s.audit.append(AuditEntry{
+			Time:       s.clock.Now(),
+			Actor:      auditActor(r),
+			Action:     action,
+			Target:     target,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     recorder.status,
+			RemoteAddr: r.RemoteAddr,
+		})
+		if err != nil {
+			s.logger.Error("Failed to write audit log", zap.String("action", action), zap.Error(err))
+			return
+		}
+		s.logger.Info("Audit",
+			zap.Int64("seq", entry.Seq),
+			zap.String("actor", entry.Actor),
+			zap.String("action", entry.Action),
+			zap.String("target", entry.Target),
+			zap.Int("status", entry.Status))
+	})
+}
+
+// auditActor names who made a request: the authenticated user and the API
+// key used, or "anonymous" without authentication
+func auditActor(r *http.Request) string {
+	claims, ok := auth.GetClaimsFromContext(r.Context())
+	if !ok || claims.Subject == "" {
+		return "anonymous"
+	}
+	if key, ok := r.Context().Value("apiKey").(*auth.APIKey); ok {
+		return fmt.Sprintf("%s (key %s)", claims.Subject, key.ID)
+	}
+	return claims.Subject
+}
+
+// statusRecorder captures the status code a handler responds with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// handleAudit serves GET /v1/audit. Entries are returned newest first and
+// may be filtered by actor, action (a prefix such as "config" matches
+// "config.update"), and since/until in RFC 3339, and paged with offset and
+// limit.
+func (s *UnifiedSupervisor) handleAudit(w http.ResponseWriter, r *http.Request) {
+	// Track API request
+	s.metrics.IncrementRequests()
+
+	query := r.URL.Query()
+	q := AuditQuery{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Limit:  defaultAuditPageSize,
+	}
+
+	for name, dst := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				problem.Error(w, r, name+" must be an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+			*dst = t
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxAuditPageSize {
+			problem.Error(w, r, fmt.Sprintf("limit must be between 1 and %d", maxAuditPageSize), http.StatusBadRequest)
+			return
+		}
+		q.Limit = n
+	}
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			problem.Error(w, r, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		q.Offset = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.audit.query(q))
+}
@@ -0,0 +1,243 @@
+package supervisor
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/auth"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestAuditLog_Chain(t *testing.T) {
+	dir := t.TempDir()
+	l, err := newAuditLog(dir)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, action := range []string{"config.update", "collector.reload", "api_key.create"} {
+		if _, err := l.append(AuditEntry{Time: start.Add(time.Duration(i) * time.Minute), Actor: "admin", Action: action}); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+	l.close()
+
+	// Reopening verifies the chain and continues it
+	l, err = newAuditLog(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen audit log: %v", err)
+	}
+	if err := l.chainError(); err != nil {
+		t.Fatalf("Expected an intact chain, got %v", err)
+	}
+	last := l.entries[len(l.entries)-1]
+	entry, err := l.append(AuditEntry{Time: start.Add(time.Hour), Actor: "admin", Action: "collector.restart"})
+	if err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	if entry.Seq != 4 || entry.PrevHash != last.Hash {
+		t.Errorf("Expected entry 4 chained to %s, got %+v", last.Hash, entry)
+	}
+	l.close()
+
+	path := filepath.Join(dir, auditLogFileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 lines, got %d", len(lines))
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"edited entry", strings.Join(lines[:1], "") + strings.Replace(lines[1], `"actor":"admin"`, `"actor":"operator"`, 1) + strings.Join(lines[2:], ""), "entry 2"},
+		{"removed entry", lines[0] + strings.Join(lines[2:], ""), "entry 3"},
+		{"truncated entry", strings.Join(lines[:3], "") + lines[3][:20], "line 4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatalf("Failed to write audit log: %v", err)
+			}
+			l, err := newAuditLog(dir)
+			if err != nil {
+				t.Fatalf("Failed to open audit log: %v", err)
+			}
+			defer l.close()
+			if err := l.chainError(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected a chain error at %s, got %v", tt.want, err)
+			}
+			if page := l.query(AuditQuery{Limit: 10}); page.ChainValid || page.ChainError == "" {
+				t.Errorf("Expected the page to report the broken chain, got %+v", page)
+			}
+		})
+	}
+}
+
+func TestAuditLog_Query(t *testing.T) {
+	l, err := newAuditLog("")
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []AuditEntry{
+		{Actor: "admin", Action: "config.update"},
+		{Actor: "operator", Action: "collector.reload"},
+		{Actor: "admin", Action: "config.rollback"},
+		{Actor: "operator", Action: "config.update"},
+		{Actor: "admin", Action: "api_key.revoke"},
+	}
+	for i, entry := range entries {
+		entry.Time = start.Add(time.Duration(i) * time.Hour)
+		if _, err := l.append(entry); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+
+	seqs := func(page AuditPage) []int64 {
+		var seqs []int64
+		for _, entry := range page.Entries {
+			seqs = append(seqs, entry.Seq)
+		}
+		return seqs
+	}
+
+	tests := []struct {
+		name  string
+		query AuditQuery
+		want  []int64
+		total int
+	}{
+		{"all, newest first", AuditQuery{Limit: 10}, []int64{5, 4, 3, 2, 1}, 5},
+		{"actor", AuditQuery{Actor: "operator", Limit: 10}, []int64{4, 2}, 2},
+		{"action", AuditQuery{Action: "config.update", Limit: 10}, []int64{4, 1}, 2},
+		{"action prefix", AuditQuery{Action: "config", Limit: 10}, []int64{4, 3, 1}, 3},
+		{"time range", AuditQuery{Since: start.Add(time.Hour), Until: start.Add(3 * time.Hour), Limit: 10}, []int64{3, 2}, 2},
+		{"first page", AuditQuery{Limit: 2}, []int64{5, 4}, 5},
+		{"last page", AuditQuery{Offset: 4, Limit: 2}, []int64{1}, 5},
+		{"past the end", AuditQuery{Offset: 5, Limit: 2}, nil, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := l.query(tt.query)
+			got := seqs(page)
+			if len(got) != len(tt.want) || page.Total != tt.total {
+				t.Fatalf("Expected %v of %d, got %v of %d", tt.want, tt.total, got, page.Total)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+
+	if page := l.query(AuditQuery{Limit: 2}); page.NextOffset == nil || *page.NextOffset != 2 {
+		t.Errorf("Expected the next page at offset 2, got %v", page.NextOffset)
+	}
+	if page := l.query(AuditQuery{Offset: 4, Limit: 2}); page.NextOffset != nil {
+		t.Errorf("Expected no next page, got %d", *page.NextOffset)
+	}
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	workDir := t.TempDir()
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		WorkDir:    workDir,
+		APIEnabled: true,
+		Logger:     zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	defer s.audit.close()
+
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.Enabled = true
+	authConfig.Type = auth.AuthTypeJWT
+	authConfig.JWT.SecretKey = "test-secret"
+	if err := s.SetupAuthenticatedAPIServer(authConfig); err != nil {
+		t.Fatalf("Failed to set up authentication: %v", err)
+	}
+	handler := s.apiServer.Handler
+
+	do := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	login := func(username, password string) string {
+		rec := do("POST", "/v1/auth/login", "", LoginRequest{Username: username, Password: password})
+		var resp LoginResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to log in as %s: %v", username, err)
+		}
+		return resp.Token
+	}
+	viewer := login("viewer", "viewer123")
+	operator := login("operator", "operator123")
+	admin := login("admin", "admin123")
+
+	// Reads, validation and logins are not audited; denied changes are
+	do("GET", "/v1/status", operator, nil)
+	do("POST", "/v1/config/validate", operator, map[string]string{})
+	do("POST", "/v1/control/reload", operator, nil)
+	do("POST", "/v1/config", viewer, map[string]string{})
+
+	rec := do("GET", "/v1/audit", admin, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var page AuditPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to decode audit page: %v", err)
+	}
+	if page.Total != 2 || !page.ChainValid {
+		t.Fatalf("Expected 2 entries in an intact chain, got %+v", page)
+	}
+	denied, reload := page.Entries[0], page.Entries[1]
+	if denied.Actor != "viewer" || denied.Action != "config.update" || denied.Status != http.StatusForbidden {
+		t.Errorf("Unexpected denied entry: %+v", denied)
+	}
+	if reload.Actor != "operator" || reload.Action != "collector.reload" || reload.Path != "/v1/control/reload" {
+		t.Errorf("Unexpected reload entry: %+v", reload)
+	}
+
+	// Filters and bad parameters
+	rec = do("GET", "/v1/audit?actor=operator&action=collector", admin, nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil || page.Total != 1 {
+		t.Errorf("Expected 1 filtered entry, got %s", rec.Body.String())
+	}
+	for _, query := range []string{"limit=0", "limit=x", "offset=-1", "since=yesterday"} {
+		if rec := do("GET", "/v1/audit?"+query, admin, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, rec.Code)
+		}
+	}
+
+	// Only admins read the audit log
+	if rec := do("GET", "/v1/audit", operator, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for an operator, got %d", rec.Code)
+	}
+
+	// The log is persisted under the work dir
+	if _, err := os.Stat(filepath.Join(workDir, auditLogFileName)); err != nil {
+		t.Errorf("Expected the audit log under the work dir: %v", err)
+	}
+}
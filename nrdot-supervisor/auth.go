@@ -2,14 +2,51 @@ package supervisor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
 
-	"github.com/gorilla/mux"
 	"github.com/newrelic/nrdot-host/nrdot-common/pkg/auth"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
 	"go.uber.org/zap"
 )
 
+const (
+	// apiKeysFileName holds the hashed API keys under WorkDir
+	apiKeysFileName = "api_keys.json"
+
+	// apiKeyRetention is how long revoked and expired keys stay listed
+	apiKeyRetention = 30 * 24 * time.Hour
+)
+
+// apiPolicy is the role each API request needs with authentication enabled.
+// Reads need viewer and changes operator, while key management, secrets
+// and the disruptive control endpoints need admin, as does the audit log.
+// Diagnostic commands need the role their allowlist entry names.
+var apiPolicy = auth.Policy{
+	Rules: []auth.RoleRule{
+		{Path: "/health", Public: true},
+		{Path: "/ready", Public: true},
+		{Methods: []string{"POST"}, Path: "/v1/auth/login", Public: true},
+		{Methods: []string{"POST"}, Path: "/v1/auth/refresh", Public: true},
+		{Path: "/v1/auth/keys", Role: auth.RoleAdmin},
+		{Path: "/v1/auth/tokens", Role: auth.RoleAdmin},
+		{Path: "/v1/secrets", Role: auth.RoleAdmin},
+		{Path: "/v1/control/restart", Role: auth.RoleAdmin},
+		{Path: "/v1/control/breaker/reset", Role: auth.RoleAdmin},
+		{Path: "/v1/control/update", Role: auth.RoleAdmin},
+		{Path: "/v1/audit", Role: auth.RoleAdmin},
+		// Each diagnostic command names its own role, checked by the handler
+		{Path: "/v1/control/exec", Role: auth.RoleViewer},
+		{Methods: []string{"GET", "HEAD"}, Role: auth.RoleViewer},
+	},
+	DefaultRole: auth.RoleOperator,
+}
+
 // AuthConfig extends SupervisorConfig with authentication settings
 type AuthConfig struct {
 	SupervisorConfig
@@ -25,12 +62,10 @@ func (s *UnifiedSupervisor) SetupAuthenticatedAPIServer(authConfig auth.Config)
 
 	// Create auth managers based on config
 	var jwtManager *auth.JWTManager
-	var tokenStore *auth.TokenStore
+	var keyStore *auth.KeyStore
 
 	if authConfig.Enabled {
-		switch authConfig.Type {
-		case auth.AuthTypeJWT:
-			// Create JWT manager
+		if authConfig.Type == auth.AuthTypeJWT || authConfig.Type == auth.AuthTypeBoth {
 			var err error
 			jwtManager, err = auth.NewJWTManager(
 				authConfig.JWT.SecretKey,
@@ -40,69 +75,48 @@ func (s *UnifiedSupervisor) SetupAuthenticatedAPIServer(authConfig auth.Config)
 			if err != nil {
 				return fmt.Errorf("failed to create JWT manager: %w", err)
 			}
-			s.logger.Info("JWT authentication enabled")
+		}
+		if authConfig.Type == auth.AuthTypeAPIKey || authConfig.Type == auth.AuthTypeBoth {
+			var err error
+			keyStore, err = s.openAPIKeyStore(authConfig)
+			if err != nil {
+				return err
+			}
+		}
 
+		switch authConfig.Type {
+		case auth.AuthTypeJWT:
+			s.logger.Info("JWT authentication enabled")
 		case auth.AuthTypeAPIKey:
-			// Create token store
-			tokenStore = auth.NewTokenStore()
-			
-			// Create default admin token if configured
-			if authConfig.DefaultAdmin.APIKey != "" {
-				info, err := tokenStore.CreateToken(
-					authConfig.DefaultAdmin.Username,
-					auth.RoleAdmin,
-					"Default admin token",
-					authConfig.APIKey.DefaultExpiration,
-				)
-				if err != nil {
-					return fmt.Errorf("failed to create default admin token: %w", err)
-				}
-				s.logger.Info("Default admin API key created", 
-					zap.String("token", info.Token),
-					zap.String("user", info.UserID))
-			}
 			s.logger.Info("API key authentication enabled")
-			
 		case auth.AuthTypeBoth:
-			// Create both JWT manager and token store
-			var err error
-			jwtManager, err = auth.NewJWTManager(
-				authConfig.JWT.SecretKey,
-				authConfig.JWT.Duration,
-				authConfig.JWT.Issuer,
-			)
-			if err != nil {
-				return fmt.Errorf("failed to create JWT manager: %w", err)
-			}
-			
-			tokenStore = auth.NewTokenStore()
-			
-			// Create default admin token if configured
-			if authConfig.DefaultAdmin.APIKey != "" {
-				info, err := tokenStore.CreateToken(
-					authConfig.DefaultAdmin.Username,
-					auth.RoleAdmin,
-					"Default admin token",
-					authConfig.APIKey.DefaultExpiration,
-				)
-				if err != nil {
-					return fmt.Errorf("failed to create default admin token: %w", err)
-				}
-				s.logger.Info("Default admin API key created", 
-					zap.String("token", info.Token),
-					zap.String("user", info.UserID))
-			}
-			
 			s.logger.Info("Both JWT and API key authentication enabled")
 		}
 	}
 
+	s.apiAuthEnabled = authConfig.Enabled
+
 	// Set up routes with authentication
-	router := mux.NewRouter()
+	router := newAPIRouter()
 
-	// Apply global middleware
+	// Apply global middleware: rate limit per identity, counting failed
+	// attempts against the client, authenticate, audit mutating calls
+	// including the ones the caller's role denies, then check the route's
+	// role
+	if s.config.RateLimitEnabled {
+		identify := func(*http.Request) (string, bool) { return "", false }
+		if authConfig.Enabled {
+			identify = credentialIdentity(authConfig, jwtManager, keyStore)
+		}
+		s.rateLimit = s.newRateLimit(identify)
+		router.Use(s.rateLimit)
+	}
+	if authConfig.Enabled {
+		router.Use(s.createAuthMiddleware(authConfig, jwtManager, keyStore))
+	}
+	router.Use(s.auditMiddleware)
 	if authConfig.Enabled {
-		router.Use(s.createAuthMiddleware(authConfig, jwtManager, tokenStore))
+		router.Use(apiPolicy.HTTPMiddleware())
 	}
 
 	// Always allow health checks without auth
@@ -115,21 +129,32 @@ func (s *UnifiedSupervisor) SetupAuthenticatedAPIServer(authConfig auth.Config)
 	// Read-only endpoints
 	v1.HandleFunc("/status", s.apiHandlers.Status).Methods("GET")
 	v1.HandleFunc("/config", s.apiHandlers.GetConfig).Methods("GET")
+	v1.HandleFunc("/config/history", s.apiHandlers.GetVersionHistory).Methods("GET")
 	v1.HandleFunc("/metrics", s.apiHandlers.GetMetrics).Methods("GET")
+	v1.HandleFunc("/logs/collector", s.handleCollectorLogs).Methods("GET")
+	v1.HandleFunc("/logs/supervisor", s.handleSupervisorLogs).Methods("GET")
+	v1.HandleFunc("/events/stream", s.handleEventStream).Methods("GET")
+	v1.HandleFunc("/audit", s.handleAudit).Methods("GET")
+	v1.HandleFunc("/logging", s.handleGetLogLevels).Methods("GET")
 
-	// Write endpoints (require higher permissions)
+	// Write endpoints; with authentication apiPolicy decides who may call them
+	v1.HandleFunc("/config", s.apiHandlers.UpdateConfig).Methods("POST", "PUT")
+	v1.HandleFunc("/config/validate", s.apiHandlers.ValidateConfig).Methods("POST")
+	v1.HandleFunc("/config/rollback", s.handleConfigRollback).Methods("POST")
+	v1.HandleFunc("/control/reload", s.handleReload).Methods("POST")
+	v1.HandleFunc("/control/restart", s.handleRestart).Methods("POST")
+	v1.HandleFunc("/control/breaker/reset", s.handleBreakerReset).Methods("POST")
+	v1.HandleFunc("/control/update", s.handleUpdate).Methods("POST")
+	v1.HandleFunc("/control/cardinality-report", s.handleCardinalityReport).Methods("POST")
+	v1.HandleFunc("/logging", s.handleSetLogLevel).Methods("PUT")
 	if authConfig.Enabled {
-		// These endpoints require operator or admin role
-		v1.HandleFunc("/config", s.requireRole(auth.RoleOperator, s.apiHandlers.UpdateConfig)).Methods("POST", "PUT")
-		v1.HandleFunc("/config/validate", s.requireRole(auth.RoleOperator, s.apiHandlers.ValidateConfig)).Methods("POST")
-		v1.HandleFunc("/control/reload", s.requireRole(auth.RoleOperator, s.handleReload)).Methods("POST")
-		v1.HandleFunc("/control/restart", s.requireRole(auth.RoleAdmin, s.handleRestart)).Methods("POST")
+		v1.HandleFunc("/secrets/{name}", s.handleSetSecret).Methods("PUT")
+		v1.HandleFunc("/control/exec", s.handleExecList).Methods("GET")
+		v1.HandleFunc("/control/exec/{name}", s.handleExec).Methods("POST")
 	} else {
-		// No auth required
-		v1.HandleFunc("/config", s.apiHandlers.UpdateConfig).Methods("POST", "PUT")
-		v1.HandleFunc("/config/validate", s.apiHandlers.ValidateConfig).Methods("POST")
-		v1.HandleFunc("/control/reload", s.handleReload).Methods("POST")
-		v1.HandleFunc("/control/restart", s.handleRestart).Methods("POST")
+		v1.HandleFunc("/secrets/{name}", s.handleSecretsAuthRequired).Methods("PUT")
+		v1.HandleFunc("/control/exec", s.handleExecAuthRequired).Methods("GET")
+		v1.HandleFunc("/control/exec/{name}", s.handleExecAuthRequired).Methods("POST")
 	}
 
 	// Auth management endpoints (only when auth is enabled)
@@ -143,10 +168,18 @@ func (s *UnifiedSupervisor) SetupAuthenticatedAPIServer(authConfig auth.Config)
 		}
 		
 		// API key endpoints
-		if tokenStore != nil {
-			authRouter.HandleFunc("/tokens", s.requireRole(auth.RoleAdmin, s.handleListTokens(tokenStore))).Methods("GET")
-			authRouter.HandleFunc("/tokens", s.requireRole(auth.RoleAdmin, s.handleCreateToken(tokenStore))).Methods("POST")
-			authRouter.HandleFunc("/tokens/{token}", s.requireRole(auth.RoleAdmin, s.handleRevokeToken(tokenStore))).Methods("DELETE")
+		if keyStore != nil {
+			defaultTTL := authConfig.APIKey.DefaultExpiration
+			authRouter.HandleFunc("/keys", s.handleListAPIKeys(keyStore)).Methods("GET")
+			authRouter.HandleFunc("/keys", s.handleCreateAPIKey(keyStore, defaultTTL)).Methods("POST")
+			authRouter.HandleFunc("/keys/{id}", s.handleGetAPIKey(keyStore)).Methods("GET")
+			authRouter.HandleFunc("/keys/{id}", s.handleRevokeAPIKey(keyStore)).Methods("DELETE")
+			authRouter.HandleFunc("/keys/{id}/rotate", s.handleRotateAPIKey(keyStore, defaultTTL)).Methods("POST")
+
+			// Deprecated aliases from before keys were persisted
+			authRouter.HandleFunc("/tokens", s.handleListAPIKeys(keyStore)).Methods("GET")
+			authRouter.HandleFunc("/tokens", s.handleCreateToken(keyStore, defaultTTL)).Methods("POST")
+			authRouter.HandleFunc("/tokens/{id}", s.handleRevokeAPIKey(keyStore)).Methods("DELETE")
 		}
 	}
 
@@ -156,59 +189,80 @@ func (s *UnifiedSupervisor) SetupAuthenticatedAPIServer(authConfig auth.Config)
 	return nil
 }
 
+// openAPIKeyStore opens the API key store under WorkDir, or in memory
+// without one, and imports the configured default admin key so it can be
+// listed, rotated and revoked like any other
+func (s *UnifiedSupervisor) openAPIKeyStore(authConfig auth.Config) (*auth.KeyStore, error) {
+	path := ""
+	if s.config.WorkDir != "" {
+		path = filepath.Join(s.config.WorkDir, apiKeysFileName)
+	}
+	store, err := auth.NewKeyStore(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open API key store: %w", err)
+	}
+
+	if removed, err := store.CleanupExpired(apiKeyRetention); err != nil {
+		s.logger.Warn("Failed to remove old API keys", zap.Error(err))
+	} else if removed > 0 {
+		s.logger.Info("Removed old API keys", zap.Int("count", removed))
+	}
+
+	if authConfig.DefaultAdmin.APIKey != "" {
+		info, err := store.Import(
+			authConfig.DefaultAdmin.APIKey,
+			authConfig.DefaultAdmin.Username,
+			auth.RoleAdmin,
+			"Default admin key",
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import default admin API key: %w", err)
+		}
+		if info.RevokedAt != nil {
+			s.logger.Warn("Configured default admin API key has been revoked",
+				zap.String("id", info.ID))
+		} else {
+			s.logger.Info("Default admin API key available",
+				zap.String("id", info.ID),
+				zap.String("user", info.UserID))
+		}
+	}
+
+	s.logger.Info("API key store opened",
+		zap.String("path", path),
+		zap.Int("keys", len(store.List(""))))
+	return store, nil
+}
+
 // createAuthMiddleware creates the appropriate auth middleware based on config
-func (s *UnifiedSupervisor) createAuthMiddleware(config auth.Config, jwtManager *auth.JWTManager, tokenStore *auth.TokenStore) func(http.Handler) http.Handler {
+func (s *UnifiedSupervisor) createAuthMiddleware(config auth.Config, jwtManager *auth.JWTManager, keyStore *auth.KeyStore) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Skip auth for health endpoints
-			if r.URL.Path == "/health" || r.URL.Path == "/ready" {
+			// Skip auth for health checks and login
+			if apiPolicy.IsPublic(r) {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			authenticated := false
-			
-			// Try JWT authentication
-			if (config.Type == auth.AuthTypeJWT || config.Type == auth.AuthTypeBoth) && jwtManager != nil {
-				authHeader := r.Header.Get("Authorization")
-				if authHeader != "" && len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-					token := authHeader[7:]
-					if claims, err := jwtManager.ValidateToken(token); err == nil {
-						// Add claims to context
-						ctx := r.Context()
-						ctx = context.WithValue(ctx, "claims", claims)
-						r = r.WithContext(ctx)
-						authenticated = true
-					}
-				}
+			claims, key, err := authenticateRequest(r, config, jwtManager, keyStore)
+			if key != nil && err != nil {
+				// A known key used after its revocation or expiry may have
+				// leaked
+				s.recordEvent(models.EventTypeAuthFailure, models.EventSeverityWarning,
+					"Rejected API key", fmt.Sprintf("key %s (%s): %v", key.ID, key.Prefix, err))
 			}
-
-			// Try API key authentication if not authenticated yet
-			if !authenticated && (config.Type == auth.AuthTypeAPIKey || config.Type == auth.AuthTypeBoth) && tokenStore != nil {
-				apiKey := r.Header.Get(config.APIKey.HeaderName)
-				if apiKey == "" && config.APIKey.AllowQueryParam {
-					apiKey = r.URL.Query().Get(config.APIKey.QueryParamName)
-				}
-				
-				if apiKey != "" {
-					if info, err := tokenStore.ValidateToken(apiKey); err == nil {
-						// Create claims from token info
-						claims := &auth.JWTClaims{
-							Role: info.Role,
-						}
-						claims.Subject = info.UserID
-						
-						ctx := r.Context()
-						ctx = context.WithValue(ctx, "claims", claims)
-						ctx = context.WithValue(ctx, "tokenInfo", info)
-						r = r.WithContext(ctx)
-						authenticated = true
-					}
+			authenticated := err == nil
+			if authenticated {
+				// Add claims to context
+				ctx := context.WithValue(r.Context(), "claims", claims)
+				if key != nil {
+					ctx = context.WithValue(ctx, "apiKey", key)
 				}
+				r = r.WithContext(ctx)
 			}
 
 			if !authenticated {
-				http.Error(w, "Authentication required", http.StatusUnauthorized)
+				problem.Error(w, r, "Authentication required", http.StatusUnauthorized)
 				return
 			}
 
@@ -217,39 +271,42 @@ func (s *UnifiedSupervisor) createAuthMiddleware(config auth.Config, jwtManager
 	}
 }
 
-// requireRole creates a middleware that checks for specific role
-func (s *UnifiedSupervisor) requireRole(requiredRole string, handler http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		claims, ok := auth.GetClaimsFromContext(r.Context())
-		if !ok {
-			http.Error(w, "No authentication claims found", http.StatusUnauthorized)
-			return
-		}
+// errNoCredentials is returned for requests without valid credentials
+var errNoCredentials = errors.New("no valid credentials")
 
-		// Check role hierarchy
-		if !hasRequiredRole(claims.Role, requiredRole) {
-			http.Error(w, "Insufficient permissions", http.StatusForbidden)
-			return
+// authenticateRequest returns the claims the credentials of a request
+// carry: a bearer token, or an API key for which the key is also returned.
+// A known key that was revoked or has expired is returned with its error.
+func authenticateRequest(r *http.Request, config auth.Config, jwtManager *auth.JWTManager, keyStore *auth.KeyStore) (*auth.JWTClaims, *auth.APIKey, error) {
+	// Try JWT authentication
+	if (config.Type == auth.AuthTypeJWT || config.Type == auth.AuthTypeBoth) && jwtManager != nil {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+			if claims, err := jwtManager.ValidateToken(token); err == nil {
+				return claims, nil, nil
+			}
 		}
-
-		handler(w, r)
-	}
-}
-
-// hasRequiredRole checks role hierarchy
-func hasRequiredRole(userRole, requiredRole string) bool {
-	roleLevel := map[string]int{
-		auth.RoleViewer:   1,
-		auth.RoleOperator: 2,
-		auth.RoleAdmin:    3,
 	}
 
-	userLevel, ok1 := roleLevel[userRole]
-	requiredLevel, ok2 := roleLevel[requiredRole]
-
-	if !ok1 || !ok2 {
-		return false
+	// Try API key authentication if not authenticated yet
+	if (config.Type == auth.AuthTypeAPIKey || config.Type == auth.AuthTypeBoth) && keyStore != nil {
+		apiKey := r.Header.Get(config.APIKey.HeaderName)
+		if apiKey == "" && config.APIKey.AllowQueryParam {
+			apiKey = r.URL.Query().Get(config.APIKey.QueryParamName)
+		}
+		if apiKey != "" {
+			info, err := keyStore.Validate(apiKey)
+			if err != nil {
+				if errors.Is(err, auth.ErrKeyNotFound) {
+					return nil, nil, errNoCredentials
+				}
+				return nil, info, err
+			}
+			// Create claims from the key
+			claims := &auth.JWTClaims{Role: info.Role}
+			claims.Subject = info.UserID
+			return claims, info, nil
+		}
 	}
 
-	return userLevel >= requiredLevel
-}
\ No newline at end of file
+	return nil, nil, errNoCredentials
+}
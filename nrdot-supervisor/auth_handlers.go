@@ -2,11 +2,15 @@ package supervisor
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/newrelic/nrdot-host/nrdot-common/pkg/auth"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
 	"go.uber.org/zap"
 )
 
@@ -23,6 +27,32 @@ type LoginResponse struct {
 	Role      string    `json:"role"`
 }
 
+// APIKeyRequest is the body of POST /v1/auth/keys
+type APIKeyRequest struct {
+	UserID      string `json:"user_id"`
+	Role        string `json:"role"`
+	Description string `json:"description,omitempty"`
+	// ExpiresIn is a duration such as "720h"; empty uses the configured
+	// default expiration and "0" never expires
+	ExpiresIn string `json:"expires_in,omitempty"`
+}
+
+// APIKeyRotateRequest is the optional body of POST /v1/auth/keys/{id}/rotate
+type APIKeyRotateRequest struct {
+	// ExpiresIn is the lifetime of the new key, as in APIKeyRequest
+	ExpiresIn string `json:"expires_in,omitempty"`
+	// GracePeriod keeps the old key working this long, e.g. "1h"; empty
+	// revokes it right away
+	GracePeriod string `json:"grace_period,omitempty"`
+}
+
+// APIKeyResponse is an API key with its secret, only returned when it is
+// created or rotated
+type APIKeyResponse struct {
+	auth.APIKey
+	Key string `json:"key"`
+}
+
 // TokenRequest represents a token creation request
 type TokenRequest struct {
 	UserID      string        `json:"user_id"`
@@ -44,9 +74,15 @@ type TokenResponse struct {
 // handleLogin handles user login requests
 func (s *UnifiedSupervisor) handleLogin(jwtManager *auth.JWTManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// The built-in users below have well-known passwords
+		if !isLoopbackAddr(s.config.APIListenAddr) {
+			problem.Error(w, r, "Built-in users can only log in to an API listening on localhost; use an API key", http.StatusForbidden)
+			return
+		}
+
 		var req LoginRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			problem.Error(w, r, "Invalid request body", http.StatusBadRequest)
 			return
 		}
 
@@ -58,24 +94,24 @@ func (s *UnifiedSupervisor) handleLogin(jwtManager *auth.JWTManager) http.Handle
 		switch req.Username {
 		case "admin":
 			if req.Password != "admin123" { // This should be hashed in production
-				http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+				problem.Error(w, r, "Invalid credentials", http.StatusUnauthorized)
 				return
 			}
 			role = auth.RoleAdmin
 		case "operator":
 			if req.Password != "operator123" {
-				http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+				problem.Error(w, r, "Invalid credentials", http.StatusUnauthorized)
 				return
 			}
 			role = auth.RoleOperator
 		case "viewer":
 			if req.Password != "viewer123" {
-				http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+				problem.Error(w, r, "Invalid credentials", http.StatusUnauthorized)
 				return
 			}
 			role = auth.RoleViewer
 		default:
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			problem.Error(w, r, "Invalid credentials", http.StatusUnauthorized)
 			return
 		}
 
@@ -83,7 +119,7 @@ func (s *UnifiedSupervisor) handleLogin(jwtManager *auth.JWTManager) http.Handle
 		token, err := jwtManager.GenerateToken(req.Username, role)
 		if err != nil {
 			s.logger.Error("Failed to generate token", zap.Error(err))
-			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			problem.Error(w, r, "Failed to generate token", http.StatusInternalServerError)
 			return
 		}
 
@@ -105,7 +141,7 @@ func (s *UnifiedSupervisor) handleRefreshToken(jwtManager *auth.JWTManager) http
 		// Get current token from Authorization header
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" || len(authHeader) <= 7 || authHeader[:7] != "Bearer " {
-			http.Error(w, "Bearer token required", http.StatusBadRequest)
+			problem.Error(w, r, "Bearer token required", http.StatusBadRequest)
 			return
 		}
 
@@ -114,14 +150,14 @@ func (s *UnifiedSupervisor) handleRefreshToken(jwtManager *auth.JWTManager) http
 		// Refresh the token
 		newToken, err := jwtManager.RefreshToken(oldToken)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			problem.Error(w, r, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		// Validate new token to get expiration
 		claims, err := jwtManager.ValidateToken(newToken)
 		if err != nil {
-			http.Error(w, "Failed to validate new token", http.StatusInternalServerError)
+			problem.Error(w, r, "Failed to validate new token", http.StatusInternalServerError)
 			return
 		}
 
@@ -136,65 +172,45 @@ func (s *UnifiedSupervisor) handleRefreshToken(jwtManager *auth.JWTManager) http
 	}
 }
 
-// handleListTokens lists all API tokens
-func (s *UnifiedSupervisor) handleListTokens(store *auth.TokenStore) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Get user ID from query parameter (optional)
-		userID := r.URL.Query().Get("user_id")
-		
-		var tokens []*auth.TokenInfo
-		if userID != "" {
-			tokens = store.ListTokens(userID)
-		} else {
-			// For admin, list all tokens
-			// This is a simplified implementation
-			tokens = store.ListTokens("")
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(tokens)
-	}
-}
-
-// handleCreateToken creates a new API token
-func (s *UnifiedSupervisor) handleCreateToken(store *auth.TokenStore) http.HandlerFunc {
+// handleCreateToken creates an API key in the legacy token format. It is a
+// deprecated alias of POST /v1/auth/keys.
+func (s *UnifiedSupervisor) handleCreateToken(store *auth.KeyStore, defaultTTL time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req TokenRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			problem.Error(w, r, "Invalid request body", http.StatusBadRequest)
 			return
 		}
 
 		// Validate role
-		switch req.Role {
-		case auth.RoleAdmin, auth.RoleOperator, auth.RoleViewer:
-			// Valid roles
-		default:
-			http.Error(w, "Invalid role", http.StatusBadRequest)
+		if !auth.ValidRole(req.Role) {
+			problem.Error(w, r, "Invalid role", http.StatusBadRequest)
 			return
 		}
 
 		// Use default duration if not specified
 		duration := req.Duration
 		if duration == 0 {
-			duration = 90 * 24 * time.Hour // 90 days
+			duration = defaultTTL
 		}
 
-		// Create token
-		info, err := store.CreateToken(req.UserID, req.Role, req.Description, duration)
+		info, secret, err := store.Create(req.UserID, req.Role, req.Description, duration)
 		if err != nil {
-			s.logger.Error("Failed to create token", zap.Error(err))
-			http.Error(w, "Failed to create token", http.StatusInternalServerError)
+			s.logger.Error("Failed to create API key", zap.Error(err))
+			problem.Error(w, r, "Failed to create API key", http.StatusInternalServerError)
 			return
 		}
+		s.auditAPIKey(r, models.EventTypeAPIKeyCreated, "API key created", info)
 
 		resp := TokenResponse{
-			Token:       info.Token,
+			Token:       secret,
 			UserID:      info.UserID,
 			Role:        info.Role,
 			Description: info.Description,
 			CreatedAt:   info.CreatedAt,
-			ExpiresAt:   info.ExpiresAt,
+		}
+		if info.ExpiresAt != nil {
+			resp.ExpiresAt = *info.ExpiresAt
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -203,22 +219,156 @@ func (s *UnifiedSupervisor) handleCreateToken(store *auth.TokenStore) http.Handl
 	}
 }
 
-// handleRevokeToken revokes an API token
-func (s *UnifiedSupervisor) handleRevokeToken(store *auth.TokenStore) http.HandlerFunc {
+// handleListAPIKeys lists API keys, of one user with ?user_id=
+func (s *UnifiedSupervisor) handleListAPIKeys(store *auth.KeyStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		token := vars["token"]
-		
-		if token == "" {
-			http.Error(w, "Token required", http.StatusBadRequest)
+		keys := store.List(r.URL.Query().Get("user_id"))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(keys)
+	}
+}
+
+// handleGetAPIKey returns the metadata of one API key
+func (s *UnifiedSupervisor) handleGetAPIKey(store *auth.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := store.Get(mux.Vars(r)["id"])
+		if err != nil {
+			problem.Error(w, r, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	}
+}
+
+// handleCreateAPIKey creates an API key, returned once in the response
+func (s *UnifiedSupervisor) handleCreateAPIKey(store *auth.KeyStore, defaultTTL time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req APIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			problem.Error(w, r, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.UserID == "" {
+			problem.Error(w, r, "user_id is required", http.StatusBadRequest)
+			return
+		}
+		if !auth.ValidRole(req.Role) {
+			problem.Error(w, r, "Invalid role", http.StatusBadRequest)
+			return
+		}
+		ttl, err := parseKeyDuration(req.ExpiresIn, defaultTTL)
+		if err != nil {
+			problem.Error(w, r, "Invalid expires_in: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		info, secret, err := store.Create(req.UserID, req.Role, req.Description, ttl)
+		if err != nil {
+			s.logger.Error("Failed to create API key", zap.Error(err))
+			problem.Error(w, r, "Failed to create API key", http.StatusInternalServerError)
+			return
+		}
+		s.auditAPIKey(r, models.EventTypeAPIKeyCreated, "API key created", info)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(APIKeyResponse{APIKey: *info, Key: secret})
+	}
+}
+
+// handleRevokeAPIKey revokes an API key
+func (s *UnifiedSupervisor) handleRevokeAPIKey(store *auth.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := store.Revoke(mux.Vars(r)["id"])
+		if errors.Is(err, auth.ErrKeyNotFound) {
+			problem.Error(w, r, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error("Failed to revoke API key", zap.Error(err))
+			problem.Error(w, r, "Failed to revoke API key", http.StatusInternalServerError)
+			return
+		}
+		s.auditAPIKey(r, models.EventTypeAPIKeyRevoked, "API key revoked", info)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	}
+}
+
+// handleRotateAPIKey replaces an API key with a new one for the same user
+// and role, returned once in the response
+func (s *UnifiedSupervisor) handleRotateAPIKey(store *auth.KeyStore, defaultTTL time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req APIKeyRotateRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				problem.Error(w, r, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+		ttl, err := parseKeyDuration(req.ExpiresIn, defaultTTL)
+		if err != nil {
+			problem.Error(w, r, "Invalid expires_in: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		grace, err := parseKeyDuration(req.GracePeriod, 0)
+		if err != nil {
+			problem.Error(w, r, "Invalid grace_period: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		if err := store.RevokeToken(token); err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+		id := mux.Vars(r)["id"]
+		info, secret, err := store.Rotate(id, ttl, grace)
+		switch {
+		case errors.Is(err, auth.ErrKeyNotFound):
+			problem.Error(w, r, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, auth.ErrKeyRevoked):
+			problem.Error(w, r, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			s.logger.Error("Failed to rotate API key", zap.Error(err))
+			problem.Error(w, r, "Failed to rotate API key", http.StatusInternalServerError)
 			return
 		}
+		s.auditAPIKey(r, models.EventTypeAPIKeyRotated, "API key rotated", info)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(APIKeyResponse{APIKey: *info, Key: secret})
+	}
+}
 
-		w.WriteHeader(http.StatusNoContent)
+// auditAPIKey records a change to an API key with the user who made it
+func (s *UnifiedSupervisor) auditAPIKey(r *http.Request, eventType models.EventType, summary string, info *auth.APIKey) {
+	actor := "unknown"
+	if claims, ok := auth.GetClaimsFromContext(r.Context()); ok && claims.Subject != "" {
+		actor = claims.Subject
+	}
+	details := fmt.Sprintf("key %s (%s) of user %s with role %s, by %s",
+		info.ID, info.Prefix, info.UserID, info.Role, actor)
+	if info.RotatedFrom != "" {
+		details += ", replacing key " + info.RotatedFrom
 	}
-}
\ No newline at end of file
+	s.recordEvent(eventType, models.EventSeverityInfo, summary, details)
+}
+
+// parseKeyDuration parses a key lifetime such as "720h". Empty means
+// fallback and "0" never expires.
+func parseKeyDuration(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return d, nil
+}
@@ -0,0 +1,327 @@
+package supervisor
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/auth"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestSetupAuthenticatedAPIServer_Roles(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		WorkDir:    t.TempDir(),
+		APIEnabled: true,
+		Logger:     zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.Enabled = true
+	authConfig.Type = auth.AuthTypeBoth
+	authConfig.JWT.SecretKey = "test-secret"
+	if err := s.SetupAuthenticatedAPIServer(authConfig); err != nil {
+		t.Fatalf("Failed to set up authentication: %v", err)
+	}
+	handler := s.apiServer.Handler
+
+	do := func(method, path string, header http.Header, body interface{}) *httptest.ResponseRecorder {
+		var data []byte
+		if body != nil {
+			data, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	bearer := func(token string) http.Header {
+		return http.Header{"Authorization": {"Bearer " + token}}
+	}
+	login := func(username, password string) string {
+		rec := do("POST", "/v1/auth/login", nil, LoginRequest{Username: username, Password: password})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Login as %s: expected 200, got %d", username, rec.Code)
+		}
+		var resp LoginResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode login response: %v", err)
+		}
+		return resp.Token
+	}
+
+	// Health checks and login need no credentials, everything else does
+	if rec := do("GET", "/health", nil, nil); rec.Code == http.StatusUnauthorized {
+		t.Error("Expected /health to be public")
+	}
+	if rec := do("GET", "/v1/status", nil, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without credentials, got %d", rec.Code)
+	}
+
+	viewer := bearer(login("viewer", "viewer123"))
+	operator := bearer(login("operator", "operator123"))
+	admin := bearer(login("admin", "admin123"))
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		header  http.Header
+		allowed bool
+	}{
+		{"viewer reads status", "GET", "/v1/status", viewer, true},
+		{"viewer cannot reload", "POST", "/v1/control/reload", viewer, false},
+		{"viewer cannot change config", "POST", "/v1/config", viewer, false},
+		{"operator reloads", "POST", "/v1/control/reload", operator, true},
+		{"operator validates config", "POST", "/v1/config/validate", operator, true},
+		{"operator cannot restart", "POST", "/v1/control/restart", operator, false},
+		{"operator cannot list tokens", "GET", "/v1/auth/tokens", operator, false},
+		{"operator cannot set secrets", "PUT", "/v1/secrets/X", operator, false},
+		{"admin lists tokens", "GET", "/v1/auth/tokens", admin, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.method, tt.path, tt.header, map[string]string{})
+			if tt.allowed && (rec.Code == http.StatusUnauthorized || rec.Code == http.StatusForbidden) {
+				t.Errorf("Expected access, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if !tt.allowed && rec.Code != http.StatusForbidden {
+				t.Errorf("Expected 403, got %d", rec.Code)
+			}
+		})
+	}
+
+	// API keys carry the role they were created with
+	rec := do("POST", "/v1/auth/tokens", admin, TokenRequest{UserID: "dashboards", Role: auth.RoleViewer})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating an API key, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created TokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to decode token response: %v", err)
+	}
+	apiKey := http.Header{"X-Api-Key": {created.Token}}
+	if rec := do("GET", "/v1/status", apiKey, nil); rec.Code == http.StatusUnauthorized || rec.Code == http.StatusForbidden {
+		t.Errorf("Expected the viewer key to read status, got %d", rec.Code)
+	}
+	if rec := do("POST", "/v1/control/reload", apiKey, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for the viewer key, got %d", rec.Code)
+	}
+
+	if rec := do("POST", "/v1/auth/tokens", admin, TokenRequest{UserID: "x", Role: "root"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown role, got %d", rec.Code)
+	}
+}
+
+func TestSetupAuthenticatedAPIServer_ProblemDetails(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		WorkDir:    t.TempDir(),
+		APIEnabled: true,
+		Logger:     zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.Enabled = true
+	authConfig.Type = auth.AuthTypeJWT
+	authConfig.JWT.SecretKey = "test-secret"
+	if err := s.SetupAuthenticatedAPIServer(authConfig); err != nil {
+		t.Fatalf("Failed to set up authentication: %v", err)
+	}
+
+	data, _ := json.Marshal(LoginRequest{Username: "viewer", Password: "viewer123"})
+	rec := httptest.NewRecorder()
+	s.apiServer.Handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/auth/login", bytes.NewReader(data)))
+	var login LoginResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &login); err != nil || login.Token == "" {
+		t.Fatalf("Failed to log in: %d %s", rec.Code, rec.Body.String())
+	}
+	token := login.Token
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		auth   bool
+		body   string
+		status int
+	}{
+		{"unauthenticated", "GET", "/v1/status", false, "", http.StatusUnauthorized},
+		{"forbidden", "POST", "/v1/control/reload", true, "", http.StatusForbidden},
+		{"invalid login", "POST", "/v1/auth/login", false, "{", http.StatusBadRequest},
+		{"unknown path", "GET", "/v1/unknown", true, "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.auth {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			rec := httptest.NewRecorder()
+			s.apiServer.Handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("Expected %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			details, ok := problem.Parse(rec.Header(), rec.Body.Bytes())
+			if !ok {
+				t.Fatalf("Expected problem details, got %q (%s)", rec.Body.String(), rec.Header().Get("Content-Type"))
+			}
+			if details.Status != tt.status || details.Code != problem.Code(tt.status) ||
+				details.Instance != tt.path || details.Detail == "" {
+				t.Errorf("Unexpected problem details %+v", details)
+			}
+		})
+	}
+}
+
+func TestSetupAuthenticatedAPIServer_APIKeys(t *testing.T) {
+	workDir := t.TempDir()
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.Enabled = true
+	authConfig.Type = auth.AuthTypeAPIKey
+	authConfig.DefaultAdmin.APIKey = "bootstrap-admin-key"
+
+	newServer := func() (*UnifiedSupervisor, http.Handler) {
+		s, err := NewUnifiedSupervisor(SupervisorConfig{
+			WorkDir:    workDir,
+			APIEnabled: true,
+			Logger:     zaptest.NewLogger(t),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create supervisor: %v", err)
+		}
+		if err := s.SetupAuthenticatedAPIServer(authConfig); err != nil {
+			t.Fatalf("Failed to set up authentication: %v", err)
+		}
+		return s, s.apiServer.Handler
+	}
+	s, handler := newServer()
+	events, cancel := s.SubscribeEvents(EventFilter{Types: []string{"security."}})
+	defer cancel()
+
+	do := func(method, path, key string, body interface{}) *httptest.ResponseRecorder {
+		var data []byte
+		if body != nil {
+			data, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	decode := func(rec *httptest.ResponseRecorder, v interface{}) {
+		t.Helper()
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("Failed to decode %s: %v", rec.Body.String(), err)
+		}
+	}
+	const admin = "bootstrap-admin-key"
+
+	// Create an operator key; the response is the only place it appears
+	rec := do("POST", "/v1/auth/keys", admin, APIKeyRequest{UserID: "ci", Role: auth.RoleOperator, ExpiresIn: "24h"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created APIKeyResponse
+	decode(rec, &created)
+	if created.Key == "" || created.ExpiresAt == nil || created.Role != auth.RoleOperator {
+		t.Fatalf("Unexpected key: %+v", created)
+	}
+	if event := <-events; event.Type != models.EventTypeAPIKeyCreated || !strings.Contains(event.Details, created.ID) {
+		t.Errorf("Expected a key creation event, got %+v", event)
+	}
+
+	if rec := do("POST", "/v1/control/reload", created.Key, nil); rec.Code == http.StatusUnauthorized || rec.Code == http.StatusForbidden {
+		t.Errorf("Expected the operator key to reload, got %d", rec.Code)
+	}
+	if rec := do("GET", "/v1/auth/keys", created.Key, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 listing keys as operator, got %d", rec.Code)
+	}
+	for _, req := range []APIKeyRequest{{Role: auth.RoleViewer}, {UserID: "x", Role: "root"}, {UserID: "x", Role: auth.RoleViewer, ExpiresIn: "soon"}} {
+		if rec := do("POST", "/v1/auth/keys", admin, req); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %+v, got %d", req, rec.Code)
+		}
+	}
+
+	// Listing shows metadata, never keys or hashes
+	rec = do("GET", "/v1/auth/keys", admin, nil)
+	if strings.Contains(rec.Body.String(), created.Key) || strings.Contains(rec.Body.String(), "hash") {
+		t.Errorf("Key list leaks secrets: %s", rec.Body.String())
+	}
+	var keys []auth.APIKey
+	decode(rec, &keys)
+	if len(keys) != 2 {
+		t.Fatalf("Expected the default admin and the new key, got %+v", keys)
+	}
+
+	// Rotate with a grace period: both keys work until it ends
+	rec = do("POST", "/v1/auth/keys/"+created.ID+"/rotate", admin, APIKeyRotateRequest{GracePeriod: "1h"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 rotating, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var rotated APIKeyResponse
+	decode(rec, &rotated)
+	if rotated.RotatedFrom != created.ID || rotated.Role != auth.RoleOperator {
+		t.Errorf("Unexpected rotated key: %+v", rotated)
+	}
+	if event := <-events; event.Type != models.EventTypeAPIKeyRotated {
+		t.Errorf("Expected a rotation event, got %+v", event)
+	}
+	for _, key := range []string{created.Key, rotated.Key} {
+		if rec := do("GET", "/v1/status", key, nil); rec.Code == http.StatusUnauthorized {
+			t.Errorf("Expected key %s to work during the grace period", key[:10])
+		}
+	}
+
+	// Revoke the old key; using it afterwards is audited
+	if rec := do("DELETE", "/v1/auth/keys/"+created.ID, admin, nil); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 revoking, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if event := <-events; event.Type != models.EventTypeAPIKeyRevoked {
+		t.Errorf("Expected a revocation event, got %+v", event)
+	}
+	if rec := do("GET", "/v1/status", created.Key, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a revoked key, got %d", rec.Code)
+	}
+	if event := <-events; event.Type != models.EventTypeAuthFailure {
+		t.Errorf("Expected an auth failure event, got %+v", event)
+	}
+	if rec := do("DELETE", "/v1/auth/keys/missing", admin, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown key, got %d", rec.Code)
+	}
+	if rec := do("POST", "/v1/auth/keys/"+created.ID+"/rotate", admin, nil); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 rotating a revoked key, got %d", rec.Code)
+	}
+
+	// Keys survive a restart, and so does their revocation
+	_, handler = newServer()
+	if rec := do("GET", "/v1/status", rotated.Key, nil); rec.Code == http.StatusUnauthorized {
+		t.Error("Expected the rotated key to work after a restart")
+	}
+	if rec := do("GET", "/v1/status", created.Key, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the revoked key to stay revoked, got %d", rec.Code)
+	}
+	rec = do("GET", "/v1/auth/keys/"+rotated.ID, admin, nil)
+	var info auth.APIKey
+	decode(rec, &info)
+	if info.LastUsedAt == nil {
+		t.Errorf("Expected the last use to be recorded: %+v", info)
+	}
+}
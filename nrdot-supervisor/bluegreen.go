@@ -0,0 +1,297 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// defaultBlueGreenPortOffset separates the listen ports of the two
+	// collector slots
+	defaultBlueGreenPortOffset = 1000
+
+	// defaultReloadHealthTimeout bounds how long a new collector may take
+	// to report healthy
+	defaultReloadHealthTimeout = 30 * time.Second
+
+	// collectorValidateTimeout bounds `otelcol validate`
+	collectorValidateTimeout = 30 * time.Second
+)
+
+// Listen endpoints used by collector components when their config leaves
+// them unset. They are made explicit so they can be moved to another slot;
+// only extensions listed here are moved.
+var (
+	defaultExtensionEndpoints = map[string]string{
+		"health_check": "0.0.0.0:13133",
+		"zpages":       "localhost:55679",
+		"pprof":        "localhost:1777",
+	}
+	defaultOTLPEndpoints = map[string]string{
+		"grpc": "0.0.0.0:4317",
+		"http": "0.0.0.0:4318",
+	}
+	defaultTelemetryMetricsAddress = ":8888"
+
+	// listeningReceivers accept data on their "endpoint" settings. Other
+	// receivers (redis, mysql, ...) use "endpoint" for the target they
+	// scrape, which must not move.
+	listeningReceivers = map[string]bool{
+		"otlp":          true,
+		"jaeger":        true,
+		"zipkin":        true,
+		"opencensus":    true,
+		"carbon":        true,
+		"statsd":        true,
+		"fluentforward": true,
+		"signalfx":      true,
+		"splunk_hec":    true,
+		"influxdb":      true,
+		"sapm":          true,
+		"skywalking":    true,
+		"loki":          true,
+	}
+)
+
+// blueGreenPortOffset returns the configured port shift between slots
+func (s *UnifiedSupervisor) blueGreenPortOffset() int {
+	if s.config.BlueGreenPortOffset > 0 {
+		return s.config.BlueGreenPortOffset
+	}
+	return defaultBlueGreenPortOffset
+}
+
+// reloadHealthTimeout returns how long a new collector may take to get healthy
+func (s *UnifiedSupervisor) reloadHealthTimeout() time.Duration {
+	if s.config.ReloadHealthTimeout > 0 {
+		return s.config.ReloadHealthTimeout
+	}
+	return defaultReloadHealthTimeout
+}
+
+// slotConfig rewrites a generated collector config to listen on the ports of
+// a blue-green slot: the endpoints of listening receivers and extensions and
+// the internal telemetry address are shifted by offset, so the new collector
+// can run next to the old one. A health_check extension is added if the
+// config lacks one. Returns the rewritten config and the health URL to probe.
+func slotConfig(otelConfig string, offset int) ([]byte, string, error) {
+	var cfg map[string]interface{}
+	if err := yaml.Unmarshal([]byte(otelConfig), &cfg); err != nil {
+		return nil, "", fmt.Errorf("failed to parse collector config: %w", err)
+	}
+	if cfg == nil {
+		return nil, "", fmt.Errorf("collector config is empty")
+	}
+
+	// Receivers
+	receivers := childMap(cfg, "receivers")
+	for name, raw := range receivers {
+		receiver, _ := raw.(map[string]interface{})
+		if !listeningReceivers[componentType(name)] {
+			continue
+		}
+		if componentType(name) == "otlp" && receiver != nil {
+			protocols := childMap(receiver, "protocols")
+			for protocol, defaultEndpoint := range defaultOTLPEndpoints {
+				if raw, ok := protocols[protocol]; ok {
+					settings, _ := raw.(map[string]interface{})
+					if settings == nil {
+						settings = map[string]interface{}{}
+						protocols[protocol] = settings
+					}
+					if _, ok := settings["endpoint"]; !ok {
+						settings["endpoint"] = defaultEndpoint
+					}
+				}
+			}
+		}
+		if err := shiftEndpoints(receiver, offset); err != nil {
+			return nil, "", fmt.Errorf("receiver %s: %w", name, err)
+		}
+	}
+
+	// Extensions, making sure health_check is present and enabled
+	extensions := childMap(cfg, "extensions")
+	healthName := ""
+	for name := range extensions {
+		if componentType(name) == "health_check" {
+			healthName = name
+			break
+		}
+	}
+	if healthName == "" {
+		healthName = "health_check"
+		extensions[healthName] = map[string]interface{}{}
+	}
+	for name, raw := range extensions {
+		defaultEndpoint, ok := defaultExtensionEndpoints[componentType(name)]
+		if !ok {
+			continue
+		}
+		extension, _ := raw.(map[string]interface{})
+		if extension == nil {
+			extension = map[string]interface{}{}
+			extensions[name] = extension
+		}
+		if _, ok := extension["endpoint"]; !ok {
+			extension["endpoint"] = defaultEndpoint
+		}
+		if err := shiftEndpoints(extension, offset); err != nil {
+			return nil, "", fmt.Errorf("extension %s: %w", name, err)
+		}
+	}
+
+	service := childMap(cfg, "service")
+	enabled, _ := service["extensions"].([]interface{})
+	if !containsValue(enabled, healthName) {
+		service["extensions"] = append(enabled, healthName)
+	}
+
+	// Internal telemetry
+	metrics := childMap(childMap(service, "telemetry"), "metrics")
+	address, _ := metrics["address"].(string)
+	if address == "" {
+		address = defaultTelemetryMetricsAddress
+	}
+	shifted, err := shiftPort(address, offset)
+	if err != nil {
+		return nil, "", fmt.Errorf("telemetry metrics address: %w", err)
+	}
+	metrics["address"] = shifted
+
+	healthURL, err := healthCheckURL(extensions[healthName].(map[string]interface{}))
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode collector config: %w", err)
+	}
+	return data, healthURL, nil
+}
+
+// healthCheckURL builds the URL probing a health_check extension
+func healthCheckURL(extension map[string]interface{}) (string, error) {
+	endpoint, _ := extension["endpoint"].(string)
+	path, _ := extension["path"].(string)
+	if path == "" {
+		path = "/"
+	}
+
+	url, err := localURL(endpoint, path)
+	if err != nil {
+		return "", fmt.Errorf("invalid health_check endpoint: %w", err)
+	}
+	return url, nil
+}
+
+// shiftEndpoints moves every host:port "endpoint" under node by offset
+func shiftEndpoints(node interface{}, offset int) error {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if endpoint, ok := value.(string); ok && key == "endpoint" {
+				shifted, err := shiftPort(endpoint, offset)
+				if err != nil {
+					return err
+				}
+				v[key] = shifted
+				continue
+			}
+			if err := shiftEndpoints(value, offset); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			if err := shiftEndpoints(value, offset); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// shiftPort adds offset to the port of a host:port address. Values that are
+// not host:port (e.g. URLs or unix sockets) are returned unchanged.
+func shiftPort(address string, offset int) (string, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return address, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return address, nil
+	}
+	if port+offset > 65535 {
+		return "", fmt.Errorf("port %d shifted by %d is out of range", port, offset)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port+offset)), nil
+}
+
+// childMap returns parent[key] as a map, creating it if missing or null
+func childMap(parent map[string]interface{}, key string) map[string]interface{} {
+	child, ok := parent[key].(map[string]interface{})
+	if !ok {
+		child = map[string]interface{}{}
+		parent[key] = child
+	}
+	return child
+}
+
+// componentType strips the instance name from a component ID, e.g.
+// "otlp/internal" -> "otlp"
+func componentType(id string) string {
+	return strings.SplitN(id, "/", 2)[0]
+}
+
+func containsValue(values []interface{}, want string) bool {
+	for _, v := range values {
+		if s, ok := v.(string); ok && s == want {
+			return true
+		}
+	}
+	return false
+}
+
+// collectorRejectedError is a config `otelcol validate` rejected, with the
+// command's output
+type collectorRejectedError struct {
+	err    error
+	output string
+}
+
+func (e *collectorRejectedError) Error() string {
+	return fmt.Sprintf("collector rejected config: %v: %s", e.err, e.output)
+}
+
+func (e *collectorRejectedError) Unwrap() error {
+	return e.err
+}
+
+// validateCollectorConfig runs `<binary> validate --config <path>` so a config
+// the collector rejects never replaces a running one. A rejection is a
+// *collectorRejectedError; failing to run the command is not.
+func validateCollectorConfig(ctx context.Context, binary, configPath string) error {
+	ctx, cancel := context.WithTimeout(ctx, collectorValidateTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, binary, "validate", "--config", configPath).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if ctx.Err() != nil || !errors.As(err, &exitErr) {
+		return fmt.Errorf("running collector validate: %w", err)
+	}
+	return &collectorRejectedError{err: err, output: strings.TrimSpace(string(output))}
+}
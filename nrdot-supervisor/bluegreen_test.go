@@ -0,0 +1,267 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	configengine "github.com/newrelic/nrdot-host/nrdot-config-engine"
+	"go.uber.org/zap/zaptest"
+	"gopkg.in/yaml.v3"
+)
+
+const testCollectorConfig = `
+receivers:
+  otlp:
+    protocols:
+      grpc:
+      http:
+        endpoint: 127.0.0.1:4318
+  redis:
+    endpoint: localhost:6379
+  hostmetrics:
+    collection_interval: 30s
+exporters:
+  otlp/newrelic:
+    endpoint: https://otlp.nr-data.net:4317
+extensions:
+  zpages: {}
+service:
+  extensions: [zpages]
+  pipelines:
+    metrics:
+      receivers: [otlp, redis, hostmetrics]
+      exporters: [otlp/newrelic]
+`
+
+func TestSlotConfig(t *testing.T) {
+	data, healthURL, err := slotConfig(testCollectorConfig, 1000)
+	if err != nil {
+		t.Fatalf("Failed to build slot config: %v", err)
+	}
+	if healthURL != "http://localhost:14133/" {
+		t.Errorf("Unexpected health URL: %s", healthURL)
+	}
+
+	var cfg map[string]interface{}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("Slot config is not valid YAML: %v", err)
+	}
+
+	get := func(path string) interface{} {
+		var node interface{} = cfg
+		for _, key := range strings.Split(path, ".") {
+			m, ok := node.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			node = m[key]
+		}
+		return node
+	}
+
+	expected := map[string]string{
+		// Listening endpoints move, including defaults
+		"receivers.otlp.protocols.grpc.endpoint": "0.0.0.0:5317",
+		"receivers.otlp.protocols.http.endpoint": "127.0.0.1:5318",
+		"extensions.zpages.endpoint":             "localhost:56679",
+		"extensions.health_check.endpoint":       "0.0.0.0:14133",
+		"service.telemetry.metrics.address":      ":9888",
+		// Scrape targets and exporters do not
+		"receivers.redis.endpoint":         "localhost:6379",
+		"exporters.otlp/newrelic.endpoint": "https://otlp.nr-data.net:4317",
+	}
+	for path, want := range expected {
+		if got := get(path); got != want {
+			t.Errorf("%s: expected %q, got %v", path, want, got)
+		}
+	}
+
+	extensions := fmt.Sprint(get("service.extensions"))
+	if extensions != "[zpages health_check]" {
+		t.Errorf("Expected health_check to be enabled, got %s", extensions)
+	}
+}
+
+func TestSlotConfig_Errors(t *testing.T) {
+	if _, _, err := slotConfig("", 1000); err == nil {
+		t.Error("Expected error for empty config")
+	}
+	if _, _, err := slotConfig("receivers: [", 1000); err == nil {
+		t.Error("Expected error for invalid YAML")
+	}
+	if _, _, err := slotConfig(testCollectorConfig, 60000); err == nil {
+		t.Error("Expected error for port out of range")
+	}
+}
+
+// blueGreenFixture is a supervisor running a fake collector in slot 0. The
+// fake collector accepts `validate` unless the config contains "invalid" and
+// otherwise sleeps; the test serves the health endpoint of slot 1.
+type blueGreenFixture struct {
+	supervisor *UnifiedSupervisor
+	strategy   *BlueGreenReloadStrategy
+	healthy    bool
+}
+
+func newBlueGreenFixture(t *testing.T) *blueGreenFixture {
+	t.Helper()
+	dir := t.TempDir()
+	logger := zaptest.NewLogger(t)
+
+	binary := filepath.Join(dir, "otelcol")
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = validate ]; then\n" +
+		"  if grep -q invalid \"$3\"; then echo 'invalid keys' >&2; exit 1; fi\n" +
+		"  exit 0\n" +
+		"fi\n" +
+		"exec sleep 30\n"
+	if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write collector script: %v", err)
+	}
+
+	f := &blueGreenFixture{healthy: true}
+
+	// Serve the health endpoint the slot 1 collector will be probed on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"status":"Server available"}`)
+	}))
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	healthPort := listener.Addr().(*net.TCPAddr).Port
+
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		CollectorPath:       binary,
+		WorkDir:             dir,
+		BlueGreenPortOffset: healthPort - 1,
+		ReloadHealthTimeout: 2 * time.Second,
+		Logger:              logger,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+
+	f.supervisor = s
+	f.strategy = s.reloadStrategy.(*BlueGreenReloadStrategy)
+	f.strategy.generate = func(ctx context.Context) (string, error) {
+		return "extensions:\n  health_check:\n    endpoint: 127.0.0.1:1\n", nil
+	}
+
+	// The running (blue) collector
+	s.collector = NewCollectorProcess(CollectorConfig{BinaryPath: binary, ConfigPath: "/dev/null"}, logger)
+	if err := s.collector.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start collector: %v", err)
+	}
+	s.status.State = models.CollectorStateRunning
+	s.status.ConfigVersion = 1
+
+	t.Cleanup(func() {
+		s.mu.RLock()
+		collector := s.collector
+		s.mu.RUnlock()
+		collector.Stop(context.Background())
+	})
+	return f
+}
+
+func TestBlueGreenReload_Swap(t *testing.T) {
+	f := newBlueGreenFixture(t)
+	s := f.supervisor
+	oldCollector := s.collector
+
+	result, err := s.ReloadCollector(context.Background(), models.ReloadStrategyBlueGreen)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if !result.Success || result.OldVersion != 1 || result.NewVersion != 2 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	if s.collector == oldCollector || !s.collector.IsRunning() {
+		t.Error("Expected new collector to be active")
+	}
+	if oldCollector.IsRunning() {
+		t.Error("Expected old collector to be stopped")
+	}
+	if s.portSlot != 1 {
+		t.Errorf("Expected slot 1, got %d", s.portSlot)
+	}
+
+	// The hash identifies the generated config, not its slot rewrite
+	wantHash := configengine.HashOTelConfig("extensions:\n  health_check:\n    endpoint: 127.0.0.1:1\n")
+	if s.status.ConfigHash != wantHash {
+		t.Errorf("Expected config hash %s, got %s", wantHash, s.status.ConfigHash)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.config.WorkDir, "config-green.yaml"))
+	if err != nil {
+		t.Fatalf("Expected green config: %v", err)
+	}
+	wantEndpoint := "127.0.0.1:" + strconv.Itoa(1+s.blueGreenPortOffset())
+	if !strings.Contains(string(data), wantEndpoint) {
+		t.Errorf("Expected health endpoint %s in green config:\n%s", wantEndpoint, data)
+	}
+}
+
+func TestBlueGreenReload_UnhealthyRollsBack(t *testing.T) {
+	f := newBlueGreenFixture(t)
+	f.healthy = false
+	s := f.supervisor
+	oldCollector := s.collector
+
+	result, err := s.ReloadCollector(context.Background(), models.ReloadStrategyBlueGreen)
+	if err == nil {
+		t.Fatal("Expected reload to fail")
+	}
+	if result.Success || result.RollbackInfo == nil || !result.RollbackInfo.Triggered || !result.RollbackInfo.Success {
+		t.Errorf("Expected successful rollback, got %+v", result)
+	}
+	if s.collector != oldCollector || !oldCollector.IsRunning() {
+		t.Error("Expected old collector to keep running")
+	}
+	if s.status.ConfigVersion != 1 || s.portSlot != 0 {
+		t.Errorf("Reload state changed: version %d, slot %d", s.status.ConfigVersion, s.portSlot)
+	}
+}
+
+func TestBlueGreenReload_ValidationGate(t *testing.T) {
+	f := newBlueGreenFixture(t)
+	f.strategy.generate = func(ctx context.Context) (string, error) {
+		return "receivers:\n  invalid: {}\n", nil
+	}
+	s := f.supervisor
+	oldCollector := s.collector
+
+	result, err := s.ReloadCollector(context.Background(), models.ReloadStrategyBlueGreen)
+	if err == nil || !strings.Contains(err.Error(), "invalid keys") {
+		t.Fatalf("Expected validation error, got %v", err)
+	}
+	if result.Error == nil || result.Error.Code != models.ErrCodeConfigInvalid {
+		t.Errorf("Expected CONFIG_INVALID, got %+v", result.Error)
+	}
+	if s.collector != oldCollector || !oldCollector.IsRunning() {
+		t.Error("Expected old collector to keep running")
+	}
+	if _, err := os.Stat(filepath.Join(s.config.WorkDir, "config-green.yaml")); !os.IsNotExist(err) {
+		t.Error("Rejected config should be removed")
+	}
+}
@@ -0,0 +1,86 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"syscall"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+)
+
+// cardinalityReportSignal makes the collector's nrcap processors write a
+// cardinality report to their report.directory. Processors without one,
+// and collectors without nrcap, ignore it.
+var cardinalityReportSignal os.Signal = syscall.SIGUSR2
+
+var (
+	errCollectorNotRunning = errors.New("collector is not running")
+	errCollectorNoSignals  = errors.New("collector does not accept signals")
+)
+
+// signalingCollector is a Collector that can be signaled, like the default
+// collector process
+type signalingCollector interface {
+	Signal(sig os.Signal) error
+}
+
+// CardinalityReportResponse is the response to a cardinality report request
+type CardinalityReportResponse struct {
+	Status  string `json:"status"`
+	PID     int    `json:"pid"`
+	Message string `json:"message"`
+}
+
+// RequestCardinalityReport signals the running collector to write a
+// cardinality report and returns its process ID. The report is written
+// asynchronously by the collector.
+func (s *UnifiedSupervisor) RequestCardinalityReport() (int, error) {
+	s.mu.RLock()
+	collector := s.collector
+	s.mu.RUnlock()
+
+	if collector == nil || !collector.IsRunning() {
+		return 0, errCollectorNotRunning
+	}
+	signaler, ok := collector.(signalingCollector)
+	if !ok {
+		return 0, errCollectorNoSignals
+	}
+	if err := signaler.Signal(cardinalityReportSignal); err != nil {
+		return 0, fmt.Errorf("failed to signal collector: %w", err)
+	}
+
+	s.logger.Info("Requested cardinality report", zap.Int("pid", collector.Pid()))
+	return collector.Pid(), nil
+}
+
+// handleCardinalityReport serves POST /v1/control/cardinality-report
+func (s *UnifiedSupervisor) handleCardinalityReport(w http.ResponseWriter, r *http.Request) {
+	// Track API request
+	s.metrics.IncrementRequests()
+
+	pid, err := s.RequestCardinalityReport()
+	switch {
+	case errors.Is(err, errCollectorNotRunning):
+		problem.Error(w, r, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, errCollectorNoSignals):
+		problem.Error(w, r, err.Error(), http.StatusNotImplemented)
+		return
+	case err != nil:
+		problem.Error(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(CardinalityReportResponse{
+		Status:  "requested",
+		PID:     pid,
+		Message: "nrcap processors with report.directory set write the report there",
+	})
+}
@@ -0,0 +1,71 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// signaledCollector is a fake collector that records the signals it gets
+type signaledCollector struct {
+	fakeCollector
+	signalMu sync.Mutex
+	signals  []os.Signal
+}
+
+func (c *signaledCollector) Signal(sig os.Signal) error {
+	c.signalMu.Lock()
+	defer c.signalMu.Unlock()
+	c.signals = append(c.signals, sig)
+	return nil
+}
+
+func TestHandleCardinalityReport(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		APIEnabled: true,
+		Logger:     zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	handler := s.apiServer.Handler
+
+	request := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/control/cardinality-report", nil))
+		return rec
+	}
+
+	// No collector running
+	if rec := request(); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 without a collector, got %d", rec.Code)
+	}
+
+	// A collector that cannot be signaled
+	s.collector = &fakeCollector{pid: 1000, running: true}
+	if rec := request(); rec.Code != http.StatusNotImplemented {
+		t.Errorf("Expected 501 for a collector without signals, got %d", rec.Code)
+	}
+
+	collector := &signaledCollector{fakeCollector: fakeCollector{pid: 1001, running: true}}
+	s.collector = collector
+	rec := request()
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp CardinalityReportResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Status != "requested" || resp.PID != 1001 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+	if len(collector.signals) != 1 || collector.signals[0] != cardinalityReportSignal {
+		t.Errorf("Expected one report signal, got %v", collector.signals)
+	}
+}
@@ -0,0 +1,414 @@
+package supervisor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// cgroupCPUPeriod is the cpu.max period in microseconds
+	cgroupCPUPeriod = 100000
+
+	// cgroupPollInterval is how often the collector cgroups are checked for
+	// OOM kills and throttling
+	cgroupPollInterval = 5 * time.Second
+
+	// supervisorCgroupName holds the supervisor itself when it has to leave
+	// the parent cgroup, which may not contain processes once controllers
+	// are enabled for its children
+	supervisorCgroupName = "supervisor"
+)
+
+// ResourceLimits configures cgroup v2 enforcement for the collector. It is
+// Linux only; elsewhere, or when the cgroup cannot be set up, the collector
+// runs unconstrained and a warning is logged.
+type ResourceLimits struct {
+	MemoryMax uint64  // bytes, 0 for no limit
+	CPUMax    float64 // CPU cores, e.g. 1.5, 0 for no limit
+
+	// CgroupParent is the cgroup directory the collector cgroups are created
+	// under, defaults to the supervisor's own cgroup
+	CgroupParent string
+}
+
+// enabled reports whether any limit is set
+func (l ResourceLimits) enabled() bool {
+	return l.MemoryMax > 0 || l.CPUMax > 0
+}
+
+// cgroupStats is a snapshot of a collector cgroup's counters
+type cgroupStats struct {
+	MemoryCurrent   uint64 // memory.current
+	MemoryMaxEvents uint64 // memory.events max: usage hit memory.max
+	OOMKills        uint64 // memory.events oom_kill
+	CPUPeriods      uint64 // cpu.stat nr_periods
+	CPUThrottled    uint64 // cpu.stat nr_throttled
+}
+
+// since returns the counter increments from prev; counters that went
+// backwards (the cgroup was recreated) count from zero
+func (c cgroupStats) since(prev cgroupStats) cgroupStats {
+	delta := func(cur, old uint64) uint64 {
+		if cur < old {
+			return cur
+		}
+		return cur - old
+	}
+	return cgroupStats{
+		MemoryCurrent:   c.MemoryCurrent,
+		MemoryMaxEvents: delta(c.MemoryMaxEvents, prev.MemoryMaxEvents),
+		OOMKills:        delta(c.OOMKills, prev.OOMKills),
+		CPUPeriods:      delta(c.CPUPeriods, prev.CPUPeriods),
+		CPUThrottled:    delta(c.CPUThrottled, prev.CPUThrottled),
+	}
+}
+
+// collectorCgroups manages one cgroup per blue-green slot, so the collector
+// started during a reload gets its own budget while the old one drains
+type collectorCgroups struct {
+	parent string
+	limits ResourceLimits
+
+	mu   sync.Mutex
+	last [2]cgroupStats
+}
+
+// newCollectorCgroups creates the collector cgroups under parent and applies
+// limits to them
+func newCollectorCgroups(parent string, limits ResourceLimits) (*collectorCgroups, error) {
+	// cgroup v2 lists the controllers available to a group in cgroup.controllers
+	data, err := os.ReadFile(filepath.Join(parent, "cgroup.controllers"))
+	if err != nil {
+		return nil, fmt.Errorf("cgroup v2 not available at %s: %w", parent, err)
+	}
+	available := strings.Fields(string(data))
+	for _, controller := range requiredControllers(limits) {
+		if !containsString(available, controller) {
+			return nil, fmt.Errorf("cgroup controller %q not delegated to %s", controller, parent)
+		}
+	}
+
+	if err := enableControllers(parent, requiredControllers(limits)); err != nil {
+		return nil, err
+	}
+
+	c := &collectorCgroups{parent: parent, limits: limits}
+	for slot := 0; slot < 2; slot++ {
+		dir := c.dir(slot)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("creating cgroup %s: %w", dir, err)
+		}
+		if err := writeCgroupLimits(dir, limits); err != nil {
+			return nil, err
+		}
+		// Counters survive supervisor restarts; only report new events
+		c.last[slot], _ = readCgroupStats(dir)
+	}
+	return c, nil
+}
+
+// dir returns the cgroup directory of a port slot
+func (c *collectorCgroups) dir(slot int) string {
+	return filepath.Join(c.parent, "collector-"+slotColor(slot))
+}
+
+// sample reads a slot's counters and returns them as increments since the
+// previous sample
+func (c *collectorCgroups) sample(slot int) (cgroupStats, error) {
+	stats, err := readCgroupStats(c.dir(slot))
+	if err != nil {
+		return cgroupStats{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delta := stats.since(c.last[slot])
+	c.last[slot] = stats
+	return delta, nil
+}
+
+// requiredControllers returns the controllers needed for limits
+func requiredControllers(limits ResourceLimits) []string {
+	var controllers []string
+	if limits.MemoryMax > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if limits.CPUMax > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	return controllers
+}
+
+// enableControllers enables controllers for the children of parent. The
+// kernel refuses that while parent itself holds processes, in which case
+// they are first moved into a supervisor leaf cgroup.
+func enableControllers(parent string, controllers []string) error {
+	var value []string
+	for _, controller := range controllers {
+		value = append(value, "+"+controller)
+	}
+	control := filepath.Join(parent, "cgroup.subtree_control")
+
+	err := writeCgroupFile(control, strings.Join(value, " "))
+	if err == nil || !errors.Is(err, syscall.EBUSY) {
+		return err
+	}
+
+	if err := moveProcesses(parent, filepath.Join(parent, supervisorCgroupName)); err != nil {
+		return err
+	}
+	return writeCgroupFile(control, strings.Join(value, " "))
+}
+
+// moveProcesses moves every process of cgroup from into cgroup to
+func moveProcesses(from, to string) error {
+	if err := os.MkdirAll(to, 0755); err != nil {
+		return fmt.Errorf("creating cgroup %s: %w", to, err)
+	}
+	data, err := os.ReadFile(filepath.Join(from, "cgroup.procs"))
+	if err != nil {
+		return fmt.Errorf("reading cgroup processes: %w", err)
+	}
+	for _, pid := range strings.Fields(string(data)) {
+		if err := writeCgroupFile(filepath.Join(to, "cgroup.procs"), pid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCgroupLimits sets memory.max and cpu.max of a cgroup
+func writeCgroupLimits(dir string, limits ResourceLimits) error {
+	if limits.MemoryMax > 0 {
+		if err := writeCgroupFile(filepath.Join(dir, "memory.max"), strconv.FormatUint(limits.MemoryMax, 10)); err != nil {
+			return err
+		}
+		// Without this the limit only covers RAM and the collector swaps
+		swapMax := filepath.Join(dir, "memory.swap.max")
+		if _, err := os.Stat(swapMax); err == nil {
+			if err := writeCgroupFile(swapMax, "0"); err != nil {
+				return err
+			}
+		}
+	}
+	if limits.CPUMax > 0 {
+		quota := int64(limits.CPUMax * cgroupCPUPeriod)
+		if quota < 1000 {
+			quota = 1000 // kernel minimum
+		}
+		if err := writeCgroupFile(filepath.Join(dir, "cpu.max"), fmt.Sprintf("%d %d", quota, cgroupCPUPeriod)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachToCgroup moves a process into the cgroup at dir
+func attachToCgroup(dir string, pid int) error {
+	return writeCgroupFile(filepath.Join(dir, "cgroup.procs"), strconv.Itoa(pid))
+}
+
+// writeCgroupFile writes a single value to a cgroup interface file
+func writeCgroupFile(path, value string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(value); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// readCgroupStats reads the memory and CPU counters of a cgroup. Files of
+// controllers that are not enabled are skipped.
+func readCgroupStats(dir string) (cgroupStats, error) {
+	var stats cgroupStats
+
+	if data, err := os.ReadFile(filepath.Join(dir, "memory.current")); err == nil {
+		stats.MemoryCurrent, _ = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	} else if !os.IsNotExist(err) {
+		return stats, fmt.Errorf("reading memory.current: %w", err)
+	}
+
+	events, err := readCgroupKeyValues(filepath.Join(dir, "memory.events"))
+	if err != nil {
+		return stats, err
+	}
+	stats.MemoryMaxEvents = events["max"]
+	stats.OOMKills = events["oom_kill"]
+
+	cpu, err := readCgroupKeyValues(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return stats, err
+	}
+	stats.CPUPeriods = cpu["nr_periods"]
+	stats.CPUThrottled = cpu["nr_throttled"]
+
+	return stats, nil
+}
+
+// readCgroupKeyValues parses a flat keyed file such as memory.events; a
+// missing file yields no values
+func readCgroupKeyValues(path string) (map[string]uint64, error) {
+	values := make(map[string]uint64)
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filepath.Base(path), err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	}
+	return values, scanner.Err()
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// setupCollectorCgroups enables resource enforcement if limits are
+// configured. Failures are not fatal: the collector runs unconstrained.
+func (s *UnifiedSupervisor) setupCollectorCgroups() {
+	limits := s.config.Resources
+	if !limits.enabled() {
+		return
+	}
+
+	parent := limits.CgroupParent
+	if parent == "" {
+		var err error
+		parent, err = defaultCgroupParent()
+		if err != nil {
+			s.logger.Warn("Collector resource limits not enforced", zap.Error(err))
+			return
+		}
+	}
+
+	cgroups, err := newCollectorCgroups(parent, limits)
+	if err != nil {
+		s.logger.Warn("Collector resource limits not enforced", zap.Error(err))
+		return
+	}
+	s.cgroups = cgroups
+	s.metrics.SetCollectorLimits(limits.MemoryMax, limits.CPUMax)
+
+	s.logger.Info("Collector resource limits enforced",
+		zap.String("cgroup", parent),
+		zap.Uint64("memoryMax", limits.MemoryMax),
+		zap.Float64("cpuMax", limits.CPUMax))
+}
+
+// collectorCgroupDir returns the cgroup for the collector of a port slot, or
+// "" when limits are not enforced
+func (s *UnifiedSupervisor) collectorCgroupDir(slot int) string {
+	if s.cgroups == nil {
+		return ""
+	}
+	return s.cgroups.dir(slot)
+}
+
+// resourceMonitorLoop watches the collector cgroups for limit violations
+func (s *UnifiedSupervisor) resourceMonitorLoop(ctx context.Context) {
+	ticker := time.NewTicker(cgroupPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkResourceLimits()
+		}
+	}
+}
+
+// checkResourceLimits turns cgroup counter increments into events and
+// metrics. OOM kills are always reported; memory.max hits and heavy CPU
+// throttling are reported when the collector starts and stops hitting its
+// limits.
+func (s *UnifiedSupervisor) checkResourceLimits() {
+	s.mu.RLock()
+	activeSlot := s.portSlot
+	wasLimited := s.resourceLimited
+	s.mu.RUnlock()
+
+	var limited []string
+	for slot := 0; slot < 2; slot++ {
+		delta, err := s.cgroups.sample(slot)
+		if err != nil {
+			s.logger.Debug("Failed to read collector cgroup", zap.Error(err))
+			continue
+		}
+
+		s.metrics.AddCollectorLimitEvents(delta.OOMKills, delta.MemoryMaxEvents, delta.CPUThrottled)
+		if slot == activeSlot {
+			s.metrics.SetCollectorMemory(delta.MemoryCurrent)
+		}
+
+		if delta.OOMKills > 0 {
+			s.recordEvent(models.EventTypeResourceExhausted, models.EventSeverityCritical,
+				"Collector killed by the OOM killer",
+				fmt.Sprintf("%d OOM kill(s) in %s, memory limit %d bytes", delta.OOMKills, s.cgroups.dir(slot), s.cgroups.limits.MemoryMax))
+		}
+		if slot != activeSlot {
+			continue
+		}
+		if delta.MemoryMaxEvents > 0 {
+			limited = append(limited, fmt.Sprintf("memory limit reached %d time(s), usage %d of %d bytes",
+				delta.MemoryMaxEvents, delta.MemoryCurrent, s.cgroups.limits.MemoryMax))
+		}
+		// Occasional throttling is normal; report it when most periods are
+		if delta.CPUPeriods > 0 && delta.CPUThrottled*2 >= delta.CPUPeriods {
+			limited = append(limited, fmt.Sprintf("CPU throttled in %d of %d periods, limit %.2f cores",
+				delta.CPUThrottled, delta.CPUPeriods, s.cgroups.limits.CPUMax))
+		}
+	}
+
+	isLimited := len(limited) > 0
+	if isLimited == wasLimited {
+		return
+	}
+	s.mu.Lock()
+	s.resourceLimited = isLimited
+	s.mu.Unlock()
+
+	if isLimited {
+		s.recordEvent(models.EventTypeResourceHigh, models.EventSeverityWarning,
+			"Collector at its resource limits", strings.Join(limited, "; "))
+	} else {
+		s.recordEvent(models.EventTypeResourceNormal, models.EventSeverityInfo,
+			"Collector back within its resource limits", "")
+	}
+}
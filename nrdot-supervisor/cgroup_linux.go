@@ -0,0 +1,41 @@
+// +build linux
+
+package supervisor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cgroupRoot is where the cgroup v2 hierarchy is mounted
+const cgroupRoot = "/sys/fs/cgroup"
+
+// defaultCgroupParent returns the supervisor's own cgroup v2 directory, e.g.
+// /sys/fs/cgroup/system.slice/nrdot-host.service
+func defaultCgroupParent() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", fmt.Errorf("reading own cgroup: %w", err)
+	}
+	return parseCgroupParent(string(data))
+}
+
+// parseCgroupParent extracts the unified hierarchy path from the contents of
+// /proc/self/cgroup
+func parseCgroupParent(data string) (string, error) {
+	for _, line := range strings.Split(data, "\n") {
+		// The unified hierarchy is the "0::<path>" entry
+		if !strings.HasPrefix(line, "0::") {
+			continue
+		}
+		dir := filepath.Join(cgroupRoot, strings.TrimPrefix(line, "0::"))
+		// A previous run already moved the supervisor into its leaf
+		if filepath.Base(dir) == supervisorCgroupName {
+			dir = filepath.Dir(dir)
+		}
+		return dir, nil
+	}
+	return "", fmt.Errorf("cgroup v2 not in use (no unified hierarchy entry in /proc/self/cgroup)")
+}
@@ -0,0 +1,31 @@
+// +build linux
+
+package supervisor
+
+import "testing"
+
+func TestParseCgroupParent(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"0::/system.slice/nrdot-host.service\n", "/sys/fs/cgroup/system.slice/nrdot-host.service"},
+		{"0::/system.slice/nrdot-host.service/supervisor\n", "/sys/fs/cgroup/system.slice/nrdot-host.service"},
+		{"0::/\n", "/sys/fs/cgroup"},
+	}
+	for _, tt := range tests {
+		got, err := parseCgroupParent(tt.data)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.data, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.data, got, tt.want)
+		}
+	}
+
+	// cgroup v1 only
+	if _, err := parseCgroupParent("12:memory:/user.slice\n1:name=systemd:/user.slice\n"); err == nil {
+		t.Error("Expected error without a unified hierarchy")
+	}
+}
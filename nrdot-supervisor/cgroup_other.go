@@ -0,0 +1,10 @@
+// +build !linux
+
+package supervisor
+
+import "fmt"
+
+// defaultCgroupParent is only supported on Linux
+func defaultCgroupParent() (string, error) {
+	return "", fmt.Errorf("collector resource limits require Linux cgroup v2")
+}
@@ -0,0 +1,188 @@
+package supervisor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeCgroupParent creates a directory that looks like a delegated cgroup v2
+// group with the memory and cpu controllers
+func fakeCgroupParent(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.controllers"), []byte("cpuset cpu io memory pids\n"), 0644); err != nil {
+		t.Fatalf("Failed to write cgroup.controllers: %v", err)
+	}
+	return dir
+}
+
+func writeCgroupTestFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
+
+func readCgroupTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestNewCollectorCgroups_WritesLimits(t *testing.T) {
+	parent := fakeCgroupParent(t)
+
+	cgroups, err := newCollectorCgroups(parent, ResourceLimits{MemoryMax: 256 << 20, CPUMax: 1.5})
+	if err != nil {
+		t.Fatalf("Failed to set up cgroups: %v", err)
+	}
+
+	if got := readCgroupTestFile(t, filepath.Join(parent, "cgroup.subtree_control")); got != "+memory +cpu" {
+		t.Errorf("subtree_control = %q", got)
+	}
+	for slot := 0; slot < 2; slot++ {
+		dir := cgroups.dir(slot)
+		if got := readCgroupTestFile(t, filepath.Join(dir, "memory.max")); got != "268435456" {
+			t.Errorf("%s memory.max = %q", dir, got)
+		}
+		if got := readCgroupTestFile(t, filepath.Join(dir, "cpu.max")); got != "150000 100000" {
+			t.Errorf("%s cpu.max = %q", dir, got)
+		}
+	}
+
+	if err := attachToCgroup(cgroups.dir(1), 4242); err != nil {
+		t.Fatalf("Failed to attach: %v", err)
+	}
+	if got := readCgroupTestFile(t, filepath.Join(parent, "collector-green", "cgroup.procs")); got != "4242" {
+		t.Errorf("cgroup.procs = %q", got)
+	}
+}
+
+func TestNewCollectorCgroups_MissingController(t *testing.T) {
+	parent := t.TempDir()
+	writeCgroupTestFile(t, parent, "cgroup.controllers", "cpu pids\n")
+
+	if _, err := newCollectorCgroups(parent, ResourceLimits{MemoryMax: 1 << 30}); err == nil {
+		t.Error("Expected error without the memory controller")
+	}
+	// Only the controllers for the configured limits are required
+	if _, err := newCollectorCgroups(parent, ResourceLimits{CPUMax: 2}); err != nil {
+		t.Errorf("Unexpected error for CPU-only limits: %v", err)
+	}
+	if _, err := newCollectorCgroups(t.TempDir(), ResourceLimits{CPUMax: 2}); err == nil {
+		t.Error("Expected error without cgroup v2")
+	}
+}
+
+func TestCollectorCgroups_Sample(t *testing.T) {
+	parent := fakeCgroupParent(t)
+	cgroups, err := newCollectorCgroups(parent, ResourceLimits{MemoryMax: 1 << 30, CPUMax: 1})
+	if err != nil {
+		t.Fatalf("Failed to set up cgroups: %v", err)
+	}
+	dir := cgroups.dir(0)
+
+	writeCgroupTestFile(t, dir, "memory.current", "1048576\n")
+	writeCgroupTestFile(t, dir, "memory.events", "low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n")
+	writeCgroupTestFile(t, dir, "cpu.stat", "usage_usec 100\nnr_periods 50\nnr_throttled 10\nthrottled_usec 900\n")
+
+	delta, err := cgroups.sample(0)
+	if err != nil {
+		t.Fatalf("Failed to sample: %v", err)
+	}
+	want := cgroupStats{MemoryCurrent: 1048576, MemoryMaxEvents: 3, OOMKills: 1, CPUPeriods: 50, CPUThrottled: 10}
+	if delta != want {
+		t.Errorf("First sample = %+v, want %+v", delta, want)
+	}
+
+	writeCgroupTestFile(t, dir, "memory.events", "low 0\nhigh 0\nmax 3\noom 1\noom_kill 2\n")
+	writeCgroupTestFile(t, dir, "cpu.stat", "usage_usec 200\nnr_periods 70\nnr_throttled 10\nthrottled_usec 900\n")
+
+	delta, err = cgroups.sample(0)
+	if err != nil {
+		t.Fatalf("Failed to sample: %v", err)
+	}
+	want = cgroupStats{MemoryCurrent: 1048576, OOMKills: 1, CPUPeriods: 20}
+	if delta != want {
+		t.Errorf("Second sample = %+v, want %+v", delta, want)
+	}
+}
+
+func TestCheckResourceLimits(t *testing.T) {
+	parent := fakeCgroupParent(t)
+	limits := ResourceLimits{MemoryMax: 1 << 30, CPUMax: 1}
+	cgroups, err := newCollectorCgroups(parent, limits)
+	if err != nil {
+		t.Fatalf("Failed to set up cgroups: %v", err)
+	}
+
+	s := &UnifiedSupervisor{
+		logger:  zaptest.NewLogger(t),
+		metrics: NewMetricsCollector(),
+		cgroups: cgroups,
+	}
+	s.metrics.SetCollectorLimits(limits.MemoryMax, limits.CPUMax)
+
+	dir := cgroups.dir(0)
+	writeCgroupTestFile(t, dir, "memory.current", "1073741824\n")
+	writeCgroupTestFile(t, dir, "memory.events", "max 5\noom_kill 1\n")
+	writeCgroupTestFile(t, dir, "cpu.stat", "nr_periods 10\nnr_throttled 8\n")
+
+	s.checkResourceLimits()
+	if !s.resourceLimited {
+		t.Error("Collector should be reported at its limits")
+	}
+
+	metrics := map[string]float64{}
+	for _, m := range s.metrics.GetCustomMetrics() {
+		metrics[m.Name] = m.Value
+	}
+	for name, want := range map[string]float64{
+		"nrdot_collector_oom_kills_total":             1,
+		"nrdot_collector_memory_limit_hits_total":     5,
+		"nrdot_collector_cpu_throttled_periods_total": 8,
+		"nrdot_collector_memory_bytes":                1 << 30,
+		"nrdot_collector_memory_limit_bytes":          1 << 30,
+	} {
+		if metrics[name] != want {
+			t.Errorf("%s = %v, want %v", name, metrics[name], want)
+		}
+	}
+
+	// No new limit events: back to normal
+	writeCgroupTestFile(t, dir, "cpu.stat", "nr_periods 30\nnr_throttled 9\n")
+	s.checkResourceLimits()
+	if s.resourceLimited {
+		t.Error("Collector should be back within its limits")
+	}
+}
+
+func TestCollectorProcess_AttachesToCgroup(t *testing.T) {
+	dir := t.TempDir()
+
+	config := DefaultCollectorConfig()
+	config.BinaryPath = "sleep"
+	config.Args = []string{"30"}
+	config.CgroupDir = dir
+
+	collector := NewCollectorProcess(config, zaptest.NewLogger(t))
+	collector.args = []string{"30"}
+	collector.configPath = ""
+	if err := collector.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start collector: %v", err)
+	}
+	defer collector.Stop(context.Background())
+
+	pid := strings.TrimSpace(readCgroupTestFile(t, filepath.Join(dir, "cgroup.procs")))
+	if pid == "" || pid == "0" {
+		t.Errorf("Expected collector pid in cgroup.procs, got %q", pid)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/tracecontext"
 	"go.uber.org/zap"
 )
 
@@ -28,6 +29,15 @@ type CollectorProcess struct {
 	stderr          io.ReadCloser
 	memoryLimit     uint64 // in bytes
 	shutdownTimeout time.Duration
+	outputHandler   func(stream, line string)
+	cgroupDir       string
+	exit            *processExit
+}
+
+// processExit records how a started collector process ended.
+type processExit struct {
+	done chan struct{} // closed once the process has been reaped
+	err  error         // written before done is closed
 }
 
 // CollectorConfig holds collector process configuration
@@ -39,6 +49,8 @@ type CollectorConfig struct {
 	WorkDir         string
 	MemoryLimit     uint64
 	ShutdownTimeout time.Duration
+	OutputHandler   func(stream, line string) // receives stdout/stderr lines instead of the logger
+	CgroupDir       string                    // cgroup v2 directory the process is moved into, if set
 }
 
 // DefaultCollectorConfig returns default collector configuration
@@ -65,6 +77,8 @@ func NewCollectorProcess(config CollectorConfig, logger *zap.Logger) *CollectorP
 		logger:          logger,
 		memoryLimit:     config.MemoryLimit,
 		shutdownTimeout: config.ShutdownTimeout,
+		outputHandler:   config.OutputHandler,
+		cgroupDir:       config.CgroupDir,
 	}
 }
 
@@ -74,14 +88,19 @@ func (c *CollectorProcess) Start(ctx context.Context) error {
 	defer c.mu.Unlock()
 
 	if c.cmd != nil && c.cmd.Process != nil {
-		return fmt.Errorf("collector process already running")
+		if !c.hasExited() {
+			return fmt.Errorf("collector process already running")
+		}
+		// The previous process crashed; its exit has already been reaped
+		c.cmd = nil
 	}
 
 	// Build command arguments
 	args := append([]string{"--config", c.configPath}, c.args...)
 	
 	cmd := exec.CommandContext(ctx, c.binaryPath, args...)
-	cmd.Env = c.env
+	// The collector continues the trace of the operation starting it
+	cmd.Env = tracecontext.Env(ctx, c.env)
 	cmd.Dir = c.workDir
 	
 	// Set process group to enable killing all child processes
@@ -109,15 +128,35 @@ func (c *CollectorProcess) Start(ctx context.Context) error {
 	}
 
 	c.cmd = cmd
-	c.logger.Info("Collector process started",
+
+	// Move into the resource-limited cgroup; the collector has barely
+	// started, so its allocations are charged there
+	if c.cgroupDir != "" {
+		if err := attachToCgroup(c.cgroupDir, cmd.Process.Pid); err != nil {
+			c.logger.Warn("Failed to apply collector resource limits", zap.Error(err))
+		}
+	}
+
+	// Start log readers
+	var logs sync.WaitGroup
+	logs.Add(2)
+	go func() {
+		defer logs.Done()
+		c.readLogs("stdout", stdout)
+	}()
+	go func() {
+		defer logs.Done()
+		c.readLogs("stderr", stderr)
+	}()
+
+	c.exit = &processExit{done: make(chan struct{})}
+	go c.reap(cmd, &logs, c.exit)
+
+	c.logger.Info("Collector process started", append(tracecontext.Fields(ctx),
 		zap.Int("pid", cmd.Process.Pid),
 		zap.String("binary", c.binaryPath),
 		zap.String("config", c.configPath),
-	)
-
-	// Start log readers
-	go c.readLogs("stdout", c.stdout)
-	go c.readLogs("stderr", c.stderr)
+	)...)
 
 	return nil
 }
@@ -140,27 +179,29 @@ func (c *CollectorProcess) Stop(ctx context.Context) error {
 		c.logger.Warn("Failed to send SIGTERM", zap.Error(err))
 	}
 
-	// Wait for graceful shutdown or timeout
-	done := make(chan error, 1)
-	go func() {
-		done <- c.cmd.Wait()
-	}()
+	// A deadline on ctx replaces the shutdown timeout, so callers can give
+	// the collector longer to flush, e.g. when the host shuts down
+	var timeout <-chan time.Time
+	if _, ok := ctx.Deadline(); !ok {
+		timeout = time.After(c.shutdownTimeout)
+	}
 
+	// Wait for graceful shutdown or timeout
 	select {
 	case <-ctx.Done():
 		// Context cancelled, force kill
 		c.logger.Warn("Context cancelled, force killing collector")
 		return c.forceKill()
-	case err := <-done:
+	case <-c.exit.done:
 		// Process exited
 		c.cmd = nil
-		if err != nil {
+		if err := c.exit.err; err != nil {
 			c.logger.Warn("Collector process exited with error", zap.Error(err))
 		} else {
 			c.logger.Info("Collector process stopped gracefully")
 		}
 		return nil
-	case <-time.After(c.shutdownTimeout):
+	case <-timeout:
 		// Timeout reached, force kill
 		c.logger.Warn("Graceful shutdown timeout exceeded, force killing collector")
 		return c.forceKill()
@@ -181,11 +222,40 @@ func (c *CollectorProcess) forceKill() error {
 		c.cmd.Process.Kill()
 	}
 
-	c.cmd.Wait()
+	<-c.exit.done
 	c.cmd = nil
 	return nil
 }
 
+// reap waits for cmd to exit so a crashed collector does not linger as a
+// zombie, then records the exit status. The log readers are drained first
+// since Wait closes the pipes. It does not take c.mu because Stop holds the
+// lock while waiting for the exit.
+func (c *CollectorProcess) reap(cmd *exec.Cmd, logs *sync.WaitGroup, exit *processExit) {
+	logs.Wait()
+	err := cmd.Wait()
+	exit.err = err
+	close(exit.done)
+
+	if err != nil {
+		c.logger.Warn("Collector process exited", zap.Int("pid", cmd.Process.Pid), zap.Error(err))
+	}
+}
+
+// hasExited reports whether the current process has been reaped. The caller
+// must hold c.mu.
+func (c *CollectorProcess) hasExited() bool {
+	if c.exit == nil {
+		return true
+	}
+	select {
+	case <-c.exit.done:
+		return true
+	default:
+		return false
+	}
+}
+
 // IsRunning returns whether the collector process is running
 func (c *CollectorProcess) IsRunning() bool {
 	c.mu.Lock()
@@ -195,28 +265,45 @@ func (c *CollectorProcess) IsRunning() bool {
 		return false
 	}
 
-	// Check if process is still alive
-	err := c.cmd.Process.Signal(syscall.Signal(0))
-	return err == nil
+	return !c.hasExited()
+}
+
+// Pid returns the collector process ID, or 0 if not started
+func (c *CollectorProcess) Pid() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cmd == nil || c.cmd.Process == nil {
+		return 0
+	}
+	return c.cmd.Process.Pid
+}
+
+// ConfigPath returns the config file the collector runs with
+func (c *CollectorProcess) ConfigPath() string {
+	return c.configPath
 }
 
 // Wait waits for the collector process to exit
 func (c *CollectorProcess) Wait() error {
 	c.mu.Lock()
 	cmd := c.cmd
+	exit := c.exit
 	c.mu.Unlock()
 
 	if cmd == nil {
 		return nil
 	}
 
-	err := cmd.Wait()
-	
+	<-exit.done
+
 	c.mu.Lock()
-	c.cmd = nil
+	if c.cmd == cmd {
+		c.cmd = nil
+	}
 	c.mu.Unlock()
 
-	return err
+	return exit.err
 }
 
 // Signal sends a signal to the collector process
@@ -236,8 +323,13 @@ func (c *CollectorProcess) readLogs(source string, reader io.ReadCloser) {
 	defer reader.Close()
 
 	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
 	for scanner.Scan() {
 		line := scanner.Text()
+		if c.outputHandler != nil {
+			c.outputHandler(source, line)
+			continue
+		}
 		c.logger.Info("Collector output",
 			zap.String("source", source),
 			zap.String("line", line),
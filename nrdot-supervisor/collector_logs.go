@@ -0,0 +1,506 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+)
+
+const (
+	// defaultCollectorLogMaxSize is the size at which collector.log rotates
+	defaultCollectorLogMaxSize = 10 * 1024 * 1024
+
+	// defaultCollectorLogMaxFiles is how many rotated files are kept
+	defaultCollectorLogMaxFiles = 5
+
+	// collectorLogBufferLines is how many recent lines are kept for tailing
+	collectorLogBufferLines = 5000
+
+	// collectorLogFileName is the active log file under <workdir>/logs
+	collectorLogFileName = "collector.log"
+)
+
+// Log severities parsed from collector output, in increasing order
+var logSeverities = []string{"debug", "info", "warn", "error", "fatal"}
+
+// CollectorLogLine is one line of collector stdout/stderr
+type CollectorLogLine struct {
+	Timestamp time.Time `json:"timestamp"`
+	Stream    string    `json:"stream"`
+	Severity  string    `json:"severity"`
+	Message   string    `json:"message"`
+}
+
+// collectorLogStore writes collector output to size-rotated files and keeps
+// the most recent lines in memory for tailing and live following. Stores
+// without a directory keep the lines in memory only.
+type collectorLogStore struct {
+	mu       sync.Mutex
+	dir      string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+
+	lines []CollectorLogLine // ring buffer
+	next  int
+	full  bool
+
+	subscribers map[chan CollectorLogLine]struct{}
+	closed      bool
+}
+
+// newCollectorLogStore creates <dir> and opens <dir>/collector.log for append
+func newCollectorLogStore(dir string, maxSize int64, maxFiles int) (*collectorLogStore, error) {
+	if maxSize <= 0 {
+		maxSize = defaultCollectorLogMaxSize
+	}
+	if maxFiles <= 0 {
+		maxFiles = defaultCollectorLogMaxFiles
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating log directory: %w", err)
+	}
+
+	s := &collectorLogStore{
+		dir:         dir,
+		maxSize:     maxSize,
+		maxFiles:    maxFiles,
+		lines:       make([]CollectorLogLine, collectorLogBufferLines),
+		subscribers: make(map[chan CollectorLogLine]struct{}),
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newMemoryLogStore creates a store keeping the most recent lines in memory
+// only
+func newMemoryLogStore() *collectorLogStore {
+	return &collectorLogStore{
+		lines:       make([]CollectorLogLine, collectorLogBufferLines),
+		subscribers: make(map[chan CollectorLogLine]struct{}),
+	}
+}
+
+// writeLine records one line of collector output. It is the CollectorProcess
+// output handler.
+func (s *collectorLogStore) writeLine(stream, text string) {
+	s.append(CollectorLogLine{
+		Timestamp: time.Now(),
+		Stream:    stream,
+		Severity:  parseSeverity(text),
+		Message:   text,
+	})
+}
+
+// append records a parsed line
+func (s *collectorLogStore) append(line CollectorLogLine) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writeFile(line)
+
+	s.lines[s.next] = line
+	s.next = (s.next + 1) % len(s.lines)
+	if s.next == 0 {
+		s.full = true
+	}
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- line:
+		default:
+			// Slow follower, drop rather than block the collector
+		}
+	}
+}
+
+// tail returns up to n of the most recent lines at or above minSeverity,
+// oldest first
+func (s *collectorLogStore) tail(n int, minSeverity string) []CollectorLogLine {
+	return s.tailMatching(n, logFilter{level: minSeverity})
+}
+
+// tailMatching returns up to n of the most recent lines matching filter,
+// oldest first
+func (s *collectorLogStore) tailMatching(n int, filter logFilter) []CollectorLogLine {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tailLocked(n, filter)
+}
+
+// follow returns the tail like tailMatching does plus a channel receiving
+// every later line, unfiltered, with no gap between the two. The channel is
+// closed when the store is; call cancel to stop following.
+func (s *collectorLogStore) follow(n int, filter logFilter) ([]CollectorLogLine, <-chan CollectorLogLine, func()) {
+	ch := make(chan CollectorLogLine, 256)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		close(ch)
+	} else {
+		s.subscribers[ch] = struct{}{}
+	}
+
+	cancel := func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}
+	return s.tailLocked(n, filter), ch, cancel
+}
+
+// tailLocked must be called with s.mu held
+func (s *collectorLogStore) tailLocked(n int, filter logFilter) []CollectorLogLine {
+	count := s.next
+	if s.full {
+		count = len(s.lines)
+	}
+
+	var result []CollectorLogLine
+	for i := 0; i < count && len(result) < n; i++ {
+		idx := (s.next - 1 - i + len(s.lines)) % len(s.lines)
+		if filter.matches(s.lines[idx]) {
+			result = append(result, s.lines[idx])
+		}
+	}
+
+	// Collected newest first
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// close closes the active log file and ends all followers
+func (s *collectorLogStore) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for ch := range s.subscribers {
+		close(ch)
+		delete(s.subscribers, ch)
+	}
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// open opens the active log file; callers must hold s.mu after construction
+func (s *collectorLogStore) open() error {
+	path := filepath.Join(s.dir, collectorLogFileName)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening collector log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("opening collector log: %w", err)
+	}
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+// writeFile appends line as JSON, rotating first if the file would exceed
+// maxSize. Must be called with s.mu held. Errors are dropped: losing log
+// lines must never affect the collector.
+func (s *collectorLogStore) writeFile(line CollectorLogLine) {
+	if s.file == nil {
+		return
+	}
+	data, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	if s.size > 0 && s.size+int64(len(data)) > s.maxSize {
+		s.rotate()
+		if s.file == nil {
+			return
+		}
+	}
+
+	n, _ := s.file.Write(data)
+	s.size += int64(n)
+}
+
+// rotate shifts collector.log -> collector.log.1 -> ... dropping the oldest.
+// Must be called with s.mu held.
+func (s *collectorLogStore) rotate() {
+	s.file.Close()
+	s.file = nil
+
+	base := filepath.Join(s.dir, collectorLogFileName)
+	os.Remove(fmt.Sprintf("%s.%d", base, s.maxFiles))
+	for i := s.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", base, i), fmt.Sprintf("%s.%d", base, i+1))
+	}
+	os.Rename(base, base+".1")
+
+	s.open()
+}
+
+// parseSeverity extracts the level from a collector log line. The collector
+// logs in zap's console format ("<time>\t<level>\t...") or, when configured,
+// as JSON with a "level" field. Unrecognized lines are info.
+func parseSeverity(text string) string {
+	if strings.HasPrefix(text, "{") {
+		var entry struct {
+			Level string `json:"level"`
+		}
+		if json.Unmarshal([]byte(text), &entry) == nil && entry.Level != "" {
+			return normalizeSeverity(entry.Level)
+		}
+	}
+
+	fields := strings.SplitN(text, "\t", 3)
+	if len(fields) >= 2 {
+		if severity := normalizeSeverity(fields[1]); severity != "" {
+			return severity
+		}
+	}
+	return "info"
+}
+
+// normalizeSeverity maps zap level names to logSeverities, "" if unknown
+func normalizeSeverity(level string) string {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return "debug"
+	case "info":
+		return "info"
+	case "warn", "warning":
+		return "warn"
+	case "error":
+		return "error"
+	case "dpanic", "panic", "fatal":
+		return "fatal"
+	}
+	return ""
+}
+
+// logFilter selects log lines; the zero value matches everything
+type logFilter struct {
+	level string         // minimum severity
+	since time.Time      // lines logged at or after
+	grep  *regexp.Regexp // lines whose message matches
+}
+
+// matches reports whether line passes the filter
+func (f logFilter) matches(line CollectorLogLine) bool {
+	if !severityAtLeast(line.Severity, f.level) {
+		return false
+	}
+	if !f.since.IsZero() && line.Timestamp.Before(f.since) {
+		return false
+	}
+	return f.grep == nil || f.grep.MatchString(line.Message)
+}
+
+// parseSince parses the since query parameter: a duration back from now,
+// e.g. 10m, or an RFC 3339 time
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("since must not be negative")
+		}
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("since must be a duration like 10m or an RFC 3339 time")
+	}
+	return t, nil
+}
+
+// severityAtLeast reports whether severity is at or above min; an empty min
+// matches everything
+func severityAtLeast(severity, min string) bool {
+	if min == "" {
+		return true
+	}
+	rank := func(s string) int {
+		for i, name := range logSeverities {
+			if name == s {
+				return i
+			}
+		}
+		return -1
+	}
+	return rank(severity) >= rank(min)
+}
+
+// collectorOutputHandler returns the CollectorProcess output handler, nil when
+// output is not captured
+func (s *UnifiedSupervisor) collectorOutputHandler() func(stream, line string) {
+	if s.collectorLogs == nil {
+		return nil
+	}
+	return s.collectorLogs.writeLine
+}
+
+// handleCollectorLogs serves GET /v1/logs/collector; see serveLogs
+func (s *UnifiedSupervisor) handleCollectorLogs(w http.ResponseWriter, r *http.Request) {
+	// Track API request
+	s.metrics.IncrementRequests()
+
+	if s.collectorLogs == nil {
+		problem.Error(w, r, "collector log capture is disabled (no work dir)", http.StatusNotFound)
+		return
+	}
+	serveLogs(w, r, s.collectorLogs)
+}
+
+// serveLogs serves the lines of store.
+//
+// Query parameters:
+//
+//	tail=N        number of recent lines to return (default 100)
+//	follow=true   keep the response open and stream new lines
+//	level=warn    only lines at or above this severity
+//	since=10m     only lines from the last 10 minutes, or since an RFC 3339 time
+//	grep=regexp   only lines whose message matches
+//	format=json   one JSON CollectorLogLine per line instead of raw text
+func serveLogs(w http.ResponseWriter, r *http.Request, store *collectorLogStore) {
+	query := r.URL.Query()
+
+	tail := 100
+	if v := query.Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			problem.Error(w, r, "tail must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		tail = n
+	}
+
+	follow := false
+	if v := query.Get("follow"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			problem.Error(w, r, "follow must be a boolean", http.StatusBadRequest)
+			return
+		}
+		follow = b
+	}
+
+	var filter logFilter
+	if v := query.Get("level"); v != "" {
+		filter.level = normalizeSeverity(v)
+		if filter.level == "" {
+			problem.Error(w, r, fmt.Sprintf("unknown level %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if v := query.Get("since"); v != "" {
+		since, err := parseSince(v, time.Now())
+		if err != nil {
+			problem.Error(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.since = since
+	}
+
+	if v := query.Get("grep"); v != "" {
+		grep, err := regexp.Compile(v)
+		if err != nil {
+			problem.Error(w, r, fmt.Sprintf("invalid grep pattern: %v", err), http.StatusBadRequest)
+			return
+		}
+		filter.grep = grep
+	}
+
+	format := query.Get("format")
+	switch format {
+	case "":
+		format = "text"
+	case "text", "json":
+	default:
+		problem.Error(w, r, "format must be text or json", http.StatusBadRequest)
+		return
+	}
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+
+	encoder := json.NewEncoder(w)
+	write := func(line CollectorLogLine) error {
+		if format == "json" {
+			return encoder.Encode(line)
+		}
+		_, err := fmt.Fprintf(w, "%s\n", line.Message)
+		return err
+	}
+
+	if !follow {
+		w.WriteHeader(http.StatusOK)
+		for _, line := range store.tailMatching(tail, filter) {
+			if err := write(line); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	// Streaming outlives the server's write timeout
+	controller := http.NewResponseController(w)
+	controller.SetWriteDeadline(time.Time{})
+
+	lines, updates, cancel := store.follow(tail, filter)
+	defer cancel()
+
+	w.WriteHeader(http.StatusOK)
+	for _, line := range lines {
+		if err := write(line); err != nil {
+			return
+		}
+	}
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case line, ok := <-updates:
+			if !ok {
+				return
+			}
+			if !filter.matches(line) {
+				continue
+			}
+			if err := write(line); err != nil {
+				return
+			}
+			if err := controller.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
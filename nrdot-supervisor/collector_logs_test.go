@@ -0,0 +1,260 @@
+package supervisor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"2024-01-01T00:00:00.000Z\tinfo\tservice@v0.91.0/service.go:143\tStarting otelcol...", "info"},
+		{"2024-01-01T00:00:00.000Z\twarn\texporterhelper/queue.go:90\tDropping data", "warn"},
+		{"2024-01-01T00:00:00.000Z\terror\texporterhelper/retry.go:39\tExporting failed", "error"},
+		{"2024-01-01T00:00:00.000Z\tdebug\tfoo", "debug"},
+		{`{"level":"error","ts":1700000000,"msg":"Exporting failed"}`, "error"},
+		{`{"level":"WARN","msg":"x"}`, "warn"},
+		{"Error: failed to get config: cannot unmarshal the configuration", "info"},
+		{"", "info"},
+	}
+
+	for _, tt := range tests {
+		if got := parseSeverity(tt.line); got != tt.want {
+			t.Errorf("parseSeverity(%q) = %s, want %s", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestCollectorLogStore_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	store, err := newCollectorLogStore(dir, 512, 2)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.close()
+
+	for i := 0; i < 50; i++ {
+		store.writeLine("stdout", fmt.Sprintf("line %d %s", i, strings.Repeat("x", 40)))
+	}
+
+	for _, name := range []string{"collector.log", "collector.log.1", "collector.log.2"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Expected %s: %v", name, err)
+		}
+		if info.Size() > 512 {
+			t.Errorf("%s is %d bytes, above the rotation size", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "collector.log.3")); !os.IsNotExist(err) {
+		t.Error("Expected only 2 rotated files to be kept")
+	}
+
+	// The active file holds the newest lines as JSON
+	data, err := os.ReadFile(filepath.Join(dir, "collector.log"))
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var last CollectorLogLine
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatalf("Log line is not JSON: %v", err)
+	}
+	if !strings.HasPrefix(last.Message, "line 49 ") || last.Stream != "stdout" {
+		t.Errorf("Unexpected last line: %+v", last)
+	}
+}
+
+func TestCollectorLogStore_Tail(t *testing.T) {
+	store, err := newCollectorLogStore(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.close()
+
+	store.writeLine("stderr", "ts\tinfo\tone")
+	store.writeLine("stderr", "ts\terror\ttwo")
+	store.writeLine("stderr", "ts\tinfo\tthree")
+	store.writeLine("stderr", "ts\twarn\tfour")
+
+	lines := store.tail(2, "")
+	if len(lines) != 2 || lines[0].Message != "ts\tinfo\tthree" || lines[1].Message != "ts\twarn\tfour" {
+		t.Errorf("Unexpected tail: %+v", lines)
+	}
+
+	lines = store.tail(10, "warn")
+	if len(lines) != 2 || lines[0].Severity != "error" || lines[1].Severity != "warn" {
+		t.Errorf("Unexpected filtered tail: %+v", lines)
+	}
+
+	// The ring buffer keeps only the most recent lines
+	for i := 0; i < collectorLogBufferLines+10; i++ {
+		store.writeLine("stdout", fmt.Sprintf("line %d", i))
+	}
+	lines = store.tail(collectorLogBufferLines*2, "")
+	if len(lines) != collectorLogBufferLines {
+		t.Fatalf("Expected %d lines, got %d", collectorLogBufferLines, len(lines))
+	}
+	if lines[0].Message != "line 10" {
+		t.Errorf("Expected oldest kept line to be line 10, got %s", lines[0].Message)
+	}
+}
+
+func TestHandleCollectorLogs(t *testing.T) {
+	s := &UnifiedSupervisor{metrics: NewMetricsCollector()}
+
+	// Disabled without a work dir
+	rec := httptest.NewRecorder()
+	s.handleCollectorLogs(rec, httptest.NewRequest("GET", "/v1/logs/collector", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without log capture, got %d", rec.Code)
+	}
+
+	store, err := newCollectorLogStore(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.close()
+	s.collectorLogs = store
+
+	for i := 0; i < 5; i++ {
+		store.writeLine("stdout", fmt.Sprintf("ts\tinfo\tline %d", i))
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleCollectorLogs(rec, httptest.NewRequest("GET", "/v1/logs/collector?tail=2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	if got := rec.Body.String(); got != "ts\tinfo\tline 3\nts\tinfo\tline 4\n" {
+		t.Errorf("Unexpected body: %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleCollectorLogs(rec, httptest.NewRequest("GET", "/v1/logs/collector?tail=1&format=json", nil))
+	var line CollectorLogLine
+	if err := json.Unmarshal(rec.Body.Bytes(), &line); err != nil {
+		t.Fatalf("Expected JSON line: %v", err)
+	}
+	if line.Message != "ts\tinfo\tline 4" || line.Severity != "info" {
+		t.Errorf("Unexpected line: %+v", line)
+	}
+
+	for _, query := range []string{"tail=-1", "follow=maybe", "level=loud", "format=xml"} {
+		rec = httptest.NewRecorder()
+		s.handleCollectorLogs(rec, httptest.NewRequest("GET", "/v1/logs/collector?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestHandleCollectorLogs_Follow(t *testing.T) {
+	store, err := newCollectorLogStore(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	s := &UnifiedSupervisor{metrics: NewMetricsCollector(), collectorLogs: store}
+
+	server := httptest.NewServer(http.HandlerFunc(s.handleCollectorLogs))
+	defer server.Close()
+
+	store.writeLine("stdout", "before")
+
+	resp, err := http.Get(server.URL + "?tail=1&follow=true&level=warn")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	read := make(chan string)
+	go func() {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(read)
+				return
+			}
+			read <- strings.TrimSuffix(line, "\n")
+		}
+	}()
+
+	store.writeLine("stderr", "ts\tinfo\tfiltered")
+	store.writeLine("stderr", "ts\terror\tstreamed")
+
+	select {
+	case line := <-read:
+		if line != "ts\terror\tstreamed" {
+			t.Errorf("Expected streamed error line, got %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for streamed line")
+	}
+
+	// Closing the store ends the stream
+	store.close()
+	select {
+	case _, ok := <-read:
+		if ok {
+			t.Error("Expected stream to end")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for stream to end")
+	}
+}
+
+func TestHandleCollectorLogs_Filters(t *testing.T) {
+	store, err := newCollectorLogStore(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.close()
+	s := &UnifiedSupervisor{metrics: NewMetricsCollector(), collectorLogs: store}
+
+	now := time.Now()
+	store.append(CollectorLogLine{Timestamp: now.Add(-time.Hour), Severity: "error", Message: "old export failed"})
+	store.append(CollectorLogLine{Timestamp: now.Add(-time.Minute), Severity: "info", Message: "export succeeded"})
+	store.append(CollectorLogLine{Timestamp: now.Add(-time.Minute), Severity: "error", Message: "export failed"})
+	store.append(CollectorLogLine{Timestamp: now, Severity: "info", Message: "scrape done"})
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"since=10m", "export succeeded\nexport failed\nscrape done\n"},
+		{"grep=export", "old export failed\nexport succeeded\nexport failed\n"},
+		{"grep=fail&since=10m", "export failed\n"},
+		{"grep=%5Eexport&tail=1", "export failed\n"},
+		{"since=" + url.QueryEscape(now.Add(-2*time.Hour).Format(time.RFC3339)) + "&level=error", "old export failed\nexport failed\n"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleCollectorLogs(rec, httptest.NewRequest("GET", "/v1/logs/collector?"+tt.query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", tt.query, rec.Code)
+		}
+		if got := rec.Body.String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{"since=yesterday", "since=-5m", "grep=%28unclosed"} {
+		rec := httptest.NewRecorder()
+		s.handleCollectorLogs(rec, httptest.NewRequest("GET", "/v1/logs/collector?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package supervisor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+)
+
+// errIllegalTransition is returned when the collector cannot move to the
+// requested state from its current one, e.g. a restart while a reload is
+// replacing the collector
+var errIllegalTransition = errors.New("illegal collector state transition")
+
+// collectorTransitions lists the states the collector may move to from each
+// state:
+//
+//	stopped   -> starting, reloading
+//	starting  -> running; stopped or degraded if the process fails to start
+//	running   -> stopping, reloading; degraded when the process exits, or
+//	             failed if that trips the crash-loop breaker
+//	degraded  -> starting (crash restart), stopping, reloading, failed, stopped
+//	failed    -> starting (breaker reset), reloading, stopped
+//	stopping  -> stopped; running if the process outlived the stop
+//	reloading -> running, starting (graceful reload), or back to the state
+//	             it was entered from when the reload fails
+var collectorTransitions = map[models.CollectorState][]models.CollectorState{
+	models.CollectorStateStopped: {
+		models.CollectorStateStarting,
+		models.CollectorStateReloading,
+	},
+	models.CollectorStateStarting: {
+		models.CollectorStateRunning,
+		models.CollectorStateStopped,
+		models.CollectorStateDegraded,
+	},
+	models.CollectorStateRunning: {
+		models.CollectorStateStopping,
+		models.CollectorStateReloading,
+		models.CollectorStateDegraded,
+		models.CollectorStateFailed,
+	},
+	models.CollectorStateDegraded: {
+		models.CollectorStateStarting,
+		models.CollectorStateStopping,
+		models.CollectorStateReloading,
+		models.CollectorStateFailed,
+		models.CollectorStateStopped,
+	},
+	models.CollectorStateFailed: {
+		models.CollectorStateStarting,
+		models.CollectorStateReloading,
+		models.CollectorStateStopped,
+	},
+	models.CollectorStateStopping: {
+		models.CollectorStateStopped,
+		models.CollectorStateRunning,
+	},
+	models.CollectorStateReloading: {
+		models.CollectorStateRunning,
+		models.CollectorStateStarting,
+		models.CollectorStateDegraded,
+		models.CollectorStateFailed,
+		models.CollectorStateStopped,
+	},
+}
+
+// canTransition reports whether the collector may move from one state to
+// another
+func canTransition(from, to models.CollectorState) bool {
+	for _, next := range collectorTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transition moves the collector to a new state, recording a state change
+// event. Moving to the current state is a no-op. The caller must hold s.mu.
+func (s *UnifiedSupervisor) transition(to models.CollectorState) error {
+	from := s.status.State
+	if from == to {
+		return nil
+	}
+	if !canTransition(from, to) {
+		return fmt.Errorf("%w: %s -> %s", errIllegalTransition, from, to)
+	}
+
+	s.status.State = to
+	s.status.StateSince = s.now()
+
+	s.recordEvent(models.EventTypeStateChanged, models.EventSeverityInfo,
+		"Collector state changed", fmt.Sprintf("%s -> %s", from, to))
+	return nil
+}
+
+// collectorState returns the current collector state
+func (s *UnifiedSupervisor) collectorState() models.CollectorState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status.State
+}
+
+// controlErrorStatus maps the error of a collector control operation to an
+// HTTP status: 409 if the collector's state does not allow the operation
+func controlErrorStatus(err error) int {
+	if errors.Is(err, errIllegalTransition) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// noteExitedLocked moves a collector that is still marked running but whose
+// process has exited, before a health check noticed, to degraded. The caller
+// must hold s.mu.
+func (s *UnifiedSupervisor) noteExitedLocked() {
+	if s.status.State == models.CollectorStateRunning && s.collector != nil && !s.collector.IsRunning() {
+		s.transition(models.CollectorStateDegraded)
+	}
+}
@@ -0,0 +1,145 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to models.CollectorState
+		allowed  bool
+	}{
+		{models.CollectorStateStopped, models.CollectorStateStarting, true},
+		{models.CollectorStateStarting, models.CollectorStateRunning, true},
+		{models.CollectorStateRunning, models.CollectorStateReloading, true},
+		{models.CollectorStateRunning, models.CollectorStateDegraded, true},
+		{models.CollectorStateDegraded, models.CollectorStateStarting, true},
+		{models.CollectorStateFailed, models.CollectorStateStarting, true},
+		{models.CollectorStateStopping, models.CollectorStateStopped, true},
+		{models.CollectorStateReloading, models.CollectorStateRunning, true},
+		{models.CollectorStateStopped, models.CollectorStateRunning, false},
+		{models.CollectorStateRunning, models.CollectorStateStarting, false},
+		{models.CollectorStateReloading, models.CollectorStateStopping, false},
+		{models.CollectorStateReloading, models.CollectorStateReloading, false},
+		{models.CollectorStateStopping, models.CollectorStateStarting, false},
+		{models.CollectorStateStarting, models.CollectorStateReloading, false},
+	}
+
+	for _, tt := range tests {
+		if got := canTransition(tt.from, tt.to); got != tt.allowed {
+			t.Errorf("%s -> %s: expected allowed=%t, got %t", tt.from, tt.to, tt.allowed, got)
+		}
+	}
+}
+
+func TestTransition(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		WorkDir: t.TempDir(),
+		Logger:  zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+
+	events, cancel := s.SubscribeEvents(EventFilter{Types: []string{string(models.EventTypeStateChanged)}})
+	defer cancel()
+
+	s.mu.Lock()
+	err = s.transition(models.CollectorStateStarting)
+	s.mu.Unlock()
+	if err != nil {
+		t.Fatalf("Expected stopped -> starting to be allowed, got %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Details != "stopped -> starting" {
+			t.Errorf("Expected transition details, got %q", event.Details)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No state change event recorded")
+	}
+
+	s.mu.Lock()
+	err = s.transition(models.CollectorStateStopping)
+	s.mu.Unlock()
+	if !errors.Is(err, errIllegalTransition) {
+		t.Fatalf("Expected illegal transition, got %v", err)
+	}
+
+	status, _ := s.GetStatus(context.Background())
+	if status.State != models.CollectorStateStarting {
+		t.Errorf("Expected state starting after rejected transition, got %s", status.State)
+	}
+	if status.StateSince.IsZero() {
+		t.Error("Expected state_since to be set")
+	}
+}
+
+func TestCheckHealth_LeavesReloadingCollector(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		WorkDir:      t.TempDir(),
+		RestartDelay: time.Millisecond,
+		Logger:       logger,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+
+	// The old collector has exited while a reload replaces it
+	config := DefaultCollectorConfig()
+	config.BinaryPath = "false"
+	s.collector = NewCollectorProcess(config, logger)
+	s.status.State = models.CollectorStateReloading
+
+	ctx := context.Background()
+	if err := s.collector.Start(ctx); err != nil {
+		t.Fatalf("Failed to start collector: %v", err)
+	}
+	waitForExit(t, s.collector)
+
+	s.checkHealth(ctx)
+
+	status, _ := s.GetStatus(ctx)
+	if status.State != models.CollectorStateReloading {
+		t.Errorf("Expected state reloading, got %s", status.State)
+	}
+	if status.RestartCount != 0 {
+		t.Errorf("Expected no restarts during reload, got %d", status.RestartCount)
+	}
+	if crashes := s.CrashLoopStatus().ConsecutiveCrashes; crashes != 0 {
+		t.Errorf("Expected no crash recorded during reload, got %d", crashes)
+	}
+}
+
+func TestHandleRestart_Conflict(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		WorkDir: t.TempDir(),
+		Logger:  zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+
+	// A running collector that a reload is replacing
+	s.collector = &fakeCollector{pid: 1000, running: true}
+	s.status.State = models.CollectorStateReloading
+
+	rec := httptest.NewRecorder()
+	s.handleRestart(rec, httptest.NewRequest(http.MethodPost, "/v1/control/restart", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 restarting a reloading collector, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !s.collector.IsRunning() {
+		t.Error("Collector stopped by a rejected restart")
+	}
+}
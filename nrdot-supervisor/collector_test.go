@@ -4,6 +4,8 @@ import (
 	"context"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -285,4 +287,64 @@ func TestCollectorProcess_StdoutStderr(t *testing.T) {
 	if err != nil {
 		t.Logf("Process exited with: %v", err)
 	}
-}
\ No newline at end of file
+}
+func TestCollectorProcess_DetectsCrash(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	config := DefaultCollectorConfig()
+	config.BinaryPath = "false" // exits immediately with status 1
+
+	collector := NewCollectorProcess(config, logger)
+
+	ctx := context.Background()
+	if err := collector.Start(ctx); err != nil {
+		t.Fatalf("Failed to start collector: %v", err)
+	}
+
+	// The exited process must not be reported as running (no zombie)
+	deadline := time.Now().Add(5 * time.Second)
+	for collector.IsRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("Crashed collector still reported as running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A crashed collector can be started again
+	if err := collector.Start(ctx); err != nil {
+		t.Fatalf("Failed to restart crashed collector: %v", err)
+	}
+	if err := collector.Wait(); err == nil {
+		t.Error("Expected exit error from crashed collector")
+	}
+}
+
+func TestCollectorProcess_OutputHandler(t *testing.T) {
+	// A script ignores the --config flag passed to the collector
+	script := filepath.Join(t.TempDir(), "collector.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho to-stdout\necho to-stderr >&2\n"), 0755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	var mu sync.Mutex
+	received := map[string]string{}
+
+	config := DefaultCollectorConfig()
+	config.BinaryPath = script
+	config.OutputHandler = func(stream, line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		received[stream] = line
+	}
+
+	collector := NewCollectorProcess(config, zaptest.NewLogger(t))
+	if err := collector.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start collector: %v", err)
+	}
+	collector.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if received["stdout"] != "to-stdout" || received["stderr"] != "to-stderr" {
+		t.Errorf("Unexpected output: %v", received)
+	}
+}
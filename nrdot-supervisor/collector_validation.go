@@ -0,0 +1,52 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	configengine "github.com/newrelic/nrdot-host/nrdot-config-engine"
+)
+
+// ValidateCollectorConfig implements configengine.CollectorValidator with
+// the collector binary, so the config engine rejects a config the collector
+// would refuse, with its component errors, before the config is versioned
+// and a reload is attempted. A collector without the validate command
+// cannot check configs, which is reported as an error.
+func (s *UnifiedSupervisor) ValidateCollectorConfig(ctx context.Context, otelConfig string) ([]models.ValidationError, error) {
+	s.mu.RLock()
+	binaryPath := s.config.CollectorPath
+	s.mu.RUnlock()
+
+	file, err := os.CreateTemp(s.config.WorkDir, "config-validate-*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write config to validate: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(otelConfig); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write config to validate: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write config to validate: %w", err)
+	}
+
+	err = s.collectorRunner().ValidateConfig(ctx, binaryPath, file.Name())
+	if err == nil {
+		return nil, nil
+	}
+	var rejected *collectorRejectedError
+	if !errors.As(err, &rejected) {
+		return nil, err
+	}
+	if strings.Contains(rejected.output, `unknown command "validate"`) {
+		return nil, fmt.Errorf("collector %s has no validate command", binaryPath)
+	}
+	return configengine.ParseCollectorErrors(rejected.output), nil
+}
+
+// Ensure the supervisor checks the configs of the engine it creates
+var _ configengine.CollectorValidator = (*UnifiedSupervisor)(nil)
@@ -0,0 +1,96 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestUnifiedSupervisor_CollectorValidation(t *testing.T) {
+	dir := t.TempDir()
+	var validated string
+	runner := &fakeRunner{validate: func(configPath string) error {
+		validated = configPath
+		return &collectorRejectedError{
+			err:    errors.New("exit status 1"),
+			output: "Error: invalid configuration: processors::batch: timeout must be positive",
+		}
+	}}
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		ConfigPath:      filepath.Join(dir, "config.yaml"),
+		WorkDir:         dir,
+		CollectorRunner: runner,
+		Logger:          zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+
+	// The engine rejects what the collector rejects, with the component
+	result, err := s.configEngine.ApplyConfig(context.Background(), &models.ConfigUpdate{
+		Config: []byte(testUserConfigV1),
+		Format: "yaml",
+		Source: "api",
+	})
+	if err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	if result.Success || result.ValidationResult == nil || len(result.ValidationResult.Errors) != 1 {
+		t.Fatalf("Expected one collector validation error, got %+v", result)
+	}
+	if got := result.ValidationResult.Errors[0]; got.Path != "processors.batch" || got.Message != "timeout must be positive" {
+		t.Errorf("Unexpected validation error %+v", got)
+	}
+	if _, err := os.Stat(validated); !os.IsNotExist(err) {
+		t.Errorf("Validated config %s was not removed", validated)
+	}
+
+	// Collectors that cannot validate do not block configs
+	runner.validate = func(configPath string) error {
+		return &collectorRejectedError{
+			err:    errors.New("exit status 1"),
+			output: `Error: unknown command "validate" for "otelcol"`,
+		}
+	}
+	result, err = s.configEngine.ApplyConfig(context.Background(), &models.ConfigUpdate{
+		Config: []byte(testUserConfigV1),
+		Format: "yaml",
+		Source: "api",
+	})
+	if err != nil || !result.Success {
+		t.Fatalf("Expected the config to apply, got %+v: %v", result, err)
+	}
+	if warnings := result.ValidationResult.Warnings; len(warnings) != 1 || !strings.Contains(warnings[0], "no validate command") {
+		t.Errorf("Expected a skipped validation warning, got %v", warnings)
+	}
+}
+
+func TestValidateCollectorConfig(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "otelcol")
+	script := "#!/bin/sh\necho \"Error: invalid configuration: service::pipelines::metrics: no receivers\"\nexit 1\n"
+	if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := validateCollectorConfig(context.Background(), binary, filepath.Join(dir, "config.yaml"))
+	var rejected *collectorRejectedError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("Expected a rejection, got %v", err)
+	}
+	if !strings.Contains(rejected.output, "no receivers") {
+		t.Errorf("Unexpected output %q", rejected.output)
+	}
+
+	// Failing to run the binary is not a rejection
+	err = validateCollectorConfig(context.Background(), filepath.Join(dir, "missing"), filepath.Join(dir, "config.yaml"))
+	if err == nil || errors.As(err, &rejected) {
+		t.Errorf("Expected an error other than a rejection, got %v", err)
+	}
+}
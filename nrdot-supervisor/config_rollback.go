@@ -0,0 +1,123 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+)
+
+// ConfigRollbackRequest is the body of POST /v1/config/rollback
+type ConfigRollbackRequest struct {
+	Version int `json:"version"`
+}
+
+// ConfigRollbackResult reports a configuration rollback. The restored
+// config is recorded as a new version; FromVersion is the version that was
+// current before.
+type ConfigRollbackResult struct {
+	Success     bool                 `json:"success"`
+	FromVersion int                  `json:"from_version"`
+	ToVersion   int                  `json:"to_version"`
+	Version     int                  `json:"version"`
+	Reload      *models.ReloadResult `json:"reload,omitempty"`
+	Error       *models.ErrorInfo    `json:"error,omitempty"`
+}
+
+// rollbackConfig restores the user config of version, regenerates the
+// collector config from it and reloads a running collector with the reload
+// strategy. If the reload fails the old collector keeps serving, and the
+// config engine is returned to the version it was on.
+func (s *UnifiedSupervisor) rollbackConfig(ctx context.Context, version int, author string) (*ConfigRollbackResult, error) {
+	result := &ConfigRollbackResult{ToVersion: version}
+	if history, err := s.configEngine.GetConfigHistory(ctx, 1); err == nil && len(history) > 0 {
+		result.FromVersion = history[0].Version
+	}
+
+	applied, err := s.configEngine.RollbackToVersion(ctx, version, author)
+	if err != nil {
+		result.Error = rollbackErrorInfo(err)
+		s.recordEvent(models.EventTypeConfigRejected, models.EventSeverityWarning,
+			"Configuration rollback rejected", err.Error())
+		return result, err
+	}
+	result.Version = applied.Version
+
+	s.mu.RLock()
+	running := s.collector != nil && s.collector.IsRunning()
+	s.mu.RUnlock()
+
+	if running {
+		reload, err := s.ReloadCollector(ctx, models.ReloadStrategyBlueGreen)
+		result.Reload = reload
+		if err != nil {
+			s.logger.Warn("Reload after config rollback failed, restoring previous version",
+				zap.Int("version", result.FromVersion), zap.Error(err))
+			if _, restoreErr := s.configEngine.RollbackToVersion(ctx, result.FromVersion, "supervisor"); restoreErr != nil {
+				s.logger.Error("Failed to restore config version after rollback",
+					zap.Int("version", result.FromVersion), zap.Error(restoreErr))
+			}
+			err = fmt.Errorf("reload after rollback to version %d failed: %w", version, err)
+			result.Error = rollbackErrorInfo(err)
+			return result, err
+		}
+	}
+
+	result.Success = true
+	s.recordEvent(models.EventTypeConfigRolledBack, models.EventSeverityWarning,
+		"Configuration rolled back",
+		fmt.Sprintf("Version %d -> %d (restored version %d)", result.FromVersion, result.Version, version))
+
+	return result, nil
+}
+
+// rollbackErrorInfo returns the ErrorInfo carried by err, or wraps it
+func rollbackErrorInfo(err error) *models.ErrorInfo {
+	var errInfo *models.ErrorInfo
+	if errors.As(err, &errInfo) {
+		return errInfo
+	}
+	return models.NewError(
+		models.ErrCodeInternalError,
+		"Configuration rollback failed",
+		models.ErrorCategoryConfig,
+		models.SeverityError,
+	).WithDetails(err.Error())
+}
+
+// handleConfigRollback serves POST /v1/config/rollback
+func (s *UnifiedSupervisor) handleConfigRollback(w http.ResponseWriter, r *http.Request) {
+	// Track API request
+	s.metrics.IncrementRequests()
+
+	var req ConfigRollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Version <= 0 {
+		problem.Error(w, r, "Invalid request body: a positive version is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.rollbackConfig(r.Context(), req.Version, "api")
+
+	status := http.StatusOK
+	if err != nil {
+		switch result.Error.Code {
+		case models.ErrCodeResourceNotFound:
+			status = http.StatusNotFound
+		case models.ErrCodeConfigConflict:
+			status = http.StatusConflict
+		case models.ErrCodeConfigInvalid:
+			status = http.StatusUnprocessableEntity
+		default:
+			status = http.StatusInternalServerError
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
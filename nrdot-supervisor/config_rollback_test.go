@@ -0,0 +1,188 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	configengine "github.com/newrelic/nrdot-host/nrdot-config-engine"
+)
+
+// applyTestConfigs applies user configs to the supervisor's config engine
+func applyTestConfigs(t *testing.T, s *UnifiedSupervisor, configs ...string) {
+	t.Helper()
+	for _, config := range configs {
+		result, err := s.configEngine.ApplyConfig(context.Background(), &models.ConfigUpdate{
+			Config: []byte(config),
+			Format: "yaml",
+			Source: "api",
+		})
+		if err != nil || !result.Success {
+			t.Fatalf("Failed to apply config: %v", err)
+		}
+	}
+}
+
+func currentConfigVersion(t *testing.T, s *UnifiedSupervisor) *models.ConfigVersion {
+	t.Helper()
+	history, err := s.configEngine.GetConfigHistory(context.Background(), 1)
+	if err != nil || len(history) != 1 {
+		t.Fatalf("Failed to get config history: %v", err)
+	}
+	return history[0]
+}
+
+const (
+	testUserConfigV1 = "service:\n  name: web-01\n"
+	testUserConfigV2 = "service:\n  name: web-01\ntraces:\n  enabled: true\n"
+)
+
+func TestUnifiedSupervisor_RollbackConfig(t *testing.T) {
+	f := newBlueGreenFixture(t)
+	s := f.supervisor
+	applyTestConfigs(t, s, testUserConfigV1, testUserConfigV2)
+	oldCollector := s.collector
+
+	events, cancel := s.SubscribeEvents(EventFilter{Types: []string{string(models.EventTypeConfigRolledBack)}})
+	defer cancel()
+
+	result, err := s.rollbackConfig(context.Background(), 1, "ops")
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if !result.Success || result.FromVersion != 2 || result.ToVersion != 1 || result.Version != 3 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if result.Reload == nil || !result.Reload.Success {
+		t.Errorf("Expected a successful reload, got %+v", result.Reload)
+	}
+	if s.collector == oldCollector || !s.collector.IsRunning() {
+		t.Error("Expected the collector to be reloaded")
+	}
+
+	current := currentConfigVersion(t, s)
+	if current.Version != 3 || current.Source != "rollback" || current.Metadata[configengine.RollbackConfigMetadataKey] != "1" {
+		t.Errorf("Unexpected current version %+v", current)
+	}
+
+	event := receiveEvent(t, events)
+	if event.Summary != "Configuration rolled back" || event.Details != "Version 2 -> 3 (restored version 1)" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+}
+
+func TestUnifiedSupervisor_RollbackConfig_ReloadFails(t *testing.T) {
+	f := newBlueGreenFixture(t)
+	f.healthy = false
+	s := f.supervisor
+	applyTestConfigs(t, s, testUserConfigV1, testUserConfigV2)
+	oldCollector := s.collector
+
+	result, err := s.rollbackConfig(context.Background(), 1, "ops")
+	if err == nil {
+		t.Fatal("Expected rollback to fail")
+	}
+	if result.Success || result.Reload == nil || result.Reload.Success || result.Error == nil {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if s.collector != oldCollector || !oldCollector.IsRunning() {
+		t.Error("Expected the old collector to keep running")
+	}
+
+	// The engine is back on the config the collector runs
+	current := currentConfigVersion(t, s)
+	if current.Version != 4 || current.Metadata[configengine.RollbackConfigMetadataKey] != "2" {
+		t.Errorf("Expected version 2 to be restored, got %+v", current)
+	}
+}
+
+func TestUnifiedSupervisor_HandleConfigRollback(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	applyTestConfigs(t, s, testUserConfigV1, testUserConfigV2)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/config/rollback", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		s.handleConfigRollback(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "invalid body", body: `{`, status: http.StatusBadRequest},
+		{name: "missing version", body: `{}`, status: http.StatusBadRequest},
+		{name: "unknown version", body: `{"version": 42}`, status: http.StatusNotFound},
+		{name: "current version", body: `{"version": 2}`, status: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := post(tt.body); w.Code != tt.status {
+				t.Errorf("Expected %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// Without a running collector only the config is restored
+	w := post(`{"version": 1}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result ConfigRollbackResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !result.Success || result.Version != 3 || result.Reload != nil {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	exported, err := s.configEngine.ExportConfig(context.Background(), "yaml")
+	if err != nil || string(exported) != testUserConfigV1 {
+		t.Errorf("Expected version 1 config, got %q, %v", exported, err)
+	}
+}
+
+func TestHandlers_GetVersionHistory(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{WorkDir: t.TempDir(), APIEnabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	applyTestConfigs(t, s, testUserConfigV1, testUserConfigV2)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.apiServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/v1/config/history")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var history []models.ConfigVersion
+	if err := json.NewDecoder(w.Body).Decode(&history); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(history) != 2 || history[0].Version != 1 || history[1].Version != 2 {
+		t.Errorf("Expected versions 1 and 2, got %+v", history)
+	}
+
+	w = get("/v1/config/history?limit=1")
+	history = nil
+	if err := json.NewDecoder(w.Body).Decode(&history); err != nil || len(history) != 1 || history[0].Version != 2 {
+		t.Errorf("Expected the latest version, got %+v, %v", history, err)
+	}
+
+	if w := get("/v1/config/history?limit=zero"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid limit, got %d", w.Code)
+	}
+}
@@ -0,0 +1,231 @@
+package supervisor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	configengine "github.com/newrelic/nrdot-host/nrdot-config-engine"
+	"go.uber.org/zap"
+)
+
+// defaultConfigWatchDebounce is how long the config file must be quiet
+// before a change is applied
+const defaultConfigWatchDebounce = time.Second
+
+// Triggers of a config file reload, recorded in its events
+const (
+	ConfigReloadTriggerWatch      = "file watch"
+	ConfigReloadTriggerSIGHUP     = "SIGHUP"
+	ConfigReloadTriggerAutoConfig = "auto-configuration"
+)
+
+// ReloadConfigFile re-reads the user config file, merged with its conf.d
+// overrides, and, if the result changed, applies it through the config engine and reloads a running
+// collector with the blue-green strategy. A config the engine rejects leaves
+// everything as it was; if the reload fails the engine is returned to the
+// version the collector runs. trigger names the cause in events, e.g.
+// ConfigReloadTriggerSIGHUP.
+func (s *UnifiedSupervisor) ReloadConfigFile(ctx context.Context, trigger string) error {
+	path := s.config.ConfigPath
+	if path == "" {
+		return fmt.Errorf("no config file configured")
+	}
+
+	// File watch and SIGHUP reloads take turns
+	s.configFileMu.Lock()
+	defer s.configFileMu.Unlock()
+
+	data, files, err := readConfigFile(path)
+	if err != nil {
+		s.recordEvent(models.EventTypeConfigRejected, models.EventSeverityWarning,
+			"Configuration file unreadable", fmt.Sprintf("%s (%s): %v", path, trigger, err))
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	hash := configFileHash(data)
+	s.mu.RLock()
+	unchanged := hash == s.configFileHash
+	s.mu.RUnlock()
+	if unchanged {
+		s.logger.Debug("Config file unchanged, nothing to reload",
+			zap.String("path", path),
+			zap.String("trigger", trigger))
+		return nil
+	}
+
+	// Remember the content even if it is rejected, so it is not retried
+	// until the file changes again
+	s.mu.Lock()
+	s.configFileHash = hash
+	s.mu.Unlock()
+
+	s.logger.Info("Config file changed, applying",
+		zap.String("path", path),
+		zap.Strings("files", files),
+		zap.String("trigger", trigger))
+	s.recordEvent(models.EventTypeConfigChanged, models.EventSeverityInfo,
+		"Configuration file changed", fmt.Sprintf("%s (%s)", path, trigger))
+
+	previous := s.currentConfigVersion(ctx)
+
+	result, err := s.configEngine.ApplyConfig(ctx, &models.ConfigUpdate{
+		Config:      data,
+		Format:      "yaml",
+		Source:      "file",
+		Author:      "supervisor",
+		Description: "Reloaded from " + path + " on " + trigger,
+	})
+	if err == nil && !result.Success {
+		err = configResultError(result)
+	}
+	if err != nil {
+		s.recordEvent(models.EventTypeConfigRejected, models.EventSeverityWarning,
+			"Configuration file rejected", err.Error())
+		return fmt.Errorf("config file rejected: %w", err)
+	}
+	s.markConfigPending(hash)
+
+	if err := s.reloadAppliedConfig(ctx, previous); err != nil {
+		return fmt.Errorf("reload after config file change failed: %w", err)
+	}
+	return nil
+}
+
+// currentConfigVersion returns the latest config engine version, 0 if there
+// is none
+func (s *UnifiedSupervisor) currentConfigVersion(ctx context.Context) int {
+	if history, err := s.configEngine.GetConfigHistory(ctx, 1); err == nil && len(history) > 0 {
+		return history[0].Version
+	}
+	return 0
+}
+
+// reloadAppliedConfig reloads a running collector with the config just
+// applied to the engine, returning the engine to version previous if the
+// reload fails. A stopped collector picks the config up when it next
+// starts.
+func (s *UnifiedSupervisor) reloadAppliedConfig(ctx context.Context, previous int) error {
+	s.mu.RLock()
+	running := s.collector != nil && s.collector.IsRunning()
+	s.mu.RUnlock()
+	if !running {
+		return nil
+	}
+
+	if _, err := s.ReloadCollector(ctx, models.ReloadStrategyBlueGreen); err != nil {
+		if previous > 0 {
+			if _, restoreErr := s.configEngine.RollbackToVersion(ctx, previous, "supervisor"); restoreErr != nil {
+				s.logger.Error("Failed to restore config version after failed reload",
+					zap.Int("version", previous), zap.Error(restoreErr))
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// configResultError describes why the engine did not apply a config
+func configResultError(result *models.ConfigResult) error {
+	if result.ValidationResult != nil && len(result.ValidationResult.Errors) > 0 {
+		messages := make([]string, len(result.ValidationResult.Errors))
+		for i, e := range result.ValidationResult.Errors {
+			messages[i] = e.Message
+		}
+		return fmt.Errorf("invalid configuration: %s", strings.Join(messages, "; "))
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+	return fmt.Errorf("configuration not applied")
+}
+
+// readConfigFile reads the user config file at path merged with the
+// overrides of its conf.d directory, returning the files merged
+func readConfigFile(path string) ([]byte, []string, error) {
+	layered, err := configengine.LoadLayeredConfig(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return layered.Data, layered.Files, nil
+}
+
+// configFileHash identifies config file content
+func configFileHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// startConfigWatch watches the user config file and reloads it on change.
+// The directory is watched so that editors saving through a rename and
+// symlink swaps, as done for Kubernetes ConfigMaps, are seen as well.
+func (s *UnifiedSupervisor) startConfigWatch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	dir := filepath.Dir(s.config.ConfigPath)
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	// Overrides too, once the directory exists
+	confDir := filepath.Join(dir, configengine.ConfDirName)
+	if err := watcher.Add(confDir); err != nil && !os.IsNotExist(err) {
+		s.logger.Warn("Failed to watch config overrides", zap.String("dir", confDir), zap.Error(err))
+	}
+
+	s.logger.Info("Watching config file for changes", zap.String("path", s.config.ConfigPath))
+	go s.configWatchLoop(ctx, watcher)
+	return nil
+}
+
+// configWatchLoop debounces file events and reloads the config file once
+// they settle. Other files in the directory only cause a content check.
+func (s *UnifiedSupervisor) configWatchLoop(ctx context.Context, watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+
+	debounce := s.config.ConfigWatchDebounce
+	if debounce <= 0 {
+		debounce = defaultConfigWatchDebounce
+	}
+
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			if event.Op&fsnotify.Create != 0 && filepath.Base(event.Name) == configengine.ConfDirName &&
+				filepath.Dir(event.Name) == filepath.Dir(s.config.ConfigPath) {
+				if err := watcher.Add(event.Name); err != nil {
+					s.logger.Warn("Failed to watch config overrides", zap.String("dir", event.Name), zap.Error(err))
+				}
+			}
+			settled = time.After(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			s.logger.Warn("Config watcher error", zap.Error(err))
+		case <-settled:
+			settled = nil
+			if err := s.ReloadConfigFile(ctx, ConfigReloadTriggerWatch); err != nil {
+				s.logger.Warn("Config file reload failed", zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package supervisor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	configengine "github.com/newrelic/nrdot-host/nrdot-config-engine"
+	"go.uber.org/zap/zaptest"
+)
+
+// writeConfigFile writes the user config file at path
+func writeConfigFile(t *testing.T, path, config string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+}
+
+func TestUnifiedSupervisor_ReloadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeConfigFile(t, path, testUserConfigV1)
+
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		ConfigPath: path,
+		WorkDir:    dir,
+		Logger:     zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	ctx := context.Background()
+	if err := s.loadConfiguration(ctx); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	events, cancel := s.SubscribeEvents(EventFilter{Types: []string{"config."}})
+	defer cancel()
+
+	// The loaded content is not applied again
+	if err := s.ReloadConfigFile(ctx, ConfigReloadTriggerSIGHUP); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if current := currentConfigVersion(t, s); current.Version != 1 {
+		t.Errorf("Expected no new version for unchanged content, got %d", current.Version)
+	}
+
+	// Without a running collector the config is only applied
+	writeConfigFile(t, path, testUserConfigV2)
+	if err := s.ReloadConfigFile(ctx, ConfigReloadTriggerSIGHUP); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	current := currentConfigVersion(t, s)
+	if current.Version != 2 || current.Source != "file" {
+		t.Errorf("Unexpected current version %+v", current)
+	}
+	event := receiveEvent(t, events)
+	if event.Type != models.EventTypeConfigChanged || event.Details != path+" (SIGHUP)" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	// An invalid config is rejected and not retried until it changes
+	writeConfigFile(t, path, "service: [\n")
+	if err := s.ReloadConfigFile(ctx, ConfigReloadTriggerWatch); err == nil {
+		t.Fatal("Expected invalid config to be rejected")
+	}
+	receiveEvent(t, events)
+	if event := receiveEvent(t, events); event.Type != models.EventTypeConfigRejected {
+		t.Errorf("Expected config.rejected, got %+v", event)
+	}
+	if current := currentConfigVersion(t, s); current.Version != 2 {
+		t.Errorf("Expected version 2 to stay current, got %d", current.Version)
+	}
+	if err := s.ReloadConfigFile(ctx, ConfigReloadTriggerWatch); err != nil {
+		t.Errorf("Expected unchanged invalid config to be skipped, got %v", err)
+	}
+}
+
+func TestUnifiedSupervisor_ReloadConfigFile_ReloadsCollector(t *testing.T) {
+	f := newBlueGreenFixture(t)
+	s := f.supervisor
+	path := filepath.Join(s.config.WorkDir, "user.yaml")
+	writeConfigFile(t, path, testUserConfigV1)
+	s.config.ConfigPath = path
+	ctx := context.Background()
+	if err := s.loadConfiguration(ctx); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	oldCollector := s.collector
+
+	events, cancel := s.SubscribeEvents(EventFilter{Types: []string{string(models.EventTypeReloaded)}})
+	defer cancel()
+
+	writeConfigFile(t, path, testUserConfigV2)
+	if err := s.ReloadConfigFile(ctx, ConfigReloadTriggerWatch); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if s.collector == oldCollector || !s.collector.IsRunning() {
+		t.Error("Expected the collector to be reloaded")
+	}
+	receiveEvent(t, events)
+
+	// A failed reload returns the engine to the running config
+	f.healthy = false
+	oldCollector = s.collector
+	writeConfigFile(t, path, testUserConfigV1)
+	if err := s.ReloadConfigFile(ctx, ConfigReloadTriggerWatch); err == nil {
+		t.Fatal("Expected reload to fail")
+	}
+	if s.collector != oldCollector || !oldCollector.IsRunning() {
+		t.Error("Expected the old collector to keep running")
+	}
+	current := currentConfigVersion(t, s)
+	if current.Version != 4 || current.Metadata[configengine.RollbackConfigMetadataKey] != "2" {
+		t.Errorf("Expected version 2 to be restored, got %+v", current)
+	}
+}
+
+func TestUnifiedSupervisor_ConfigWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeConfigFile(t, path, testUserConfigV1)
+
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		ConfigPath:          path,
+		WorkDir:             dir,
+		WatchConfig:         true,
+		ConfigWatchDebounce: 50 * time.Millisecond,
+		Logger:              zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	if err := s.loadConfiguration(ctx); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if err := s.startConfigWatch(ctx); err != nil {
+		t.Fatalf("Failed to watch config: %v", err)
+	}
+	events, cancel := s.SubscribeEvents(EventFilter{Types: []string{string(models.EventTypeConfigChanged)}})
+	defer cancel()
+
+	// Saving through a rename, as editors do
+	tmp := filepath.Join(dir, ".config.yaml.swp")
+	writeConfigFile(t, tmp, testUserConfigV2)
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("Failed to replace config: %v", err)
+	}
+
+	event := receiveEvent(t, events)
+	if event.Details != path+" (file watch)" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	// config.changed is recorded before the config is applied
+	deadline := time.Now().Add(5 * time.Second)
+	for currentConfigVersion(t, s).Version != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the changed config to be applied")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUnifiedSupervisor_ConfigWatch_Overrides(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeConfigFile(t, path, testUserConfigV1)
+
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		ConfigPath:          path,
+		WorkDir:             dir,
+		WatchConfig:         true,
+		ConfigWatchDebounce: 50 * time.Millisecond,
+		Logger:              zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	if err := s.loadConfiguration(ctx); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if err := s.startConfigWatch(ctx); err != nil {
+		t.Fatalf("Failed to watch config: %v", err)
+	}
+
+	// An override in a conf.d created after the watch started
+	confDir := filepath.Join(dir, configengine.ConfDirName)
+	if err := os.Mkdir(confDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfigFile(t, filepath.Join(confDir, "90-traces.yaml"), "traces:\n  enabled: true\n")
+
+	waitForVersion := func(version int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for currentConfigVersion(t, s).Version != version {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected config version %d to be applied", version)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForVersion(2)
+
+	// The merged config is what was applied
+	merged, _, err := readConfigFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read merged config: %v", err)
+	}
+	s.mu.RLock()
+	hash := s.configFileHash
+	s.mu.RUnlock()
+	if hash != configFileHash(merged) {
+		t.Error("Applied config is not the merged config")
+	}
+
+	// Changing the override reloads as well
+	writeConfigFile(t, filepath.Join(confDir, "90-traces.yaml"), "traces:\n  enabled: false\n")
+	waitForVersion(3)
+
+	// A broken override is rejected, keeping the current version
+	writeConfigFile(t, filepath.Join(confDir, "95-broken.yaml"), "service: !append [x]\n")
+	if err := s.ReloadConfigFile(ctx, ConfigReloadTriggerSIGHUP); err == nil {
+		t.Error("Expected a broken override to be rejected")
+	}
+	if current := currentConfigVersion(t, s); current.Version != 3 {
+		t.Errorf("Expected version 3 to stay current, got %d", current.Version)
+	}
+}
@@ -0,0 +1,137 @@
+package supervisor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-supervisor/pkg/restart"
+)
+
+const (
+	// defaultMaxRestartDelay caps the backoff between crash restarts
+	defaultMaxRestartDelay = 5 * time.Minute
+
+	// crashLoopStableRun is how long the collector must stay up before its
+	// crash history is forgotten
+	crashLoopStableRun = 5 * time.Minute
+)
+
+// CrashLoopStatus describes the collector crash-loop breaker
+type CrashLoopStatus struct {
+	Tripped            bool      `json:"tripped"`
+	ConsecutiveCrashes int       `json:"consecutive_crashes"`
+	MaxCrashes         int       `json:"max_crashes"`
+	LastCrash          time.Time `json:"last_crash,omitempty"`
+	TrippedAt          time.Time `json:"tripped_at,omitempty"`
+}
+
+// crashLoopBreaker spaces out collector restarts with exponential backoff and
+// stops restarting altogether once maxCrashes consecutive restarts have all
+// crashed. A run longer than stableRun clears the crash history; a tripped
+// breaker stays open until reset.
+type crashLoopBreaker struct {
+	mu         sync.Mutex
+	backoff    *restart.ExponentialBackoff
+	maxCrashes int
+	stableRun  time.Duration
+	crashes    int
+	lastCrash  time.Time
+	tripped    bool
+	trippedAt  time.Time
+	now        func() time.Time
+}
+
+// newCrashLoopBreaker creates a breaker. maxCrashes <= 0 never trips.
+func newCrashLoopBreaker(initialDelay, maxDelay time.Duration, maxCrashes int) *crashLoopBreaker {
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxRestartDelay
+	}
+	if initialDelay <= 0 || initialDelay > maxDelay {
+		initialDelay = time.Second
+	}
+	return &crashLoopBreaker{
+		backoff:    restart.NewExponentialBackoff(initialDelay, maxDelay, 2.0, 0),
+		maxCrashes: maxCrashes,
+		stableRun:  crashLoopStableRun,
+		now:        time.Now,
+	}
+}
+
+// recordRunning notes that the collector is up after running for uptime.
+// Reports whether that cleared a crash history.
+func (b *crashLoopBreaker) recordRunning(uptime time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.crashes == 0 || uptime < b.stableRun {
+		return false
+	}
+	b.clear()
+	return true
+}
+
+// recordCrash notes that the collector exited after running for uptime and
+// returns how long to wait before restarting it. ok is false when the breaker
+// is open and the collector must not be restarted.
+func (b *crashLoopBreaker) recordCrash(uptime time.Duration) (delay time.Duration, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tripped {
+		return 0, false
+	}
+
+	// A crash after a long healthy run starts a new sequence
+	if uptime >= b.stableRun {
+		b.clear()
+	}
+
+	b.crashes++
+	b.lastCrash = b.now()
+
+	if b.maxCrashes > 0 && b.crashes > b.maxCrashes {
+		b.tripped = true
+		b.trippedAt = b.lastCrash
+		return 0, false
+	}
+
+	delay, _ = b.backoff.NextDelay()
+	return delay, true
+}
+
+// isTripped reports whether restarts are suspended
+func (b *crashLoopBreaker) isTripped() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tripped
+}
+
+// reset closes the breaker and forgets the crash history
+func (b *crashLoopBreaker) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clear()
+}
+
+// status returns a snapshot of the breaker
+func (b *crashLoopBreaker) status() CrashLoopStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return CrashLoopStatus{
+		Tripped:            b.tripped,
+		ConsecutiveCrashes: b.crashes,
+		MaxCrashes:         b.maxCrashes,
+		LastCrash:          b.lastCrash,
+		TrippedAt:          b.trippedAt,
+	}
+}
+
+// clear must be called with b.mu held
+func (b *crashLoopBreaker) clear() {
+	b.backoff.Reset()
+	b.crashes = 0
+	b.lastCrash = time.Time{}
+	b.tripped = false
+	b.trippedAt = time.Time{}
+}
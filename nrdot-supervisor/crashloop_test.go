@@ -0,0 +1,172 @@
+package supervisor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCrashLoopBreaker_Backoff(t *testing.T) {
+	b := newCrashLoopBreaker(time.Second, 5*time.Second, 4)
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		delay, ok := b.recordCrash(time.Second)
+		if !ok {
+			t.Fatalf("Crash %d: breaker tripped early", i+1)
+		}
+		if delay != want {
+			t.Errorf("Crash %d: expected delay %s, got %s", i+1, want, delay)
+		}
+	}
+
+	// The restart budget is spent, the next crash trips the breaker
+	if _, ok := b.recordCrash(time.Second); ok {
+		t.Fatal("Expected breaker to trip")
+	}
+	status := b.status()
+	if !status.Tripped || status.ConsecutiveCrashes != 5 || status.TrippedAt.IsZero() {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+
+	// Stays open, even after a long run
+	if _, ok := b.recordCrash(time.Hour); ok {
+		t.Error("Tripped breaker allowed a restart")
+	}
+
+	b.reset()
+	if b.isTripped() {
+		t.Error("Breaker still tripped after reset")
+	}
+	if delay, ok := b.recordCrash(time.Second); !ok || delay != time.Second {
+		t.Errorf("Expected backoff to restart at 1s after reset, got %s (ok=%t)", delay, ok)
+	}
+}
+
+func TestCrashLoopBreaker_StableRunClearsHistory(t *testing.T) {
+	b := newCrashLoopBreaker(time.Second, time.Minute, 3)
+
+	b.recordCrash(time.Second)
+	b.recordCrash(time.Second)
+
+	if b.recordRunning(time.Minute) {
+		t.Error("Short run should not clear crash history")
+	}
+	if !b.recordRunning(crashLoopStableRun) {
+		t.Error("Stable run should clear crash history")
+	}
+	if delay, _ := b.recordCrash(time.Second); delay != time.Second {
+		t.Errorf("Expected backoff reset to 1s, got %s", delay)
+	}
+
+	// A crash after a long run starts a new sequence too
+	b.recordCrash(time.Second)
+	if delay, _ := b.recordCrash(crashLoopStableRun); delay != time.Second {
+		t.Errorf("Expected backoff reset to 1s, got %s", delay)
+	}
+	if got := b.status().ConsecutiveCrashes; got != 1 {
+		t.Errorf("Expected 1 consecutive crash, got %d", got)
+	}
+}
+
+func TestCrashLoopBreaker_Unlimited(t *testing.T) {
+	b := newCrashLoopBreaker(time.Millisecond, time.Second, 0)
+	for i := 0; i < 100; i++ {
+		if _, ok := b.recordCrash(0); !ok {
+			t.Fatalf("Unlimited breaker tripped after %d crashes", i+1)
+		}
+	}
+}
+
+func TestUnifiedSupervisor_CrashLoop(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		WorkDir:      t.TempDir(),
+		RestartDelay: time.Millisecond,
+		MaxRestarts:  2,
+		APIEnabled:   true,
+		Logger:       logger,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+
+	// A collector that exits as soon as it starts
+	config := DefaultCollectorConfig()
+	config.BinaryPath = "false"
+	s.collector = NewCollectorProcess(config, logger)
+	s.status.State = models.CollectorStateRunning
+
+	ctx := context.Background()
+	if err := s.collector.Start(ctx); err != nil {
+		t.Fatalf("Failed to start collector: %v", err)
+	}
+	waitForExit(t, s.collector)
+
+	// Restarts are attempted until the breaker trips
+	for i := 0; i < 3; i++ {
+		s.checkHealth(ctx)
+	}
+
+	status, _ := s.GetStatus(ctx)
+	if status.State != models.CollectorStateFailed {
+		t.Errorf("Expected collector state failed, got %s", status.State)
+	}
+	if status.RestartCount != 2 {
+		t.Errorf("Expected 2 restarts, got %d", status.RestartCount)
+	}
+	if status.LastError == nil || status.LastError.Code != "CRASH_LOOP" {
+		t.Errorf("Expected crash loop error, got %+v", status.LastError)
+	}
+
+	health, _ := s.GetHealth(ctx)
+	if health.State != models.HealthStateDegraded || health.Summary == "" {
+		t.Errorf("Expected degraded health with summary, got %s %q", health.State, health.Summary)
+	}
+
+	// No more restart attempts while tripped
+	s.checkHealth(ctx)
+	if status, _ := s.GetStatus(ctx); status.RestartCount != 2 {
+		t.Errorf("Collector restarted while breaker tripped")
+	}
+
+	// Reset through the API; the collector is already back up so it is
+	// left alone
+	config.BinaryPath = filepath.Join(t.TempDir(), "otelcol")
+	if err := os.WriteFile(config.BinaryPath, []byte("#!/bin/sh\nexec sleep 30\n"), 0755); err != nil {
+		t.Fatalf("Failed to write collector script: %v", err)
+	}
+	s.collector = NewCollectorProcess(config, logger)
+	if err := s.collector.Start(ctx); err != nil {
+		t.Fatalf("Failed to start collector: %v", err)
+	}
+	defer s.collector.Stop(ctx)
+
+	rec := httptest.NewRecorder()
+	s.apiServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/control/breaker/reset", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from breaker reset, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if s.CrashLoopStatus().Tripped {
+		t.Error("Breaker still tripped after reset")
+	}
+}
+
+// waitForExit waits for a collector to be reaped
+func waitForExit(t *testing.T, c Collector) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.IsRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("Collector did not exit")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
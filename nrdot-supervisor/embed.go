@@ -0,0 +1,121 @@
+package supervisor
+
+import (
+	"context"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	configengine "github.com/newrelic/nrdot-host/nrdot-config-engine"
+	"go.uber.org/zap"
+)
+
+// The interfaces below are the seams for embedding UnifiedSupervisor in
+// another binary. Each has a default, used when the SupervisorConfig field is
+// nil, that matches what nrdot-host runs.
+
+// ConfigEngine versions the user configuration and generates the collector
+// config from it. *configengine.EngineV2 is the default.
+type ConfigEngine interface {
+	// ApplyConfig validates and stores a new user config version
+	ApplyConfig(ctx context.Context, update *models.ConfigUpdate) (*models.ConfigResult, error)
+
+	// GetGeneratedConfig returns the collector config of the current version
+	GetGeneratedConfig(ctx context.Context) (*models.GeneratedConfig, error)
+
+	// GetConfigHistory returns the latest limit versions, newest first
+	GetConfigHistory(ctx context.Context, limit int) ([]*models.ConfigVersion, error)
+
+	// GetVersionHistory returns the latest limit versions for the API
+	GetVersionHistory(ctx context.Context, limit int) ([]*models.ConfigVersion, error)
+
+	// RollbackToVersion re-applies the user config of version as a new version
+	RollbackToVersion(ctx context.Context, version int, author string) (*models.ConfigResult, error)
+
+	// ExportConfig returns the current user config in format
+	ExportConfig(ctx context.Context, format string) ([]byte, error)
+}
+
+// Collector is a supervised collector instance. *CollectorProcess is the
+// default; a fake lets the supervision logic be tested without processes.
+type Collector interface {
+	// Start launches the collector and returns once it is running
+	Start(ctx context.Context) error
+
+	// Stop shuts the collector down, forcefully once ctx is done
+	Stop(ctx context.Context) error
+
+	// IsRunning reports whether the collector has not exited
+	IsRunning() bool
+
+	// Pid returns the process ID, or 0 if not started
+	Pid() int
+
+	// ConfigPath returns the collector config file the instance runs
+	ConfigPath() string
+}
+
+// CollectorRunner creates collectors and runs the collector binary's
+// one-shot commands. The default runs the binary as a child process.
+type CollectorRunner interface {
+	// NewCollector returns an unstarted collector for config
+	NewCollector(config CollectorConfig, logger *zap.Logger) Collector
+
+	// ValidateConfig checks that binaryPath accepts the config at configPath
+	ValidateConfig(ctx context.Context, binaryPath, configPath string) error
+
+	// Version returns the version of the collector at binaryPath
+	Version(ctx context.Context, binaryPath string) (string, error)
+}
+
+// Clock tells the supervisor the time for status, events and crash-loop and
+// probe timing, and runs the health check interval, the crash restart
+// backoff and the update verification period. clock.Real() is the default;
+// tests pass a clock.Fake to step through them without sleeping. Other
+// pollers, such as pipeline scraping and the systemd watchdog, run on the
+// wall clock.
+type Clock = clock.Clock
+
+// processRunner runs the collector binary as child processes
+type processRunner struct{}
+
+func (processRunner) NewCollector(config CollectorConfig, logger *zap.Logger) Collector {
+	return NewCollectorProcess(config, logger)
+}
+
+func (processRunner) ValidateConfig(ctx context.Context, binaryPath, configPath string) error {
+	return validateCollectorConfig(ctx, binaryPath, configPath)
+}
+
+func (processRunner) Version(ctx context.Context, binaryPath string) (string, error) {
+	return detectCollectorVersion(ctx, binaryPath)
+}
+
+// Ensure the defaults implement the embedding interfaces
+var (
+	_ ConfigEngine    = (*configengine.EngineV2)(nil)
+	_ Collector       = (*CollectorProcess)(nil)
+	_ CollectorRunner = processRunner{}
+)
+
+// now returns the supervisor clock's time
+func (s *UnifiedSupervisor) now() time.Time {
+	return s.timeSource().Now()
+}
+
+// timeSource returns the supervisor clock, the wall clock for a supervisor
+// not created by NewUnifiedSupervisor
+func (s *UnifiedSupervisor) timeSource() Clock {
+	if s.clock == nil {
+		return clock.Real()
+	}
+	return s.clock
+}
+
+// collectorRunner returns the runner collectors are created with
+func (s *UnifiedSupervisor) collectorRunner() CollectorRunner {
+	if s.runner == nil {
+		return processRunner{}
+	}
+	return s.runner
+}
@@ -0,0 +1,223 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeCollector is a collector without a process
+type fakeCollector struct {
+	mu      sync.Mutex
+	config  CollectorConfig
+	pid     int
+	running bool
+
+	// Deadline of the last Stop, zero without one
+	stopDeadline time.Time
+}
+
+func (c *fakeCollector) Start(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running = true
+	return nil
+}
+
+func (c *fakeCollector) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running = false
+	c.stopDeadline, _ = ctx.Deadline()
+	return nil
+}
+
+// crash makes the collector exit as if the process died
+func (c *fakeCollector) crash() {
+	c.Stop(context.Background())
+}
+
+func (c *fakeCollector) IsRunning() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.running
+}
+
+func (c *fakeCollector) Pid() int           { return c.pid }
+func (c *fakeCollector) ConfigPath() string { return c.config.ConfigPath }
+
+// fakeRunner hands out fake collectors and accepts every config unless
+// validate is set
+type fakeRunner struct {
+	mu         sync.Mutex
+	collectors []*fakeCollector
+
+	// validate checks the config file ValidateConfig is given
+	validate func(configPath string) error
+}
+
+func (r *fakeRunner) NewCollector(config CollectorConfig, logger *zap.Logger) Collector {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := &fakeCollector{config: config, pid: 1000 + len(r.collectors)}
+	r.collectors = append(r.collectors, c)
+	return c
+}
+
+func (r *fakeRunner) ValidateConfig(ctx context.Context, binaryPath, configPath string) error {
+	if r.validate != nil {
+		return r.validate(configPath)
+	}
+	return nil
+}
+
+func (r *fakeRunner) Version(ctx context.Context, binaryPath string) (string, error) {
+	return "0.91.0", nil
+}
+
+func (r *fakeRunner) started() []*fakeCollector {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*fakeCollector(nil), r.collectors...)
+}
+
+// stubConfigEngine serves a fixed collector config
+type stubConfigEngine struct {
+	otelConfig string
+}
+
+func (e *stubConfigEngine) ApplyConfig(ctx context.Context, update *models.ConfigUpdate) (*models.ConfigResult, error) {
+	return &models.ConfigResult{Success: true, Version: 1}, nil
+}
+
+func (e *stubConfigEngine) GetGeneratedConfig(ctx context.Context) (*models.GeneratedConfig, error) {
+	return &models.GeneratedConfig{OTelConfig: e.otelConfig, Hash: "stub"}, nil
+}
+
+func (e *stubConfigEngine) GetConfigHistory(ctx context.Context, limit int) ([]*models.ConfigVersion, error) {
+	return nil, nil
+}
+
+func (e *stubConfigEngine) GetVersionHistory(ctx context.Context, limit int) ([]*models.ConfigVersion, error) {
+	return nil, nil
+}
+
+func (e *stubConfigEngine) RollbackToVersion(ctx context.Context, version int, author string) (*models.ConfigResult, error) {
+	return nil, errors.New("no history")
+}
+
+func (e *stubConfigEngine) ExportConfig(ctx context.Context, format string) ([]byte, error) {
+	return nil, nil
+}
+
+func TestUnifiedSupervisor_Embedded(t *testing.T) {
+	runner := &fakeRunner{}
+	clk := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	engine := &stubConfigEngine{otelConfig: "receivers: {}\n"}
+
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		CollectorPath:       "/opt/vendor/otelcol",
+		WorkDir:             t.TempDir(),
+		HealthCheckInterval: 30 * time.Second,
+		RestartDelay:        10 * time.Second,
+		ConfigEngine:        engine,
+		CollectorRunner:     runner,
+		Clock:               clk,
+		Logger:              zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	events, cancel := s.SubscribeEvents(EventFilter{Types: []string{
+		string(models.EventTypeStarted),
+		string(models.EventTypeCrashed),
+	}})
+	defer cancel()
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	defer s.Stop(context.Background())
+
+	started := runner.started()
+	if len(started) != 1 || !started[0].IsRunning() {
+		t.Fatalf("Expected one running collector, got %d", len(started))
+	}
+	if started[0].config.BinaryPath != "/opt/vendor/otelcol" {
+		t.Errorf("Unexpected collector config %+v", started[0].config)
+	}
+	data, err := os.ReadFile(started[0].ConfigPath())
+	if err != nil || string(data) != engine.otelConfig {
+		t.Errorf("Expected the engine's config in %s, got %q, %v", started[0].ConfigPath(), data, err)
+	}
+	if filepath.Dir(started[0].ConfigPath()) != s.config.WorkDir {
+		t.Errorf("Expected config under the work dir, got %s", started[0].ConfigPath())
+	}
+
+	status, _ := s.GetStatus(ctx)
+	if !status.StartTime.Equal(clk.Now()) || status.ConfigHash != "stub" {
+		t.Errorf("Expected start time from the clock and hash from the engine, got %+v", status)
+	}
+	if event := receiveEvent(t, events); event.Details != "PID: 1000" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	// A crash is found by the next health check and restarted through the
+	// runner after the restart delay, all timed by the clock. The health
+	// and restart monitor tickers wait on the clock once started.
+	clk.BlockUntil(2)
+	started[0].crash()
+	clk.Advance(30 * time.Second)
+	if event := receiveEvent(t, events); event.Type != models.EventTypeCrashed {
+		t.Fatalf("Expected component.crashed, got %+v", event)
+	}
+	crashedAt := clk.Now()
+	if crashLoop := s.CrashLoopStatus(); !crashLoop.LastCrash.Equal(crashedAt) {
+		t.Errorf("Expected crash time from the clock, got %s", crashLoop.LastCrash)
+	}
+
+	clk.BlockUntil(3)
+	clk.Advance(9 * time.Second)
+	if n := len(runner.started()); n != 1 {
+		t.Fatalf("Expected no restart before the delay, got %d collectors", n)
+	}
+	clk.Advance(time.Second)
+	if event := receiveEvent(t, events); event.Type != models.EventTypeStarted {
+		t.Fatalf("Expected component.started, got %+v", event)
+	}
+
+	started = runner.started()
+	if len(started) != 2 || !started[1].IsRunning() {
+		t.Fatalf("Expected the collector to be restarted, got %d collectors", len(started))
+	}
+	if status, _ := s.GetStatus(ctx); status.RestartCount != 1 || !status.StartTime.Equal(crashedAt.Add(10*time.Second)) {
+		t.Errorf("Unexpected status after restart %+v", status)
+	}
+}
+
+func TestNewUnifiedSupervisor_Defaults(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	if s.configEngine == nil {
+		t.Error("Expected the default config engine")
+	}
+	if _, ok := s.collectorRunner().(processRunner); !ok {
+		t.Errorf("Expected the process runner, got %T", s.collectorRunner())
+	}
+	if s.clock != clock.Real() {
+		t.Errorf("Expected the wall clock, got %T", s.clock)
+	}
+}
@@ -0,0 +1,354 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/interfaces"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+)
+
+const (
+	// eventBufferSize is how many events a subscriber may fall behind by
+	// before further events are dropped for it
+	eventBufferSize = 256
+
+	// eventKeepAlive is how often an idle event stream sends a comment so
+	// proxies do not close it
+	eventKeepAlive = 30 * time.Second
+)
+
+// eventSeverities orders event severities from least to most severe
+var eventSeverities = []models.EventSeverity{
+	models.EventSeverityInfo,
+	models.EventSeverityWarning,
+	models.EventSeverityError,
+	models.EventSeverityCritical,
+}
+
+// statusEventTypes are the event type prefixes that change the collector
+// status seen by StatusSubscribers
+var statusEventTypes = []string{"component.", "config.", "health."}
+
+// EventFilter selects the events delivered to a subscriber. The zero value
+// matches every event.
+type EventFilter struct {
+	// Types are event types ("health.changed") or type prefixes ending in a
+	// dot ("health."); an event matches if it matches any of them
+	Types []string
+
+	// MinSeverity drops events less severe than it
+	MinSeverity models.EventSeverity
+}
+
+// matches reports whether the filter selects an event
+func (f EventFilter) matches(event *models.Event) bool {
+	if f.MinSeverity != "" && eventSeverityRank(event.Severity) < eventSeverityRank(f.MinSeverity) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if strings.HasSuffix(t, ".") {
+			if strings.HasPrefix(string(event.Type), t) {
+				return true
+			}
+		} else if string(event.Type) == t {
+			return true
+		}
+	}
+	return false
+}
+
+// eventSeverityRank returns the position of a severity in eventSeverities,
+// -1 if unknown
+func eventSeverityRank(severity models.EventSeverity) int {
+	for i, s := range eventSeverities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// EventSubscriber is a StatusSubscriber that also receives the events behind
+// each status change
+type EventSubscriber interface {
+	interfaces.StatusSubscriber
+	OnEvent(event models.Event)
+}
+
+// FilteredSubscriber is a StatusSubscriber that narrows the events it is
+// notified of. Without it a subscriber is notified of lifecycle, config and
+// health events.
+type FilteredSubscriber interface {
+	interfaces.StatusSubscriber
+	EventFilter() EventFilter
+}
+
+// eventSubscription is one subscriber's filter and buffered queue
+type eventSubscription struct {
+	filter  EventFilter
+	ch      chan models.Event
+	dropped atomic.Int64
+}
+
+// eventBus fans supervisor events out to subscribers. Publishing never
+// blocks: a subscriber whose buffer is full misses the event.
+type eventBus struct {
+	metrics *MetricsCollector
+
+	mu          sync.Mutex
+	subscribers map[*eventSubscription]struct{}
+	seq         uint64
+	closed      bool
+}
+
+// newEventBus creates an event bus reporting to metrics, which may be nil
+func newEventBus(metrics *MetricsCollector) *eventBus {
+	return &eventBus{
+		metrics:     metrics,
+		subscribers: make(map[*eventSubscription]struct{}),
+	}
+}
+
+// publish assigns the event an ID and queues it for every matching
+// subscriber
+func (b *eventBus) publish(event models.Event) models.Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return event
+	}
+
+	b.seq++
+	if event.ID == "" {
+		event.ID = fmt.Sprintf("%d", b.seq)
+	}
+	if b.metrics != nil {
+		b.metrics.IncrementEvents()
+	}
+
+	for sub := range b.subscribers {
+		if !sub.filter.matches(&event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			// Slow subscriber, drop rather than block the supervisor
+			sub.dropped.Add(1)
+			if b.metrics != nil {
+				b.metrics.IncrementDroppedEvents()
+			}
+		}
+	}
+	return event
+}
+
+// subscribe returns a subscription receiving every later matching event.
+// Its channel is closed by cancel or when the bus is closed.
+func (b *eventBus) subscribe(filter EventFilter) (*eventSubscription, func()) {
+	sub := &eventSubscription{
+		filter: filter,
+		ch:     make(chan models.Event, eventBufferSize),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(sub.ch)
+		return sub, func() {}
+	}
+	b.subscribers[sub] = struct{}{}
+	b.updateSubscriberCount()
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[sub]; ok {
+			delete(b.subscribers, sub)
+			close(sub.ch)
+			b.updateSubscriberCount()
+		}
+	}
+	return sub, cancel
+}
+
+// close ends every subscription; later events are discarded
+func (b *eventBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		close(sub.ch)
+		delete(b.subscribers, sub)
+	}
+	b.updateSubscriberCount()
+}
+
+// updateSubscriberCount must be called with b.mu held
+func (b *eventBus) updateSubscriberCount() {
+	if b.metrics != nil {
+		b.metrics.SetEventSubscribers(len(b.subscribers))
+	}
+}
+
+// SubscribeEvents returns a channel receiving every later supervisor event
+// selected by filter. Events that arrive while the channel buffer is full
+// are dropped. The channel is closed when the supervisor stops; call cancel
+// to unsubscribe.
+func (s *UnifiedSupervisor) SubscribeEvents(filter EventFilter) (<-chan models.Event, func()) {
+	sub, cancel := s.events.subscribe(filter)
+	return sub.ch, cancel
+}
+
+// Subscribe notifies subscriber with the collector status after each
+// lifecycle, config or health event until ctx is done. Subscribers
+// implementing FilteredSubscriber choose their own events, and those
+// implementing EventSubscriber also receive the event itself. Notifications
+// are delivered in order from a goroutine per subscriber.
+func (s *UnifiedSupervisor) Subscribe(ctx context.Context, subscriber interfaces.StatusSubscriber) error {
+	if subscriber == nil {
+		return fmt.Errorf("subscriber is nil")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	filter := EventFilter{Types: statusEventTypes}
+	if f, ok := subscriber.(FilteredSubscriber); ok {
+		filter = f.EventFilter()
+	}
+	eventSubscriber, _ := subscriber.(EventSubscriber)
+
+	sub, cancel := s.events.subscribe(filter)
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-sub.ch:
+				if !ok {
+					return
+				}
+				if eventSubscriber != nil {
+					eventSubscriber.OnEvent(event)
+				}
+				status, err := s.GetStatus(ctx)
+				if err != nil {
+					continue
+				}
+				subscriber.OnStatusChange(status)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// parseEventFilter reads the type and severity query parameters
+func parseEventFilter(r *http.Request) (EventFilter, error) {
+	var filter EventFilter
+	query := r.URL.Query()
+
+	for _, v := range query["type"] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, t)
+			}
+		}
+	}
+
+	if v := query.Get("severity"); v != "" {
+		severity := models.EventSeverity(strings.ToLower(v))
+		if eventSeverityRank(severity) < 0 {
+			return filter, fmt.Errorf("unknown severity %q", v)
+		}
+		filter.MinSeverity = severity
+	}
+
+	return filter, nil
+}
+
+// handleEventStream serves GET /v1/events/stream as Server-Sent Events.
+//
+// Query parameters:
+//
+//	type=health.,config.changed   only these event types or type prefixes
+//	severity=warning              only events at or above this severity
+func (s *UnifiedSupervisor) handleEventStream(w http.ResponseWriter, r *http.Request) {
+	// Track API request
+	s.metrics.IncrementRequests()
+
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		problem.Error(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Streaming outlives the server's write timeout
+	controller := http.NewResponseController(w)
+	controller.SetWriteDeadline(time.Time{})
+
+	sub, cancel := s.events.subscribe(filter)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	var reported int64
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.ch:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				s.logger.Warn("Failed to encode event", zap.String("type", string(event.Type)), zap.Error(err))
+				continue
+			}
+			// Tell the client how many events it missed by reading too slowly
+			if dropped := sub.dropped.Load(); dropped > reported {
+				if _, err := fmt.Fprintf(w, ": dropped %d events\n", dropped-reported); err != nil {
+					return
+				}
+				reported = dropped
+			}
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return
+			}
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package supervisor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// newEventTestSupervisor returns a supervisor with just enough state to
+// record and deliver events
+func newEventTestSupervisor(t *testing.T) *UnifiedSupervisor {
+	metrics := NewMetricsCollector()
+	return &UnifiedSupervisor{
+		logger:     zaptest.NewLogger(t),
+		metrics:    metrics,
+		events:     newEventBus(metrics),
+		crashLoop:  newCrashLoopBreaker(time.Second, time.Minute, 5),
+		lastHealth: models.HealthStateUnknown,
+		status:     models.CollectorStatus{State: models.CollectorStateRunning, ConfigVersion: 3},
+	}
+}
+
+func receiveEvent(t *testing.T, ch <-chan models.Event) models.Event {
+	t.Helper()
+	select {
+	case event, ok := <-ch:
+		if !ok {
+			t.Fatal("Event channel closed")
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+	return models.Event{}
+}
+
+func TestEventFilter_Matches(t *testing.T) {
+	event := &models.Event{Type: models.EventTypeHealthDegraded, Severity: models.EventSeverityWarning}
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   bool
+	}{
+		{"zero value", EventFilter{}, true},
+		{"exact type", EventFilter{Types: []string{"health.degraded"}}, true},
+		{"prefix", EventFilter{Types: []string{"config.", "health."}}, true},
+		{"other type", EventFilter{Types: []string{"config.changed"}}, false},
+		{"type without dot is not a prefix", EventFilter{Types: []string{"health"}}, false},
+		{"severity met", EventFilter{MinSeverity: models.EventSeverityWarning}, true},
+		{"severity too low", EventFilter{MinSeverity: models.EventSeverityError}, false},
+		{"both", EventFilter{Types: []string{"health."}, MinSeverity: models.EventSeverityInfo}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filter.matches(event); got != tt.want {
+			t.Errorf("%s: expected %t, got %t", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestEventBus_PublishFiltersAndDrops(t *testing.T) {
+	metrics := NewMetricsCollector()
+	bus := newEventBus(metrics)
+
+	health, cancelHealth := bus.subscribe(EventFilter{Types: []string{"health."}})
+	defer cancelHealth()
+	all, cancelAll := bus.subscribe(EventFilter{})
+	defer cancelAll()
+
+	first := bus.publish(models.Event{Type: models.EventTypeStarted, Severity: models.EventSeverityInfo})
+	bus.publish(models.Event{Type: models.EventTypeHealthChanged, Severity: models.EventSeverityWarning})
+
+	if first.ID != "1" {
+		t.Errorf("Expected ID 1, got %q", first.ID)
+	}
+	if event := receiveEvent(t, health.ch); event.Type != models.EventTypeHealthChanged || event.ID != "2" {
+		t.Errorf("Unexpected health event: %+v", event)
+	}
+	if event := receiveEvent(t, all.ch); event.Type != models.EventTypeStarted {
+		t.Errorf("Expected started event first, got %s", event.Type)
+	}
+	receiveEvent(t, all.ch)
+
+	// Fill the buffer; publishing must not block
+	for i := 0; i < eventBufferSize+3; i++ {
+		bus.publish(models.Event{Type: models.EventTypeStarted})
+	}
+	if dropped := all.dropped.Load(); dropped != 3 {
+		t.Errorf("Expected 3 dropped events, got %d", dropped)
+	}
+	if dropped := health.dropped.Load(); dropped != 0 {
+		t.Errorf("Expected filtered subscriber to drop nothing, got %d", dropped)
+	}
+	if dropped := metrics.eventsDropped.Load(); dropped != 3 {
+		t.Errorf("Expected dropped metric 3, got %d", dropped)
+	}
+
+	// Cancel closes the channel and can be called twice
+	cancelHealth()
+	cancelHealth()
+	if _, ok := <-health.ch; ok {
+		t.Error("Expected cancelled subscription to be closed")
+	}
+	if metrics.eventSubscribers != 1 {
+		t.Errorf("Expected 1 subscriber, got %d", metrics.eventSubscribers)
+	}
+
+	// Closing the bus ends the remaining subscriptions
+	bus.close()
+	for range all.ch {
+	}
+	late, _ := bus.subscribe(EventFilter{})
+	if _, ok := <-late.ch; ok {
+		t.Error("Expected subscription after close to be closed")
+	}
+}
+
+type recordingSubscriber struct {
+	mu       sync.Mutex
+	statuses []*models.CollectorStatus
+	events   []models.Event
+	notified chan struct{}
+}
+
+func newRecordingSubscriber() *recordingSubscriber {
+	return &recordingSubscriber{notified: make(chan struct{}, 16)}
+}
+
+func (r *recordingSubscriber) OnStatusChange(status *models.CollectorStatus) {
+	r.mu.Lock()
+	r.statuses = append(r.statuses, status)
+	r.mu.Unlock()
+	r.notified <- struct{}{}
+}
+
+func (r *recordingSubscriber) OnEvent(event models.Event) {
+	r.mu.Lock()
+	r.events = append(r.events, event)
+	r.mu.Unlock()
+}
+
+func (r *recordingSubscriber) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-r.notified:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for status notification")
+	}
+}
+
+type filteredRecordingSubscriber struct {
+	*recordingSubscriber
+	filter EventFilter
+}
+
+func (f filteredRecordingSubscriber) EventFilter() EventFilter {
+	return f.filter
+}
+
+func TestUnifiedSupervisor_Subscribe(t *testing.T) {
+	s := newEventTestSupervisor(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sub := newRecordingSubscriber()
+	if err := s.Subscribe(ctx, sub); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	// Resource events do not change the status, config events do
+	s.recordEvent(models.EventTypeResourceHigh, models.EventSeverityWarning, "high", "")
+	s.recordEvent(models.EventTypeConfigChanged, models.EventSeverityInfo, "changed", "Version 3")
+	sub.wait(t)
+
+	sub.mu.Lock()
+	if len(sub.events) != 1 || sub.events[0].Type != models.EventTypeConfigChanged {
+		t.Errorf("Expected only the config event, got %+v", sub.events)
+	}
+	if sub.statuses[0].ConfigVersion != 3 {
+		t.Errorf("Expected status snapshot, got %+v", sub.statuses[0])
+	}
+	sub.mu.Unlock()
+
+	// A filtered subscriber picks its own events
+	filtered := filteredRecordingSubscriber{
+		recordingSubscriber: newRecordingSubscriber(),
+		filter:              EventFilter{MinSeverity: models.EventSeverityCritical},
+	}
+	if err := s.Subscribe(ctx, filtered); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	s.recordEvent(models.EventTypeCrashed, models.EventSeverityError, "crashed", "")
+	s.recordEvent(models.EventTypeResourceExhausted, models.EventSeverityCritical, "oom", "")
+	filtered.wait(t)
+	sub.wait(t)
+
+	filtered.mu.Lock()
+	if len(filtered.events) != 1 || filtered.events[0].Type != models.EventTypeResourceExhausted {
+		t.Errorf("Expected only the critical event, got %+v", filtered.events)
+	}
+	filtered.mu.Unlock()
+
+	// Cancelling the context unsubscribes
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.metrics.mu.RLock()
+		subscribers := s.metrics.eventSubscribers
+		s.metrics.mu.RUnlock()
+		if subscribers == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected subscribers to be removed, %d left", subscribers)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := s.Subscribe(ctx, newRecordingSubscriber()); err == nil {
+		t.Error("Expected error subscribing with a done context")
+	}
+}
+
+func TestUnifiedSupervisor_ReportHealthChange(t *testing.T) {
+	s := newEventTestSupervisor(t)
+	events, cancel := s.SubscribeEvents(EventFilter{Types: []string{"health."}})
+	defer cancel()
+
+	// No collector: unknown -> unhealthy
+	s.reportHealthChange()
+	event := receiveEvent(t, events)
+	if event.Type != models.EventTypeHealthChanged || event.Severity != models.EventSeverityWarning {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if event.Details != "unknown -> unhealthy" {
+		t.Errorf("Unexpected details %q", event.Details)
+	}
+
+	// Unchanged health records nothing
+	s.reportHealthChange()
+	select {
+	case event := <-events:
+		t.Errorf("Unexpected event: %+v", event)
+	default:
+	}
+}
+
+func TestHandleEventStream(t *testing.T) {
+	s := newEventTestSupervisor(t)
+
+	server := httptest.NewServer(http.HandlerFunc(s.handleEventStream))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?type=component.&severity=error")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected event stream, got %q", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	read := make(chan string)
+	go func() {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(read)
+				return
+			}
+			read <- strings.TrimSuffix(line, "\n")
+		}
+	}()
+
+	// The subscription exists once the headers are flushed
+	s.recordEvent(models.EventTypeStarted, models.EventSeverityInfo, "filtered", "")
+	s.recordEvent(models.EventTypeConfigRejected, models.EventSeverityError, "filtered", "")
+	s.recordEvent(models.EventTypeCrashed, models.EventSeverityError, "Collector exited unexpectedly", "")
+
+	var lines []string
+	for len(lines) < 3 {
+		select {
+		case line := <-read:
+			lines = append(lines, line)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for event, got %q", lines)
+		}
+	}
+
+	if lines[0] != "id: 3" || lines[1] != "event: component.crashed" {
+		t.Errorf("Unexpected event header %q", lines[:2])
+	}
+	var event models.Event
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &event); err != nil {
+		t.Fatalf("Failed to decode event data %q: %v", lines[2], err)
+	}
+	if event.Summary != "Collector exited unexpectedly" || event.Severity != models.EventSeverityError {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	// Stopping the bus ends the stream
+	s.events.close()
+	for {
+		select {
+		case _, ok := <-read:
+			if !ok {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for stream to end")
+		}
+	}
+}
+
+func TestHandleEventStream_BadSeverity(t *testing.T) {
+	s := newEventTestSupervisor(t)
+
+	rec := httptest.NewRecorder()
+	s.handleEventStream(rec, httptest.NewRequest("GET", "/v1/events/stream?severity=loud", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,283 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/auth"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultExecTimeout   = 10 * time.Second
+	defaultExecMaxOutput = 64 * 1024
+	maxExecMaxOutput     = 1024 * 1024
+)
+
+// execEnv is the whole environment of diagnostic commands, so they never
+// see the supervisor's own, which may hold license keys
+var execEnv = []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin", "LC_ALL=C"}
+
+var execNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+var errExecBusy = errors.New("command is already running")
+
+// ExecCommand is a diagnostic command the API may run on the host, such as
+// "ss -lntp" or "df -h". Callers pick commands by name and cannot pass
+// arguments, so the allowlist bounds exactly what runs.
+type ExecCommand struct {
+	// Name identifies the command in /v1/control/exec/{name}
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+
+	// Command is the absolute path of the program and its arguments, run
+	// without a shell
+	Command []string `yaml:"command"`
+
+	// Role is the role needed to run the command (default admin)
+	Role string `yaml:"role"`
+
+	// Timeout kills the command (default 10s); MaxOutput caps the bytes of
+	// stdout and of stderr returned (default 64KiB, at most 1MiB)
+	Timeout   time.Duration `yaml:"timeout"`
+	MaxOutput int           `yaml:"max_output"`
+}
+
+// ExecCommandInfo describes an allowed command to API callers
+type ExecCommandInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Command     []string `json:"command"`
+	Role        string   `json:"role"`
+}
+
+// ExecResult is the outcome of a diagnostic command. A command that ran
+// and exited non-zero is still a result, not an API error.
+type ExecResult struct {
+	Name            string `json:"name"`
+	ExitCode        int    `json:"exit_code"`
+	TimedOut        bool   `json:"timed_out,omitempty"`
+	Stdout          string `json:"stdout"`
+	Stderr          string `json:"stderr"`
+	StdoutTruncated bool   `json:"stdout_truncated,omitempty"`
+	StderrTruncated bool   `json:"stderr_truncated,omitempty"`
+	DurationMs      int64  `json:"duration_ms"`
+}
+
+// LoadExecCommands reads the diagnostic command allowlist from a YAML file
+// with a top-level "commands" list of ExecCommand entries
+func LoadExecCommands(path string) ([]ExecCommand, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exec allowlist: %w", err)
+	}
+
+	var file struct {
+		Commands []ExecCommand `yaml:"commands"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse exec allowlist %s: %w", path, err)
+	}
+	if _, err := newExecAllowlist(file.Commands); err != nil {
+		return nil, fmt.Errorf("invalid exec allowlist %s: %w", path, err)
+	}
+	return file.Commands, nil
+}
+
+// execAllowlist holds the commands the API may run, each run at most once
+// at a time
+type execAllowlist struct {
+	commands map[string]*allowedCommand
+}
+
+type allowedCommand struct {
+	ExecCommand
+	running sync.Mutex
+}
+
+// newExecAllowlist validates commands and fills in their defaults
+func newExecAllowlist(commands []ExecCommand) (*execAllowlist, error) {
+	l := &execAllowlist{commands: make(map[string]*allowedCommand)}
+	for i, cmd := range commands {
+		if !execNamePattern.MatchString(cmd.Name) {
+			return nil, fmt.Errorf("command %d: invalid name %q", i, cmd.Name)
+		}
+		if _, ok := l.commands[cmd.Name]; ok {
+			return nil, fmt.Errorf("command %s: duplicate name", cmd.Name)
+		}
+		if len(cmd.Command) == 0 || !filepath.IsAbs(cmd.Command[0]) {
+			return nil, fmt.Errorf("command %s: command must start with an absolute program path", cmd.Name)
+		}
+		if cmd.Role == "" {
+			cmd.Role = auth.RoleAdmin
+		}
+		if !auth.ValidRole(cmd.Role) {
+			return nil, fmt.Errorf("command %s: invalid role %q", cmd.Name, cmd.Role)
+		}
+		if cmd.Timeout < 0 || cmd.MaxOutput < 0 {
+			return nil, fmt.Errorf("command %s: timeout and max_output must not be negative", cmd.Name)
+		}
+		if cmd.Timeout == 0 {
+			cmd.Timeout = defaultExecTimeout
+		}
+		if cmd.MaxOutput == 0 {
+			cmd.MaxOutput = defaultExecMaxOutput
+		}
+		if cmd.MaxOutput > maxExecMaxOutput {
+			return nil, fmt.Errorf("command %s: max_output must be at most %d", cmd.Name, maxExecMaxOutput)
+		}
+		l.commands[cmd.Name] = &allowedCommand{ExecCommand: cmd}
+	}
+	return l, nil
+}
+
+// list returns the commands role may run, by name
+func (l *execAllowlist) list(role string) []ExecCommandInfo {
+	infos := make([]ExecCommandInfo, 0, len(l.commands))
+	for _, cmd := range l.commands {
+		if auth.HasRole(role, cmd.Role) {
+			infos = append(infos, ExecCommandInfo{
+				Name:        cmd.Name,
+				Description: cmd.Description,
+				Command:     cmd.Command,
+				Role:        cmd.Role,
+			})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// run runs the command unless it is already running, capping its output
+// and killing it after its timeout or once ctx is done
+func (c *allowedCommand) run(ctx context.Context) (*ExecResult, error) {
+	if !c.running.TryLock() {
+		return nil, errExecBusy
+	}
+	defer c.running.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	stdout := &cappedBuffer{max: c.MaxOutput}
+	stderr := &cappedBuffer{max: c.MaxOutput}
+	cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+	cmd.Env = execEnv
+	cmd.Dir = "/"
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Don't wait on children that keep the output pipes open
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	result := &ExecResult{
+		Name:            c.Name,
+		Stdout:          stdout.buf.String(),
+		Stderr:          stderr.buf.String(),
+		StdoutTruncated: stdout.truncated,
+		StderrTruncated: stderr.truncated,
+		DurationMs:      time.Since(start).Milliseconds(),
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		result.TimedOut = true
+		result.ExitCode = -1
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		return nil, fmt.Errorf("failed to run %s: %w", c.Name, err)
+	}
+	return result, nil
+}
+
+// cappedBuffer keeps the first max bytes written to it and discards the
+// rest without failing, so the command is not killed by a broken pipe
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		b.buf.Write(p[:max(room, 0)])
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// handleExecList serves GET /v1/control/exec, the commands the caller may run
+func (s *UnifiedSupervisor) handleExecList(w http.ResponseWriter, r *http.Request) {
+	// Track API request
+	s.metrics.IncrementRequests()
+
+	role, _ := auth.RoleFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.execCommands.list(role))
+}
+
+// handleExec serves POST /v1/control/exec/{name}, running an allowed command
+func (s *UnifiedSupervisor) handleExec(w http.ResponseWriter, r *http.Request) {
+	// Track API request
+	s.metrics.IncrementRequests()
+
+	name := mux.Vars(r)["name"]
+	cmd, ok := s.execCommands.commands[name]
+	if !ok {
+		problem.Error(w, r, fmt.Sprintf("command %q is not in the exec allowlist", name), http.StatusNotFound)
+		return
+	}
+	if role, _ := auth.RoleFromContext(r.Context()); !auth.HasRole(role, cmd.Role) {
+		problem.Error(w, r, fmt.Sprintf("Insufficient permissions: %s role required", cmd.Role), http.StatusForbidden)
+		return
+	}
+
+	result, err := cmd.run(r.Context())
+	switch {
+	case errors.Is(err, errExecBusy):
+		problem.Error(w, r, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		s.logger.Error("Diagnostic command failed", zap.String("command", name), zap.Error(err))
+		problem.Error(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.Info("Ran diagnostic command",
+		zap.String("command", name),
+		zap.String("actor", auditActor(r)),
+		zap.Int("exit_code", result.ExitCode),
+		zap.Bool("timed_out", result.TimedOut))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleExecAuthRequired refuses diagnostic commands on an unauthenticated API
+func (s *UnifiedSupervisor) handleExecAuthRequired(w http.ResponseWriter, r *http.Request) {
+	// Track API request
+	s.metrics.IncrementRequests()
+
+	problem.Error(w, r, "exec API requires authentication; start nrdot-host with --auth", http.StatusForbidden)
+}
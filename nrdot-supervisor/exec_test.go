@@ -0,0 +1,199 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/auth"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestNewExecAllowlist(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []ExecCommand
+		wantErr  string
+	}{
+		{"valid", []ExecCommand{{Name: "disk-usage", Command: []string{"/bin/df", "-h"}}}, ""},
+		{"bad name", []ExecCommand{{Name: "Disk Usage", Command: []string{"/bin/df"}}}, "invalid name"},
+		{"duplicate", []ExecCommand{{Name: "df", Command: []string{"/bin/df"}}, {Name: "df", Command: []string{"/bin/df", "-h"}}}, "duplicate"},
+		{"relative path", []ExecCommand{{Name: "df", Command: []string{"df", "-h"}}}, "absolute"},
+		{"no command", []ExecCommand{{Name: "df"}}, "absolute"},
+		{"bad role", []ExecCommand{{Name: "df", Command: []string{"/bin/df"}, Role: "root"}}, "invalid role"},
+		{"output too large", []ExecCommand{{Name: "df", Command: []string{"/bin/df"}, MaxOutput: 2 * maxExecMaxOutput}}, "max_output"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := newExecAllowlist(tt.commands)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			cmd := l.commands["disk-usage"]
+			if cmd.Role != auth.RoleAdmin || cmd.Timeout != defaultExecTimeout || cmd.MaxOutput != defaultExecMaxOutput {
+				t.Errorf("Expected defaults, got %+v", cmd.ExecCommand)
+			}
+		})
+	}
+}
+
+func TestLoadExecCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exec.yaml")
+	content := `commands:
+  - name: listening-sockets
+    description: Listening TCP sockets
+    command: ["/usr/bin/ss", "-lntp"]
+    role: operator
+    timeout: 5s
+    max_output: 4096
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	commands, err := LoadExecCommands(path)
+	if err != nil {
+		t.Fatalf("Failed to load allowlist: %v", err)
+	}
+	if len(commands) != 1 || commands[0].Role != auth.RoleOperator || commands[0].Timeout != 5*time.Second || commands[0].MaxOutput != 4096 {
+		t.Errorf("Unexpected commands: %+v", commands)
+	}
+
+	// Unknown fields are rejected rather than silently ignored
+	if err := os.WriteFile(path, []byte(content+"    shell: true\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadExecCommands(path); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+}
+
+func TestExecAPI(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		APIEnabled: true,
+		ExecCommands: []ExecCommand{
+			{Name: "echo", Command: []string{"/bin/echo", "hello"}, Role: auth.RoleViewer},
+			{Name: "fail", Command: []string{"/bin/sh", "-c", "echo oops >&2; exit 3"}, Role: auth.RoleOperator},
+			{Name: "big", Command: []string{"/bin/sh", "-c", "head -c 5000 /dev/zero | tr '\\0' x"}, Role: auth.RoleOperator, MaxOutput: 100},
+			{Name: "slow", Command: []string{"/bin/sleep", "5"}, Timeout: 100 * time.Millisecond},
+		},
+		Logger: zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+
+	// Without authentication nothing runs
+	rec := httptest.NewRecorder()
+	s.apiServer.Handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/control/exec/echo", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 without authentication, got %d", rec.Code)
+	}
+
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.Enabled = true
+	authConfig.Type = auth.AuthTypeJWT
+	authConfig.JWT.SecretKey = "test-secret"
+	if err := s.SetupAuthenticatedAPIServer(authConfig); err != nil {
+		t.Fatalf("Failed to set up authentication: %v", err)
+	}
+	handler := s.apiServer.Handler
+
+	do := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	login := func(username, password string) string {
+		rec := do("POST", "/v1/auth/login", "", LoginRequest{Username: username, Password: password})
+		var resp LoginResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to log in as %s: %v", username, err)
+		}
+		return resp.Token
+	}
+	run := func(name, token string) ExecResult {
+		t.Helper()
+		rec := do("POST", "/v1/control/exec/"+name, token, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200 running %s, got %d: %s", name, rec.Code, rec.Body.String())
+		}
+		var result ExecResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Failed to decode result: %v", err)
+		}
+		return result
+	}
+	viewer := login("viewer", "viewer123")
+	operator := login("operator", "operator123")
+	admin := login("admin", "admin123")
+
+	// Callers only see the commands their role may run
+	rec = do("GET", "/v1/control/exec", viewer, nil)
+	var list []ExecCommandInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 1 || list[0].Name != "echo" {
+		t.Errorf("Expected only echo for a viewer, got %s", rec.Body.String())
+	}
+	rec = do("GET", "/v1/control/exec", admin, nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 4 {
+		t.Errorf("Expected every command for an admin, got %s", rec.Body.String())
+	}
+
+	if result := run("echo", viewer); result.ExitCode != 0 || result.Stdout != "hello\n" {
+		t.Errorf("Unexpected echo result: %+v", result)
+	}
+	if rec := do("POST", "/v1/control/exec/fail", viewer, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a viewer, got %d", rec.Code)
+	}
+	if result := run("fail", operator); result.ExitCode != 3 || result.Stderr != "oops\n" {
+		t.Errorf("Unexpected fail result: %+v", result)
+	}
+	if result := run("big", operator); !result.StdoutTruncated || len(result.Stdout) != 100 {
+		t.Errorf("Expected 100 bytes of truncated output, got %d (truncated %v)", len(result.Stdout), result.StdoutTruncated)
+	}
+	if result := run("slow", admin); !result.TimedOut || result.ExitCode != -1 {
+		t.Errorf("Expected a timeout, got %+v", result)
+	}
+	if rec := do("POST", "/v1/control/exec/rm", admin, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a command outside the allowlist, got %d", rec.Code)
+	}
+
+	// Runs, including denied ones, are audited with the command name
+	page := s.audit.query(AuditQuery{Action: "host.exec", Limit: 10})
+	if page.Total != 7 {
+		t.Fatalf("Expected 7 audited runs, got %d", page.Total)
+	}
+	if denied := page.Entries[4]; denied.Actor != "viewer" || denied.Target != "fail" || denied.Status != http.StatusForbidden {
+		t.Errorf("Unexpected denied entry: %+v", denied)
+	}
+}
+
+func TestExecBusy(t *testing.T) {
+	l, err := newExecAllowlist([]ExecCommand{{Name: "echo", Command: []string{"/bin/echo"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := l.commands["echo"]
+	cmd.running.Lock()
+	defer cmd.running.Unlock()
+	if _, err := cmd.run(context.Background()); err != errExecBusy {
+		t.Errorf("Expected errExecBusy, got %v", err)
+	}
+}
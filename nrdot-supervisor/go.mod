@@ -3,6 +3,7 @@ module github.com/newrelic/nrdot-host/nrdot-supervisor
 go 1.21
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/gorilla/mux v1.8.1
 	github.com/newrelic/nrdot-host/nrdot-api-server v0.0.0-00010101000000-000000000000
 	github.com/newrelic/nrdot-host/nrdot-common v0.0.0-00010101000000-000000000000
@@ -10,6 +11,8 @@ require (
 	github.com/newrelic/nrdot-host/nrdot-telemetry-client v0.0.0-00010101000000-000000000000
 	github.com/spf13/pflag v1.0.5
 	go.uber.org/zap v1.27.0
+	golang.org/x/crypto v0.19.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
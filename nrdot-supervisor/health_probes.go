@@ -0,0 +1,526 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/interfaces"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+)
+
+// HealthProbeType identifies what a health probe checks
+type HealthProbeType string
+
+const (
+	// HealthProbeHTTP requests the collector's health_check endpoint
+	HealthProbeHTTP HealthProbeType = "http"
+	// HealthProbeQueue compares exporter queue fill to a threshold
+	HealthProbeQueue HealthProbeType = "queue_saturation"
+	// HealthProbeDataFlow fails when no items were exported for a while
+	HealthProbeDataFlow HealthProbeType = "data_flow"
+)
+
+// Default probe thresholds
+const (
+	defaultHTTPProbeTimeout       = 5 * time.Second
+	defaultHTTPDegradedFailures   = 1
+	defaultHTTPUnhealthyFailures  = 3
+	defaultQueueDegradedRatio     = 0.8
+	defaultQueueUnhealthyRatio    = 0.95
+	defaultDataFlowDegradedAfter  = 5 * time.Minute
+	defaultDataFlowUnhealthyAfter = 15 * time.Minute
+)
+
+// HealthProbeConfig configures one collector health probe. Each probe
+// reports Healthy, Degraded or Unhealthy from its own thresholds; the
+// collector health is the worst of them. Zero values take the defaults.
+type HealthProbeConfig struct {
+	// Name identifies the probe in health checks, by default
+	// collector-http, exporter-queue or data-flow
+	Name string
+	Type HealthProbeType
+
+	// http: the URL to request, by default the health_check extension of
+	// the running collector config, and the request timeout
+	URL     string
+	Timeout time.Duration
+
+	// http: consecutive failed requests before Degraded and Unhealthy
+	// (defaults 1 and 3)
+	DegradedFailures  int
+	UnhealthyFailures int
+
+	// queue_saturation: exporter queue fill, 0-1, at which a pipeline is
+	// Degraded and Unhealthy (defaults 0.8 and 0.95)
+	DegradedRatio  float64
+	UnhealthyRatio float64
+
+	// data_flow: time without exported items before Degraded and Unhealthy
+	// (defaults 5m and 15m)
+	DegradedAfter  time.Duration
+	UnhealthyAfter time.Duration
+}
+
+// DefaultHealthProbes returns one probe of each type with default thresholds
+func DefaultHealthProbes() []HealthProbeConfig {
+	return []HealthProbeConfig{
+		{Type: HealthProbeHTTP},
+		{Type: HealthProbeQueue},
+		{Type: HealthProbeDataFlow},
+	}
+}
+
+// ParseHealthProbes parses a comma-separated probe list such as
+// "http:1:3,queue_saturation:0.8:0.95,data_flow:5m:15m". Each entry is a
+// probe type optionally followed by its Degraded and Unhealthy thresholds:
+// failures for http, a fill ratio for queue_saturation and a duration for
+// data_flow. "none" disables probing; an empty string means the defaults.
+func ParseHealthProbes(s string) ([]HealthProbeConfig, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if s == "none" {
+		return []HealthProbeConfig{}, nil
+	}
+
+	var probes []HealthProbeConfig
+	seen := make(map[HealthProbeType]bool)
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("invalid health probe %q: expected type[:degraded[:unhealthy]]", entry)
+		}
+
+		probe := HealthProbeConfig{Type: HealthProbeType(parts[0])}
+		if seen[probe.Type] {
+			return nil, fmt.Errorf("duplicate health probe %q", probe.Type)
+		}
+		seen[probe.Type] = true
+		thresholds := parts[1:]
+
+		var err error
+		switch probe.Type {
+		case HealthProbeHTTP:
+			if len(thresholds) > 0 {
+				probe.DegradedFailures, err = strconv.Atoi(thresholds[0])
+			}
+			if err == nil && len(thresholds) > 1 {
+				probe.UnhealthyFailures, err = strconv.Atoi(thresholds[1])
+			}
+		case HealthProbeQueue:
+			if len(thresholds) > 0 {
+				probe.DegradedRatio, err = strconv.ParseFloat(thresholds[0], 64)
+			}
+			if err == nil && len(thresholds) > 1 {
+				probe.UnhealthyRatio, err = strconv.ParseFloat(thresholds[1], 64)
+			}
+		case HealthProbeDataFlow:
+			if len(thresholds) > 0 {
+				probe.DegradedAfter, err = time.ParseDuration(thresholds[0])
+			}
+			if err == nil && len(thresholds) > 1 {
+				probe.UnhealthyAfter, err = time.ParseDuration(thresholds[1])
+			}
+		default:
+			return nil, fmt.Errorf("unknown health probe type %q: use http, queue_saturation or data_flow", parts[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s probe threshold: %w", probe.Type, err)
+		}
+		if err := probe.validate(); err != nil {
+			return nil, err
+		}
+		probes = append(probes, probe)
+	}
+	return probes, nil
+}
+
+// validate checks the thresholds of a probe after defaults are applied
+func (p HealthProbeConfig) validate() error {
+	p = p.withDefaults()
+	switch p.Type {
+	case HealthProbeHTTP:
+		if p.DegradedFailures < 1 || p.UnhealthyFailures < p.DegradedFailures {
+			return fmt.Errorf("http probe: need 1 <= degraded (%d) <= unhealthy (%d) failures", p.DegradedFailures, p.UnhealthyFailures)
+		}
+	case HealthProbeQueue:
+		if p.DegradedRatio <= 0 || p.UnhealthyRatio > 1 || p.UnhealthyRatio < p.DegradedRatio {
+			return fmt.Errorf("queue_saturation probe: need 0 < degraded (%g) <= unhealthy (%g) <= 1", p.DegradedRatio, p.UnhealthyRatio)
+		}
+	case HealthProbeDataFlow:
+		if p.DegradedAfter <= 0 || p.UnhealthyAfter < p.DegradedAfter {
+			return fmt.Errorf("data_flow probe: need 0 < degraded (%s) <= unhealthy (%s)", p.DegradedAfter, p.UnhealthyAfter)
+		}
+	default:
+		return fmt.Errorf("unknown health probe type %q", p.Type)
+	}
+	return nil
+}
+
+// withDefaults fills in unset thresholds
+func (p HealthProbeConfig) withDefaults() HealthProbeConfig {
+	switch p.Type {
+	case HealthProbeHTTP:
+		if p.Name == "" {
+			p.Name = "collector-http"
+		}
+		if p.Timeout <= 0 {
+			p.Timeout = defaultHTTPProbeTimeout
+		}
+		if p.DegradedFailures == 0 {
+			p.DegradedFailures = defaultHTTPDegradedFailures
+		}
+		if p.UnhealthyFailures == 0 {
+			p.UnhealthyFailures = max(defaultHTTPUnhealthyFailures, p.DegradedFailures)
+		}
+	case HealthProbeQueue:
+		if p.Name == "" {
+			p.Name = "exporter-queue"
+		}
+		if p.DegradedRatio == 0 {
+			p.DegradedRatio = defaultQueueDegradedRatio
+		}
+		if p.UnhealthyRatio == 0 {
+			p.UnhealthyRatio = max(defaultQueueUnhealthyRatio, p.DegradedRatio)
+		}
+	case HealthProbeDataFlow:
+		if p.Name == "" {
+			p.Name = "data-flow"
+		}
+		if p.DegradedAfter == 0 {
+			p.DegradedAfter = defaultDataFlowDegradedAfter
+		}
+		if p.UnhealthyAfter == 0 {
+			p.UnhealthyAfter = max(defaultDataFlowUnhealthyAfter, p.DegradedAfter)
+		}
+	}
+	return p
+}
+
+// probeInput is what the probes see of the supervisor on one run
+type probeInput struct {
+	// collectorStart is when the running collector started
+	collectorStart time.Time
+
+	// healthURL is the health_check extension of the running config
+	healthURL string
+
+	// pipelines is the last scraped pipeline status
+	pipelines []models.PipelineStatus
+}
+
+// healthProber runs the configured probes and registered checks and keeps
+// their latest results
+type healthProber struct {
+	probes []HealthProbeConfig
+
+	mu           sync.Mutex
+	checks       map[string]interfaces.HealthCheck
+	httpFailures map[string]int
+	exported     int64
+	lastExport   time.Time
+	results      []models.HealthCheckResult
+	state        models.HealthState
+	message      string
+
+	// now is the clock, replaceable in tests
+	now func() time.Time
+}
+
+// newHealthProber creates a prober; nil probes means DefaultHealthProbes
+func newHealthProber(probes []HealthProbeConfig) (*healthProber, error) {
+	if probes == nil {
+		probes = DefaultHealthProbes()
+	} else {
+		probes = append(make([]HealthProbeConfig, 0, len(probes)), probes...)
+	}
+	names := make(map[string]bool)
+	for i := range probes {
+		if err := probes[i].validate(); err != nil {
+			return nil, err
+		}
+		probes[i] = probes[i].withDefaults()
+		if names[probes[i].Name] {
+			return nil, fmt.Errorf("duplicate health probe name %q", probes[i].Name)
+		}
+		names[probes[i].Name] = true
+	}
+
+	return &healthProber{
+		probes:       probes,
+		checks:       make(map[string]interfaces.HealthCheck),
+		httpFailures: make(map[string]int),
+		state:        models.HealthStateHealthy,
+		now:          time.Now,
+	}, nil
+}
+
+// register adds a named check; an error from it reports Degraded
+func (p *healthProber) register(name string, check interfaces.HealthCheck) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if check == nil {
+		delete(p.checks, name)
+		return
+	}
+	p.checks[name] = check
+}
+
+// run runs every probe and check and records the worst state
+func (p *healthProber) run(ctx context.Context, input probeInput) {
+	var results []models.HealthCheckResult
+	for _, probe := range p.probes {
+		start := p.now()
+		var state models.HealthState
+		var message string
+		switch probe.Type {
+		case HealthProbeHTTP:
+			state, message = p.probeHTTP(ctx, probe, input)
+		case HealthProbeQueue:
+			state, message = probeQueue(probe, input)
+		case HealthProbeDataFlow:
+			state, message = p.probeDataFlow(probe, input)
+		}
+		results = append(results, probeResult(probe.Name, string(probe.Type), state, message, start, p.now()))
+	}
+
+	p.mu.Lock()
+	checks := make(map[string]interfaces.HealthCheck, len(p.checks))
+	for name, check := range p.checks {
+		checks[name] = check
+	}
+	p.mu.Unlock()
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		start := p.now()
+		state, message := models.HealthStateHealthy, "ok"
+		if err := checks[name](ctx); err != nil {
+			state, message = models.HealthStateDegraded, err.Error()
+		}
+		results = append(results, probeResult(name, "check", state, message, start, p.now()))
+	}
+
+	// The worst probe decides; probes without data do not count
+	state, message := models.HealthStateHealthy, ""
+	for _, result := range results {
+		resultState := models.HealthState(result.Metadata["state"])
+		if healthStateRank(resultState) > healthStateRank(state) {
+			state = resultState
+			message = result.Name + ": " + result.Message
+		}
+	}
+
+	p.mu.Lock()
+	p.results = results
+	p.state = state
+	p.message = message
+	p.mu.Unlock()
+}
+
+// reset forgets the results and failure counts of earlier runs
+func (p *healthProber) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.httpFailures = make(map[string]int)
+	p.results = nil
+	p.state = models.HealthStateHealthy
+	p.message = ""
+}
+
+// status returns the collector health according to the probes and the
+// message of the worst probe
+func (p *healthProber) status() (models.HealthState, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state, p.message
+}
+
+// lastResults returns the results of the latest run
+func (p *healthProber) lastResults() []models.HealthCheckResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	results := make([]models.HealthCheckResult, len(p.results))
+	copy(results, p.results)
+	return results
+}
+
+// probeHTTP requests the collector health endpoint, counting consecutive
+// failures
+func (p *healthProber) probeHTTP(ctx context.Context, probe HealthProbeConfig, input probeInput) (models.HealthState, string) {
+	url := probe.URL
+	if url == "" {
+		url = input.healthURL
+	}
+	if url == "" {
+		return models.HealthStateUnknown, "collector config has no health_check extension"
+	}
+
+	checker := NewHealthChecker(HealthCheckerConfig{Endpoint: url, Timeout: probe.Timeout}, nil)
+	err := checker.Check(ctx)
+
+	p.mu.Lock()
+	if err == nil {
+		p.httpFailures[probe.Name] = 0
+	} else {
+		p.httpFailures[probe.Name]++
+	}
+	failures := p.httpFailures[probe.Name]
+	p.mu.Unlock()
+
+	switch {
+	case err == nil:
+		return models.HealthStateHealthy, "health endpoint OK"
+	case failures >= probe.UnhealthyFailures:
+		return models.HealthStateUnhealthy, fmt.Sprintf("%d consecutive failures: %v", failures, err)
+	case failures >= probe.DegradedFailures:
+		return models.HealthStateDegraded, fmt.Sprintf("%d consecutive failures: %v", failures, err)
+	default:
+		return models.HealthStateHealthy, fmt.Sprintf("%d consecutive failures: %v", failures, err)
+	}
+}
+
+// probeQueue reports the most saturated pipeline exporter queue
+func probeQueue(probe HealthProbeConfig, input probeInput) (models.HealthState, string) {
+	worst := -1.0
+	worstPipeline := ""
+	for _, pipeline := range input.pipelines {
+		if pipeline.Metrics.QueueCapacity <= 0 {
+			continue
+		}
+		ratio := float64(pipeline.Metrics.QueueSize) / float64(pipeline.Metrics.QueueCapacity)
+		if ratio > worst {
+			worst = ratio
+			worstPipeline = pipeline.Name
+		}
+	}
+	if worst < 0 {
+		return models.HealthStateUnknown, "no exporter queue telemetry"
+	}
+
+	message := fmt.Sprintf("pipeline %s exporter queue %.0f%% full", worstPipeline, worst*100)
+	switch {
+	case worst >= probe.UnhealthyRatio:
+		return models.HealthStateUnhealthy, message
+	case worst >= probe.DegradedRatio:
+		return models.HealthStateDegraded, message
+	default:
+		return models.HealthStateHealthy, message
+	}
+}
+
+// probeDataFlow reports how long ago the collector last exported items
+func (p *healthProber) probeDataFlow(probe HealthProbeConfig, input probeInput) (models.HealthState, string) {
+	var exported int64
+	scraped := false
+	for _, pipeline := range input.pipelines {
+		if pipeline.State == pipelineStateUnknown || pipeline.State == pipelineStateStopped {
+			continue
+		}
+		scraped = true
+		exported += pipeline.Metrics.ItemsExported
+	}
+
+	now := p.now()
+	p.mu.Lock()
+	switch {
+	case p.lastExport.IsZero() || p.lastExport.Before(input.collectorStart):
+		// First run or a restarted collector: measure from its start
+		p.lastExport = input.collectorStart
+		if p.lastExport.IsZero() {
+			p.lastExport = now
+		}
+	case exported != p.exported:
+		// Progress, or counters reset underneath us
+		p.lastExport = now
+	}
+	p.exported = exported
+	idle := now.Sub(p.lastExport)
+	p.mu.Unlock()
+
+	if !scraped {
+		return models.HealthStateUnknown, "no pipeline telemetry"
+	}
+
+	message := fmt.Sprintf("%d items exported, last progress %s ago", exported, idle.Round(time.Second))
+	switch {
+	case idle >= probe.UnhealthyAfter:
+		return models.HealthStateUnhealthy, message
+	case idle >= probe.DegradedAfter:
+		return models.HealthStateDegraded, message
+	default:
+		return models.HealthStateHealthy, message
+	}
+}
+
+// probeResult builds the HealthCheckResult of a probe run
+func probeResult(name, probeType string, state models.HealthState, message string, start, end time.Time) models.HealthCheckResult {
+	status := "pass"
+	switch state {
+	case models.HealthStateDegraded, models.HealthStateUnknown:
+		status = "warn"
+	case models.HealthStateUnhealthy:
+		status = "fail"
+	}
+	return models.HealthCheckResult{
+		Name:      name,
+		Type:      "readiness",
+		Status:    status,
+		Timestamp: end,
+		Duration:  end.Sub(start),
+		Message:   message,
+		Metadata: map[string]string{
+			"probe": probeType,
+			"state": string(state),
+		},
+	}
+}
+
+// healthStateRank orders health states by severity; unknown does not count
+// against the collector
+func healthStateRank(state models.HealthState) int {
+	switch state {
+	case models.HealthStateDegraded:
+		return 1
+	case models.HealthStateUnhealthy:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// runHealthProbes probes the running collector
+func (s *UnifiedSupervisor) runHealthProbes(ctx context.Context) {
+	s.mu.RLock()
+	collector := s.collector
+	input := probeInput{
+		collectorStart: s.status.StartTime,
+		pipelines:      s.status.Pipelines,
+	}
+	s.mu.RUnlock()
+
+	if collector == nil || !collector.IsRunning() {
+		// Results of a previous collector do not carry over
+		s.probes.reset()
+		return
+	}
+
+	if data, err := os.ReadFile(s.activeConfigPath()); err == nil {
+		if topology, err := parseCollectorTopology(data); err == nil {
+			input.healthURL = topology.HealthURL
+		}
+	}
+
+	s.probes.run(ctx, input)
+}
@@ -0,0 +1,266 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+)
+
+func TestParseHealthProbes(t *testing.T) {
+	probes, err := ParseHealthProbes("http:2:4, queue_saturation:0.5, data_flow:1m:10m")
+	if err != nil {
+		t.Fatalf("Failed to parse probes: %v", err)
+	}
+	if len(probes) != 3 {
+		t.Fatalf("Expected 3 probes, got %d", len(probes))
+	}
+	if probes[0].Type != HealthProbeHTTP || probes[0].DegradedFailures != 2 || probes[0].UnhealthyFailures != 4 {
+		t.Errorf("Unexpected http probe %+v", probes[0])
+	}
+	if probes[1].DegradedRatio != 0.5 || probes[1].UnhealthyRatio != 0 {
+		t.Errorf("Unexpected queue probe %+v", probes[1])
+	}
+	if probes[2].DegradedAfter != time.Minute || probes[2].UnhealthyAfter != 10*time.Minute {
+		t.Errorf("Unexpected data flow probe %+v", probes[2])
+	}
+
+	if probes, err := ParseHealthProbes(""); err != nil || probes != nil {
+		t.Errorf("Expected defaults for an empty spec, got %+v, %v", probes, err)
+	}
+	if probes, err := ParseHealthProbes("none"); err != nil || probes == nil || len(probes) != 0 {
+		t.Errorf("Expected no probes for none, got %+v, %v", probes, err)
+	}
+
+	invalid := []string{
+		"tcp",
+		"http:x",
+		"http:3:1",
+		"queue_saturation:0.9:0.5",
+		"queue_saturation:0.8:1.5",
+		"data_flow:soon",
+		"data_flow:10m:5m",
+		"http,http",
+		"http:1:2:3",
+	}
+	for _, spec := range invalid {
+		if _, err := ParseHealthProbes(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestHealthProber_HTTP(t *testing.T) {
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	prober, err := newHealthProber([]HealthProbeConfig{
+		{Type: HealthProbeHTTP, UnhealthyFailures: 2},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create prober: %v", err)
+	}
+	ctx := context.Background()
+	input := probeInput{healthURL: server.URL}
+
+	// Failures degrade first, then make the collector unhealthy
+	expected := []models.HealthState{models.HealthStateDegraded, models.HealthStateUnhealthy}
+	for _, want := range expected {
+		prober.run(ctx, input)
+		if state, _ := prober.status(); state != want {
+			t.Fatalf("Expected %s, got %s", want, state)
+		}
+	}
+	results := prober.lastResults()
+	if len(results) != 1 || results[0].Status != "fail" || results[0].Metadata["probe"] != "http" {
+		t.Errorf("Unexpected results %+v", results)
+	}
+
+	// One success clears the failure count
+	healthy.Store(true)
+	prober.run(ctx, input)
+	if state, message := prober.status(); state != models.HealthStateHealthy || message != "" {
+		t.Errorf("Expected healthy, got %s %q", state, message)
+	}
+
+	// Without a health_check extension the probe has nothing to say
+	prober.run(ctx, probeInput{})
+	if state, _ := prober.status(); state != models.HealthStateHealthy {
+		t.Errorf("Expected unknown probe not to count, got %s", state)
+	}
+	if results := prober.lastResults(); results[0].Status != "warn" {
+		t.Errorf("Expected warn result, got %+v", results[0])
+	}
+}
+
+func TestHealthProber_QueueSaturation(t *testing.T) {
+	prober, err := newHealthProber([]HealthProbeConfig{{Type: HealthProbeQueue}})
+	if err != nil {
+		t.Fatalf("Failed to create prober: %v", err)
+	}
+
+	pipelines := func(size int64) []models.PipelineStatus {
+		return []models.PipelineStatus{
+			{Name: "metrics", Metrics: models.PipelineMetrics{QueueSize: 10, QueueCapacity: 1000}},
+			{Name: "traces", Metrics: models.PipelineMetrics{QueueSize: size, QueueCapacity: 1000}},
+		}
+	}
+
+	tests := []struct {
+		size int64
+		want models.HealthState
+	}{
+		{size: 100, want: models.HealthStateHealthy},
+		{size: 850, want: models.HealthStateDegraded},
+		{size: 1000, want: models.HealthStateUnhealthy},
+	}
+	for _, tt := range tests {
+		prober.run(context.Background(), probeInput{pipelines: pipelines(tt.size)})
+		if state, _ := prober.status(); state != tt.want {
+			t.Errorf("Queue size %d: expected %s, got %s", tt.size, tt.want, state)
+		}
+	}
+
+	// The fullest queue is reported
+	if _, message := prober.status(); message != "exporter-queue: pipeline traces exporter queue 100% full" {
+		t.Errorf("Unexpected message %q", message)
+	}
+}
+
+func TestHealthProber_DataFlow(t *testing.T) {
+	prober, err := newHealthProber([]HealthProbeConfig{
+		{Type: HealthProbeDataFlow, DegradedAfter: time.Minute, UnhealthyAfter: 5 * time.Minute},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create prober: %v", err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	prober.now = func() time.Time { return now }
+
+	start := now
+	input := func(exported int64) probeInput {
+		return probeInput{
+			collectorStart: start,
+			pipelines: []models.PipelineStatus{
+				{Name: "metrics", State: pipelineStateRunning, Metrics: models.PipelineMetrics{ItemsExported: exported}},
+			},
+		}
+	}
+	ctx := context.Background()
+	check := func(exported int64, want models.HealthState) {
+		t.Helper()
+		prober.run(ctx, input(exported))
+		if state, _ := prober.status(); state != want {
+			t.Errorf("At %s with %d exported: expected %s, got %s", now.Format(time.Kitchen), exported, want, state)
+		}
+	}
+
+	check(0, models.HealthStateHealthy)
+	now = now.Add(2 * time.Minute)
+	check(0, models.HealthStateDegraded)
+	now = now.Add(4 * time.Minute)
+	check(0, models.HealthStateUnhealthy)
+
+	// Progress resets the timer
+	check(100, models.HealthStateHealthy)
+	now = now.Add(2 * time.Minute)
+	check(100, models.HealthStateDegraded)
+
+	// A restarted collector starts from zero and gets a fresh grace period
+	start = now
+	now = now.Add(30 * time.Second)
+	check(0, models.HealthStateHealthy)
+
+	// Without scraped pipelines the probe does not count
+	now = now.Add(time.Hour)
+	prober.run(ctx, probeInput{collectorStart: start})
+	if state, _ := prober.status(); state != models.HealthStateHealthy {
+		t.Errorf("Expected unknown probe not to count, got %s", state)
+	}
+}
+
+func TestHealthProber_RegisteredChecks(t *testing.T) {
+	prober, err := newHealthProber([]HealthProbeConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create prober: %v", err)
+	}
+
+	prober.register("disk", func(ctx context.Context) error { return errors.New("disk almost full") })
+	prober.register("ok", func(ctx context.Context) error { return nil })
+	prober.run(context.Background(), probeInput{})
+
+	state, message := prober.status()
+	if state != models.HealthStateDegraded || message != "disk: disk almost full" {
+		t.Errorf("Expected degraded from the failing check, got %s %q", state, message)
+	}
+	results := prober.lastResults()
+	if len(results) != 2 || results[0].Name != "disk" || results[1].Status != "pass" {
+		t.Errorf("Unexpected results %+v", results)
+	}
+
+	prober.register("disk", nil)
+	prober.run(context.Background(), probeInput{})
+	if state, _ := prober.status(); state != models.HealthStateHealthy {
+		t.Errorf("Expected healthy after removing the check, got %s", state)
+	}
+}
+
+func TestUnifiedSupervisor_HealthProbes(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		HealthProbes: []HealthProbeConfig{{Type: HealthProbeQueue}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	if _, err := NewUnifiedSupervisor(SupervisorConfig{
+		HealthProbes: []HealthProbeConfig{{Type: "tcp"}},
+	}); err == nil {
+		t.Error("Expected error for an unknown probe type")
+	}
+
+	// A long-running collector stands in for the real one
+	config := DefaultCollectorConfig()
+	config.BinaryPath = "sleep"
+	config.Args = []string{"30"}
+	s.collector = NewCollectorProcess(config, s.logger)
+	ctx := context.Background()
+	if err := s.collector.Start(ctx); err != nil {
+		t.Fatalf("Failed to start collector: %v", err)
+	}
+	defer s.collector.Stop(ctx)
+
+	// Past startup, so the probes alone decide readiness
+	s.configLoaded = true
+	s.pipelinesActive = true
+	s.status.Pipelines = []models.PipelineStatus{
+		{Name: "metrics", Metrics: models.PipelineMetrics{QueueSize: 900, QueueCapacity: 1000}},
+	}
+	s.runHealthProbes(ctx)
+
+	health, _ := s.GetHealth(ctx)
+	if health.State != models.HealthStateDegraded || !health.ReadinessProbe {
+		t.Errorf("Expected degraded but ready, got %s ready=%v", health.State, health.ReadinessProbe)
+	}
+	if len(health.Checks) != 1 || health.Checks[0].Status != "warn" {
+		t.Errorf("Unexpected checks %+v", health.Checks)
+	}
+	if collector := health.Components[2]; collector.Message == "" {
+		t.Errorf("Expected probe message on the collector component, got %+v", collector)
+	}
+
+	s.status.Pipelines[0].Metrics.QueueSize = 1000
+	s.runHealthProbes(ctx)
+	if health, _ := s.GetHealth(ctx); health.State != models.HealthStateUnhealthy || health.ReadinessProbe {
+		t.Errorf("Expected unhealthy and not ready, got %s ready=%v", health.State, health.ReadinessProbe)
+	}
+}
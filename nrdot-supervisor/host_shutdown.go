@@ -0,0 +1,157 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultHostShutdownFlushTimeout is how long the collector gets to
+	// flush its exporters when the host shuts down
+	DefaultHostShutdownFlushTimeout = 60 * time.Second
+
+	// defaultCollectorStopTimeout is how long the collector gets to stop
+	// when the supervisor stops otherwise
+	defaultCollectorStopTimeout = 30 * time.Second
+
+	// hostShutdownCheckTimeout bounds asking systemd about a shutdown
+	hostShutdownCheckTimeout = 2 * time.Second
+)
+
+// Kinds of host shutdown
+const (
+	HostShutdownReboot     = "reboot"
+	HostShutdownSoftReboot = "soft-reboot"
+	HostShutdownPoweroff   = "poweroff"
+	HostShutdownHalt       = "halt"
+	HostShutdownKexec      = "kexec"
+
+	// HostShutdownUnknown is a shutdown of unknown kind
+	HostShutdownUnknown = "shutdown"
+)
+
+// HostShutdown is a host shutdown in progress
+type HostShutdown struct {
+	// Kind is one of the HostShutdown kinds, e.g. reboot
+	Kind string
+}
+
+// HostShutdownDetector tells whether the host is shutting down, so the
+// supervisor stopping is a planned shutdown rather than the agent going
+// away
+type HostShutdownDetector interface {
+	// HostShutdown returns the shutdown in progress, nil if there is none
+	HostShutdown(ctx context.Context) (*HostShutdown, error)
+}
+
+// shutdownTargets maps the systemd targets of a shutdown to its kind, most
+// specific first; shutdown.target is queued with every one of them
+var shutdownTargets = []struct {
+	unit string
+	kind string
+}{
+	{"reboot.target", HostShutdownReboot},
+	{"soft-reboot.target", HostShutdownSoftReboot},
+	{"poweroff.target", HostShutdownPoweroff},
+	{"halt.target", HostShutdownHalt},
+	{"kexec.target", HostShutdownKexec},
+	{"shutdown.target", HostShutdownUnknown},
+}
+
+// systemdShutdownDetector detects a shutdown from the jobs systemd has
+// queued: when the service is stopped because the host goes down, a start
+// job for a shutdown target such as reboot.target is pending.
+type systemdShutdownDetector struct{}
+
+// HostShutdown implements HostShutdownDetector
+func (systemdShutdownDetector) HostShutdown(ctx context.Context) (*HostShutdown, error) {
+	ctx, cancel := context.WithTimeout(ctx, hostShutdownCheckTimeout)
+	defer cancel()
+
+	jobs, err := exec.CommandContext(ctx, "systemctl", "list-jobs", "--no-legend").Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing systemd jobs: %w", err)
+	}
+	if kind := shutdownKindFromJobs(string(jobs)); kind != "" {
+		return &HostShutdown{Kind: kind}, nil
+	}
+
+	// The jobs may already be done; the manager still reports stopping.
+	// is-system-running exits non-zero in every state but running.
+	state, _ := exec.CommandContext(ctx, "systemctl", "is-system-running").Output()
+	if strings.TrimSpace(string(state)) == "stopping" {
+		return &HostShutdown{Kind: HostShutdownUnknown}, nil
+	}
+	return nil, nil
+}
+
+// shutdownKindFromJobs returns the kind of shutdown systemctl list-jobs
+// output has a start job for, "" if there is none. Lines are
+// "JOB UNIT TYPE STATE".
+func shutdownKindFromJobs(jobs string) string {
+	queued := make(map[string]bool)
+	for _, line := range strings.Split(jobs, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[2] == "start" {
+			queued[fields[1]] = true
+		}
+	}
+	for _, target := range shutdownTargets {
+		if queued[target.unit] {
+			return target.kind
+		}
+	}
+	return ""
+}
+
+// defaultHostShutdownDetector asks systemd on hosts booted with it, and
+// returns nil elsewhere
+func defaultHostShutdownDetector() HostShutdownDetector {
+	// sd_booted(3)
+	if info, err := os.Stat("/run/systemd/system"); err != nil || !info.IsDir() {
+		return nil
+	}
+	return systemdShutdownDetector{}
+}
+
+// hostShutdownFlushTimeout returns how long the collector gets to flush on
+// a host shutdown, 60s by default
+func (s *UnifiedSupervisor) hostShutdownFlushTimeout() time.Duration {
+	if s.config.HostShutdownFlushTimeout <= 0 {
+		return DefaultHostShutdownFlushTimeout
+	}
+	return s.config.HostShutdownFlushTimeout
+}
+
+// collectorStopTimeout returns how long the collector gets to stop as the
+// supervisor stops. On a host shutdown a host.stopping event is recorded
+// first and the collector gets the flush timeout, so downstream systems
+// can tell a planned shutdown or reboot from the agent crashing and the
+// exporters can drain their queues.
+func (s *UnifiedSupervisor) collectorStopTimeout(ctx context.Context) time.Duration {
+	if s.hostShutdown == nil {
+		return defaultCollectorStopTimeout
+	}
+
+	shutdown, err := s.hostShutdown.HostShutdown(ctx)
+	if err != nil {
+		s.logger.Debug("Failed to detect a host shutdown", zap.Error(err))
+		return defaultCollectorStopTimeout
+	}
+	if shutdown == nil {
+		return defaultCollectorStopTimeout
+	}
+
+	timeout := s.hostShutdownFlushTimeout()
+	s.recordEvent(models.EventTypeHostStopping, models.EventSeverityInfo,
+		"Host stopping",
+		fmt.Sprintf("%s: flushing the collector for up to %s", shutdown.Kind, timeout))
+	return timeout
+}
@@ -0,0 +1,111 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeShutdownDetector reports a fixed host shutdown
+type fakeShutdownDetector struct {
+	shutdown *HostShutdown
+	err      error
+}
+
+func (d fakeShutdownDetector) HostShutdown(ctx context.Context) (*HostShutdown, error) {
+	return d.shutdown, d.err
+}
+
+func TestShutdownKindFromJobs(t *testing.T) {
+	tests := []struct {
+		name string
+		jobs string
+		want string
+	}{
+		{"no jobs", "", ""},
+		{"other jobs", "  87 apt-daily.service start running\n", ""},
+		{"reboot", " 981 reboot.target          start waiting\n 982 shutdown.target start waiting\n 990 nrdot-host.service stop running\n", HostShutdownReboot},
+		{"poweroff", "1203 shutdown.target start waiting\n1201 poweroff.target start waiting\n", HostShutdownPoweroff},
+		{"shutdown only", "1203 shutdown.target start waiting\n", HostShutdownUnknown},
+		{"stopping a target", "12 reboot.target stop waiting\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shutdownKindFromJobs(tt.jobs); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUnifiedSupervisor_Stop_HostShutdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		detector HostShutdownDetector
+		flush    time.Duration
+		timeout  time.Duration
+		event    bool
+	}{
+		{"reboot", fakeShutdownDetector{shutdown: &HostShutdown{Kind: HostShutdownReboot}}, 0, DefaultHostShutdownFlushTimeout, true},
+		{"configured flush", fakeShutdownDetector{shutdown: &HostShutdown{Kind: HostShutdownPoweroff}}, 2 * time.Minute, 2 * time.Minute, true},
+		{"no shutdown", fakeShutdownDetector{}, 0, defaultCollectorStopTimeout, false},
+		{"detection failed", fakeShutdownDetector{err: errors.New("no systemd")}, 0, defaultCollectorStopTimeout, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{}
+			s, err := NewUnifiedSupervisor(SupervisorConfig{
+				CollectorPath:            "/opt/vendor/otelcol",
+				WorkDir:                  t.TempDir(),
+				HealthCheckInterval:      30 * time.Second,
+				ConfigEngine:             &stubConfigEngine{otelConfig: "receivers: {}\n"},
+				CollectorRunner:          runner,
+				HostShutdown:             tt.detector,
+				HostShutdownFlushTimeout: tt.flush,
+				Logger:                   zaptest.NewLogger(t),
+			})
+			if err != nil {
+				t.Fatalf("Failed to create supervisor: %v", err)
+			}
+			events, cancel := s.SubscribeEvents(EventFilter{Types: []string{
+				string(models.EventTypeHostStopping),
+			}})
+			defer cancel()
+
+			ctx, stop := context.WithCancel(context.Background())
+			defer stop()
+			if err := s.Start(ctx); err != nil {
+				t.Fatalf("Failed to start: %v", err)
+			}
+
+			before := time.Now()
+			if err := s.Stop(context.Background()); err != nil {
+				t.Fatalf("Failed to stop: %v", err)
+			}
+
+			collector := runner.started()[0]
+			collector.mu.Lock()
+			timeout := collector.stopDeadline.Sub(before)
+			collector.mu.Unlock()
+			if timeout < tt.timeout-time.Second || timeout > tt.timeout+time.Second {
+				t.Errorf("Expected the collector to get %s to stop, got %s", tt.timeout, timeout)
+			}
+
+			// The event is delivered before the stream ends
+			event, ok := <-events
+			if ok != tt.event {
+				t.Fatalf("Expected a host.stopping event: %v, got %+v", tt.event, event)
+			}
+			if ok && !strings.HasPrefix(event.Details, tt.detector.(fakeShutdownDetector).shutdown.Kind+":") {
+				t.Errorf("Expected the shutdown kind in the details, got %q", event.Details)
+			}
+		})
+	}
+}
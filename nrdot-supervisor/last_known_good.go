@@ -0,0 +1,290 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// configStateFileName holds the last-known-good config under WorkDir
+	configStateFileName = "config_state.json"
+
+	// defaultLastKnownGoodAfter is how long the collector must stay healthy
+	// on a config before it becomes the last-known-good one
+	defaultLastKnownGoodAfter = 5 * time.Minute
+
+	// configSourceLastKnownGood is the config engine source of a config
+	// restored from the last-known-good copy at boot
+	configSourceLastKnownGood = "last-known-good"
+)
+
+// LastKnownGoodConfig is a user config the collector ran healthy on for
+// LastKnownGoodAfter
+type LastKnownGoodConfig struct {
+	Hash     string    `json:"hash"`
+	Config   string    `json:"config"`
+	Version  int       `json:"version"`
+	MarkedAt time.Time `json:"marked_at"`
+}
+
+// pendingConfig is a config file content the supervisor started applying
+// that has not become last-known-good yet
+type pendingConfig struct {
+	Hash  string    `json:"hash"`
+	Since time.Time `json:"since"`
+}
+
+// configState is the content of the config state file
+type configState struct {
+	LastKnownGood *LastKnownGoodConfig `json:"last_known_good,omitempty"`
+	Pending       *pendingConfig       `json:"pending,omitempty"`
+}
+
+// configStateStore persists the last-known-good config across restarts, so
+// a config file that never proved healthy is not booted into twice. It also
+// tracks, in memory, how long the running config has been healthy.
+type configStateStore struct {
+	mu    sync.Mutex
+	path  string
+	state configState
+
+	// candidateHash is the collector config hash healthy since candidateSince
+	candidateHash  string
+	candidateSince time.Time
+}
+
+// newConfigStateStore opens the config state in dir. A missing file is an
+// empty state; an unreadable one is reported with an empty state, which the
+// next save replaces.
+func newConfigStateStore(dir string) (*configStateStore, error) {
+	c := &configStateStore{path: filepath.Join(dir, configStateFileName)}
+
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("reading config state: %w", err)
+	}
+	if err := json.Unmarshal(data, &c.state); err != nil {
+		c.state = configState{}
+		return c, fmt.Errorf("decoding config state %s: %w", c.path, err)
+	}
+	return c, nil
+}
+
+// lastKnownGood returns the last-known-good config, nil if there is none
+func (c *configStateStore) lastKnownGood() *LastKnownGoodConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state.LastKnownGood == nil {
+		return nil
+	}
+	lkg := *c.state.LastKnownGood
+	return &lkg
+}
+
+// pendingHash returns the hash of the pending config file content
+func (c *configStateStore) pendingHash() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state.Pending == nil {
+		return ""
+	}
+	return c.state.Pending.Hash
+}
+
+// setPending records that config file content with hash is being applied.
+// The last-known-good config itself is never pending.
+func (c *configStateStore) setPending(hash string, now time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state.LastKnownGood != nil && c.state.LastKnownGood.Hash == hash {
+		hash = ""
+	}
+	current := ""
+	if c.state.Pending != nil {
+		current = c.state.Pending.Hash
+	}
+	if current == hash {
+		return nil
+	}
+
+	previous := c.state.Pending
+	c.state.Pending = nil
+	if hash != "" {
+		c.state.Pending = &pendingConfig{Hash: hash, Since: now}
+	}
+	if err := c.save(); err != nil {
+		c.state.Pending = previous
+		return err
+	}
+	return nil
+}
+
+// observe tracks how long the collector has been healthy on the config with
+// hash and returns since when, zero if it is not healthy
+func (c *configStateStore) observe(hash string, healthy bool, now time.Time) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !healthy || hash == "" {
+		c.candidateHash = ""
+		return time.Time{}
+	}
+	if hash != c.candidateHash {
+		c.candidateHash = hash
+		c.candidateSince = now
+	}
+	return c.candidateSince
+}
+
+// markGood stores lkg as the last-known-good config and clears the pending
+// config if it is the same content
+func (c *configStateStore) markGood(lkg LastKnownGoodConfig) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.state
+	c.state.LastKnownGood = &lkg
+	if c.state.Pending != nil && c.state.Pending.Hash == lkg.Hash {
+		c.state.Pending = nil
+	}
+	if err := c.save(); err != nil {
+		c.state = previous
+		return err
+	}
+	return nil
+}
+
+// save replaces the state file atomically. Callers must hold c.mu.
+func (c *configStateStore) save() error {
+	data, err := json.MarshalIndent(c.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding config state: %w", err)
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing config state: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing config state: %w", err)
+	}
+	return nil
+}
+
+// LastKnownGood returns the config the supervisor falls back to at boot,
+// nil if no config has proven healthy yet or there is no WorkDir
+func (s *UnifiedSupervisor) LastKnownGood() *LastKnownGoodConfig {
+	if s.configState == nil {
+		return nil
+	}
+	return s.configState.lastKnownGood()
+}
+
+// lastKnownGoodAfter returns how long a config must stay healthy before it
+// becomes last-known-good, 5m by default
+func (s *UnifiedSupervisor) lastKnownGoodAfter() time.Duration {
+	if s.config.LastKnownGoodAfter <= 0 {
+		return defaultLastKnownGoodAfter
+	}
+	return s.config.LastKnownGoodAfter
+}
+
+// bootConfig picks the user config to start with from the config file
+// content. The file is used unless it differs from the last-known-good
+// config and was already being applied before this start without proving
+// healthy, which means the collector, or the host, went down on it. Then
+// the last-known-good config is returned instead; the file is tried again on
+// the next SIGHUP, API reload or change.
+func (s *UnifiedSupervisor) bootConfig(data []byte) ([]byte, string) {
+	if s.configState == nil {
+		return data, "file"
+	}
+
+	hash := configFileHash(data)
+	lkg := s.configState.lastKnownGood()
+	if lkg == nil || lkg.Hash == hash || s.configState.pendingHash() != hash {
+		return data, "file"
+	}
+
+	s.recordEvent(models.EventTypeConfigRolledBack, models.EventSeverityWarning,
+		"Started with last-known-good configuration",
+		fmt.Sprintf("%s was applied before the restart but never stayed healthy for %s; reload to try it again",
+			s.config.ConfigPath, s.lastKnownGoodAfter()))
+	return []byte(lkg.Config), configSourceLastKnownGood
+}
+
+// markConfigPending records that config file content is being applied, so a
+// restart before it proves healthy falls back to the last-known-good config
+func (s *UnifiedSupervisor) markConfigPending(hash string) {
+	if s.configState == nil {
+		return
+	}
+	if err := s.configState.setPending(hash, s.now()); err != nil {
+		s.logger.Warn("Failed to record pending config", zap.Error(err))
+	}
+}
+
+// checkLastKnownGood marks the running config last-known-good once the
+// collector has been healthy on it for lastKnownGoodAfter. Only the config
+// the collector runs counts: one applied but not reloaded yet does not.
+func (s *UnifiedSupervisor) checkLastKnownGood(ctx context.Context) {
+	if s.configState == nil {
+		return
+	}
+
+	s.mu.RLock()
+	healthy := s.getCollectorHealthState() == models.HealthStateHealthy && !s.crashLoop.isTripped()
+	running := s.status.ConfigHash
+	s.mu.RUnlock()
+
+	if healthy {
+		generated, err := s.configEngine.GetGeneratedConfig(ctx)
+		healthy = err == nil && generated.Hash == running
+	}
+	now := s.now()
+	since := s.configState.observe(running, healthy, now)
+	if since.IsZero() || now.Sub(since) < s.lastKnownGoodAfter() {
+		return
+	}
+
+	config, err := s.configEngine.ExportConfig(ctx, "yaml")
+	if err != nil {
+		s.logger.Warn("Failed to export config for last-known-good", zap.Error(err))
+		return
+	}
+	hash := configFileHash(config)
+	if lkg := s.configState.lastKnownGood(); lkg != nil && lkg.Hash == hash {
+		return
+	}
+
+	version := 0
+	if history, err := s.configEngine.GetConfigHistory(ctx, 1); err == nil && len(history) > 0 {
+		version = history[0].Version
+	}
+	if err := s.configState.markGood(LastKnownGoodConfig{
+		Hash:     hash,
+		Config:   string(config),
+		Version:  version,
+		MarkedAt: now,
+	}); err != nil {
+		s.logger.Warn("Failed to save last-known-good config", zap.Error(err))
+		return
+	}
+
+	s.recordEvent(models.EventTypeConfigValidated, models.EventSeverityInfo,
+		"Configuration marked last-known-good",
+		fmt.Sprintf("Version %d healthy for %s", version, now.Sub(since).Round(time.Second)))
+}
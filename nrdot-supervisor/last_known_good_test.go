@@ -0,0 +1,141 @@
+package supervisor
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestUnifiedSupervisor_LastKnownGood(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "user.yaml")
+	clk := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+
+	// boot starts a supervisor on the same work dir, as after a restart
+	boot := func() (*UnifiedSupervisor, <-chan models.Event, func()) {
+		t.Helper()
+		s, err := NewUnifiedSupervisor(SupervisorConfig{
+			ConfigPath:         path,
+			WorkDir:            dir,
+			CollectorRunner:    &fakeRunner{},
+			Clock:              clk,
+			LastKnownGoodAfter: 5 * time.Minute,
+			// Checks are stepped by hand, not by the health monitor
+			HealthCheckInterval: time.Hour,
+			Logger:              zaptest.NewLogger(t),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create supervisor: %v", err)
+		}
+		events, cancel := s.SubscribeEvents(EventFilter{Types: []string{
+			string(models.EventTypeConfigValidated),
+			string(models.EventTypeConfigRolledBack),
+		}})
+		runCtx, stop := context.WithCancel(ctx)
+		if err := s.Start(runCtx); err != nil {
+			t.Fatalf("Failed to start: %v", err)
+		}
+		return s, events, func() {
+			cancel()
+			stop()
+			s.Stop(context.Background())
+		}
+	}
+	running := func(s *UnifiedSupervisor) string {
+		t.Helper()
+		config, err := s.configEngine.ExportConfig(ctx, "yaml")
+		if err != nil {
+			t.Fatalf("Failed to export config: %v", err)
+		}
+		return string(config)
+	}
+
+	// A config becomes last-known-good after staying healthy long enough
+	writeConfigFile(t, path, testUserConfigV1)
+	s, events, shutdown := boot()
+	s.checkLastKnownGood(ctx)
+	clk.Advance(4 * time.Minute)
+	s.checkLastKnownGood(ctx)
+	if lkg := s.LastKnownGood(); lkg != nil {
+		t.Fatalf("Expected no last-known-good config yet, got %+v", lkg)
+	}
+	clk.Advance(time.Minute)
+	s.checkLastKnownGood(ctx)
+	lkg := s.LastKnownGood()
+	if lkg == nil || lkg.Config != testUserConfigV1 || !lkg.MarkedAt.Equal(clk.Now()) {
+		t.Fatalf("Expected v1 to be last-known-good, got %+v", lkg)
+	}
+	if event := receiveEvent(t, events); event.Type != models.EventTypeConfigValidated {
+		t.Errorf("Expected config.validated, got %+v", event)
+	}
+	shutdown()
+
+	// A new config file is tried at the next start; a crash restarts the
+	// healthy period
+	writeConfigFile(t, path, testUserConfigV2)
+	s, _, shutdown = boot()
+	if config := running(s); config != testUserConfigV2 {
+		t.Fatalf("Expected the new config file to be tried, got %q", config)
+	}
+	s.checkLastKnownGood(ctx)
+	clk.Advance(3 * time.Minute)
+	s.collector.(*fakeCollector).crash()
+	s.checkLastKnownGood(ctx)
+	s.collector.Start(ctx)
+	clk.Advance(3 * time.Minute)
+	s.checkLastKnownGood(ctx)
+	if lkg := s.LastKnownGood(); lkg.Config != testUserConfigV1 {
+		t.Fatalf("Expected the crash to keep v2 from becoming last-known-good, got %+v", lkg)
+	}
+	shutdown()
+
+	// Going down before the new config proved healthy falls back to the
+	// last-known-good one instead of booting into it again
+	s, events, shutdown = boot()
+	defer func() { shutdown() }()
+	if config := running(s); config != testUserConfigV1 {
+		t.Fatalf("Expected the last-known-good config, got %q", config)
+	}
+	if current := currentConfigVersion(t, s); current.Source != configSourceLastKnownGood {
+		t.Errorf("Expected source %s, got %+v", configSourceLastKnownGood, current)
+	}
+	if event := receiveEvent(t, events); event.Type != models.EventTypeConfigRolledBack {
+		t.Errorf("Expected config.rolled_back, got %+v", event)
+	}
+
+	// An explicit reload tries the config file again
+	s.collector.Stop(ctx)
+	if err := s.ReloadConfigFile(ctx, ConfigReloadTriggerSIGHUP); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if config := running(s); config != testUserConfigV2 {
+		t.Errorf("Expected the reload to apply the config file, got %q", config)
+	}
+	if hash := s.configState.pendingHash(); hash != configFileHash([]byte(testUserConfigV2)) {
+		t.Errorf("Expected v2 to be pending again, got %q", hash)
+	}
+
+	// Once it stays healthy it replaces the last-known-good config, and the
+	// next start uses the file as is
+	if err := s.startCollector(ctx); err != nil {
+		t.Fatalf("Failed to start collector: %v", err)
+	}
+	s.checkLastKnownGood(ctx)
+	clk.Advance(5 * time.Minute)
+	s.checkLastKnownGood(ctx)
+	if lkg := s.LastKnownGood(); lkg.Config != testUserConfigV2 || s.configState.pendingHash() != "" {
+		t.Fatalf("Expected v2 to become last-known-good, got %+v", lkg)
+	}
+	shutdown()
+
+	s, _, shutdown = boot()
+	if config := running(s); config != testUserConfigV2 {
+		t.Errorf("Expected the config file, got %q", config)
+	}
+}
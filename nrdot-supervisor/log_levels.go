@@ -0,0 +1,437 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+// collectorLogComponent is the component of PUT /v1/logging that sets the
+// collector's own log level, through its generated config
+const collectorLogComponent = "collector"
+
+// Log levels a component can be set to, those of the NRDOT config's
+// logging.level
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+// LogLevelRequest is the body of PUT /v1/logging. An empty level, or
+// "reset", returns a supervisor component to the supervisor's log level and
+// the collector to the default of its config.
+type LogLevelRequest struct {
+	Component string `json:"component"`
+	Level     string `json:"level"`
+}
+
+// ComponentLogLevel is the log level a component logs at
+type ComponentLogLevel struct {
+	Component string `json:"component"`
+	Level     string `json:"level"`
+	// Overridden is set for a level changed at runtime
+	Overridden bool `json:"overridden"`
+}
+
+// LogLevelResult reports a log level change. Changing the collector's level
+// records a config version and reloads a running collector.
+type LogLevelResult struct {
+	Success   bool                 `json:"success"`
+	Component string               `json:"component"`
+	Level     string               `json:"level"`
+	Version   int                  `json:"version,omitempty"`
+	Reload    *models.ReloadResult `json:"reload,omitempty"`
+	Error     *models.ErrorInfo    `json:"error,omitempty"`
+}
+
+// logLevels holds the runtime log levels of the supervisor's components.
+// Component loggers log at the supervisor's level until one is set.
+type logLevels struct {
+	mu         sync.Mutex
+	components map[string]*componentLevel
+	// base is the level of the supervisor's logger
+	base zapcore.LevelEnabler
+}
+
+// componentLevel is a component's level, nil while it follows the
+// supervisor's
+type componentLevel struct {
+	override atomic.Pointer[zapcore.Level]
+}
+
+func newLogLevels(base zapcore.LevelEnabler) *logLevels {
+	return &logLevels{
+		components: make(map[string]*componentLevel),
+		base:       base,
+	}
+}
+
+// logger returns logger named name, logging at the level of component.
+// Loggers of the same component share its level.
+func (l *logLevels) logger(logger *zap.Logger, component, name string) *zap.Logger {
+	if l == nil {
+		return logger.Named(name)
+	}
+	l.mu.Lock()
+	level, ok := l.components[component]
+	if !ok {
+		level = &componentLevel{}
+		l.components[component] = level
+	}
+	l.mu.Unlock()
+
+	return logger.Named(name).WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &componentCore{Core: core, level: level}
+	}))
+}
+
+// set overrides the level of component; nil returns it to the supervisor's
+func (l *logLevels) set(component string, level *zapcore.Level) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.components[component]
+	if !ok {
+		return fmt.Errorf("unknown component %q", component)
+	}
+	c.override.Store(level)
+	return nil
+}
+
+// list returns the levels of the components, ordered by name
+func (l *logLevels) list() []ComponentLogLevel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	levels := make([]ComponentLogLevel, 0, len(l.components))
+	for name, c := range l.components {
+		level := ComponentLogLevel{Component: name, Level: zapcore.LevelOf(l.base).String()}
+		if override := c.override.Load(); override != nil {
+			level.Level, level.Overridden = override.String(), true
+		}
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].Component < levels[j].Component
+	})
+	return levels
+}
+
+// names returns the component names, ordered
+func (l *logLevels) names() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	names := make([]string, 0, len(l.components))
+	for name := range l.components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// componentCore filters the entries of a component at its level. Entries
+// the wrapped core is not enabled for are written to it directly, so a
+// component can log below the supervisor's level.
+type componentCore struct {
+	zapcore.Core
+	level *componentLevel
+}
+
+func (c *componentCore) Enabled(level zapcore.Level) bool {
+	if override := c.level.override.Load(); override != nil {
+		return override.Enabled(level)
+	}
+	return c.Core.Enabled(level)
+}
+
+func (c *componentCore) With(fields []zapcore.Field) zapcore.Core {
+	return &componentCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *componentCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	override := c.level.override.Load()
+	switch {
+	case override == nil || c.Core.Enabled(entry.Level) && override.Enabled(entry.Level):
+		return c.Core.Check(entry, checked)
+	case override.Enabled(entry.Level):
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// componentLogger returns the supervisor's logger named name, logging at
+// the level of component
+func (s *UnifiedSupervisor) componentLogger(component, name string) *zap.Logger {
+	return s.logLevels.logger(s.logger, component, name)
+}
+
+// parseLogLevel parses a level of PUT /v1/logging; nil resets it
+func parseLogLevel(level string) (*zapcore.Level, error) {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if level == "" || level == "reset" {
+		return nil, nil
+	}
+	for _, name := range logLevelNames {
+		if level == name {
+			parsed, err := zapcore.ParseLevel(level)
+			return &parsed, err
+		}
+	}
+	return nil, fmt.Errorf("invalid level %q, must be one of %s or reset", level, strings.Join(logLevelNames, ", "))
+}
+
+// setCollectorLogLevel records the current user config with logging.level
+// set to level, or removed for nil, as a new version, and reloads a running
+// collector with the reload strategy so it logs at the level. If the reload
+// fails the old collector keeps serving, and the config engine is returned
+// to the version it was on.
+func (s *UnifiedSupervisor) setCollectorLogLevel(ctx context.Context, level *zapcore.Level, author string) (*LogLevelResult, error) {
+	result := &LogLevelResult{Component: collectorLogComponent}
+	fromVersion := 0
+	if history, err := s.configEngine.GetConfigHistory(ctx, 1); err == nil && len(history) > 0 {
+		fromVersion = history[0].Version
+	}
+
+	current, err := s.configEngine.ExportConfig(ctx, "yaml")
+	if err != nil {
+		err = fmt.Errorf("no configuration to change: %w", err)
+		result.Error = logLevelErrorInfo(err)
+		return result, err
+	}
+	name := ""
+	if level != nil {
+		name = level.String()
+	}
+	config, err := setConfigLogLevel(current, name)
+	if err != nil {
+		result.Error = logLevelErrorInfo(err)
+		return result, err
+	}
+
+	applied, err := s.configEngine.ApplyConfig(ctx, &models.ConfigUpdate{
+		Config:      config,
+		Format:      "yaml",
+		Source:      "api",
+		Author:      author,
+		Description: "Collector log level changed",
+	})
+	if err == nil && !applied.Success {
+		err = fmt.Errorf("configuration is invalid")
+		if applied.Error != nil {
+			err = applied.Error
+		}
+	}
+	if err != nil {
+		result.Error = logLevelErrorInfo(err)
+		s.recordEvent(models.EventTypeConfigRejected, models.EventSeverityWarning,
+			"Collector log level change rejected", err.Error())
+		return result, err
+	}
+	result.Version = applied.Version
+	result.Level = configLogLevel(config)
+
+	s.mu.RLock()
+	running := s.collector != nil && s.collector.IsRunning()
+	s.mu.RUnlock()
+
+	if running {
+		reload, err := s.ReloadCollector(ctx, models.ReloadStrategyBlueGreen)
+		result.Reload = reload
+		if err != nil {
+			s.logger.Warn("Reload after collector log level change failed, restoring previous version",
+				zap.Int("version", fromVersion), zap.Error(err))
+			if _, restoreErr := s.configEngine.RollbackToVersion(ctx, fromVersion, "supervisor"); restoreErr != nil {
+				s.logger.Error("Failed to restore config version after log level change",
+					zap.Int("version", fromVersion), zap.Error(restoreErr))
+			}
+			err = fmt.Errorf("reload with collector log level %s failed: %w", result.Level, err)
+			result.Error = logLevelErrorInfo(err)
+			return result, err
+		}
+	}
+
+	result.Success = true
+	s.recordEvent(models.EventTypeConfigChanged, models.EventSeverityInfo,
+		"Collector log level changed",
+		fmt.Sprintf("Level %s (version %d)", result.Level, result.Version))
+
+	return result, nil
+}
+
+// setConfigLogLevel returns the user config with logging.level set to
+// level, or removed when level is empty, keeping the rest of the document
+// as written
+func setConfigLogLevel(config []byte, level string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(config, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config is not a mapping")
+	}
+
+	logging := mappingValue(root, "logging")
+	switch {
+	case logging == nil && level == "":
+		return config, nil
+	case logging == nil:
+		logging = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		root.Content = append(root.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "logging"}, logging)
+	case logging.Kind != yaml.MappingNode:
+		return nil, fmt.Errorf("logging is not a mapping")
+	}
+
+	for i := 0; i+1 < len(logging.Content); i += 2 {
+		if logging.Content[i].Value != "level" {
+			continue
+		}
+		if level == "" {
+			logging.Content = append(logging.Content[:i], logging.Content[i+2:]...)
+		} else {
+			logging.Content[i+1].SetString(level)
+		}
+		return encodeConfig(&doc)
+	}
+	if level != "" {
+		logging.Content = append(logging.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "level"},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: level})
+	}
+	return encodeConfig(&doc)
+}
+
+// configLogLevel returns logging.level of a user config, info by default
+func configLogLevel(config []byte) string {
+	var cfg struct {
+		Logging struct {
+			Level string `yaml:"level"`
+		} `yaml:"logging"`
+	}
+	if err := yaml.Unmarshal(config, &cfg); err != nil || cfg.Logging.Level == "" {
+		return "info"
+	}
+	return cfg.Logging.Level
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func encodeConfig(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// logLevelErrorInfo returns the ErrorInfo carried by err, or wraps it
+func logLevelErrorInfo(err error) *models.ErrorInfo {
+	var errInfo *models.ErrorInfo
+	if errors.As(err, &errInfo) {
+		return errInfo
+	}
+	return models.NewError(
+		models.ErrCodeInternalError,
+		"Log level change failed",
+		models.ErrorCategoryConfig,
+		models.SeverityError,
+	).WithDetails(err.Error())
+}
+
+// handleGetLogLevels serves GET /v1/logging with the level of each
+// supervisor component and of the collector
+func (s *UnifiedSupervisor) handleGetLogLevels(w http.ResponseWriter, r *http.Request) {
+	// Track API request
+	s.metrics.IncrementRequests()
+
+	levels := s.logLevels.list()
+	if config, err := s.configEngine.ExportConfig(r.Context(), "yaml"); err == nil {
+		levels = append(levels, ComponentLogLevel{
+			Component: collectorLogComponent,
+			Level:     configLogLevel(config),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"components": levels,
+	})
+}
+
+// handleSetLogLevel serves PUT /v1/logging. Supervisor components change
+// level at once; the collector is reloaded with the level in its config.
+func (s *UnifiedSupervisor) handleSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	// Track API request
+	s.metrics.IncrementRequests()
+
+	var req LogLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Component == "" {
+		problem.Error(w, r, "Invalid request body: a component is required", http.StatusBadRequest)
+		return
+	}
+	level, err := parseLogLevel(req.Level)
+	if err != nil {
+		problem.Error(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result *LogLevelResult
+	status := http.StatusOK
+	if req.Component == collectorLogComponent {
+		result, err = s.setCollectorLogLevel(r.Context(), level, "api")
+		if err != nil {
+			switch result.Error.Code {
+			case models.ErrCodeConfigInvalid:
+				status = http.StatusUnprocessableEntity
+			default:
+				status = http.StatusInternalServerError
+			}
+		}
+	} else {
+		if err := s.logLevels.set(req.Component, level); err != nil {
+			problem.Error(w, r, fmt.Sprintf("%v, must be one of %s or %s", err,
+				strings.Join(s.logLevels.names(), ", "), collectorLogComponent), http.StatusNotFound)
+			return
+		}
+		result = &LogLevelResult{
+			Success:   true,
+			Component: req.Component,
+			Level:     zapcore.LevelOf(s.logLevels.base).String(),
+		}
+		if level != nil {
+			result.Level = level.String()
+		}
+		s.logger.Info("Component log level changed",
+			zap.String("component", req.Component), zap.String("level", result.Level))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,238 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogLevels_ComponentLogger(t *testing.T) {
+	core, observed := observer.New(zapcore.InfoLevel)
+	store := newMemoryLogStore()
+	logger := captureLogs(zap.New(core), store)
+	levels := newLogLevels(logger.Core())
+
+	engine := levels.logger(logger, "config-engine", "config-engine").With(zap.Int("version", 3))
+	api := levels.logger(logger, "api", "api")
+
+	engine.Debug("not enabled")
+	if observed.Len() != 0 {
+		t.Fatalf("Expected debug entries to follow the supervisor's level, got %d", observed.Len())
+	}
+
+	debug := zapcore.DebugLevel
+	if err := levels.set("config-engine", &debug); err != nil {
+		t.Fatalf("Failed to set level: %v", err)
+	}
+	engine.Debug("Generating config")
+	api.Debug("still not enabled")
+
+	entries := observed.TakeAll()
+	if len(entries) != 1 || entries[0].Message != "Generating config" || entries[0].LoggerName != "config-engine" {
+		t.Fatalf("Expected only the config engine debug entry, got %+v", entries)
+	}
+	if entries[0].ContextMap()["version"] != int64(3) {
+		t.Errorf("Expected the logger's fields, got %v", entries[0].ContextMap())
+	}
+	if lines := store.tail(10, ""); len(lines) != 1 || lines[0].Severity != "debug" {
+		t.Errorf("Expected the debug entry to be captured, got %+v", lines)
+	}
+
+	// A level above the supervisor's quiets a component
+	warn := zapcore.WarnLevel
+	levels.set("api", &warn)
+	api.Info("quiet")
+	api.Warn("API request slow")
+	if entries := observed.TakeAll(); len(entries) != 1 || entries[0].Message != "API request slow" {
+		t.Errorf("Expected only the warning, got %+v", entries)
+	}
+
+	levels.set("config-engine", nil)
+	engine.Debug("reset")
+	if observed.Len() != 0 {
+		t.Errorf("Expected the reset component to follow the supervisor's level again")
+	}
+
+	if err := levels.set("unknown", &debug); err == nil {
+		t.Error("Expected an unknown component to be rejected")
+	}
+
+	list := levels.list()
+	if len(list) != 2 || list[0].Component != "api" || list[0].Level != "warn" || !list[0].Overridden ||
+		list[1].Component != "config-engine" || list[1].Level != "info" || list[1].Overridden {
+		t.Errorf("Unexpected levels %+v", list)
+	}
+}
+
+func TestSetConfigLogLevel(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		level  string
+		want   string
+	}{
+		{
+			name:   "add logging",
+			config: "# host\nservice:\n  name: web-01\n",
+			level:  "debug",
+			want:   "# host\nservice:\n  name: web-01\nlogging:\n  level: debug\n",
+		},
+		{
+			name:   "replace level",
+			config: "service:\n  name: web-01\nlogging:\n  level: info # quiet\n  format: json\n",
+			level:  "debug",
+			want:   "service:\n  name: web-01\nlogging:\n  level: debug # quiet\n  format: json\n",
+		},
+		{
+			name:   "remove level",
+			config: "logging:\n  level: debug\n  format: json\n",
+			level:  "",
+			want:   "logging:\n  format: json\n",
+		},
+		{
+			name:   "nothing to remove",
+			config: "service:\n  name: web-01\n",
+			level:  "",
+			want:   "service:\n  name: web-01\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setConfigLogLevel([]byte(tt.config), tt.level)
+			if err != nil {
+				t.Fatalf("Failed to set level: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := setConfigLogLevel([]byte("logging: debug\n"), "info"); err == nil {
+		t.Error("Expected a scalar logging section to be rejected")
+	}
+}
+
+func TestUnifiedSupervisor_SetCollectorLogLevel(t *testing.T) {
+	f := newBlueGreenFixture(t)
+	s := f.supervisor
+	applyTestConfigs(t, s, testUserConfigV1)
+	oldCollector := s.collector
+
+	debug := zapcore.DebugLevel
+	result, err := s.setCollectorLogLevel(context.Background(), &debug, "ops")
+	if err != nil {
+		t.Fatalf("Log level change failed: %v", err)
+	}
+	if !result.Success || result.Level != "debug" || result.Version != 2 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if result.Reload == nil || !result.Reload.Success {
+		t.Errorf("Expected a successful reload, got %+v", result.Reload)
+	}
+	if s.collector == oldCollector || !s.collector.IsRunning() {
+		t.Error("Expected the collector to be reloaded")
+	}
+
+	generated, err := s.configEngine.GetGeneratedConfig(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get generated config: %v", err)
+	}
+	if !strings.Contains(generated.OTelConfig, "level: debug") {
+		t.Errorf("Expected the collector's telemetry to log at debug:\n%s", generated.OTelConfig)
+	}
+
+	// A failed reload restores the config the collector runs
+	f.healthy = false
+	oldCollector = s.collector
+	result, err = s.setCollectorLogLevel(context.Background(), nil, "ops")
+	if err == nil || result.Success || result.Error == nil {
+		t.Fatalf("Expected the change to fail, got %+v", result)
+	}
+	if s.collector != oldCollector || !oldCollector.IsRunning() {
+		t.Error("Expected the old collector to keep running")
+	}
+	config, err := s.configEngine.ExportConfig(context.Background(), "yaml")
+	if err != nil || configLogLevel(config) != "debug" {
+		t.Errorf("Expected the debug config to be restored, got %q: %v", config, err)
+	}
+}
+
+func TestUnifiedSupervisor_HandleLogLevels(t *testing.T) {
+	core, _ := observer.New(zapcore.InfoLevel)
+	s, err := NewUnifiedSupervisor(SupervisorConfig{WorkDir: t.TempDir(), Logger: zap.New(core)})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	applyTestConfigs(t, s, testUserConfigV1)
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/v1/logging", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		s.handleSetLogLevel(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "invalid body", body: `{`, status: http.StatusBadRequest},
+		{name: "missing component", body: `{"level": "debug"}`, status: http.StatusBadRequest},
+		{name: "invalid level", body: `{"component": "config-engine", "level": "trace"}`, status: http.StatusBadRequest},
+		{name: "unknown component", body: `{"component": "scheduler", "level": "debug"}`, status: http.StatusNotFound},
+		{name: "supervisor component", body: `{"component": "config-engine", "level": "debug"}`, status: http.StatusOK},
+		{name: "collector", body: `{"component": "collector", "level": "warn"}`, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := put(tt.body); w.Code != tt.status {
+				t.Errorf("Expected %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	s.handleGetLogLevels(w, httptest.NewRequest(http.MethodGet, "/v1/logging", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Components []ComponentLogLevel `json:"components"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode levels: %v", err)
+	}
+	levels := make(map[string]ComponentLogLevel)
+	for _, level := range body.Components {
+		levels[level.Component] = level
+	}
+	if level := levels["config-engine"]; level.Level != "debug" || !level.Overridden {
+		t.Errorf("Expected the config engine at debug, got %+v", level)
+	}
+	if level := levels["pipelines"]; level.Level != "info" || level.Overridden {
+		t.Errorf("Expected the pipelines at the supervisor's level, got %+v", level)
+	}
+	if level := levels["collector"]; level.Level != "warn" {
+		t.Errorf("Expected the collector at warn, got %+v", level)
+	}
+
+	// Reset returns the component to the supervisor's level
+	if w := put(`{"component": "config-engine", "level": "reset"}`); w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	for _, level := range s.logLevels.list() {
+		if level.Overridden {
+			t.Errorf("Expected no overrides after reset, got %+v", level)
+		}
+	}
+}
@@ -19,6 +19,15 @@ type MetricsCollector struct {
 	failedReloads      atomic.Int64
 	collectorRestarts  atomic.Int64
 	
+	// Collector cgroup counters
+	collectorOOMKills      atomic.Int64
+	collectorMemoryMaxHits atomic.Int64
+	collectorCPUThrottled  atomic.Int64
+	
+	// Event bus counters
+	eventsPublished atomic.Int64
+	eventsDropped   atomic.Int64
+	
 	// Timing metrics
 	lastReloadDuration time.Duration
 	lastHealthCheck    time.Time
@@ -26,6 +35,18 @@ type MetricsCollector struct {
 	// State metrics
 	collectorRunning bool
 	apiEnabled       bool
+	
+	// Collector resource limits, set when enforced
+	limitsEnforced       bool
+	collectorMemoryLimit uint64
+	collectorCPULimit    float64
+	collectorMemory      uint64
+	
+	// Current event bus subscribers
+	eventSubscribers int
+	
+	// Generation hash of the running collector config
+	configHash string
 }
 
 // NewMetricsCollector creates a new metrics collector
@@ -83,6 +104,53 @@ func (m *MetricsCollector) SetAPIEnabled(enabled bool) {
 	m.mu.Unlock()
 }
 
+// SetCollectorLimits records the enforced collector resource limits
+func (m *MetricsCollector) SetCollectorLimits(memoryMax uint64, cpuMax float64) {
+	m.mu.Lock()
+	m.limitsEnforced = true
+	m.collectorMemoryLimit = memoryMax
+	m.collectorCPULimit = cpuMax
+	m.mu.Unlock()
+}
+
+// SetCollectorMemory sets the collector cgroup memory usage
+func (m *MetricsCollector) SetCollectorMemory(bytes uint64) {
+	m.mu.Lock()
+	m.collectorMemory = bytes
+	m.mu.Unlock()
+}
+
+// AddCollectorLimitEvents adds to the collector cgroup limit counters
+func (m *MetricsCollector) AddCollectorLimitEvents(oomKills, memoryMaxHits, cpuThrottled uint64) {
+	m.collectorOOMKills.Add(int64(oomKills))
+	m.collectorMemoryMaxHits.Add(int64(memoryMaxHits))
+	m.collectorCPUThrottled.Add(int64(cpuThrottled))
+}
+
+// IncrementEvents increments the published event counter
+func (m *MetricsCollector) IncrementEvents() {
+	m.eventsPublished.Add(1)
+}
+
+// IncrementDroppedEvents increments the counter of events dropped for slow subscribers
+func (m *MetricsCollector) IncrementDroppedEvents() {
+	m.eventsDropped.Add(1)
+}
+
+// SetEventSubscribers sets the number of event bus subscribers
+func (m *MetricsCollector) SetEventSubscribers(n int) {
+	m.mu.Lock()
+	m.eventSubscribers = n
+	m.mu.Unlock()
+}
+
+// SetConfigHash sets the generation hash of the running collector config
+func (m *MetricsCollector) SetConfigHash(hash string) {
+	m.mu.Lock()
+	m.configHash = hash
+	m.mu.Unlock()
+}
+
 // GetCustomMetrics implements the MetricsProvider interface
 func (m *MetricsCollector) GetCustomMetrics() []handlers.Metric {
 	m.mu.RLock()
@@ -90,6 +158,12 @@ func (m *MetricsCollector) GetCustomMetrics() []handlers.Metric {
 	apiEnabled := m.apiEnabled
 	lastReloadDuration := m.lastReloadDuration
 	lastHealthCheck := m.lastHealthCheck
+	limitsEnforced := m.limitsEnforced
+	memoryLimit := m.collectorMemoryLimit
+	cpuLimit := m.collectorCPULimit
+	memory := m.collectorMemory
+	eventSubscribers := m.eventSubscribers
+	configHash := m.configHash
 	m.mu.RUnlock()
 
 	metrics := []handlers.Metric{
@@ -119,6 +193,26 @@ func (m *MetricsCollector) GetCustomMetrics() []handlers.Metric {
 			Value: float64(m.collectorRestarts.Load()),
 		},
 		
+		// Event metrics
+		{
+			Name:  "nrdot_supervisor_events_total",
+			Help:  "Total number of supervisor events published",
+			Type:  "counter",
+			Value: float64(m.eventsPublished.Load()),
+		},
+		{
+			Name:  "nrdot_supervisor_events_dropped_total",
+			Help:  "Total number of events dropped for subscribers that fell behind",
+			Type:  "counter",
+			Value: float64(m.eventsDropped.Load()),
+		},
+		{
+			Name:  "nrdot_supervisor_event_subscribers",
+			Help:  "Number of current event subscribers",
+			Type:  "gauge",
+			Value: float64(eventSubscribers),
+		},
+		
 		// State metrics
 		{
 			Name:  "nrdot_collector_running",
@@ -179,6 +273,59 @@ func (m *MetricsCollector) GetCustomMetrics() []handlers.Metric {
 		})
 	}
 
+	// Identify the running config so fleets can check hosts converged
+	if configHash != "" {
+		metrics = append(metrics, handlers.Metric{
+			Name:   "nrdot_collector_config_info",
+			Help:   "Generation hash of the running collector config",
+			Type:   "gauge",
+			Value:  1,
+			Labels: map[string]string{"config_hash": configHash},
+		})
+	}
+
+	// Add cgroup metrics if resource limits are enforced
+	if limitsEnforced {
+		metrics = append(metrics,
+			handlers.Metric{
+				Name:  "nrdot_collector_memory_bytes",
+				Help:  "Memory used by the collector cgroup",
+				Type:  "gauge",
+				Value: float64(memory),
+			},
+			handlers.Metric{
+				Name:  "nrdot_collector_memory_limit_bytes",
+				Help:  "Collector cgroup memory limit, 0 for none",
+				Type:  "gauge",
+				Value: float64(memoryLimit),
+			},
+			handlers.Metric{
+				Name:  "nrdot_collector_cpu_limit_cores",
+				Help:  "Collector cgroup CPU limit in cores, 0 for none",
+				Type:  "gauge",
+				Value: cpuLimit,
+			},
+			handlers.Metric{
+				Name:  "nrdot_collector_oom_kills_total",
+				Help:  "Total number of collector processes killed for exceeding the memory limit",
+				Type:  "counter",
+				Value: float64(m.collectorOOMKills.Load()),
+			},
+			handlers.Metric{
+				Name:  "nrdot_collector_memory_limit_hits_total",
+				Help:  "Total number of times collector memory usage reached the limit",
+				Type:  "counter",
+				Value: float64(m.collectorMemoryMaxHits.Load()),
+			},
+			handlers.Metric{
+				Name:  "nrdot_collector_cpu_throttled_periods_total",
+				Help:  "Total number of CPU periods the collector was throttled in",
+				Type:  "counter",
+				Value: float64(m.collectorCPUThrottled.Load()),
+			},
+		)
+	}
+
 	return metrics
 }
 
@@ -0,0 +1,581 @@
+package supervisor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// pipelinePollInterval is how often the collector's internal telemetry
+	// is scraped for pipeline status
+	pipelinePollInterval = 15 * time.Second
+
+	// pipelineStartupPollInterval is how often it is scraped until the
+	// pipelines of a started collector are active
+	pipelineStartupPollInterval = 2 * time.Second
+
+	// pipelineScrapeTimeout bounds a scrape of the telemetry endpoint
+	pipelineScrapeTimeout = 5 * time.Second
+
+	// maxTelemetryResponse bounds the internal telemetry read per scrape
+	maxTelemetryResponse = 8 << 20
+)
+
+// Pipeline states reported in models.PipelineStatus
+const (
+	pipelineStateRunning  = "running"
+	pipelineStateDegraded = "degraded"
+	pipelineStateStopped  = "stopped"
+	pipelineStateUnknown  = "unknown"
+)
+
+// pipelineSignalItems maps a pipeline type to the item suffix of the
+// collector's internal counters, e.g. otelcol_receiver_accepted_spans
+var pipelineSignalItems = map[string]string{
+	"metrics": "metric_points",
+	"traces":  "spans",
+	"logs":    "log_records",
+}
+
+// collectorTopology is the part of a collector config that locates its
+// pipelines and internal telemetry
+type collectorTopology struct {
+	Pipelines []pipelineDefinition
+
+	// MetricsURL is the internal Prometheus endpoint
+	MetricsURL string
+
+	// ZPagesURL is the zpages pipelinez page, empty if zpages is disabled
+	ZPagesURL string
+
+	// HealthURL is the health_check extension, empty if it is disabled
+	HealthURL string
+}
+
+// pipelineDefinition is one service pipeline
+type pipelineDefinition struct {
+	Name       string
+	Receivers  []string
+	Processors []string
+	Exporters  []string
+}
+
+// parseCollectorTopology reads pipelines and telemetry endpoints from a
+// collector config
+func parseCollectorTopology(data []byte) (*collectorTopology, error) {
+	var cfg struct {
+		Extensions map[string]map[string]interface{} `yaml:"extensions"`
+		Service    struct {
+			Extensions []string `yaml:"extensions"`
+			Pipelines  map[string]struct {
+				Receivers  []string `yaml:"receivers"`
+				Processors []string `yaml:"processors"`
+				Exporters  []string `yaml:"exporters"`
+			} `yaml:"pipelines"`
+			Telemetry struct {
+				Metrics struct {
+					Address string `yaml:"address"`
+					Level   string `yaml:"level"`
+				} `yaml:"metrics"`
+			} `yaml:"telemetry"`
+		} `yaml:"service"`
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse collector config: %w", err)
+	}
+
+	topology := &collectorTopology{}
+	for name, p := range cfg.Service.Pipelines {
+		topology.Pipelines = append(topology.Pipelines, pipelineDefinition{
+			Name:       name,
+			Receivers:  p.Receivers,
+			Processors: p.Processors,
+			Exporters:  p.Exporters,
+		})
+	}
+	sort.Slice(topology.Pipelines, func(i, j int) bool {
+		return topology.Pipelines[i].Name < topology.Pipelines[j].Name
+	})
+
+	if cfg.Service.Telemetry.Metrics.Level != "none" {
+		address := cfg.Service.Telemetry.Metrics.Address
+		if address == "" {
+			address = defaultTelemetryMetricsAddress
+		}
+		metricsURL, err := localURL(address, "/metrics")
+		if err != nil {
+			return nil, fmt.Errorf("telemetry metrics address: %w", err)
+		}
+		topology.MetricsURL = metricsURL
+	}
+
+	for _, name := range cfg.Service.Extensions {
+		extension := cfg.Extensions[name]
+		if extension == nil {
+			extension = map[string]interface{}{}
+		}
+		switch componentType(name) {
+		case "zpages":
+			if topology.ZPagesURL != "" {
+				continue
+			}
+			endpoint, _ := extension["endpoint"].(string)
+			if endpoint == "" {
+				endpoint = defaultExtensionEndpoints["zpages"]
+			}
+			zpagesURL, err := localURL(endpoint, "/debug/pipelinez")
+			if err != nil {
+				return nil, fmt.Errorf("zpages endpoint: %w", err)
+			}
+			topology.ZPagesURL = zpagesURL
+		case "health_check":
+			if topology.HealthURL != "" {
+				continue
+			}
+			if _, ok := extension["endpoint"]; !ok {
+				extension["endpoint"] = defaultExtensionEndpoints["health_check"]
+			}
+			healthURL, err := healthCheckURL(extension)
+			if err != nil {
+				return nil, err
+			}
+			topology.HealthURL = healthURL
+		}
+	}
+
+	return topology, nil
+}
+
+// localURL builds an http URL reaching a listen address from this host.
+// Wildcard addresses, such as :8888, 0.0.0.0:8888 or [::]:8888, are reached
+// on localhost; IPv6 literals keep their brackets.
+func localURL(address, path string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: %w", address, err)
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return fmt.Sprintf("http://%s%s", net.JoinHostPort(host, port), path), nil
+}
+
+// promSample is one sample of a Prometheus text exposition
+type promSample struct {
+	labels map[string]string
+	value  float64
+}
+
+// telemetrySamples holds scraped samples by metric name, with any _total
+// suffix removed so collectors with and without it read the same
+type telemetrySamples map[string][]promSample
+
+// sum adds the samples of a metric whose label key equals value. Samples
+// labelled with another data_type than dataType are skipped; samples without
+// one are shared by all signals. ok is false if no sample matched.
+func (t telemetrySamples) sum(name, key, value, dataType string) (total float64, ok bool) {
+	for _, sample := range t[name] {
+		if sample.labels[key] != value {
+			continue
+		}
+		if dt, has := sample.labels["data_type"]; has && dt != dataType {
+			continue
+		}
+		total += sample.value
+		ok = true
+	}
+	return total, ok
+}
+
+// parsePrometheusText parses the Prometheus text format, keeping only
+// otelcol_ metrics
+func parsePrometheusText(r io.Reader) (telemetrySamples, error) {
+	samples := make(telemetrySamples)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || !strings.HasPrefix(line, "otelcol_") {
+			continue
+		}
+
+		name := line
+		labels := map[string]string{}
+		rest := ""
+		if i := strings.IndexAny(line, "{ "); i >= 0 {
+			name = line[:i]
+			rest = line[i:]
+		}
+		if strings.HasPrefix(rest, "{") {
+			parsed, remaining, err := parsePromLabels(rest[1:])
+			if err != nil {
+				return nil, fmt.Errorf("metric %s: %w", name, err)
+			}
+			labels = parsed
+			rest = remaining
+		}
+
+		// Value, optionally followed by a timestamp
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("metric %s: missing value", name)
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("metric %s: invalid value %q", name, fields[0])
+		}
+
+		name = strings.TrimSuffix(name, "_total")
+		samples[name] = append(samples[name], promSample{labels: labels, value: value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// parsePromLabels parses `key="value",...}` and returns what follows the
+// closing brace
+func parsePromLabels(s string) (map[string]string, string, error) {
+	labels := map[string]string{}
+	for {
+		s = strings.TrimLeft(s, " ,")
+		if strings.HasPrefix(s, "}") {
+			return labels, s[1:], nil
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 || len(s) < eq+2 || s[eq+1] != '"' {
+			return nil, "", fmt.Errorf("malformed labels")
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+2:]
+
+		var value strings.Builder
+		closed := false
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			if c == '"' {
+				s = s[i+1:]
+				closed = true
+				break
+			}
+			value.WriteByte(c)
+		}
+		if !closed {
+			return nil, "", fmt.Errorf("unterminated label value")
+		}
+		labels[key] = value.String()
+	}
+}
+
+// pipelineScraper turns the collector's internal telemetry into pipeline
+// status. Counters are cumulative; component health and rates come from the
+// change since the previous scrape.
+type pipelineScraper struct {
+	client *http.Client
+	logger *zap.Logger
+
+	mu         sync.Mutex
+	previous   map[string]float64
+	previousAt time.Time
+	now        func() time.Time
+}
+
+// newPipelineScraper creates a scraper
+func newPipelineScraper(logger *zap.Logger) *pipelineScraper {
+	return &pipelineScraper{
+		client:   &http.Client{Timeout: pipelineScrapeTimeout},
+		logger:   logger,
+		previous: make(map[string]float64),
+		now:      time.Now,
+	}
+}
+
+// reset forgets the previous scrape, e.g. after a collector restart zeroed
+// its counters
+func (p *pipelineScraper) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.previous = make(map[string]float64)
+	p.previousAt = time.Time{}
+}
+
+// get fetches an internal telemetry page
+func (p *pipelineScraper) get(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// scrape reads the collector's telemetry and returns the status of every
+// pipeline in topology. A failed scrape reports every pipeline unknown.
+func (p *pipelineScraper) scrape(ctx context.Context, topology *collectorTopology) []models.PipelineStatus {
+	now := p.now()
+	statuses := make([]models.PipelineStatus, len(topology.Pipelines))
+	for i, def := range topology.Pipelines {
+		statuses[i] = models.PipelineStatus{
+			Name:             def.Name,
+			Type:             componentType(def.Name),
+			State:            pipelineStateUnknown,
+			ComponentsHealth: make(map[string]string),
+			UpdatedAt:        now,
+		}
+	}
+
+	if topology.MetricsURL == "" {
+		return statuses
+	}
+
+	samples, err := p.scrapeMetrics(ctx, topology.MetricsURL)
+	if err != nil {
+		p.logger.Debug("Failed to scrape collector telemetry", zap.String("url", topology.MetricsURL), zap.Error(err))
+		for i := range statuses {
+			statuses[i].LastError = models.NewError(models.ErrCodeConnectionFailed,
+				"Failed to scrape collector telemetry", models.ErrorCategoryConnection, models.SeverityWarning).
+				WithDetails(err.Error())
+		}
+		return statuses
+	}
+
+	// Pipelines zpages does not list were not built by the collector
+	var zpages string
+	if topology.ZPagesURL != "" {
+		if body, err := p.get(ctx, topology.ZPagesURL); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(body, maxTelemetryResponse))
+			body.Close()
+			zpages = string(data)
+		} else {
+			p.logger.Debug("Failed to read collector zpages", zap.String("url", topology.ZPagesURL), zap.Error(err))
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elapsed := now.Sub(p.previousAt).Seconds()
+	if p.previousAt.IsZero() {
+		elapsed = 0
+	}
+	current := make(map[string]float64)
+	for i, def := range topology.Pipelines {
+		p.buildStatus(&statuses[i], def, samples, current, elapsed)
+		if zpages != "" && !strings.Contains(zpages, def.Name) {
+			statuses[i].State = pipelineStateDegraded
+			statuses[i].LastError = models.NewError(models.ErrCodeResourceNotFound,
+				"Pipeline not reported by the collector", models.ErrorCategoryConfig, models.SeverityError)
+		}
+	}
+	p.previous = current
+	p.previousAt = now
+
+	return statuses
+}
+
+// scrapeMetrics fetches and parses the internal Prometheus endpoint
+func (p *pipelineScraper) scrapeMetrics(ctx context.Context, url string) (telemetrySamples, error) {
+	body, err := p.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return parsePrometheusText(io.LimitReader(body, maxTelemetryResponse))
+}
+
+// buildStatus fills a pipeline status from its components' counters.
+// current collects the counters for the next scrape; elapsed is the time
+// since the previous scrape in seconds, 0 on the first.
+func (p *pipelineScraper) buildStatus(status *models.PipelineStatus, def pipelineDefinition, samples telemetrySamples, current map[string]float64, elapsed float64) {
+	items, known := pipelineSignalItems[status.Type]
+	if !known {
+		return
+	}
+
+	// counter reads a component counter, remembering it per pipeline, and
+	// returns its value and increase since the previous scrape
+	counter := func(kind, id, name string) (value, delta float64, ok bool) {
+		value, ok = samples.sum("otelcol_"+kind+"_"+name, kind, id, status.Type)
+		key := status.Name + "/" + kind + "/" + id + "/" + name
+		current[key] = value
+		if previous, seen := p.previous[key]; seen && value >= previous {
+			delta = value - previous
+		}
+		return value, delta, ok
+	}
+	health := func(kind, id string, seen, degraded bool) {
+		state := models.HealthStateHealthy
+		switch {
+		case degraded:
+			state = models.HealthStateDegraded
+			status.State = pipelineStateDegraded
+		case !seen:
+			state = models.HealthStateUnknown
+		}
+		status.ComponentsHealth[kind+"/"+id] = string(state)
+	}
+
+	status.State = pipelineStateRunning
+	m := &status.Metrics
+	var errorsDelta float64
+
+	for _, id := range def.Receivers {
+		accepted, _, okAccepted := counter("receiver", id, "accepted_"+items)
+		refused, refusedDelta, okRefused := counter("receiver", id, "refused_"+items)
+		m.ItemsReceived += int64(accepted)
+		m.ItemsRefused += int64(refused)
+		errorsDelta += refusedDelta
+		health("receiver", id, okAccepted || okRefused, refusedDelta > 0)
+	}
+
+	m.ItemsProcessed = m.ItemsReceived
+	for _, id := range def.Processors {
+		accepted, _, okAccepted := counter("processor", id, "accepted_"+items)
+		refused, refusedDelta, okRefused := counter("processor", id, "refused_"+items)
+		dropped, droppedDelta, okDropped := counter("processor", id, "dropped_"+items)
+		m.ItemsDropped += int64(refused + dropped)
+		errorsDelta += refusedDelta + droppedDelta
+		if okAccepted {
+			// What the last processor passed on is what the pipeline processed
+			m.ItemsProcessed = int64(accepted)
+		}
+		health("processor", id, okAccepted || okRefused || okDropped, refusedDelta+droppedDelta > 0)
+	}
+
+	for _, id := range def.Exporters {
+		sent, _, okSent := counter("exporter", id, "sent_"+items)
+		failed, failedDelta, okFailed := counter("exporter", id, "send_failed_"+items)
+		enqueueFailed, enqueueDelta, okEnqueue := counter("exporter", id, "enqueue_failed_"+items)
+		size, okSize := samples.sum("otelcol_exporter_queue_size", "exporter", id, status.Type)
+		capacity, _ := samples.sum("otelcol_exporter_queue_capacity", "exporter", id, status.Type)
+		m.ItemsExported += int64(sent)
+		m.ExportFailed += int64(failed)
+		m.ItemsDropped += int64(enqueueFailed)
+		m.QueueSize += int64(size)
+		m.QueueCapacity += int64(capacity)
+		errorsDelta += failedDelta + enqueueDelta
+		queueFull := capacity > 0 && size >= capacity
+		health("exporter", id, okSent || okFailed || okEnqueue || okSize, failedDelta+enqueueDelta > 0 || queueFull)
+	}
+
+	if elapsed > 0 {
+		key := status.Name + "/processed"
+		if previous, seen := p.previous[key]; seen && float64(m.ItemsProcessed) >= previous {
+			m.ProcessingRate = (float64(m.ItemsProcessed) - previous) / elapsed
+		}
+		m.ErrorRate = errorsDelta / elapsed
+	}
+	current[status.Name+"/processed"] = float64(m.ItemsProcessed)
+
+	if status.State == pipelineStateDegraded {
+		status.LastError = models.NewError(models.ErrCodeDataLoss,
+			"Pipeline is refusing, dropping or failing to export data", models.ErrorCategoryData, models.SeverityWarning)
+	}
+}
+
+// refreshPipelineStatus scrapes the running collector and records its
+// pipelines in the status
+func (s *UnifiedSupervisor) refreshPipelineStatus(ctx context.Context) {
+	s.mu.RLock()
+	collector := s.collector
+	s.mu.RUnlock()
+
+	if collector == nil || !collector.IsRunning() {
+		// Replace rather than modify, GetStatus hands out the slice
+		s.mu.Lock()
+		stopped := make([]models.PipelineStatus, len(s.status.Pipelines))
+		for i, pipeline := range s.status.Pipelines {
+			pipeline.State = pipelineStateStopped
+			stopped[i] = pipeline
+		}
+		if s.status.Pipelines != nil {
+			s.status.Pipelines = stopped
+		}
+		s.mu.Unlock()
+		s.pipelines.reset()
+		return
+	}
+
+	data, err := os.ReadFile(s.activeConfigPath())
+	if err != nil {
+		s.logger.Debug("Failed to read collector config", zap.Error(err))
+		return
+	}
+	topology, err := parseCollectorTopology(data)
+	if err != nil {
+		s.logger.Debug("Failed to read collector pipelines", zap.Error(err))
+		return
+	}
+
+	s.mu.RLock()
+	started := s.status.StartTime
+	s.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, pipelineScrapeTimeout)
+	defer cancel()
+	pipelines := s.pipelines.scrape(ctx, topology)
+
+	s.mu.Lock()
+	s.status.Pipelines = pipelines
+	// A collector restarted during the scrape has yet to show its own
+	if s.status.StartTime.Equal(started) && pipelinesActive(topology, pipelines) {
+		s.notePipelinesActiveLocked()
+	}
+	s.mu.Unlock()
+}
+
+// pipelineMonitorLoop keeps the pipeline status current, polling more
+// often while a started collector's pipelines are not active yet so that
+// readiness follows soon after
+func (s *UnifiedSupervisor) pipelineMonitorLoop(ctx context.Context) {
+	timer := time.NewTimer(pipelineStartupPollInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			s.refreshPipelineStatus(ctx)
+			interval := pipelinePollInterval
+			if s.Phase() == models.PhaseCollectorStarted {
+				interval = pipelineStartupPollInterval
+			}
+			timer.Reset(interval)
+		}
+	}
+}
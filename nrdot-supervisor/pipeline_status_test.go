@@ -0,0 +1,329 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+const testPipelineConfig = `
+receivers:
+  hostmetrics: {}
+  otlp: {}
+processors:
+  batch: {}
+  memory_limiter: {}
+exporters:
+  otlp/newrelic: {}
+extensions:
+  zpages:
+    endpoint: localhost:55679
+service:
+  extensions: [zpages]
+  pipelines:
+    metrics:
+      receivers: [hostmetrics, otlp]
+      processors: [memory_limiter, batch]
+      exporters: [otlp/newrelic]
+    traces:
+      receivers: [otlp]
+      exporters: [otlp/newrelic]
+  telemetry:
+    metrics:
+      address: 0.0.0.0:8888
+`
+
+func TestParseCollectorTopology(t *testing.T) {
+	topology, err := parseCollectorTopology([]byte(testPipelineConfig))
+	if err != nil {
+		t.Fatalf("Failed to parse topology: %v", err)
+	}
+
+	if len(topology.Pipelines) != 2 || topology.Pipelines[0].Name != "metrics" || topology.Pipelines[1].Name != "traces" {
+		t.Fatalf("Unexpected pipelines: %+v", topology.Pipelines)
+	}
+	if got := strings.Join(topology.Pipelines[0].Processors, ","); got != "memory_limiter,batch" {
+		t.Errorf("Expected processors in pipeline order, got %s", got)
+	}
+	if topology.MetricsURL != "http://localhost:8888/metrics" {
+		t.Errorf("Unexpected metrics URL %q", topology.MetricsURL)
+	}
+	if topology.ZPagesURL != "http://localhost:55679/debug/pipelinez" {
+		t.Errorf("Unexpected zpages URL %q", topology.ZPagesURL)
+	}
+
+	// Defaults, and no zpages unless the extension is enabled
+	topology, err = parseCollectorTopology([]byte("extensions:\n  zpages: {}\nservice:\n  pipelines:\n    logs: {}\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse topology: %v", err)
+	}
+	if topology.MetricsURL != "http://localhost:8888/metrics" || topology.ZPagesURL != "" {
+		t.Errorf("Unexpected URLs %q %q", topology.MetricsURL, topology.ZPagesURL)
+	}
+
+	// health_check on its default endpoint
+	topology, err = parseCollectorTopology([]byte("extensions:\n  health_check: {}\nservice:\n  extensions: [health_check]\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse topology: %v", err)
+	}
+	if topology.HealthURL != "http://localhost:13133/" {
+		t.Errorf("Unexpected health URL %q", topology.HealthURL)
+	}
+
+	// Internal telemetry disabled
+	topology, err = parseCollectorTopology([]byte("service:\n  telemetry:\n    metrics:\n      level: none\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse topology: %v", err)
+	}
+	if topology.MetricsURL != "" {
+		t.Errorf("Expected no metrics URL, got %q", topology.MetricsURL)
+	}
+}
+
+func TestLocalURL(t *testing.T) {
+	tests := map[string]string{
+		":8888":          "http://localhost:8888/metrics",
+		"0.0.0.0:8888":   "http://localhost:8888/metrics",
+		"[::]:8888":      "http://localhost:8888/metrics",
+		"[::1]:8888":     "http://[::1]:8888/metrics",
+		"[fd00::5]:8888": "http://[fd00::5]:8888/metrics",
+		"10.0.0.5:8888":  "http://10.0.0.5:8888/metrics",
+	}
+	for address, want := range tests {
+		got, err := localURL(address, "/metrics")
+		if err != nil {
+			t.Errorf("localURL(%q): %v", address, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("localURL(%q) = %q, want %q", address, got, want)
+		}
+	}
+
+	// Unbracketed IPv6 literals are ambiguous
+	if _, err := localURL("::1:8888", "/metrics"); err == nil {
+		t.Error("Expected an error for an unbracketed IPv6 address")
+	}
+}
+
+func TestParsePrometheusText(t *testing.T) {
+	text := `# HELP otelcol_receiver_accepted_metric_points Number of points accepted.
+# TYPE otelcol_receiver_accepted_metric_points counter
+otelcol_receiver_accepted_metric_points{receiver="otlp",service_instance_id="a\"b",transport="grpc"} 100
+otelcol_receiver_accepted_metric_points_total{receiver="hostmetrics",transport=""} 50 1700000000000
+otelcol_process_uptime 12.5
+go_goroutines 40
+`
+	samples, err := parsePrometheusText(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	accepted := samples["otelcol_receiver_accepted_metric_points"]
+	if len(accepted) != 2 {
+		t.Fatalf("Expected _total to be folded into one metric, got %+v", samples)
+	}
+	if accepted[0].labels["service_instance_id"] != `a"b` || accepted[0].value != 100 {
+		t.Errorf("Unexpected sample %+v", accepted[0])
+	}
+	if samples["otelcol_process_uptime"][0].value != 12.5 {
+		t.Errorf("Unexpected uptime %+v", samples["otelcol_process_uptime"])
+	}
+	if _, ok := samples["go_goroutines"]; ok {
+		t.Error("Expected non-collector metrics to be skipped")
+	}
+
+	if _, err := parsePrometheusText(strings.NewReader(`otelcol_x{a="b} 1`)); err == nil {
+		t.Error("Expected error for unterminated label")
+	}
+}
+
+// fakeCollectorTelemetry serves internal metrics and zpages
+type fakeCollectorTelemetry struct {
+	mu      sync.Mutex
+	metrics string
+	zpages  string
+}
+
+func (f *fakeCollectorTelemetry) set(metrics string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.metrics = metrics
+}
+
+func (f *fakeCollectorTelemetry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/metrics":
+		fmt.Fprint(w, f.metrics)
+	case "/debug/pipelinez":
+		fmt.Fprint(w, f.zpages)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func testTopology(url string) *collectorTopology {
+	return &collectorTopology{
+		Pipelines: []pipelineDefinition{
+			{Name: "metrics", Receivers: []string{"hostmetrics", "otlp"}, Processors: []string{"memory_limiter", "batch"}, Exporters: []string{"otlp/newrelic"}},
+			{Name: "traces", Receivers: []string{"otlp"}, Exporters: []string{"otlp/newrelic"}},
+		},
+		MetricsURL: url + "/metrics",
+		ZPagesURL:  url + "/debug/pipelinez",
+	}
+}
+
+func pipelineMetricsText(hostAccepted, sent, failed, queueSize int) string {
+	return fmt.Sprintf(`otelcol_receiver_accepted_metric_points{receiver="hostmetrics",transport=""} %d
+otelcol_receiver_accepted_metric_points{receiver="otlp",transport="grpc"} 100
+otelcol_receiver_refused_metric_points{receiver="otlp",transport="grpc"} 2
+otelcol_receiver_accepted_spans{receiver="otlp",transport="grpc"} 40
+otelcol_processor_accepted_metric_points{processor="memory_limiter"} %d
+otelcol_processor_refused_metric_points{processor="memory_limiter"} 0
+otelcol_processor_dropped_metric_points{processor="memory_limiter"} 0
+otelcol_processor_batch_batch_send_size_count{processor="batch"} 3
+otelcol_exporter_sent_metric_points{exporter="otlp/newrelic"} %d
+otelcol_exporter_send_failed_metric_points{exporter="otlp/newrelic"} %d
+otelcol_exporter_enqueue_failed_metric_points{exporter="otlp/newrelic"} 0
+otelcol_exporter_sent_spans{exporter="otlp/newrelic"} 40
+otelcol_exporter_queue_size{data_type="metrics",exporter="otlp/newrelic"} %d
+otelcol_exporter_queue_capacity{data_type="metrics",exporter="otlp/newrelic"} 1000
+otelcol_exporter_queue_size{data_type="traces",exporter="otlp/newrelic"} 0
+otelcol_exporter_queue_capacity{data_type="traces",exporter="otlp/newrelic"} 1000
+`, hostAccepted, hostAccepted+100, sent, failed, queueSize)
+}
+
+func TestPipelineScraper_Scrape(t *testing.T) {
+	telemetry := &fakeCollectorTelemetry{zpages: "<td>metrics</td><td>traces</td>"}
+	telemetry.set(pipelineMetricsText(50, 140, 0, 10))
+	server := httptest.NewServer(telemetry)
+	defer server.Close()
+
+	scraper := newPipelineScraper(zaptest.NewLogger(t))
+	topology := testTopology(server.URL)
+
+	statuses := scraper.scrape(context.Background(), topology)
+	if len(statuses) != 2 {
+		t.Fatalf("Expected 2 pipelines, got %d", len(statuses))
+	}
+
+	metrics := statuses[0]
+	if metrics.State != pipelineStateRunning || metrics.Type != "metrics" {
+		t.Errorf("Unexpected metrics pipeline %+v", metrics)
+	}
+	want := models.PipelineMetrics{
+		ItemsReceived:  150,
+		ItemsRefused:   2,
+		ItemsProcessed: 150,
+		ItemsExported:  140,
+		QueueSize:      10,
+		QueueCapacity:  1000,
+	}
+	if metrics.Metrics != want {
+		t.Errorf("Expected metrics %+v, got %+v", want, metrics.Metrics)
+	}
+	// Refusals before the first scrape do not degrade the receiver
+	if metrics.ComponentsHealth["receiver/otlp"] != "healthy" || metrics.ComponentsHealth["processor/batch"] != "unknown" {
+		t.Errorf("Unexpected component health %+v", metrics.ComponentsHealth)
+	}
+
+	traces := statuses[1]
+	if traces.Metrics.ItemsReceived != 40 || traces.Metrics.ItemsExported != 40 || traces.Metrics.QueueSize != 0 {
+		t.Errorf("Unexpected traces metrics %+v", traces.Metrics)
+	}
+
+	// Export failures since the last scrape degrade the pipeline
+	telemetry.set(pipelineMetricsText(80, 150, 5, 10))
+	scraper.mu.Lock()
+	scraper.previousAt = scraper.previousAt.Add(-10 * time.Second)
+	scraper.mu.Unlock()
+
+	statuses = scraper.scrape(context.Background(), topology)
+	metrics = statuses[0]
+	if metrics.State != pipelineStateDegraded || metrics.LastError == nil {
+		t.Errorf("Expected degraded pipeline, got %+v", metrics)
+	}
+	if metrics.ComponentsHealth["exporter/otlp/newrelic"] != "degraded" || metrics.ComponentsHealth["receiver/hostmetrics"] != "healthy" {
+		t.Errorf("Unexpected component health %+v", metrics.ComponentsHealth)
+	}
+	if metrics.Metrics.ExportFailed != 5 {
+		t.Errorf("Expected 5 failed exports, got %d", metrics.Metrics.ExportFailed)
+	}
+	if metrics.Metrics.ProcessingRate < 2.9 || metrics.Metrics.ProcessingRate > 3.1 {
+		t.Errorf("Expected ~3 items/s processed, got %f", metrics.Metrics.ProcessingRate)
+	}
+	if metrics.Metrics.ErrorRate < 0.49 || metrics.Metrics.ErrorRate > 0.51 {
+		t.Errorf("Expected ~0.5 errors/s, got %f", metrics.Metrics.ErrorRate)
+	}
+	if statuses[1].State != pipelineStateRunning {
+		t.Errorf("Expected traces pipeline unaffected, got %s", statuses[1].State)
+	}
+
+	// A full queue degrades the exporter even without failures
+	telemetry.set(pipelineMetricsText(80, 150, 5, 1000))
+	statuses = scraper.scrape(context.Background(), topology)
+	if statuses[0].ComponentsHealth["exporter/otlp/newrelic"] != "degraded" {
+		t.Errorf("Expected full queue to degrade exporter, got %+v", statuses[0].ComponentsHealth)
+	}
+}
+
+func TestPipelineScraper_Failures(t *testing.T) {
+	telemetry := &fakeCollectorTelemetry{zpages: "<td>metrics</td>"}
+	telemetry.set(pipelineMetricsText(50, 140, 0, 10))
+	server := httptest.NewServer(telemetry)
+
+	scraper := newPipelineScraper(zaptest.NewLogger(t))
+	topology := testTopology(server.URL)
+
+	// A pipeline the collector did not build
+	statuses := scraper.scrape(context.Background(), topology)
+	if statuses[0].State != pipelineStateRunning || statuses[1].State != pipelineStateDegraded {
+		t.Errorf("Expected traces pipeline missing from zpages to be degraded, got %s %s", statuses[0].State, statuses[1].State)
+	}
+
+	// Unreachable telemetry reports unknown
+	server.Close()
+	statuses = scraper.scrape(context.Background(), topology)
+	for _, status := range statuses {
+		if status.State != pipelineStateUnknown || status.LastError == nil {
+			t.Errorf("Expected unknown state with error, got %+v", status)
+		}
+	}
+}
+
+func TestUnifiedSupervisor_GetPipelineStatus(t *testing.T) {
+	s := newEventTestSupervisor(t)
+	s.pipelines = newPipelineScraper(zaptest.NewLogger(t))
+	s.status.Pipelines = []models.PipelineStatus{
+		{Name: "metrics", State: pipelineStateRunning, ComponentsHealth: map[string]string{"receiver/otlp": "healthy"}},
+	}
+
+	status, err := s.GetPipelineStatus(context.Background(), "metrics")
+	if err != nil {
+		t.Fatalf("GetPipelineStatus failed: %v", err)
+	}
+	status.ComponentsHealth["receiver/otlp"] = "changed"
+	if s.status.Pipelines[0].ComponentsHealth["receiver/otlp"] != "healthy" {
+		t.Error("Expected a copy of the pipeline status")
+	}
+
+	if _, err := s.GetPipelineStatus(context.Background(), "logs"); err == nil {
+		t.Error("Expected error for unknown pipeline")
+	}
+
+	// Without a collector the pipelines are stopped
+	s.refreshPipelineStatus(context.Background())
+	if s.status.Pipelines[0].State != pipelineStateStopped {
+		t.Errorf("Expected stopped pipeline, got %s", s.status.Pipelines[0].State)
+	}
+}
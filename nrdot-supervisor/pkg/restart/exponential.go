@@ -3,51 +3,32 @@ package restart
 import (
 	"sync"
 	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/backoff"
 )
 
 // ExponentialBackoff implements exponential backoff restart strategy
 type ExponentialBackoff struct {
-	mu                sync.Mutex
-	initialDelay      time.Duration
-	maxDelay          time.Duration
-	backoffMultiplier float64
-	maxRetries        int
-	currentDelay      time.Duration
-	retryCount        int
+	mu                 sync.Mutex
+	backoff            *backoff.Backoff
 	consecutiveSuccess int
 }
 
 // NewExponentialBackoff creates a new exponential backoff strategy
 func NewExponentialBackoff(initialDelay, maxDelay time.Duration, backoffMultiplier float64, maxRetries int) *ExponentialBackoff {
 	return &ExponentialBackoff{
-		initialDelay:      initialDelay,
-		maxDelay:          maxDelay,
-		backoffMultiplier: backoffMultiplier,
-		maxRetries:        maxRetries,
-		currentDelay:      initialDelay,
+		backoff: backoff.New(backoff.Policy{
+			Initial:    initialDelay,
+			Max:        maxDelay,
+			Multiplier: backoffMultiplier,
+			MaxRetries: maxRetries,
+		}, nil),
 	}
 }
 
 // NextDelay returns the next delay and whether to restart
 func (e *ExponentialBackoff) NextDelay() (time.Duration, bool) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	if e.maxRetries > 0 && e.retryCount >= e.maxRetries {
-		return 0, false
-	}
-
-	delay := e.currentDelay
-	e.retryCount++
-
-	// Calculate next delay
-	nextDelay := time.Duration(float64(e.currentDelay) * e.backoffMultiplier)
-	if nextDelay > e.maxDelay {
-		nextDelay = e.maxDelay
-	}
-	e.currentDelay = nextDelay
-
-	return delay, true
+	return e.backoff.Next()
 }
 
 // Reset resets the backoff state
@@ -55,8 +36,7 @@ func (e *ExponentialBackoff) Reset() {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	e.currentDelay = e.initialDelay
-	e.retryCount = 0
+	e.backoff.Reset()
 	e.consecutiveSuccess = 0
 }
 
@@ -76,7 +56,6 @@ func (e *ExponentialBackoff) RecordSuccess() {
 	e.consecutiveSuccess++
 	// Reset backoff after 3 consecutive successful runs
 	if e.consecutiveSuccess >= 3 {
-		e.currentDelay = e.initialDelay
-		e.retryCount = 0
+		e.backoff.Reset()
 	}
 }
\ No newline at end of file
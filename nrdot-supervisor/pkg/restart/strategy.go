@@ -3,6 +3,8 @@ package restart
 import (
 	"context"
 	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
 )
 
 // Policy defines when the supervisor should restart the collector
@@ -111,17 +113,23 @@ func (s *alwaysStrategy) RecordSuccess() {}
 
 // WaitForRestart waits for the specified delay with context cancellation support
 func WaitForRestart(ctx context.Context, delay time.Duration) error {
+	return WaitForRestartOn(ctx, clock.Real(), delay)
+}
+
+// WaitForRestartOn waits for the specified delay to pass on c, with context
+// cancellation support
+func WaitForRestartOn(ctx context.Context, c clock.Clock, delay time.Duration) error {
 	if delay <= 0 {
 		return nil
 	}
 	
-	timer := time.NewTimer(delay)
+	timer := c.NewTimer(delay)
 	defer timer.Stop()
 	
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
-	case <-timer.C:
+	case <-timer.C():
 		return nil
 	}
 }
\ No newline at end of file
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"testing"
 	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
 )
 
 func TestNeverStrategy(t *testing.T) {
@@ -192,6 +194,27 @@ func TestWaitForRestart(t *testing.T) {
 	}
 }
 
+func TestWaitForRestartOn(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	done := make(chan error, 1)
+	go func() {
+		done <- WaitForRestartOn(context.Background(), clk, time.Minute)
+	}()
+
+	// The wait only ends once the clock has moved by the delay
+	clk.BlockUntil(1)
+	clk.Advance(59 * time.Second)
+	select {
+	case err := <-done:
+		t.Fatalf("Wait ended before the delay: %v", err)
+	default:
+	}
+	clk.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("Wait failed: %v", err)
+	}
+}
+
 func TestDefaultConfig(t *testing.T) {
 	config := DefaultConfig()
 
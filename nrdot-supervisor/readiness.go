@@ -0,0 +1,64 @@
+package supervisor
+
+import (
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap"
+)
+
+// Phase returns how far the supervisor got bringing the collector up
+func (s *UnifiedSupervisor) Phase() models.StartupPhase {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.phaseLocked()
+}
+
+// phaseLocked derives the startup phase from the config and collector
+// state. A collector that exits falls back to config-loaded, and a
+// restarted one has to show active pipelines again; a blue-green reload
+// keeps the phase, its new collector passed a health check before taking
+// over. The caller must hold s.mu.
+func (s *UnifiedSupervisor) phaseLocked() models.StartupPhase {
+	switch {
+	case !s.configLoaded:
+		return models.PhaseStarting
+	case s.collector == nil || !s.collector.IsRunning():
+		return models.PhaseConfigLoaded
+	case !s.pipelinesActive:
+		return models.PhaseCollectorStarted
+	default:
+		return models.PhasePipelinesActive
+	}
+}
+
+// setConfigLoaded records that the initial config was applied
+func (s *UnifiedSupervisor) setConfigLoaded() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configLoaded = true
+	s.logger.Info("Startup phase reached", zap.String("phase", string(models.PhaseConfigLoaded)))
+}
+
+// notePipelinesActiveLocked records that the running collector's pipelines
+// are active. The caller must hold s.mu.
+func (s *UnifiedSupervisor) notePipelinesActiveLocked() {
+	if s.pipelinesActive {
+		return
+	}
+	s.pipelinesActive = true
+	s.logger.Info("Startup phase reached", zap.String("phase", string(models.PhasePipelinesActive)))
+}
+
+// pipelinesActive reports whether a scrape shows the collector running its
+// pipelines. Without internal telemetry there is nothing more to wait for
+// than the collector process.
+func pipelinesActive(topology *collectorTopology, pipelines []models.PipelineStatus) bool {
+	if topology.MetricsURL == "" {
+		return true
+	}
+	for _, pipeline := range pipelines {
+		if pipeline.State != pipelineStateRunning && pipeline.State != pipelineStateDegraded {
+			return false
+		}
+	}
+	return true
+}
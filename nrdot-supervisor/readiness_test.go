@@ -0,0 +1,130 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// readyResponse requests /ready, returning the status code and phase
+func readyResponse(t *testing.T, h *Handlers) (int, models.StartupPhase) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.Ready(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+	var body struct {
+		Ready bool                `json:"ready"`
+		Phase models.StartupPhase `json:"phase"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode /ready: %v", err)
+	}
+	if body.Ready != (w.Code == http.StatusOK) {
+		t.Errorf("Expected ready to match status %d, got %v", w.Code, body.Ready)
+	}
+	return w.Code, body.Phase
+}
+
+func TestUnifiedSupervisor_Readiness(t *testing.T) {
+	// The collector's telemetry answers once it is up
+	var up atomic.Bool
+	telemetry := &fakeCollectorTelemetry{zpages: "<td>metrics</td><td>traces</td>"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+			return
+		}
+		telemetry.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	telemetry.set(pipelineMetricsText(10, 100, 0, 0))
+
+	otelConfig := strings.Replace(testPipelineConfig, "0.0.0.0:8888", strings.TrimPrefix(server.URL, "http://"), 1)
+	otelConfig = strings.Replace(otelConfig, "localhost:55679", strings.TrimPrefix(server.URL, "http://"), 1)
+	runner := &fakeRunner{}
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		WorkDir:             t.TempDir(),
+		HealthCheckInterval: 30 * time.Second,
+		ConfigEngine:        &stubConfigEngine{otelConfig: otelConfig},
+		CollectorRunner:     runner,
+		HealthProbes:        []HealthProbeConfig{},
+		Logger:              zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	h := &Handlers{Supervisor: s, Logger: zaptest.NewLogger(t)}
+	ctx := context.Background()
+
+	// Generating the initial config
+	if code, phase := readyResponse(t, h); code != http.StatusServiceUnavailable || phase != models.PhaseStarting {
+		t.Errorf("Expected 503 while starting, got %d %s", code, phase)
+	}
+
+	s.setConfigLoaded()
+	if code, phase := readyResponse(t, h); code != http.StatusServiceUnavailable || phase != models.PhaseConfigLoaded {
+		t.Errorf("Expected 503 with the config loaded, got %d %s", code, phase)
+	}
+
+	if err := s.startCollector(ctx); err != nil {
+		t.Fatalf("Failed to start collector: %v", err)
+	}
+	s.refreshPipelineStatus(ctx)
+	if code, phase := readyResponse(t, h); code != http.StatusServiceUnavailable || phase != models.PhaseCollectorStarted {
+		t.Errorf("Expected 503 before the pipelines are active, got %d %s", code, phase)
+	}
+
+	up.Store(true)
+	s.refreshPipelineStatus(ctx)
+	if code, phase := readyResponse(t, h); code != http.StatusOK || phase != models.PhasePipelinesActive {
+		t.Errorf("Expected 200 with active pipelines, got %d %s", code, phase)
+	}
+	if status, _ := s.GetStatus(ctx); status.Phase != models.PhasePipelinesActive {
+		t.Errorf("Expected the phase in the status, got %q", status.Phase)
+	}
+
+	// A crashed collector is not ready, and its restart starts over
+	runner.started()[0].crash()
+	if code, phase := readyResponse(t, h); code != http.StatusServiceUnavailable || phase != models.PhaseConfigLoaded {
+		t.Errorf("Expected 503 after a crash, got %d %s", code, phase)
+	}
+	if err := s.startCollector(ctx); err != nil {
+		t.Fatalf("Failed to restart collector: %v", err)
+	}
+	if phase := s.Phase(); phase != models.PhaseCollectorStarted {
+		t.Errorf("Expected collector-started after the restart, got %s", phase)
+	}
+}
+
+func TestPipelinesActive(t *testing.T) {
+	topology := testTopology("http://localhost:8888")
+	running := []models.PipelineStatus{
+		{Name: "metrics", State: pipelineStateRunning},
+		{Name: "traces", State: pipelineStateDegraded},
+	}
+	if !pipelinesActive(topology, running) {
+		t.Error("Expected running and degraded pipelines to be active")
+	}
+
+	unknown := []models.PipelineStatus{
+		{Name: "metrics", State: pipelineStateRunning},
+		{Name: "traces", State: pipelineStateUnknown},
+	}
+	if pipelinesActive(topology, unknown) {
+		t.Error("Expected a pipeline without telemetry to hold back readiness")
+	}
+
+	// Without internal telemetry the running collector is all there is
+	topology.MetricsURL = ""
+	if !pipelinesActive(topology, unknown) {
+		t.Error("Expected pipelines active without internal telemetry")
+	}
+}
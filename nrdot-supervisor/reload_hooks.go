@@ -0,0 +1,73 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-config-engine/pkg/hooks"
+	"go.uber.org/zap"
+)
+
+// reloadEvent describes the reload about to happen to the reload scripts
+func (s *UnifiedSupervisor) reloadEvent(ctx context.Context) hooks.ReloadEvent {
+	s.mu.RLock()
+	oldVersion := s.status.ConfigVersion
+	s.mu.RUnlock()
+	return hooks.ReloadEvent{
+		OldVersion: oldVersion,
+		NewVersion: s.currentConfigVersion(ctx),
+		ConfigPath: s.config.ConfigPath,
+	}
+}
+
+// runPreReloadHook runs the pre_reload script. It returns an error only
+// when the script failed and its failure policy aborts the reload.
+func (s *UnifiedSupervisor) runPreReloadHook(ctx context.Context, event hooks.ReloadEvent) error {
+	if s.reloadHooks == nil {
+		return nil
+	}
+	err := s.reloadHooks.RunPreReload(ctx, event)
+	if err == nil {
+		return nil
+	}
+	var scriptErr *hooks.ScriptError
+	if errors.As(err, &scriptErr) && !scriptErr.Abort {
+		s.logger.Warn("pre_reload hook failed, reloading anyway", zap.Error(err))
+		return nil
+	}
+	s.logger.Error("pre_reload hook failed, reload aborted", zap.Error(err))
+	s.recordEventContext(ctx, models.EventTypeConfigRejected, models.EventSeverityError,
+		"Configuration reload aborted by pre_reload hook", err.Error())
+	return err
+}
+
+// runPostReloadHook runs the post_reload script after an attempted reload,
+// reloadErr being why it failed. A failing script is only reported, the
+// reload is over.
+func (s *UnifiedSupervisor) runPostReloadHook(ctx context.Context, event hooks.ReloadEvent, reloadErr error) {
+	if s.reloadHooks == nil {
+		return
+	}
+	event.Error = reloadErr
+	if err := s.reloadHooks.RunPostReload(ctx, event); err != nil {
+		s.logger.Warn("post_reload hook failed", zap.Error(err))
+		s.recordEventContext(ctx, models.EventTypeConfigChanged, models.EventSeverityWarning,
+			"post_reload hook failed", err.Error())
+	}
+}
+
+// reloadAbortedResult is the result of a reload a pre_reload hook stopped
+func reloadAbortedResult(strategy models.ReloadStrategy, event hooks.ReloadEvent, err error) *models.ReloadResult {
+	return &models.ReloadResult{
+		Success:    false,
+		Strategy:   strategy,
+		OldVersion: event.OldVersion,
+		Error: models.NewError(
+			models.ErrCodeResourceLocked,
+			"Reload aborted by pre_reload hook",
+			models.ErrorCategoryResource,
+			models.SeverityError,
+		).WithDetails(err.Error()),
+	}
+}
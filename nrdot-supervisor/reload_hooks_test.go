@@ -0,0 +1,191 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/interfaces"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/tracecontext"
+	"github.com/newrelic/nrdot-host/nrdot-config-engine/pkg/hooks"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeReloader stands in for the reload strategy
+type fakeReloader struct {
+	interfaces.SupervisorCommander
+	calls int
+	err   error
+}
+
+func (r *fakeReloader) ReloadCollector(ctx context.Context, strategy models.ReloadStrategy) (*models.ReloadResult, error) {
+	r.calls++
+	return &models.ReloadResult{Success: r.err == nil, Strategy: strategy, OldVersion: 1, NewVersion: 2}, r.err
+}
+
+// writeHookScript writes a script recording its environment to out and
+// exiting with code
+func writeHookScript(t *testing.T, dir, name, out string, code int) string {
+	path := filepath.Join(dir, name)
+	script := "#!/bin/sh\nenv | grep '^NRDOT_\\|^LB_' | sort > " + out + "\nexit " + strconv.Itoa(code) + "\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	return path
+}
+
+func newReloadHookSupervisor(t *testing.T, scripts hooks.ScriptConfig) (*UnifiedSupervisor, *fakeReloader) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		CollectorPath:   "/opt/vendor/otelcol",
+		ConfigPath:      "/etc/nrdot/config.yaml",
+		WorkDir:         t.TempDir(),
+		ConfigEngine:    &stubConfigEngine{otelConfig: "receivers: {}\n"},
+		CollectorRunner: &fakeRunner{},
+		ReloadHooks:     scripts,
+		Logger:          zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	reloader := &fakeReloader{}
+	s.reloadStrategy = reloader
+	s.status.State = models.CollectorStateRunning
+	s.status.ConfigVersion = 1
+	return s, reloader
+}
+
+func TestReloadHooks(t *testing.T) {
+	dir := t.TempDir()
+	preOut, postOut := filepath.Join(dir, "pre.env"), filepath.Join(dir, "post.env")
+	s, reloader := newReloadHookSupervisor(t, hooks.ScriptConfig{
+		PreReload:  writeHookScript(t, dir, "drain.sh", preOut, 0),
+		PostReload: writeHookScript(t, dir, "undrain.sh", postOut, 0),
+		Env:        map[string]string{"LB_POOL": "web"},
+	})
+
+	if _, err := s.ReloadCollector(context.Background(), models.ReloadStrategyBlueGreen); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if reloader.calls != 1 {
+		t.Errorf("Expected one reload, got %d", reloader.calls)
+	}
+
+	pre, err := os.ReadFile(preOut)
+	if err != nil {
+		t.Fatalf("pre_reload did not run: %v", err)
+	}
+	for _, want := range []string{"NRDOT_HOOK=pre_reload", "NRDOT_OLD_VERSION=1", "NRDOT_CONFIG_PATH=/etc/nrdot/config.yaml", "LB_POOL=web"} {
+		if !strings.Contains(string(pre), want+"\n") {
+			t.Errorf("Expected %s in pre_reload environment:\n%s", want, pre)
+		}
+	}
+	post, err := os.ReadFile(postOut)
+	if err != nil {
+		t.Fatalf("post_reload did not run: %v", err)
+	}
+	if !strings.Contains(string(post), "NRDOT_RELOAD_RESULT=success\n") {
+		t.Errorf("Expected a successful reload in post_reload environment:\n%s", post)
+	}
+
+	// post_reload runs after a failed reload too
+	reloader.err = errors.New("collector unhealthy")
+	s.ReloadCollector(context.Background(), models.ReloadStrategyBlueGreen)
+	post, _ = os.ReadFile(postOut)
+	if !strings.Contains(string(post), "NRDOT_RELOAD_RESULT=failure\n") || !strings.Contains(string(post), "NRDOT_RELOAD_ERROR=collector unhealthy\n") {
+		t.Errorf("Expected the failed reload in post_reload environment:\n%s", post)
+	}
+}
+
+func TestReloadHooks_FailurePolicy(t *testing.T) {
+	dir := t.TempDir()
+	postOut := filepath.Join(dir, "post.env")
+	scripts := hooks.ScriptConfig{
+		PreReload:  writeHookScript(t, dir, "drain.sh", filepath.Join(dir, "pre.env"), 3),
+		PostReload: writeHookScript(t, dir, "undrain.sh", postOut, 0),
+	}
+
+	// abort, the default, leaves the collector alone
+	s, reloader := newReloadHookSupervisor(t, scripts)
+	result, err := s.ReloadCollector(context.Background(), models.ReloadStrategyBlueGreen)
+	if err == nil || !strings.Contains(err.Error(), "pre_reload hook") {
+		t.Fatalf("Expected the pre_reload failure, got %v", err)
+	}
+	if reloader.calls != 0 {
+		t.Error("Expected no reload after pre_reload failed")
+	}
+	if result.Success || result.Error == nil || result.Error.Code != models.ErrCodeResourceLocked {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if s.status.State != models.CollectorStateRunning {
+		t.Errorf("Expected the collector to stay running, got %s", s.status.State)
+	}
+	if _, err := os.Stat(postOut); !os.IsNotExist(err) {
+		t.Error("Expected no post_reload without a reload")
+	}
+
+	// warn reloads anyway
+	scripts.OnFailure = hooks.FailureWarn
+	s, reloader = newReloadHookSupervisor(t, scripts)
+	if _, err := s.ReloadCollector(context.Background(), models.ReloadStrategyBlueGreen); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if reloader.calls != 1 {
+		t.Errorf("Expected the reload to go ahead, got %d reloads", reloader.calls)
+	}
+}
+
+func TestReloadHooks_Invalid(t *testing.T) {
+	_, err := NewUnifiedSupervisor(SupervisorConfig{
+		WorkDir:      t.TempDir(),
+		ConfigEngine: &stubConfigEngine{},
+		ReloadHooks:  hooks.ScriptConfig{PreReload: "drain.sh"},
+		Logger:       zaptest.NewLogger(t),
+	})
+	if err == nil || !strings.Contains(err.Error(), "absolute path") {
+		t.Errorf("Expected relative script to be rejected, got %v", err)
+	}
+}
+
+func TestReloadCollector_TraceContext(t *testing.T) {
+	dir := t.TempDir()
+	preOut := filepath.Join(dir, "pre.env")
+	path := filepath.Join(dir, "drain.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nenv | grep '^TRACEPARENT=' > "+preOut+"\n"), 0755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	s, _ := newReloadHookSupervisor(t, hooks.ScriptConfig{PreReload: path})
+	events, cancel := s.events.subscribe(EventFilter{Types: []string{string(models.EventTypeReloaded)}})
+	defer cancel()
+
+	parent, err := tracecontext.Parse("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatalf("Failed to parse traceparent: %v", err)
+	}
+	result, err := s.ReloadCollector(tracecontext.ContextWith(context.Background(), parent), models.ReloadStrategyBlueGreen)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	// The reload is a child span of the caller's
+	if result.TraceID != parent.TraceIDString() || result.SpanID == "" || result.SpanID == parent.SpanIDString() {
+		t.Errorf("Expected a child span of the caller's, got trace %q span %q", result.TraceID, result.SpanID)
+	}
+	if event := receiveEvent(t, events.ch); event.Type != models.EventTypeReloaded || event.TraceID != result.TraceID || event.SpanID != result.SpanID {
+		t.Errorf("Expected the reloaded event in the reload span, got %+v", event)
+	}
+	env, _ := os.ReadFile(preOut)
+	if want := "TRACEPARENT=00-" + result.TraceID + "-" + result.SpanID + "-01\n"; string(env) != want {
+		t.Errorf("Expected %q in pre_reload environment, got %q", want, env)
+	}
+
+	// A reload without a caller trace starts one
+	result, _ = s.ReloadCollector(context.Background(), models.ReloadStrategyBlueGreen)
+	if result.TraceID == "" || result.TraceID == parent.TraceIDString() {
+		t.Errorf("Expected a new trace, got %q", result.TraceID)
+	}
+}
@@ -4,20 +4,33 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/tracecontext"
+	configengine "github.com/newrelic/nrdot-host/nrdot-config-engine"
 	"go.uber.org/zap"
 )
 
+// healthPollInterval is how often a starting collector's health is probed
+const healthPollInterval = 500 * time.Millisecond
+
 // BlueGreenReloadStrategy implements zero-downtime configuration reload
 type BlueGreenReloadStrategy struct {
 	supervisor *UnifiedSupervisor
+	
+	// serializes reloads, each one owns the alternate port slot
+	reloadMu sync.Mutex
+	
+	// generate overrides the config engine as config source (tests)
+	generate func(ctx context.Context) (string, error)
 }
 
 // ReloadCollector performs a blue-green reload
 func (s *BlueGreenReloadStrategy) ReloadCollector(ctx context.Context, strategy models.ReloadStrategy) (*models.ReloadResult, error) {
-	startTime := time.Now()
+	startTime := s.supervisor.now()
 	oldVersion := s.supervisor.status.ConfigVersion
 	
 	result := &models.ReloadResult{
@@ -45,91 +58,122 @@ func (s *BlueGreenReloadStrategy) ReloadCollector(ctx context.Context, strategy
 	}
 }
 
-// blueGreenReload starts new collector, verifies health, then stops old
+// blueGreenReload starts a second collector with the new config on the
+// alternate port slot, verifies it, then swaps it in and stops the old one.
+// Any failure before the swap leaves the old collector serving.
 func (s *BlueGreenReloadStrategy) blueGreenReload(ctx context.Context, result *models.ReloadResult) (*models.ReloadResult, error) {
-	s.supervisor.logger.Info("Starting blue-green reload")
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	
+	sup := s.supervisor
+	sup.logger.Info("Starting blue-green reload")
 	
-	// Get new configuration (not used but keeping for future)
-	_, err := s.supervisor.configEngine.GetCurrentConfig(ctx)
+	otelConfig, err := s.generateConfig(ctx)
 	if err != nil {
 		result.Success = false
 		result.Error = models.NewError(
-			models.ErrCodeConfigMissing,
-			"No configuration available",
+			models.ErrCodeConfigInvalid,
+			"Failed to generate configuration",
 			models.ErrorCategoryConfig,
 			models.SeverityError,
-		)
+		).WithDetails(err.Error())
 		return result, err
 	}
 	
-	// Generate new OTel config
-	generated, err := s.supervisor.configEngine.ProcessUserConfig(ctx, nil)
+	sup.mu.RLock()
+	oldCollector := sup.collector
+	slot := 1 - sup.portSlot
+	sup.mu.RUnlock()
+	
+	// Move listeners to the alternate slot so both collectors can run at once
+	offset := slot * sup.blueGreenPortOffset()
+	configData, healthURL, err := slotConfig(otelConfig, offset)
 	if err != nil {
 		result.Success = false
 		result.Error = models.NewError(
 			models.ErrCodeConfigInvalid,
-			"Failed to generate configuration",
+			"Failed to prepare configuration for blue-green reload",
 			models.ErrorCategoryConfig,
 			models.SeverityError,
-		)
+		).WithDetails(err.Error())
 		return result, err
 	}
 	
-	// Keep reference to old collector
-	oldCollector := s.supervisor.collector
-	
-	// Write new config to temporary file
-	tmpConfig := fmt.Sprintf("%s/config-new.yaml", s.supervisor.config.WorkDir)
-	if err := os.WriteFile(tmpConfig, []byte(generated.OTelConfig), 0644); err != nil {
+	color := slotColor(slot)
+	configPath := filepath.Join(sup.config.WorkDir, fmt.Sprintf("config-%s.yaml", color))
+	if err := os.WriteFile(configPath, configData, 0644); err != nil {
 		return nil, fmt.Errorf("failed to write new config: %w", err)
 	}
-	defer os.Remove(tmpConfig)
-	
-	// Create new collector process (blue)
-	newCollector := &CollectorProcess{
-		binaryPath: s.supervisor.config.CollectorPath,
-		configPath: tmpConfig,
-		workDir:    s.supervisor.config.WorkDir,
-		logger:     s.supervisor.logger.Named("collector-new"),
-		args:       []string{"--config", tmpConfig},
+	
+	// Validation gate: the collector itself must accept the config
+	if err := sup.collectorRunner().ValidateConfig(ctx, sup.config.CollectorPath, configPath); err != nil {
+		os.Remove(configPath)
+		return s.rollback(result, nil, oldCollector, models.NewError(
+			models.ErrCodeConfigInvalid,
+			"Collector rejected new configuration",
+			models.ErrorCategoryConfig,
+			models.SeverityError,
+		).WithDetails(err.Error()), err)
+	}
+	
+	env, err := sup.collectorEnv(ctx, otelConfig)
+	if err != nil {
+		os.Remove(configPath)
+		return s.rollback(result, nil, oldCollector, models.NewError(
+			models.ErrCodeConfigInvalid,
+			"Failed to resolve configuration secrets",
+			models.ErrorCategoryConfig,
+			models.SeverityError,
+		).WithDetails(err.Error()), err)
 	}
 	
-	// Start new collector
+	newCollector := sup.collectorRunner().NewCollector(CollectorConfig{
+		BinaryPath:      sup.config.CollectorPath,
+		ConfigPath:      configPath,
+		Env:             env,
+		WorkDir:         sup.config.WorkDir,
+		ShutdownTimeout: 30 * time.Second,
+		OutputHandler:   sup.collectorOutputHandler(),
+		CgroupDir:       sup.collectorCgroupDir(slot),
+	}, sup.componentLogger("collector-process", "collector-"+color))
+	
 	if err := newCollector.Start(ctx); err != nil {
-		result.Success = false
-		result.Error = models.NewError(
+		return s.rollback(result, nil, oldCollector, models.NewError(
 			models.ErrCodeInternalError,
 			"Failed to start new collector",
 			models.ErrorCategoryInternal,
 			models.SeverityError,
-		).WithDetails(err.Error())
-		return result, fmt.Errorf("failed to start new collector: %w", err)
+		).WithDetails(err.Error()), fmt.Errorf("failed to start new collector: %w", err))
 	}
 	
-	// Wait for new collector to be healthy
-	healthCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	// Wait for the health endpoint, which reports ready once all pipelines
+	// have started
+	healthCtx, cancel := context.WithTimeout(ctx, sup.reloadHealthTimeout())
 	defer cancel()
 	
-	if err := s.waitForHealth(healthCtx, newCollector); err != nil {
-		// New collector failed, kill it
-		newCollector.Stop(context.Background())
-		
-		result.Success = false
-		result.Error = models.NewError(
+	if err := s.waitForHealth(healthCtx, newCollector, healthURL); err != nil {
+		return s.rollback(result, newCollector, oldCollector, models.NewError(
 			models.ErrCodeInternalError,
 			"New collector failed health check",
 			models.ErrorCategoryInternal,
 			models.SeverityError,
-		).WithDetails(err.Error())
-		return result, fmt.Errorf("new collector failed health check: %w", err)
+		).WithDetails(err.Error()), fmt.Errorf("new collector failed health check: %w", err))
 	}
 	
 	// Switch to new collector
-	s.supervisor.mu.Lock()
-	s.supervisor.collector = newCollector
-	s.supervisor.status.ConfigVersion++
-	s.supervisor.status.LastConfigLoad = time.Now()
-	s.supervisor.mu.Unlock()
+	sup.mu.Lock()
+	sup.collector = newCollector
+	sup.portSlot = slot
+	sup.status.ConfigVersion++
+	sup.status.ConfigHash = configengine.HashOTelConfig(otelConfig)
+	sup.status.LastConfigLoad = sup.now()
+	sup.status.StartTime = sup.now()
+	sup.transition(models.CollectorStateRunning)
+	newVersion := sup.status.ConfigVersion
+	configHash := sup.status.ConfigHash
+	sup.mu.Unlock()
+	sup.metrics.SetCollectorRunning(true)
+	sup.metrics.SetConfigHash(configHash)
 	
 	// Stop old collector gracefully
 	if oldCollector != nil && oldCollector.IsRunning() {
@@ -137,25 +181,83 @@ func (s *BlueGreenReloadStrategy) blueGreenReload(ctx context.Context, result *m
 		defer stopCancel()
 		
 		if err := oldCollector.Stop(stopCtx); err != nil {
-			s.supervisor.logger.Warn("Failed to stop old collector gracefully", 
+			sup.logger.Warn("Failed to stop old collector gracefully", 
 				zap.Error(err))
 		}
 	}
 	
 	// Success
 	result.Success = true
-	result.NewVersion = s.supervisor.status.ConfigVersion
-	result.EndTime = time.Now()
+	result.NewVersion = newVersion
+	result.EndTime = s.supervisor.now()
 	result.Duration = result.EndTime.Sub(result.StartTime)
 	
-	s.supervisor.logger.Info("Blue-green reload completed successfully",
+	sup.logger.Info("Blue-green reload completed successfully", append(tracecontext.Fields(ctx),
+		zap.String("slot", color),
+		zap.String("healthEndpoint", healthURL),
 		zap.Duration("duration", result.Duration),
 		zap.Int("oldVersion", result.OldVersion),
-		zap.Int("newVersion", result.NewVersion))
+		zap.Int("newVersion", result.NewVersion),
+		zap.String("configHash", configHash))...)
 	
 	return result, nil
 }
 
+// rollback abandons a blue-green reload: the new collector, if started, is
+// stopped and the old one keeps serving
+func (s *BlueGreenReloadStrategy) rollback(result *models.ReloadResult, newCollector, oldCollector Collector, errInfo *models.ErrorInfo, err error) (*models.ReloadResult, error) {
+	if newCollector != nil {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if stopErr := newCollector.Stop(stopCtx); stopErr != nil {
+			s.supervisor.logger.Warn("Failed to stop new collector", zap.Error(stopErr))
+		}
+	}
+	
+	oldRunning := oldCollector != nil && oldCollector.IsRunning()
+	
+	result.Success = false
+	result.Error = errInfo
+	result.NewVersion = result.OldVersion
+	result.EndTime = s.supervisor.now()
+	result.Duration = result.EndTime.Sub(result.StartTime)
+	result.RollbackInfo = &models.RollbackInfo{
+		Triggered:   true,
+		Reason:      err.Error(),
+		FromVersion: result.OldVersion + 1,
+		ToVersion:   result.OldVersion,
+		Success:     oldRunning,
+		Timestamp:   result.EndTime,
+	}
+	
+	s.supervisor.logger.Warn("Blue-green reload rolled back",
+		zap.Error(err),
+		zap.Bool("oldCollectorRunning", oldRunning))
+	
+	return result, err
+}
+
+// generateConfig returns the collector config to reload with
+func (s *BlueGreenReloadStrategy) generateConfig(ctx context.Context) (string, error) {
+	if s.generate != nil {
+		return s.generate(ctx)
+	}
+	
+	generated, err := s.supervisor.configEngine.GetGeneratedConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	return generated.OTelConfig, nil
+}
+
+// slotColor names a port slot in file and logger names
+func slotColor(slot int) string {
+	if slot == 0 {
+		return "blue"
+	}
+	return "green"
+}
+
 // gracefulReload stops the collector and starts with new config
 func (s *BlueGreenReloadStrategy) gracefulReload(ctx context.Context, result *models.ReloadResult) (*models.ReloadResult, error) {
 	s.supervisor.logger.Info("Starting graceful reload")
@@ -196,7 +298,7 @@ func (s *BlueGreenReloadStrategy) gracefulReload(ctx context.Context, result *mo
 	// Success
 	result.Success = true
 	result.NewVersion = s.supervisor.status.ConfigVersion
-	result.EndTime = time.Now()
+	result.EndTime = s.supervisor.now()
 	result.Duration = result.EndTime.Sub(result.StartTime)
 	
 	return result, nil
@@ -222,18 +324,30 @@ func (s *BlueGreenReloadStrategy) inPlaceReload(ctx context.Context, result *mod
 	return s.blueGreenReload(ctx, result)
 }
 
-// waitForHealth waits for collector to become healthy
-func (s *BlueGreenReloadStrategy) waitForHealth(ctx context.Context, collector *CollectorProcess) error {
-	ticker := time.NewTicker(time.Second)
+// waitForHealth polls the collector's health endpoint until it answers 200,
+// failing early if the process exits
+func (s *BlueGreenReloadStrategy) waitForHealth(ctx context.Context, collector Collector, healthURL string) error {
+	checker := NewHealthChecker(HealthCheckerConfig{
+		Endpoint: healthURL,
+		Timeout:  2 * time.Second,
+	}, s.supervisor.componentLogger("reload-health", "reload-health"))
+	
+	ticker := time.NewTicker(healthPollInterval)
 	defer ticker.Stop()
 	
+	var lastErr error
 	for {
 		select {
 		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("%w: %v", ctx.Err(), lastErr)
+			}
 			return ctx.Err()
 		case <-ticker.C:
-			// Simple check - if process is running, assume healthy
-			if collector.IsRunning() {
+			if !collector.IsRunning() {
+				return fmt.Errorf("collector exited during startup")
+			}
+			if lastErr = checker.Check(ctx); lastErr == nil {
 				return nil
 			}
 		}
@@ -255,28 +369,12 @@ func (s *BlueGreenReloadStrategy) rollbackStart(ctx context.Context) {
 
 // RestartCollector implements SupervisorCommander interface
 func (s *BlueGreenReloadStrategy) RestartCollector(ctx context.Context, reason string) error {
-	// Log the restart reason
-	s.supervisor.logger.Info("Restarting collector", zap.String("reason", reason))
-	
-	// Stop and start the collector
-	if s.supervisor.collector != nil {
-		if err := s.supervisor.collector.Stop(ctx); err != nil {
-			return fmt.Errorf("failed to stop collector: %w", err)
-		}
-	}
-	
-	return s.supervisor.startCollector(ctx)
+	return s.supervisor.RestartCollector(ctx, reason)
 }
 
 // StopCollector implements SupervisorCommander interface
 func (s *BlueGreenReloadStrategy) StopCollector(ctx context.Context, gracePeriod time.Duration) error {
-	if s.supervisor.collector != nil {
-		// Create context with timeout
-		stopCtx, cancel := context.WithTimeout(ctx, gracePeriod)
-		defer cancel()
-		return s.supervisor.collector.Stop(stopCtx)
-	}
-	return nil
+	return s.supervisor.StopCollector(ctx, gracePeriod)
 }
 
 // StartCollector implements SupervisorCommander interface
@@ -287,6 +385,5 @@ func (s *BlueGreenReloadStrategy) StartCollector(ctx context.Context, source str
 
 // UpdateCollector implements SupervisorCommander interface
 func (s *BlueGreenReloadStrategy) UpdateCollector(ctx context.Context, update *models.CollectorUpdate) (*models.UpdateResult, error) {
-	// Not implemented
-	return nil, fmt.Errorf("collector updates not implemented")
+	return s.supervisor.UpdateCollector(ctx, update)
 }
\ No newline at end of file
@@ -0,0 +1,101 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	configengine "github.com/newrelic/nrdot-host/nrdot-config-engine"
+	"go.uber.org/zap"
+)
+
+// remoteConfigStateFileName keeps the serial of the last remote bundle
+// applied under WorkDir, so older bundles are refused after a restart
+const remoteConfigStateFileName = "remote_config.json"
+
+// RemoteConfigStatus returns the state of remote configuration polling, nil
+// without a RemoteConfig
+func (s *UnifiedSupervisor) RemoteConfigStatus() *configengine.RemoteStatus {
+	if s.remoteConfig == nil {
+		return nil
+	}
+	status := s.remoteConfig.Status()
+	return &status
+}
+
+// remoteConfigLoop polls remote configuration now and every interval
+func (s *UnifiedSupervisor) remoteConfigLoop(ctx context.Context) {
+	s.logger.Info("Starting remote configuration polling",
+		zap.Duration("interval", s.remoteConfig.Interval()))
+
+	s.pollRemoteConfig(ctx)
+
+	ticker := time.NewTicker(s.remoteConfig.Interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.pollRemoteConfig(ctx)
+		}
+	}
+}
+
+// pollRemoteConfig polls once. Bundles the engine rejects are recorded by
+// applyRemoteConfig; ones that fail verification here.
+func (s *UnifiedSupervisor) pollRemoteConfig(ctx context.Context) {
+	err := s.remoteConfig.Poll(ctx)
+	switch {
+	case err == nil:
+	case errors.Is(err, configengine.ErrRemoteBundleInvalid):
+		s.recordEvent(models.EventTypeConfigRejected, models.EventSeverityError,
+			"Remote configuration bundle rejected", err.Error())
+	default:
+		s.logger.Warn("Remote configuration poll failed", zap.Error(err))
+	}
+}
+
+// applyRemoteConfig applies the user config of a verified remote bundle
+// through the config engine and reloads a running collector with it, like
+// a config file change. If the reload fails the engine is returned to the
+// version the collector runs. It returns the config version applied.
+func (s *UnifiedSupervisor) applyRemoteConfig(ctx context.Context, config []byte, version string) (int, error) {
+	// Config file and remote reloads take turns
+	s.configFileMu.Lock()
+	defer s.configFileMu.Unlock()
+
+	previous := s.currentConfigVersion(ctx)
+
+	result, err := s.configEngine.ApplyConfig(ctx, &models.ConfigUpdate{
+		Config:      config,
+		Format:      "yaml",
+		Source:      "remote",
+		Author:      "supervisor",
+		Description: "Remote configuration " + version,
+		Metadata: map[string]string{
+			configengine.RemoteVersionMetadataKey: version,
+		},
+	})
+	if err == nil && !result.Success {
+		err = configResultError(result)
+	}
+	if err != nil {
+		s.recordEvent(models.EventTypeConfigRejected, models.EventSeverityWarning,
+			"Remote configuration rejected", fmt.Sprintf("%s: %v", version, err))
+		return 0, err
+	}
+
+	if err := s.reloadAppliedConfig(ctx, previous); err != nil {
+		s.recordEvent(models.EventTypeConfigRejected, models.EventSeverityError,
+			"Remote configuration reload failed", fmt.Sprintf("%s: %v", version, err))
+		return 0, fmt.Errorf("reload failed: %w", err)
+	}
+
+	s.recordEvent(models.EventTypeConfigChanged, models.EventSeverityInfo,
+		"Remote configuration applied", fmt.Sprintf("%s (config version %d)", version, result.Version))
+	return result.Version, nil
+}
@@ -0,0 +1,152 @@
+package supervisor
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	configengine "github.com/newrelic/nrdot-host/nrdot-config-engine"
+	"go.uber.org/zap/zaptest"
+)
+
+// signedBundleServer serves a remote config bundle and records reports
+type signedBundleServer struct {
+	*httptest.Server
+	key *ecdsa.PrivateKey
+
+	mu      sync.Mutex
+	bundle  configengine.RemoteBundle
+	reports []configengine.RemoteReport
+}
+
+func newSignedBundleServer(t *testing.T) *signedBundleServer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	s := &signedBundleServer{key: key}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if r.Method == http.MethodPost {
+			var report configengine.RemoteReport
+			json.NewDecoder(r.Body).Decode(&report)
+			s.reports = append(s.reports, report)
+			return
+		}
+		json.NewEncoder(w).Encode(s.bundle)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// serve publishes config as version, signed unless tampered
+func (s *signedBundleServer) serve(t *testing.T, serial uint64, version, config string, tampered bool) {
+	hash := sha256.Sum256(configengine.SignedBundleData(serial, version, config))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, hash[:])
+	if err != nil {
+		t.Fatalf("Failed to sign bundle: %v", err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+	if tampered {
+		config += "metrics:\n  interval: 1s\n"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bundle = configengine.RemoteBundle{
+		Version:   version,
+		Serial:    serial,
+		Config:    config,
+		Signature: base64.StdEncoding.EncodeToString(signature),
+	}
+}
+
+func (s *signedBundleServer) publicKeyPEM(t *testing.T) string {
+	der, err := x509.MarshalPKIXPublicKey(&s.key.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to encode key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func (s *signedBundleServer) received() []configengine.RemoteReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]configengine.RemoteReport(nil), s.reports...)
+}
+
+func TestUnifiedSupervisor_RemoteConfig(t *testing.T) {
+	server := newSignedBundleServer(t)
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		WorkDir: t.TempDir(),
+		RemoteConfig: &configengine.RemoteConfig{
+			URL:       server.URL,
+			PublicKey: server.publicKeyPEM(t),
+		},
+		Logger: zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	events, cancel := s.SubscribeEvents(EventFilter{Types: []string{"config."}})
+	defer cancel()
+	ctx := context.Background()
+
+	server.serve(t, 1, "2024-06-01.1", testUserConfigV1, false)
+	s.pollRemoteConfig(ctx)
+	current := currentConfigVersion(t, s)
+	if current.Source != "remote" || current.Metadata[configengine.RemoteVersionMetadataKey] != "2024-06-01.1" {
+		t.Errorf("Unexpected current version %+v", current)
+	}
+	if event := receiveEvent(t, events); event.Type != models.EventTypeConfigChanged || event.Details != "2024-06-01.1 (config version 1)" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	if status := s.RemoteConfigStatus(); status == nil || status.Version != "2024-06-01.1" {
+		t.Errorf("Unexpected remote config status %+v", status)
+	}
+
+	// A bundle that does not verify is not applied
+	server.serve(t, 2, "2024-06-01.2", testUserConfigV2, true)
+	s.pollRemoteConfig(ctx)
+	if current := currentConfigVersion(t, s); current.Version != 1 {
+		t.Errorf("Expected version 1 to stay current, got %d", current.Version)
+	}
+	if event := receiveEvent(t, events); event.Type != models.EventTypeConfigRejected {
+		t.Errorf("Expected config.rejected, got %+v", event)
+	}
+
+	reports := server.received()
+	if len(reports) != 2 {
+		t.Fatalf("Expected 2 reports, got %+v", reports)
+	}
+	if reports[0].Status != configengine.RemoteStatusApplied || reports[0].ConfigVersion != 1 {
+		t.Errorf("Unexpected report %+v", reports[0])
+	}
+	if reports[1].Status != configengine.RemoteStatusRejected || reports[1].Version != "2024-06-01.2" {
+		t.Errorf("Unexpected report %+v", reports[1])
+	}
+}
+
+func TestNewUnifiedSupervisor_InvalidRemoteConfig(t *testing.T) {
+	_, err := NewUnifiedSupervisor(SupervisorConfig{
+		RemoteConfig: &configengine.RemoteConfig{URL: "http://config.example.com"},
+		Logger:       zaptest.NewLogger(t),
+	})
+	if err == nil {
+		t.Fatal("Expected an invalid remote config to be rejected")
+	}
+}
@@ -0,0 +1,663 @@
+package supervisor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// secretValidationTimeout bounds a credential check against its service
+const secretValidationTimeout = 10 * time.Second
+
+// maxSCRAMIterations caps the PBKDF2 iteration count a SCRAM server may ask
+// for, so a hostile endpoint cannot make a check spin; PostgreSQL uses 4096
+const maxSCRAMIterations = 100000
+
+// Services a secret can be validated against
+const (
+	SecretServiceMySQL      = "mysql"
+	SecretServicePostgreSQL = "postgresql"
+	SecretServiceRedis      = "redis"
+	SecretServiceHTTP       = "http"
+)
+
+// SecretValidation describes the service a secret is a credential for. The
+// supervisor logs in with it before storing the secret.
+type SecretValidation struct {
+	Service  string `json:"service"`            // mysql, postgresql, redis or http
+	Endpoint string `json:"endpoint"`           // host:port, or a URL for http
+	Username string `json:"username,omitempty"` // required except for redis
+	Database string `json:"database,omitempty"` // postgresql only, defaults to postgres
+}
+
+// check reports whether the validation request is complete
+func (v *SecretValidation) check() error {
+	switch v.Service {
+	case SecretServiceMySQL, SecretServicePostgreSQL, SecretServiceHTTP:
+		if v.Username == "" {
+			return fmt.Errorf("validating a %s credential requires a username", v.Service)
+		}
+	case SecretServiceRedis:
+	default:
+		return fmt.Errorf("cannot validate credentials for service %q (supported: mysql, postgresql, redis, http)", v.Service)
+	}
+	if v.Endpoint == "" {
+		return fmt.Errorf("validating a %s credential requires an endpoint", v.Service)
+	}
+	return nil
+}
+
+// validateCredential logs in to the service with password and reports an
+// error if the service rejects it or cannot be reached
+func validateCredential(ctx context.Context, v *SecretValidation, password string) error {
+	ctx, cancel := context.WithTimeout(ctx, secretValidationTimeout)
+	defer cancel()
+
+	if v.Service == SecretServiceHTTP {
+		return validateHTTPCredential(ctx, v, password)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", v.Endpoint)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", v.Endpoint, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	switch v.Service {
+	case SecretServiceMySQL:
+		return validateMySQLCredential(conn, v.Username, password)
+	case SecretServicePostgreSQL:
+		database := v.Database
+		if database == "" {
+			database = "postgres"
+		}
+		return validatePostgresCredential(conn, v.Username, database, password)
+	case SecretServiceRedis:
+		return validateRedisCredential(conn, v.Username, password)
+	}
+	return fmt.Errorf("unsupported service %q", v.Service)
+}
+
+// validateHTTPCredential requests the endpoint with basic auth; 401 and 403
+// mean the credential was rejected
+func validateHTTPCredential(ctx context.Context, v *SecretValidation, password string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.Endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint: %w", err)
+	}
+	req.SetBasicAuth(v.Username, password)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("requesting %s: %w", v.Endpoint, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%s rejected the credential (status %d)", v.Endpoint, resp.StatusCode)
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("%s returned status %d", v.Endpoint, resp.StatusCode)
+	}
+	return nil
+}
+
+// validateRedisCredential sends AUTH [username] password
+func validateRedisCredential(conn net.Conn, username, password string) error {
+	args := []string{"AUTH", password}
+	if username != "" {
+		args = []string{"AUTH", username, password}
+	}
+
+	var cmd bytes.Buffer
+	fmt.Fprintf(&cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write(cmd.Bytes()); err != nil {
+		return fmt.Errorf("sending AUTH: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("reading AUTH reply: %w", err)
+	}
+	reply = strings.TrimSpace(reply)
+	if reply != "+OK" {
+		return fmt.Errorf("redis: %s", strings.TrimPrefix(reply, "-"))
+	}
+	return nil
+}
+
+// MySQL client/server protocol
+
+const (
+	mysqlClientLongPassword     = 0x00000001
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSecureConnection = 0x00008000
+	mysqlClientPluginAuth       = 0x00080000
+
+	mysqlCharsetUTF8MB4 = 45
+
+	mysqlNativePassword = "mysql_native_password"
+	mysqlCachingSHA2    = "caching_sha2_password"
+)
+
+// mysqlConn frames MySQL protocol packets
+type mysqlConn struct {
+	rw  io.ReadWriter
+	seq byte
+}
+
+func (c *mysqlConn) readPacket() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.rw, header[:]); err != nil {
+		return nil, err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	c.seq = header[3] + 1
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func (c *mysqlConn) writePacket(payload []byte) error {
+	header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), c.seq}
+	c.seq++
+	_, err := c.rw.Write(append(header, payload...))
+	return err
+}
+
+// validateMySQLCredential performs the MySQL handshake with the
+// mysql_native_password or caching_sha2_password plugin
+func validateMySQLCredential(conn net.Conn, username, password string) error {
+	c := &mysqlConn{rw: conn}
+
+	greeting, err := c.readPacket()
+	if err != nil {
+		return fmt.Errorf("reading mysql handshake: %w", err)
+	}
+	if len(greeting) > 0 && greeting[0] == 0xff {
+		return mysqlError(greeting)
+	}
+	scramble, plugin, err := parseMySQLHandshake(greeting)
+	if err != nil {
+		return err
+	}
+	if plugin != mysqlNativePassword && plugin != mysqlCachingSHA2 {
+		plugin = mysqlNativePassword
+	}
+
+	authData := mysqlScramble(plugin, scramble, password)
+
+	var response bytes.Buffer
+	binary.Write(&response, binary.LittleEndian, uint32(mysqlClientLongPassword|mysqlClientProtocol41|mysqlClientSecureConnection|mysqlClientPluginAuth))
+	binary.Write(&response, binary.LittleEndian, uint32(16*1024*1024))
+	response.WriteByte(mysqlCharsetUTF8MB4)
+	response.Write(make([]byte, 23))
+	response.WriteString(username)
+	response.WriteByte(0)
+	response.WriteByte(byte(len(authData)))
+	response.Write(authData)
+	response.WriteString(plugin)
+	response.WriteByte(0)
+	if err := c.writePacket(response.Bytes()); err != nil {
+		return fmt.Errorf("sending mysql handshake response: %w", err)
+	}
+
+	for {
+		packet, err := c.readPacket()
+		if err != nil {
+			return fmt.Errorf("reading mysql auth result: %w", err)
+		}
+		if len(packet) == 0 {
+			return fmt.Errorf("empty mysql auth result")
+		}
+
+		switch packet[0] {
+		case 0x00: // OK
+			return nil
+		case 0xff: // ERR
+			return mysqlError(packet)
+		case 0xfe: // auth switch request
+			rest := packet[1:]
+			end := bytes.IndexByte(rest, 0)
+			if end < 0 {
+				return fmt.Errorf("malformed mysql auth switch request")
+			}
+			plugin = string(rest[:end])
+			scramble = bytes.TrimSuffix(rest[end+1:], []byte{0})
+			if plugin != mysqlNativePassword && plugin != mysqlCachingSHA2 {
+				return fmt.Errorf("unsupported mysql auth plugin %s", plugin)
+			}
+			if err := c.writePacket(mysqlScramble(plugin, scramble, password)); err != nil {
+				return fmt.Errorf("sending mysql auth switch response: %w", err)
+			}
+		case 0x01: // caching_sha2_password more data
+			if len(packet) < 2 {
+				return fmt.Errorf("malformed mysql auth data")
+			}
+			switch packet[1] {
+			case 3: // fast auth succeeded, OK follows
+			case 4: // full auth: send the password RSA-encrypted
+				if err := mysqlFullAuth(c, scramble, password); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected mysql auth data %d", packet[1])
+			}
+		default:
+			return fmt.Errorf("unexpected mysql packet 0x%02x", packet[0])
+		}
+	}
+}
+
+// parseMySQLHandshake extracts the scramble and auth plugin of a v10 handshake
+func parseMySQLHandshake(p []byte) ([]byte, string, error) {
+	malformed := fmt.Errorf("malformed mysql handshake")
+	if len(p) < 1 || p[0] != 10 {
+		return nil, "", fmt.Errorf("unsupported mysql protocol version")
+	}
+	pos := bytes.IndexByte(p[1:], 0)
+	if pos < 0 {
+		return nil, "", malformed
+	}
+	pos += 2 // version string and its terminator
+	pos += 4 // connection id
+	if len(p) < pos+8+1+2 {
+		return nil, "", malformed
+	}
+	scramble := append([]byte{}, p[pos:pos+8]...)
+	pos += 8 + 1 // scramble part 1, filler
+	pos += 2     // capability flags, lower
+
+	plugin := mysqlNativePassword
+	if len(p) >= pos+1+2+2+1+10 {
+		pos += 1 + 2 + 2 // charset, status, capability flags, upper
+		authDataLen := int(p[pos])
+		pos += 1 + 10 // auth data length, reserved
+
+		part2Len := authDataLen - 8
+		if part2Len < 13 {
+			part2Len = 13
+		}
+		if len(p) < pos+part2Len {
+			return nil, "", malformed
+		}
+		scramble = append(scramble, bytes.TrimSuffix(p[pos:pos+part2Len], []byte{0})...)
+		pos += part2Len
+
+		if end := bytes.IndexByte(p[pos:], 0); end >= 0 {
+			plugin = string(p[pos : pos+end])
+		} else if pos < len(p) {
+			plugin = string(p[pos:])
+		}
+	}
+	return scramble, plugin, nil
+}
+
+// mysqlScramble computes the auth response of a plugin
+func mysqlScramble(plugin string, scramble []byte, password string) []byte {
+	if password == "" {
+		return nil
+	}
+
+	if plugin == mysqlCachingSHA2 {
+		// XOR(SHA256(password), SHA256(SHA256(SHA256(password)), scramble))
+		m1 := sha256.Sum256([]byte(password))
+		m2 := sha256.Sum256(m1[:])
+		h := sha256.New()
+		h.Write(m2[:])
+		h.Write(scramble)
+		m3 := h.Sum(nil)
+		for i := range m1 {
+			m1[i] ^= m3[i]
+		}
+		return m1[:]
+	}
+
+	// XOR(SHA1(password), SHA1(scramble, SHA1(SHA1(password))))
+	s1 := sha1.Sum([]byte(password))
+	s2 := sha1.Sum(s1[:])
+	h := sha1.New()
+	h.Write(scramble)
+	h.Write(s2[:])
+	s3 := h.Sum(nil)
+	for i := range s1 {
+		s1[i] ^= s3[i]
+	}
+	return s1[:]
+}
+
+// mysqlFullAuth sends the password encrypted with the server's RSA key, as
+// caching_sha2_password requires on connections without TLS
+func mysqlFullAuth(c *mysqlConn, scramble []byte, password string) error {
+	// Request the public key
+	if err := c.writePacket([]byte{2}); err != nil {
+		return fmt.Errorf("requesting mysql public key: %w", err)
+	}
+	packet, err := c.readPacket()
+	if err != nil {
+		return fmt.Errorf("reading mysql public key: %w", err)
+	}
+	if len(packet) > 0 && packet[0] == 0xff {
+		return mysqlError(packet)
+	}
+	if len(packet) < 2 || packet[0] != 0x01 {
+		return fmt.Errorf("unexpected mysql public key response")
+	}
+
+	block, _ := pem.Decode(packet[1:])
+	if block == nil {
+		return fmt.Errorf("invalid mysql public key")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid mysql public key: %w", err)
+	}
+	publicKey, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("mysql public key is not RSA")
+	}
+
+	plain := append([]byte(password), 0)
+	for i := range plain {
+		plain[i] ^= scramble[i%len(scramble)]
+	}
+	encrypted, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, publicKey, plain, nil)
+	if err != nil {
+		return fmt.Errorf("encrypting mysql password: %w", err)
+	}
+	if err := c.writePacket(encrypted); err != nil {
+		return fmt.Errorf("sending mysql password: %w", err)
+	}
+	return nil
+}
+
+// mysqlError formats an ERR packet
+func mysqlError(p []byte) error {
+	if len(p) < 3 {
+		return fmt.Errorf("mysql error")
+	}
+	code := binary.LittleEndian.Uint16(p[1:3])
+	message := p[3:]
+	if len(message) > 0 && message[0] == '#' && len(message) >= 6 {
+		message = message[6:] // SQL state
+	}
+	return fmt.Errorf("mysql error %d: %s", code, message)
+}
+
+// PostgreSQL frontend/backend protocol
+
+const (
+	postgresProtocolVersion = 196608 // 3.0
+
+	postgresAuthOK           = 0
+	postgresAuthCleartext    = 3
+	postgresAuthMD5          = 5
+	postgresAuthSASL         = 10
+	postgresAuthSASLContinue = 11
+	postgresAuthSASLFinal    = 12
+
+	postgresSCRAM = "SCRAM-SHA-256"
+)
+
+// validatePostgresCredential authenticates with a startup message; the
+// cleartext, md5 and SCRAM-SHA-256 methods are supported
+func validatePostgresCredential(conn net.Conn, username, database, password string) error {
+	var startup bytes.Buffer
+	binary.Write(&startup, binary.BigEndian, uint32(postgresProtocolVersion))
+	for _, s := range []string{"user", username, "database", database} {
+		startup.WriteString(s)
+		startup.WriteByte(0)
+	}
+	startup.WriteByte(0)
+
+	msg := make([]byte, 4, 4+startup.Len())
+	binary.BigEndian.PutUint32(msg, uint32(4+startup.Len()))
+	if _, err := conn.Write(append(msg, startup.Bytes()...)); err != nil {
+		return fmt.Errorf("sending postgres startup: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	var scram *scramClient
+	for {
+		msgType, payload, err := readPostgresMessage(reader)
+		if err != nil {
+			return fmt.Errorf("reading postgres auth: %w", err)
+		}
+
+		switch msgType {
+		case 'E':
+			return postgresError(payload)
+		case 'R':
+			if len(payload) < 4 {
+				return fmt.Errorf("malformed postgres auth request")
+			}
+			method := binary.BigEndian.Uint32(payload)
+			data := payload[4:]
+
+			switch method {
+			case postgresAuthOK:
+				writePostgresMessage(conn, 'X', nil) // terminate
+				return nil
+			case postgresAuthCleartext:
+				err = writePostgresMessage(conn, 'p', append([]byte(password), 0))
+			case postgresAuthMD5:
+				if len(data) < 4 {
+					return fmt.Errorf("malformed postgres md5 request")
+				}
+				err = writePostgresMessage(conn, 'p', append([]byte(postgresMD5(username, password, data[:4])), 0))
+			case postgresAuthSASL:
+				if !bytes.Contains(data, []byte(postgresSCRAM+"\x00")) {
+					return fmt.Errorf("postgres offers no supported SASL mechanism")
+				}
+				scram, err = newSCRAMClient(password)
+				if err != nil {
+					return err
+				}
+				first := scram.firstMessage()
+				var body bytes.Buffer
+				body.WriteString(postgresSCRAM)
+				body.WriteByte(0)
+				binary.Write(&body, binary.BigEndian, uint32(len(first)))
+				body.WriteString(first)
+				err = writePostgresMessage(conn, 'p', body.Bytes())
+			case postgresAuthSASLContinue:
+				if scram == nil {
+					return fmt.Errorf("unexpected postgres SASL continue")
+				}
+				final, serr := scram.finalMessage(string(data))
+				if serr != nil {
+					return serr
+				}
+				err = writePostgresMessage(conn, 'p', []byte(final))
+			case postgresAuthSASLFinal:
+				if scram == nil {
+					return fmt.Errorf("unexpected postgres SASL final")
+				}
+				if err := scram.verifyServer(string(data)); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unsupported postgres auth method %d", method)
+			}
+			if err != nil {
+				return fmt.Errorf("sending postgres auth: %w", err)
+			}
+		default:
+			// NoticeResponse and the like before authentication completes
+		}
+	}
+}
+
+func readPostgresMessage(r *bufio.Reader) (byte, []byte, error) {
+	msgType, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return 0, nil, err
+	}
+	if length < 4 || length > 1<<20 {
+		return 0, nil, fmt.Errorf("invalid postgres message length %d", length)
+	}
+	payload := make([]byte, length-4)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return msgType, payload, nil
+}
+
+func writePostgresMessage(w io.Writer, msgType byte, payload []byte) error {
+	msg := make([]byte, 5, 5+len(payload))
+	msg[0] = msgType
+	binary.BigEndian.PutUint32(msg[1:], uint32(4+len(payload)))
+	_, err := w.Write(append(msg, payload...))
+	return err
+}
+
+// postgresMD5 computes "md5" + md5hex(md5hex(password + user) + salt)
+func postgresMD5(username, password string, salt []byte) string {
+	inner := md5.Sum([]byte(password + username))
+	outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt...))
+	return "md5" + hex.EncodeToString(outer[:])
+}
+
+// postgresError formats an ErrorResponse
+func postgresError(payload []byte) error {
+	var code, message string
+	for _, field := range bytes.Split(payload, []byte{0}) {
+		if len(field) < 2 {
+			continue
+		}
+		switch field[0] {
+		case 'C':
+			code = string(field[1:])
+		case 'M':
+			message = string(field[1:])
+		}
+	}
+	return fmt.Errorf("postgres error %s: %s", code, message)
+}
+
+// scramClient implements the client side of SCRAM-SHA-256 (RFC 7677) as
+// used by PostgreSQL, without channel binding
+type scramClient struct {
+	password    string
+	clientNonce string
+	firstBare   string
+	authMessage string
+	saltedPass  []byte
+}
+
+func newSCRAMClient(password string) (*scramClient, error) {
+	nonce := make([]byte, 18)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("generating SCRAM nonce: %w", err)
+	}
+	c := &scramClient{
+		password:    password,
+		clientNonce: base64.RawStdEncoding.EncodeToString(nonce),
+	}
+	// PostgreSQL takes the user from the startup message
+	c.firstBare = "n=,r=" + c.clientNonce
+	return c, nil
+}
+
+func (c *scramClient) firstMessage() string {
+	return "n,," + c.firstBare
+}
+
+func (c *scramClient) finalMessage(serverFirst string) (string, error) {
+	attrs := scramAttributes(serverFirst)
+	nonce, salt64, iterStr := attrs["r"], attrs["s"], attrs["i"]
+	if !strings.HasPrefix(nonce, c.clientNonce) {
+		return "", fmt.Errorf("invalid SCRAM server nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(salt64)
+	if err != nil {
+		return "", fmt.Errorf("invalid SCRAM salt: %w", err)
+	}
+	iterations, err := strconv.Atoi(iterStr)
+	if err != nil || iterations < 1 {
+		return "", fmt.Errorf("invalid SCRAM iteration count %q", iterStr)
+	}
+	if iterations > maxSCRAMIterations {
+		return "", fmt.Errorf("SCRAM iteration count %d exceeds %d", iterations, maxSCRAMIterations)
+	}
+
+	c.saltedPass = pbkdf2.Key([]byte(c.password), salt, iterations, sha256.Size, sha256.New)
+	clientKey := hmacSHA256(c.saltedPass, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+
+	withoutProof := "c=biws,r=" + nonce
+	c.authMessage = c.firstBare + "," + serverFirst + "," + withoutProof
+
+	signature := hmacSHA256(storedKey[:], c.authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ signature[i]
+	}
+	return withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+func (c *scramClient) verifyServer(serverFinal string) error {
+	attrs := scramAttributes(serverFinal)
+	if e, ok := attrs["e"]; ok {
+		return fmt.Errorf("SCRAM authentication failed: %s", e)
+	}
+	serverKey := hmacSHA256(c.saltedPass, "Server Key")
+	expected := hmacSHA256(serverKey, c.authMessage)
+	got, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || !hmac.Equal(got, expected) {
+		return fmt.Errorf("invalid SCRAM server signature")
+	}
+	return nil
+}
+
+// scramAttributes parses "k=v,k=v" SCRAM messages
+func scramAttributes(msg string) map[string]string {
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(msg, ",") {
+		if len(part) >= 2 && part[1] == '=' {
+			attrs[part[:1]] = part[2:]
+		}
+	}
+	return attrs
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
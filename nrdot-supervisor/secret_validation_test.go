@@ -0,0 +1,278 @@
+package supervisor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// serveOnce accepts a single connection on a local listener and hands it to
+// handle; it returns the listener address
+func serveOnce(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(conn)
+	}()
+	return listener.Addr().String()
+}
+
+func TestSecretValidation_Check(t *testing.T) {
+	tests := []struct {
+		validation SecretValidation
+		valid      bool
+	}{
+		{SecretValidation{Service: "mysql", Endpoint: "localhost:3306", Username: "newrelic"}, true},
+		{SecretValidation{Service: "mysql", Endpoint: "localhost:3306"}, false},
+		{SecretValidation{Service: "redis", Endpoint: "localhost:6379"}, true},
+		{SecretValidation{Service: "redis"}, false},
+		{SecretValidation{Service: "oracle", Endpoint: "localhost:1521", Username: "x"}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.validation.check()
+		if (err == nil) != tt.valid {
+			t.Errorf("%+v: expected valid=%v, got %v", tt.validation, tt.valid, err)
+		}
+	}
+}
+
+func TestValidateCredential_Redis(t *testing.T) {
+	fakeRedis := func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		var args []string
+		header, _ := reader.ReadString('\n')
+		for i := 0; i < int(header[1]-'0'); i++ {
+			reader.ReadString('\n') // $len
+			arg, _ := reader.ReadString('\n')
+			args = append(args, strings.TrimSpace(arg))
+		}
+		if len(args) == 2 && args[0] == "AUTH" && args[1] == "s3cret" {
+			io.WriteString(conn, "+OK\r\n")
+		} else {
+			io.WriteString(conn, "-WRONGPASS invalid username-password pair\r\n")
+		}
+	}
+
+	ctx := context.Background()
+	addr := serveOnce(t, fakeRedis)
+	if err := validateCredential(ctx, &SecretValidation{Service: "redis", Endpoint: addr}, "s3cret"); err != nil {
+		t.Errorf("Expected valid credential, got %v", err)
+	}
+
+	addr = serveOnce(t, fakeRedis)
+	err := validateCredential(ctx, &SecretValidation{Service: "redis", Endpoint: addr}, "wrong")
+	if err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("Expected WRONGPASS error, got %v", err)
+	}
+}
+
+func TestValidateCredential_HTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "elastic" || pass != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	v := &SecretValidation{Service: "http", Endpoint: server.URL, Username: "elastic"}
+	if err := validateCredential(ctx, v, "s3cret"); err != nil {
+		t.Errorf("Expected valid credential, got %v", err)
+	}
+	if err := validateCredential(ctx, v, "wrong"); err == nil {
+		t.Error("Expected rejected credential")
+	}
+}
+
+func TestValidateCredential_MySQL(t *testing.T) {
+	scramble := []byte("abcdefghijklmnopqrst")
+
+	fakeMySQL := func(conn net.Conn) {
+		c := &mysqlConn{rw: conn}
+
+		var greeting bytes.Buffer
+		greeting.WriteByte(10)
+		greeting.WriteString("8.0.36\x00")
+		greeting.Write([]byte{1, 0, 0, 0}) // connection id
+		greeting.Write(scramble[:8])
+		greeting.WriteByte(0)
+		greeting.Write([]byte{0xff, 0xf7}) // capabilities, lower
+		greeting.WriteByte(mysqlCharsetUTF8MB4)
+		greeting.Write([]byte{2, 0})       // status
+		greeting.Write([]byte{0xff, 0x81}) // capabilities, upper
+		greeting.WriteByte(21)
+		greeting.Write(make([]byte, 10))
+		greeting.Write(scramble[8:])
+		greeting.WriteByte(0)
+		greeting.WriteString(mysqlNativePassword + "\x00")
+		c.writePacket(greeting.Bytes())
+
+		response, err := c.readPacket()
+		if err != nil {
+			return
+		}
+		rest := response[32:]
+		end := bytes.IndexByte(rest, 0)
+		username := string(rest[:end])
+		rest = rest[end+1:]
+		authData := rest[1 : 1+int(rest[0])]
+
+		if username == "newrelic" && bytes.Equal(authData, mysqlScramble(mysqlNativePassword, scramble, "s3cret")) {
+			c.writePacket([]byte{0x00, 0, 0, 2, 0, 0, 0})
+		} else {
+			c.writePacket(append([]byte{0xff, 0x15, 0x04, '#', '2', '8', '0', '0', '0'}, "Access denied for user 'newrelic'"...))
+		}
+	}
+
+	ctx := context.Background()
+	addr := serveOnce(t, fakeMySQL)
+	v := &SecretValidation{Service: "mysql", Endpoint: addr, Username: "newrelic"}
+	if err := validateCredential(ctx, v, "s3cret"); err != nil {
+		t.Errorf("Expected valid credential, got %v", err)
+	}
+
+	v.Endpoint = serveOnce(t, fakeMySQL)
+	err := validateCredential(ctx, v, "wrong")
+	if err == nil || !strings.Contains(err.Error(), "mysql error 1045: Access denied") {
+		t.Errorf("Expected access denied, got %v", err)
+	}
+}
+
+func TestMySQLScramble_NativePassword(t *testing.T) {
+	// The server knows only SHA1(SHA1(password)) and checks the reply as
+	// SHA1(reply XOR SHA1(scramble + stage2)) == stage2
+	scramble := []byte("01234567890123456789")
+	reply := mysqlScramble(mysqlNativePassword, scramble, "s3cret")
+	if len(reply) != 20 {
+		t.Fatalf("Expected 20-byte reply, got %d", len(reply))
+	}
+
+	stage1 := sha1.Sum([]byte("s3cret"))
+	stage2 := sha1.Sum(stage1[:])
+	h := sha1.Sum(append(append([]byte{}, scramble...), stage2[:]...))
+	candidate := make([]byte, 20)
+	for i := range candidate {
+		candidate[i] = reply[i] ^ h[i]
+	}
+	if sha1.Sum(candidate) != stage2 {
+		t.Error("Server-side check of the reply failed")
+	}
+
+	if mysqlScramble(mysqlNativePassword, scramble, "") != nil {
+		t.Error("Empty password must produce an empty reply")
+	}
+}
+
+func TestSCRAMClient_IterationCount(t *testing.T) {
+	client, err := newSCRAMClient("s3cret")
+	if err != nil {
+		t.Fatalf("Failed to create SCRAM client: %v", err)
+	}
+	salt := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+
+	for _, iterations := range []string{"0", "-1", "many", strconv.Itoa(maxSCRAMIterations + 1), "2147483648"} {
+		serverFirst := "r=" + client.clientNonce + "server,s=" + salt + ",i=" + iterations
+		if _, err := client.finalMessage(serverFirst); err == nil {
+			t.Errorf("Expected iteration count %s to be rejected", iterations)
+		}
+	}
+
+	serverFirst := "r=" + client.clientNonce + "server,s=" + salt + ",i=4096"
+	if _, err := client.finalMessage(serverFirst); err != nil {
+		t.Errorf("Expected iteration count 4096 to be accepted, got %v", err)
+	}
+}
+
+func TestValidateCredential_PostgresSCRAM(t *testing.T) {
+	const password = "s3cret"
+
+	fakePostgres := func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+
+		// Startup message
+		var length uint32
+		binary.Read(reader, binary.BigEndian, &length)
+		io.ReadFull(reader, make([]byte, length-4))
+
+		writePostgresMessage(conn, 'R', append([]byte{0, 0, 0, postgresAuthSASL}, postgresSCRAM+"\x00\x00"...))
+
+		// SASLInitialResponse
+		_, payload, err := readPostgresMessage(reader)
+		if err != nil {
+			return
+		}
+		clientFirst := string(payload[len(postgresSCRAM)+1+4:])
+		clientFirstBare := strings.TrimPrefix(clientFirst, "n,,")
+		clientNonce := scramAttributes(clientFirstBare)["r"]
+
+		salt := []byte("0123456789abcdef")
+		serverFirst := "r=" + clientNonce + "server,s=" + base64.StdEncoding.EncodeToString(salt) + ",i=4096"
+		writePostgresMessage(conn, 'R', append([]byte{0, 0, 0, postgresAuthSASLContinue}, serverFirst...))
+
+		// SASLResponse
+		_, payload, err = readPostgresMessage(reader)
+		if err != nil {
+			return
+		}
+		clientFinal := string(payload)
+		proofAt := strings.LastIndex(clientFinal, ",p=")
+		proof, _ := base64.StdEncoding.DecodeString(clientFinal[proofAt+3:])
+		authMessage := clientFirstBare + "," + serverFirst + "," + clientFinal[:proofAt]
+
+		saltedPassword := pbkdf2.Key([]byte(password), salt, 4096, sha256.Size, sha256.New)
+		storedKey := sha256.Sum256(hmacSHA256(saltedPassword, "Client Key"))
+		signature := hmacSHA256(storedKey[:], authMessage)
+		clientKey := make([]byte, len(proof))
+		for i := range proof {
+			clientKey[i] = proof[i] ^ signature[i]
+		}
+		if sha256.Sum256(clientKey) != storedKey {
+			writePostgresMessage(conn, 'E', []byte("SFATAL\x00C28P01\x00Mpassword authentication failed for user \"newrelic\"\x00\x00"))
+			return
+		}
+
+		serverSignature := hmacSHA256(hmacSHA256(saltedPassword, "Server Key"), authMessage)
+		writePostgresMessage(conn, 'R', append([]byte{0, 0, 0, postgresAuthSASLFinal}, "v="+base64.StdEncoding.EncodeToString(serverSignature)...))
+		writePostgresMessage(conn, 'R', []byte{0, 0, 0, postgresAuthOK})
+		readPostgresMessage(reader) // Terminate
+	}
+
+	ctx := context.Background()
+	v := &SecretValidation{Service: "postgresql", Endpoint: serveOnce(t, fakePostgres), Username: "newrelic"}
+	if err := validateCredential(ctx, v, password); err != nil {
+		t.Errorf("Expected valid credential, got %v", err)
+	}
+
+	v.Endpoint = serveOnce(t, fakePostgres)
+	err := validateCredential(ctx, v, "wrong")
+	if err == nil || !strings.Contains(err.Error(), "28P01") {
+		t.Errorf("Expected authentication failure, got %v", err)
+	}
+}
@@ -0,0 +1,381 @@
+package supervisor
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"github.com/newrelic/nrdot-host/nrdot-config-engine/pkg/secrets"
+	"go.uber.org/zap"
+)
+
+const (
+	// secretsFileName holds the encrypted secrets under WorkDir
+	secretsFileName = "secrets.enc"
+
+	// secretsKeyFileName holds the AES-256 key for secretsFileName
+	secretsKeyFileName = "secrets.key"
+
+	// maxSecretRequestSize bounds the body of PUT /v1/secrets/{name}
+	maxSecretRequestSize = 64 * 1024
+)
+
+// Secrets are exposed to the collector as environment variables, so names
+// follow environment variable conventions, e.g. MYSQL_MONITOR_PASS
+var secretNamePattern = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+
+// SecretSetRequest is the body of PUT /v1/secrets/{name}
+type SecretSetRequest struct {
+	Value    string            `json:"value"`
+	Validate *SecretValidation `json:"validate,omitempty"`
+}
+
+// SecretSetResult is the response of PUT /v1/secrets/{name}. It never
+// contains the secret value.
+type SecretSetResult struct {
+	Name      string    `json:"name"`
+	Validated bool      `json:"validated"`
+	Message   string    `json:"message"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// storedSecret is one entry of the secrets file
+type storedSecret struct {
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// secretStore keeps service credentials encrypted at rest with AES-256-GCM.
+// The key is generated on first use next to the secrets file; both files are
+// readable by the supervisor user only.
+type secretStore struct {
+	mu      sync.Mutex
+	path    string
+	aead    cipher.AEAD
+	secrets map[string]storedSecret
+}
+
+// newSecretStore opens the secrets store in dir, creating its key if needed
+func newSecretStore(dir string) (*secretStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating secrets directory: %w", err)
+	}
+
+	key, err := loadOrCreateSecretsKey(filepath.Join(dir, secretsKeyFileName))
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating secrets cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("creating secrets cipher: %w", err)
+	}
+
+	s := &secretStore{
+		path:    filepath.Join(dir, secretsFileName),
+		aead:    aead,
+		secrets: make(map[string]storedSecret),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// set stores a secret and persists the store
+func (s *secretStore) set(name, value string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := make(map[string]storedSecret, len(s.secrets)+1)
+	for k, v := range s.secrets {
+		updated[k] = v
+	}
+	now := time.Now()
+	updated[name] = storedSecret{Value: value, UpdatedAt: now}
+
+	if err := s.save(updated); err != nil {
+		return time.Time{}, err
+	}
+	s.secrets = updated
+	return now, nil
+}
+
+// environ returns the secrets as NAME=value pairs, sorted by name
+func (s *secretStore) environ() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	env := make([]string, 0, len(s.secrets))
+	for name, secret := range s.secrets {
+		env = append(env, name+"="+secret.Value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// Name implements secrets.Provider
+func (s *secretStore) Name() string { return "store" }
+
+// Lookup implements secrets.Provider
+func (s *secretStore) Lookup(ctx context.Context, names []string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := make(map[string]string)
+	for _, name := range names {
+		if secret, ok := s.secrets[name]; ok {
+			found[name] = secret.Value
+		}
+	}
+	return found, nil
+}
+
+// load reads and decrypts the secrets file; a missing file is an empty store
+func (s *secretStore) load() error {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading secrets: %w", err)
+	}
+
+	nonceSize := s.aead.NonceSize()
+	if len(data) < nonceSize {
+		return fmt.Errorf("secrets file %s is corrupt", s.path)
+	}
+	plaintext, err := s.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return fmt.Errorf("decrypting secrets (wrong key?): %w", err)
+	}
+
+	if err := json.Unmarshal(plaintext, &s.secrets); err != nil {
+		return fmt.Errorf("decoding secrets: %w", err)
+	}
+	return nil
+}
+
+// save encrypts secrets and replaces the secrets file atomically
+func (s *secretStore) save(secrets map[string]storedSecret) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return fmt.Errorf("encoding secrets: %w", err)
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("generating nonce: %w", err)
+	}
+	data := s.aead.Seal(nonce, nonce, plaintext, nil)
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing secrets: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing secrets: %w", err)
+	}
+	return nil
+}
+
+// loadOrCreateSecretsKey reads the 32-byte store key, generating it on first use
+func loadOrCreateSecretsKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("secrets key %s must be 32 bytes, got %d", path, len(key))
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading secrets key: %w", err)
+	}
+
+	key = make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("generating secrets key: %w", err)
+	}
+	// Written to a temporary file first so a failed write never leaves a
+	// truncated key, then linked into place so a concurrently created key
+	// is never overwritten
+	tmp, err := os.CreateTemp(filepath.Dir(path), secretsKeyFileName+"-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("creating secrets key: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(key); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("writing secrets key: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("writing secrets key: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("writing secrets key: %w", err)
+	}
+	if err := os.Link(tmp.Name(), path); err != nil {
+		if os.IsExist(err) {
+			return loadOrCreateSecretsKey(path)
+		}
+		return nil, fmt.Errorf("creating secrets key: %w", err)
+	}
+	return key, nil
+}
+
+// collectorEnv returns the environment for collector processes: the
+// supervisor's own environment plus the stored secrets, so configs can
+// reference them as ${env:MYSQL_MONITOR_PASS}, and with SecretProviders the
+// variables otelConfig references, resolved when the collector starts
+func (s *UnifiedSupervisor) collectorEnv(ctx context.Context, otelConfig string) ([]string, error) {
+	env := os.Environ()
+	if s.secrets != nil {
+		env = append(env, s.secrets.environ()...)
+	}
+	if s.secretResolver != nil {
+		resolved, err := s.secretResolver.Environ(ctx, otelConfig)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, resolved...)
+	}
+	return env, nil
+}
+
+// newSecretResolver chains the secrets store, when there is one, before the
+// configured providers
+func newSecretResolver(store *secretStore, configs []secrets.ProviderConfig) (*secrets.Resolver, error) {
+	var providers []secrets.Provider
+	if store != nil {
+		providers = append(providers, store)
+	}
+	configured, err := secrets.NewResolverFromConfig(configs)
+	if err != nil {
+		return nil, err
+	}
+	providers = append(providers, configured.Providers()...)
+	return secrets.NewResolver(providers...), nil
+}
+
+// SetSecret validates value against the service described by validation, if
+// any, and stores it. The running collector picks it up on its next reload
+// or restart.
+func (s *UnifiedSupervisor) SetSecret(ctx context.Context, name, value string, validation *SecretValidation) (*SecretSetResult, error) {
+	if s.secrets == nil {
+		return nil, fmt.Errorf("secrets store is disabled (no work dir)")
+	}
+	if !secretNamePattern.MatchString(name) {
+		return nil, &secretRequestError{fmt.Sprintf("invalid secret name %q: use upper-case letters, digits and underscores", name)}
+	}
+	if value == "" {
+		return nil, &secretRequestError{"secret value is empty"}
+	}
+
+	result := &SecretSetResult{Name: name}
+	if validation != nil {
+		if err := validation.check(); err != nil {
+			return nil, &secretRequestError{err.Error()}
+		}
+		if err := validateCredential(ctx, validation, value); err != nil {
+			return nil, &credentialError{err: err}
+		}
+		result.Validated = true
+	}
+
+	updatedAt, err := s.secrets.set(name, value)
+	if err != nil {
+		return nil, err
+	}
+	result.UpdatedAt = updatedAt
+	result.Message = "Secret stored; the collector uses it after the next reload or restart"
+
+	s.logger.Info("Secret updated",
+		zap.String("name", name),
+		zap.Bool("validated", result.Validated))
+	return result, nil
+}
+
+// secretRequestError reports a malformed secret request
+type secretRequestError struct {
+	msg string
+}
+
+func (e *secretRequestError) Error() string {
+	return e.msg
+}
+
+// credentialError reports a secret rejected by its target service
+type credentialError struct {
+	err error
+}
+
+func (e *credentialError) Error() string {
+	return fmt.Sprintf("credential validation failed: %v", e.err)
+}
+
+func (e *credentialError) Unwrap() error {
+	return e.err
+}
+
+// handleSetSecret serves PUT /v1/secrets/{name}
+func (s *UnifiedSupervisor) handleSetSecret(w http.ResponseWriter, r *http.Request) {
+	// Track API request
+	s.metrics.IncrementRequests()
+
+	if s.secrets == nil {
+		problem.Error(w, r, "secrets store is disabled (no work dir)", http.StatusNotFound)
+		return
+	}
+
+	var req SecretSetRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxSecretRequestSize)).Decode(&req); err != nil {
+		problem.Error(w, r, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.SetSecret(r.Context(), mux.Vars(r)["name"], req.Value, req.Validate)
+	if err != nil {
+		var requestErr *secretRequestError
+		var credErr *credentialError
+		switch {
+		case errors.As(err, &requestErr):
+			problem.Error(w, r, err.Error(), http.StatusBadRequest)
+		case errors.As(err, &credErr):
+			problem.Error(w, r, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			problem.Error(w, r, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleSecretsAuthRequired refuses secret writes on an unauthenticated API
+func (s *UnifiedSupervisor) handleSecretsAuthRequired(w http.ResponseWriter, r *http.Request) {
+	// Track API request
+	s.metrics.IncrementRequests()
+
+	problem.Error(w, r, "secrets API requires authentication; start nrdot-host with --auth", http.StatusForbidden)
+}
@@ -0,0 +1,250 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/nrdot-host/nrdot-config-engine/pkg/secrets"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestSecretStore_Persistence(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := newSecretStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if _, err := store.set("MYSQL_MONITOR_PASS", "s3cret-value"); err != nil {
+		t.Fatalf("Failed to set secret: %v", err)
+	}
+	if _, err := store.set("REDIS_PASS", "other"); err != nil {
+		t.Fatalf("Failed to set secret: %v", err)
+	}
+
+	// Encrypted at rest and private
+	data, err := os.ReadFile(filepath.Join(dir, secretsFileName))
+	if err != nil {
+		t.Fatalf("Failed to read secrets file: %v", err)
+	}
+	if bytes.Contains(data, []byte("s3cret-value")) {
+		t.Error("Secrets file contains the plaintext value")
+	}
+	for _, name := range []string{secretsFileName, secretsKeyFileName} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", name, err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%s has mode %o, want 600", name, info.Mode().Perm())
+		}
+	}
+
+	// Reopening decrypts with the persisted key
+	reopened, err := newSecretStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	env := reopened.environ()
+	if len(env) != 2 || env[0] != "MYSQL_MONITOR_PASS=s3cret-value" || env[1] != "REDIS_PASS=other" {
+		t.Errorf("Unexpected environment: %v", env)
+	}
+
+	// A different key cannot read the store
+	if err := os.WriteFile(filepath.Join(dir, secretsKeyFileName), bytes.Repeat([]byte{1}, 32), 0600); err != nil {
+		t.Fatalf("Failed to replace key: %v", err)
+	}
+	if _, err := newSecretStore(dir); err == nil {
+		t.Error("Expected decryption to fail with the wrong key")
+	}
+}
+
+func TestLoadOrCreateSecretsKey(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, secretsKeyFileName)
+
+	key, err := loadOrCreateSecretsKey(path)
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if len(key) != 32 {
+		t.Fatalf("Expected a 32-byte key, got %d bytes", len(key))
+	}
+
+	// Only the key is left behind, not its temporary file
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != secretsKeyFileName {
+		t.Errorf("Expected only %s, got %v", secretsKeyFileName, entries)
+	}
+
+	loaded, err := loadOrCreateSecretsKey(path)
+	if err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+	if !bytes.Equal(loaded, key) {
+		t.Error("Expected the persisted key to be loaded")
+	}
+
+	// A truncated key is refused rather than replaced
+	if err := os.WriteFile(path, key[:16], 0600); err != nil {
+		t.Fatalf("Failed to truncate key: %v", err)
+	}
+	if _, err := loadOrCreateSecretsKey(path); err == nil {
+		t.Error("Expected a truncated key to be refused")
+	}
+}
+
+func TestHandleSetSecret(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		WorkDir: dir,
+		Logger:  zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/secrets/{name}", s.handleSetSecret).Methods("PUT")
+
+	put := func(name string, req SecretSetRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("PUT", "/v1/secrets/"+name, bytes.NewReader(body)))
+		return rec
+	}
+
+	rec := put("MYSQL_MONITOR_PASS", SecretSetRequest{Value: "s3cret"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result SecretSetResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+	if result.Name != "MYSQL_MONITOR_PASS" || result.Validated || strings.Contains(rec.Body.String(), "s3cret") {
+		t.Errorf("Unexpected result: %s", rec.Body.String())
+	}
+
+	// The collector gets the secret in its environment
+	env, err := s.collectorEnv(context.Background(), "")
+	if err != nil {
+		t.Fatalf("collectorEnv: %v", err)
+	}
+	found := false
+	for _, kv := range env {
+		if kv == "MYSQL_MONITOR_PASS=s3cret" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Secret missing from collector environment")
+	}
+
+	// Bad requests
+	if rec := put("mysql-pass", SecretSetRequest{Value: "x"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Invalid name: expected 400, got %d", rec.Code)
+	}
+	if rec := put("EMPTY", SecretSetRequest{}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Empty value: expected 400, got %d", rec.Code)
+	}
+	if rec := put("X", SecretSetRequest{Value: "x", Validate: &SecretValidation{Service: "oracle"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Unknown service: expected 400, got %d", rec.Code)
+	}
+
+	// A credential the service rejects is not stored
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	rec = put("ES_PASS", SecretSetRequest{
+		Value:    "wrong",
+		Validate: &SecretValidation{Service: "http", Endpoint: server.URL, Username: "elastic"},
+	})
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Rejected credential: expected 422, got %d", rec.Code)
+	}
+	for _, kv := range s.secrets.environ() {
+		if strings.HasPrefix(kv, "ES_PASS=") {
+			t.Error("Rejected credential was stored")
+		}
+	}
+}
+
+func TestSetSecret_Disabled(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{Logger: zaptest.NewLogger(t)})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	if _, err := s.SetSecret(context.Background(), "X", "y", nil); err == nil {
+		t.Error("Expected error without a work dir")
+	}
+}
+
+func TestCollectorEnv_SecretProviders(t *testing.T) {
+	dir := t.TempDir()
+	secretsDir := filepath.Join(dir, "secrets")
+	if err := os.Mkdir(secretsDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"MYSQL_MONITOR_PASS": "from-file\n", "REDIS_PASS": "redis"} {
+		if err := os.WriteFile(filepath.Join(secretsDir, name), []byte(value), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		WorkDir: filepath.Join(dir, "work"),
+		SecretProviders: []secrets.ProviderConfig{
+			{Type: secrets.ProviderFile, Dir: secretsDir},
+		},
+		Logger: zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+
+	// Stored secrets come before the providers
+	if _, err := s.SetSecret(context.Background(), "MYSQL_MONITOR_PASS", "from-store", nil); err != nil {
+		t.Fatalf("Failed to set secret: %v", err)
+	}
+
+	env, err := s.collectorEnv(context.Background(), "password: ${env:MYSQL_MONITOR_PASS}\nredis: ${REDIS_PASS}\n")
+	if err != nil {
+		t.Fatalf("collectorEnv: %v", err)
+	}
+	values := make(map[string]string)
+	for _, kv := range env {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			values[name] = value
+		}
+	}
+	if values["MYSQL_MONITOR_PASS"] != "from-store" || values["REDIS_PASS"] != "redis" {
+		t.Errorf("Unexpected secrets: MYSQL_MONITOR_PASS=%q REDIS_PASS=%q", values["MYSQL_MONITOR_PASS"], values["REDIS_PASS"])
+	}
+
+	// A reference no provider has fails the start
+	if _, err := s.collectorEnv(context.Background(), "password: ${MISSING_PASS}"); err == nil {
+		t.Error("Expected error for an unresolved secret")
+	}
+
+	// Invalid provider configs are rejected up front
+	_, err = NewUnifiedSupervisor(SupervisorConfig{
+		SecretProviders: []secrets.ProviderConfig{{Type: "keyring"}},
+		Logger:          zaptest.NewLogger(t),
+	})
+	if err == nil {
+		t.Error("Expected error for an unknown provider type")
+	}
+}
@@ -0,0 +1,83 @@
+package supervisor
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// supervisorLogStream is the stream of captured supervisor log lines
+const supervisorLogStream = "supervisor"
+
+// logStoreCore is a zap core recording entries in a log store, rendered in
+// zap's console format like the collector's output
+type logStoreCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	store   *collectorLogStore
+}
+
+// captureLogs returns logger also recording its entries in store, at the
+// levels logger is enabled for
+func captureLogs(logger *zap.Logger, store *collectorLogStore) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		encoderConfig := zap.NewProductionEncoderConfig()
+		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		return zapcore.NewTee(core, &logStoreCore{
+			LevelEnabler: core,
+			encoder:      zapcore.NewConsoleEncoder(encoderConfig),
+			store:        store,
+		})
+	}))
+}
+
+func (c *logStoreCore) With(fields []zapcore.Field) zapcore.Core {
+	encoder := c.encoder.Clone()
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+	return &logStoreCore{LevelEnabler: c.LevelEnabler, encoder: encoder, store: c.store}
+}
+
+func (c *logStoreCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *logStoreCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	c.store.append(CollectorLogLine{
+		Timestamp: entry.Time,
+		Stream:    supervisorLogStream,
+		Severity:  normalizeSeverity(entry.Level.String()),
+		Message:   strings.TrimSuffix(buf.String(), "\n"),
+	})
+	return nil
+}
+
+func (c *logStoreCore) Sync() error {
+	return nil
+}
+
+// handleSupervisorLogs serves GET /v1/logs/supervisor with the query
+// parameters of /v1/logs/collector; see serveLogs
+func (s *UnifiedSupervisor) handleSupervisorLogs(w http.ResponseWriter, r *http.Request) {
+	// Track API request
+	s.metrics.IncrementRequests()
+
+	if s.supervisorLogs == nil {
+		problem.Error(w, r, "supervisor log capture is disabled", http.StatusNotFound)
+		return
+	}
+	serveLogs(w, r, s.supervisorLogs)
+}
@@ -0,0 +1,73 @@
+package supervisor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCaptureLogs(t *testing.T) {
+	core, observed := observer.New(zapcore.InfoLevel)
+	store := newMemoryLogStore()
+	logger := captureLogs(zap.New(core), store)
+
+	logger.Debug("not enabled")
+	logger.Named("api").With(zap.String("addr", ":8080")).Info("Starting API server")
+	logger.Warn("Collector crashed", zap.Int("exit_code", 2))
+
+	if observed.Len() != 2 {
+		t.Errorf("Expected the wrapped core to still get 2 entries, got %d", observed.Len())
+	}
+
+	lines := store.tail(10, "")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 captured lines, got %d: %+v", len(lines), lines)
+	}
+	if lines[0].Stream != supervisorLogStream || lines[0].Severity != "info" {
+		t.Errorf("Unexpected first line: %+v", lines[0])
+	}
+	for _, want := range []string{"\tinfo\tapi\t", "Starting API server", `{"addr": ":8080"}`} {
+		if !strings.Contains(lines[0].Message, want) {
+			t.Errorf("Expected %q in %q", want, lines[0].Message)
+		}
+	}
+	if lines[1].Severity != "warn" || !strings.Contains(lines[1].Message, `{"exit_code": 2}`) {
+		t.Errorf("Unexpected second line: %+v", lines[1])
+	}
+}
+
+func TestHandleSupervisorLogs(t *testing.T) {
+	s := &UnifiedSupervisor{metrics: NewMetricsCollector()}
+
+	rec := httptest.NewRecorder()
+	s.handleSupervisorLogs(rec, httptest.NewRequest("GET", "/v1/logs/supervisor", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without log capture, got %d", rec.Code)
+	}
+
+	s.supervisorLogs = newMemoryLogStore()
+	logger := captureLogs(zap.New(observerCore()), s.supervisorLogs)
+	logger.Info("Reloading collector")
+	logger.Info("Config file changed")
+
+	rec = httptest.NewRecorder()
+	s.handleSupervisorLogs(rec, httptest.NewRequest("GET", "/v1/logs/supervisor?grep=Reload", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], "Reloading collector") {
+		t.Errorf("Unexpected body: %q", rec.Body.String())
+	}
+}
+
+// observerCore returns a core enabled at info level, discarding entries
+func observerCore() zapcore.Core {
+	core, _ := observer.New(zapcore.InfoLevel)
+	return core
+}
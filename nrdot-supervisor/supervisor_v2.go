@@ -3,18 +3,31 @@ package supervisor
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/newrelic/nrdot-host/nrdot-api-server/pkg/middleware"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
 	"github.com/newrelic/nrdot-host/nrdot-common/pkg/interfaces"
 	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/problem"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/tracecontext"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/tlsconfig"
 	configengine "github.com/newrelic/nrdot-host/nrdot-config-engine"
+	"github.com/newrelic/nrdot-host/nrdot-config-engine/pkg/hooks"
+	"github.com/newrelic/nrdot-host/nrdot-config-engine/pkg/secrets"
+	"github.com/newrelic/nrdot-host/nrdot-supervisor/pkg/restart"
 	telemetryclient "github.com/newrelic/nrdot-host/nrdot-telemetry-client"
 	"go.uber.org/zap"
 )
@@ -22,17 +35,27 @@ import (
 // UnifiedSupervisor combines supervisor, API server, and config engine
 type UnifiedSupervisor struct {
 	logger        *zap.Logger
-	configEngine  *configengine.EngineV2
+	configEngine  ConfigEngine
 	telemetry     telemetryclient.TelemetryClient
 	
 	// Collector management
-	collector     *CollectorProcess
+	collector     Collector
+	runner        CollectorRunner
 	reloadStrategy interfaces.SupervisorCommander
 	
 	// API Server
 	apiServer     *http.Server
 	apiHandlers   *Handlers
 	
+	// Whether SetupAuthenticatedAPIServer enabled authentication
+	apiAuthEnabled bool
+	
+	// API rate limiting middleware, nil when disabled
+	rateLimit     func(http.Handler) http.Handler
+	
+	// Proxies whose forwarding headers name the client being rate limited
+	trustedProxies []*net.IPNet
+	
 	// State
 	mu            sync.RWMutex
 	status        models.CollectorStatus
@@ -42,10 +65,93 @@ type UnifiedSupervisor struct {
 	// Metrics collection
 	metrics       *MetricsCollector
 	
+	// Collector upgrade checks, nil when disabled
+	updater       *collectorUpdater
+	
+	// Remote configuration polling, nil unless RemoteConfig is set
+	remoteConfig  *configengine.RemotePoller
+	
+	// Serializes collector binary updates
+	updateMu      sync.Mutex
+	
+	// Crash restart backoff and circuit breaker
+	crashLoop     *crashLoopBreaker
+	
+	// Scripts run before and after collector reloads
+	reloadHooks   *hooks.Manager
+	
+	// Blue-green port slot of the running collector (0 or 1)
+	portSlot      int
+	
+	// Captured collector stdout/stderr, nil without a WorkDir
+	collectorLogs *collectorLogStore
+	
+	// Recent supervisor log entries, kept in memory
+	supervisorLogs *collectorLogStore
+	
+	// Runtime log levels of the supervisor's components
+	logLevels     *logLevels
+	
+	// Encrypted service credentials passed to the collector, nil without a WorkDir
+	secrets       *secretStore
+	
+	// Resolves config variables for the collector, nil without SecretProviders
+	secretResolver *secrets.Resolver
+	
+	// Last-known-good config kept across restarts, nil without a WorkDir
+	configState   *configStateStore
+	
+	// Hash-chained log of mutating API calls, in memory without a WorkDir
+	audit         *auditLog
+	
+	// Diagnostic commands the API may run
+	execCommands  *execAllowlist
+	
+	// Collector cgroups, nil when resource limits are not enforced
+	cgroups         *collectorCgroups
+	resourceLimited bool
+	
+	// Event fan-out to subscribers and event stream clients
+	events        *eventBus
+	
+	// Collector health last reported by a health.changed event
+	lastHealth    models.HealthState
+	
+	// Pipeline status from the collector's internal telemetry
+	pipelines     *pipelineScraper
+	
+	// Startup phases reached: the initial config applied, and the running
+	// collector's pipelines seen active
+	configLoaded    bool
+	pipelinesActive bool
+	
+	// Collector health probes and registered checks
+	probes        *healthProber
+	
+	// systemd readiness and watchdog notifications, and the end of the
+	// last health monitor pass in unix nanoseconds
+	notifier       *sdNotifier
+	lastHealthPass atomic.Int64
+	
+	// Time source for status, events and timing decisions
+	clock         Clock
+	
+	// Tells a host shutdown from the supervisor being stopped, nil when
+	// shutdowns are not detected
+	hostShutdown  HostShutdownDetector
+	
+	// Serializes config file reloads; hash of the file content last applied
+	configFileMu   sync.Mutex
+	configFileHash string
+	
 	// Options
 	config        SupervisorConfig
 }
 
+// DefaultAPIListenAddr is where the API listens unless configured: only
+// local clients can connect
+const DefaultAPIListenAddr = "127.0.0.1:8080"
+
 // SupervisorConfig holds configuration for the unified supervisor
 type SupervisorConfig struct {
 	// Core settings
@@ -53,25 +159,103 @@ type SupervisorConfig struct {
 	ConfigPath      string
 	WorkDir         string
 	
+	// Reload ConfigPath when it changes, once it has been quiet for
+	// ConfigWatchDebounce (default 1s)
+	WatchConfig         bool
+	ConfigWatchDebounce time.Duration
+	
 	// API settings
 	APIEnabled      bool
-	APIListenAddr   string
+	APIListenAddr   string // defaults to DefaultAPIListenAddr
+	
+	// API listener TLS; a self-signed certificate is kept under
+	// WorkDir/tls unless SelfSignedDir is set
+	APITLS          tlsconfig.Config
 	
 	// Behavior settings
-	RestartDelay    time.Duration
-	MaxRestarts     int
+	RestartDelay    time.Duration // initial delay before restarting a crashed collector
+	MaxRestartDelay time.Duration // backoff cap, defaults to 5m
+	MaxRestarts     int           // consecutive crash restarts before giving up, 0 for unlimited
 	HealthCheckInterval time.Duration
 	
+	// Probes deciding collector health while it runs, run every
+	// HealthCheckInterval; nil means DefaultHealthProbes
+	HealthProbes []HealthProbeConfig
+	
+	// Blue-green reload: port shift for the alternate collector and how
+	// long it may take to report healthy (defaults 1000 and 30s)
+	BlueGreenPortOffset int
+	ReloadHealthTimeout time.Duration
+	
+	// Collector output captured under WorkDir/logs: rotation size and
+	// number of rotated files kept (defaults 10MB and 5)
+	CollectorLogMaxSize  int64
+	CollectorLogMaxFiles int
+	
+	// Collector memory and CPU limits (Linux cgroup v2)
+	Resources ResourceLimits
+	
+	// Diagnostic commands the authenticated API may run on the host, see
+	// LoadExecCommands
+	ExecCommands []ExecCommand
+	
+	// Scripts run before and after each collector reload, see
+	// hooks.LoadScriptConfig; empty runs none
+	ReloadHooks hooks.ScriptConfig
+	
+	// Providers resolving the variables generated configs reference, after
+	// the secrets stored through the API; see secrets.LoadProviderConfigs.
+	// Empty leaves unresolved variables to the collector.
+	SecretProviders []secrets.ProviderConfig
+	
 	// Features
 	EnableTelemetry bool
 	EnableDebug     bool
 	
-	// Rate limiting
+	// Rate limiting, per authenticated user or API key and otherwise per IP
 	RateLimitEnabled   bool
 	RateLimitRate      int           // requests per interval
 	RateLimitInterval  time.Duration // interval duration
 	RateLimitBurst     int           // burst size
 	
+	// Mutating requests get their own buckets when set, leaving the rate
+	// and burst above to reads
+	RateLimitWriteRate  int
+	RateLimitWriteBurst int
+	
+	// IPs or CIDRs of the proxies whose X-Real-IP and X-Forwarded-For
+	// headers name the client being limited; clients are otherwise told
+	// apart by their connection address
+	RateLimitTrustedProxies []string
+	
+	// Collector upgrade channel
+	Updater         UpdaterConfig
+	
+	// How long an updated collector must stay up before the update is kept
+	// (default 10s)
+	UpdateVerifyPeriod time.Duration
+	
+	// How long the collector must stay healthy on a config before it becomes
+	// the last-known-good one the next start falls back to (default 5m)
+	LastKnownGoodAfter time.Duration
+	
+	// Signed configuration bundles polled from an HTTPS endpoint and
+	// applied like config file changes; nil disables remote configuration
+	RemoteConfig *configengine.RemoteConfig
+	
+	// How long the collector may take to flush its exporters when the
+	// supervisor stops because the host shuts down or reboots (default
+	// DefaultHostShutdownFlushTimeout)
+	HostShutdownFlushTimeout time.Duration
+	
+	// Embedding: replacements for the config engine, the collector
+	// processes, the clock and host shutdown detection (systemd jobs on
+	// hosts booted with systemd); nil uses the defaults nrdot-host runs with
+	ConfigEngine    ConfigEngine
+	CollectorRunner CollectorRunner
+	Clock           Clock
+	HostShutdown    HostShutdownDetector
+	
 	Logger          *zap.Logger
 }
 
@@ -80,17 +264,70 @@ func NewUnifiedSupervisor(config SupervisorConfig) (*UnifiedSupervisor, error) {
 	if config.Logger == nil {
 		config.Logger = zap.NewNop()
 	}
+	if config.APIListenAddr == "" {
+		config.APIListenAddr = DefaultAPIListenAddr
+	}
 	
-	// Create config engine
-	engineConfig := configengine.ConfigV2{
-		Logger:      config.Logger.Named("config-engine"),
-		MaxVersions: 20,
-		EnableBackup: true,
+	// Keep recent supervisor log entries for the logs API
+	supervisorLogs := newMemoryLogStore()
+	config.Logger = captureLogs(config.Logger, supervisorLogs)
+	
+	// Components log at the supervisor's level until the API changes theirs
+	logLevels := newLogLevels(config.Logger.Core())
+	
+	// Open the secrets store under the work dir
+	var err error
+	var store *secretStore
+	if config.WorkDir != "" {
+		store, err = newSecretStore(config.WorkDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open secrets store: %w", err)
+		}
 	}
 	
-	engine, err := configengine.NewEngineV2(engineConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create config engine: %w", err)
+	// Resolve config variables through the store, then the configured
+	// providers
+	var resolver *secrets.Resolver
+	if len(config.SecretProviders) > 0 {
+		resolver, err = newSecretResolver(store, config.SecretProviders)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret providers: %w", err)
+		}
+	}
+	
+	// Create config engine unless one is embedded
+	engine := config.ConfigEngine
+	var ownEngine *configengine.EngineV2
+	if engine == nil {
+		engineConfig := configengine.ConfigV2{
+			Logger:      logLevels.logger(config.Logger, "config-engine", "config-engine"),
+			MaxVersions: 20,
+			EnableBackup: true,
+			Secrets:     resolver,
+			// Version history and rollback survive restarts, "" without
+			// a WorkDir
+			HistoryDir:  config.WorkDir,
+		}
+		
+		ownEngine, err = configengine.NewEngineV2(engineConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create config engine: %w", err)
+		}
+		engine = ownEngine
+	}
+	
+	runner := config.CollectorRunner
+	if runner == nil {
+		runner = processRunner{}
+	}
+	clk := config.Clock
+	if clk == nil {
+		clk = clock.Real()
+	}
+	now := clk.Now()
+	hostShutdown := config.HostShutdown
+	if hostShutdown == nil {
+		hostShutdown = defaultHostShutdownDetector()
 	}
 	
 	// Create telemetry client if enabled
@@ -102,31 +339,144 @@ func NewUnifiedSupervisor(config SupervisorConfig) (*UnifiedSupervisor, error) {
 	
 	s := &UnifiedSupervisor{
 		logger:       config.Logger,
+		supervisorLogs: supervisorLogs,
+		logLevels:      logLevels,
 		configEngine: engine,
+		runner:       runner,
+		clock:        clk,
 		telemetry:    telemetry,
 		metrics:      NewMetricsCollector(),
 		config:       config,
-		startTime:    time.Now(),
+		startTime:    now,
 		status: models.CollectorStatus{
 			State:         models.CollectorStateStopped,
+			StateSince:    now,
 			Version:       "unknown",
 			ConfigVersion: 0,
-			StartTime:     time.Now(),
+			StartTime:     now,
 		},
 		health: models.HealthStatus{
 			State:     models.HealthStateUnknown,
-			Timestamp: time.Now(),
+			Timestamp: now,
 		},
+		lastHealth: models.HealthStateUnknown,
+		notifier:   newSDNotifier(),
+		secrets:    store,
+		secretResolver: resolver,
+		hostShutdown:   hostShutdown,
 	}
 	
 	// Set initial metrics state
 	s.metrics.SetAPIEnabled(config.APIEnabled)
 	
+	// Set up event delivery
+	s.events = newEventBus(s.metrics)
+	
+	// Set up pipeline status scraping
+	s.pipelines = newPipelineScraper(logLevels.logger(config.Logger, "pipelines", "pipelines"))
+	s.pipelines.now = clk.Now
+	
+	// Set up collector health probes
+	s.probes, err = newHealthProber(config.HealthProbes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid health probes: %w", err)
+	}
+	s.probes.now = clk.Now
+	
 	// Set up reload strategy
 	s.reloadStrategy = &BlueGreenReloadStrategy{supervisor: s}
 	
+	// Set up the scripts run around reloads
+	s.reloadHooks = hooks.NewManager()
+	if err := s.reloadHooks.SetScripts(config.ReloadHooks); err != nil {
+		return nil, fmt.Errorf("invalid reload hooks: %w", err)
+	}
+	
+	// Set up crash-loop protection
+	s.crashLoop = newCrashLoopBreaker(config.RestartDelay, config.MaxRestartDelay, config.MaxRestarts)
+	s.crashLoop.now = clk.Now
+	
+	// Capture collector output under the work dir
+	if config.WorkDir != "" {
+		logDir := filepath.Join(config.WorkDir, "logs")
+		s.collectorLogs, err = newCollectorLogStore(logDir, config.CollectorLogMaxSize, config.CollectorLogMaxFiles)
+		if err != nil {
+			// Not fatal, output goes to the supervisor log instead
+			config.Logger.Warn("Failed to set up collector log capture",
+				zap.String("dir", logDir),
+				zap.Error(err))
+		}
+	}
+	
+	// Open the last-known-good config state under the work dir
+	if config.WorkDir != "" {
+		s.configState, err = newConfigStateStore(config.WorkDir)
+		if err != nil {
+			// Not fatal, the config file is used as is
+			config.Logger.Warn("Failed to read last-known-good config", zap.Error(err))
+		}
+	}
+	
+	// Open the API audit log under the work dir
+	s.audit, err = newAuditLog(config.WorkDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if err := s.audit.chainError(); err != nil {
+		// Kept as evidence; new entries continue the chain
+		config.Logger.Error("Audit log failed verification, it may have been tampered with",
+			zap.String("path", s.audit.path),
+			zap.Error(err))
+	}
+	
+	// Set up the diagnostic command allowlist
+	s.execCommands, err = newExecAllowlist(config.ExecCommands)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exec allowlist: %w", err)
+	}
+	
+	// Enforce collector resource limits
+	s.setupCollectorCgroups()
+	
+	// Run the collector version a previous update installed
+	if config.WorkDir != "" {
+		if path := installedCollectorPath(config.WorkDir); isFile(path) {
+			config.Logger.Info("Using updated collector binary", zap.String("path", path))
+			s.config.CollectorPath = path
+		}
+	}
+	
+	// The engine checks generated configs with the collector binary
+	if ownEngine != nil {
+		ownEngine.SetCollectorValidator(s)
+	}
+	
+	// Set up collector upgrade checks if enabled
+	if config.Updater.Enabled {
+		s.updater, err = newCollectorUpdater(config.Updater, s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create updater: %w", err)
+		}
+	}
+	
+	// Set up remote configuration polling if configured
+	if config.RemoteConfig != nil {
+		remoteConfig := *config.RemoteConfig
+		if remoteConfig.StatePath == "" && config.WorkDir != "" {
+			remoteConfig.StatePath = filepath.Join(config.WorkDir, remoteConfigStateFileName)
+		}
+		s.remoteConfig, err = configengine.NewRemotePoller(remoteConfig, s.applyRemoteConfig, logLevels.logger(config.Logger, "remote-config", "remote-config"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote config: %w", err)
+		}
+	}
+	
 	// Set up API server if enabled
 	if config.APIEnabled {
+		s.trustedProxies, err = middleware.ParseTrustedProxies(config.RateLimitTrustedProxies)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit: %w", err)
+		}
 		s.setupAPIServer()
 	}
 	
@@ -141,6 +491,12 @@ func (s *UnifiedSupervisor) Start(ctx context.Context) error {
 	
 	// Start API server if enabled
 	if s.config.APIEnabled {
+		if err := validateListenAddr(s.config.APIListenAddr); err != nil {
+			return fmt.Errorf("invalid API listen address: %w", err)
+		}
+		if err := s.configureAPITLS(); err != nil {
+			return fmt.Errorf("invalid API TLS configuration: %w", err)
+		}
 		go s.startAPIServer()
 	}
 	
@@ -149,19 +505,54 @@ func (s *UnifiedSupervisor) Start(ctx context.Context) error {
 		if err := s.loadConfiguration(ctx); err != nil {
 			return fmt.Errorf("failed to load initial configuration: %w", err)
 		}
+		
+		// Not fatal, SIGHUP and the API still reload
+		if s.config.WatchConfig {
+			if err := s.startConfigWatch(ctx); err != nil {
+				s.logger.Warn("Config file watch disabled", zap.Error(err))
+			}
+		}
 	}
+	s.setConfigLoaded()
 	
 	// Start the collector
 	if err := s.startCollector(ctx); err != nil {
 		return fmt.Errorf("failed to start collector: %w", err)
 	}
 	
+	s.reportHealthChange()
+	
 	// Start health monitoring
+	s.lastHealthPass.Store(time.Now().UnixNano())
 	go s.healthMonitorLoop(ctx)
 	
+	// Tell systemd the collector is up and keep its watchdog fed
+	s.sdNotify(sdNotifyReady, "STATUS=Collector running")
+	if s.notifier.watchdogEnabled() {
+		go s.watchdogLoop(ctx)
+	}
+	
 	// Start restart monitor
 	go s.restartMonitorLoop(ctx)
 	
+	// Track pipeline status from the collector's internal telemetry
+	go s.pipelineMonitorLoop(ctx)
+	
+	// Watch the collector cgroups for limit violations
+	if s.cgroups != nil {
+		go s.resourceMonitorLoop(ctx)
+	}
+	
+	// Start collector upgrade checks
+	if s.updater != nil {
+		go s.updater.run(ctx)
+	}
+	
+	// Start remote configuration polling
+	if s.remoteConfig != nil {
+		go s.remoteConfigLoop(ctx)
+	}
+	
 	s.logger.Info("Unified supervisor started successfully")
 	return nil
 }
@@ -169,9 +560,10 @@ func (s *UnifiedSupervisor) Start(ctx context.Context) error {
 // Stop gracefully stops all components
 func (s *UnifiedSupervisor) Stop(ctx context.Context) error {
 	s.logger.Info("Stopping unified supervisor")
+	s.sdNotify(sdNotifyStopping)
 	
-	// Stop collector
-	if err := s.StopCollector(ctx, 30*time.Second); err != nil {
+	// Stop collector, flushing for longer when the host is going down
+	if err := s.StopCollector(ctx, s.collectorStopTimeout(ctx)); err != nil {
 		s.logger.Error("Failed to stop collector", zap.Error(err))
 	}
 	
@@ -182,35 +574,59 @@ func (s *UnifiedSupervisor) Stop(ctx context.Context) error {
 		}
 	}
 	
+	// Close captured collector logs
+	if s.collectorLogs != nil {
+		s.collectorLogs.close()
+	}
+	
+	// End supervisor log followers; later entries are still kept
+	if s.supervisorLogs != nil {
+		s.supervisorLogs.close()
+	}
+	
+	// Close the audit log
+	s.audit.close()
+	
+	// End event subscriptions
+	s.events.close()
+	
 	// Telemetry client cleanup (no-op client doesn't need stopping)
 	
 	return nil
 }
 
+// newAPIRouter returns a router answering unknown paths and methods with
+// problem details, like the handlers
+func newAPIRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.NotFoundHandler = problem.Handler(http.StatusNotFound, "No such API endpoint")
+	router.MethodNotAllowedHandler = problem.Handler(http.StatusMethodNotAllowed, "Method not allowed")
+	return router
+}
+
 // setupAPIServer configures the embedded API server
 func (s *UnifiedSupervisor) setupAPIServer() {
 	// Create handlers
 	s.apiHandlers = &Handlers{
 		Supervisor: s,
-		Logger:     s.logger.Named("api"),
+		Logger:     s.componentLogger("api", "api"),
 	}
 	
 	// Set up routes
-	router := mux.NewRouter()
+	router := newAPIRouter()
+	
+	// Continue the caller's trace, so what a request triggers is traced
+	router.Use(tracecontext.Middleware)
 	
 	// Apply rate limiting if configured
 	if s.config.RateLimitEnabled {
-		rateLimiter := middleware.NewRateLimiter(
-			s.config.RateLimitRate,
-			s.config.RateLimitInterval,
-			s.config.RateLimitBurst,
-			s.logger.Named("ratelimit"),
-		)
-		
-		// Rate limit by IP address for supervisor API
-		router.Use(rateLimiter.RateLimitMiddleware(middleware.IPKeyFunc))
+		s.rateLimit = s.newRateLimit(func(*http.Request) (string, bool) { return "", false })
+		router.Use(s.rateLimit)
 	}
 	
+	// Audit mutating API calls
+	router.Use(s.auditMiddleware)
+	
 	// Health endpoints
 	router.HandleFunc("/health", s.apiHandlers.Health).Methods("GET")
 	router.HandleFunc("/ready", s.apiHandlers.Ready).Methods("GET")
@@ -222,13 +638,29 @@ func (s *UnifiedSupervisor) setupAPIServer() {
 	v1 := router.PathPrefix("/v1").Subrouter()
 	v1.HandleFunc("/status", s.apiHandlers.Status).Methods("GET")
 	v1.HandleFunc("/config", s.apiHandlers.GetConfig).Methods("GET")
+	v1.HandleFunc("/config/history", s.apiHandlers.GetVersionHistory).Methods("GET")
 	v1.HandleFunc("/config", s.apiHandlers.UpdateConfig).Methods("POST", "PUT")
 	v1.HandleFunc("/config/validate", s.apiHandlers.ValidateConfig).Methods("POST")
+	v1.HandleFunc("/config/rollback", s.handleConfigRollback).Methods("POST")
 	v1.HandleFunc("/metrics", s.apiHandlers.GetMetrics).Methods("GET")
+	v1.HandleFunc("/logs/collector", s.handleCollectorLogs).Methods("GET")
+	v1.HandleFunc("/logs/supervisor", s.handleSupervisorLogs).Methods("GET")
+	v1.HandleFunc("/events/stream", s.handleEventStream).Methods("GET")
+	v1.HandleFunc("/audit", s.handleAudit).Methods("GET")
+	v1.HandleFunc("/logging", s.handleGetLogLevels).Methods("GET")
+	v1.HandleFunc("/logging", s.handleSetLogLevel).Methods("PUT")
+	
+	// Secrets can only be written through the authenticated API
+	v1.HandleFunc("/secrets/{name}", s.handleSecretsAuthRequired).Methods("PUT")
+	v1.HandleFunc("/control/exec", s.handleExecAuthRequired).Methods("GET")
+	v1.HandleFunc("/control/exec/{name}", s.handleExecAuthRequired).Methods("POST")
 	
 	// Control endpoints (new)
 	v1.HandleFunc("/control/reload", s.handleReload).Methods("POST")
 	v1.HandleFunc("/control/restart", s.handleRestart).Methods("POST")
+	v1.HandleFunc("/control/breaker/reset", s.handleBreakerReset).Methods("POST")
+	v1.HandleFunc("/control/update", s.handleUpdate).Methods("POST")
+	v1.HandleFunc("/control/cardinality-report", s.handleCardinalityReport).Methods("POST")
 	
 	s.apiServer = &http.Server{
 		Addr:         s.config.APIListenAddr,
@@ -240,8 +672,17 @@ func (s *UnifiedSupervisor) setupAPIServer() {
 
 // startAPIServer starts the embedded API server
 func (s *UnifiedSupervisor) startAPIServer() {
-	s.logger.Info("Starting API server", zap.String("addr", s.config.APIListenAddr))
-	if err := s.apiServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	s.logger.Info("Starting API server",
+		zap.String("addr", s.config.APIListenAddr),
+		zap.Bool("tls", s.apiServer.TLSConfig != nil))
+	
+	var err error
+	if s.apiServer.TLSConfig != nil {
+		err = s.apiServer.ListenAndServeTLS("", "")
+	} else {
+		err = s.apiServer.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		s.logger.Error("API server error", zap.Error(err))
 	}
 }
@@ -252,7 +693,8 @@ func (s *UnifiedSupervisor) GetStatus(ctx context.Context) (*models.CollectorSta
 	defer s.mu.RUnlock()
 	
 	status := s.status
-	status.Uptime = time.Since(s.startTime)
+	status.Uptime = s.now().Sub(s.startTime)
+	status.Phase = s.phaseLocked()
 	
 	// Get real-time metrics if collector is running
 	if s.collector != nil && s.collector.IsRunning() {
@@ -273,7 +715,7 @@ func (s *UnifiedSupervisor) GetHealth(ctx context.Context) (*models.HealthStatus
 	defer s.mu.RUnlock()
 	
 	health := s.health
-	health.Timestamp = time.Now()
+	health.Timestamp = s.now()
 	
 	// Add component health
 	health.Components = []models.ComponentHealth{
@@ -281,48 +723,95 @@ func (s *UnifiedSupervisor) GetHealth(ctx context.Context) (*models.HealthStatus
 			Name:      "supervisor",
 			Type:      "core",
 			State:     models.HealthStateHealthy,
-			LastCheck: time.Now(),
+			LastCheck: s.now(),
 		},
 		{
 			Name:      "config-engine",
 			Type:      "core", 
 			State:     models.HealthStateHealthy,
-			LastCheck: time.Now(),
-		},
-		{
-			Name:      "collector",
-			Type:      "core",
-			State:     s.getCollectorHealthState(),
-			LastCheck: time.Now(),
+			LastCheck: s.now(),
 		},
+		s.collectorComponentHealth(),
 	}
+	health.Checks = s.probes.lastResults()
 	
 	// Overall health based on components
-	if s.getCollectorHealthState() == models.HealthStateHealthy {
-		health.State = models.HealthStateHealthy
-		health.ReadinessProbe = true
+	if s.collector != nil && s.collector.IsRunning() {
+		health.State = s.getCollectorHealthState()
+		health.ReadinessProbe = health.State != models.HealthStateUnhealthy &&
+			s.phaseLocked() == models.PhasePipelinesActive
 		health.LivenessProbe = true
 	} else {
 		health.State = models.HealthStateDegraded
 		health.ReadinessProbe = false
 		health.LivenessProbe = true
 	}
+	if s.crashLoop.isTripped() {
+		health.Summary = "Collector is crash looping; restarts suspended until the breaker is reset"
+	}
 	
 	return &health, nil
 }
 
 // Implement SupervisorCommander interface
 func (s *UnifiedSupervisor) ReloadCollector(ctx context.Context, strategy models.ReloadStrategy) (*models.ReloadResult, error) {
-	s.logger.Info("Reloading collector", zap.String("strategy", string(strategy)))
+	// The reload is a span of the trace of the request that asked for it,
+	// which its result, events, logs and collector processes carry
+	ctx, span := tracecontext.StartSpan(ctx)
+	result, err := s.reloadCollector(ctx, strategy)
+	if result != nil {
+		result.TraceID = span.TraceIDString()
+		result.SpanID = span.SpanIDString()
+	}
+	return result, err
+}
+
+// reloadCollector reloads the collector within the reload span
+func (s *UnifiedSupervisor) reloadCollector(ctx context.Context, strategy models.ReloadStrategy) (*models.ReloadResult, error) {
+	s.logger.Info("Reloading collector", append(tracecontext.Fields(ctx),
+		zap.String("strategy", string(strategy)))...)
 	
 	// Track reload timing
 	_ = time.Now()
 	_ = s.status.ConfigVersion
 	
+	// Reload scripts coordinate with the host, e.g. draining it from a
+	// load balancer first
+	hookEvent := s.reloadEvent(ctx)
+	if err := s.runPreReloadHook(ctx, hookEvent); err != nil {
+		return reloadAbortedResult(strategy, hookEvent, err), err
+	}
+	
+	// Health checks leave the collector alone while it is being replaced
+	s.mu.Lock()
+	previous := s.status.State
+	err := s.transition(models.CollectorStateReloading)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	
+	// systemd tracks the reload until the collector serves again
+	s.sdNotify(sdNotifyReloading, "STATUS=Reloading collector")
+	defer s.sdNotify(sdNotifyReady, "STATUS=Collector running")
+	
 	// Use the configured reload strategy
 	result, err := s.reloadStrategy.ReloadCollector(ctx, strategy)
+	
+	// A failed reload leaves the old collector, if any, as it was
+	s.mu.Lock()
+	if s.status.State == models.CollectorStateReloading {
+		if err == nil {
+			previous = models.CollectorStateRunning
+		}
+		s.transition(previous)
+	}
+	s.mu.Unlock()
+	
+	s.runPostReloadHook(ctx, hookEvent, err)
+	
 	if err != nil {
-		s.recordEvent(models.EventTypeConfigRejected, models.EventSeverityError, 
+		s.recordEventContext(ctx, models.EventTypeConfigRejected, models.EventSeverityError,
 			"Configuration reload failed", err.Error())
 		return result, err
 	}
@@ -330,41 +819,62 @@ func (s *UnifiedSupervisor) ReloadCollector(ctx context.Context, strategy models
 	// Update status
 	s.mu.Lock()
 	s.status.ConfigVersion = result.NewVersion
-	s.status.LastConfigLoad = time.Now()
+	s.status.LastConfigLoad = s.now()
 	s.mu.Unlock()
 	
-	s.recordEvent(models.EventTypeReloaded, models.EventSeverityInfo,
+	s.recordEventContext(ctx, models.EventTypeReloaded, models.EventSeverityInfo,
 		"Configuration reloaded successfully", 
 		fmt.Sprintf("Version %d -> %d", result.OldVersion, result.NewVersion))
 	
 	return result, nil
 }
 
-// loadConfiguration loads configuration from file
+// loadConfiguration loads configuration from file, merged with its conf.d
+// overrides
 func (s *UnifiedSupervisor) loadConfiguration(ctx context.Context) error {
-	data, err := os.ReadFile(s.config.ConfigPath)
+	data, _, err := readConfigFile(s.config.ConfigPath)
 	if err != nil {
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
 	
+	// A config file that was being applied when the collector or host went
+	// down is skipped for the last-known-good config
+	config, source := s.bootConfig(data)
+	if source == "file" {
+		s.markConfigPending(configFileHash(data))
+	}
+	
 	update := &models.ConfigUpdate{
-		Config: data,
+		Config: config,
 		Format: "yaml",
-		Source: "file",
+		Source: source,
 		Author: "supervisor",
 	}
 	
 	result, err := s.configEngine.ApplyConfig(ctx, update)
+	if err == nil && !result.Success {
+		err = fmt.Errorf("configuration is invalid")
+	}
+	if err != nil && source == configSourceLastKnownGood {
+		// The last-known-good config no longer applies, e.g. after an
+		// upgrade; the file is the only option left
+		s.logger.Warn("Last-known-good config rejected, using the config file", zap.Error(err))
+		config = data
+		s.markConfigPending(configFileHash(data))
+		update.Config, update.Source = data, "file"
+		result, err = s.configEngine.ApplyConfig(ctx, update)
+		if err == nil && !result.Success {
+			err = fmt.Errorf("configuration is invalid")
+		}
+	}
 	if err != nil {
 		return err
 	}
 	
-	if !result.Success {
-		return fmt.Errorf("configuration is invalid")
-	}
-	
+	// With the last-known-good config the file differs, so a reload tries it
 	s.mu.Lock()
 	s.status.ConfigVersion = result.Version
+	s.configFileHash = configFileHash(config)
 	s.mu.Unlock()
 	
 	return nil
@@ -374,45 +884,79 @@ func (s *UnifiedSupervisor) loadConfiguration(ctx context.Context) error {
 func (s *UnifiedSupervisor) startCollector(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	
+	return s.startCollectorLocked(ctx)
+}
+
+// startCollectorLocked starts the collector process. The caller must hold
+// s.mu.
+func (s *UnifiedSupervisor) startCollectorLocked(ctx context.Context) error {
 	if s.collector != nil && s.collector.IsRunning() {
 		return fmt.Errorf("collector already running")
 	}
+	s.noteExitedLocked()
+	
+	from := s.status.State
+	if err := s.transition(models.CollectorStateStarting); err != nil {
+		return err
+	}
+	
+	// A collector that never ran stays stopped; otherwise health checks
+	// keep retrying it
+	failed := models.CollectorStateDegraded
+	if from == models.CollectorStateStopped {
+		failed = models.CollectorStateStopped
+	}
 	
-	// Generate OTel config
-	generated, err := s.configEngine.ProcessUserConfig(ctx, nil)
+	// Generated OTel config
+	generated, err := s.configEngine.GetGeneratedConfig(ctx)
 	if err != nil {
+		s.transition(failed)
 		return fmt.Errorf("failed to generate config: %w", err)
 	}
 	
 	// Write config to file
 	configPath := fmt.Sprintf("%s/config.yaml", s.config.WorkDir)
 	if err := os.WriteFile(configPath, []byte(generated.OTelConfig), 0644); err != nil {
+		s.transition(failed)
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 	
-	// Create collector process
-	s.collector = &CollectorProcess{
-		binaryPath: s.config.CollectorPath,
-		configPath: configPath,
-		workDir:    s.config.WorkDir,
-		logger:     s.logger.Named("collector"),
+	// Resolve the secrets the config references
+	env, err := s.collectorEnv(ctx, generated.OTelConfig)
+	if err != nil {
+		s.transition(failed)
+		return fmt.Errorf("failed to resolve secrets: %w", err)
 	}
 	
+	// Create collector process
+	s.collector = s.collectorRunner().NewCollector(CollectorConfig{
+		BinaryPath:    s.config.CollectorPath,
+		ConfigPath:    configPath,
+		Env:           env,
+		WorkDir:       s.config.WorkDir,
+		OutputHandler: s.collectorOutputHandler(),
+		CgroupDir:     s.collectorCgroupDir(0),
+	}, s.componentLogger("collector-process", "collector"))
+	
 	// Start the collector
 	if err := s.collector.Start(ctx); err != nil {
+		s.transition(failed)
 		return fmt.Errorf("failed to start collector: %w", err)
 	}
 	
 	// Update status
-	s.status.State = models.CollectorStateRunning
-	s.status.StartTime = time.Now()
+	s.transition(models.CollectorStateRunning)
+	s.status.StartTime = s.now()
+	s.status.ConfigHash = generated.Hash
+	s.portSlot = 0
+	s.pipelinesActive = false
 	
 	// Update metrics
 	s.metrics.SetCollectorRunning(true)
+	s.metrics.SetConfigHash(generated.Hash)
 	
-	s.recordEvent(models.EventTypeStarted, models.EventSeverityInfo,
-		"Collector started", fmt.Sprintf("PID: %d", s.collector.cmd.Process.Pid))
+	s.recordEventContext(ctx, models.EventTypeStarted, models.EventSeverityInfo,
+		"Collector started", fmt.Sprintf("PID: %d", s.collector.Pid()))
 	
 	return nil
 }
@@ -427,15 +971,15 @@ func (s *UnifiedSupervisor) handleReload(w http.ResponseWriter, r *http.Request)
 	// Default to blue-green strategy
 	strategy := models.ReloadStrategyBlueGreen
 	
-	startTime := time.Now()
+	startTime := s.now()
 	result, err := s.ReloadCollector(ctx, strategy)
-	duration := time.Since(startTime)
+	duration := s.now().Sub(startTime)
 	
 	// Update metrics
 	s.metrics.SetReloadDuration(duration)
 	if err != nil {
 		s.metrics.IncrementFailedReloads()
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err.Error(), controlErrorStatus(err))
 		return
 	}
 	
@@ -453,7 +997,7 @@ func (s *UnifiedSupervisor) handleRestart(w http.ResponseWriter, r *http.Request
 	s.metrics.IncrementRequests()
 	
 	if err := s.RestartCollector(ctx, "API request"); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err.Error(), controlErrorStatus(err))
 		return
 	}
 	
@@ -463,21 +1007,111 @@ func (s *UnifiedSupervisor) handleRestart(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
+// handleUpdate serves POST /v1/control/update with a CollectorUpdate body
+func (s *UnifiedSupervisor) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	// Track API request
+	s.metrics.IncrementRequests()
+	
+	var update models.CollectorUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		problem.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	
+	result, err := s.UpdateCollector(r.Context(), &update)
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *UnifiedSupervisor) handleBreakerReset(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	
+	// Track API request
+	s.metrics.IncrementRequests()
+	
+	if err := s.ResetCrashLoop(ctx); err != nil {
+		problem.Error(w, r, err.Error(), controlErrorStatus(err))
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.CrashLoopStatus())
+}
+
 // Helper methods
 func (s *UnifiedSupervisor) getCollectorHealthState() models.HealthState {
 	if s.collector == nil || !s.collector.IsRunning() {
 		return models.HealthStateUnhealthy
 	}
 	
-	// Check collector health endpoint or metrics
-	// For now, just check if process is running
-	return models.HealthStateHealthy
+	state, _ := s.probes.status()
+	return state
+}
+
+// collectorComponentHealth reports the collector process and its crash-loop
+// breaker. Callers must hold s.mu.
+func (s *UnifiedSupervisor) collectorComponentHealth() models.ComponentHealth {
+	breaker := s.crashLoop.status()
+	health := models.ComponentHealth{
+		Name:      "collector",
+		Type:      "core",
+		State:     s.getCollectorHealthState(),
+		LastCheck: s.now(),
+		Details: map[string]interface{}{
+			"restart_count":       s.status.RestartCount,
+			"consecutive_crashes": breaker.ConsecutiveCrashes,
+			"breaker_tripped":     breaker.Tripped,
+		},
+	}
+	if breaker.Tripped {
+		health.Message = fmt.Sprintf("crash loop detected after %d consecutive crashes", breaker.ConsecutiveCrashes)
+	} else if _, message := s.probes.status(); message != "" && s.collector != nil && s.collector.IsRunning() {
+		health.Message = message
+	}
+	return health
+}
+
+// CrashLoopStatus returns the state of the collector crash-loop breaker
+func (s *UnifiedSupervisor) CrashLoopStatus() CrashLoopStatus {
+	return s.crashLoop.status()
+}
+
+// ResetCrashLoop closes the crash-loop breaker and starts the collector again
+// if it is down.
+func (s *UnifiedSupervisor) ResetCrashLoop(ctx context.Context) error {
+	wasTripped := s.crashLoop.isTripped()
+	s.crashLoop.reset()
+	
+	s.recordEvent(models.EventTypeHealthChanged, models.EventSeverityInfo,
+		"Crash-loop breaker reset", fmt.Sprintf("Breaker was tripped: %t", wasTripped))
+	
+	s.mu.RLock()
+	running := s.collector != nil && s.collector.IsRunning()
+	s.mu.RUnlock()
+	if running {
+		return nil
+	}
+	
+	return s.startCollector(ctx)
 }
 
 func (s *UnifiedSupervisor) recordEvent(eventType models.EventType, severity models.EventSeverity, summary, details string) {
-	_ = models.Event{
+	s.recordEventContext(context.Background(), eventType, severity, summary, details)
+}
+
+// recordEventContext records an event caused by the operation traced in
+// ctx, linking the event and its log line to the trace
+func (s *UnifiedSupervisor) recordEventContext(ctx context.Context, eventType models.EventType, severity models.EventSeverity, summary, details string) {
+	event := models.Event{
 		Type:      eventType,
-		Timestamp: time.Now(),
+		Timestamp: s.now(),
 		Component: "supervisor",
 		Severity:  severity,
 		Summary:   summary,
@@ -488,15 +1122,25 @@ func (s *UnifiedSupervisor) recordEvent(eventType models.EventType, severity mod
 			Version:   "2.0",
 		},
 	}
+	if span, ok := tracecontext.FromContext(ctx); ok {
+		event.TraceID = span.TraceIDString()
+		event.SpanID = span.SpanIDString()
+	}
 	
 	// Log the event
+	fields := append(tracecontext.Fields(ctx), zap.String("details", details))
 	switch severity {
 	case models.EventSeverityError, models.EventSeverityCritical:
-		s.logger.Error(summary, zap.String("details", details))
+		s.logger.Error(summary, fields...)
 	case models.EventSeverityWarning:
-		s.logger.Warn(summary, zap.String("details", details))
+		s.logger.Warn(summary, fields...)
 	default:
-		s.logger.Info(summary, zap.String("details", details))
+		s.logger.Info(summary, fields...)
+	}
+	
+	// Deliver to subscribers
+	if s.events != nil {
+		s.events.publish(event)
 	}
 	
 	// Send to telemetry if enabled
@@ -513,10 +1157,24 @@ func (s *UnifiedSupervisor) RestartCollector(ctx context.Context, reason string)
 	s.logger.Info("Restarting collector", zap.String("reason", reason))
 	
 	// Stop existing collector
-	if s.collector != nil && s.collector.IsRunning() {
-		if err := s.collector.Stop(ctx); err != nil {
+	s.mu.Lock()
+	collector := s.collector
+	running := collector != nil && collector.IsRunning()
+	if running {
+		if err := s.transition(models.CollectorStateStopping); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	s.mu.Unlock()
+	
+	if running {
+		if err := collector.Stop(ctx); err != nil {
 			s.logger.Warn("Failed to stop collector cleanly", zap.Error(err))
 		}
+		s.mu.Lock()
+		s.transition(models.CollectorStateStopped)
+		s.mu.Unlock()
 	}
 	
 	// Small delay before restart
@@ -531,23 +1189,42 @@ func (s *UnifiedSupervisor) StopCollector(ctx context.Context, timeout time.Dura
 	s.logger.Info("Stopping collector", zap.Duration("timeout", timeout))
 	
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	
-	if s.collector == nil || !s.collector.IsRunning() {
+	collector := s.collector
+	if collector == nil || !collector.IsRunning() {
+		// Keep health checks from restarting a crashed collector
+		s.noteExitedLocked()
+		if state := s.status.State; state == models.CollectorStateDegraded || state == models.CollectorStateFailed {
+			s.transition(models.CollectorStateStopped)
+		}
+		s.mu.Unlock()
 		return nil
 	}
+	if err := s.transition(models.CollectorStateStopping); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
 	
 	// Create timeout context
 	stopCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 	
 	// Stop the collector
-	if err := s.collector.Stop(stopCtx); err != nil {
+	err := collector.Stop(stopCtx)
+	
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		if collector.IsRunning() {
+			s.transition(models.CollectorStateRunning)
+		} else {
+			s.transition(models.CollectorStateStopped)
+		}
 		return fmt.Errorf("failed to stop collector: %w", err)
 	}
 	
 	// Update state
-	s.status.State = models.CollectorStateStopped
+	s.transition(models.CollectorStateStopped)
 	s.metrics.SetCollectorRunning(false)
 	
 	s.recordEvent(models.EventTypeStopped, models.EventSeverityInfo,
@@ -570,20 +1247,268 @@ func (s *UnifiedSupervisor) StartCollector(ctx context.Context, configPath strin
 	return s.startCollector(ctx)
 }
 
-// UpdateCollector implements SupervisorCommander interface
+// UpdateCollector implements SupervisorCommander interface. The new binary is
+// staged under WorkDir/collectors/<version>, verified (checksum, signature
+// when an update key is configured, a --version test run and a dry-run
+// validation of the current config), then swapped in by atomically repointing
+// the WorkDir/collectors/current link and restarting the collector. If the
+// new collector does not start or stay up for UpdateVerifyPeriod the previous
+// binary is restored. The result lists the outcome of every step.
 func (s *UnifiedSupervisor) UpdateCollector(ctx context.Context, update *models.CollectorUpdate) (*models.UpdateResult, error) {
-	// Not implemented yet - would handle binary updates
-	return nil, fmt.Errorf("collector updates not implemented")
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+	
+	startTime := s.now()
+	oldVersion := s.collectorVersion(ctx)
+	
+	result := &models.UpdateResult{
+		OldVersion: oldVersion,
+		NewVersion: update.Version,
+	}
+	fail := func(err error) (*models.UpdateResult, error) {
+		result.UpdateDuration = s.now().Sub(startTime)
+		result.Error = &models.ErrorInfo{
+			Code:      "UPDATE_FAILED",
+			Message:   err.Error(),
+			Category:  models.ErrorCategoryInternal,
+			Severity:  models.SeverityError,
+			Component: "supervisor",
+			Timestamp: s.now(),
+		}
+		s.recordEvent(models.EventTypeUpdated, models.EventSeverityError,
+			"Collector update failed", err.Error())
+		return result, err
+	}
+	step := func(name string, fn func() (string, error)) error {
+		begin := s.now()
+		message, err := fn()
+		if err != nil {
+			message = err.Error()
+		}
+		result.Steps = append(result.Steps, models.UpdateStep{
+			Name:     name,
+			Success:  err == nil,
+			Duration: s.now().Sub(begin),
+			Message:  message,
+		})
+		return err
+	}
+	
+	if s.config.WorkDir == "" {
+		return fail(fmt.Errorf("collector updates require a work dir"))
+	}
+	
+	var binaryPath string
+	err := step("download", func() (string, error) {
+		path, err := downloadCollector(ctx, s.config.WorkDir, update)
+		binaryPath = path
+		return path, err
+	})
+	if err != nil {
+		return fail(err)
+	}
+	
+	key, err := s.updateVerificationKey()
+	if err != nil {
+		return fail(err)
+	}
+	if key != nil {
+		err := step("verify_signature", func() (string, error) {
+			return "", verifyCollectorSignature(binaryPath, update.Signature, key)
+		})
+		if err != nil {
+			return fail(err)
+		}
+	}
+	
+	err = step("test_version", func() (string, error) {
+		version, err := s.collectorRunner().Version(ctx, binaryPath)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimPrefix(version, "v") != strings.TrimPrefix(update.Version, "v") {
+			return "", fmt.Errorf("binary reports version %s, expected %s", version, update.Version)
+		}
+		return version, nil
+	})
+	if err != nil {
+		return fail(err)
+	}
+	
+	err = step("validate_config", func() (string, error) {
+		configPath := s.activeConfigPath()
+		if _, err := os.Stat(configPath); err != nil {
+			return "skipped, no collector config yet", nil
+		}
+		return configPath, s.collectorRunner().ValidateConfig(ctx, binaryPath, configPath)
+	})
+	if err != nil {
+		return fail(err)
+	}
+	
+	s.mu.Lock()
+	oldPath := s.config.CollectorPath
+	s.mu.Unlock()
+	
+	var previousLink string
+	err = step("swap", func() (string, error) {
+		previous, err := activateCollector(s.config.WorkDir, update.Version)
+		if err != nil {
+			return "", err
+		}
+		previousLink = previous
+		s.mu.Lock()
+		s.config.CollectorPath = installedCollectorPath(s.config.WorkDir)
+		s.mu.Unlock()
+		return update.Version, nil
+	})
+	if err != nil {
+		return fail(err)
+	}
+	
+	restartTime := s.now()
+	err = step("restart", func() (string, error) {
+		return "", s.RestartCollector(ctx, "update to "+update.Version)
+	})
+	if err == nil {
+		err = step("verify_running", func() (string, error) {
+			return "", s.verifyUpdatedCollector(ctx)
+		})
+	}
+	if err != nil {
+		rbErr := step("rollback", func() (string, error) {
+			if err := restoreCollectorLink(s.config.WorkDir, previousLink); err != nil {
+				return "", err
+			}
+			s.mu.Lock()
+			s.config.CollectorPath = oldPath
+			s.mu.Unlock()
+			return oldPath, s.RestartCollector(ctx, "update rollback")
+		})
+		if rbErr != nil {
+			s.logger.Error("Failed to restore previous collector", zap.Error(rbErr))
+		}
+		result.RolledBack = rbErr == nil
+		return fail(fmt.Errorf("collector failed after update: %w", err))
+	}
+	
+	s.mu.Lock()
+	s.status.Version = update.Version
+	s.status.AvailableUpdate = nil
+	s.mu.Unlock()
+	
+	result.Success = true
+	result.Downtime = s.now().Sub(restartTime)
+	result.UpdateDuration = s.now().Sub(startTime)
+	
+	s.recordEvent(models.EventTypeUpdated, models.EventSeverityInfo,
+		"Collector updated", fmt.Sprintf("%s -> %s", oldVersion, update.Version))
+	
+	return result, nil
+}
+
+// updateVerificationKey returns the key collector binaries must be signed
+// with, nil when no update key is configured
+func (s *UnifiedSupervisor) updateVerificationKey() (ed25519.PublicKey, error) {
+	if s.config.Updater.PublicKey == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(s.config.Updater.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("update public key must be a base64-encoded ed25519 key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// activeConfigPath returns the config file of the running collector
+func (s *UnifiedSupervisor) activeConfigPath() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.collector != nil && s.collector.ConfigPath() != "" {
+		return s.collector.ConfigPath()
+	}
+	return filepath.Join(s.config.WorkDir, "config.yaml")
 }
 
-// GetPipelineStatus returns status for a specific pipeline
+// verifyUpdatedCollector checks that a freshly updated collector stays up
+// for the verify period
+func (s *UnifiedSupervisor) verifyUpdatedCollector(ctx context.Context) error {
+	period := s.config.UpdateVerifyPeriod
+	if period <= 0 {
+		period = defaultUpdateVerifyPeriod
+	}
+	
+	timer := s.timeSource().NewTimer(period)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+	}
+	
+	s.mu.RLock()
+	running := s.collector != nil && s.collector.IsRunning()
+	s.mu.RUnlock()
+	if !running {
+		return fmt.Errorf("collector exited within %s of starting", period)
+	}
+	return nil
+}
+
+// collectorVersion returns the running collector version, detecting it from
+// the binary on first use. Returns "" if it cannot be determined.
+func (s *UnifiedSupervisor) collectorVersion(ctx context.Context) string {
+	s.mu.RLock()
+	version := s.status.Version
+	binaryPath := s.config.CollectorPath
+	s.mu.RUnlock()
+	
+	if version != "" && version != "unknown" {
+		return version
+	}
+	
+	detected, err := s.collectorRunner().Version(ctx, binaryPath)
+	if err != nil {
+		s.logger.Debug("Failed to detect collector version", zap.Error(err))
+		return ""
+	}
+	
+	s.mu.Lock()
+	s.status.Version = detected
+	s.mu.Unlock()
+	return detected
+}
+
+// setAvailableUpdate records the pending collector update in the status
+func (s *UnifiedSupervisor) setAvailableUpdate(update *models.AvailableUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.AvailableUpdate = update
+}
+
+// GetPipelineStatus returns status for a specific pipeline, as of the last
+// scrape of the collector's internal telemetry
 func (s *UnifiedSupervisor) GetPipelineStatus(ctx context.Context, pipelineName string) (*models.PipelineStatus, error) {
-	// For now, return a simple status
-	return &models.PipelineStatus{
-		Name:  pipelineName,
-		Type:  "metrics",
-		State: "running",
-	}, nil
+	s.mu.RLock()
+	scraped := s.status.Pipelines != nil
+	s.mu.RUnlock()
+	if !scraped {
+		s.refreshPipelineStatus(ctx)
+	}
+	
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, pipeline := range s.status.Pipelines {
+		if pipeline.Name == pipelineName {
+			status := pipeline
+			status.ComponentsHealth = make(map[string]string, len(pipeline.ComponentsHealth))
+			for k, v := range pipeline.ComponentsHealth {
+				status.ComponentsHealth[k] = v
+			}
+			return &status, nil
+		}
+	}
+	return nil, fmt.Errorf("pipeline %q not found", pipelineName)
 }
 
 // GetComponentHealth returns health status for a specific component
@@ -592,25 +1517,42 @@ func (s *UnifiedSupervisor) GetComponentHealth(ctx context.Context, componentNam
 		Name:      componentName,
 		Type:      "service",
 		State:     models.HealthStateHealthy,
-		LastCheck: time.Now(),
+		LastCheck: s.now(),
 	}, nil
 }
 
 // ApplyConfig applies a new configuration
 func (s *UnifiedSupervisor) ApplyConfig(ctx context.Context, update *models.ConfigUpdate) (*models.ConfigResult, error) {
 	// Delegate to config engine
-	return s.configEngine.ApplyConfig(ctx, update)
-}
-
-// Subscribe allows components to receive status updates
-func (s *UnifiedSupervisor) Subscribe(ctx context.Context, subscriber interfaces.StatusSubscriber) error {
-	// Not implemented yet
-	return nil
+	result, err := s.configEngine.ApplyConfig(ctx, update)
+	if update.DryRun {
+		return result, err
+	}
+	
+	switch {
+	case err != nil:
+		s.recordEventContext(ctx, models.EventTypeConfigRejected, models.EventSeverityError,
+			"Configuration update failed", err.Error())
+	case !result.Success:
+		details := "Validation failed"
+		if result.Error != nil {
+			details = result.Error.Message
+		}
+		s.recordEventContext(ctx, models.EventTypeConfigRejected, models.EventSeverityWarning,
+			"Configuration update rejected", details)
+	default:
+		s.recordEventContext(ctx, models.EventTypeConfigChanged, models.EventSeverityInfo,
+			"Configuration updated", fmt.Sprintf("Version %d", result.Version))
+	}
+	
+	return result, err
 }
 
-// RegisterHealthCheck registers a health check function
+// RegisterHealthCheck registers a health check run alongside the health
+// probes; an error from it marks the collector Degraded. A nil check
+// removes the named one.
 func (s *UnifiedSupervisor) RegisterHealthCheck(name string, check interfaces.HealthCheck) {
-	// Not implemented yet
+	s.probes.register(name, check)
 }
 
 // GetConfigHistory returns the configuration history
@@ -623,14 +1565,15 @@ func (s *UnifiedSupervisor) GetConfigHistory(ctx context.Context, limit int) ([]
 func (s *UnifiedSupervisor) GetCurrentConfig(ctx context.Context) (*models.Config, error) {
 	// Return a simple config for now
 	return &models.Config{
-		Version: 1,
+		ConfigVersion: 2,
 	}, nil
 }
 
-// RollbackConfig rolls back to a previous configuration version
+// RollbackConfig rolls back to a previous configuration version and
+// reloads the collector with it
 func (s *UnifiedSupervisor) RollbackConfig(ctx context.Context, version int) error {
-	// Not implemented yet
-	return fmt.Errorf("rollback not implemented")
+	_, err := s.rollbackConfig(ctx, version, "supervisor")
+	return err
 }
 
 // ValidateConfig validates a configuration without applying it
@@ -652,42 +1595,165 @@ func (s *UnifiedSupervisor) ValidateConfig(ctx context.Context, config []byte) (
 
 // healthMonitorLoop monitors collector health
 func (s *UnifiedSupervisor) healthMonitorLoop(ctx context.Context) {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := s.timeSource().NewTicker(s.healthCheckInterval())
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			s.checkHealth(ctx)
+			s.runHealthProbes(ctx)
+			s.reportHealthChange()
+			s.checkLastKnownGood(ctx)
+			s.lastHealthPass.Store(time.Now().UnixNano())
 		}
 	}
 }
 
+// healthCheckInterval returns the health monitor interval, 30s by default
+func (s *UnifiedSupervisor) healthCheckInterval() time.Duration {
+	if s.config.HealthCheckInterval <= 0 {
+		return 30 * time.Second
+	}
+	return s.config.HealthCheckInterval
+}
+
 // restartMonitorLoop monitors for restart conditions
 func (s *UnifiedSupervisor) restartMonitorLoop(ctx context.Context) {
-	ticker := time.NewTicker(60 * time.Second)
+	ticker := s.timeSource().NewTicker(60 * time.Second)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			s.checkRestartConditions(ctx)
 		}
 	}
 }
 
-// checkHealth performs health checks
+// checkHealth restarts the collector if it has exited, backing off
+// exponentially between crashes and giving up once the crash-loop breaker trips
 func (s *UnifiedSupervisor) checkHealth(ctx context.Context) {
-	// Simple health check - is collector running?
-	if s.collector != nil && !s.collector.IsRunning() {
-		s.logger.Warn("Collector is not running, attempting restart")
-		if err := s.startCollector(ctx); err != nil {
-			s.logger.Error("Failed to restart collector", zap.Error(err))
+	s.mu.RLock()
+	collector := s.collector
+	state := s.status.State
+	uptime := s.now().Sub(s.status.StartTime)
+	s.mu.RUnlock()
+	
+	// Nothing to supervise, the collector was stopped on purpose, or it is
+	// being started, stopped or replaced
+	if collector == nil || (state != models.CollectorStateRunning && state != models.CollectorStateDegraded) {
+		return
+	}
+	
+	if collector.IsRunning() {
+		if s.crashLoop.recordRunning(uptime) {
+			s.logger.Info("Collector stable, crash history cleared", zap.Duration("uptime", uptime))
 		}
+		return
+	}
+	
+	// Already given up; wait for the breaker to be reset
+	if s.crashLoop.isTripped() {
+		return
+	}
+	
+	delay, ok := s.crashLoop.recordCrash(uptime)
+	breaker := s.crashLoop.status()
+	if !ok {
+		s.tripCrashLoop(breaker)
+		return
+	}
+	
+	s.mu.Lock()
+	if s.collector != collector || s.transition(models.CollectorStateDegraded) != nil {
+		// A reload or stop took over meanwhile
+		s.mu.Unlock()
+		return
+	}
+	s.status.RestartCount++
+	s.mu.Unlock()
+	s.metrics.SetCollectorRunning(false)
+	
+	s.recordEvent(models.EventTypeCrashed, models.EventSeverityError,
+		"Collector exited unexpectedly",
+		fmt.Sprintf("Uptime %s, crash %d, restarting in %s", uptime.Round(time.Second), breaker.ConsecutiveCrashes, delay))
+	
+	if err := restart.WaitForRestartOn(ctx, s.timeSource(), delay); err != nil {
+		return
+	}
+	
+	// The breaker may have been reset and the collector started meanwhile
+	if s.crashLoop.isTripped() {
+		return
+	}
+	
+	// Only restart if nothing else, like a reload or stop, has handled the
+	// collector during the backoff
+	s.mu.Lock()
+	if s.status.State != models.CollectorStateDegraded {
+		s.mu.Unlock()
+		return
+	}
+	err := s.startCollectorLocked(ctx)
+	s.mu.Unlock()
+	if err != nil {
+		s.logger.Error("Failed to restart collector", zap.Error(err))
+		return
+	}
+	s.metrics.IncrementCollectorRestarts()
+}
+
+// tripCrashLoop marks the collector failed once restarts are suspended
+func (s *UnifiedSupervisor) tripCrashLoop(breaker CrashLoopStatus) {
+	summary := "Collector crash loop detected, restarts suspended"
+	details := fmt.Sprintf("%d consecutive crashes; reset via POST /v1/control/breaker/reset", breaker.ConsecutiveCrashes)
+	
+	s.mu.Lock()
+	s.transition(models.CollectorStateFailed)
+	s.status.LastError = &models.ErrorInfo{
+		Code:      "CRASH_LOOP",
+		Message:   summary,
+		Details:   details,
+		Category:  models.ErrorCategoryInternal,
+		Severity:  models.SeverityCritical,
+		Component: "collector",
+		Timestamp: breaker.TrippedAt,
+	}
+	s.mu.Unlock()
+	s.metrics.SetCollectorRunning(false)
+	
+	s.recordEvent(models.EventTypeHealthDegraded, models.EventSeverityCritical, summary, details)
+}
+
+// reportHealthChange records a health event when the collector health
+// differs from the last reported state
+func (s *UnifiedSupervisor) reportHealthChange() {
+	s.mu.Lock()
+	state := s.getCollectorHealthState()
+	previous := s.lastHealth
+	s.lastHealth = state
+	s.mu.Unlock()
+	
+	if state == previous {
+		return
+	}
+	
+	details := fmt.Sprintf("%s -> %s", previous, state)
+	switch {
+	case state != models.HealthStateHealthy:
+		s.recordEvent(models.EventTypeHealthChanged, models.EventSeverityWarning,
+			"Collector health changed", details)
+	case previous == models.HealthStateUnknown:
+		s.recordEvent(models.EventTypeHealthChanged, models.EventSeverityInfo,
+			"Collector health changed", details)
+	default:
+		s.recordEvent(models.EventTypeHealthRecovered, models.EventSeverityInfo,
+			"Collector health recovered", details)
 	}
 }
 
@@ -701,7 +1767,7 @@ func (s *UnifiedSupervisor) checkRestartConditions(ctx context.Context) {
 func (s *UnifiedSupervisor) loadInitialConfig(ctx context.Context) (*models.Config, error) {
 	// For now, return a default config
 	return &models.Config{
-		Version: 1,
+		ConfigVersion: 2,
 	}, nil
 }
 
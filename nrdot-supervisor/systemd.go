@@ -0,0 +1,133 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// systemd service state notifications (sd_notify(3))
+const (
+	sdNotifyReady     = "READY=1"
+	sdNotifyReloading = "RELOADING=1"
+	sdNotifyStopping  = "STOPPING=1"
+	sdNotifyWatchdog  = "WATCHDOG=1"
+)
+
+// sdNotifier reports service state to systemd over $NOTIFY_SOCKET. It does
+// nothing when the supervisor is not run as a Type=notify unit.
+type sdNotifier struct {
+	socket string
+	// systemd watchdog timeout from $WATCHDOG_USEC, 0 when disabled
+	watchdog time.Duration
+}
+
+// newSDNotifier reads the notification socket and watchdog settings systemd
+// passes in the environment
+func newSDNotifier() *sdNotifier {
+	n := &sdNotifier{socket: os.Getenv("NOTIFY_SOCKET")}
+	if n.socket == "" {
+		return n
+	}
+
+	// The watchdog is meant for another process when WATCHDOG_PID is not ours
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return n
+	}
+	if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+		n.watchdog = time.Duration(usec) * time.Microsecond
+	}
+	return n
+}
+
+// enabled reports whether systemd listens for notifications
+func (n *sdNotifier) enabled() bool {
+	return n != nil && n.socket != ""
+}
+
+// watchdogEnabled reports whether systemd expects watchdog keepalives
+func (n *sdNotifier) watchdogEnabled() bool {
+	return n.enabled() && n.watchdog > 0
+}
+
+// notify sends state lines such as READY=1 to systemd
+func (n *sdNotifier) notify(state ...string) error {
+	if !n.enabled() {
+		return nil
+	}
+
+	// Abstract socket names are passed with a leading @
+	addr := &net.UnixAddr{Name: n.socket, Net: "unixgram"}
+	if strings.HasPrefix(addr.Name, "@") {
+		addr.Name = "\x00" + addr.Name[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to systemd notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(strings.Join(state, "\n"))); err != nil {
+		return fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	return nil
+}
+
+// sdNotify sends a state notification to systemd, logging failures
+func (s *UnifiedSupervisor) sdNotify(state ...string) {
+	if err := s.notifier.notify(state...); err != nil {
+		s.logger.Warn("systemd notification failed",
+			zap.Strings("state", state),
+			zap.Error(err))
+	}
+}
+
+// watchdogLoop sends systemd watchdog keepalives at half the watchdog
+// timeout while the health monitor keeps running. If its passes stall, for
+// instance on a deadlock, keepalives stop and systemd restarts the service.
+// A pass may legitimately wait out a crash restart backoff, so a stall is
+// two health check intervals plus the maximum restart delay.
+func (s *UnifiedSupervisor) watchdogLoop(ctx context.Context) {
+	timeout := s.notifier.watchdog
+	maxRestartDelay := s.config.MaxRestartDelay
+	if maxRestartDelay <= 0 {
+		maxRestartDelay = defaultMaxRestartDelay
+	}
+	stallAfter := max(2*s.healthCheckInterval()+maxRestartDelay, timeout)
+
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	stalled := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if healthMonitorStalled(&s.lastHealthPass, stallAfter) {
+				if !stalled {
+					s.logger.Error("Health monitor stalled, withholding systemd watchdog keepalives",
+						zap.Duration("stall_after", stallAfter))
+				}
+				stalled = true
+				continue
+			}
+			stalled = false
+			s.sdNotify(sdNotifyWatchdog)
+		}
+	}
+}
+
+// healthMonitorStalled reports whether the last health monitor pass, in
+// unix nanoseconds, is more than stallAfter ago
+func healthMonitorStalled(lastPass *atomic.Int64, stallAfter time.Duration) bool {
+	return time.Since(time.Unix(0, lastPass.Load())) > stallAfter
+}
@@ -0,0 +1,176 @@
+package supervisor
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+)
+
+// listenNotifySocket serves a systemd notify socket and sets NOTIFY_SOCKET
+// to it. Received notifications are sent on the returned channel.
+func listenNotifySocket(t *testing.T) <-chan string {
+	t.Helper()
+
+	// Unix socket paths are limited to about 100 bytes, too short for t.TempDir
+	dir, err := os.MkdirTemp("", "sd")
+	if err != nil {
+		t.Fatalf("Failed to create socket dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	messages := make(chan string, 100)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			messages <- string(buf[:n])
+		}
+	}()
+	return messages
+}
+
+func receiveNotification(t *testing.T, messages <-chan string) string {
+	t.Helper()
+	select {
+	case message := <-messages:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for systemd notification")
+		return ""
+	}
+}
+
+func TestNewSDNotifier(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	t.Setenv("WATCHDOG_USEC", "")
+	t.Setenv("WATCHDOG_PID", "")
+	if n := newSDNotifier(); n.enabled() || n.watchdogEnabled() {
+		t.Error("Expected notifier to be disabled without NOTIFY_SOCKET")
+	}
+	if err := newSDNotifier().notify(sdNotifyReady); err != nil {
+		t.Errorf("Expected disabled notifier to do nothing, got %v", err)
+	}
+
+	t.Setenv("NOTIFY_SOCKET", "/run/systemd/notify")
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	n := newSDNotifier()
+	if !n.enabled() || !n.watchdogEnabled() || n.watchdog != 30*time.Second {
+		t.Errorf("Unexpected notifier %+v", n)
+	}
+
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	if n := newSDNotifier(); !n.watchdogEnabled() {
+		t.Error("Expected watchdog for our pid")
+	}
+	t.Setenv("WATCHDOG_PID", "1")
+	if n := newSDNotifier(); !n.enabled() || n.watchdogEnabled() {
+		t.Errorf("Expected no watchdog for another pid, got %+v", n)
+	}
+}
+
+func TestSDNotifier_Notify(t *testing.T) {
+	messages := listenNotifySocket(t)
+
+	if err := newSDNotifier().notify(sdNotifyReady, "STATUS=Collector running"); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if message := receiveNotification(t, messages); message != "READY=1\nSTATUS=Collector running" {
+		t.Errorf("Unexpected notification %q", message)
+	}
+
+	n := &sdNotifier{socket: filepath.Join(t.TempDir(), "missing")}
+	if err := n.notify(sdNotifyReady); err == nil {
+		t.Error("Expected error for a missing socket")
+	}
+}
+
+func TestUnifiedSupervisor_ReloadNotifiesSystemd(t *testing.T) {
+	f := newBlueGreenFixture(t)
+	s := f.supervisor
+	messages := listenNotifySocket(t)
+	s.notifier = newSDNotifier()
+
+	if _, err := s.ReloadCollector(context.Background(), models.ReloadStrategyBlueGreen); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if message := receiveNotification(t, messages); !strings.HasPrefix(message, sdNotifyReloading) {
+		t.Errorf("Expected RELOADING first, got %q", message)
+	}
+	if message := receiveNotification(t, messages); !strings.HasPrefix(message, sdNotifyReady) {
+		t.Errorf("Expected READY after the reload, got %q", message)
+	}
+
+	// A failed reload leaves the old collector serving, so it is ready again
+	f.healthy = false
+	if _, err := s.ReloadCollector(context.Background(), models.ReloadStrategyBlueGreen); err == nil {
+		t.Fatal("Expected reload to fail")
+	}
+	receiveNotification(t, messages)
+	if message := receiveNotification(t, messages); !strings.HasPrefix(message, sdNotifyReady) {
+		t.Errorf("Expected READY after the failed reload, got %q", message)
+	}
+}
+
+func TestUnifiedSupervisor_WatchdogLoop(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		HealthCheckInterval: 10 * time.Millisecond,
+		MaxRestartDelay:     10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	messages := listenNotifySocket(t)
+	s.notifier = &sdNotifier{socket: os.Getenv("NOTIFY_SOCKET"), watchdog: 100 * time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.lastHealthPass.Store(time.Now().UnixNano())
+	go s.watchdogLoop(ctx)
+
+	if message := receiveNotification(t, messages); message != sdNotifyWatchdog {
+		t.Errorf("Expected watchdog keepalive, got %q", message)
+	}
+
+	// A stalled health monitor stops the keepalives
+	s.lastHealthPass.Store(time.Now().Add(-time.Hour).UnixNano())
+	time.Sleep(60 * time.Millisecond)
+	for len(messages) > 0 {
+		<-messages
+	}
+	select {
+	case message := <-messages:
+		t.Errorf("Expected no keepalive while stalled, got %q", message)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestHealthMonitorStalled(t *testing.T) {
+	var lastPass atomic.Int64
+	lastPass.Store(time.Now().UnixNano())
+	if healthMonitorStalled(&lastPass, time.Minute) {
+		t.Error("Expected a recent pass not to be stalled")
+	}
+	lastPass.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+	if !healthMonitorStalled(&lastPass, time.Minute) {
+		t.Error("Expected an old pass to be stalled")
+	}
+}
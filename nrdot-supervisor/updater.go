@@ -0,0 +1,528 @@
+package supervisor
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/release"
+	"go.uber.org/zap"
+)
+
+// UpdateChannel selects which collector releases a host follows
+type UpdateChannel string
+
+const (
+	UpdateChannelStable UpdateChannel = "stable"
+	UpdateChannelBeta   UpdateChannel = "beta"
+)
+
+// UpdateMode controls what happens when a newer release is found
+type UpdateMode string
+
+const (
+	// UpdateModeNotify only records an event and the status field
+	UpdateModeNotify UpdateMode = "notify"
+	// UpdateModeAuto also applies the update during the maintenance window
+	UpdateModeAuto UpdateMode = "auto"
+)
+
+const (
+	// defaultUpdateCheckInterval is used when no interval is configured
+	defaultUpdateCheckInterval = 6 * time.Hour
+	// defaultUpdateVerifyPeriod is how long an updated collector must stay up
+	defaultUpdateVerifyPeriod = 10 * time.Second
+)
+
+// UpdaterConfig configures collector upgrade checks
+type UpdaterConfig struct {
+	Enabled bool
+	Channel UpdateChannel
+
+	// ManifestURL is the base URL of the release manifests. The channel
+	// manifest is fetched from <ManifestURL>/<channel>.json and its detached
+	// ed25519 signature from <ManifestURL>/<channel>.json.sig
+	ManifestURL string
+
+	// PublicKey is the base64-encoded ed25519 key manifests are signed with
+	PublicKey string
+
+	CheckInterval     time.Duration
+	Mode              UpdateMode
+	MaintenanceWindow MaintenanceWindow
+}
+
+// ReleaseManifest describes the latest releases on a channel
+type ReleaseManifest = release.Manifest
+
+// MaintenanceWindow is a recurring local-time window in which updates may
+// be applied. A zero Duration means updates may be applied at any time.
+type MaintenanceWindow struct {
+	Days     []time.Weekday // empty means every day
+	Start    time.Duration  // offset from local midnight
+	Duration time.Duration
+}
+
+// Contains reports whether t falls inside the window
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	if w.Duration <= 0 {
+		return true
+	}
+
+	// The window may have opened the previous day and wrapped past midnight
+	for _, dayOffset := range []int{0, -1} {
+		day := t.AddDate(0, 0, dayOffset)
+		if !w.onDay(day.Weekday()) {
+			continue
+		}
+		midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, t.Location())
+		start := midnight.Add(w.Start)
+		if !t.Before(start) && t.Before(start.Add(w.Duration)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns t if it is inside the window, otherwise the next window start
+func (w MaintenanceWindow) Next(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+
+	for dayOffset := 0; dayOffset <= 7; dayOffset++ {
+		day := t.AddDate(0, 0, dayOffset)
+		if !w.onDay(day.Weekday()) {
+			continue
+		}
+		midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, t.Location())
+		if start := midnight.Add(w.Start); start.After(t) {
+			return start
+		}
+	}
+	return t
+}
+
+func (w MaintenanceWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseMaintenanceWindow parses a window such as "02:00-04:00" or
+// "sat,sun 01:00-05:00". An empty string means no window restriction.
+func ParseMaintenanceWindow(s string) (MaintenanceWindow, error) {
+	var w MaintenanceWindow
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return w, nil
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) > 2 {
+		return w, fmt.Errorf("invalid maintenance window %q", s)
+	}
+	if len(fields) == 2 {
+		for _, name := range strings.Split(fields[0], ",") {
+			day, ok := weekdays[strings.ToLower(name)]
+			if !ok {
+				return w, fmt.Errorf("invalid weekday %q in maintenance window", name)
+			}
+			w.Days = append(w.Days, day)
+		}
+	}
+
+	bounds := strings.Split(fields[len(fields)-1], "-")
+	if len(bounds) != 2 {
+		return w, fmt.Errorf("invalid maintenance window %q: expected HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(bounds[0])
+	if err != nil {
+		return w, err
+	}
+	end, err := parseClock(bounds[1])
+	if err != nil {
+		return w, err
+	}
+
+	w.Start = start
+	w.Duration = end - start
+	if w.Duration <= 0 {
+		// Window wraps past midnight
+		w.Duration += 24 * time.Hour
+	}
+	return w, nil
+}
+
+// parseClock parses HH:MM into an offset from midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q in maintenance window: expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// collectorUpdater periodically checks the release channel for newer
+// collector versions
+type collectorUpdater struct {
+	config     UpdaterConfig
+	publicKey  ed25519.PublicKey
+	client     *http.Client
+	supervisor *UnifiedSupervisor
+	logger     *zap.Logger
+
+	// now and apply are replaceable in tests
+	now   func() time.Time
+	apply func(ctx context.Context, update *models.CollectorUpdate) (*models.UpdateResult, error)
+
+	mu       sync.Mutex
+	notified string // last version an event was recorded for
+}
+
+// newCollectorUpdater validates the config and creates an updater
+func newCollectorUpdater(config UpdaterConfig, s *UnifiedSupervisor) (*collectorUpdater, error) {
+	switch config.Channel {
+	case UpdateChannelStable, UpdateChannelBeta:
+	case "":
+		config.Channel = UpdateChannelStable
+	default:
+		return nil, fmt.Errorf("invalid update channel: %s", config.Channel)
+	}
+
+	switch config.Mode {
+	case UpdateModeNotify, UpdateModeAuto:
+	case "":
+		config.Mode = UpdateModeNotify
+	default:
+		return nil, fmt.Errorf("invalid update mode: %s", config.Mode)
+	}
+
+	if config.ManifestURL == "" {
+		return nil, fmt.Errorf("update manifest URL is required")
+	}
+
+	key, err := release.ParsePublicKey(config.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = defaultUpdateCheckInterval
+	}
+
+	u := &collectorUpdater{
+		config:     config,
+		publicKey:  key,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		supervisor: s,
+		logger:     s.componentLogger("updater", "updater"),
+		now:        time.Now,
+	}
+	u.apply = s.UpdateCollector
+	return u, nil
+}
+
+// run checks for updates until ctx is cancelled
+func (u *collectorUpdater) run(ctx context.Context) {
+	u.logger.Info("Starting collector update checks",
+		zap.String("channel", string(u.config.Channel)),
+		zap.String("mode", string(u.config.Mode)),
+		zap.Duration("interval", u.config.CheckInterval))
+
+	if err := u.check(ctx); err != nil {
+		u.logger.Warn("Collector update check failed", zap.Error(err))
+	}
+
+	ticker := time.NewTicker(u.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := u.check(ctx); err != nil {
+				u.logger.Warn("Collector update check failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// check fetches the channel manifest, records newer releases and applies
+// them when auto-updating inside the maintenance window
+func (u *collectorUpdater) check(ctx context.Context) error {
+	manifest, err := u.fetchManifest(ctx)
+	if err != nil {
+		return err
+	}
+
+	current := u.supervisor.collectorVersion(ctx)
+	if current == "" {
+		return fmt.Errorf("cannot determine current collector version")
+	}
+
+	if release.CompareVersions(manifest.Version, current) <= 0 {
+		u.supervisor.setAvailableUpdate(nil)
+		return nil
+	}
+
+	now := u.now()
+	available := &models.AvailableUpdate{
+		Version:      manifest.Version,
+		Channel:      string(u.config.Channel),
+		ReleaseNotes: manifest.ReleaseNotes,
+		ChangelogURL: manifest.ChangelogURL,
+		Mandatory:    manifest.Mandatory,
+		DetectedAt:   now,
+	}
+	if u.config.Mode == UpdateModeAuto {
+		next := u.config.MaintenanceWindow.Next(now)
+		available.ScheduledFor = &next
+	}
+
+	u.mu.Lock()
+	notify := u.notified != manifest.Version
+	u.notified = manifest.Version
+	u.mu.Unlock()
+
+	u.supervisor.setAvailableUpdate(available)
+	if notify {
+		u.supervisor.recordEvent(models.EventTypeUpdateAvailable, models.EventSeverityInfo,
+			"Collector update available",
+			fmt.Sprintf("%s -> %s on %s channel", current, manifest.Version, u.config.Channel))
+	}
+
+	if u.config.Mode != UpdateModeAuto || !u.config.MaintenanceWindow.Contains(now) {
+		return nil
+	}
+
+	result, err := u.apply(ctx, &models.CollectorUpdate{
+		Version:      manifest.Version,
+		DownloadURL:  manifest.DownloadURL,
+		Checksum:     manifest.Checksum,
+		Signature:    manifest.Signature,
+		ReleaseNotes: manifest.ReleaseNotes,
+		Mandatory:    manifest.Mandatory,
+		Metadata:     map[string]string{"channel": string(u.config.Channel)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply collector update %s: %w", manifest.Version, err)
+	}
+
+	u.logger.Info("Collector updated",
+		zap.String("old_version", result.OldVersion),
+		zap.String("new_version", result.NewVersion),
+		zap.Duration("downtime", result.Downtime))
+	return nil
+}
+
+// fetchManifest downloads the channel manifest and verifies its signature
+func (u *collectorUpdater) fetchManifest(ctx context.Context) (*ReleaseManifest, error) {
+	return release.FetchManifest(ctx, u.client, u.config.ManifestURL, string(u.config.Channel), u.publicKey)
+}
+
+// downloadCollector downloads a collector binary into
+// <workDir>/collectors/<version>/ and verifies its sha256 checksum. A binary
+// already present with a matching checksum is reused.
+func downloadCollector(ctx context.Context, workDir string, update *models.CollectorUpdate) (string, error) {
+	if !release.ValidVersion(update.Version) {
+		return "", fmt.Errorf("invalid collector version %q", update.Version)
+	}
+	if update.DownloadURL == "" {
+		return "", fmt.Errorf("download URL is required")
+	}
+	want := strings.ToLower(strings.TrimPrefix(update.Checksum, "sha256:"))
+	if len(want) != sha256.Size*2 {
+		return "", fmt.Errorf("checksum must be a hex sha256 digest")
+	}
+
+	dir := filepath.Join(workDir, "collectors", update.Version)
+	binaryPath := filepath.Join(dir, "otelcol")
+	if sum, err := fileChecksum(binaryPath); err == nil && sum == want {
+		return binaryPath, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("creating collector directory: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, update.DownloadURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("creating download request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("downloading collector: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("downloading collector: unexpected status %s", resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(dir, "otelcol-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), resp.Body); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("downloading collector: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("writing collector binary: %w", err)
+	}
+
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return "", fmt.Errorf("checksum mismatch: expected %s, got %s", want, got)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return "", fmt.Errorf("making collector executable: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), binaryPath); err != nil {
+		return "", fmt.Errorf("installing collector binary: %w", err)
+	}
+
+	return binaryPath, nil
+}
+
+// verifyCollectorSignature checks the base64 ed25519 signature of a
+// downloaded collector binary
+func verifyCollectorSignature(binaryPath, signature string, key ed25519.PublicKey) error {
+	if signature == "" {
+		return fmt.Errorf("binary signature is required")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return fmt.Errorf("invalid binary signature encoding: %w", err)
+	}
+
+	data, err := os.ReadFile(binaryPath)
+	if err != nil {
+		return fmt.Errorf("reading collector binary: %w", err)
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return fmt.Errorf("binary signature verification failed")
+	}
+	return nil
+}
+
+// currentCollectorLink is the symlink under <workdir>/collectors naming the
+// active installed version
+const currentCollectorLink = "current"
+
+// installedCollectorPath returns the binary path that runs whichever version
+// the current link points at
+func installedCollectorPath(workDir string) string {
+	return filepath.Join(workDir, "collectors", currentCollectorLink, "otelcol")
+}
+
+// activateCollector points the current link at an installed version,
+// replacing it atomically. Returns the previous link target, "" if there was
+// none.
+func activateCollector(workDir, version string) (string, error) {
+	dir := filepath.Join(workDir, "collectors")
+	previous, err := os.Readlink(filepath.Join(dir, currentCollectorLink))
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("reading current collector link: %w", err)
+	}
+	if err := setCollectorLink(dir, version); err != nil {
+		return previous, err
+	}
+	return previous, nil
+}
+
+// restoreCollectorLink points the current link back at a previous target,
+// removing it if there was none
+func restoreCollectorLink(workDir, previous string) error {
+	dir := filepath.Join(workDir, "collectors")
+	if previous == "" {
+		if err := os.Remove(filepath.Join(dir, currentCollectorLink)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing current collector link: %w", err)
+		}
+		return nil
+	}
+	return setCollectorLink(dir, previous)
+}
+
+// setCollectorLink replaces the current link by renaming a new link over it
+func setCollectorLink(dir, target string) error {
+	tmp := filepath.Join(dir, currentCollectorLink+".tmp")
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return fmt.Errorf("creating collector link: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, currentCollectorLink)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("switching collector link: %w", err)
+	}
+	return nil
+}
+
+// isFile reports whether path exists and is a regular file, following links
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// fileChecksum returns the hex sha256 of a file
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// versionPattern extracts the version from `otelcol --version` output
+var versionPattern = regexp.MustCompile(`version\s+(v?[0-9]+(\.[0-9]+)*(-[0-9A-Za-z.]+)?)`)
+
+// detectCollectorVersion asks the collector binary for its version
+func detectCollectorVersion(ctx context.Context, binaryPath string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, binaryPath, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("running %s --version: %w", binaryPath, err)
+	}
+
+	match := versionPattern.FindStringSubmatch(string(out))
+	if match == nil {
+		return "", fmt.Errorf("no version in %s --version output", binaryPath)
+	}
+	return match[1], nil
+}
@@ -0,0 +1,533 @@
+package supervisor
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/models"
+	"go.uber.org/zap/zaptest"
+)
+
+// releaseServer serves a signed channel manifest
+type releaseServer struct {
+	*httptest.Server
+	publicKey string
+	manifest  []byte
+	signature string
+}
+
+func newReleaseServer(t *testing.T, manifest ReleaseManifest) *releaseServer {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("Failed to marshal manifest: %v", err)
+	}
+
+	rs := &releaseServer{
+		publicKey: base64.StdEncoding.EncodeToString(pub),
+		manifest:  data,
+		signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data)),
+	}
+	rs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/" + manifest.Channel + ".json":
+			w.Write(rs.manifest)
+		case "/" + manifest.Channel + ".json.sig":
+			w.Write([]byte(rs.signature))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(rs.Close)
+	return rs
+}
+
+func newTestUpdater(t *testing.T, config UpdaterConfig, currentVersion string) (*collectorUpdater, *UnifiedSupervisor) {
+	t.Helper()
+
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		WorkDir: t.TempDir(),
+		Logger:  zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	s.status.Version = currentVersion
+
+	config.Enabled = true
+	u, err := newCollectorUpdater(config, s)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+	return u, s
+}
+
+func TestUpdater_NotifiesNewerRelease(t *testing.T) {
+	rs := newReleaseServer(t, ReleaseManifest{
+		Channel:      "beta",
+		Version:      "0.97.0-rc.1",
+		ReleaseNotes: "Faster startup",
+	})
+
+	u, s := newTestUpdater(t, UpdaterConfig{
+		Channel:     UpdateChannelBeta,
+		ManifestURL: rs.URL,
+		PublicKey:   rs.publicKey,
+	}, "0.96.0")
+	u.apply = func(ctx context.Context, update *models.CollectorUpdate) (*models.UpdateResult, error) {
+		t.Fatal("notify mode must not apply updates")
+		return nil, nil
+	}
+
+	if err := u.check(context.Background()); err != nil {
+		t.Fatalf("Update check failed: %v", err)
+	}
+
+	status, _ := s.GetStatus(context.Background())
+	if status.AvailableUpdate == nil {
+		t.Fatal("Expected available update in status")
+	}
+	if status.AvailableUpdate.Version != "0.97.0-rc.1" || status.AvailableUpdate.Channel != "beta" {
+		t.Errorf("Unexpected available update: %+v", status.AvailableUpdate)
+	}
+	if status.AvailableUpdate.ScheduledFor != nil {
+		t.Error("Notify mode should not schedule the update")
+	}
+	if u.notified != "0.97.0-rc.1" {
+		t.Errorf("Expected update event for 0.97.0-rc.1, got %q", u.notified)
+	}
+}
+
+func TestUpdater_UpToDate(t *testing.T) {
+	rs := newReleaseServer(t, ReleaseManifest{Channel: "stable", Version: "0.96.0"})
+
+	u, s := newTestUpdater(t, UpdaterConfig{ManifestURL: rs.URL, PublicKey: rs.publicKey}, "v0.96.0")
+	s.status.AvailableUpdate = &models.AvailableUpdate{Version: "0.95.0"}
+
+	if err := u.check(context.Background()); err != nil {
+		t.Fatalf("Update check failed: %v", err)
+	}
+	if s.status.AvailableUpdate != nil {
+		t.Errorf("Expected no available update, got %+v", s.status.AvailableUpdate)
+	}
+}
+
+func TestUpdater_RejectsBadSignature(t *testing.T) {
+	rs := newReleaseServer(t, ReleaseManifest{Channel: "stable", Version: "0.97.0"})
+	// Tamper with the manifest after signing
+	rs.manifest = []byte(`{"channel":"stable","version":"9.9.9"}`)
+
+	u, s := newTestUpdater(t, UpdaterConfig{ManifestURL: rs.URL, PublicKey: rs.publicKey}, "0.96.0")
+
+	if err := u.check(context.Background()); err == nil {
+		t.Fatal("Expected signature verification failure")
+	}
+	if s.status.AvailableUpdate != nil {
+		t.Error("Unverified manifest must not be reported")
+	}
+}
+
+func TestUpdater_RejectsWrongChannel(t *testing.T) {
+	rs := newReleaseServer(t, ReleaseManifest{Channel: "stable", Version: "0.97.0"})
+	// Serve the stable manifest at the beta path
+	rs.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/beta.json":
+			w.Write(rs.manifest)
+		case "/beta.json.sig":
+			w.Write([]byte(rs.signature))
+		}
+	})
+
+	u, _ := newTestUpdater(t, UpdaterConfig{
+		Channel:     UpdateChannelBeta,
+		ManifestURL: rs.URL,
+		PublicKey:   rs.publicKey,
+	}, "0.96.0")
+
+	if err := u.check(context.Background()); err == nil {
+		t.Fatal("Expected channel mismatch error")
+	}
+}
+
+func TestUpdater_AutoAppliesInMaintenanceWindow(t *testing.T) {
+	rs := newReleaseServer(t, ReleaseManifest{
+		Channel:     "stable",
+		Version:     "0.97.0",
+		DownloadURL: "https://example.com/otelcol",
+		Checksum:    "abc",
+	})
+
+	window, err := ParseMaintenanceWindow("sat,sun 02:00-04:00")
+	if err != nil {
+		t.Fatalf("Failed to parse window: %v", err)
+	}
+	u, s := newTestUpdater(t, UpdaterConfig{
+		ManifestURL:       rs.URL,
+		PublicKey:         rs.publicKey,
+		Mode:              UpdateModeAuto,
+		MaintenanceWindow: window,
+	}, "0.96.0")
+
+	var applied []*models.CollectorUpdate
+	u.apply = func(ctx context.Context, update *models.CollectorUpdate) (*models.UpdateResult, error) {
+		applied = append(applied, update)
+		return &models.UpdateResult{Success: true, OldVersion: "0.96.0", NewVersion: update.Version}, nil
+	}
+
+	// Monday: outside the window, only scheduled
+	monday := time.Date(2024, 1, 1, 3, 0, 0, 0, time.Local)
+	u.now = func() time.Time { return monday }
+	if err := u.check(context.Background()); err != nil {
+		t.Fatalf("Update check failed: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Fatal("Update applied outside the maintenance window")
+	}
+	scheduled := s.status.AvailableUpdate.ScheduledFor
+	if scheduled == nil || !scheduled.Equal(time.Date(2024, 1, 6, 2, 0, 0, 0, time.Local)) {
+		t.Errorf("Expected update scheduled for Saturday 02:00, got %v", scheduled)
+	}
+
+	// Saturday 03:00: inside the window
+	saturday := time.Date(2024, 1, 6, 3, 0, 0, 0, time.Local)
+	u.now = func() time.Time { return saturday }
+	if err := u.check(context.Background()); err != nil {
+		t.Fatalf("Update check failed: %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != "0.97.0" || applied[0].Metadata["channel"] != "stable" {
+		t.Errorf("Unexpected applied updates: %+v", applied)
+	}
+}
+
+func TestNewCollectorUpdater_Validation(t *testing.T) {
+	s, err := NewUnifiedSupervisor(SupervisorConfig{Logger: zaptest.NewLogger(t)})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	pub, _, _ := ed25519.GenerateKey(nil)
+	key := base64.StdEncoding.EncodeToString(pub)
+
+	tests := []struct {
+		name   string
+		config UpdaterConfig
+	}{
+		{"bad channel", UpdaterConfig{Channel: "nightly", ManifestURL: "https://example.com", PublicKey: key}},
+		{"bad mode", UpdaterConfig{Mode: "yolo", ManifestURL: "https://example.com", PublicKey: key}},
+		{"no manifest", UpdaterConfig{PublicKey: key}},
+		{"bad key", UpdaterConfig{ManifestURL: "https://example.com", PublicKey: "not-a-key"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newCollectorUpdater(tt.config, s); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+
+	u, err := newCollectorUpdater(UpdaterConfig{ManifestURL: "https://example.com", PublicKey: key}, s)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if u.config.Channel != UpdateChannelStable || u.config.Mode != UpdateModeNotify {
+		t.Errorf("Unexpected defaults: %+v", u.config)
+	}
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	window, err := ParseMaintenanceWindow("23:00-01:00")
+	if err != nil {
+		t.Fatalf("Failed to parse window: %v", err)
+	}
+
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{time.Date(2024, 1, 1, 22, 59, 0, 0, time.Local), false},
+		{time.Date(2024, 1, 1, 23, 0, 0, 0, time.Local), true},
+		{time.Date(2024, 1, 2, 0, 30, 0, 0, time.Local), true},
+		{time.Date(2024, 1, 2, 1, 0, 0, 0, time.Local), false},
+	}
+	for _, tt := range tests {
+		if got := window.Contains(tt.at); got != tt.want {
+			t.Errorf("Contains(%v) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+
+	if !(MaintenanceWindow{}).Contains(time.Now()) {
+		t.Error("Empty window should always be open")
+	}
+
+	for _, invalid := range []string{"02:00", "funday 02:00-04:00", "2am-4am", "mon 02:00-04:00 extra"} {
+		if _, err := ParseMaintenanceWindow(invalid); err == nil {
+			t.Errorf("Expected error parsing %q", invalid)
+		}
+	}
+}
+
+func TestDownloadCollector(t *testing.T) {
+	binary := []byte("#!/bin/sh\necho otelcol version 0.97.0\n")
+	sum := sha256.Sum256(binary)
+	checksum := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binary)
+	}))
+	defer server.Close()
+
+	workDir := t.TempDir()
+	update := &models.CollectorUpdate{Version: "0.97.0", DownloadURL: server.URL, Checksum: "sha256:" + checksum}
+
+	path, err := downloadCollector(context.Background(), workDir, update)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Binary not installed: %v", err)
+	}
+	if info.Mode().Perm()&0100 == 0 {
+		t.Error("Binary is not executable")
+	}
+
+	version, err := detectCollectorVersion(context.Background(), path)
+	if err != nil || version != "0.97.0" {
+		t.Errorf("detectCollectorVersion = %q, %v", version, err)
+	}
+
+	// Checksum mismatch is rejected
+	update.Version = "0.98.0"
+	update.Checksum = hex.EncodeToString(make([]byte, sha256.Size))
+	if _, err := downloadCollector(context.Background(), workDir, update); err == nil {
+		t.Error("Expected checksum mismatch error")
+	}
+
+	// Versions that could escape the work dir are rejected
+	update.Version = "../../bin"
+	if _, err := downloadCollector(context.Background(), workDir, update); err == nil {
+		t.Error("Expected invalid version error")
+	}
+}
+
+// collectorScript returns a fake collector reporting version and running
+// with the given shell body
+func collectorScript(version, run string) []byte {
+	return []byte("#!/bin/sh\ncase \"$1\" in\n--version) echo \"otelcol version " + version + "\" ;;\nvalidate) exit 0 ;;\n*) " + run + " ;;\nesac\n")
+}
+
+// updateFixture is a supervisor running an old collector plus a server
+// offering a signed new one
+type updateFixture struct {
+	supervisor *UnifiedSupervisor
+	update     *models.CollectorUpdate
+	oldPath    string
+}
+
+func newUpdateFixture(t *testing.T, binary []byte) *updateFixture {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binary)
+	}))
+	t.Cleanup(server.Close)
+
+	workDir := t.TempDir()
+	oldPath := filepath.Join(t.TempDir(), "otelcol")
+	if err := os.WriteFile(oldPath, collectorScript("0.96.0", "exec sleep 30"), 0755); err != nil {
+		t.Fatalf("Failed to write collector script: %v", err)
+	}
+
+	s, err := NewUnifiedSupervisor(SupervisorConfig{
+		CollectorPath:      oldPath,
+		WorkDir:            workDir,
+		Updater:            UpdaterConfig{PublicKey: base64.StdEncoding.EncodeToString(pub)},
+		UpdateVerifyPeriod: 100 * time.Millisecond,
+		Logger:             zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	update := &models.ConfigUpdate{Config: []byte("service:\n  name: web-01\n"), Format: "yaml"}
+	if _, err := s.configEngine.ApplyConfig(context.Background(), update); err != nil {
+		t.Fatalf("Failed to apply config: %v", err)
+	}
+	if err := s.startCollector(context.Background()); err != nil {
+		t.Fatalf("Failed to start collector: %v", err)
+	}
+	t.Cleanup(func() {
+		s.StopCollector(context.Background(), 5*time.Second)
+	})
+
+	sum := sha256.Sum256(binary)
+	return &updateFixture{
+		supervisor: s,
+		oldPath:    oldPath,
+		update: &models.CollectorUpdate{
+			Version:     "0.97.0",
+			DownloadURL: server.URL,
+			Checksum:    hex.EncodeToString(sum[:]),
+			Signature:   base64.StdEncoding.EncodeToString(ed25519.Sign(priv, binary)),
+		},
+	}
+}
+
+func stepNames(result *models.UpdateResult) []string {
+	var names []string
+	for _, step := range result.Steps {
+		names = append(names, step.Name)
+	}
+	return names
+}
+
+func TestUnifiedSupervisor_UpdateCollector(t *testing.T) {
+	f := newUpdateFixture(t, collectorScript("0.97.0", "exec sleep 30"))
+	s := f.supervisor
+
+	result, err := s.UpdateCollector(context.Background(), f.update)
+	if err != nil {
+		t.Fatalf("Update failed: %v (%+v)", err, result)
+	}
+	if !result.Success || result.RolledBack || result.OldVersion != "0.96.0" || result.NewVersion != "0.97.0" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	want := []string{"download", "verify_signature", "test_version", "validate_config", "swap", "restart", "verify_running"}
+	if got := stepNames(result); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected steps %v, got %v", want, got)
+	}
+	for _, step := range result.Steps {
+		if !step.Success {
+			t.Errorf("Step %s failed: %s", step.Name, step.Message)
+		}
+	}
+
+	// The current link now names the new version and the collector runs it
+	target, err := os.Readlink(filepath.Join(s.config.WorkDir, "collectors", "current"))
+	if err != nil || target != "0.97.0" {
+		t.Errorf("Expected current link to 0.97.0, got %q, %v", target, err)
+	}
+	if s.config.CollectorPath != installedCollectorPath(s.config.WorkDir) {
+		t.Errorf("Expected collector path %s, got %s", installedCollectorPath(s.config.WorkDir), s.config.CollectorPath)
+	}
+	if !s.collector.IsRunning() || s.status.Version != "0.97.0" {
+		t.Errorf("Expected 0.97.0 running, got version %s running %t", s.status.Version, s.collector.IsRunning())
+	}
+
+	// A restarted supervisor keeps running the updated binary
+	restarted, err := NewUnifiedSupervisor(SupervisorConfig{
+		CollectorPath: f.oldPath,
+		WorkDir:       s.config.WorkDir,
+		Logger:        zaptest.NewLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create supervisor: %v", err)
+	}
+	if restarted.config.CollectorPath != installedCollectorPath(s.config.WorkDir) {
+		t.Errorf("Expected restarted supervisor to use the updated binary, got %s", restarted.config.CollectorPath)
+	}
+}
+
+func TestUnifiedSupervisor_UpdateCollector_RollsBack(t *testing.T) {
+	// The new collector starts but exits right away
+	f := newUpdateFixture(t, collectorScript("0.97.0", "exit 1"))
+	s := f.supervisor
+
+	result, err := s.UpdateCollector(context.Background(), f.update)
+	if err == nil {
+		t.Fatal("Expected update to fail")
+	}
+	if result.Success || !result.RolledBack || result.Error == nil {
+		t.Errorf("Expected rolled back failure, got %+v", result)
+	}
+
+	last := result.Steps[len(result.Steps)-1]
+	if last.Name != "rollback" || !last.Success {
+		t.Errorf("Expected successful rollback step, got %+v", last)
+	}
+
+	if s.config.CollectorPath != f.oldPath {
+		t.Errorf("Expected collector path restored to %s, got %s", f.oldPath, s.config.CollectorPath)
+	}
+	if _, err := os.Lstat(filepath.Join(s.config.WorkDir, "collectors", "current")); !os.IsNotExist(err) {
+		t.Errorf("Expected current link removed, got %v", err)
+	}
+	if !s.collector.IsRunning() {
+		t.Error("Expected previous collector running")
+	}
+}
+
+func TestUnifiedSupervisor_UpdateCollector_RejectedBeforeSwap(t *testing.T) {
+	tests := []struct {
+		name     string
+		binary   []byte
+		mutate   func(*models.CollectorUpdate)
+		failStep string
+	}{
+		{
+			name:   "bad signature",
+			binary: collectorScript("0.97.0", "exec sleep 30"),
+			mutate: func(u *models.CollectorUpdate) {
+				u.Signature = base64.StdEncoding.EncodeToString(make([]byte, ed25519.SignatureSize))
+			},
+			failStep: "verify_signature",
+		},
+		{
+			name:     "missing signature",
+			binary:   collectorScript("0.97.0", "exec sleep 30"),
+			mutate:   func(u *models.CollectorUpdate) { u.Signature = "" },
+			failStep: "verify_signature",
+		},
+		{
+			name:     "wrong version",
+			binary:   collectorScript("0.95.0", "exec sleep 30"),
+			failStep: "test_version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newUpdateFixture(t, tt.binary)
+			s := f.supervisor
+			if tt.mutate != nil {
+				tt.mutate(f.update)
+			}
+			running := s.collector
+
+			result, err := s.UpdateCollector(context.Background(), f.update)
+			if err == nil {
+				t.Fatal("Expected update to fail")
+			}
+			last := result.Steps[len(result.Steps)-1]
+			if last.Name != tt.failStep || last.Success {
+				t.Errorf("Expected %s to fail, got %+v", tt.failStep, last)
+			}
+			if result.RolledBack {
+				t.Error("Expected no rollback before the swap")
+			}
+			if s.collector != running || !running.IsRunning() || s.config.CollectorPath != f.oldPath {
+				t.Error("Expected running collector untouched")
+			}
+		})
+	}
+}
@@ -248,8 +248,6 @@ func (c *ProcessCollector) calculateCPUPercentages(processes []*ProcessInfo) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	currentTime := time.Now()
-	
 	for _, proc := range processes {
 		lastCPU, exists := c.lastCPUTimes[proc.PID]
 		if exists {
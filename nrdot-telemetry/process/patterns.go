@@ -81,6 +81,26 @@ func NewServiceDetector() *ServiceDetector {
 			Patterns:   []string{"java.*cassandra", "cassandra"},
 			Confidence: "MEDIUM",
 		},
+		{
+			Service:    "haproxy",
+			Patterns:   []string{"haproxy"},
+			Confidence: "HIGH",
+		},
+		{
+			Service:    "varnish",
+			Patterns:   []string{"varnishd"},
+			Confidence: "HIGH",
+		},
+		{
+			Service:    "consul",
+			Patterns:   []string{"consul"},
+			Confidence: "HIGH",
+		},
+		{
+			Service:    "etcd",
+			Patterns:   []string{"etcd"},
+			Confidence: "HIGH",
+		},
 		{
 			Service:    "docker",
 			Patterns:   []string{"dockerd", "docker-containerd"},
@@ -142,6 +162,10 @@ func (d *ServiceDetector) DetectService(proc *ProcessInfo) (service string, conf
 			if strings.Contains(cmdLower, "elasticsearch") {
 				return "elasticsearch", "HIGH"
 			}
+			// Solr's start script sets -Dsolr.solr.home and -Dsolr.install.dir
+			if strings.Contains(cmdLower, "-dsolr.") {
+				return "solr", "HIGH"
+			}
 			if strings.Contains(cmdLower, "kafka") && !strings.Contains(cmdLower, "zookeeper") {
 				return "kafka", "HIGH"
 			}
@@ -151,6 +175,9 @@ func (d *ServiceDetector) DetectService(proc *ProcessInfo) (service string, conf
 			if strings.Contains(cmdLower, "cassandra") {
 				return "cassandra", "HIGH"
 			}
+			if strings.Contains(cmdLower, "org.apache.catalina.startup.bootstrap") {
+				return "tomcat", "HIGH"
+			}
 		}
 
 		// Beam/Erlang services
@@ -227,6 +254,45 @@ func (d *ServiceDetector) GetServiceMetadata(service string) map[string]interfac
 		metadata["jmx_port"] = 9999
 		metadata["log_paths"] = []string{"/var/log/kafka/"}
 		metadata["config_paths"] = []string{"/etc/kafka/"}
+
+	case "tomcat":
+		metadata["default_port"] = 8080
+		metadata["jmx_port"] = 9010
+		metadata["log_paths"] = []string{"/var/log/tomcat9/", "/var/log/tomcat10/", "/var/log/tomcat/", "/opt/tomcat/logs/"}
+		metadata["config_paths"] = []string{"/etc/tomcat9/", "/etc/tomcat10/", "/etc/tomcat/", "/opt/tomcat/conf/"}
+
+	case "haproxy":
+		metadata["default_ports"] = []int{80, 443}
+		metadata["stats_port"] = 8404
+		metadata["metrics_endpoint"] = "/stats"
+		metadata["log_paths"] = []string{"/var/log/haproxy.log"}
+		metadata["config_paths"] = []string{"/etc/haproxy/"}
+
+	case "consul":
+		metadata["default_port"] = 8500
+		metadata["metrics_endpoint"] = "/v1/agent/metrics"
+		metadata["log_paths"] = []string{"/var/log/consul/"}
+		metadata["config_paths"] = []string{"/etc/consul.d/", "/etc/consul/"}
+
+	case "etcd":
+		metadata["default_port"] = 2379
+		metadata["metrics_endpoint"] = "/metrics"
+		metadata["config_paths"] = []string{"/etc/etcd/", "/etc/default/etcd"}
+
+	case "varnish":
+		metadata["default_port"] = 6081
+		metadata["admin_port"] = 6082
+		metadata["exporter_port"] = 9131
+		metadata["metrics_endpoint"] = "varnishstat"
+		metadata["log_paths"] = []string{"/var/log/varnish/"}
+		metadata["config_paths"] = []string{"/etc/varnish/"}
+
+	case "solr":
+		metadata["default_port"] = 8983
+		metadata["jmx_port"] = 18983
+		metadata["metrics_endpoint"] = "/solr/admin/metrics"
+		metadata["log_paths"] = []string{"/var/solr/logs/"}
+		metadata["config_paths"] = []string{"/var/solr/", "/etc/default/solr.in.sh"}
 	}
 
 	return metadata
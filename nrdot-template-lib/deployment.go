@@ -0,0 +1,158 @@
+package templatelib
+
+// gatewayAuthenticator is the extension authenticating agents sending to a
+// gateway
+const gatewayAuthenticator = "bearertokenauth/gateway"
+
+// gatewayMode reports whether the collector is a gateway, receiving from
+// other agents rather than collecting from its host
+func (g *Generator) gatewayMode() bool {
+	return g.config.Deployment.Mode == "gateway"
+}
+
+// loadBalancing reports whether telemetry is forwarded to a pool of
+// collectors instead of New Relic
+func (g *Generator) loadBalancing() bool {
+	return len(g.config.Deployment.LoadBalancing.Hostnames) > 0
+}
+
+// generateDeploymentExtensions adds the extensions of the deployment mode to
+// extensions
+func (g *Generator) generateDeploymentExtensions(extensions map[string]interface{}) {
+	if g.gatewayMode() && g.config.Deployment.Receiver.AuthToken != "" {
+		extensions[gatewayAuthenticator] = map[string]interface{}{
+			"token": g.config.Deployment.Receiver.AuthToken,
+		}
+	}
+}
+
+// deploymentExtensions returns the names of the extensions of the
+// deployment mode
+func (g *Generator) deploymentExtensions() []string {
+	if g.gatewayMode() && g.config.Deployment.Receiver.AuthToken != "" {
+		return []string{gatewayAuthenticator}
+	}
+	return nil
+}
+
+// otlpReceiverConfig returns the configuration of the OTLP receiver: local
+// applications send to it on an agent, agents on a gateway, with the
+// gateway's endpoints, TLS and authentication. It listens on every
+// interface, IPv4 and IPv6, by default.
+func (g *Generator) otlpReceiverConfig() map[string]interface{} {
+	grpc := map[string]interface{}{"endpoint": ":4317"}
+	http := map[string]interface{}{"endpoint": ":4318"}
+
+	if g.gatewayMode() {
+		receiver := g.config.Deployment.Receiver
+		if receiver.GRPCEndpoint != "" {
+			grpc["endpoint"] = receiver.GRPCEndpoint
+		}
+		if receiver.HTTPEndpoint != "" {
+			http["endpoint"] = receiver.HTTPEndpoint
+		}
+		for _, protocol := range []map[string]interface{}{grpc, http} {
+			if receiver.TLS != nil {
+				tls := map[string]interface{}{
+					"cert_file": receiver.TLS.CertFile,
+					"key_file":  receiver.TLS.KeyFile,
+				}
+				if receiver.TLS.ClientCAFile != "" {
+					tls["client_ca_file"] = receiver.TLS.ClientCAFile
+				}
+				protocol["tls"] = tls
+			}
+			if receiver.AuthToken != "" {
+				protocol["auth"] = map[string]interface{}{"authenticator": gatewayAuthenticator}
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"protocols": map[string]interface{}{
+			"grpc": grpc,
+			"http": http,
+		},
+	}
+}
+
+// loadBalancingExporterConfig returns the configuration of the loadbalancing
+// exporter, which spreads telemetry over the collectors of the pool over
+// OTLP/gRPC, keeping each trace, or each service, on one of them
+func (g *Generator) loadBalancingExporterConfig() map[string]interface{} {
+	lb := g.config.Deployment.LoadBalancing
+
+	otlpConfig := map[string]interface{}{}
+	if lb.Insecure || lb.CAFile != "" {
+		tls := map[string]interface{}{}
+		if lb.Insecure {
+			tls["insecure"] = true
+		}
+		if lb.CAFile != "" {
+			tls["ca_file"] = lb.CAFile
+		}
+		otlpConfig["tls"] = tls
+	}
+	if lb.AuthToken != "" {
+		otlpConfig["headers"] = map[string]string{"authorization": "Bearer " + lb.AuthToken}
+	}
+	if g.config.Export.Compression != "" && g.config.Export.Compression != "none" {
+		otlpConfig["compression"] = g.config.Export.Compression
+	}
+
+	// Queue batches while a collector of the pool is slow or unreachable
+	sizing := g.Sizing()
+	otlpConfig["sending_queue"] = map[string]interface{}{
+		"enabled":       true,
+		"num_consumers": sizing.NumConsumers,
+		"queue_size":    sizing.QueueSize,
+	}
+
+	cfg := map[string]interface{}{
+		"protocol": map[string]interface{}{"otlp": otlpConfig},
+		"resolver": map[string]interface{}{
+			"static": map[string]interface{}{"hostnames": lb.Hostnames},
+		},
+	}
+	if lb.RoutingKey != "" {
+		cfg["routing_key"] = lb.RoutingKey
+	}
+	return cfg
+}
+
+// primaryExporter returns the exporter every pipeline sends to: the
+// loadbalancing exporter when forwarding to a pool of collectors, otherwise
+// the exporter to New Relic
+func (g *Generator) primaryExporter() string {
+	if g.loadBalancing() {
+		return "loadbalancing"
+	}
+	return g.otlpExporter()
+}
+
+// metricsReceivers returns the receivers of the metrics pipeline: the host's
+// metrics on an agent, the agents' on a gateway, and the collector's own
+// unless disabled
+func (g *Generator) metricsReceivers() []string {
+	receivers := []string{"hostmetrics"}
+	if g.gatewayMode() {
+		receivers = []string{"otlp"}
+	}
+	if g.internalMetricsEnabled() {
+		receivers = append(receivers, "prometheus")
+	}
+	return receivers
+}
+
+// logsReceivers returns the receivers of the logs pipeline, none when it has
+// nothing to receive
+func (g *Generator) logsReceivers() []string {
+	var receivers []string
+	if g.gatewayMode() {
+		receivers = append(receivers, "otlp")
+	}
+	if len(g.config.Logs.Sources) > 0 {
+		receivers = append(receivers, "filelog")
+	}
+	return receivers
+}
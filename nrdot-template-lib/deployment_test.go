@@ -0,0 +1,113 @@
+package templatelib
+
+import (
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratorGatewayMode(t *testing.T) {
+	config := &schema.Config{
+		Service: schema.ServiceConfig{Name: "gateway-01"},
+		Metrics: schema.MetricsConfig{Enabled: true, Interval: "60s"},
+		Traces:  schema.TracesConfig{Enabled: true, SampleRate: 1.0},
+		Logs:    schema.LogsConfig{Enabled: true},
+		Export:  schema.ExportConfig{Endpoint: "https://otlp.nr-data.net", Compression: "gzip"},
+		Deployment: schema.DeploymentConfig{
+			Mode: "gateway",
+			Receiver: schema.GatewayReceiverConfig{
+				GRPCEndpoint: "[::]:14317",
+				TLS: &schema.TLSConfig{
+					CertFile:     "/etc/nrdot/tls/gateway.crt",
+					KeyFile:      "/etc/nrdot/tls/gateway.key",
+					ClientCAFile: "/etc/nrdot/tls/agents-ca.crt",
+				},
+				AuthToken: "s3cret",
+			},
+		},
+		Logging: schema.LoggingConfig{Level: "info"},
+	}
+
+	otelConfig, err := NewGenerator(config).Generate()
+	require.NoError(t, err)
+
+	// Agents send to the gateway, which collects nothing from its host
+	assert.NotContains(t, otelConfig.Receivers, "hostmetrics")
+	tls := map[string]interface{}{
+		"cert_file":      "/etc/nrdot/tls/gateway.crt",
+		"key_file":       "/etc/nrdot/tls/gateway.key",
+		"client_ca_file": "/etc/nrdot/tls/agents-ca.crt",
+	}
+	auth := map[string]interface{}{"authenticator": "bearertokenauth/gateway"}
+	assert.Equal(t, map[string]interface{}{
+		"protocols": map[string]interface{}{
+			"grpc": map[string]interface{}{"endpoint": "[::]:14317", "tls": tls, "auth": auth},
+			"http": map[string]interface{}{"endpoint": ":4318", "tls": tls, "auth": auth},
+		},
+	}, otelConfig.Receivers["otlp"])
+	assert.Equal(t, map[string]interface{}{"token": "s3cret"}, otelConfig.Extensions["bearertokenauth/gateway"])
+	assert.Contains(t, otelConfig.Service.Extensions, "bearertokenauth/gateway")
+
+	// Every signal is received from the agents, logs even without sources
+	pipelines := otelConfig.Service.Pipelines
+	assert.Equal(t, []string{"otlp", "prometheus"}, pipelines["metrics"].Receivers)
+	assert.Equal(t, []string{"otlp"}, pipelines["traces"].Receivers)
+	assert.Equal(t, []string{"otlp"}, pipelines["logs"].Receivers)
+	assert.Equal(t, []string{"otlp"}, pipelines["logs"].Exporters)
+}
+
+func TestGeneratorLoadBalancing(t *testing.T) {
+	config := &schema.Config{
+		Service: schema.ServiceConfig{Name: "web-01"},
+		Metrics: schema.MetricsConfig{Enabled: true, Interval: "60s"},
+		Traces:  schema.TracesConfig{Enabled: true, SampleRate: 1.0},
+		Export:  schema.ExportConfig{Endpoint: "https://otlp.nr-data.net", Compression: "gzip"},
+		Deployment: schema.DeploymentConfig{
+			Mode: "agent",
+			LoadBalancing: schema.LoadBalancingConfig{
+				Hostnames:  []string{"gateway-1:4317", "gateway-2:4317"},
+				RoutingKey: "traceID",
+				CAFile:     "/etc/nrdot/tls/gateway-ca.crt",
+				AuthToken:  "s3cret",
+			},
+		},
+		Logging: schema.LoggingConfig{Level: "info"},
+	}
+
+	generator := NewGenerator(config)
+	generator.SetHostResources(HostResources{MemoryMiB: 4096, CPUs: 2})
+	otelConfig, err := generator.Generate()
+	require.NoError(t, err)
+
+	// The agent forwards to the gateways instead of New Relic
+	assert.NotContains(t, otelConfig.Exporters, "otlp")
+	sizing := generator.Sizing()
+	assert.Equal(t, map[string]interface{}{
+		"routing_key": "traceID",
+		"protocol": map[string]interface{}{
+			"otlp": map[string]interface{}{
+				"tls":         map[string]interface{}{"ca_file": "/etc/nrdot/tls/gateway-ca.crt"},
+				"headers":     map[string]string{"authorization": "Bearer s3cret"},
+				"compression": "gzip",
+				"sending_queue": map[string]interface{}{
+					"enabled":       true,
+					"num_consumers": sizing.NumConsumers,
+					"queue_size":    sizing.QueueSize,
+				},
+			},
+		},
+		"resolver": map[string]interface{}{
+			"static": map[string]interface{}{"hostnames": []string{"gateway-1:4317", "gateway-2:4317"}},
+		},
+	}, otelConfig.Exporters["loadbalancing"])
+
+	pipelines := otelConfig.Service.Pipelines
+	assert.Equal(t, []string{"hostmetrics", "prometheus"}, pipelines["metrics"].Receivers)
+	assert.Equal(t, []string{"loadbalancing"}, pipelines["metrics"].Exporters)
+	assert.Equal(t, []string{"loadbalancing"}, pipelines["traces"].Exporters)
+
+	// Agents don't authenticate senders
+	assert.NotContains(t, otelConfig.Extensions, "bearertokenauth/gateway")
+}
@@ -0,0 +1,105 @@
+package templatelib
+
+import (
+	"slices"
+
+	"github.com/newrelic/nrdot-host/nrdot-schema"
+)
+
+// exporterSignals lists the signals each additional exporter type can
+// export
+var exporterSignals = map[string][]string{
+	"otlp":                  {"metrics", "traces", "logs"},
+	"otlphttp":              {"metrics", "traces", "logs"},
+	"prometheusremotewrite": {"metrics"},
+	"file":                  {"metrics", "traces", "logs"},
+	"kafka":                 {"metrics", "traces", "logs"},
+}
+
+// additionalExporterID returns the collector component ID of an additional
+// exporter, such as otlp/backup
+func additionalExporterID(exporter schema.AdditionalExporter) string {
+	return exporter.Type + "/" + exporter.Name
+}
+
+// generateAdditionalExporters adds the configurations of the additional
+// exporters to exporters
+func (g *Generator) generateAdditionalExporters(exporters map[string]interface{}) {
+	for _, exporter := range g.config.Export.Additional {
+		if cfg := g.additionalExporterConfig(exporter); cfg != nil {
+			exporters[additionalExporterID(exporter)] = cfg
+		}
+	}
+}
+
+// additionalExporterConfig returns the collector configuration of an
+// additional exporter, nil for unknown types
+func (g *Generator) additionalExporterConfig(exporter schema.AdditionalExporter) map[string]interface{} {
+	cfg := make(map[string]interface{})
+
+	switch exporter.Type {
+	case "otlp", "otlphttp", "prometheusremotewrite":
+		cfg["endpoint"] = exporter.Endpoint
+		if len(exporter.Headers) > 0 {
+			cfg["headers"] = exporter.Headers
+		}
+		if exporter.Insecure {
+			cfg["tls"] = map[string]interface{}{"insecure": true}
+		}
+		// The gRPC exporter has no proxy setting and picks the proxy up
+		// from the collector's environment
+		if exporter.Type != "otlp" {
+			if proxyURL := g.proxyForTarget(exporter.Endpoint); proxyURL != "" {
+				cfg["proxy_url"] = proxyURL
+			}
+		}
+
+	case "file":
+		cfg["path"] = exporter.Path
+
+	case "kafka":
+		cfg["brokers"] = exporter.Brokers
+		if exporter.Topic != "" {
+			cfg["topic"] = exporter.Topic
+		}
+		cfg["encoding"] = "otlp_proto"
+
+	default:
+		return nil
+	}
+
+	return cfg
+}
+
+// additionalExporters returns the IDs of the additional exporters of a
+// signal's pipeline, in configuration order
+func (g *Generator) additionalExporters(signal string) []string {
+	var ids []string
+	for _, exporter := range g.config.Export.Additional {
+		if exportsSignal(exporter, signal) {
+			ids = append(ids, additionalExporterID(exporter))
+		}
+	}
+	return ids
+}
+
+// exportsSignal reports whether an additional exporter exports signal: one
+// of its signals, or any its type supports when none are set
+func exportsSignal(exporter schema.AdditionalExporter, signal string) bool {
+	if !slices.Contains(exporterSignals[exporter.Type], signal) {
+		return false
+	}
+	return len(exporter.Signals) == 0 || slices.Contains(exporter.Signals, signal)
+}
+
+// pipelineExporters returns the exporters of a signal's pipeline: New
+// Relic or the load-balanced pool, then the additional exporters, then
+// debug in debug mode
+func (g *Generator) pipelineExporters(signal string) []string {
+	exporters := []string{g.primaryExporter()}
+	exporters = append(exporters, g.additionalExporters(signal)...)
+	if g.config.Logging.Level == "debug" {
+		exporters = append(exporters, "debug")
+	}
+	return exporters
+}
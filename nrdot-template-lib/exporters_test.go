@@ -0,0 +1,107 @@
+package templatelib
+
+import (
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratorAdditionalExporters(t *testing.T) {
+	config := &schema.Config{
+		Service: schema.ServiceConfig{Name: "test-service"},
+		Metrics: schema.MetricsConfig{Enabled: true, Interval: "60s"},
+		Traces:  schema.TracesConfig{Enabled: true, SampleRate: 1.0},
+		Logs: schema.LogsConfig{
+			Enabled: true,
+			Sources: []schema.LogSource{{Path: "/var/log/app.log"}},
+		},
+		Export: schema.ExportConfig{
+			Endpoint:    "https://otlp.nr-data.net",
+			Compression: "gzip",
+			Additional: []schema.AdditionalExporter{
+				{
+					Name:     "backup",
+					Type:     "otlp",
+					Endpoint: "collector.internal:4317",
+					Headers:  map[string]string{"x-tenant": "web"},
+					Insecure: true,
+				},
+				{
+					Name:     "thanos",
+					Type:     "prometheusremotewrite",
+					Endpoint: "https://thanos.example.com/api/v1/receive",
+				},
+				{
+					Name: "debug",
+					Type: "file",
+					Path: "/var/log/nrdot/telemetry.json",
+				},
+				{
+					Name:    "events",
+					Type:    "kafka",
+					Brokers: []string{"kafka-1:9092", "kafka-2:9092"},
+					Topic:   "nrdot-logs",
+					Signals: []string{"logs"},
+				},
+			},
+		},
+		Proxy:   schema.ProxyConfig{HTTPSProxy: "http://proxy.corp:3129", NoProxy: ".internal"},
+		Logging: schema.LoggingConfig{Level: "debug"},
+	}
+
+	otelConfig, err := NewGenerator(config).Generate()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"endpoint": "collector.internal:4317",
+		"headers":  map[string]string{"x-tenant": "web"},
+		"tls":      map[string]interface{}{"insecure": true},
+	}, otelConfig.Exporters["otlp/backup"])
+	assert.Equal(t, map[string]interface{}{
+		"endpoint":  "https://thanos.example.com/api/v1/receive",
+		"proxy_url": "http://proxy.corp:3129",
+	}, otelConfig.Exporters["prometheusremotewrite/thanos"])
+	assert.Equal(t, map[string]interface{}{
+		"path": "/var/log/nrdot/telemetry.json",
+	}, otelConfig.Exporters["file/debug"])
+	assert.Equal(t, map[string]interface{}{
+		"brokers":  []string{"kafka-1:9092", "kafka-2:9092"},
+		"topic":    "nrdot-logs",
+		"encoding": "otlp_proto",
+	}, otelConfig.Exporters["kafka/events"])
+
+	// New Relic comes first and debug last; each pipeline gets the
+	// exporters of its signal
+	pipelines := otelConfig.Service.Pipelines
+	assert.Equal(t, []string{"otlphttp", "otlp/backup", "prometheusremotewrite/thanos", "file/debug", "debug"},
+		pipelines["metrics"].Exporters)
+	assert.Equal(t, []string{"otlphttp", "otlp/backup", "file/debug", "debug"},
+		pipelines["traces"].Exporters)
+	assert.Equal(t, []string{"otlphttp", "otlp/backup", "file/debug", "kafka/events", "debug"},
+		pipelines["logs"].Exporters)
+
+	// Every pipeline exporter is configured
+	for name, pipeline := range pipelines {
+		for _, exporter := range pipeline.Exporters {
+			assert.Contains(t, otelConfig.Exporters, exporter, name)
+		}
+	}
+}
+
+func TestExportsSignal(t *testing.T) {
+	prw := schema.AdditionalExporter{Name: "prw", Type: "prometheusremotewrite"}
+	assert.True(t, exportsSignal(prw, "metrics"))
+	assert.False(t, exportsSignal(prw, "traces"))
+
+	// Signals the type does not support are never exported
+	prw.Signals = []string{"metrics", "logs"}
+	assert.False(t, exportsSignal(prw, "logs"))
+
+	file := schema.AdditionalExporter{Name: "out", Type: "file", Signals: []string{"traces"}}
+	assert.True(t, exportsSignal(file, "traces"))
+	assert.False(t, exportsSignal(file, "metrics"))
+
+	assert.False(t, exportsSignal(schema.AdditionalExporter{Name: "x", Type: "zipkin"}, "traces"))
+}
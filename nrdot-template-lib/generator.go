@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/backoff"
 	"github.com/newrelic/nrdot-host/nrdot-schema"
 	"gopkg.in/yaml.v3"
 )
@@ -35,15 +36,32 @@ type PipelineConfig struct {
 // Generator creates OTel configurations from NRDOT configs
 type Generator struct {
 	config *schema.Config
+
+	// resources size the memory limiter, batches and export queue
+	resources HostResources
 }
 
-// NewGenerator creates a new configuration generator
+// NewGenerator creates a new configuration generator sized for the
+// resources of the current host
 func NewGenerator(config *schema.Config) *Generator {
 	return &Generator{
-		config: config,
+		config:    config,
+		resources: DetectHostResources(),
 	}
 }
 
+// SetHostResources sets the resources the generated config is sized for,
+// e.g. to generate a config for another host
+func (g *Generator) SetHostResources(resources HostResources) {
+	g.resources = resources
+}
+
+// Sizing returns the memory limiter, batch and export queue settings of the
+// generated config
+func (g *Generator) Sizing() Sizing {
+	return ComputeSizing(g.resources, g.config.Processing.Tuning)
+}
+
 // Generate creates a complete OTel configuration
 func (g *Generator) Generate() (*OTelConfig, error) {
 	otelConfig := &OTelConfig{
@@ -61,19 +79,22 @@ func (g *Generator) Generate() (*OTelConfig, error) {
 func (g *Generator) generateExtensions() map[string]interface{} {
 	extensions := make(map[string]interface{})
 
-	// Health check extension
+	// Health check extension, listening on every interface, IPv4 and IPv6
 	extensions["health_check"] = map[string]interface{}{
-		"endpoint": "0.0.0.0:13133",
+		"endpoint": ":13133",
 		"path":     "/health",
 	}
 
 	// Performance profiler for debugging
 	if g.config.Logging.Level == "debug" {
 		extensions["pprof"] = map[string]interface{}{
-			"endpoint": "0.0.0.0:1777",
+			"endpoint": ":1777",
 		}
 	}
 
+	// Authentication of agents sending to a gateway
+	g.generateDeploymentExtensions(extensions)
+
 	return extensions
 }
 
@@ -81,54 +102,62 @@ func (g *Generator) generateExtensions() map[string]interface{} {
 func (g *Generator) generateReceivers() map[string]interface{} {
 	receivers := make(map[string]interface{})
 
-	// Host metrics receiver
+	// Host metrics receiver, on agents only: a gateway receives the metrics
+	// of its agents' hosts
 	if g.config.Metrics.Enabled {
 		interval, _ := parseDuration(g.config.Metrics.Interval)
 		
-		receivers["hostmetrics"] = map[string]interface{}{
-			"collection_interval": interval.String(),
-			"scrapers": map[string]interface{}{
-				"cpu":        map[string]interface{}{},
-				"disk":       map[string]interface{}{},
-				"filesystem": map[string]interface{}{},
-				"load":       map[string]interface{}{},
-				"memory":     map[string]interface{}{},
-				"network":    map[string]interface{}{},
-				"paging":     map[string]interface{}{},
-				"processes":  map[string]interface{}{},
-			},
+		if !g.gatewayMode() {
+			receivers["hostmetrics"] = map[string]interface{}{
+				"collection_interval": interval.String(),
+				"scrapers": map[string]interface{}{
+					"cpu":        map[string]interface{}{},
+					"disk":       map[string]interface{}{},
+					"filesystem": map[string]interface{}{},
+					"load":       map[string]interface{}{},
+					"memory":     map[string]interface{}{},
+					"network":    map[string]interface{}{},
+					"paging":     map[string]interface{}{},
+					"processes":  map[string]interface{}{},
+				},
+			}
 		}
 
-		// Add Prometheus receiver for app metrics
-		receivers["prometheus"] = map[string]interface{}{
-			"config": map[string]interface{}{
-				"scrape_configs": []map[string]interface{}{
+		// Prometheus receiver scraping the collector's own metrics, unless
+		// they are disabled
+		if g.internalMetricsEnabled() {
+			scrapeTarget := g.internalMetricsScrapeTarget()
+			scrapeConfig := map[string]interface{}{
+				"job_name":        "otel-collector",
+				"scrape_interval": interval.String(),
+				"static_configs": []map[string]interface{}{
 					{
-						"job_name":        "otel-collector",
-						"scrape_interval": interval.String(),
-						"static_configs": []map[string]interface{}{
-							{
-								"targets": []string{"0.0.0.0:8888"},
-							},
-						},
+						"targets": []string{scrapeTarget},
 					},
 				},
-			},
+			}
+			// Exemplars are only exposed in the OpenMetrics format
+			if g.config.Telemetry.InternalMetrics.Exemplars {
+				scrapeConfig["scrape_protocols"] = []string{"OpenMetricsText1.0.0", "OpenMetricsText0.0.1", "PrometheusText0.0.4"}
+			}
+			if proxyURL := g.proxyForTarget(scrapeTarget); proxyURL != "" {
+				scrapeConfig["proxy_url"] = proxyURL
+				if g.config.Proxy.NoProxy != "" {
+					scrapeConfig["no_proxy"] = g.config.Proxy.NoProxy
+				}
+			}
+			receivers["prometheus"] = map[string]interface{}{
+				"config": map[string]interface{}{
+					"scrape_configs": []map[string]interface{}{scrapeConfig},
+				},
+			}
 		}
 	}
 
-	// OTLP receiver for traces
-	if g.config.Traces.Enabled {
-		receivers["otlp"] = map[string]interface{}{
-			"protocols": map[string]interface{}{
-				"grpc": map[string]interface{}{
-					"endpoint": "0.0.0.0:4317",
-				},
-				"http": map[string]interface{}{
-					"endpoint": "0.0.0.0:4318",
-				},
-			},
-		}
+	// OTLP receiver for traces, and for all signals of the agents of a
+	// gateway
+	if g.config.Traces.Enabled || g.gatewayMode() {
+		receivers["otlp"] = g.otlpReceiverConfig()
 	}
 
 	// File log receiver
@@ -166,18 +195,20 @@ func (g *Generator) generateReceivers() map[string]interface{} {
 func (g *Generator) generateProcessors() map[string]interface{} {
 	processors := make(map[string]interface{})
 
+	sizing := g.Sizing()
+
 	// Memory limiter (always enabled)
 	processors["memory_limiter"] = map[string]interface{}{
-		"check_interval":        "1s",
-		"limit_percentage":      75,
-		"spike_limit_percentage": 25,
+		"check_interval":  "1s",
+		"limit_mib":       sizing.MemoryLimitMiB,
+		"spike_limit_mib": sizing.SpikeLimitMiB,
 	}
 
 	// Batch processor (always enabled)
 	processors["batch"] = map[string]interface{}{
-		"send_batch_size":    10000,
-		"timeout":            "10s",
-		"send_batch_max_size": 11000,
+		"send_batch_size":     sizing.BatchSize,
+		"timeout":             "10s",
+		"send_batch_max_size": sizing.BatchMaxSize,
 	}
 
 	// NR Security processor
@@ -317,15 +348,7 @@ func (g *Generator) generateExporters() map[string]interface{} {
 	exporters := make(map[string]interface{})
 
 	// OTLP exporter to New Relic
-	endpoint := g.config.Export.Endpoint
-	if endpoint == "" {
-		endpoint = "https://otlp.nr-data.net"
-	}
-	
-	// Adjust endpoint for EU region
-	if g.config.Export.Region == "EU" {
-		endpoint = strings.Replace(endpoint, "otlp.nr-data.net", "otlp.eu01.nr-data.net", 1)
-	}
+	endpoint := g.exportEndpoint()
 
 	headers := map[string]string{}
 	
@@ -352,14 +375,33 @@ func (g *Generator) generateExporters() map[string]interface{} {
 
 	// Retry settings
 	if g.config.Export.Retry.Enabled {
-		otlpConfig["retry_on_failure"] = map[string]interface{}{
-			"enabled":         true,
-			"max_attempts":    g.config.Export.Retry.MaxAttempts,
-			"initial_interval": g.config.Export.Retry.Backoff,
-		}
+		otlpConfig["retry_on_failure"] = g.exportRetryPolicy().RetryOnFailure()
+	}
+
+	// Queue batches while the endpoint is slow or unreachable
+	sizing := g.Sizing()
+	otlpConfig["sending_queue"] = map[string]interface{}{
+		"enabled":       true,
+		"num_consumers": sizing.NumConsumers,
+		"queue_size":    sizing.QueueSize,
 	}
 
-	exporters["otlp"] = otlpConfig
+	// Export over OTLP/HTTP when the endpoint needs a proxy, as the gRPC
+	// exporter has no proxy setting and would depend on the collector's
+	// environment alone
+	if proxyURL := g.proxyForTarget(endpoint); proxyURL != "" {
+		otlpConfig["proxy_url"] = proxyURL
+	}
+
+	// Forward to the next tier instead of New Relic when load balancing
+	if g.loadBalancing() {
+		exporters["loadbalancing"] = g.loadBalancingExporterConfig()
+	} else {
+		exporters[g.otlpExporter()] = otlpConfig
+	}
+
+	// Exporters to other backends
+	g.generateAdditionalExporters(exporters)
 
 	// Debug exporter for development
 	if g.config.Logging.Level == "debug" {
@@ -373,6 +415,29 @@ func (g *Generator) generateExporters() map[string]interface{} {
 	return exporters
 }
 
+// otlpExporter returns the name of the exporter to New Relic: otlphttp when
+// the endpoint is reached through a proxy, otherwise otlp
+func (g *Generator) otlpExporter() string {
+	if g.proxyForTarget(g.exportEndpoint()) != "" {
+		return "otlphttp"
+	}
+	return "otlp"
+}
+
+// exportEndpoint returns the OTLP endpoint for the configured region
+func (g *Generator) exportEndpoint() string {
+	endpoint := g.config.Export.Endpoint
+	if endpoint == "" {
+		endpoint = "https://otlp.nr-data.net"
+	}
+
+	// Adjust endpoint for EU region
+	if g.config.Export.Region == "EU" {
+		endpoint = strings.Replace(endpoint, "otlp.nr-data.net", "otlp.eu01.nr-data.net", 1)
+	}
+	return endpoint
+}
+
 // generateService creates the service configuration
 func (g *Generator) generateService() ServiceConfig {
 	service := ServiceConfig{
@@ -383,16 +448,14 @@ func (g *Generator) generateService() ServiceConfig {
 				"level": g.config.Logging.Level,
 				"encoding": g.config.Logging.Format,
 			},
-			"metrics": map[string]interface{}{
-				"level": "detailed",
-				"address": "0.0.0.0:8888",
-			},
+			"metrics": g.telemetryMetricsConfig(),
 		},
 	}
 
 	if g.config.Logging.Level == "debug" {
 		service.Extensions = append(service.Extensions, "pprof")
 	}
+	service.Extensions = append(service.Extensions, g.deploymentExtensions()...)
 
 	// Build processor pipeline
 	baseProcessors := []string{"memory_limiter", "batch"}
@@ -417,13 +480,10 @@ func (g *Generator) generateService() ServiceConfig {
 		}
 		processors = append(processors, "resource")
 		
-		exporters := []string{"otlp"}
-		if g.config.Logging.Level == "debug" {
-			exporters = append(exporters, "debug")
-		}
-		
+		exporters := g.pipelineExporters("metrics")
+
 		service.Pipelines["metrics"] = PipelineConfig{
-			Receivers:  []string{"hostmetrics", "prometheus"},
+			Receivers:  g.metricsReceivers(),
 			Processors: processors,
 			Exporters:  exporters,
 		}
@@ -444,11 +504,8 @@ func (g *Generator) generateService() ServiceConfig {
 		}
 		processors = append(processors, "resource")
 		
-		exporters := []string{"otlp"}
-		if g.config.Logging.Level == "debug" {
-			exporters = append(exporters, "debug")
-		}
-		
+		exporters := g.pipelineExporters("traces")
+
 		service.Pipelines["traces"] = PipelineConfig{
 			Receivers:  []string{"otlp"},
 			Processors: processors,
@@ -457,7 +514,7 @@ func (g *Generator) generateService() ServiceConfig {
 	}
 
 	// Logs pipeline
-	if g.config.Logs.Enabled && len(g.config.Logs.Sources) > 0 {
+	if receivers := g.logsReceivers(); g.config.Logs.Enabled && len(receivers) > 0 {
 		processors := append([]string{}, baseProcessors...)
 		
 		if g.config.Security.RedactSecrets {
@@ -468,13 +525,10 @@ func (g *Generator) generateService() ServiceConfig {
 		}
 		processors = append(processors, "resource")
 		
-		exporters := []string{"otlp"}
-		if g.config.Logging.Level == "debug" {
-			exporters = append(exporters, "debug")
-		}
-		
+		exporters := g.pipelineExporters("logs")
+
 		service.Pipelines["logs"] = PipelineConfig{
-			Receivers:  []string{"filelog"},
+			Receivers:  receivers,
 			Processors: processors,
 			Exporters:  exporters,
 		}
@@ -495,6 +549,31 @@ func (g *Generator) getMultilineConfig(parser string) map[string]interface{} {
 	}
 }
 
+// exportRetryPolicy returns the retry policy of export.retry: backoff before
+// the first retry, doubling up to the collector's default cap of 30s with
+// its default jitter, for max_attempts attempts. Without max_attempts
+// retries stop after the collector's default 5m.
+func (g *Generator) exportRetryPolicy() backoff.Policy {
+	retry := g.config.Export.Retry
+	policy := backoff.Policy{
+		Initial: 5 * time.Second,
+		Max:     30 * time.Second,
+		Jitter:  0.5,
+	}
+	if initial, err := parseDuration(retry.Backoff); err == nil && initial > 0 {
+		policy.Initial = initial
+	}
+	switch {
+	case retry.MaxAttempts <= 0:
+		policy.MaxElapsed = 5 * time.Minute
+	case retry.MaxAttempts == 1:
+		policy.MaxRetries = -1
+	default:
+		policy.MaxRetries = retry.MaxAttempts - 1
+	}
+	return policy
+}
+
 // parseDuration parses a duration string
 func parseDuration(s string) (time.Duration, error) {
 	// Handle simple formats like "30s", "5m"
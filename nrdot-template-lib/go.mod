@@ -3,6 +3,7 @@ module github.com/newrelic/nrdot-host/nrdot-template-lib
 go 1.21
 
 require (
+	github.com/newrelic/nrdot-host/nrdot-common v0.0.0-00010101000000-000000000000
 	github.com/newrelic/nrdot-host/nrdot-schema v0.0.0
 	github.com/stretchr/testify v1.9.0
 	gopkg.in/yaml.v3 v3.0.1
@@ -16,4 +17,7 @@ require (
 	github.com/xeipuuv/gojsonschema v1.2.0 // indirect
 )
 
-replace github.com/newrelic/nrdot-host/nrdot-schema => ../nrdot-schema
+replace (
+	github.com/newrelic/nrdot-host/nrdot-common => ../nrdot-common
+	github.com/newrelic/nrdot-host/nrdot-schema => ../nrdot-schema
+)
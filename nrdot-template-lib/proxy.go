@@ -0,0 +1,99 @@
+package templatelib
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// proxyForTarget returns the proxy URL for connections to target, a URL or a
+// host:port, or "" if the connection goes direct. Like Go's
+// http.ProxyFromEnvironment, https targets use https_proxy and all others
+// http_proxy, and loopback addresses never use a proxy.
+func (g *Generator) proxyForTarget(target string) string {
+	proxyURL := g.config.Proxy.HTTPProxy
+	host := target
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		host = u.Host
+		if u.Scheme == "https" {
+			proxyURL = g.config.Proxy.HTTPSProxy
+		}
+	}
+	if proxyURL == "" || bypassProxy(host, g.config.Proxy.NoProxy) {
+		return ""
+	}
+	return proxyURL
+}
+
+// bypassProxy reports whether connections to host, optionally with a port,
+// skip the proxy. noProxy is a comma-separated list of hosts, domains
+// (".example.com" or "example.com", matching subdomains too), CIDRs or "*".
+func bypassProxy(host, noProxy string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && (ip.IsLoopback() || ip.IsUnspecified()) {
+		return true
+	}
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		domain := strings.TrimPrefix(entry, ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Environment returns the proxy environment variables to start the
+// collector with. Components without a proxy setting, such as the gRPC OTLP
+// exporter, only pick the proxy up from the environment.
+func (g *Generator) Environment() map[string]string {
+	env := make(map[string]string)
+	set := func(name, value string) {
+		if value != "" {
+			env[strings.ToUpper(name)] = value
+			env[strings.ToLower(name)] = value
+		}
+	}
+	set("HTTP_PROXY", g.config.Proxy.HTTPProxy)
+	set("HTTPS_PROXY", g.config.Proxy.HTTPSProxy)
+	if len(env) > 0 {
+		set("NO_PROXY", g.config.Proxy.NoProxy)
+	}
+	return env
+}
+
+// EnvironmentList returns Environment as sorted KEY=value pairs, as used by
+// exec.Cmd.Env
+func (g *Generator) EnvironmentList() []string {
+	env := g.Environment()
+	list := make([]string, 0, len(env))
+	for name, value := range env {
+		list = append(list, name+"="+value)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,92 @@
+package templatelib
+
+import (
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratorProxy(t *testing.T) {
+	config := &schema.Config{
+		Service: schema.ServiceConfig{Name: "test-service"},
+		Metrics: schema.MetricsConfig{Enabled: true, Interval: "60s"},
+		Traces:  schema.TracesConfig{Enabled: true, SampleRate: 1.0},
+		Export: schema.ExportConfig{
+			Endpoint:    "https://otlp.nr-data.net",
+			Compression: "gzip",
+		},
+		Proxy: schema.ProxyConfig{
+			HTTPProxy:  "http://proxy.corp:3128",
+			HTTPSProxy: "http://proxy.corp:3129",
+			NoProxy:    "localhost,.internal",
+		},
+		Logging: schema.LoggingConfig{Level: "info"},
+	}
+
+	t.Run("proxied endpoint", func(t *testing.T) {
+		otelConfig, err := NewGenerator(config).Generate()
+		require.NoError(t, err)
+
+		// The gRPC exporter has no proxy setting, so OTLP/HTTP is used
+		require.NotContains(t, otelConfig.Exporters, "otlp")
+		exporter := otelConfig.Exporters["otlphttp"].(map[string]interface{})
+		assert.Equal(t, "http://proxy.corp:3129", exporter["proxy_url"])
+		assert.Equal(t, "gzip", exporter["compression"])
+		assert.Equal(t, []string{"otlphttp"}, otelConfig.Service.Pipelines["metrics"].Exporters)
+		assert.Equal(t, []string{"otlphttp"}, otelConfig.Service.Pipelines["traces"].Exporters)
+
+		// The local self-scrape goes direct
+		prometheus := otelConfig.Receivers["prometheus"].(map[string]interface{})
+		scrape := prometheus["config"].(map[string]interface{})["scrape_configs"].([]map[string]interface{})[0]
+		assert.NotContains(t, scrape, "proxy_url")
+	})
+
+	t.Run("bypassed endpoint", func(t *testing.T) {
+		bypassed := *config
+		bypassed.Export.Endpoint = "https://gateway.internal:4318"
+
+		otelConfig, err := NewGenerator(&bypassed).Generate()
+		require.NoError(t, err)
+		assert.Contains(t, otelConfig.Exporters, "otlp")
+		assert.NotContains(t, otelConfig.Exporters["otlp"], "proxy_url")
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		gen := NewGenerator(config)
+		env := gen.Environment()
+		assert.Equal(t, "http://proxy.corp:3128", env["HTTP_PROXY"])
+		assert.Equal(t, "http://proxy.corp:3129", env["https_proxy"])
+		assert.Equal(t, "localhost,.internal", env["NO_PROXY"])
+		assert.Contains(t, gen.EnvironmentList(), "no_proxy=localhost,.internal")
+
+		assert.Empty(t, NewGenerator(&schema.Config{}).Environment())
+	})
+}
+
+func TestBypassProxy(t *testing.T) {
+	noProxy := "example.com, .corp.net,10.0.0.0/8,db:5432"
+	tests := []struct {
+		host   string
+		bypass bool
+	}{
+		{"localhost:8888", true},
+		{"127.0.0.1", true},
+		{"0.0.0.0:8888", true},
+		{"[::1]:4317", true},
+		{"example.com", true},
+		{"api.example.com:443", true},
+		{"notexample.com", false},
+		{"corp.net", true},
+		{"a.corp.net", true},
+		{"10.1.2.3:9100", true},
+		{"192.168.1.1", false},
+		{"db", true},
+		{"otlp.nr-data.net:443", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.bypass, bypassProxy(tt.host, noProxy), tt.host)
+	}
+	assert.True(t, bypassProxy("otlp.nr-data.net", "*"))
+}
@@ -0,0 +1,179 @@
+package templatelib
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/nrdot-host/nrdot-schema"
+)
+
+const (
+	// defaultHostMemoryMiB is assumed where host memory cannot be detected
+	defaultHostMemoryMiB = 2048
+
+	// The collector may use a quarter of the host's memory, within bounds
+	minMemoryLimitMiB = 64
+	maxMemoryLimitMiB = 4096
+
+	// Batches grow with the memory limit, within bounds
+	batchPointsPerMiB = 8
+	minBatchSize      = 500
+	maxBatchSize      = 16384
+
+	// Queued batches may fill a quarter of the memory limit, assuming this
+	// many bytes per data point
+	bytesPerDataPoint = 256
+	minQueueSize      = 10
+	maxQueueSize      = 5000
+
+	maxNumConsumers = 16
+)
+
+// HostResources are the memory and CPUs available to the collector, which
+// size its memory limiter, batches and export queue
+type HostResources struct {
+	// MemoryMiB is the host's memory, or the cgroup limit of the collector
+	// if lower. 0 if unknown.
+	MemoryMiB int
+
+	// CPUs is the number of CPUs the collector may use
+	CPUs int
+}
+
+// Sizing holds the memory limiter, batch and export queue settings of a
+// generated collector config
+type Sizing struct {
+	MemoryLimitMiB int
+	SpikeLimitMiB  int
+	BatchSize      int
+	BatchMaxSize   int
+	QueueSize      int
+	NumConsumers   int
+}
+
+// DetectHostResources detects the memory and CPUs of the host, honoring
+// cgroup limits of the current process
+func DetectHostResources() HostResources {
+	return detectHostResources("/")
+}
+
+// detectHostResources detects host resources from the proc and cgroup
+// filesystems under root
+func detectHostResources(root string) HostResources {
+	resources := HostResources{CPUs: runtime.NumCPU()}
+
+	if total, ok := readMemInfoTotal(filepath.Join(root, "proc/meminfo")); ok {
+		resources.MemoryMiB = int(total >> 20)
+	}
+
+	// cgroup v2, then v1; v1 reports no limit as a huge value
+	for _, path := range []string{"sys/fs/cgroup/memory.max", "sys/fs/cgroup/memory/memory.limit_in_bytes"} {
+		limit, ok := readUintFile(filepath.Join(root, path))
+		if !ok {
+			continue
+		}
+		if limitMiB := limit >> 20; limitMiB > 0 && (resources.MemoryMiB == 0 || limitMiB < uint64(resources.MemoryMiB)) {
+			resources.MemoryMiB = int(limitMiB)
+		}
+		break
+	}
+
+	// A cgroup v2 CPU quota, e.g. "150000 100000" for 1.5 CPUs
+	if data, err := os.ReadFile(filepath.Join(root, "sys/fs/cgroup/cpu.max")); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 {
+			quota, errQuota := strconv.ParseFloat(fields[0], 64)
+			period, errPeriod := strconv.ParseFloat(fields[1], 64)
+			if errQuota == nil && errPeriod == nil && period > 0 {
+				if cpus := int(quota/period + 0.5); cpus >= 1 && cpus < resources.CPUs {
+					resources.CPUs = cpus
+				}
+			}
+		}
+	}
+
+	return resources
+}
+
+// readMemInfoTotal returns MemTotal from a /proc/meminfo file in bytes
+func readMemInfoTotal(path string) (uint64, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			return kb << 10, true
+		}
+	}
+	return 0, false
+}
+
+// readUintFile reads a file holding a single unsigned integer; "max" and
+// other values are not one
+func readUintFile(path string) (uint64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// ComputeSizing sizes the memory limiter, batches and export queue for the
+// host's resources. Settings in tuning override the computed ones, and the
+// settings derived from them follow the overrides.
+func ComputeSizing(resources HostResources, tuning schema.TuningConfig) Sizing {
+	memoryMiB := resources.MemoryMiB
+	if memoryMiB <= 0 {
+		memoryMiB = defaultHostMemoryMiB
+	}
+
+	var s Sizing
+	s.MemoryLimitMiB = override(tuning.MemoryLimitMiB, clamp(memoryMiB/4, minMemoryLimitMiB, maxMemoryLimitMiB))
+	s.SpikeLimitMiB = override(tuning.SpikeLimitMiB, s.MemoryLimitMiB/5)
+	if tuning.MemoryLimitMiB == 0 && s.SpikeLimitMiB >= s.MemoryLimitMiB {
+		// Keep the headroom a fifth of the limit
+		s.MemoryLimitMiB = s.SpikeLimitMiB * 5
+	}
+
+	s.BatchSize = override(tuning.BatchSize, clamp(s.MemoryLimitMiB*batchPointsPerMiB, minBatchSize, maxBatchSize))
+	if tuning.BatchSize == 0 && tuning.BatchMaxSize > 0 {
+		s.BatchSize = min(s.BatchSize, tuning.BatchMaxSize)
+	}
+	s.BatchMaxSize = override(tuning.BatchMaxSize, s.BatchSize+s.BatchSize/10)
+
+	queueBytes := s.MemoryLimitMiB << 20 / 4
+	s.QueueSize = override(tuning.QueueSize, clamp(queueBytes/(s.BatchSize*bytesPerDataPoint), minQueueSize, maxQueueSize))
+	s.NumConsumers = override(tuning.NumConsumers, clamp(resources.CPUs, 2, maxNumConsumers))
+
+	return s
+}
+
+// override returns value if it is set, computed otherwise
+func override(value, computed int) int {
+	if value > 0 {
+		return value
+	}
+	return computed
+}
+
+// clamp bounds value to [lo, hi]
+func clamp(value, lo, hi int) int {
+	return max(lo, min(value, hi))
+}
@@ -0,0 +1,160 @@
+package templatelib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeSizing(t *testing.T) {
+	t.Run("small VM", func(t *testing.T) {
+		s := ComputeSizing(HostResources{MemoryMiB: 1024, CPUs: 1}, schema.TuningConfig{})
+		assert.Equal(t, Sizing{
+			MemoryLimitMiB: 256,
+			SpikeLimitMiB:  51,
+			BatchSize:      2048,
+			BatchMaxSize:   2252,
+			QueueSize:      128,
+			NumConsumers:   2,
+		}, s)
+	})
+
+	t.Run("large host", func(t *testing.T) {
+		s := ComputeSizing(HostResources{MemoryMiB: 256 * 1024, CPUs: 64}, schema.TuningConfig{})
+		assert.Equal(t, 4096, s.MemoryLimitMiB)
+		assert.Equal(t, 819, s.SpikeLimitMiB)
+		assert.Equal(t, 16384, s.BatchSize)
+		assert.Equal(t, 256, s.QueueSize)
+		assert.Equal(t, 16, s.NumConsumers)
+	})
+
+	t.Run("unknown memory", func(t *testing.T) {
+		s := ComputeSizing(HostResources{CPUs: 4}, schema.TuningConfig{})
+		assert.Equal(t, 512, s.MemoryLimitMiB)
+		assert.Equal(t, 4, s.NumConsumers)
+	})
+
+	t.Run("tiny container", func(t *testing.T) {
+		s := ComputeSizing(HostResources{MemoryMiB: 128, CPUs: 1}, schema.TuningConfig{})
+		assert.Equal(t, 64, s.MemoryLimitMiB)
+		assert.Equal(t, 512, s.BatchSize)
+		assert.Equal(t, 128, s.QueueSize)
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		s := ComputeSizing(HostResources{MemoryMiB: 1024, CPUs: 1}, schema.TuningConfig{
+			MemoryLimitMiB: 400,
+			BatchSize:      1000,
+			NumConsumers:   8,
+		})
+		assert.Equal(t, 400, s.MemoryLimitMiB)
+		assert.Equal(t, 80, s.SpikeLimitMiB, "spike limit follows the memory limit")
+		assert.Equal(t, 1000, s.BatchSize)
+		assert.Equal(t, 1100, s.BatchMaxSize, "max batch size follows the batch size")
+		assert.Equal(t, 409, s.QueueSize)
+		assert.Equal(t, 8, s.NumConsumers)
+	})
+
+	t.Run("batch max size override caps batch size", func(t *testing.T) {
+		s := ComputeSizing(HostResources{MemoryMiB: 1024, CPUs: 1}, schema.TuningConfig{BatchMaxSize: 1500})
+		assert.Equal(t, 1500, s.BatchSize)
+		assert.Equal(t, 1500, s.BatchMaxSize)
+	})
+
+	t.Run("spike limit override raises memory limit", func(t *testing.T) {
+		s := ComputeSizing(HostResources{MemoryMiB: 256, CPUs: 1}, schema.TuningConfig{SpikeLimitMiB: 100})
+		assert.Equal(t, 500, s.MemoryLimitMiB)
+		assert.Equal(t, 100, s.SpikeLimitMiB)
+	})
+}
+
+func TestDetectHostResources(t *testing.T) {
+	writeFile := func(t *testing.T, root, path, content string) {
+		t.Helper()
+		full := filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+	}
+	meminfo := "MemTotal:        8388608 kB\nMemFree:         4194304 kB\n"
+
+	t.Run("host memory", func(t *testing.T) {
+		root := t.TempDir()
+		writeFile(t, root, "proc/meminfo", meminfo)
+
+		resources := detectHostResources(root)
+		assert.Equal(t, 8192, resources.MemoryMiB)
+		assert.GreaterOrEqual(t, resources.CPUs, 1)
+	})
+
+	t.Run("cgroup v2 limits", func(t *testing.T) {
+		root := t.TempDir()
+		writeFile(t, root, "proc/meminfo", meminfo)
+		writeFile(t, root, "sys/fs/cgroup/memory.max", "1073741824\n")
+		writeFile(t, root, "sys/fs/cgroup/cpu.max", "100000 100000\n")
+
+		resources := detectHostResources(root)
+		assert.Equal(t, 1024, resources.MemoryMiB)
+		assert.Equal(t, 1, resources.CPUs)
+	})
+
+	t.Run("cgroup v2 without limits", func(t *testing.T) {
+		root := t.TempDir()
+		writeFile(t, root, "proc/meminfo", meminfo)
+		writeFile(t, root, "sys/fs/cgroup/memory.max", "max\n")
+		writeFile(t, root, "sys/fs/cgroup/cpu.max", "max 100000\n")
+
+		resources := detectHostResources(root)
+		assert.Equal(t, 8192, resources.MemoryMiB)
+	})
+
+	t.Run("cgroup v1 unlimited", func(t *testing.T) {
+		root := t.TempDir()
+		writeFile(t, root, "proc/meminfo", meminfo)
+		writeFile(t, root, "sys/fs/cgroup/memory/memory.limit_in_bytes", "9223372036854771712\n")
+
+		resources := detectHostResources(root)
+		assert.Equal(t, 8192, resources.MemoryMiB)
+	})
+
+	t.Run("nothing readable", func(t *testing.T) {
+		resources := detectHostResources(t.TempDir())
+		assert.Equal(t, 0, resources.MemoryMiB)
+	})
+}
+
+func TestGeneratorSizing(t *testing.T) {
+	config := &schema.Config{
+		Service: schema.ServiceConfig{Name: "test-service"},
+		Metrics: schema.MetricsConfig{Enabled: true, Interval: "60s"},
+		Processing: schema.ProcessingConfig{
+			Tuning: schema.TuningConfig{QueueSize: 1000},
+		},
+		Export: schema.ExportConfig{Endpoint: "https://otlp.nr-data.net"},
+	}
+
+	gen := NewGenerator(config)
+	gen.SetHostResources(HostResources{MemoryMiB: 1024, CPUs: 4})
+	otelConfig, err := gen.Generate()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"check_interval":  "1s",
+		"limit_mib":       256,
+		"spike_limit_mib": 51,
+	}, otelConfig.Processors["memory_limiter"])
+
+	batch := otelConfig.Processors["batch"].(map[string]interface{})
+	assert.Equal(t, 2048, batch["send_batch_size"])
+	assert.Equal(t, 2252, batch["send_batch_max_size"])
+
+	otlp := otelConfig.Exporters["otlp"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"enabled":       true,
+		"num_consumers": 4,
+		"queue_size":    1000,
+	}, otlp["sending_queue"])
+}
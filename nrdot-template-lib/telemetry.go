@@ -0,0 +1,60 @@
+package templatelib
+
+import "net"
+
+const (
+	// defaultInternalMetricsLevel is the level of the collector's own
+	// metrics when not configured
+	defaultInternalMetricsLevel = "detailed"
+
+	// defaultInternalMetricsAddress is where the collector exposes its own
+	// metrics when not configured: every interface, IPv4 and IPv6
+	defaultInternalMetricsAddress = ":8888"
+)
+
+// internalMetricsLevel returns the level of the collector's own metrics
+func (g *Generator) internalMetricsLevel() string {
+	if level := g.config.Telemetry.InternalMetrics.Level; level != "" {
+		return level
+	}
+	return defaultInternalMetricsLevel
+}
+
+// internalMetricsAddress returns the address the collector exposes its own
+// metrics on
+func (g *Generator) internalMetricsAddress() string {
+	if address := g.config.Telemetry.InternalMetrics.Address; address != "" {
+		return address
+	}
+	return defaultInternalMetricsAddress
+}
+
+// internalMetricsEnabled reports whether the collector exposes its own
+// metrics, which the prometheus receiver then scrapes
+func (g *Generator) internalMetricsEnabled() bool {
+	return g.internalMetricsLevel() != "none"
+}
+
+// internalMetricsScrapeTarget returns the host:port the prometheus receiver
+// scrapes the collector's own metrics from. An address listening on every
+// interface is scraped on localhost.
+func (g *Generator) internalMetricsScrapeTarget() string {
+	host, port, err := net.SplitHostPort(g.internalMetricsAddress())
+	if err != nil {
+		return "localhost:8888"
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// telemetryMetricsConfig returns service.telemetry.metrics: the level and,
+// unless disabled, the address of the collector's own metrics
+func (g *Generator) telemetryMetricsConfig() map[string]interface{} {
+	metrics := map[string]interface{}{"level": g.internalMetricsLevel()}
+	if g.internalMetricsEnabled() {
+		metrics["address"] = g.internalMetricsAddress()
+	}
+	return metrics
+}
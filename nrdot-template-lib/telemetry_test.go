@@ -0,0 +1,61 @@
+package templatelib
+
+import (
+	"testing"
+
+	"github.com/newrelic/nrdot-host/nrdot-schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratorInternalMetrics(t *testing.T) {
+	config := &schema.Config{
+		Service: schema.ServiceConfig{Name: "web-01"},
+		Metrics: schema.MetricsConfig{Enabled: true, Interval: "60s"},
+		Logging: schema.LoggingConfig{Level: "info"},
+	}
+
+	// Detailed metrics on every interface by default
+	otelConfig, err := NewGenerator(config).Generate()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"level": "detailed", "address": ":8888"}, otelConfig.Service.Telemetry["metrics"])
+
+	config.Telemetry.InternalMetrics = schema.InternalMetricsConfig{
+		Level:     "basic",
+		Address:   "[::]:9888",
+		Exemplars: true,
+	}
+	otelConfig, err = NewGenerator(config).Generate()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"level": "basic", "address": "[::]:9888"}, otelConfig.Service.Telemetry["metrics"])
+
+	prometheus := otelConfig.Receivers["prometheus"].(map[string]interface{})
+	scrapeConfigs := prometheus["config"].(map[string]interface{})["scrape_configs"].([]map[string]interface{})
+	require.Len(t, scrapeConfigs, 1)
+	assert.Equal(t, []map[string]interface{}{{"targets": []string{"localhost:9888"}}}, scrapeConfigs[0]["static_configs"])
+	assert.Equal(t, []string{"OpenMetricsText1.0.0", "OpenMetricsText0.0.1", "PrometheusText0.0.4"}, scrapeConfigs[0]["scrape_protocols"])
+
+	// Disabled metrics are neither exposed nor scraped
+	config.Telemetry.InternalMetrics = schema.InternalMetricsConfig{Level: "none"}
+	otelConfig, err = NewGenerator(config).Generate()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"level": "none"}, otelConfig.Service.Telemetry["metrics"])
+	assert.NotContains(t, otelConfig.Receivers, "prometheus")
+	assert.Equal(t, []string{"hostmetrics"}, otelConfig.Service.Pipelines["metrics"].Receivers)
+}
+
+func TestInternalMetricsScrapeTarget(t *testing.T) {
+	for address, want := range map[string]string{
+		"":               "localhost:8888",
+		":9888":          "localhost:9888",
+		"0.0.0.0:8888":   "localhost:8888",
+		"[::]:8888":      "localhost:8888",
+		"127.0.0.1:9888": "127.0.0.1:9888",
+		"[::1]:9888":     "[::1]:9888",
+	} {
+		g := NewGenerator(&schema.Config{Telemetry: schema.TelemetryConfig{
+			InternalMetrics: schema.InternalMetricsConfig{Address: address},
+		}})
+		assert.Equal(t, want, g.internalMetricsScrapeTarget(), address)
+	}
+}
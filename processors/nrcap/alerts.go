@@ -0,0 +1,168 @@
+package nrcap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+)
+
+const (
+	// AlertScopeGlobal marks alerts on the global cardinality limit
+	AlertScopeGlobal = "global"
+	// AlertScopeMetric marks alerts on a single metric's limit
+	AlertScopeMetric = "metric"
+
+	// alertEventName is the event.name attribute of emitted log records
+	alertEventName = "nrcap.cardinality_alert"
+
+	// defaultSinkTimeout bounds each alert delivery when no timeout is set
+	defaultSinkTimeout = 5 * time.Second
+)
+
+// CardinalityAlert describes a cardinality threshold breach
+type CardinalityAlert struct {
+	Scope            string    `json:"scope"`
+	Metric           string    `json:"metric,omitempty"`
+	Cardinality      int       `json:"cardinality"`
+	Limit            int       `json:"limit"`
+	ThresholdPercent int       `json:"threshold_percent"`
+	Strategy         Strategy  `json:"strategy,omitempty"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+// alertSink delivers alerts to an external system
+type alertSink interface {
+	name() string
+	send(ctx context.Context, alert CardinalityAlert) error
+}
+
+// newAlertSinks creates the sinks enabled in the config
+func newAlertSinks(cfg AlertsConfig) []alertSink {
+	var sinks []alertSink
+	if cfg.Webhook != nil {
+		sinks = append(sinks, &webhookSink{httpSink: newHTTPSink(cfg.Webhook)})
+	}
+	if cfg.LogEvents != nil {
+		sinks = append(sinks, &logEventSink{httpSink: newHTTPSink(cfg.LogEvents)})
+	}
+	return sinks
+}
+
+// httpSink posts payloads to a configured endpoint
+type httpSink struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// newHTTPSink creates an HTTP sink from its config
+func newHTTPSink(cfg *HTTPSinkConfig) httpSink {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultSinkTimeout
+	}
+	return httpSink{
+		endpoint: cfg.Endpoint,
+		headers:  cfg.Headers,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// post sends body to the endpoint and fails on non-2xx responses
+func (s *httpSink) post(ctx context.Context, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to %s: %w", s.endpoint, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status from %s: %s", s.endpoint, resp.Status)
+	}
+	return nil
+}
+
+// webhookSink posts alerts as JSON
+type webhookSink struct {
+	httpSink
+}
+
+func (s *webhookSink) name() string { return "webhook" }
+
+func (s *webhookSink) send(ctx context.Context, alert CardinalityAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+	return s.post(ctx, "application/json", body)
+}
+
+// logEventSink exports alerts as OTel log events over OTLP/HTTP
+type logEventSink struct {
+	httpSink
+}
+
+func (s *logEventSink) name() string { return "log_events" }
+
+func (s *logEventSink) send(ctx context.Context, alert CardinalityAlert) error {
+	body, err := plogotlp.NewExportRequestFromLogs(alertToLogs(alert)).MarshalProto()
+	if err != nil {
+		return fmt.Errorf("failed to marshal log event: %w", err)
+	}
+	return s.post(ctx, "application/x-protobuf", body)
+}
+
+// alertToLogs converts an alert into a single warning log record
+func alertToLogs(alert CardinalityAlert) plog.Logs {
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	sl := rl.ScopeLogs().AppendEmpty()
+	sl.Scope().SetName("nrcap")
+
+	record := sl.LogRecords().AppendEmpty()
+	record.SetTimestamp(pcommon.NewTimestampFromTime(alert.Timestamp))
+	record.SetObservedTimestamp(pcommon.NewTimestampFromTime(time.Now()))
+	record.SetSeverityNumber(plog.SeverityNumberWarn)
+	record.SetSeverityText("WARN")
+
+	if alert.Scope == AlertScopeGlobal {
+		record.Body().SetStr(fmt.Sprintf("Global cardinality %d exceeded %d%% of limit %d",
+			alert.Cardinality, alert.ThresholdPercent, alert.Limit))
+	} else {
+		record.Body().SetStr(fmt.Sprintf("Cardinality of %s %d exceeded %d%% of limit %d",
+			alert.Metric, alert.Cardinality, alert.ThresholdPercent, alert.Limit))
+	}
+
+	attrs := record.Attributes()
+	attrs.PutStr("event.name", alertEventName)
+	attrs.PutStr("nrcap.scope", alert.Scope)
+	if alert.Metric != "" {
+		attrs.PutStr("metric.name", alert.Metric)
+	}
+	attrs.PutInt("nrcap.cardinality", int64(alert.Cardinality))
+	attrs.PutInt("nrcap.limit", int64(alert.Limit))
+	attrs.PutInt("nrcap.threshold_percent", int64(alert.ThresholdPercent))
+	if alert.Strategy != "" {
+		attrs.PutStr("nrcap.strategy", string(alert.Strategy))
+	}
+
+	return logs
+}
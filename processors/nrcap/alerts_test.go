@@ -0,0 +1,234 @@
+package nrcap
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.uber.org/zap"
+)
+
+func TestCheckAlertsPerMetric(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.DefaultLimit = 10
+	cfg.AlertThreshold = 50
+	cfg.MetricLimits["orders_total"] = MetricLimit{Limit: 100}
+
+	limiter := NewCardinalityLimiter(cfg, zap.NewNop())
+	var alerts []CardinalityAlert
+	limiter.SetAlertHandler(func(alert CardinalityAlert) {
+		alerts = append(alerts, alert)
+	})
+
+	// 6 series is above 50% of the default limit but not of orders_total's
+	labels := []map[string]string{
+		{"id": "1"}, {"id": "2"}, {"id": "3"}, {"id": "4"}, {"id": "5"}, {"id": "6"},
+	}
+	_, err := limiter.ProcessMetrics(generateMetricsWithLabels("exploding_metric", labels))
+	require.NoError(t, err)
+	_, err = limiter.ProcessMetrics(generateMetricsWithLabels("orders_total", labels))
+	require.NoError(t, err)
+
+	require.Len(t, alerts, 1)
+	assert.Equal(t, AlertScopeMetric, alerts[0].Scope)
+	assert.Equal(t, "exploding_metric", alerts[0].Metric)
+	assert.Equal(t, 6, alerts[0].Cardinality)
+	assert.Equal(t, 10, alerts[0].Limit)
+	assert.Equal(t, 50, alerts[0].ThresholdPercent)
+	assert.Equal(t, StrategyDrop, alerts[0].Strategy)
+
+	// Repeats within the alert interval are suppressed
+	_, err = limiter.ProcessMetrics(generateMetricsWithLabels("exploding_metric", labels))
+	require.NoError(t, err)
+	assert.Len(t, alerts, 1)
+
+	// Reset clears suppression
+	limiter.Reset()
+	_, err = limiter.ProcessMetrics(generateMetricsWithLabels("exploding_metric", labels))
+	require.NoError(t, err)
+	assert.Len(t, alerts, 2)
+}
+
+func TestCheckAlertsGlobal(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.GlobalLimit = 4
+	cfg.DefaultLimit = 100
+	cfg.AlertThreshold = 50
+
+	limiter := NewCardinalityLimiter(cfg, zap.NewNop())
+	var alerts []CardinalityAlert
+	limiter.SetAlertHandler(func(alert CardinalityAlert) {
+		alerts = append(alerts, alert)
+	})
+
+	_, err := limiter.ProcessMetrics(generateMetricsWithLabels("test_metric", []map[string]string{
+		{"id": "1"}, {"id": "2"}, {"id": "3"},
+	}))
+	require.NoError(t, err)
+
+	require.Len(t, alerts, 1)
+	assert.Equal(t, AlertScopeGlobal, alerts[0].Scope)
+	assert.Empty(t, alerts[0].Metric)
+	assert.Equal(t, 4, alerts[0].Limit)
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received CardinalityAlert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sinks := newAlertSinks(AlertsConfig{Webhook: &HTTPSinkConfig{
+		Endpoint: server.URL,
+		Headers:  map[string]string{"X-Api-Key": "secret"},
+	}})
+	require.Len(t, sinks, 1)
+
+	alert := CardinalityAlert{
+		Scope:            AlertScopeMetric,
+		Metric:           "http_requests_total",
+		Cardinality:      9500,
+		Limit:            10000,
+		ThresholdPercent: 90,
+		Timestamp:        time.Now().UTC().Truncate(time.Second),
+	}
+	require.NoError(t, sinks[0].send(context.Background(), alert))
+	assert.Equal(t, alert, received)
+}
+
+func TestWebhookSinkErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sinks := newAlertSinks(AlertsConfig{Webhook: &HTTPSinkConfig{Endpoint: server.URL}})
+	assert.Error(t, sinks[0].send(context.Background(), CardinalityAlert{Scope: AlertScopeGlobal}))
+}
+
+func TestLogEventSink(t *testing.T) {
+	var received plog.Logs
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req := plogotlp.NewExportRequest()
+		require.NoError(t, req.UnmarshalProto(body))
+		received = req.Logs()
+	}))
+	defer server.Close()
+
+	sinks := newAlertSinks(AlertsConfig{LogEvents: &HTTPSinkConfig{Endpoint: server.URL}})
+	require.Len(t, sinks, 1)
+
+	require.NoError(t, sinks[0].send(context.Background(), CardinalityAlert{
+		Scope:            AlertScopeMetric,
+		Metric:           "http_requests_total",
+		Cardinality:      9500,
+		Limit:            10000,
+		ThresholdPercent: 90,
+		Timestamp:        time.Now(),
+	}))
+
+	require.Equal(t, 1, received.LogRecordCount())
+	record := received.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	assert.Equal(t, plog.SeverityNumberWarn, record.SeverityNumber())
+
+	attrs := record.Attributes()
+	name, _ := attrs.Get("event.name")
+	assert.Equal(t, alertEventName, name.Str())
+	metric, _ := attrs.Get("metric.name")
+	assert.Equal(t, "http_requests_total", metric.Str())
+	cardinality, _ := attrs.Get("nrcap.cardinality")
+	assert.Equal(t, int64(9500), cardinality.Int())
+	limit, _ := attrs.Get("nrcap.limit")
+	assert.Equal(t, int64(10000), limit.Int())
+}
+
+func TestCapProcessorDeliversAlerts(t *testing.T) {
+	var mu sync.Mutex
+	var received []CardinalityAlert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert CardinalityAlert
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&alert))
+		mu.Lock()
+		received = append(received, alert)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.DefaultLimit = 5
+	cfg.Alerts.Webhook = &HTTPSinkConfig{Endpoint: server.URL}
+
+	proc, err := newCapProcessor(cfg, zap.NewNop(), consumertest.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, proc.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, proc.ConsumeMetrics(context.Background(), generateMetrics("exploding_metric", 10)))
+
+	// Shutdown flushes queued alerts
+	require.NoError(t, proc.Shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, received, 1)
+	assert.Equal(t, "exploding_metric", received[0].Metric)
+	assert.Equal(t, 5, received[0].Limit)
+}
+
+func TestConfigUnmarshalAlerts(t *testing.T) {
+	conf := confmap.NewFromStringMap(map[string]any{
+		"alerts": map[string]any{
+			"interval": "1m",
+			"webhook": map[string]any{
+				"endpoint": "https://pager.example.com/hooks/nrcap",
+				"headers":  map[string]any{"Authorization": "Bearer token"},
+			},
+			"log_events": map[string]any{
+				"endpoint": "http://localhost:4318/v1/logs",
+				"timeout":  "2s",
+			},
+		},
+	})
+
+	cfg := createDefaultConfig().(*Config)
+	require.NoError(t, component.UnmarshalConfig(conf, cfg))
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, time.Minute, cfg.Alerts.Interval)
+	require.NotNil(t, cfg.Alerts.Webhook)
+	assert.Equal(t, "Bearer token", cfg.Alerts.Webhook.Headers["Authorization"])
+	require.NotNil(t, cfg.Alerts.LogEvents)
+	assert.Equal(t, 2*time.Second, cfg.Alerts.LogEvents.Timeout)
+}
+
+func TestConfigValidateAlerts(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Alerts.Webhook = &HTTPSinkConfig{Endpoint: "pager.example.com"}
+	assert.Error(t, cfg.Validate())
+
+	cfg.Alerts.Webhook = &HTTPSinkConfig{Endpoint: "https://pager.example.com", Timeout: -time.Second}
+	assert.Error(t, cfg.Validate())
+
+	cfg.Alerts.Webhook = nil
+	cfg.Alerts.Interval = 0
+	assert.Error(t, cfg.Validate())
+}
@@ -0,0 +1,141 @@
+package nrcap
+
+import (
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// defaultNewSeriesInterval is the admission window when new_series_interval
+// is unset
+const defaultNewSeriesInterval = time.Minute
+
+// seriesRateLimiter caps how many new series each metric may admit per
+// fixed interval. It bounds churn, such as label values rotating on pod
+// restarts, that stays under the cardinality limits while still causing
+// ingest spikes.
+type seriesRateLimiter struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	windows map[string]*admissionWindow
+}
+
+// admissionWindow counts the new series a metric admitted in one interval
+type admissionWindow struct {
+	start    time.Time
+	admitted int
+	rejected int
+}
+
+// newSeriesRateLimiter creates a limiter with the given admission interval
+func newSeriesRateLimiter(interval time.Duration) *seriesRateLimiter {
+	if interval <= 0 {
+		interval = defaultNewSeriesInterval
+	}
+	return &seriesRateLimiter{
+		interval: interval,
+		windows:  make(map[string]*admissionWindow),
+	}
+}
+
+// admit reports whether metricName may admit another new series at now
+// under limit, and whether this is the first rejection of the interval
+func (l *seriesRateLimiter) admit(metricName string, limit int, now time.Time) (admitted, firstRejection bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	window, exists := l.windows[metricName]
+	if !exists || now.Sub(window.start) >= l.interval {
+		window = &admissionWindow{start: now}
+		l.windows[metricName] = window
+	}
+	if window.admitted < limit {
+		window.admitted++
+		return true, false
+	}
+	window.rejected++
+	return false, window.rejected == 1
+}
+
+// prune forgets windows that ended before now
+func (l *seriesRateLimiter) prune(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for metricName, window := range l.windows {
+		if now.Sub(window.start) >= l.interval {
+			delete(l.windows, metricName)
+		}
+	}
+}
+
+// reset clears every window
+func (l *seriesRateLimiter) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.windows = make(map[string]*admissionWindow)
+}
+
+// admitNewSeries removes the data points of series the tracker has not seen
+// once the metric has admitted limit new series this interval. Rejected
+// series stay untracked, so they are admitted once a later interval has
+// room. Several data points of one new series in a batch count once.
+func (cl *CardinalityLimiter) admitNewSeries(metric pmetric.Metric, limit int) {
+	if limit <= 0 {
+		return
+	}
+
+	metricName := metric.Name()
+	now := cl.clock.Now()
+	admitted := make(map[uint64]bool)
+	keep := func(attrs pcommon.Map) bool {
+		labelHash := cl.tracker.hashDataPointLabels(metricName, attrs)
+		if ok, seen := admitted[labelHash]; seen {
+			return ok
+		}
+		if cl.tracker.Contains(labelHash) {
+			admitted[labelHash] = true
+			return true
+		}
+
+		ok, firstRejection := cl.churn.admit(metricName, limit, now)
+		admitted[labelHash] = ok
+		if firstRejection {
+			cl.logger.Warn("New series rate limit reached, dropping further new series",
+				zap.String("metric", metricName),
+				zap.Int("new_series_limit", limit),
+				zap.Duration("interval", cl.churn.interval))
+		}
+		if !ok {
+			cl.tracker.IncrementStats("churn_dropped")
+		}
+		return ok
+	}
+
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		metric.Gauge().DataPoints().RemoveIf(func(dp pmetric.NumberDataPoint) bool {
+			return !keep(dp.Attributes())
+		})
+	case pmetric.MetricTypeSum:
+		metric.Sum().DataPoints().RemoveIf(func(dp pmetric.NumberDataPoint) bool {
+			return !keep(dp.Attributes())
+		})
+	case pmetric.MetricTypeHistogram:
+		metric.Histogram().DataPoints().RemoveIf(func(dp pmetric.HistogramDataPoint) bool {
+			return !keep(dp.Attributes())
+		})
+	case pmetric.MetricTypeSummary:
+		metric.Summary().DataPoints().RemoveIf(func(dp pmetric.SummaryDataPoint) bool {
+			return !keep(dp.Attributes())
+		})
+	case pmetric.MetricTypeExponentialHistogram:
+		metric.ExponentialHistogram().DataPoints().RemoveIf(func(dp pmetric.ExponentialHistogramDataPoint) bool {
+			return !keep(dp.Attributes())
+		})
+	}
+}
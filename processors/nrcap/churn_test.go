@@ -0,0 +1,149 @@
+package nrcap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+	"go.uber.org/zap"
+)
+
+func TestSeriesRateLimiterAdmit(t *testing.T) {
+	limiter := newSeriesRateLimiter(0)
+	assert.Equal(t, time.Minute, limiter.interval)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		admitted, _ := limiter.admit("pods", 3, start)
+		assert.True(t, admitted)
+	}
+	admitted, first := limiter.admit("pods", 3, start.Add(time.Second))
+	assert.False(t, admitted)
+	assert.True(t, first)
+	admitted, first = limiter.admit("pods", 3, start.Add(2*time.Second))
+	assert.False(t, admitted)
+	assert.False(t, first)
+
+	// Metrics have separate budgets
+	admitted, _ = limiter.admit("nodes", 3, start)
+	assert.True(t, admitted)
+
+	// The next interval starts a new budget
+	admitted, _ = limiter.admit("pods", 3, start.Add(time.Minute))
+	assert.True(t, admitted)
+
+	limiter.prune(start.Add(time.Minute + time.Second))
+	assert.NotContains(t, limiter.windows, "nodes")
+	assert.Contains(t, limiter.windows, "pods")
+}
+
+func TestProcessMetricsNewSeriesLimit(t *testing.T) {
+	cfg := &Config{
+		GlobalLimit:    100,
+		DefaultLimit:   100,
+		Strategy:       StrategyDrop,
+		WindowSize:     5 * time.Minute,
+		ResetInterval:  time.Hour,
+		NewSeriesLimit: 2,
+	}
+	limiter := NewCardinalityLimiter(cfg, zap.NewNop())
+
+	pods := func(names ...string) []map[string]string {
+		labels := make([]map[string]string, len(names))
+		for i, name := range names {
+			labels[i] = map[string]string{"pod": name}
+		}
+		return labels
+	}
+
+	// A burst of new series is admitted up to the limit, well under the
+	// cardinality limit; repeated points of one series count once
+	result, err := limiter.ProcessMetrics(generateMetricsWithLabels("container_cpu", pods("a", "a", "b", "c", "d", "e")))
+	require.NoError(t, err)
+	assert.Equal(t, 3, countDataPoints(result))
+	assert.Equal(t, 2, limiter.tracker.GetCardinality("container_cpu"))
+	assert.Equal(t, int64(3), limiter.GetStats().ChurnDroppedMetrics)
+
+	// Admitted series keep flowing, rejected ones wait for the next interval
+	result, err = limiter.ProcessMetrics(generateMetricsWithLabels("container_cpu", pods("a", "b", "c")))
+	require.NoError(t, err)
+	assert.Equal(t, 2, countDataPoints(result))
+
+	limiter.churn.windows["container_cpu"].start = time.Now().Add(-time.Minute)
+	result, err = limiter.ProcessMetrics(generateMetricsWithLabels("container_cpu", pods("a", "b", "c", "d", "e")))
+	require.NoError(t, err)
+	assert.Equal(t, 4, countDataPoints(result))
+	assert.Equal(t, 4, limiter.tracker.GetCardinality("container_cpu"))
+
+	// A metric whose every point is rejected is left out
+	limiter.churn.windows["other"] = &admissionWindow{start: time.Now(), admitted: 2}
+	result, err = limiter.ProcessMetrics(generateMetricsWithLabels("other", pods("x")))
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().Len())
+}
+
+func TestProcessMetricsNewSeriesLimitAggregate(t *testing.T) {
+	cfg := &Config{
+		GlobalLimit:       100,
+		DefaultLimit:      100,
+		Strategy:          StrategyAggregate,
+		AggregationLabels: []string{"service"},
+		WindowSize:        5 * time.Minute,
+		ResetInterval:     time.Hour,
+		NewSeriesLimit:    1,
+	}
+	limiter := NewCardinalityLimiter(cfg, zap.NewNop())
+
+	// New series are counted after aggregation, so churning trace IDs of
+	// a known service are not new
+	labels := []map[string]string{
+		{"service": "api", "trace_id": "1"},
+		{"service": "api", "trace_id": "2"},
+		{"service": "web", "trace_id": "3"},
+	}
+	result, err := limiter.ProcessMetrics(generateMetricsWithLabels("requests", labels))
+	require.NoError(t, err)
+	assert.Equal(t, 2, countDataPoints(result))
+	assert.Equal(t, 1, limiter.tracker.GetCardinality("requests"))
+
+	labels = []map[string]string{
+		{"service": "api", "trace_id": "4"},
+	}
+	result, err = limiter.ProcessMetrics(generateMetricsWithLabels("requests", labels))
+	require.NoError(t, err)
+	assert.Equal(t, 1, countDataPoints(result))
+}
+
+func TestConfigNewSeriesLimit(t *testing.T) {
+	conf := confmap.NewFromStringMap(map[string]any{
+		"new_series_limit":    100,
+		"new_series_interval": "30s",
+		"metric_limits": map[string]any{
+			"k8s.pod.phase": map[string]any{
+				"limit":            5000,
+				"new_series_limit": 500,
+			},
+		},
+	})
+
+	cfg := createDefaultConfig().(*Config)
+	require.NoError(t, component.UnmarshalConfig(conf, cfg))
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, 30*time.Second, cfg.NewSeriesInterval)
+	assert.Equal(t, 500, cfg.metricLimit("k8s.pod.phase").NewSeriesLimit)
+	assert.Equal(t, 100, cfg.metricLimit("other").NewSeriesLimit)
+
+	for name, mutate := range map[string]func(*Config){
+		"negative limit":      func(c *Config) { c.NewSeriesLimit = -1 },
+		"negative interval":   func(c *Config) { c.NewSeriesInterval = -time.Second },
+		"negative per metric": func(c *Config) { c.MetricLimits["noisy"] = MetricLimit{Limit: 10, NewSeriesLimit: -1} },
+	} {
+		cfg := createDefaultConfig().(*Config)
+		mutate(cfg)
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
@@ -2,9 +2,11 @@ package nrcap
 
 import (
 	"errors"
+	"net/url"
 	"time"
 
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
 )
 
 // Strategy defines the limiting strategy
@@ -21,13 +23,33 @@ const (
 	StrategyOldest Strategy = "oldest"
 )
 
+// LabelAction defines what happens to values of a label key over its limit
+type LabelAction string
+
+const (
+	// LabelActionDrop removes the label from data points with new values
+	LabelActionDrop LabelAction = "drop"
+	// LabelActionHash replaces new values with one of a fixed number of
+	// hash buckets
+	LabelActionHash LabelAction = "hash"
+)
+
+// defaultLabelHashBuckets is the number of hash buckets when buckets is unset
+const defaultLabelHashBuckets = 100
+
 // Config configures the cardinality protection processor
 type Config struct {
 	// GlobalLimit is the maximum total cardinality across all metrics
 	GlobalLimit int `mapstructure:"global_limit"`
 
-	// MetricLimits defines per-metric cardinality limits
-	MetricLimits map[string]int `mapstructure:"metric_limits"`
+	// MetricLimits defines per-metric cardinality limits. Entries are either a
+	// bare limit or a MetricLimit object overriding the strategy.
+	MetricLimits map[string]MetricLimit `mapstructure:"metric_limits"`
+
+	// LabelLimits caps the distinct values of label keys across all metrics.
+	// Entries are either a bare limit or a LabelLimit object choosing the
+	// action.
+	LabelLimits map[string]LabelLimit `mapstructure:"label_limits"`
 
 	// DefaultLimit is the default cardinality limit for unlisted metrics
 	DefaultLimit int `mapstructure:"default_limit"`
@@ -56,30 +78,218 @@ type Config struct {
 	// WindowSize for time-based cardinality windows
 	WindowSize time.Duration `mapstructure:"window_size"`
 
+	// NewSeriesLimit is the default number of new series a metric may admit
+	// per NewSeriesInterval, independent of its cardinality limit. 0 admits
+	// new series at any rate.
+	NewSeriesLimit int `mapstructure:"new_series_limit"`
+
+	// NewSeriesInterval is the window NewSeriesLimit applies to (default 1m)
+	NewSeriesInterval time.Duration `mapstructure:"new_series_interval"`
+
 	// AlertThreshold percentage (0-100) to trigger alerts
 	AlertThreshold int `mapstructure:"alert_threshold"`
+
+	// Alerts configures where threshold alerts are delivered
+	Alerts AlertsConfig `mapstructure:"alerts"`
+
+	// Report configures on-demand cardinality reports
+	Report ReportConfig `mapstructure:"report"`
+
+	// StatsServer serves live cardinality statistics over HTTP
+	StatsServer StatsServerConfig `mapstructure:"stats_server"`
+
+	// ScopeAttributes annotates the scope of each emitted metric with the
+	// metric's cardinality, limit and utilization, so backends can show its
+	// headroom without the stats endpoint. Metrics sharing a scope are split
+	// into a scope each.
+	ScopeAttributes bool `mapstructure:"scope_attributes"`
+
+	// Coordination shares GlobalLimit with the other nrcap instances of the
+	// host
+	Coordination CoordinationConfig `mapstructure:"coordination"`
+}
+
+// CoordinationConfig configures a global limit shared by the nrcap instances
+// of a host, such as the collectors of sharded pipelines. Instances using the
+// same socket count each other's series against their global limit instead
+// of each enforcing it in full. Counts are exchanged every Interval, so the
+// combined cardinality may overshoot by the series admitted in between.
+type CoordinationConfig struct {
+	// Socket is the Unix socket the instances coordinate over, e.g.
+	// /var/run/nrdot/nrcap.sock. Coordination is off when empty.
+	Socket string `mapstructure:"socket"`
+
+	// Instance identifies this instance to the others (default: the process
+	// ID and component ID)
+	Instance string `mapstructure:"instance"`
+
+	// Interval is how often counts are exchanged (default 5s)
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// StatsServerConfig configures the live statistics endpoint, which serves a
+// JSON snapshot of the top metrics and label keys by cardinality on GET
+// /stats, e.g. for nrdot-ctl top cardinality
+type StatsServerConfig struct {
+	// Endpoint is the address statistics are served on, e.g.
+	// localhost:13134. Statistics are not served when empty.
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// ReportConfig configures on-demand cardinality reports. A report breaks the
+// tracked series down by metric, label key and label value, and is written
+// to Directory each time the collector receives SIGUSR2.
+type ReportConfig struct {
+	// Directory reports are written to. Reports are off when empty; with
+	// them on, the tracker keeps the labels of every series.
+	Directory string `mapstructure:"directory"`
+
+	// TopValues is how many of the most common values are listed per label
+	// key (default 10)
+	TopValues int `mapstructure:"top_values"`
+
+	// MaxFiles is how many reports are kept in Directory, oldest removed
+	// first (default 10, 0 keeps all)
+	MaxFiles int `mapstructure:"max_files"`
+}
+
+// AlertsConfig configures cardinality alert delivery. Alerts are always
+// logged; the sinks below are optional.
+type AlertsConfig struct {
+	// Interval is the minimum time between repeated alerts for the same
+	// metric (or the global limit)
+	Interval time.Duration `mapstructure:"interval"`
+
+	// Webhook posts a JSON payload with the metric, current cardinality and
+	// limit to an HTTP endpoint
+	Webhook *HTTPSinkConfig `mapstructure:"webhook"`
+
+	// LogEvents exports alerts as OTel log records to an OTLP/HTTP logs
+	// endpoint, e.g. http://localhost:4318/v1/logs
+	LogEvents *HTTPSinkConfig `mapstructure:"log_events"`
+}
+
+// HTTPSinkConfig configures an HTTP alert sink
+type HTTPSinkConfig struct {
+	// Endpoint is the URL alerts are posted to
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Headers are added to every request, e.g. for authentication
+	Headers map[string]string `mapstructure:"headers"`
+
+	// Timeout bounds each delivery (default 5s)
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// MetricLimit configures cardinality handling for a single metric
+type MetricLimit struct {
+	// Limit is the cardinality limit for the metric
+	Limit int `mapstructure:"limit"`
+
+	// Strategy overrides the global strategy for the metric
+	Strategy Strategy `mapstructure:"strategy"`
+
+	// AggregationLabels overrides the global aggregation labels for the metric
+	AggregationLabels []string `mapstructure:"aggregation_labels"`
+
+	// NewSeriesLimit overrides the global new series limit for the metric
+	NewSeriesLimit int `mapstructure:"new_series_limit"`
+}
+
+// LabelLimit caps the distinct values of a label key across all metrics.
+// Once Limit values are known, data points with other values lose the label
+// or have its value hashed, so a single runaway key cannot inflate every
+// metric carrying it.
+type LabelLimit struct {
+	// Limit is the number of distinct values admitted per reset interval
+	Limit int `mapstructure:"limit"`
+
+	// Action applies to values over the limit: drop (default) or hash
+	Action LabelAction `mapstructure:"action"`
+
+	// Buckets is the number of values the hash action maps excess values
+	// to (default 100)
+	Buckets int `mapstructure:"buckets"`
+}
+
+// Unmarshal expands bare `metric: limit` entries in metric_limits, and
+// `label: limit` entries in label_limits, into objects before decoding
+func (cfg *Config) Unmarshal(conf *confmap.Conf) error {
+	raw := conf.ToStringMap()
+	for _, key := range []string{"metric_limits", "label_limits"} {
+		if limits, ok := raw[key].(map[string]any); ok {
+			for name, value := range limits {
+				if _, isObject := value.(map[string]any); !isObject && value != nil {
+					limits[name] = map[string]any{"limit": value}
+				}
+			}
+		}
+	}
+	return confmap.NewFromStringMap(raw).Unmarshal(cfg)
+}
+
+// metricLimit returns the effective limit, strategy, aggregation labels and
+// new series limit for a metric, filling unset overrides from the global
+// settings
+func (cfg *Config) metricLimit(metricName string) MetricLimit {
+	resolved := MetricLimit{
+		Limit:             cfg.DefaultLimit,
+		Strategy:          cfg.Strategy,
+		AggregationLabels: cfg.AggregationLabels,
+		NewSeriesLimit:    cfg.NewSeriesLimit,
+	}
+
+	override, exists := cfg.MetricLimits[metricName]
+	if !exists {
+		return resolved
+	}
+	if override.Limit > 0 {
+		resolved.Limit = override.Limit
+	}
+	if override.Strategy != "" {
+		resolved.Strategy = override.Strategy
+	}
+	if override.AggregationLabels != nil {
+		resolved.AggregationLabels = override.AggregationLabels
+	}
+	if override.NewSeriesLimit > 0 {
+		resolved.NewSeriesLimit = override.NewSeriesLimit
+	}
+	return resolved
 }
 
 // createDefaultConfig returns the default config
 func createDefaultConfig() component.Config {
 	return &Config{
-		GlobalLimit:    100000,
-		DefaultLimit:   1000,
-		Strategy:       StrategyDrop,
-		ResetInterval:  1 * time.Hour,
-		EnableStats:    true,
-		SampleRate:     0.1,
-		WindowSize:     5 * time.Minute,
-		AlertThreshold: 90,
-		MetricLimits:   make(map[string]int),
-		DenyLabels:     []string{},
-		AllowLabels:    []string{},
+		GlobalLimit:       100000,
+		DefaultLimit:      1000,
+		Strategy:          StrategyDrop,
+		ResetInterval:     1 * time.Hour,
+		EnableStats:       true,
+		SampleRate:        0.1,
+		WindowSize:        5 * time.Minute,
+		AlertThreshold:    90,
+		NewSeriesInterval: time.Minute,
+		MetricLimits:      make(map[string]MetricLimit),
+		LabelLimits:       make(map[string]LabelLimit),
+		DenyLabels:        []string{},
+		AllowLabels:       []string{},
 		AggregationLabels: []string{
 			"service",
 			"environment",
 			"host",
 			"region",
 		},
+		Alerts: AlertsConfig{
+			Interval: 5 * time.Minute,
+		},
+		Report: ReportConfig{
+			TopValues: 10,
+			MaxFiles:  10,
+		},
+		Coordination: CoordinationConfig{
+			Interval: 5 * time.Second,
+		},
 	}
 }
 
@@ -93,20 +303,41 @@ func (cfg *Config) Validate() error {
 		return errors.New("default_limit must be positive")
 	}
 
+	if !isValidStrategy(cfg.Strategy) {
+		return errors.New("invalid strategy: " + string(cfg.Strategy))
+	}
+
+	usesSampling := cfg.Strategy == StrategySample
 	for metric, limit := range cfg.MetricLimits {
-		if limit <= 0 {
+		if limit.Limit <= 0 {
 			return errors.New("metric limit for " + metric + " must be positive")
 		}
+		if limit.Strategy != "" && !isValidStrategy(limit.Strategy) {
+			return errors.New("invalid strategy for " + metric + ": " + string(limit.Strategy))
+		}
+		if limit.Strategy == StrategySample {
+			usesSampling = true
+		}
+		if limit.NewSeriesLimit < 0 {
+			return errors.New("new_series_limit for " + metric + " must not be negative")
+		}
 	}
 
-	switch cfg.Strategy {
-	case StrategyDrop, StrategyAggregate, StrategySample, StrategyOldest:
-		// valid strategies
-	default:
-		return errors.New("invalid strategy: " + string(cfg.Strategy))
+	for label, limit := range cfg.LabelLimits {
+		if limit.Limit <= 0 {
+			return errors.New("label limit for " + label + " must be positive")
+		}
+		switch limit.Action {
+		case "", LabelActionDrop, LabelActionHash:
+		default:
+			return errors.New("invalid action for label " + label + ": " + string(limit.Action))
+		}
+		if limit.Buckets < 0 {
+			return errors.New("buckets for label " + label + " must not be negative")
+		}
 	}
 
-	if cfg.Strategy == StrategySample {
+	if usesSampling {
 		if cfg.SampleRate <= 0 || cfg.SampleRate > 1 {
 			return errors.New("sample_rate must be between 0 and 1")
 		}
@@ -120,9 +351,68 @@ func (cfg *Config) Validate() error {
 		return errors.New("window_size must be positive")
 	}
 
+	if cfg.NewSeriesLimit < 0 {
+		return errors.New("new_series_limit must not be negative")
+	}
+
+	if cfg.NewSeriesInterval < 0 {
+		return errors.New("new_series_interval must not be negative")
+	}
+
 	if cfg.AlertThreshold < 0 || cfg.AlertThreshold > 100 {
 		return errors.New("alert_threshold must be between 0 and 100")
 	}
 
+	if cfg.Alerts.Interval <= 0 {
+		return errors.New("alerts.interval must be positive")
+	}
+
+	if err := cfg.Alerts.Webhook.validate("alerts.webhook"); err != nil {
+		return err
+	}
+
+	if err := cfg.Alerts.LogEvents.validate("alerts.log_events"); err != nil {
+		return err
+	}
+
+	if cfg.Report.Directory != "" && cfg.Report.TopValues <= 0 {
+		return errors.New("report.top_values must be positive")
+	}
+
+	if cfg.Report.MaxFiles < 0 {
+		return errors.New("report.max_files must not be negative")
+	}
+
+	if cfg.Coordination.Socket != "" && cfg.Coordination.Interval <= 0 {
+		return errors.New("coordination.interval must be positive")
+	}
+
+	return nil
+}
+
+// validate checks an optional HTTP sink; a nil sink is valid
+func (s *HTTPSinkConfig) validate(name string) error {
+	if s == nil {
+		return nil
+	}
+
+	u, err := url.Parse(s.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New(name + ".endpoint must be an http(s) URL")
+	}
+
+	if s.Timeout < 0 {
+		return errors.New(name + ".timeout must not be negative")
+	}
+
 	return nil
-}
\ No newline at end of file
+}
+
+// isValidStrategy reports whether s is a known limiting strategy
+func isValidStrategy(s Strategy) bool {
+	switch s {
+	case StrategyDrop, StrategyAggregate, StrategySample, StrategyOldest:
+		return true
+	}
+	return false
+}
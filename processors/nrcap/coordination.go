@@ -0,0 +1,325 @@
+package nrcap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
+	"go.uber.org/zap"
+)
+
+// coordinationIOTimeout bounds each exchange over the coordination socket
+const coordinationIOTimeout = 2 * time.Second
+
+// coordinationReport is sent by an instance each interval
+type coordinationReport struct {
+	Instance    string `json:"instance"`
+	Cardinality int    `json:"cardinality"`
+}
+
+// coordinationReply answers a report with the combined cardinality of the
+// other instances
+type coordinationReply struct {
+	Peers     int `json:"peers"`
+	Instances int `json:"instances"`
+}
+
+// peerReport is the last report of an instance, kept by the leader
+type peerReport struct {
+	cardinality int
+	seen        time.Time
+}
+
+// budgetCoordinator shares the global limit between the nrcap instances of a
+// host, e.g. the collectors of sharded pipelines, through a Unix socket.
+//
+// The first instance to listen on the socket leads: every interval the
+// others connect, report their tracked series and get back the series of
+// all other instances, which count against their global limit. When the
+// leader goes away the next instance to sync takes over the socket. Reports
+// older than three intervals are forgotten, so an instance that lost its
+// peers falls back to enforcing the global limit alone.
+type budgetCoordinator struct {
+	socket   string
+	instance string
+	interval time.Duration
+	local    func() int
+	logger   *zap.Logger
+	clock    clock.Clock
+
+	// Combined cardinality of the other instances
+	peers atomic.Int64
+
+	// Leader state: the listener, the socket file it created and the
+	// reports of the other instances
+	mu       sync.Mutex
+	listener net.Listener
+	owned    os.FileInfo
+	reports  map[string]peerReport
+
+	lastSync time.Time
+	stale    bool
+
+	stopCh   chan struct{}
+	syncDone chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newBudgetCoordinator creates a coordinator reporting local as the
+// cardinality of instance
+func newBudgetCoordinator(cfg CoordinationConfig, instance string, local func() int, logger *zap.Logger, clk clock.Clock) *budgetCoordinator {
+	if cfg.Instance != "" {
+		instance = cfg.Instance
+	}
+	return &budgetCoordinator{
+		socket:   cfg.Socket,
+		instance: instance,
+		interval: cfg.Interval,
+		local:    local,
+		logger:   logger,
+		clock:    clk,
+		reports:  make(map[string]peerReport),
+		stopCh:   make(chan struct{}),
+		syncDone: make(chan struct{}),
+	}
+}
+
+// start syncs once, so limits account for peers from the first batch, then
+// every interval
+func (c *budgetCoordinator) start() {
+	c.sync()
+
+	ticker := c.clock.NewTicker(c.interval)
+	go func() {
+		defer close(c.syncDone)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				c.sync()
+			case <-c.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops syncing and hands the socket over to the remaining instances
+func (c *budgetCoordinator) stop() {
+	close(c.stopCh)
+	// No sync may start leading after the listener is closed
+	<-c.syncDone
+	c.mu.Lock()
+	c.closeListenerLocked()
+	c.mu.Unlock()
+	c.wg.Wait()
+}
+
+// peerCardinality returns the combined cardinality of the other instances
+func (c *budgetCoordinator) peerCardinality() int {
+	return int(c.peers.Load())
+}
+
+// sync refreshes the peer cardinality, as leader from the reports received
+// and otherwise by reporting to the leader, taking over if there is none
+func (c *budgetCoordinator) sync() {
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	leading := c.listener != nil
+	if leading && !c.ownsSocketLocked() {
+		// Another instance replaced the socket; report to it instead
+		c.logger.Info("Cardinality coordination socket taken over, following",
+			zap.String("socket", c.socket))
+		c.closeListenerLocked()
+		leading = false
+	}
+	if leading {
+		c.peers.Store(int64(c.sumLocked("", now)))
+		c.lastSync = now
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+
+	reply, err := c.report()
+	if err == nil {
+		c.peers.Store(int64(reply.Peers))
+		c.lastSync = now
+		if c.stale {
+			c.logger.Info("Cardinality coordination restored", zap.Int("instances", reply.Instances))
+			c.stale = false
+		}
+		return
+	}
+
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+		err = c.lead(errors.Is(err, syscall.ECONNREFUSED))
+		if err == nil {
+			c.logger.Info("Leading cardinality coordination", zap.String("socket", c.socket))
+			c.mu.Lock()
+			c.peers.Store(int64(c.sumLocked("", now)))
+			c.mu.Unlock()
+			c.lastSync = now
+			c.stale = false
+			return
+		}
+	}
+
+	// Without a leader, peers are forgotten once their counts are stale
+	if now.Sub(c.lastSync) > c.staleAfter() && c.peers.Load() != 0 {
+		c.peers.Store(0)
+	}
+	if !c.stale {
+		c.logger.Warn("Cardinality coordination failed, enforcing the global limit locally",
+			zap.String("socket", c.socket),
+			zap.Error(err))
+		c.stale = true
+	}
+}
+
+// staleAfter is how long a report counts
+func (c *budgetCoordinator) staleAfter() time.Duration {
+	return 3 * c.interval
+}
+
+// report sends the local cardinality to the leader
+func (c *budgetCoordinator) report() (coordinationReply, error) {
+	var reply coordinationReply
+
+	conn, err := net.DialTimeout("unix", c.socket, coordinationIOTimeout)
+	if err != nil {
+		return reply, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(coordinationIOTimeout))
+
+	if err := json.NewEncoder(conn).Encode(coordinationReport{Instance: c.instance, Cardinality: c.local()}); err != nil {
+		return reply, fmt.Errorf("sending report: %w", err)
+	}
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&reply); err != nil {
+		return reply, fmt.Errorf("reading reply: %w", err)
+	}
+	return reply, nil
+}
+
+// lead listens on the socket, first removing it if it is left over from a
+// leader that died
+func (c *budgetCoordinator) lead(leftOver bool) error {
+	if leftOver {
+		if err := os.Remove(c.socket); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	listener, err := net.Listen("unix", c.socket)
+	if err != nil {
+		return err
+	}
+	// Only collectors of the same user or group may report
+	if err := os.Chmod(c.socket, 0660); err != nil {
+		listener.Close()
+		return err
+	}
+	owned, err := os.Stat(c.socket)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	c.mu.Lock()
+	c.listener = listener
+	c.owned = owned
+	c.reports = make(map[string]peerReport)
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go c.serve(listener)
+	return nil
+}
+
+// ownsSocketLocked reports whether the socket is still the one the listener
+// created. Two instances taking over at once can replace each other's
+// socket; the one that lost it follows the other.
+func (c *budgetCoordinator) ownsSocketLocked() bool {
+	info, err := os.Stat(c.socket)
+	return err == nil && os.SameFile(info, c.owned)
+}
+
+// closeListenerLocked stops leading. The socket is removed only while it is
+// still the listener's own.
+func (c *budgetCoordinator) closeListenerLocked() {
+	if c.listener == nil {
+		return
+	}
+	if unixListener, ok := c.listener.(*net.UnixListener); ok {
+		unixListener.SetUnlinkOnClose(c.ownsSocketLocked())
+	}
+	c.listener.Close()
+	c.listener = nil
+	c.owned = nil
+}
+
+// serve answers reports until the listener closes
+func (c *budgetCoordinator) serve(listener net.Listener) {
+	defer c.wg.Done()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		c.handle(conn)
+	}
+}
+
+// handle answers one report
+func (c *budgetCoordinator) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(coordinationIOTimeout))
+
+	var report coordinationReport
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&report); err != nil || report.Instance == "" {
+		c.logger.Debug("Ignoring invalid cardinality report", zap.Error(err))
+		return
+	}
+
+	now := c.clock.Now()
+	c.mu.Lock()
+	c.reports[report.Instance] = peerReport{cardinality: report.Cardinality, seen: now}
+	reply := coordinationReply{
+		Peers:     c.sumLocked(report.Instance, now),
+		Instances: len(c.reports) + 1,
+	}
+	c.peers.Store(int64(c.sumLocked("", now)))
+	c.mu.Unlock()
+
+	json.NewEncoder(conn).Encode(reply)
+}
+
+// sumLocked returns the cardinality of all instances but the one named
+// exclude ("" for the leader itself), dropping stale reports
+func (c *budgetCoordinator) sumLocked(exclude string, now time.Time) int {
+	total := 0
+	if exclude != "" {
+		total += c.local()
+	}
+	for instance, report := range c.reports {
+		if now.Sub(report.seen) > c.staleAfter() {
+			delete(c.reports, instance)
+			continue
+		}
+		if instance != exclude {
+			total += report.cardinality
+		}
+	}
+	return total
+}
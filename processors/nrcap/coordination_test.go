@@ -0,0 +1,165 @@
+package nrcap
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap"
+)
+
+// newCoordinatedLimiter creates a limiter whose coordinator is synced by the
+// test rather than a ticker
+func newCoordinatedLimiter(t *testing.T, socket, instance string, globalLimit int, clk clock.Clock) *CardinalityLimiter {
+	cfg := createDefaultConfig().(*Config)
+	cfg.GlobalLimit = globalLimit
+	cfg.Coordination.Socket = socket
+
+	limiter := newCardinalityLimiter(cfg, zap.NewNop(), clk)
+	limiter.coordinator = newBudgetCoordinator(cfg.Coordination, instance, limiter.tracker.GetGlobalCardinality, zap.NewNop(), clk)
+	t.Cleanup(func() {
+		c := limiter.coordinator
+		c.mu.Lock()
+		c.closeListenerLocked()
+		c.mu.Unlock()
+		c.wg.Wait()
+	})
+	return limiter
+}
+
+func seriesLabels(prefix string, n int) []map[string]string {
+	labels := make([]map[string]string, n)
+	for i := range labels {
+		labels[i] = map[string]string{"id": fmt.Sprintf("%s-%d", prefix, i)}
+	}
+	return labels
+}
+
+func TestCoordination_SharedGlobalLimit(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "nrcap.sock")
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	a := newCoordinatedLimiter(t, socket, "a", 5, clk)
+	b := newCoordinatedLimiter(t, socket, "b", 5, clk)
+
+	// The first instance to sync leads, the second reports to it
+	a.coordinator.sync()
+	b.coordinator.sync()
+	assert.NotNil(t, a.coordinator.listener)
+	assert.Nil(t, b.coordinator.listener)
+
+	_, err := a.ProcessMetrics(generateMetricsWithLabels("requests", seriesLabels("a", 3)))
+	require.NoError(t, err)
+	assert.Equal(t, 3, a.tracker.GetGlobalCardinality())
+
+	// b learns of a's series and only has the rest of the budget
+	b.coordinator.sync()
+	assert.Equal(t, 3, b.coordinator.peerCardinality())
+	output, err := b.ProcessMetrics(generateMetricsWithLabels("requests", seriesLabels("b", 4)))
+	require.NoError(t, err)
+	assert.Equal(t, 2, output.DataPointCount())
+
+	// And a of b's, once b reported
+	b.coordinator.sync()
+	a.coordinator.sync()
+	assert.Equal(t, b.tracker.GetGlobalCardinality(), a.coordinator.peerCardinality())
+	output, err = a.ProcessMetrics(generateMetricsWithLabels("requests", seriesLabels("a2", 1)))
+	require.NoError(t, err)
+	assert.Equal(t, 0, output.DataPointCount())
+
+	// Reports stop counting once stale
+	clk.Advance(a.coordinator.staleAfter() + time.Second)
+	a.coordinator.sync()
+	assert.Equal(t, 0, a.coordinator.peerCardinality())
+}
+
+func TestCoordination_Takeover(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "nrcap.sock")
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	// A socket left over by a leader that died is replaced
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	_, err = os.Stat(socket)
+	require.NoError(t, err)
+
+	a := newCoordinatedLimiter(t, socket, "a", 5, clk)
+	b := newCoordinatedLimiter(t, socket, "b", 5, clk)
+	a.coordinator.sync()
+	require.NotNil(t, a.coordinator.listener)
+
+	_, err = b.ProcessMetrics(generateMetricsWithLabels("requests", seriesLabels("b", 2)))
+	require.NoError(t, err)
+	b.coordinator.sync()
+	a.coordinator.sync()
+	assert.Equal(t, 2, a.coordinator.peerCardinality())
+
+	// When the leader stops, the next instance to sync takes over
+	a.coordinator.mu.Lock()
+	a.coordinator.closeListenerLocked()
+	a.coordinator.mu.Unlock()
+	_, err = os.Stat(socket)
+	assert.True(t, os.IsNotExist(err))
+
+	b.coordinator.sync()
+	assert.NotNil(t, b.coordinator.listener)
+	assert.Equal(t, 0, b.coordinator.peerCardinality())
+
+	// An instance whose socket was replaced follows the new leader
+	require.NoError(t, os.Remove(socket))
+	a.coordinator.sync()
+	require.NotNil(t, a.coordinator.listener)
+	b.coordinator.sync()
+	assert.Nil(t, b.coordinator.listener)
+	assert.Equal(t, 0, b.coordinator.peerCardinality())
+	_, err = os.Stat(socket)
+	assert.NoError(t, err, "the follower must not remove the leader's socket")
+}
+
+func TestCoordination_Processor(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "nrcap.sock")
+
+	newProc := func(id string) *capProcessor {
+		cfg := createDefaultConfig().(*Config)
+		cfg.Coordination.Socket = socket
+		require.NoError(t, cfg.Validate())
+		proc, err := newCapProcessor(cfg, zap.NewNop(), consumertest.NewNop())
+		require.NoError(t, err)
+		proc.id = id
+		require.NoError(t, proc.Start(context.Background(), componenttest.NewNopHost()))
+		return proc
+	}
+
+	a := newProc("nrcap/a")
+	b := newProc("nrcap/b")
+	assert.Equal(t, fmt.Sprintf("%d/nrcap/b", os.Getpid()), b.limiter.coordinator.instance)
+
+	require.NoError(t, a.ConsumeMetrics(context.Background(), generateMetricsWithLabels("requests", seriesLabels("a", 3))))
+	b.limiter.coordinator.sync()
+	assert.Equal(t, 3, b.limiter.LiveStats(10).PeerCardinality)
+
+	require.NoError(t, a.Shutdown(context.Background()))
+	require.NoError(t, b.Shutdown(context.Background()))
+	_, err := os.Stat(socket)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCoordinationConfig_Validate(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Coordination.Socket = "/var/run/nrdot/nrcap.sock"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Coordination.Interval = 0
+	assert.EqualError(t, cfg.Validate(), "coordination.interval must be positive")
+}
@@ -4,14 +4,19 @@
 // to prevent metric explosions that can cause performance issues and increased costs.
 //
 // Features:
-//   - Per-metric cardinality limits
+//   - Per-metric cardinality limits and strategy overrides
 //   - Global cardinality limit enforcement
+//   - New series rate limiting to bound churn under the cardinality limits
+//   - Per-label-key value limits that drop or hash the values of runaway keys
 //   - Multiple limiting strategies (drop, aggregate, sample, oldest)
 //   - High-cardinality label detection and filtering
 //   - Time-based cardinality windows
 //   - Memory-efficient tracking using xxhash
+//   - Lock-striped tracker shards so concurrent pipelines scale across cores
 //   - Configurable reset intervals
 //   - Cardinality statistics reporting
+//   - Threshold alerts delivered to a webhook and as OTel log events
+//   - Global limit shared with the other collectors of a host over a Unix socket
 //
 // Limiting Strategies:
 //   - drop: Drop new metrics that exceed cardinality limit
@@ -27,6 +32,10 @@
 //	    metric_limits:
 //	      http_requests_total: 10000
 //	      db_connections: 5000
+//	      orders_total:
+//	        limit: 5000
+//	        strategy: aggregate
+//	        aggregation_labels: [region]
 //	    default_limit: 1000
 //	    strategy: drop
 //	    deny_labels:
@@ -47,6 +47,7 @@ func createMetricsProcessor(
 	if err != nil {
 		return nil, err
 	}
+	proc.id = set.ID.String()
 
 	return proc, nil
 }
\ No newline at end of file
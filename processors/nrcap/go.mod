@@ -4,9 +4,11 @@ go 1.21
 
 require (
 	github.com/cespare/xxhash/v2 v2.2.0
+	github.com/newrelic/nrdot-host/nrdot-common v0.0.0-00010101000000-000000000000
 	github.com/newrelic/nrdot-host/otel-processor-common v0.0.0-00010101000000-000000000000
 	github.com/stretchr/testify v1.9.0
 	go.opentelemetry.io/collector/component v0.96.0
+	go.opentelemetry.io/collector/confmap v0.96.0
 	go.opentelemetry.io/collector/consumer v0.96.0
 	go.opentelemetry.io/collector/pdata v1.3.0
 	go.opentelemetry.io/collector/processor v0.96.0
@@ -36,7 +38,6 @@ require (
 	github.com/prometheus/procfs v0.12.0 // indirect
 	go.opentelemetry.io/collector v0.96.0 // indirect
 	go.opentelemetry.io/collector/config/configtelemetry v0.96.0 // indirect
-	go.opentelemetry.io/collector/confmap v0.96.0 // indirect
 	go.opentelemetry.io/otel v1.24.0 // indirect
 	go.opentelemetry.io/otel/exporters/prometheus v0.46.0 // indirect
 	go.opentelemetry.io/otel/metric v1.24.0 // indirect
@@ -54,3 +55,5 @@ require (
 )
 
 replace github.com/newrelic/nrdot-host/otel-processor-common => ../otel-processor-common
+
+replace github.com/newrelic/nrdot-host/nrdot-common => ../../nrdot-common
@@ -0,0 +1,166 @@
+package nrcap
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// labelOverflowPrefix starts the values the hash action replaces excess
+// values with, e.g. overflow-17
+const labelOverflowPrefix = "overflow-"
+
+// labelValueLimiter caps the distinct values of label keys across all
+// metrics. The first Limit values of a key seen since the last reset are
+// admitted; later values are dropped or hashed. Per-metric limits miss a
+// single runaway key, such as a container ID, spread over many metrics that
+// each stay under their limit.
+type labelValueLimiter struct {
+	limits map[string]LabelLimit
+
+	mu       sync.Mutex
+	admitted map[string]map[string]struct{}
+	limited  map[string]bool
+}
+
+// newLabelValueLimiter creates a limiter for the configured label limits
+func newLabelValueLimiter(limits map[string]LabelLimit) *labelValueLimiter {
+	return &labelValueLimiter{
+		limits:   limits,
+		admitted: make(map[string]map[string]struct{}),
+		limited:  make(map[string]bool),
+	}
+}
+
+// admit reports whether value is admitted for label under limit, and
+// whether this is the first value rejected since the last reset.
+// Must be called with mu held.
+func (l *labelValueLimiter) admit(label, value string, limit int) (admitted, firstRejection bool) {
+	values, exists := l.admitted[label]
+	if !exists {
+		values = make(map[string]struct{})
+		l.admitted[label] = values
+	}
+	if _, known := values[value]; known {
+		return true, false
+	}
+	if len(values) < limit {
+		values[value] = struct{}{}
+		return true, false
+	}
+	first := !l.limited[label]
+	l.limited[label] = true
+	return false, first
+}
+
+// reset forgets every admitted value
+func (l *labelValueLimiter) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.admitted = make(map[string]map[string]struct{})
+	l.limited = make(map[string]bool)
+}
+
+// hashLabelValue maps a value to one of buckets overflow values
+func hashLabelValue(value string, buckets int) string {
+	if buckets <= 0 {
+		buckets = defaultLabelHashBuckets
+	}
+	return labelOverflowPrefix + strconv.FormatUint(xxhash.Sum64String(value)%uint64(buckets), 10)
+}
+
+// applyLabelLimits drops or hashes the values of limited label keys beyond
+// their limits, before series are tracked, so every metric counts the
+// limited values
+func (cl *CardinalityLimiter) applyLabelLimits(metrics pmetric.Metrics) {
+	if len(cl.labelLimits.limits) == 0 {
+		return
+	}
+
+	cl.labelLimits.mu.Lock()
+	defer cl.labelLimits.mu.Unlock()
+
+	limitAttributes := func(attrs pcommon.Map) {
+		limited := false
+		for label, limit := range cl.labelLimits.limits {
+			v, exists := attrs.Get(label)
+			if !exists {
+				continue
+			}
+			admitted, firstRejection := cl.labelLimits.admit(label, v.AsString(), limit.Limit)
+			if admitted {
+				continue
+			}
+			if firstRejection {
+				cl.logger.Warn("Label value limit reached, limiting further values",
+					zap.String("label", label),
+					zap.Int("limit", limit.Limit),
+					zap.String("action", string(limit.labelAction())))
+			}
+			if limit.labelAction() == LabelActionHash {
+				attrs.PutStr(label, hashLabelValue(v.AsString(), limit.Buckets))
+			} else {
+				attrs.Remove(label)
+			}
+			limited = true
+		}
+		if limited {
+			cl.tracker.IncrementStats("label_limited")
+		}
+	}
+
+	resourceMetrics := metrics.ResourceMetrics()
+	for i := 0; i < resourceMetrics.Len(); i++ {
+		scopeMetrics := resourceMetrics.At(i).ScopeMetrics()
+		for j := 0; j < scopeMetrics.Len(); j++ {
+			ms := scopeMetrics.At(j).Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				forEachAttributes(ms.At(k), limitAttributes)
+			}
+		}
+	}
+}
+
+// labelAction returns the action of the limit, drop when unset
+func (l LabelLimit) labelAction() LabelAction {
+	if l.Action == "" {
+		return LabelActionDrop
+	}
+	return l.Action
+}
+
+// forEachAttributes calls fn with the attributes of every data point of a
+// metric
+func forEachAttributes(metric pmetric.Metric, fn func(pcommon.Map)) {
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		dps := metric.Gauge().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	case pmetric.MetricTypeSum:
+		dps := metric.Sum().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	case pmetric.MetricTypeHistogram:
+		dps := metric.Histogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	case pmetric.MetricTypeSummary:
+		dps := metric.Summary().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		dps := metric.ExponentialHistogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	}
+}
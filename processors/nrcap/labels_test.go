@@ -0,0 +1,140 @@
+package nrcap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+func labelLimitConfig(limits map[string]LabelLimit) *Config {
+	return &Config{
+		GlobalLimit:   1000,
+		DefaultLimit:  1000,
+		Strategy:      StrategyDrop,
+		WindowSize:    5 * time.Minute,
+		ResetInterval: time.Hour,
+		LabelLimits:   limits,
+	}
+}
+
+// containerValues returns the container_id of every data point, "" where
+// the label is missing
+func containerValues(metrics pmetric.Metrics) []string {
+	var values []string
+	rms := metrics.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			ms := sms.At(j).Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				dps := ms.At(k).Gauge().DataPoints()
+				for d := 0; d < dps.Len(); d++ {
+					v, _ := dps.At(d).Attributes().Get("container_id")
+					values = append(values, v.Str())
+				}
+			}
+		}
+	}
+	return values
+}
+
+func containers(ids ...string) []map[string]string {
+	labels := make([]map[string]string, len(ids))
+	for i, id := range ids {
+		labels[i] = map[string]string{"container_id": id, "node": "n1"}
+	}
+	return labels
+}
+
+func TestProcessMetricsLabelLimitDrop(t *testing.T) {
+	limiter := NewCardinalityLimiter(labelLimitConfig(map[string]LabelLimit{
+		"container_id": {Limit: 2},
+	}), zap.NewNop())
+
+	result, err := limiter.ProcessMetrics(generateMetricsWithLabels("container_cpu", containers("a", "b", "c")))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", ""}, containerValues(result))
+
+	// The limit spans metrics: a known value passes, a new one loses the
+	// label everywhere
+	result, err = limiter.ProcessMetrics(generateMetricsWithLabels("container_memory", containers("b", "d")))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", ""}, containerValues(result))
+	assert.Equal(t, int64(2), limiter.GetStats().LabelLimitedMetrics)
+	assert.Equal(t, 2, limiter.GetStats().HighCardinalityLabels["container_id"])
+
+	// Other labels are untouched
+	var node string
+	dp := result.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().At(1)
+	if v, ok := dp.Attributes().Get("node"); ok {
+		node = v.Str()
+	}
+	assert.Equal(t, "n1", node)
+
+	// A reset admits new values again
+	limiter.Reset()
+	result, err = limiter.ProcessMetrics(generateMetricsWithLabels("container_cpu", containers("d")))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d"}, containerValues(result))
+}
+
+func TestProcessMetricsLabelLimitHash(t *testing.T) {
+	limiter := NewCardinalityLimiter(labelLimitConfig(map[string]LabelLimit{
+		"container_id": {Limit: 1, Action: LabelActionHash, Buckets: 4},
+	}), zap.NewNop())
+
+	result, err := limiter.ProcessMetrics(generateMetricsWithLabels("container_cpu",
+		containers("a", "b", "c", "d", "e", "f", "g", "h", "i", "j")))
+	require.NoError(t, err)
+
+	values := containerValues(result)
+	assert.Equal(t, "a", values[0])
+	distinct := make(map[string]struct{})
+	for _, value := range values[1:] {
+		assert.Contains(t, value, labelOverflowPrefix)
+		distinct[value] = struct{}{}
+	}
+	assert.LessOrEqual(t, len(distinct), 4)
+
+	// Values hash to the same bucket every time
+	assert.Equal(t, hashLabelValue("b", 4), values[1])
+	assert.Equal(t, int64(9), limiter.GetStats().LabelLimitedMetrics)
+	assert.LessOrEqual(t, limiter.tracker.GetCardinality("container_cpu"), 5)
+}
+
+func TestConfigLabelLimits(t *testing.T) {
+	conf := confmap.NewFromStringMap(map[string]any{
+		"label_limits": map[string]any{
+			"container_id": 20000,
+			"user_agent": map[string]any{
+				"limit":   100,
+				"action":  "hash",
+				"buckets": 50,
+			},
+		},
+	})
+
+	cfg := createDefaultConfig().(*Config)
+	require.NoError(t, component.UnmarshalConfig(conf, cfg))
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, LabelLimit{Limit: 20000}, cfg.LabelLimits["container_id"])
+	assert.Equal(t, LabelActionDrop, cfg.LabelLimits["container_id"].labelAction())
+	assert.Equal(t, LabelLimit{Limit: 100, Action: LabelActionHash, Buckets: 50}, cfg.LabelLimits["user_agent"])
+
+	for name, limit := range map[string]LabelLimit{
+		"zero limit":       {},
+		"unknown action":   {Limit: 10, Action: "truncate"},
+		"negative buckets": {Limit: 10, Action: LabelActionHash, Buckets: -1},
+	} {
+		cfg := createDefaultConfig().(*Config)
+		cfg.LabelLimits["pod"] = limit
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
@@ -3,8 +3,11 @@ package nrcap
 import (
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/cespare/xxhash/v2"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.uber.org/zap"
@@ -16,40 +19,80 @@ type CardinalityLimiter struct {
 	tracker *CardinalityTracker
 	logger  *zap.Logger
 
-	// Label value tracking for high cardinality detection
-	labelCardinality map[string]map[string]struct{}
-	labelMutex       sync.RWMutex
+	// Label value tracking for high cardinality detection, striped by label key
+	labelCardinality [shardCount]labelShard
+
+	// Expired-entry cleanup is throttled so concurrent batches don't all
+	// sweep every tracker shard
+	cleanupInterval time.Duration
+	lastCleanup     atomic.Int64
+
+	// New series admission per metric and interval
+	churn *seriesRateLimiter
+
+	// Distinct values admitted per limited label key
+	labelLimits *labelValueLimiter
 
 	// Random source for sampling
 	rand *rand.Rand
 
+	// Time source for cleanup, admission intervals and alerts
+	clock clock.Clock
+
+	// Other instances sharing the global limit, nil without coordination
+	coordinator *budgetCoordinator
+
 	// Alert tracking
-	alertsSent map[string]time.Time
-	alertMutex sync.Mutex
+	alertsSent   map[string]time.Time
+	alertMutex   sync.Mutex
+	alertHandler func(CardinalityAlert)
+}
+
+// labelShard holds the unique values seen for a subset of label keys
+type labelShard struct {
+	mu     sync.Mutex
+	values map[string]map[string]struct{}
 }
 
 // NewCardinalityLimiter creates a new cardinality limiter
 func NewCardinalityLimiter(cfg *Config, logger *zap.Logger) *CardinalityLimiter {
-	return &CardinalityLimiter{
-		config:           cfg,
-		tracker:          NewCardinalityTracker(cfg.WindowSize),
-		logger:           logger,
-		labelCardinality: make(map[string]map[string]struct{}),
-		rand:             rand.New(rand.NewSource(time.Now().UnixNano())),
-		alertsSent:       make(map[string]time.Time),
+	return newCardinalityLimiter(cfg, logger, clock.Real())
+}
+
+// newCardinalityLimiter creates a cardinality limiter on the given clock
+func newCardinalityLimiter(cfg *Config, logger *zap.Logger, clk clock.Clock) *CardinalityLimiter {
+	cl := &CardinalityLimiter{
+		config:          cfg,
+		tracker:         newCardinalityTracker(cfg.WindowSize, clk),
+		logger:          logger,
+		cleanupInterval: cfg.WindowSize / 10,
+		churn:           newSeriesRateLimiter(cfg.NewSeriesInterval),
+		labelLimits:     newLabelValueLimiter(cfg.LabelLimits),
+		rand:            rand.New(rand.NewSource(time.Now().UnixNano())),
+		clock:           clk,
+		alertsSent:      make(map[string]time.Time),
+	}
+	for i := range cl.labelCardinality {
+		cl.labelCardinality[i].values = make(map[string]map[string]struct{})
 	}
+	cl.tracker.keepLabels = cfg.Report.Directory != ""
+	return cl
 }
 
 // ProcessMetrics applies cardinality limits to metrics
 func (cl *CardinalityLimiter) ProcessMetrics(metrics pmetric.Metrics) (pmetric.Metrics, error) {
 	// First, remove deny labels from all metrics
 	cl.removeDenyLabelsFromMetrics(metrics)
+
+	// Then cap the values of limited label keys
+	cl.applyLabelLimits(metrics)
 	
 	// Track high cardinality labels
 	cl.trackLabelCardinality(metrics)
 
 	// Create output metrics
 	output := pmetric.NewMetrics()
+	metricNames := make(map[string]struct{})
 	
 	resourceMetrics := metrics.ResourceMetrics()
 	for i := 0; i < resourceMetrics.Len(); i++ {
@@ -67,32 +110,62 @@ func (cl *CardinalityLimiter) ProcessMetrics(metrics pmetric.Metrics) (pmetric.M
 			for k := 0; k < metrics.Len(); k++ {
 				metric := metrics.At(k)
 				cl.processMetric(metric, outputSM.Metrics())
+				metricNames[metric.Name()] = struct{}{}
 			}
 		}
+
+		if cl.config.ScopeAttributes {
+			cl.annotateScopes(outputRM)
+		}
 	}
 
 	// Periodic cleanup
-	cl.tracker.CleanupOldEntries()
+	cl.maybeCleanup()
 
 	// Check for alerts
-	cl.checkAlerts()
+	cl.checkAlerts(metricNames)
 
 	return output, nil
 }
 
+// maybeCleanup removes expired tracker entries at most once per cleanup interval
+func (cl *CardinalityLimiter) maybeCleanup() {
+	now := cl.clock.Now().UnixNano()
+	last := cl.lastCleanup.Load()
+	if now-last < int64(cl.cleanupInterval) {
+		return
+	}
+	// Only the goroutine that wins the swap performs the sweep
+	if !cl.lastCleanup.CompareAndSwap(last, now) {
+		return
+	}
+	cl.tracker.CleanupOldEntries()
+	cl.churn.prune(time.Unix(0, now))
+}
+
 // processMetric processes a single metric
 func (cl *CardinalityLimiter) processMetric(metric pmetric.Metric, output pmetric.MetricSlice) {
 	metricName := metric.Name()
 	
-	// Get limit for this metric
-	limit := cl.getMetricLimit(metricName)
+	// Get limit and strategy for this metric
+	policy := cl.config.metricLimit(metricName)
+	limit := policy.Limit
 	
+	// Admit new series at the configured rate; aggregated series are only
+	// known once labels are removed
+	if policy.Strategy != StrategyAggregate {
+		cl.admitNewSeries(metric, policy.NewSeriesLimit)
+		if cl.getDataPointCount(metric) == 0 {
+			return
+		}
+	}
+
 	// Apply limiting strategy
-	switch cl.config.Strategy {
+	switch policy.Strategy {
 	case StrategyDrop:
 		cl.handleDrop(metric, output, limit)
 	case StrategyAggregate:
-		cl.handleAggregate(metric, output, limit)
+		cl.handleAggregate(metric, output, limit, policy.AggregationLabels, policy.NewSeriesLimit)
 	case StrategySample:
 		cl.handleSample(metric, output, limit)
 	case StrategyOldest:
@@ -151,7 +224,7 @@ func (cl *CardinalityLimiter) filterDataPoints(metric pmetric.Metric, dps pmetri
 		
 		// Check current cardinality before tracking
 		currentCardinality := cl.tracker.GetCardinality(metricName)
-		globalCardinality := cl.tracker.GetGlobalCardinality()
+		globalCardinality := cl.globalCardinality()
 		
 		isNew, _ := cl.tracker.TrackDataPoint(metricName, dp.Attributes())
 		cl.tracker.IncrementStats("total")
@@ -189,7 +262,7 @@ func (cl *CardinalityLimiter) filterHistogramDataPoints(metric pmetric.Metric, d
 		
 		// Check current cardinality before tracking
 		currentCardinality := cl.tracker.GetCardinality(metricName)
-		globalCardinality := cl.tracker.GetGlobalCardinality()
+		globalCardinality := cl.globalCardinality()
 		
 		isNew, _ := cl.tracker.TrackDataPoint(metricName, dp.Attributes())
 		cl.tracker.IncrementStats("total")
@@ -220,7 +293,7 @@ func (cl *CardinalityLimiter) filterSummaryDataPoints(metric pmetric.Metric, dps
 		
 		// Check current cardinality before tracking
 		currentCardinality := cl.tracker.GetCardinality(metricName)
-		globalCardinality := cl.tracker.GetGlobalCardinality()
+		globalCardinality := cl.globalCardinality()
 		
 		isNew, _ := cl.tracker.TrackDataPoint(metricName, dp.Attributes())
 		cl.tracker.IncrementStats("total")
@@ -251,7 +324,7 @@ func (cl *CardinalityLimiter) filterExponentialHistogramDataPoints(metric pmetri
 		
 		// Check current cardinality before tracking
 		currentCardinality := cl.tracker.GetCardinality(metricName)
-		globalCardinality := cl.tracker.GetGlobalCardinality()
+		globalCardinality := cl.globalCardinality()
 		
 		isNew, _ := cl.tracker.TrackDataPoint(metricName, dp.Attributes())
 		cl.tracker.IncrementStats("total")
@@ -291,7 +364,7 @@ func (cl *CardinalityLimiter) getDataPointCount(metric pmetric.Metric) int {
 }
 
 // handleAggregate handles the aggregate strategy
-func (cl *CardinalityLimiter) handleAggregate(metric pmetric.Metric, output pmetric.MetricSlice, limit int) {
+func (cl *CardinalityLimiter) handleAggregate(metric pmetric.Metric, output pmetric.MetricSlice, limit int, aggregationLabels []string, newSeriesLimit int) {
 	metricName := metric.Name()
 	
 	// Create aggregated metric
@@ -299,10 +372,19 @@ func (cl *CardinalityLimiter) handleAggregate(metric pmetric.Metric, output pmet
 	metric.CopyTo(outputMetric)
 	
 	// Always apply aggregation labels if specified, or remove high cardinality labels when over limit
-	if len(cl.config.AggregationLabels) > 0 || cl.shouldAggregate(metricName, limit) {
-		cl.removeHighCardinalityLabels(outputMetric)
+	if len(aggregationLabels) > 0 || cl.shouldAggregate(metricName, limit) {
+		cl.removeHighCardinalityLabels(outputMetric, aggregationLabels)
 		cl.tracker.IncrementStats("aggregated")
 	}
+
+	// Admit new aggregated series at the configured rate
+	cl.admitNewSeries(outputMetric, newSeriesLimit)
+	if cl.getDataPointCount(outputMetric) == 0 {
+		output.RemoveIf(func(m pmetric.Metric) bool {
+			return m.Name() == outputMetric.Name()
+		})
+		return
+	}
 	
 	// Track all data points after aggregation
 	cl.trackAllDataPoints(outputMetric)
@@ -346,7 +428,7 @@ func (cl *CardinalityLimiter) sampleDataPoints(metric pmetric.Metric, dps pmetri
 		
 		// Check current cardinality before tracking
 		currentCardinality := cl.tracker.GetCardinality(metricName)
-		globalCardinality := cl.tracker.GetGlobalCardinality()
+		globalCardinality := cl.globalCardinality()
 		
 		isNew, _ := cl.tracker.TrackDataPoint(metricName, dp.Attributes())
 		cl.tracker.IncrementStats("total")
@@ -384,7 +466,7 @@ func (cl *CardinalityLimiter) sampleHistogramDataPoints(metric pmetric.Metric, d
 		
 		// Check current cardinality before tracking
 		currentCardinality := cl.tracker.GetCardinality(metricName)
-		globalCardinality := cl.tracker.GetGlobalCardinality()
+		globalCardinality := cl.globalCardinality()
 		
 		isNew, _ := cl.tracker.TrackDataPoint(metricName, dp.Attributes())
 		cl.tracker.IncrementStats("total")
@@ -422,7 +504,7 @@ func (cl *CardinalityLimiter) sampleSummaryDataPoints(metric pmetric.Metric, dps
 		
 		// Check current cardinality before tracking
 		currentCardinality := cl.tracker.GetCardinality(metricName)
-		globalCardinality := cl.tracker.GetGlobalCardinality()
+		globalCardinality := cl.globalCardinality()
 		
 		isNew, _ := cl.tracker.TrackDataPoint(metricName, dp.Attributes())
 		cl.tracker.IncrementStats("total")
@@ -460,7 +542,7 @@ func (cl *CardinalityLimiter) sampleExponentialHistogramDataPoints(metric pmetri
 		
 		// Check current cardinality before tracking
 		currentCardinality := cl.tracker.GetCardinality(metricName)
-		globalCardinality := cl.tracker.GetGlobalCardinality()
+		globalCardinality := cl.globalCardinality()
 		
 		isNew, _ := cl.tracker.TrackDataPoint(metricName, dp.Attributes())
 		cl.tracker.IncrementStats("total")
@@ -609,23 +691,23 @@ func (cl *CardinalityLimiter) processOldestExponentialHistogramDataPoints(metric
 }
 
 // removeHighCardinalityLabels removes labels based on configuration
-func (cl *CardinalityLimiter) removeHighCardinalityLabels(metric pmetric.Metric) {
+func (cl *CardinalityLimiter) removeHighCardinalityLabels(metric pmetric.Metric, aggregationLabels []string) {
 	switch metric.Type() {
 	case pmetric.MetricTypeGauge:
-		cl.removeLabelsFromDataPoints(metric.Gauge().DataPoints())
+		cl.removeLabelsFromDataPoints(metric.Gauge().DataPoints(), aggregationLabels)
 	case pmetric.MetricTypeSum:
-		cl.removeLabelsFromDataPoints(metric.Sum().DataPoints())
+		cl.removeLabelsFromDataPoints(metric.Sum().DataPoints(), aggregationLabels)
 	case pmetric.MetricTypeHistogram:
-		cl.removeLabelsFromHistogramDataPoints(metric.Histogram().DataPoints())
+		cl.removeLabelsFromHistogramDataPoints(metric.Histogram().DataPoints(), aggregationLabels)
 	case pmetric.MetricTypeSummary:
-		cl.removeLabelsFromSummaryDataPoints(metric.Summary().DataPoints())
+		cl.removeLabelsFromSummaryDataPoints(metric.Summary().DataPoints(), aggregationLabels)
 	case pmetric.MetricTypeExponentialHistogram:
-		cl.removeLabelsFromExponentialHistogramDataPoints(metric.ExponentialHistogram().DataPoints())
+		cl.removeLabelsFromExponentialHistogramDataPoints(metric.ExponentialHistogram().DataPoints(), aggregationLabels)
 	}
 }
 
 // removeLabelsFromDataPoints removes labels from number data points
-func (cl *CardinalityLimiter) removeLabelsFromDataPoints(dps pmetric.NumberDataPointSlice) {
+func (cl *CardinalityLimiter) removeLabelsFromDataPoints(dps pmetric.NumberDataPointSlice, aggregationLabels []string) {
 	for i := 0; i < dps.Len(); i++ {
 		dp := dps.At(i)
 		attrs := dp.Attributes()
@@ -633,9 +715,9 @@ func (cl *CardinalityLimiter) removeLabelsFromDataPoints(dps pmetric.NumberDataP
 		// Keep only allowed labels or remove denied labels
 		newAttrs := pcommon.NewMap()
 		
-		if len(cl.config.AggregationLabels) > 0 {
+		if len(aggregationLabels) > 0 {
 			// Keep only aggregation labels
-			for _, label := range cl.config.AggregationLabels {
+			for _, label := range aggregationLabels {
 				if val, ok := attrs.Get(label); ok {
 					newAttrs.PutStr(label, val.AsString())
 				}
@@ -655,15 +737,15 @@ func (cl *CardinalityLimiter) removeLabelsFromDataPoints(dps pmetric.NumberDataP
 }
 
 // removeLabelsFromHistogramDataPoints removes labels from histogram data points
-func (cl *CardinalityLimiter) removeLabelsFromHistogramDataPoints(dps pmetric.HistogramDataPointSlice) {
+func (cl *CardinalityLimiter) removeLabelsFromHistogramDataPoints(dps pmetric.HistogramDataPointSlice, aggregationLabels []string) {
 	for i := 0; i < dps.Len(); i++ {
 		dp := dps.At(i)
 		attrs := dp.Attributes()
 		
 		newAttrs := pcommon.NewMap()
 		
-		if len(cl.config.AggregationLabels) > 0 {
-			for _, label := range cl.config.AggregationLabels {
+		if len(aggregationLabels) > 0 {
+			for _, label := range aggregationLabels {
 				if val, ok := attrs.Get(label); ok {
 					newAttrs.PutStr(label, val.AsString())
 				}
@@ -682,15 +764,15 @@ func (cl *CardinalityLimiter) removeLabelsFromHistogramDataPoints(dps pmetric.Hi
 }
 
 // removeLabelsFromSummaryDataPoints removes labels from summary data points
-func (cl *CardinalityLimiter) removeLabelsFromSummaryDataPoints(dps pmetric.SummaryDataPointSlice) {
+func (cl *CardinalityLimiter) removeLabelsFromSummaryDataPoints(dps pmetric.SummaryDataPointSlice, aggregationLabels []string) {
 	for i := 0; i < dps.Len(); i++ {
 		dp := dps.At(i)
 		attrs := dp.Attributes()
 		
 		newAttrs := pcommon.NewMap()
 		
-		if len(cl.config.AggregationLabels) > 0 {
-			for _, label := range cl.config.AggregationLabels {
+		if len(aggregationLabels) > 0 {
+			for _, label := range aggregationLabels {
 				if val, ok := attrs.Get(label); ok {
 					newAttrs.PutStr(label, val.AsString())
 				}
@@ -709,15 +791,15 @@ func (cl *CardinalityLimiter) removeLabelsFromSummaryDataPoints(dps pmetric.Summ
 }
 
 // removeLabelsFromExponentialHistogramDataPoints removes labels from exponential histogram data points
-func (cl *CardinalityLimiter) removeLabelsFromExponentialHistogramDataPoints(dps pmetric.ExponentialHistogramDataPointSlice) {
+func (cl *CardinalityLimiter) removeLabelsFromExponentialHistogramDataPoints(dps pmetric.ExponentialHistogramDataPointSlice, aggregationLabels []string) {
 	for i := 0; i < dps.Len(); i++ {
 		dp := dps.At(i)
 		attrs := dp.Attributes()
 		
 		newAttrs := pcommon.NewMap()
 		
-		if len(cl.config.AggregationLabels) > 0 {
-			for _, label := range cl.config.AggregationLabels {
+		if len(aggregationLabels) > 0 {
+			for _, label := range aggregationLabels {
 				if val, ok := attrs.Get(label); ok {
 					newAttrs.PutStr(label, val.AsString())
 				}
@@ -745,18 +827,11 @@ func (cl *CardinalityLimiter) isDeniedLabel(label string) bool {
 	return false
 }
 
-// getMetricLimit returns the limit for a specific metric
-func (cl *CardinalityLimiter) getMetricLimit(metricName string) int {
-	if limit, exists := cl.config.MetricLimits[metricName]; exists {
-		return limit
-	}
-	return cl.config.DefaultLimit
-}
-
 // trackLabelCardinality tracks unique values per label
 func (cl *CardinalityLimiter) trackLabelCardinality(metrics pmetric.Metrics) {
-	cl.labelMutex.Lock()
-	defer cl.labelMutex.Unlock()
+	// Collect the batch's label values locally, then merge each key into its
+	// shard so a batch takes one lock per distinct label key
+	batch := make(map[string]map[string]struct{})
 
 	resourceMetrics := metrics.ResourceMetrics()
 	for i := 0; i < resourceMetrics.Len(); i++ {
@@ -767,26 +842,51 @@ func (cl *CardinalityLimiter) trackLabelCardinality(metrics pmetric.Metrics) {
 			metrics := sm.Metrics()
 			for k := 0; k < metrics.Len(); k++ {
 				metric := metrics.At(k)
-				cl.trackMetricLabels(metric)
+				cl.trackMetricLabels(metric, batch)
 			}
 		}
 	}
 
-	// Update tracker with high cardinality labels
-	for label, values := range cl.labelCardinality {
-		cl.tracker.TrackLabelCardinality(label, len(values))
+	for label, values := range batch {
+		shard := cl.labelShard(label)
+		shard.mu.Lock()
+		seen, exists := shard.values[label]
+		if !exists {
+			seen = make(map[string]struct{}, len(values))
+			shard.values[label] = seen
+		}
+		for value := range values {
+			seen[value] = struct{}{}
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// labelShard returns the shard owning a label key
+func (cl *CardinalityLimiter) labelShard(label string) *labelShard {
+	return &cl.labelCardinality[xxhash.Sum64String(label)>>(64-shardBits)]
+}
+
+// syncLabelCardinality publishes the unique value count of every label to the tracker
+func (cl *CardinalityLimiter) syncLabelCardinality() {
+	for i := range cl.labelCardinality {
+		shard := &cl.labelCardinality[i]
+		shard.mu.Lock()
+		for label, values := range shard.values {
+			cl.tracker.TrackLabelCardinality(label, len(values))
+		}
+		shard.mu.Unlock()
 	}
 }
 
-// trackMetricLabels tracks labels from a single metric
-func (cl *CardinalityLimiter) trackMetricLabels(metric pmetric.Metric) {
-	var processAttributes func(attrs pcommon.Map)
-	processAttributes = func(attrs pcommon.Map) {
+// trackMetricLabels records the label values of a single metric into batch
+func (cl *CardinalityLimiter) trackMetricLabels(metric pmetric.Metric, batch map[string]map[string]struct{}) {
+	processAttributes := func(attrs pcommon.Map) {
 		attrs.Range(func(k string, v pcommon.Value) bool {
-			if _, exists := cl.labelCardinality[k]; !exists {
-				cl.labelCardinality[k] = make(map[string]struct{})
+			if _, exists := batch[k]; !exists {
+				batch[k] = make(map[string]struct{})
 			}
-			cl.labelCardinality[k][v.AsString()] = struct{}{}
+			batch[k][v.AsString()] = struct{}{}
 			return true
 		})
 	}
@@ -820,29 +920,86 @@ func (cl *CardinalityLimiter) trackMetricLabels(metric pmetric.Metric) {
 	}
 }
 
-// checkAlerts checks if alerts should be sent
-func (cl *CardinalityLimiter) checkAlerts() {
+// SetAlertHandler registers a callback for threshold alerts. The handler is
+// called synchronously from ProcessMetrics and must not block.
+func (cl *CardinalityLimiter) SetAlertHandler(handler func(CardinalityAlert)) {
 	cl.alertMutex.Lock()
 	defer cl.alertMutex.Unlock()
+	cl.alertHandler = handler
+}
+
+// checkAlerts checks the global limit and the limits of the metrics in the
+// current batch, raising at most one alert per target per alert interval
+func (cl *CardinalityLimiter) checkAlerts(metricNames map[string]struct{}) {
+	now := cl.clock.Now()
+	percent := float64(cl.config.AlertThreshold) / 100.0
 
-	globalCardinality := cl.tracker.GetGlobalCardinality()
-	threshold := float64(cl.config.GlobalLimit) * float64(cl.config.AlertThreshold) / 100.0
+	cl.alertMutex.Lock()
+	var alerts []CardinalityAlert
+
+	globalCardinality := cl.globalCardinality()
+	if float64(globalCardinality) > float64(cl.config.GlobalLimit)*percent && cl.shouldAlert("global", now) {
+		alerts = append(alerts, CardinalityAlert{
+			Scope:            AlertScopeGlobal,
+			Cardinality:      globalCardinality,
+			Limit:            cl.config.GlobalLimit,
+			ThresholdPercent: cl.config.AlertThreshold,
+			Timestamp:        now,
+		})
+	}
 
-	if float64(globalCardinality) > threshold {
-		// Check if we've sent an alert recently
-		lastAlert, exists := cl.alertsSent["global"]
-		if !exists || time.Since(lastAlert) > 5*time.Minute {
+	for metricName := range metricNames {
+		policy := cl.config.metricLimit(metricName)
+		cardinality := cl.tracker.GetCardinality(metricName)
+		if float64(cardinality) > float64(policy.Limit)*percent && cl.shouldAlert("metric:"+metricName, now) {
+			alerts = append(alerts, CardinalityAlert{
+				Scope:            AlertScopeMetric,
+				Metric:           metricName,
+				Cardinality:      cardinality,
+				Limit:            policy.Limit,
+				ThresholdPercent: cl.config.AlertThreshold,
+				Strategy:         policy.Strategy,
+				Timestamp:        now,
+			})
+		}
+	}
+
+	handler := cl.alertHandler
+	cl.alertMutex.Unlock()
+
+	for _, alert := range alerts {
+		if alert.Scope == AlertScopeGlobal {
 			cl.logger.Warn("Global cardinality threshold exceeded",
-				zap.Int("current", globalCardinality),
-				zap.Int("limit", cl.config.GlobalLimit),
-				zap.Int("threshold_percent", cl.config.AlertThreshold))
-			cl.alertsSent["global"] = time.Now()
+				zap.Int("current", alert.Cardinality),
+				zap.Int("limit", alert.Limit),
+				zap.Int("threshold_percent", alert.ThresholdPercent))
+		} else {
+			cl.logger.Warn("Metric cardinality threshold exceeded",
+				zap.String("metric", alert.Metric),
+				zap.Int("current", alert.Cardinality),
+				zap.Int("limit", alert.Limit),
+				zap.Int("threshold_percent", alert.ThresholdPercent))
+		}
+		if handler != nil {
+			handler(alert)
 		}
 	}
 }
 
+// shouldAlert reports whether an alert for key is due and records it.
+// Must be called with alertMutex held.
+func (cl *CardinalityLimiter) shouldAlert(key string, now time.Time) bool {
+	lastAlert, exists := cl.alertsSent[key]
+	if exists && now.Sub(lastAlert) <= cl.config.Alerts.Interval {
+		return false
+	}
+	cl.alertsSent[key] = now
+	return true
+}
+
 // GetStats returns current statistics
 func (cl *CardinalityLimiter) GetStats() CardinalityStats {
+	cl.syncLabelCardinality()
 	return cl.tracker.GetStats()
 }
 
@@ -943,20 +1100,35 @@ func (cl *CardinalityLimiter) trackAllDataPoints(metric pmetric.Metric) {
 	}
 }
 
+// globalCardinality returns the series counted against the global limit:
+// those tracked here plus those of coordinated instances
+func (cl *CardinalityLimiter) globalCardinality() int {
+	global := cl.tracker.GetGlobalCardinality()
+	if cl.coordinator != nil {
+		global += cl.coordinator.peerCardinality()
+	}
+	return global
+}
+
 // shouldAggregate checks if aggregation is needed based on cardinality
 func (cl *CardinalityLimiter) shouldAggregate(metricName string, limit int) bool {
 	currentCardinality := cl.tracker.GetCardinality(metricName)
-	globalCardinality := cl.tracker.GetGlobalCardinality()
+	globalCardinality := cl.globalCardinality()
 	return currentCardinality >= limit || globalCardinality >= cl.config.GlobalLimit
 }
 
 // Reset resets the limiter state
 func (cl *CardinalityLimiter) Reset() {
 	cl.tracker.Reset()
+	cl.churn.reset()
+	cl.labelLimits.reset()
 	
-	cl.labelMutex.Lock()
-	cl.labelCardinality = make(map[string]map[string]struct{})
-	cl.labelMutex.Unlock()
+	for i := range cl.labelCardinality {
+		shard := &cl.labelCardinality[i]
+		shard.mu.Lock()
+		shard.values = make(map[string]map[string]struct{})
+		shard.mu.Unlock()
+	}
 	
 	cl.alertMutex.Lock()
 	cl.alertsSent = make(map[string]time.Time)
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/pdata/pcommon"
@@ -26,7 +27,7 @@ func TestNewCardinalityLimiter(t *testing.T) {
 	assert.Equal(t, cfg, limiter.config)
 	assert.Equal(t, logger, limiter.logger)
 	assert.NotNil(t, limiter.tracker)
-	assert.NotNil(t, limiter.labelCardinality)
+	assert.NotNil(t, limiter.labelCardinality[0].values)
 	assert.NotNil(t, limiter.rand)
 }
 
@@ -101,6 +102,49 @@ func TestProcessMetricsAggregateStrategy(t *testing.T) {
 	}
 }
 
+func TestProcessMetricsPerMetricStrategy(t *testing.T) {
+	cfg := &Config{
+		GlobalLimit:  100,
+		DefaultLimit: 2,
+		Strategy:     StrategyDrop,
+		MetricLimits: map[string]MetricLimit{
+			"business_metric": {
+				Limit:             2,
+				Strategy:          StrategyAggregate,
+				AggregationLabels: []string{"region"},
+			},
+		},
+		WindowSize:    5 * time.Minute,
+		ResetInterval: time.Hour,
+	}
+	limiter := NewCardinalityLimiter(cfg, zap.NewNop())
+
+	labels := []map[string]string{
+		{"region": "us", "user": "a"},
+		{"region": "us", "user": "b"},
+		{"region": "eu", "user": "c"},
+		{"region": "eu", "user": "d"},
+	}
+
+	// The debug metric uses the global drop strategy
+	result, err := limiter.ProcessMetrics(generateMetricsWithLabels("debug_metric", labels))
+	require.NoError(t, err)
+	assert.Equal(t, 2, countDataPoints(result))
+
+	// The business metric keeps every point but aggregates to its own labels
+	result, err = limiter.ProcessMetrics(generateMetricsWithLabels("business_metric", labels))
+	require.NoError(t, err)
+	assert.Equal(t, 4, countDataPoints(result))
+
+	dps := result.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints()
+	for i := 0; i < dps.Len(); i++ {
+		attrs := dps.At(i).Attributes()
+		assert.Equal(t, 1, attrs.Len())
+		_, exists := attrs.Get("region")
+		assert.True(t, exists)
+	}
+}
+
 func TestProcessMetricsSampleStrategy(t *testing.T) {
 	cfg := &Config{
 		GlobalLimit:   100,
@@ -144,7 +188,8 @@ func TestProcessMetricsOldestStrategy(t *testing.T) {
 		ResetInterval: time.Hour,
 	}
 	logger := zap.NewNop()
-	limiter := NewCardinalityLimiter(cfg, logger)
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	limiter := newCardinalityLimiter(cfg, logger, clk)
 
 	// Process metrics in sequence
 	for i := 0; i < 5; i++ {
@@ -152,8 +197,8 @@ func TestProcessMetricsOldestStrategy(t *testing.T) {
 			{"label": string(rune('a' + i))},
 		})
 		
-		// Add small delay to ensure different timestamps
-		time.Sleep(10 * time.Millisecond)
+		// Each batch arrives a second after the last
+		clk.Advance(time.Second)
 		
 		_, err := limiter.ProcessMetrics(metrics)
 		require.NoError(t, err)
@@ -285,7 +330,72 @@ func TestReset(t *testing.T) {
 
 	// Verify data is cleared
 	assert.Equal(t, 0, limiter.tracker.GetGlobalCardinality())
-	assert.Equal(t, 0, len(limiter.labelCardinality))
+	for i := range limiter.labelCardinality {
+		assert.Empty(t, limiter.labelCardinality[i].values)
+	}
+}
+
+func TestProcessMetricsScopeAttributes(t *testing.T) {
+	cfg := &Config{
+		GlobalLimit:  100,
+		DefaultLimit: 10,
+		MetricLimits: map[string]MetricLimit{
+			"busy_metric": {Limit: 4},
+		},
+		Strategy:        StrategyDrop,
+		WindowSize:      5 * time.Minute,
+		ResetInterval:   time.Hour,
+		ScopeAttributes: true,
+	}
+	limiter := NewCardinalityLimiter(cfg, zap.NewNop())
+
+	// Two metrics sharing a scope
+	metrics := generateMetricsWithLabels("busy_metric", []map[string]string{
+		{"label": "a"}, {"label": "b"}, {"label": "c"},
+	})
+	sm := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0)
+	sm.Scope().SetName("hostmetrics")
+	sm.Scope().Attributes().PutStr("owner", "infra")
+	quiet := sm.Metrics().AppendEmpty()
+	quiet.SetName("quiet_metric")
+	quiet.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1)
+
+	// And an empty scope
+	metrics.ResourceMetrics().At(0).ScopeMetrics().AppendEmpty()
+
+	result, err := limiter.ProcessMetrics(metrics)
+	require.NoError(t, err)
+
+	scopes := result.ResourceMetrics().At(0).ScopeMetrics()
+	require.Equal(t, 2, scopes.Len())
+
+	busy := scopes.At(0)
+	require.Equal(t, 1, busy.Metrics().Len())
+	assert.Equal(t, "busy_metric", busy.Metrics().At(0).Name())
+	assert.Equal(t, "hostmetrics", busy.Scope().Name())
+	assert.Equal(t, map[string]any{
+		"owner":             "infra",
+		"nrcap.cardinality": int64(3),
+		"nrcap.limit":       int64(4),
+		"nrcap.utilization": 0.75,
+	}, busy.Scope().Attributes().AsRaw())
+
+	quietScope := scopes.At(1)
+	require.Equal(t, 1, quietScope.Metrics().Len())
+	assert.Equal(t, "quiet_metric", quietScope.Metrics().At(0).Name())
+	assert.Equal(t, "hostmetrics", quietScope.Scope().Name())
+	assert.Equal(t, map[string]any{
+		"owner":             "infra",
+		"nrcap.cardinality": int64(1),
+		"nrcap.limit":       int64(10),
+		"nrcap.utilization": 0.1,
+	}, quietScope.Scope().Attributes().AsRaw())
+
+	// Off by default, scopes are left as received
+	cfg.ScopeAttributes = false
+	result, err = limiter.ProcessMetrics(generateMetrics("test_metric", 2))
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.ResourceMetrics().At(0).ScopeMetrics().At(0).Scope().Attributes().Len())
 }
 
 // Helper function to generate metrics with specific labels
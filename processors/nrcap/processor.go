@@ -3,9 +3,13 @@ package nrcap
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
 	"sync"
 	"time"
 
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/pdata/pmetric"
@@ -21,32 +25,68 @@ type capProcessor struct {
 	config *Config
 	logger *zap.Logger
 
+	// Component ID, names report files
+	id string
+
 	limiter      *CardinalityLimiter
 	nextConsumer consumer.Metrics
 
+	// Time source for the reset and stats intervals
+	clock clock.Clock
+
 	// Reset ticker
-	resetTicker *time.Ticker
+	resetTicker clock.Ticker
 	stopCh      chan struct{}
 	wg          sync.WaitGroup
 
 	// Stats reporting
-	statsTicker *time.Ticker
+	statsTicker clock.Ticker
+
+	// Alert delivery, off the data path
+	alertSinks []alertSink
+	alertCh    chan CardinalityAlert
+
+	// Report signals, nil unless reports are enabled
+	reportCh chan os.Signal
+
+	// Live statistics endpoint, nil unless enabled
+	statsServer *http.Server
 }
 
+// alertQueueSize bounds alerts waiting for delivery; further alerts are
+// dropped (they are still logged by the limiter)
+const alertQueueSize = 100
+
 // newCapProcessor creates a new processor instance
 func newCapProcessor(cfg component.Config, logger *zap.Logger, nextConsumer consumer.Metrics) (*capProcessor, error) {
+	return newCapProcessorWithClock(cfg, logger, nextConsumer, clock.Real())
+}
+
+// newCapProcessorWithClock creates a processor instance whose intervals and
+// windows run on clk
+func newCapProcessorWithClock(cfg component.Config, logger *zap.Logger, nextConsumer consumer.Metrics, clk clock.Clock) (*capProcessor, error) {
 	processorCfg, ok := cfg.(*Config)
 	if !ok {
 		return nil, fmt.Errorf("invalid config type: %T", cfg)
 	}
 
-	return &capProcessor{
+	p := &capProcessor{
 		config:       processorCfg,
 		logger:       logger,
-		limiter:      NewCardinalityLimiter(processorCfg, logger),
+		id:           typeStr,
+		clock:        clk,
+		limiter:      newCardinalityLimiter(processorCfg, logger, clk),
 		nextConsumer: nextConsumer,
 		stopCh:       make(chan struct{}),
-	}, nil
+		alertSinks:   newAlertSinks(processorCfg.Alerts),
+	}
+
+	if len(p.alertSinks) > 0 {
+		p.alertCh = make(chan CardinalityAlert, alertQueueSize)
+		p.limiter.SetAlertHandler(p.enqueueAlert)
+	}
+
+	return p, nil
 }
 
 // Capabilities returns the capabilities of the processor
@@ -60,18 +100,49 @@ func (p *capProcessor) Start(ctx context.Context, host component.Host) error {
 		zap.Int("global_limit", p.config.GlobalLimit),
 		zap.String("strategy", string(p.config.Strategy)))
 
+	// Serve live statistics if enabled; first, so failing to listen leaves
+	// nothing running
+	if p.config.StatsServer.Endpoint != "" {
+		if err := p.startStatsServer(); err != nil {
+			return err
+		}
+	}
+
+	// Share the global limit with the other instances on the host
+	if p.config.Coordination.Socket != "" {
+		p.limiter.coordinator = newBudgetCoordinator(p.config.Coordination,
+			fmt.Sprintf("%d/%s", os.Getpid(), p.id),
+			p.limiter.tracker.GetGlobalCardinality,
+			p.logger, p.clock)
+		p.limiter.coordinator.start()
+	}
+
 	// Start reset ticker
-	p.resetTicker = time.NewTicker(p.config.ResetInterval)
+	p.resetTicker = p.clock.NewTicker(p.config.ResetInterval)
 	p.wg.Add(1)
 	go p.resetLoop()
 
 	// Start stats ticker if enabled
 	if p.config.EnableStats {
-		p.statsTicker = time.NewTicker(1 * time.Minute)
+		p.statsTicker = p.clock.NewTicker(1 * time.Minute)
 		p.wg.Add(1)
 		go p.statsLoop()
 	}
 
+	// Start alert delivery if any sink is configured
+	if p.alertCh != nil {
+		p.wg.Add(1)
+		go p.alertLoop()
+	}
+
+	// Write cardinality reports on demand if enabled
+	if p.config.Report.Directory != "" && len(reportSignals) > 0 {
+		p.reportCh = make(chan os.Signal, 1)
+		signal.Notify(p.reportCh, reportSignals...)
+		p.wg.Add(1)
+		go p.reportLoop()
+	}
+
 	return nil
 }
 
@@ -87,6 +158,13 @@ func (p *capProcessor) Shutdown(ctx context.Context) error {
 	if p.statsTicker != nil {
 		p.statsTicker.Stop()
 	}
+	if p.reportCh != nil {
+		signal.Stop(p.reportCh)
+	}
+	p.stopStatsServer(ctx)
+	if p.limiter.coordinator != nil {
+		p.limiter.coordinator.stop()
+	}
 
 	// Wait for goroutines to finish
 	done := make(chan struct{})
@@ -121,7 +199,7 @@ func (p *capProcessor) resetLoop() {
 
 	for {
 		select {
-		case <-p.resetTicker.C:
+		case <-p.resetTicker.C():
 			p.logger.Info("Resetting cardinality tracker")
 			p.limiter.Reset()
 		case <-p.stopCh:
@@ -136,13 +214,15 @@ func (p *capProcessor) statsLoop() {
 
 	for {
 		select {
-		case <-p.statsTicker.C:
+		case <-p.statsTicker.C():
 			stats := p.limiter.GetStats()
 			p.logger.Info("Cardinality protection statistics",
 				zap.Int64("total_metrics", stats.TotalMetrics),
 				zap.Int64("dropped_metrics", stats.DroppedMetrics),
 				zap.Int64("aggregated_metrics", stats.AggregatedMetrics),
 				zap.Int64("sampled_metrics", stats.SampledMetrics),
+				zap.Int64("churn_dropped_metrics", stats.ChurnDroppedMetrics),
+				zap.Int64("label_limited_metrics", stats.LabelLimitedMetrics),
 				zap.Time("last_reset", stats.LastReset))
 
 			// Log high cardinality metrics
@@ -169,12 +249,55 @@ func (p *capProcessor) statsLoop() {
 	}
 }
 
+// enqueueAlert queues an alert for delivery without blocking the pipeline
+func (p *capProcessor) enqueueAlert(alert CardinalityAlert) {
+	select {
+	case p.alertCh <- alert:
+	default:
+		p.logger.Warn("Alert queue full, dropping alert",
+			zap.String("scope", alert.Scope),
+			zap.String("metric", alert.Metric))
+	}
+}
+
+// alertLoop delivers queued alerts to every configured sink
+func (p *capProcessor) alertLoop() {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case alert := <-p.alertCh:
+			p.deliverAlert(alert)
+		case <-p.stopCh:
+			// Flush what is already queued
+			for {
+				select {
+				case alert := <-p.alertCh:
+					p.deliverAlert(alert)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliverAlert sends an alert to every sink, logging failures
+func (p *capProcessor) deliverAlert(alert CardinalityAlert) {
+	for _, sink := range p.alertSinks {
+		if err := sink.send(context.Background(), alert); err != nil {
+			p.logger.Error("Failed to deliver cardinality alert",
+				zap.String("sink", sink.name()),
+				zap.String("scope", alert.Scope),
+				zap.String("metric", alert.Metric),
+				zap.Error(err))
+		}
+	}
+}
+
 // getMetricLimit returns the limit for a specific metric
 func (p *capProcessor) getMetricLimit(metricName string) int {
-	if limit, exists := p.config.MetricLimits[metricName]; exists {
-		return limit
-	}
-	return p.config.DefaultLimit
+	return p.config.metricLimit(metricName).Limit
 }
 
 // Ensure capProcessor implements the necessary interfaces
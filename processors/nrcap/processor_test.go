@@ -5,9 +5,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/confmap"
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
@@ -30,20 +33,32 @@ func TestNewCapProcessor(t *testing.T) {
 
 func TestCapProcessorStart(t *testing.T) {
 	cfg := createDefaultConfig().(*Config)
-	cfg.ResetInterval = 100 * time.Millisecond
+	cfg.ResetInterval = time.Hour
 	cfg.EnableStats = true
 	
 	logger := zap.NewNop()
 	consumer := consumertest.NewNop()
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
 
-	proc, err := newCapProcessor(cfg, logger, consumer)
+	proc, err := newCapProcessorWithClock(cfg, logger, consumer, clk)
 	require.NoError(t, err)
 
 	err = proc.Start(context.Background(), componenttest.NewNopHost())
 	require.NoError(t, err)
 
-	// Let the tickers run
-	time.Sleep(150 * time.Millisecond)
+	err = proc.ConsumeMetrics(context.Background(), generateMetrics("test_metric", 5))
+	require.NoError(t, err)
+	assert.Equal(t, 5, proc.limiter.tracker.GetGlobalCardinality())
+
+	// The reset and stats tickers run on the clock
+	clk.BlockUntil(2)
+	clk.Advance(59 * time.Minute)
+	assert.Equal(t, 5, proc.limiter.tracker.GetGlobalCardinality())
+	clk.Advance(time.Minute)
+	assert.Eventually(t, func() bool {
+		return proc.limiter.tracker.GetGlobalCardinality() == 0
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, clk.Now(), proc.limiter.tracker.GetStats().LastReset.UTC())
 
 	err = proc.Shutdown(context.Background())
 	require.NoError(t, err)
@@ -130,8 +145,8 @@ func TestCapProcessorWithMetricLimits(t *testing.T) {
 	cfg := &Config{
 		GlobalLimit:  100,
 		DefaultLimit: 10,
-		MetricLimits: map[string]int{
-			"limited_metric": 2,
+		MetricLimits: map[string]MetricLimit{
+			"limited_metric": {Limit: 2},
 		},
 		Strategy:      StrategyDrop,
 		ResetInterval: time.Hour,
@@ -228,6 +243,47 @@ func TestFactoryCreateDefaultConfig(t *testing.T) {
 	assert.Equal(t, StrategyDrop, capCfg.Strategy)
 }
 
+func TestConfigUnmarshalMetricLimits(t *testing.T) {
+	conf := confmap.NewFromStringMap(map[string]any{
+		"strategy": "drop",
+		"metric_limits": map[string]any{
+			"debug_metric": 100,
+			"orders_total": map[string]any{
+				"limit":              5000,
+				"strategy":           "aggregate",
+				"aggregation_labels": []any{"region"},
+			},
+		},
+	})
+
+	cfg := createDefaultConfig().(*Config)
+	require.NoError(t, component.UnmarshalConfig(conf, cfg))
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, MetricLimit{Limit: 100}, cfg.MetricLimits["debug_metric"])
+	assert.Equal(t, MetricLimit{
+		Limit:             5000,
+		Strategy:          StrategyAggregate,
+		AggregationLabels: []string{"region"},
+	}, cfg.MetricLimits["orders_total"])
+
+	// Unset overrides fall back to the global settings
+	debug := cfg.metricLimit("debug_metric")
+	assert.Equal(t, StrategyDrop, debug.Strategy)
+	assert.Equal(t, cfg.AggregationLabels, debug.AggregationLabels)
+	assert.Equal(t, cfg.DefaultLimit, cfg.metricLimit("other_metric").Limit)
+}
+
+func TestConfigValidateMetricLimitStrategy(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetricLimits["noisy"] = MetricLimit{Limit: 10, Strategy: "bogus"}
+	assert.Error(t, cfg.Validate())
+
+	cfg.MetricLimits["noisy"] = MetricLimit{Limit: 10, Strategy: StrategySample}
+	cfg.SampleRate = 0
+	assert.Error(t, cfg.Validate())
+}
+
 func TestFactoryCreateMetricsProcessor(t *testing.T) {
 	factory := NewFactory()
 	cfg := factory.CreateDefaultConfig()
@@ -0,0 +1,242 @@
+package nrcap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// reportTimeFormat names report files so they sort by time
+const reportTimeFormat = "20060102T150405.000Z"
+
+// CardinalityReport is an on-demand breakdown of the tracked series
+type CardinalityReport struct {
+	// Processor is the component ID of the processor, e.g. nrcap/pods
+	Processor string `json:"processor"`
+
+	GeneratedAt time.Time `json:"generated_at"`
+	LastReset   time.Time `json:"last_reset"`
+
+	GlobalCardinality int `json:"global_cardinality"`
+	GlobalLimit       int `json:"global_limit"`
+
+	// Metrics are ordered by cardinality, highest first
+	Metrics []MetricReport `json:"metrics"`
+}
+
+// MetricReport is the cardinality of one metric and its label keys
+type MetricReport struct {
+	Name        string   `json:"name"`
+	Cardinality int      `json:"cardinality"`
+	Limit       int      `json:"limit"`
+	Strategy    Strategy `json:"strategy"`
+
+	// Labels are ordered by distinct values, most first
+	Labels []LabelReport `json:"labels"`
+}
+
+// LabelReport is the distinct values of one label key of a metric
+type LabelReport struct {
+	Key            string `json:"key"`
+	DistinctValues int    `json:"distinct_values"`
+
+	// TopValues are the values carried by the most series
+	TopValues []LabelValueCount `json:"top_values"`
+}
+
+// LabelValueCount is the number of series carrying a label value
+type LabelValueCount struct {
+	Value  string `json:"value"`
+	Series int    `json:"series"`
+}
+
+// Report breaks the tracked series down by metric, label key and label
+// value, listing the topValues most common values of each key. Labels are
+// only known for series tracked while keepLabels was set.
+func (ct *CardinalityTracker) Report(topValues int) CardinalityReport {
+	type metricSeries struct {
+		series int
+		labels map[string]map[string]int // key -> value -> series
+	}
+
+	metrics := make(map[string]*metricSeries)
+	for i := range ct.series {
+		shard := &ct.series[i]
+		shard.mu.Lock()
+		for _, entry := range shard.series {
+			m, ok := metrics[entry.metricName]
+			if !ok {
+				m = &metricSeries{labels: make(map[string]map[string]int)}
+				metrics[entry.metricName] = m
+			}
+			m.series++
+			for j := 0; j+1 < len(entry.labels); j += 2 {
+				values, ok := m.labels[entry.labels[j]]
+				if !ok {
+					values = make(map[string]int)
+					m.labels[entry.labels[j]] = values
+				}
+				values[entry.labels[j+1]]++
+			}
+		}
+		shard.mu.Unlock()
+	}
+
+	report := CardinalityReport{
+		GeneratedAt: ct.clock.Now().UTC(),
+		LastReset:   time.Unix(0, ct.stats.lastReset.Load()).UTC(),
+		Metrics:     make([]MetricReport, 0, len(metrics)),
+	}
+	for name, m := range metrics {
+		metric := MetricReport{
+			Name:        name,
+			Cardinality: m.series,
+			Labels:      make([]LabelReport, 0, len(m.labels)),
+		}
+		for key, values := range m.labels {
+			label := LabelReport{Key: key, DistinctValues: len(values)}
+			for value, series := range values {
+				label.TopValues = append(label.TopValues, LabelValueCount{Value: value, Series: series})
+			}
+			sort.Slice(label.TopValues, func(i, j int) bool {
+				a, b := label.TopValues[i], label.TopValues[j]
+				if a.Series != b.Series {
+					return a.Series > b.Series
+				}
+				return a.Value < b.Value
+			})
+			if len(label.TopValues) > topValues {
+				label.TopValues = label.TopValues[:max(topValues, 0)]
+			}
+			metric.Labels = append(metric.Labels, label)
+		}
+		sort.Slice(metric.Labels, func(i, j int) bool {
+			a, b := metric.Labels[i], metric.Labels[j]
+			if a.DistinctValues != b.DistinctValues {
+				return a.DistinctValues > b.DistinctValues
+			}
+			return a.Key < b.Key
+		})
+
+		report.GlobalCardinality += m.series
+		report.Metrics = append(report.Metrics, metric)
+	}
+	sort.Slice(report.Metrics, func(i, j int) bool {
+		a, b := report.Metrics[i], report.Metrics[j]
+		if a.Cardinality != b.Cardinality {
+			return a.Cardinality > b.Cardinality
+		}
+		return a.Name < b.Name
+	})
+
+	return report
+}
+
+// Report returns the cardinality report of the tracked series with each
+// metric's effective limit and strategy
+func (cl *CardinalityLimiter) Report() CardinalityReport {
+	report := cl.tracker.Report(cl.config.Report.TopValues)
+	report.GlobalLimit = cl.config.GlobalLimit
+	for i := range report.Metrics {
+		limit := cl.config.metricLimit(report.Metrics[i].Name)
+		report.Metrics[i].Limit = limit.Limit
+		report.Metrics[i].Strategy = limit.Strategy
+	}
+	return report
+}
+
+// reportLoop writes a report each time a report signal arrives
+func (p *capProcessor) reportLoop() {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-p.reportCh:
+			path, err := p.writeReport()
+			if err != nil {
+				p.logger.Error("Failed to write cardinality report", zap.Error(err))
+				continue
+			}
+			p.logger.Info("Wrote cardinality report", zap.String("path", path))
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+// writeReport writes a report to the report directory, removes the oldest
+// reports beyond MaxFiles and returns the report's path
+func (p *capProcessor) writeReport() (string, error) {
+	report := p.limiter.Report()
+	report.Processor = p.id
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding report: %w", err)
+	}
+
+	dir := p.config.Report.Directory
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("creating report directory: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial report
+	prefix := "nrcap-" + strings.ReplaceAll(p.id, "/", "_") + "-"
+	path := filepath.Join(dir, prefix+report.GeneratedAt.Format(reportTimeFormat)+".json")
+	tmp, err := os.CreateTemp(dir, prefix+"*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("creating report: %w", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("writing report: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("writing report: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("writing report: %w", err)
+	}
+
+	p.pruneReports(dir, prefix)
+	return path, nil
+}
+
+// pruneReports removes the oldest of this processor's reports beyond MaxFiles
+func (p *capProcessor) pruneReports(dir, prefix string) {
+	if p.config.Report.MaxFiles <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, prefix+"*.json"))
+	if err != nil {
+		return
+	}
+	// Skip the reports of processors whose ID extends this one's
+	var reports []string
+	for _, path := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), prefix), ".json")
+		if _, err := time.Parse(reportTimeFormat, stamp); err == nil {
+			reports = append(reports, path)
+		}
+	}
+	if len(reports) <= p.config.Report.MaxFiles {
+		return
+	}
+	sort.Strings(reports)
+	for _, path := range reports[:len(reports)-p.config.Report.MaxFiles] {
+		if err := os.Remove(path); err != nil {
+			p.logger.Warn("Failed to remove old cardinality report",
+				zap.String("path", path), zap.Error(err))
+		}
+	}
+}
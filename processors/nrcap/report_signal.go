@@ -0,0 +1,11 @@
+//go:build !windows
+
+package nrcap
+
+import (
+	"os"
+	"syscall"
+)
+
+// reportSignals make the processor write a cardinality report
+var reportSignals = []os.Signal{syscall.SIGUSR2}
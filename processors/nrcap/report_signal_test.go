@@ -0,0 +1,36 @@
+//go:build !windows
+
+package nrcap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap"
+)
+
+func TestCapProcessorReportOnSignal(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Report.Directory = t.TempDir()
+
+	proc, err := newCapProcessor(cfg, zap.NewNop(), consumertest.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, proc.Start(context.Background(), componenttest.NewNopHost()))
+	defer proc.Shutdown(context.Background())
+
+	require.NoError(t, proc.ConsumeMetrics(context.Background(), generateMetrics("test_metric", 3)))
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR2))
+
+	assert.Eventually(t, func() bool {
+		reports, _ := filepath.Glob(filepath.Join(cfg.Report.Directory, "nrcap-nrcap-*.json"))
+		return len(reports) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
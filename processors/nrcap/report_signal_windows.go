@@ -0,0 +1,7 @@
+package nrcap
+
+import "os"
+
+// reportSignals is empty on Windows, which has no SIGUSR2; reports cannot be
+// triggered there
+var reportSignals []os.Signal
@@ -0,0 +1,127 @@
+package nrcap
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap"
+)
+
+func TestCardinalityReport(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Report.Directory = t.TempDir()
+	cfg.Report.TopValues = 2
+	cfg.MetricLimits = map[string]MetricLimit{
+		"http_requests": {Limit: 50, Strategy: StrategyOldest},
+	}
+
+	limiter := NewCardinalityLimiter(cfg, zap.NewNop())
+	_, err := limiter.ProcessMetrics(generateMetricsWithLabels("http_requests", []map[string]string{
+		{"path": "/a", "status": "200"},
+		{"path": "/b", "status": "200"},
+		{"path": "/c", "status": "500"},
+		{"path": "/a", "status": "500"},
+	}))
+	require.NoError(t, err)
+	_, err = limiter.ProcessMetrics(generateMetricsWithLabels("queue_depth", []map[string]string{
+		{"queue": "orders"},
+	}))
+	require.NoError(t, err)
+
+	report := limiter.Report()
+	assert.Equal(t, 5, report.GlobalCardinality)
+	assert.Equal(t, cfg.GlobalLimit, report.GlobalLimit)
+	require.Len(t, report.Metrics, 2)
+
+	// Metrics by cardinality, with their effective limits
+	requests := report.Metrics[0]
+	assert.Equal(t, "http_requests", requests.Name)
+	assert.Equal(t, 4, requests.Cardinality)
+	assert.Equal(t, 50, requests.Limit)
+	assert.Equal(t, StrategyOldest, requests.Strategy)
+	assert.Equal(t, "queue_depth", report.Metrics[1].Name)
+	assert.Equal(t, cfg.DefaultLimit, report.Metrics[1].Limit)
+
+	// Label keys by distinct values, top values by series
+	require.Len(t, requests.Labels, 2)
+	assert.Equal(t, LabelReport{
+		Key:            "path",
+		DistinctValues: 3,
+		TopValues:      []LabelValueCount{{Value: "/a", Series: 2}, {Value: "/b", Series: 1}},
+	}, requests.Labels[0])
+	assert.Equal(t, LabelReport{
+		Key:            "status",
+		DistinctValues: 2,
+		TopValues:      []LabelValueCount{{Value: "200", Series: 2}, {Value: "500", Series: 2}},
+	}, requests.Labels[1])
+}
+
+func TestCardinalityReportWithoutLabels(t *testing.T) {
+	// Without a report directory the tracker keeps no labels
+	cfg := createDefaultConfig().(*Config)
+	limiter := NewCardinalityLimiter(cfg, zap.NewNop())
+	_, err := limiter.ProcessMetrics(generateMetrics("test_metric", 3))
+	require.NoError(t, err)
+
+	report := limiter.Report()
+	require.Len(t, report.Metrics, 1)
+	assert.Equal(t, 3, report.Metrics[0].Cardinality)
+	assert.Empty(t, report.Metrics[0].Labels)
+}
+
+func TestWriteReport(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Report.Directory = filepath.Join(t.TempDir(), "reports")
+	cfg.Report.MaxFiles = 2
+	clk := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	proc, err := newCapProcessorWithClock(cfg, zap.NewNop(), consumertest.NewNop(), clk)
+	require.NoError(t, err)
+	proc.id = "nrcap/pods"
+	require.NoError(t, proc.ConsumeMetrics(context.Background(), generateMetrics("test_metric", 3)))
+
+	path, err := proc.writeReport()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(cfg.Report.Directory, "nrcap-nrcap_pods-20240101T120000.000Z.json"), path)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var report CardinalityReport
+	require.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, "nrcap/pods", report.Processor)
+	assert.Equal(t, 3, report.GlobalCardinality)
+	assert.Equal(t, clk.Now(), report.GeneratedAt)
+
+	// Only the newest MaxFiles reports are kept
+	var paths []string
+	for i := 0; i < 3; i++ {
+		clk.Advance(time.Minute)
+		path, err := proc.writeReport()
+		require.NoError(t, err)
+		paths = append(paths, path)
+	}
+	remaining, err := filepath.Glob(filepath.Join(cfg.Report.Directory, "*"))
+	require.NoError(t, err)
+	assert.Equal(t, paths[1:], remaining)
+}
+
+func TestConfigValidateReport(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Report.Directory = "/var/lib/nrdot/nrcap"
+	require.NoError(t, cfg.Validate())
+
+	cfg.Report.TopValues = 0
+	assert.EqualError(t, cfg.Validate(), "report.top_values must be positive")
+
+	cfg.Report.TopValues = 10
+	cfg.Report.MaxFiles = -1
+	assert.EqualError(t, cfg.Validate(), "report.max_files must not be negative")
+}
@@ -0,0 +1,50 @@
+package nrcap
+
+import (
+	"math"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// Scope attributes carrying the cardinality of the metrics in the scope
+const (
+	scopeAttrCardinality = "nrcap.cardinality"
+	scopeAttrLimit       = "nrcap.limit"
+	scopeAttrUtilization = "nrcap.utilization"
+)
+
+// annotateScopes moves each metric of a resource into a copy of its scope
+// annotated with the metric's cardinality. Scope attributes apply to every
+// metric of the scope, so metrics get a scope each; scopes left without
+// metrics are dropped.
+func (cl *CardinalityLimiter) annotateScopes(rm pmetric.ResourceMetrics) {
+	scopes := pmetric.NewScopeMetricsSlice()
+	rm.ScopeMetrics().MoveAndAppendTo(scopes)
+
+	for i := 0; i < scopes.Len(); i++ {
+		sm := scopes.At(i)
+		metrics := sm.Metrics()
+		for j := 0; j < metrics.Len(); j++ {
+			metric := metrics.At(j)
+			annotated := rm.ScopeMetrics().AppendEmpty()
+			annotated.SetSchemaUrl(sm.SchemaUrl())
+			sm.Scope().CopyTo(annotated.Scope())
+			cl.putCardinalityAttributes(annotated.Scope().Attributes(), metric.Name())
+			metric.MoveTo(annotated.Metrics().AppendEmpty())
+		}
+	}
+}
+
+// putCardinalityAttributes sets the cardinality of a metric against its
+// limit, with the utilization rounded to two decimals, e.g. 0.82
+func (cl *CardinalityLimiter) putCardinalityAttributes(attrs pcommon.Map, metricName string) {
+	cardinality := cl.tracker.GetCardinality(metricName)
+	limit := cl.config.metricLimit(metricName).Limit
+
+	attrs.PutInt(scopeAttrCardinality, int64(cardinality))
+	attrs.PutInt(scopeAttrLimit, int64(limit))
+	if limit > 0 {
+		attrs.PutDouble(scopeAttrUtilization, math.Round(float64(cardinality)/float64(limit)*100)/100)
+	}
+}
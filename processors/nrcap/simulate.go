@@ -0,0 +1,550 @@
+package nrcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// Simulation sources
+const (
+	// SimulationSourceOTLP replays recorded data points through the limiter
+	SimulationSourceOTLP = "otlp"
+	// SimulationSourceReport estimates from the series counts of a
+	// cardinality report
+	SimulationSourceReport = "report"
+)
+
+// SimulationReport is what a candidate configuration would do to recorded
+// traffic: the series and data points each metric would keep, and the label
+// keys aggregation, deny labels and label limits would strip
+type SimulationReport struct {
+	Source string `json:"source"`
+
+	// Estimated is set for simulations from a cardinality report, which
+	// only has series counts and label value counts to go on
+	Estimated bool `json:"estimated"`
+
+	// Batches is the number of OTLP batches replayed
+	Batches int `json:"batches,omitempty"`
+
+	GlobalLimit  int `json:"global_limit"`
+	InputSeries  int `json:"input_series"`
+	OutputSeries int `json:"output_series"`
+
+	// Data points are only known when replaying
+	InputPoints  int64 `json:"input_points,omitempty"`
+	OutputPoints int64 `json:"output_points,omitempty"`
+
+	// Metrics are ordered by series lost, most first, then by name
+	Metrics []MetricSimulation `json:"metrics"`
+
+	// Labels are the label keys with a label limit
+	Labels []LabelSimulation `json:"labels,omitempty"`
+}
+
+// MetricSimulation is what a candidate configuration would do to one metric
+type MetricSimulation struct {
+	Name     string   `json:"name"`
+	Strategy Strategy `json:"strategy"`
+	Limit    int      `json:"limit"`
+
+	InputSeries  int `json:"input_series"`
+	OutputSeries int `json:"output_series"`
+
+	InputPoints   int64 `json:"input_points,omitempty"`
+	OutputPoints  int64 `json:"output_points,omitempty"`
+	DroppedPoints int64 `json:"dropped_points,omitempty"`
+
+	// RemovedLabels are the label keys the metric would lose to
+	// aggregation or deny labels
+	RemovedLabels []string `json:"removed_labels,omitempty"`
+
+	// Affected is set when the metric would lose series, data points or
+	// labels
+	Affected bool `json:"affected"`
+}
+
+// LabelSimulation is what a label limit would do to the values of a key
+// across all metrics
+type LabelSimulation struct {
+	Key          string      `json:"key"`
+	Limit        int         `json:"limit"`
+	Action       LabelAction `json:"action"`
+	InputValues  int         `json:"input_values"`
+	OutputValues int         `json:"output_values"`
+}
+
+// NewConfig returns the processor configuration raw describes, as under
+// processors.nrcap in a collector config, on top of the defaults
+func NewConfig(raw map[string]any) (*Config, error) {
+	cfg := createDefaultConfig().(*Config)
+	if err := component.UnmarshalConfig(confmap.NewFromStringMap(raw), cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// SimulateFile simulates cfg against a recorded sample: an OTLP metrics
+// file, see ReadOTLPMetrics, or a cardinality report written on SIGUSR2
+func SimulateFile(cfg *Config, path string) (*SimulationReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var probe map[string]json.RawMessage
+	if json.Unmarshal(data, &probe) == nil && probe["global_cardinality"] != nil {
+		var report CardinalityReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, fmt.Errorf("failed to decode cardinality report: %w", err)
+		}
+		return SimulateReport(cfg, report)
+	}
+
+	batches, err := parseOTLPMetrics(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return Simulate(cfg, batches)
+}
+
+// ReadOTLPMetrics reads recorded metrics in the formats of the file
+// exporter: JSON, one export request per line or a single document, or
+// protobuf, length-prefixed or a single message
+func ReadOTLPMetrics(path string) ([]pmetric.Metrics, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseOTLPMetrics(data)
+}
+
+func parseOTLPMetrics(data []byte) ([]pmetric.Metrics, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, errors.New("no metrics")
+	}
+
+	if trimmed[0] == '{' {
+		var unmarshaler pmetric.JSONUnmarshaler
+		var batches []pmetric.Metrics
+		for _, line := range bytes.Split(trimmed, []byte("\n")) {
+			if line = bytes.TrimSpace(line); len(line) == 0 {
+				continue
+			}
+			md, err := unmarshaler.UnmarshalMetrics(line)
+			if err != nil {
+				// A single document spread over lines
+				md, err = unmarshaler.UnmarshalMetrics(trimmed)
+				if err != nil {
+					return nil, err
+				}
+				return []pmetric.Metrics{md}, nil
+			}
+			batches = append(batches, md)
+		}
+		return batches, nil
+	}
+
+	var unmarshaler pmetric.ProtoUnmarshaler
+	if batches, ok := parseLengthPrefixed(data, &unmarshaler); ok {
+		return batches, nil
+	}
+	md, err := unmarshaler.UnmarshalMetrics(data)
+	if err != nil {
+		return nil, err
+	}
+	return []pmetric.Metrics{md}, nil
+}
+
+// parseLengthPrefixed reads protobuf messages each preceded by its length
+// as a big-endian uint32, as the file exporter writes them. ok is false if
+// data is not made of such messages.
+func parseLengthPrefixed(data []byte, unmarshaler *pmetric.ProtoUnmarshaler) (batches []pmetric.Metrics, ok bool) {
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, false
+		}
+		size := binary.BigEndian.Uint32(data)
+		if uint64(size) > uint64(len(data)-4) {
+			return nil, false
+		}
+		md, err := unmarshaler.UnmarshalMetrics(data[4 : 4+size])
+		if err != nil {
+			return nil, false
+		}
+		batches = append(batches, md)
+		data = data[4+size:]
+	}
+	return batches, len(batches) > 0
+}
+
+// simulationCounts counts the series, data points and label keys of
+// metrics, and the values of limited label keys
+type simulationCounts struct {
+	metrics map[string]*metricCounts
+	labels  map[string]map[string]struct{}
+}
+
+type metricCounts struct {
+	points int64
+	series map[string]struct{}
+	keys   map[string]struct{}
+}
+
+func newSimulationCounts(labelLimits map[string]LabelLimit) *simulationCounts {
+	counts := &simulationCounts{
+		metrics: make(map[string]*metricCounts),
+		labels:  make(map[string]map[string]struct{}),
+	}
+	for key := range labelLimits {
+		counts.labels[key] = make(map[string]struct{})
+	}
+	return counts
+}
+
+func (c *simulationCounts) metric(name string) *metricCounts {
+	m, ok := c.metrics[name]
+	if !ok {
+		m = &metricCounts{series: make(map[string]struct{}), keys: make(map[string]struct{})}
+		c.metrics[name] = m
+	}
+	return m
+}
+
+func (c *simulationCounts) add(md pmetric.Metrics) {
+	resourceMetrics := md.ResourceMetrics()
+	for i := 0; i < resourceMetrics.Len(); i++ {
+		scopeMetrics := resourceMetrics.At(i).ScopeMetrics()
+		for j := 0; j < scopeMetrics.Len(); j++ {
+			ms := scopeMetrics.At(j).Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				m := c.metric(ms.At(k).Name())
+				forEachAttributes(ms.At(k), func(attrs pcommon.Map) {
+					m.points++
+					m.series[strings.Join(labelPairs(attrs), "\x00")] = struct{}{}
+					attrs.Range(func(key string, v pcommon.Value) bool {
+						m.keys[key] = struct{}{}
+						if values, limited := c.labels[key]; limited {
+							values[v.AsString()] = struct{}{}
+						}
+						return true
+					})
+				})
+			}
+		}
+	}
+}
+
+// latestTimestamp returns the time of the latest data point of md, zero
+// without timestamps
+func latestTimestamp(md pmetric.Metrics) time.Time {
+	var latest pcommon.Timestamp
+	observe := func(ts pcommon.Timestamp) {
+		if ts > latest {
+			latest = ts
+		}
+	}
+	resourceMetrics := md.ResourceMetrics()
+	for i := 0; i < resourceMetrics.Len(); i++ {
+		scopeMetrics := resourceMetrics.At(i).ScopeMetrics()
+		for j := 0; j < scopeMetrics.Len(); j++ {
+			ms := scopeMetrics.At(j).Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				metric := ms.At(k)
+				switch metric.Type() {
+				case pmetric.MetricTypeGauge:
+					for n := 0; n < metric.Gauge().DataPoints().Len(); n++ {
+						observe(metric.Gauge().DataPoints().At(n).Timestamp())
+					}
+				case pmetric.MetricTypeSum:
+					for n := 0; n < metric.Sum().DataPoints().Len(); n++ {
+						observe(metric.Sum().DataPoints().At(n).Timestamp())
+					}
+				case pmetric.MetricTypeHistogram:
+					for n := 0; n < metric.Histogram().DataPoints().Len(); n++ {
+						observe(metric.Histogram().DataPoints().At(n).Timestamp())
+					}
+				case pmetric.MetricTypeSummary:
+					for n := 0; n < metric.Summary().DataPoints().Len(); n++ {
+						observe(metric.Summary().DataPoints().At(n).Timestamp())
+					}
+				case pmetric.MetricTypeExponentialHistogram:
+					for n := 0; n < metric.ExponentialHistogram().DataPoints().Len(); n++ {
+						observe(metric.ExponentialHistogram().DataPoints().At(n).Timestamp())
+					}
+				}
+			}
+		}
+	}
+	if latest == 0 {
+		return time.Time{}
+	}
+	return latest.AsTime()
+}
+
+// Simulate replays recorded batches through a limiter configured with cfg,
+// as the processor would receive them, and reports what it would keep. Time
+// follows the data point timestamps, so windows, new series limits and
+// resets apply as they did when the sample was recorded. Sampling is seeded,
+// so runs are repeatable. batches are not modified.
+func Simulate(cfg *Config, batches []pmetric.Metrics) (*SimulationReport, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	start := time.Unix(0, 0).UTC()
+	for _, batch := range batches {
+		if ts := latestTimestamp(batch); !ts.IsZero() {
+			start = ts
+			break
+		}
+	}
+	clk := clock.NewFake(start)
+	limiter := newCardinalityLimiter(cfg, zap.NewNop(), clk)
+	limiter.rand = rand.New(rand.NewSource(1))
+	lastReset := start
+
+	in := newSimulationCounts(cfg.LabelLimits)
+	out := newSimulationCounts(cfg.LabelLimits)
+	for _, batch := range batches {
+		if ts := latestTimestamp(batch); ts.After(clk.Now()) {
+			clk.Advance(ts.Sub(clk.Now()))
+		}
+		if clk.Since(lastReset) >= cfg.ResetInterval {
+			limiter.Reset()
+			lastReset = clk.Now()
+		}
+
+		input := pmetric.NewMetrics()
+		batch.CopyTo(input)
+		in.add(input)
+		output, err := limiter.ProcessMetrics(input)
+		if err != nil {
+			return nil, err
+		}
+		out.add(output)
+	}
+
+	report := &SimulationReport{
+		Source:      SimulationSourceOTLP,
+		Batches:     len(batches),
+		GlobalLimit: cfg.GlobalLimit,
+		Metrics:     make([]MetricSimulation, 0, len(in.metrics)),
+	}
+	for name, before := range in.metrics {
+		policy := cfg.metricLimit(name)
+		metric := MetricSimulation{
+			Name:        name,
+			Strategy:    policy.Strategy,
+			Limit:       policy.Limit,
+			InputSeries: len(before.series),
+			InputPoints: before.points,
+		}
+		if after, ok := out.metrics[name]; ok {
+			metric.OutputSeries = len(after.series)
+			metric.OutputPoints = after.points
+			for key := range before.keys {
+				if _, kept := after.keys[key]; !kept {
+					metric.RemovedLabels = append(metric.RemovedLabels, key)
+				}
+			}
+			sort.Strings(metric.RemovedLabels)
+		}
+		metric.DroppedPoints = metric.InputPoints - metric.OutputPoints
+		report.addMetric(metric)
+	}
+	for key, limit := range cfg.LabelLimits {
+		report.Labels = append(report.Labels, LabelSimulation{
+			Key:          key,
+			Limit:        limit.Limit,
+			Action:       limit.labelAction(),
+			InputValues:  len(in.labels[key]),
+			OutputValues: len(out.labels[key]),
+		})
+	}
+	report.sort()
+	return report, nil
+}
+
+// SimulateReport estimates what cfg would do to the series of a cardinality
+// report. Reports only count series and the values of each label key, so
+// series left after removing labels are estimated from the values of the
+// remaining keys, and the global limit is shared out in report order,
+// highest cardinality first. Label values are counted per metric; a key's
+// values across metrics are at least those of its metric with the most.
+func SimulateReport(cfg *Config, source CardinalityReport) (*SimulationReport, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	report := &SimulationReport{
+		Source:      SimulationSourceReport,
+		Estimated:   true,
+		GlobalLimit: cfg.GlobalLimit,
+		Metrics:     make([]MetricSimulation, 0, len(source.Metrics)),
+	}
+
+	labelValues := make(map[string]int)
+	for _, m := range source.Metrics {
+		for _, label := range m.Labels {
+			labelValues[label.Key] = max(labelValues[label.Key], label.DistinctValues)
+		}
+	}
+
+	budget := cfg.GlobalLimit
+	for _, m := range source.Metrics {
+		policy := cfg.metricLimit(m.Name)
+		metric := MetricSimulation{
+			Name:        m.Name,
+			Strategy:    policy.Strategy,
+			Limit:       policy.Limit,
+			InputSeries: m.Cardinality,
+		}
+
+		// Values per label key left after deny labels and label limits
+		values := make(map[string]int, len(m.Labels))
+		for _, label := range m.Labels {
+			if containsString(cfg.DenyLabels, label.Key) {
+				metric.RemovedLabels = append(metric.RemovedLabels, label.Key)
+				continue
+			}
+			values[label.Key] = label.DistinctValues
+			if limit, ok := cfg.LabelLimits[label.Key]; ok {
+				values[label.Key] = min(label.DistinctValues, labelValueCap(limit))
+			}
+		}
+		series := estimateSeries(m.Cardinality, m.Labels, values)
+
+		switch policy.Strategy {
+		case StrategyAggregate:
+			// Without aggregation labels, aggregating only removes deny
+			// labels
+			if len(policy.AggregationLabels) > 0 {
+				kept := make(map[string]int)
+				for key, n := range values {
+					if containsString(policy.AggregationLabels, key) {
+						kept[key] = n
+					} else {
+						metric.RemovedLabels = append(metric.RemovedLabels, key)
+					}
+				}
+				series = estimateSeries(m.Cardinality, m.Labels, kept)
+			}
+		case StrategyDrop:
+			series = min(series, policy.Limit, max(budget, 0))
+		case StrategySample:
+			admitted := min(series, policy.Limit, max(budget, 0))
+			series = admitted + int(math.Round(float64(series-admitted)*cfg.SampleRate))
+		}
+		// The oldest strategy evicts tracked series but drops no data points
+
+		metric.OutputSeries = series
+		budget -= series
+		sort.Strings(metric.RemovedLabels)
+		report.addMetric(metric)
+	}
+
+	for key, limit := range cfg.LabelLimits {
+		report.Labels = append(report.Labels, LabelSimulation{
+			Key:          key,
+			Limit:        limit.Limit,
+			Action:       limit.labelAction(),
+			InputValues:  labelValues[key],
+			OutputValues: min(labelValues[key], labelValueCap(limit)),
+		})
+	}
+	report.sort()
+	return report, nil
+}
+
+// labelValueCap returns the most values a limited label key keeps: the
+// admitted ones, plus the hash buckets or the label being absent
+func labelValueCap(limit LabelLimit) int {
+	if limit.labelAction() == LabelActionHash {
+		buckets := limit.Buckets
+		if buckets <= 0 {
+			buckets = defaultLabelHashBuckets
+		}
+		return limit.Limit + buckets
+	}
+	return limit.Limit + 1
+}
+
+// estimateSeries estimates the series left of cardinality when the label
+// keys of a metric are reduced to values, a key left out being removed: at
+// most the combinations of the remaining values, scaled down by the values
+// each key lost. Reports only break series down by label when the tracker
+// kept labels; without a breakdown the series are taken as they are.
+func estimateSeries(cardinality int, labels []LabelReport, values map[string]int) int {
+	if len(labels) == 0 {
+		return cardinality
+	}
+	combinations := 1.0
+	scaled := float64(cardinality)
+	for _, label := range labels {
+		n, kept := values[label.Key]
+		if !kept || label.DistinctValues == 0 {
+			continue
+		}
+		combinations *= float64(n)
+		scaled *= float64(n) / float64(label.DistinctValues)
+	}
+	return int(math.Ceil(math.Min(scaled, combinations)))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// addMetric adds a metric to the report totals and list
+func (r *SimulationReport) addMetric(metric MetricSimulation) {
+	metric.Affected = metric.OutputSeries < metric.InputSeries || metric.DroppedPoints > 0 || len(metric.RemovedLabels) > 0
+	r.InputSeries += metric.InputSeries
+	r.OutputSeries += metric.OutputSeries
+	r.InputPoints += metric.InputPoints
+	r.OutputPoints += metric.OutputPoints
+	r.Metrics = append(r.Metrics, metric)
+}
+
+// sort orders metrics by series lost, then data points dropped, then name,
+// and labels by key
+func (r *SimulationReport) sort() {
+	sort.Slice(r.Metrics, func(i, j int) bool {
+		a, b := r.Metrics[i], r.Metrics[j]
+		if lostA, lostB := a.InputSeries-a.OutputSeries, b.InputSeries-b.OutputSeries; lostA != lostB {
+			return lostA > lostB
+		}
+		if a.DroppedPoints != b.DroppedPoints {
+			return a.DroppedPoints > b.DroppedPoints
+		}
+		return a.Name < b.Name
+	})
+	sort.Slice(r.Labels, func(i, j int) bool {
+		return r.Labels[i].Key < r.Labels[j].Key
+	})
+}
@@ -0,0 +1,328 @@
+package nrcap
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func podLabels(n int) []map[string]string {
+	labels := make([]map[string]string, n)
+	for i := range labels {
+		labels[i] = map[string]string{"pod": fmt.Sprintf("pod-%d", i), "namespace": "default"}
+	}
+	return labels
+}
+
+func TestNewConfig(t *testing.T) {
+	cfg, err := NewConfig(map[string]any{
+		"global_limit": 500,
+		"metric_limits": map[string]any{
+			"http_requests_total": 50,
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 500, cfg.GlobalLimit)
+	assert.Equal(t, 50, cfg.metricLimit("http_requests_total").Limit)
+	assert.Equal(t, StrategyDrop, cfg.Strategy)
+
+	_, err = NewConfig(map[string]any{"strategy": "shred"})
+	assert.Error(t, err)
+}
+
+func TestSimulate_Drop(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetricLimits = map[string]MetricLimit{"pod_cpu": {Limit: 10}}
+
+	batch := generateMetricsWithLabels("pod_cpu", podLabels(25))
+	generateMetricsWithLabels("node_cpu", podLabels(3)).ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().MoveAndAppendTo(
+		batch.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics())
+	small := generateMetricsWithLabels("node_cpu", podLabels(3))
+
+	report, err := Simulate(cfg, []pmetric.Metrics{batch, small})
+	require.NoError(t, err)
+
+	assert.Equal(t, SimulationSourceOTLP, report.Source)
+	assert.False(t, report.Estimated)
+	assert.Equal(t, 2, report.Batches)
+	require.Len(t, report.Metrics, 2)
+
+	pods := report.Metrics[0]
+	assert.Equal(t, "pod_cpu", pods.Name)
+	assert.Equal(t, StrategyDrop, pods.Strategy)
+	assert.Equal(t, 25, pods.InputSeries)
+	assert.Equal(t, 10, pods.OutputSeries)
+	assert.Equal(t, int64(15), pods.DroppedPoints)
+	assert.True(t, pods.Affected)
+
+	nodes := report.Metrics[1]
+	assert.Equal(t, "node_cpu", nodes.Name)
+	assert.Equal(t, 3, nodes.OutputSeries)
+	assert.Equal(t, int64(6), nodes.InputPoints)
+	assert.False(t, nodes.Affected)
+
+	assert.Equal(t, 28, report.InputSeries)
+	assert.Equal(t, 13, report.OutputSeries)
+
+	// The sample is left as recorded
+	assert.Equal(t, 28, countDataPoints(batch))
+}
+
+func TestSimulate_AggregateAndDenyLabels(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.DenyLabels = []string{"namespace"}
+	cfg.MetricLimits = map[string]MetricLimit{
+		"pod_cpu": {Limit: 5, Strategy: StrategyAggregate, AggregationLabels: []string{"node"}},
+	}
+
+	labels := podLabels(8)
+	for i, l := range labels {
+		l["node"] = fmt.Sprintf("node-%d", i%2)
+	}
+	report, err := Simulate(cfg, []pmetric.Metrics{generateMetricsWithLabels("pod_cpu", labels)})
+	require.NoError(t, err)
+
+	require.Len(t, report.Metrics, 1)
+	metric := report.Metrics[0]
+	assert.Equal(t, 8, metric.InputSeries)
+	assert.Equal(t, 2, metric.OutputSeries)
+	assert.Equal(t, []string{"namespace", "pod"}, metric.RemovedLabels)
+	assert.True(t, metric.Affected)
+}
+
+func TestSimulate_LabelLimits(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.LabelLimits = map[string]LabelLimit{"pod": {Limit: 4, Action: LabelActionHash, Buckets: 2}}
+
+	report, err := Simulate(cfg, []pmetric.Metrics{generateMetricsWithLabels("pod_cpu", podLabels(20))})
+	require.NoError(t, err)
+
+	require.Len(t, report.Labels, 1)
+	label := report.Labels[0]
+	assert.Equal(t, "pod", label.Key)
+	assert.Equal(t, LabelActionHash, label.Action)
+	assert.Equal(t, 20, label.InputValues)
+	assert.LessOrEqual(t, label.OutputValues, 6)
+	assert.Greater(t, label.OutputValues, 4)
+	assert.Equal(t, label.OutputValues, report.Metrics[0].OutputSeries)
+}
+
+func TestSimulate_Repeatable(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Strategy = StrategySample
+	cfg.SampleRate = 0.5
+	cfg.DefaultLimit = 10
+
+	batches := []pmetric.Metrics{generateMetricsWithLabels("pod_cpu", podLabels(100))}
+	first, err := Simulate(cfg, batches)
+	require.NoError(t, err)
+	second, err := Simulate(cfg, batches)
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.Greater(t, first.Metrics[0].OutputSeries, 10)
+	assert.Less(t, first.Metrics[0].OutputSeries, 100)
+}
+
+func TestSimulate_ResetInterval(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.DefaultLimit = 5
+	cfg.ResetInterval = time.Hour
+
+	start := time.Now()
+	batch := func(at time.Time, first int) pmetric.Metrics {
+		labels := make([]map[string]string, 5)
+		for i := range labels {
+			labels[i] = map[string]string{"pod": fmt.Sprintf("pod-%d", first+i)}
+		}
+		md := generateMetricsWithLabels("pod_cpu", labels)
+		dps := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			dps.At(i).SetTimestamp(pcommon.NewTimestampFromTime(at))
+		}
+		return md
+	}
+
+	// The second five pods only fit once tracking resets
+	report, err := Simulate(cfg, []pmetric.Metrics{
+		batch(start, 0),
+		batch(start.Add(time.Minute), 5),
+		batch(start.Add(2*time.Hour), 5),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 10, report.Metrics[0].InputSeries)
+	assert.Equal(t, 10, report.Metrics[0].OutputSeries)
+	assert.Equal(t, int64(5), report.Metrics[0].DroppedPoints)
+}
+
+func TestSimulateReport(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.GlobalLimit = 1000
+	cfg.DenyLabels = []string{"request_id"}
+	cfg.MetricLimits = map[string]MetricLimit{
+		"http_requests_total": {Limit: 100},
+		"pod_cpu":             {Limit: 50, Strategy: StrategyAggregate, AggregationLabels: []string{"namespace"}},
+	}
+
+	source := CardinalityReport{
+		GlobalCardinality: 5210,
+		GlobalLimit:       10000,
+		Metrics: []MetricReport{
+			{
+				Name:        "http_requests_total",
+				Cardinality: 5000,
+				Labels: []LabelReport{
+					{Key: "request_id", DistinctValues: 5000},
+					{Key: "route", DistinctValues: 40},
+					{Key: "status", DistinctValues: 5},
+				},
+			},
+			{
+				Name:        "pod_cpu",
+				Cardinality: 200,
+				Labels: []LabelReport{
+					{Key: "pod", DistinctValues: 200},
+					{Key: "namespace", DistinctValues: 4},
+				},
+			},
+			{
+				Name:        "node_cpu",
+				Cardinality: 10,
+				Labels:      []LabelReport{{Key: "node", DistinctValues: 10}},
+			},
+		},
+	}
+
+	report, err := SimulateReport(cfg, source)
+	require.NoError(t, err)
+	assert.Equal(t, SimulationSourceReport, report.Source)
+	assert.True(t, report.Estimated)
+	require.Len(t, report.Metrics, 3)
+
+	byName := make(map[string]MetricSimulation)
+	for _, m := range report.Metrics {
+		byName[m.Name] = m
+	}
+
+	// Without request_id, at most one series per route and status
+	http := byName["http_requests_total"]
+	assert.Equal(t, 100, http.OutputSeries)
+	assert.Equal(t, []string{"request_id"}, http.RemovedLabels)
+
+	pods := byName["pod_cpu"]
+	assert.Equal(t, 4, pods.OutputSeries)
+	assert.Equal(t, []string{"pod"}, pods.RemovedLabels)
+
+	nodes := byName["node_cpu"]
+	assert.Equal(t, 10, nodes.OutputSeries)
+	assert.False(t, nodes.Affected)
+
+	assert.Equal(t, "http_requests_total", report.Metrics[0].Name)
+	assert.Equal(t, 5210, report.InputSeries)
+	assert.Equal(t, 114, report.OutputSeries)
+}
+
+func TestSimulateReport_GlobalLimit(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.GlobalLimit = 150
+	cfg.DefaultLimit = 100
+
+	source := CardinalityReport{Metrics: []MetricReport{
+		{Name: "a", Cardinality: 120},
+		{Name: "b", Cardinality: 80},
+	}}
+	report, err := SimulateReport(cfg, source)
+	require.NoError(t, err)
+
+	byName := make(map[string]MetricSimulation)
+	for _, m := range report.Metrics {
+		byName[m.Name] = m
+	}
+	assert.Equal(t, 100, byName["a"].OutputSeries)
+	assert.Equal(t, 50, byName["b"].OutputSeries)
+}
+
+func TestSimulateFile(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.DefaultLimit = 10
+	dir := t.TempDir()
+
+	first := generateMetricsWithLabels("pod_cpu", podLabels(8))
+	second := generateMetricsWithLabels("pod_cpu", podLabels(12))
+
+	var jsonMarshaler pmetric.JSONMarshaler
+	var lines []byte
+	for _, md := range []pmetric.Metrics{first, second} {
+		data, err := jsonMarshaler.MarshalMetrics(md)
+		require.NoError(t, err)
+		lines = append(append(lines, data...), '\n')
+	}
+
+	var protoMarshaler pmetric.ProtoMarshaler
+	var prefixed []byte
+	for _, md := range []pmetric.Metrics{first, second} {
+		data, err := protoMarshaler.MarshalMetrics(md)
+		require.NoError(t, err)
+		prefixed = binary.BigEndian.AppendUint32(prefixed, uint32(len(data)))
+		prefixed = append(prefixed, data...)
+	}
+	single, err := protoMarshaler.MarshalMetrics(second)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		batches int
+	}{
+		{name: "metrics.json", data: lines, batches: 2},
+		{name: "metrics.pb", data: prefixed, batches: 2},
+		{name: "single.pb", data: single, batches: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			require.NoError(t, os.WriteFile(path, tt.data, 0o600))
+
+			batches, err := ReadOTLPMetrics(path)
+			require.NoError(t, err)
+			assert.Len(t, batches, tt.batches)
+
+			report, err := SimulateFile(cfg, path)
+			require.NoError(t, err)
+			assert.Equal(t, SimulationSourceOTLP, report.Source)
+			assert.Equal(t, 12, report.Metrics[0].InputSeries)
+			assert.Equal(t, 10, report.Metrics[0].OutputSeries)
+		})
+	}
+
+	t.Run("report", func(t *testing.T) {
+		data, err := json.Marshal(CardinalityReport{
+			GlobalCardinality: 20,
+			Metrics:           []MetricReport{{Name: "pod_cpu", Cardinality: 20}},
+		})
+		require.NoError(t, err)
+		path := filepath.Join(dir, "report.json")
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+
+		report, err := SimulateFile(cfg, path)
+		require.NoError(t, err)
+		assert.Equal(t, SimulationSourceReport, report.Source)
+		assert.Equal(t, 10, report.Metrics[0].OutputSeries)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		path := filepath.Join(dir, "empty.json")
+		require.NoError(t, os.WriteFile(path, nil, 0o600))
+		_, err := SimulateFile(cfg, path)
+		assert.Error(t, err)
+	})
+}
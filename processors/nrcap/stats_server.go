@@ -0,0 +1,188 @@
+package nrcap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultStatsTop is how many metrics and label keys the stats endpoint
+// lists unless asked for another number
+const defaultStatsTop = 20
+
+// LiveStats is a snapshot of the processor served on its stats endpoint.
+// The counters are cumulative since the processor started, so clients
+// polling the endpoint derive rates from successive snapshots.
+type LiveStats struct {
+	// Processor is the component ID of the processor, e.g. nrcap/pods
+	Processor string `json:"processor"`
+
+	GeneratedAt time.Time `json:"generated_at"`
+	LastReset   time.Time `json:"last_reset"`
+
+	GlobalCardinality int `json:"global_cardinality"`
+	GlobalLimit       int `json:"global_limit"`
+
+	// PeerCardinality is the series of the other instances sharing the
+	// global limit, see CoordinationConfig
+	PeerCardinality int `json:"peer_cardinality,omitempty"`
+
+	TotalMetrics        int64 `json:"total_metrics"`
+	DroppedMetrics      int64 `json:"dropped_metrics"`
+	AggregatedMetrics   int64 `json:"aggregated_metrics"`
+	SampledMetrics      int64 `json:"sampled_metrics"`
+	ChurnDroppedMetrics int64 `json:"churn_dropped_metrics"`
+	LabelLimitedMetrics int64 `json:"label_limited_metrics"`
+
+	// Metrics are ordered by cardinality, highest first
+	Metrics []MetricStats `json:"metrics"`
+
+	// Labels are label keys across all metrics, ordered by distinct values,
+	// most first
+	Labels []LabelStats `json:"labels"`
+}
+
+// MetricStats is the cardinality of one metric against its limit
+type MetricStats struct {
+	Name        string   `json:"name"`
+	Cardinality int      `json:"cardinality"`
+	Limit       int      `json:"limit"`
+	Strategy    Strategy `json:"strategy"`
+}
+
+// LabelStats is the distinct values of one label key
+type LabelStats struct {
+	Key            string `json:"key"`
+	DistinctValues int    `json:"distinct_values"`
+
+	// Limit is the label limit of the key, 0 when it has none
+	Limit int `json:"limit,omitempty"`
+}
+
+// LiveStats returns a snapshot of the tracked cardinality, listing the top
+// metrics and label keys
+func (cl *CardinalityLimiter) LiveStats(top int) LiveStats {
+	stats := cl.GetStats()
+	live := LiveStats{
+		GeneratedAt:         cl.tracker.clock.Now().UTC(),
+		LastReset:           stats.LastReset.UTC(),
+		GlobalCardinality:   cl.tracker.GetGlobalCardinality(),
+		GlobalLimit:         cl.config.GlobalLimit,
+		TotalMetrics:        stats.TotalMetrics,
+		DroppedMetrics:      stats.DroppedMetrics,
+		AggregatedMetrics:   stats.AggregatedMetrics,
+		SampledMetrics:      stats.SampledMetrics,
+		ChurnDroppedMetrics: stats.ChurnDroppedMetrics,
+		LabelLimitedMetrics: stats.LabelLimitedMetrics,
+		Metrics:             make([]MetricStats, 0, len(stats.MetricCardinalities)),
+		Labels:              make([]LabelStats, 0, len(stats.HighCardinalityLabels)),
+	}
+	if cl.coordinator != nil {
+		live.PeerCardinality = cl.coordinator.peerCardinality()
+	}
+
+	for name, cardinality := range stats.MetricCardinalities {
+		limit := cl.config.metricLimit(name)
+		live.Metrics = append(live.Metrics, MetricStats{
+			Name:        name,
+			Cardinality: cardinality,
+			Limit:       limit.Limit,
+			Strategy:    limit.Strategy,
+		})
+	}
+	sort.Slice(live.Metrics, func(i, j int) bool {
+		a, b := live.Metrics[i], live.Metrics[j]
+		if a.Cardinality != b.Cardinality {
+			return a.Cardinality > b.Cardinality
+		}
+		return a.Name < b.Name
+	})
+	if len(live.Metrics) > top {
+		live.Metrics = live.Metrics[:top]
+	}
+
+	for key, values := range stats.HighCardinalityLabels {
+		live.Labels = append(live.Labels, LabelStats{Key: key, DistinctValues: values, Limit: cl.config.LabelLimits[key].Limit})
+	}
+	sort.Slice(live.Labels, func(i, j int) bool {
+		a, b := live.Labels[i], live.Labels[j]
+		if a.DistinctValues != b.DistinctValues {
+			return a.DistinctValues > b.DistinctValues
+		}
+		return a.Key < b.Key
+	})
+	if len(live.Labels) > top {
+		live.Labels = live.Labels[:top]
+	}
+
+	return live
+}
+
+// startStatsServer serves the live statistics on the configured endpoint
+func (p *capProcessor) startStatsServer() error {
+	listener, err := net.Listen("tcp", p.config.StatsServer.Endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to listen on stats endpoint: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", p.handleStats)
+	p.statsServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	p.logger.Info("Serving cardinality statistics", zap.String("endpoint", listener.Addr().String()))
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		if err := p.statsServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			p.logger.Error("Stats endpoint failed", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// stopStatsServer stops serving statistics
+func (p *capProcessor) stopStatsServer(ctx context.Context) {
+	if p.statsServer == nil {
+		return
+	}
+	if err := p.statsServer.Shutdown(ctx); err != nil {
+		p.statsServer.Close()
+	}
+}
+
+// handleStats serves GET /stats, listing the top metrics and label keys;
+// ?top=N changes how many (default 20)
+func (p *capProcessor) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	top := defaultStatsTop
+	if value := r.URL.Query().Get("top"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "top must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+
+	stats := p.limiter.LiveStats(top)
+	stats.Processor = p.id
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
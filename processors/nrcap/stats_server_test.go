@@ -0,0 +1,82 @@
+package nrcap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap"
+)
+
+func TestLiveStats(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.StatsServer.Endpoint = "127.0.0.1:0"
+	cfg.MetricLimits = map[string]MetricLimit{
+		"http_requests": {Limit: 50, Strategy: StrategyOldest},
+	}
+
+	proc, err := newCapProcessor(cfg, zap.NewNop(), consumertest.NewNop())
+	require.NoError(t, err)
+	proc.id = "nrcap/pods"
+	require.NoError(t, proc.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, proc.Shutdown(context.Background()))
+	}()
+
+	require.NoError(t, proc.ConsumeMetrics(context.Background(), generateMetricsWithLabels("http_requests", []map[string]string{
+		{"path": "/a", "status": "200"},
+		{"path": "/b", "status": "200"},
+		{"path": "/c", "status": "500"},
+	})))
+	require.NoError(t, proc.ConsumeMetrics(context.Background(), generateMetricsWithLabels("queue_depth", []map[string]string{
+		{"queue": "orders"},
+	})))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		proc.handleStats(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	w := get("/stats")
+	require.Equal(t, http.StatusOK, w.Code)
+	var stats LiveStats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, "nrcap/pods", stats.Processor)
+	assert.Equal(t, 4, stats.GlobalCardinality)
+	assert.Equal(t, cfg.GlobalLimit, stats.GlobalLimit)
+	assert.Equal(t, int64(4), stats.TotalMetrics)
+	assert.Equal(t, []MetricStats{
+		{Name: "http_requests", Cardinality: 3, Limit: 50, Strategy: StrategyOldest},
+		{Name: "queue_depth", Cardinality: 1, Limit: cfg.DefaultLimit, Strategy: cfg.Strategy},
+	}, stats.Metrics)
+	assert.Equal(t, []LabelStats{
+		{Key: "path", DistinctValues: 3},
+		{Key: "status", DistinctValues: 2},
+		{Key: "queue", DistinctValues: 1},
+	}, stats.Labels)
+
+	// top limits both lists
+	w = get("/stats?top=1")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Len(t, stats.Metrics, 1)
+	assert.Len(t, stats.Labels, 1)
+
+	assert.Equal(t, http.StatusBadRequest, get("/stats?top=zero").Code)
+}
+
+func TestStatsServerListenFailure(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.StatsServer.Endpoint = "256.0.0.1:1"
+
+	proc, err := newCapProcessor(cfg, zap.NewNop(), consumertest.NewNop())
+	require.NoError(t, err)
+	assert.Error(t, proc.Start(context.Background(), componenttest.NewNopHost()))
+}
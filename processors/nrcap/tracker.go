@@ -1,210 +1,316 @@
 package nrcap
 
 import (
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cespare/xxhash/v2"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 )
 
-// CardinalityTracker tracks metric cardinality
+const (
+	// shardBits is the number of hash prefix bits used to select a shard
+	shardBits = 6
+	// shardCount is the number of lock-striped shards in the tracker
+	shardCount = 1 << shardBits
+)
+
+// seriesEntry is a tracked label combination
+type seriesEntry struct {
+	metricName string
+	lastSeen   int64 // unix nanoseconds
+
+	// labels holds sorted key, value pairs, only kept for reports
+	labels []string
+}
+
+// seriesShard holds the label combinations whose hash prefix maps to it
+type seriesShard struct {
+	mu     sync.Mutex
+	series map[uint64]*seriesEntry
+}
+
+// countShard holds per-metric cardinality counts for a subset of metric names
+type countShard struct {
+	mu     sync.RWMutex
+	counts map[string]int
+}
+
+// CardinalityTracker tracks metric cardinality.
+//
+// Label combinations are spread across shards keyed by the top bits of their
+// hash, and per-metric counts are striped by metric name, so concurrent
+// ProcessMetrics calls only contend when they touch the same shard.
 type CardinalityTracker struct {
-	mu sync.RWMutex
+	// Label hash -> series, striped by hash prefix
+	series [shardCount]seriesShard
 
-	// Metric name -> label hash -> timestamp
-	metrics map[string]map[uint64]time.Time
+	// Metric name -> cardinality count, striped by metric name hash
+	metricCounts [shardCount]countShard
 
 	// Global cardinality count
-	globalCount int
-
-	// Metric name -> cardinality count
-	metricCounts map[string]int
+	globalCount atomic.Int64
 
 	// Configuration
 	windowSize time.Duration
 
+	// Time source for the window and reset times
+	clock clock.Clock
+
+	// keepLabels retains each series' labels for cardinality reports
+	keepLabels bool
+
 	// Statistics
-	stats CardinalityStats
+	stats trackerStats
+}
+
+// trackerStats holds the tracker's live counters
+type trackerStats struct {
+	totalMetrics      atomic.Int64
+	droppedMetrics    atomic.Int64
+	aggregatedMetrics atomic.Int64
+	sampledMetrics    atomic.Int64
+	churnDropped      atomic.Int64
+	labelLimited      atomic.Int64
+
+	lastReset atomic.Int64 // unix nanoseconds
+
+	labelsMu              sync.RWMutex
+	highCardinalityLabels map[string]int
 }
 
 // CardinalityStats holds cardinality statistics
 type CardinalityStats struct {
-	mu sync.RWMutex
-
 	TotalMetrics      int64
 	DroppedMetrics    int64
 	AggregatedMetrics int64
 	SampledMetrics    int64
-	
-	MetricCardinalities map[string]int
+
+	// ChurnDroppedMetrics counts data points of new series dropped by the
+	// new series rate limit
+	ChurnDroppedMetrics int64
+
+	// LabelLimitedMetrics counts data points whose label values were
+	// dropped or hashed by a label limit
+	LabelLimitedMetrics int64
+
+	MetricCardinalities   map[string]int
 	HighCardinalityLabels map[string]int
-	
+
 	LastReset time.Time
 }
 
 // NewCardinalityTracker creates a new cardinality tracker
 func NewCardinalityTracker(windowSize time.Duration) *CardinalityTracker {
-	return &CardinalityTracker{
-		metrics:      make(map[string]map[uint64]time.Time),
-		metricCounts: make(map[string]int),
-		windowSize:   windowSize,
-		stats: CardinalityStats{
-			MetricCardinalities:   make(map[string]int),
-			HighCardinalityLabels: make(map[string]int),
-			LastReset:            time.Now(),
-		},
+	return newCardinalityTracker(windowSize, clock.Real())
+}
+
+// newCardinalityTracker creates a cardinality tracker on the given clock
+func newCardinalityTracker(windowSize time.Duration, clk clock.Clock) *CardinalityTracker {
+	ct := &CardinalityTracker{
+		windowSize: windowSize,
+		clock:      clk,
 	}
+	for i := range ct.series {
+		ct.series[i].series = make(map[uint64]*seriesEntry)
+	}
+	for i := range ct.metricCounts {
+		ct.metricCounts[i].counts = make(map[string]int)
+	}
+	ct.stats.highCardinalityLabels = make(map[string]int)
+	ct.stats.lastReset.Store(clk.Now().UnixNano())
+	return ct
 }
 
 // Track tracks a metric and all its data points, returns true if any are new
 func (ct *CardinalityTracker) Track(metric pmetric.Metric) (bool, uint64) {
 	metricName := metric.Name()
-	
+
 	// Get all unique label combinations for this metric
 	labelHashes := ct.getAllLabelHashes(metric)
 	if len(labelHashes) == 0 {
 		return false, 0
 	}
-	
-	anyNew := false
-	var firstHash uint64
-	
-	// Track all data points
-	for i, labelHash := range labelHashes {
-		if i == 0 {
-			firstHash = labelHash
-		}
-		
-		ct.mu.Lock()
-		// Initialize metric map if needed
-		if _, exists := ct.metrics[metricName]; !exists {
-			ct.metrics[metricName] = make(map[uint64]time.Time)
-		}
 
-		// Check if this label combination exists
-		if _, exists := ct.metrics[metricName][labelHash]; exists {
-			// Update timestamp
-			ct.metrics[metricName][labelHash] = time.Now()
-		} else {
-			// New label combination
-			ct.metrics[metricName][labelHash] = time.Now()
-			ct.metricCounts[metricName]++
-			ct.globalCount++
+	anyNew := false
+	now := ct.clock.Now().UnixNano()
+	for _, labelHash := range labelHashes {
+		if ct.touch(metricName, labelHash, now, nil) {
 			anyNew = true
 		}
-		ct.mu.Unlock()
 	}
 
-	// Update stats
-	ct.mu.Lock()
-	ct.stats.MetricCardinalities[metricName] = ct.metricCounts[metricName]
-	ct.mu.Unlock()
+	return anyNew, labelHashes[0]
+}
+
+// touch records a sighting of a label combination and returns true if it is
+// new. labels, if not nil, is called for the labels of a new series.
+func (ct *CardinalityTracker) touch(metricName string, labelHash uint64, now int64, labels func() []string) bool {
+	shard := ct.seriesShard(labelHash)
+
+	shard.mu.Lock()
+	if entry, exists := shard.series[labelHash]; exists {
+		entry.lastSeen = now
+		shard.mu.Unlock()
+		return false
+	}
+	entry := &seriesEntry{metricName: metricName, lastSeen: now}
+	if labels != nil {
+		entry.labels = labels()
+	}
+	shard.series[labelHash] = entry
+	ct.addCount(metricName, 1)
+	shard.mu.Unlock()
+
+	return true
+}
+
+// seriesShard returns the shard owning a label hash
+func (ct *CardinalityTracker) seriesShard(labelHash uint64) *seriesShard {
+	return &ct.series[labelHash>>(64-shardBits)]
+}
 
-	return anyNew, firstHash
+// countShard returns the shard owning a metric name's count
+func (ct *CardinalityTracker) countShard(metricName string) *countShard {
+	return &ct.metricCounts[xxhash.Sum64String(metricName)>>(64-shardBits)]
+}
+
+// addCount adjusts the per-metric and global counts by delta. Callers hold the
+// series shard lock for the affected label hash so counts never lag the maps.
+func (ct *CardinalityTracker) addCount(metricName string, delta int) {
+	shard := ct.countShard(metricName)
+
+	shard.mu.Lock()
+	count := shard.counts[metricName] + delta
+	if count <= 0 {
+		delete(shard.counts, metricName)
+	} else {
+		shard.counts[metricName] = count
+	}
+	shard.mu.Unlock()
+
+	ct.globalCount.Add(int64(delta))
 }
 
 // GetCardinality returns the current cardinality for a metric
 func (ct *CardinalityTracker) GetCardinality(metricName string) int {
-	ct.mu.RLock()
-	defer ct.mu.RUnlock()
-	
-	return ct.metricCounts[metricName]
+	shard := ct.countShard(metricName)
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	return shard.counts[metricName]
 }
 
 // GetGlobalCardinality returns the total cardinality across all metrics
 func (ct *CardinalityTracker) GetGlobalCardinality() int {
-	ct.mu.RLock()
-	defer ct.mu.RUnlock()
-	
-	return ct.globalCount
+	return int(ct.globalCount.Load())
 }
 
 // CleanupOldEntries removes entries older than the window size
 func (ct *CardinalityTracker) CleanupOldEntries() {
-	ct.mu.Lock()
-	defer ct.mu.Unlock()
-
-	now := time.Now()
-	cutoff := now.Add(-ct.windowSize)
-
-	for metricName, labelMap := range ct.metrics {
-		for hash, timestamp := range labelMap {
-			if timestamp.Before(cutoff) {
-				delete(labelMap, hash)
-				ct.metricCounts[metricName]--
-				ct.globalCount--
+	cutoff := ct.clock.Now().Add(-ct.windowSize).UnixNano()
+
+	for i := range ct.series {
+		shard := &ct.series[i]
+
+		shard.mu.Lock()
+		expired := make(map[string]int)
+		for hash, entry := range shard.series {
+			if entry.lastSeen < cutoff {
+				delete(shard.series, hash)
+				expired[entry.metricName]++
 			}
 		}
-
-		// Remove empty metric entries
-		if len(labelMap) == 0 {
-			delete(ct.metrics, metricName)
-			delete(ct.metricCounts, metricName)
+		for metricName, count := range expired {
+			ct.addCount(metricName, -count)
 		}
+		shard.mu.Unlock()
 	}
 }
 
 // Reset clears all tracking data
 func (ct *CardinalityTracker) Reset() {
-	ct.mu.Lock()
-	defer ct.mu.Unlock()
-
-	ct.metrics = make(map[string]map[uint64]time.Time)
-	ct.metricCounts = make(map[string]int)
-	ct.globalCount = 0
+	// Hold every series shard so no new series slip in between clearing the
+	// maps and clearing the counts.
+	for i := range ct.series {
+		ct.series[i].mu.Lock()
+	}
+	for i := range ct.series {
+		ct.series[i].series = make(map[uint64]*seriesEntry)
+	}
+	for i := range ct.metricCounts {
+		shard := &ct.metricCounts[i]
+		shard.mu.Lock()
+		shard.counts = make(map[string]int)
+		shard.mu.Unlock()
+	}
+	ct.globalCount.Store(0)
+	for i := range ct.series {
+		ct.series[i].mu.Unlock()
+	}
 
-	ct.stats.LastReset = time.Now()
+	ct.stats.lastReset.Store(ct.clock.Now().UnixNano())
 }
 
 // GetStats returns current statistics
 func (ct *CardinalityTracker) GetStats() CardinalityStats {
-	ct.stats.mu.RLock()
-	defer ct.stats.mu.RUnlock()
-
-	// Create a copy of the stats
 	statsCopy := CardinalityStats{
-		TotalMetrics:      ct.stats.TotalMetrics,
-		DroppedMetrics:    ct.stats.DroppedMetrics,
-		AggregatedMetrics: ct.stats.AggregatedMetrics,
-		SampledMetrics:    ct.stats.SampledMetrics,
-		LastReset:         ct.stats.LastReset,
+		TotalMetrics:          ct.stats.totalMetrics.Load(),
+		DroppedMetrics:        ct.stats.droppedMetrics.Load(),
+		AggregatedMetrics:     ct.stats.aggregatedMetrics.Load(),
+		SampledMetrics:        ct.stats.sampledMetrics.Load(),
+		ChurnDroppedMetrics:   ct.stats.churnDropped.Load(),
+		LabelLimitedMetrics:   ct.stats.labelLimited.Load(),
+		LastReset:             time.Unix(0, ct.stats.lastReset.Load()),
 		MetricCardinalities:   make(map[string]int),
 		HighCardinalityLabels: make(map[string]int),
 	}
 
-	for k, v := range ct.stats.MetricCardinalities {
-		statsCopy.MetricCardinalities[k] = v
+	for i := range ct.metricCounts {
+		shard := &ct.metricCounts[i]
+		shard.mu.RLock()
+		for k, v := range shard.counts {
+			statsCopy.MetricCardinalities[k] = v
+		}
+		shard.mu.RUnlock()
 	}
-	for k, v := range ct.stats.HighCardinalityLabels {
+
+	ct.stats.labelsMu.RLock()
+	for k, v := range ct.stats.highCardinalityLabels {
 		statsCopy.HighCardinalityLabels[k] = v
 	}
+	ct.stats.labelsMu.RUnlock()
 
 	return statsCopy
 }
 
 // IncrementStats increments various statistics
 func (ct *CardinalityTracker) IncrementStats(statType string) {
-	ct.stats.mu.Lock()
-	defer ct.stats.mu.Unlock()
-
 	switch statType {
 	case "total":
-		ct.stats.TotalMetrics++
+		ct.stats.totalMetrics.Add(1)
 	case "dropped":
-		ct.stats.DroppedMetrics++
+		ct.stats.droppedMetrics.Add(1)
 	case "aggregated":
-		ct.stats.AggregatedMetrics++
+		ct.stats.aggregatedMetrics.Add(1)
 	case "sampled":
-		ct.stats.SampledMetrics++
+		ct.stats.sampledMetrics.Add(1)
+	case "churn_dropped":
+		ct.stats.churnDropped.Add(1)
+	case "label_limited":
+		ct.stats.labelLimited.Add(1)
 	}
 }
 
 // getAllLabelHashes returns all unique label hashes for a metric
 func (ct *CardinalityTracker) getAllLabelHashes(metric pmetric.Metric) []uint64 {
 	var hashes []uint64
-	
+
 	switch metric.Type() {
 	case pmetric.MetricTypeGauge:
 		dps := metric.Gauge().DataPoints()
@@ -237,34 +343,27 @@ func (ct *CardinalityTracker) getAllLabelHashes(metric pmetric.Metric) []uint64
 			hashes = append(hashes, hash)
 		}
 	}
-	
+
 	return hashes
 }
 
 // hashDataPointLabels creates a hash from metric name and attributes
 func (ct *CardinalityTracker) hashDataPointLabels(metricName string, attrs pcommon.Map) uint64 {
-	h := xxhash.New()
-	
+	var h xxhash.Digest
+	h.Reset()
+
 	// Include metric name in hash
 	h.WriteString(metricName)
 	h.WriteString("|")
-	
+
 	// Collect and sort attribute keys for consistent hashing
 	keys := make([]string, 0, attrs.Len())
 	attrs.Range(func(k string, v pcommon.Value) bool {
 		keys = append(keys, k)
 		return true
 	})
-	
-	// Simple sort
-	for i := 0; i < len(keys)-1; i++ {
-		for j := i + 1; j < len(keys); j++ {
-			if keys[i] > keys[j] {
-				keys[i], keys[j] = keys[j], keys[i]
-			}
-		}
-	}
-	
+	sort.Strings(keys)
+
 	// Hash sorted attributes
 	for _, k := range keys {
 		v, _ := attrs.Get(k)
@@ -273,7 +372,7 @@ func (ct *CardinalityTracker) hashDataPointLabels(metricName string, attrs pcomm
 		h.WriteString(v.AsString())
 		h.WriteString("|")
 	}
-	
+
 	return h.Sum64()
 }
 
@@ -288,43 +387,41 @@ func (ct *CardinalityTracker) hashLabels(metric pmetric.Metric) uint64 {
 
 // TrackLabelCardinality tracks cardinality of individual labels
 func (ct *CardinalityTracker) TrackLabelCardinality(labelName string, uniqueValues int) {
-	ct.stats.mu.Lock()
-	defer ct.stats.mu.Unlock()
+	ct.stats.labelsMu.Lock()
+	defer ct.stats.labelsMu.Unlock()
 
-	ct.stats.HighCardinalityLabels[labelName] = uniqueValues
+	ct.stats.highCardinalityLabels[labelName] = uniqueValues
 }
 
 // GetOldestEntries returns the oldest entries for a metric
 func (ct *CardinalityTracker) GetOldestEntries(metricName string, count int) []uint64 {
-	ct.mu.RLock()
-	defer ct.mu.RUnlock()
-
-	labelMap, exists := ct.metrics[metricName]
-	if !exists {
+	if count <= 0 || ct.GetCardinality(metricName) == 0 {
 		return nil
 	}
 
 	// Create slice of hash-timestamp pairs
 	type entry struct {
-		hash      uint64
-		timestamp time.Time
-	}
-	
-	entries := make([]entry, 0, len(labelMap))
-	for hash, timestamp := range labelMap {
-		entries = append(entries, entry{hash, timestamp})
+		hash     uint64
+		lastSeen int64
 	}
 
-	// Sort by timestamp (oldest first)
-	// Simple bubble sort for now (can optimize later)
-	for i := 0; i < len(entries)-1; i++ {
-		for j := 0; j < len(entries)-i-1; j++ {
-			if entries[j].timestamp.After(entries[j+1].timestamp) {
-				entries[j], entries[j+1] = entries[j+1], entries[j]
+	var entries []entry
+	for i := range ct.series {
+		shard := &ct.series[i]
+		shard.mu.Lock()
+		for hash, e := range shard.series {
+			if e.metricName == metricName {
+				entries = append(entries, entry{hash, e.lastSeen})
 			}
 		}
+		shard.mu.Unlock()
 	}
 
+	// Sort by timestamp (oldest first)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastSeen < entries[j].lastSeen
+	})
+
 	// Return oldest hashes
 	result := make([]uint64, 0, count)
 	for i := 0; i < count && i < len(entries); i++ {
@@ -336,44 +433,53 @@ func (ct *CardinalityTracker) GetOldestEntries(metricName string, count int) []u
 
 // RemoveEntry removes a specific entry
 func (ct *CardinalityTracker) RemoveEntry(metricName string, labelHash uint64) {
-	ct.mu.Lock()
-	defer ct.mu.Unlock()
-
-	if labelMap, exists := ct.metrics[metricName]; exists {
-		if _, exists := labelMap[labelHash]; exists {
-			delete(labelMap, labelHash)
-			ct.metricCounts[metricName]--
-			ct.globalCount--
-		}
+	shard := ct.seriesShard(labelHash)
+
+	shard.mu.Lock()
+	entry, exists := shard.series[labelHash]
+	if !exists || entry.metricName != metricName {
+		shard.mu.Unlock()
+		return
 	}
+	delete(shard.series, labelHash)
+	ct.addCount(metricName, -1)
+	shard.mu.Unlock()
+}
+
+// Contains reports whether a label combination is tracked
+func (ct *CardinalityTracker) Contains(labelHash uint64) bool {
+	shard := ct.seriesShard(labelHash)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	_, exists := shard.series[labelHash]
+	return exists
 }
 
 // TrackDataPoint tracks a single data point by metric name and attributes
 func (ct *CardinalityTracker) TrackDataPoint(metricName string, attrs pcommon.Map) (bool, uint64) {
 	labelHash := ct.hashDataPointLabels(metricName, attrs)
-	
-	ct.mu.Lock()
-	defer ct.mu.Unlock()
-
-	// Initialize metric map if needed
-	if _, exists := ct.metrics[metricName]; !exists {
-		ct.metrics[metricName] = make(map[uint64]time.Time)
-	}
 
-	// Check if this label combination exists
-	if _, exists := ct.metrics[metricName][labelHash]; exists {
-		// Update timestamp
-		ct.metrics[metricName][labelHash] = time.Now()
-		return false, labelHash
+	var labels func() []string
+	if ct.keepLabels {
+		labels = func() []string { return labelPairs(attrs) }
 	}
+	return ct.touch(metricName, labelHash, ct.clock.Now().UnixNano(), labels), labelHash
+}
 
-	// New label combination
-	ct.metrics[metricName][labelHash] = time.Now()
-	ct.metricCounts[metricName]++
-	ct.globalCount++
-
-	// Update stats
-	ct.stats.MetricCardinalities[metricName] = ct.metricCounts[metricName]
+// labelPairs returns attrs as key, value pairs sorted by key
+func labelPairs(attrs pcommon.Map) []string {
+	keys := make([]string, 0, attrs.Len())
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	sort.Strings(keys)
 
-	return true, labelHash
-}
\ No newline at end of file
+	pairs := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		v, _ := attrs.Get(k)
+		pairs = append(pairs, k, v.AsString())
+	}
+	return pairs
+}
@@ -1,9 +1,12 @@
 package nrcap
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
 	"github.com/stretchr/testify/assert"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
@@ -15,11 +18,12 @@ func TestNewCardinalityTracker(t *testing.T) {
 
 	assert.NotNil(t, tracker)
 	assert.Equal(t, windowSize, tracker.windowSize)
-	assert.NotNil(t, tracker.metrics)
-	assert.NotNil(t, tracker.metricCounts)
-	assert.Equal(t, 0, tracker.globalCount)
-	assert.NotNil(t, tracker.stats.MetricCardinalities)
-	assert.NotNil(t, tracker.stats.HighCardinalityLabels)
+	for i := range tracker.series {
+		assert.NotNil(t, tracker.series[i].series)
+		assert.NotNil(t, tracker.metricCounts[i].counts)
+	}
+	assert.Equal(t, 0, tracker.GetGlobalCardinality())
+	assert.NotNil(t, tracker.stats.highCardinalityLabels)
 }
 
 func TestTrack(t *testing.T) {
@@ -76,18 +80,22 @@ func TestTrackMultipleMetrics(t *testing.T) {
 }
 
 func TestCleanupOldEntries(t *testing.T) {
-	windowSize := 100 * time.Millisecond
-	tracker := NewCardinalityTracker(windowSize)
+	windowSize := 5 * time.Minute
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	tracker := newCardinalityTracker(windowSize, clk)
 
 	// Track a metric
 	metric := createTestMetric("test_metric", map[string]string{"label": "value"})
 	tracker.Track(metric)
 	assert.Equal(t, 1, tracker.GetCardinality("test_metric"))
 
-	// Wait for window to expire
-	time.Sleep(150 * time.Millisecond)
+	// Entries inside the window are kept
+	clk.Advance(windowSize)
+	tracker.CleanupOldEntries()
+	assert.Equal(t, 1, tracker.GetCardinality("test_metric"))
 
-	// Cleanup should remove the old entry
+	// Cleanup should remove the old entry once the window has passed
+	clk.Advance(time.Nanosecond)
 	tracker.CleanupOldEntries()
 	assert.Equal(t, 0, tracker.GetCardinality("test_metric"))
 	assert.Equal(t, 0, tracker.GetGlobalCardinality())
@@ -112,8 +120,7 @@ func TestTrackerReset(t *testing.T) {
 
 	assert.Equal(t, 0, tracker.GetCardinality("test_metric"))
 	assert.Equal(t, 0, tracker.GetGlobalCardinality())
-	assert.NotNil(t, tracker.metrics)
-	assert.NotNil(t, tracker.metricCounts)
+	assert.Empty(t, tracker.GetStats().MetricCardinalities)
 }
 
 func TestGetStats(t *testing.T) {
@@ -184,15 +191,16 @@ func TestHashLabels(t *testing.T) {
 }
 
 func TestGetOldestEntries(t *testing.T) {
-	tracker := NewCardinalityTracker(5 * time.Minute)
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	tracker := newCardinalityTracker(5*time.Minute, clk)
 
-	// Track metrics with delays to ensure different timestamps
+	// Track metrics at different times
 	for i := 0; i < 5; i++ {
 		metric := createTestMetric("test_metric", map[string]string{
 			"label": string(rune('a' + i)),
 		})
 		tracker.Track(metric)
-		time.Sleep(10 * time.Millisecond)
+		clk.Advance(time.Second)
 	}
 
 	// Get oldest 3 entries
@@ -296,6 +304,56 @@ func TestDifferentMetricTypes(t *testing.T) {
 	}
 }
 
+func TestConcurrentTracking(t *testing.T) {
+	tracker := NewCardinalityTracker(5 * time.Minute)
+
+	const workers = 8
+	const seriesPerWorker = 500
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < seriesPerWorker; i++ {
+				attrs := pcommon.NewMap()
+				attrs.PutStr("worker", fmt.Sprintf("%d", w))
+				attrs.PutStr("series", fmt.Sprintf("%d", i))
+				tracker.TrackDataPoint(fmt.Sprintf("metric_%d", w%2), attrs)
+				// Every series is seen twice; only the first sighting is new
+				tracker.TrackDataPoint(fmt.Sprintf("metric_%d", w%2), attrs)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	assert.Equal(t, workers*seriesPerWorker, tracker.GetGlobalCardinality())
+	assert.Equal(t, workers/2*seriesPerWorker, tracker.GetCardinality("metric_0"))
+	assert.Equal(t, workers/2*seriesPerWorker, tracker.GetCardinality("metric_1"))
+
+	stats := tracker.GetStats()
+	assert.Equal(t, workers/2*seriesPerWorker, stats.MetricCardinalities["metric_0"])
+}
+
+func BenchmarkTrackDataPointParallel(b *testing.B) {
+	tracker := NewCardinalityTracker(5 * time.Minute)
+
+	attrs := make([]pcommon.Map, 10000)
+	for i := range attrs {
+		attrs[i] = pcommon.NewMap()
+		attrs[i].PutStr("series", fmt.Sprintf("%d", i))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			tracker.TrackDataPoint("bench_metric", attrs[i%len(attrs)])
+			i++
+		}
+	})
+}
+
 // Helper function to create a test metric
 func createTestMetric(name string, labels map[string]string) pmetric.Metric {
 	metrics := pmetric.NewMetrics()
@@ -0,0 +1,202 @@
+package nrhostcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// State is the outcome of a check run
+type State string
+
+// Check states, following the Nagios plugin convention
+const (
+	StateOK       State = "ok"
+	StateWarning  State = "warning"
+	StateCritical State = "critical"
+)
+
+// code returns the Nagios-style numeric value of a state
+func (s State) code() int64 {
+	switch s {
+	case StateOK:
+		return 0
+	case StateWarning:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// maxScriptOutput bounds the script output kept as the check message
+const maxScriptOutput = 1024
+
+// result is the outcome of one check run
+type result struct {
+	State    State
+	Message  string
+	Duration time.Duration
+
+	// HTTPStatus is set by http checks that got a response
+	HTTPStatus int
+
+	// UsedPercent is set by disk checks that could read the filesystem
+	UsedPercent float64
+	HasUsage    bool
+}
+
+// runCheck runs a check with its timeout applied
+func runCheck(ctx context.Context, check *CheckConfig, timeout time.Duration) result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	var res result
+	switch check.Type {
+	case CheckTCP:
+		res = checkTCP(ctx, check)
+	case CheckHTTP:
+		res = checkHTTP(ctx, check)
+	case CheckDNS:
+		res = checkDNS(ctx, check)
+	case CheckDisk:
+		res = checkDisk(check)
+	case CheckScript:
+		res = checkScript(ctx, check)
+	default:
+		res = result{State: StateCritical, Message: fmt.Sprintf("unknown check type %q", check.Type)}
+	}
+	res.Duration = time.Since(start)
+	return res
+}
+
+// checkTCP connects to the target
+func checkTCP(ctx context.Context, check *CheckConfig) result {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", check.Target)
+	if err != nil {
+		return result{State: StateCritical, Message: err.Error()}
+	}
+	conn.Close()
+	return result{State: StateOK, Message: "connected to " + check.Target}
+}
+
+// checkHTTP requests the target and checks the response status
+func checkHTTP(ctx context.Context, check *CheckConfig) result {
+	method := check.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, check.Target, nil)
+	if err != nil {
+		return result{State: StateCritical, Message: err.Error()}
+	}
+	req.Header.Set("User-Agent", "nrdot-hostcheck")
+
+	// Redirects are reported as-is so 3xx can be checked
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return result{State: StateCritical, Message: err.Error()}
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	res := result{HTTPStatus: resp.StatusCode, Message: resp.Status}
+	if statusExpected(resp.StatusCode, check.ExpectedStatus) {
+		res.State = StateOK
+	} else {
+		res.State = StateCritical
+		res.Message = "unexpected status " + resp.Status
+	}
+	return res
+}
+
+// statusExpected reports whether an HTTP status is accepted; with no
+// expected statuses any 2xx or 3xx is
+func statusExpected(status int, expected []int) bool {
+	if len(expected) == 0 {
+		return status >= 200 && status < 400
+	}
+	for _, s := range expected {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDNS resolves the target host name
+func checkDNS(ctx context.Context, check *CheckConfig) result {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, check.Target)
+	if err != nil {
+		return result{State: StateCritical, Message: err.Error()}
+	}
+	return result{State: StateOK, Message: "resolved to " + strings.Join(addrs, ", ")}
+}
+
+// checkDisk compares the used space of the target filesystem to the threshold
+func checkDisk(check *CheckConfig) result {
+	used, err := diskUsedPercent(check.Target)
+	if err != nil {
+		return result{State: StateCritical, Message: err.Error()}
+	}
+
+	threshold := check.MaxUsedPercent
+	if threshold == 0 {
+		threshold = 90
+	}
+
+	res := result{UsedPercent: used, HasUsage: true, Message: fmt.Sprintf("%s %.1f%% used", check.Target, used)}
+	if used > threshold {
+		res.State = StateCritical
+		res.Message = fmt.Sprintf("%s %.1f%% used, above %.1f%%", check.Target, used, threshold)
+	} else {
+		res.State = StateOK
+	}
+	return res
+}
+
+// checkScript runs the command; its first output line becomes the message
+func checkScript(ctx context.Context, check *CheckConfig) result {
+	cmd := exec.CommandContext(ctx, check.Command, check.Args...)
+	output, err := cmd.CombinedOutput()
+
+	message := strings.TrimSpace(string(output))
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		message = message[:i]
+	}
+	if len(message) > maxScriptOutput {
+		message = message[:maxScriptOutput]
+	}
+
+	if ctx.Err() != nil {
+		return result{State: StateCritical, Message: "timed out"}
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return result{State: StateOK, Message: message}
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		return result{State: StateWarning, Message: message}
+	case errors.As(err, &exitErr):
+		if message == "" {
+			message = err.Error()
+		}
+		return result{State: StateCritical, Message: message}
+	default:
+		return result{State: StateCritical, Message: err.Error()}
+	}
+}
@@ -0,0 +1,108 @@
+package nrhostcheck
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+
+	res := runCheck(context.Background(), &CheckConfig{Type: CheckTCP, Target: addr}, time.Second)
+	assert.Equal(t, StateOK, res.State)
+
+	listener.Close()
+	res = runCheck(context.Background(), &CheckConfig{Type: CheckTCP, Target: addr}, time.Second)
+	assert.Equal(t, StateCritical, res.State)
+}
+
+func TestCheckHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/moved":
+			http.Redirect(w, r, "/health", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path     string
+		expected []int
+		state    State
+		status   int
+	}{
+		{"/health", nil, StateOK, 200},
+		{"/down", nil, StateCritical, 503},
+		{"/moved", nil, StateOK, 302},
+		{"/moved", []int{200}, StateCritical, 302},
+		{"/down", []int{503}, StateOK, 503},
+	}
+
+	for _, tt := range tests {
+		check := &CheckConfig{Type: CheckHTTP, Target: server.URL + tt.path, ExpectedStatus: tt.expected}
+		res := runCheck(context.Background(), check, time.Second)
+		assert.Equal(t, tt.state, res.State, "%s expecting %v", tt.path, tt.expected)
+		assert.Equal(t, tt.status, res.HTTPStatus, tt.path)
+	}
+}
+
+func TestCheckDNS(t *testing.T) {
+	res := runCheck(context.Background(), &CheckConfig{Type: CheckDNS, Target: "localhost"}, time.Second)
+	assert.Equal(t, StateOK, res.State)
+
+	res = runCheck(context.Background(), &CheckConfig{Type: CheckDNS, Target: "does-not-exist.invalid"}, time.Second)
+	assert.Equal(t, StateCritical, res.State)
+}
+
+func TestCheckDisk(t *testing.T) {
+	dir := t.TempDir()
+
+	res := runCheck(context.Background(), &CheckConfig{Type: CheckDisk, Target: dir, MaxUsedPercent: 100}, time.Second)
+	assert.Equal(t, StateOK, res.State)
+	assert.True(t, res.HasUsage)
+	assert.GreaterOrEqual(t, res.UsedPercent, 0.0)
+	assert.LessOrEqual(t, res.UsedPercent, 100.0)
+
+	res = runCheck(context.Background(), &CheckConfig{Type: CheckDisk, Target: filepath.Join(dir, "missing")}, time.Second)
+	assert.Equal(t, StateCritical, res.State)
+	assert.False(t, res.HasUsage)
+}
+
+func TestCheckScript(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "check.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho \"exit $1\"\necho second line\nexit $1\n"), 0755))
+
+	tests := []struct {
+		code  string
+		state State
+	}{
+		{"0", StateOK},
+		{"1", StateWarning},
+		{"2", StateCritical},
+		{"3", StateCritical},
+	}
+	for _, tt := range tests {
+		res := runCheck(context.Background(), &CheckConfig{Type: CheckScript, Command: script, Args: []string{tt.code}}, 5*time.Second)
+		assert.Equal(t, tt.state, res.State, "exit %s", tt.code)
+		assert.Equal(t, "exit "+tt.code, res.Message)
+	}
+
+	res := runCheck(context.Background(), &CheckConfig{Type: CheckScript, Command: "sleep", Args: []string{"5"}}, 50*time.Millisecond)
+	assert.Equal(t, StateCritical, res.State)
+	assert.Equal(t, "timed out", res.Message)
+}
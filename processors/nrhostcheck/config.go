@@ -0,0 +1,177 @@
+package nrhostcheck
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+// CheckType identifies what a check probes
+type CheckType string
+
+const (
+	// CheckTCP connects to a host:port
+	CheckTCP CheckType = "tcp"
+	// CheckHTTP requests a URL and checks the response status
+	CheckHTTP CheckType = "http"
+	// CheckDNS resolves a host name
+	CheckDNS CheckType = "dns"
+	// CheckDisk compares the used space of a filesystem to a threshold
+	CheckDisk CheckType = "disk"
+	// CheckScript runs a command and maps its exit code Nagios style:
+	// 0 ok, 1 warning, anything else critical
+	CheckScript CheckType = "script"
+)
+
+// Config configures the host check receiver
+type Config struct {
+	// CollectionInterval is how often checks run unless a check sets its own
+	CollectionInterval time.Duration `mapstructure:"collection_interval"`
+
+	// Timeout bounds each check unless a check sets its own
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// Checks are the checks to run
+	Checks []CheckConfig `mapstructure:"checks"`
+}
+
+// CheckConfig configures a single check
+type CheckConfig struct {
+	// Name identifies the check in metrics and events; must be unique
+	Name string `mapstructure:"name"`
+
+	// Type is one of tcp, http, dns, disk or script
+	Type CheckType `mapstructure:"type"`
+
+	// Target is what the check probes: host:port for tcp, a URL for http, a
+	// host name for dns and a mount path for disk
+	Target string `mapstructure:"target"`
+
+	// Interval overrides the receiver collection interval
+	Interval time.Duration `mapstructure:"interval"`
+
+	// Timeout overrides the receiver timeout
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// Method is the HTTP method (default GET)
+	Method string `mapstructure:"method"`
+
+	// ExpectedStatus lists the accepted HTTP status codes (default any 2xx or 3xx)
+	ExpectedStatus []int `mapstructure:"expected_status"`
+
+	// MaxUsedPercent is the disk usage above which the check fails (default 90)
+	MaxUsedPercent float64 `mapstructure:"max_used_percent"`
+
+	// Command and Args are the script to run
+	Command string   `mapstructure:"command"`
+	Args    []string `mapstructure:"args"`
+
+	// Attributes are added to the check's metrics and events
+	Attributes map[string]string `mapstructure:"attributes"`
+}
+
+// createDefaultConfig returns the default config
+func createDefaultConfig() component.Config {
+	return &Config{
+		CollectionInterval: 60 * time.Second,
+		Timeout:            10 * time.Second,
+	}
+}
+
+// Validate checks if the configuration is valid
+func (cfg *Config) Validate() error {
+	if cfg.CollectionInterval <= 0 {
+		return errors.New("collection_interval must be positive")
+	}
+
+	if cfg.Timeout <= 0 {
+		return errors.New("timeout must be positive")
+	}
+
+	if len(cfg.Checks) == 0 {
+		return errors.New("at least one check must be configured")
+	}
+
+	names := make(map[string]bool)
+	for i, check := range cfg.Checks {
+		if check.Name == "" {
+			return fmt.Errorf("checks[%d]: name is required", i)
+		}
+		if names[check.Name] {
+			return fmt.Errorf("checks[%d]: duplicate check name %q", i, check.Name)
+		}
+		names[check.Name] = true
+
+		if err := check.validate(); err != nil {
+			return fmt.Errorf("check %q: %w", check.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// validate checks the type-specific settings of a check
+func (c *CheckConfig) validate() error {
+	if c.Interval < 0 {
+		return errors.New("interval must not be negative")
+	}
+	if c.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+
+	switch c.Type {
+	case CheckTCP:
+		if _, _, err := net.SplitHostPort(c.Target); err != nil {
+			return fmt.Errorf("target must be host:port: %w", err)
+		}
+	case CheckHTTP:
+		u, err := url.Parse(c.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("target must be an http(s) URL")
+		}
+		for _, status := range c.ExpectedStatus {
+			if status < 100 || status > 599 {
+				return fmt.Errorf("invalid expected_status %d", status)
+			}
+		}
+	case CheckDNS:
+		if c.Target == "" {
+			return errors.New("target host name is required")
+		}
+	case CheckDisk:
+		if c.Target == "" {
+			return errors.New("target path is required")
+		}
+		if c.MaxUsedPercent < 0 || c.MaxUsedPercent > 100 {
+			return errors.New("max_used_percent must be between 0 and 100")
+		}
+	case CheckScript:
+		if c.Command == "" {
+			return errors.New("command is required")
+		}
+	default:
+		return fmt.Errorf("invalid type %q: use tcp, http, dns, disk or script", c.Type)
+	}
+
+	return nil
+}
+
+// interval returns the effective run interval of a check
+func (c *CheckConfig) interval(cfg *Config) time.Duration {
+	if c.Interval > 0 {
+		return c.Interval
+	}
+	return cfg.CollectionInterval
+}
+
+// timeout returns the effective timeout of a check
+func (c *CheckConfig) timeout(cfg *Config) time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return cfg.Timeout
+}
@@ -0,0 +1,111 @@
+package nrhostcheck
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/confmap"
+)
+
+func TestConfigUnmarshal(t *testing.T) {
+	conf := confmap.NewFromStringMap(map[string]any{
+		"collection_interval": "30s",
+		"checks": []any{
+			map[string]any{"name": "postgres", "type": "tcp", "target": "localhost:5432"},
+			map[string]any{
+				"name":            "api",
+				"type":            "http",
+				"target":          "http://localhost:8080/health",
+				"expected_status": []any{200, 204},
+				"timeout":         "2s",
+			},
+			map[string]any{"name": "root", "type": "disk", "target": "/", "max_used_percent": 85},
+		},
+	})
+
+	cfg := createDefaultConfig().(*Config)
+	require.NoError(t, conf.Unmarshal(cfg))
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, 30*time.Second, cfg.CollectionInterval)
+	require.Len(t, cfg.Checks, 3)
+	assert.Equal(t, []int{200, 204}, cfg.Checks[1].ExpectedStatus)
+	assert.Equal(t, 2*time.Second, cfg.Checks[1].timeout(cfg))
+	assert.Equal(t, 10*time.Second, cfg.Checks[0].timeout(cfg))
+	assert.Equal(t, 30*time.Second, cfg.Checks[2].interval(cfg))
+	assert.Equal(t, 85.0, cfg.Checks[2].MaxUsedPercent)
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		checks  []CheckConfig
+		wantErr string
+	}{
+		{
+			name:    "no checks",
+			wantErr: "at least one check",
+		},
+		{
+			name:    "missing name",
+			checks:  []CheckConfig{{Type: CheckTCP, Target: "localhost:22"}},
+			wantErr: "name is required",
+		},
+		{
+			name: "duplicate name",
+			checks: []CheckConfig{
+				{Name: "a", Type: CheckTCP, Target: "localhost:22"},
+				{Name: "a", Type: CheckDNS, Target: "localhost"},
+			},
+			wantErr: "duplicate check name",
+		},
+		{
+			name:    "tcp without port",
+			checks:  []CheckConfig{{Name: "a", Type: CheckTCP, Target: "localhost"}},
+			wantErr: "host:port",
+		},
+		{
+			name:    "http without scheme",
+			checks:  []CheckConfig{{Name: "a", Type: CheckHTTP, Target: "localhost/health"}},
+			wantErr: "http(s) URL",
+		},
+		{
+			name:    "disk threshold out of range",
+			checks:  []CheckConfig{{Name: "a", Type: CheckDisk, Target: "/", MaxUsedPercent: 120}},
+			wantErr: "max_used_percent",
+		},
+		{
+			name:    "script without command",
+			checks:  []CheckConfig{{Name: "a", Type: CheckScript}},
+			wantErr: "command is required",
+		},
+		{
+			name:    "unknown type",
+			checks:  []CheckConfig{{Name: "a", Type: "icmp", Target: "localhost"}},
+			wantErr: "invalid type",
+		},
+		{
+			name: "valid",
+			checks: []CheckConfig{
+				{Name: "ssh", Type: CheckTCP, Target: "localhost:22"},
+				{Name: "resolver", Type: CheckDNS, Target: "example.com"},
+				{Name: "cron", Type: CheckScript, Command: "/bin/true"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.Checks = tt.checks
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
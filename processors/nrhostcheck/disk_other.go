@@ -0,0 +1,10 @@
+// +build !linux,!darwin,!freebsd
+
+package nrhostcheck
+
+import "fmt"
+
+// diskUsedPercent is not implemented on this platform
+func diskUsedPercent(path string) (float64, error) {
+	return 0, fmt.Errorf("disk checks are not supported on this platform")
+}
@@ -0,0 +1,24 @@
+// +build linux darwin freebsd
+
+package nrhostcheck
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// diskUsedPercent returns the used space of the filesystem holding path, as
+// reported by df: used / (used + available to unprivileged users)
+func diskUsedPercent(path string) (float64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, fmt.Errorf("statfs %s: %w", path, err)
+	}
+
+	used := uint64(stat.Blocks) - uint64(stat.Bfree)
+	total := used + uint64(stat.Bavail)
+	if total == 0 {
+		return 0, nil
+	}
+	return float64(used) / float64(total) * 100, nil
+}
@@ -0,0 +1,43 @@
+// Package nrhostcheck provides a receiver that runs lightweight local checks
+// and emits their results through the collector pipeline.
+//
+// It replaces ad-hoc cron checks on hosts consolidated onto NRDOT: each check
+// reports metrics on every run, and a log event whenever its state changes.
+//
+// Check types:
+//   - tcp: connect to host:port
+//   - http: request a URL and check the response status
+//   - dns: resolve a host name
+//   - disk: compare filesystem usage to a threshold
+//   - script: run a command, mapping exit codes Nagios style
+//     (0 ok, 1 warning, anything else critical)
+//
+// Metrics, with check.name, check.type, check.target and check.state attributes:
+//   - nrhostcheck.status: 0 ok, 1 warning, 2 critical
+//   - nrhostcheck.duration: check duration in milliseconds
+//   - nrhostcheck.http.status_code: http checks only
+//   - nrhostcheck.disk.used_percent: disk checks only
+//
+// Example configuration:
+//
+//	receivers:
+//	  nrhostcheck:
+//	    collection_interval: 60s
+//	    timeout: 10s
+//	    checks:
+//	      - name: postgres
+//	        type: tcp
+//	        target: localhost:5432
+//	      - name: api-health
+//	        type: http
+//	        target: http://localhost:8080/health
+//	        expected_status: [200]
+//	      - name: root-disk
+//	        type: disk
+//	        target: /
+//	        max_used_percent: 85
+//	      - name: backup-fresh
+//	        type: script
+//	        command: /usr/local/bin/check_backup.sh
+//	        interval: 5m
+package nrhostcheck
@@ -0,0 +1,95 @@
+package nrhostcheck
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/receiver"
+)
+
+var errInvalidConfig = errors.New("invalid configuration")
+
+const (
+	// typeStr is the type string for this receiver
+	typeStr = "nrhostcheck"
+	// stability is the stability level of this receiver
+	stability = component.StabilityLevelAlpha
+)
+
+// receivers shares one running receiver between the metrics and logs
+// pipelines of the same config, so each check runs once per interval
+var receivers = struct {
+	sync.Mutex
+	byConfig map[*Config]*hostCheckReceiver
+}{byConfig: make(map[*Config]*hostCheckReceiver)}
+
+// NewFactory returns a new factory for the host check receiver
+func NewFactory() receiver.Factory {
+	return receiver.NewFactory(
+		typeStr,
+		createDefaultConfig,
+		receiver.WithMetrics(createMetricsReceiver, stability),
+		receiver.WithLogs(createLogsReceiver, stability),
+	)
+}
+
+// createMetricsReceiver creates a receiver emitting check results as metrics
+func createMetricsReceiver(
+	ctx context.Context,
+	set receiver.CreateSettings,
+	cfg component.Config,
+	nextConsumer consumer.Metrics,
+) (receiver.Metrics, error) {
+	r, err := sharedReceiver(set, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.metricsConsumer = nextConsumer
+	r.mu.Unlock()
+	return r, nil
+}
+
+// createLogsReceiver creates a receiver emitting check state changes as log events
+func createLogsReceiver(
+	ctx context.Context,
+	set receiver.CreateSettings,
+	cfg component.Config,
+	nextConsumer consumer.Logs,
+) (receiver.Logs, error) {
+	r, err := sharedReceiver(set, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.logsConsumer = nextConsumer
+	r.mu.Unlock()
+	return r, nil
+}
+
+// sharedReceiver returns the receiver for cfg, creating it on first use
+func sharedReceiver(set receiver.CreateSettings, cfg component.Config) (*hostCheckReceiver, error) {
+	receiverCfg, ok := cfg.(*Config)
+	if !ok {
+		return nil, errInvalidConfig
+	}
+
+	if err := receiverCfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	receivers.Lock()
+	defer receivers.Unlock()
+
+	r, exists := receivers.byConfig[receiverCfg]
+	if !exists {
+		r = newHostCheckReceiver(receiverCfg, set.Logger)
+		receivers.byConfig[receiverCfg] = r
+	}
+	return r, nil
+}
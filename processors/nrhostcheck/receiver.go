@@ -0,0 +1,244 @@
+package nrhostcheck
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+const (
+	// scopeName is the instrumentation scope of emitted metrics and events
+	scopeName = "github.com/newrelic/nrdot-host/processors/nrhostcheck"
+
+	// stateChangeEvent is the event.name of check state change log records
+	stateChangeEvent = "nrhostcheck.state_change"
+)
+
+// hostCheckReceiver runs the configured checks and emits their results as
+// metrics and their state changes as log events. One instance serves both
+// the metrics and logs pipelines of a receiver config.
+type hostCheckReceiver struct {
+	config *Config
+	logger *zap.Logger
+
+	mu              sync.Mutex
+	metricsConsumer consumer.Metrics
+	logsConsumer    consumer.Logs
+	states          map[string]State
+	starts          int
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
+}
+
+// newHostCheckReceiver creates a receiver without consumers
+func newHostCheckReceiver(cfg *Config, logger *zap.Logger) *hostCheckReceiver {
+	return &hostCheckReceiver{
+		config: cfg,
+		logger: logger,
+		states: make(map[string]State),
+	}
+}
+
+// Start starts the check runners on the first call
+func (r *hostCheckReceiver) Start(ctx context.Context, host component.Host) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.starts++
+	if r.starts > 1 {
+		return nil
+	}
+
+	// The start context ends with Start; runners live until Shutdown
+	runCtx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	for i := range r.config.Checks {
+		check := &r.config.Checks[i]
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			r.runLoop(runCtx, check)
+		}()
+	}
+
+	r.logger.Info("Host checks started", zap.Int("checks", len(r.config.Checks)))
+	return nil
+}
+
+// Shutdown stops the check runners once every pipeline has shut down
+func (r *hostCheckReceiver) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	if r.starts == 0 {
+		r.mu.Unlock()
+		return nil
+	}
+	r.starts--
+	if r.starts > 0 {
+		r.mu.Unlock()
+		return nil
+	}
+	cancel := r.cancel
+	r.mu.Unlock()
+
+	cancel()
+
+	receivers.Lock()
+	delete(receivers.byConfig, r.config)
+	receivers.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runLoop runs a check immediately and then on its interval
+func (r *hostCheckReceiver) runLoop(ctx context.Context, check *CheckConfig) {
+	ticker := time.NewTicker(check.interval(r.config))
+	defer ticker.Stop()
+
+	for {
+		r.runOnce(ctx, check)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce runs a check and emits its result
+func (r *hostCheckReceiver) runOnce(ctx context.Context, check *CheckConfig) {
+	res := runCheck(ctx, check, check.timeout(r.config))
+	if ctx.Err() != nil {
+		return // shutting down
+	}
+	now := pcommon.NewTimestampFromTime(time.Now())
+
+	r.mu.Lock()
+	previous := r.states[check.Name]
+	r.states[check.Name] = res.State
+	metricsConsumer := r.metricsConsumer
+	logsConsumer := r.logsConsumer
+	r.mu.Unlock()
+
+	if metricsConsumer != nil {
+		if err := metricsConsumer.ConsumeMetrics(ctx, buildMetrics(check, res, now)); err != nil {
+			r.logger.Warn("Failed to emit host check metrics", zap.String("check", check.Name), zap.Error(err))
+		}
+	}
+
+	// A first run that passes is not a change worth an event
+	if res.State == previous || (previous == "" && res.State == StateOK) {
+		return
+	}
+
+	r.logger.Info("Host check state changed",
+		zap.String("check", check.Name),
+		zap.String("from", string(previous)),
+		zap.String("to", string(res.State)),
+		zap.String("message", res.Message))
+
+	if logsConsumer != nil {
+		if err := logsConsumer.ConsumeLogs(ctx, buildStateChangeEvent(check, res, previous, now)); err != nil {
+			r.logger.Warn("Failed to emit host check event", zap.String("check", check.Name), zap.Error(err))
+		}
+	}
+}
+
+// putCheckAttributes sets the attributes identifying a check
+func putCheckAttributes(attrs pcommon.Map, check *CheckConfig, state State) {
+	attrs.PutStr("check.name", check.Name)
+	attrs.PutStr("check.type", string(check.Type))
+	if check.Type == CheckScript {
+		attrs.PutStr("check.target", check.Command)
+	} else {
+		attrs.PutStr("check.target", check.Target)
+	}
+	attrs.PutStr("check.state", string(state))
+	for k, v := range check.Attributes {
+		attrs.PutStr(k, v)
+	}
+}
+
+// buildMetrics converts a check result into metrics
+func buildMetrics(check *CheckConfig, res result, now pcommon.Timestamp) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	sm := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName(scopeName)
+
+	addGauge := func(name, description, unit string) pmetric.NumberDataPoint {
+		m := sm.Metrics().AppendEmpty()
+		m.SetName(name)
+		m.SetDescription(description)
+		m.SetUnit(unit)
+		dp := m.SetEmptyGauge().DataPoints().AppendEmpty()
+		dp.SetTimestamp(now)
+		putCheckAttributes(dp.Attributes(), check, res.State)
+		return dp
+	}
+
+	addGauge("nrhostcheck.status", "Check state: 0 ok, 1 warning, 2 critical", "1").
+		SetIntValue(res.State.code())
+	addGauge("nrhostcheck.duration", "Time the check took", "ms").
+		SetDoubleValue(float64(res.Duration) / float64(time.Millisecond))
+
+	if res.HTTPStatus > 0 {
+		addGauge("nrhostcheck.http.status_code", "HTTP response status code", "1").
+			SetIntValue(int64(res.HTTPStatus))
+	}
+	if res.HasUsage {
+		addGauge("nrhostcheck.disk.used_percent", "Used space of the filesystem", "%").
+			SetDoubleValue(res.UsedPercent)
+	}
+
+	return md
+}
+
+// buildStateChangeEvent converts a check state change into a log event
+func buildStateChangeEvent(check *CheckConfig, res result, previous State, now pcommon.Timestamp) plog.Logs {
+	ld := plog.NewLogs()
+	sl := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty()
+	sl.Scope().SetName(scopeName)
+
+	record := sl.LogRecords().AppendEmpty()
+	record.SetTimestamp(now)
+	record.SetObservedTimestamp(now)
+	switch res.State {
+	case StateOK:
+		record.SetSeverityNumber(plog.SeverityNumberInfo)
+	case StateWarning:
+		record.SetSeverityNumber(plog.SeverityNumberWarn)
+	default:
+		record.SetSeverityNumber(plog.SeverityNumberError)
+	}
+	record.SetSeverityText(record.SeverityNumber().String())
+	record.Body().SetStr("Check " + check.Name + " is " + string(res.State) + ": " + res.Message)
+
+	attrs := record.Attributes()
+	attrs.PutStr("event.name", stateChangeEvent)
+	putCheckAttributes(attrs, check, res.State)
+	if previous == "" {
+		attrs.PutStr("check.previous_state", "unknown")
+	} else {
+		attrs.PutStr("check.previous_state", string(previous))
+	}
+	attrs.PutStr("check.message", res.Message)
+
+	return ld
+}
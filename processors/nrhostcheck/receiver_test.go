@@ -0,0 +1,121 @@
+package nrhostcheck
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+)
+
+func TestFactory_SharesReceiverAcrossPipelines(t *testing.T) {
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.Checks = []CheckConfig{{Name: "ssh", Type: CheckTCP, Target: "localhost:22"}}
+	set := receivertest.NewNopCreateSettings()
+
+	metrics, err := factory.CreateMetricsReceiver(context.Background(), set, cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	logs, err := factory.CreateLogsReceiver(context.Background(), set, cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	assert.Same(t, metrics, logs)
+
+	// An invalid config is rejected
+	_, err = factory.CreateMetricsReceiver(context.Background(), set, factory.CreateDefaultConfig(), consumertest.NewNop())
+	assert.Error(t, err)
+}
+
+func TestReceiver_EmitsMetricsAndStateChanges(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close() // the check starts out failing
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Checks = []CheckConfig{{
+		Name:       "db",
+		Type:       CheckTCP,
+		Target:     addr,
+		Attributes: map[string]string{"team": "storage"},
+	}}
+
+	metricsSink := new(consumertest.MetricsSink)
+	logsSink := new(consumertest.LogsSink)
+	r := newHostCheckReceiver(cfg, receivertest.NewNopCreateSettings().Logger)
+	r.metricsConsumer = metricsSink
+	r.logsConsumer = logsSink
+
+	check := &cfg.Checks[0]
+	r.runOnce(context.Background(), check)
+
+	require.Equal(t, 2, metricsSink.DataPointCount()) // status and duration
+	metrics := metricsSink.AllMetrics()[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	status := findMetric(t, metrics, "nrhostcheck.status").Gauge().DataPoints().At(0)
+	assert.Equal(t, int64(2), status.IntValue())
+	state, _ := status.Attributes().Get("check.state")
+	assert.Equal(t, "critical", state.Str())
+	team, _ := status.Attributes().Get("team")
+	assert.Equal(t, "storage", team.Str())
+
+	// The first failure is an event
+	require.Equal(t, 1, logsSink.LogRecordCount())
+	record := logsSink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	name, _ := record.Attributes().Get("event.name")
+	assert.Equal(t, stateChangeEvent, name.Str())
+	previous, _ := record.Attributes().Get("check.previous_state")
+	assert.Equal(t, "unknown", previous.Str())
+
+	// Still failing: metrics only
+	r.runOnce(context.Background(), check)
+	assert.Equal(t, 1, logsSink.LogRecordCount())
+
+	// Recovery is an event
+	listener, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+	defer listener.Close()
+	r.runOnce(context.Background(), check)
+	require.Equal(t, 2, logsSink.LogRecordCount())
+	record = logsSink.AllLogs()[1].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	previous, _ = record.Attributes().Get("check.previous_state")
+	assert.Equal(t, "critical", previous.Str())
+	assert.Contains(t, record.Body().Str(), "is ok")
+}
+
+func TestReceiver_StartShutdown(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.CollectionInterval = 10 * time.Millisecond
+	cfg.Checks = []CheckConfig{{Name: "local", Type: CheckDNS, Target: "localhost"}}
+
+	metricsSink := new(consumertest.MetricsSink)
+	r := newHostCheckReceiver(cfg, receivertest.NewNopCreateSettings().Logger)
+	r.metricsConsumer = metricsSink
+
+	host := componenttest.NewNopHost()
+	require.NoError(t, r.Start(context.Background(), host))
+	require.NoError(t, r.Start(context.Background(), host)) // logs pipeline
+
+	assert.Eventually(t, func() bool { return len(metricsSink.AllMetrics()) >= 2 }, 5*time.Second, 10*time.Millisecond)
+
+	// Runners keep going until the last pipeline shuts down
+	require.NoError(t, r.Shutdown(context.Background()))
+	count := len(metricsSink.AllMetrics())
+	assert.Eventually(t, func() bool { return len(metricsSink.AllMetrics()) > count }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, r.Shutdown(context.Background()))
+}
+
+func findMetric(t *testing.T, metrics pmetric.MetricSlice, name string) pmetric.Metric {
+	t.Helper()
+	for i := 0; i < metrics.Len(); i++ {
+		if metrics.At(i).Name() == name {
+			return metrics.At(i)
+		}
+	}
+	t.Fatalf("metric %s not found", name)
+	return pmetric.Metric{}
+}
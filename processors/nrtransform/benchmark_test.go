@@ -0,0 +1,203 @@
+package nrtransform
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// Allocation budget gate, see TestAllocationBudget and make alloc-budget
+var (
+	allocBudget    = flag.Bool("alloc-budget", false, "check transformation allocations per data point against their budgets")
+	allocTolerance = flag.Float64("alloc-tolerance", 0.1, "fraction by which allocations may exceed a budget")
+)
+
+// transformBenchmark is a representative transformation mix. Budgets are
+// allocations per input data point; raise one only with the reason in the
+// change that needs it, as transformation cost is agent overhead on every
+// host.
+type transformBenchmark struct {
+	name   string
+	config *Config
+	batch  func(i int) pmetric.Metrics
+	budget float64
+}
+
+var transformBenchmarks = []transformBenchmark{
+	{
+		name: "rate_10k_series",
+		config: &Config{Transformations: []TransformationConfig{{
+			Type:         TransformTypeCalculateRate,
+			MetricName:   "http.requests",
+			OutputMetric: "http.requests.rate",
+		}}},
+		batch:  counterBatch(10000),
+		budget: 12.5,
+	},
+	{
+		name: "combine_1k_groups",
+		config: &Config{Transformations: []TransformationConfig{{
+			Type:         TransformTypeCombine,
+			Expression:   "http_errors / http_requests * 100",
+			Metrics:      []string{"http.requests", "http.errors"},
+			OutputMetric: "http.error_rate",
+		}}},
+		batch:  ratioBatch(1000),
+		budget: 16.5,
+	},
+	{
+		name: "filter_regex_1k_metrics",
+		config: &Config{Transformations: []TransformationConfig{{
+			Type:      TransformTypeFilter,
+			Condition: `name matches "^(system|process)\\."`,
+		}}},
+		batch:  namedBatch(1000, 10),
+		budget: 4.5,
+	},
+}
+
+// counterBatch returns batches of a cumulative counter with the given
+// series, 10s apart
+func counterBatch(series int) func(i int) pmetric.Metrics {
+	return func(i int) pmetric.Metrics {
+		metrics := pmetric.NewMetrics()
+		metric := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		metric.SetName("http.requests")
+		metric.SetEmptySum()
+		metric.Sum().SetIsMonotonic(true)
+		metric.Sum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+
+		ts := pcommon.NewTimestampFromTime(time.Unix(1700000000, 0).Add(time.Duration(i) * 10 * time.Second))
+		dps := metric.Sum().DataPoints()
+		dps.EnsureCapacity(series)
+		for s := 0; s < series; s++ {
+			dp := dps.AppendEmpty()
+			dp.SetTimestamp(ts)
+			dp.SetIntValue(int64(i*100 + s))
+			dp.Attributes().PutStr("endpoint", fmt.Sprintf("/api/%d", s%100))
+			dp.Attributes().PutStr("instance", fmt.Sprintf("pod-%d", s/100))
+		}
+		return metrics
+	}
+}
+
+// ratioBatch returns batches of two gauges over the given groups
+func ratioBatch(groups int) func(i int) pmetric.Metrics {
+	return func(i int) pmetric.Metrics {
+		metrics := pmetric.NewMetrics()
+		sm := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+		ts := pcommon.NewTimestampFromTime(time.Unix(1700000000, 0).Add(time.Duration(i) * 10 * time.Second))
+		for _, name := range []string{"http.requests", "http.errors"} {
+			metric := sm.Metrics().AppendEmpty()
+			metric.SetName(name)
+			dps := metric.SetEmptyGauge().DataPoints()
+			dps.EnsureCapacity(groups)
+			for g := 0; g < groups; g++ {
+				dp := dps.AppendEmpty()
+				dp.SetTimestamp(ts)
+				dp.SetDoubleValue(float64(g + i + 1))
+				dp.Attributes().PutStr("endpoint", fmt.Sprintf("/api/%d", g))
+			}
+		}
+		return metrics
+	}
+}
+
+// namedBatch returns batches of gauges with system, process and application
+// names, each with the given data points
+func namedBatch(count, points int) func(i int) pmetric.Metrics {
+	prefixes := []string{"system.cpu", "process.memory", "app.requests", "app.queue"}
+	return func(i int) pmetric.Metrics {
+		metrics := pmetric.NewMetrics()
+		ms := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+		ms.EnsureCapacity(count)
+		for m := 0; m < count; m++ {
+			metric := ms.AppendEmpty()
+			metric.SetName(fmt.Sprintf("%s.%d", prefixes[m%len(prefixes)], m))
+			dps := metric.SetEmptyGauge().DataPoints()
+			for p := 0; p < points; p++ {
+				dp := dps.AppendEmpty()
+				dp.SetDoubleValue(float64(i + p))
+				dp.Attributes().PutStr("cpu", fmt.Sprintf("cpu%d", p))
+			}
+		}
+		return metrics
+	}
+}
+
+// benchmarkTransform transforms a fresh batch per iteration, reporting
+// allocations per input data point as allocs/dp. Building batches is not
+// timed or counted.
+func benchmarkTransform(b *testing.B, bench transformBenchmark) {
+	transformer, err := NewTransformer(bench.config, zap.NewNop())
+	require.NoError(b, err)
+	// State such as previous counter values is in place before timing
+	require.NoError(b, transformer.Transform(bench.batch(0)))
+
+	var before, after runtime.MemStats
+	var mallocs, points uint64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 1; i <= b.N; i++ {
+		b.StopTimer()
+		batch := bench.batch(i)
+		points += uint64(batch.DataPointCount())
+		runtime.ReadMemStats(&before)
+		b.StartTimer()
+
+		if err := transformer.Transform(batch); err != nil {
+			b.Fatal(err)
+		}
+
+		b.StopTimer()
+		runtime.ReadMemStats(&after)
+		mallocs += after.Mallocs - before.Mallocs
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(mallocs)/float64(points), "allocs/dp")
+}
+
+func BenchmarkTransform(b *testing.B) {
+	for _, bench := range transformBenchmarks {
+		bench := bench
+		b.Run(bench.name, func(b *testing.B) {
+			benchmarkTransform(b, bench)
+		})
+	}
+}
+
+// TestAllocationBudget fails when a transformation mix allocates more per
+// data point than its budget allows. It runs the benchmarks, so it only runs
+// with -alloc-budget.
+func TestAllocationBudget(t *testing.T) {
+	if !*allocBudget {
+		t.Skip("run with -alloc-budget, see make alloc-budget")
+	}
+
+	for _, bench := range transformBenchmarks {
+		bench := bench
+		t.Run(bench.name, func(t *testing.T) {
+			result := testing.Benchmark(func(b *testing.B) {
+				benchmarkTransform(b, bench)
+			})
+			if result.N == 0 {
+				t.Fatal("benchmark failed")
+			}
+			allocs := result.Extra["allocs/dp"]
+			limit := bench.budget * (1 + *allocTolerance)
+			t.Logf("%.2f allocs/dp, budget %.2f (%s/op)", allocs, bench.budget, time.Duration(result.NsPerOp()))
+			if allocs > limit {
+				t.Errorf("%.2f allocs/dp exceeds the budget of %.2f by more than %.0f%%",
+					allocs, bench.budget, *allocTolerance*100)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 )
@@ -17,8 +18,13 @@ type MetricCalculator struct {
 
 // NewMetricCalculator creates a new metric calculator
 func NewMetricCalculator() *MetricCalculator {
+	return newMetricCalculator(clock.Real())
+}
+
+// newMetricCalculator creates a metric calculator on the given clock
+func newMetricCalculator(clk clock.Clock) *MetricCalculator {
 	return &MetricCalculator{
-		stateStore: NewStateStore(),
+		stateStore: NewStateStore(clk),
 	}
 }
 
@@ -35,7 +41,7 @@ func (mc *MetricCalculator) CalculateRate(metric pmetric.Metric, outputName stri
 			return newMetric, fmt.Errorf("can only calculate rate for monotonic sums")
 		}
 		newMetric.SetEmptyGauge()
-		if err := mc.calculateSumRate(metric.Sum(), newMetric.Gauge()); err != nil {
+		if err := mc.calculateSumRate(metric.Name(), outputName, metric.Sum(), newMetric.Gauge()); err != nil {
 			return newMetric, err
 		}
 
@@ -61,7 +67,7 @@ func (mc *MetricCalculator) CalculateDelta(metric pmetric.Metric, outputName str
 		newMetric.SetEmptySum()
 		newMetric.Sum().SetIsMonotonic(false)
 		newMetric.Sum().SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
-		if err := mc.calculateSumDelta(metric.Sum(), newMetric.Sum()); err != nil {
+		if err := mc.calculateSumDelta(metric.Name(), outputName, metric.Sum(), newMetric.Sum()); err != nil {
 			return newMetric, err
 		}
 
@@ -72,22 +78,19 @@ func (mc *MetricCalculator) CalculateDelta(metric pmetric.Metric, outputName str
 	return newMetric, nil
 }
 
-func (mc *MetricCalculator) calculateSumRate(sum pmetric.Sum, gauge pmetric.Gauge) error {
+func (mc *MetricCalculator) calculateSumRate(source, output string, sum pmetric.Sum, gauge pmetric.Gauge) error {
 	dataPoints := sum.DataPoints()
 	for i := 0; i < dataPoints.Len(); i++ {
 		dp := dataPoints.At(i)
 
 		// Generate unique key for this data point
-		key := mc.generateDataPointKey(sum.DataPoints().At(i))
+		key := mc.seriesKey("rate", source, output, dp)
 
 		// Get previous state
 		prevState := mc.stateStore.Get(key)
 		if prevState == nil {
 			// First observation, store state but don't produce rate
-			mc.stateStore.Set(key, &DataPointState{
-				Value:     dp.DoubleValue(),
-				Timestamp: dp.Timestamp(),
-			})
+			mc.stateStore.Set(key, newDataPointState(dp))
 			continue
 		}
 
@@ -97,13 +100,11 @@ func (mc *MetricCalculator) calculateSumRate(sum pmetric.Sum, gauge pmetric.Gaug
 			continue
 		}
 
-		valueDiff := dp.DoubleValue() - prevState.Value
+		state := newDataPointState(dp)
+		valueDiff := state.Value - prevState.Value
 		if valueDiff < 0 {
 			// Counter reset, skip this calculation
-			mc.stateStore.Set(key, &DataPointState{
-				Value:     dp.DoubleValue(),
-				Timestamp: dp.Timestamp(),
-			})
+			mc.stateStore.Set(key, state)
 			continue
 		}
 
@@ -116,53 +117,54 @@ func (mc *MetricCalculator) calculateSumRate(sum pmetric.Sum, gauge pmetric.Gaug
 		newDp.SetDoubleValue(rate)
 
 		// Update state
-		mc.stateStore.Set(key, &DataPointState{
-			Value:     dp.DoubleValue(),
-			Timestamp: dp.Timestamp(),
-		})
+		mc.stateStore.Set(key, state)
 	}
 
 	return nil
 }
 
-func (mc *MetricCalculator) calculateSumDelta(sum pmetric.Sum, newSum pmetric.Sum) error {
+func (mc *MetricCalculator) calculateSumDelta(source, output string, sum pmetric.Sum, newSum pmetric.Sum) error {
 	dataPoints := sum.DataPoints()
 	for i := 0; i < dataPoints.Len(); i++ {
 		dp := dataPoints.At(i)
 		
 		// Generate unique key for this data point
-		key := mc.generateDataPointKey(dp)
+		key := mc.seriesKey("delta", source, output, dp)
 
 		// Get previous state
 		prevState := mc.stateStore.Get(key)
+		state := newDataPointState(dp)
 		if prevState == nil {
 			// First observation, store state but don't produce delta
-			mc.stateStore.Set(key, &DataPointState{
-				Value:     dp.DoubleValue(),
-				Timestamp: dp.Timestamp(),
-			})
+			mc.stateStore.Set(key, state)
 			continue
 		}
 
-		// Calculate delta
-		valueDiff := dp.DoubleValue() - prevState.Value
-		if valueDiff < 0 {
-			// Counter reset, use current value as delta
-			valueDiff = dp.DoubleValue()
-		}
-
 		// Create new data point with delta value
 		newDp := newSum.DataPoints().AppendEmpty()
 		dp.Attributes().CopyTo(newDp.Attributes())
 		newDp.SetTimestamp(dp.Timestamp())
 		newDp.SetStartTimestamp(prevState.Timestamp)
-		newDp.SetDoubleValue(valueDiff)
+
+		// Integer counters keep exact integer deltas
+		if state.IsInt && prevState.IsInt {
+			valueDiff := state.IntValue - prevState.IntValue
+			if valueDiff < 0 {
+				// Counter reset, use current value as delta
+				valueDiff = state.IntValue
+			}
+			newDp.SetIntValue(valueDiff)
+		} else {
+			valueDiff := state.Value - prevState.Value
+			if valueDiff < 0 {
+				// Counter reset, use current value as delta
+				valueDiff = state.Value
+			}
+			newDp.SetDoubleValue(valueDiff)
+		}
 
 		// Update state
-		mc.stateStore.Set(key, &DataPointState{
-			Value:     dp.DoubleValue(),
-			Timestamp: dp.Timestamp(),
-		})
+		mc.stateStore.Set(key, state)
 	}
 
 	return nil
@@ -179,6 +181,121 @@ func (mc *MetricCalculator) generateDataPointKey(dp pmetric.NumberDataPoint) str
 	return key
 }
 
+// seriesKey identifies the state of a derived series by the transformation
+// kind, the source metric, the output metric and the attributes of the
+// source series it came from
+func (mc *MetricCalculator) seriesKey(kind, source, output string, dp pmetric.NumberDataPoint) string {
+	return kind + "|" + source + "|" + output + "|" + mc.generateDataPointKey(dp)
+}
+
+// GaugeToCounter re-types a gauge as a sum. For monotonic output, a drop in a
+// series' value is treated as a counter reset and negative values are dropped.
+// Delta output needs a previous observation, so the first point of each series
+// only primes state.
+func (mc *MetricCalculator) GaugeToCounter(metric pmetric.Metric, temporality pmetric.AggregationTemporality, monotonic bool, outputName string) (pmetric.Metric, error) {
+	newMetric := pmetric.NewMetric()
+	newMetric.SetName(outputName)
+	newMetric.SetDescription(metric.Description())
+	newMetric.SetUnit(metric.Unit())
+
+	if metric.Type() != pmetric.MetricTypeGauge {
+		return newMetric, fmt.Errorf("gauge_to_counter requires a gauge metric, got %s", metric.Type())
+	}
+
+	newMetric.SetEmptySum()
+	newMetric.Sum().SetIsMonotonic(monotonic)
+	newMetric.Sum().SetAggregationTemporality(temporality)
+
+	dataPoints := metric.Gauge().DataPoints()
+	for i := 0; i < dataPoints.Len(); i++ {
+		dp := dataPoints.At(i)
+		value := numberValue(dp)
+		if math.IsNaN(value) || math.IsInf(value, 0) || (monotonic && value < 0) {
+			continue
+		}
+
+		key := mc.seriesKey("coerce", metric.Name(), outputName, dp)
+		prevState := mc.stateStore.Get(key)
+		reset := prevState != nil && monotonic && value < prevState.Value
+
+		state := &DataPointState{Value: value, Timestamp: dp.Timestamp()}
+		switch {
+		case prevState == nil:
+			state.StartTimestamp = dp.StartTimestamp()
+			if state.StartTimestamp == 0 {
+				state.StartTimestamp = dp.Timestamp()
+			}
+		case reset:
+			// The counter restarted somewhere after the previous observation
+			state.StartTimestamp = prevState.Timestamp
+		default:
+			state.StartTimestamp = prevState.StartTimestamp
+		}
+		mc.stateStore.Set(key, state)
+
+		if temporality == pmetric.AggregationTemporalityDelta {
+			if prevState == nil {
+				continue
+			}
+			delta := value - prevState.Value
+			if reset {
+				delta = value
+			}
+			newDp := newMetric.Sum().DataPoints().AppendEmpty()
+			dp.CopyTo(newDp)
+			newDp.SetStartTimestamp(prevState.Timestamp)
+			setNumberValue(newDp, delta)
+			continue
+		}
+
+		newDp := newMetric.Sum().DataPoints().AppendEmpty()
+		dp.CopyTo(newDp)
+		newDp.SetStartTimestamp(state.StartTimestamp)
+	}
+
+	return newMetric, nil
+}
+
+// CounterToGauge re-types a sum as a gauge, keeping each point's current value
+func (mc *MetricCalculator) CounterToGauge(metric pmetric.Metric, outputName string) (pmetric.Metric, error) {
+	newMetric := pmetric.NewMetric()
+	newMetric.SetName(outputName)
+	newMetric.SetDescription(metric.Description())
+	newMetric.SetUnit(metric.Unit())
+
+	if metric.Type() != pmetric.MetricTypeSum {
+		return newMetric, fmt.Errorf("counter_to_gauge requires a sum metric, got %s", metric.Type())
+	}
+
+	newMetric.SetEmptyGauge()
+	dataPoints := metric.Sum().DataPoints()
+	for i := 0; i < dataPoints.Len(); i++ {
+		newDp := newMetric.Gauge().DataPoints().AppendEmpty()
+		dataPoints.At(i).CopyTo(newDp)
+		// Gauges are instantaneous and carry no start time
+		newDp.SetStartTimestamp(0)
+	}
+
+	return newMetric, nil
+}
+
+// numberValue returns a data point's value as a float regardless of its value type
+func numberValue(dp pmetric.NumberDataPoint) float64 {
+	if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+		return float64(dp.IntValue())
+	}
+	return dp.DoubleValue()
+}
+
+// setNumberValue sets a data point's value, preserving its value type
+func setNumberValue(dp pmetric.NumberDataPoint, value float64) {
+	if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+		dp.SetIntValue(int64(value))
+		return
+	}
+	dp.SetDoubleValue(value)
+}
+
 // Aggregate performs aggregation operations on metrics
 func (mc *MetricCalculator) Aggregate(metric pmetric.Metric, agg AggregationType, groupBy []string, outputName string) (pmetric.Metric, error) {
 	newMetric := pmetric.NewMetric()
@@ -217,12 +334,13 @@ func (mc *MetricCalculator) aggregateGauge(gauge pmetric.Gauge, newGauge pmetric
 			group = &AggregationGroup{
 				attributes: mc.filterAttributes(dp.Attributes(), groupBy),
 				values:     []float64{},
+				allInt:     true,
 				timestamp:  dp.Timestamp(),
 			}
 			groups[groupKey] = group
 		}
 		
-		group.values = append(group.values, dp.DoubleValue())
+		group.add(dp)
 		if dp.Timestamp() > group.timestamp {
 			group.timestamp = dp.Timestamp()
 		}
@@ -233,7 +351,7 @@ func (mc *MetricCalculator) aggregateGauge(gauge pmetric.Gauge, newGauge pmetric
 		newDp := newGauge.DataPoints().AppendEmpty()
 		group.attributes.CopyTo(newDp.Attributes())
 		newDp.SetTimestamp(group.timestamp)
-		newDp.SetDoubleValue(mc.calculateAggregationValue(group.values, agg))
+		mc.setAggregationValue(newDp, group, agg)
 	}
 }
 
@@ -250,12 +368,13 @@ func (mc *MetricCalculator) aggregateSum(sum pmetric.Sum, newSum pmetric.Sum, ag
 			group = &AggregationGroup{
 				attributes: mc.filterAttributes(dp.Attributes(), groupBy),
 				values:     []float64{},
+				allInt:     true,
 				timestamp:  dp.Timestamp(),
 			}
 			groups[groupKey] = group
 		}
 		
-		group.values = append(group.values, dp.DoubleValue())
+		group.add(dp)
 		if dp.Timestamp() > group.timestamp {
 			group.timestamp = dp.Timestamp()
 		}
@@ -266,7 +385,7 @@ func (mc *MetricCalculator) aggregateSum(sum pmetric.Sum, newSum pmetric.Sum, ag
 		newDp := newSum.DataPoints().AppendEmpty()
 		group.attributes.CopyTo(newDp.Attributes())
 		newDp.SetTimestamp(group.timestamp)
-		newDp.SetDoubleValue(mc.calculateAggregationValue(group.values, agg))
+		mc.setAggregationValue(newDp, group, agg)
 	}
 }
 
@@ -294,6 +413,55 @@ func (mc *MetricCalculator) filterAttributes(attrs pcommon.Map, keepAttrs []stri
 	return newAttrs
 }
 
+// setAggregationValue sets the aggregate of a group. Sum, min, max and count
+// of integer points stay integers; averages and mixed groups are doubles.
+func (mc *MetricCalculator) setAggregationValue(dp pmetric.NumberDataPoint, group *AggregationGroup, agg AggregationType) {
+	if group.allInt && agg != AggregationAvg {
+		dp.SetIntValue(mc.calculateIntAggregationValue(group.intValues, agg))
+		return
+	}
+	dp.SetDoubleValue(mc.calculateAggregationValue(group.values, agg))
+}
+
+func (mc *MetricCalculator) calculateIntAggregationValue(values []int64, agg AggregationType) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	switch agg {
+	case AggregationSum:
+		var sum int64
+		for _, v := range values {
+			sum += v
+		}
+		return sum
+
+	case AggregationMin:
+		min := values[0]
+		for _, v := range values[1:] {
+			if v < min {
+				min = v
+			}
+		}
+		return min
+
+	case AggregationMax:
+		max := values[0]
+		for _, v := range values[1:] {
+			if v > max {
+				max = v
+			}
+		}
+		return max
+
+	case AggregationCount:
+		return int64(len(values))
+
+	default:
+		return 0
+	}
+}
+
 func (mc *MetricCalculator) calculateAggregationValue(values []float64, agg AggregationType) float64 {
 	if len(values) == 0 {
 		return 0
@@ -381,7 +549,8 @@ func (mc *MetricCalculator) convertGaugeUnit(gauge pmetric.Gauge, newGauge pmetr
 		dp := dataPoints.At(i)
 		newDp := newGauge.DataPoints().AppendEmpty()
 		dp.CopyTo(newDp)
-		newDp.SetDoubleValue(dp.DoubleValue() * factor)
+		newDp.SetDoubleValue(numberValue(dp) * factor)
+		scaleExemplars(newDp.Exemplars(), factor)
 	}
 }
 
@@ -391,7 +560,8 @@ func (mc *MetricCalculator) convertSumUnit(sum pmetric.Sum, newSum pmetric.Sum,
 		dp := dataPoints.At(i)
 		newDp := newSum.DataPoints().AppendEmpty()
 		dp.CopyTo(newDp)
-		newDp.SetDoubleValue(dp.DoubleValue() * factor)
+		newDp.SetDoubleValue(numberValue(dp) * factor)
+		scaleExemplars(newDp.Exemplars(), factor)
 	}
 }
 
@@ -435,19 +605,21 @@ func (mc *MetricCalculator) convertHistogramUnit(hist pmetric.Histogram, newHist
 		}
 		
 		// Copy exemplars if any
-		exemplars := dp.Exemplars()
-		newExemplars := newDp.Exemplars()
-		for j := 0; j < exemplars.Len(); j++ {
-			exemplar := exemplars.At(j)
-			newExemplar := newExemplars.AppendEmpty()
-			exemplar.CopyTo(newExemplar)
-			// Convert exemplar value
-			switch newExemplar.ValueType() {
-			case pmetric.ExemplarValueTypeDouble:
-				newExemplar.SetDoubleValue(newExemplar.DoubleValue() * factor)
-			case pmetric.ExemplarValueTypeInt:
-				newExemplar.SetIntValue(int64(float64(newExemplar.IntValue()) * factor))
-			}
+		dp.Exemplars().CopyTo(newDp.Exemplars())
+		scaleExemplars(newDp.Exemplars(), factor)
+	}
+}
+
+// scaleExemplars converts exemplar values by a unit factor. Like the data
+// points they belong to, converted values are doubles.
+func scaleExemplars(exemplars pmetric.ExemplarSlice, factor float64) {
+	for i := 0; i < exemplars.Len(); i++ {
+		exemplar := exemplars.At(i)
+		switch exemplar.ValueType() {
+		case pmetric.ExemplarValueTypeDouble:
+			exemplar.SetDoubleValue(exemplar.DoubleValue() * factor)
+		case pmetric.ExemplarValueTypeInt:
+			exemplar.SetDoubleValue(float64(exemplar.IntValue()) * factor)
 		}
 	}
 }
@@ -503,22 +675,47 @@ func getConversionFactor(fromUnit, toUnit string) (float64, error) {
 	return 0, fmt.Errorf("unsupported unit conversion: %s to %s", fromUnit, toUnit)
 }
 
-// StateStore manages state for rate and delta calculations
+// StateStore manages state for rate and delta calculations. Each entry is
+// the state of one derived series and records when its source series was
+// last seen, so the state of series whose sources disappeared can be
+// expired.
 type StateStore struct {
-	mu    sync.RWMutex
-	store map[string]*DataPointState
+	mu       sync.RWMutex
+	store    map[string]*DataPointState
+	lastSeen map[string]time.Time
+
+	clock clock.Clock
 }
 
 // DataPointState stores the state of a data point
 type DataPointState struct {
-	Value     float64
-	Timestamp pcommon.Timestamp
+	Value          float64
+	IntValue       int64 // exact value when IsInt
+	IsInt          bool
+	Timestamp      pcommon.Timestamp
+	StartTimestamp pcommon.Timestamp
+}
+
+// newDataPointState captures a data point's value and timestamp
+func newDataPointState(dp pmetric.NumberDataPoint) *DataPointState {
+	state := &DataPointState{
+		Value:     numberValue(dp),
+		Timestamp: dp.Timestamp(),
+	}
+	if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+		state.IntValue = dp.IntValue()
+		state.IsInt = true
+	}
+	return state
 }
 
-// NewStateStore creates a new state store
-func NewStateStore() *StateStore {
+// NewStateStore creates a new state store, recording when series are seen
+// on clk
+func NewStateStore(clk clock.Clock) *StateStore {
 	return &StateStore{
-		store: make(map[string]*DataPointState),
+		store:    make(map[string]*DataPointState),
+		lastSeen: make(map[string]time.Time),
+		clock:    clk,
 	}
 }
 
@@ -529,20 +726,58 @@ func (ss *StateStore) Get(key string) *DataPointState {
 	return ss.store[key]
 }
 
-// Set stores state for a key
+// Set stores state for a key, marking its source series as seen
 func (ss *StateStore) Set(key string, state *DataPointState) {
 	ss.mu.Lock()
 	defer ss.mu.Unlock()
 	ss.store[key] = state
+	ss.lastSeen[key] = ss.clock.Now()
+}
+
+// Expire removes the state of every series whose source was last seen
+// before cutoff and returns how many were removed
+func (ss *StateStore) Expire(cutoff time.Time) int {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	expired := 0
+	for key, seen := range ss.lastSeen {
+		if seen.Before(cutoff) {
+			delete(ss.store, key)
+			delete(ss.lastSeen, key)
+			expired++
+		}
+	}
+	return expired
+}
+
+// Len returns the number of series with state
+func (ss *StateStore) Len() int {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return len(ss.store)
 }
 
 // AggregationGroup represents a group of values to aggregate
 type AggregationGroup struct {
 	attributes pcommon.Map
 	values     []float64
+	intValues  []int64 // exact values while allInt
+	allInt     bool
 	timestamp  pcommon.Timestamp
 }
 
+// add adds a data point's value to the group
+func (g *AggregationGroup) add(dp pmetric.NumberDataPoint) {
+	g.values = append(g.values, numberValue(dp))
+	if g.allInt && dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+		g.intValues = append(g.intValues, dp.IntValue())
+	} else {
+		g.allInt = false
+		g.intValues = nil
+	}
+}
+
 // CalculatePercentile calculates the percentile value from a sorted slice
 func CalculatePercentile(values []float64, percentile float64) float64 {
 	if len(values) == 0 {
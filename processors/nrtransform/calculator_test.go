@@ -124,6 +124,108 @@ func TestCalculateDelta_CounterReset(t *testing.T) {
 	assert.Equal(t, 100.0, delta.Sum().DataPoints().At(0).DoubleValue())
 }
 
+func TestGaugeToCounter(t *testing.T) {
+	calculator := NewMetricCalculator()
+	start := time.Now().Add(-time.Minute)
+
+	gauge := func(value float64, ts time.Time) pmetric.Metric {
+		metric := pmetric.NewMetric()
+		metric.SetName("bytes.sent")
+		metric.SetEmptyGauge()
+		dp := metric.Gauge().DataPoints().AppendEmpty()
+		dp.SetDoubleValue(value)
+		dp.SetTimestamp(pcommon.NewTimestampFromTime(ts))
+		dp.Attributes().PutStr("iface", "eth0")
+		return metric
+	}
+
+	counter, err := calculator.GaugeToCounter(gauge(100, start), pmetric.AggregationTemporalityCumulative, true, "bytes.sent")
+	require.NoError(t, err)
+	require.Equal(t, pmetric.MetricTypeSum, counter.Type())
+	assert.True(t, counter.Sum().IsMonotonic())
+	assert.Equal(t, pmetric.AggregationTemporalityCumulative, counter.Sum().AggregationTemporality())
+	require.Equal(t, 1, counter.Sum().DataPoints().Len())
+	firstStart := counter.Sum().DataPoints().At(0).StartTimestamp()
+	assert.Equal(t, pcommon.NewTimestampFromTime(start), firstStart)
+
+	// Increasing values keep the series start time
+	counter, err = calculator.GaugeToCounter(gauge(150, start.Add(10*time.Second)), pmetric.AggregationTemporalityCumulative, true, "bytes.sent")
+	require.NoError(t, err)
+	assert.Equal(t, firstStart, counter.Sum().DataPoints().At(0).StartTimestamp())
+	assert.Equal(t, 150.0, counter.Sum().DataPoints().At(0).DoubleValue())
+
+	// A decrease is a reset and starts a new series
+	counter, err = calculator.GaugeToCounter(gauge(20, start.Add(20*time.Second)), pmetric.AggregationTemporalityCumulative, true, "bytes.sent")
+	require.NoError(t, err)
+	assert.Equal(t, pcommon.NewTimestampFromTime(start.Add(10*time.Second)), counter.Sum().DataPoints().At(0).StartTimestamp())
+
+	// Negative values cannot be represented by a monotonic counter
+	counter, err = calculator.GaugeToCounter(gauge(-5, start.Add(30*time.Second)), pmetric.AggregationTemporalityCumulative, true, "bytes.sent")
+	require.NoError(t, err)
+	assert.Equal(t, 0, counter.Sum().DataPoints().Len())
+}
+
+func TestGaugeToCounter_Delta(t *testing.T) {
+	calculator := NewMetricCalculator()
+	start := time.Now().Add(-time.Minute)
+
+	values := []float64{100, 130, 10}
+	var deltas []float64
+	for i, value := range values {
+		metric := pmetric.NewMetric()
+		metric.SetName("requests")
+		metric.SetEmptyGauge()
+		dp := metric.Gauge().DataPoints().AppendEmpty()
+		dp.SetDoubleValue(value)
+		dp.SetTimestamp(pcommon.NewTimestampFromTime(start.Add(time.Duration(i) * 10 * time.Second)))
+
+		counter, err := calculator.GaugeToCounter(metric, pmetric.AggregationTemporalityDelta, true, "requests")
+		require.NoError(t, err)
+		for j := 0; j < counter.Sum().DataPoints().Len(); j++ {
+			deltas = append(deltas, counter.Sum().DataPoints().At(j).DoubleValue())
+		}
+	}
+
+	// First observation only primes state; the reset reports the current value
+	assert.Equal(t, []float64{30, 10}, deltas)
+}
+
+func TestGaugeToCounter_WrongType(t *testing.T) {
+	calculator := NewMetricCalculator()
+
+	metric := pmetric.NewMetric()
+	metric.SetName("already.sum")
+	metric.SetEmptySum()
+
+	_, err := calculator.GaugeToCounter(metric, pmetric.AggregationTemporalityCumulative, true, "already.sum")
+	assert.Error(t, err)
+}
+
+func TestCounterToGauge(t *testing.T) {
+	calculator := NewMetricCalculator()
+
+	metric := pmetric.NewMetric()
+	metric.SetName("queue.depth")
+	metric.SetEmptySum()
+	metric.Sum().SetIsMonotonic(true)
+	dp := metric.Sum().DataPoints().AppendEmpty()
+	dp.SetIntValue(42)
+	dp.SetStartTimestamp(pcommon.NewTimestampFromTime(time.Now().Add(-time.Minute)))
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(time.Now()))
+	dp.Attributes().PutStr("queue", "orders")
+
+	gauge, err := calculator.CounterToGauge(metric, "queue.depth")
+	require.NoError(t, err)
+	require.Equal(t, pmetric.MetricTypeGauge, gauge.Type())
+	require.Equal(t, 1, gauge.Gauge().DataPoints().Len())
+
+	out := gauge.Gauge().DataPoints().At(0)
+	assert.Equal(t, int64(42), out.IntValue())
+	assert.Equal(t, pcommon.Timestamp(0), out.StartTimestamp())
+	queue, _ := out.Attributes().Get("queue")
+	assert.Equal(t, "orders", queue.Str())
+}
+
 func TestAggregate(t *testing.T) {
 	calculator := NewMetricCalculator()
 	
@@ -370,4 +472,140 @@ func TestCalculatePercentile(t *testing.T) {
 			assert.InDelta(t, tt.expected, result, 0.1)
 		})
 	}
-}
\ No newline at end of file
+}
+func TestCalculate_IntValues(t *testing.T) {
+	calculator := NewMetricCalculator()
+	now := time.Now()
+
+	counter := func(value int64, ts time.Time) pmetric.Metric {
+		metric := pmetric.NewMetric()
+		metric.SetName("packets.total")
+		metric.SetEmptySum()
+		metric.Sum().SetIsMonotonic(true)
+		metric.Sum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		dp := metric.Sum().DataPoints().AppendEmpty()
+		dp.SetIntValue(value)
+		dp.SetTimestamp(pcommon.NewTimestampFromTime(ts))
+		return metric
+	}
+
+	_, err := calculator.CalculateRate(counter(100, now.Add(-10*time.Second)), "packets.rate")
+	require.NoError(t, err)
+	rate, err := calculator.CalculateRate(counter(150, now), "packets.rate")
+	require.NoError(t, err)
+	require.Equal(t, 1, rate.Gauge().DataPoints().Len())
+	assert.InDelta(t, 5.0, rate.Gauge().DataPoints().At(0).DoubleValue(), 0.001)
+
+	// Deltas of large integer counters stay exact
+	calculator = NewMetricCalculator()
+	_, err = calculator.CalculateDelta(counter(1<<60, now.Add(-10*time.Second)), "packets.delta")
+	require.NoError(t, err)
+	delta, err := calculator.CalculateDelta(counter(1<<60+1, now), "packets.delta")
+	require.NoError(t, err)
+	require.Equal(t, 1, delta.Sum().DataPoints().Len())
+	out := delta.Sum().DataPoints().At(0)
+	assert.Equal(t, pmetric.NumberDataPointValueTypeInt, out.ValueType())
+	assert.Equal(t, int64(1), out.IntValue())
+
+	// Counter reset
+	delta, err = calculator.CalculateDelta(counter(7, now.Add(10*time.Second)), "packets.delta")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), delta.Sum().DataPoints().At(0).IntValue())
+}
+
+func TestCalculateDelta_MixedValueTypes(t *testing.T) {
+	calculator := NewMetricCalculator()
+	now := time.Now()
+
+	metric := pmetric.NewMetric()
+	metric.SetName("bytes.total")
+	metric.SetEmptySum()
+	metric.Sum().SetIsMonotonic(true)
+	dp := metric.Sum().DataPoints().AppendEmpty()
+	dp.SetIntValue(1000)
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(now.Add(-5 * time.Second)))
+	_, err := calculator.CalculateDelta(metric, "bytes.delta")
+	require.NoError(t, err)
+
+	// The series switched to doubles
+	dp.SetDoubleValue(1500.5)
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(now))
+	delta, err := calculator.CalculateDelta(metric, "bytes.delta")
+	require.NoError(t, err)
+	out := delta.Sum().DataPoints().At(0)
+	assert.Equal(t, pmetric.NumberDataPointValueTypeDouble, out.ValueType())
+	assert.Equal(t, 500.5, out.DoubleValue())
+}
+
+func TestAggregate_IntValues(t *testing.T) {
+	calculator := NewMetricCalculator()
+
+	newMetric := func(values ...interface{}) pmetric.Metric {
+		metric := pmetric.NewMetric()
+		metric.SetName("connections")
+		metric.SetEmptyGauge()
+		for _, value := range values {
+			dp := metric.Gauge().DataPoints().AppendEmpty()
+			switch v := value.(type) {
+			case int:
+				dp.SetIntValue(int64(v))
+			case float64:
+				dp.SetDoubleValue(v)
+			}
+		}
+		return metric
+	}
+
+	tests := []struct {
+		name        string
+		values      []interface{}
+		aggregation AggregationType
+		intResult   bool
+		expected    float64
+	}{
+		{name: "int sum", values: []interface{}{10, 20, 30}, aggregation: AggregationSum, intResult: true, expected: 60},
+		{name: "int max", values: []interface{}{10, 20, 30}, aggregation: AggregationMax, intResult: true, expected: 30},
+		{name: "int count", values: []interface{}{10, 20, 30}, aggregation: AggregationCount, intResult: true, expected: 3},
+		{name: "int avg", values: []interface{}{10, 20}, aggregation: AggregationAvg, expected: 15},
+		{name: "mixed sum", values: []interface{}{10, 2.5, 30}, aggregation: AggregationSum, expected: 42.5},
+		{name: "mixed min", values: []interface{}{2.5, 1}, aggregation: AggregationMin, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := calculator.Aggregate(newMetric(tt.values...), tt.aggregation, nil, "connections.agg")
+			require.NoError(t, err)
+			require.Equal(t, 1, result.Gauge().DataPoints().Len())
+
+			dp := result.Gauge().DataPoints().At(0)
+			if tt.intResult {
+				assert.Equal(t, pmetric.NumberDataPointValueTypeInt, dp.ValueType())
+				assert.Equal(t, int64(tt.expected), dp.IntValue())
+			} else {
+				assert.Equal(t, pmetric.NumberDataPointValueTypeDouble, dp.ValueType())
+				assert.Equal(t, tt.expected, dp.DoubleValue())
+			}
+		})
+	}
+}
+
+func TestConvertUnit_IntValues(t *testing.T) {
+	calculator := NewMetricCalculator()
+
+	metric := pmetric.NewMetric()
+	metric.SetName("memory.used")
+	metric.SetEmptySum()
+	dp := metric.Sum().DataPoints().AppendEmpty()
+	dp.SetIntValue(1536)
+	exemplar := dp.Exemplars().AppendEmpty()
+	exemplar.SetIntValue(512)
+
+	result, err := calculator.ConvertUnit(metric, "bytes", "kilobytes", "memory.used.kb")
+	require.NoError(t, err)
+
+	out := result.Sum().DataPoints().At(0)
+	assert.Equal(t, 1.5, out.DoubleValue())
+	require.Equal(t, 1, out.Exemplars().Len())
+	assert.Equal(t, pmetric.ExemplarValueTypeDouble, out.Exemplars().At(0).ValueType())
+	assert.Equal(t, 0.5, out.Exemplars().At(0).DoubleValue())
+}
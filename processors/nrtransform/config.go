@@ -2,6 +2,7 @@ package nrtransform
 
 import (
 	"fmt"
+	"time"
 
 	"go.opentelemetry.io/collector/component"
 )
@@ -10,6 +11,13 @@ import (
 type Config struct {
 	// Transformations is the list of transformations to apply
 	Transformations []TransformationConfig `mapstructure:"transformations"`
+
+	// StalenessWindow is how long the state of a derived series is kept
+	// after its source series was last seen. Rate, delta and
+	// gauge_to_counter outputs then stop, and restart from a fresh first
+	// observation if the source returns, instead of resuming from state
+	// left by a previous metric shape. 0 keeps state forever.
+	StalenessWindow time.Duration `mapstructure:"staleness_window"`
 }
 
 // TransformationConfig represents a single transformation configuration
@@ -40,25 +48,45 @@ type TransformationConfig struct {
 	LabelKey   string `mapstructure:"label_key"`
 	LabelValue string `mapstructure:"label_value"`
 
+	// Resource specific. resource_to_label copies ResourceAttribute to the
+	// LabelKey label of data points, label_to_resource moves the LabelKey
+	// label to the ResourceAttribute resource attribute; either key defaults
+	// to the other. MetricName is optional, all metrics when empty.
+	ResourceAttribute string `mapstructure:"resource_attribute"`
+
 	// Histogram specific
 	Buckets []float64 `mapstructure:"buckets"`
 
 	// Summary specific
 	Percentiles []float64 `mapstructure:"percentiles"`
+
+	// Type coercion specific. Temporality is "cumulative" (default) or "delta";
+	// Monotonic defaults to true.
+	Temporality string `mapstructure:"temporality"`
+	Monotonic   *bool  `mapstructure:"monotonic"`
 }
 
 // TransformationType defines the type of transformation
 type TransformationType string
 
 const (
-	TransformTypeAggregate      TransformationType = "aggregate"
-	TransformTypeCalculateRate  TransformationType = "calculate_rate"
-	TransformTypeCalculateDelta TransformationType = "calculate_delta"
-	TransformTypeConvertUnit    TransformationType = "convert_unit"
-	TransformTypeCombine        TransformationType = "combine"
-	TransformTypeRename         TransformationType = "rename"
-	TransformTypeFilter         TransformationType = "filter"
-	TransformTypeExtractLabel   TransformationType = "extract_label"
+	TransformTypeAggregate       TransformationType = "aggregate"
+	TransformTypeCalculateRate   TransformationType = "calculate_rate"
+	TransformTypeCalculateDelta  TransformationType = "calculate_delta"
+	TransformTypeConvertUnit     TransformationType = "convert_unit"
+	TransformTypeCombine         TransformationType = "combine"
+	TransformTypeRename          TransformationType = "rename"
+	TransformTypeFilter          TransformationType = "filter"
+	TransformTypeExtractLabel    TransformationType = "extract_label"
+	TransformTypeGaugeToCounter  TransformationType = "gauge_to_counter"
+	TransformTypeCounterToGauge  TransformationType = "counter_to_gauge"
+	TransformTypeResourceToLabel TransformationType = "resource_to_label"
+	TransformTypeLabelToResource TransformationType = "label_to_resource"
+)
+
+const (
+	TemporalityCumulative = "cumulative"
+	TemporalityDelta      = "delta"
 )
 
 // AggregationType defines the type of aggregation
@@ -84,6 +112,14 @@ func (cfg *Config) Validate() error {
 		}
 	}
 
+	if _, err := orderTransformations(cfg.Transformations); err != nil {
+		return err
+	}
+
+	if cfg.StalenessWindow < 0 {
+		return fmt.Errorf("staleness_window must not be negative")
+	}
+
 	return nil
 }
 
@@ -157,6 +193,40 @@ func validateTransformation(t TransformationConfig) error {
 			return fmt.Errorf("output_metric is required for extract_label transformation")
 		}
 
+	case TransformTypeGaugeToCounter:
+		if t.MetricName == "" {
+			return fmt.Errorf("metric_name is required for gauge_to_counter transformation")
+		}
+		switch t.Temporality {
+		case "", TemporalityCumulative, TemporalityDelta:
+		default:
+			return fmt.Errorf("invalid temporality: %s", t.Temporality)
+		}
+
+	case TransformTypeCounterToGauge:
+		if t.MetricName == "" {
+			return fmt.Errorf("metric_name is required for counter_to_gauge transformation")
+		}
+		if t.Temporality != "" || t.Monotonic != nil {
+			return fmt.Errorf("temporality and monotonic only apply to gauge_to_counter transformation")
+		}
+
+	case TransformTypeResourceToLabel:
+		if t.ResourceAttribute == "" {
+			return fmt.Errorf("resource_attribute is required for resource_to_label transformation")
+		}
+		if t.OutputMetric != "" {
+			return fmt.Errorf("output_metric does not apply to resource_to_label transformation")
+		}
+
+	case TransformTypeLabelToResource:
+		if t.LabelKey == "" {
+			return fmt.Errorf("label_key is required for label_to_resource transformation")
+		}
+		if t.OutputMetric != "" {
+			return fmt.Errorf("output_metric does not apply to label_to_resource transformation")
+		}
+
 	default:
 		return fmt.Errorf("unsupported transformation type: %s", t.Type)
 	}
@@ -3,6 +3,7 @@ package nrtransform
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
@@ -15,6 +16,9 @@ const (
 	TypeStr = "nrtransform"
 	// Stability level
 	stability = component.StabilityLevelAlpha
+	// defaultStalenessWindow is how long derived series outlive their
+	// sources by default
+	defaultStalenessWindow = 5 * time.Minute
 )
 
 // NewFactory creates a new processor factory
@@ -29,6 +33,7 @@ func NewFactory() processor.Factory {
 func createDefaultConfig() component.Config {
 	return &Config{
 		Transformations: []TransformationConfig{},
+		StalenessWindow: defaultStalenessWindow,
 	}
 }
 
@@ -4,6 +4,7 @@ go 1.21
 
 require (
 	github.com/expr-lang/expr v1.16.0
+	github.com/newrelic/nrdot-host/nrdot-common v0.0.0-00010101000000-000000000000
 	github.com/stretchr/testify v1.9.0
 	go.opentelemetry.io/collector/component v0.96.0
 	go.opentelemetry.io/collector/consumer v0.96.0
@@ -43,3 +44,5 @@ require (
 )
 
 replace github.com/newrelic/nrdot-host/otel-processor-common => ../otel-processor-common
+
+replace github.com/newrelic/nrdot-host/nrdot-common => ../../nrdot-common
@@ -0,0 +1,129 @@
+package nrtransform
+
+import (
+	"fmt"
+	"strings"
+)
+
+// transformInputs returns the metrics a transformation reads
+func transformInputs(t TransformationConfig) []string {
+	switch t.Type {
+	case TransformTypeCombine:
+		return t.Metrics
+	case TransformTypeFilter:
+		return nil
+	case TransformTypeResourceToLabel, TransformTypeLabelToResource:
+		// All metrics unless one is named
+		if t.MetricName == "" {
+			return nil
+		}
+		return []string{t.MetricName}
+	default:
+		return []string{t.MetricName}
+	}
+}
+
+// transformOutput returns the metric a transformation produces, or "" if
+// it only rewrites or removes metrics under their own names
+func transformOutput(t TransformationConfig) string {
+	if t.Type == TransformTypeFilter || t.OutputMetric == t.MetricName {
+		return ""
+	}
+	return t.OutputMetric
+}
+
+// orderTransformations returns the indexes of the transformations in the
+// order they must be applied, so that each one runs after the ones
+// producing the metrics it reads. Transformations that don't depend on each
+// other keep their configured order. It fails if the dependencies form a
+// cycle.
+func orderTransformations(transforms []TransformationConfig) ([]int, error) {
+	producers := make(map[string][]int)
+	for i, t := range transforms {
+		if out := transformOutput(t); out != "" {
+			producers[out] = append(producers[out], i)
+		}
+	}
+
+	// deps[j] are the transformations j reads the output of
+	deps := make([][]int, len(transforms))
+	dependents := make([][]int, len(transforms))
+	pending := make([]int, len(transforms))
+	for j, t := range transforms {
+		seen := make(map[int]bool)
+		for _, in := range transformInputs(t) {
+			for _, i := range producers[in] {
+				// A transformation writing the metric it reads is not a dependency
+				if i == j || seen[i] {
+					continue
+				}
+				seen[i] = true
+				deps[j] = append(deps[j], i)
+				dependents[i] = append(dependents[i], j)
+				pending[j]++
+			}
+		}
+	}
+
+	// Kahn's algorithm, always taking the earliest configured transformation
+	// whose inputs are satisfied
+	order := make([]int, 0, len(transforms))
+	done := make([]bool, len(transforms))
+	for len(order) < len(transforms) {
+		next := -1
+		for i := range transforms {
+			if !done[i] && pending[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return nil, dependencyCycle(transforms, deps, done)
+		}
+
+		done[next] = true
+		order = append(order, next)
+		for _, j := range dependents[next] {
+			pending[j]--
+		}
+	}
+
+	return order, nil
+}
+
+// dependencyCycle describes a cycle among the transformations left undone.
+// Each of them waits on another undone one, so following those dependencies
+// from any of them must revisit a transformation.
+func dependencyCycle(transforms []TransformationConfig, deps [][]int, done []bool) error {
+	start := 0
+	for done[start] {
+		start++
+	}
+
+	var path []int
+	visited := make(map[int]int) // transformation -> position in path
+	for i := start; ; {
+		if pos, ok := visited[i]; ok {
+			path = path[pos:]
+			break
+		}
+		visited[i] = len(path)
+		path = append(path, i)
+		for _, dep := range deps[i] {
+			if !done[dep] {
+				i = dep
+				break
+			}
+		}
+	}
+
+	// path follows dependencies backwards; report it from producer to consumer
+	steps := make([]string, 0, len(path)+1)
+	for k := len(path) - 1; k >= 0; k-- {
+		i := path[k]
+		steps = append(steps, fmt.Sprintf("%d (%s)", i, transformOutput(transforms[i])))
+	}
+	steps = append(steps, fmt.Sprintf("%d", path[len(path)-1]))
+
+	return fmt.Errorf("transformations form a dependency cycle: %s", strings.Join(steps, " -> "))
+}
@@ -0,0 +1,209 @@
+package nrtransform
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// resourceAttributeKeys returns the resource attribute and data point label
+// a resource transformation moves between, each defaulting to the other
+func resourceAttributeKeys(transform TransformationConfig) (string, string) {
+	attribute, label := transform.ResourceAttribute, transform.LabelKey
+	if attribute == "" {
+		attribute = label
+	}
+	if label == "" {
+		label = attribute
+	}
+	return attribute, label
+}
+
+// appliesTo reports whether a resource transformation applies to a metric
+func appliesTo(transform TransformationConfig, metric pmetric.Metric) bool {
+	return transform.MetricName == "" || metric.Name() == transform.MetricName
+}
+
+// resourceToLabel copies a resource attribute to the data points of the
+// metrics. Data points already carrying the label keep their own value.
+func (t *Transformer) resourceToLabel(transform TransformationConfig, resource pcommon.Resource, metrics []pmetric.Metric) {
+	attribute, label := resourceAttributeKeys(transform)
+	value, ok := resource.Attributes().Get(attribute)
+	if !ok {
+		return
+	}
+
+	for _, metric := range metrics {
+		if !appliesTo(transform, metric) {
+			continue
+		}
+		forEachDataPointAttributes(metric, func(attrs pcommon.Map) {
+			if _, exists := attrs.Get(label); !exists {
+				value.CopyTo(attrs.PutEmpty(label))
+			}
+		})
+	}
+}
+
+// labelToResource moves a data point label to the resources of the metrics.
+// The data points of a resource are split by the value of the label: those
+// with a value move, without the label, to a copy of their resource and
+// scope carrying the value as the attribute, replacing any value the
+// resource had. Data points without the label stay where they are, and
+// metrics, scopes and resources left empty are removed. Resources are
+// regrouped after the other transformations of a batch ran.
+func (t *Transformer) labelToResource(transform TransformationConfig, metrics pmetric.Metrics) {
+	attribute, label := resourceAttributeKeys(transform)
+	resourceMetrics := metrics.ResourceMetrics()
+
+	// Resources split off are appended, and not split again
+	n := resourceMetrics.Len()
+	for i := 0; i < n; i++ {
+		rm := resourceMetrics.At(i)
+		split := make(map[string]pmetric.ResourceMetrics)
+
+		scopeMetrics := rm.ScopeMetrics()
+		for j := 0; j < scopeMetrics.Len(); j++ {
+			sm := scopeMetrics.At(j)
+			splitScopes := make(map[string]pmetric.ScopeMetrics)
+
+			sm.Metrics().RemoveIf(func(metric pmetric.Metric) bool {
+				if !appliesTo(transform, metric) {
+					return false
+				}
+
+				moved := false
+				for _, value := range labelValues(metric, label) {
+					key := value.AsString()
+					target, ok := split[key]
+					if !ok {
+						target = resourceMetrics.AppendEmpty()
+						rm.Resource().CopyTo(target.Resource())
+						target.SetSchemaUrl(rm.SchemaUrl())
+						value.CopyTo(target.Resource().Attributes().PutEmpty(attribute))
+						split[key] = target
+					}
+					targetScope, ok := splitScopes[key]
+					if !ok {
+						targetScope = target.ScopeMetrics().AppendEmpty()
+						sm.Scope().CopyTo(targetScope.Scope())
+						targetScope.SetSchemaUrl(sm.SchemaUrl())
+						splitScopes[key] = targetScope
+					}
+
+					// The data points with this value, without the label
+					copied := targetScope.Metrics().AppendEmpty()
+					metric.CopyTo(copied)
+					removeDataPointsIf(copied, func(attrs pcommon.Map) bool {
+						v, ok := attrs.Get(label)
+						return !ok || v.AsString() != key
+					})
+					forEachDataPointAttributes(copied, func(attrs pcommon.Map) {
+						attrs.Remove(label)
+					})
+					moved = true
+				}
+				if !moved {
+					return false
+				}
+
+				removeDataPointsIf(metric, func(attrs pcommon.Map) bool {
+					_, ok := attrs.Get(label)
+					return ok
+				})
+				return dataPointCount(metric) == 0
+			})
+		}
+
+		if len(split) > 0 {
+			rm.ScopeMetrics().RemoveIf(func(sm pmetric.ScopeMetrics) bool {
+				return sm.Metrics().Len() == 0
+			})
+		}
+	}
+
+	resourceMetrics.RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		return rm.ScopeMetrics().Len() == 0
+	})
+}
+
+// labelValues returns the distinct values of a label across the data points
+// of a metric, in order of appearance
+func labelValues(metric pmetric.Metric, label string) []pcommon.Value {
+	var values []pcommon.Value
+	seen := make(map[string]bool)
+	forEachDataPointAttributes(metric, func(attrs pcommon.Map) {
+		if value, ok := attrs.Get(label); ok && !seen[value.AsString()] {
+			seen[value.AsString()] = true
+			values = append(values, value)
+		}
+	})
+	return values
+}
+
+// forEachDataPointAttributes calls fn with the attributes of every data
+// point of a metric
+func forEachDataPointAttributes(metric pmetric.Metric, fn func(pcommon.Map)) {
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		dps := metric.Gauge().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	case pmetric.MetricTypeSum:
+		dps := metric.Sum().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	case pmetric.MetricTypeHistogram:
+		dps := metric.Histogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		dps := metric.ExponentialHistogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	case pmetric.MetricTypeSummary:
+		dps := metric.Summary().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	}
+}
+
+// removeDataPointsIf removes the data points of a metric whose attributes
+// match
+func removeDataPointsIf(metric pmetric.Metric, match func(pcommon.Map) bool) {
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		metric.Gauge().DataPoints().RemoveIf(func(dp pmetric.NumberDataPoint) bool {
+			return match(dp.Attributes())
+		})
+	case pmetric.MetricTypeSum:
+		metric.Sum().DataPoints().RemoveIf(func(dp pmetric.NumberDataPoint) bool {
+			return match(dp.Attributes())
+		})
+	case pmetric.MetricTypeHistogram:
+		metric.Histogram().DataPoints().RemoveIf(func(dp pmetric.HistogramDataPoint) bool {
+			return match(dp.Attributes())
+		})
+	case pmetric.MetricTypeExponentialHistogram:
+		metric.ExponentialHistogram().DataPoints().RemoveIf(func(dp pmetric.ExponentialHistogramDataPoint) bool {
+			return match(dp.Attributes())
+		})
+	case pmetric.MetricTypeSummary:
+		metric.Summary().DataPoints().RemoveIf(func(dp pmetric.SummaryDataPoint) bool {
+			return match(dp.Attributes())
+		})
+	}
+}
+
+// dataPointCount returns the number of data points of a metric
+func dataPointCount(metric pmetric.Metric) int {
+	count := 0
+	forEachDataPointAttributes(metric, func(pcommon.Map) {
+		count++
+	})
+	return count
+}
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync/atomic"
 
 	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/vm"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.uber.org/zap"
@@ -18,13 +20,24 @@ type Transformer struct {
 	calculator *MetricCalculator
 	logger     *zap.Logger
 	programs   map[int]*vm.Program // Compiled expression programs
+	order      []int               // Transformation indexes in dependency order
+
+	// lastExpiry is when stale derived series were last expired, in unix
+	// nanoseconds
+	lastExpiry atomic.Int64
 }
 
 // NewTransformer creates a new transformer
 func NewTransformer(config *Config, logger *zap.Logger) (*Transformer, error) {
+	return newTransformer(config, logger, clock.Real())
+}
+
+// newTransformer creates a transformer whose derived series state is kept
+// on the given clock
+func newTransformer(config *Config, logger *zap.Logger, clk clock.Clock) (*Transformer, error) {
 	t := &Transformer{
 		config:     config,
-		calculator: NewMetricCalculator(),
+		calculator: newMetricCalculator(clk),
 		logger:     logger,
 		programs:   make(map[int]*vm.Program),
 	}
@@ -40,6 +53,12 @@ func NewTransformer(config *Config, logger *zap.Logger) (*Transformer, error) {
 		}
 	}
 
+	order, err := orderTransformations(config.Transformations)
+	if err != nil {
+		return nil, err
+	}
+	t.order = order
+
 	return t, nil
 }
 
@@ -72,9 +91,11 @@ func (t *Transformer) Transform(metrics pmetric.Metrics) error {
 				metricMap[metric.Name()] = metric
 			}
 			
-			// Apply transformations
-			for idx, transform := range t.config.Transformations {
-				transformedMetrics, toRemove, err := t.applyTransformation(transform, originalMetrics, metricMap, idx)
+			// Apply transformations after the ones producing their inputs, so
+			// derived metrics can be chained within a batch
+			for _, idx := range t.order {
+				transform := t.config.Transformations[idx]
+				transformedMetrics, toRemove, err := t.applyTransformation(transform, rm.Resource(), allMetrics, metricMap, idx)
 				if err != nil {
 					t.logger.Error("Failed to apply transformation",
 						zap.Error(err),
@@ -130,9 +151,48 @@ func (t *Transformer) Transform(metrics pmetric.Metrics) error {
 		}
 	}
 
+	// Regroup resources last, as the transformations above work a scope at
+	// a time
+	for _, idx := range t.order {
+		if transform := t.config.Transformations[idx]; transform.Type == TransformTypeLabelToResource {
+			t.labelToResource(transform, metrics)
+		}
+	}
+
+	t.expireStaleSeries()
+
 	return nil
 }
 
+// expireStaleSeries clears the state of derived series whose sources have
+// not been seen for the staleness window. It runs at most twice per window,
+// so state is cleared between one and one and a half windows after its
+// source was last seen.
+func (t *Transformer) expireStaleSeries() {
+	window := t.config.StalenessWindow
+	if window <= 0 {
+		return
+	}
+
+	store := t.calculator.stateStore
+	now := store.clock.Now()
+	last := t.lastExpiry.Load()
+	if last == 0 {
+		// Start counting from the first batch
+		t.lastExpiry.CompareAndSwap(0, now.UnixNano())
+		return
+	}
+	if now.UnixNano()-last < int64(window/2) || !t.lastExpiry.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	if expired := store.Expire(now.Add(-window)); expired > 0 {
+		t.logger.Debug("Expired derived series whose sources disappeared",
+			zap.Int("series", expired),
+			zap.Duration("staleness_window", window))
+	}
+}
+
 func (t *Transformer) buildMetricMap(metrics pmetric.MetricSlice) map[string]pmetric.Metric {
 	metricMap := make(map[string]pmetric.Metric)
 	for i := 0; i < metrics.Len(); i++ {
@@ -144,7 +204,8 @@ func (t *Transformer) buildMetricMap(metrics pmetric.MetricSlice) map[string]pme
 
 func (t *Transformer) applyTransformation(
 	transform TransformationConfig,
-	metrics pmetric.MetricSlice,
+	resource pcommon.Resource,
+	metrics []pmetric.Metric,
 	metricMap map[string]pmetric.Metric,
 	idx int,
 ) ([]pmetric.Metric, []string, error) {
@@ -211,8 +272,7 @@ func (t *Transformer) applyTransformation(
 		}
 
 	case TransformTypeRename:
-		for i := 0; i < metrics.Len(); i++ {
-			metric := metrics.At(i)
+		for _, metric := range metrics {
 			if metric.Name() == transform.MetricName {
 				newMetric := pmetric.NewMetric()
 				metric.CopyTo(newMetric)
@@ -239,11 +299,54 @@ func (t *Transformer) applyTransformation(
 		if extracted.Type() != pmetric.MetricTypeEmpty {
 			newMetrics = append(newMetrics, extracted)
 		}
+
+	case TransformTypeGaugeToCounter, TransformTypeCounterToGauge:
+		metric, exists := metricMap[transform.MetricName]
+		if !exists {
+			return nil, nil, nil
+		}
+
+		coerced, err := t.coerceType(metric, transform)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if transform.OutputMetric == "" || transform.OutputMetric == transform.MetricName {
+			// Re-type the metric in place
+			coerced.CopyTo(metric)
+		} else {
+			newMetrics = append(newMetrics, coerced)
+		}
+
+	case TransformTypeResourceToLabel:
+		t.resourceToLabel(transform, resource, metrics)
 	}
 
 	return newMetrics, toRemove, nil
 }
 
+func (t *Transformer) coerceType(metric pmetric.Metric, transform TransformationConfig) (pmetric.Metric, error) {
+	outputName := transform.OutputMetric
+	if outputName == "" {
+		outputName = metric.Name()
+	}
+
+	if transform.Type == TransformTypeCounterToGauge {
+		return t.calculator.CounterToGauge(metric, outputName)
+	}
+
+	temporality := pmetric.AggregationTemporalityCumulative
+	if transform.Temporality == TemporalityDelta {
+		temporality = pmetric.AggregationTemporalityDelta
+	}
+	monotonic := true
+	if transform.Monotonic != nil {
+		monotonic = *transform.Monotonic
+	}
+
+	return t.calculator.GaugeToCounter(metric, temporality, monotonic, outputName)
+}
+
 func (t *Transformer) combineMetrics(transform TransformationConfig, metricMap map[string]pmetric.Metric, idx int) (pmetric.Metric, error) {
 	// Get all metrics involved
 	var baseMetric pmetric.Metric
@@ -321,7 +424,7 @@ func (t *Transformer) combineGaugeMetrics(transform TransformationConfig, metric
 			// Use sanitized metric name as variable name in expression
 			varName := strings.ReplaceAll(metricName, ".", "_")
 			varName = strings.ReplaceAll(varName, "-", "_")
-			group.values[varName] = dp.DoubleValue()
+			group.values[varName] = numberValue(dp)
 			if dp.Timestamp() > group.timestamp {
 				group.timestamp = dp.Timestamp()
 			}
@@ -338,7 +441,7 @@ func (t *Transformer) combineGaugeMetrics(transform TransformationConfig, metric
 			continue
 		}
 
-		if value, ok := result.(float64); ok {
+		if value, ok := expressionValue(result); ok {
 			newDp := gauge.DataPoints().AppendEmpty()
 			group.attributes.CopyTo(newDp.Attributes())
 			newDp.SetTimestamp(group.timestamp)
@@ -376,7 +479,7 @@ func (t *Transformer) combineSumMetrics(transform TransformationConfig, metricMa
 			// Use sanitized metric name as variable name in expression
 			varName := strings.ReplaceAll(metricName, ".", "_")
 			varName = strings.ReplaceAll(varName, "-", "_")
-			group.values[varName] = dp.DoubleValue()
+			group.values[varName] = numberValue(dp)
 			if dp.Timestamp() > group.timestamp {
 				group.timestamp = dp.Timestamp()
 			}
@@ -393,7 +496,7 @@ func (t *Transformer) combineSumMetrics(transform TransformationConfig, metricMa
 			continue
 		}
 
-		if value, ok := result.(float64); ok {
+		if value, ok := expressionValue(result); ok {
 			newDp := sum.DataPoints().AppendEmpty()
 			group.attributes.CopyTo(newDp.Attributes())
 			newDp.SetTimestamp(group.timestamp)
@@ -402,7 +505,7 @@ func (t *Transformer) combineSumMetrics(transform TransformationConfig, metricMa
 	}
 }
 
-func (t *Transformer) filterMetrics(transform TransformationConfig, metrics pmetric.MetricSlice) ([]pmetric.Metric, []string, error) {
+func (t *Transformer) filterMetrics(transform TransformationConfig, metrics []pmetric.Metric) ([]pmetric.Metric, []string, error) {
 	var toRemove []string
 	
 	program, err := expr.Compile(transform.Condition)
@@ -410,8 +513,7 @@ func (t *Transformer) filterMetrics(transform TransformationConfig, metrics pmet
 		return nil, nil, fmt.Errorf("failed to compile filter condition: %w", err)
 	}
 
-	for i := 0; i < metrics.Len(); i++ {
-		metric := metrics.At(i)
+	for _, metric := range metrics {
 		env := map[string]interface{}{
 			"name": metric.Name(),
 			"type": metric.Type().String(),
@@ -469,7 +571,7 @@ func (t *Transformer) extractLabelFromGauge(gauge pmetric.Gauge, newGauge pmetri
 				newDp := newGauge.DataPoints().AppendEmpty()
 				dp.CopyTo(newDp)
 				// Set value to 1 for presence
-				newDp.SetDoubleValue(1.0)
+				setNumberValue(newDp, 1)
 			}
 		} else {
 			// Extract specific label value
@@ -492,7 +594,7 @@ func (t *Transformer) extractLabelFromSum(sum pmetric.Sum, newSum pmetric.Sum, l
 				newDp := newSum.DataPoints().AppendEmpty()
 				dp.CopyTo(newDp)
 				// Set value to 1 for presence
-				newDp.SetDoubleValue(1.0)
+				setNumberValue(newDp, 1)
 			}
 		} else {
 			// Extract specific label value
@@ -511,6 +613,20 @@ func (t *Transformer) removeMetrics(metrics pmetric.MetricSlice, toRemove map[st
 	// and not copying them to the final output
 }
 
+// expressionValue converts a numeric expression result to a float
+func expressionValue(result interface{}) (float64, bool) {
+	switch v := result.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
 func (t *Transformer) attributeKey(attrs pcommon.Map) string {
 	keys := []string{}
 	attrs.Range(func(k string, v pcommon.Value) bool {
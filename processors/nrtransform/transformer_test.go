@@ -2,7 +2,9 @@ package nrtransform
 
 import (
 	"testing"
+	"time"
 
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/clock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/pdata/pcommon"
@@ -135,6 +137,37 @@ func TestTransformer_MultipleTransformations(t *testing.T) {
 	assert.True(t, foundConverted, "converted metric not found")
 }
 
+func TestTransformer_GaugeToCounterInPlace(t *testing.T) {
+	config := &Config{
+		Transformations: []TransformationConfig{
+			{
+				Type:       TransformTypeGaugeToCounter,
+				MetricName: "disk.reads",
+			},
+		},
+	}
+
+	transformer, err := NewTransformer(config, zap.NewNop())
+	require.NoError(t, err)
+
+	metrics := pmetric.NewMetrics()
+	sm := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	metric := sm.Metrics().AppendEmpty()
+	metric.SetName("disk.reads")
+	metric.SetEmptyGauge()
+	metric.Gauge().DataPoints().AppendEmpty().SetDoubleValue(1234)
+
+	require.NoError(t, transformer.Transform(metrics))
+
+	// The metric is re-typed rather than duplicated
+	require.Equal(t, 1, metrics.MetricCount())
+	out := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	assert.Equal(t, "disk.reads", out.Name())
+	require.Equal(t, pmetric.MetricTypeSum, out.Type())
+	assert.True(t, out.Sum().IsMonotonic())
+	assert.Equal(t, 1234.0, out.Sum().DataPoints().At(0).DoubleValue())
+}
+
 func TestAttributeKey(t *testing.T) {
 	transformer := &Transformer{logger: zap.NewNop()}
 	
@@ -164,4 +197,394 @@ func TestTransformer_InvalidExpression(t *testing.T) {
 	_, err := NewTransformer(config, logger)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to compile expression")
-}
\ No newline at end of file
+}
+func TestTransformer_CombineIntValues(t *testing.T) {
+	config := &Config{
+		Transformations: []TransformationConfig{
+			{
+				Type:         TransformTypeCombine,
+				Metrics:      []string{"disk.used", "disk.total"},
+				Expression:   "disk_used / disk_total * 100",
+				OutputMetric: "disk.utilization",
+			},
+		},
+	}
+
+	transformer, err := NewTransformer(config, zap.NewNop())
+	require.NoError(t, err)
+
+	metrics := pmetric.NewMetrics()
+	sm := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+
+	// An integer series combined with a double one
+	used := sm.Metrics().AppendEmpty()
+	used.SetName("disk.used")
+	used.SetEmptyGauge()
+	dp := used.Gauge().DataPoints().AppendEmpty()
+	dp.SetIntValue(250)
+	dp.Attributes().PutStr("device", "sda")
+
+	total := sm.Metrics().AppendEmpty()
+	total.SetName("disk.total")
+	total.SetEmptyGauge()
+	dp = total.Gauge().DataPoints().AppendEmpty()
+	dp.SetDoubleValue(1000)
+	dp.Attributes().PutStr("device", "sda")
+
+	require.NoError(t, transformer.Transform(metrics))
+
+	var combined pmetric.Metric
+	outputMetrics := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < outputMetrics.Len(); i++ {
+		if outputMetrics.At(i).Name() == "disk.utilization" {
+			combined = outputMetrics.At(i)
+		}
+	}
+	require.Equal(t, "disk.utilization", combined.Name())
+	require.Equal(t, 1, combined.Gauge().DataPoints().Len())
+	assert.Equal(t, 25.0, combined.Gauge().DataPoints().At(0).DoubleValue())
+}
+
+func TestTransformer_ChainedTransformations(t *testing.T) {
+	// Each transformation reads the output of the one after it
+	config := &Config{
+		Transformations: []TransformationConfig{
+			{
+				Type:         TransformTypeRename,
+				MetricName:   "disk.utilization",
+				OutputMetric: "disk.utilization.percent",
+			},
+			{
+				Type:         TransformTypeCombine,
+				Metrics:      []string{"disk.used.kb", "disk.total"},
+				Expression:   "disk_used_kb / disk_total * 100",
+				OutputMetric: "disk.utilization",
+			},
+			{
+				Type:         TransformTypeConvertUnit,
+				MetricName:   "disk.used",
+				OutputMetric: "disk.used.kb",
+				FromUnit:     "bytes",
+				ToUnit:       "kilobytes",
+			},
+		},
+	}
+	require.NoError(t, config.Validate())
+
+	transformer, err := NewTransformer(config, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 1, 0}, transformer.order)
+
+	metrics := pmetric.NewMetrics()
+	sm := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+
+	used := sm.Metrics().AppendEmpty()
+	used.SetName("disk.used")
+	used.SetEmptyGauge()
+	used.Gauge().DataPoints().AppendEmpty().SetDoubleValue(256000)
+
+	total := sm.Metrics().AppendEmpty()
+	total.SetName("disk.total")
+	total.SetEmptyGauge()
+	total.Gauge().DataPoints().AppendEmpty().SetDoubleValue(1000)
+
+	require.NoError(t, transformer.Transform(metrics))
+
+	names := make(map[string]pmetric.Metric)
+	outputMetrics := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < outputMetrics.Len(); i++ {
+		names[outputMetrics.At(i).Name()] = outputMetrics.At(i)
+	}
+	assert.NotContains(t, names, "disk.utilization")
+	require.Contains(t, names, "disk.utilization.percent")
+	assert.Equal(t, 25.0, names["disk.utilization.percent"].Gauge().DataPoints().At(0).DoubleValue())
+}
+
+func TestOrderTransformations(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  []TransformationConfig
+		want    []int
+		wantErr string
+	}{
+		{
+			name: "independent keep config order",
+			config: []TransformationConfig{
+				{Type: TransformTypeCalculateRate, MetricName: "b", OutputMetric: "b.rate"},
+				{Type: TransformTypeCalculateRate, MetricName: "a", OutputMetric: "a.rate"},
+			},
+			want: []int{0, 1},
+		},
+		{
+			name: "consumer moves after producer",
+			config: []TransformationConfig{
+				{Type: TransformTypeConvertUnit, MetricName: "a.rate", OutputMetric: "a.rate.kb"},
+				{Type: TransformTypeFilter, Condition: "true"},
+				{Type: TransformTypeCalculateRate, MetricName: "a", OutputMetric: "a.rate"},
+			},
+			want: []int{1, 2, 0},
+		},
+		{
+			name: "in-place coercion",
+			config: []TransformationConfig{
+				{Type: TransformTypeGaugeToCounter, MetricName: "a"},
+				{Type: TransformTypeConvertUnit, MetricName: "a", OutputMetric: "a"},
+			},
+			want: []int{0, 1},
+		},
+		{
+			name: "cycle",
+			config: []TransformationConfig{
+				{Type: TransformTypeCalculateRate, MetricName: "x", OutputMetric: "x.rate"},
+				{Type: TransformTypeRename, MetricName: "b", OutputMetric: "a"},
+				{Type: TransformTypeCombine, Metrics: []string{"a", "x.rate"}, OutputMetric: "b"},
+			},
+			wantErr: "dependency cycle: 2 (b) -> 1 (a) -> 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := orderTransformations(tt.config)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, order)
+		})
+	}
+}
+
+func TestTransformer_StaleDerivedSeries(t *testing.T) {
+	config := &Config{
+		Transformations: []TransformationConfig{
+			{Type: TransformTypeCalculateRate, MetricName: "requests", OutputMetric: "requests.rate"},
+		},
+		StalenessWindow: time.Minute,
+	}
+	clk := clock.NewFake(time.Unix(1700000000, 0))
+	transformer, err := newTransformer(config, zap.NewNop(), clk)
+	require.NoError(t, err)
+	store := transformer.calculator.stateStore
+
+	batch := func(ts time.Time, pods ...string) pmetric.Metrics {
+		metrics := pmetric.NewMetrics()
+		metric := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		metric.SetName("requests")
+		metric.SetEmptySum()
+		metric.Sum().SetIsMonotonic(true)
+		metric.Sum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		for _, pod := range pods {
+			dp := metric.Sum().DataPoints().AppendEmpty()
+			dp.SetTimestamp(pcommon.NewTimestampFromTime(ts))
+			dp.SetIntValue(ts.Unix() - 1700000000)
+			dp.Attributes().PutStr("pod", pod)
+		}
+		return metrics
+	}
+	rates := func(metrics pmetric.Metrics) int {
+		ms := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+		for i := 0; i < ms.Len(); i++ {
+			if ms.At(i).Name() == "requests.rate" {
+				return ms.At(i).Gauge().DataPoints().Len()
+			}
+		}
+		return 0
+	}
+
+	require.NoError(t, transformer.Transform(batch(clk.Now(), "a", "b")))
+	assert.Equal(t, 2, store.Len())
+
+	// Pod b goes away with a deployment; a keeps reporting
+	for i := 1; i <= 4; i++ {
+		clk.Advance(30 * time.Second)
+		metrics := batch(clk.Now(), "a")
+		require.NoError(t, transformer.Transform(metrics))
+		assert.Equal(t, 1, rates(metrics))
+	}
+	assert.Equal(t, 1, store.Len(), "the state of the series derived from b is cleared")
+
+	// A returning source starts over rather than resuming from stale state
+	clk.Advance(30 * time.Second)
+	metrics := batch(clk.Now(), "a", "b")
+	require.NoError(t, transformer.Transform(metrics))
+	assert.Equal(t, 1, rates(metrics))
+	assert.Equal(t, 2, store.Len())
+	config.StalenessWindow = -time.Second
+	assert.Error(t, config.Validate())
+	assert.Equal(t, 5*time.Minute, createDefaultConfig().(*Config).StalenessWindow)
+}
+
+func TestTransformer_StateKeyedBySource(t *testing.T) {
+	config := &Config{
+		Transformations: []TransformationConfig{
+			{Type: TransformTypeCalculateDelta, MetricName: "rx", OutputMetric: "rx.delta"},
+			{Type: TransformTypeCalculateDelta, MetricName: "tx", OutputMetric: "tx.delta"},
+		},
+	}
+	transformer, err := NewTransformer(config, zap.NewNop())
+	require.NoError(t, err)
+
+	metrics := pmetric.NewMetrics()
+	sm := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	for _, name := range []string{"rx", "tx"} {
+		metric := sm.Metrics().AppendEmpty()
+		metric.SetName(name)
+		metric.SetEmptySum()
+		metric.Sum().SetIsMonotonic(true)
+		dp := metric.Sum().DataPoints().AppendEmpty()
+		dp.SetIntValue(100)
+		dp.Attributes().PutStr("nic", "eth0")
+	}
+	require.NoError(t, transformer.Transform(metrics))
+
+	// Series with the same attributes from different sources keep separate state
+	assert.Equal(t, 2, transformer.calculator.stateStore.Len())
+}
+
+func TestTransformer_ResourceToLabel(t *testing.T) {
+	config := &Config{
+		Transformations: []TransformationConfig{
+			{Type: TransformTypeResourceToLabel, ResourceAttribute: "host.name"},
+			{
+				Type:         TransformTypeAggregate,
+				MetricName:   "cpu.usage",
+				Aggregation:  AggregationSum,
+				GroupBy:      []string{"host.name"},
+				OutputMetric: "cpu.usage.by_host",
+			},
+		},
+	}
+	require.NoError(t, config.Validate())
+	transformer, err := NewTransformer(config, zap.NewNop())
+	require.NoError(t, err)
+
+	metrics := pmetric.NewMetrics()
+	rm := metrics.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("host.name", "web-1")
+	metric := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("cpu.usage")
+	metric.SetEmptyGauge()
+	for _, cpu := range []string{"0", "1"} {
+		dp := metric.Gauge().DataPoints().AppendEmpty()
+		dp.SetDoubleValue(0.25)
+		dp.Attributes().PutStr("cpu", cpu)
+	}
+	// A data point's own label wins
+	dp := metric.Gauge().DataPoints().AppendEmpty()
+	dp.SetDoubleValue(0.5)
+	dp.Attributes().PutStr("host.name", "web-2")
+
+	require.NoError(t, transformer.Transform(metrics))
+
+	// The resource keeps its attribute
+	_, ok := rm.Resource().Attributes().Get("host.name")
+	assert.True(t, ok)
+
+	output := rm.ScopeMetrics().At(0).Metrics()
+	require.Equal(t, 2, output.Len())
+	dps := output.At(0).Gauge().DataPoints()
+	for i, want := range []string{"web-1", "web-1", "web-2"} {
+		host, _ := dps.At(i).Attributes().Get("host.name")
+		assert.Equal(t, want, host.Str())
+	}
+
+	// The aggregate groups by the copied label
+	aggregated := output.At(1)
+	assert.Equal(t, "cpu.usage.by_host", aggregated.Name())
+	assert.Equal(t, 2, aggregated.Gauge().DataPoints().Len())
+}
+
+func TestTransformer_LabelToResource(t *testing.T) {
+	config := &Config{
+		Transformations: []TransformationConfig{
+			{
+				Type:              TransformTypeLabelToResource,
+				MetricName:        "kafka.consumer.lag",
+				LabelKey:          "instance",
+				ResourceAttribute: "host.name",
+			},
+		},
+	}
+	require.NoError(t, config.Validate())
+	transformer, err := NewTransformer(config, zap.NewNop())
+	require.NoError(t, err)
+
+	metrics := pmetric.NewMetrics()
+	rm := metrics.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "kafka")
+	sm := rm.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName("prometheus")
+
+	lag := sm.Metrics().AppendEmpty()
+	lag.SetName("kafka.consumer.lag")
+	lag.SetEmptySum()
+	for _, point := range []struct {
+		instance string
+		topic    string
+	}{{"broker-1", "orders"}, {"broker-2", "orders"}, {"broker-1", "payments"}, {"", "audit"}} {
+		dp := lag.Sum().DataPoints().AppendEmpty()
+		dp.SetIntValue(10)
+		dp.Attributes().PutStr("topic", point.topic)
+		if point.instance != "" {
+			dp.Attributes().PutStr("instance", point.instance)
+		}
+	}
+
+	// Not named, so left alone
+	up := sm.Metrics().AppendEmpty()
+	up.SetName("up")
+	up.SetEmptyGauge().DataPoints().AppendEmpty().Attributes().PutStr("instance", "broker-1")
+
+	require.NoError(t, transformer.Transform(metrics))
+
+	resources := metrics.ResourceMetrics()
+	require.Equal(t, 3, resources.Len())
+
+	// The data point without the label stays, next to the other metric
+	original := resources.At(0).ScopeMetrics().At(0).Metrics()
+	require.Equal(t, 2, original.Len())
+	assert.Equal(t, 1, original.At(0).Sum().DataPoints().Len())
+	assert.Equal(t, "up", original.At(1).Name())
+
+	for i, want := range []struct {
+		host   string
+		points int
+	}{{"broker-1", 2}, {"broker-2", 1}} {
+		split := resources.At(i + 1)
+		assert.Equal(t, map[string]any{"service.name": "kafka", "host.name": want.host}, split.Resource().Attributes().AsRaw())
+		require.Equal(t, 1, split.ScopeMetrics().Len())
+		assert.Equal(t, "prometheus", split.ScopeMetrics().At(0).Scope().Name())
+
+		dps := split.ScopeMetrics().At(0).Metrics().At(0).Sum().DataPoints()
+		require.Equal(t, want.points, dps.Len())
+		for k := 0; k < dps.Len(); k++ {
+			_, ok := dps.At(k).Attributes().Get("instance")
+			assert.False(t, ok)
+		}
+	}
+
+	// A resource left empty is removed
+	metrics = pmetric.NewMetrics()
+	only := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	only.SetName("kafka.consumer.lag")
+	only.SetEmptyGauge().DataPoints().AppendEmpty().Attributes().PutStr("instance", "broker-1")
+	require.NoError(t, transformer.Transform(metrics))
+	require.Equal(t, 1, metrics.ResourceMetrics().Len())
+	host, _ := metrics.ResourceMetrics().At(0).Resource().Attributes().Get("host.name")
+	assert.Equal(t, "broker-1", host.Str())
+}
+
+func TestValidate_ResourceTransformations(t *testing.T) {
+	for _, transform := range []TransformationConfig{
+		{Type: TransformTypeResourceToLabel},
+		{Type: TransformTypeResourceToLabel, ResourceAttribute: "host.name", OutputMetric: "x"},
+		{Type: TransformTypeLabelToResource},
+		{Type: TransformTypeLabelToResource, LabelKey: "instance", OutputMetric: "x"},
+	} {
+		config := &Config{Transformations: []TransformationConfig{transform}}
+		assert.Error(t, config.Validate(), "%+v", transform)
+	}
+}
@@ -0,0 +1,244 @@
+// Command leakcheck reads the resource samples of a soak run and fails when
+// a process's memory or goroutines grow steadily over the run.
+//
+// Samples are CSV lines of unix_seconds,process,metric,value. After the
+// warm-up, each series is split into equal windows and the minimum of each
+// window is taken, so GC cycles and batching spikes do not count. A series
+// leaks when its window minimums never decrease and the last exceeds the
+// first by more than both the relative and the absolute threshold.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// sample is one measurement of a process metric
+type sample struct {
+	time  time.Time
+	value float64
+}
+
+// seriesKey identifies a metric of a process
+type seriesKey struct {
+	process string
+	metric  string
+}
+
+// thresholds decide when growth counts as a leak
+type thresholds struct {
+	warmup    time.Duration
+	windows   int
+	maxGrowth float64
+	// minGrowth is the absolute growth per metric below which a series
+	// never leaks; metrics not listed have no absolute threshold
+	minGrowth map[string]float64
+}
+
+// result is the verdict on one series
+type result struct {
+	Process     string    `json:"process"`
+	Metric      string    `json:"metric"`
+	Samples     int       `json:"samples"`
+	WindowMins  []float64 `json:"window_mins,omitempty"`
+	Growth      float64   `json:"growth"`
+	GrowthRatio float64   `json:"growth_ratio"`
+	SlopePerHr  float64   `json:"slope_per_hour"`
+	Monotonic   bool      `json:"monotonic"`
+	Leak        bool      `json:"leak"`
+	Skipped     string    `json:"skipped,omitempty"`
+}
+
+func main() {
+	var (
+		samplesFile = flag.String("samples", "samples.csv", "CSV samples: unix_seconds,process,metric,value")
+		reportFile  = flag.String("report", "", "Write the results as JSON to this file")
+		warmup      = flag.Duration("warmup", 15*time.Minute, "Ignore samples taken this long after the first")
+		windows     = flag.Int("windows", 6, "Number of windows the run is split into")
+		maxGrowth   = flag.Float64("max-growth", 0.2, "Relative growth of the window minimum allowed, e.g. 0.2 for 20%")
+		minRSS      = flag.Float64("min-rss-growth", 64<<20, "Absolute rss_bytes growth below which memory never leaks")
+		minRoutines = flag.Float64("min-goroutine-growth", 50, "Absolute goroutines growth below which goroutines never leak")
+	)
+	flag.Parse()
+
+	f, err := os.Open(*samplesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "leakcheck: %v\n", err)
+		os.Exit(2)
+	}
+	series, err := readSamples(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "leakcheck: %s: %v\n", *samplesFile, err)
+		os.Exit(2)
+	}
+
+	results := analyze(series, thresholds{
+		warmup:    *warmup,
+		windows:   *windows,
+		maxGrowth: *maxGrowth,
+		minGrowth: map[string]float64{
+			"rss_bytes":  *minRSS,
+			"goroutines": *minRoutines,
+		},
+	})
+
+	leaks := 0
+	for _, r := range results {
+		verdict := "ok"
+		switch {
+		case r.Skipped != "":
+			verdict = "skipped: " + r.Skipped
+		case r.Leak:
+			verdict = "LEAK"
+			leaks++
+		}
+		fmt.Printf("%-12s %-12s samples=%-5d growth=%-14.0f ratio=%-7.3f slope/h=%-12.1f %s\n",
+			r.Process, r.Metric, r.Samples, r.Growth, r.GrowthRatio, r.SlopePerHr, verdict)
+	}
+
+	if *reportFile != "" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*reportFile, data, 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "leakcheck: failed to write report: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if leaks > 0 {
+		fmt.Printf("%d series grew steadily beyond the thresholds\n", leaks)
+		os.Exit(1)
+	}
+}
+
+// readSamples parses CSV samples into series ordered by time
+func readSamples(r io.Reader) (map[seriesKey][]sample, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 4
+	reader.Comment = '#'
+
+	series := make(map[seriesKey][]sample)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		seconds, err := strconv.ParseInt(record[0], 10, 64)
+		if err != nil {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: invalid time %q", line, record[0])
+		}
+		value, err := strconv.ParseFloat(record[3], 64)
+		if err != nil {
+			line, _ := reader.FieldPos(3)
+			return nil, fmt.Errorf("line %d: invalid value %q", line, record[3])
+		}
+		key := seriesKey{process: record[1], metric: record[2]}
+		series[key] = append(series[key], sample{time: time.Unix(seconds, 0), value: value})
+	}
+
+	for _, samples := range series {
+		sort.SliceStable(samples, func(i, j int) bool { return samples[i].time.Before(samples[j].time) })
+	}
+	return series, nil
+}
+
+// analyze judges every series, ordered by process and metric
+func analyze(series map[seriesKey][]sample, t thresholds) []result {
+	keys := make([]seriesKey, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].process != keys[j].process {
+			return keys[i].process < keys[j].process
+		}
+		return keys[i].metric < keys[j].metric
+	})
+
+	results := make([]result, 0, len(keys))
+	for _, key := range keys {
+		results = append(results, analyzeSeries(key, series[key], t))
+	}
+	return results
+}
+
+// analyzeSeries judges one series ordered by time
+func analyzeSeries(key seriesKey, samples []sample, t thresholds) result {
+	r := result{Process: key.process, Metric: key.metric}
+	if len(samples) == 0 {
+		r.Skipped = "no samples"
+		return r
+	}
+
+	start := samples[0].time.Add(t.warmup)
+	for len(samples) > 0 && samples[0].time.Before(start) {
+		samples = samples[1:]
+	}
+	r.Samples = len(samples)
+
+	windows := max(t.windows, 2)
+	if len(samples) < 2*windows {
+		r.Skipped = fmt.Sprintf("need %d samples after warm-up", 2*windows)
+		return r
+	}
+
+	r.WindowMins = make([]float64, windows)
+	for w := range r.WindowMins {
+		lo := w * len(samples) / windows
+		hi := (w + 1) * len(samples) / windows
+		r.WindowMins[w] = samples[lo].value
+		for _, s := range samples[lo+1 : hi] {
+			r.WindowMins[w] = min(r.WindowMins[w], s.value)
+		}
+	}
+
+	first, last := r.WindowMins[0], r.WindowMins[windows-1]
+	r.Monotonic = last > first
+	for w := 1; w < windows; w++ {
+		if r.WindowMins[w] < r.WindowMins[w-1] {
+			r.Monotonic = false
+		}
+	}
+	r.Growth = last - first
+	if first > 0 {
+		r.GrowthRatio = r.Growth / first
+	}
+	r.SlopePerHr = slope(samples) * float64(time.Hour/time.Second)
+
+	r.Leak = r.Monotonic && r.GrowthRatio > t.maxGrowth && r.Growth > t.minGrowth[key.metric]
+	return r
+}
+
+// slope returns the least-squares slope of the samples in units per second
+func slope(samples []sample) float64 {
+	origin := samples[0].time
+	var n, sumX, sumY, sumXY, sumXX float64
+	for _, s := range samples {
+		x := s.time.Sub(origin).Seconds()
+		n++
+		sumX += x
+		sumY += s.value
+		sumXY += x * s.value
+		sumXX += x * x
+	}
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denominator
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// generateSamples writes n CSV samples of value(i), one a minute
+func generateSamples(process, metric string, n int, value func(i int) float64) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "%d,%s,%s,%g\n", 1700000000+i*60, process, metric, value(i))
+	}
+	return b.String()
+}
+
+func testThresholds() thresholds {
+	return thresholds{
+		warmup:    10 * time.Minute,
+		windows:   6,
+		maxGrowth: 0.2,
+		minGrowth: map[string]float64{"rss_bytes": 64 << 20, "goroutines": 50},
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	const mib = 1 << 20
+	input := "# unix_seconds,process,metric,value\n" +
+		// Grows 1MiB a minute behind a GC sawtooth: a leak
+		generateSamples("collector", "rss_bytes", 240, func(i int) float64 {
+			return float64(200*mib + i*mib + (i%5)*20*mib)
+		}) +
+		// Sawtooth around a flat baseline
+		generateSamples("supervisor", "rss_bytes", 240, func(i int) float64 {
+			return float64(40*mib + (i%7)*10*mib)
+		}) +
+		// Doubles, but by fewer goroutines than the absolute threshold
+		generateSamples("supervisor", "goroutines", 240, func(i int) float64 {
+			return float64(20 + i/10)
+		}) +
+		// Grew during warm-up only
+		generateSamples("collector", "goroutines", 240, func(i int) float64 {
+			return float64(min(i, 10)*30 + 100)
+		}) +
+		generateSamples("collector", "threads", 5, func(i int) float64 { return 12 })
+
+	series, err := readSamples(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Failed to read samples: %v", err)
+	}
+	results := analyze(series, testThresholds())
+
+	verdicts := make(map[string]result)
+	for _, r := range results {
+		verdicts[r.Process+"/"+r.Metric] = r
+	}
+	if len(verdicts) != 5 {
+		t.Fatalf("Expected 5 series, got %d", len(verdicts))
+	}
+
+	if r := verdicts["collector/rss_bytes"]; !r.Leak || !r.Monotonic || r.SlopePerHr < 50*mib {
+		t.Errorf("Expected collector memory to leak: %+v", r)
+	}
+	if r := verdicts["supervisor/rss_bytes"]; r.Leak {
+		t.Errorf("Expected flat supervisor memory: %+v", r)
+	}
+	if r := verdicts["supervisor/goroutines"]; r.Leak || !r.Monotonic || r.GrowthRatio < 0.2 {
+		t.Errorf("Expected goroutine growth under the absolute threshold: %+v", r)
+	}
+	if r := verdicts["collector/goroutines"]; r.Leak || r.Samples != 230 {
+		t.Errorf("Expected warm-up growth to be ignored: %+v", r)
+	}
+	if r := verdicts["collector/threads"]; r.Skipped == "" {
+		t.Errorf("Expected a short series to be skipped: %+v", r)
+	}
+}
+
+func TestReadSamplesInvalid(t *testing.T) {
+	for _, input := range []string{
+		"1700000000,collector,rss_bytes\n",
+		"yesterday,collector,rss_bytes,1\n",
+		"1700000000,collector,rss_bytes,lots\n",
+	} {
+		if _, err := readSamples(strings.NewReader(input)); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+// Command soak-generator sends a steady mix of metrics, traces and logs over
+// OTLP/HTTP for soak tests.
+//
+// Metric series churn like pods being replaced: every CHURN_INTERVAL,
+// CHURN_PODS of the POD_COUNT pods get new names, so stateful processors see
+// a constant stream of new series and must let the old ones go. Payloads are
+// OTLP JSON, and when an endpoint refuses a request the generator moves on to
+// the next one in OTLP_ENDPOINTS, following the collector across blue-green
+// reloads.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+type object = map[string]interface{}
+
+// settings are read from the environment
+type settings struct {
+	endpoints      []string
+	interval       time.Duration
+	podCount       int
+	churnPods      int
+	churnInterval  time.Duration
+	spansPerSecond int
+	logsPerSecond  int
+}
+
+func loadSettings() settings {
+	return settings{
+		endpoints:      strings.Split(getenv("OTLP_ENDPOINTS", "http://localhost:4318"), ","),
+		interval:       getDuration("EXPORT_INTERVAL", 10*time.Second),
+		podCount:       getInt("POD_COUNT", 50),
+		churnPods:      getInt("CHURN_PODS", 5),
+		churnInterval:  getDuration("CHURN_INTERVAL", time.Minute),
+		spansPerSecond: getInt("SPANS_PER_SECOND", 50),
+		logsPerSecond:  getInt("LOGS_PER_SECOND", 50),
+	}
+}
+
+func main() {
+	s := loadSettings()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Sending to %v: %d pods (%d replaced every %s), %d spans/s, %d logs/s",
+		s.endpoints, s.podCount, s.churnPods, s.churnInterval, s.spansPerSecond, s.logsPerSecond)
+
+	client := &otlpClient{endpoints: s.endpoints, http: &http.Client{Timeout: 10 * time.Second}}
+	pods := newPodSet(s.podCount)
+	start := time.Now()
+
+	export := time.NewTicker(s.interval)
+	defer export.Stop()
+	churn := time.NewTicker(s.churnInterval)
+	defer churn.Stop()
+	second := time.NewTicker(time.Second)
+	defer second.Stop()
+
+	var sent, failed int
+	report := time.NewTicker(5 * time.Minute)
+	defer report.Stop()
+
+	send := func(path string, payload object) {
+		if err := client.send(ctx, path, payload); err != nil {
+			failed++
+			log.Printf("Failed to send %s: %v", path, err)
+			return
+		}
+		sent++
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-churn.C:
+			pods.replace(s.churnPods)
+		case <-export.C:
+			send("/v1/metrics", pods.metrics(start, time.Now()))
+		case <-second.C:
+			now := time.Now()
+			send("/v1/traces", traces(pods.pods, s.spansPerSecond, now))
+			send("/v1/logs", logs(pods.pods, s.logsPerSecond, now))
+		case <-report.C:
+			log.Printf("Sent %d requests, %d failed, %d pods created", sent, failed, pods.created)
+		}
+	}
+}
+
+// otlpClient posts OTLP JSON to the first endpoint that accepts it
+type otlpClient struct {
+	endpoints []string
+	current   int
+	http      *http.Client
+}
+
+// send posts payload to path, trying each endpoint once starting with the
+// last one that worked
+func (c *otlpClient) send(ctx context.Context, path string, payload object) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for i := range c.endpoints {
+		endpoint := c.endpoints[(c.current+i)%len(c.endpoints)]
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+path, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			lastErr = fmt.Errorf("%s answered %s", endpoint, resp.Status)
+			continue
+		}
+		c.current = (c.current + i) % len(c.endpoints)
+		return nil
+	}
+	return lastErr
+}
+
+// podSet is the set of pods currently reporting, with the cumulative request
+// counts of their series
+type podSet struct {
+	pods     []string
+	requests map[string]int64
+	created  int
+}
+
+func newPodSet(n int) *podSet {
+	p := &podSet{requests: make(map[string]int64)}
+	for i := 0; i < n; i++ {
+		p.pods = append(p.pods, p.newName())
+	}
+	return p
+}
+
+// newName returns the name of a pod never seen before
+func (p *podSet) newName() string {
+	p.created++
+	return fmt.Sprintf("soak-%06d", p.created)
+}
+
+// replace swaps n random pods for new ones and forgets their series
+func (p *podSet) replace(n int) {
+	for i := 0; i < n && len(p.pods) > 0; i++ {
+		idx := mathrand.Intn(len(p.pods))
+		old := p.pods[idx]
+		for key := range p.requests {
+			if strings.HasPrefix(key, old+"|") {
+				delete(p.requests, key)
+			}
+		}
+		p.pods[idx] = p.newName()
+	}
+}
+
+var endpoints = []string{"/api/orders", "/api/users", "/api/search", "/healthz"}
+
+// metrics builds a cumulative counter, a gauge and a histogram per pod and
+// endpoint
+func (p *podSet) metrics(start, now time.Time) object {
+	var counters, gauges, histograms []interface{}
+	bounds := []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+	for _, pod := range p.pods {
+		for _, endpoint := range endpoints {
+			attrs := attributes("k8s.pod.name", pod, "http.route", endpoint, "http.method", "GET")
+
+			key := pod + "|" + endpoint
+			p.requests[key] += int64(mathrand.Intn(100))
+			counters = append(counters, object{
+				"attributes":        attrs,
+				"startTimeUnixNano": nanos(start),
+				"timeUnixNano":      nanos(now),
+				"asInt":             strconv.FormatInt(p.requests[key], 10),
+			})
+
+			gauges = append(gauges, object{
+				"attributes":   attrs,
+				"timeUnixNano": nanos(now),
+				"asDouble":     mathrand.Float64() * 100,
+			})
+
+			counts := make([]string, len(bounds)+1)
+			var total int64
+			var sum float64
+			for i := range counts {
+				c := int64(mathrand.Intn(20))
+				counts[i] = strconv.FormatInt(c, 10)
+				total += c
+				if i < len(bounds) {
+					sum += float64(c) * bounds[i]
+				}
+			}
+			histograms = append(histograms, object{
+				"attributes":        attrs,
+				"startTimeUnixNano": nanos(now.Add(-10 * time.Second)),
+				"timeUnixNano":      nanos(now),
+				"count":             strconv.FormatInt(total, 10),
+				"sum":               sum,
+				"bucketCounts":      counts,
+				"explicitBounds":    bounds,
+			})
+		}
+	}
+
+	return object{"resourceMetrics": []interface{}{object{
+		"resource": object{"attributes": attributes("service.name", "soak-generator")},
+		"scopeMetrics": []interface{}{object{
+			"scope": object{"name": "soak-generator"},
+			"metrics": []interface{}{
+				object{"name": "http.server.requests", "unit": "1", "sum": object{
+					"aggregationTemporality": 2, "isMonotonic": true, "dataPoints": counters,
+				}},
+				object{"name": "http.server.active_requests", "unit": "1", "gauge": object{
+					"dataPoints": gauges,
+				}},
+				object{"name": "http.server.duration", "unit": "s", "histogram": object{
+					"aggregationTemporality": 1, "dataPoints": histograms,
+				}},
+			},
+		}},
+	}}}
+}
+
+// traces builds n server spans spread over the pods
+func traces(pods []string, n int, now time.Time) object {
+	spans := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		duration := time.Duration(mathrand.Intn(500)) * time.Millisecond
+		endpoint := endpoints[mathrand.Intn(len(endpoints))]
+		status := object{}
+		if mathrand.Intn(50) == 0 {
+			status = object{"code": 2, "message": "upstream timeout"}
+		}
+		spans = append(spans, object{
+			"traceId":           randomHex(16),
+			"spanId":            randomHex(8),
+			"name":              "GET " + endpoint,
+			"kind":              2,
+			"startTimeUnixNano": nanos(now.Add(-duration)),
+			"endTimeUnixNano":   nanos(now),
+			"attributes":        attributes("k8s.pod.name", pods[mathrand.Intn(len(pods))], "http.route", endpoint),
+			"status":            status,
+		})
+	}
+
+	return object{"resourceSpans": []interface{}{object{
+		"resource":   object{"attributes": attributes("service.name", "soak-generator")},
+		"scopeSpans": []interface{}{object{"scope": object{"name": "soak-generator"}, "spans": spans}},
+	}}}
+}
+
+// logs builds n log records, some of them carrying secrets to redact
+func logs(pods []string, n int, now time.Time) object {
+	records := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		severity, text, body := 9, "INFO", "request served"
+		switch mathrand.Intn(20) {
+		case 0:
+			severity, text, body = 17, "ERROR", "request failed: password=hunter2 rejected"
+		case 1:
+			severity, text, body = 13, "WARN", "slow request"
+		}
+		records = append(records, object{
+			"timeUnixNano":   nanos(now),
+			"severityNumber": severity,
+			"severityText":   text,
+			"body":           object{"stringValue": body},
+			"attributes":     attributes("k8s.pod.name", pods[mathrand.Intn(len(pods))]),
+		})
+	}
+
+	return object{"resourceLogs": []interface{}{object{
+		"resource":  object{"attributes": attributes("service.name", "soak-generator")},
+		"scopeLogs": []interface{}{object{"scope": object{"name": "soak-generator"}, "logRecords": records}},
+	}}}
+}
+
+// attributes builds OTLP string attributes from key, value pairs
+func attributes(kv ...string) []interface{} {
+	attrs := make([]interface{}, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		attrs = append(attrs, object{"key": kv[i], "value": object{"stringValue": kv[i+1]}})
+	}
+	return attrs
+}
+
+// nanos formats a time as OTLP JSON encodes 64-bit integers
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func getenv(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getInt(name string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v >= 0 {
+		return v
+	}
+	return fallback
+}
+
+func getDuration(name string, fallback time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(name)); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,267 @@
+#!/bin/bash
+
+set -e
+
+SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
+TEST_NAME="soak"
+RESULTS_DIR="${RESULTS_DIR:-$SCRIPT_DIR/../test-results/${TEST_NAME}}"
+
+# Run length and cadence, as Go durations (s, m or h)
+SOAK_DURATION="${SOAK_DURATION:-6h}"
+SAMPLE_INTERVAL="${SAMPLE_INTERVAL:-60s}"
+# In-process collector reloads (SIGHUP to the collector): processors are
+# shut down and recreated in the same process, so state they fail to release
+# accumulates
+RELOAD_INTERVAL="${RELOAD_INTERVAL:-10m}"
+# Config changes applied by the supervisor with a blue-green reload, which
+# replaces the collector process; 0 disables them
+BLUEGREEN_INTERVAL="${BLUEGREEN_INTERVAL:-2h}"
+
+# Leak thresholds, see leakcheck
+WARMUP="${WARMUP:-15m}"
+MAX_GROWTH="${MAX_GROWTH:-0.2}"
+MIN_RSS_GROWTH="${MIN_RSS_GROWTH:-67108864}"
+MIN_GOROUTINE_GROWTH="${MIN_GOROUTINE_GROWTH:-50}"
+
+CONTAINER="nrdot-soak"
+API="http://localhost:8080"
+PPROF_PORTS="1777 2777"
+
+# Colors for output
+GREEN='\033[0;32m'
+RED='\033[0;31m'
+YELLOW='\033[1;33m'
+NC='\033[0m'
+
+# Convert a Go-style duration (90s, 10m, 6h) to seconds
+to_seconds() {
+    local value=$1
+    case "$value" in
+        *h) echo $(( ${value%h} * 3600 )) ;;
+        *m) echo $(( ${value%m} * 60 )) ;;
+        *s) echo "${value%s}" ;;
+        *)  echo "$value" ;;
+    esac
+}
+
+# Function to check service health
+check_health() {
+    local service=$1
+    local url=$2
+    local max_attempts=30
+    local attempt=0
+
+    echo -n "Checking $service health..."
+    while [ $attempt -lt $max_attempts ]; do
+        if curl -sf "$url" > /dev/null 2>&1; then
+            echo -e " ${GREEN}OK${NC}"
+            return 0
+        fi
+        attempt=$((attempt + 1))
+        sleep 2
+    done
+    echo -e " ${RED}FAILED${NC}"
+    return 1
+}
+
+# The newest collector process; during a blue-green reload two run briefly
+collector_pid() {
+    docker exec "$CONTAINER" pgrep -n -f otelcol 2>/dev/null || true
+}
+
+# Resident memory of a process in the container, in bytes
+rss_bytes() {
+    docker exec "$CONTAINER" awk '/^VmRSS:/ {print $2 * 1024}' "/proc/$1/status" 2>/dev/null || true
+}
+
+# Goroutines of the collector from the pprof extension of the active slot.
+# Nothing is printed while both slots answer.
+collector_goroutines() {
+    local found="" count port
+    for port in $PPROF_PORTS; do
+        count=$(curl -sf --max-time 5 "http://localhost:$port/debug/pprof/goroutine?debug=1" 2>/dev/null | awk 'NR == 1 {print $NF}')
+        if [ -n "$count" ]; then
+            [ -n "$found" ] && return 0
+            found=$count
+        fi
+    done
+    echo "$found"
+}
+
+# Goroutines of the supervisor from its /metrics
+supervisor_goroutines() {
+    curl -sf --max-time 5 "$API/metrics" 2>/dev/null | awk '/^nrdot_supervisor_goroutines / {print $2}'
+}
+
+# Append one sample per process metric to samples.csv. Collector series are
+# named after the process ID, since a blue-green reload starts a new process.
+sample() {
+    local now pid value
+    now=$(date +%s)
+
+    value=$(rss_bytes 1)
+    [ -n "$value" ] && echo "$now,supervisor,rss_bytes,$value" >> "$SAMPLES"
+    value=$(supervisor_goroutines)
+    [ -n "$value" ] && echo "$now,supervisor,goroutines,$value" >> "$SAMPLES"
+
+    pid=$(collector_pid)
+    if [ -n "$pid" ]; then
+        value=$(rss_bytes "$pid")
+        [ -n "$value" ] && echo "$now,collector@$pid,rss_bytes,$value" >> "$SAMPLES"
+        value=$(collector_goroutines)
+        [ -n "$value" ] && echo "$now,collector@$pid,goroutines,$value" >> "$SAMPLES"
+    fi
+    return 0
+}
+
+# Reload the collector in-process
+reload_collector() {
+    local pid
+    pid=$(collector_pid)
+    if [ -z "$pid" ]; then
+        echo -e "⚠ ${YELLOW}No collector running to reload${NC}"
+        return 0
+    fi
+    docker exec "$CONTAINER" kill -HUP "$pid"
+    RELOADS=$((RELOADS + 1))
+}
+
+# Change the config and have the supervisor apply it with a blue-green reload
+bluegreen_reload() {
+    if grep -q "reset_interval: 10m" "$CONFIG_DIR/config.yaml"; then
+        sed -i "s/reset_interval: 10m/reset_interval: 11m/" "$CONFIG_DIR/config.yaml"
+    else
+        sed -i "s/reset_interval: 11m/reset_interval: 10m/" "$CONFIG_DIR/config.yaml"
+    fi
+    docker kill -s HUP "$CONTAINER" > /dev/null
+    BLUEGREEN_RELOADS=$((BLUEGREEN_RELOADS + 1))
+}
+
+echo -e "${YELLOW}Starting Soak E2E Test (${SOAK_DURATION})...${NC}"
+
+# Create results directory
+mkdir -p "$RESULTS_DIR"
+SAMPLES="$RESULTS_DIR/samples.csv"
+echo "# unix_seconds,process,metric,value" > "$SAMPLES"
+
+# The config directory is mounted writable so it can change during the run
+CONFIG_DIR="$(mktemp -d)"
+cp "$SCRIPT_DIR/configs/nrdot-config.yaml" "$CONFIG_DIR/config.yaml"
+chmod 0755 "$CONFIG_DIR"
+chmod 0644 "$CONFIG_DIR/config.yaml"
+export SOAK_CONFIG_DIR="$CONFIG_DIR"
+
+# Start services
+echo "Starting services..."
+cd "$SCRIPT_DIR"
+docker-compose up -d --build
+
+# Wait for services to be ready
+echo "Waiting for services to start..."
+sleep 15
+
+check_health "NRDOT" "http://localhost:13133" || exit 1
+check_health "Supervisor API" "$API/health" || exit 1
+
+DURATION_S=$(to_seconds "$SOAK_DURATION")
+SAMPLE_S=$(to_seconds "$SAMPLE_INTERVAL")
+RELOAD_S=$(to_seconds "$RELOAD_INTERVAL")
+BLUEGREEN_S=$(to_seconds "$BLUEGREEN_INTERVAL")
+
+START=$(date +%s)
+END=$((START + DURATION_S))
+NEXT_RELOAD=$((START + RELOAD_S))
+NEXT_BLUEGREEN=$((START + BLUEGREEN_S))
+RELOADS=0
+BLUEGREEN_RELOADS=0
+
+echo -e "\n${YELLOW}Sampling every ${SAMPLE_INTERVAL}, reloading every ${RELOAD_INTERVAL}, blue-green every ${BLUEGREEN_INTERVAL}...${NC}"
+while [ "$(date +%s)" -lt "$END" ]; do
+    sample
+
+    NOW=$(date +%s)
+    if [ "$BLUEGREEN_S" -gt 0 ] && [ "$NOW" -ge "$NEXT_BLUEGREEN" ]; then
+        bluegreen_reload
+        NEXT_BLUEGREEN=$((NOW + BLUEGREEN_S))
+        # The new collector starts its own schedule
+        NEXT_RELOAD=$((NOW + RELOAD_S))
+    elif [ "$RELOAD_S" -gt 0 ] && [ "$NOW" -ge "$NEXT_RELOAD" ]; then
+        reload_collector
+        NEXT_RELOAD=$((NOW + RELOAD_S))
+    fi
+
+    ELAPSED=$(( (NOW - START) / 60 ))
+    if [ $((ELAPSED % 30)) -eq 0 ] && [ "$ELAPSED" != "$LAST_REPORT" ]; then
+        LAST_REPORT=$ELAPSED
+        echo "  ${ELAPSED}m: $(( $(wc -l < "$SAMPLES") - 1 )) samples, $RELOADS reloads, $BLUEGREEN_RELOADS blue-green reloads"
+    fi
+
+    sleep "$SAMPLE_S"
+done
+
+# The collector must have survived the run
+if ! curl -sf "http://localhost:13133" > /dev/null 2>&1 && ! curl -sf "http://localhost:14133" > /dev/null 2>&1; then
+    echo -e "✗ ${RED}Collector is not healthy at the end of the run${NC}"
+    FAILED=true
+fi
+
+# Check for leaks
+echo -e "\n${YELLOW}Checking for leaks...${NC}"
+if go run ./leakcheck \
+    -samples "$SAMPLES" \
+    -report "$RESULTS_DIR/leakcheck.json" \
+    -warmup "$WARMUP" \
+    -max-growth "$MAX_GROWTH" \
+    -min-rss-growth "$MIN_RSS_GROWTH" \
+    -min-goroutine-growth "$MIN_GOROUTINE_GROWTH"; then
+    echo -e "✓ No steady memory or goroutine growth"
+else
+    echo -e "✗ ${RED}Steady growth detected, see $RESULTS_DIR/leakcheck.json${NC}"
+    FAILED=true
+fi
+
+# Get profiling data for the analysis of a failure
+echo -e "\n${YELLOW}Collecting profiling data...${NC}"
+for port in $PPROF_PORTS; do
+    if curl -sf "http://localhost:$port/debug/pprof/" > /dev/null 2>&1; then
+        curl -s "http://localhost:$port/debug/pprof/heap" > "$RESULTS_DIR/heap.pprof"
+        curl -s "http://localhost:$port/debug/pprof/goroutine" > "$RESULTS_DIR/goroutine.pprof"
+        echo -e "✓ Profiling data saved"
+        break
+    fi
+done
+
+# Generate report
+echo -e "\n${YELLOW}Generating test report...${NC}"
+cat > "$RESULTS_DIR/report.json" <<EOF
+{
+  "test": "$TEST_NAME",
+  "timestamp": "$(date -u +"%Y-%m-%dT%H:%M:%SZ")",
+  "status": "${FAILED:-false}",
+  "results": {
+    "duration_seconds": $(( $(date +%s) - START )),
+    "samples": $(( $(wc -l < "$SAMPLES") - 1 )),
+    "reloads": $RELOADS,
+    "bluegreen_reloads": $BLUEGREEN_RELOADS
+  }
+}
+EOF
+
+# Collect logs
+echo "Collecting logs..."
+docker-compose logs > "$RESULTS_DIR/docker-compose.log" 2>&1
+
+# Cleanup if not in debug mode
+if [ -z "$NRDOT_DEBUG" ]; then
+    echo "Cleaning up..."
+    docker-compose down -v
+    rm -rf "$CONFIG_DIR"
+fi
+
+if [ -z "$FAILED" ]; then
+    echo -e "\n${GREEN}Soak E2E Test PASSED${NC}"
+    exit 0
+else
+    echo -e "\n${RED}Soak E2E Test FAILED${NC}"
+    exit 1
+fi
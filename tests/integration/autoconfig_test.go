@@ -9,7 +9,7 @@ import (
 	"time"
 
 	"github.com/newrelic/nrdot-host/nrdot-autoconfig"
-	"github.com/newrelic/nrdot-host/nrdot-common/config"
+	"github.com/newrelic/nrdot-host/nrdot-common/pkg/config"
 	"github.com/newrelic/nrdot-host/nrdot-discovery"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"